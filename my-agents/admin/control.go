@@ -0,0 +1,357 @@
+// Package admin exposes authenticated runtime controls — pause/resume,
+// draining, cache flushes, provider key rotation, workflow toggles and live
+// worker status — so the system can be operated without restarts.
+package admin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+// Errors returned by the gated runner's Emit.
+var (
+	ErrDraining         = errors.New("runner is draining and not accepting new events")
+	ErrWorkflowDisabled = errors.New("workflow is disabled")
+)
+
+// Worker is the live status of one agent.
+type Worker struct {
+	Agent     string        `json:"agent"`
+	Busy      bool          `json:"busy"`
+	RunID     string        `json:"run_id,omitempty"`
+	Since     time.Time     `json:"since,omitzero"` // start of the current job
+	Processed int           `json:"processed"`
+	Failed    int           `json:"failed"`
+	LastError string        `json:"last_error,omitempty"`
+	LastTook  time.Duration `json:"last_took_ns,omitempty"`
+}
+
+// Status is a snapshot of the runner's operating state.
+type Status struct {
+	Paused            bool      `json:"paused"`
+	Draining          bool      `json:"draining"`
+	Held              int       `json:"held"`     // events waiting for resume
+	InFlight          []string  `json:"inflight"` // runs emitted by this process still running
+	DisabledWorkflows []string  `json:"disabled_workflows"`
+	Workers           []Worker  `json:"workers"`
+	Caches            []string  `json:"caches"`
+	StartedAt         time.Time `json:"started_at"`
+}
+
+// Controller gates a runner's intake and tracks its workers.
+type Controller struct {
+	runs    history.Store
+	started time.Time
+
+	mu       sync.Mutex
+	inner    core.Runner
+	paused   bool
+	draining bool
+	held     []core.Event
+	disabled map[string]bool
+	inflight map[string]time.Time // run → when it was emitted
+	workers  map[string]*Worker
+	flushers map[string]func() (int, error)
+	rotate   func(provider, apiKey string) error
+}
+
+// NewController creates a controller. runs is the store the history
+// recorder writes to; it tells the controller when runs finish.
+func NewController(runs history.Store) *Controller {
+	return &Controller{
+		runs:     runs,
+		started:  time.Now(),
+		disabled: make(map[string]bool),
+		inflight: make(map[string]time.Time),
+		workers:  make(map[string]*Worker),
+		flushers: make(map[string]func() (int, error)),
+	}
+}
+
+// Wrap returns runner gated by the controller: while paused, emitted events
+// are held until Resume; while draining, they are rejected.
+func (c *Controller) Wrap(runner core.Runner) core.Runner {
+	c.mu.Lock()
+	c.inner = runner
+	c.mu.Unlock()
+	return &gatedRunner{Runner: runner, c: c}
+}
+
+// RegisterCache makes a cache flushable by name. flush returns how many
+// entries it dropped.
+func (c *Controller) RegisterCache(name string, flush func() (int, error)) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flushers[name] = flush
+}
+
+// SetRotator sets how provider keys are rotated.
+func (c *Controller) SetRotator(rotate func(provider, apiKey string) error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.rotate = rotate
+}
+
+// Register tracks worker status with agent callbacks.
+func (c *Controller) Register(runner core.Runner) error {
+	before := func(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+		runID := ""
+		if args.Event != nil {
+			runID, _ = args.Event.GetMetadataValue(history.RunIDKey)
+		}
+		c.mu.Lock()
+		w := c.worker(args.AgentID)
+		w.Busy, w.RunID, w.Since = true, runID, time.Now()
+		c.mu.Unlock()
+		return args.State, nil
+	}
+	after := func(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+		c.mu.Lock()
+		w := c.worker(args.AgentID)
+		w.Processed++
+		if args.Error != nil {
+			w.Failed++
+			w.LastError = args.Error.Error()
+		}
+		w.LastTook = time.Since(w.Since)
+		w.Busy, w.RunID, w.Since = false, "", time.Time{}
+		c.mu.Unlock()
+		return args.State, nil
+	}
+	if err := runner.RegisterCallback(core.HookBeforeAgentRun, "admin-workers", before); err != nil {
+		return err
+	}
+	return runner.RegisterCallback(core.HookAfterAgentRun, "admin-workers", after)
+}
+
+// worker returns the agent's status entry. Callers hold c.mu.
+func (c *Controller) worker(agent string) *Worker {
+	w, ok := c.workers[agent]
+	if !ok {
+		w = &Worker{Agent: agent}
+		c.workers[agent] = w
+	}
+	return w
+}
+
+// emit applies the gate to an event.
+func (c *Controller) emit(event core.Event) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	runID, continuing := event.GetMetadataValue(history.RunIDKey)
+	if c.draining {
+		return ErrDraining
+	}
+	if !continuing || runID == "" {
+		route, _ := event.GetMetadataValue(core.RouteMetadataKey)
+		if c.disabled[route] {
+			return fmt.Errorf("%w: %s", ErrWorkflowDisabled, route)
+		}
+		runID = event.GetID()
+	}
+	if _, ok := c.inflight[runID]; !ok {
+		c.inflight[runID] = time.Now()
+	}
+	if c.paused {
+		c.held = append(c.held, event)
+		return nil
+	}
+	return c.inner.Emit(event)
+}
+
+// Pause holds newly emitted events. Events already in the runner finish.
+func (c *Controller) Pause() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused = true
+}
+
+// Resume releases held events and accepts new ones again, ending a drain.
+func (c *Controller) Resume() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.paused, c.draining = false, false
+	return c.release()
+}
+
+// release emits the held events. Callers hold c.mu.
+func (c *Controller) release() error {
+	held := c.held
+	c.held = nil
+	var errs []error
+	for _, event := range held {
+		if err := c.inner.Emit(event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Drain stops accepting events, releases held ones and waits until every
+// run emitted by this process has finished or ctx is done. The runner
+// stays closed to new events until Resume. It returns the runs still in
+// flight.
+func (c *Controller) Drain(ctx context.Context) ([]string, error) {
+	c.mu.Lock()
+	c.draining, c.paused = true, false
+	err := c.release()
+	c.mu.Unlock()
+	if err != nil {
+		return c.pending(ctx), err
+	}
+
+	ticker := time.NewTicker(200 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		left := c.pending(ctx)
+		if len(left) == 0 {
+			return nil, nil
+		}
+		select {
+		case <-ctx.Done():
+			return left, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// unrecordedTimeout is how long a run may go without a history record
+// before it is assumed dropped (e.g. rejected by a callback).
+const unrecordedTimeout = 10 * time.Minute
+
+// pending prunes finished runs from the in-flight set and returns the rest.
+func (c *Controller) pending(ctx context.Context) []string {
+	c.mu.Lock()
+	ids := make(map[string]time.Time, len(c.inflight))
+	for id, at := range c.inflight {
+		ids[id] = at
+	}
+	heldRuns := make(map[string]bool, len(c.held))
+	for _, event := range c.held {
+		id, ok := event.GetMetadataValue(history.RunIDKey)
+		if !ok || id == "" {
+			id = event.GetID()
+		}
+		heldRuns[id] = true
+	}
+	c.mu.Unlock()
+
+	var left []string
+	for id, at := range ids {
+		// A run's first save happens after its first step
+		run, err := c.runs.Get(ctx, id)
+		unrecorded := errors.Is(err, history.ErrNotFound) && time.Since(at) < unrecordedTimeout
+		failed := err != nil && !errors.Is(err, history.ErrNotFound)
+		if heldRuns[id] || unrecorded || failed || (err == nil && run.Status == history.StatusRunning) {
+			left = append(left, id)
+			continue
+		}
+		c.mu.Lock()
+		delete(c.inflight, id)
+		c.mu.Unlock()
+	}
+	sort.Strings(left)
+	return left
+}
+
+// WorkflowEnabled reports whether new runs may enter at route.
+func (c *Controller) WorkflowEnabled(route string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.disabled[route]
+}
+
+// SetWorkflow enables or disables new runs entering at route.
+func (c *Controller) SetWorkflow(route string, enabled bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if enabled {
+		delete(c.disabled, route)
+	} else {
+		c.disabled[route] = true
+	}
+}
+
+// Flush flushes the named cache, or every cache when name is empty, and
+// returns the entries dropped per cache.
+func (c *Controller) Flush(name string) (map[string]int, error) {
+	c.mu.Lock()
+	flushers := make(map[string]func() (int, error))
+	for n, f := range c.flushers {
+		if name == "" || n == name {
+			flushers[n] = f
+		}
+	}
+	c.mu.Unlock()
+	if name != "" && len(flushers) == 0 {
+		return nil, fmt.Errorf("unknown cache %q", name)
+	}
+	dropped := make(map[string]int, len(flushers))
+	var errs []error
+	for n, f := range flushers {
+		count, err := f()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", n, err))
+		}
+		dropped[n] = count
+	}
+	return dropped, errors.Join(errs...)
+}
+
+// RotateKey swaps a provider's API key.
+func (c *Controller) RotateKey(provider, apiKey string) error {
+	c.mu.Lock()
+	rotate := c.rotate
+	c.mu.Unlock()
+	if rotate == nil {
+		return errors.New("key rotation is not available")
+	}
+	return rotate(provider, apiKey)
+}
+
+// Status returns a snapshot of the controller's state.
+func (c *Controller) Status(ctx context.Context) Status {
+	inflight := c.pending(ctx)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st := Status{
+		Paused:            c.paused,
+		Draining:          c.draining,
+		Held:              len(c.held),
+		InFlight:          append([]string{}, inflight...),
+		DisabledWorkflows: []string{},
+		Workers:           []Worker{},
+		Caches:            []string{},
+		StartedAt:         c.started,
+	}
+	for route := range c.disabled {
+		st.DisabledWorkflows = append(st.DisabledWorkflows, route)
+	}
+	sort.Strings(st.DisabledWorkflows)
+	for _, w := range c.workers {
+		st.Workers = append(st.Workers, *w)
+	}
+	sort.Slice(st.Workers, func(i, j int) bool { return st.Workers[i].Agent < st.Workers[j].Agent })
+	for name := range c.flushers {
+		st.Caches = append(st.Caches, name)
+	}
+	sort.Strings(st.Caches)
+	return st
+}
+
+type gatedRunner struct {
+	core.Runner
+	c *Controller
+}
+
+func (r *gatedRunner) Emit(event core.Event) error {
+	return r.c.emit(event)
+}
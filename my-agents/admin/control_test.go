@@ -0,0 +1,164 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+// runner records emitted events and the callbacks registered on it.
+type runner struct {
+	core.Runner
+	emitted   []core.Event
+	callbacks map[core.HookPoint]core.CallbackFunc
+}
+
+func (r *runner) Emit(event core.Event) error {
+	r.emitted = append(r.emitted, event)
+	return nil
+}
+
+func (r *runner) RegisterCallback(hook core.HookPoint, name string, cb core.CallbackFunc) error {
+	if r.callbacks == nil {
+		r.callbacks = make(map[core.HookPoint]core.CallbackFunc)
+	}
+	r.callbacks[hook] = cb
+	return nil
+}
+
+func TestPauseResume(t *testing.T) {
+	c := NewController(history.NewMemoryStore())
+	inner := &runner{}
+	r := c.Wrap(inner)
+
+	c.Pause()
+	if err := r.Emit(core.NewEvent("a", nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if len(inner.emitted) != 0 {
+		t.Fatal("event passed while paused")
+	}
+	if st := c.Status(context.Background()); !st.Paused || st.Held != 1 || len(st.InFlight) != 1 {
+		t.Errorf("status = %+v, want one held event in flight", st)
+	}
+	if err := c.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if len(inner.emitted) != 1 {
+		t.Errorf("emitted %d events after resume, want 1", len(inner.emitted))
+	}
+}
+
+func TestWorkflowToggle(t *testing.T) {
+	c := NewController(history.NewMemoryStore())
+	inner := &runner{}
+	r := c.Wrap(inner)
+	c.SetWorkflow("support", false)
+
+	event := core.NewEvent("a", nil, map[string]string{core.RouteMetadataKey: "support"})
+	if err := r.Emit(event); !errors.Is(err, ErrWorkflowDisabled) {
+		t.Errorf("new run = %v, want ErrWorkflowDisabled", err)
+	}
+	cont := core.NewEvent("a", nil, map[string]string{core.RouteMetadataKey: "support", history.RunIDKey: "run-1"})
+	if err := r.Emit(cont); err != nil {
+		t.Errorf("continuing run = %v, want it let through", err)
+	}
+	if st := c.Status(context.Background()); !reflect.DeepEqual(st.DisabledWorkflows, []string{"support"}) {
+		t.Errorf("disabled = %v", st.DisabledWorkflows)
+	}
+	c.SetWorkflow("support", true)
+	if !c.WorkflowEnabled("support") || r.Emit(event) != nil {
+		t.Error("workflow still disabled")
+	}
+}
+
+func TestDrain(t *testing.T) {
+	runs := history.NewMemoryStore()
+	c := NewController(runs)
+	inner := &runner{}
+	r := c.Wrap(inner)
+	ctx := context.Background()
+
+	event := core.NewEvent("a", nil, nil)
+	if err := r.Emit(event); err != nil {
+		t.Fatal(err)
+	}
+	runs.Save(ctx, &history.Run{ID: event.GetID(), Status: history.StatusRunning})
+
+	short, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	left, err := c.Drain(short)
+	if err == nil || !reflect.DeepEqual(left, []string{event.GetID()}) {
+		t.Fatalf("Drain = %v, %v; want the running run left", left, err)
+	}
+	if err := r.Emit(core.NewEvent("a", nil, nil)); !errors.Is(err, ErrDraining) {
+		t.Errorf("emit while draining = %v", err)
+	}
+
+	runs.Save(ctx, &history.Run{ID: event.GetID(), Status: history.StatusCompleted})
+	if left, err := c.Drain(ctx); err != nil || len(left) != 0 {
+		t.Errorf("Drain = %v, %v after the run finished", left, err)
+	}
+	if err := c.Resume(); err != nil || r.Emit(core.NewEvent("a", nil, nil)) != nil {
+		t.Error("runner still closed after resume")
+	}
+}
+
+func TestFlush(t *testing.T) {
+	c := NewController(history.NewMemoryStore())
+	c.RegisterCache("llm", func() (int, error) { return 3, nil })
+	c.RegisterCache("tools", func() (int, error) { return 1, errors.New("locked") })
+
+	got, err := c.Flush("llm")
+	if err != nil || !reflect.DeepEqual(got, map[string]int{"llm": 3}) {
+		t.Errorf("Flush(llm) = %v, %v", got, err)
+	}
+	got, err = c.Flush("")
+	if err == nil || len(got) != 2 {
+		t.Errorf("Flush() = %v, %v; want both caches and the error", got, err)
+	}
+	if _, err := c.Flush("prompts"); err == nil {
+		t.Error("unknown cache flushed")
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	c := NewController(history.NewMemoryStore())
+	if err := c.RotateKey("openai", "sk-new"); err == nil {
+		t.Error("rotated without a rotator")
+	}
+	var got string
+	c.SetRotator(func(provider, key string) error {
+		got = provider + "=" + key
+		return nil
+	})
+	if err := c.RotateKey("openai", "sk-new"); err != nil || got != "openai=sk-new" {
+		t.Errorf("RotateKey = %v, rotator saw %q", err, got)
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	c := NewController(history.NewMemoryStore())
+	r := &runner{}
+	if err := c.Register(r); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	event := core.NewEvent("writer", nil, map[string]string{history.RunIDKey: "run-1"})
+	r.callbacks[core.HookBeforeAgentRun](ctx, core.CallbackArgs{AgentID: "writer", Event: event})
+	st := c.Status(ctx)
+	if len(st.Workers) != 1 || !st.Workers[0].Busy || st.Workers[0].RunID != "run-1" {
+		t.Fatalf("workers = %+v, want writer busy on run-1", st.Workers)
+	}
+	r.callbacks[core.HookAfterAgentRun](ctx, core.CallbackArgs{AgentID: "writer", Event: event, Error: errors.New("boom")})
+	w := c.Status(ctx).Workers[0]
+	if w.Busy || w.Processed != 1 || w.Failed != 1 || w.LastError != "boom" {
+		t.Errorf("worker = %+v", w)
+	}
+}
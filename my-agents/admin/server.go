@@ -0,0 +1,213 @@
+package admin
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Config is the [admin] section of agentflow.toml.
+type Config struct {
+	Enabled bool   `toml:"enabled"`
+	Addr    string `toml:"addr"` // listen address (default 127.0.0.1:9090)
+	// TokenEnv names the env var holding the bearer token required on every
+	// request (default AGENTFLOW_ADMIN_TOKEN). The server won't start
+	// without one.
+	TokenEnv string `toml:"token_env"`
+	// DeploymentsPath is where blue/green workflow versions are kept
+	// (default .agentflow/deployments.json).
+	DeploymentsPath string `toml:"deployments_path"`
+	// BreakpointTimeout is how long a run paused at a breakpoint waits to
+	// be continued before going on by itself (default 10m).
+	BreakpointTimeout string `toml:"breakpoint_timeout"`
+}
+
+// Server serves the admin API:
+//
+//	GET  /admin/status
+//	POST /admin/pause
+//	POST /admin/resume
+//	POST /admin/drain?timeout=30s
+//	POST /admin/caches/flush[?name=...]
+//	POST /admin/providers/{name}/rotate   {"api_key": "..."} or {"api_key_env": "VAR"}
+//	POST /admin/workflows/{route}/enable
+//	POST /admin/workflows/{route}/disable
+type Server struct {
+	ctl      *Controller
+	token    string
+	mux      *http.ServeMux
+	onAccess func(r *http.Request, status int, authorized bool)
+}
+
+// NewServer creates the API over ctl, reading its token from cfg.TokenEnv.
+func NewServer(cfg Config, ctl *Controller) (*Server, error) {
+	env := cfg.TokenEnv
+	if env == "" {
+		env = "AGENTFLOW_ADMIN_TOKEN"
+	}
+	token := os.Getenv(env)
+	if token == "" {
+		return nil, fmt.Errorf("admin API needs a token in %s", env)
+	}
+	s := &Server{ctl: ctl, token: token, mux: http.NewServeMux()}
+	s.routes()
+	return s, nil
+}
+
+// Mount adds extra handlers (e.g. usage reports) under the admin API's
+// authentication.
+func (s *Server) Mount(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, h)
+}
+
+func (s *Server) routes() {
+	s.mux.HandleFunc("GET /admin/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.ctl.Status(r.Context()))
+	})
+	s.mux.HandleFunc("POST /admin/pause", func(w http.ResponseWriter, r *http.Request) {
+		s.ctl.Pause()
+		writeJSON(w, http.StatusOK, s.ctl.Status(r.Context()))
+	})
+	s.mux.HandleFunc("POST /admin/resume", func(w http.ResponseWriter, r *http.Request) {
+		if err := s.ctl.Resume(); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, s.ctl.Status(r.Context()))
+	})
+	s.mux.HandleFunc("POST /admin/drain", func(w http.ResponseWriter, r *http.Request) {
+		timeout := 30 * time.Second
+		if t := r.URL.Query().Get("timeout"); t != "" {
+			d, err := time.ParseDuration(t)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			timeout = d
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		left, err := s.ctl.Drain(ctx)
+		status := http.StatusOK
+		if err != nil {
+			status = http.StatusAccepted // still draining; poll /admin/status
+		}
+		writeJSON(w, status, map[string]any{"drained": len(left) == 0, "inflight": left})
+	})
+	s.mux.HandleFunc("POST /admin/caches/flush", func(w http.ResponseWriter, r *http.Request) {
+		dropped, err := s.ctl.Flush(r.URL.Query().Get("name"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"flushed": dropped})
+	})
+	s.mux.HandleFunc("POST /admin/providers/{name}/rotate", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			APIKey    string `json:"api_key"`
+			APIKeyEnv string `json:"api_key_env"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		key := body.APIKey
+		if body.APIKeyEnv != "" {
+			key = os.Getenv(body.APIKeyEnv)
+		}
+		if key == "" {
+			writeError(w, http.StatusBadRequest, errors.New("api_key or a non-empty api_key_env is required"))
+			return
+		}
+		name := r.PathValue("name")
+		if err := s.ctl.RotateKey(name, key); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		core.Logger().Info().Str("provider", name).Msg("Provider key rotated via admin API")
+		writeJSON(w, http.StatusOK, map[string]string{"rotated": name})
+	})
+	for _, action := range []string{"enable", "disable"} {
+		s.mux.HandleFunc("POST /admin/workflows/{route}/"+action, func(w http.ResponseWriter, r *http.Request) {
+			s.ctl.SetWorkflow(r.PathValue("route"), action == "enable")
+			writeJSON(w, http.StatusOK, s.ctl.Status(r.Context()))
+		})
+	}
+}
+
+// OnAccess sets a function called after every request with the response
+// status and whether the request carried a valid token, to keep an access
+// log.
+func (s *Server) OnAccess(fn func(r *http.Request, status int, authorized bool)) {
+	s.onAccess = fn
+}
+
+// ServeHTTP authenticates the request and dispatches it.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	authorized := ok && subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+	if s.onAccess != nil {
+		defer func() { s.onAccess(r, rec.status, authorized) }()
+	}
+	if !authorized {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="admin"`)
+		writeError(rec, http.StatusUnauthorized, errors.New("missing or invalid admin token"))
+		return
+	}
+	s.mux.ServeHTTP(rec, r)
+}
+
+// statusRecorder remembers the status a handler writes.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// Flush lets streaming handlers flush through the recorder.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// ListenAndServe serves on addr until ctx is done.
+func (s *Server) ListenAndServe(ctx context.Context, addr string) error {
+	if addr == "" {
+		addr = "127.0.0.1:9090"
+	}
+	srv := &http.Server{Addr: addr, Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
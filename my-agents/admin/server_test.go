@@ -0,0 +1,106 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"my-agents/history"
+)
+
+func newServer(t *testing.T) (*Server, *Controller) {
+	t.Helper()
+	t.Setenv("AGENTFLOW_ADMIN_TOKEN", "secret")
+	c := NewController(history.NewMemoryStore())
+	c.Wrap(&runner{})
+	s, err := NewServer(Config{}, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, c
+}
+
+func do(s *Server, method, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer secret")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestNewServerNeedsToken(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "")
+	if _, err := NewServer(Config{TokenEnv: "ADMIN_TOKEN"}, nil); err == nil {
+		t.Error("server created without a token")
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	s, _ := newServer(t)
+	var logged []int
+	s.OnAccess(func(r *http.Request, status int, authorized bool) {
+		if authorized {
+			logged = append(logged, status)
+		} else {
+			logged = append(logged, -status)
+		}
+	})
+
+	r := httptest.NewRequest("GET", "/admin/status", nil)
+	r.Header.Set("Authorization", "Bearer wrong")
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized || w.Header().Get("WWW-Authenticate") == "" {
+		t.Errorf("wrong token: status %d", w.Code)
+	}
+	if w := do(s, "GET", "/admin/status", ""); w.Code != http.StatusOK {
+		t.Errorf("valid token: status %d", w.Code)
+	}
+	if len(logged) != 2 || logged[0] != -http.StatusUnauthorized || logged[1] != http.StatusOK {
+		t.Errorf("access log = %v", logged)
+	}
+}
+
+func TestRoutes(t *testing.T) {
+	s, c := newServer(t)
+	c.RegisterCache("llm", func() (int, error) { return 2, nil })
+	var rotated string
+	c.SetRotator(func(provider, key string) error {
+		rotated = provider + "=" + key
+		return nil
+	})
+	t.Setenv("NEW_KEY", "sk-env")
+
+	w := do(s, "POST", "/admin/pause", "")
+	var st Status
+	json.NewDecoder(w.Body).Decode(&st)
+	if !st.Paused {
+		t.Errorf("pause: %s", w.Body)
+	}
+	if w := do(s, "POST", "/admin/resume", ""); w.Code != http.StatusOK {
+		t.Errorf("resume: status %d", w.Code)
+	}
+	if w := do(s, "POST", "/admin/workflows/support/disable", ""); c.WorkflowEnabled("support") {
+		t.Errorf("disable: %s", w.Body)
+	}
+	if w := do(s, "POST", "/admin/caches/flush?name=llm", ""); !strings.Contains(w.Body.String(), `"llm":2`) {
+		t.Errorf("flush: %s", w.Body)
+	}
+	if w := do(s, "POST", "/admin/caches/flush?name=nope", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("flush unknown: status %d", w.Code)
+	}
+	if w := do(s, "POST", "/admin/providers/openai/rotate", `{"api_key_env":"NEW_KEY"}`); w.Code != http.StatusOK || rotated != "openai=sk-env" {
+		t.Errorf("rotate: status %d, rotated %q", w.Code, rotated)
+	}
+	if w := do(s, "POST", "/admin/providers/openai/rotate", `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("rotate without a key: status %d", w.Code)
+	}
+	if w := do(s, "POST", "/admin/drain?timeout=1s", ""); !strings.Contains(w.Body.String(), `"drained":true`) {
+		t.Errorf("drain: %s", w.Body)
+	}
+	if w := do(s, "POST", "/admin/drain?timeout=soon", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("drain with a bad timeout: status %d", w.Code)
+	}
+}
@@ -0,0 +1,297 @@
+// Package anthropic calls Claude models through Anthropic's Messages API.
+// The prompt's system text goes in the request's system field rather than
+// as a message, max_tokens (which the API requires) defaults when unset, and
+// replies stream as server-sent events.
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/httpclient"
+	"my-agents/ratelimit"
+)
+
+// Type is the provider type of a [providers.<name>] table.
+const Type = "anthropic"
+
+// Defaults for unset settings.
+const (
+	DefaultBaseURL   = "https://api.anthropic.com"
+	DefaultVersion   = "2023-06-01"
+	DefaultMaxTokens = 4096
+	DefaultTimeout   = 2 * time.Minute
+)
+
+// KeyEnv holds the API key when the table has no api_key.
+const KeyEnv = "ANTHROPIC_API_KEY"
+
+// Options are the settings of an anthropic [providers.<name>] table besides
+// agenticgokit's type, model, api_key, base_url, max_tokens, temperature
+// and http_timeout:
+//
+//	[providers.claude]
+//	type = "anthropic"
+//	model = "claude-sonnet-4-5"
+//	max_tokens = 8192
+type Options struct {
+	// Version is the anthropic-version header (default DefaultVersion).
+	Version string `toml:"anthropic_version"`
+	// Beta lists anthropic-beta features to enable.
+	Beta []string `toml:"anthropic_beta"`
+}
+
+// Provider calls one Claude model.
+type Provider struct {
+	http        *http.Client
+	baseURL     string
+	apiKey      string
+	version     string
+	beta        string
+	model       string
+	maxTokens   int
+	temperature float64
+}
+
+// New creates the provider cfg configures.
+func New(cfg core.LLMProviderConfig, opts Options) (*Provider, error) {
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("anthropic provider needs a model")
+	}
+	key := cfg.APIKey
+	if key == "" {
+		key = os.Getenv(KeyEnv)
+	}
+	if key == "" {
+		return nil, fmt.Errorf("anthropic provider needs api_key or $%s", KeyEnv)
+	}
+	p := &Provider{
+		http:        httpclient.New(DefaultTimeout),
+		baseURL:     DefaultBaseURL,
+		apiKey:      key,
+		version:     DefaultVersion,
+		beta:        strings.Join(opts.Beta, ","),
+		model:       cfg.Model,
+		maxTokens:   DefaultMaxTokens,
+		temperature: cfg.Temperature,
+	}
+	if cfg.BaseURL != "" {
+		p.baseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	}
+	if cfg.HTTPTimeout > 0 {
+		p.http = httpclient.New(cfg.HTTPTimeout)
+	}
+	if opts.Version != "" {
+		p.version = opts.Version
+	}
+	if cfg.MaxTokens > 0 {
+		p.maxTokens = cfg.MaxTokens
+	}
+	return p, nil
+}
+
+// message is a Messages API request's body.
+type message struct {
+	Model       string    `json:"model"`
+	System      string    `json:"system,omitempty"`
+	Messages    []content `json:"messages"`
+	MaxTokens   int       `json:"max_tokens"`
+	Temperature *float64  `json:"temperature,omitempty"`
+	Stream      bool      `json:"stream,omitempty"`
+}
+
+type content struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+// usage is the token count of a reply, or of part of a streamed one.
+type usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// request builds prompt's body; the prompt's parameters win over the
+// table's.
+func (p *Provider) request(prompt core.Prompt, stream bool) (message, error) {
+	if strings.TrimSpace(prompt.User) == "" {
+		return message{}, fmt.Errorf("anthropic: prompt has no user message")
+	}
+	m := message{
+		Model:     p.model,
+		System:    prompt.System,
+		Messages:  []content{{Role: "user", Content: prompt.User}},
+		MaxTokens: p.maxTokens,
+		Stream:    stream,
+	}
+	if t := prompt.Parameters.MaxTokens; t != nil && *t > 0 {
+		m.MaxTokens = int(*t)
+	}
+	if t := prompt.Parameters.Temperature; t != nil {
+		v := float64(*t)
+		m.Temperature = &v
+	} else if p.temperature > 0 {
+		m.Temperature = &p.temperature
+	}
+	return m, nil
+}
+
+// post sends m and returns the response body, which the caller closes.
+// Error responses are reported with their status and Anthropic's error
+// type ("overloaded_error", "rate_limit_error"), which the retry and
+// fallback policies classify.
+func (p *Provider) post(ctx context.Context, m message) (io.ReadCloser, error) {
+	body, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.baseURL+"/v1/messages", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-api-key", p.apiKey)
+	req.Header.Set("anthropic-version", p.version)
+	if p.beta != "" {
+		req.Header.Set("anthropic-beta", p.beta)
+	}
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("anthropic: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, ratelimit.WithRetryAfter(fmt.Errorf("anthropic: messages API returned status %d: %s", resp.StatusCode, errorMessage(msg)), resp.Header)
+	}
+	return resp.Body, nil
+}
+
+// errorMessage renders an error body as "type: message", or as it is when
+// it isn't Anthropic's error JSON.
+func errorMessage(body []byte) string {
+	var e struct {
+		Error struct {
+			Type    string `json:"type"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &e) != nil || e.Error.Type == "" {
+		return strings.TrimSpace(string(body))
+	}
+	return e.Error.Type + ": " + e.Error.Message
+}
+
+func (p *Provider) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	m, err := p.request(prompt, false)
+	if err != nil {
+		return core.Response{}, err
+	}
+	body, err := p.post(ctx, m)
+	if err != nil {
+		return core.Response{}, err
+	}
+	defer body.Close()
+	var out struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		StopReason string `json:"stop_reason"`
+		Usage      usage  `json:"usage"`
+	}
+	if err := json.NewDecoder(body).Decode(&out); err != nil {
+		return core.Response{}, fmt.Errorf("anthropic: failed to decode response: %w", err)
+	}
+	var text strings.Builder
+	for _, c := range out.Content {
+		if c.Type == "text" {
+			text.WriteString(c.Text)
+		}
+	}
+	return core.Response{
+		Content:      text.String(),
+		Usage:        core.UsageStats{PromptTokens: out.Usage.InputTokens, CompletionTokens: out.Usage.OutputTokens, TotalTokens: out.Usage.InputTokens + out.Usage.OutputTokens},
+		FinishReason: out.StopReason,
+	}, nil
+}
+
+// Stream sends the text deltas of a streamed reply until message_stop. An
+// error event, such as an overload mid-reply, ends the stream with it.
+func (p *Provider) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	m, err := p.request(prompt, true)
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.post(ctx, m)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make(chan core.Token)
+	go func() {
+		defer close(tokens)
+		defer body.Close()
+		send := func(t core.Token) bool {
+			select {
+			case tokens <- t:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			data, ok := bytes.CutPrefix(scanner.Bytes(), []byte("data:"))
+			if !ok {
+				continue // event names and blank lines; the data says its type
+			}
+			var event struct {
+				Type  string `json:"type"`
+				Delta struct {
+					Type string `json:"type"`
+					Text string `json:"text"`
+				} `json:"delta"`
+				Error struct {
+					Type    string `json:"type"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(bytes.TrimSpace(data), &event); err != nil {
+				send(core.Token{Error: fmt.Errorf("anthropic: bad stream event: %w", err)})
+				return
+			}
+			switch event.Type {
+			case "content_block_delta":
+				if event.Delta.Type == "text_delta" && event.Delta.Text != "" && !send(core.Token{Content: event.Delta.Text}) {
+					return
+				}
+			case "error":
+				send(core.Token{Error: fmt.Errorf("anthropic: stream failed: %s: %s", event.Error.Type, event.Error.Message)})
+				return
+			case "message_stop":
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			send(core.Token{Error: fmt.Errorf("anthropic: %w", err)})
+		}
+	}()
+	return tokens, nil
+}
+
+// Embeddings fails: Anthropic has no embeddings API, so retrieval takes
+// its [retrieval.embedding] provider from elsewhere.
+func (p *Provider) Embeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	return nil, fmt.Errorf("anthropic: no embeddings API; set [retrieval.embedding] to another provider")
+}
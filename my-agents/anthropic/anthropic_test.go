@@ -0,0 +1,193 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/ratelimit"
+)
+
+// serve starts a Messages API answering with respond, keeping the last
+// request in got and its headers in header.
+func serve(t *testing.T, got *message, header *http.Header, respond func(w http.ResponseWriter)) *Provider {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/messages" {
+			http.NotFound(w, r)
+			return
+		}
+		*got = message{}
+		json.NewDecoder(r.Body).Decode(got)
+		*header = r.Header.Clone()
+		respond(w)
+	}))
+	t.Cleanup(srv.Close)
+	p, err := New(core.LLMProviderConfig{Model: "claude-sonnet-4-5", APIKey: "sk-ant", BaseURL: srv.URL + "/"}, Options{Beta: []string{"a", "b"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestNew(t *testing.T) {
+	t.Setenv(KeyEnv, "")
+	if _, err := New(core.LLMProviderConfig{Model: "claude-sonnet-4-5"}, Options{}); err == nil {
+		t.Error("created without a key")
+	}
+	if _, err := New(core.LLMProviderConfig{APIKey: "sk-ant"}, Options{}); err == nil {
+		t.Error("created without a model")
+	}
+	t.Setenv(KeyEnv, "sk-env")
+	p, err := New(core.LLMProviderConfig{Model: "claude-sonnet-4-5", Temperature: 0.3}, Options{Version: "2024-01-01"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.apiKey != "sk-env" || p.baseURL != DefaultBaseURL || p.version != "2024-01-01" || p.maxTokens != DefaultMaxTokens {
+		t.Errorf("provider = %+v", p)
+	}
+}
+
+func TestRequest(t *testing.T) {
+	p := &Provider{model: "m", maxTokens: 1000, temperature: 0.3}
+	if _, err := p.request(core.Prompt{System: "Be brief."}, false); err == nil {
+		t.Error("prompt without a user message accepted")
+	}
+	m, _ := p.request(core.Prompt{System: "Be brief.", User: "hi"}, true)
+	if m.System != "Be brief." || len(m.Messages) != 1 || m.MaxTokens != 1000 || *m.Temperature != 0.3 || !m.Stream {
+		t.Errorf("request = %+v", m)
+	}
+	maxTokens, temperature := int32(50), float32(0)
+	m, _ = p.request(core.Prompt{User: "hi", Parameters: core.ModelParameters{MaxTokens: &maxTokens, Temperature: &temperature}}, false)
+	if m.MaxTokens != 50 || *m.Temperature != 0 {
+		t.Errorf("prompt's parameters = %+v", m)
+	}
+	p.temperature = 0
+	if m, _ := p.request(core.Prompt{User: "hi"}, false); m.Temperature != nil {
+		t.Errorf("temperature = %v", *m.Temperature)
+	}
+}
+
+func TestCall(t *testing.T) {
+	var got message
+	var header http.Header
+	p := serve(t, &got, &header, func(w http.ResponseWriter) {
+		fmt.Fprint(w, `{"content": [{"type": "text", "text": "Go "}, {"type": "tool_use"}, {"type": "text", "text": "is fast."}], "stop_reason": "end_turn", "usage": {"input_tokens": 10, "output_tokens": 4}}`)
+	})
+	resp, err := p.Call(context.Background(), core.Prompt{System: "Be brief.", User: "Is Go fast?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Go is fast." || resp.FinishReason != "end_turn" || resp.Usage.TotalTokens != 14 {
+		t.Errorf("response = %+v", resp)
+	}
+	if got.System != "Be brief." || got.Messages[0].Content != "Is Go fast?" || got.Stream {
+		t.Errorf("request = %+v", got)
+	}
+	if header.Get("x-api-key") != "sk-ant" || header.Get("anthropic-version") != DefaultVersion || header.Get("anthropic-beta") != "a,b" {
+		t.Errorf("headers = %v", header)
+	}
+}
+
+func TestCallErrors(t *testing.T) {
+	var got message
+	var header http.Header
+	status, body := http.StatusServiceUnavailable, `{"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}`
+	p := serve(t, &got, &header, func(w http.ResponseWriter) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	})
+	if _, err := p.Call(context.Background(), core.Prompt{User: "hi"}); err == nil || err.Error() != "anthropic: messages API returned status 503: overloaded_error: Overloaded" {
+		t.Errorf("overloaded: %v", err)
+	}
+	status, body = http.StatusBadGateway, "upstream down\n"
+	if _, err := p.Call(context.Background(), core.Prompt{User: "hi"}); err == nil || err.Error() != "anthropic: messages API returned status 502: upstream down" {
+		t.Errorf("not Anthropic's error: %v", err)
+	}
+	status, body = http.StatusOK, "{"
+	if _, err := p.Call(context.Background(), core.Prompt{User: "hi"}); err == nil || !strings.Contains(err.Error(), "failed to decode response") {
+		t.Errorf("bad response: %v", err)
+	}
+	if _, err := p.Call(context.Background(), core.Prompt{}); err == nil {
+		t.Error("called without a user message")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var got message
+	var header http.Header
+	p := serve(t, &got, &header, func(w http.ResponseWriter) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"type": "error", "error": {"type": "rate_limit_error", "message": "Slow down"}}`)
+	})
+	_, err := p.Call(context.Background(), core.Prompt{User: "hi"})
+	var ra *ratelimit.RetryAfterError
+	if !errors.As(err, &ra) || ra.Wait != 2*time.Second {
+		t.Errorf("rate limited: %#v", err)
+	}
+}
+
+func TestStream(t *testing.T) {
+	var got message
+	var header http.Header
+	events := []string{
+		`{"type": "message_start"}`,
+		`{"type": "content_block_delta", "delta": {"type": "text_delta", "text": "Go "}}`,
+		`{"type": "content_block_delta", "delta": {"type": "input_json_delta"}}`,
+		`{"type": "ping"}`,
+		`{"type": "content_block_delta", "delta": {"type": "text_delta", "text": "is fast."}}`,
+		`{"type": "message_stop"}`,
+		`{"type": "content_block_delta", "delta": {"type": "text_delta", "text": "ignored"}}`,
+	}
+	p := serve(t, &got, &header, func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range events {
+			fmt.Fprintf(w, "event: x\ndata: %s\n\n", e)
+		}
+	})
+	stream := func() (string, error) {
+		t.Helper()
+		tokens, err := p.Stream(context.Background(), core.Prompt{User: "hi"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var text strings.Builder
+		for tok := range tokens {
+			if tok.Error != nil {
+				return text.String(), tok.Error
+			}
+			text.WriteString(tok.Content)
+		}
+		return text.String(), nil
+	}
+	if text, err := stream(); text != "Go is fast." || err != nil || !got.Stream {
+		t.Errorf("streamed %q, %v", text, err)
+	}
+
+	events = []string{
+		`{"type": "content_block_delta", "delta": {"type": "text_delta", "text": "Go "}}`,
+		`{"type": "error", "error": {"type": "overloaded_error", "message": "Overloaded"}}`,
+	}
+	if text, err := stream(); text != "Go " || err == nil || err.Error() != "anthropic: stream failed: overloaded_error: Overloaded" {
+		t.Errorf("failed stream: %q, %v", text, err)
+	}
+	events = []string{"{oops"}
+	if _, err := stream(); err == nil || !strings.Contains(err.Error(), "bad stream event") {
+		t.Errorf("bad event: %v", err)
+	}
+}
+
+func TestEmbeddings(t *testing.T) {
+	if _, err := (&Provider{}).Embeddings(context.Background(), []string{"a"}); err == nil {
+		t.Error("embedded")
+	}
+}
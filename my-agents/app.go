@@ -0,0 +1,1262 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/admin"
+	"my-agents/anthropic"
+	"my-agents/appconfig"
+	"my-agents/audit"
+	"my-agents/bedrock"
+	"my-agents/billing"
+	"my-agents/blackboard"
+	"my-agents/bus"
+	"my-agents/catalog"
+	"my-agents/coldstore"
+	"my-agents/compliance"
+	"my-agents/conversation"
+	"my-agents/credentials"
+	"my-agents/deadletter"
+	"my-agents/debate"
+	"my-agents/debugger"
+	"my-agents/deploy"
+	"my-agents/di"
+	"my-agents/drift"
+	"my-agents/eventbus"
+	"my-agents/flags"
+	"my-agents/gemini"
+	"my-agents/guardrail"
+	"my-agents/history"
+	"my-agents/ingest"
+	"my-agents/langdetect"
+	"my-agents/llmcache"
+	"my-agents/locale"
+	"my-agents/maintenance"
+	"my-agents/mcp"
+	"my-agents/middleware"
+	"my-agents/modelroute"
+	"my-agents/nbest"
+	"my-agents/ocr"
+	"my-agents/parallel"
+	"my-agents/partial"
+	"my-agents/personalize"
+	"my-agents/plan"
+	"my-agents/policy"
+	"my-agents/prefetch"
+	"my-agents/profile"
+	"my-agents/prompts"
+	"my-agents/quality"
+	"my-agents/quota"
+	"my-agents/ratelimit"
+	"my-agents/react"
+	"my-agents/region"
+	"my-agents/retrieval"
+	"my-agents/retry"
+	"my-agents/schema"
+	"my-agents/sink"
+	"my-agents/statestore"
+	"my-agents/storage"
+	"my-agents/stream"
+	"my-agents/stylelint"
+	"my-agents/telemetry"
+	"my-agents/tools/calculator"
+	"my-agents/tools/sandbox"
+	"my-agents/tools/spreadsheet"
+	"my-agents/tot"
+	"my-agents/usage"
+	"my-agents/vcr"
+)
+
+// application is the wired pipeline: agents registered on a runner, with
+// run history recorded. The demo, the simulator and other commands share it.
+type application struct {
+	configPath string
+	appCfg     *appconfig.Config
+	runner     core.Runner
+	runs       history.Store
+	recorder   *history.Recorder
+	agents     map[string]core.AgentHandler
+	container  *di.Container
+	router     *modelroute.Router    // nil unless model routing is enabled
+	quotas     *quota.Manager        // nil unless quotas are enabled
+	cache      *llmcache.Cache       // nil unless the LLM response cache is enabled
+	regions    *region.Metrics       // nil unless a provider lists regions
+	usage      *usage.Ledger         // nil unless usage metering is enabled
+	meter      *usage.Meter          // nil unless usage metering is enabled
+	admin      *admin.Controller     // nil unless the admin API is enabled
+	deploys    *deploy.Manager       // nil unless the admin API is enabled
+	breaks     *debugger.Remote      // nil unless the admin API is enabled
+	queue      *storage.Queue        // nil unless the durable queue is enabled
+	dead       *deadletter.Queue     // nil unless the dead-letter queue is enabled
+	drift      *drift.Monitor        // nil unless drift monitoring is enabled
+	quality    *quality.Tracker      // nil unless quality tracking is enabled
+	state      *statestore.Persister // nil unless a state store is configured
+	events     *eventbus.Node        // nil unless an event bus is configured
+	keys       *credentials.Reloader // nil unless key reloading is enabled
+	plans      *plan.Planner         // nil unless planning is enabled
+	policy     *policy.Policy        // nil unless the action policy is enabled
+	audit      *audit.Auditor        // nil unless tool auditing is enabled
+	telemetry  *telemetry.Collector  // nil unless telemetry is enabled
+	compliance *compliance.Generator // nil unless compliance reports are enabled
+	profiles   *profile.Store        // nil unless profiles or personalization are enabled
+	vectors    *retrieval.Retriever  // nil unless [retrieval] is enabled
+	ingester   *ingest.Ingester      // nil without agent memory or [retrieval]
+	refresher  *ingest.Refresher     // nil unless [knowledge] refresh is on
+	mover      *coldstore.Mover      // nil unless cold storage is enabled
+	catalog    *catalog.Catalog
+	maintainer *maintenance.Scheduler // nil unless maintenance is enabled
+	metrics    *middleware.Metrics    // runs of the agents wrapped in the metrics middleware
+	streams    *stream.Hub            // the formatter's responses, per run, as they're generated
+	closers    []func()
+}
+
+// appOverrides changes how buildApp wires the pipeline, for recording and
+// replaying fixtures.
+type appOverrides struct {
+	// llm stands in for every configured provider.
+	llm core.ModelProvider
+	// record is added innermost around every agent's provider.
+	record di.LLMMiddleware
+	// debug pauses before every agent and provider call.
+	debug *debugger.Debugger
+	// scratch keeps all state in this directory, with memory, the queue and
+	// outbound integrations turned off, so runs neither see nor change the
+	// deployment's data.
+	scratch string
+	// mcp connects to the [mcp_client] servers for their tools, which only
+	// the long-running serve and worker commands start.
+	mcp bool
+	// quiet turns memory, the queue and outbound integrations off but keeps
+	// the deployment's stores, so runs are recorded in its history and
+	// usage ledger without reaching anything else.
+	quiet bool
+}
+
+// newApp builds the pipeline from configPath. The runner is not started.
+func newApp(configPath string) (*application, error) {
+	return buildApp(configPath, appOverrides{})
+}
+
+func buildApp(configPath string, ov appOverrides) (*application, error) {
+	app := &application{configPath: configPath, streams: stream.NewHub()}
+	cfg, err := core.LoadConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	appCfg, err := appconfig.Load(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load app config: %w", err)
+	}
+
+	// 🌍 Providers listing regions send each call to the fastest healthy one
+	if len(appCfg.LLM.Regions) > 0 {
+		app.regions = region.NewMetrics()
+	}
+	for _, opts := range appCfg.ProviderOptions {
+		if len(opts.Regions) > 0 {
+			app.regions = region.NewMetrics()
+			break
+		}
+	}
+	provider := ov.llm
+	// [providers.default] is left to the container, which can rotate its key
+	if _, configured := appCfg.Providers[di.DefaultProvider]; provider == nil && !configured {
+		provider, err = defaultProvider(cfg, appCfg, app.regions)
+		log.Printf("Provider %v", &provider)
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to create LLM provider: %w", err)
+		}
+	}
+	if appCfg.SchemaVersion < appconfig.SchemaVersion {
+		log.Printf("agentflow.toml uses schema version %d (current is %d); run 'migrate-config' to upgrade", appCfg.SchemaVersion, appconfig.SchemaVersion)
+	}
+	switch {
+	case ov.scratch != "":
+		isolate(cfg, appCfg, ov.scratch)
+	case ov.quiet:
+		quiet(cfg, appCfg)
+	}
+
+	// 📼 Record provider traffic to a cassette, or serve it back offline
+	if vcfg := appCfg.VCR.WithEnv(); vcfg.Mode != "" && ov.llm == nil {
+		t, err := vcr.New(vcfg, providerHosts(cfg, appCfg))
+		if err != nil {
+			return nil, fmt.Errorf("failed to open cassette: %w", err)
+		}
+		app.closers = append(app.closers, vcr.Install(t))
+		log.Printf("Provider traffic %s via cassette (%d interactions)", t.Mode, t.Cassette.Len())
+	}
+
+	// 🧠 Optional memory backend; knowledge is prefetched while routing happens
+	var memory core.Memory
+	var prefetcher *prefetch.Prefetcher
+	var sources *ingest.Sources
+	if cfg.AgentMemory.Provider == storage.MemoryProvider && cfg.AgentMemory.Connection == "" {
+		cfg.AgentMemory.Connection = appCfg.Storage.Path
+	}
+	if cfg.AgentMemory.Provider != "" {
+		memory, err = core.NewMemory(cfg.AgentMemory)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create memory: %w", err)
+		}
+		app.closers = append(app.closers, func() { memory.Close() })
+		// Documents of deleted sources stay in the index; leave them out
+		if sources, err = ingest.OpenSources(appCfg.Storage.Path); err != nil {
+			return nil, fmt.Errorf("failed to open knowledge sources: %w", err)
+		}
+		prefetcher = prefetch.New(sources.Live(prefetch.FromMemory(memory)), cfg.AgentMemory.KnowledgeMaxResults)
+	}
+
+	// 🔌 Wire agents from the dependencies they declare in agentflow.toml
+	container := di.New(appCfg, provider, memory)
+	container.SetRegions(app.regions)
+	if provider == nil {
+		if provider, err = container.Provider(di.DefaultProvider); err != nil {
+			return nil, fmt.Errorf("failed to create LLM provider: %w", err)
+		}
+	}
+	container.RegisterSink("stdout", sink.Stdout())
+	if ov.llm != nil {
+		for name := range appCfg.Providers {
+			container.RegisterProvider(name, ov.llm)
+		}
+	}
+
+	// 🔑 Provider keys from secret files, reloaded while running
+	if appCfg.Credentials.Enabled {
+		app.keys = credentials.New(appCfg.Credentials, container.RotateKey)
+		app.keys.SetConfigKeys(func() (map[string]string, error) {
+			latest, err := appconfig.Load(configPath)
+			if err != nil {
+				return nil, err
+			}
+			keys := make(map[string]string, len(latest.Providers))
+			for name, pcfg := range latest.Providers {
+				keys[name] = pcfg.APIKey
+			}
+			return keys, nil
+		})
+		if _, err := app.keys.Reload(); err != nil {
+			return nil, fmt.Errorf("failed to load provider keys: %w", err)
+		}
+	}
+
+	container.RegisterTool(spreadsheet.New())
+	if err := container.Tools().Register(calculator.Name, calculator.Description, calculator.Parameters, calculator.Call); err != nil {
+		return nil, fmt.Errorf("failed to register calculator tool: %w", err)
+	}
+	messages := bus.New()
+	container.SetBus(messages)
+	container.RegisterTool(&bus.Tool{Bus: messages})
+	ocrEngine, err := ocr.New(appCfg.OCR)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure OCR: %w", err)
+	}
+	if ocrEngine != nil {
+		container.RegisterTool(&ocr.Tool{Engine: ocrEngine, DPI: appCfg.OCR.DPI})
+	}
+
+	// 📚 Documents ingested go into memory and, in chunks, the vector store
+	var vectors *retrieval.Retriever
+	if appCfg.Retrieval.Enabled {
+		if vectors, err = retrieval.Open(appCfg.Retrieval, appCfg.Storage.Path); err != nil {
+			return nil, fmt.Errorf("failed to open retrieval: %w", err)
+		}
+		app.closers = append(app.closers, func() { vectors.Close() })
+		var llm core.ModelProvider
+		if appCfg.Retrieval.Rerank.Provider == retrieval.RerankLLM {
+			if llm, err = container.Provider(appCfg.Retrieval.Rerank.LLM); err != nil {
+				return nil, fmt.Errorf("rerank provider: %w", err)
+			}
+		}
+		reranker, err := retrieval.NewReranker(appCfg.Retrieval.Rerank, llm)
+		if err != nil {
+			return nil, err
+		}
+		vectors.UseReranker(reranker)
+		if appCfg.Retrieval.Classifier != "" {
+			classifier, err := container.Provider(appCfg.Retrieval.Classifier)
+			if err != nil {
+				return nil, fmt.Errorf("collection classifier: %w", err)
+			}
+			vectors.UseClassifier(classifier)
+		}
+		app.vectors = vectors
+	}
+	if memory != nil || vectors != nil {
+		if sources == nil {
+			if sources, err = ingest.OpenSources(appCfg.Storage.Path); err != nil {
+				return nil, fmt.Errorf("failed to open knowledge sources: %w", err)
+			}
+		}
+		app.ingester = &ingest.Ingester{Memory: memory, Vectors: vectors, OCR: ocrEngine, DPI: appCfg.OCR.DPI, Sources: sources, Workers: appCfg.Knowledge.Workers}
+
+		// ♻️ Re-ingest knowledge sources that changed, tombstone deleted ones
+		if appCfg.Knowledge.Refresh {
+			app.refresher = ingest.NewRefresher(appCfg.Knowledge, app.ingester)
+		}
+	}
+	sandboxed, err := sandbox.New(appCfg.Sandbox)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure sandbox tools: %w", err)
+	}
+	for _, tool := range sandboxed {
+		container.RegisterTool(tool)
+	}
+
+	// 🔌 Tools exposed by external MCP servers, discovered at startup
+	if ov.mcp {
+		servers, err := mcp.Discover(context.Background(), appCfg.MCP, container.Tools())
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to MCP servers: %w", err)
+		}
+		for _, server := range servers {
+			app.closers = append(app.closers, func() { server.Close() })
+		}
+	}
+
+	// 🚩 Feature flags gate rollouts per tenant without a deploy
+	flagClient, err := flags.New(appCfg.FeatureFlags)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load feature flags: %w", err)
+	}
+	container.SetFlags(flagClient)
+
+	// 🌍 Locale-specific prompts, messages and formatting from event metadata
+	locales, err := locale.NewRegistry(appCfg.DefaultLocale, appCfg.Locales)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load locales: %w", err)
+	}
+
+	// 🛡️ Operator word lists and brand style rules, per tenant
+	guard, err := guardrail.New(appCfg.Guardrail)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load guardrail config: %w", err)
+	}
+
+	// 💾 Checkpoint streamed output so a crash mid-generation is recoverable
+	checkpoints, err := partial.Open(appCfg.Recovery)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open checkpoint store: %w", err)
+	}
+	gen := partial.NewGenerator(checkpoints, appCfg.Recovery)
+
+	// 💬 Conversation memory carries a session's earlier turns into prompts
+	var convo conversation.Memory
+	var conversations *conversation.Store
+	if appCfg.Conversation.Enabled {
+		summarizer, err := container.Provider(appCfg.Conversation.Summarizer)
+		if err != nil {
+			return nil, fmt.Errorf("conversation summarizer: %w", err)
+		}
+		if conversations, err = conversation.Open(appCfg.Conversation, appCfg.Storage.Path, summarizer); err != nil {
+			return nil, fmt.Errorf("failed to open conversation memory: %w", err)
+		}
+		app.closers = append(app.closers, conversations.Close)
+		convo = conversations
+	}
+
+	// 👤 Recipient profiles: preferences they set and traits learned from
+	// their feedback, shown to agents and adapting the final response
+	var profiles, users *profile.Store
+	if appCfg.Profiles.Enabled || appCfg.Personalization.Enabled {
+		if profiles, err = profile.Open(appCfg.Profiles, appCfg.Storage.Path); err != nil {
+			return nil, fmt.Errorf("failed to open profile store: %w", err)
+		}
+		app.profiles = profiles
+		if profiles.Enabled() {
+			users = profiles
+		}
+	}
+
+	// 📑 Ground the processor in the document chunks closest to a request;
+	// routing to "retriever" first leaves them in the state instead
+	var retriever *RetrieverAgent
+	if vectors != nil {
+		retriever = &RetrieverAgent{retriever: vectors, next: "processor", locales: locales}
+		container.RegisterAgent("retriever", func(d di.Deps) (core.AgentHandler, error) {
+			return retriever, nil
+		})
+	}
+
+	// 🤖 Create three specialized agents
+	container.RegisterAgent("processor", func(d di.Deps) (core.AgentHandler, error) {
+		g, err := generationFor(container, appCfg, d.Name, gen, true)
+		if err != nil {
+			return nil, err
+		}
+		prompt, err := promptFor(appCfg, d, processorPrompt)
+		if err != nil {
+			return nil, err
+		}
+		agent := &ProcessorAgent{generation: g, llm: d.LLM, prompt: prompt, clarify: appCfg.Clarification.Enabled, prefetch: prefetcher, convo: convo, users: users, ground: retriever, locales: locales, guard: guard}
+		if len(d.Tools) > 0 {
+			agent.react = &react.Executor{LLM: d.LLM, Tools: d.Tools, MaxSteps: d.MaxSteps, Agent: d.Name}
+		}
+		return agent, nil
+	})
+	container.RegisterAgent("enhancer", func(d di.Deps) (core.AgentHandler, error) {
+		g, err := generationFor(container, appCfg, d.Name, gen, true)
+		if err != nil {
+			return nil, err
+		}
+		prompt, err := promptFor(appCfg, d, enhancerPrompt)
+		if err != nil {
+			return nil, err
+		}
+		agent := &EnhancerAgent{generation: g, llm: d.LLM, bus: d.Bus, prompt: prompt, flags: d.Flags, convo: convo, users: users, locales: locales}
+		if len(d.Tools) > 0 {
+			agent.react = &react.Executor{LLM: d.LLM, Tools: d.Tools, MaxSteps: d.MaxSteps, Agent: d.Name}
+		}
+		var scorer core.ModelProvider
+		if name := appCfg.TreeOfThought.ScorerProvider; name != "" {
+			p, err := container.AgentProvider(d.Name, name)
+			if err != nil {
+				return nil, fmt.Errorf("tree-of-thought scorer: %w", err)
+			}
+			scorer = p
+		}
+		agent.tot = tot.New(appCfg.TreeOfThought, d.LLM, scorer)
+		return agent, nil
+	})
+	container.RegisterAgent("formatter", func(d di.Deps) (core.AgentHandler, error) {
+		sinks := d.Sinks
+		if len(sinks) == 0 {
+			stdout, err := container.AgentSink(d.Name, "stdout")
+			if err != nil {
+				return nil, err
+			}
+			sinks = []sink.Sink{stdout}
+		}
+		// Its reply is edited after generation, so it can't promise a schema
+		g, err := generationFor(container, appCfg, d.Name, gen, false)
+		if err != nil {
+			return nil, err
+		}
+		fixer := d.LLM
+		if name := appCfg.StyleLint.Fixer; name != "" {
+			if fixer, err = container.AgentProvider(d.Name, name); err != nil {
+				return nil, fmt.Errorf("style_lint fixer: %w", err)
+			}
+		}
+		lint, err := stylelint.New(appCfg.StyleLint, fixer)
+		if err != nil {
+			return nil, err
+		}
+		rewriter := d.LLM
+		if name := appCfg.Personalization.Provider; name != "" {
+			if rewriter, err = container.AgentProvider(d.Name, name); err != nil {
+				return nil, fmt.Errorf("personalization provider: %w", err)
+			}
+		}
+		prompt, err := promptFor(appCfg, d, formatterPrompt)
+		if err != nil {
+			return nil, err
+		}
+		return &FormatterAgent{generation: g, llm: d.LLM, bus: d.Bus, prompt: prompt, sinks: sinks, convo: convo, locales: locales, guard: guard, lint: lint, adapt: personalize.New(appCfg.Personalization, profiles, rewriter), render: appCfg.Formatter, streams: app.streams, runs: app.recorder}, nil
+	})
+
+	if bb := appCfg.Blackboard; len(bb.Specialists) > 0 {
+		container.RegisterAgent("blackboard", func(d di.Deps) (core.AgentHandler, error) {
+			agent := &BlackboardAgent{cfg: bb, locales: locales, ctrl: &blackboard.KeyController{SolutionKey: cmp.Or(bb.SolutionKey, "answer")}}
+			for _, sc := range bb.Specialists {
+				llm, err := container.AgentProvider(d.Name, sc.Provider)
+				if err != nil {
+					return nil, fmt.Errorf("specialist %s: %w", sc.Name, err)
+				}
+				spec, err := blackboard.NewLLMSpecialist(sc, llm)
+				if err != nil {
+					return nil, err
+				}
+				agent.specialists = append(agent.specialists, spec)
+			}
+			if bb.Judge != "" {
+				judge, err := container.AgentProvider(d.Name, bb.Judge)
+				if err != nil {
+					return nil, fmt.Errorf("blackboard judge: %w", err)
+				}
+				agent.ctrl.Judge = judge
+			}
+			return agent, nil
+		})
+	}
+
+	if dc := appCfg.Debate; dc.Enabled {
+		container.RegisterAgent("debate", func(d di.Deps) (core.AgentHandler, error) {
+			var models [3]core.ModelProvider
+			for i, role := range []debate.Role{dc.Proponent, dc.Opponent, dc.Judge} {
+				llm, err := container.AgentProvider(d.Name, role.Provider)
+				if err != nil {
+					return nil, fmt.Errorf("debate: %w", err)
+				}
+				models[i] = llm
+			}
+			return &DebateAgent{debate: debate.New(dc, models[0], models[1], models[2]), next: cmp.Or(dc.Next, "formatter"), locales: locales}, nil
+		})
+	}
+
+	// ♻️ Identical calls to the same model replay the earlier reply; added
+	// first, so a hit spends no quota and isn't metered
+	if appCfg.LLMCache.Enabled {
+		app.cache, err = llmcache.New(appCfg.LLMCache, func(agent, provider string) string {
+			name := cmp.Or(provider, appCfg.Agents[agent].Provider, di.DefaultProvider)
+			if p, ok := appCfg.Providers[name]; ok {
+				return name + "/" + p.Type + "/" + p.Model
+			}
+			return name + "/" + cfg.LLM.Provider + "/" + cfg.LLM.Model
+		}, func(agent string) bool {
+			c := appCfg.Agents[agent].Cache
+			return c == nil || *c
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure the LLM cache: %w", err)
+		}
+		app.closers = append(app.closers, func() { app.cache.Close() })
+		container.UseLLM(app.cache.Middleware())
+	}
+
+	// 🎟️ Daily request and token allowances per user / API key
+	if appCfg.Quotas.Enabled {
+		app.quotas, err = quota.New(appCfg.Quotas)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load quotas: %w", err)
+		}
+		container.UseLLM(app.quotas.Middleware())
+	}
+
+	// 🧾 Meter tokens and cost per tenant, user and workflow for billing
+	var meter *usage.Meter
+	if appCfg.Usage.Enabled {
+		app.usage, err = usage.OpenLedger(appCfg.Usage.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open usage ledger: %w", err)
+		}
+		meter = usage.NewMeter(app.usage, appCfg.Usage, func(agent string) string {
+			return cmp.Or(appCfg.Agents[agent].Provider, di.DefaultProvider)
+		})
+		container.UseLLM(meter.Middleware())
+		app.meter = meter
+	}
+
+	// 💸 Agents on the "auto" provider get the cheapest model likely to cope
+	if appCfg.ModelRouting.Enabled {
+		app.router, err = newModelRouter(container, appCfg.ModelRouting)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure model routing: %w", err)
+		}
+		container.RegisterProvider(cmp.Or(appCfg.ModelRouting.Name, modelroute.DefaultName), app.router)
+	}
+
+	runStore, err := openRuns(appCfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open run history: %w", err)
+	}
+	// 🧊 Old runs and documents nobody retrieves move to cheaper storage,
+	// coming back when asked for
+	if cold := appCfg.ColdStorage; cold.Enabled {
+		runsAge, vectorsAge, err := cold.Ages()
+		if err != nil {
+			return nil, err
+		}
+		app.mover = coldstore.NewMover(cold)
+		if tiered, ok := runStore.(*history.TieredStore); ok && runsAge > 0 {
+			app.mover.Add("runs", tiered, runsAge)
+		}
+		// Documents archived before vectors_after was set to 0 still come
+		// back; a shared store's never move
+		if vectors != nil && (vectorsAge > 0 || !vectors.SharedStore()) {
+			archive, err := coldstore.Open(cold)
+			if err != nil {
+				return nil, fmt.Errorf("failed to open cold storage: %w", err)
+			}
+			if err := vectors.UseColdStorage(archive, cold.RestoreScore); err != nil {
+				return nil, err
+			}
+			if vectorsAge > 0 {
+				app.mover.Add("vectors", vectors, vectorsAge)
+			}
+		}
+	}
+	// 🧹 Vacuum the databases, rebuild their indexes, compact caches and
+	// sweep orphaned archives on a schedule
+	if appCfg.Maintenance.Enabled {
+		app.maintainer = newMaintenance(appCfg.Maintenance, app.cache, runStore, vectors)
+	}
+	// 📚 Record every run for inspection and transcript export, with the
+	// keys each agent writes
+	app.recorder = history.NewRecorder(runStore)
+
+	// ⭐ Ratings of earlier runs teach the model router and the recipient's
+	// profile
+	if app.router != nil || app.profiles != nil {
+		container.RegisterAgent("feedback", func(d di.Deps) (core.AgentHandler, error) {
+			return &FeedbackAgent{router: app.router, profiles: app.profiles, runs: runStore}, nil
+		})
+	}
+
+	// 🚦 Operator rules on which tools and sinks agents may use
+	if appCfg.Policy.Enabled {
+		app.policy, err = policy.New(appCfg.Policy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load policy: %w", err)
+		}
+		container.UseTool(app.policy.ToolMiddleware())
+		container.UseSink(app.policy.SinkMiddleware())
+	}
+
+	// 📋 Side-effecting workflows plan their external actions for approval
+	if appCfg.Plan.Enabled {
+		app.plans, err = plan.New(appCfg.Plan, runStore)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load plans: %w", err)
+		}
+		container.UseTool(app.plans.ToolMiddleware())
+		container.UseSink(app.plans.SinkMiddleware())
+	}
+
+	// 📈 Opt-in anonymized usage statistics
+	if appCfg.Telemetry.Enabled {
+		app.telemetry, err = telemetry.New(appCfg.Telemetry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure telemetry: %w", err)
+		}
+		container.UseLLM(app.telemetry.Middleware())
+		container.UseTool(app.telemetry.ToolMiddleware())
+	}
+
+	// 🔎 Audit every tool call that actually runs
+	if appCfg.Audit.Enabled {
+		calls, err := audit.Open(appCfg.Audit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+		app.audit = audit.New(calls)
+		container.UseTool(app.audit.ToolMiddleware())
+	}
+
+	// 🗂️ Periodic compliance evidence from the audit log and run history
+	if appCfg.Compliance.Enabled {
+		if app.audit == nil {
+			return nil, fmt.Errorf("compliance reports need [audit] enabled")
+		}
+		app.compliance, err = compliance.New(appCfg.Compliance, app.audit.Log(), runStore, encryptionInventory(cfg, appCfg))
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure compliance reports: %w", err)
+		}
+	}
+
+	if ov.record != nil {
+		container.UseLLM(ov.record)
+	}
+	if ov.debug != nil {
+		container.UseLLM(ov.debug.Middleware())
+	}
+	// 🔁 Provider calls failing on a transient error are tried again;
+	// innermost, so quotas and the meter count each call once
+	container.UseLLM(retry.Middleware(func(agent string) retry.Policy { return retryPolicy(appCfg, agent) }))
+
+	// 🧅 Cross-cutting middleware around each agent's Run; agents leave
+	// passing the request metadata, and the tenant, on to it
+	container.UseAgent(middleware.ForwardMetadata)
+	container.UseAgent(middleware.Tenant)
+	app.metrics = middleware.NewMetrics()
+	builtins, err := middleware.Builtins(appCfg.Middleware, app.metrics)
+	if err != nil {
+		return nil, err
+	}
+	for name, mw := range builtins {
+		container.RegisterMiddleware(name, mw)
+	}
+
+	agents, err := container.BuildAgents()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build agents: %w", err)
+	}
+
+	// 🔀 Fan-outs run several agents at once and merge what they write
+	fanOuts := make(map[string]core.AgentHandler, len(appCfg.Parallel))
+	for name, pc := range appCfg.Parallel {
+		if _, taken := agents[name]; taken {
+			return nil, fmt.Errorf("parallel %s: name taken by an agent", name)
+		}
+		branches := make(map[string]core.AgentHandler, len(pc.Agents))
+		for _, branch := range pc.Agents {
+			if agent, ok := agents[branch]; ok {
+				if agent, err = retry.Wrap(branch, retryPolicy(appCfg, branch), agent); err != nil {
+					return nil, err
+				}
+				branches[branch] = app.recorder.Attribute(branch, agent)
+			}
+		}
+		if fanOuts[name], err = parallel.New(name, pc, branches, mergeReducers); err != nil {
+			return nil, err
+		}
+	}
+	for name, agent := range fanOuts {
+		agents[name] = agent
+	}
+
+	// 📖 Documented workflows and their examples, for discovery
+	names := make([]string, 0, len(agents))
+	for name := range agents {
+		names = append(names, name)
+	}
+	app.catalog, err = catalog.New(appCfg.Workflows, names)
+	if err != nil {
+		return nil, err
+	}
+
+	// 🚀 Prefer config-driven runner (supports route/collab/seq/loop/mixed)
+	runner, err := core.NewRunnerFromConfig(configPath)
+	if err != nil {
+		return nil, fmt.Errorf("runner: %w", err)
+	}
+	// 🛰️ Agents other processes run are reached over the event bus
+	if appCfg.EventBus.Backend != "" {
+		if app.events, err = eventbus.New(appCfg.EventBus, names); err != nil {
+			return nil, err
+		}
+		app.closers = append(app.closers, func() { app.events.Close() })
+	}
+	for name, agent := range agents {
+		// A fan-out's branches retry and report their own writes
+		if _, fanOut := agent.(*parallel.Agent); !fanOut {
+			if agent, err = retry.Wrap(name, retryPolicy(appCfg, name), agent); err != nil {
+				return nil, err
+			}
+			agent = app.recorder.Attribute(name, agent)
+		}
+		if meter != nil {
+			agent = meter.Wrap(agent)
+		}
+		if app.events != nil && !app.events.Runs(name) {
+			agent = app.events.Forwarder(name)
+		}
+		if err := runner.RegisterAgent(name, agent); err != nil {
+			return nil, fmt.Errorf("failed to register agent %s: %w", name, err)
+		}
+	}
+	if err := runner.RegisterAgent("error-handler", &ErrorHandlerAgent{}); err != nil {
+		return nil, fmt.Errorf("failed to register error handler: %w", err)
+	}
+
+	if err := app.recorder.Register(runner); err != nil {
+		return nil, fmt.Errorf("failed to register history recorder: %w", err)
+	}
+	if conversations != nil {
+		if err := conversation.Register(runner, conversations, runStore); err != nil {
+			return nil, fmt.Errorf("failed to register conversation memory: %w", err)
+		}
+	}
+	// 💾 Save each run's state after every agent, to restore after a crash
+	if appCfg.StateStore.Backend != "" {
+		store, err := statestore.Open(appCfg.StateStore)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open state store: %w", err)
+		}
+		app.closers = append(app.closers, func() { store.Close() })
+		app.state = statestore.NewPersister(store)
+		if err := app.state.Register(runner); err != nil {
+			return nil, fmt.Errorf("failed to register state store: %w", err)
+		}
+	}
+	if appCfg.Recovery.ResumeOnStart && app.state == nil {
+		return nil, fmt.Errorf("[recovery] resume_on_start claims the runs it resumes in the [state_store]; configure its backend")
+	}
+	// 📮 Keep the events agents fail on, to inspect and re-emit
+	if appCfg.DeadLetter.Enabled {
+		if app.dead, err = deadletter.Open(appCfg.DeadLetter, runStore); err != nil {
+			return nil, fmt.Errorf("failed to open dead-letter queue: %w", err)
+		}
+		if err := app.dead.Register(runner); err != nil {
+			return nil, fmt.Errorf("failed to register dead-letter queue: %w", err)
+		}
+	}
+	// 📉 Watch final responses for drift from a baseline
+	if appCfg.Drift.Enabled {
+		embedder, err := container.Provider(appCfg.Drift.Provider)
+		if err != nil {
+			return nil, fmt.Errorf("drift provider: %w", err)
+		}
+		if app.drift, err = drift.Open(appCfg.Drift, embedder); err != nil {
+			return nil, fmt.Errorf("failed to open drift monitor: %w", err)
+		}
+		app.closers = append(app.closers, app.drift.Close)
+		if err := app.drift.Register(runner); err != nil {
+			return nil, fmt.Errorf("failed to register drift monitor: %w", err)
+		}
+	}
+	// ⚖️ Score a daily sample of responses for quality and safety
+	if appCfg.Quality.Enabled {
+		judge, err := container.Provider(appCfg.Quality.Provider)
+		if err != nil {
+			return nil, fmt.Errorf("quality judge provider: %w", err)
+		}
+		if app.quality, err = quality.Open(appCfg.Quality, judge, runStore); err != nil {
+			return nil, fmt.Errorf("failed to open quality tracker: %w", err)
+		}
+	}
+	if err := messages.Register(runner); err != nil {
+		return nil, fmt.Errorf("failed to register message bus: %w", err)
+	}
+	if meter != nil {
+		if err := meter.Register(runner); err != nil {
+			return nil, fmt.Errorf("failed to register usage meter: %w", err)
+		}
+	}
+	if appCfg.LanguageRouting.Enabled {
+		router := langdetect.NewRouter(appCfg.LanguageRouting, locales)
+		if err := runner.RegisterCallback(core.HookBeforeEventHandling, "language-routing", router.Callback()); err != nil {
+			return nil, fmt.Errorf("failed to register language routing: %w", err)
+		}
+	}
+	if appCfg.Admin.Enabled {
+		app.deploys, err = deploy.New(appCfg.Admin.DeploymentsPath, container, runner)
+		if err != nil {
+			return nil, fmt.Errorf("failed to restore workflow deployments: %w", err)
+		}
+		if err := app.deploys.Register(runner); err != nil {
+			return nil, fmt.Errorf("failed to register workflow deployments: %w", err)
+		}
+	}
+	if app.router != nil {
+		if err := app.router.Register(runner); err != nil {
+			return nil, fmt.Errorf("failed to register model routing: %w", err)
+		}
+	}
+	if prefetcher != nil {
+		if err := runner.RegisterCallback(core.HookBeforeEventHandling, "prefetch", prefetcher.Callback()); err != nil {
+			return nil, fmt.Errorf("failed to register prefetch callback: %w", err)
+		}
+	}
+	if app.telemetry != nil {
+		if err := app.telemetry.Register(runner); err != nil {
+			return nil, fmt.Errorf("failed to register telemetry: %w", err)
+		}
+	}
+	if app.audit != nil {
+		if err := app.audit.Register(runner); err != nil {
+			return nil, fmt.Errorf("failed to register tool audit: %w", err)
+		}
+	}
+	if app.policy != nil {
+		if err := app.policy.Register(runner); err != nil {
+			return nil, fmt.Errorf("failed to register policy: %w", err)
+		}
+	}
+	if app.plans != nil {
+		if err := app.plans.Register(runner); err != nil {
+			return nil, fmt.Errorf("failed to register planner: %w", err)
+		}
+		runner = app.plans.Wrap(runner)
+	}
+	if appCfg.Admin.Enabled {
+		app.admin = admin.NewController(runStore)
+		if err := app.admin.Register(runner); err != nil {
+			return nil, fmt.Errorf("failed to register admin controls: %w", err)
+		}
+		if prefetcher != nil {
+			app.admin.RegisterCache("prefetch", func() (int, error) { return prefetcher.Flush(), nil })
+		}
+		if app.cache != nil {
+			app.admin.RegisterCache("llm", app.cache.Flush)
+		}
+		app.admin.SetRotator(container.RotateKey)
+		runner = app.admin.Wrap(runner)
+	}
+	if appCfg.Storage.Queue == "" || appCfg.Storage.Queue == storage.BackendSQLite {
+		db, err := storage.Open(appCfg.Storage.Path)
+		if err != nil {
+			return nil, err
+		}
+		if app.queue, err = storage.NewQueue(db); err != nil {
+			return nil, err
+		}
+		if err := app.queue.Register(runner); err != nil {
+			return nil, fmt.Errorf("failed to register durable queue: %w", err)
+		}
+		runner = app.queue.Wrap(runner)
+	} else if appCfg.Storage.Queue != storage.BackendMemory {
+		return nil, fmt.Errorf("unknown queue backend %q", appCfg.Storage.Queue)
+	}
+	if app.quotas != nil {
+		if err := app.quotas.Register(runner); err != nil {
+			return nil, fmt.Errorf("failed to register quotas: %w", err)
+		}
+		runner = quota.Enforce(runner, app.quotas)
+	}
+	// Registered last, so the state shown is what the agent receives
+	if ov.debug != nil {
+		if err := ov.debug.Register(runner); err != nil {
+			return nil, fmt.Errorf("failed to register debugger: %w", err)
+		}
+	}
+	if appCfg.Admin.Enabled {
+		var timeout time.Duration
+		if appCfg.Admin.BreakpointTimeout != "" {
+			if timeout, err = time.ParseDuration(appCfg.Admin.BreakpointTimeout); err != nil {
+				return nil, fmt.Errorf("admin breakpoint_timeout: %w", err)
+			}
+		}
+		app.breaks = debugger.NewRemote(timeout)
+		if err := app.breaks.Register(runner); err != nil {
+			return nil, fmt.Errorf("failed to register breakpoints: %w", err)
+		}
+	}
+
+	app.appCfg = appCfg
+	app.runner, app.runs, app.agents, app.container = runner, runStore, agents, container
+	return app, nil
+}
+
+// retryPolicy is agent's own retry policy, or else the [retry] one.
+func retryPolicy(appCfg *appconfig.Config, agent string) retry.Policy {
+	if acfg, ok := appCfg.Agents[agent]; ok && acfg.Retry != nil {
+		return *acfg.Retry
+	}
+	return appCfg.Retry
+}
+
+// mergeReducers are the custom strategies [parallel.<name>.merge] tables can
+// name besides the built-in ones.
+var mergeReducers = map[string]parallel.Reducer{
+	// concat joins the agents' text with blank lines, in the order they
+	// finished, so no agent's contribution is lost
+	"concat": func(key string, _ any, writes []parallel.Write) (any, []string, error) {
+		parts := make([]string, 0, len(writes))
+		from := make([]string, 0, len(writes))
+		for _, w := range writes {
+			s, ok := w.Value.(string)
+			if !ok {
+				return nil, nil, fmt.Errorf("%s: concat needs text, %s wrote %v", key, w.Agent, w.Value)
+			}
+			parts = append(parts, s)
+			from = append(from, w.Agent)
+		}
+		return strings.Join(parts, "\n\n"), from, nil
+	},
+}
+
+// newModelRouter resolves the routed models' providers from the container.
+func newModelRouter(container *di.Container, cfg modelroute.Config) (*modelroute.Router, error) {
+	models := make([]modelroute.Model, 0, len(cfg.Models))
+	for _, mc := range cfg.Models {
+		llm, err := container.Provider(mc.Provider)
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, modelroute.Model{ModelConfig: mc, LLM: llm})
+	}
+	return modelroute.New(cfg, models)
+}
+
+// isolate points every store at dir and turns off agent memory, the durable
+// queue, the event bus and the integrations that reach outside the process.
+func isolate(cfg *core.Config, appCfg *appconfig.Config, dir string) {
+	quiet(cfg, appCfg)
+	db := filepath.Join(dir, "agentflow.db")
+	appCfg.Storage = storage.Config{Path: db, Queue: storage.BackendMemory}
+	appCfg.History = history.Config{Path: db}
+	appCfg.Recovery.Backend, appCfg.Recovery.Path = "", db
+	appCfg.Audit.Backend, appCfg.Audit.Path = "", db
+	appCfg.DeadLetter.Path = db
+	appCfg.Retrieval.Path = db
+	appCfg.Plan.Path = filepath.Join(dir, "plans.json")
+	appCfg.Quotas.Path = filepath.Join(dir, "quota.json")
+	appCfg.Usage.Path = filepath.Join(dir, "usage")
+	appCfg.ModelRouting.StatsPath = filepath.Join(dir, "model_stats.json")
+	appCfg.Compliance.Enabled = false
+	appCfg.ColdStorage.Enabled = false
+	appCfg.Maintenance.Enabled = false
+	appCfg.Credentials.Enabled = false
+}
+
+// quiet turns off agent memory, the durable queue, the event bus, the LLM
+// cache and the integrations that reach outside the process, leaving the
+// stores where they are.
+func quiet(cfg *core.Config, appCfg *appconfig.Config) {
+	cfg.AgentMemory = core.AgentMemoryConfig{}
+	appCfg.Storage.Queue = storage.BackendMemory
+	appCfg.EventBus = eventbus.Config{}
+	appCfg.Admin.Enabled = false
+	appCfg.Billing.Enabled = false
+	appCfg.Telemetry.Enabled = false
+	appCfg.LLMCache.Enabled = false
+	appCfg.Drift.Enabled = false
+	appCfg.Quality.Enabled = false
+	appCfg.StateStore = statestore.Config{}
+	appCfg.Recovery.ResumeOnStart = false
+}
+
+// generationFor sets up how the agent produces its main completion: as the
+// best of several when its [agents.<name>.n_best] table says so, and
+// validated against its output_schema when it supports structured output.
+func generationFor(container *di.Container, appCfg *appconfig.Config, agent string, gen *partial.Generator, structured bool) (generation, error) {
+	acfg := appCfg.Agents[agent]
+	g := generation{gen: gen}
+	if acfg.OutputSchema != "" {
+		if !structured {
+			return generation{}, fmt.Errorf("output_schema is not supported by this agent")
+		}
+		var err error
+		if g.output, err = schema.Load(acfg.OutputSchema); err != nil {
+			return generation{}, fmt.Errorf("output_schema: %w", err)
+		}
+	}
+	if acfg.NBest.Enabled() {
+		judge, err := container.AgentProvider(agent, cmp.Or(acfg.NBest.Judge, acfg.Provider))
+		if err != nil {
+			return generation{}, fmt.Errorf("n_best judge: %w", err)
+		}
+		if g.best, err = nbest.New(*acfg.NBest, judge); err != nil {
+			return generation{}, err
+		}
+	}
+	return g, nil
+}
+
+// promptFor loads the agent's prompt templates over builtin; its
+// system_prompt, when set, stands in for the built-in system template.
+func promptFor(appCfg *appconfig.Config, d di.Deps, builtin prompts.Source) (*prompts.Template, error) {
+	builtin.System = cmp.Or(d.SystemPrompt, builtin.System)
+	return prompts.Load(appCfg.Prompts, d.Name, appCfg.Agents[d.Name].Prompt, builtin)
+}
+
+// encryptionInventory lists the connections and stores configured in cfg and
+// appCfg and whether each is encrypted, for compliance reports.
+func encryptionInventory(cfg *core.Config, appCfg *appconfig.Config) []compliance.EncryptionStatus {
+	var out []compliance.EncryptionStatus
+	out = append(out, providerTransport("provider default", cfg.LLM.Provider, "", appCfg.LLM.BaseURL))
+	names := make([]string, 0, len(appCfg.Providers))
+	for name := range appCfg.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := appCfg.Providers[name]
+		out = append(out, providerTransport("provider "+name, p.Type, p.Endpoint, p.BaseURL))
+	}
+
+	atRest := "not encrypted by the application; relies on volume or disk encryption"
+	dbPath := appCfg.Storage.Path
+	if dbPath == "" {
+		dbPath = storage.DefaultPath
+	}
+	out = append(out, compliance.EncryptionStatus{Component: "database " + dbPath, Detail: "SQLite file " + atRest})
+	if appCfg.History.Backend == storage.BackendFile {
+		out = append(out, compliance.EncryptionStatus{Component: "history files", Detail: "JSON files " + atRest})
+	}
+	if appCfg.Admin.Enabled {
+		addr := appCfg.Admin.Addr
+		if addr == "" {
+			addr = "127.0.0.1:9090"
+		}
+		out = append(out, compliance.EncryptionStatus{Component: "admin API", Detail: "plain HTTP on " + addr + " with bearer token; terminate TLS in front of it when exposed beyond localhost"})
+	}
+	if appCfg.Billing.Enabled {
+		out = append(out, urlTransport("billing", cmp.Or(appCfg.Billing.URL, billing.DefaultStripeURL)))
+	}
+	if appCfg.Telemetry.Enabled {
+		out = append(out, urlTransport("telemetry", appCfg.Telemetry.Endpoint))
+	}
+	if bus := appCfg.EventBus; bus.Backend != "" && bus.Backend != eventbus.BackendMemory {
+		status := urlTransport("event bus "+bus.Backend, bus.URL)
+		if u, err := url.Parse(bus.URL); err == nil {
+			switch u.Scheme {
+			case "tls", "rediss":
+				status.Encrypted = true
+			case "nats":
+				// Upgraded only if the server requires it, which the
+				// configuration can't tell
+				status.Detail += "; TLS only when the server requires it, use tls:// to insist"
+			}
+		}
+		out = append(out, status)
+	}
+	return out
+}
+
+// openRuns opens the run history, tiered to cold storage when
+// [cold_storage] is enabled.
+func openRuns(appCfg *appconfig.Config) (history.Store, error) {
+	runs, err := history.Open(appCfg.History)
+	if err != nil || !appCfg.ColdStorage.Enabled {
+		return runs, err
+	}
+	archive, err := coldstore.Open(appCfg.ColdStorage)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open cold storage: %w", err)
+	}
+	db, err := storage.Open(appCfg.Storage.Path)
+	if err != nil {
+		return nil, err
+	}
+	return history.NewTieredStore(runs, db, archive)
+}
+
+// newMaintenance schedules the maintenance jobs over the stores that are
+// configured: every SQLite database the process opened, the LLM cache, and
+// the cold storage of runs and documents.
+func newMaintenance(cfg maintenance.Config, cache *llmcache.Cache, runs history.Store, vectors *retrieval.Retriever) *maintenance.Scheduler {
+	s := maintenance.New(cfg)
+	s.Add(maintenance.JobVacuum, func(ctx context.Context) (maintenance.Result, error) {
+		var res maintenance.Result
+		for _, path := range storage.Opened() {
+			n, err := storage.Vacuum(ctx, path)
+			if err != nil {
+				return res, err
+			}
+			res.Bytes += n
+		}
+		return res, nil
+	})
+	s.Add(maintenance.JobReindex, func(ctx context.Context) (maintenance.Result, error) {
+		for _, path := range storage.Opened() {
+			if err := storage.Reindex(ctx, path); err != nil {
+				return maintenance.Result{}, err
+			}
+		}
+		return maintenance.Result{}, nil
+	})
+	if cache != nil {
+		s.Add(maintenance.JobCaches, func(ctx context.Context) (maintenance.Result, error) {
+			n, size, err := cache.Compact(ctx)
+			return maintenance.Result{Removed: n, Bytes: size}, err
+		})
+	}
+	tiered, _ := runs.(*history.TieredStore)
+	if tiered != nil || vectors != nil {
+		s.Add(maintenance.JobOrphans, func(ctx context.Context) (maintenance.Result, error) {
+			// Objects archived within the hour may still be getting indexed
+			cutoff := time.Now().Add(-time.Hour)
+			var res maintenance.Result
+			sweeps := []func(context.Context, time.Time) (int, int64, error){}
+			if tiered != nil {
+				sweeps = append(sweeps, tiered.SweepOrphans)
+			}
+			if vectors != nil {
+				sweeps = append(sweeps, vectors.SweepOrphans)
+			}
+			for _, sweep := range sweeps {
+				n, size, err := sweep(ctx, cutoff)
+				res.Removed += n
+				res.Bytes += size
+				if err != nil {
+					return res, err
+				}
+			}
+			return res, nil
+		})
+	}
+	return s
+}
+
+// defaultProvider builds the provider of agents that don't name one from
+// [llm], paced by its rate limits, when there is no [providers.default].
+// That table, which can carry the API key and endpoint [llm] has no room
+// for, is built by the container like any provider, so its key rotates.
+// [llm] regions keep their latency and health in regions.
+func defaultProvider(cfg *core.Config, appCfg *appconfig.Config, regions *region.Metrics) (core.ModelProvider, error) {
+	var provider core.ModelProvider
+	var err error
+	if di.Native(cfg.LLM.Provider) {
+		// [llm] takes the types agenticgokit doesn't implement too, and
+		// their regions
+		provider, err = di.NewRouted(di.DefaultProvider, core.LLMProviderConfig{
+			Type:        cfg.LLM.Provider,
+			Model:       cfg.LLM.Model,
+			MaxTokens:   cfg.LLM.MaxTokens,
+			Temperature: cfg.LLM.Temperature,
+			BaseURL:     appCfg.LLM.BaseURL,
+			HTTPTimeout: time.Duration(cfg.LLM.TimeoutSeconds) * time.Second,
+		}, appCfg.LLM.ProviderOptions, regions)
+	} else if len(appCfg.LLM.Regions) > 0 {
+		return nil, fmt.Errorf("[llm] regions need a %s provider configured as [providers.%s]", cfg.LLM.Provider, di.DefaultProvider)
+	} else {
+		provider, err = cfg.InitializeProvider()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ratelimit.New(appCfg.LLM.RateLimitOptions).Wrap(provider), nil
+}
+
+// providerHosts lists the host[:port] of every configured provider.
+func providerHosts(cfg *core.Config, appCfg *appconfig.Config) []string {
+	hosts := []string{providerHost(cfg.LLM.Provider, "", appCfg.LLM.BaseURL, appCfg.LLM.ProviderOptions)}
+	for name, p := range appCfg.Providers {
+		if h := providerHost(p.Type, p.Endpoint, p.BaseURL, appCfg.ProviderOptions[name]); h != "" && !slices.Contains(hosts, h) {
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts
+}
+
+// providerHost is the host a provider talks to: its endpoint's, or else the
+// vendor API's.
+func providerHost(typ, endpoint, baseURL string, opts appconfig.ProviderOptions) string {
+	if raw := cmp.Or(endpoint, baseURL); raw != "" {
+		if u, err := url.Parse(raw); err == nil {
+			return u.Host
+		}
+		return ""
+	}
+	switch typ {
+	case "ollama":
+		return "localhost:11434"
+	case "openai", "":
+		return "api.openai.com"
+	case anthropic.Type:
+		return "api.anthropic.com"
+	case gemini.Type:
+		return "generativelanguage.googleapis.com"
+	case bedrock.Type:
+		if region := bedrock.Region(opts.BedrockOptions); region != "" {
+			return bedrock.Host(region)
+		}
+		return ""
+	default:
+		return ""
+	}
+}
+
+// providerTransport describes the connection to an LLM provider. Providers
+// without an endpoint use the vendor's HTTPS API, except Ollama, which
+// defaults to a local plain HTTP server.
+func providerTransport(component, typ, endpoint, baseURL string) compliance.EncryptionStatus {
+	if u := cmp.Or(endpoint, baseURL); u != "" {
+		return urlTransport(component, u)
+	}
+	if typ == "ollama" {
+		return compliance.EncryptionStatus{Component: component, Detail: "ollama at http://localhost:11434 (local)"}
+	}
+	return compliance.EncryptionStatus{Component: component, Encrypted: true, Detail: typ + " API over HTTPS"}
+}
+
+func urlTransport(component, raw string) compliance.EncryptionStatus {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return compliance.EncryptionStatus{Component: component, Detail: "unparseable endpoint " + raw}
+	}
+	return compliance.EncryptionStatus{Component: component, Encrypted: strings.EqualFold(u.Scheme, "https"), Detail: u.Scheme + "://" + u.Host}
+}
+
+// Close releases the resources newApp opened.
+func (a *application) Close() {
+	for i := len(a.closers) - 1; i >= 0; i-- {
+		a.closers[i]()
+	}
+}
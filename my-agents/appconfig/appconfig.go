@@ -0,0 +1,275 @@
+// Package appconfig loads the application-specific sections of agentflow.toml
+// that the agenticgokit core config does not know about. Both parsers read the
+// same file; each ignores the keys it doesn't own.
+package appconfig
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/BurntSushi/toml"
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/admin"
+	"my-agents/anthropic"
+	"my-agents/audit"
+	"my-agents/bedrock"
+	"my-agents/billing"
+	"my-agents/blackboard"
+	"my-agents/catalog"
+	"my-agents/clarify"
+	"my-agents/coldstore"
+	"my-agents/compliance"
+	"my-agents/conversation"
+	"my-agents/credentials"
+	"my-agents/deadletter"
+	"my-agents/debate"
+	"my-agents/drift"
+	"my-agents/eventbus"
+	"my-agents/fallback"
+	"my-agents/flags"
+	"my-agents/gemini"
+	"my-agents/guardrail"
+	"my-agents/history"
+	"my-agents/httpserver"
+	"my-agents/ingest"
+	"my-agents/langdetect"
+	"my-agents/llmcache"
+	"my-agents/local"
+	"my-agents/locale"
+	"my-agents/maintenance"
+	"my-agents/mcp"
+	"my-agents/middleware"
+	"my-agents/modelroute"
+	"my-agents/nbest"
+	"my-agents/ocr"
+	"my-agents/parallel"
+	"my-agents/partial"
+	"my-agents/personalize"
+	"my-agents/plan"
+	"my-agents/policy"
+	"my-agents/profile"
+	"my-agents/prompts"
+	"my-agents/quality"
+	"my-agents/quota"
+	"my-agents/ratelimit"
+	"my-agents/region"
+	"my-agents/retrieval"
+	"my-agents/retry"
+	"my-agents/simulate"
+	"my-agents/statestore"
+	"my-agents/storage"
+	"my-agents/stylelint"
+	"my-agents/telemetry"
+	"my-agents/tools/sandbox"
+	"my-agents/tot"
+	"my-agents/usage"
+	"my-agents/vcr"
+)
+
+// Config holds the application-level configuration.
+type Config struct {
+	SchemaVersion int                               `toml:"schema_version"`
+	Providers     map[string]core.LLMProviderConfig `toml:"providers"`
+	// ProviderOptions are the rest of each [providers.<name>] table.
+	ProviderOptions map[string]ProviderOptions `toml:"-"`
+	// LLM is the rest of the [llm] table agenticgokit reads.
+	LLM       LLMOptions             `toml:"llm"`
+	Agents    map[string]AgentConfig `toml:"agents"`
+	Prompts   prompts.Config         `toml:"prompts"`
+	Workflows catalog.Config         `toml:"workflows"`
+
+	FeatureFlags flags.Config `toml:"feature_flags"`
+
+	DefaultLocale   string                      `toml:"default_locale"`
+	Locales         map[string]locale.Overrides `toml:"locales"`
+	LanguageRouting langdetect.Config           `toml:"language_routing"`
+
+	Storage    storage.Config    `toml:"storage"`
+	EventBus   eventbus.Config   `toml:"event_bus"`
+	Guardrail  guardrail.Config  `toml:"guardrail"`
+	History    history.Config    `toml:"history"`
+	Formatter  FormatterConfig   `toml:"formatter"`
+	StyleLint  stylelint.Config  `toml:"style_lint"`
+	OCR        ocr.Config        `toml:"ocr"`
+	Knowledge  ingest.Config     `toml:"knowledge"`
+	Retrieval  retrieval.Config  `toml:"retrieval"`
+	Sandbox    sandbox.Config    `toml:"sandbox"`
+	MCP        mcp.Config        `toml:"mcp_client"`
+	Recovery   partial.Config    `toml:"recovery"`
+	Retry      retry.Policy      `toml:"retry"`
+	Middleware middleware.Config `toml:"middleware"`
+	DeadLetter deadletter.Config `toml:"dead_letter"`
+	Drift      drift.Config      `toml:"drift"`
+	Quality    quality.Config    `toml:"quality"`
+	StateStore statestore.Config `toml:"state_store"`
+	Plan       plan.Config       `toml:"plan"`
+	Policy     policy.Config     `toml:"policy"`
+	Audit      audit.Config      `toml:"audit"`
+	Compliance compliance.Config `toml:"compliance"`
+	// ColdStorage moves old runs and idle documents out of the hot stores.
+	ColdStorage coldstore.Config `toml:"cold_storage"`
+	// Maintenance vacuums, reindexes and compacts the stores on a schedule.
+	Maintenance maintenance.Config `toml:"maintenance"`
+
+	Conversation    conversation.Config `toml:"conversation"`
+	Personalization personalize.Config  `toml:"personalization"`
+	Profiles        profile.Config      `toml:"profiles"`
+
+	Clarification clarify.Config    `toml:"clarification"`
+	TreeOfThought tot.Config        `toml:"tree_of_thought"`
+	Blackboard    blackboard.Config `toml:"blackboard"`
+	Debate        debate.Config     `toml:"debate"`
+	// Parallel fan-outs by the name events are routed to.
+	Parallel map[string]parallel.Config `toml:"parallel"`
+
+	Simulation   simulate.Config    `toml:"simulation"`
+	ModelRouting modelroute.Config  `toml:"model_routing"`
+	Quotas       quota.Config       `toml:"quotas"`
+	Usage        usage.Config       `toml:"usage"`
+	LLMCache     llmcache.Config    `toml:"llm_cache"`
+	Billing      billing.Config     `toml:"billing"`
+	Admin        admin.Config       `toml:"admin"`
+	HTTP         httpserver.Config  `toml:"http"`
+	Credentials  credentials.Config `toml:"credentials"`
+	Telemetry    telemetry.Config   `toml:"telemetry"`
+	VCR          vcr.Config         `toml:"vcr"`
+}
+
+// ProviderOptions are the settings of a provider table that agenticgokit's
+// provider config has no room for, read by the providers implemented here.
+// Each provider's are embedded, so their keys sit in the table itself.
+type ProviderOptions struct {
+	LocalOptions
+	AnthropicOptions
+	GeminiOptions
+	BedrockOptions
+	RateLimitOptions
+	RegionOptions
+}
+
+// Provider options by package, named so they can be embedded side by side.
+type (
+	LocalOptions     = local.Options
+	AnthropicOptions = anthropic.Options
+	GeminiOptions    = gemini.Options
+	BedrockOptions   = bedrock.Options
+	RateLimitOptions = ratelimit.Limits
+	RegionOptions    = region.Options
+)
+
+// LLMOptions are the [llm] settings agenticgokit doesn't read.
+type LLMOptions struct {
+	BaseURL string `toml:"base_url"`
+	ProviderOptions
+}
+
+// FormatterConfig controls how the formatter renders the final response.
+type FormatterConfig struct {
+	// ShowToolResults appends a section per tool call (query, result table,
+	// sources). Callers can override it with the "show_tools" metadata key.
+	ShowToolResults bool `toml:"show_tool_results"`
+	// ExpandToolResults renders those sections open instead of collapsed.
+	ExpandToolResults bool `toml:"expand_tool_results"`
+	// ShowWork appends how the answer was produced — the agents, tools and
+	// sources the run recorded. Callers can override it with the
+	// "show_work" metadata key.
+	ShowWork bool `toml:"show_work"`
+	// EmbedCharts appends a ```vega-lite block for each chartable table.
+	// Tables and chart specs are always available in state.
+	EmbedCharts bool `toml:"embed_charts"`
+	// Stream shows the response on sinks that can (stdout) as it is
+	// generated. Callers subscribed to a run's stream get it regardless.
+	Stream bool `toml:"stream"`
+}
+
+// AgentConfig declares the dependencies an agent is wired with at startup.
+type AgentConfig struct {
+	// Extends builds this agent with another agent's implementation, e.g. a
+	// "processor-de" variant of "processor" with its own system prompt.
+	Extends string `toml:"extends"`
+	// SystemPrompt replaces the built-in system prompt template; a system
+	// template in Prompt replaces both.
+	SystemPrompt string `toml:"system_prompt"`
+	// Prompt overrides the agent's system and user prompt templates.
+	Prompt   prompts.Source `toml:"prompt"`
+	Provider string         `toml:"provider"`
+	Tools    []string       `toml:"tools"`
+	Sinks    []string       `toml:"sinks"`
+	// MaxSteps bounds the ReAct loop of agents wired with tools.
+	MaxSteps int `toml:"max_steps"`
+	// Retry replaces the [retry] policy for this agent.
+	Retry *retry.Policy `toml:"retry"`
+	// Fallback names providers to fail over to, in order, when Provider is
+	// rate limited, times out or fails with a server error.
+	Fallback *fallback.Config `toml:"fallback"`
+	// Middleware names agent middleware to run around this agent only.
+	Middleware []string `toml:"middleware"`
+	// NBest has the agent generate several candidates and keep the one a
+	// judge rates best.
+	NBest *nbest.Config `toml:"n_best"`
+	// OutputSchema is a JSON Schema, inline or the path of a file, the
+	// agent's reply must be valid against; the processor and enhancer
+	// support it.
+	OutputSchema string `toml:"output_schema"`
+	// Cache set to false leaves the agent's calls out of the [llm_cache]
+	// response cache, e.g. when its replies should vary between runs.
+	Cache *bool `toml:"cache"`
+}
+
+// Load reads the application config from path.
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read configuration file %s: %w", path, err)
+	}
+	return Parse(data)
+}
+
+// Parse decodes the application config from TOML data.
+func Parse(data []byte) (*Config, error) {
+	var cfg Config
+	if err := toml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse TOML configuration: %w", err)
+	}
+	if cfg.Providers == nil {
+		cfg.Providers = make(map[string]core.LLMProviderConfig)
+	}
+	// The provider tables again, for the settings agenticgokit skips
+	var options struct {
+		Providers map[string]ProviderOptions `toml:"providers"`
+	}
+	if err := toml.Unmarshal(data, &options); err != nil {
+		return nil, fmt.Errorf("failed to parse TOML configuration: %w", err)
+	}
+	cfg.ProviderOptions = options.Providers
+	if cfg.ProviderOptions == nil {
+		cfg.ProviderOptions = make(map[string]ProviderOptions)
+	}
+	if cfg.Agents == nil {
+		cfg.Agents = make(map[string]AgentConfig)
+	}
+	// Stores on the embedded database share the [storage] file by default
+	if cfg.History.Path == "" && (cfg.History.Backend == "" || cfg.History.Backend == storage.BackendSQLite) {
+		cfg.History.Path = cfg.Storage.Path
+	}
+	if cfg.Recovery.Path == "" && (cfg.Recovery.Backend == "" || cfg.Recovery.Backend == storage.BackendSQLite) {
+		cfg.Recovery.Path = cfg.Storage.Path
+	}
+	if cfg.Audit.Path == "" && (cfg.Audit.Backend == "" || cfg.Audit.Backend == storage.BackendSQLite) {
+		cfg.Audit.Path = cfg.Storage.Path
+	}
+	if cfg.DeadLetter.Path == "" {
+		cfg.DeadLetter.Path = cfg.Storage.Path
+	}
+	if cfg.Drift.Path == "" {
+		cfg.Drift.Path = cfg.Storage.Path
+	}
+	if cfg.Quality.Path == "" {
+		cfg.Quality.Path = cfg.Storage.Path
+	}
+	if cfg.StateStore.Path == "" && cfg.StateStore.Backend == statestore.BackendSQLite {
+		cfg.StateStore.Path = cfg.Storage.Path
+	}
+	return &cfg, nil
+}
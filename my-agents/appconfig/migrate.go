@@ -0,0 +1,109 @@
+package appconfig
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+)
+
+// SchemaVersion is the current agentflow.toml schema version. Files without a
+// schema_version key are treated as version 0.
+const SchemaVersion = 1
+
+// Migration upgrades a decoded config document from one schema version to
+// the next, returning a human readable description of every change it made.
+type Migration struct {
+	From        int
+	Description string
+	Apply       func(doc map[string]any) []string
+}
+
+// migrations are applied in order; migrations[i] upgrades version i to i+1.
+var migrations = []Migration{
+	{
+		From:        0,
+		Description: "move legacy [agent_flow].provider into [llm].provider",
+		Apply: func(doc map[string]any) []string {
+			var changes []string
+			flow := table(doc, "agent_flow", false)
+			if flow == nil {
+				return nil
+			}
+			legacy, ok := flow["provider"].(string)
+			if !ok {
+				return nil
+			}
+			llm := table(doc, "llm", true)
+			if current, _ := llm["provider"].(string); current == "" {
+				llm["provider"] = legacy
+				changes = append(changes, fmt.Sprintf("set [llm].provider = %q from [agent_flow].provider", legacy))
+			}
+			delete(flow, "provider")
+			changes = append(changes, "removed [agent_flow].provider")
+			if len(flow) == 0 {
+				delete(doc, "agent_flow")
+			}
+			return changes
+		},
+	},
+}
+
+// MigrationResult describes the outcome of Migrate.
+type MigrationResult struct {
+	FromVersion int
+	ToVersion   int
+	Changes     []string
+	Output      []byte
+}
+
+// Migrate upgrades TOML config data to SchemaVersion. The re-encoded output
+// does not preserve comments or key order.
+func Migrate(data []byte) (*MigrationResult, error) {
+	doc := make(map[string]any)
+	if err := toml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse TOML configuration: %w", err)
+	}
+
+	version := 0
+	if v, ok := doc["schema_version"].(int64); ok {
+		version = int(v)
+	}
+	if version < 0 {
+		return nil, fmt.Errorf("config schema_version %d is negative", version)
+	}
+	if version > SchemaVersion {
+		return nil, fmt.Errorf("config schema_version %d is newer than supported version %d", version, SchemaVersion)
+	}
+
+	result := &MigrationResult{FromVersion: version, ToVersion: SchemaVersion}
+	for _, m := range migrations[version:] {
+		for _, change := range m.Apply(doc) {
+			result.Changes = append(result.Changes, fmt.Sprintf("v%d→v%d: %s", m.From, m.From+1, change))
+		}
+	}
+	if version != SchemaVersion {
+		doc["schema_version"] = SchemaVersion
+		result.Changes = append(result.Changes, fmt.Sprintf("set schema_version = %d", SchemaVersion))
+	}
+
+	var buf bytes.Buffer
+	if err := toml.NewEncoder(&buf).Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to encode migrated configuration: %w", err)
+	}
+	result.Output = buf.Bytes()
+	return result, nil
+}
+
+// table returns doc[name] as a table, creating it when create is set.
+func table(doc map[string]any, name string, create bool) map[string]any {
+	if t, ok := doc[name].(map[string]any); ok {
+		return t
+	}
+	if !create {
+		return nil
+	}
+	t := make(map[string]any)
+	doc[name] = t
+	return t
+}
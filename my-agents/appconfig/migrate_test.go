@@ -0,0 +1,63 @@
+package appconfig
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+func TestMigrateLegacyProvider(t *testing.T) {
+	result, err := Migrate([]byte("[agent_flow]\nprovider = \"ollama\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FromVersion != 0 || result.ToVersion != SchemaVersion {
+		t.Errorf("migrated v%d→v%d, want v0→v%d", result.FromVersion, result.ToVersion, SchemaVersion)
+	}
+	var doc struct {
+		SchemaVersion int `toml:"schema_version"`
+		LLM           struct {
+			Provider string `toml:"provider"`
+		} `toml:"llm"`
+		AgentFlow map[string]any `toml:"agent_flow"`
+	}
+	if _, err := toml.Decode(string(result.Output), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.SchemaVersion != SchemaVersion || doc.LLM.Provider != "ollama" || doc.AgentFlow != nil {
+		t.Errorf("migrated config = %+v", doc)
+	}
+	if !slices.ContainsFunc(result.Changes, func(c string) bool { return strings.Contains(c, "removed [agent_flow].provider") }) {
+		t.Errorf("changes %q don't mention the removed key", result.Changes)
+	}
+}
+
+func TestMigrateKeepsExistingProvider(t *testing.T) {
+	result, err := Migrate([]byte("[agent_flow]\nprovider = \"ollama\"\n[llm]\nprovider = \"openai\"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(result.Output), `provider = "openai"`) {
+		t.Errorf("migrated config lost [llm].provider:\n%s", result.Output)
+	}
+}
+
+func TestMigrateCurrentIsNoop(t *testing.T) {
+	result, err := Migrate([]byte("schema_version = 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Changes) != 0 {
+		t.Errorf("current config changed: %q", result.Changes)
+	}
+}
+
+func TestMigrateRejectsBadVersions(t *testing.T) {
+	for _, in := range []string{"schema_version = -1\n", "schema_version = 99\n", "not toml ="} {
+		if _, err := Migrate([]byte(in)); err == nil {
+			t.Errorf("Migrate(%q) succeeded", in)
+		}
+	}
+}
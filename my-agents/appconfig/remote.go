@@ -0,0 +1,199 @@
+package appconfig
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Source fetches raw agentflow.toml data together with an opaque version
+// string that changes whenever the content changes.
+type Source interface {
+	Fetch(ctx context.Context) (data []byte, version string, err error)
+}
+
+// OpenSource returns a Source for a config URL. Supported schemes:
+//
+//	file:///etc/agentflow.toml
+//	http(s)://host/agentflow.toml        (ETag / Last-Modified aware; S3 presigned URLs work here)
+//	s3://bucket/key                      (public objects via the virtual-hosted endpoint)
+//	consul://host:8500/path/to/key       (Consul KV, raw value)
+//	etcd://host:2379/path/to/key         (etcd v3 JSON gateway)
+func OpenSource(rawURL string) (Source, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config URL %q: %w", rawURL, err)
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	key := strings.TrimPrefix(u.Path, "/")
+
+	switch u.Scheme {
+	case "", "file":
+		return fileSource(u.Path), nil
+	case "http", "https":
+		return &httpSource{client: client, url: rawURL}, nil
+	case "s3":
+		return &httpSource{client: client, url: fmt.Sprintf("https://%s.s3.amazonaws.com/%s", u.Host, key)}, nil
+	case "consul":
+		return &httpSource{client: client, url: fmt.Sprintf("http://%s/v1/kv/%s?raw", u.Host, key), versionHeader: "X-Consul-Index"}, nil
+	case "etcd":
+		return &etcdSource{client: client, endpoint: "http://" + u.Host, key: "/" + key}, nil
+	default:
+		return nil, fmt.Errorf("unsupported config URL scheme %q", u.Scheme)
+	}
+}
+
+type fileSource string
+
+func (f fileSource) Fetch(ctx context.Context) ([]byte, string, error) {
+	info, err := os.Stat(string(f))
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(string(f))
+	if err != nil {
+		return nil, "", err
+	}
+	return data, fmt.Sprintf("%d-%d", info.ModTime().UnixNano(), info.Size()), nil
+}
+
+type httpSource struct {
+	client        *http.Client
+	url           string
+	versionHeader string
+}
+
+func (h *httpSource) Fetch(ctx context.Context) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.url, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch config from %s: %w", h.url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to fetch config from %s: %s", h.url, resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	version := ""
+	for _, header := range []string{h.versionHeader, "ETag", "Last-Modified"} {
+		if header == "" {
+			continue
+		}
+		if version = resp.Header.Get(header); version != "" {
+			break
+		}
+	}
+	if version == "" {
+		version = fmt.Sprintf("%x", sha256.Sum256(data))
+	}
+	return data, version, nil
+}
+
+type etcdSource struct {
+	client   *http.Client
+	endpoint string
+	key      string
+}
+
+func (e *etcdSource) Fetch(ctx context.Context) ([]byte, string, error) {
+	body, _ := json.Marshal(map[string]string{"key": base64.StdEncoding.EncodeToString([]byte(e.key))})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint+"/v3/kv/range", bytes.NewReader(body))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to fetch config from etcd: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to fetch config from etcd: %s", resp.Status)
+	}
+
+	var out struct {
+		KVs []struct {
+			Value       string `json:"value"`
+			ModRevision string `json:"mod_revision"`
+		} `json:"kvs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, "", fmt.Errorf("failed to decode etcd response: %w", err)
+	}
+	if len(out.KVs) == 0 {
+		return nil, "", fmt.Errorf("etcd key %s not found", e.key)
+	}
+	data, err := base64.StdEncoding.DecodeString(out.KVs[0].Value)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, out.KVs[0].ModRevision, nil
+}
+
+// Materialize fetches src once and writes it to a temporary file so that
+// path-based loaders (core.LoadConfig, core.NewRunnerFromConfig) can read it.
+func Materialize(ctx context.Context, src Source) (path, version string, err error) {
+	data, version, err := src.Fetch(ctx)
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := Parse(data); err != nil {
+		return "", "", err
+	}
+	f, err := os.CreateTemp("", "agentflow-*.toml")
+	if err != nil {
+		return "", "", err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return "", "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return "", "", err
+	}
+	return f.Name(), version, nil
+}
+
+// Watch polls src every interval and calls onChange with the new data and
+// its version each time the version differs from lastVersion. Fetch errors
+// are passed to onChange with nil data so callers can log them; the previous
+// config stays in effect. Watch returns when ctx is cancelled.
+func Watch(ctx context.Context, src Source, interval time.Duration, lastVersion string, onChange func(data []byte, version string, err error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			data, version, err := src.Fetch(ctx)
+			if err != nil {
+				onChange(nil, "", err)
+				continue
+			}
+			if version == lastVersion {
+				continue
+			}
+			lastVersion = version
+			onChange(data, version, nil)
+		}
+	}
+}
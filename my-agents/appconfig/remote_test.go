@@ -0,0 +1,100 @@
+package appconfig
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const remoteConfig = "schema_version = 1\n"
+
+func TestMaterialize(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "agentflow.toml")
+	if err := os.WriteFile(src, []byte(remoteConfig), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	s, err := OpenSource("file://" + src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path, version, err := Materialize(context.Background(), s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(path)
+	if data, err := os.ReadFile(path); err != nil || string(data) != remoteConfig || version == "" {
+		t.Errorf("materialized %q at version %q, %v", data, version, err)
+	}
+}
+
+func TestMaterializeRejectsInvalidConfig(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "agentflow.toml")
+	if err := os.WriteFile(src, []byte("not toml ="), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Materialize(context.Background(), fileSource(src)); err == nil {
+		t.Error("an invalid config was materialized")
+	}
+}
+
+func TestHTTPSourceVersion(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v2"`)
+		w.Write([]byte(remoteConfig))
+	}))
+	defer srv.Close()
+	s, err := OpenSource(srv.URL + "/agentflow.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, version, err := s.Fetch(context.Background())
+	if err != nil || string(data) != remoteConfig || version != `"v2"` {
+		t.Errorf("Fetch = %q, %q, %v", data, version, err)
+	}
+}
+
+func TestOpenSourceRejectsUnknownScheme(t *testing.T) {
+	if _, err := OpenSource("ftp://host/agentflow.toml"); err == nil {
+		t.Error("an ftp URL was accepted")
+	}
+}
+
+type versions []string
+
+func (v *versions) Fetch(context.Context) ([]byte, string, error) {
+	next := (*v)[0]
+	if len(*v) > 1 {
+		*v = (*v)[1:]
+	}
+	return []byte(next), next, nil
+}
+
+func TestWatchReportsChanges(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	src := &versions{"a", "a", "b", "b"}
+	changes := make(chan string, 4)
+	go Watch(ctx, src, time.Millisecond, "a", func(data []byte, version string, err error) {
+		if err != nil || string(data) != version {
+			t.Errorf("onChange(%q, %q, %v)", data, version, err)
+		}
+		changes <- version
+	})
+	select {
+	case v := <-changes:
+		if v != "b" {
+			t.Errorf("first change = %q, want b", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the change was never reported")
+	}
+	select {
+	case v := <-changes:
+		t.Errorf("unchanged version %q reported again", v)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
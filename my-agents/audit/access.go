@@ -0,0 +1,55 @@
+package audit
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Access is one request to an operator interface such as the admin API.
+type Access struct {
+	ID         int64     `json:"id"`
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Status     int       `json:"status"`
+	Remote     string    `json:"remote"` // client IP
+	Authorized bool      `json:"authorized"`
+}
+
+// Erasure is a processed request to delete a data subject's data.
+type Erasure struct {
+	ID          int64     `json:"id"`
+	Time        time.Time `json:"time"`
+	SessionID   string    `json:"session_id"`
+	RequestedBy string    `json:"requested_by,omitempty"`
+	Reason      string    `json:"reason,omitempty"`
+	Runs        int       `json:"runs"`             // run records deleted
+	Checkpoints int       `json:"checkpoints"`      // partial generations deleted
+	ToolCalls   int       `json:"tool_calls"`       // tool call records with arguments cleared
+	Memory      []string  `json:"memory,omitempty"` // memory sessions cleared
+	// UserID is the user erased with their sessions, when one was named.
+	UserID       string `json:"user_id,omitempty"`
+	Profile      bool   `json:"profile,omitempty"`       // profile, preferences and traits deleted
+	UsageRecords int    `json:"usage_records,omitempty"` // usage ledger records stripped of the user and sessions
+	CacheEntries int    `json:"cache_entries,omitempty"` // LLM cache entries flushed
+	Error        string `json:"error,omitempty"`         // what could not be deleted
+}
+
+// AccessRecorder records requests into log. It has the shape of
+// admin.Server's access hook.
+func AccessRecorder(log Log) func(r *http.Request, status int, authorized bool) {
+	return func(r *http.Request, status int, authorized bool) {
+		remote, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			remote = r.RemoteAddr
+		}
+		access := &Access{Time: time.Now(), Method: r.Method, Path: r.URL.Path, Status: status, Remote: remote, Authorized: authorized}
+		if err := log.RecordAccess(context.WithoutCancel(r.Context()), access); err != nil {
+			core.Logger().Error().Str("path", access.Path).Err(err).Msg("Failed to record admin access")
+		}
+	}
+}
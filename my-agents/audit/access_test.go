@@ -0,0 +1,97 @@
+package audit
+
+import (
+	"context"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestAccessRecorder(t *testing.T) {
+	log := &MemoryLog{}
+	record := AccessRecorder(log)
+	r := httptest.NewRequest("POST", "/admin/pause", nil)
+	r.RemoteAddr = "10.0.0.7:5123"
+	record(r, 401, false)
+
+	got, err := log.Accesses(context.Background(), time.Time{}, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Remote != "10.0.0.7" || got[0].Path != "/admin/pause" || got[0].Status != 401 || got[0].Authorized {
+		t.Errorf("accesses = %+v", got)
+	}
+}
+
+func TestAccesses(t *testing.T) {
+	for name, log := range logs(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			start := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+			for i := 0; i < 3; i++ {
+				log.RecordAccess(ctx, &Access{Time: start.Add(time.Duration(i) * time.Hour), Method: "GET", Path: "/admin/status", Status: 200, Authorized: true})
+			}
+			got, err := log.Accesses(ctx, start.Add(time.Hour), start.Add(2*time.Hour))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 || !got[0].Time.Equal(start.Add(time.Hour)) || !got[0].Authorized {
+				t.Errorf("accesses in [1h, 2h) = %+v", got)
+			}
+		})
+	}
+}
+
+func TestErasures(t *testing.T) {
+	for name, log := range logs(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			at := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+			in := &Erasure{Time: at, SessionID: "s1", RequestedBy: "dpo", Runs: 2, Memory: []string{"s1", "s1-notes"}, UserID: "ann", Profile: true, UsageRecords: 4, CacheEntries: 1}
+			if err := log.RecordErasure(ctx, in); err != nil {
+				t.Fatal(err)
+			}
+			got, err := log.Erasures(ctx, at, time.Time{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != 1 {
+				t.Fatalf("got %d erasures", len(got))
+			}
+			e := got[0]
+			if e.ID != in.ID || e.SessionID != "s1" || !slices.Equal(e.Memory, in.Memory) || e.UserID != "ann" || !e.Profile || e.UsageRecords != 4 || e.CacheEntries != 1 {
+				t.Errorf("erasure = %+v", e)
+			}
+			if got, _ := log.Erasures(ctx, time.Time{}, at); len(got) != 0 {
+				t.Errorf("erasures before %v = %+v", at, got)
+			}
+		})
+	}
+}
+
+func TestForgetArgs(t *testing.T) {
+	for name, log := range logs(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			start := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+			log.Record(ctx, &ToolCall{RunID: "run-1", Tool: "send_email", Args: map[string]any{"to": "ann@example.com"}, StartedAt: start})
+			log.Record(ctx, &ToolCall{RunID: "run-2", Tool: "send_email", Args: map[string]any{"to": "bob@example.com"}, StartedAt: start.Add(time.Hour)})
+
+			n, err := log.ForgetArgs(ctx, []string{"run-1", "run-3"})
+			if err != nil || n != 1 {
+				t.Fatalf("ForgetArgs = %d, %v", n, err)
+			}
+			if n, _ := log.ForgetArgs(ctx, []string{"run-1"}); n != 0 {
+				t.Errorf("forgetting again changed %d calls", n)
+			}
+			calls, _ := log.List(ctx, Filter{})
+			if calls[0].Args != nil || calls[0].Tool != "send_email" || calls[1].Args["to"] != "bob@example.com" {
+				t.Errorf("calls = %+v", calls)
+			}
+			if calls, _ := log.List(ctx, Filter{Since: start.Add(time.Minute)}); len(calls) != 1 || calls[0].RunID != "run-2" {
+				t.Errorf("calls since 12:01 = %+v", calls)
+			}
+		})
+	}
+}
@@ -0,0 +1,277 @@
+// Package audit keeps a record of every external action agents take — each
+// tool call with its arguments, a hash of its result, how long it took and
+// which agent, run and event made it — along with who used the admin API
+// and the data deletion requests processed.
+package audit
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+	"my-agents/storage"
+	"my-agents/tools"
+)
+
+// Config is the [audit] section of agentflow.toml.
+type Config struct {
+	Enabled bool   `toml:"enabled"`
+	Backend string `toml:"backend"` // "sqlite" (default) or "memory"
+	Path    string `toml:"path"`    // database file (default the [storage] path)
+}
+
+// ToolCall is the audit record of one tool call.
+type ToolCall struct {
+	ID      int64          `json:"id"`
+	RunID   string         `json:"run_id,omitempty"`
+	EventID string         `json:"event_id,omitempty"`
+	Agent   string         `json:"agent"`
+	Tool    string         `json:"tool"`
+	Args    map[string]any `json:"args,omitempty"`
+	// ResultHash is the SHA-256 of the result's JSON encoding, so a result
+	// can be matched against what a system received without storing it.
+	ResultHash string        `json:"result_hash,omitempty"`
+	ResultSize int           `json:"result_size"`
+	Error      string        `json:"error,omitempty"`
+	StartedAt  time.Time     `json:"started_at"`
+	Duration   time.Duration `json:"duration_ns"`
+}
+
+// Filter selects tool calls. Zero fields match everything.
+type Filter struct {
+	RunID string
+	Agent string
+	Tool  string
+	Since time.Time
+	Until time.Time
+	Limit int // most recent calls; 0 for all
+}
+
+// Log persists the audit trail.
+type Log interface {
+	Record(ctx context.Context, call *ToolCall) error
+	// List returns matching calls oldest first.
+	List(ctx context.Context, filter Filter) ([]ToolCall, error)
+	// ForgetArgs clears the arguments recorded for runs' tool calls, keeping
+	// the rest of each record, and returns how many calls it changed.
+	ForgetArgs(ctx context.Context, runIDs []string) (int, error)
+
+	RecordAccess(ctx context.Context, access *Access) error
+	// Accesses returns the accesses in [since, until), oldest first.
+	Accesses(ctx context.Context, since, until time.Time) ([]Access, error)
+
+	RecordErasure(ctx context.Context, erasure *Erasure) error
+	// Erasures returns the erasures in [since, until), oldest first.
+	Erasures(ctx context.Context, since, until time.Time) ([]Erasure, error)
+}
+
+// Open creates the configured log.
+func Open(cfg Config) (Log, error) {
+	switch cfg.Backend {
+	case "", storage.BackendSQLite:
+		db, err := storage.Open(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		return NewSQLiteLog(db)
+	case storage.BackendMemory:
+		return &MemoryLog{}, nil
+	default:
+		return nil, fmt.Errorf("unknown audit backend %q", cfg.Backend)
+	}
+}
+
+// MemoryLog keeps the audit trail in memory.
+type MemoryLog struct {
+	mu       sync.Mutex
+	calls    []ToolCall
+	accesses []Access
+	erasures []Erasure
+}
+
+func (l *MemoryLog) Record(ctx context.Context, call *ToolCall) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	call.ID = int64(len(l.calls) + 1)
+	l.calls = append(l.calls, *call)
+	return nil
+}
+
+func (l *MemoryLog) List(ctx context.Context, filter Filter) ([]ToolCall, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []ToolCall
+	for _, c := range l.calls {
+		if filter.match(&c) {
+			out = append(out, c)
+		}
+	}
+	if filter.Limit > 0 && len(out) > filter.Limit {
+		out = out[len(out)-filter.Limit:]
+	}
+	return out, nil
+}
+
+func (l *MemoryLog) ForgetArgs(ctx context.Context, runIDs []string) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	runs := make(map[string]bool, len(runIDs))
+	for _, id := range runIDs {
+		runs[id] = true
+	}
+	n := 0
+	for i := range l.calls {
+		if runs[l.calls[i].RunID] && l.calls[i].Args != nil {
+			l.calls[i].Args = nil
+			n++
+		}
+	}
+	return n, nil
+}
+
+func (l *MemoryLog) RecordAccess(ctx context.Context, access *Access) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	access.ID = int64(len(l.accesses) + 1)
+	l.accesses = append(l.accesses, *access)
+	return nil
+}
+
+func (l *MemoryLog) Accesses(ctx context.Context, since, until time.Time) ([]Access, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []Access
+	for _, a := range l.accesses {
+		if within(a.Time, since, until) {
+			out = append(out, a)
+		}
+	}
+	return out, nil
+}
+
+func (l *MemoryLog) RecordErasure(ctx context.Context, erasure *Erasure) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	erasure.ID = int64(len(l.erasures) + 1)
+	l.erasures = append(l.erasures, *erasure)
+	return nil
+}
+
+func (l *MemoryLog) Erasures(ctx context.Context, since, until time.Time) ([]Erasure, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var out []Erasure
+	for _, e := range l.erasures {
+		if within(e.Time, since, until) {
+			out = append(out, e)
+		}
+	}
+	return out, nil
+}
+
+func (f Filter) match(c *ToolCall) bool {
+	return (f.RunID == "" || c.RunID == f.RunID) && (f.Agent == "" || c.Agent == f.Agent) && (f.Tool == "" || c.Tool == f.Tool) &&
+		within(c.StartedAt, f.Since, f.Until)
+}
+
+// within reports whether t is in [since, until); zero bounds are open.
+func within(t, since, until time.Time) bool {
+	return (since.IsZero() || !t.Before(since)) && (until.IsZero() || t.Before(until))
+}
+
+// Hash returns the SHA-256 of a result's JSON encoding (its text when it
+// doesn't encode) and the size of that encoding.
+func Hash(result any) (string, int) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		data = []byte(fmt.Sprint(result))
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), len(data)
+}
+
+// Auditor records the tool calls agents make into a log.
+type Auditor struct {
+	log Log
+
+	mu           sync.Mutex
+	runID, event string // of the event the runner is handling
+}
+
+// New creates an auditor writing to log.
+func New(log Log) *Auditor {
+	return &Auditor{log: log}
+}
+
+// Log returns the log the auditor writes to.
+func (a *Auditor) Log() Log {
+	return a.log
+}
+
+// Register tracks which run and event the runner is handling; the default
+// runner handles one event at a time.
+func (a *Auditor) Register(runner core.Runner) error {
+	return runner.RegisterCallback(core.HookBeforeEventHandling, "audit", func(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+		event := args.Event
+		if event == nil {
+			return args.State, nil
+		}
+		runID, ok := event.GetMetadataValue(history.RunIDKey)
+		if !ok || runID == "" {
+			runID = event.GetID()
+		}
+		a.mu.Lock()
+		a.runID, a.event = runID, event.GetID()
+		a.mu.Unlock()
+		return args.State, nil
+	})
+}
+
+// ToolMiddleware records every tool call. It has the shape of
+// di.ToolMiddleware; add it last, so it records the calls that actually
+// run: not those denied by policy, and those a plan defers when the plan
+// is applied.
+func (a *Auditor) ToolMiddleware() func(agent string, tool tools.Tool) tools.Tool {
+	return func(agent string, tool tools.Tool) tools.Tool {
+		return &auditedTool{Tool: tool, a: a, agent: agent}
+	}
+}
+
+type auditedTool struct {
+	tools.Tool
+	a     *Auditor
+	agent string
+}
+
+// Unwrap returns the wrapped tool.
+func (t *auditedTool) Unwrap() tools.Tool { return t.Tool }
+
+func (t *auditedTool) Call(ctx context.Context, args map[string]any) (any, error) {
+	call := &ToolCall{Agent: t.agent, Tool: t.Name(), Args: args, StartedAt: time.Now()}
+	if runID, ok := history.RunIDFrom(ctx); ok {
+		call.RunID = runID // called outside the runner
+	} else {
+		t.a.mu.Lock()
+		call.RunID, call.EventID = t.a.runID, t.a.event
+		t.a.mu.Unlock()
+	}
+	result, err := t.Tool.Call(ctx, args)
+	call.Duration = time.Since(call.StartedAt)
+	if err != nil {
+		call.Error = err.Error()
+	} else {
+		call.ResultHash, call.ResultSize = Hash(result)
+	}
+	// Failing to audit doesn't undo an action that already happened
+	if logErr := t.a.log.Record(context.WithoutCancel(ctx), call); logErr != nil {
+		core.Logger().Error().Str("tool", call.Tool).Str("run_id", call.RunID).Err(logErr).Msg("Failed to record tool call")
+	}
+	return result, err
+}
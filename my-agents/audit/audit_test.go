@@ -0,0 +1,147 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+	"my-agents/tools"
+)
+
+// logs returns an empty log of each backend.
+func logs(t *testing.T) map[string]Log {
+	t.Helper()
+	sqlite, err := Open(Config{Path: filepath.Join(t.TempDir(), "agentflow.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory, err := Open(Config{Backend: "memory"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return map[string]Log{"sqlite": sqlite, "memory": memory}
+}
+
+type tool struct{ fail bool }
+
+func (t *tool) Name() string        { return "lookup" }
+func (t *tool) Description() string { return "" }
+
+func (t *tool) Call(ctx context.Context, args map[string]any) (any, error) {
+	if t.fail {
+		return nil, errors.New("timeout")
+	}
+	return map[string]any{"found": true}, nil
+}
+
+type runner struct {
+	core.Runner
+	callback core.CallbackFunc
+}
+
+func (r *runner) RegisterCallback(hook core.HookPoint, name string, cb core.CallbackFunc) error {
+	r.callback = cb
+	return nil
+}
+
+func TestAuditor(t *testing.T) {
+	for name, log := range logs(t) {
+		t.Run(name, func(t *testing.T) {
+			a := New(log)
+			r := &runner{}
+			if err := a.Register(r); err != nil {
+				t.Fatal(err)
+			}
+			ctx := context.Background()
+			event := core.NewEvent("writer", nil, map[string]string{history.RunIDKey: "run-1"})
+			r.callback(ctx, core.CallbackArgs{Event: event})
+
+			ok := a.ToolMiddleware()("writer", &tool{})
+			failing := a.ToolMiddleware()("writer", &tool{fail: true})
+			if _, err := ok.Call(ctx, map[string]any{"q": "refunds"}); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := failing.Call(ctx, nil); err == nil {
+				t.Fatal("tool error hidden")
+			}
+			// Outside the runner, e.g. applying a plan, the run comes with ctx
+			ok.Call(history.WithRunID(ctx, "run-2"), nil)
+
+			calls, err := a.Log().List(ctx, Filter{RunID: "run-1"})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(calls) != 2 {
+				t.Fatalf("got %d calls for run-1, want 2", len(calls))
+			}
+			hash, size := Hash(map[string]any{"found": true})
+			if c := calls[0]; c.Agent != "writer" || c.Tool != "lookup" || c.EventID != event.GetID() || c.Args["q"] != "refunds" || c.ResultHash != hash || c.ResultSize != size {
+				t.Errorf("call = %+v", c)
+			}
+			if c := calls[1]; c.Error != "timeout" || c.ResultHash != "" {
+				t.Errorf("failed call = %+v", c)
+			}
+			if calls, _ := a.Log().List(ctx, Filter{RunID: "run-2"}); len(calls) != 1 || calls[0].EventID != "" {
+				t.Errorf("run-2 calls = %+v", calls)
+			}
+		})
+	}
+}
+
+func TestList(t *testing.T) {
+	for name, log := range logs(t) {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			start := time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC)
+			for i, agent := range []string{"writer", "reviewer", "writer", "writer"} {
+				call := &ToolCall{RunID: "run-1", Agent: agent, Tool: "lookup", StartedAt: start.Add(time.Duration(i) * time.Minute)}
+				if err := log.Record(ctx, call); err != nil {
+					t.Fatal(err)
+				}
+				if call.ID == 0 {
+					t.Error("Record didn't assign an ID")
+				}
+			}
+			calls, err := log.List(ctx, Filter{Agent: "writer", Limit: 2})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(calls) != 2 || !calls[0].StartedAt.Equal(start.Add(2*time.Minute)) || !calls[1].StartedAt.Equal(start.Add(3*time.Minute)) {
+				t.Errorf("limited list = %+v, want the two most recent, oldest first", calls)
+			}
+			if calls, _ := log.List(ctx, Filter{Tool: "send_email"}); len(calls) != 0 {
+				t.Errorf("tool filter returned %d calls", len(calls))
+			}
+		})
+	}
+}
+
+func TestHash(t *testing.T) {
+	a, _ := Hash(map[string]int{"a": 1})
+	b, size := Hash(map[string]int{"a": 1})
+	if a != b || size != len(`{"a":1}`) {
+		t.Errorf("Hash not stable: %s %s (%d bytes)", a, b, size)
+	}
+	if h, _ := Hash(func() {}); h == "" {
+		t.Error("unencodable result not hashed")
+	}
+}
+
+func TestOpenUnknownBackend(t *testing.T) {
+	if _, err := Open(Config{Backend: "postgres"}); err == nil {
+		t.Error("Open accepted an unknown backend")
+	}
+}
+
+func TestAuditedToolUnwraps(t *testing.T) {
+	lookup := &tool{}
+	u, ok := New(&MemoryLog{}).ToolMiddleware()("writer", lookup).(interface{ Unwrap() tools.Tool })
+	if !ok || u.Unwrap() != lookup {
+		t.Error("audited tool does not unwrap to the tool it wraps")
+	}
+}
@@ -0,0 +1,77 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"my-agents/history"
+)
+
+// RunDetails is a run's history record with the tool calls it made.
+type RunDetails struct {
+	*history.Run
+	ToolCalls []ToolCall `json:"tool_calls"`
+}
+
+// Handler serves the audit endpoints, for mounting on the admin API under
+// "/admin/runs/" and "/admin/tool-calls":
+//
+//	GET /admin/runs/{run}
+//	GET /admin/tool-calls[?run=&agent=&tool=&limit=]
+func (a *Auditor) Handler(runs history.Store) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/runs/{run}", func(w http.ResponseWriter, r *http.Request) {
+		run, err := runs.Get(r.Context(), r.PathValue("run"))
+		if errors.Is(err, history.ErrNotFound) {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		calls, err := a.log.List(r.Context(), Filter{RunID: run.ID})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if calls == nil {
+			calls = []ToolCall{}
+		}
+		writeJSON(w, http.StatusOK, RunDetails{Run: run, ToolCalls: calls})
+	})
+	mux.HandleFunc("GET /admin/tool-calls", func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		filter := Filter{RunID: q.Get("run"), Agent: q.Get("agent"), Tool: q.Get("tool"), Limit: 100}
+		if l := q.Get("limit"); l != "" {
+			n, err := strconv.Atoi(l)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			filter.Limit = n
+		}
+		calls, err := a.log.List(r.Context(), filter)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if calls == nil {
+			calls = []ToolCall{}
+		}
+		writeJSON(w, http.StatusOK, calls)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
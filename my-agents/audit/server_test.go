@@ -0,0 +1,51 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"my-agents/history"
+)
+
+func TestHandler(t *testing.T) {
+	ctx := context.Background()
+	runs := history.NewMemoryStore()
+	runs.Save(ctx, &history.Run{ID: "run-1", Status: history.StatusCompleted})
+	runs.Save(ctx, &history.Run{ID: "run-2", Status: history.StatusCompleted})
+	a := New(&MemoryLog{})
+	a.Log().Record(ctx, &ToolCall{RunID: "run-1", Agent: "writer", Tool: "lookup"})
+	a.Log().Record(ctx, &ToolCall{RunID: "run-1", Agent: "writer", Tool: "send_email"})
+	h := a.Handler(runs)
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	var details struct {
+		ID        string     `json:"id"`
+		ToolCalls []ToolCall `json:"tool_calls"`
+	}
+	json.NewDecoder(get("/admin/runs/run-1").Body).Decode(&details)
+	if details.ID != "run-1" || len(details.ToolCalls) != 2 {
+		t.Errorf("run details = %+v", details)
+	}
+	if w := get("/admin/runs/run-2"); !json.Valid(w.Body.Bytes()) || w.Code != http.StatusOK {
+		t.Errorf("run without calls: %d %s", w.Code, w.Body)
+	}
+	if w := get("/admin/runs/run-9"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown run: status %d", w.Code)
+	}
+
+	var calls []ToolCall
+	json.NewDecoder(get("/admin/tool-calls?tool=send_email").Body).Decode(&calls)
+	if len(calls) != 1 || calls[0].Tool != "send_email" {
+		t.Errorf("filtered calls = %+v", calls)
+	}
+	if w := get("/admin/tool-calls?limit=many"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad limit: status %d", w.Code)
+	}
+}
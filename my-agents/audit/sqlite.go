@@ -0,0 +1,244 @@
+package audit
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"my-agents/storage"
+)
+
+// SQLiteLog keeps the audit trail in tables of the embedded database; tool
+// calls are indexed by run.
+type SQLiteLog struct {
+	db *sql.DB
+}
+
+// NewSQLiteLog creates a log in db, creating its tables if needed.
+func NewSQLiteLog(db *sql.DB) (*SQLiteLog, error) {
+	err := storage.Migrate(db,
+		`CREATE TABLE IF NOT EXISTS tool_calls (
+			id          INTEGER PRIMARY KEY AUTOINCREMENT,
+			run_id      TEXT NOT NULL,
+			event_id    TEXT NOT NULL,
+			agent       TEXT NOT NULL,
+			tool        TEXT NOT NULL,
+			args        TEXT NOT NULL,
+			result_hash TEXT NOT NULL,
+			result_size INTEGER NOT NULL,
+			error       TEXT NOT NULL,
+			started_at  INTEGER NOT NULL,
+			duration    INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS tool_calls_run ON tool_calls (run_id)`,
+		`CREATE TABLE IF NOT EXISTS admin_access (
+			id         INTEGER PRIMARY KEY AUTOINCREMENT,
+			time       INTEGER NOT NULL,
+			method     TEXT NOT NULL,
+			path       TEXT NOT NULL,
+			status     INTEGER NOT NULL,
+			remote     TEXT NOT NULL,
+			authorized INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS admin_access_time ON admin_access (time)`,
+		`CREATE TABLE IF NOT EXISTS erasures (
+			id           INTEGER PRIMARY KEY AUTOINCREMENT,
+			time         INTEGER NOT NULL,
+			session_id   TEXT NOT NULL,
+			requested_by TEXT NOT NULL,
+			reason       TEXT NOT NULL,
+			runs         INTEGER NOT NULL,
+			checkpoints  INTEGER NOT NULL,
+			tool_calls   INTEGER NOT NULL,
+			memory       TEXT NOT NULL,
+			error        TEXT NOT NULL
+		)`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range [][2]string{
+		{"user_id", "TEXT NOT NULL DEFAULT ''"},
+		{"profile", "INTEGER NOT NULL DEFAULT 0"},
+		{"usage_records", "INTEGER NOT NULL DEFAULT 0"},
+		{"cache_entries", "INTEGER NOT NULL DEFAULT 0"},
+	} {
+		if err := storage.AddColumn(db, "erasures", c[0], c[1]); err != nil {
+			return nil, err
+		}
+	}
+	return &SQLiteLog{db: db}, nil
+}
+
+func (l *SQLiteLog) Record(ctx context.Context, call *ToolCall) error {
+	args, err := json.Marshal(call.Args)
+	if err != nil {
+		args = []byte(fmt.Sprintf("%q", fmt.Sprint(call.Args)))
+	}
+	res, err := l.db.ExecContext(ctx,
+		`INSERT INTO tool_calls (run_id, event_id, agent, tool, args, result_hash, result_size, error, started_at, duration)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		call.RunID, call.EventID, call.Agent, call.Tool, string(args), call.ResultHash, call.ResultSize, call.Error,
+		call.StartedAt.UnixNano(), int64(call.Duration))
+	if err != nil {
+		return err
+	}
+	call.ID, _ = res.LastInsertId()
+	return nil
+}
+
+func (l *SQLiteLog) List(ctx context.Context, filter Filter) ([]ToolCall, error) {
+	var where []string
+	var args []any
+	for col, v := range map[string]string{"run_id": filter.RunID, "agent": filter.Agent, "tool": filter.Tool} {
+		if v != "" {
+			where, args = append(where, col+" = ?"), append(args, v)
+		}
+	}
+	if !filter.Since.IsZero() {
+		where, args = append(where, "started_at >= ?"), append(args, filter.Since.UnixNano())
+	}
+	if !filter.Until.IsZero() {
+		where, args = append(where, "started_at < ?"), append(args, filter.Until.UnixNano())
+	}
+	query := `SELECT id, run_id, event_id, agent, tool, args, result_hash, result_size, error, started_at, duration FROM tool_calls`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY id DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+	rows, err := l.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var calls []ToolCall
+	for rows.Next() {
+		var c ToolCall
+		var argData string
+		var started, took int64
+		if err := rows.Scan(&c.ID, &c.RunID, &c.EventID, &c.Agent, &c.Tool, &argData, &c.ResultHash, &c.ResultSize, &c.Error, &started, &took); err != nil {
+			return nil, err
+		}
+		_ = json.Unmarshal([]byte(argData), &c.Args)
+		c.StartedAt, c.Duration = time.Unix(0, started), time.Duration(took)
+		calls = append(calls, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	// Oldest first, like the other stores
+	for i, j := 0, len(calls)-1; i < j; i, j = i+1, j-1 {
+		calls[i], calls[j] = calls[j], calls[i]
+	}
+	return calls, nil
+}
+
+func (l *SQLiteLog) ForgetArgs(ctx context.Context, runIDs []string) (int, error) {
+	if len(runIDs) == 0 {
+		return 0, nil
+	}
+	args := make([]any, len(runIDs))
+	for i, id := range runIDs {
+		args[i] = id
+	}
+	res, err := l.db.ExecContext(ctx,
+		`UPDATE tool_calls SET args = 'null' WHERE args != 'null' AND run_id IN (?`+strings.Repeat(", ?", len(runIDs)-1)+`)`, args...)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+func (l *SQLiteLog) RecordAccess(ctx context.Context, access *Access) error {
+	res, err := l.db.ExecContext(ctx,
+		`INSERT INTO admin_access (time, method, path, status, remote, authorized) VALUES (?, ?, ?, ?, ?, ?)`,
+		access.Time.UnixNano(), access.Method, access.Path, access.Status, access.Remote, access.Authorized)
+	if err != nil {
+		return err
+	}
+	access.ID, _ = res.LastInsertId()
+	return nil
+}
+
+func (l *SQLiteLog) Accesses(ctx context.Context, since, until time.Time) ([]Access, error) {
+	where, args := timeRange(since, until)
+	rows, err := l.db.QueryContext(ctx,
+		`SELECT id, time, method, path, status, remote, authorized FROM admin_access`+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Access
+	for rows.Next() {
+		var a Access
+		var t int64
+		if err := rows.Scan(&a.ID, &t, &a.Method, &a.Path, &a.Status, &a.Remote, &a.Authorized); err != nil {
+			return nil, err
+		}
+		a.Time = time.Unix(0, t)
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+func (l *SQLiteLog) RecordErasure(ctx context.Context, erasure *Erasure) error {
+	res, err := l.db.ExecContext(ctx,
+		`INSERT INTO erasures (time, session_id, requested_by, reason, runs, checkpoints, tool_calls, memory, user_id, profile, usage_records, cache_entries, error)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		erasure.Time.UnixNano(), erasure.SessionID, erasure.RequestedBy, erasure.Reason, erasure.Runs, erasure.Checkpoints, erasure.ToolCalls,
+		strings.Join(erasure.Memory, ","), erasure.UserID, erasure.Profile, erasure.UsageRecords, erasure.CacheEntries, erasure.Error)
+	if err != nil {
+		return err
+	}
+	erasure.ID, _ = res.LastInsertId()
+	return nil
+}
+
+func (l *SQLiteLog) Erasures(ctx context.Context, since, until time.Time) ([]Erasure, error) {
+	where, args := timeRange(since, until)
+	rows, err := l.db.QueryContext(ctx,
+		`SELECT id, time, session_id, requested_by, reason, runs, checkpoints, tool_calls, memory, user_id, profile, usage_records, cache_entries, error FROM erasures`+where+` ORDER BY id`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Erasure
+	for rows.Next() {
+		var e Erasure
+		var t int64
+		var memory string
+		if err := rows.Scan(&e.ID, &t, &e.SessionID, &e.RequestedBy, &e.Reason, &e.Runs, &e.Checkpoints, &e.ToolCalls, &memory, &e.UserID, &e.Profile, &e.UsageRecords, &e.CacheEntries, &e.Error); err != nil {
+			return nil, err
+		}
+		e.Time = time.Unix(0, t)
+		if memory != "" {
+			e.Memory = strings.Split(memory, ",")
+		}
+		out = append(out, e)
+	}
+	return out, rows.Err()
+}
+
+// timeRange builds the WHERE clause selecting the time column in
+// [since, until); zero bounds are open.
+func timeRange(since, until time.Time) (string, []any) {
+	var where []string
+	var args []any
+	if !since.IsZero() {
+		where, args = append(where, "time >= ?"), append(args, since.UnixNano())
+	}
+	if !until.IsZero() {
+		where, args = append(where, "time < ?"), append(args, until.UnixNano())
+	}
+	if len(where) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(where, " AND "), args
+}
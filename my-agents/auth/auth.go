@@ -0,0 +1,139 @@
+// Package auth identifies who an API request comes from. The HTTP API
+// authenticates each caller by bearer token as one of its configured
+// clients, bound to a tenant and a user, or to a gateway trusted to name
+// the users it acts for. Quotas, policy, retrieval scoping and the
+// self-service handlers read that principal, never headers or event
+// metadata the caller could set.
+package auth
+
+import (
+	"context"
+	"net"
+	"net/http"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/flags"
+	"my-agents/tenant"
+)
+
+// HTTP headers a client acting for its users names them with.
+const (
+	UserHeader   = "X-User-ID"
+	TenantHeader = "X-Tenant-ID"
+)
+
+// IdentityKey is the event metadata key the API sets to the caller's
+// identity, e.g. for quotas.
+const IdentityKey = "principal"
+
+// Client is an [http.clients.<name>] table: one caller of the API and its
+// token.
+//
+//	[http.clients.webapp]
+//	token_env = "WEBAPP_API_TOKEN"
+//	tenant = "acme"
+//	acts_for_users = true
+type Client struct {
+	TokenEnv string `toml:"token_env"` // env var holding the bearer token
+	// Tenant and User are who the client's requests are for; empty leaves
+	// them untenanted or without a user.
+	Tenant string `toml:"tenant"`
+	User   string `toml:"user"`
+	// ActsForUsers trusts the client, a gateway that authenticated its own
+	// users, to name the user a request is for in X-User-ID, and the tenant
+	// in X-Tenant-ID when it has none of its own.
+	ActsForUsers bool `toml:"acts_for_users"`
+}
+
+// Principal is an authenticated caller.
+type Principal struct {
+	Client string // "" on an API without tokens
+	Tenant string
+	User   string
+	Addr   string // the remote host, identifying anonymous callers
+}
+
+// Authenticated builds the principal of a request client made.
+func Authenticated(name string, c Client, r *http.Request) Principal {
+	p := Principal{Client: name, Tenant: c.Tenant, User: c.User, Addr: host(r)}
+	if c.ActsForUsers {
+		if user := r.Header.Get(UserHeader); user != "" {
+			p.User = user
+		}
+		if p.Tenant == "" {
+			p.Tenant = r.Header.Get(TenantHeader)
+		}
+	}
+	return p
+}
+
+// Anonymous is the principal of a request to an API without tokens.
+func Anonymous(r *http.Request) Principal {
+	return Principal{Addr: host(r)}
+}
+
+func host(r *http.Request) string {
+	if h, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return h
+	}
+	return r.RemoteAddr
+}
+
+// Identity is who the principal's usage is counted against: its user,
+// else its client, else its address.
+func (p Principal) Identity() string {
+	switch {
+	case p.User != "" && p.Tenant != "":
+		return "user:" + p.Tenant + "/" + p.User
+	case p.User != "":
+		return "user:" + p.User
+	case p.Client != "":
+		return "client:" + p.Client
+	case p.Addr != "":
+		return "ip:" + p.Addr
+	}
+	return "anonymous"
+}
+
+// Metadata is the event metadata carrying the principal, which the API
+// sets on the events it emits.
+func (p Principal) Metadata() map[string]string {
+	md := map[string]string{IdentityKey: p.Identity()}
+	if p.Tenant != "" {
+		md[tenant.MetadataKey] = p.Tenant
+	}
+	if p.User != "" {
+		md[flags.UserKey] = p.User
+	}
+	return md
+}
+
+// Owns reports whether metadata, of an event the API emitted or of its run,
+// names the principal as the caller: the same identity and tenant.
+func (p Principal) Owns(metadata map[string]string) bool {
+	return metadata[IdentityKey] == p.Identity() && metadata[tenant.MetadataKey] == p.Tenant
+}
+
+// Keys are the metadata keys Metadata sets, which callers can't supply.
+var Keys = []string{IdentityKey, tenant.MetadataKey, flags.UserKey}
+
+type contextKey struct{}
+
+// NewContext returns ctx carrying p.
+func NewContext(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// FromContext returns the principal ctx carries, and whether it carries one.
+func FromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(contextKey{}).(Principal)
+	return p, ok
+}
+
+// FromEvent returns the identity the API set on event, or "" for events
+// that didn't come through it.
+func FromEvent(event core.Event) string {
+	id, _ := event.GetMetadataValue(IdentityKey)
+	return id
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/flags"
+	"my-agents/tenant"
+)
+
+func TestAuthenticated(t *testing.T) {
+	r := httptest.NewRequest("POST", "/events", nil)
+	r.RemoteAddr = "10.0.0.7:5123"
+	r.Header.Set(UserHeader, "mallory")
+	r.Header.Set(TenantHeader, "other")
+
+	p := Authenticated("webapp", Client{Tenant: "acme", User: "svc"}, r)
+	if p != (Principal{Client: "webapp", Tenant: "acme", User: "svc", Addr: "10.0.0.7"}) {
+		t.Errorf("untrusted client = %+v, want the headers ignored", p)
+	}
+	p = Authenticated("gateway", Client{Tenant: "acme", ActsForUsers: true}, r)
+	if p.User != "mallory" || p.Tenant != "acme" {
+		t.Errorf("gateway with a tenant = %+v, want its tenant kept", p)
+	}
+	p = Authenticated("gateway", Client{ActsForUsers: true}, r)
+	if p.User != "mallory" || p.Tenant != "other" {
+		t.Errorf("gateway without a tenant = %+v", p)
+	}
+	if p := Anonymous(r); p != (Principal{Addr: "10.0.0.7"}) {
+		t.Errorf("Anonymous = %+v", p)
+	}
+}
+
+func TestIdentity(t *testing.T) {
+	for p, want := range map[Principal]string{
+		{Tenant: "acme", User: "ann", Client: "webapp"}: "user:acme/ann",
+		{User: "ann"}:                        "user:ann",
+		{Client: "webapp", Addr: "10.0.0.7"}: "client:webapp",
+		{Addr: "10.0.0.7"}:                   "ip:10.0.0.7",
+		{}:                                   "anonymous",
+	} {
+		if got := p.Identity(); got != want {
+			t.Errorf("%+v.Identity() = %q, want %q", p, got, want)
+		}
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	md := Principal{Tenant: "acme", User: "ann"}.Metadata()
+	if md[IdentityKey] != "user:acme/ann" || md[tenant.MetadataKey] != "acme" || md[flags.UserKey] != "ann" {
+		t.Errorf("Metadata = %v", md)
+	}
+	if md := (Principal{Client: "webapp"}).Metadata(); len(md) != 1 {
+		t.Errorf("Metadata of a client = %v", md)
+	}
+	event := core.NewEvent("processor", nil, md)
+	if got := FromEvent(event); got != "user:acme/ann" {
+		t.Errorf("FromEvent = %q", got)
+	}
+}
+
+func TestContext(t *testing.T) {
+	p := Principal{Client: "webapp"}
+	if got, ok := FromContext(NewContext(context.Background(), p)); !ok || got != p {
+		t.Errorf("FromContext = %+v, %v", got, ok)
+	}
+	if _, ok := FromContext(context.Background()); ok {
+		t.Error("FromContext found a principal in an empty context")
+	}
+}
+
+func TestOwns(t *testing.T) {
+	ann := Principal{Tenant: "acme", User: "ann"}
+	if !ann.Owns(ann.Metadata()) {
+		t.Error("a principal doesn't own its own event")
+	}
+	for _, other := range []Principal{{Tenant: "acme", User: "bob"}, {Tenant: "globex", User: "ann"}, {User: "ann"}} {
+		if ann.Owns(other.Metadata()) {
+			t.Errorf("%+v owns the event of %+v", ann, other)
+		}
+	}
+	// A tenant in the identity alone isn't enough
+	if ann.Owns(map[string]string{IdentityKey: ann.Identity()}) {
+		t.Error("owns an event with no tenant")
+	}
+}
@@ -0,0 +1,294 @@
+package awsauth
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Where code on AWS gets its role's credentials.
+const (
+	containerCredentialsHost = "http://169.254.170.2"
+	instanceMetadataURL      = "http://169.254.169.254/latest"
+)
+
+// Region is region when set, else the environment's: $AWS_REGION, else
+// $AWS_DEFAULT_REGION.
+func Region(region string) string {
+	if region != "" {
+		return region
+	}
+	if r := os.Getenv("AWS_REGION"); r != "" {
+		return r
+	}
+	return os.Getenv("AWS_DEFAULT_REGION")
+}
+
+// Credentials sign requests; Expires is zero for keys that don't expire.
+type Credentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expires         time.Time
+}
+
+// Source provides credentials, refreshing temporary ones five minutes
+// before they expire.
+type Source struct {
+	http  *http.Client
+	fetch func(ctx context.Context, client *http.Client) (Credentials, error)
+
+	mu      sync.Mutex
+	current Credentials
+}
+
+// Credentials returns valid credentials.
+func (s *Source) Credentials(ctx context.Context) (Credentials, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.current.AccessKeyID != "" && (s.current.Expires.IsZero() || time.Until(s.current.Expires) > 5*time.Minute) {
+		return s.current, nil
+	}
+	creds, err := s.fetch(ctx, s.http)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("aws credentials: %w", err)
+	}
+	s.current = creds
+	return creds, nil
+}
+
+// NewSource picks credentials the way the AWS SDKs do: the environment's
+// keys, then the profile's in the shared credentials file, then a web
+// identity token (EKS service accounts), then the container's role (ECS,
+// EKS pod identity), then the instance's (EC2). Profile defaults to
+// $AWS_PROFILE, else "default"; region is where STS is asked for web
+// identity credentials.
+func NewSource(profile, region string) (*Source, error) {
+	s := &Source{http: &http.Client{Timeout: 30 * time.Second}}
+	if id, secret := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); id != "" && secret != "" {
+		s.current = Credentials{AccessKeyID: id, SecretAccessKey: secret, SessionToken: os.Getenv("AWS_SESSION_TOKEN")}
+		return s, nil
+	}
+	creds, found, err := sharedCredentials(profile)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		s.current = creds
+		return s, nil
+	}
+	if profile != "" && profile != "default" {
+		return nil, fmt.Errorf("aws profile %q has no keys in the shared credentials file", profile)
+	}
+	switch {
+	case os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE") != "" && os.Getenv("AWS_ROLE_ARN") != "":
+		s.fetch = webIdentity(region)
+	case os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI") != "" || os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI") != "":
+		s.fetch = containerCredentials
+	case strings.EqualFold(os.Getenv("AWS_EC2_METADATA_DISABLED"), "true"):
+		return nil, fmt.Errorf("no aws credentials in the environment, the shared credentials file or the container, and the instance metadata service is disabled")
+	default:
+		s.fetch = instanceCredentials
+	}
+	return s, nil
+}
+
+// sharedCredentials reads the profile's keys from
+// $AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials.
+func sharedCredentials(profile string) (Credentials, bool, error) {
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+	if profile == "" {
+		profile = "default"
+	}
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return Credentials{}, false, nil
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return Credentials{}, false, nil
+	}
+	if err != nil {
+		return Credentials{}, false, fmt.Errorf("aws credentials: %w", err)
+	}
+	defer f.Close()
+	var creds Credentials
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != profile {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch strings.TrimSpace(key) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = value
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = value
+		case "aws_session_token":
+			creds.SessionToken = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Credentials{}, false, fmt.Errorf("aws credentials %s: %w", path, err)
+	}
+	return creds, creds.AccessKeyID != "" && creds.SecretAccessKey != "", nil
+}
+
+// webIdentity exchanges the token in $AWS_WEB_IDENTITY_TOKEN_FILE for
+// credentials of the role in $AWS_ROLE_ARN.
+func webIdentity(region string) func(ctx context.Context, client *http.Client) (Credentials, error) {
+	return func(ctx context.Context, client *http.Client) (Credentials, error) {
+		token, err := os.ReadFile(os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"))
+		if err != nil {
+			return Credentials{}, err
+		}
+		session := os.Getenv("AWS_ROLE_SESSION_NAME")
+		if session == "" {
+			session = fmt.Sprintf("agentflow-%d", time.Now().Unix())
+		}
+		query := url.Values{
+			"Action":           {"AssumeRoleWithWebIdentity"},
+			"Version":          {"2011-06-15"},
+			"RoleArn":          {os.Getenv("AWS_ROLE_ARN")},
+			"RoleSessionName":  {session},
+			"WebIdentityToken": {strings.TrimSpace(string(token))},
+		}
+		endpoint := fmt.Sprintf("https://sts.%s.amazonaws.com/?%s", region, query.Encode())
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return Credentials{}, err
+		}
+		body, err := fetch(client, req)
+		if err != nil {
+			return Credentials{}, fmt.Errorf("sts: %w", err)
+		}
+		var out struct {
+			Credentials struct {
+				AccessKeyID     string    `xml:"AccessKeyId"`
+				SecretAccessKey string    `xml:"SecretAccessKey"`
+				SessionToken    string    `xml:"SessionToken"`
+				Expiration      time.Time `xml:"Expiration"`
+			} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+		}
+		if err := xml.Unmarshal(body, &out); err != nil || out.Credentials.AccessKeyID == "" {
+			return Credentials{}, fmt.Errorf("sts returned no credentials")
+		}
+		c := out.Credentials
+		return Credentials{AccessKeyID: c.AccessKeyID, SecretAccessKey: c.SecretAccessKey, SessionToken: c.SessionToken, Expires: c.Expiration}, nil
+	}
+}
+
+// containerCredentials asks the ECS or EKS pod identity agent for the
+// container's role credentials.
+func containerCredentials(ctx context.Context, client *http.Client) (Credentials, error) {
+	endpoint := os.Getenv("AWS_CONTAINER_CREDENTIALS_FULL_URI")
+	if relative := os.Getenv("AWS_CONTAINER_CREDENTIALS_RELATIVE_URI"); relative != "" {
+		endpoint = containerCredentialsHost + relative
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return Credentials{}, err
+	}
+	token := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN")
+	if path := os.Getenv("AWS_CONTAINER_AUTHORIZATION_TOKEN_FILE"); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return Credentials{}, err
+		}
+		token = strings.TrimSpace(string(data))
+	}
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+	body, err := fetch(client, req)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("container credentials: %w", err)
+	}
+	return roleCredentials(body)
+}
+
+// instanceCredentials asks the EC2 instance metadata service, version 2,
+// for the instance profile's credentials.
+func instanceCredentials(ctx context.Context, client *http.Client) (Credentials, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, instanceMetadataURL+"/api/token", nil)
+	if err != nil {
+		return Credentials{}, err
+	}
+	req.Header.Set("X-aws-ec2-metadata-token-ttl-seconds", "21600")
+	token, err := fetch(client, req)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("no aws credentials in the environment, the shared credentials file or the container, and no instance metadata service: %w", err)
+	}
+	get := func(path string) ([]byte, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, instanceMetadataURL+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-aws-ec2-metadata-token", string(token))
+		return fetch(client, req)
+	}
+	roles, err := get("/meta-data/iam/security-credentials/")
+	if err != nil {
+		return Credentials{}, fmt.Errorf("instance has no role: %w", err)
+	}
+	role, _, _ := strings.Cut(strings.TrimSpace(string(roles)), "\n")
+	body, err := get("/meta-data/iam/security-credentials/" + role)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("instance role %s: %w", role, err)
+	}
+	return roleCredentials(body)
+}
+
+// roleCredentials decodes the credentials container and instance metadata
+// endpoints return.
+func roleCredentials(body []byte) (Credentials, error) {
+	var out struct {
+		AccessKeyID     string    `json:"AccessKeyId"`
+		SecretAccessKey string    `json:"SecretAccessKey"`
+		Token           string    `json:"Token"`
+		Expiration      time.Time `json:"Expiration"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil || out.AccessKeyID == "" {
+		return Credentials{}, fmt.Errorf("credentials endpoint returned no credentials")
+	}
+	return Credentials{AccessKeyID: out.AccessKeyID, SecretAccessKey: out.SecretAccessKey, SessionToken: out.Token, Expires: out.Expiration}, nil
+}
+
+func fetch(client *http.Client, req *http.Request) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return body, nil
+}
@@ -0,0 +1,388 @@
+// Package backfill re-processes stored runs after a prompt or model upgrade:
+// it re-runs a filtered set of them through the workflow as it is now,
+// several at a time and within a token or cost budget, and reports how each
+// new response compares with the original.
+package backfill
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/deploy"
+	"my-agents/history"
+	"my-agents/usage"
+)
+
+// DefaultConcurrency is how many runs are re-run at once when Options
+// doesn't say.
+const DefaultConcurrency = 4
+
+// SessionPrefix starts the session ID of each re-run, followed by the
+// original run's ID.
+const SessionPrefix = "backfill-"
+
+// Options selects the runs to re-run and bounds the backfill.
+type Options struct {
+	// Filter selects stored runs; its Status defaults to completed runs.
+	Filter history.Filter
+	// Workflow keeps only runs that started at this workflow.
+	Workflow string
+	// RunIDs re-runs these runs instead of the filtered ones.
+	RunIDs []string
+	// From is the agent to re-run from, on what the agents before it
+	// produced; empty re-runs each run from its input.
+	From string
+	// Metadata replaces the runs' request metadata keys.
+	Metadata map[string]string
+	// Concurrency bounds the runs in flight (default DefaultConcurrency).
+	Concurrency int
+	// MaxTokens and MaxCost stop the backfill from starting more runs once
+	// the re-runs have used that many tokens or that much money; runs in
+	// flight finish, so the total can exceed a cap by up to Concurrency
+	// runs. Both need the usage ledger. Zero is no cap.
+	MaxTokens int
+	MaxCost   float64
+	// Timeout bounds each run (default 5m).
+	Timeout time.Duration
+}
+
+// Spend is the tokens and money a run's LLM calls cost.
+type Spend struct {
+	Tokens int     `json:"tokens"`
+	Cost   float64 `json:"cost"`
+}
+
+// Result compares one re-run with its original.
+type Result struct {
+	RunID    string `json:"run_id"`
+	NewRunID string `json:"new_run_id,omitempty"`
+	// Skipped says why the run wasn't re-run.
+	Skipped        string   `json:"skipped,omitempty"`
+	Status         string   `json:"status,omitempty"`
+	OriginalStatus string   `json:"original_status"`
+	Error          string   `json:"error,omitempty"`
+	Original       string   `json:"original_response"`
+	Response       string   `json:"response,omitempty"`
+	Changed        bool     `json:"changed"`
+	Difference     string   `json:"difference,omitempty"` // the first changed line
+	Agents         []string `json:"agents,omitempty"`
+	OriginalAgents []string `json:"original_agents"` // from the agent the re-run started at
+
+	Duration      time.Duration `json:"duration,omitempty"`
+	OriginalTime  time.Duration `json:"original_duration"`
+	Spend         *Spend        `json:"spend,omitempty"` // nil without a usage ledger
+	OriginalSpend *Spend        `json:"original_spend,omitempty"`
+}
+
+// Report is the outcome of a backfill.
+type Report struct {
+	StartedAt time.Time `json:"started_at"`
+	EndedAt   time.Time `json:"ended_at"`
+	Selected  int       `json:"selected"`
+	Completed int       `json:"completed"`
+	Failed    int       `json:"failed"`
+	Changed   int       `json:"changed"`
+	Skipped   int       `json:"skipped"`
+	Spend     *Spend    `json:"spend,omitempty"`
+	// Stopped says why the backfill ended before re-running every selected
+	// run.
+	Stopped string   `json:"stopped,omitempty"`
+	Results []Result `json:"results"`
+}
+
+// Backfiller re-runs stored runs through a runner.
+type Backfiller struct {
+	recorder *history.Recorder
+	runner   core.Runner
+	ledger   *usage.Ledger
+}
+
+// New creates a backfiller emitting on runner, which must be started, and
+// reading runs from recorder's store. ledger, when not nil, prices the runs
+// and enforces the caps.
+func New(recorder *history.Recorder, runner core.Runner, ledger *usage.Ledger) *Backfiller {
+	return &Backfiller{recorder: recorder, runner: runner, ledger: ledger}
+}
+
+// Select returns the runs opts picks, oldest first.
+func (b *Backfiller) Select(ctx context.Context, opts Options) ([]*history.Run, error) {
+	store := b.recorder.Store()
+	if len(opts.RunIDs) > 0 {
+		runs := make([]*history.Run, 0, len(opts.RunIDs))
+		for _, id := range opts.RunIDs {
+			run, err := store.Get(ctx, id)
+			if err != nil {
+				return nil, fmt.Errorf("run %s: %w", id, err)
+			}
+			runs = append(runs, run)
+		}
+		return runs, nil
+	}
+	filter := opts.Filter
+	if filter.Status == "" {
+		filter.Status = history.StatusCompleted
+	}
+	// Limit applies after the workflow and rerun exclusions below
+	limit := filter.Limit
+	filter.Limit = 0
+	all, err := store.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var runs []*history.Run
+	for _, run := range all {
+		// Re-runs of earlier backfills aren't history to re-process
+		if run.Metadata[history.RerunOfKey] != "" {
+			continue
+		}
+		if opts.Workflow != "" && workflow(run) != opts.Workflow {
+			continue
+		}
+		runs = append(runs, run)
+	}
+	if limit > 0 && len(runs) > limit {
+		runs = runs[len(runs)-limit:]
+	}
+	return runs, nil
+}
+
+func workflow(run *history.Run) string {
+	if wf := run.Metadata[usage.WorkflowKey]; wf != "" {
+		return wf
+	}
+	route, _, _ := strings.Cut(run.Metadata[core.RouteMetadataKey], "@")
+	return route
+}
+
+// Run re-runs the runs opts selects and compares each with its original.
+// Runs that can't be re-run, or that the caps left unstarted, are reported
+// as skipped. It returns an error only when no run could be selected.
+func (b *Backfiller) Run(ctx context.Context, opts Options) (*Report, error) {
+	if (opts.MaxTokens > 0 || opts.MaxCost > 0) && b.ledger == nil {
+		return nil, errors.New("token and cost caps need [usage] metering")
+	}
+	runs, err := b.Select(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+
+	report := &Report{StartedAt: time.Now(), Selected: len(runs), Results: make([]Result, len(runs))}
+	if b.ledger != nil {
+		report.Spend = &Spend{}
+	}
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, concurrency)
+	)
+	for i, run := range runs {
+		// Wait for a slot before checking the caps, so they see what the
+		// runs before this one spent
+		sem <- struct{}{}
+		mu.Lock()
+		if report.Stopped == "" {
+			report.Stopped = over(ctx, report.Spend, opts)
+		}
+		stopped := report.Stopped
+		mu.Unlock()
+		if stopped != "" {
+			<-sem
+			report.Results[i] = b.original(run)
+			report.Results[i].Skipped = stopped
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			runCtx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			result := b.rerun(runCtx, run, opts)
+			mu.Lock()
+			report.Results[i] = result
+			if report.Spend != nil && result.Spend != nil {
+				report.Spend.Tokens += result.Spend.Tokens
+				report.Spend.Cost += result.Spend.Cost
+			}
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	for _, r := range report.Results {
+		switch {
+		case r.Skipped != "":
+			report.Skipped++
+		case r.Status == history.StatusCompleted:
+			report.Completed++
+		default:
+			report.Failed++
+		}
+		if r.Changed {
+			report.Changed++
+		}
+	}
+	report.EndedAt = time.Now()
+	return report, nil
+}
+
+// over says why no more runs should start, if they shouldn't: ctx ended or
+// spent reached a cap.
+func over(ctx context.Context, spent *Spend, opts Options) string {
+	if err := ctx.Err(); err != nil {
+		return err.Error()
+	}
+	if spent == nil {
+		return ""
+	}
+	if opts.MaxTokens > 0 && spent.Tokens >= opts.MaxTokens {
+		return fmt.Sprintf("token cap of %d reached", opts.MaxTokens)
+	}
+	if opts.MaxCost > 0 && spent.Cost >= opts.MaxCost {
+		return fmt.Sprintf("cost cap of %.4f reached", opts.MaxCost)
+	}
+	return ""
+}
+
+// original describes run before it is re-run.
+func (b *Backfiller) original(run *history.Run) Result {
+	r := Result{
+		RunID:          run.ID,
+		OriginalStatus: run.Status,
+		Original:       run.FinalResponse,
+		OriginalAgents: agents(run),
+	}
+	if !run.EndedAt.IsZero() {
+		r.OriginalTime = run.EndedAt.Sub(run.StartedAt)
+	}
+	r.OriginalSpend = b.Spent(run.ID, run.StartedAt, run.EndedAt)
+	return r
+}
+
+func (b *Backfiller) rerun(ctx context.Context, run *history.Run, opts Options) Result {
+	r := b.original(run)
+	// The workflow's active version serves the re-run unless opts pins
+	// one, in a session of its own so it neither reads nor extends the
+	// original's conversation
+	metadata := map[string]string{deploy.VersionKey: "", core.SessionIDKey: SessionPrefix + run.ID}
+	for k, v := range opts.Metadata {
+		metadata[k] = v
+	}
+	var event core.Event
+	var err error
+	if opts.From != "" {
+		event, err = history.RerunEvent(run, opts.From, metadata)
+	} else {
+		event, err = history.RestartEvent(run, metadata)
+	}
+	if err != nil {
+		r.Skipped = err.Error()
+		return r
+	}
+
+	started := time.Now()
+	result, err := b.recorder.ProcessSync(ctx, b.runner, event)
+	r.NewRunID = result.RunID
+	r.Duration = time.Since(started)
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if result.Run == nil {
+		r.Status = history.StatusFailed
+		return r
+	}
+	r.Status = result.Status
+	r.Response = result.Response
+	r.Agents = agents(result.Run)
+	if opts.From != "" {
+		// Only the agents from where the re-run started compare
+		for i := len(r.OriginalAgents) - 1; i >= 0; i-- {
+			if r.OriginalAgents[i] == opts.From {
+				r.OriginalAgents = r.OriginalAgents[i:]
+				break
+			}
+		}
+	}
+	r.Changed = r.Response != r.Original || r.Status != r.OriginalStatus ||
+		strings.Join(r.Agents, " ") != strings.Join(r.OriginalAgents, " ")
+	if r.Response != r.Original {
+		r.Difference = firstDifference(r.Original, r.Response)
+	}
+	r.Spend = b.Spent(result.RunID, started, time.Now())
+	return r
+}
+
+// Spent totals the usage ledger's records of runID, a run between from and
+// to. It returns nil without a ledger.
+func (b *Backfiller) Spent(runID string, from, to time.Time) *Spend {
+	if b.ledger == nil {
+		return nil
+	}
+	months := []string{usage.Month(from)}
+	if !to.IsZero() && usage.Month(to) != months[0] {
+		months = append(months, usage.Month(to))
+	}
+	s := &Spend{}
+	for _, month := range months {
+		records, err := b.ledger.Records(month)
+		if err != nil {
+			core.Logger().Warn().Str("month", month).Err(err).Msg("Failed to read usage ledger")
+			continue
+		}
+		for _, rec := range records {
+			if rec.RunID == runID {
+				s.Tokens += rec.Tokens()
+				s.Cost += rec.Cost
+			}
+		}
+	}
+	return s
+}
+
+// agents lists the agents a run went through, in order.
+func agents(run *history.Run) []string {
+	out := make([]string, 0, len(run.Steps))
+	for _, s := range run.Steps {
+		out = append(out, s.Agent)
+	}
+	return out
+}
+
+// firstDifference describes the first line where got departs from want.
+func firstDifference(want, got string) string {
+	w, g := strings.Split(want, "\n"), strings.Split(got, "\n")
+	for i := 0; i < max(len(w), len(g)); i++ {
+		var wl, gl string
+		if i < len(w) {
+			wl = w[i]
+		}
+		if i < len(g) {
+			gl = g[i]
+		}
+		if wl != gl {
+			return fmt.Sprintf("line %d: now %q, was %q", i+1, truncate(gl), truncate(wl))
+		}
+	}
+	return "differs"
+}
+
+func truncate(s string) string {
+	if r := []rune(s); len(r) > 80 {
+		return string(r[:80]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,295 @@
+package backfill
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/deploy"
+	"my-agents/history"
+	"my-agents/usage"
+)
+
+// runner runs a writer → formatter pipeline inline on Emit, reporting each
+// step to the recorder's callbacks. The writer fails on the input "fail".
+type runner struct {
+	core.Runner
+	callbacks map[core.HookPoint]core.CallbackFunc
+	ledger    *usage.Ledger
+
+	mu      sync.Mutex
+	emitted []core.Event
+}
+
+func (r *runner) RegisterCallback(hook core.HookPoint, name string, cb core.CallbackFunc) error {
+	if r.callbacks == nil {
+		r.callbacks = make(map[core.HookPoint]core.CallbackFunc)
+	}
+	r.callbacks[hook] = cb
+	return nil
+}
+
+func (r *runner) Emit(event core.Event) error {
+	r.mu.Lock()
+	r.emitted = append(r.emitted, event)
+	r.mu.Unlock()
+	ctx := context.Background()
+	for event != nil {
+		args := core.CallbackArgs{AgentID: event.GetTargetAgentID(), Event: event}
+		r.callbacks[core.HookBeforeEventHandling](ctx, args)
+		state := core.NewState()
+		var next core.Event
+		switch args.AgentID {
+		case "writer":
+			input, _ := event.GetData()["input"].(string)
+			if input == "fail" {
+				args.Error = errors.New("model down")
+				break
+			}
+			draft := strings.ToUpper(input)
+			state.Set("draft", draft)
+			state.SetMeta(core.RouteMetadataKey, "formatter")
+			next = core.NewEvent("formatter", core.EventData{"draft": draft}, maps.Clone(event.GetMetadata()))
+		case "formatter":
+			state.Set("final_response", event.GetData()["draft"].(string)+".")
+		}
+		if r.ledger != nil {
+			runID, _ := event.GetMetadataValue(history.RunIDKey)
+			r.ledger.Append(usage.Record{Time: time.Now(), RunID: runID, Agent: args.AgentID, PromptTokens: 6, CompletionTokens: 4, Cost: 0.01})
+		}
+		if args.Error == nil {
+			args.State = state
+		}
+		r.callbacks[core.HookAfterEventHandling](ctx, args)
+		event = next
+	}
+	return nil
+}
+
+func (r *runner) events() []core.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]core.Event(nil), r.emitted...)
+}
+
+// stored saves a completed writer → formatter run of input.
+func stored(t *testing.T, store history.Store, id, input, response string, at time.Time, metadata map[string]string) {
+	t.Helper()
+	meta := map[string]string{core.RouteMetadataKey: "writer"}
+	maps.Copy(meta, metadata)
+	err := store.Save(context.Background(), &history.Run{
+		ID:            id,
+		SessionID:     "s1",
+		Input:         input,
+		Status:        history.StatusCompleted,
+		FinalResponse: response,
+		Metadata:      meta,
+		StartedAt:     at,
+		EndedAt:       at.Add(2 * time.Second),
+		Steps: []history.Step{
+			{Agent: "writer", Route: "formatter", Output: map[string]any{"draft": strings.ToUpper(input)}},
+			{Agent: "formatter", Output: map[string]any{"final_response": response}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func backfiller(t *testing.T, ledger *usage.Ledger) (*Backfiller, *runner, history.Store) {
+	t.Helper()
+	store := history.NewMemoryStore()
+	recorder := history.NewRecorder(store)
+	r := &runner{ledger: ledger}
+	if err := recorder.Register(r); err != nil {
+		t.Fatal(err)
+	}
+	return New(recorder, r, ledger), r, store
+}
+
+func TestSelect(t *testing.T) {
+	b, _, store := backfiller(t, nil)
+	ctx := context.Background()
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	stored(t, store, "run-2", "rust", "RUST.", at.Add(time.Hour), map[string]string{usage.WorkflowKey: "news"})
+	stored(t, store, "run-1", "go", "GO.", at, map[string]string{core.RouteMetadataKey: "blog@v2"})
+	stored(t, store, "run-3", "zig", "ZIG.", at.Add(2*time.Hour), map[string]string{history.RerunOfKey: "run-1"})
+	store.Save(ctx, &history.Run{ID: "run-4", Status: history.StatusFailed, StartedAt: at})
+
+	ids := func(opts Options) string {
+		t.Helper()
+		runs, err := b.Select(ctx, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, run := range runs {
+			out = append(out, run.ID)
+		}
+		return strings.Join(out, " ")
+	}
+	tests := []struct {
+		opts Options
+		want string
+	}{
+		// Completed runs that aren't re-runs, oldest first
+		{Options{}, "run-1 run-2"},
+		{Options{Workflow: "blog"}, "run-1"},
+		{Options{Workflow: "news"}, "run-2"},
+		{Options{Filter: history.Filter{Limit: 1}}, "run-2"},
+		{Options{Filter: history.Filter{Status: history.StatusFailed}}, "run-4"},
+		{Options{RunIDs: []string{"run-3", "run-1"}}, "run-3 run-1"},
+	}
+	for _, tt := range tests {
+		if got := ids(tt.opts); got != tt.want {
+			t.Errorf("select %+v = %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+	if _, err := b.Select(ctx, Options{RunIDs: []string{"run-9"}}); !errors.Is(err, history.ErrNotFound) {
+		t.Errorf("unknown run: %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	b, r, store := backfiller(t, nil)
+	at := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	stored(t, store, "run-1", "go", "GO.", at, map[string]string{deploy.VersionKey: "blog@v1"})
+	stored(t, store, "run-2", "rust", "Rust.", at.Add(time.Hour), nil)
+	stored(t, store, "run-3", "fail", "FAIL.", at.Add(2*time.Hour), nil)
+	store.Save(context.Background(), &history.Run{ID: "run-4", Status: history.StatusCompleted, StartedAt: at.Add(3 * time.Hour)})
+
+	report, err := b.Run(context.Background(), Options{Metadata: map[string]string{"tone": "casual"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Selected != 4 || report.Completed != 2 || report.Failed != 1 || report.Skipped != 1 || report.Changed != 2 || report.Spend != nil {
+		t.Errorf("report = %+v", report)
+	}
+	same, changed, failed, skipped := report.Results[0], report.Results[1], report.Results[2], report.Results[3]
+	if same.RunID != "run-1" || same.Changed || same.Response != "GO." || same.NewRunID == "" || same.OriginalTime != 2*time.Second {
+		t.Errorf("unchanged run = %+v", same)
+	}
+	if strings.Join(same.Agents, " ") != "writer formatter" || strings.Join(same.OriginalAgents, " ") != "writer formatter" {
+		t.Errorf("agents = %v, originally %v", same.Agents, same.OriginalAgents)
+	}
+	if !changed.Changed || changed.Response != "RUST." || changed.Difference != `line 1: now "RUST.", was "Rust."` {
+		t.Errorf("changed run = %+v", changed)
+	}
+	if !failed.Changed || failed.Status != history.StatusFailed || !strings.Contains(failed.Error, "model down") {
+		t.Errorf("failed run = %+v", failed)
+	}
+	if !strings.Contains(skipped.Skipped, "no entry route") {
+		t.Errorf("run without a route = %+v", skipped)
+	}
+
+	// Each re-run is a session of its own on the active version
+	events := r.events()
+	if len(events) != 3 {
+		t.Fatalf("emitted %d events", len(events))
+	}
+	for _, event := range events {
+		meta := event.GetMetadata()
+		rerunOf := meta[history.RerunOfKey]
+		if event.GetSessionID() != SessionPrefix+rerunOf || meta[deploy.VersionKey] != "" || meta["tone"] != "casual" {
+			t.Errorf("re-run of %s: session %s, metadata %v", rerunOf, event.GetSessionID(), meta)
+		}
+	}
+}
+
+func TestRunFrom(t *testing.T) {
+	b, r, store := backfiller(t, nil)
+	stored(t, store, "run-1", "go", "Go.", time.Now(), nil)
+	report, err := b.Run(context.Background(), Options{From: "formatter"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := report.Results[0]
+	if res.Response != "GO." || strings.Join(res.Agents, " ") != "formatter" || strings.Join(res.OriginalAgents, " ") != "formatter" {
+		t.Errorf("result = %+v", res)
+	}
+	if event := r.events()[0]; event.GetTargetAgentID() != "formatter" {
+		t.Errorf("re-ran from %s", event.GetTargetAgentID())
+	}
+	report, err = b.Run(context.Background(), Options{From: "editor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Skipped != 1 || !strings.Contains(report.Results[0].Skipped, "never reached editor") {
+		t.Errorf("re-run from an agent the run never reached = %+v", report.Results[0])
+	}
+}
+
+func TestRunCaps(t *testing.T) {
+	b, _, _ := backfiller(t, nil)
+	if _, err := b.Run(context.Background(), Options{MaxTokens: 100}); err == nil {
+		t.Error("caps accepted without a ledger")
+	}
+
+	ledger, err := usage.OpenLedger(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _, store := backfiller(t, ledger)
+	at := time.Now().Add(-time.Minute)
+	for i, id := range []string{"run-1", "run-2", "run-3"} {
+		stored(t, store, id, id, strings.ToUpper(id)+".", at.Add(time.Duration(i)*time.Second), nil)
+	}
+	ledger.Append(usage.Record{Time: at, RunID: "run-1", Agent: "writer", PromptTokens: 30, CompletionTokens: 20, Cost: 0.5})
+
+	report, err := b.Run(context.Background(), Options{MaxTokens: 20, Concurrency: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Completed != 1 || report.Skipped != 2 || report.Spend.Tokens != 20 {
+		t.Errorf("report = %+v", report)
+	}
+	first, last := report.Results[0], report.Results[2]
+	if first.Spend.Tokens != 20 || first.OriginalSpend.Tokens != 50 || first.OriginalSpend.Cost != 0.5 {
+		t.Errorf("spend %+v, originally %+v", first.Spend, first.OriginalSpend)
+	}
+	if last.Skipped != "token cap of 20 reached" || last.Original != "RUN-3." {
+		t.Errorf("run past the cap = %+v", last)
+	}
+
+	report, err = b.Run(context.Background(), Options{MaxCost: 0.01, Concurrency: 1, RunIDs: []string{"run-1", "run-2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Completed != 1 || report.Results[1].Skipped != "cost cap of 0.0100 reached" {
+		t.Errorf("cost capped report = %+v", report)
+	}
+}
+
+func TestRunCanceled(t *testing.T) {
+	b, r, store := backfiller(t, nil)
+	stored(t, store, "run-1", "go", "GO.", time.Now(), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	report, err := b.Run(ctx, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Skipped != 1 || report.Stopped != context.Canceled.Error() || len(r.events()) != 0 {
+		t.Errorf("report = %+v", report)
+	}
+}
+
+func TestFirstDifference(t *testing.T) {
+	tests := []struct{ want, got, diff string }{
+		{"a\nb", "a\nc", `line 2: now "c", was "b"`},
+		{"a", "a\nb", `line 2: now "b", was ""`},
+		{"a", "a", "differs"},
+		{strings.Repeat("x", 90), "y", `line 1: now "y", was "` + strings.Repeat("x", 80) + `…"`},
+	}
+	for _, tt := range tests {
+		if got := firstDifference(tt.want, tt.got); got != tt.diff {
+			t.Errorf("firstDifference(%q, %q) = %s, want %s", tt.want, tt.got, got, tt.diff)
+		}
+	}
+}
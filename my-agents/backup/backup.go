@@ -0,0 +1,366 @@
+// Package backup dumps everything the system persists — the config, run
+// history, checkpoints, agent memory, vectors, ledgers and the other state
+// files — into one portable archive, and restores it on another machine.
+//
+// An archive is a gzipped tar of the config under config/, each data file
+// under data/ and a manifest.json last, listing every file with its
+// checksum. SQLite databases are copied with VACUUM INTO, so a backup taken
+// while the system serves is consistent. Stores on servers (pgvector,
+// Qdrant, Chroma, Redis, S3) are backed up with their own tools.
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"my-agents/storage"
+)
+
+// Version is the archive format written.
+const Version = 1
+
+const manifestName = "manifest.json"
+
+// Manifest describes an archive's contents.
+type Manifest struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	Config  string    `json:"config"` // the config file's name
+	Files   []File    `json:"files"`
+}
+
+// File is an archived file. Path is where it is restored: relative to the
+// working directory when it was inside it, absolute otherwise.
+type File struct {
+	Path     string `json:"path"`
+	Size     int64  `json:"size"`
+	SHA256   string `json:"sha256"`
+	Database bool   `json:"database,omitempty"`
+}
+
+// Options says what a backup holds.
+type Options struct {
+	ConfigPath string
+	// Dirs are the data directories archived whole.
+	Dirs []string
+	// Databases are the SQLite files, inside Dirs or not, to snapshot
+	// rather than copy.
+	Databases []string
+}
+
+// Create writes an archive of opts' config and data to w.
+func Create(ctx context.Context, w io.Writer, opts Options) (*Manifest, error) {
+	m := &Manifest{Version: Version, Created: time.Now().UTC(), Config: filepath.Base(opts.ConfigPath)}
+	zw := gzip.NewWriter(w)
+	tw := tar.NewWriter(zw)
+	a := &archiver{tw: tw, m: m}
+
+	if err := a.addFile("config/"+m.Config, opts.ConfigPath, nil); err != nil {
+		return nil, err
+	}
+	tmp, err := os.MkdirTemp("", "agentflow-backup-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmp)
+
+	databases := make(map[string]bool, len(opts.Databases))
+	for _, db := range opts.Databases {
+		abs, err := filepath.Abs(db)
+		if err != nil {
+			return nil, err
+		}
+		databases[abs] = true
+	}
+	var paths []string
+	for _, dir := range opts.Dirs {
+		err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if errors.Is(err, fs.ErrNotExist) && p == dir {
+				return fs.SkipDir
+			}
+			if err != nil || d.IsDir() || !d.Type().IsRegular() {
+				return err
+			}
+			abs, err := filepath.Abs(p)
+			if err != nil {
+				return err
+			}
+			paths = append(paths, abs)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s: %w", dir, err)
+		}
+	}
+	for db := range databases {
+		paths = append(paths, db)
+	}
+	slices.Sort(paths)
+	paths = slices.Compact(paths)
+
+	for i, p := range paths {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		switch {
+		case strings.HasSuffix(p, "-wal") && databases[strings.TrimSuffix(p, "-wal")],
+			strings.HasSuffix(p, "-shm") && databases[strings.TrimSuffix(p, "-shm")],
+			strings.HasSuffix(p, ".tmp"):
+			// A snapshot folds in the log; half-written files aren't state
+			continue
+		case databases[p]:
+			snap := filepath.Join(tmp, fmt.Sprintf("%d.db", i))
+			if err := storage.Snapshot(ctx, p, snap); err != nil {
+				return nil, err
+			}
+			to := restorePath(p)
+			if err := a.addFile(entryName(to), snap, &File{Path: to, Database: true}); err != nil {
+				return nil, err
+			}
+			os.Remove(snap)
+		default:
+			to := restorePath(p)
+			if err := a.addFile(entryName(to), p, &File{Path: to}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	manifest, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := tw.WriteHeader(&tar.Header{Name: manifestName, Mode: 0o644, Size: int64(len(manifest)), ModTime: m.Created}); err != nil {
+		return nil, err
+	}
+	if _, err := tw.Write(manifest); err != nil {
+		return nil, err
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	return m, zw.Close()
+}
+
+type archiver struct {
+	tw *tar.Writer
+	m  *Manifest
+}
+
+// addFile writes the file at src under name, listing it in the manifest
+// when f is set.
+func (a *archiver) addFile(name, src string, f *File) error {
+	file, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{Name: name, Mode: int64(info.Mode().Perm()), Size: info.Size(), ModTime: info.ModTime()}
+	if err := a.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(a.tw, h), file); err != nil {
+		return fmt.Errorf("failed to archive %s: %w", src, err)
+	}
+	if f != nil {
+		f.Size, f.SHA256 = info.Size(), hex.EncodeToString(h.Sum(nil))
+		a.m.Files = append(a.m.Files, *f)
+	}
+	return nil
+}
+
+// restorePath is where the file at abs is restored: relative to the
+// working directory when inside it.
+func restorePath(abs string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, abs); err == nil && !strings.HasPrefix(rel, "..") {
+			return filepath.ToSlash(rel)
+		}
+	}
+	return filepath.ToSlash(abs)
+}
+
+// entryName is the archive entry of the file restored to p.
+func entryName(p string) string {
+	return "data/" + strings.TrimPrefix(p, "/")
+}
+
+// RestoreOptions says where an archive is restored.
+type RestoreOptions struct {
+	// ConfigPath is where the config is written (default its archived
+	// name in the working directory).
+	ConfigPath string
+	// Force overwrites existing files instead of refusing to.
+	Force bool
+	// Outside restores the files the archive places outside the working
+	// directory, at absolute paths or above it, which are refused
+	// otherwise: an archive from elsewhere could overwrite any file.
+	Outside bool
+}
+
+// Restore unpacks an archive Create wrote. Every file is checked against
+// the manifest before any is put in place, and nothing is written when a
+// file exists and opts.Force is unset. The system must not be running.
+func Restore(r io.Reader, opts RestoreOptions) (*Manifest, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, fmt.Errorf("not a backup archive: %w", err)
+	}
+	staging, err := os.MkdirTemp("", "agentflow-restore-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(staging)
+
+	// Stage every entry, then check them all against the manifest
+	tr := tar.NewReader(zr)
+	sums := make(map[string]string)
+	var m *Manifest
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		name := path.Clean(hdr.Name)
+		if name == manifestName {
+			m = &Manifest{}
+			if err := json.NewDecoder(tr).Decode(m); err != nil {
+				return nil, fmt.Errorf("bad manifest: %w", err)
+			}
+			continue
+		}
+		if hdr.Typeflag != tar.TypeReg || strings.HasPrefix(name, "..") || path.IsAbs(name) {
+			return nil, fmt.Errorf("unexpected archive entry %s", hdr.Name)
+		}
+		dst := filepath.Join(staging, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, fs.FileMode(hdr.Mode).Perm())
+		if err != nil {
+			return nil, err
+		}
+		h := sha256.New()
+		_, err = io.Copy(io.MultiWriter(f, h), tr)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to unpack %s: %w", name, err)
+		}
+		sums[name] = hex.EncodeToString(h.Sum(nil))
+	}
+	if m == nil {
+		return nil, errors.New("archive has no manifest; it is incomplete or not a backup")
+	}
+	if m.Version > Version {
+		return nil, fmt.Errorf("archive format %d is newer than this build reads (%d)", m.Version, Version)
+	}
+	if m.Config == "" || m.Config != path.Base(m.Config) || m.Config == ".." {
+		return nil, fmt.Errorf("bad config name %q in manifest", m.Config)
+	}
+	if _, ok := sums["config/"+m.Config]; !ok {
+		return nil, fmt.Errorf("archive lacks its config %s", m.Config)
+	}
+	for _, f := range m.Files {
+		if outside(f.Path) && !opts.Outside {
+			return nil, fmt.Errorf("%s is outside the working directory; restore with outside to write it", f.Path)
+		}
+		if sums[path.Clean(entryName(f.Path))] != f.SHA256 {
+			return nil, fmt.Errorf("%s is missing from the archive or corrupt", f.Path)
+		}
+	}
+
+	configPath := opts.ConfigPath
+	if configPath == "" {
+		configPath = m.Config
+	}
+	targets := []string{configPath}
+	for _, f := range m.Files {
+		targets = append(targets, filepath.FromSlash(f.Path))
+	}
+	if !opts.Force {
+		for _, t := range targets {
+			if _, err := os.Stat(t); err == nil {
+				return nil, fmt.Errorf("%s exists; restore with force to overwrite it", t)
+			}
+		}
+	}
+
+	if err := place(filepath.Join(staging, "config", m.Config), configPath); err != nil {
+		return nil, err
+	}
+	for _, f := range m.Files {
+		dst := filepath.FromSlash(f.Path)
+		if f.Database {
+			// A log left from the overwritten database would be replayed
+			// into the restored one
+			os.Remove(dst + "-wal")
+			os.Remove(dst + "-shm")
+		}
+		if err := place(filepath.Join(staging, filepath.FromSlash(path.Clean(entryName(f.Path)))), dst); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// outside reports whether p, a manifest path, is restored outside the
+// working directory.
+func outside(p string) bool {
+	p = path.Clean(filepath.ToSlash(p))
+	return path.IsAbs(p) || filepath.IsAbs(filepath.FromSlash(p)) || p == ".." || strings.HasPrefix(p, "../")
+}
+
+// place copies the staged file src to dst, with its mode, replacing dst at
+// once.
+func place(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return err
+	}
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out, in)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to restore %s: %w", dst, err)
+	}
+	return os.Rename(tmp, dst)
+}
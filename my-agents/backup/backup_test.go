@@ -0,0 +1,249 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"my-agents/storage"
+)
+
+// system lays out a config, a data directory and a database in the working
+// directory, which it changes to a fresh one.
+func system(t *testing.T) Options {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	write(t, "agentflow.toml", "[llm]\ntype = \"openai\"\n")
+	write(t, ".agentflow/usage/2026-10.jsonl", `{"agent":"a","cost":0.5}`+"\n")
+	write(t, ".agentflow/blobs/ab/cdef", "blob")
+	write(t, ".agentflow/usage/2026-10.jsonl.tmp", "half written")
+
+	db, err := storage.Open(".agentflow/agentflow.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`CREATE TABLE runs (id TEXT); INSERT INTO runs VALUES ('run-1')`); err != nil {
+		t.Fatal(err)
+	}
+	return Options{
+		ConfigPath: "agentflow.toml",
+		Dirs:       []string{".agentflow", "missing"},
+		Databases:  []string{".agentflow/agentflow.db"},
+	}
+}
+
+func write(t *testing.T, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(name, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func read(t *testing.T, name string) string {
+	t.Helper()
+	data, err := os.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRoundTrip(t *testing.T) {
+	opts := system(t)
+	var archive bytes.Buffer
+	m, err := Create(context.Background(), &archive, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]File)
+	for _, f := range m.Files {
+		files[f.Path] = f
+	}
+	if !files[".agentflow/agentflow.db"].Database {
+		t.Error("the database wasn't snapshotted")
+	}
+	for _, skipped := range []string{".agentflow/agentflow.db-wal", ".agentflow/agentflow.db-shm", ".agentflow/usage/2026-10.jsonl.tmp"} {
+		if _, ok := files[skipped]; ok {
+			t.Errorf("%s was archived", skipped)
+		}
+	}
+
+	t.Chdir(t.TempDir())
+	restored, err := Restore(bytes.NewReader(archive.Bytes()), RestoreOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored.Files) != len(m.Files) {
+		t.Errorf("restored %d files, archived %d", len(restored.Files), len(m.Files))
+	}
+	if got := read(t, "agentflow.toml"); !strings.Contains(got, "openai") {
+		t.Errorf("config = %q", got)
+	}
+	if got := read(t, ".agentflow/blobs/ab/cdef"); got != "blob" {
+		t.Errorf("blob = %q", got)
+	}
+	if got := read(t, ".agentflow/usage/2026-10.jsonl"); !strings.Contains(got, `"cost":0.5`) {
+		t.Errorf("ledger = %q", got)
+	}
+	db, err := storage.Open(".agentflow/agentflow.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var id string
+	if err := db.QueryRow(`SELECT id FROM runs`).Scan(&id); err != nil || id != "run-1" {
+		t.Errorf("restored database run = %q, %v", id, err)
+	}
+}
+
+func TestRestoreConfigPath(t *testing.T) {
+	var archive bytes.Buffer
+	if _, err := Create(context.Background(), &archive, system(t)); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(t.TempDir())
+	if _, err := Restore(&archive, RestoreOptions{ConfigPath: "conf/prod.toml"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat("conf/prod.toml"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRestoreRefusesToOverwrite(t *testing.T) {
+	var archive bytes.Buffer
+	if _, err := Create(context.Background(), &archive, system(t)); err != nil {
+		t.Fatal(err)
+	}
+	data := archive.Bytes()
+
+	t.Chdir(t.TempDir())
+	write(t, ".agentflow/blobs/ab/cdef", "newer")
+	if _, err := Restore(bytes.NewReader(data), RestoreOptions{}); err == nil || !strings.Contains(err.Error(), "exists") {
+		t.Fatalf("Restore over an existing file = %v, want it refused", err)
+	}
+	if _, err := os.Stat("agentflow.toml"); err == nil {
+		t.Error("a refused restore wrote the config")
+	}
+	if _, err := Restore(bytes.NewReader(data), RestoreOptions{Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, ".agentflow/blobs/ab/cdef"); got != "blob" {
+		t.Errorf("forced restore left %q", got)
+	}
+}
+
+// rewrite copies an archive, passing each entry's content through edit.
+func rewrite(t *testing.T, archive []byte, edit func(name string, content []byte) []byte) []byte {
+	t.Helper()
+	zr, err := gzip.NewReader(bytes.NewReader(archive))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(zr)
+	var out bytes.Buffer
+	zw := gzip.NewWriter(&out)
+	tw := tar.NewWriter(zw)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content = edit(hdr.Name, content)
+		hdr.Size = int64(len(content))
+		tw.WriteHeader(hdr)
+		tw.Write(content)
+	}
+	tw.Close()
+	zw.Close()
+	return out.Bytes()
+}
+
+func TestRestoreRejectsCorruptArchive(t *testing.T) {
+	var archive bytes.Buffer
+	if _, err := Create(context.Background(), &archive, system(t)); err != nil {
+		t.Fatal(err)
+	}
+	corrupt := rewrite(t, archive.Bytes(), func(name string, content []byte) []byte {
+		if strings.HasSuffix(name, "cdef") {
+			return []byte("tampered")
+		}
+		return content
+	})
+
+	t.Chdir(t.TempDir())
+	if _, err := Restore(bytes.NewReader(corrupt), RestoreOptions{}); err == nil || !strings.Contains(err.Error(), "corrupt") {
+		t.Fatalf("Restore = %v, want the corrupt file reported", err)
+	}
+	if entries, _ := os.ReadDir("."); len(entries) != 0 {
+		t.Errorf("a rejected restore wrote %d entries", len(entries))
+	}
+}
+
+func TestRestoreRefusesOutsidePaths(t *testing.T) {
+	var archive bytes.Buffer
+	if _, err := Create(context.Background(), &archive, system(t)); err != nil {
+		t.Fatal(err)
+	}
+	escaping := rewrite(t, archive.Bytes(), func(name string, content []byte) []byte {
+		if name != manifestName {
+			return content
+		}
+		var m Manifest
+		json.Unmarshal(content, &m)
+		m.Files[0].Path = "../escaped"
+		out, _ := json.Marshal(m)
+		return out
+	})
+
+	t.Chdir(t.TempDir())
+	if _, err := Restore(bytes.NewReader(escaping), RestoreOptions{}); err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Fatalf("Restore = %v, want the path outside the working directory refused", err)
+	}
+}
+
+func TestRestoreRejectsNewerFormat(t *testing.T) {
+	var archive bytes.Buffer
+	if _, err := Create(context.Background(), &archive, system(t)); err != nil {
+		t.Fatal(err)
+	}
+	newer := rewrite(t, archive.Bytes(), func(name string, content []byte) []byte {
+		if name != manifestName {
+			return content
+		}
+		var m Manifest
+		json.Unmarshal(content, &m)
+		m.Version = Version + 1
+		out, _ := json.Marshal(m)
+		return out
+	})
+
+	t.Chdir(t.TempDir())
+	if _, err := Restore(bytes.NewReader(newer), RestoreOptions{}); err == nil || !strings.Contains(err.Error(), "newer") {
+		t.Fatalf("Restore = %v, want the newer format refused", err)
+	}
+}
+
+func TestRestoreRejectsNonArchive(t *testing.T) {
+	t.Chdir(t.TempDir())
+	if _, err := Restore(strings.NewReader("not gzip"), RestoreOptions{}); err == nil {
+		t.Error("Restore accepted a file that isn't an archive")
+	}
+}
@@ -0,0 +1,371 @@
+// Package bedrock calls models hosted on Amazon Bedrock (Claude, Titan,
+// Llama and the others the Converse API serves) with requests signed by
+// AWS Signature Version 4, so the pipeline runs inside AWS on its role's
+// credentials rather than an external API key. Replies stream through
+// ConverseStream; embeddings come from a Titan or Cohere embedding model.
+package bedrock
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/awsauth"
+	"my-agents/httpclient"
+	"my-agents/ratelimit"
+)
+
+// Type is the provider type of a [providers.<name>] table.
+const Type = "bedrock"
+
+// signingName is the service Bedrock runtime requests are signed for.
+const signingName = "bedrock"
+
+// Defaults for unset settings.
+const (
+	DefaultEmbeddingModel = "amazon.titan-embed-text-v2:0"
+	DefaultTimeout        = 2 * time.Minute
+)
+
+// Options are the settings of a bedrock [providers.<name>] table besides
+// agenticgokit's type, model, base_url, max_tokens, temperature and
+// http_timeout. Credentials come from the environment, the shared
+// credentials file or the role of the machine, container or pod:
+//
+//	[providers.bedrock]
+//	type = "bedrock"
+//	model = "anthropic.claude-3-5-sonnet-20240620-v1:0"
+//	region = "eu-central-1"
+type Options struct {
+	// Region hosts the models (default $AWS_REGION, else
+	// $AWS_DEFAULT_REGION).
+	Region string `toml:"region"`
+	// Profile names the shared credentials file's profile (default
+	// $AWS_PROFILE, else "default").
+	Profile string `toml:"aws_profile"`
+	// EmbeddingModel is the table's embedding_model, which the local
+	// providers read too (default DefaultEmbeddingModel).
+	EmbeddingModel string `toml:"-"`
+}
+
+// Region is the region opts configure, or the environment's.
+func Region(opts Options) string {
+	return awsauth.Region(opts.Region)
+}
+
+// Host is the Bedrock runtime endpoint's host in region.
+func Host(region string) string {
+	return "bedrock-runtime." + region + ".amazonaws.com"
+}
+
+// Provider calls one Bedrock model.
+type Provider struct {
+	http           *http.Client
+	base           string
+	region         string
+	creds          *awsauth.Source
+	model          string
+	embeddingModel string
+	maxTokens      int
+	temperature    float64
+}
+
+// New creates the provider cfg configures.
+func New(cfg core.LLMProviderConfig, opts Options) (*Provider, error) {
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("bedrock provider needs a model")
+	}
+	region := Region(opts)
+	if region == "" {
+		return nil, fmt.Errorf("bedrock provider needs a region or $AWS_REGION")
+	}
+	creds, err := awsauth.NewSource(opts.Profile, region)
+	if err != nil {
+		return nil, fmt.Errorf("bedrock: %w", err)
+	}
+	p := &Provider{
+		http:           httpclient.New(DefaultTimeout),
+		base:           "https://" + Host(region),
+		region:         region,
+		creds:          creds,
+		model:          cfg.Model,
+		embeddingModel: opts.EmbeddingModel,
+		maxTokens:      cfg.MaxTokens,
+		temperature:    cfg.Temperature,
+	}
+	if cfg.BaseURL != "" {
+		// A VPC endpoint; requests are still signed for the region
+		p.base = strings.TrimSuffix(cfg.BaseURL, "/")
+	}
+	if cfg.HTTPTimeout > 0 {
+		p.http = httpclient.New(cfg.HTTPTimeout)
+	}
+	if p.embeddingModel == "" {
+		p.embeddingModel = DefaultEmbeddingModel
+	}
+	return p, nil
+}
+
+type text struct {
+	Text string `json:"text"`
+}
+
+type message struct {
+	Role    string `json:"role"`
+	Content []text `json:"content"`
+}
+
+type inferenceConfig struct {
+	MaxTokens   int      `json:"maxTokens,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+}
+
+// converse is a Converse or ConverseStream request's body.
+type converse struct {
+	System          []text          `json:"system,omitempty"`
+	Messages        []message       `json:"messages"`
+	InferenceConfig inferenceConfig `json:"inferenceConfig"`
+}
+
+// usage is the token count of a reply.
+type usage struct {
+	InputTokens  int `json:"inputTokens"`
+	OutputTokens int `json:"outputTokens"`
+	TotalTokens  int `json:"totalTokens"`
+}
+
+// request builds prompt's body; the prompt's parameters win over the
+// table's. Titan text models take no system prompt, so theirs leads the
+// user message.
+func (p *Provider) request(prompt core.Prompt) (converse, error) {
+	if strings.TrimSpace(prompt.User) == "" {
+		return converse{}, fmt.Errorf("bedrock: prompt has no user message")
+	}
+	user := prompt.User
+	var system []text
+	if prompt.System != "" {
+		if strings.Contains(p.model, "amazon.titan") {
+			user = prompt.System + "\n\n" + user
+		} else {
+			system = []text{{Text: prompt.System}}
+		}
+	}
+	c := converse{
+		System:          system,
+		Messages:        []message{{Role: "user", Content: []text{{Text: user}}}},
+		InferenceConfig: inferenceConfig{MaxTokens: p.maxTokens},
+	}
+	if t := prompt.Parameters.MaxTokens; t != nil && *t > 0 {
+		c.InferenceConfig.MaxTokens = int(*t)
+	}
+	if t := prompt.Parameters.Temperature; t != nil {
+		v := float64(*t)
+		c.InferenceConfig.Temperature = &v
+	} else if p.temperature > 0 {
+		c.InferenceConfig.Temperature = &p.temperature
+	}
+	return c, nil
+}
+
+// post sends in to the model's operation, e.g. "converse", signed, and
+// returns the response body, which the caller closes. Error responses are
+// reported with their status and AWS error type ("ThrottlingException",
+// "ServiceUnavailableException"), which the retry and fallback policies
+// classify.
+func (p *Provider) post(ctx context.Context, model, operation string, in any) (io.ReadCloser, error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	creds, err := p.creds.Credentials(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("bedrock: %w", err)
+	}
+	// Model IDs hold ':', which the path carries escaped
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.base+"/model/"+awsauth.Escape(model, true)+"/"+operation, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	awsauth.Sign(req, body, creds, p.region, signingName, time.Now())
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("bedrock: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, ratelimit.WithRetryAfter(fmt.Errorf("bedrock: %s returned status %d: %s", operation, resp.StatusCode, errorMessage(resp.Header, msg)), resp.Header)
+	}
+	return resp.Body, nil
+}
+
+// errorMessage renders an error response as "type: message".
+func errorMessage(header http.Header, body []byte) string {
+	var e struct {
+		Message string `json:"message"`
+	}
+	msg := strings.TrimSpace(string(body))
+	if json.Unmarshal(body, &e) == nil && e.Message != "" {
+		msg = e.Message
+	}
+	// "ThrottlingException:http://internal.amazon.com/coral/..."
+	if typ, _, _ := strings.Cut(header.Get("X-Amzn-Errortype"), ":"); typ != "" {
+		return typ + ": " + msg
+	}
+	return msg
+}
+
+func (p *Provider) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	c, err := p.request(prompt)
+	if err != nil {
+		return core.Response{}, err
+	}
+	body, err := p.post(ctx, p.model, "converse", c)
+	if err != nil {
+		return core.Response{}, err
+	}
+	defer body.Close()
+	var out struct {
+		Output struct {
+			Message message `json:"message"`
+		} `json:"output"`
+		StopReason string `json:"stopReason"`
+		Usage      usage  `json:"usage"`
+	}
+	if err := json.NewDecoder(body).Decode(&out); err != nil {
+		return core.Response{}, fmt.Errorf("bedrock: failed to decode response: %w", err)
+	}
+	var reply strings.Builder
+	for _, t := range out.Output.Message.Content {
+		reply.WriteString(t.Text)
+	}
+	return core.Response{
+		Content:      reply.String(),
+		Usage:        core.UsageStats{PromptTokens: out.Usage.InputTokens, CompletionTokens: out.Usage.OutputTokens, TotalTokens: out.Usage.TotalTokens},
+		FinishReason: out.StopReason,
+	}, nil
+}
+
+// Stream sends the text deltas of a ConverseStream reply until its
+// messageStop. An exception event, such as throttling mid-reply, ends the
+// stream with it.
+func (p *Provider) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	c, err := p.request(prompt)
+	if err != nil {
+		return nil, err
+	}
+	body, err := p.post(ctx, p.model, "converse-stream", c)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make(chan core.Token)
+	go func() {
+		defer close(tokens)
+		defer body.Close()
+		send := func(t core.Token) bool {
+			select {
+			case tokens <- t:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		r := bufio.NewReader(body)
+		for {
+			e, err := readEvent(r)
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				send(core.Token{Error: fmt.Errorf("bedrock: bad stream event: %w", err)})
+				return
+			}
+			if typ := e.headers[":message-type"]; typ != "event" {
+				var msg struct {
+					Message string `json:"message"`
+				}
+				_ = json.Unmarshal(e.payload, &msg)
+				kind := cmp.Or(e.headers[":exception-type"], e.headers[":error-code"])
+				send(core.Token{Error: fmt.Errorf("bedrock: stream failed: %s: %s", kind, cmp.Or(msg.Message, e.headers[":error-message"]))})
+				return
+			}
+			switch e.headers[":event-type"] {
+			case "contentBlockDelta":
+				var delta struct {
+					Delta text `json:"delta"`
+				}
+				if err := json.Unmarshal(e.payload, &delta); err != nil {
+					send(core.Token{Error: fmt.Errorf("bedrock: bad stream event: %w", err)})
+					return
+				}
+				if delta.Delta.Text != "" && !send(core.Token{Content: delta.Delta.Text}) {
+					return
+				}
+			case "messageStop":
+				return
+			}
+		}
+	}()
+	return tokens, nil
+}
+
+// maxCohereBatch is the most texts a Cohere embedding request takes.
+const maxCohereBatch = 96
+
+// Embeddings embeds texts with the embedding model: Titan models one text
+// per request, Cohere models in batches.
+func (p *Provider) Embeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	vectors := make([][]float64, 0, len(texts))
+	if strings.Contains(p.embeddingModel, "cohere.embed") {
+		for start := 0; start < len(texts); start += maxCohereBatch {
+			batch := texts[start:min(start+maxCohereBatch, len(texts))]
+			var out struct {
+				Embeddings [][]float64 `json:"embeddings"`
+			}
+			if err := p.invoke(ctx, map[string]any{"texts": batch, "input_type": "search_document"}, &out); err != nil {
+				return nil, err
+			}
+			if len(out.Embeddings) != len(batch) {
+				return nil, fmt.Errorf("bedrock: %d embeddings for %d texts", len(out.Embeddings), len(batch))
+			}
+			vectors = append(vectors, out.Embeddings...)
+		}
+		return vectors, nil
+	}
+	for _, t := range texts {
+		var out struct {
+			Embedding []float64 `json:"embedding"`
+		}
+		if err := p.invoke(ctx, map[string]any{"inputText": t}, &out); err != nil {
+			return nil, err
+		}
+		vectors = append(vectors, out.Embedding)
+	}
+	return vectors, nil
+}
+
+// invoke calls the embedding model with its native body and decodes the
+// response.
+func (p *Provider) invoke(ctx context.Context, in, out any) error {
+	body, err := p.post(ctx, p.embeddingModel, "invoke", in)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := json.NewDecoder(body).Decode(out); err != nil {
+		return fmt.Errorf("bedrock: failed to decode embeddings: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package bedrock
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// maxEventSize bounds a streamed event, well above Bedrock's chunks.
+const maxEventSize = 1 << 20
+
+// event is a message of the binary event stream Bedrock streams replies
+// in: string headers (":event-type", ":message-type") and a JSON payload.
+type event struct {
+	headers map[string]string
+	payload []byte
+}
+
+// readEvent reads the next message: a prelude of total and header lengths
+// and its CRC, the headers, the payload and the message's CRC. It returns
+// io.EOF at the end of the stream.
+func readEvent(r *bufio.Reader) (event, error) {
+	var prelude [12]byte
+	if _, err := io.ReadFull(r, prelude[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return event{}, fmt.Errorf("truncated event")
+		}
+		return event{}, err
+	}
+	total := binary.BigEndian.Uint32(prelude[0:4])
+	headersLen := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return event{}, fmt.Errorf("event prelude checksum mismatch")
+	}
+	if total < 16 || total > maxEventSize || headersLen > total-16 {
+		return event{}, fmt.Errorf("bad event length %d", total)
+	}
+	msg := make([]byte, total)
+	copy(msg, prelude[:])
+	if _, err := io.ReadFull(r, msg[12:]); err != nil {
+		return event{}, fmt.Errorf("truncated event")
+	}
+	if crc32.ChecksumIEEE(msg[:total-4]) != binary.BigEndian.Uint32(msg[total-4:]) {
+		return event{}, fmt.Errorf("event checksum mismatch")
+	}
+	headers, err := eventHeaders(msg[12 : 12+headersLen])
+	if err != nil {
+		return event{}, err
+	}
+	return event{headers: headers, payload: msg[12+headersLen : total-4]}, nil
+}
+
+// eventHeaders decodes the string headers of an event, skipping the other
+// types.
+func eventHeaders(b []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	for len(b) > 0 {
+		n := int(b[0])
+		if len(b) < 1+n+1 {
+			return nil, fmt.Errorf("bad event header")
+		}
+		name, typ := string(b[1:1+n]), b[1+n]
+		b = b[2+n:]
+		var size int
+		switch typ {
+		case 0, 1: // true, false
+		case 2:
+			size = 1
+		case 3:
+			size = 2
+		case 4:
+			size = 4
+		case 5, 8: // int64, timestamp
+			size = 8
+		case 9: // uuid
+			size = 16
+		case 6, 7: // bytes, string
+			if len(b) < 2 {
+				return nil, fmt.Errorf("bad event header %s", name)
+			}
+			size = int(binary.BigEndian.Uint16(b))
+			b = b[2:]
+			if len(b) < size {
+				return nil, fmt.Errorf("bad event header %s", name)
+			}
+			if typ == 7 {
+				headers[name] = string(b[:size])
+			}
+		default:
+			return nil, fmt.Errorf("bad event header %s type %d", name, typ)
+		}
+		if len(b) < size {
+			return nil, fmt.Errorf("bad event header %s", name)
+		}
+		b = b[size:]
+	}
+	return headers, nil
+}
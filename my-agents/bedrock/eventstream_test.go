@@ -0,0 +1,105 @@
+package bedrock
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+)
+
+// frame encodes an event stream message with string headers, and an int32
+// header the reader skips.
+func frame(headers map[string]string, payload string) []byte {
+	var h bytes.Buffer
+	h.WriteByte(byte(len(":skipped")))
+	h.WriteString(":skipped")
+	h.WriteByte(4)
+	binary.Write(&h, binary.BigEndian, int32(7))
+	for name, value := range headers {
+		h.WriteByte(byte(len(name)))
+		h.WriteString(name)
+		h.WriteByte(7)
+		binary.Write(&h, binary.BigEndian, uint16(len(value)))
+		h.WriteString(value)
+	}
+	total := 12 + h.Len() + len(payload) + 4
+	msg := make([]byte, 0, total)
+	msg = binary.BigEndian.AppendUint32(msg, uint32(total))
+	msg = binary.BigEndian.AppendUint32(msg, uint32(h.Len()))
+	msg = binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg[:8]))
+	msg = append(msg, h.Bytes()...)
+	msg = append(msg, payload...)
+	return binary.BigEndian.AppendUint32(msg, crc32.ChecksumIEEE(msg))
+}
+
+func TestReadEvents(t *testing.T) {
+	stream := append(
+		frame(map[string]string{":event-type": "chunk", ":message-type": "event"}, `{"bytes":"aGk="}`),
+		frame(map[string]string{":message-type": "exception", ":exception-type": "throttlingException"}, `{"message":"slow down"}`)...)
+	r := bufio.NewReader(bytes.NewReader(stream))
+
+	ev, err := readEvent(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.headers[":event-type"] != "chunk" || ev.headers[":message-type"] != "event" || string(ev.payload) != `{"bytes":"aGk="}` {
+		t.Errorf("first event = %v %s", ev.headers, ev.payload)
+	}
+	if _, ok := ev.headers[":skipped"]; ok {
+		t.Error("a non-string header was read as a string")
+	}
+	ev, err = readEvent(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.headers[":exception-type"] != "throttlingException" {
+		t.Errorf("second event headers = %v", ev.headers)
+	}
+	if _, err := readEvent(r); !errors.Is(err, io.EOF) {
+		t.Errorf("after the last event = %v, want io.EOF", err)
+	}
+}
+
+func TestReadEventRejectsDamage(t *testing.T) {
+	good := frame(map[string]string{":event-type": "chunk"}, `{"bytes":""}`)
+	damage := func(f func(b []byte) []byte) []byte {
+		return f(bytes.Clone(good))
+	}
+	for name, tt := range map[string]struct {
+		stream []byte
+		want   string
+	}{
+		"prelude checksum": {damage(func(b []byte) []byte { b[11] ^= 1; return b }), "prelude checksum"},
+		"message checksum": {damage(func(b []byte) []byte { b[len(b)-6] ^= 1; return b }), "event checksum"},
+		"truncated":        {good[:len(good)-3], "truncated"},
+		"short prelude":    {good[:5], "truncated"},
+		"oversized": {damage(func(b []byte) []byte {
+			binary.BigEndian.PutUint32(b[0:4], maxEventSize+1)
+			binary.BigEndian.PutUint32(b[8:12], crc32.ChecksumIEEE(b[:8]))
+			return b
+		}), "bad event length"},
+	} {
+		_, err := readEvent(bufio.NewReader(bytes.NewReader(tt.stream)))
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: readEvent = %v, want %q", name, err, tt.want)
+		}
+	}
+}
+
+func TestEventHeadersRejectsBadLengths(t *testing.T) {
+	for name, b := range map[string][]byte{
+		"name past end":   {5, 'a'},
+		"value past end":  {1, 'a', 7, 0, 9, 'x'},
+		"unknown type":    {1, 'a', 42},
+		"int past end":    {1, 'a', 5, 0, 0},
+		"no value length": {1, 'a', 7, 0},
+	} {
+		if _, err := eventHeaders(b); err == nil {
+			t.Errorf("%s: eventHeaders accepted %v", name, b)
+		}
+	}
+}
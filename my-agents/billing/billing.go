@@ -0,0 +1,377 @@
+// Package billing pushes metered usage from the local usage ledger to Stripe
+// billing meters per customer, idempotently, and reconciles what Stripe
+// holds against the ledger.
+package billing
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/usage"
+)
+
+// Metrics pushed to Stripe.
+const (
+	MetricTokens = "tokens"
+	MetricRuns   = "runs"
+)
+
+// maxAge is how far back Stripe accepts meter event timestamps.
+const maxAge = 35 * 24 * time.Hour
+
+// Config is the [billing] section of agentflow.toml:
+//
+//	[billing]
+//	enabled = true
+//	tokens_event = "agentflow_tokens"
+//	runs_event = "agentflow_runs"
+//	[billing.customers]
+//	acme = "cus_123"
+type Config struct {
+	Enabled   bool   `toml:"enabled"`
+	APIKeyEnv string `toml:"api_key_env"` // default STRIPE_API_KEY
+	URL       string `toml:"url"`         // default DefaultStripeURL
+	Interval  string `toml:"interval"`    // push schedule (default "1h")
+	// Lag is how long after an hour ends its usage is pushed, so calls still
+	// in flight at the boundary are included (default "5m").
+	Lag string `toml:"lag"`
+	// CustomerBy picks the ledger field mapped to customers: "tenant"
+	// (default) or "user".
+	CustomerBy string            `toml:"customer_by"`
+	Customers  map[string]string `toml:"customers"` // tenant/user → Stripe customer ID
+	// Event names of the Stripe meters; an empty name skips that metric.
+	TokensEvent string `toml:"tokens_event"`
+	RunsEvent   string `toml:"runs_event"`
+	// Meter IDs, used only for reconciliation.
+	TokensMeter string `toml:"tokens_meter"`
+	RunsMeter   string `toml:"runs_meter"`
+	StatePath   string `toml:"state_path"` // default .agentflow/billing.json
+}
+
+// Reporter pushes ledger usage to Stripe.
+type Reporter struct {
+	cfg    Config
+	ledger *usage.Ledger
+	stripe *Stripe
+	lag    time.Duration
+	now    func() time.Time
+
+	mu    sync.Mutex
+	state *state
+}
+
+// state records the meter events already accepted by Stripe.
+type state struct {
+	Sent map[string]Sent `json:"sent"` // by identifier
+	path string
+}
+
+// Sent is a meter event Stripe accepted.
+type Sent struct {
+	Customer string    `json:"customer"`
+	Metric   string    `json:"metric"`
+	Window   time.Time `json:"window"`
+	Value    int       `json:"value"`
+	SentAt   time.Time `json:"sent_at"`
+}
+
+// New creates a reporter reading from ledger.
+func New(cfg Config, ledger *usage.Ledger) (*Reporter, error) {
+	keyEnv := cfg.APIKeyEnv
+	if keyEnv == "" {
+		keyEnv = "STRIPE_API_KEY"
+	}
+	key := os.Getenv(keyEnv)
+	if key == "" {
+		return nil, fmt.Errorf("billing: %s is not set", keyEnv)
+	}
+	lag := 5 * time.Minute
+	if cfg.Lag != "" {
+		d, err := time.ParseDuration(cfg.Lag)
+		if err != nil {
+			return nil, fmt.Errorf("billing lag: %w", err)
+		}
+		lag = d
+	}
+	switch cfg.CustomerBy {
+	case "":
+		cfg.CustomerBy = usage.ByTenant
+	case usage.ByTenant, usage.ByUser:
+	default:
+		return nil, fmt.Errorf("billing: customer_by must be tenant or user, not %q", cfg.CustomerBy)
+	}
+	st, err := loadState(cfg.StatePath)
+	if err != nil {
+		return nil, err
+	}
+	return &Reporter{cfg: cfg, ledger: ledger, stripe: &Stripe{URL: cfg.URL, APIKey: key}, lag: lag, now: time.Now, state: st}, nil
+}
+
+// Window is the aggregated usage of one customer in one hour.
+type Window struct {
+	Customer string
+	Start    time.Time
+	Tokens   int
+	Runs     int
+}
+
+// windows aggregates records into hourly windows per customer. Runs are
+// counted in the window of their first call. Unmapped tenants/users are
+// returned by name so they can be reported.
+func (r *Reporter) windows(records []usage.Record) ([]Window, map[string]int) {
+	byKey := make(map[string]*Window)
+	seenRuns := make(map[string]bool)
+	unmapped := make(map[string]int)
+	for _, rec := range records {
+		who := rec.Tenant
+		if r.cfg.CustomerBy == usage.ByUser {
+			who = rec.User
+		}
+		customer, ok := r.cfg.Customers[who]
+		if !ok {
+			unmapped[who] += rec.Tokens()
+			continue
+		}
+		start := rec.Time.UTC().Truncate(time.Hour)
+		key := customer + "|" + start.Format(time.RFC3339)
+		w, ok := byKey[key]
+		if !ok {
+			w = &Window{Customer: customer, Start: start}
+			byKey[key] = w
+		}
+		w.Tokens += rec.Tokens()
+		if rec.RunID != "" && !seenRuns[rec.RunID] {
+			seenRuns[rec.RunID] = true
+			w.Runs++
+		}
+	}
+	out := make([]Window, 0, len(byKey))
+	for _, w := range byKey {
+		out = append(out, *w)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].Start.Equal(out[j].Start) {
+			return out[i].Start.Before(out[j].Start)
+		}
+		return out[i].Customer < out[j].Customer
+	})
+	return out, unmapped
+}
+
+// records reads the ledger months overlapping [start, end).
+func (r *Reporter) records(start, end time.Time) ([]usage.Record, error) {
+	var all []usage.Record
+	for m := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC); m.Before(end); m = m.AddDate(0, 1, 0) {
+		recs, err := r.ledger.Records(usage.Month(m))
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range recs {
+			if !rec.Time.Before(start) && rec.Time.Before(end) {
+				all = append(all, rec)
+			}
+		}
+	}
+	return all, nil
+}
+
+// SyncResult summarizes a push.
+type SyncResult struct {
+	Sent     int
+	Skipped  int            // already reported
+	Unmapped map[string]int // tokens by tenant/user without a customer ID
+}
+
+// Sync pushes every closed hour of usage not yet reported. Each meter event
+// carries a deterministic identifier, so a push interrupted midway can be
+// retried without double billing.
+func (r *Reporter) Sync(ctx context.Context) (SyncResult, error) {
+	now := r.now().UTC()
+	end := now.Add(-r.lag).Truncate(time.Hour)
+	start := now.Add(-maxAge).Truncate(time.Hour).Add(time.Hour)
+	records, err := r.records(start, end)
+	if err != nil {
+		return SyncResult{}, err
+	}
+	windows, unmapped := r.windows(records)
+	res := SyncResult{Unmapped: unmapped}
+
+	for _, w := range windows {
+		for _, m := range []struct {
+			metric, event string
+			value         int
+		}{{MetricTokens, r.cfg.TokensEvent, w.Tokens}, {MetricRuns, r.cfg.RunsEvent, w.Runs}} {
+			if m.event == "" || m.value == 0 {
+				continue
+			}
+			id := fmt.Sprintf("%s-%s-%d", m.event, w.Customer, w.Start.Unix())
+			if r.sent(id) {
+				res.Skipped++
+				continue
+			}
+			ev := MeterEvent{EventName: m.event, Customer: w.Customer, Value: m.value, Timestamp: w.Start, Identifier: id}
+			if err := r.stripe.SendMeterEvent(ctx, ev); err != nil {
+				return res, fmt.Errorf("customer %s, %s at %s: %w", w.Customer, m.metric, w.Start.Format(time.RFC3339), err)
+			}
+			if err := r.markSent(id, Sent{Customer: w.Customer, Metric: m.metric, Window: w.Start, Value: m.value, SentAt: time.Now()}); err != nil {
+				return res, err
+			}
+			res.Sent++
+		}
+	}
+	return res, nil
+}
+
+// Run syncs on the configured interval until ctx is done.
+func (r *Reporter) Run(ctx context.Context) {
+	interval := time.Hour
+	if d, err := time.ParseDuration(r.cfg.Interval); err == nil && d > 0 {
+		interval = d
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		res, err := r.Sync(ctx)
+		if err != nil {
+			core.Logger().Error().Err(err).Msg("Billing sync failed")
+		} else if res.Sent > 0 || len(res.Unmapped) > 0 {
+			core.Logger().Info().Int("sent", res.Sent).Int("unmapped", len(res.Unmapped)).Msg("Billing sync")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Discrepancy compares one customer's metric across the ledger, what was
+// reported and what Stripe holds.
+type Discrepancy struct {
+	Customer string
+	Metric   string
+	Ledger   int
+	Reported int
+	Stripe   float64
+}
+
+// OK reports whether all three agree.
+func (d Discrepancy) OK() bool {
+	return d.Ledger == d.Reported && float64(d.Reported) == d.Stripe
+}
+
+// Reconcile compares usage between start and end (hour-aligned) for every
+// mapped customer. Metrics without a configured meter ID are not queried.
+func (r *Reporter) Reconcile(ctx context.Context, start, end time.Time) ([]Discrepancy, error) {
+	start, end = start.UTC().Truncate(time.Hour), end.UTC().Truncate(time.Hour)
+	records, err := r.records(start, end)
+	if err != nil {
+		return nil, err
+	}
+	windows, _ := r.windows(records)
+	ledger := make(map[string]map[string]int) // customer → metric → value
+	for _, customer := range r.cfg.Customers {
+		ledger[customer] = map[string]int{}
+	}
+	for _, w := range windows {
+		ledger[w.Customer][MetricTokens] += w.Tokens
+		ledger[w.Customer][MetricRuns] += w.Runs
+	}
+	reported := make(map[string]map[string]int)
+	r.mu.Lock()
+	for _, s := range r.state.Sent {
+		if s.Window.Before(start) || !s.Window.Before(end) {
+			continue
+		}
+		if reported[s.Customer] == nil {
+			reported[s.Customer] = map[string]int{}
+		}
+		reported[s.Customer][s.Metric] += s.Value
+	}
+	r.mu.Unlock()
+
+	customers := make([]string, 0, len(ledger))
+	for c := range ledger {
+		customers = append(customers, c)
+	}
+	sort.Strings(customers)
+	var out []Discrepancy
+	for _, customer := range customers {
+		for _, m := range []struct{ metric, meter string }{{MetricTokens, r.cfg.TokensMeter}, {MetricRuns, r.cfg.RunsMeter}} {
+			if m.meter == "" {
+				continue
+			}
+			total, err := r.stripe.MeterTotal(ctx, m.meter, customer, start, end)
+			if err != nil {
+				return out, fmt.Errorf("customer %s: %w", customer, err)
+			}
+			out = append(out, Discrepancy{Customer: customer, Metric: m.metric, Ledger: ledger[customer][m.metric], Reported: reported[customer][m.metric], Stripe: total})
+		}
+	}
+	return out, nil
+}
+
+func (r *Reporter) sent(id string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.state.Sent[id]
+	return ok
+}
+
+func (r *Reporter) markSent(id string, s Sent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.state.Sent[id] = s
+	// Windows Stripe no longer accepts can't be resent; forget them
+	for k, old := range r.state.Sent {
+		if time.Since(old.Window) > maxAge+24*time.Hour {
+			delete(r.state.Sent, k)
+		}
+	}
+	return r.state.save()
+}
+
+func loadState(path string) (*state, error) {
+	if path == "" {
+		path = filepath.Join(".agentflow", "billing.json")
+	}
+	st := &state{Sent: make(map[string]Sent), path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("failed to decode billing state %s: %w", path, err)
+	}
+	if st.Sent == nil {
+		st.Sent = make(map[string]Sent)
+	}
+	return st, nil
+}
+
+func (s *state) save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
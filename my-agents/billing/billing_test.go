@@ -0,0 +1,213 @@
+package billing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"my-agents/usage"
+)
+
+// stripe fakes Stripe's meter API, deduplicating events by identifier and
+// summing them per event name and customer.
+type stripe struct {
+	mu     sync.Mutex
+	events map[string]url.Values // by identifier
+	posts  int
+	fail   bool
+}
+
+func (s *stripe) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer sk_test" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/billing/meter_events":
+		s.posts++
+		if s.fail {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"no such customer"}}`))
+			return
+		}
+		r.ParseForm()
+		s.events[r.PostForm.Get("identifier")] = r.PostForm
+		w.Write([]byte(`{}`))
+	case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/v1/billing/meters/"):
+		meter := strings.Split(r.URL.Path, "/")[4]
+		total := 0
+		for _, ev := range s.events {
+			if ev.Get("event_name") == meter && ev.Get("payload[stripe_customer_id]") == r.URL.Query().Get("customer") {
+				v, _ := strconv.Atoi(ev.Get("payload[value]"))
+				total += v
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]any{"data": []map[string]any{{"aggregated_value": total}}})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// newReporter returns a reporter on a ledger holding records, pushing to a
+// fake Stripe. Meter IDs equal the event names so the fake can sum them.
+func newReporter(t *testing.T, records ...usage.Record) (*Reporter, *stripe) {
+	t.Helper()
+	fake := &stripe{events: make(map[string]url.Values)}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	t.Setenv("STRIPE_API_KEY", "sk_test")
+
+	dir := t.TempDir()
+	ledger, err := usage.OpenLedger(filepath.Join(dir, "usage"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range records {
+		if err := ledger.Append(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	r, err := New(Config{
+		URL:         srv.URL,
+		Customers:   map[string]string{"acme": "cus_acme", "globex": "cus_globex"},
+		TokensEvent: "tokens_ev",
+		RunsEvent:   "runs_ev",
+		TokensMeter: "tokens_ev",
+		RunsMeter:   "runs_ev",
+		StatePath:   filepath.Join(dir, "billing.json"),
+	}, ledger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r, fake
+}
+
+func call(at time.Time, tenant, run string, tokens int) usage.Record {
+	return usage.Record{Time: at, Tenant: tenant, RunID: run, Agent: "writer", Provider: "openai", PromptTokens: tokens}
+}
+
+func TestSync(t *testing.T) {
+	hour := time.Now().UTC().Truncate(time.Hour)
+	r, fake := newReporter(t,
+		call(hour.Add(-3*time.Hour+time.Minute), "acme", "r1", 100),
+		call(hour.Add(-3*time.Hour+2*time.Minute), "acme", "r1", 50),
+		call(hour.Add(-2*time.Hour), "acme", "r2", 10),
+		call(hour.Add(-2*time.Hour), "globex", "r3", 20),
+		call(hour.Add(-2*time.Hour), "initech", "r4", 30),
+		call(hour.Add(time.Minute), "acme", "r5", 999), // hour still open
+	)
+	r.now = func() time.Time { return hour.Add(10 * time.Minute) }
+
+	res, err := r.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// acme has tokens and runs in two hours, globex in one
+	if res.Sent != 6 || res.Skipped != 0 {
+		t.Errorf("result = %+v, want 6 sent", res)
+	}
+	if res.Unmapped["initech"] != 30 {
+		t.Errorf("unmapped = %v, want initech's tokens", res.Unmapped)
+	}
+	id := "tokens_ev-cus_acme-" + strconv.FormatInt(hour.Add(-3*time.Hour).Unix(), 10)
+	if ev := fake.events[id]; ev.Get("payload[value]") != "150" {
+		t.Errorf("event %s = %v, want the hour's 150 tokens", id, ev)
+	}
+
+	// A second push, even by a fresh reporter on the same state, resends
+	// nothing
+	r2, err := New(r.cfg, r.ledger)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r2.stripe, r2.now = r.stripe, r.now
+	res, err = r2.Sync(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Sent != 0 || res.Skipped != 6 || fake.posts != 6 {
+		t.Errorf("second sync = %+v after %d posts, want all skipped", res, fake.posts)
+	}
+}
+
+func TestSyncError(t *testing.T) {
+	hour := time.Now().UTC().Truncate(time.Hour)
+	r, fake := newReporter(t, call(hour.Add(-2*time.Hour), "acme", "r1", 10))
+	fake.fail = true
+	_, err := r.Sync(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "no such customer") {
+		t.Fatalf("Sync = %v, want Stripe's message", err)
+	}
+	if len(r.state.Sent) != 0 {
+		t.Error("failed event recorded as sent")
+	}
+}
+
+func TestByUser(t *testing.T) {
+	hour := time.Now().UTC().Truncate(time.Hour)
+	r, _ := newReporter(t)
+	r.cfg.CustomerBy = usage.ByUser
+	r.cfg.Customers = map[string]string{"ann": "cus_ann"}
+	windows, unmapped := r.windows([]usage.Record{
+		{Time: hour, Tenant: "acme", User: "ann", PromptTokens: 5},
+		{Time: hour, Tenant: "acme", User: "bob", PromptTokens: 7},
+	})
+	if len(windows) != 1 || windows[0].Customer != "cus_ann" || windows[0].Tokens != 5 {
+		t.Errorf("windows = %+v", windows)
+	}
+	if unmapped["bob"] != 7 {
+		t.Errorf("unmapped = %v", unmapped)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	hour := time.Now().UTC().Truncate(time.Hour)
+	r, fake := newReporter(t,
+		call(hour.Add(-3*time.Hour), "acme", "r1", 100),
+		call(hour.Add(-2*time.Hour), "globex", "r2", 20),
+	)
+	r.now = func() time.Time { return hour.Add(10 * time.Minute) }
+	if _, err := r.Sync(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	// Stripe lost one of globex's events
+	delete(fake.events, "tokens_ev-cus_globex-"+strconv.FormatInt(hour.Add(-2*time.Hour).Unix(), 10))
+
+	got, err := r.Reconcile(context.Background(), hour.Add(-24*time.Hour), hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 {
+		t.Fatalf("got %d discrepancies, want tokens and runs for 2 customers", len(got))
+	}
+	for _, d := range got {
+		wantOK := d.Customer != "cus_globex" || d.Metric != MetricTokens
+		if d.OK() != wantOK {
+			t.Errorf("%+v: OK = %v, want %v", d, d.OK(), wantOK)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	t.Setenv("STRIPE_API_KEY", "")
+	if _, err := New(Config{}, nil); err == nil || !strings.Contains(err.Error(), "STRIPE_API_KEY") {
+		t.Errorf("New without a key = %v", err)
+	}
+	t.Setenv("STRIPE_API_KEY", "sk_test")
+	for _, cfg := range []Config{{Lag: "soon"}, {CustomerBy: "workflow"}} {
+		cfg.StatePath = filepath.Join(t.TempDir(), "billing.json")
+		if _, err := New(cfg, nil); err == nil {
+			t.Errorf("New(%+v) accepted", cfg)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package billing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultStripeURL is Stripe's API base URL.
+const DefaultStripeURL = "https://api.stripe.com"
+
+// Stripe is a minimal client for Stripe Billing meter events.
+type Stripe struct {
+	URL    string
+	APIKey string
+	Client *http.Client
+}
+
+// MeterEvent is one usage report to a billing meter.
+type MeterEvent struct {
+	EventName  string
+	Customer   string // Stripe customer ID
+	Value      int
+	Timestamp  time.Time
+	Identifier string // Stripe deduplicates events with the same identifier
+}
+
+// SendMeterEvent reports ev. Resending an event with the same identifier is
+// a no-op on Stripe's side.
+func (s *Stripe) SendMeterEvent(ctx context.Context, ev MeterEvent) error {
+	form := url.Values{
+		"event_name":                  {ev.EventName},
+		"identifier":                  {ev.Identifier},
+		"timestamp":                   {strconv.FormatInt(ev.Timestamp.Unix(), 10)},
+		"payload[stripe_customer_id]": {ev.Customer},
+		"payload[value]":              {strconv.Itoa(ev.Value)},
+	}
+	_, err := s.do(ctx, http.MethodPost, "/v1/billing/meter_events", form, ev.Identifier)
+	return err
+}
+
+// MeterTotal returns the aggregated value Stripe holds for customer on a
+// meter between start and end (hour-aligned).
+func (s *Stripe) MeterTotal(ctx context.Context, meterID, customer string, start, end time.Time) (float64, error) {
+	q := url.Values{
+		"customer":   {customer},
+		"start_time": {strconv.FormatInt(start.Unix(), 10)},
+		"end_time":   {strconv.FormatInt(end.Unix(), 10)},
+	}
+	body, err := s.do(ctx, http.MethodGet, "/v1/billing/meters/"+url.PathEscape(meterID)+"/event_summaries?"+q.Encode(), nil, "")
+	if err != nil {
+		return 0, err
+	}
+	var out struct {
+		Data []struct {
+			AggregatedValue float64 `json:"aggregated_value"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil {
+		return 0, fmt.Errorf("failed to decode meter summaries: %w", err)
+	}
+	total := 0.0
+	for _, d := range out.Data {
+		total += d.AggregatedValue
+	}
+	return total, nil
+}
+
+func (s *Stripe) do(ctx context.Context, method, path string, form url.Values, idempotencyKey string) ([]byte, error) {
+	base := s.URL
+	if base == "" {
+		base = DefaultStripeURL
+	}
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(base, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if idempotencyKey != "" {
+		req.Header.Set("Idempotency-Key", idempotencyKey)
+	}
+	client := s.Client
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(data, &apiErr) == nil && apiErr.Error.Message != "" {
+			return nil, fmt.Errorf("stripe %s %s: %s: %s", method, path, resp.Status, apiErr.Error.Message)
+		}
+		return nil, fmt.Errorf("stripe %s %s: %s", method, path, resp.Status)
+	}
+	return data, nil
+}
@@ -0,0 +1,71 @@
+package billing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSendMeterEvent(t *testing.T) {
+	var got *http.Request
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		got = r
+	}))
+	defer srv.Close()
+
+	s := &Stripe{URL: srv.URL + "/", APIKey: "sk_test"}
+	at := time.Unix(1700000000, 0)
+	err := s.SendMeterEvent(context.Background(), MeterEvent{EventName: "tokens", Customer: "cus_1", Value: 42, Timestamp: at, Identifier: "id-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.URL.Path != "/v1/billing/meter_events" || got.Header.Get("Idempotency-Key") != "id-1" {
+		t.Errorf("request %s with Idempotency-Key %q", got.URL.Path, got.Header.Get("Idempotency-Key"))
+	}
+	if got.PostForm.Get("payload[value]") != "42" || got.PostForm.Get("timestamp") != "1700000000" || got.PostForm.Get("payload[stripe_customer_id]") != "cus_1" {
+		t.Errorf("form = %v", got.PostForm)
+	}
+}
+
+func TestMeterTotal(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/billing/meters/mtr_1/event_summaries" || r.URL.Query().Get("customer") != "cus_1" {
+			t.Errorf("request %s", r.URL)
+		}
+		w.Write([]byte(`{"data":[{"aggregated_value":1.5},{"aggregated_value":2}]}`))
+	}))
+	defer srv.Close()
+
+	s := &Stripe{URL: srv.URL, APIKey: "sk_test"}
+	total, err := s.MeterTotal(context.Background(), "mtr_1", "cus_1", time.Unix(0, 0), time.Unix(3600, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 3.5 {
+		t.Errorf("total = %v, want 3.5", total)
+	}
+}
+
+func TestStripeError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusPaymentRequired)
+		if r.Method == http.MethodPost {
+			w.Write([]byte(`{"error":{"message":"card declined"}}`))
+		}
+	}))
+	defer srv.Close()
+
+	s := &Stripe{URL: srv.URL, APIKey: "sk_test"}
+	err := s.SendMeterEvent(context.Background(), MeterEvent{Timestamp: time.Now()})
+	if err == nil || !strings.Contains(err.Error(), "card declined") {
+		t.Errorf("error = %v, want Stripe's message", err)
+	}
+	_, err = s.MeterTotal(context.Background(), "mtr_1", "cus_1", time.Now(), time.Now())
+	if err == nil || !strings.Contains(err.Error(), "402") {
+		t.Errorf("error = %v, want the status", err)
+	}
+}
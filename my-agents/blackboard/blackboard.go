@@ -0,0 +1,188 @@
+// Package blackboard implements blackboard orchestration: specialists watch
+// a shared workspace, contribute whenever their trigger conditions match,
+// and a controller decides when the solution is complete.
+package blackboard
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// DefaultMaxCycles bounds a run when Controller.MaxCycles is zero.
+const DefaultMaxCycles = 8
+
+// Entry is one value on the board.
+type Entry struct {
+	Key       string    `json:"key"`
+	Value     string    `json:"value"`
+	Author    string    `json:"author"`
+	Version   int       `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Board is the shared workspace.
+type Board struct {
+	mu      sync.RWMutex
+	run     string
+	entries map[string]Entry
+	cycle   int
+}
+
+// NewBoard creates the board of a run, seeded with values authored by
+// "input".
+func NewBoard(runID string, seed map[string]string) *Board {
+	b := &Board{run: runID, entries: make(map[string]Entry)}
+	for k, v := range seed {
+		b.Post("input", k, v)
+	}
+	return b
+}
+
+// RunID returns the run the board is for.
+func (b *Board) RunID() string { return b.run }
+
+// Post writes key, bumping its version.
+func (b *Board) Post(author, key, value string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e := b.entries[key]
+	b.entries[key] = Entry{Key: key, Value: value, Author: author, Version: e.Version + 1, UpdatedAt: time.Now()}
+}
+
+// Get returns the value of key.
+func (b *Board) Get(key string) (string, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	e, ok := b.entries[key]
+	return e.Value, ok
+}
+
+// Entry returns the full entry for key.
+func (b *Board) Entry(key string) (Entry, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	e, ok := b.entries[key]
+	return e, ok
+}
+
+// Snapshot returns every entry, sorted by key.
+func (b *Board) Snapshot() []Entry {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	out := make([]Entry, 0, len(b.entries))
+	for _, e := range b.entries {
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Key < out[j].Key })
+	return out
+}
+
+// Cycle returns the current cycle number, starting at 1.
+func (b *Board) Cycle() int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.cycle
+}
+
+// Specialist contributes to the board when triggered.
+type Specialist interface {
+	Name() string
+	Triggered(b *Board) bool
+	Contribute(ctx context.Context, b *Board) error
+}
+
+// Forgetter is a Specialist keeping per-run state, which Run has it drop
+// when the run's board is done with.
+type Forgetter interface {
+	Forget(runID string)
+}
+
+// Controller decides when the board holds a complete solution.
+type Controller interface {
+	Done(ctx context.Context, b *Board) (bool, error)
+}
+
+// Contribution records one specialist's turn for tracing.
+type Contribution struct {
+	Cycle      int           `json:"cycle"`
+	Specialist string        `json:"specialist"`
+	Error      string        `json:"error,omitempty"`
+	Duration   time.Duration `json:"duration"`
+}
+
+// Run alternates cycles of triggered specialists (run concurrently) with a
+// controller check. It stops when the controller is satisfied, no
+// specialist is triggered, or maxCycles is reached.
+func Run(ctx context.Context, b *Board, specialists []Specialist, ctrl Controller, maxCycles int) ([]Contribution, error) {
+	if maxCycles <= 0 {
+		maxCycles = DefaultMaxCycles
+	}
+	defer func() {
+		for _, s := range specialists {
+			if f, ok := s.(Forgetter); ok {
+				f.Forget(b.RunID())
+			}
+		}
+	}()
+	var log []Contribution
+	for cycle := 1; cycle <= maxCycles; cycle++ {
+		b.mu.Lock()
+		b.cycle = cycle
+		b.mu.Unlock()
+
+		done, err := ctrl.Done(ctx, b)
+		if err != nil {
+			return log, err
+		}
+		if done {
+			return log, nil
+		}
+
+		var ready []Specialist
+		for _, s := range specialists {
+			if s.Triggered(b) {
+				ready = append(ready, s)
+			}
+		}
+		if len(ready) == 0 {
+			return log, fmt.Errorf("blackboard: no specialist can contribute and the solution is incomplete")
+		}
+
+		results := make([]Contribution, len(ready))
+		var wg sync.WaitGroup
+		for i, s := range ready {
+			wg.Add(1)
+			go func(i int, s Specialist) {
+				defer wg.Done()
+				start := time.Now()
+				err := s.Contribute(ctx, b)
+				results[i] = Contribution{Cycle: cycle, Specialist: s.Name(), Duration: time.Since(start)}
+				if err != nil {
+					results[i].Error = err.Error()
+				}
+			}(i, s)
+		}
+		wg.Wait()
+		for _, c := range results {
+			ev := core.Logger().Debug().Int("cycle", c.Cycle).Str("specialist", c.Specialist).Dur("duration", c.Duration)
+			if c.Error != "" {
+				ev = ev.Str("error", c.Error)
+			}
+			ev.Msg("Blackboard contribution")
+		}
+		log = append(log, results...)
+		if err := ctx.Err(); err != nil {
+			return log, err
+		}
+	}
+	done, err := ctrl.Done(ctx, b)
+	if err == nil && !done {
+		err = fmt.Errorf("blackboard: solution incomplete after %d cycles", maxCycles)
+	}
+	return log, err
+}
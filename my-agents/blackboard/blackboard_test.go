@@ -0,0 +1,86 @@
+package blackboard
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestBoard(t *testing.T) {
+	b := NewBoard("run-1", map[string]string{"input": "question"})
+	b.Post("researcher", "facts", "a")
+	b.Post("researcher", "facts", "b")
+	e, ok := b.Entry("facts")
+	if !ok || e.Value != "b" || e.Version != 2 || e.Author != "researcher" {
+		t.Errorf("Entry = %+v, %v", e, ok)
+	}
+	var keys []string
+	for _, e := range b.Snapshot() {
+		keys = append(keys, e.Key)
+	}
+	if strings.Join(keys, ",") != "facts,input" {
+		t.Errorf("Snapshot keys = %v", keys)
+	}
+}
+
+// step posts produces from requires once, and counts its turns.
+type step struct {
+	name, requires, produces string
+	turns                    int
+}
+
+func (s *step) Name() string { return s.name }
+
+func (s *step) Triggered(b *Board) bool {
+	_, have := b.Get(s.requires)
+	_, done := b.Get(s.produces)
+	return have && !done
+}
+
+func (s *step) Contribute(ctx context.Context, b *Board) error {
+	s.turns++
+	v, _ := b.Get(s.requires)
+	b.Post(s.name, s.produces, v+" → "+s.produces)
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	b := NewBoard("run-1", map[string]string{"input": "q"})
+	research := &step{name: "researcher", requires: "input", produces: "facts"}
+	write := &step{name: "writer", requires: "facts", produces: "answer"}
+	log, err := Run(context.Background(), b, []Specialist{write, research}, &KeyController{SolutionKey: "answer"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answer, _ := b.Get("answer"); answer != "q → facts → answer" {
+		t.Errorf("answer = %q", answer)
+	}
+	if len(log) != 2 || log[0].Specialist != "researcher" || log[1].Cycle != 2 {
+		t.Errorf("log = %+v", log)
+	}
+}
+
+func TestRunStuck(t *testing.T) {
+	b := NewBoard("run-1", nil)
+	research := &step{name: "researcher", requires: "input", produces: "facts"}
+	if _, err := Run(context.Background(), b, []Specialist{research}, &KeyController{SolutionKey: "answer"}, 0); err == nil {
+		t.Error("Run succeeded with no specialist able to contribute")
+	}
+}
+
+// reviser revises the answer every cycle and never finishes.
+type reviser struct{}
+
+func (reviser) Name() string            { return "reviser" }
+func (reviser) Triggered(b *Board) bool { return true }
+func (reviser) Contribute(ctx context.Context, b *Board) error {
+	b.Post("reviser", "draft", "again")
+	return nil
+}
+
+func TestRunMaxCycles(t *testing.T) {
+	log, err := Run(context.Background(), NewBoard("run-1", nil), []Specialist{reviser{}}, &KeyController{SolutionKey: "answer"}, 3)
+	if err == nil || len(log) != 3 {
+		t.Errorf("Run = %d contributions, %v, want an error after 3", len(log), err)
+	}
+}
@@ -0,0 +1,141 @@
+package blackboard
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Config is the [blackboard] section of agentflow.toml:
+//
+//	[blackboard]
+//	solution_key = "answer"
+//	[[blackboard.specialists]]
+//	name = "researcher"
+//	requires = ["input"]
+//	produces = "facts"
+//	prompt = "List the key facts needed to answer the request."
+type Config struct {
+	SolutionKey string `toml:"solution_key"` // default "answer"
+	MaxCycles   int    `toml:"max_cycles"`
+	Next        string `toml:"next"` // agent routed to afterwards (default "formatter")
+	// Judge names a provider that must accept the solution before the run
+	// ends; empty accepts it as soon as it is posted.
+	Judge       string             `toml:"judge"`
+	Specialists []SpecialistConfig `toml:"specialists"`
+}
+
+// SpecialistConfig declares an LLM specialist. It triggers when every
+// Requires key is on the board and Produces is missing or older than one of
+// its requirements (so it revises work others have updated).
+type SpecialistConfig struct {
+	Name     string   `toml:"name"`
+	Provider string   `toml:"provider"` // [providers.<name>]; empty uses the default
+	Prompt   string   `toml:"prompt"`
+	Requires []string `toml:"requires"`
+	Produces string   `toml:"produces"`
+}
+
+// LLMSpecialist is a specialist backed by a model.
+type LLMSpecialist struct {
+	cfg SpecialistConfig
+	llm core.ModelProvider
+	mu  sync.Mutex
+	// seen tracks the requirement versions last contributed from, by run
+	// ID and key, as runs share the specialist.
+	seen map[string]map[string]int
+}
+
+// NewLLMSpecialist creates a specialist from its config.
+func NewLLMSpecialist(cfg SpecialistConfig, llm core.ModelProvider) (*LLMSpecialist, error) {
+	if cfg.Name == "" || cfg.Produces == "" {
+		return nil, fmt.Errorf("blackboard: specialists need a name and a produces key")
+	}
+	return &LLMSpecialist{cfg: cfg, llm: llm, seen: make(map[string]map[string]int)}, nil
+}
+
+func (s *LLMSpecialist) Name() string { return s.cfg.Name }
+
+// Forget drops what the specialist saw of a run's board.
+func (s *LLMSpecialist) Forget(runID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.seen, runID)
+}
+
+func (s *LLMSpecialist) Triggered(b *Board) bool {
+	s.mu.Lock()
+	seen := s.seen[b.RunID()]
+	s.mu.Unlock()
+	changed := false
+	for _, key := range s.cfg.Requires {
+		e, ok := b.Entry(key)
+		if !ok {
+			return false
+		}
+		if e.Version != seen[key] && e.Author != s.cfg.Name {
+			changed = true
+		}
+	}
+	if _, ok := b.Get(s.cfg.Produces); !ok {
+		return true
+	}
+	return changed
+}
+
+func (s *LLMSpecialist) Contribute(ctx context.Context, b *Board) error {
+	var user strings.Builder
+	user.WriteString("Shared workspace:\n")
+	for _, e := range b.Snapshot() {
+		fmt.Fprintf(&user, "\n[%s] (by %s)\n%s\n", e.Key, e.Author, e.Value)
+	}
+	fmt.Fprintf(&user, "\nWrite the %q entry.", s.cfg.Produces)
+	seen := make(map[string]int, len(s.cfg.Requires))
+	for _, key := range s.cfg.Requires {
+		if e, ok := b.Entry(key); ok {
+			seen[key] = e.Version
+		}
+	}
+	s.mu.Lock()
+	s.seen[b.RunID()] = seen
+	s.mu.Unlock()
+
+	resp, err := s.llm.Call(ctx, core.Prompt{
+		System: s.cfg.Prompt + " You are the " + s.cfg.Name + " specialist collaborating on a shared workspace. Reply with your entry only.",
+		User:   user.String(),
+	})
+	if err != nil {
+		return err
+	}
+	b.Post(s.cfg.Name, s.cfg.Produces, strings.TrimSpace(resp.Content))
+	return nil
+}
+
+// KeyController is satisfied once the solution key is on the board and no
+// specialist is still revising it. Judge, when set, must also accept it.
+type KeyController struct {
+	SolutionKey string
+	Judge       core.ModelProvider
+}
+
+func (c *KeyController) Done(ctx context.Context, b *Board) (bool, error) {
+	solution, ok := b.Get(c.SolutionKey)
+	if !ok {
+		return false, nil
+	}
+	if c.Judge == nil {
+		return true, nil
+	}
+	input, _ := b.Get("input")
+	resp, err := c.Judge.Call(ctx, core.Prompt{
+		System: "You decide whether a proposed solution fully answers a request. Reply YES or NO.",
+		User:   fmt.Sprintf("Request:\n%s\n\nProposed solution:\n%s", input, solution),
+	})
+	if err != nil {
+		return false, err
+	}
+	return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(resp.Content)), "YES"), nil
+}
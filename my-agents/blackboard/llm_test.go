@@ -0,0 +1,86 @@
+package blackboard
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// echo replies with reply, recording the user prompts it was sent.
+type echo struct {
+	core.ModelProvider
+	reply string
+	users []string
+}
+
+func (e *echo) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	e.users = append(e.users, prompt.User)
+	return core.Response{Content: e.reply}, nil
+}
+
+func TestNewLLMSpecialist(t *testing.T) {
+	if _, err := NewLLMSpecialist(SpecialistConfig{Name: "researcher"}, nil); err == nil {
+		t.Error("NewLLMSpecialist accepted a specialist producing nothing")
+	}
+}
+
+func TestLLMSpecialistTriggers(t *testing.T) {
+	llm := &echo{reply: " the facts "}
+	s, err := NewLLMSpecialist(SpecialistConfig{Name: "researcher", Requires: []string{"input"}, Produces: "facts"}, llm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := NewBoard("run-1", nil)
+	if s.Triggered(b) {
+		t.Fatal("triggered without its requirement")
+	}
+	b.Post("input", "input", "question")
+	if !s.Triggered(b) {
+		t.Fatal("not triggered with its requirement and no output")
+	}
+	if err := s.Contribute(context.Background(), b); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := b.Get("facts"); got != "the facts" {
+		t.Errorf("facts = %q", got)
+	}
+	if !strings.Contains(llm.users[0], "[input] (by input)\nquestion") {
+		t.Errorf("prompt = %q", llm.users[0])
+	}
+	if s.Triggered(b) {
+		t.Error("triggered again with nothing changed")
+	}
+	b.Post("input", "input", "revised question")
+	if !s.Triggered(b) {
+		t.Error("not triggered by a revised requirement")
+	}
+
+	// Runs are tracked apart, and forgotten
+	other := NewBoard("run-2", map[string]string{"input": "q", "facts": "f"})
+	if !s.Triggered(other) {
+		t.Error("another run's board inherited what this one saw")
+	}
+	s.Forget("run-1")
+	if len(s.seen) != 0 {
+		t.Errorf("seen after Forget = %v", s.seen)
+	}
+}
+
+func TestKeyControllerJudge(t *testing.T) {
+	b := NewBoard("run-1", map[string]string{"input": "q"})
+	judge := &echo{reply: "NO, it misses the totals"}
+	c := &KeyController{SolutionKey: "answer", Judge: judge}
+	if done, _ := c.Done(context.Background(), b); done {
+		t.Error("done without a solution")
+	}
+	b.Post("writer", "answer", "draft")
+	if done, _ := c.Done(context.Background(), b); done {
+		t.Error("done although the judge refused")
+	}
+	judge.reply = "yes"
+	if done, err := c.Done(context.Background(), b); !done || err != nil {
+		t.Errorf("Done = %v, %v", done, err)
+	}
+}
@@ -0,0 +1,203 @@
+// Package bus lets agents send targeted messages — questions, partial
+// results, hints — to specific other agents within a run, alongside the
+// linear route.
+package bus
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+// Message kinds.
+const (
+	KindQuestion = "question"
+	KindPartial  = "partial"
+	KindInfo     = "info"
+)
+
+// Message is one agent-to-agent message.
+type Message struct {
+	ID      string         `json:"id"`
+	RunID   string         `json:"run_id"`
+	From    string         `json:"from"`
+	To      string         `json:"to"`
+	Kind    string         `json:"kind"`
+	Body    string         `json:"body"`
+	Data    map[string]any `json:"data,omitempty"`
+	ReplyTo string         `json:"reply_to,omitempty"`
+	SentAt  time.Time      `json:"sent_at"`
+
+	DeliveredAt time.Time `json:"delivered_at,omitempty"`
+}
+
+// Bus holds per-run inboxes. Messages stay queued until the recipient reads
+// them or the run ends.
+type Bus struct {
+	mu      sync.Mutex
+	seq     int
+	inboxes map[string]map[string][]Message // run → agent → queued
+	trace   map[string][]Message            // run → every message sent
+	notify  map[string]chan struct{}        // run → closed on each send
+}
+
+// New creates an empty bus.
+func New() *Bus {
+	return &Bus{
+		inboxes: make(map[string]map[string][]Message),
+		trace:   make(map[string][]Message),
+		notify:  make(map[string]chan struct{}),
+	}
+}
+
+// Send queues msg for msg.To. ID and Kind are filled when empty.
+func (b *Bus) Send(msg Message) (Message, error) {
+	if msg.RunID == "" || msg.To == "" {
+		return msg, fmt.Errorf("bus: message needs a run and a recipient")
+	}
+	if msg.Kind == "" {
+		msg.Kind = KindInfo
+	}
+	msg.SentAt = time.Now()
+
+	b.mu.Lock()
+	if msg.ID == "" {
+		b.seq++
+		msg.ID = fmt.Sprintf("msg-%d", b.seq)
+	}
+	if b.inboxes[msg.RunID] == nil {
+		b.inboxes[msg.RunID] = make(map[string][]Message)
+	}
+	b.inboxes[msg.RunID][msg.To] = append(b.inboxes[msg.RunID][msg.To], msg)
+	b.trace[msg.RunID] = append(b.trace[msg.RunID], msg)
+	if ch, ok := b.notify[msg.RunID]; ok {
+		close(ch)
+		delete(b.notify, msg.RunID)
+	}
+	b.mu.Unlock()
+
+	core.Logger().Info().Str("run_id", msg.RunID).Str("message_id", msg.ID).
+		Str("from", msg.From).Str("to", msg.To).Str("kind", msg.Kind).Msg("Agent message sent")
+	return msg, nil
+}
+
+// Inbox returns and removes the messages queued for agent in run.
+func (b *Bus) Inbox(runID, agent string) []Message {
+	if b == nil || runID == "" {
+		return nil
+	}
+	b.mu.Lock()
+	msgs := b.inboxes[runID][agent]
+	delete(b.inboxes[runID], agent)
+	now := time.Now()
+	for i := range msgs {
+		msgs[i].DeliveredAt = now
+		b.markDelivered(runID, msgs[i].ID, now)
+	}
+	b.mu.Unlock()
+
+	for _, m := range msgs {
+		core.Logger().Info().Str("run_id", runID).Str("message_id", m.ID).
+			Str("from", m.From).Str("to", m.To).Dur("latency", now.Sub(m.SentAt)).Msg("Agent message delivered")
+	}
+	return msgs
+}
+
+// Wait blocks until a message for agent arrives in run or ctx is done. It
+// suits agents running concurrently; in a linear route the recipient runs
+// after the sender and should just read its Inbox.
+func (b *Bus) Wait(ctx context.Context, runID, agent string) ([]Message, error) {
+	for {
+		b.mu.Lock()
+		if len(b.inboxes[runID][agent]) > 0 {
+			b.mu.Unlock()
+			return b.Inbox(runID, agent), nil
+		}
+		ch, ok := b.notify[runID]
+		if !ok {
+			ch = make(chan struct{})
+			b.notify[runID] = ch
+		}
+		b.mu.Unlock()
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ch:
+		}
+	}
+}
+
+// Trace returns every message sent in run, with delivery times.
+func (b *Bus) Trace(runID string) []Message {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]Message(nil), b.trace[runID]...)
+}
+
+// Release drops a finished run's inboxes and trace, logging undelivered
+// messages.
+func (b *Bus) Release(runID string) {
+	b.mu.Lock()
+	inboxes := b.inboxes[runID]
+	delete(b.inboxes, runID)
+	delete(b.trace, runID)
+	if ch, ok := b.notify[runID]; ok {
+		close(ch)
+		delete(b.notify, runID)
+	}
+	b.mu.Unlock()
+
+	for agent, msgs := range inboxes {
+		for _, m := range msgs {
+			core.Logger().Warn().Str("run_id", runID).Str("message_id", m.ID).
+				Str("from", m.From).Str("to", agent).Msg("Agent message never delivered")
+		}
+	}
+}
+
+func (b *Bus) markDelivered(runID, id string, at time.Time) {
+	for i := range b.trace[runID] {
+		if b.trace[runID][i].ID == id {
+			b.trace[runID][i].DeliveredAt = at
+		}
+	}
+}
+
+// Register releases each run's messages once it finishes.
+func (b *Bus) Register(runner core.Runner) error {
+	return runner.RegisterCallback(core.HookAfterEventHandling, "bus-release", func(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+		runID, _ := args.Event.GetMetadataValue(history.RunIDKey)
+		if runID == "" {
+			return args.State, nil
+		}
+		done := args.Error != nil || args.State == nil
+		if !done {
+			route, _ := args.State.GetMeta(core.RouteMetadataKey)
+			done = route == ""
+		}
+		if done {
+			b.Release(runID)
+		}
+		return args.State, nil
+	})
+}
+
+// Format renders messages for inclusion in a prompt.
+func Format(msgs []Message) string {
+	if len(msgs) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\n\nMessages from other agents:\n")
+	for _, m := range msgs {
+		fmt.Fprintf(&sb, "- (%s from %s) %s\n", m.Kind, m.From, m.Body)
+	}
+	return sb.String()
+}
@@ -0,0 +1,108 @@
+package bus
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+func TestSendInbox(t *testing.T) {
+	b := New()
+	if _, err := b.Send(Message{To: "critic"}); err == nil {
+		t.Error("Send without a run succeeded")
+	}
+	msg, err := b.Send(Message{RunID: "run-1", From: "processor", To: "critic", Body: "check the totals"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.ID == "" || msg.Kind != KindInfo {
+		t.Errorf("sent %+v", msg)
+	}
+
+	if got := b.Inbox("run-2", "critic"); len(got) != 0 {
+		t.Errorf("another run's inbox = %+v", got)
+	}
+	got := b.Inbox("run-1", "critic")
+	if len(got) != 1 || got[0].Body != "check the totals" || got[0].DeliveredAt.IsZero() {
+		t.Fatalf("Inbox = %+v", got)
+	}
+	if again := b.Inbox("run-1", "critic"); len(again) != 0 {
+		t.Errorf("Inbox delivered %d messages twice", len(again))
+	}
+	if trace := b.Trace("run-1"); len(trace) != 1 || trace[0].DeliveredAt.IsZero() {
+		t.Errorf("Trace = %+v", trace)
+	}
+
+	b.Release("run-1")
+	if trace := b.Trace("run-1"); len(trace) != 0 {
+		t.Errorf("Trace after Release = %+v", trace)
+	}
+}
+
+func TestWait(t *testing.T) {
+	b := New()
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		b.Send(Message{RunID: "run-1", To: "other", Body: "not yours"})
+		b.Send(Message{RunID: "run-1", To: "critic", Body: "yours"})
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	got, err := b.Wait(ctx, "run-1", "critic")
+	if err != nil || len(got) != 1 || got[0].Body != "yours" {
+		t.Errorf("Wait = %+v, %v", got, err)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := b.Wait(ctx, "run-1", "critic"); err == nil {
+		t.Error("Wait returned with no message")
+	}
+}
+
+// registry captures the callback registered on it.
+type registry struct {
+	core.Runner
+	callback core.CallbackFunc
+}
+
+func (r *registry) RegisterCallback(hook core.HookPoint, name string, cb core.CallbackFunc) error {
+	r.callback = cb
+	return nil
+}
+
+func TestRegisterReleases(t *testing.T) {
+	b := New()
+	runner := &registry{}
+	if err := b.Register(runner); err != nil {
+		t.Fatal(err)
+	}
+	b.Send(Message{RunID: "run-1", To: "critic", Body: "x"})
+	event := core.NewEvent("processor", nil, map[string]string{history.RunIDKey: "run-1"})
+
+	routed := core.NewState()
+	routed.SetMeta(core.RouteMetadataKey, "critic")
+	runner.callback(context.Background(), core.CallbackArgs{Event: event, State: routed})
+	if len(b.Trace("run-1")) != 1 {
+		t.Fatal("released a run still routing")
+	}
+	runner.callback(context.Background(), core.CallbackArgs{Event: event, State: core.NewState()})
+	if len(b.Trace("run-1")) != 0 {
+		t.Error("kept a finished run")
+	}
+}
+
+func TestFormat(t *testing.T) {
+	if got := Format(nil); got != "" {
+		t.Errorf("Format(nil) = %q", got)
+	}
+	got := Format([]Message{{Kind: KindQuestion, From: "processor", Body: "which year?"}})
+	if !strings.Contains(got, "- (question from processor) which year?") {
+		t.Errorf("Format = %q", got)
+	}
+}
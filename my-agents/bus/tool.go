@@ -0,0 +1,49 @@
+package bus
+
+import (
+	"context"
+	"fmt"
+)
+
+type senderKey struct{}
+
+type sender struct {
+	runID, agent string
+}
+
+// WithSender records which run and agent is calling tools in ctx, so the
+// send_message tool knows who is sending.
+func WithSender(ctx context.Context, runID, agent string) context.Context {
+	return context.WithValue(ctx, senderKey{}, sender{runID: runID, agent: agent})
+}
+
+// Tool lets agents in a tool loop message other agents. Arguments: to,
+// body, and optionally kind (question, partial or info) and reply_to.
+type Tool struct {
+	Bus *Bus
+}
+
+func (t *Tool) Name() string { return "send_message" }
+
+func (t *Tool) Description() string {
+	return "Send a message to another agent in this run. Args: to (agent name), body, kind (question|partial|info)."
+}
+
+func (t *Tool) Call(ctx context.Context, args map[string]any) (any, error) {
+	from, ok := ctx.Value(senderKey{}).(sender)
+	if !ok {
+		return nil, fmt.Errorf("send_message is only available inside a run")
+	}
+	to, _ := args["to"].(string)
+	body, _ := args["body"].(string)
+	if to == "" || body == "" {
+		return nil, fmt.Errorf("to and body are required")
+	}
+	kind, _ := args["kind"].(string)
+	replyTo, _ := args["reply_to"].(string)
+	msg, err := t.Bus.Send(Message{RunID: from.runID, From: from.agent, To: to, Kind: kind, Body: body, ReplyTo: replyTo})
+	if err != nil {
+		return nil, err
+	}
+	return fmt.Sprintf("sent %s to %s", msg.ID, to), nil
+}
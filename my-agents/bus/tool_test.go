@@ -0,0 +1,25 @@
+package bus
+
+import (
+	"context"
+	"testing"
+)
+
+func TestTool(t *testing.T) {
+	b := New()
+	tool := &Tool{Bus: b}
+	if _, err := tool.Call(context.Background(), map[string]any{"to": "critic", "body": "x"}); err == nil {
+		t.Error("Call outside a run succeeded")
+	}
+	ctx := WithSender(context.Background(), "run-1", "processor")
+	if _, err := tool.Call(ctx, map[string]any{"to": "critic"}); err == nil {
+		t.Error("Call without a body succeeded")
+	}
+	if _, err := tool.Call(ctx, map[string]any{"to": "critic", "body": "which year?", "kind": KindQuestion}); err != nil {
+		t.Fatal(err)
+	}
+	got := b.Inbox("run-1", "critic")
+	if len(got) != 1 || got[0].From != "processor" || got[0].Kind != KindQuestion {
+		t.Errorf("Inbox = %+v", got)
+	}
+}
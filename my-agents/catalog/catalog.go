@@ -0,0 +1,99 @@
+// Package catalog describes the workflows a deployment serves: their entry
+// route, what they do, the metadata they accept and example requests with
+// the responses they produce. The admin API serves it for discovery and as
+// an OpenAPI document, so consumers can see how to call each workflow.
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+)
+
+// ErrNotFound is returned for routes no workflow is declared at.
+var ErrNotFound = errors.New("workflow not found")
+
+// Config is the [workflows] section of agentflow.toml, one table per entry
+// route:
+//
+//	[workflows.processor]
+//	description = "Answer a question: extract, enhance and format."
+//	metadata = { locale = "response language, e.g. de-DE" }
+//	[[workflows.processor.examples]]
+//	name = "explain"
+//	input = "Explain quantum computing in simple terms"
+//	output = "Quantum computers use qubits, which..."
+type Config map[string]Workflow
+
+// Workflow is a documented entry route.
+type Workflow struct {
+	Route       string `toml:"-" json:"route"`
+	Description string `toml:"description" json:"description,omitempty"`
+	// Metadata lists the metadata keys the workflow reads, with what each
+	// does.
+	Metadata map[string]string `toml:"metadata" json:"metadata,omitempty"`
+	Examples []Example         `toml:"examples" json:"examples"`
+	// Enabled is filled in when served: false while the workflow is
+	// disabled through the admin API.
+	Enabled *bool `toml:"-" json:"enabled,omitempty"`
+}
+
+// Example is a request to a workflow and the response it gives.
+type Example struct {
+	Name     string            `toml:"name" json:"name"`
+	Summary  string            `toml:"summary" json:"summary,omitempty"`
+	Input    string            `toml:"input" json:"input"`
+	Metadata map[string]string `toml:"metadata" json:"metadata,omitempty"`
+	Output   string            `toml:"output" json:"output,omitempty"`
+}
+
+// Catalog holds the declared workflows.
+type Catalog struct {
+	workflows []Workflow
+}
+
+// New checks cfg against the registered agents and builds the catalog.
+func New(cfg Config, agents []string) (*Catalog, error) {
+	c := &Catalog{}
+	for route, wf := range cfg {
+		if !slices.Contains(agents, route) {
+			return nil, fmt.Errorf("workflow %s: no agent is registered at that route", route)
+		}
+		seen := make(map[string]bool)
+		for i, ex := range wf.Examples {
+			if ex.Name == "" {
+				return nil, fmt.Errorf("workflow %s: example %d needs a name", route, i+1)
+			}
+			if seen[ex.Name] {
+				return nil, fmt.Errorf("workflow %s: duplicate example %q", route, ex.Name)
+			}
+			seen[ex.Name] = true
+			if ex.Input == "" {
+				return nil, fmt.Errorf("workflow %s: example %s needs an input", route, ex.Name)
+			}
+		}
+		wf.Route = route
+		if wf.Examples == nil {
+			wf.Examples = []Example{}
+		}
+		c.workflows = append(c.workflows, wf)
+	}
+	sort.Slice(c.workflows, func(i, j int) bool { return c.workflows[i].Route < c.workflows[j].Route })
+	return c, nil
+}
+
+// List returns the workflows sorted by route.
+func (c *Catalog) List() []Workflow {
+	return slices.Clone(c.workflows)
+}
+
+// Get returns the workflow at route.
+func (c *Catalog) Get(route string) (Workflow, error) {
+	for _, wf := range c.workflows {
+		if wf.Route == route {
+			return wf, nil
+		}
+	}
+	return Workflow{}, fmt.Errorf("%w: %s", ErrNotFound, route)
+}
@@ -0,0 +1,43 @@
+package catalog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	agents := []string{"processor", "triage"}
+	cfg := Config{
+		"triage":    {Description: "Triage a ticket", Examples: []Example{{Name: "refund", Input: "I want my money back"}}},
+		"processor": {},
+	}
+	c, err := New(cfg, agents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	list := c.List()
+	if len(list) != 2 || list[0].Route != "processor" || list[1].Route != "triage" {
+		t.Fatalf("workflows = %+v", list)
+	}
+	if list[0].Examples == nil {
+		t.Error("a workflow without examples lists them as null")
+	}
+	if wf, err := c.Get("triage"); err != nil || wf.Description != "Triage a ticket" {
+		t.Errorf("triage = %+v, %v", wf, err)
+	}
+	if _, err := c.Get("writer"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown route err = %v", err)
+	}
+
+	for want, cfg := range map[string]Config{
+		"no agent is registered": {"writer": {}},
+		"needs a name":           {"triage": {Examples: []Example{{Input: "x"}}}},
+		"duplicate example":      {"triage": {Examples: []Example{{Name: "a", Input: "x"}, {Name: "a", Input: "y"}}}},
+		"needs an input":         {"triage": {Examples: []Example{{Name: "a"}}}},
+	} {
+		if _, err := New(cfg, agents); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("%v: err = %v, want %q", cfg, err, want)
+		}
+	}
+}
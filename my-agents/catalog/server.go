@@ -0,0 +1,237 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+)
+
+// Handler serves the discovery endpoints, for mounting on the admin API
+// under "GET /admin/workflows", "GET /admin/workflows/{route}" and
+// "GET /admin/openapi.json":
+//
+//	GET /admin/workflows
+//	GET /admin/workflows/{route}
+//	GET /admin/openapi.json
+//
+// enabled reports whether new runs may enter a route; it may be nil.
+func (c *Catalog) Handler(enabled func(route string) bool) http.Handler {
+	withStatus := func(wf Workflow) Workflow {
+		if enabled != nil {
+			on := enabled(wf.Route)
+			wf.Enabled = &on
+		}
+		return wf
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/workflows", func(w http.ResponseWriter, r *http.Request) {
+		list := c.List()
+		for i := range list {
+			list[i] = withStatus(list[i])
+		}
+		writeJSON(w, http.StatusOK, list)
+	})
+	mux.HandleFunc("GET /admin/workflows/{route}", func(w http.ResponseWriter, r *http.Request) {
+		wf, err := c.Get(r.PathValue("route"))
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, withStatus(wf))
+	})
+	mux.HandleFunc("GET /admin/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, c.OpenAPI())
+	})
+	return mux
+}
+
+// OpenAPI describes the discovery endpoints and POST /events, which runs a
+// workflow, with the request each workflow takes and the response it gives
+// as schemas named after its route, carrying the declared examples.
+func (c *Catalog) OpenAPI() map[string]any {
+	schemas := map[string]any{
+		"Example": object(map[string]any{
+			"name":     str(""),
+			"summary":  str(""),
+			"input":    str(""),
+			"metadata": stringMap(""),
+			"output":   str(""),
+		}, "name", "input"),
+		"Workflow": object(map[string]any{
+			"route":       str("entry route, sent as the \"route\" metadata"),
+			"description": str(""),
+			"metadata":    stringMap("metadata keys the workflow reads, with what each does"),
+			"examples":    map[string]any{"type": "array", "items": ref("Example")},
+			"enabled":     map[string]any{"type": "boolean", "description": "false while disabled through the admin API"},
+		}, "route", "examples"),
+		"Error": object(map[string]any{"error": str("")}, "error"),
+	}
+	var requestRefs, responseRefs []any
+	for _, wf := range c.workflows {
+		metadata := map[string]any{}
+		keys := make([]string, 0, len(wf.Metadata))
+		for k := range wf.Metadata {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			metadata[k] = str(wf.Metadata[k])
+		}
+		var requests, responses []any
+		for _, ex := range wf.Examples {
+			request := map[string]any{"input": ex.Input, "route": wf.Route}
+			if len(ex.Metadata) > 0 {
+				request["metadata"] = ex.Metadata
+			}
+			requests = append(requests, request)
+			if ex.Output != "" {
+				responses = append(responses, map[string]any{"status": "completed", "final_response": ex.Output})
+			}
+		}
+		req := object(map[string]any{
+			"input":      str("the user's request"),
+			"route":      map[string]any{"const": wf.Route},
+			"session_id": str("groups the run with the session's earlier ones"),
+			"metadata": map[string]any{
+				"type":                 "object",
+				"properties":           metadata,
+				"additionalProperties": map[string]any{"type": "string"},
+			},
+		}, "input", "route")
+		req["description"] = wf.Description
+		resp := object(map[string]any{
+			"id":             str("the run's event ID"),
+			"session_id":     str(""),
+			"status":         str("queued, running, completed, failed or awaiting_input"),
+			"final_response": str("the last agent's message, once completed"),
+			"question":       str("set when the run awaits an answer"),
+			"error":          str("set when the run failed"),
+		}, "id", "status")
+		if requests != nil {
+			req["examples"] = requests
+		}
+		if responses != nil {
+			resp["examples"] = responses
+		}
+		schemas[wf.Route+".request"] = req
+		schemas[wf.Route+".response"] = resp
+		requestRefs = append(requestRefs, ref(wf.Route+".request"))
+		responseRefs = append(responseRefs, ref(wf.Route+".response"))
+	}
+	request := map[string]any{"oneOf": requestRefs}
+	response := map[string]any{"oneOf": responseRefs}
+	if len(c.workflows) == 0 {
+		// Nothing declared to describe
+		request, response = map[string]any{"type": "object"}, map[string]any{"type": "object"}
+	}
+
+	routeParam := map[string]any{"name": "route", "in": "path", "required": true, "schema": str("")}
+	errorResponse := func(desc string) map[string]any {
+		return map[string]any{"description": desc, "content": jsonContent(ref("Error"))}
+	}
+	return map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":   "agentflow workflows",
+			"version": "1",
+			"description": "A workflow runs for each POST /events on the API (the [http] address) naming it as \"route\"; " +
+				"the <route>.request and <route>.response schemas show what each expects and returns. The /admin paths are on the admin API.",
+		},
+		"security": []any{map[string]any{"bearer": []string{}}},
+		"paths": map[string]any{
+			"/events": map[string]any{"post": map[string]any{
+				"summary": "Run a workflow",
+				"parameters": []any{map[string]any{
+					"name": "wait", "in": "query", "schema": map[string]any{"type": "boolean"},
+					"description": "answer when the run ends or asks a question, rather than once it is queued",
+				}},
+				"requestBody": map[string]any{"required": true, "content": jsonContent(request)},
+				"responses": map[string]any{
+					"200": map[string]any{"description": "the run, ended or awaiting an answer (wait=true)", "content": jsonContent(response)},
+					"202": map[string]any{"description": "the run, queued or still running; poll the Location", "content": jsonContent(response)},
+					"400": errorResponse("no input, or the route is not open to the API"),
+					"403": errorResponse("the workflow is disabled"),
+					"429": errorResponse("the caller's quota is exceeded"),
+					"503": errorResponse("the server is draining"),
+				},
+			}},
+			"/events/{id}": map[string]any{"get": map[string]any{
+				"summary":    "Read a run's status and final response",
+				"parameters": []any{map[string]any{"name": "id", "in": "path", "required": true, "schema": str("")}},
+				"responses": map[string]any{
+					"200": map[string]any{"description": "the run", "content": jsonContent(response)},
+					"404": errorResponse("no such run"),
+				},
+			}},
+			"/admin/workflows": map[string]any{"get": map[string]any{
+				"summary":   "List the declared workflows with their examples",
+				"responses": map[string]any{"200": map[string]any{"description": "workflows by route", "content": jsonContent(map[string]any{"type": "array", "items": ref("Workflow")})}},
+			}},
+			"/admin/workflows/{route}": map[string]any{"get": map[string]any{
+				"summary":    "Describe one workflow",
+				"parameters": []any{routeParam},
+				"responses": map[string]any{
+					"200": map[string]any{"description": "the workflow", "content": jsonContent(ref("Workflow"))},
+					"404": errorResponse("no workflow is declared at route"),
+				},
+			}},
+			"/admin/workflows/{route}/enable": map[string]any{"post": map[string]any{
+				"summary":    "Let new runs enter the workflow",
+				"parameters": []any{routeParam},
+				"responses":  map[string]any{"200": map[string]any{"description": "the admin status"}},
+			}},
+			"/admin/workflows/{route}/disable": map[string]any{"post": map[string]any{
+				"summary":    "Refuse new runs at the workflow; runs in flight finish",
+				"parameters": []any{routeParam},
+				"responses":  map[string]any{"200": map[string]any{"description": "the admin status"}},
+			}},
+			"/admin/openapi.json": map[string]any{"get": map[string]any{
+				"summary":   "This document",
+				"responses": map[string]any{"200": map[string]any{"description": "OpenAPI 3.1 document"}},
+			}},
+		},
+		"components": map[string]any{
+			"schemas":         schemas,
+			"securitySchemes": map[string]any{"bearer": map[string]any{"type": "http", "scheme": "bearer"}},
+		},
+	}
+}
+
+func object(props map[string]any, required ...string) map[string]any {
+	return map[string]any{"type": "object", "properties": props, "required": required}
+}
+
+func str(desc string) map[string]any {
+	s := map[string]any{"type": "string"}
+	if desc != "" {
+		s["description"] = desc
+	}
+	return s
+}
+
+func stringMap(desc string) map[string]any {
+	s := map[string]any{"type": "object", "additionalProperties": map[string]any{"type": "string"}}
+	if desc != "" {
+		s["description"] = desc
+	}
+	return s
+}
+
+func ref(name string) map[string]any {
+	return map[string]any{"$ref": "#/components/schemas/" + name}
+}
+
+func jsonContent(schema any) map[string]any {
+	return map[string]any{"application/json": map[string]any{"schema": schema}}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
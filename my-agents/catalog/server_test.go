@@ -0,0 +1,103 @@
+package catalog
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	c, err := New(Config{"processor": {}, "triage": {}}, []string{"processor", "triage"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := c.Handler(func(route string) bool { return route != "triage" })
+	get := func(target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		return w
+	}
+
+	var list []Workflow
+	json.NewDecoder(get("/admin/workflows").Body).Decode(&list)
+	if len(list) != 2 || list[0].Enabled == nil || !*list[0].Enabled || list[1].Enabled == nil || *list[1].Enabled {
+		t.Errorf("workflows = %+v", list)
+	}
+	var wf Workflow
+	json.NewDecoder(get("/admin/workflows/triage").Body).Decode(&wf)
+	if wf.Route != "triage" || wf.Enabled == nil || *wf.Enabled {
+		t.Errorf("triage = %+v", wf)
+	}
+	if w := get("/admin/workflows/writer"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown route: status %d", w.Code)
+	}
+	if w := get("/admin/openapi.json"); w.Code != http.StatusOK || !json.Valid(w.Body.Bytes()) {
+		t.Errorf("openapi: %d %s", w.Code, w.Body)
+	}
+
+	// Without a status source workflows don't claim to be enabled
+	w := httptest.NewRecorder()
+	c.Handler(nil).ServeHTTP(w, httptest.NewRequest("GET", "/admin/workflows/triage", nil))
+	wf = Workflow{}
+	json.NewDecoder(w.Body).Decode(&wf)
+	if wf.Enabled != nil {
+		t.Errorf("triage = %+v", wf)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	c, err := New(Config{"triage": {
+		Description: "Triage a ticket",
+		Metadata:    map[string]string{"locale": "reply language"},
+		Examples: []Example{
+			{Name: "refund", Input: "I want my money back", Metadata: map[string]string{"locale": "de-DE"}, Output: "P3 billing"},
+			{Name: "vague", Input: "help"},
+		},
+	}}, []string{"triage"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Round-trip through JSON, as served
+	data, err := json.Marshal(c.OpenAPI())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		OpenAPI    string `json:"openapi"`
+		Paths      map[string]map[string]any
+		Components struct {
+			Schemas map[string]struct {
+				Description string
+				Properties  map[string]json.RawMessage
+				Examples    []map[string]any
+			}
+		}
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.OpenAPI != "3.1.0" || doc.Paths["/events"]["post"] == nil || doc.Paths["/admin/workflows/{route}"]["get"] == nil {
+		t.Errorf("document = %s", data)
+	}
+	req, resp := doc.Components.Schemas["triage.request"], doc.Components.Schemas["triage.response"]
+	if req.Description != "Triage a ticket" || len(req.Examples) != 2 || req.Examples[0]["route"] != "triage" {
+		t.Errorf("triage.request = %+v", req)
+	}
+	if string(req.Properties["route"]) != `{"const":"triage"}` {
+		t.Errorf("route schema = %s", req.Properties["route"])
+	}
+	// Only examples with an output illustrate the response
+	if len(resp.Examples) != 1 || resp.Examples[0]["final_response"] != "P3 billing" {
+		t.Errorf("triage.response examples = %+v", resp.Examples)
+	}
+}
+
+func TestOpenAPIEmpty(t *testing.T) {
+	c, _ := New(nil, nil)
+	post := c.OpenAPI()["paths"].(map[string]any)["/events"].(map[string]any)["post"].(map[string]any)
+	schema := post["requestBody"].(map[string]any)["content"].(map[string]any)["application/json"].(map[string]any)["schema"]
+	if s, ok := schema.(map[string]any); !ok || s["type"] != "object" {
+		t.Errorf("request schema without workflows = %v", schema)
+	}
+}
@@ -0,0 +1,78 @@
+// Package clarify lets an agent pause a workflow to ask the caller a
+// question. The answer resumes the workflow at the same agent.
+package clarify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Config is the [clarification] section of agentflow.toml.
+type Config struct {
+	// Enabled lets the processor ask one question per run when the
+	// request is ambiguous.
+	Enabled bool `toml:"enabled"`
+}
+
+// State keys. QuestionKey in an agent's output state (with no route) pauses
+// the run; AnswerKey carries the caller's reply into the resumed agent.
+const (
+	QuestionKey = "needs_clarification"
+	AnswerKey   = "clarification_answer"
+	AskedKey    = "clarification_question"
+)
+
+// Marker is the reply prefix agents ask the model to use when a request is
+// too ambiguous to answer.
+const Marker = "CLARIFY:"
+
+// Instruction is appended to system prompts of agents allowed to ask.
+const Instruction = "If the request is too ambiguous to answer well, reply only with \"" + Marker + " <one short question for the user>\"."
+
+// Ask returns a result that pauses the run until the caller answers question.
+func Ask(question string) core.AgentResult {
+	out := core.NewState()
+	out.Set(QuestionKey, question)
+	out.Set("message", question)
+	return core.AgentResult{OutputState: out}
+}
+
+// Parse returns the question when reply uses the Marker convention.
+func Parse(reply string) (string, bool) {
+	reply = strings.TrimSpace(reply)
+	if !strings.HasPrefix(strings.ToUpper(reply), Marker) {
+		return "", false
+	}
+	question := strings.TrimSpace(reply[len(Marker):])
+	return question, question != ""
+}
+
+// Pending returns the question an output state asks, if any.
+func Pending(state core.State) (string, bool) {
+	if state == nil {
+		return "", false
+	}
+	v, ok := state.Get(QuestionKey)
+	if !ok {
+		return "", false
+	}
+	q, _ := v.(string)
+	return q, q != ""
+}
+
+// Answered returns the question and answer a resumed event carries.
+func Answered(data core.EventData) (question, answer string, ok bool) {
+	answer, ok = data[AnswerKey].(string)
+	if !ok {
+		return "", "", false
+	}
+	question, _ = data[AskedKey].(string)
+	return question, answer, true
+}
+
+// WithAnswer appends the clarification exchange to the original input.
+func WithAnswer(input, question, answer string) string {
+	return fmt.Sprintf("%s\n\nClarification — Q: %s\nA: %s", input, question, answer)
+}
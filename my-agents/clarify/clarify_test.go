@@ -0,0 +1,47 @@
+package clarify
+
+import (
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+func TestParse(t *testing.T) {
+	for reply, want := range map[string]string{
+		"CLARIFY: Which region?":   "Which region?",
+		"  clarify:Which region? ": "Which region?",
+		"CLARIFY:":                 "",
+		"The answer is 42.":        "",
+	} {
+		got, ok := Parse(reply)
+		if got != want || ok != (want != "") {
+			t.Errorf("Parse(%q) = %q, %v", reply, got, ok)
+		}
+	}
+}
+
+func TestAskPending(t *testing.T) {
+	result := Ask("Which region?")
+	if q, ok := Pending(result.OutputState); !ok || q != "Which region?" {
+		t.Errorf("Pending = %q, %v", q, ok)
+	}
+	if _, ok := Pending(core.NewState()); ok {
+		t.Error("Pending found a question in an empty state")
+	}
+	if _, ok := Pending(nil); ok {
+		t.Error("Pending found a question in a nil state")
+	}
+}
+
+func TestAnswered(t *testing.T) {
+	q, a, ok := Answered(core.EventData{AskedKey: "Which region?", AnswerKey: "EMEA"})
+	if !ok || q != "Which region?" || a != "EMEA" {
+		t.Errorf("Answered = %q, %q, %v", q, a, ok)
+	}
+	if _, _, ok := Answered(core.EventData{"input": "hi"}); ok {
+		t.Error("Answered found an answer in a fresh event")
+	}
+	if got, want := WithAnswer("Sales?", q, a), "Sales?\n\nClarification — Q: Which region?\nA: EMEA"; got != want {
+		t.Errorf("WithAnswer = %q", got)
+	}
+}
@@ -0,0 +1,245 @@
+// Package coldstore keeps records the hot database no longer needs close
+// at hand — old runs, documents nobody retrieves — as compressed objects in
+// a directory or an S3 bucket. The stores that move records here keep an
+// index of what they moved and bring a record back when it is asked for,
+// so callers never see where it was.
+package coldstore
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"my-agents/storage"
+)
+
+// Backends.
+const (
+	BackendFile = "file"
+	BackendS3   = "s3"
+)
+
+// Defaults.
+const (
+	DefaultPath     = ".agentflow/cold"
+	DefaultAge      = 30 * 24 * time.Hour
+	DefaultInterval = 24 * time.Hour
+
+	DefaultRestoreScore = 0.5
+)
+
+// ErrNotFound is returned for a key the archive doesn't hold.
+var ErrNotFound = errors.New("cold storage: object not found")
+
+// Config is the [cold_storage] section of agentflow.toml:
+//
+//	[cold_storage]
+//	enabled = true
+//	backend = "s3"        # file (default) or s3
+//	bucket = "acme-agentflow-archive"
+//	region = "eu-central-1"
+//	runs_after = "720h"
+//	vectors_after = "720h"
+//
+// The file backend writes under path (default DefaultPath). The S3 backend
+// signs its requests with the AWS credential chain, like the bedrock
+// provider; endpoint points it at an S3-compatible server (MinIO, R2)
+// addressed path-style.
+type Config struct {
+	Enabled  bool   `toml:"enabled"`
+	Backend  string `toml:"backend"`
+	Path     string `toml:"path"`
+	Bucket   string `toml:"bucket"`
+	Prefix   string `toml:"prefix"` // starts every key in the bucket
+	Region   string `toml:"region"` // default $AWS_REGION, else $AWS_DEFAULT_REGION
+	Profile  string `toml:"aws_profile"`
+	Endpoint string `toml:"endpoint"`
+	// RunsAfter is how long after it ended a run moves to cold storage
+	// (default DefaultAge); "0" keeps runs hot.
+	RunsAfter string `toml:"runs_after"`
+	// VectorsAfter is how long a document goes neither indexed nor
+	// retrieved before its chunks move (default DefaultAge); "0" keeps
+	// them hot, as it must with a vector store other processes share.
+	VectorsAfter string `toml:"vectors_after"`
+	// Interval is how often records are moved while serving (default
+	// DefaultInterval).
+	Interval string `toml:"interval"`
+	// RestoreScore is how similar to a query the mean of an archived
+	// document's chunk vectors must be for a search the hot chunks don't
+	// fill to bring it back (default DefaultRestoreScore). A search they
+	// fill brings back documents as close as its weakest match.
+	RestoreScore float64 `toml:"restore_score"`
+}
+
+// Ages returns how old runs and documents get before they move; zero
+// keeps them hot.
+func (cfg Config) Ages() (runs, vectors time.Duration, err error) {
+	if runs, err = age("runs_after", cfg.RunsAfter); err != nil {
+		return 0, 0, err
+	}
+	vectors, err = age("vectors_after", cfg.VectorsAfter)
+	return runs, vectors, err
+}
+
+func age(key, s string) (time.Duration, error) {
+	switch s {
+	case "":
+		return DefaultAge, nil
+	case "0":
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("cold storage: bad %s %q", key, s)
+	}
+	return d, nil
+}
+
+// EveryInterval is how often records are moved.
+func (cfg Config) EveryInterval() time.Duration {
+	if d, err := time.ParseDuration(cfg.Interval); err == nil && d > 0 {
+		return d
+	}
+	return DefaultInterval
+}
+
+// Moved is what a store moved to cold storage.
+type Moved struct {
+	Records int   `json:"records"`
+	Bytes   int64 `json:"bytes"` // compressed
+}
+
+// Archive keeps objects by key.
+type Archive interface {
+	Put(ctx context.Context, key string, data []byte) error
+	// Get returns the object under key, or ErrNotFound.
+	Get(ctx context.Context, key string) ([]byte, error)
+	// Delete removes the object under key; deleting a missing one is not
+	// an error.
+	Delete(ctx context.Context, key string) error
+	// List returns the objects whose keys start with prefix.
+	List(ctx context.Context, prefix string) ([]Object, error)
+}
+
+// Object describes a stored object.
+type Object struct {
+	Key      string
+	Size     int64
+	Modified time.Time
+}
+
+// Open creates the archive cfg configures.
+func Open(cfg Config) (Archive, error) {
+	switch cfg.Backend {
+	case "", BackendFile:
+		path := cfg.Path
+		if path == "" {
+			path = DefaultPath
+		}
+		return NewFileArchive(path)
+	case BackendS3:
+		return NewS3Archive(cfg)
+	default:
+		return nil, fmt.Errorf("unknown cold storage backend %q", cfg.Backend)
+	}
+}
+
+// Freeze stores v under key as gzipped JSON and returns its compressed
+// size.
+func Freeze(ctx context.Context, a Archive, key string, v any) (int, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(v); err != nil {
+		return 0, err
+	}
+	if err := zw.Close(); err != nil {
+		return 0, err
+	}
+	if err := a.Put(ctx, key, buf.Bytes()); err != nil {
+		return 0, fmt.Errorf("failed to archive %s: %w", key, err)
+	}
+	return buf.Len(), nil
+}
+
+// Thaw decodes the object Freeze stored under key into v.
+func Thaw(ctx context.Context, a Archive, key string, v any) error {
+	data, err := a.Get(ctx, key)
+	if err != nil {
+		return fmt.Errorf("failed to read %s from cold storage: %w", key, err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("cold storage: %s: %w", key, err)
+	}
+	if err := json.NewDecoder(zr).Decode(v); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("cold storage: failed to decode %s: %w", key, err)
+	}
+	return nil
+}
+
+// Owner returns the name the stores indexing in db write their objects
+// under, made the first time and kept in db. Replicas sharing a bucket
+// each index in their own database, so keying objects by owner keeps one
+// replica's sweep off what the others archived.
+func Owner(db *sql.DB) (string, error) {
+	err := storage.Migrate(db,
+		`CREATE TABLE IF NOT EXISTS cold_owner (
+			singleton INTEGER PRIMARY KEY CHECK (singleton = 1),
+			name      TEXT NOT NULL
+		)`,
+	)
+	if err != nil {
+		return "", err
+	}
+	b := make([]byte, 8)
+	rand.Read(b)
+	if _, err := db.Exec(`INSERT OR IGNORE INTO cold_owner (singleton, name) VALUES (1, ?)`, hex.EncodeToString(b)); err != nil {
+		return "", fmt.Errorf("failed to name the cold storage owner: %w", err)
+	}
+	var name string
+	if err := db.QueryRow(`SELECT name FROM cold_owner`).Scan(&name); err != nil {
+		return "", fmt.Errorf("failed to read the cold storage owner: %w", err)
+	}
+	return name, nil
+}
+
+// SweepOrphans deletes the objects under prefix, last written before
+// cutoff, that indexed reports no store knows of — left behind when a
+// process stopped between archiving a record and indexing it — and returns
+// how many it deleted and their size. The cutoff spares objects whose
+// index entry is still being written; prefix must hold only objects the
+// caller's index records, its Owner's.
+func SweepOrphans(ctx context.Context, a Archive, prefix string, cutoff time.Time, indexed func(ctx context.Context, key string) (bool, error)) (int, int64, error) {
+	objects, err := a.List(ctx, prefix)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to list %s in cold storage: %w", prefix, err)
+	}
+	var n int
+	var size int64
+	for _, o := range objects {
+		if !o.Modified.Before(cutoff) {
+			continue
+		}
+		known, err := indexed(ctx, o.Key)
+		if err != nil {
+			return n, size, err
+		}
+		if known {
+			continue
+		}
+		if err := a.Delete(ctx, o.Key); err != nil {
+			return n, size, fmt.Errorf("failed to delete orphaned %s: %w", o.Key, err)
+		}
+		n++
+		size += o.Size
+	}
+	return n, size, nil
+}
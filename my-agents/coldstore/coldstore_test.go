@@ -0,0 +1,130 @@
+package coldstore
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+// archiveWith puts each key in a file archive with its mtime set to age
+// ago.
+func archiveWith(t *testing.T, objects map[string]time.Duration) *FileArchive {
+	t.Helper()
+	dir := t.TempDir()
+	a, err := NewFileArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, age := range objects {
+		if err := a.Put(context.Background(), key, []byte("data")); err != nil {
+			t.Fatal(err)
+		}
+		at := time.Now().Add(-age)
+		if err := os.Chtimes(a.path(key), at, at); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return a
+}
+
+func keys(t *testing.T, a Archive, prefix string) []string {
+	t.Helper()
+	objects, err := a.List(context.Background(), prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []string
+	for _, o := range objects {
+		out = append(out, o.Key)
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestSweepOrphans(t *testing.T) {
+	a := archiveWith(t, map[string]time.Duration{
+		"runs/me/indexed": 2 * time.Hour,
+		"runs/me/orphan":  2 * time.Hour,
+		"runs/me/recent":  time.Minute,   // its index entry may be on its way
+		"runs/other/old":  2 * time.Hour, // another replica's
+		"documents/me/d1": 2 * time.Hour, // another store's
+	})
+	indexed := func(ctx context.Context, key string) (bool, error) {
+		return key == "runs/me/indexed", nil
+	}
+
+	n, size, err := SweepOrphans(context.Background(), a, "runs/me/", time.Now().Add(-time.Hour), indexed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || size != int64(len("data")) {
+		t.Errorf("swept %d objects of %d bytes, want 1 of 4", n, size)
+	}
+	got := keys(t, a, "")
+	want := []string{"documents/me/d1", "runs/me/indexed", "runs/me/recent", "runs/other/old"}
+	if !slices.Equal(got, want) {
+		t.Errorf("left %v, want %v", got, want)
+	}
+}
+
+func TestSweepOrphansStopsOnIndexError(t *testing.T) {
+	a := archiveWith(t, map[string]time.Duration{"runs/me/a": time.Hour})
+	broken := errors.New("database is locked")
+	_, _, err := SweepOrphans(context.Background(), a, "runs/me/", time.Now(), func(context.Context, string) (bool, error) {
+		return false, broken
+	})
+	if !errors.Is(err, broken) {
+		t.Errorf("SweepOrphans = %v, want the index error", err)
+	}
+	if got := keys(t, a, ""); len(got) != 1 {
+		t.Error("an object was deleted though the index couldn't be read")
+	}
+}
+
+func TestFreezeThaw(t *testing.T) {
+	a := archiveWith(t, nil)
+	type run struct {
+		ID    string
+		Steps []string
+	}
+	in := run{ID: "r1", Steps: []string{"plan", "answer"}}
+	size, err := Freeze(context.Background(), a, "runs/me/r1", in)
+	if err != nil || size == 0 {
+		t.Fatalf("Freeze = %d, %v", size, err)
+	}
+	var out run
+	if err := Thaw(context.Background(), a, "runs/me/r1", &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.ID != in.ID || !slices.Equal(out.Steps, in.Steps) {
+		t.Errorf("thawed %+v, want %+v", out, in)
+	}
+	if err := Thaw(context.Background(), a, "runs/me/missing", &out); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Thaw of a missing key = %v, want ErrNotFound", err)
+	}
+}
+
+func TestFileArchiveKeepsKeysInside(t *testing.T) {
+	a := archiveWith(t, nil)
+	for _, key := range []string{"../../escape", "runs/../../escape", "/etc/passwd"} {
+		if rel, err := filepath.Rel(a.dir, a.path(key)); err != nil || !filepath.IsLocal(rel) {
+			t.Errorf("key %s maps to %s, outside %s", key, a.path(key), a.dir)
+		}
+	}
+}
+
+func TestAges(t *testing.T) {
+	runs, vectors, err := Config{VectorsAfter: "0"}.Ages()
+	if err != nil || runs != DefaultAge || vectors != 0 {
+		t.Errorf("Ages = %v, %v, %v; want the default and zero", runs, vectors, err)
+	}
+	for _, bad := range []string{"soon", "-1h"} {
+		if _, _, err := (Config{RunsAfter: bad}).Ages(); err == nil {
+			t.Errorf("runs_after %q accepted", bad)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package coldstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileArchive keeps each object in a file under a directory, "runs/<id>"
+// as <dir>/runs/<id>.gz.
+type FileArchive struct {
+	dir string
+}
+
+// NewFileArchive creates an archive rooted at dir, creating it if needed.
+func NewFileArchive(dir string) (*FileArchive, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create cold storage directory %s: %w", dir, err)
+	}
+	return &FileArchive{dir: dir}, nil
+}
+
+// path maps key to its file, keeping each of its segments inside the
+// directory.
+func (a *FileArchive) path(key string) string {
+	parts := strings.Split(key, "/")
+	for i, p := range parts {
+		if p = filepath.Base(p); p == ".." {
+			p = "_"
+		}
+		parts[i] = p
+	}
+	return filepath.Join(a.dir, filepath.Join(parts...)) + ".gz"
+}
+
+func (a *FileArchive) Put(ctx context.Context, key string, data []byte) error {
+	path := a.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	// Write-then-rename so a crash never leaves half an object
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func (a *FileArchive) Get(ctx context.Context, key string) ([]byte, error) {
+	data, err := os.ReadFile(a.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (a *FileArchive) Delete(ctx context.Context, key string) error {
+	if err := os.Remove(a.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (a *FileArchive) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	err := filepath.WalkDir(a.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.HasSuffix(path, ".gz") {
+			return err
+		}
+		rel, err := filepath.Rel(a.dir, path)
+		if err != nil {
+			return err
+		}
+		key := strings.TrimSuffix(filepath.ToSlash(rel), ".gz")
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		objects = append(objects, Object{Key: key, Size: info.Size(), Modified: info.ModTime()})
+		return nil
+	})
+	return objects, err
+}
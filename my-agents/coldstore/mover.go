@@ -0,0 +1,72 @@
+package coldstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Tier is a store that moves records older than cutoff to cold storage.
+type Tier interface {
+	Archive(ctx context.Context, cutoff time.Time) (Moved, error)
+}
+
+// Mover moves the old records of its tiers to cold storage on a schedule.
+type Mover struct {
+	interval time.Duration
+	tiers    []tier
+}
+
+type tier struct {
+	name string
+	t    Tier
+	age  time.Duration
+}
+
+// NewMover creates a mover running every cfg's interval.
+func NewMover(cfg Config) *Mover {
+	return &Mover{interval: cfg.EveryInterval()}
+}
+
+// Add has the mover archive t's records once they are age old.
+func (m *Mover) Add(name string, t Tier, age time.Duration) {
+	m.tiers = append(m.tiers, tier{name: name, t: t, age: age})
+}
+
+// Move archives every tier's old records now and returns what moved, by
+// tier name. It stops at the first tier that fails.
+func (m *Mover) Move(ctx context.Context) (map[string]Moved, error) {
+	moved := make(map[string]Moved, len(m.tiers))
+	now := time.Now()
+	for _, t := range m.tiers {
+		done, err := t.t.Archive(ctx, now.Add(-t.age))
+		moved[t.name] = done
+		if err != nil {
+			return moved, err
+		}
+	}
+	return moved, nil
+}
+
+// Run moves records every interval until ctx is cancelled.
+func (m *Mover) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		moved, err := m.Move(ctx)
+		for name, done := range moved {
+			if done.Records > 0 {
+				core.Logger().Info().Str("tier", name).Int("records", done.Records).Int("bytes", int(done.Bytes)).Msg("Moved records to cold storage")
+			}
+		}
+		if err != nil && ctx.Err() == nil {
+			core.Logger().Error().Err(err).Msg("Cold storage tiering failed")
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package coldstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"my-agents/awsauth"
+)
+
+// S3Archive keeps each object in an S3 bucket under the prefix.
+type S3Archive struct {
+	http   *http.Client
+	base   string // the bucket's URL
+	prefix string
+	region string
+	creds  *awsauth.Source
+}
+
+// NewS3Archive creates an archive in cfg's bucket.
+func NewS3Archive(cfg Config) (*S3Archive, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("cold storage: s3 needs a bucket")
+	}
+	region := awsauth.Region(cfg.Region)
+	if region == "" {
+		return nil, fmt.Errorf("cold storage: s3 needs a region or $AWS_REGION")
+	}
+	creds, err := awsauth.NewSource(cfg.Profile, region)
+	if err != nil {
+		return nil, fmt.Errorf("cold storage: %w", err)
+	}
+	a := &S3Archive{
+		http:   &http.Client{Timeout: 5 * time.Minute},
+		base:   "https://" + cfg.Bucket + ".s3." + region + ".amazonaws.com",
+		prefix: cfg.Prefix,
+		region: region,
+		creds:  creds,
+	}
+	if cfg.Endpoint != "" {
+		a.base = strings.TrimSuffix(cfg.Endpoint, "/") + "/" + cfg.Bucket
+	}
+	return a, nil
+}
+
+// do sends a signed request for the object under key and returns the
+// response, whose body the caller closes.
+func (a *S3Archive) do(ctx context.Context, method, key string, body []byte) (*http.Response, error) {
+	creds, err := a.creds.Credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, a.base+"/"+awsauth.Escape(a.prefix+key, false), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/gzip")
+	}
+	awsauth.Sign(req, body, creds, a.region, "s3", time.Now())
+	return a.http.Do(req)
+}
+
+// check turns an error response into an error.
+func check(resp *http.Response, method, key string) error {
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("s3 %s %s returned status %d: %s", method, key, resp.StatusCode, strings.TrimSpace(string(msg)))
+}
+
+func (a *S3Archive) Put(ctx context.Context, key string, data []byte) error {
+	resp, err := a.do(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return check(resp, http.MethodPut, key)
+}
+
+func (a *S3Archive) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := a.do(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if err := check(resp, http.MethodGet, key); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(resp.Body)
+}
+
+func (a *S3Archive) Delete(ctx context.Context, key string) error {
+	resp, err := a.do(ctx, http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil
+	}
+	return check(resp, http.MethodDelete, key)
+}
+
+// listing is the part of a ListObjectsV2 response List reads.
+type listing struct {
+	Contents []struct {
+		Key          string    `xml:"Key"`
+		Size         int64     `xml:"Size"`
+		LastModified time.Time `xml:"LastModified"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+func (a *S3Archive) List(ctx context.Context, prefix string) ([]Object, error) {
+	creds, err := a.creds.Credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var objects []Object
+	token := ""
+	for {
+		q := url.Values{"list-type": {"2"}, "prefix": {a.prefix + prefix}}
+		if token != "" {
+			q.Set("continuation-token", token)
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.base+"/?"+q.Encode(), nil)
+		if err != nil {
+			return nil, err
+		}
+		awsauth.Sign(req, nil, creds, a.region, "s3", time.Now())
+		resp, err := a.http.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var page listing
+		err = check(resp, http.MethodGet, "?"+q.Encode())
+		if err == nil {
+			err = xml.NewDecoder(resp.Body).Decode(&page)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range page.Contents {
+			objects = append(objects, Object{Key: strings.TrimPrefix(c.Key, a.prefix), Size: c.Size, Modified: c.LastModified})
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return objects, nil
+		}
+		token = page.NextContinuationToken
+	}
+}
@@ -0,0 +1,2769 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/appconfig"
+	"my-agents/audit"
+	"my-agents/backfill"
+	"my-agents/backup"
+	"my-agents/billing"
+	"my-agents/coldstore"
+	"my-agents/compliance"
+	"my-agents/conversation"
+	"my-agents/deadletter"
+	"my-agents/debugger"
+	"my-agents/deploy"
+	"my-agents/di"
+	"my-agents/doctor"
+	"my-agents/fixture"
+	"my-agents/history"
+	"my-agents/httpserver"
+	"my-agents/ingest"
+	"my-agents/llmcache"
+	"my-agents/local"
+	"my-agents/modelroute"
+	"my-agents/ocr"
+	"my-agents/partial"
+	"my-agents/plan"
+	"my-agents/profile"
+	"my-agents/quality"
+	"my-agents/quota"
+	"my-agents/reformat"
+	"my-agents/retrieval"
+	"my-agents/setup"
+	"my-agents/simulate"
+	"my-agents/storage"
+	"my-agents/tools/sandbox"
+	"my-agents/transcript"
+	"my-agents/usage"
+	"my-agents/vcr"
+)
+
+// command is a CLI subcommand invoked as `my-agents <name> [flags]`.
+type command struct {
+	summary string
+	run     func(args []string) error
+}
+
+var commands = map[string]command{
+	"migrate-config":    {summary: "upgrade agentflow.toml to the current schema", run: migrateConfigCommand},
+	"export-transcript": {summary: "export a session's conversation as Markdown or HTML", run: exportTranscriptCommand},
+	"ingest":            {summary: "add files and URLs to the knowledge base and vector store (OCR for images and scanned PDFs), or refresh the ones added", run: ingestCommand},
+	"recover":           {summary: "list or print partial responses saved from interrupted generations", run: recoverCommand},
+	"model-stats":       {summary: "show per-model routing quality and realized cost savings", run: modelStatsCommand},
+	"quota":             {summary: "show today's request and token usage against quota limits", run: quotaCommand},
+	"usage-report":      {summary: "aggregate a month of token and cost usage as CSV or JSON", run: usageReportCommand},
+	"billing":           {summary: "push usage to Stripe meters (sync) or compare Stripe against the ledger (reconcile)", run: billingCommand},
+	"simulate":          {summary: "play simulated users from persona definitions against the pipeline and score the conversations", run: simulateCommand},
+	"eval-retrieval":    {summary: "score retrieval against labeled question→document pairs: recall@k, hit rate@k and MRR, per search mode and reranker", run: evalRetrievalCommand},
+	"audit":             {summary: "list the tool calls agents made, with arguments, result hashes and durations", run: auditCommand},
+	"plan":              {summary: "list, approve and apply or discard the planned actions of side-effecting runs", run: planCommand},
+	"erase":             {summary: "delete a session's or user's runs, memory, profile and usage attribution and record the deletion request", run: eraseCommand},
+	"snapshot":          {summary: "run one input through the pipeline and save its config, prompts and responses as a fixture", run: snapshotCommand},
+	"replay":            {summary: "replay fixtures against recorded responses and report behavioral drift", run: replayCommand},
+	"vcr-proxy":         {summary: "proxy a provider API locally, recording its traffic to a cassette or replaying it", run: vcrProxyCommand},
+	"compliance-report": {summary: "write a compliance evidence bundle (access log, retention, encryption, deletions) for a period", run: complianceReportCommand},
+	"init":              {summary: "write an agentflow.toml from a provider, model and memory backend (and optionally a workflow template), checking the provider answers", run: initCommand},
+	"doctor":            {summary: "check config, provider connectivity, models, memory, storage, sandboxes and required binaries", run: doctorCommand},
+	"run-state":         {summary: "show a run's state after any step or at any time, who changed a key and when, or which agent wrote each key", run: runStateCommand},
+	"debug":             {summary: "step through one run, pausing before each agent and provider call to inspect or edit its state and prompt", run: debugCommand},
+	"worker":            {summary: "run this process's share of the agents, taking their events from the [event_bus] until stopped", run: workerCommand},
+	"serve":             {summary: "serve the pipeline as a REST API (POST /events, GET /events/{id}) until stopped", run: serveCommand},
+	"drift":             {summary: "show how recent responses compare with the drift baseline, its alerts, or reset the baseline", run: driftCommand},
+	"quality":           {summary: "show the daily quality and safety scores of sampled responses and their alerts, or score a day now", run: qualityCommand},
+	"saved-runs":        {summary: "list the runs the [state_store] saved mid-pipeline, which restart at their next agent, or drop one", run: savedRunsCommand},
+	"dead-letters":      {summary: "list, show, re-emit or drop the events agents failed on", run: deadLettersCommand},
+	"rerun":             {summary: "re-run a stored run from a chosen agent or step on the earlier agents' results, e.g. with a new workflow version", run: rerunCommand},
+	"backfill":          {summary: "re-run a filtered set of stored runs through the current workflow within token and cost caps, comparing each with its original", run: backfillCommand},
+	"reformat":          {summary: "format a stored run's enhanced content again with other length, tone or format, re-running only the formatter", run: reformatCommand},
+	"preferences":       {summary: "show or set how a user wants responses written to them, and what their feedback taught: verbosity, format and expertise", run: preferencesCommand},
+	"archive":           {summary: "move old runs and documents nobody retrieves to [cold_storage] now instead of at the next interval", run: archiveCommand},
+	"maintain":          {summary: "run the [maintenance] jobs now (vacuum, reindex, cache compaction, orphan sweep) and show what they reclaimed", run: maintainCommand},
+	"backup":            {summary: "dump the config, run history, memory, vectors, checkpoints and other state to a portable archive", run: backupCommand},
+	"restore":           {summary: "unpack a backup archive on this machine, checking every file against its manifest first", run: restoreCommand},
+}
+
+func runCommand(name string, args []string) error {
+	cmd, ok := commands[name]
+	if !ok {
+		names := make([]string, 0, len(commands))
+		for n := range commands {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		fmt.Fprintln(os.Stderr, "Available commands:")
+		for _, n := range names {
+			fmt.Fprintf(os.Stderr, "  %-16s %s\n", n, commands[n].summary)
+		}
+		return fmt.Errorf("unknown command %q", name)
+	}
+	return cmd.run(args)
+}
+
+func migrateConfigCommand(args []string) error {
+	fs := flag.NewFlagSet("migrate-config", flag.ContinueOnError)
+	path := fs.String("config", "agentflow.toml", "config file to migrate")
+	dryRun := fs.Bool("dry-run", false, "print changes without writing the file")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(*path)
+	if err != nil {
+		return err
+	}
+	result, err := appconfig.Migrate(data)
+	if err != nil {
+		return err
+	}
+	if len(result.Changes) == 0 {
+		fmt.Printf("%s is already at schema version %d\n", *path, result.ToVersion)
+		return nil
+	}
+
+	fmt.Printf("Migrating %s from schema version %d to %d:\n", *path, result.FromVersion, result.ToVersion)
+	for _, change := range result.Changes {
+		fmt.Printf("  • %s\n", change)
+	}
+	if *dryRun {
+		fmt.Printf("\n%s", result.Output)
+		return nil
+	}
+
+	backup := *path + ".bak"
+	if err := os.WriteFile(backup, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := os.WriteFile(*path, result.Output, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s (previous version saved to %s)\n", *path, backup)
+	return nil
+}
+
+func exportTranscriptCommand(args []string) error {
+	fs := flag.NewFlagSet("export-transcript", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file locating the run history")
+	session := fs.String("session", "", "session ID to export (required)")
+	format := fs.String("format", transcript.Markdown, "markdown or html")
+	steps := fs.Bool("steps", false, "include each agent's intermediate output")
+	out := fs.String("o", "", "output file (default stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *session == "" {
+		return fmt.Errorf("-session is required")
+	}
+
+	store, err := openHistory(*configPath)
+	if err != nil {
+		return err
+	}
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	return transcript.Export(context.Background(), w, store, *session, transcript.Options{Format: *format, IncludeSteps: *steps})
+}
+
+func ingestCommand(args []string) error {
+	fs := flag.NewFlagSet("ingest", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file with [agent_memory], [retrieval] and [ocr]")
+	var tags repeated
+	fs.Var(&tags, "tag", "metadata key=value added to the chunks indexed (repeatable)")
+	collection := fs.String("collection", "", "index into this [retrieval.collections] collection instead of the main one")
+	chunker := fs.String("chunker", "", "chunk with this strategy (fixed, markdown, code or semantic) instead of [retrieval.chunkers]")
+	refresh := fs.Bool("refresh", false, "re-check the sources ingested before: re-ingest changed ones, tombstone deleted ones")
+	list := fs.Bool("sources", false, "list the sources ingested, with their versions")
+	workers := fs.Int("workers", 0, "sources ingested at once, replacing [knowledge] workers")
+	resume := fs.Bool("resume", false, "skip files already ingested at their version; without files, finish the documents an interrupted run left")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 && !*refresh && !*list && !*resume {
+		return fmt.Errorf("usage: ingest [-config agentflow.toml] [-collection name] [-chunker strategy] [-tag key=value]... [-workers n] [-resume] file-or-url... | -resume | -refresh | -sources")
+	}
+	tagged, err := tags.pairs("-tag")
+	if err != nil {
+		return err
+	}
+	if *collection != "" {
+		tagged[retrieval.CollectionKey] = *collection
+	}
+	if *chunker != "" {
+		tagged[retrieval.ChunkerKey] = *chunker
+	}
+
+	cfg, err := core.LoadConfig(*configPath)
+	if err != nil {
+		return err
+	}
+	appCfg, err := appconfig.Load(*configPath)
+	if err != nil {
+		return err
+	}
+	sources, err := ingest.OpenSources(appCfg.Storage.Path)
+	if err != nil {
+		return err
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *list {
+		tracked, err := sources.List(ctx)
+		if err != nil {
+			return err
+		}
+		for _, src := range tracked {
+			if src.Deleted() {
+				fmt.Printf("✗ %s  tombstoned %s\n", src.Source, src.DeletedAt.Format(time.DateTime))
+				continue
+			}
+			fmt.Printf("✓ %s  %s  ingested %s, checked %s\n", src.Source, strings.TrimPrefix(src.Version, "sha256:")[:12], src.IngestedAt.Format(time.DateTime), src.CheckedAt.Format(time.DateTime))
+		}
+		return nil
+	}
+
+	if cfg.AgentMemory.Provider == "" && !appCfg.Retrieval.Enabled {
+		return fmt.Errorf("%s has no [agent_memory] provider or [retrieval] to ingest into", *configPath)
+	}
+	engine, err := ocr.New(appCfg.OCR)
+	if err != nil {
+		return err
+	}
+	in := &ingest.Ingester{OCR: engine, DPI: appCfg.OCR.DPI, Sources: sources, Tags: tagged, Workers: appCfg.Knowledge.Workers}
+	if *workers > 0 {
+		in.Workers = *workers
+	}
+	if cfg.AgentMemory.Provider != "" {
+		if cfg.AgentMemory.Provider == storage.MemoryProvider && cfg.AgentMemory.Connection == "" {
+			cfg.AgentMemory.Connection = appCfg.Storage.Path
+		}
+		memory, err := core.NewMemory(cfg.AgentMemory)
+		if err != nil {
+			return err
+		}
+		defer memory.Close()
+		in.Memory = memory
+	}
+	if appCfg.Retrieval.Enabled {
+		if in.Vectors, err = retrieval.Open(appCfg.Retrieval, appCfg.Storage.Path); err != nil {
+			return err
+		}
+		defer in.Vectors.Close()
+	}
+	if *refresh {
+		done, err := in.Refresh(ctx)
+		if err != nil {
+			return err
+		}
+		for _, source := range done.Updated {
+			fmt.Printf("↻ %s: re-ingested\n", source)
+		}
+		for _, source := range done.Tombstoned {
+			fmt.Printf("✗ %s: gone, tombstoned\n", source)
+		}
+		failed := make([]string, 0, len(done.Failed))
+		for source := range done.Failed {
+			failed = append(failed, source)
+		}
+		sort.Strings(failed)
+		for _, source := range failed {
+			fmt.Fprintf(os.Stderr, "✗ %s: %s\n", source, done.Failed[source])
+		}
+		fmt.Printf("%d updated, %d tombstoned, %d unchanged\n", len(done.Updated), len(done.Tombstoned), done.Unchanged)
+		if len(failed) > 0 {
+			return fmt.Errorf("%d sources could not be checked", len(failed))
+		}
+		return nil
+	}
+	in.Resume = *resume
+	var jobs []ingest.Job
+	for _, path := range fs.Args() {
+		jobs = append(jobs, ingest.Job{Path: path})
+	}
+	if *resume && fs.NArg() == 0 {
+		if in.Vectors == nil {
+			return fmt.Errorf("only [retrieval] indexing can be resumed; name the files to ingest")
+		}
+		// Finish what an interrupted run left, tagged as it was
+		interrupted, err := in.Vectors.Interrupted(ctx)
+		if err != nil {
+			return err
+		}
+		for _, cp := range interrupted {
+			if strings.HasPrefix(cp.Source, "upload:") {
+				fmt.Fprintf(os.Stderr, "✗ %s: uploads can't be resumed; upload it again\n", cp.Source)
+				continue
+			}
+			jobs = append(jobs, ingest.Job{Path: cp.Source, Tags: cp.Tags})
+		}
+		if len(jobs) == 0 {
+			fmt.Println("No interrupted ingestion to resume")
+			return nil
+		}
+	}
+
+	// Progress goes to a terminal on one line, updated as batches are
+	// stored; elsewhere only resumptions are reported
+	info, err := os.Stderr.Stat()
+	live := err == nil && info.Mode()&os.ModeCharDevice != 0
+	var mu sync.Mutex
+	finished := 0
+	indexing := make(map[string]retrieval.Progress) // by document, while in progress
+	status := func() {
+		done, total := 0, 0
+		for _, p := range indexing {
+			done, total = done+p.Done, total+p.Total
+		}
+		fmt.Fprintf(os.Stderr, "\r  %d/%d files, %d indexing: %d/%d chunks\033[K", finished, len(jobs), len(indexing), done, total)
+	}
+	ctx = retrieval.WithProgress(ctx, func(p retrieval.Progress) {
+		mu.Lock()
+		defer mu.Unlock()
+		if p.Resumed > 0 && p.Done == p.Resumed {
+			fmt.Fprintf(os.Stderr, "\r\033[K↷ %s: resuming after %d of %d chunks\n", p.Source, p.Resumed, p.Total)
+		}
+		if p.Done < p.Total {
+			indexing[p.DocumentID] = p
+		} else {
+			delete(indexing, p.DocumentID)
+		}
+		if live {
+			status()
+		}
+	})
+	failed, skipped, interrupted := 0, 0, 0
+	in.Files(ctx, jobs, func(r ingest.Result) {
+		mu.Lock()
+		defer mu.Unlock()
+		finished++
+		delete(indexing, r.Document.ID)
+		if live {
+			fmt.Fprint(os.Stderr, "\r\033[K")
+			defer status()
+		}
+		switch {
+		case errors.Is(r.Err, ingest.ErrUnchanged):
+			fmt.Printf("= %s: unchanged since ingested, skipped\n", r.Path)
+			skipped++
+		case ctx.Err() != nil:
+			interrupted++
+		case r.Err != nil:
+			fmt.Fprintf(os.Stderr, "✗ %v\n", r.Err)
+			failed++
+		default:
+			via := ""
+			if r.Document.Metadata["ocr"] == true {
+				via = " (OCR)"
+			}
+			if chunks, ok := r.Document.Metadata["chunks"].(int); ok {
+				via += fmt.Sprintf(", %d chunks", chunks)
+			}
+			fmt.Printf("✓ %s: %d characters%s\n", r.Path, len(r.Document.Content), via)
+		}
+	})
+	if live {
+		fmt.Fprint(os.Stderr, "\r\033[K")
+	}
+	if ctx.Err() != nil {
+		fmt.Fprintf(os.Stderr, "Interrupted with %d of %d files finished; `ingest -resume` finishes those half-indexed from their last stored chunk, `ingest -resume <files>` the rest\n", finished-interrupted, len(jobs))
+		return ctx.Err()
+	}
+	if skipped > 0 {
+		fmt.Printf("%d of %d files unchanged\n", skipped, len(jobs))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files failed", failed, len(jobs))
+	}
+	return nil
+}
+
+func recoverCommand(args []string) error {
+	fs := flag.NewFlagSet("recover", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file locating the checkpoints")
+	key := fs.String("key", "", "print the partial response for this checkpoint key")
+	discard := fs.Bool("discard", false, "delete the -key checkpoint instead of printing it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := appconfig.Load(*configPath)
+	if err != nil {
+		return err
+	}
+	store, err := partial.Open(cfg.Recovery)
+	if err != nil {
+		return err
+	}
+
+	if *key != "" {
+		if *discard {
+			return store.Delete(*key)
+		}
+		cp, err := store.Get(*key)
+		if err != nil {
+			return err
+		}
+		if cp == nil {
+			return fmt.Errorf("no checkpoint %q", *key)
+		}
+		fmt.Println(cp.Content)
+		return nil
+	}
+
+	cps, err := store.List()
+	if err != nil {
+		return err
+	}
+	if len(cps) == 0 {
+		fmt.Println("No interrupted generations.")
+		return nil
+	}
+	for _, cp := range cps {
+		fmt.Printf("%s  %-10s %5d tokens  attempt %d  updated %s\n", cp.Key, cp.Agent, cp.Tokens, cp.Attempt+1, cp.UpdatedAt.Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
+
+// openHistory opens the run history store configured in configPath.
+func openHistory(configPath string) (history.Store, error) {
+	cfg, err := appconfig.Load(configPath)
+	if err != nil {
+		return nil, err
+	}
+	return openRuns(cfg)
+}
+
+func simulateCommand(args []string) error {
+	fs := flag.NewFlagSet("simulate", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file for the pipeline and [simulation]")
+	personasPath := fs.String("personas", "", "persona file (default [simulation] personas, or personas.toml)")
+	only := fs.String("persona", "", "run only this persona")
+	out := fs.String("o", filepath.Join(".agentflow", "simulations"), "directory for transcripts and the score report")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	app, err := newApp(*configPath)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+	cfg := app.appCfg.Simulation
+	if *personasPath == "" {
+		*personasPath = cmp.Or(cfg.Personas, "personas.toml")
+	}
+	personas, err := simulate.LoadPersonas(*personasPath)
+	if err != nil {
+		return err
+	}
+	if *only != "" {
+		var selected []simulate.Persona
+		for _, p := range personas {
+			if p.Name == *only {
+				selected = append(selected, p)
+			}
+		}
+		if len(selected) == 0 {
+			return fmt.Errorf("no persona %q in %s", *only, *personasPath)
+		}
+		personas = selected
+	}
+
+	user, err := app.container.Provider(cfg.Provider)
+	if err != nil {
+		return err
+	}
+	judge, err := app.container.Provider(cmp.Or(cfg.JudgeProvider, cfg.Provider))
+	if err != nil {
+		return err
+	}
+	timeout, _ := time.ParseDuration(cfg.TurnTimeout)
+	sim := &simulate.Simulator{
+		User:     user,
+		Judge:    judge,
+		Pipeline: &simulate.RunnerPipeline{Runner: app.runner, Runs: app.runs, Timeout: timeout},
+		MaxTurns: cfg.MaxTurns,
+	}
+	if err := os.MkdirAll(*out, 0o755); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	app.runner.Start(ctx)
+	defer app.runner.Stop()
+
+	results := []*simulate.Result{}
+	failed := 0
+	for _, persona := range personas {
+		res, err := sim.Run(ctx, persona)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %v\n", err)
+			failed++
+			continue
+		}
+		results = append(results, res)
+		if err := writeSimulationTranscript(ctx, app, res, filepath.Join(*out, persona.Name+".md")); err != nil {
+			fmt.Fprintf(os.Stderr, "✗ %s: transcript: %v\n", persona.Name, err)
+		}
+		if app.router != nil {
+			for _, turn := range res.Turns {
+				app.router.Feedback(turn.RunID, res.Score.Overall/10)
+			}
+		}
+		mark := "✓"
+		if !res.Score.GoalMet || res.Score.Overall < cfg.MinScore {
+			mark = "✗"
+			failed++
+		}
+		fmt.Printf("%s %-24s %4.1f  goal met: %-5t turns: %d (%s)\n", mark, persona.Name, res.Score.Overall, res.Score.GoalMet, len(res.Turns), res.Ended)
+		if res.Score.Notes != "" {
+			fmt.Printf("    %s\n", res.Score.Notes)
+		}
+	}
+
+	report, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	reportPath := filepath.Join(*out, "report.json")
+	if err := os.WriteFile(reportPath, report, 0o644); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %s\n", reportPath)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d personas failed", failed, len(personas))
+	}
+	return nil
+}
+
+func evalRetrievalCommand(args []string) error {
+	fs := flag.NewFlagSet("eval-retrieval", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file with [retrieval]")
+	ksFlag := fs.String("k", "1,3,5,10", "comma-separated cutoffs to report recall and hit rate at")
+	mode := fs.String("mode", "", "search every question this way (vector, keyword, hybrid) instead of as configured")
+	rerankFlag := fs.String("rerank", "", "true or false to force reranking on or off instead of as configured")
+	minRecall := fs.Float64("min-recall", 0, "fail when recall at the largest cutoff is below this")
+	minMRR := fs.Float64("min-mrr", 0, "fail when MRR is below this")
+	asJSON := fs.Bool("json", false, "print the report as JSON, with every question's ranks")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: eval-retrieval [-config agentflow.toml] [-k 1,3,5,10] [-mode hybrid] [-rerank false] dataset.jsonl")
+	}
+	opts := retrieval.EvalOptions{Mode: *mode}
+	for _, k := range strings.Split(*ksFlag, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(k))
+		if err != nil {
+			return fmt.Errorf("-k %q: %w", k, err)
+		}
+		opts.Ks = append(opts.Ks, n)
+	}
+	if *rerankFlag != "" {
+		on, err := strconv.ParseBool(*rerankFlag)
+		if err != nil {
+			return fmt.Errorf("-rerank: %w", err)
+		}
+		opts.Rerank = &on
+	}
+	cases, err := retrieval.LoadCases(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	app, err := newApp(*configPath)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+	if app.vectors == nil {
+		return fmt.Errorf("%s doesn't enable [retrieval]", *configPath)
+	}
+	report, err := app.vectors.Evaluate(context.Background(), cases, opts)
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			return err
+		}
+	} else {
+		for _, c := range report.Cases {
+			if c.Error != "" {
+				fmt.Printf("✗ %s\n    %s\n", c.Question, c.Error)
+				continue
+			}
+			for i, rank := range c.Ranks {
+				if rank == 0 {
+					fmt.Printf("✗ %s\n    %s not retrieved\n", c.Question, c.Documents[i])
+				}
+			}
+		}
+		fmt.Printf("%d questions", report.Questions)
+		if report.Failed > 0 {
+			fmt.Printf(" (%d failed)", report.Failed)
+		}
+		fmt.Printf("\n%-6s  %8s  %8s\n", "k", "recall", "hit rate")
+		for _, k := range report.Ks {
+			fmt.Printf("%-6d  %8.3f  %8.3f\n", k, report.RecallAt[k], report.HitRateAt[k])
+		}
+		fmt.Printf("MRR     %8.3f\n", report.MRR)
+	}
+
+	depth := report.Ks[len(report.Ks)-1]
+	if recall := report.RecallAt[depth]; recall < *minRecall {
+		return fmt.Errorf("recall@%d %.3f is below %.3f", depth, recall, *minRecall)
+	}
+	if report.MRR < *minMRR {
+		return fmt.Errorf("MRR %.3f is below %.3f", report.MRR, *minMRR)
+	}
+	return nil
+}
+
+func writeSimulationTranscript(ctx context.Context, app *application, res *simulate.Result, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return transcript.Export(ctx, f, app.runs, res.SessionID, transcript.Options{})
+}
+
+func modelStatsCommand(args []string) error {
+	fs := flag.NewFlagSet("model-stats", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file locating the model stats")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := appconfig.Load(*configPath)
+	if err != nil {
+		return err
+	}
+	stats, err := modelroute.LoadStats(cfg.ModelRouting.StatsPath)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(stats.Models))
+	for name := range stats.Models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Printf("%-20s %-8s %7s %9s %8s\n", "MODEL", "LEVEL", "CALLS", "FAILURES", "RATING")
+	for _, name := range names {
+		for _, d := range modelroute.Difficulties {
+			o := stats.Models[name][d]
+			if o == nil {
+				continue
+			}
+			fmt.Printf("%-20s %-8s %7d %9d %8s\n", name, d, o.Calls, o.Failures, averageRating(o))
+		}
+	}
+	keys := make([]string, 0, len(stats.Categories))
+	for key := range stats.Categories {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) > 0 {
+		fmt.Printf("\n%-32s %-20s %7s %9s %8s\n", "AGENT/CATEGORY", "MODEL", "CALLS", "FAILURES", "RATING")
+	}
+	for _, key := range keys {
+		models := make([]string, 0, len(stats.Categories[key]))
+		for name := range stats.Categories[key] {
+			models = append(models, name)
+		}
+		sort.Strings(models)
+		for _, name := range models {
+			o := stats.Categories[key][name]
+			fmt.Printf("%-32s %-20s %7d %9d %8s\n", key, name, o.Calls, o.Failures, averageRating(o))
+		}
+	}
+
+	sv := stats.Savings
+	fmt.Printf("\n%d routed calls cost %.4f vs %.4f on the baseline model (saved %.4f)\n", sv.Calls, sv.Cost, sv.BaselineCost, sv.Saved())
+	return nil
+}
+
+func averageRating(o *modelroute.Outcome) string {
+	if o.Rated == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.2f", o.QualitySum/float64(o.Rated))
+}
+
+func quotaCommand(args []string) error {
+	fs := flag.NewFlagSet("quota", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file with [quotas]")
+	identity := fs.String("identity", "", `show one identity ("user:<id>", "client:<name>" or "ip:<address>")`)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := appconfig.Load(*configPath)
+	if err != nil {
+		return err
+	}
+	m, err := quota.New(cfg.Quotas)
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	if *identity != "" {
+		ids = []string{*identity}
+	} else {
+		for id := range m.Store().Day(m.Day(time.Now())) {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+	}
+	fmt.Printf("%-24s %15s %21s\n", "IDENTITY", "REQUESTS", "TOKENS")
+	for _, id := range ids {
+		rep := m.Report(id)
+		fmt.Printf("%-24s %15s %21s\n", id, ofLimit(rep.Usage.Requests, rep.Limits.RequestsPerDay), ofLimit(rep.Usage.Tokens, rep.Limits.TokensPerDay))
+	}
+	if len(ids) == 0 {
+		fmt.Println("(no usage today)")
+	}
+	return nil
+}
+
+func ofLimit(used, limit int) string {
+	if limit == 0 {
+		return fmt.Sprintf("%d", used)
+	}
+	return fmt.Sprintf("%d/%d", used, limit)
+}
+
+func usageReportCommand(args []string) error {
+	fs := flag.NewFlagSet("usage-report", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file locating the usage ledger")
+	month := fs.String("month", usage.Month(time.Now()), "month to report (YYYY-MM)")
+	by := fs.String("by", "tenant,user,workflow", "comma-separated grouping: tenant, user, workflow, agent, provider")
+	format := fs.String("format", "csv", "csv or json")
+	out := fs.String("o", "", "output file (default stdout)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	dims, err := usage.ParseDimensions(*by)
+	if err != nil {
+		return err
+	}
+	cfg, err := appconfig.Load(*configPath)
+	if err != nil {
+		return err
+	}
+	ledger, err := usage.OpenLedger(cfg.Usage.Path)
+	if err != nil {
+		return err
+	}
+	rows, err := ledger.Report(*month, dims)
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	switch *format {
+	case "csv":
+		return usage.WriteCSV(w, rows, dims)
+	case "json":
+		return usage.WriteJSON(w, rows)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func billingCommand(args []string) error {
+	if len(args) == 0 || (args[0] != "sync" && args[0] != "reconcile") {
+		return fmt.Errorf("usage: billing sync|reconcile [flags]")
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("billing "+action, flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file with [usage] and [billing]")
+	monthStart := time.Date(time.Now().Year(), time.Now().Month(), 1, 0, 0, 0, 0, time.UTC)
+	from := fs.String("from", monthStart.Format(time.DateOnly), "reconcile: start date (UTC)")
+	to := fs.String("to", time.Now().UTC().Format(time.DateOnly), "reconcile: end date, exclusive (UTC)")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+	cfg, err := appconfig.Load(*configPath)
+	if err != nil {
+		return err
+	}
+	ledger, err := usage.OpenLedger(cfg.Usage.Path)
+	if err != nil {
+		return err
+	}
+	reporter, err := billing.New(cfg.Billing, ledger)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+
+	if action == "sync" {
+		res, err := reporter.Sync(ctx)
+		fmt.Printf("Sent %d meter events (%d already reported)\n", res.Sent, res.Skipped)
+		for who, tokens := range res.Unmapped {
+			fmt.Printf("  ! %q has %d tokens but no [billing.customers] entry\n", who, tokens)
+		}
+		return err
+	}
+
+	start, err := time.Parse(time.DateOnly, *from)
+	if err != nil {
+		return fmt.Errorf("-from: %w", err)
+	}
+	end, err := time.Parse(time.DateOnly, *to)
+	if err != nil {
+		return fmt.Errorf("-to: %w", err)
+	}
+	diffs, err := reporter.Reconcile(ctx, start, end)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%-20s %-8s %12s %12s %12s\n", "CUSTOMER", "METRIC", "LEDGER", "REPORTED", "STRIPE")
+	mismatched := 0
+	for _, d := range diffs {
+		mark := ""
+		if !d.OK() {
+			mark = "  ✗"
+			mismatched++
+		}
+		fmt.Printf("%-20s %-8s %12d %12d %12.0f%s\n", d.Customer, d.Metric, d.Ledger, d.Reported, d.Stripe, mark)
+	}
+	if mismatched > 0 {
+		return fmt.Errorf("%d of %d totals disagree", mismatched, len(diffs))
+	}
+	return nil
+}
+
+func planCommand(args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file with [plan]")
+	runID := fs.String("run", "", "show the plan of this run")
+	status := fs.String("status", plan.StatusPending, `list plans with this status ("" for all)`)
+	apply := fs.Bool("apply", false, "apply the -run plan")
+	actions := fs.String("actions", "", "with -apply, the comma-separated actions approved (default all); the rest are skipped")
+	discard := fs.Bool("discard", false, "discard the -run plan without applying it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (*apply || *discard) && *runID == "" {
+		return fmt.Errorf("-apply and -discard need -run")
+	}
+
+	if *apply {
+		var approved []int
+		for _, field := range strings.FieldsFunc(*actions, func(r rune) bool { return r == ',' || r == ' ' }) {
+			i, err := strconv.Atoi(field)
+			if err != nil {
+				return fmt.Errorf("invalid action %q", field)
+			}
+			approved = append(approved, i)
+		}
+		// Applying needs the tools and sinks the agents were wired with
+		app, err := newApp(*configPath)
+		if err != nil {
+			return err
+		}
+		defer app.Close()
+		if app.plans == nil {
+			return fmt.Errorf("planning is not enabled in %s", *configPath)
+		}
+		pl, err := app.plans.Apply(context.Background(), *runID, approved)
+		if pl != nil {
+			fmt.Print(plan.Describe(pl))
+		}
+		return err
+	}
+
+	cfg, err := appconfig.Load(*configPath)
+	if err != nil {
+		return err
+	}
+	runs, err := openRuns(cfg)
+	if err != nil {
+		return err
+	}
+	planner, err := plan.New(cfg.Plan, runs)
+	if err != nil {
+		return err
+	}
+	if *discard {
+		_, err := planner.Discard(*runID)
+		return err
+	}
+	if *runID != "" {
+		pl, err := planner.Get(*runID)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Run %s (%s) — %s\n%s", pl.RunID, pl.Workflow, pl.Status, plan.Describe(pl))
+		return nil
+	}
+
+	plans := planner.List(*status)
+	if len(plans) == 0 {
+		fmt.Println("No plans.")
+		return nil
+	}
+	for _, pl := range plans {
+		fmt.Printf("%s  %-12s %-10s %3d actions  planned %s\n", pl.RunID, pl.Workflow, pl.Status, len(pl.Actions), pl.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+	return nil
+}
+
+func auditCommand(args []string) error {
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file with [audit]")
+	runID := fs.String("run", "", "only calls made by this run")
+	agent := fs.String("agent", "", "only calls made by this agent")
+	tool := fs.String("tool", "", "only calls to this tool")
+	limit := fs.Int("limit", 50, "show the most recent calls (0 for all)")
+	asJSON := fs.Bool("json", false, "print the calls as JSON, with their arguments")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := appconfig.Load(*configPath)
+	if err != nil {
+		return err
+	}
+	log, err := audit.Open(cfg.Audit)
+	if err != nil {
+		return err
+	}
+	calls, err := log.List(context.Background(), audit.Filter{RunID: *runID, Agent: *agent, Tool: *tool, Limit: *limit})
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(calls)
+	}
+	if len(calls) == 0 {
+		fmt.Println("No tool calls recorded.")
+		return nil
+	}
+	for _, c := range calls {
+		outcome := "sha256:" + c.ResultHash[:min(12, len(c.ResultHash))]
+		if c.Error != "" {
+			outcome = "error: " + c.Error
+		}
+		fmt.Printf("%s  %-36s %-10s %-14s %8s  %s\n", c.StartedAt.Format("2006-01-02 15:04:05"), c.RunID, c.Agent, c.Tool, c.Duration.Round(time.Millisecond), outcome)
+	}
+	return nil
+}
+
+// eraseCommand deletes a session, or a user with every session of theirs,
+// from each store keeping it, and records the deletion in the audit log.
+// The LLM cache can't tell whose replies it holds, so a shared (Redis)
+// cache is flushed whole; an in-memory one lives in the serving process
+// and lets its entries expire.
+func eraseCommand(args []string) error {
+	fs := flag.NewFlagSet("erase", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file locating the stores")
+	sessionID := fs.String("session", "", "session to erase")
+	userID := fs.String("user", "", "user to erase, with their profile, usage and every session of theirs")
+	requestedBy := fs.String("requested-by", "", "who asked for the deletion, kept in the audit log")
+	reason := fs.String("reason", "", "why, kept in the audit log")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *sessionID == "" && *userID == "" {
+		return fmt.Errorf("-session or -user is required")
+	}
+	cfg, err := core.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	appCfg, err := appconfig.Load(*configPath)
+	if err != nil {
+		return err
+	}
+	runs, err := openRuns(appCfg)
+	if err != nil {
+		return err
+	}
+	calls, err := audit.Open(appCfg.Audit)
+	if err != nil {
+		return err
+	}
+	checkpoints, err := partial.Open(appCfg.Recovery)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	erasure := &audit.Erasure{Time: time.Now(), SessionID: *sessionID, UserID: *userID, RequestedBy: *requestedBy, Reason: *reason}
+	var failures []string
+	sessions := make(map[string]bool)
+	if *sessionID != "" {
+		sessions[*sessionID] = true
+	}
+	var deleted []*history.Run
+	if *userID != "" {
+		all, err := runs.List(ctx, history.Filter{})
+		if err != nil {
+			failures = append(failures, "runs: "+err.Error())
+		}
+		for _, run := range all {
+			if run.Metadata[profile.UserKey] == *userID {
+				sessions[run.SessionID] = true
+			}
+		}
+		for _, run := range all {
+			if sessions[run.SessionID] {
+				deleted = append(deleted, run)
+			}
+		}
+	} else {
+		if deleted, err = runs.List(ctx, history.Filter{SessionID: *sessionID}); err != nil {
+			failures = append(failures, "runs: "+err.Error())
+		}
+	}
+	runIDs := make(map[string]bool)
+	var ids []string
+	for _, run := range deleted {
+		if err := runs.Delete(ctx, run.ID); err != nil {
+			failures = append(failures, "run "+run.ID+": "+err.Error())
+			continue
+		}
+		runIDs[run.ID] = true
+		ids = append(ids, run.ID)
+	}
+	erasure.Runs = len(ids)
+
+	cps, err := checkpoints.List()
+	if err != nil {
+		failures = append(failures, "checkpoints: "+err.Error())
+	}
+	for _, cp := range cps {
+		if !runIDs[cp.RunID] {
+			continue
+		}
+		if err := checkpoints.Delete(cp.Key); err != nil {
+			failures = append(failures, "checkpoint "+cp.Key+": "+err.Error())
+			continue
+		}
+		erasure.Checkpoints++
+	}
+
+	if erasure.ToolCalls, err = calls.ForgetArgs(ctx, ids); err != nil {
+		failures = append(failures, "tool calls: "+err.Error())
+	}
+
+	ordered := slices.Sorted(maps.Keys(sessions))
+	if cfg.AgentMemory.Provider == storage.MemoryProvider && cfg.AgentMemory.Connection == "" {
+		cfg.AgentMemory.Connection = appCfg.Storage.Path
+	}
+	if cfg.AgentMemory.Provider != "" && len(ordered) > 0 {
+		memory, err := core.NewMemory(cfg.AgentMemory)
+		if err != nil {
+			failures = append(failures, "memory: "+err.Error())
+		} else {
+			defer memory.Close()
+			for _, session := range ordered {
+				if err := memory.ClearSession(memory.SetSession(ctx, session)); err != nil {
+					failures = append(failures, "memory "+session+": "+err.Error())
+				} else {
+					erasure.Memory = append(erasure.Memory, session)
+				}
+			}
+		}
+	}
+
+	// Conversation memory is listed with the memory sessions, as
+	// "conversation:<session>"
+	conversations, err := conversation.Open(appCfg.Conversation, appCfg.Storage.Path, nil)
+	if err != nil {
+		failures = append(failures, "conversation memory: "+err.Error())
+	} else {
+		defer conversations.Close()
+		for _, session := range ordered {
+			if turns, err := conversations.Erase(ctx, session); err != nil {
+				failures = append(failures, "conversation memory "+session+": "+err.Error())
+			} else if turns > 0 {
+				erasure.Memory = append(erasure.Memory, "conversation:"+session)
+			}
+		}
+	}
+
+	// The profile holds the user's preferences and learned traits
+	if *userID != "" {
+		if profiles, err := profile.Open(appCfg.Profiles, appCfg.Storage.Path); err != nil {
+			failures = append(failures, "profile: "+err.Error())
+		} else if err := profiles.Delete(ctx, *userID); err != nil {
+			failures = append(failures, "profile: "+err.Error())
+		} else {
+			erasure.Profile = true
+		}
+	}
+
+	if ledger, err := usage.OpenLedger(appCfg.Usage.Path); err != nil {
+		failures = append(failures, "usage ledger: "+err.Error())
+	} else if erasure.UsageRecords, err = ledger.Forget(*userID, sessions); err != nil {
+		failures = append(failures, "usage ledger: "+err.Error())
+	}
+
+	if appCfg.LLMCache.Enabled && appCfg.LLMCache.Backend == llmcache.BackendRedis {
+		if store, err := llmcache.Open(appCfg.LLMCache); err != nil {
+			failures = append(failures, "llm cache: "+err.Error())
+		} else {
+			defer store.Close()
+			if erasure.CacheEntries, err = store.Flush(ctx); err != nil {
+				failures = append(failures, "llm cache: "+err.Error())
+			}
+		}
+	}
+
+	erased := "session " + *sessionID
+	if *userID != "" {
+		erased = "user " + *userID
+	}
+	erasure.Error = strings.Join(failures, "; ")
+	if err := calls.RecordErasure(ctx, erasure); err != nil {
+		return fmt.Errorf("erased %s but failed to record it: %w", erased, err)
+	}
+	fmt.Printf("Erased %s: %d runs, %d checkpoints, %d tool calls redacted, %d memory sessions cleared, %d usage records anonymized, %d cached replies flushed",
+		erased, erasure.Runs, erasure.Checkpoints, erasure.ToolCalls, len(erasure.Memory), erasure.UsageRecords, erasure.CacheEntries)
+	if erasure.Profile {
+		fmt.Print(", profile deleted")
+	}
+	fmt.Println()
+	if erasure.Error != "" {
+		return fmt.Errorf("erasure incomplete: %s", erasure.Error)
+	}
+	return nil
+}
+
+func complianceReportCommand(args []string) error {
+	fs := flag.NewFlagSet("compliance-report", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file with [compliance]")
+	since := fs.String("since", "", "start of the period (YYYY-MM-DD; default the last complete period)")
+	until := fs.String("until", "", "end of the period, exclusive (YYYY-MM-DD; default since plus one period)")
+	out := fs.String("o", "", "bundle directory (default under the configured dir)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := core.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	appCfg, err := appconfig.Load(*configPath)
+	if err != nil {
+		return err
+	}
+	runs, err := openRuns(appCfg)
+	if err != nil {
+		return err
+	}
+	calls, err := audit.Open(appCfg.Audit)
+	if err != nil {
+		return err
+	}
+	gen, err := compliance.New(appCfg.Compliance, calls, runs, encryptionInventory(cfg, appCfg))
+	if err != nil {
+		return err
+	}
+
+	current, _ := gen.Period(time.Now())
+	from, to := gen.Period(current.Add(-time.Nanosecond))
+	if *since != "" {
+		if from, err = time.Parse("2006-01-02", *since); err != nil {
+			return fmt.Errorf("invalid -since: %w", err)
+		}
+		_, to = gen.Period(from)
+	}
+	if *until != "" {
+		if to, err = time.Parse("2006-01-02", *until); err != nil {
+			return fmt.Errorf("invalid -until: %w", err)
+		}
+	}
+	if !from.Before(to) {
+		return fmt.Errorf("-since must be before -until")
+	}
+	dir := *out
+	if dir == "" {
+		dir = gen.Dir(from, to)
+	}
+	m, err := gen.Generate(context.Background(), from, to, dir)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d files to %s for %s – %s\n", len(m.Files)+1, dir, m.Since.Format("2006-01-02"), m.Until.Format("2006-01-02"))
+	return nil
+}
+
+func snapshotCommand(args []string) error {
+	fs := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file for the pipeline")
+	input := fs.String("input", "", "input to run")
+	route := fs.String("route", "processor", "entry agent")
+	name := fs.String("name", "", "fixture name (default derived from the input)")
+	out := fs.String("o", "", "fixture directory (default fixtures/<name>)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *input == "" {
+		return fmt.Errorf("-input is required")
+	}
+	if *name == "" {
+		*name = slug(*input)
+	}
+	if *out == "" {
+		*out = filepath.Join("fixtures", *name)
+	}
+
+	scratch, err := os.MkdirTemp("", "agentflow-snapshot-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(scratch)
+	rec := &fixture.Recorder{}
+	app, err := buildApp(*configPath, appOverrides{record: rec.Middleware(), scratch: scratch})
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+	f := &fixture.Fixture{Name: *name, Route: *route, SessionID: "fixture-" + *name, Input: *input, RecordedAt: time.Now()}
+	if err := runFixture(context.Background(), app, f); err != nil {
+		return err
+	}
+	f.Calls = rec.Calls()
+	if err := fixture.Save(*out, f, *configPath); err != nil {
+		return err
+	}
+	fmt.Printf("Recorded %s: %s, %d provider calls → %s\n", f.Name, strings.Join(f.Agents, " → "), len(f.Calls), *out)
+	return nil
+}
+
+func debugCommand(args []string) error {
+	fs := flag.NewFlagSet("debug", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file for the pipeline")
+	input := fs.String("input", "", "input to run")
+	route := fs.String("route", "processor", "entry agent")
+	session := fs.String("session", "", "session ID (default a new one)")
+	var breaks repeated
+	fs.Var(&breaks, "break", `pause only at this breakpoint: "AGENT", "if CONDITION" or "AGENT if CONDITION" (repeatable)`)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *input == "" {
+		return fmt.Errorf("-input is required")
+	}
+	if *session == "" {
+		*session = "debug-" + core.GenerateSessionID()
+	}
+
+	dbg := debugger.New(os.Stdin, os.Stdout)
+	for _, spec := range breaks {
+		if _, err := dbg.Breakpoints().Parse(spec); err != nil {
+			return err
+		}
+	}
+	app, err := buildApp(*configPath, appOverrides{debug: dbg})
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+	ctx := context.Background()
+	app.runner.Start(ctx)
+	defer app.runner.Stop()
+
+	if len(breaks) == 0 {
+		fmt.Println("Before each agent and provider call: Enter continues, h lists commands.")
+	} else {
+		fmt.Println("At each breakpoint: Enter continues, h lists commands.")
+	}
+	// Pauses last as long as the developer takes
+	pipeline := &simulate.RunnerPipeline{Runner: app.runner, Runs: app.runs, Route: *route, Timeout: 24 * time.Hour}
+	reply, err := pipeline.Ask(ctx, *session, *input, nil)
+	if reply.RunID == "" {
+		return err
+	}
+	run, getErr := app.runs.Get(ctx, reply.RunID)
+	if getErr != nil {
+		if err != nil {
+			return err
+		}
+		return getErr
+	}
+	if run.Status == history.StatusFailed {
+		return fmt.Errorf("run %s failed: %s", run.ID, run.Error)
+	}
+	// The formatter has printed the final response
+	fmt.Printf("\n■ run %s %s\n", run.ID, run.Status)
+	return nil
+}
+
+// startServices starts what a long-running process runs besides taking
+// events: the runner, re-emitting the queued events and resuming the runs
+// a stop interrupted, and the background jobs and admin API configured.
+// It returns the func stopping the runner.
+func startServices(ctx context.Context, app *application) func() {
+	app.runner.Start(ctx)
+	if app.queue != nil {
+		if n, err := app.queue.Recover(); err != nil {
+			log.Printf("Failed to recover queued events: %v", err)
+		} else if n > 0 {
+			log.Printf("Re-emitted %d queued events", n)
+		}
+	}
+	resumeRuns(ctx, app)
+	if app.state != nil {
+		go app.state.Run(ctx, app.runner, runsLocally(app), app.appCfg.StateStore.RestoreOnStart)
+	}
+	if app.refresher != nil {
+		go app.refresher.Run(ctx)
+	}
+	if app.mover != nil {
+		go app.mover.Run(ctx)
+	}
+	if app.maintainer != nil {
+		go app.maintainer.Run(ctx)
+	}
+	if app.admin != nil {
+		serveAdmin(ctx, app)
+	}
+	return app.runner.Stop
+}
+
+func workerCommand(args []string) error {
+	fs := flag.NewFlagSet("worker", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file with [event_bus]")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return runWithConfig(func(ctx context.Context) error {
+		app, err := buildApp(*configPath, appOverrides{mcp: true})
+		if err != nil {
+			return err
+		}
+		defer app.Close()
+		if app.events == nil {
+			return fmt.Errorf("%s has no [event_bus] backend to take events from", *configPath)
+		}
+		defer startServices(ctx, app)()
+
+		var local []string
+		for name := range app.agents {
+			if app.events.Runs(name) {
+				local = append(local, name)
+			}
+		}
+		sort.Strings(local)
+		fmt.Printf("Running %s from the %s event bus; Ctrl-C stops\n", strings.Join(local, ", "), app.appCfg.EventBus.Backend)
+		app.events.Run(ctx, app.runner)
+		return nil
+	})
+}
+
+func serveCommand(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file with [http]")
+	addr := fs.String("addr", "", "listen address, replacing [http] addr")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	return runWithConfig(func(ctx context.Context) error {
+		app, err := buildApp(*configPath, appOverrides{mcp: true})
+		if err != nil {
+			return err
+		}
+		defer app.Close()
+		cfg := app.appCfg.HTTP
+		if *addr != "" {
+			cfg.Addr = *addr
+		}
+		server, err := httpserver.New(cfg, app.runner, app.recorder, app.quotas, app.streams)
+		if err != nil {
+			return err
+		}
+		if app.profiles != nil {
+			server.Mount("/profile", app.profiles.SelfHandler(app.runs))
+			server.Mount("/profile/", app.profiles.SelfHandler(app.runs))
+		}
+		if app.vectors != nil {
+			sources := app.vectors.SourceHandler(app.runs)
+			server.Mount("GET /sources/{citation}", sources)
+			server.Mount("GET /events/{id}/citations/{n}", sources)
+		}
+		defer startServices(ctx, app)()
+		if app.events != nil {
+			go app.events.Run(ctx, app.runner)
+		}
+
+		fmt.Printf("Serving the pipeline on http://%s; Ctrl-C stops\n", cmp.Or(cfg.Addr, "127.0.0.1:8080"))
+		return server.ListenAndServe(ctx)
+	})
+}
+
+// runWithConfig runs run until the process is interrupted. When the config
+// comes from AGENTFLOW_CONFIG_URL, a change to it stops run and starts it
+// again on the new config, so a fleet picks up an edit without a redeploy.
+func runWithConfig(run func(ctx context.Context) error) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if remote == nil {
+		return run(ctx)
+	}
+	for {
+		runCtx, restart := context.WithCancel(ctx)
+		changed := false
+		watched := make(chan struct{})
+		go func() {
+			defer close(watched)
+			appconfig.Watch(runCtx, remote.src, configPollInterval(), remote.version, func(data []byte, version string, err error) {
+				if err != nil {
+					log.Printf("Remote config poll failed: %v", err)
+					return
+				}
+				if _, err := appconfig.Parse(data); err != nil {
+					log.Printf("Ignoring invalid remote config: %v", err)
+					return
+				}
+				if err := remote.replace(data, version); err != nil {
+					log.Printf("Failed to apply remote config: %v", err)
+					return
+				}
+				log.Printf("Remote config changed; restarting")
+				changed = true
+				restart()
+			})
+		}()
+		err := run(runCtx)
+		restart()
+		<-watched
+		if err != nil || !changed || ctx.Err() != nil {
+			return err
+		}
+	}
+}
+
+func driftCommand(args []string) error {
+	fs := flag.NewFlagSet("drift", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file with [drift]")
+	alerts := fs.Int("alerts", 10, "recent alerts to list")
+	rebaseline := fs.Bool("rebaseline", false, "accept the current responses: the next ones become the new baseline")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	app, err := newApp(*configPath)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+	if app.drift == nil {
+		return fmt.Errorf("%s doesn't enable [drift]", *configPath)
+	}
+	ctx := context.Background()
+	if *rebaseline {
+		if err := app.drift.Rebaseline(ctx); err != nil {
+			return err
+		}
+		fmt.Println("The next responses become the new baseline")
+		return nil
+	}
+
+	st := app.drift.Status()
+	since := "the first responses"
+	if !st.BaselineSince.IsZero() {
+		since = "responses since " + st.BaselineSince.Format(time.DateTime)
+	}
+	fmt.Printf("Baseline: %d of %d samples (%s)\n", st.BaselineSamples, st.BaselineSize, since)
+	fmt.Printf("Window:   %d of %d samples\n", st.WindowSamples, st.Window)
+	if st.Measure != nil {
+		state := "in line with the baseline"
+		if st.Drifted {
+			state = "DRIFTED"
+		}
+		fmt.Printf("Centroid distance %.4f, shift %.2f: %s\n", st.Measure.CentroidDistance, st.Measure.Shift, state)
+	}
+	list, err := app.drift.Alerts(ctx, *alerts)
+	if err != nil {
+		return err
+	}
+	if len(list) > 0 {
+		fmt.Println("\nAlerts:")
+	}
+	for _, a := range list {
+		what := "back in line"
+		if a.Drifted {
+			what = "drifted"
+		}
+		fmt.Printf("  %s  %-12s distance %.4f, shift %.2f (run %s)\n", a.At.Format(time.DateTime), what, a.Measure.CentroidDistance, a.Measure.Shift, a.LastRun)
+	}
+	return nil
+}
+
+func qualityCommand(args []string) error {
+	fs := flag.NewFlagSet("quality", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file with [quality]")
+	days := fs.Int("days", 14, "days of the trend to show")
+	alerts := fs.Int("alerts", 10, "recent alerts to list")
+	score := fs.String("score", "", "score this day's runs (YYYY-MM-DD, UTC) now")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	app, err := newApp(*configPath)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+	if app.quality == nil {
+		return fmt.Errorf("%s doesn't enable [quality]", *configPath)
+	}
+	ctx := context.Background()
+	if *score != "" {
+		day, err := app.quality.ScoreDay(ctx, *score)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Scored %d runs of %s\n", day.Samples, day.Day)
+	}
+
+	trend, err := app.quality.Trend(ctx, *days)
+	if err != nil {
+		return err
+	}
+	baseline, err := app.quality.Baseline(ctx, time.Now().UTC().Format(quality.DayLayout))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%-10s  %7s", "day", "samples")
+	for _, axis := range quality.Axes {
+		fmt.Printf("  %11s", axis)
+	}
+	fmt.Println()
+	row := func(day string, samples int, means map[string]float64, degraded []string) {
+		fmt.Printf("%-10s  %7d", day, samples)
+		if samples == 0 {
+			fmt.Println("  no completed runs")
+			return
+		}
+		for _, axis := range quality.Axes {
+			mark := ""
+			if slices.Contains(degraded, axis) {
+				mark = " !"
+			}
+			fmt.Printf("  %11s", fmt.Sprintf("%.2f%s", means[axis], mark))
+		}
+		fmt.Println()
+	}
+	for _, d := range trend {
+		row(d.Day, d.Samples, d.Means, d.Degraded)
+	}
+	if baseline.Samples > 0 {
+		row("baseline", baseline.Samples, baseline.Means, nil)
+	}
+	if len(trend) == 0 {
+		fmt.Println("No scored days yet")
+	}
+
+	list, err := app.quality.Alerts(ctx, *alerts)
+	if err != nil {
+		return err
+	}
+	if len(list) > 0 {
+		fmt.Println("\nAlerts:")
+	}
+	for _, a := range list {
+		parts := make([]string, len(a.Axes))
+		for i, d := range a.Axes {
+			parts[i] = fmt.Sprintf("%s %.2f (baseline %.2f)", d.Axis, d.Mean, d.Baseline)
+		}
+		fmt.Printf("  %s  %s degraded: %s\n", a.At.Format(time.DateTime), a.Day, strings.Join(parts, ", "))
+	}
+	return nil
+}
+
+func savedRunsCommand(args []string) error {
+	fs := flag.NewFlagSet("saved-runs", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file with [state_store]")
+	drop := fs.String("drop", "", "delete this run's saved state, so it isn't restored")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	app, err := newApp(*configPath)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+	if app.state == nil {
+		return fmt.Errorf("%s has no [state_store] backend", *configPath)
+	}
+	ctx := context.Background()
+	store := app.state.Store()
+	if *drop != "" {
+		if err := store.Delete(ctx, *drop); err != nil {
+			return err
+		}
+		fmt.Printf("Dropped the saved state of run %s\n", *drop)
+		return nil
+	}
+	snapshots, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+	if len(snapshots) == 0 {
+		fmt.Println("No saved runs")
+		return nil
+	}
+	for _, s := range snapshots {
+		fmt.Printf("%s  %s  after %s, next %s (%d keys)\n", s.UpdatedAt.Format(time.DateTime), s.RunID, s.Agent, s.Route, len(s.State))
+	}
+	return nil
+}
+
+func deadLettersCommand(args []string) error {
+	fs := flag.NewFlagSet("dead-letters", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file with [dead_letter]")
+	id := fs.String("id", "", "show the letter of this event, with its data and state")
+	agent := fs.String("agent", "", "only letters of this agent")
+	runID := fs.String("run", "", "only letters of this run")
+	limit := fs.Int("limit", 50, "most recent letters listed (0 for all)")
+	redrive := fs.Bool("redrive", false, "re-emit the -id letter, or every letter listed, and wait for the runs")
+	drop := fs.Bool("drop", false, "drop the -id letter without re-emitting it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *drop && *id == "" {
+		return fmt.Errorf("-drop needs -id")
+	}
+	ctx := context.Background()
+
+	if *redrive {
+		// Redriven events run on this process's pipeline
+		app, err := newApp(*configPath)
+		if err != nil {
+			return err
+		}
+		defer app.Close()
+		if app.dead == nil {
+			return fmt.Errorf("the dead-letter queue is not enabled in %s", *configPath)
+		}
+		ids := []string{*id}
+		if *id == "" {
+			letters, err := app.dead.List(ctx, deadletter.Filter{Agent: *agent, RunID: *runID, Limit: *limit})
+			if err != nil {
+				return err
+			}
+			ids = ids[:0]
+			for _, l := range letters {
+				ids = append(ids, l.ID)
+			}
+		}
+		app.runner.Start(ctx)
+		defer app.runner.Stop()
+		failed := 0
+		for _, id := range ids {
+			event, err := app.dead.Redrive(ctx, id, app.runner)
+			if event == nil {
+				return err
+			}
+			runID, _ := event.GetMetadataValue(history.RunIDKey)
+			waitCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+			c, err := app.recorder.WaitForCompletion(waitCtx, runID)
+			cancel()
+			if err == nil {
+				err = c.Err()
+			}
+			if err != nil {
+				failed++
+				fmt.Printf("%s  run %s failed again: %v\n", id, runID, err)
+				continue
+			}
+			fmt.Printf("%s  run %s %s\n", id, runID, c.Run.Status)
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d of %d redriven events failed again", failed, len(ids))
+		}
+		return nil
+	}
+
+	appCfg, err := appconfig.Load(*configPath)
+	if err != nil {
+		return err
+	}
+	runs, err := openRuns(appCfg)
+	if err != nil {
+		return err
+	}
+	dead, err := deadletter.Open(appCfg.DeadLetter, runs)
+	if err != nil {
+		return err
+	}
+	if *drop {
+		return dead.Delete(ctx, *id)
+	}
+	if *id != "" {
+		l, err := dead.Get(ctx, *id)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(l)
+	}
+	letters, err := dead.List(ctx, deadletter.Filter{Agent: *agent, RunID: *runID, Limit: *limit})
+	if err != nil {
+		return err
+	}
+	if len(letters) == 0 {
+		fmt.Println("No dead letters.")
+		return nil
+	}
+	for _, l := range letters {
+		fmt.Printf("%s  %s  %-12s %d attempts  run %s  %s\n", l.ID, l.FailedAt.Format("2006-01-02 15:04:05"), l.Agent, l.Attempts, l.RunID, l.Error)
+	}
+	return nil
+}
+
+func reformatCommand(args []string) error {
+	fs := flag.NewFlagSet("reformat", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file locating the run history")
+	runID := fs.String("run", "", "run whose enhanced content to format again")
+	agent := fs.String("agent", reformat.DefaultAgent, "formatting agent to re-run")
+	var opts reformat.Options
+	fs.IntVar(&opts.MaxWords, "max-words", 0, "at most this many words")
+	fs.Float64Var(&opts.ReadingLevel, "reading-level", 0, "at or below this US grade reading level")
+	fs.StringVar(&opts.Format, "format", "", `"bullets" for a bullet list`)
+	fs.StringVar(&opts.Tone, "tone", "", `tone of voice, e.g. "casual" or "formal"`)
+	fs.StringVar(&opts.Locale, "locale", "", "locale to format for")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *runID == "" {
+		return fmt.Errorf("-run is required")
+	}
+	app, err := newApp(*configPath)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	app.runner.Start(ctx)
+	defer app.runner.Stop()
+
+	result, err := reformat.New(app.recorder, app.runner, *agent).Reformat(ctx, *runID, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Run %s (from %s):\n\n%s\n", result.RunID, *runID, result.Response)
+	if unmet, ok := result.State["constraint_violations"]; ok {
+		fmt.Printf("\nUnmet constraints: %v\n", unmet)
+	}
+	return nil
+}
+
+func preferencesCommand(args []string) error {
+	fs := flag.NewFlagSet("preferences", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file locating the profile store")
+	user := fs.String("user", "", "user whose preferences to show or set")
+	var update profile.Preferences
+	fs.StringVar(&update.Verbosity, "verbosity", "", fmt.Sprintf("one of %s", strings.Join(profile.Verbosities, ", ")))
+	fs.StringVar(&update.Format, "format", "", fmt.Sprintf("one of %s", strings.Join(profile.Formats, ", ")))
+	fs.StringVar(&update.Expertise, "expertise", "", fmt.Sprintf("one of %s", strings.Join(profile.Expertises, ", ")))
+	reset := fs.Bool("clear", false, "drop the preferences set before the ones given")
+	forget := fs.Bool("forget-traits", false, "drop the traits learned from the user's feedback")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *user == "" {
+		return fmt.Errorf("-user is required")
+	}
+	cfg, err := appconfig.Load(*configPath)
+	if err != nil {
+		return err
+	}
+	store, err := profile.Open(cfg.Profiles, cfg.Storage.Path)
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	if *forget {
+		if err := store.ForgetTraits(ctx, *user); err != nil {
+			return err
+		}
+	}
+	p, err := store.Get(ctx, *user)
+	if err != nil {
+		return err
+	}
+	if *reset || !update.Empty() {
+		prefs := update
+		if !*reset {
+			prefs = p.Preferences.Merge(update)
+		}
+		if p, err = store.SetPreferences(ctx, *user, prefs); err != nil {
+			return err
+		}
+	}
+	if p.Preferences.Empty() {
+		fmt.Printf("%s has no preferences; responses are sent as the formatter writes them\n", *user)
+	} else {
+		fmt.Printf("Verbosity: %s\nFormat:    %s\nExpertise: %s\n", cmp.Or(p.Preferences.Verbosity, "-"), cmp.Or(p.Preferences.Format, "-"), cmp.Or(p.Preferences.Expertise, "-"))
+	}
+	if len(p.Traits) > 0 {
+		fmt.Printf("Learned from %d pieces of feedback:\n", p.Feedback)
+		for _, name := range []string{profile.Verbosity, profile.Format, profile.Expertise} {
+			if t, ok := p.Traits[name]; ok {
+				fmt.Printf("  %-10s %s (score %.1f, %.0f%% confidence)\n", name+":", t.Value, t.Score, t.Confidence*100)
+			}
+		}
+	} else if p.Feedback > 0 {
+		fmt.Printf("Nothing learned yet from %d pieces of feedback\n", p.Feedback)
+	}
+	if !cfg.Personalization.Enabled && !cfg.Profiles.Enabled {
+		fmt.Printf("(%s enables neither [profiles] nor [personalization], so the profile isn't used)\n", *configPath)
+	}
+	return nil
+}
+
+func rerunCommand(args []string) error {
+	fs := flag.NewFlagSet("rerun", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file locating the run history")
+	runID := fs.String("run", "", "run to re-run")
+	from := fs.String("from", "", "agent to re-run from, on what the agents before it produced")
+	step := fs.Int("step", -1, "re-run from the agent this step routed to, instead of -from")
+	version := fs.String("deployment", "", "workflow version to re-run with (a version ID, or <workflow>@<version>)")
+	var set repeated
+	fs.Var(&set, "set", "request metadata key=value replacing the run's (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *runID == "" || (*from == "") == (*step < 0) {
+		return fmt.Errorf("-run and one of -from or -step are required")
+	}
+	metadata, err := set.pairs("-set")
+	if err != nil {
+		return err
+	}
+
+	app, err := newApp(*configPath)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+	defer cancel()
+	run, err := app.runs.Get(ctx, *runID)
+	if err != nil {
+		return fmt.Errorf("run %s: %w", *runID, err)
+	}
+	if *version != "" {
+		// Versions belong to the workflow the run started at, wherever the
+		// rerun starts
+		if !strings.Contains(*version, "@") {
+			*version = run.Metadata[core.RouteMetadataKey] + "@" + *version
+		}
+		metadata[deploy.VersionKey] = *version
+	}
+	var event core.Event
+	if *step >= 0 {
+		event, err = history.RerunStepEvent(run, *step, metadata)
+	} else {
+		event, err = history.RerunEvent(run, *from, metadata)
+	}
+	if err != nil {
+		return err
+	}
+
+	app.runner.Start(ctx)
+	defer app.runner.Stop()
+	fmt.Printf("Re-running %s from %s\n", run.ID, event.GetTargetAgentID())
+	result, err := app.recorder.ProcessSync(ctx, app.runner, event)
+	if result.Run == nil {
+		return err
+	}
+	fmt.Printf("\nRun %s %s\n\nOriginal response:\n%s\n\nNew response:\n%s\n", result.RunID, result.Status, run.FinalResponse, result.Response)
+	return err
+}
+
+func backfillCommand(args []string) error {
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file locating the run history")
+	workflow := fs.String("workflow", "", "only runs of this workflow")
+	session := fs.String("session", "", "only runs of this session")
+	status := fs.String("status", history.StatusCompleted, "only runs with this status")
+	since := fs.String("since", "", "only runs started on or after this day (YYYY-MM-DD)")
+	until := fs.String("until", "", "only runs started before this day (YYYY-MM-DD)")
+	limit := fs.Int("limit", 0, "only the most recent runs selected, this many (0 for all)")
+	runIDs := fs.String("runs", "", "comma-separated runs to re-run instead of filtering")
+	from := fs.String("from", "", "re-run from this agent on the earlier agents' results (default from the input)")
+	version := fs.String("deployment", "", "workflow version to re-run with, as <workflow>@<version> (default the active one)")
+	var set repeated
+	fs.Var(&set, "set", "request metadata key=value replacing the runs' (repeatable)")
+	concurrency := fs.Int("concurrency", backfill.DefaultConcurrency, "runs re-run at once")
+	maxTokens := fs.Int("max-tokens", 0, "stop starting runs after the re-runs used this many tokens (needs [usage])")
+	maxCost := fs.Float64("max-cost", 0, "stop starting runs after the re-runs cost this much (needs [usage])")
+	timeout := fs.Duration("timeout", 5*time.Minute, "time limit for each run")
+	dryRun := fs.Bool("dry-run", false, "list the runs that would be re-run, with what they cost, without re-running them")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	metadata, err := set.pairs("-set")
+	if err != nil {
+		return err
+	}
+	if *version != "" {
+		if !strings.Contains(*version, "@") {
+			return fmt.Errorf("-deployment %q: want <workflow>@<version>", *version)
+		}
+		metadata[deploy.VersionKey] = *version
+	}
+	opts := backfill.Options{
+		Filter:      history.Filter{SessionID: *session, Status: *status, Limit: *limit},
+		Workflow:    *workflow,
+		From:        *from,
+		Metadata:    metadata,
+		Concurrency: *concurrency,
+		MaxTokens:   *maxTokens,
+		MaxCost:     *maxCost,
+		Timeout:     *timeout,
+	}
+	if *runIDs != "" {
+		opts.RunIDs = strings.Split(*runIDs, ",")
+	}
+	if *since != "" {
+		if opts.Filter.Since, err = time.Parse(time.DateOnly, *since); err != nil {
+			return fmt.Errorf("invalid -since: %w", err)
+		}
+	}
+	if *until != "" {
+		if opts.Filter.Until, err = time.Parse(time.DateOnly, *until); err != nil {
+			return fmt.Errorf("invalid -until: %w", err)
+		}
+	}
+
+	// Re-runs are recorded and metered, but reach no memory, sink, cache
+	// or bill the originals did
+	app, err := buildApp(*configPath, appOverrides{quiet: true})
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	b := backfill.New(app.recorder, app.runner, app.usage)
+
+	if *dryRun {
+		runs, err := b.Select(ctx, opts)
+		if err != nil {
+			return err
+		}
+		var spent backfill.Spend
+		for _, run := range runs {
+			line := fmt.Sprintf("%s  %s  %-10s %.60q", run.ID, run.StartedAt.Format(time.DateTime), run.Status, run.Input)
+			if app.usage != nil {
+				s := b.Spent(run.ID, run.StartedAt, run.EndedAt)
+				spent.Tokens += s.Tokens
+				spent.Cost += s.Cost
+				line += fmt.Sprintf("  (%d tokens, %.4f)", s.Tokens, s.Cost)
+			}
+			fmt.Println(line)
+		}
+		fmt.Printf("\n%d runs would be re-run", len(runs))
+		if app.usage != nil {
+			fmt.Printf("; they originally used %d tokens costing %.4f", spent.Tokens, spent.Cost)
+		}
+		fmt.Println()
+		return nil
+	}
+
+	app.runner.Start(ctx)
+	defer app.runner.Stop()
+	report, err := b.Run(ctx, opts)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	for _, r := range report.Results {
+		switch {
+		case r.Skipped != "":
+			fmt.Printf("- %s skipped: %s\n", r.RunID, r.Skipped)
+			continue
+		case r.Status != history.StatusCompleted:
+			fmt.Printf("✗ %s → %s %s: %s\n", r.RunID, r.NewRunID, r.Status, r.Error)
+		case r.Changed:
+			fmt.Printf("≠ %s → %s changed\n", r.RunID, r.NewRunID)
+		default:
+			fmt.Printf("= %s → %s unchanged\n", r.RunID, r.NewRunID)
+		}
+		if a, b := strings.Join(r.OriginalAgents, " → "), strings.Join(r.Agents, " → "); a != b && len(r.Agents) > 0 {
+			fmt.Printf("    agents: %s, was %s\n", b, a)
+		}
+		if r.Difference != "" {
+			fmt.Printf("    response %s\n", r.Difference)
+		}
+		fmt.Printf("    %s, was %s", r.Duration.Round(time.Millisecond), r.OriginalTime.Round(time.Millisecond))
+		if r.Spend != nil && r.OriginalSpend != nil {
+			fmt.Printf("; %d tokens costing %.4f, was %d costing %.4f", r.Spend.Tokens, r.Spend.Cost, r.OriginalSpend.Tokens, r.OriginalSpend.Cost)
+		}
+		fmt.Println()
+	}
+	fmt.Printf("\n%d selected: %d completed, %d failed, %d skipped; %d changed\n", report.Selected, report.Completed, report.Failed, report.Skipped, report.Changed)
+	if report.Spend != nil {
+		fmt.Printf("Re-runs used %d tokens costing %.4f\n", report.Spend.Tokens, report.Spend.Cost)
+	}
+	if report.Stopped != "" {
+		fmt.Printf("Stopped early: %s\n", report.Stopped)
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d re-runs failed", report.Failed, report.Selected)
+	}
+	return nil
+}
+
+func runStateCommand(args []string) error {
+	fs := flag.NewFlagSet("run-state", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file locating the run history")
+	runID := fs.String("run", "", "run ID (required)")
+	step := fs.Int("step", -1, "show the state after this step (0-based; default the end)")
+	at := fs.String("at", "", "show the state at this time (RFC 3339)")
+	key := fs.String("key", "", "list every change to this key instead")
+	provenance := fs.Bool("provenance", false, "list which agent wrote each key's value instead (with -step, as of that step)")
+	conflicts := fs.Bool("conflicts", false, "list the keys parallel agents wrote different values for instead")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *runID == "" {
+		return fmt.Errorf("-run is required")
+	}
+	appCfg, err := appconfig.Load(*configPath)
+	if err != nil {
+		return err
+	}
+	runs, err := openRuns(appCfg)
+	if err != nil {
+		return err
+	}
+	run, err := runs.Get(context.Background(), *runID)
+	if err != nil {
+		return fmt.Errorf("run %s: %w", *runID, err)
+	}
+
+	if *key != "" {
+		changes := run.History(*key)
+		if len(changes) == 0 {
+			fmt.Printf("%s was never set in run %s\n", *key, run.ID)
+			return nil
+		}
+		for _, m := range changes {
+			value, _ := json.Marshal(m.Value)
+			fmt.Printf("step %d  %s  %-12s %-6s %s\n", m.Step, m.At.Format("15:04:05.000"), m.Agent, m.Source, value)
+			printCandidates(m)
+		}
+		return nil
+	}
+	if *conflicts {
+		list := run.Conflicts()
+		if len(list) == 0 {
+			fmt.Printf("No conflicting parallel writes in run %s\n", run.ID)
+		}
+		for _, m := range list {
+			fmt.Printf("step %d  %s: kept %s's value\n", m.Step, m.Key, m.Agent)
+			printCandidates(m)
+		}
+		return nil
+	}
+	if *provenance {
+		upTo := len(run.Steps)
+		if *step >= len(run.Steps) {
+			return fmt.Errorf("run %s has steps 0 to %d", run.ID, len(run.Steps)-1)
+		} else if *step >= 0 {
+			upTo = *step
+		}
+		writes := run.Provenance(upTo)
+		keys := make([]string, 0, len(writes))
+		for k := range writes {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			m := writes[k]
+			fmt.Printf("%-24s %-12s step %d  %s\n", k, m.Agent, m.Step, m.Source)
+		}
+		return nil
+	}
+	var state map[string]any
+	switch {
+	case *at != "":
+		t, err := time.Parse(time.RFC3339Nano, *at)
+		if err != nil {
+			return err
+		}
+		state = run.StateAt(t)
+	case *step >= len(run.Steps):
+		return fmt.Errorf("run %s has steps 0 to %d", run.ID, len(run.Steps)-1)
+	case *step >= 0:
+		state = run.State(*step)
+	default:
+		state = run.State(len(run.Steps))
+	}
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(state)
+}
+
+// printCandidates lists the values parallel agents wrote for m's key.
+func printCandidates(m history.Mutation) {
+	for _, c := range m.Candidates {
+		value, _ := json.Marshal(c.Value)
+		fmt.Printf("    %-12s %s\n", c.Agent, value)
+	}
+}
+
+func replayCommand(args []string) error {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the reports as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	dirs := fs.Args()
+	if len(dirs) == 0 {
+		matches, _ := filepath.Glob(filepath.Join("fixtures", "*", fixture.RunFile))
+		for _, m := range matches {
+			dirs = append(dirs, filepath.Dir(m))
+		}
+	}
+	if len(dirs) == 0 {
+		return fmt.Errorf("no fixtures given and none under fixtures/")
+	}
+
+	var reports []*fixture.Report
+	drifted := 0
+	for _, dir := range dirs {
+		report, err := replayFixture(context.Background(), dir)
+		if err != nil {
+			return fmt.Errorf("%s: %w", dir, err)
+		}
+		reports = append(reports, report)
+		if len(report.Drifts) > 0 {
+			drifted++
+		}
+		if *asJSON {
+			continue
+		}
+		if len(report.Drifts) == 0 {
+			fmt.Printf("✓ %s\n", report.Name)
+			continue
+		}
+		fmt.Printf("✗ %s\n", report.Name)
+		for _, d := range report.Drifts {
+			fmt.Printf("    %s\n", d)
+		}
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(reports); err != nil {
+			return err
+		}
+	}
+	if drifted > 0 {
+		return fmt.Errorf("%d of %d fixtures drifted", drifted, len(reports))
+	}
+	return nil
+}
+
+// replayFixture runs the fixture in dir on its own config with its recorded
+// responses standing in for the providers. Tests can replay fixtures with
+//
+//	report, err := replayFixture(ctx, "fixtures/refund-request")
+//	fixture.Expect(t, report)
+func replayFixture(ctx context.Context, dir string) (*fixture.Report, error) {
+	want, err := fixture.Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	scratch, err := os.MkdirTemp("", "agentflow-replay-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(scratch)
+	replayer := fixture.NewReplayer(want.Calls)
+	app, err := buildApp(filepath.Join(dir, fixture.ConfigFile), appOverrides{llm: replayer, scratch: scratch})
+	if err != nil {
+		return nil, err
+	}
+	defer app.Close()
+	got := &fixture.Fixture{Name: want.Name, Route: want.Route, SessionID: want.SessionID, Input: want.Input, Metadata: want.Metadata}
+	if err := runFixture(ctx, app, got); err != nil {
+		return nil, err
+	}
+	drifts := append(replayer.Drifts(), fixture.Compare(want, got)...)
+	return &fixture.Report{Name: want.Name, Drifts: drifts}, nil
+}
+
+// runFixture sends f's input through app and fills in the outcome.
+func runFixture(ctx context.Context, app *application, f *fixture.Fixture) error {
+	app.runner.Start(ctx)
+	defer app.runner.Stop()
+	pipeline := &simulate.RunnerPipeline{Runner: app.runner, Runs: app.runs, Route: f.Route}
+	reply, err := pipeline.Ask(ctx, f.SessionID, f.Input, f.Metadata)
+	if reply.RunID == "" {
+		return err
+	}
+	// A failed run is an outcome to compare, not an error
+	run, getErr := app.runs.Get(ctx, reply.RunID)
+	if getErr != nil {
+		if err != nil {
+			return err
+		}
+		return getErr
+	}
+	f.Status, f.FinalResponse, f.Agents = run.Status, run.FinalResponse, nil
+	for _, step := range run.Steps {
+		f.Agents = append(f.Agents, step.Agent)
+	}
+	return nil
+}
+
+// repeated collects every value of a flag given more than once.
+type repeated []string
+
+func (r *repeated) String() string { return strings.Join(*r, ", ") }
+
+func (r *repeated) Set(v string) error {
+	*r = append(*r, v)
+	return nil
+}
+
+// pairs parses key=value values, given with flag.
+func (r repeated) pairs(flag string) (map[string]string, error) {
+	out := make(map[string]string, len(r))
+	for _, kv := range r {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("%s %q: want key=value", flag, kv)
+		}
+		out[k] = v
+	}
+	return out, nil
+}
+
+var nonSlug = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slug makes a short directory name from text.
+func slug(text string) string {
+	s := strings.Trim(nonSlug.ReplaceAllString(strings.ToLower(text), "-"), "-")
+	if len(s) > 40 {
+		s = strings.TrimRight(s[:40], "-")
+	}
+	if s == "" {
+		s = "run"
+	}
+	return s
+}
+
+func vcrProxyCommand(args []string) error {
+	fs := flag.NewFlagSet("vcr-proxy", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file with [vcr]")
+	upstream := fs.String("upstream", "", "provider API to proxy, e.g. https://api.openai.com")
+	addr := fs.String("addr", "127.0.0.1:8089", "listen address")
+	mode := fs.String("mode", "", "record, replay or auto (default [vcr] mode, or auto)")
+	cassette := fs.String("cassette", "", "cassette name (default [vcr] cassette)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	target, err := url.Parse(*upstream)
+	if err != nil || target.Host == "" {
+		return fmt.Errorf("-upstream must be a URL like https://api.openai.com")
+	}
+	cfg, err := appconfig.Load(*configPath)
+	if err != nil {
+		return err
+	}
+	vcfg := cfg.VCR.WithEnv()
+	vcfg.Mode = cmp.Or(*mode, vcfg.Mode, vcr.ModeAuto)
+	vcfg.Cassette = cmp.Or(*cassette, vcfg.Cassette)
+	// Everything reaching the proxy is provider traffic
+	vcfg.Hosts = nil
+	t, err := vcr.New(vcfg, nil)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Addr: *addr, Handler: vcr.Proxy(t, target), ReadHeaderTimeout: 10 * time.Second}
+	fmt.Printf("Proxying %s on http://%s (%s, %d recorded interactions)\n", target, *addr, t.Mode, t.Cassette.Len())
+	return srv.ListenAndServe()
+}
+
+func doctorCommand(args []string) error {
+	fs := flag.NewFlagSet("doctor", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file to check")
+	timeout := fs.Duration("timeout", 30*time.Second, "time limit for each check")
+	asJSON := fs.Bool("json", false, "print the results as JSON")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	checks, cleanup := doctorChecks(*configPath)
+	defer cleanup()
+	results := doctor.Run(ctx, checks, *timeout)
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	} else {
+		doctor.Print(os.Stdout, results, doctor.Color(os.Stdout))
+	}
+	if n := doctor.Count(results, doctor.Fail); n > 0 {
+		return fmt.Errorf("%d of %d checks failed", n, len(results))
+	}
+	return nil
+}
+
+// doctorChecks lists the self-tests for the stack configured in
+// configPath. Providers and memory are opened directly rather than through
+// the app, so test calls are not metered or recorded as runs.
+func doctorChecks(configPath string) ([]doctor.Check, func()) {
+	var closers []func()
+	cleanup := func() {
+		for i := len(closers) - 1; i >= 0; i-- {
+			closers[i]()
+		}
+	}
+
+	cfg, err := core.LoadConfig(configPath)
+	if err != nil {
+		return []doctor.Check{doctor.Failed("config", configPath, err)}, cleanup
+	}
+	appCfg, err := appconfig.Load(configPath)
+	if err != nil {
+		return []doctor.Check{doctor.Failed("config", configPath, err)}, cleanup
+	}
+	checks := []doctor.Check{
+		{Group: "config", Name: configPath, Run: func(context.Context) (string, error) {
+			if appCfg.SchemaVersion < appconfig.SchemaVersion {
+				return "", doctor.Warning(fmt.Errorf("schema version %d (current is %d); run 'migrate-config'", appCfg.SchemaVersion, appconfig.SchemaVersion))
+			}
+			return fmt.Sprintf("parsed, schema version %d", appCfg.SchemaVersion), nil
+		}},
+		{Group: "config", Name: "agents", Run: func(context.Context) (string, error) {
+			// Building the app logs every component it wires; only the
+			// outcome matters here
+			log.SetOutput(io.Discard)
+			defer log.SetOutput(os.Stderr)
+			app, err := newApp(configPath)
+			if err != nil {
+				return "", err
+			}
+			closers = append(closers, app.Close)
+			return fmt.Sprintf("%d agents wired", len(app.agents)), nil
+		}},
+	}
+
+	// 🔌 Providers answer a test call, and Ollama has the models pulled
+	var models []doctor.Check
+	if _, ok := appCfg.Providers[di.DefaultProvider]; !ok {
+		if provider, err := defaultProvider(cfg, appCfg, nil); err != nil {
+			checks = append(checks, doctor.Failed("providers", di.DefaultProvider, err))
+		} else {
+			checks = append(checks, doctor.Provider(fmt.Sprintf("default (%s %s)", cfg.LLM.Provider, cfg.LLM.Model), provider))
+		}
+		if cfg.LLM.Provider == "ollama" {
+			models = append(models, doctor.OllamaModel(cmp.Or(appCfg.LLM.BaseURL, local.DefaultOllamaURL), cfg.LLM.Model))
+		}
+	}
+	names := make([]string, 0, len(appCfg.Providers))
+	for name := range appCfg.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		p := appCfg.Providers[name]
+		if provider, err := di.NewRouted(name, p, appCfg.ProviderOptions[name], nil); err != nil {
+			checks = append(checks, doctor.Failed("providers", name, err))
+		} else {
+			checks = append(checks, doctor.Provider(fmt.Sprintf("%s (%s %s)", name, p.Type, p.Model), provider))
+		}
+		if p.Type == "ollama" {
+			models = append(models, doctor.OllamaModel(cmp.Or(p.BaseURL, local.DefaultOllamaURL), p.Model))
+		}
+	}
+	checks = append(checks, models...)
+
+	// 🧠 Memory and the database the stores share
+	if cfg.AgentMemory.Provider == storage.MemoryProvider && cfg.AgentMemory.Connection == "" {
+		cfg.AgentMemory.Connection = appCfg.Storage.Path
+	}
+	if cfg.AgentMemory.Provider == "" {
+		checks = append(checks, doctor.Check{Group: "memory", Name: "read/write", Run: func(context.Context) (string, error) {
+			return "", doctor.Skipped("no [agent_memory] provider configured")
+		}})
+	} else if memory, err := core.NewMemory(cfg.AgentMemory); err != nil {
+		checks = append(checks, doctor.Failed("memory", cfg.AgentMemory.Provider, err))
+	} else {
+		closers = append(closers, func() { memory.Close() })
+		checks = append(checks, doctor.Memory(memory))
+	}
+	dbPath := cmp.Or(appCfg.Storage.Path, storage.DefaultPath)
+	checks = append(checks,
+		doctor.Writable("storage", filepath.Dir(dbPath)),
+		doctor.Check{Group: "storage", Name: dbPath, Run: func(ctx context.Context) (string, error) {
+			db, err := storage.Open(dbPath)
+			if err != nil {
+				return "", err
+			}
+			var result string
+			if err := db.QueryRowContext(ctx, "PRAGMA quick_check").Scan(&result); err != nil {
+				return "", err
+			}
+			if result != "ok" {
+				return "", fmt.Errorf("integrity check: %s", result)
+			}
+			return "integrity check passed", nil
+		}},
+	)
+
+	// 📦 Sandboxed tools reach their container engine and image
+	if tools, err := sandbox.New(appCfg.Sandbox); err != nil {
+		checks = append(checks, doctor.Failed("sandbox", "tools", err))
+	} else {
+		for _, t := range tools {
+			checks = append(checks, doctor.Check{Group: "sandbox", Name: t.Name(), Run: t.Check})
+		}
+	}
+
+	// 🔌 MCP servers start and list their tools
+	servers := make([]string, 0, len(appCfg.MCP.Servers))
+	for name := range appCfg.MCP.Servers {
+		servers = append(servers, name)
+	}
+	sort.Strings(servers)
+	for _, name := range servers {
+		checks = append(checks, doctor.Check{Group: "mcp", Name: name, Run: func(ctx context.Context) (string, error) {
+			return appCfg.MCP.Check(ctx, name)
+		}})
+	}
+
+	// 🔧 Programs and secrets the configured features need
+	switch appCfg.OCR.Engine {
+	case "tesseract":
+		checks = append(checks,
+			doctor.Binary(cmp.Or(appCfg.OCR.Command, "tesseract"), "OCR"),
+			doctor.Binary("pdftoppm", "OCR of scanned PDFs"),
+			doctor.Binary("pdftotext", "PDF text extraction"),
+		)
+	case "vision":
+		checks = append(checks, doctor.Env("secrets", appCfg.OCR.APIKeyEnv, "vision OCR"))
+	}
+	if appCfg.Admin.Enabled {
+		checks = append(checks, doctor.Env("secrets", cmp.Or(appCfg.Admin.TokenEnv, "AGENTFLOW_ADMIN_TOKEN"), "the admin API"))
+	}
+	if appCfg.Billing.Enabled {
+		checks = append(checks, doctor.Env("secrets", cmp.Or(appCfg.Billing.APIKeyEnv, "STRIPE_API_KEY"), "billing"))
+	}
+	return checks, cleanup
+}
+
+func initCommand(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	path := fs.String("config", "agentflow.toml", "config file to write")
+	force := fs.Bool("force", false, "overwrite an existing file without asking")
+	noTest := fs.Bool("no-test", false, "write the file without making a test call")
+	timeout := fs.Duration("timeout", 30*time.Second, "time limit for the test call")
+	templateName := fs.String("template", "", `workflow template to start from ("list" shows them)`)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	base := defaultConfig
+	var answers setup.Answers
+	switch *templateName {
+	case "":
+	case "list":
+		for _, t := range setup.Templates {
+			fmt.Printf("  %-16s %s: %s\n", t.Name, t.Title, t.Description)
+		}
+		return nil
+	default:
+		t, err := setup.FindTemplate(*templateName)
+		if err != nil {
+			return err
+		}
+		base = setup.Apply(defaultConfig, t.Overlay())
+		answers.Memory = t.Memory
+		fmt.Printf("Setting up the %s template: %s.\n\n", t.Title, t.Description)
+	}
+
+	p := setup.NewPrompter(os.Stdin, os.Stdout)
+	existing, err := os.ReadFile(*path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if existing != nil && !*force {
+		overwrite, err := p.Confirm(fmt.Sprintf("%s exists. Replace it? The current file is kept as %s.bak", *path, *path), false)
+		if err != nil || !overwrite {
+			return err
+		}
+	}
+
+	for {
+		if answers, err = setup.Interview(p, answers); err != nil {
+			return err
+		}
+		if *noTest {
+			break
+		}
+		fmt.Println("\nChecking the provider...")
+		results := doctor.Run(context.Background(), setup.Checks(answers), *timeout)
+		doctor.Print(os.Stdout, results, doctor.Color(os.Stdout))
+		if doctor.Count(results, doctor.Fail) == 0 {
+			break
+		}
+		choice, err := p.Choose("\nThe provider check failed. What now?", []string{"change the answers", "write the file anyway", "quit"}, 0)
+		if err != nil {
+			return err
+		}
+		if choice == 1 {
+			break
+		}
+		if choice == 2 {
+			return fmt.Errorf("nothing written")
+		}
+		fmt.Println()
+	}
+
+	if existing != nil {
+		if err := os.WriteFile(*path+".bak", existing, 0o600); err != nil {
+			return fmt.Errorf("failed to write backup: %w", err)
+		}
+	}
+	perm := os.FileMode(0o644)
+	if answers.HasSecret() {
+		perm = 0o600
+	}
+	if err := os.WriteFile(*path, setup.Render(base, answers), perm); err != nil {
+		return err
+	}
+	// WriteFile keeps the mode of a file it overwrites
+	if err := os.Chmod(*path, perm); err != nil {
+		return err
+	}
+	fmt.Printf("\nWrote %s. Run `my-agents doctor` to check the rest of the stack.\n", *path)
+	return nil
+}
+
+func archiveCommand(args []string) error {
+	fs := flag.NewFlagSet("archive", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file with [cold_storage]")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	app, err := newApp(*configPath)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+	if app.mover == nil {
+		return fmt.Errorf("%s doesn't enable [cold_storage]", *configPath)
+	}
+	moved, err := app.mover.Move(context.Background())
+	names := make([]string, 0, len(moved))
+	for name := range moved {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%-8s %6d moved  %10d bytes\n", name, moved[name].Records, moved[name].Bytes)
+	}
+	return err
+}
+
+func maintainCommand(args []string) error {
+	fs := flag.NewFlagSet("maintain", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file with [maintenance]")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	app, err := newApp(*configPath)
+	if err != nil {
+		return err
+	}
+	defer app.Close()
+	if app.maintainer == nil {
+		return fmt.Errorf("%s doesn't enable [maintenance]", *configPath)
+	}
+	results, err := app.maintainer.RunNow(context.Background(), fs.Args()...)
+	names := make([]string, 0, len(results))
+	for name := range results {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%-16s %6d removed  %10d bytes reclaimed\n", name, results[name].Removed, results[name].Bytes)
+	}
+	return err
+}
+
+func backupCommand(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	configPath := fs.String("config", defaultConfigPath, "config file to back up with the data it names")
+	out := fs.String("o", "agentflow-backup-"+time.Now().Format("20060102-150405")+".tar.gz", "archive to write")
+	var include repeated
+	fs.Var(&include, "include", "another file or directory to archive, e.g. prompts (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	cfg, err := core.LoadConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	appCfg, err := appconfig.Load(*configPath)
+	if err != nil {
+		return fmt.Errorf("failed to load app config: %w", err)
+	}
+	paths, databases := statePaths(cfg, appCfg)
+	dirs := append(paths, include...)
+
+	tmp := *out + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	m, err := backup.Create(context.Background(), f, backup.Options{ConfigPath: *configPath, Dirs: dirs, Databases: databases})
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, *out); err != nil {
+		return err
+	}
+	var size int64
+	for _, file := range m.Files {
+		size += file.Size
+	}
+	fmt.Printf("Backed up %s and %d files (%d bytes) to %s\n", *configPath, len(m.Files), size, *out)
+	return nil
+}
+
+// statePaths resolves where the stores cfg and appCfg configure keep
+// their state, without opening them: the SQLite databases that exist, and
+// the other files and directories they write, most of which default under
+// .agentflow.
+func statePaths(cfg *core.Config, appCfg *appconfig.Config) (paths, databases []string) {
+	shared := cmp.Or(appCfg.Storage.Path, storage.DefaultPath)
+	sqlite := []string{shared}
+	paths = []string{".agentflow"}
+	// Each store's own path: a database for the sqlite backend, a file
+	// or directory for the others
+	for _, s := range []struct{ backend, path, fallback string }{
+		{appCfg.History.Backend, appCfg.History.Path, storage.DefaultPath},
+		{appCfg.Recovery.Backend, appCfg.Recovery.Path, storage.DefaultPath},
+		{appCfg.Audit.Backend, appCfg.Audit.Path, storage.DefaultPath},
+		{"", appCfg.DeadLetter.Path, storage.DefaultPath},
+		{"", appCfg.Drift.Path, storage.DefaultPath},
+		{"", appCfg.Quality.Path, storage.DefaultPath},
+		{appCfg.StateStore.Backend, appCfg.StateStore.Path, shared},
+	} {
+		switch s.backend {
+		case "", storage.BackendSQLite:
+			sqlite = append(sqlite, cmp.Or(s.path, s.fallback))
+		case storage.BackendMemory:
+		default:
+			paths = append(paths, s.path)
+		}
+	}
+	if cfg.AgentMemory.Provider == storage.MemoryProvider {
+		sqlite = append(sqlite, cmp.Or(cfg.AgentMemory.Connection, shared))
+	}
+	if r := appCfg.Retrieval; r.Store == "" || r.Store == retrieval.StoreSQLite {
+		db := cmp.Or(r.Path, shared)
+		sqlite = append(sqlite, db)
+		paths = append(paths, cmp.Or(r.KeywordPath, filepath.Join(filepath.Dir(db), "keyword")))
+	}
+	if appCfg.ColdStorage.Backend == "" || appCfg.ColdStorage.Backend == coldstore.BackendFile {
+		paths = append(paths, appCfg.ColdStorage.Path)
+	}
+	paths = append(paths, appCfg.Usage.Path, appCfg.Quotas.Path, appCfg.Plan.Path, appCfg.ModelRouting.StatsPath,
+		appCfg.Billing.StatePath, appCfg.Telemetry.IDPath, appCfg.Admin.DeploymentsPath, appCfg.Compliance.Dir)
+	paths = slices.DeleteFunc(paths, func(p string) bool { return p == "" })
+	for _, db := range sqlite {
+		// Snapshotting a database that doesn't exist would create it
+		if _, err := os.Stat(db); err == nil && !slices.Contains(databases, db) {
+			databases = append(databases, db)
+		}
+	}
+	return paths, databases
+}
+
+func restoreCommand(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	configPath := fs.String("config", "", "where to write the config (default its archived name here)")
+	force := fs.Bool("force", false, "overwrite existing files")
+	outside := fs.Bool("outside", false, "restore files the archive places outside the working directory, at absolute paths or above it")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("usage: restore [-config agentflow.toml] [-force] [-outside] archive.tar.gz")
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	m, err := backup.Restore(f, backup.RestoreOptions{ConfigPath: *configPath, Force: *force, Outside: *outside})
+	if err != nil {
+		return err
+	}
+	for _, file := range m.Files {
+		fmt.Printf("%10d  %s\n", file.Size, file.Path)
+	}
+	fmt.Printf("Restored the config and %d files backed up at %s\n", len(m.Files), m.Created.Format(time.RFC3339))
+	return nil
+}
@@ -0,0 +1,419 @@
+// Package compliance exports periodic evidence bundles for SOC2-style
+// audits: who accessed the admin API, what agents did, how long each store
+// keeps data, which connections are encrypted and which deletion requests
+// were processed. Bundles are built from the audit and history stores and
+// carry a manifest of checksums so they can be filed as-is.
+package compliance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/audit"
+	"my-agents/history"
+)
+
+// DefaultDir is where bundles are written unless configured.
+const DefaultDir = ".agentflow/compliance"
+
+// Periods.
+const (
+	Daily   = "daily"
+	Weekly  = "weekly"
+	Monthly = "monthly"
+)
+
+// Stores whose retention is reported.
+const (
+	StoreRuns        = "runs"
+	StoreToolCalls   = "tool_calls"
+	StoreAdminAccess = "admin_access"
+	StoreErasures    = "erasures"
+)
+
+// Config is the [compliance] section of agentflow.toml. Retention declares
+// how long each store may keep records; stores without one are reported as
+// unbounded.
+//
+//	[compliance]
+//	enabled = true
+//	period = "monthly"
+//	[compliance.retention]
+//	runs = "2160h"
+//	tool_calls = "8760h"
+type Config struct {
+	Enabled   bool              `toml:"enabled"`
+	Dir       string            `toml:"dir"`    // default DefaultDir
+	Period    string            `toml:"period"` // Daily, Weekly or Monthly (default)
+	Retention map[string]string `toml:"retention"`
+}
+
+// StoreStatus is a store's retention policy against the records it holds.
+type StoreStatus struct {
+	Store     string    `json:"store"`
+	Retention string    `json:"retention"` // "unbounded" when none is declared
+	Records   int       `json:"records"`
+	Oldest    time.Time `json:"oldest,omitempty"`
+	// Expired counts records older than the retention allows.
+	Expired   int  `json:"expired"`
+	Compliant bool `json:"compliant"`
+}
+
+// EncryptionStatus describes whether a connection or store is encrypted.
+type EncryptionStatus struct {
+	Component string `json:"component"`
+	Encrypted bool   `json:"encrypted"`
+	Detail    string `json:"detail"`
+}
+
+// RunSummary counts the runs started in a period without their content.
+type RunSummary struct {
+	Total    int            `json:"total"`
+	Sessions int            `json:"sessions"`
+	ByStatus map[string]int `json:"by_status"`
+}
+
+// File is one file of a bundle.
+type File struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+	Size   int    `json:"size"`
+}
+
+// Manifest describes a bundle; it is written last, as manifest.json.
+type Manifest struct {
+	Since       time.Time `json:"since"`
+	Until       time.Time `json:"until"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Files       []File    `json:"files"`
+}
+
+// Generator builds bundles from the audit log and run history.
+type Generator struct {
+	cfg        Config
+	log        audit.Log
+	runs       history.Store
+	encryption []EncryptionStatus
+	retention  map[string]time.Duration
+}
+
+// New creates a generator. encryption is the inventory of the deployment's
+// connections and stores, which only the caller knows.
+func New(cfg Config, log audit.Log, runs history.Store, encryption []EncryptionStatus) (*Generator, error) {
+	switch cfg.Period {
+	case "":
+		cfg.Period = Monthly
+	case Daily, Weekly, Monthly:
+	default:
+		return nil, fmt.Errorf("unknown compliance period %q", cfg.Period)
+	}
+	if cfg.Dir == "" {
+		cfg.Dir = DefaultDir
+	}
+	g := &Generator{cfg: cfg, log: log, runs: runs, encryption: encryption, retention: make(map[string]time.Duration)}
+	for store, s := range cfg.Retention {
+		d, err := time.ParseDuration(s)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid retention %q for %s", s, store)
+		}
+		g.retention[store] = d
+	}
+	return g, nil
+}
+
+// Period returns the bounds of the configured period containing t, in UTC.
+func (g *Generator) Period(t time.Time) (since, until time.Time) {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch g.cfg.Period {
+	case Daily:
+		return day, day.AddDate(0, 0, 1)
+	case Weekly:
+		// Weeks start on Monday
+		start := day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+		return start, start.AddDate(0, 0, 7)
+	default:
+		start := time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+		return start, start.AddDate(0, 1, 0)
+	}
+}
+
+// Dir returns the directory of the bundle for a period.
+func (g *Generator) Dir(since, until time.Time) string {
+	return filepath.Join(g.cfg.Dir, since.UTC().Format("20060102")+"-"+until.UTC().Format("20060102"))
+}
+
+// Generate writes the bundle for [since, until) to dir, replacing any
+// earlier bundle there.
+func (g *Generator) Generate(ctx context.Context, since, until time.Time, dir string) (*Manifest, error) {
+	accesses, err := g.log.Accesses(ctx, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read access log: %w", err)
+	}
+	calls, err := g.log.List(ctx, audit.Filter{Since: since, Until: until})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tool calls: %w", err)
+	}
+	// Evidence that calls happened, not the data they carried
+	for i := range calls {
+		calls[i].Args = nil
+	}
+	erasures, err := g.log.Erasures(ctx, since, until)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deletion requests: %w", err)
+	}
+	runs, err := g.runs.List(ctx, history.Filter{Since: since, Until: until})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run history: %w", err)
+	}
+	summary := RunSummary{ByStatus: make(map[string]int)}
+	sessions := make(map[string]bool)
+	for _, run := range runs {
+		summary.Total++
+		summary.ByStatus[run.Status]++
+		sessions[run.SessionID] = true
+	}
+	summary.Sessions = len(sessions)
+	asOf := until
+	if now := time.Now(); now.Before(asOf) {
+		asOf = now
+	}
+	retention, err := g.retentionStatus(ctx, asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create bundle directory %s: %w", dir, err)
+	}
+	m := &Manifest{Since: since.UTC(), Until: until.UTC(), GeneratedAt: time.Now().UTC()}
+	files := []struct {
+		name string
+		v    any
+	}{
+		{"access_log.json", nonNil(accesses)},
+		{"tool_calls.json", nonNil(calls)},
+		{"runs.json", summary},
+		{"retention.json", retention},
+		{"encryption.json", nonNil(g.encryption)},
+		{"deletions.json", nonNil(erasures)},
+	}
+	for _, f := range files {
+		data, err := json.MarshalIndent(f.v, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := m.write(dir, f.name, data); err != nil {
+			return nil, err
+		}
+	}
+	report := summarize(m, accesses, calls, summary, retention, g.encryption, erasures)
+	if err := m.write(dir, "summary.md", []byte(report)); err != nil {
+		return nil, err
+	}
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(filepath.Join(dir, "manifest.json"), data, 0o640); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// write writes a bundle file and adds it to the manifest.
+func (m *Manifest) write(dir, name string, data []byte) error {
+	if err := os.WriteFile(filepath.Join(dir, name), data, 0o640); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	sum := sha256.Sum256(data)
+	m.Files = append(m.Files, File{Name: name, SHA256: hex.EncodeToString(sum[:]), Size: len(data)})
+	return nil
+}
+
+// retentionStatus checks every store's records against its retention as of
+// asOf.
+func (g *Generator) retentionStatus(ctx context.Context, asOf time.Time) ([]StoreStatus, error) {
+	times := make(map[string][]time.Time)
+	runs, err := g.runs.List(ctx, history.Filter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run history: %w", err)
+	}
+	for _, run := range runs {
+		times[StoreRuns] = append(times[StoreRuns], run.StartedAt)
+	}
+	calls, err := g.log.List(ctx, audit.Filter{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tool calls: %w", err)
+	}
+	for _, c := range calls {
+		times[StoreToolCalls] = append(times[StoreToolCalls], c.StartedAt)
+	}
+	accesses, err := g.log.Accesses(ctx, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read access log: %w", err)
+	}
+	for _, a := range accesses {
+		times[StoreAdminAccess] = append(times[StoreAdminAccess], a.Time)
+	}
+	erasures, err := g.log.Erasures(ctx, time.Time{}, time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deletion requests: %w", err)
+	}
+	for _, e := range erasures {
+		times[StoreErasures] = append(times[StoreErasures], e.Time)
+	}
+
+	var out []StoreStatus
+	for _, store := range []string{StoreRuns, StoreToolCalls, StoreAdminAccess, StoreErasures} {
+		st := StoreStatus{Store: store, Retention: "unbounded", Records: len(times[store]), Compliant: true}
+		limit, bounded := g.retention[store]
+		if bounded {
+			st.Retention = limit.String()
+		}
+		for _, t := range times[store] {
+			if st.Oldest.IsZero() || t.Before(st.Oldest) {
+				st.Oldest = t
+			}
+			if bounded && asOf.Sub(t) > limit {
+				st.Expired++
+			}
+		}
+		st.Compliant = st.Expired == 0
+		out = append(out, st)
+	}
+	return out, nil
+}
+
+// Run writes the bundle of every completed period that doesn't have one
+// yet, checking hourly until ctx is done.
+func (g *Generator) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		since, _ := g.Period(time.Now())
+		since, until := g.Period(since.Add(-time.Nanosecond))
+		dir := g.Dir(since, until)
+		if _, err := os.Stat(filepath.Join(dir, "manifest.json")); os.IsNotExist(err) {
+			if _, err := g.Generate(ctx, since, until, dir); err != nil {
+				core.Logger().Error().Err(err).Str("dir", dir).Msg("Failed to generate compliance bundle")
+			} else {
+				core.Logger().Info().Str("dir", dir).Msg("Compliance bundle written")
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// summarize renders the bundle's human-readable summary.
+func summarize(m *Manifest, accesses []audit.Access, calls []audit.ToolCall, runs RunSummary, retention []StoreStatus, encryption []EncryptionStatus, erasures []audit.Erasure) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Compliance evidence %s – %s\n\n", m.Since.Format("2006-01-02"), m.Until.Format("2006-01-02"))
+	fmt.Fprintf(&b, "Generated %s.\n\n", m.GeneratedAt.Format(time.RFC3339))
+
+	denied := 0
+	for _, a := range accesses {
+		if !a.Authorized {
+			denied++
+		}
+	}
+	b.WriteString("## Access\n\n")
+	fmt.Fprintf(&b, "- %d admin API requests, %d rejected for a missing or invalid token\n", len(accesses), denied)
+	tools := make(map[string]int)
+	failed := 0
+	for _, c := range calls {
+		tools[c.Tool]++
+		if c.Error != "" {
+			failed++
+		}
+	}
+	fmt.Fprintf(&b, "- %d tool calls by agents (%d failed)", len(calls), failed)
+	names := make([]string, 0, len(tools))
+	for name := range tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i, name := range names {
+		sep := ", "
+		if i == 0 {
+			sep = ": "
+		}
+		fmt.Fprintf(&b, "%s%s %d", sep, name, tools[name])
+	}
+	fmt.Fprintf(&b, "\n- %d runs across %d sessions\n\n", runs.Total, runs.Sessions)
+
+	b.WriteString("## Retention\n\n| Store | Retention | Records | Oldest | Expired | Status |\n|---|---|---|---|---|---|\n")
+	for _, st := range retention {
+		oldest, status := "—", "ok"
+		if !st.Oldest.IsZero() {
+			oldest = st.Oldest.UTC().Format("2006-01-02")
+		}
+		if !st.Compliant {
+			status = "**overdue**"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %d | %s | %d | %s |\n", st.Store, st.Retention, st.Records, oldest, st.Expired, status)
+	}
+
+	b.WriteString("\n## Encryption\n\n| Component | Encrypted | Detail |\n|---|---|---|\n")
+	for _, e := range encryption {
+		mark := "no"
+		if e.Encrypted {
+			mark = "yes"
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", e.Component, mark, e.Detail)
+	}
+
+	b.WriteString("\n## Deletion requests\n\n")
+	if len(erasures) == 0 {
+		b.WriteString("None processed.\n")
+	}
+	for _, e := range erasures {
+		var subject []string
+		if e.SessionID != "" {
+			subject = append(subject, "session "+e.SessionID)
+		}
+		if e.UserID != "" {
+			subject = append(subject, "user "+e.UserID)
+		}
+		fmt.Fprintf(&b, "- %s %s: %d runs deleted, %d tool calls redacted", e.Time.UTC().Format("2006-01-02 15:04"), strings.Join(subject, ", "), e.Runs, e.ToolCalls)
+		if e.Profile {
+			b.WriteString(", profile deleted")
+		}
+		if e.UsageRecords > 0 {
+			fmt.Fprintf(&b, ", %d usage records anonymized", e.UsageRecords)
+		}
+		if e.CacheEntries > 0 {
+			fmt.Fprintf(&b, ", %d cached LLM replies flushed", e.CacheEntries)
+		}
+		if e.RequestedBy != "" {
+			fmt.Fprintf(&b, ", requested by %s", e.RequestedBy)
+		}
+		if e.Error != "" {
+			fmt.Fprintf(&b, " — incomplete: %s", e.Error)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// nonNil keeps empty lists as [] rather than null in bundle files.
+func nonNil[T any](s []T) []T {
+	if s == nil {
+		return []T{}
+	}
+	return s
+}
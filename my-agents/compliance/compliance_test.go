@@ -0,0 +1,211 @@
+package compliance
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"my-agents/audit"
+	"my-agents/history"
+)
+
+func TestNew(t *testing.T) {
+	g, err := New(Config{}, &audit.MemoryLog{}, history.NewMemoryStore(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if g.cfg.Period != Monthly || g.cfg.Dir != DefaultDir {
+		t.Errorf("defaults = %+v", g.cfg)
+	}
+	if _, err := New(Config{Period: "yearly"}, &audit.MemoryLog{}, history.NewMemoryStore(), nil); err == nil {
+		t.Error("unknown period accepted")
+	}
+	for _, s := range []string{"forever", "-1h", "0s"} {
+		if _, err := New(Config{Retention: map[string]string{StoreRuns: s}}, &audit.MemoryLog{}, history.NewMemoryStore(), nil); err == nil {
+			t.Errorf("retention %q accepted", s)
+		}
+	}
+}
+
+func TestPeriod(t *testing.T) {
+	// A Wednesday
+	at := time.Date(2026, 3, 18, 15, 30, 0, 0, time.UTC)
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+	tests := []struct {
+		period       string
+		since, until time.Time
+	}{
+		{Daily, day(18), day(19)},
+		{Weekly, day(16), day(23)},
+		{Monthly, day(1), time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		g, err := New(Config{Period: tt.period}, &audit.MemoryLog{}, history.NewMemoryStore(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		since, until := g.Period(at)
+		if !since.Equal(tt.since) || !until.Equal(tt.until) {
+			t.Errorf("%s period of %v = [%v, %v), want [%v, %v)", tt.period, at, since, until, tt.since, tt.until)
+		}
+	}
+
+	// Sundays belong to the week that started the Monday before
+	g, _ := New(Config{Period: Weekly}, &audit.MemoryLog{}, history.NewMemoryStore(), nil)
+	if since, _ := g.Period(day(22)); !since.Equal(day(16)) {
+		t.Errorf("week of Sunday starts %v", since)
+	}
+}
+
+func TestDir(t *testing.T) {
+	g, _ := New(Config{Dir: "evidence"}, &audit.MemoryLog{}, history.NewMemoryStore(), nil)
+	since := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	if got := g.Dir(since, since.AddDate(0, 1, 0)); got != filepath.Join("evidence", "20260301-20260401") {
+		t.Errorf("dir = %q", got)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	ctx := context.Background()
+	since := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	until := since.AddDate(0, 1, 0)
+	in := since.Add(48 * time.Hour)
+
+	log := &audit.MemoryLog{}
+	log.RecordAccess(ctx, &audit.Access{Time: in, Method: "GET", Path: "/admin/status", Status: 200, Authorized: true})
+	log.RecordAccess(ctx, &audit.Access{Time: in, Method: "POST", Path: "/admin/pause", Status: 401})
+	log.Record(ctx, &audit.ToolCall{RunID: "run-1", Tool: "send_email", Args: map[string]any{"to": "ann@example.com"}, StartedAt: in})
+	log.Record(ctx, &audit.ToolCall{RunID: "run-1", Tool: "search", Error: "timeout", StartedAt: in})
+	// Outside the period, but still held
+	log.Record(ctx, &audit.ToolCall{RunID: "run-0", Tool: "search", StartedAt: since.AddDate(-1, 0, 0)})
+	log.RecordErasure(ctx, &audit.Erasure{Time: in, SessionID: "s9", UserID: "bob", Runs: 2, ToolCalls: 1, Profile: true, UsageRecords: 3, RequestedBy: "dpo"})
+
+	runs := history.NewMemoryStore()
+	runs.Save(ctx, &history.Run{ID: "run-1", SessionID: "s1", Status: history.StatusCompleted, StartedAt: in})
+	runs.Save(ctx, &history.Run{ID: "run-2", SessionID: "s1", Status: history.StatusFailed, StartedAt: in})
+	runs.Save(ctx, &history.Run{ID: "run-3", SessionID: "s2", Status: history.StatusCompleted, StartedAt: in})
+
+	encryption := []EncryptionStatus{{Component: "sqlite", Encrypted: false, Detail: "local file"}}
+	cfg := Config{Retention: map[string]string{StoreToolCalls: "2160h"}}
+	g, err := New(cfg, log, runs, encryption)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "bundle")
+	m, err := g.Generate(ctx, since, until, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The manifest lists every other file with its checksum
+	var names []string
+	for _, f := range m.Files {
+		names = append(names, f.Name)
+		data, err := os.ReadFile(filepath.Join(dir, f.Name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(data)
+		if f.SHA256 != hex.EncodeToString(sum[:]) || f.Size != len(data) {
+			t.Errorf("%s: manifest entry %+v doesn't match its content", f.Name, f)
+		}
+	}
+	want := "access_log.json tool_calls.json runs.json retention.json encryption.json deletions.json summary.md"
+	if got := strings.Join(names, " "); got != want {
+		t.Errorf("files = %s, want %s", got, want)
+	}
+	var written Manifest
+	read(t, filepath.Join(dir, "manifest.json"), &written)
+	if len(written.Files) != len(m.Files) || !written.Since.Equal(since) || !written.Until.Equal(until) {
+		t.Errorf("manifest.json = %+v", written)
+	}
+
+	var calls []audit.ToolCall
+	read(t, filepath.Join(dir, "tool_calls.json"), &calls)
+	if len(calls) != 2 {
+		t.Fatalf("tool calls = %+v", calls)
+	}
+	for _, c := range calls {
+		if c.Args != nil {
+			t.Errorf("tool call %s kept its args %v", c.Tool, c.Args)
+		}
+	}
+
+	var summary RunSummary
+	read(t, filepath.Join(dir, "runs.json"), &summary)
+	if summary.Total != 3 || summary.Sessions != 2 || summary.ByStatus[history.StatusCompleted] != 2 || summary.ByStatus[history.StatusFailed] != 1 {
+		t.Errorf("run summary = %+v", summary)
+	}
+
+	var retention []StoreStatus
+	read(t, filepath.Join(dir, "retention.json"), &retention)
+	status := make(map[string]StoreStatus)
+	for _, st := range retention {
+		status[st.Store] = st
+	}
+	if st := status[StoreToolCalls]; st.Records != 3 || st.Expired != 1 || st.Compliant || st.Retention != "2160h0m0s" {
+		t.Errorf("tool call retention = %+v", st)
+	}
+	if st := status[StoreRuns]; st.Records != 3 || st.Retention != "unbounded" || !st.Compliant {
+		t.Errorf("run retention = %+v", st)
+	}
+
+	report, err := os.ReadFile(filepath.Join(dir, "summary.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		"2 admin API requests, 1 rejected",
+		"2 tool calls by agents (1 failed): search 1, send_email 1",
+		"3 runs across 2 sessions",
+		"| tool_calls | 2160h0m0s | 3 | 2025-03-01 | 1 | **overdue** |",
+		"| sqlite | no | local file |",
+		"session s9, user bob: 2 runs deleted, 1 tool calls redacted, profile deleted, 3 usage records anonymized, requested by dpo",
+	} {
+		if !strings.Contains(string(report), s) {
+			t.Errorf("summary lacks %q:\n%s", s, report)
+		}
+	}
+}
+
+func TestGenerateEmpty(t *testing.T) {
+	g, err := New(Config{}, &audit.MemoryLog{}, history.NewMemoryStore(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	since := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	dir := t.TempDir()
+	if _, err := g.Generate(context.Background(), since, since.AddDate(0, 1, 0), dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"access_log.json", "tool_calls.json", "encryption.json", "deletions.json"} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "[]" {
+			t.Errorf("%s = %s, want []", name, data)
+		}
+	}
+	report, _ := os.ReadFile(filepath.Join(dir, "summary.md"))
+	if !strings.Contains(string(report), "None processed.") {
+		t.Errorf("summary of no deletions:\n%s", report)
+	}
+}
+
+func read(t *testing.T, path string, v any) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("%s: %v", path, err)
+	}
+}
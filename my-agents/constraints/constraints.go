@@ -0,0 +1,171 @@
+// Package constraints lets callers bound the shape of the final response
+// (length, reading level, bullet format, tone). The formatter includes the
+// constraints in its prompt and Validate checks the result afterwards.
+package constraints
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Event metadata keys read by FromEvent.
+const (
+	MaxWordsKey     = "max_words"
+	ReadingLevelKey = "reading_level" // maximum US school grade, e.g. "8"
+	FormatKey       = "format"        // "bullets" requests a bullet list
+	ToneKey         = "tone"          // e.g. "casual", "formal", "friendly"
+)
+
+// Constraints are caller-requested output limits. Zero values mean unconstrained.
+type Constraints struct {
+	MaxWords   int
+	MaxGrade   float64
+	BulletList bool
+	Tone       string // not validated; models follow it or they don't
+}
+
+// FromEvent reads constraints from event metadata.
+func FromEvent(event core.Event) Constraints {
+	var c Constraints
+	if v, ok := event.GetMetadataValue(MaxWordsKey); ok {
+		c.MaxWords, _ = strconv.Atoi(v)
+	}
+	if v, ok := event.GetMetadataValue(ReadingLevelKey); ok {
+		c.MaxGrade, _ = strconv.ParseFloat(v, 64)
+	}
+	if v, ok := event.GetMetadataValue(FormatKey); ok {
+		c.BulletList = strings.EqualFold(v, "bullets")
+	}
+	if v, ok := event.GetMetadataValue(ToneKey); ok {
+		c.Tone = strings.TrimSpace(v)
+	}
+	return c
+}
+
+// Empty reports whether no constraint is set.
+func (c Constraints) Empty() bool {
+	return c.MaxWords <= 0 && c.MaxGrade <= 0 && !c.BulletList && c.Tone == ""
+}
+
+// Instructions renders the constraints as prompt instructions.
+func (c Constraints) Instructions() string {
+	var parts []string
+	if c.MaxWords > 0 {
+		parts = append(parts, fmt.Sprintf("Use at most %d words.", c.MaxWords))
+	}
+	if c.MaxGrade > 0 {
+		parts = append(parts, fmt.Sprintf("Write at or below a US grade %g reading level: short sentences and common words.", c.MaxGrade))
+	}
+	if c.BulletList {
+		parts = append(parts, "Format the whole answer as a bullet list, one '- ' item per line, with no other text.")
+	}
+	if c.Tone != "" {
+		parts = append(parts, fmt.Sprintf("Write in a %s tone.", c.Tone))
+	}
+	return strings.Join(parts, " ")
+}
+
+// Violation describes one unmet constraint.
+type Violation struct {
+	Constraint string
+	Detail     string
+}
+
+func (v Violation) String() string {
+	return v.Constraint + ": " + v.Detail
+}
+
+// Validate checks text against the constraints.
+func (c Constraints) Validate(text string) []Violation {
+	var violations []Violation
+	if c.MaxWords > 0 {
+		if n := len(strings.Fields(text)); n > c.MaxWords {
+			violations = append(violations, Violation{"max_words", fmt.Sprintf("has %d words, limit is %d", n, c.MaxWords)})
+		}
+	}
+	if c.MaxGrade > 0 {
+		if g := Grade(text); g > c.MaxGrade {
+			violations = append(violations, Violation{"reading_level", fmt.Sprintf("reads at grade %.1f, limit is %g", g, c.MaxGrade)})
+		}
+	}
+	if c.BulletList {
+		for _, line := range strings.Split(text, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !isBullet(line) {
+				violations = append(violations, Violation{"format", fmt.Sprintf("line is not a bullet: %q", line)})
+				break
+			}
+		}
+	}
+	return violations
+}
+
+// RepairPrompt asks the model to rewrite text so it satisfies the constraints.
+func (c Constraints) RepairPrompt(text string, violations []Violation) core.Prompt {
+	var b strings.Builder
+	b.WriteString("Rewrite the response below so it satisfies every requirement. Keep the meaning.\n\nProblems found:\n")
+	for _, v := range violations {
+		fmt.Fprintf(&b, "- %s\n", v)
+	}
+	fmt.Fprintf(&b, "\nRequirements: %s\n\nResponse:\n%s", c.Instructions(), text)
+	return core.Prompt{
+		System: "You are an editor. Return only the rewritten response.",
+		User:   b.String(),
+	}
+}
+
+func isBullet(line string) bool {
+	for _, prefix := range []string{"- ", "* ", "• "} {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	// numbered items: "1. " or "1) "
+	i := strings.IndexFunc(line, func(r rune) bool { return !unicode.IsDigit(r) })
+	return i > 0 && i+1 < len(line) && (line[i] == '.' || line[i] == ')') && line[i+1] == ' '
+}
+
+// Grade estimates the Flesch-Kincaid grade level of text.
+func Grade(text string) float64 {
+	words := strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) && r != '\'' })
+	if len(words) == 0 {
+		return 0
+	}
+	sentences := strings.FieldsFunc(text, func(r rune) bool { return r == '.' || r == '!' || r == '?' || r == '\n' })
+	nSentences := 0
+	for _, s := range sentences {
+		if strings.TrimSpace(s) != "" {
+			nSentences++
+		}
+	}
+	nSentences = max(nSentences, 1)
+
+	syllables := 0
+	for _, w := range words {
+		syllables += countSyllables(w)
+	}
+	grade := 0.39*float64(len(words))/float64(nSentences) + 11.8*float64(syllables)/float64(len(words)) - 15.59
+	return math.Max(grade, 0)
+}
+
+// countSyllables approximates syllables by counting vowel groups.
+func countSyllables(word string) int {
+	word = strings.ToLower(word)
+	count, prevVowel := 0, false
+	for _, r := range word {
+		vowel := strings.ContainsRune("aeiouy", r)
+		if vowel && !prevVowel {
+			count++
+		}
+		prevVowel = vowel
+	}
+	if strings.HasSuffix(word, "e") && count > 1 && !strings.HasSuffix(word, "le") {
+		count--
+	}
+	return max(count, 1)
+}
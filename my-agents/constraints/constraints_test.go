@@ -0,0 +1,68 @@
+package constraints
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+func TestFromEvent(t *testing.T) {
+	c := FromEvent(core.NewEvent("formatter", nil, map[string]string{
+		MaxWordsKey: "50", ReadingLevelKey: "8", FormatKey: "Bullets", ToneKey: " casual ",
+	}))
+	if c != (Constraints{MaxWords: 50, MaxGrade: 8, BulletList: true, Tone: "casual"}) {
+		t.Errorf("FromEvent = %+v", c)
+	}
+	if !FromEvent(core.NewEvent("formatter", nil, map[string]string{MaxWordsKey: "many"})).Empty() {
+		t.Error("an unparsable limit set a constraint")
+	}
+}
+
+func TestInstructions(t *testing.T) {
+	got := Constraints{MaxWords: 20, BulletList: true, Tone: "formal"}.Instructions()
+	for _, want := range []string{"at most 20 words", "bullet list", "formal tone"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Instructions = %q, lacks %q", got, want)
+		}
+	}
+	if (Constraints{}).Instructions() != "" {
+		t.Error("no constraints gave instructions")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	c := Constraints{MaxWords: 8, BulletList: true}
+	if v := c.Validate("- one\n- two\n\n1. three\n2) four"); len(v) != 0 {
+		t.Errorf("a short bullet list violated %v", v)
+	}
+	v := c.Validate("Intro line\n- one two three four five six")
+	if len(v) != 2 || v[0].Constraint != "max_words" || v[1].Constraint != "format" {
+		t.Errorf("Validate = %v, want the word limit and the format", v)
+	}
+	if !strings.Contains(c.RepairPrompt("text", v).User, "max_words: has 9 words") {
+		t.Error("the repair prompt doesn't list the problems")
+	}
+}
+
+func TestGrade(t *testing.T) {
+	simple := Grade("The cat sat. The dog ran. We had fun.")
+	hard := Grade("Notwithstanding considerable institutional opposition, the administration implemented comprehensive organizational restructuring initiatives.")
+	if simple > 2 || hard < 12 {
+		t.Errorf("grades %.1f and %.1f, want a simple text low and a hard one high", simple, hard)
+	}
+	if Grade("") != 0 {
+		t.Error("an empty text has a grade")
+	}
+	if v := (Constraints{MaxGrade: 6}).Validate("Notwithstanding considerable institutional opposition."); len(v) != 1 {
+		t.Errorf("a hard text met a grade 6 limit: %v", v)
+	}
+}
+
+func TestCountSyllables(t *testing.T) {
+	for word, want := range map[string]int{"cat": 1, "table": 2, "make": 1, "banana": 3, "rhythm": 1} {
+		if got := countSyllables(word); got != want {
+			t.Errorf("countSyllables(%q) = %d, want %d", word, got, want)
+		}
+	}
+}
@@ -0,0 +1,459 @@
+// Package conversation remembers what was said in each session, so a
+// follow-up request reaches the processor, enhancer and formatter with the
+// exchanges before it. The latest turns are kept verbatim as a short-term
+// buffer; older ones are folded into a running summary by a model call,
+// which is the session's long-term memory.
+package conversation
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/auth"
+	"my-agents/history"
+	"my-agents/storage"
+	"my-agents/tenant"
+)
+
+// Defaults for a config's unset fields.
+const (
+	DefaultBufferTurns  = 6
+	DefaultSummaryWords = 150
+)
+
+// Config is the [conversation] section of agentflow.toml:
+//
+//	[conversation]
+//	enabled = true
+//	buffer_turns = 6
+//	summarizer = "cheap"
+type Config struct {
+	Enabled bool `toml:"enabled"`
+	// BufferTurns is how many of a session's latest turns are kept
+	// verbatim (default 6).
+	BufferTurns int `toml:"buffer_turns"`
+	// Summarizer is the [providers.<name>] folding older turns into the
+	// summary; empty uses the default provider.
+	Summarizer string `toml:"summarizer"`
+	// SummaryWords bounds the summary's length (default 150).
+	SummaryWords int `toml:"summary_words"`
+}
+
+// Turn is one exchange: a request and the final response to it.
+type Turn struct {
+	RunID    string    `json:"run_id"`
+	Input    string    `json:"input"`
+	Response string    `json:"response"`
+	At       time.Time `json:"at"`
+}
+
+// Context is what a session remembers.
+type Context struct {
+	Summary string `json:"summary,omitempty"` // of the turns before Recent
+	Recent  []Turn `json:"recent,omitempty"`  // oldest first
+}
+
+// Empty reports whether nothing is remembered.
+func (c Context) Empty() bool {
+	return c.Summary == "" && len(c.Recent) == 0
+}
+
+// Prompt renders c for an agent's prompt; it is empty when c is.
+func (c Context) Prompt() string {
+	if c.Empty() {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\nEarlier in this conversation (context for this request, not to repeat):\n")
+	if c.Summary != "" {
+		fmt.Fprintf(&b, "Summary: %s\n", c.Summary)
+	}
+	for _, t := range c.Recent {
+		fmt.Fprintf(&b, "- user: %s\n- assistant: %s\n", t.Input, t.Response)
+	}
+	return b.String()
+}
+
+// Session identifies a conversation: the session ID a caller chose, scoped
+// to the tenant and principal the API authenticated, so a caller reusing
+// another's session ID starts a conversation of its own.
+type Session struct {
+	Tenant    string
+	Principal string // auth.IdentityKey; empty for events not from the API
+	ID        string
+}
+
+// SessionOf returns the session event belongs to. Its ID is empty when the
+// event has none.
+func SessionOf(event core.Event) Session {
+	md := event.GetMetadata()
+	return Session{Tenant: md[tenant.MetadataKey], Principal: md[auth.IdentityKey], ID: md[core.SessionIDKey]}
+}
+
+// inSession selects the session's rows.
+const inSession = `tenant_id = ? AND principal = ? AND session_id = ?`
+
+func (s Session) args(more ...any) []any {
+	return append([]any{s.Tenant, s.Principal, s.ID}, more...)
+}
+
+// Memory is conversation memory keyed by session.
+type Memory interface {
+	// Recall returns what the session remembers.
+	Recall(ctx context.Context, session Session) (Context, error)
+	// Remember adds a completed turn to the session.
+	Remember(ctx context.Context, session Session, turn Turn) error
+	// Forget deletes the session's memory and returns how many turns it
+	// held.
+	Forget(ctx context.Context, session Session) (int, error)
+}
+
+// Store is Memory in the embedded database. Turns beyond the buffer are
+// summarized in the background, so recording one never waits on a model.
+type Store struct {
+	cfg        Config
+	db         *sql.DB
+	summarizer core.ModelProvider
+
+	folds   chan Session // sessions whose buffer overflowed
+	done    chan struct{}
+	stopped sync.WaitGroup
+}
+
+var _ Memory = (*Store)(nil)
+
+// Open opens the store in the database at path (default
+// .agentflow/agentflow.db), summarizing with summarizer. With a nil
+// summarizer, as for erasing sessions, nothing is summarized.
+func Open(cfg Config, path string, summarizer core.ModelProvider) (*Store, error) {
+	db, err := storage.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(cfg, db, summarizer)
+}
+
+// New creates a store in db, creating its tables if needed, and starts
+// summarizing in the background until Close.
+func New(cfg Config, db *sql.DB, summarizer core.ModelProvider) (*Store, error) {
+	err := storage.Migrate(db,
+		`CREATE TABLE IF NOT EXISTS conversation_turns (
+			seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+			session_id TEXT NOT NULL,
+			run_id     TEXT NOT NULL,
+			input      TEXT NOT NULL,
+			response   TEXT NOT NULL,
+			at         INTEGER NOT NULL
+		)`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	// Turns and summaries were keyed by session ID alone; the ones from
+	// then belong to the sessions of events without a principal
+	for _, c := range []string{"tenant_id", "principal"} {
+		if err := storage.AddColumn(db, "conversation_turns", c, "TEXT NOT NULL DEFAULT ''"); err != nil {
+			return nil, err
+		}
+	}
+	var columns, scoped int
+	err = db.QueryRow(`SELECT COUNT(*), COALESCE(SUM(name = 'principal'), 0) FROM pragma_table_info('conversation_summaries')`).Scan(&columns, &scoped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate database schema: %w", err)
+	}
+	unscoped := columns > 0 && scoped == 0
+	if unscoped {
+		if err := storage.Migrate(db, `ALTER TABLE conversation_summaries RENAME TO conversation_summaries_unscoped`); err != nil {
+			return nil, err
+		}
+	}
+	err = storage.Migrate(db,
+		`DROP INDEX IF EXISTS conversation_turns_session`,
+		`CREATE INDEX IF NOT EXISTS conversation_turns_scoped ON conversation_turns (tenant_id, principal, session_id, seq)`,
+		`CREATE INDEX IF NOT EXISTS conversation_turns_session_id ON conversation_turns (session_id)`,
+		`CREATE TABLE IF NOT EXISTS conversation_summaries (
+			tenant_id  TEXT NOT NULL DEFAULT '',
+			principal  TEXT NOT NULL DEFAULT '',
+			session_id TEXT NOT NULL,
+			summary    TEXT NOT NULL,
+			turns      INTEGER NOT NULL,
+			updated_at INTEGER NOT NULL,
+			PRIMARY KEY (tenant_id, principal, session_id)
+		)`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if unscoped {
+		err = storage.Migrate(db,
+			`INSERT INTO conversation_summaries (session_id, summary, turns, updated_at)
+			 SELECT session_id, summary, turns, updated_at FROM conversation_summaries_unscoped`,
+			`DROP TABLE conversation_summaries_unscoped`,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.BufferTurns <= 0 {
+		cfg.BufferTurns = DefaultBufferTurns
+	}
+	if cfg.SummaryWords <= 0 {
+		cfg.SummaryWords = DefaultSummaryWords
+	}
+	s := &Store{cfg: cfg, db: db, summarizer: summarizer, folds: make(chan Session, 64), done: make(chan struct{})}
+	s.stopped.Add(1)
+	go s.summarize()
+	return s, nil
+}
+
+// Close stops summarizing, after the sessions already queued.
+func (s *Store) Close() {
+	close(s.done)
+	s.stopped.Wait()
+}
+
+// Recall returns what the session remembers: its summary and latest turns.
+func (s *Store) Recall(ctx context.Context, session Session) (Context, error) {
+	var c Context
+	err := s.db.QueryRowContext(ctx, `SELECT summary FROM conversation_summaries WHERE `+inSession, session.args()...).Scan(&c.Summary)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return Context{}, fmt.Errorf("failed to recall session %s: %w", session.ID, err)
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT run_id, input, response, at FROM
+		 (SELECT * FROM conversation_turns WHERE `+inSession+` ORDER BY seq DESC LIMIT ?)
+		 ORDER BY seq`, session.args(s.cfg.BufferTurns)...)
+	if err != nil {
+		return Context{}, fmt.Errorf("failed to recall session %s: %w", session.ID, err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var t Turn
+		var at int64
+		if err := rows.Scan(&t.RunID, &t.Input, &t.Response, &at); err != nil {
+			return Context{}, err
+		}
+		t.At = time.Unix(0, at)
+		c.Recent = append(c.Recent, t)
+	}
+	return c, rows.Err()
+}
+
+// Remember adds a completed turn to the session, queueing the session to
+// be summarized once its buffer overflows.
+func (s *Store) Remember(ctx context.Context, session Session, turn Turn) error {
+	if turn.At.IsZero() {
+		turn.At = time.Now()
+	}
+	_, err := s.db.ExecContext(ctx,
+		`INSERT INTO conversation_turns (tenant_id, principal, session_id, run_id, input, response, at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		session.args(turn.RunID, turn.Input, turn.Response, turn.At.UnixNano())...)
+	if err != nil {
+		return fmt.Errorf("failed to remember turn of session %s: %w", session.ID, err)
+	}
+	if s.summarizer == nil {
+		return nil
+	}
+	var n int
+	if err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM conversation_turns WHERE `+inSession, session.args()...).Scan(&n); err != nil {
+		return err
+	}
+	if n > s.cfg.BufferTurns {
+		// A backlog leaves the turns in the buffer; the session's next
+		// overflow folds them
+		select {
+		case s.folds <- session:
+		default:
+		}
+	}
+	return nil
+}
+
+// Forget deletes the session's turns and summary and returns how many
+// turns they held.
+func (s *Store) Forget(ctx context.Context, session Session) (int, error) {
+	return s.forget(ctx, inSession, session.args()...)
+}
+
+// Erase deletes the memory of every session with ID sessionID, whoever's
+// it is, and returns how many turns they held. It is for erasing data by
+// session ID, as the erase command does with the session's runs.
+func (s *Store) Erase(ctx context.Context, sessionID string) (int, error) {
+	return s.forget(ctx, `session_id = ?`, sessionID)
+}
+
+func (s *Store) forget(ctx context.Context, cond string, args ...any) (int, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+	var folded int
+	err = tx.QueryRowContext(ctx, `SELECT COALESCE(SUM(turns), 0) FROM conversation_summaries WHERE `+cond, args...).Scan(&folded)
+	if err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, `DELETE FROM conversation_turns WHERE `+cond, args...)
+	if err != nil {
+		return 0, err
+	}
+	buffered, _ := res.RowsAffected()
+	if _, err := tx.ExecContext(ctx, `DELETE FROM conversation_summaries WHERE `+cond, args...); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return folded + int(buffered), nil
+}
+
+// closeTimeout bounds how long Close spends on sessions still queued.
+const closeTimeout = 30 * time.Second
+
+func (s *Store) summarize() {
+	defer s.stopped.Done()
+	fold := func(ctx context.Context, session Session) {
+		ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+		defer cancel()
+		if err := s.Fold(ctx, session); err != nil {
+			core.Logger().Warn().Str("session_id", session.ID).Err(err).Msg("Failed to summarize conversation")
+		}
+	}
+	for {
+		select {
+		case session := <-s.folds:
+			fold(context.Background(), session)
+		case <-s.done:
+			ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+			defer cancel()
+			for ctx.Err() == nil {
+				select {
+				case session := <-s.folds:
+					fold(ctx, session)
+				default:
+					return
+				}
+			}
+			return
+		}
+	}
+}
+
+// Fold summarizes the session's turns beyond the buffer into its summary
+// and drops them. Turns stay in the buffer when summarizing fails.
+func (s *Store) Fold(ctx context.Context, session Session) error {
+	if s.summarizer == nil {
+		return nil
+	}
+	var summary string
+	var folded int
+	err := s.db.QueryRowContext(ctx, `SELECT summary, turns FROM conversation_summaries WHERE `+inSession, session.args()...).Scan(&summary, &folded)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT seq, input, response FROM conversation_turns WHERE `+inSession+` ORDER BY seq DESC LIMIT -1 OFFSET ?`,
+		session.args(s.cfg.BufferTurns)...)
+	if err != nil {
+		return err
+	}
+	var old []Turn
+	var last int64
+	for rows.Next() {
+		var t Turn
+		var seq int64
+		if err := rows.Scan(&seq, &t.Input, &t.Response); err != nil {
+			rows.Close()
+			return err
+		}
+		last = max(last, seq)
+		old = append([]Turn{t}, old...)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(old) == 0 {
+		return err
+	}
+
+	resp, err := s.summarizer.Call(ctx, s.SummaryPrompt(summary, old))
+	if err != nil {
+		return err
+	}
+	summary = strings.TrimSpace(resp.Content)
+	if summary == "" {
+		return fmt.Errorf("summarizer returned nothing")
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO conversation_summaries (tenant_id, principal, session_id, summary, turns, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (tenant_id, principal, session_id) DO UPDATE SET summary = excluded.summary, turns = excluded.turns, updated_at = excluded.updated_at`,
+		session.args(summary, folded+len(old), time.Now().UnixNano())...)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM conversation_turns WHERE `+inSession+` AND seq <= ?`, session.args(last)...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// SummaryPrompt asks the model to fold turns into the running summary.
+func (s *Store) SummaryPrompt(summary string, turns []Turn) core.Prompt {
+	var b strings.Builder
+	if summary != "" {
+		fmt.Fprintf(&b, "Summary so far:\n%s\n\n", summary)
+	}
+	b.WriteString("Exchanges to add:\n")
+	for _, t := range turns {
+		fmt.Fprintf(&b, "- user: %s\n- assistant: %s\n", t.Input, t.Response)
+	}
+	return core.Prompt{
+		System: fmt.Sprintf("You keep the running summary of a conversation between a user and an assistant. Update the summary with the exchanges given: keep the user's goals, facts they stated, decisions and open questions, and drop pleasantries. Use at most %d words. Return only the summary.", s.cfg.SummaryWords),
+		User:   b.String(),
+	}
+}
+
+// Register installs a callback remembering each completed run as a turn of
+// its session, from its record in runs. It must be registered after the
+// history recorder, which saves the record.
+func Register(runner core.Runner, memory Memory, runs history.Store) error {
+	return runner.RegisterCallback(core.HookAfterEventHandling, "conversation", func(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+		if args.Error != nil || args.State == nil || args.Event == nil {
+			return args.State, nil
+		}
+		if route, _ := args.State.GetMeta(core.RouteMetadataKey); route != "" {
+			return args.State, nil
+		}
+		// Re-runs repeat a turn the session already had
+		if rerun, _ := args.Event.GetMetadataValue(history.RerunOfKey); rerun != "" {
+			return args.State, nil
+		}
+		session := SessionOf(args.Event)
+		final, _ := args.State.Get("final_response")
+		response, _ := final.(string)
+		if session.ID == "" || response == "" {
+			return args.State, nil
+		}
+		runID, _ := args.Event.GetMetadataValue(history.RunIDKey)
+		run, err := runs.Get(ctx, runID)
+		if err != nil {
+			core.Logger().Warn().Str("run_id", runID).Err(err).Msg("Failed to load run for conversation memory")
+			return args.State, nil
+		}
+		if err := memory.Remember(ctx, session, Turn{RunID: runID, Input: run.Input, Response: response}); err != nil {
+			core.Logger().Warn().Str("run_id", runID).Err(err).Msg("Failed to remember conversation turn")
+		}
+		return args.State, nil
+	})
+}
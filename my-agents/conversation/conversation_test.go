@@ -0,0 +1,145 @@
+package conversation
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/auth"
+	"my-agents/storage"
+)
+
+// summarizer answers every call with the number of exchanges it was given.
+type summarizer struct {
+	core.ModelProvider
+}
+
+func (summarizer) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	return core.Response{Content: fmt.Sprintf("folded %d", strings.Count(prompt.User, "- user:"))}, nil
+}
+
+func open(t *testing.T, llm core.ModelProvider) *Store {
+	t.Helper()
+	s, err := Open(Config{BufferTurns: 2}, filepath.Join(t.TempDir(), "agentflow.db"), llm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(s.Close)
+	return s
+}
+
+func remember(t *testing.T, s *Store, session Session, inputs ...string) {
+	t.Helper()
+	for _, in := range inputs {
+		if err := s.Remember(context.Background(), session, Turn{RunID: in, Input: in, Response: "re " + in}); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSessionsAreScopedToTheirCaller(t *testing.T) {
+	s := open(t, nil)
+	ctx := context.Background()
+	ann := Session{Tenant: "acme", Principal: "user:acme/ann", ID: "s1"}
+	bob := Session{Tenant: "acme", Principal: "user:acme/bob", ID: "s1"}
+	other := Session{Tenant: "globex", Principal: "user:acme/ann", ID: "s1"}
+	remember(t, s, ann, "hi")
+
+	if c, err := s.Recall(ctx, ann); err != nil || len(c.Recent) != 1 || c.Recent[0].Input != "hi" {
+		t.Errorf("Recall of the caller's session = %+v, %v", c, err)
+	}
+	for _, session := range []Session{bob, other, {ID: "s1"}} {
+		if c, err := s.Recall(ctx, session); err != nil || !c.Empty() {
+			t.Errorf("Recall(%+v) = %+v, %v; want another caller's session left out", session, c, err)
+		}
+	}
+	if n, err := s.Forget(ctx, bob); err != nil || n != 0 {
+		t.Errorf("Forget of another caller's session = %d, %v", n, err)
+	}
+	if n, err := s.Forget(ctx, ann); err != nil || n != 1 {
+		t.Errorf("Forget = %d, %v; want the 1 turn", n, err)
+	}
+}
+
+func TestSessionOf(t *testing.T) {
+	p := auth.Principal{Client: "webapp", Tenant: "acme", User: "ann"}
+	md := p.Metadata()
+	md[core.SessionIDKey] = "s1"
+	got := SessionOf(core.NewEvent("processor", nil, md))
+	want := Session{Tenant: "acme", Principal: "user:acme/ann", ID: "s1"}
+	if got != want {
+		t.Errorf("SessionOf = %+v, want %+v", got, want)
+	}
+}
+
+func TestFoldKeepsTheBuffer(t *testing.T) {
+	s := open(t, summarizer{})
+	ctx := context.Background()
+	session := Session{Principal: "client:cli", ID: "s1"}
+	remember(t, s, session, "a", "b", "c", "d")
+	if err := s.Fold(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+	c, err := s.Recall(ctx, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Summary != "folded 2" || len(c.Recent) != 2 || c.Recent[0].Input != "c" || c.Recent[1].Input != "d" {
+		t.Errorf("after folding, Recall = %+v; want a and b summarized and c, d kept", c)
+	}
+	if !strings.Contains(c.Prompt(), "Summary: folded 2") || !strings.Contains(c.Prompt(), "- user: d") {
+		t.Errorf("Prompt = %q", c.Prompt())
+	}
+	if n, err := s.Forget(ctx, session); err != nil || n != 4 {
+		t.Errorf("Forget = %d, %v; want the 2 folded and 2 buffered turns", n, err)
+	}
+}
+
+func TestEraseCoversEveryCaller(t *testing.T) {
+	s := open(t, nil)
+	remember(t, s, Session{Principal: "ip:10.0.0.1", ID: "s1"}, "a")
+	remember(t, s, Session{Principal: "ip:10.0.0.2", ID: "s1"}, "b")
+	remember(t, s, Session{Principal: "ip:10.0.0.2", ID: "s2"}, "c")
+	if n, err := s.Erase(context.Background(), "s1"); err != nil || n != 2 {
+		t.Errorf("Erase = %d, %v; want both callers' turns", n, err)
+	}
+	if c, _ := s.Recall(context.Background(), Session{Principal: "ip:10.0.0.2", ID: "s2"}); len(c.Recent) != 1 {
+		t.Error("Erase removed another session")
+	}
+}
+
+func TestUnscopedSummariesMigrate(t *testing.T) {
+	db, err := storage.Open(filepath.Join(t.TempDir(), "agentflow.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = storage.Migrate(db,
+		`CREATE TABLE conversation_turns (seq INTEGER PRIMARY KEY AUTOINCREMENT, session_id TEXT NOT NULL, run_id TEXT NOT NULL, input TEXT NOT NULL, response TEXT NOT NULL, at INTEGER NOT NULL)`,
+		`CREATE INDEX conversation_turns_session ON conversation_turns (session_id, seq)`,
+		`CREATE TABLE conversation_summaries (session_id TEXT PRIMARY KEY, summary TEXT NOT NULL, turns INTEGER NOT NULL, updated_at INTEGER NOT NULL)`,
+		`INSERT INTO conversation_turns (session_id, run_id, input, response, at) VALUES ('s1', 'r1', 'hi', 'hello', 1)`,
+		`INSERT INTO conversation_summaries VALUES ('s1', 'earlier', 3, 1)`,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := New(Config{}, db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	c, err := s.Recall(context.Background(), Session{ID: "s1"})
+	if err != nil || c.Summary != "earlier" || len(c.Recent) != 1 {
+		t.Errorf("Recall after migrating = %+v, %v; want the unscoped memory kept for events without a principal", c, err)
+	}
+	// Opening again finds the schema migrated
+	again, err := New(Config{}, db, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again.Close()
+}
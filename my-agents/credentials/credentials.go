@@ -0,0 +1,146 @@
+// Package credentials reloads provider API keys while the process runs, from
+// secret files that are watched for changes and from agentflow.toml on
+// SIGHUP, so keys can be rotated without a restart.
+package credentials
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Config is the [credentials] section of agentflow.toml:
+//
+//	[credentials]
+//	enabled = true
+//	interval = "30s"
+//	[credentials.files]
+//	openai = "/run/secrets/openai_api_key"
+type Config struct {
+	Enabled  bool   `toml:"enabled"`
+	Interval string `toml:"interval"` // how often files are checked (default "30s")
+	// Files maps a [providers.<name>] table to a file holding its API key,
+	// e.g. a mounted Kubernetes or Docker secret. A file's key takes
+	// precedence over the api_key in agentflow.toml.
+	Files map[string]string `toml:"files"`
+}
+
+// Reloader applies changed keys through a rotate function. Calls already in
+// flight when a key changes finish on the client they started with.
+type Reloader struct {
+	cfg      Config
+	interval time.Duration
+	rotate   func(provider, apiKey string) error
+	config   func() (map[string]string, error)
+
+	mu   sync.Mutex
+	seen map[string][sha256.Size]byte // provider → hash of the key in use
+}
+
+// New creates a reloader that hands changed keys to rotate.
+func New(cfg Config, rotate func(provider, apiKey string) error) *Reloader {
+	r := &Reloader{cfg: cfg, interval: 30 * time.Second, rotate: rotate, seen: make(map[string][sha256.Size]byte)}
+	if d, err := time.ParseDuration(cfg.Interval); err == nil && d > 0 {
+		r.interval = d
+	}
+	return r
+}
+
+// SetConfigKeys sets how the keys in agentflow.toml are re-read on reload.
+func (r *Reloader) SetConfigKeys(keys func() (map[string]string, error)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.config = keys
+}
+
+// Reload re-reads every key source and rotates the providers whose key
+// changed, returning their names.
+func (r *Reloader) Reload() ([]string, error) {
+	return r.reload(true)
+}
+
+// Run checks the key files every interval and reloads all sources on SIGHUP,
+// until ctx is cancelled.
+func (r *Reloader) Run(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		withConfig := false
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-hup:
+			withConfig = true
+			core.Logger().Info().Msg("SIGHUP received; reloading provider keys")
+		}
+		if _, err := r.reload(withConfig); err != nil {
+			core.Logger().Error().Err(err).Msg("Provider key reload failed")
+		}
+	}
+}
+
+func (r *Reloader) reload(withConfig bool) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make(map[string]string)
+	sources := make(map[string]string)
+	var errs []error
+	if withConfig && r.config != nil {
+		configKeys, err := r.config()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("config: %w", err))
+		}
+		for name, key := range configKeys {
+			keys[name], sources[name] = key, "config"
+		}
+	}
+	for name, path := range r.cfg.Files {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			delete(keys, name) // don't fall back to a key the file replaced
+			continue
+		}
+		keys[name], sources[name] = strings.TrimSpace(string(data)), path
+	}
+
+	names := make([]string, 0, len(keys))
+	for name := range keys {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var rotated []string
+	for _, name := range names {
+		key := keys[name]
+		if key == "" {
+			continue
+		}
+		sum := sha256.Sum256([]byte(key))
+		if r.seen[name] == sum {
+			continue
+		}
+		if err := r.rotate(name, key); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			continue
+		}
+		r.seen[name] = sum
+		rotated = append(rotated, name)
+		core.Logger().Info().Str("provider", name).Str("source", sources[name]).Msg("Provider key rotated")
+	}
+	return rotated, errors.Join(errs...)
+}
@@ -0,0 +1,88 @@
+package credentials
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeKey(t *testing.T, path, key string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(key+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	openai := filepath.Join(dir, "openai")
+	writeKey(t, openai, "sk-1")
+	keys := map[string]string{}
+	r := New(Config{Files: map[string]string{"openai": openai}}, func(provider, key string) error {
+		keys[provider] = key
+		return nil
+	})
+	r.SetConfigKeys(func() (map[string]string, error) {
+		return map[string]string{"openai": "sk-config", "azure": "az-1"}, nil
+	})
+
+	rotated, err := r.Reload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rotated, []string{"azure", "openai"}) {
+		t.Errorf("rotated = %v", rotated)
+	}
+	if keys["openai"] != "sk-1" || keys["azure"] != "az-1" {
+		t.Errorf("keys = %v, want the file's key over the config's", keys)
+	}
+
+	if rotated, _ := r.Reload(); len(rotated) != 0 {
+		t.Errorf("unchanged keys rotated again: %v", rotated)
+	}
+	writeKey(t, openai, "sk-2")
+	if rotated, _ := r.reload(false); !reflect.DeepEqual(rotated, []string{"openai"}) || keys["openai"] != "sk-2" {
+		t.Errorf("file change: rotated %v, key %q", rotated, keys["openai"])
+	}
+}
+
+func TestReloadErrors(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good")
+	writeKey(t, good, "k")
+	calls := 0
+	r := New(Config{Files: map[string]string{"missing": filepath.Join(dir, "nope"), "good": good, "bad": good}}, func(provider, key string) error {
+		calls++
+		if provider == "bad" {
+			return errors.New("rejected")
+		}
+		return nil
+	})
+	r.SetConfigKeys(func() (map[string]string, error) {
+		return map[string]string{"missing": "from-config"}, nil
+	})
+	rotated, err := r.Reload()
+	if err == nil {
+		t.Fatal("Reload hid the missing file and the rejected key")
+	}
+	if !reflect.DeepEqual(rotated, []string{"good"}) {
+		t.Errorf("rotated = %v, want only good; missing must not fall back to the config", rotated)
+	}
+	// A rejected key is retried next time
+	calls = 0
+	r.Reload()
+	if calls != 1 {
+		t.Errorf("rotate called %d times, want 1 retry of bad", calls)
+	}
+}
+
+func TestInterval(t *testing.T) {
+	if r := New(Config{Interval: "5s"}, nil); r.interval.Seconds() != 5 {
+		t.Errorf("interval = %v", r.interval)
+	}
+	if r := New(Config{Interval: "often"}, nil); r.interval.Seconds() != 30 {
+		t.Errorf("invalid interval = %v, want the default", r.interval)
+	}
+}
@@ -0,0 +1,291 @@
+// Package deadletter keeps the events agents failed on, with the error, how
+// many attempts the agent made and the state it last ran on, so they can be
+// inspected and re-emitted once the cause is fixed instead of ending with a
+// log line.
+package deadletter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+	"my-agents/retry"
+	"my-agents/storage"
+)
+
+// RedrivesKey is the event metadata key counting how many times an event
+// was re-emitted from the queue.
+const RedrivesKey = "redrives"
+
+// ErrNotFound is returned for an unknown dead letter.
+var ErrNotFound = errors.New("dead letter not found")
+
+// Config is the [dead_letter] section of agentflow.toml.
+type Config struct {
+	Enabled bool   `toml:"enabled"`
+	Path    string `toml:"path"` // database file (default the [storage] path)
+}
+
+// Letter is an event an agent failed on.
+type Letter struct {
+	ID        string `json:"id"` // the event's ID
+	RunID     string `json:"run_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	Agent     string `json:"agent"`
+	Error     string `json:"error"`
+	// Attempts counts the agent's tries, retries included.
+	Attempts int               `json:"attempts"`
+	Redrives int               `json:"redrives,omitempty"`
+	Data     map[string]any    `json:"data"`
+	Metadata map[string]string `json:"metadata"`
+	// State is the state the agent ran on, event data merged in.
+	State    map[string]any `json:"state"`
+	FailedAt time.Time      `json:"failed_at"`
+}
+
+// Event rebuilds the failed event, routed back to its agent, under a new ID.
+func (l *Letter) Event() core.Event {
+	metadata := make(map[string]string, len(l.Metadata)+2)
+	for k, v := range l.Metadata {
+		metadata[k] = v
+	}
+	metadata[core.RouteMetadataKey] = l.Agent
+	metadata[RedrivesKey] = strconv.Itoa(l.Redrives + 1)
+	return core.NewEvent(l.Agent, core.EventData(l.Data), metadata)
+}
+
+// Filter selects dead letters. Zero fields match everything.
+type Filter struct {
+	Agent string
+	RunID string
+	Limit int // most recent letters; 0 for all
+}
+
+// Queue stores dead letters in the embedded database.
+type Queue struct {
+	db   *sql.DB
+	runs history.Store
+}
+
+// Open opens the configured queue. Redriven events continue their run in
+// runs.
+func Open(cfg Config, runs history.Store) (*Queue, error) {
+	db, err := storage.Open(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	return New(db, runs)
+}
+
+// New creates a queue in db, creating its table if needed.
+func New(db *sql.DB, runs history.Store) (*Queue, error) {
+	err := storage.Migrate(db,
+		`CREATE TABLE IF NOT EXISTS dead_letters (
+			id         TEXT PRIMARY KEY,
+			run_id     TEXT NOT NULL,
+			session_id TEXT NOT NULL,
+			agent      TEXT NOT NULL,
+			error      TEXT NOT NULL,
+			attempts   INTEGER NOT NULL,
+			redrives   INTEGER NOT NULL,
+			data       TEXT NOT NULL,
+			metadata   TEXT NOT NULL,
+			state      TEXT NOT NULL,
+			failed_at  INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS dead_letters_failed_at ON dead_letters (failed_at)`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Queue{db: db, runs: runs}, nil
+}
+
+// Register stores the events agents on runner fail on.
+func (q *Queue) Register(runner core.Runner) error {
+	return runner.RegisterCallback(core.HookAgentError, "dead-letter", q.record)
+}
+
+func (q *Queue) record(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+	event := args.Event
+	if event == nil || args.Error == nil {
+		return args.State, nil
+	}
+	// Failure notifications from the runner's error routing aren't events
+	// the pipeline was asked to handle
+	if status, _ := event.GetMetadataValue("status"); status == "error" {
+		return args.State, nil
+	}
+	runID, _ := event.GetMetadataValue(history.RunIDKey)
+	redrives, _ := event.GetMetadataValue(RedrivesKey)
+	l := &Letter{
+		ID:        event.GetID(),
+		RunID:     runID,
+		SessionID: event.GetSessionID(),
+		Agent:     args.AgentID,
+		Error:     args.Error.Error(),
+		Attempts:  retry.Attempts(args.Error),
+		Redrives:  atoi(redrives),
+		Data:      event.GetData(),
+		Metadata:  event.GetMetadata(),
+		State:     stateData(args.State),
+		FailedAt:  time.Now(),
+	}
+	if err := q.add(ctx, l); err != nil {
+		core.Logger().Error().Str("event_id", l.ID).Str("agent", l.Agent).Err(err).Msg("Failed to store dead letter")
+	}
+	return args.State, nil
+}
+
+func (q *Queue) add(ctx context.Context, l *Letter) error {
+	data, err := json.Marshal(l.Data)
+	if err != nil {
+		return err
+	}
+	meta, err := json.Marshal(l.Metadata)
+	if err != nil {
+		return err
+	}
+	state, err := json.Marshal(l.State)
+	if err != nil {
+		return err
+	}
+	// The orchestrator reports a failure before the runner does, with the
+	// state the agent ran on; the runner's report adds nothing
+	_, err = q.db.ExecContext(ctx,
+		`INSERT INTO dead_letters (id, run_id, session_id, agent, error, attempts, redrives, data, metadata, state, failed_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (id) DO NOTHING`,
+		l.ID, l.RunID, l.SessionID, l.Agent, l.Error, l.Attempts, l.Redrives,
+		string(data), string(meta), string(state), l.FailedAt.UnixNano())
+	return err
+}
+
+// List returns matching letters, most recent first.
+func (q *Queue) List(ctx context.Context, filter Filter) ([]Letter, error) {
+	query := `SELECT id, run_id, session_id, agent, error, attempts, redrives, data, metadata, state, failed_at FROM dead_letters`
+	var where []string
+	var args []any
+	if filter.Agent != "" {
+		where, args = append(where, "agent = ?"), append(args, filter.Agent)
+	}
+	if filter.RunID != "" {
+		where, args = append(where, "run_id = ?"), append(args, filter.RunID)
+	}
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY failed_at DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+	rows, err := q.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Letter
+	for rows.Next() {
+		l, err := scan(rows)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *l)
+	}
+	return out, rows.Err()
+}
+
+// Get returns the letter of event id.
+func (q *Queue) Get(ctx context.Context, id string) (*Letter, error) {
+	row := q.db.QueryRowContext(ctx,
+		`SELECT id, run_id, session_id, agent, error, attempts, redrives, data, metadata, state, failed_at FROM dead_letters WHERE id = ?`, id)
+	l, err := scan(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return l, err
+}
+
+// Delete drops the letter of event id.
+func (q *Queue) Delete(ctx context.Context, id string) error {
+	res, err := q.db.ExecContext(ctx, `DELETE FROM dead_letters WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return fmt.Errorf("%w: %s", ErrNotFound, id)
+	}
+	return nil
+}
+
+// Redrive re-emits the letter of event id on runner, which must be started,
+// and removes it. Its run, failed by the event, is reopened so the redriven
+// event continues it. It returns the emitted event; if the agent fails again
+// that event becomes a new letter.
+func (q *Queue) Redrive(ctx context.Context, id string, runner core.Runner) (core.Event, error) {
+	l, err := q.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if run, err := q.runs.Get(ctx, l.RunID); err == nil && run.Status == history.StatusFailed {
+		run.Status, run.Error, run.EndedAt = history.StatusRunning, "", time.Time{}
+		if err := q.runs.Save(ctx, run); err != nil {
+			return nil, fmt.Errorf("failed to reopen run %s: %w", run.ID, err)
+		}
+	}
+	event := l.Event()
+	if err := runner.Emit(event); err != nil {
+		return nil, fmt.Errorf("failed to re-emit dead letter %s: %w", id, err)
+	}
+	return event, q.Delete(ctx, id)
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scan(row scanner) (*Letter, error) {
+	var l Letter
+	var data, meta, state string
+	var failedAt int64
+	if err := row.Scan(&l.ID, &l.RunID, &l.SessionID, &l.Agent, &l.Error, &l.Attempts, &l.Redrives, &data, &meta, &state, &failedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(data), &l.Data); err != nil {
+		return nil, fmt.Errorf("failed to decode dead letter %s: %w", l.ID, err)
+	}
+	if err := json.Unmarshal([]byte(meta), &l.Metadata); err != nil {
+		return nil, fmt.Errorf("failed to decode dead letter %s: %w", l.ID, err)
+	}
+	if err := json.Unmarshal([]byte(state), &l.State); err != nil {
+		return nil, fmt.Errorf("failed to decode dead letter %s: %w", l.ID, err)
+	}
+	l.FailedAt = time.Unix(0, failedAt)
+	return &l, nil
+}
+
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+func stateData(state core.State) map[string]any {
+	out := make(map[string]any)
+	if state == nil {
+		return out
+	}
+	for _, k := range state.Keys() {
+		if v, ok := state.Get(k); ok {
+			out[k] = v
+		}
+	}
+	return out
+}
@@ -0,0 +1,185 @@
+package deadletter
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+	"my-agents/retry"
+)
+
+type runner struct {
+	core.Runner
+	callback core.CallbackFunc
+	emitted  []core.Event
+	err      error
+}
+
+func (r *runner) RegisterCallback(hook core.HookPoint, name string, cb core.CallbackFunc) error {
+	r.callback = cb
+	return nil
+}
+
+func (r *runner) Emit(event core.Event) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.emitted = append(r.emitted, event)
+	return nil
+}
+
+func open(t *testing.T, runs history.Store) (*Queue, *runner) {
+	t.Helper()
+	q, err := Open(Config{Enabled: true, Path: filepath.Join(t.TempDir(), "agentflow.db")}, runs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &runner{}
+	if err := q.Register(r); err != nil {
+		t.Fatal(err)
+	}
+	return q, r
+}
+
+// fail reports agent failing on a new event of run.
+func fail(r *runner, agent, runID string, err error) core.Event {
+	event := core.NewEvent(agent, core.EventData{"draft": "hi"}, map[string]string{history.RunIDKey: runID})
+	state := core.NewState()
+	state.Set("topic", "go")
+	r.callback(context.Background(), core.CallbackArgs{AgentID: agent, Event: event, State: state, Error: err})
+	return event
+}
+
+func TestRecord(t *testing.T) {
+	q, r := open(t, history.NewMemoryStore())
+	ctx := context.Background()
+	event := fail(r, "writer", "run-1", &retry.Exhausted{Agent: "writer", Attempts: 3, Err: errors.New("status 429")})
+	// The runner reports the failure again after the orchestrator
+	r.callback(ctx, core.CallbackArgs{AgentID: "writer", Event: event, Error: errors.New("again")})
+	// Error notifications aren't letters
+	r.callback(ctx, core.CallbackArgs{AgentID: "error-handler", Event: core.NewEvent("error-handler", nil, map[string]string{"status": "error"}), Error: errors.New("x")})
+	r.callback(ctx, core.CallbackArgs{AgentID: "writer", Event: core.NewEvent("writer", nil, nil)})
+
+	letters, err := q.List(ctx, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 1 {
+		t.Fatalf("letters = %+v", letters)
+	}
+	l := letters[0]
+	if l.ID != event.GetID() || l.RunID != "run-1" || l.Agent != "writer" || l.Attempts != 3 || l.Error != "status 429 (after 3 attempts)" {
+		t.Errorf("letter = %+v", l)
+	}
+	if l.Data["draft"] != "hi" || l.State["topic"] != "go" || l.Metadata[history.RunIDKey] != "run-1" || l.FailedAt.IsZero() {
+		t.Errorf("letter = %+v", l)
+	}
+}
+
+func TestList(t *testing.T) {
+	q, r := open(t, history.NewMemoryStore())
+	ctx := context.Background()
+	fail(r, "writer", "run-1", errors.New("a"))
+	time.Sleep(time.Millisecond)
+	fail(r, "formatter", "run-1", errors.New("b"))
+	time.Sleep(time.Millisecond)
+	fail(r, "writer", "run-2", errors.New("c"))
+
+	tests := []struct {
+		filter Filter
+		want   []string
+	}{
+		{Filter{}, []string{"c", "b", "a"}},
+		{Filter{Agent: "writer"}, []string{"c", "a"}},
+		{Filter{RunID: "run-1"}, []string{"b", "a"}},
+		{Filter{Agent: "writer", RunID: "run-1"}, []string{"a"}},
+		{Filter{Limit: 1}, []string{"c"}},
+	}
+	for _, tt := range tests {
+		letters, err := q.List(ctx, tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, l := range letters {
+			got = append(got, l.Error)
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%+v = %v, want %v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestGetDelete(t *testing.T) {
+	q, r := open(t, history.NewMemoryStore())
+	ctx := context.Background()
+	event := fail(r, "writer", "run-1", errors.New("boom"))
+	if l, err := q.Get(ctx, event.GetID()); err != nil || l.Error != "boom" {
+		t.Errorf("get = %+v, %v", l, err)
+	}
+	if err := q.Delete(ctx, event.GetID()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := q.Get(ctx, event.GetID()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("get deleted err = %v", err)
+	}
+	if err := q.Delete(ctx, event.GetID()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("delete twice err = %v", err)
+	}
+}
+
+func TestRedrive(t *testing.T) {
+	ctx := context.Background()
+	runs := history.NewMemoryStore()
+	runs.Save(ctx, &history.Run{ID: "run-1", Status: history.StatusFailed, Error: "boom", EndedAt: time.Now()})
+	q, r := open(t, runs)
+	event := fail(r, "writer", "run-1", errors.New("boom"))
+
+	redriven, err := q.Redrive(ctx, event.GetID(), r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.emitted) != 1 || r.emitted[0] != redriven || redriven.GetID() == event.GetID() {
+		t.Fatalf("emitted %v", r.emitted)
+	}
+	if route, _ := redriven.GetMetadataValue(core.RouteMetadataKey); route != "writer" {
+		t.Errorf("routed to %q", route)
+	}
+	if n, _ := redriven.GetMetadataValue(RedrivesKey); n != "1" {
+		t.Errorf("redrives = %q", n)
+	}
+	if run, _ := runs.Get(ctx, "run-1"); run.Status != history.StatusRunning || run.Error != "" || !run.EndedAt.IsZero() {
+		t.Errorf("run = %+v", run)
+	}
+	if _, err := q.Get(ctx, event.GetID()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("redriven letter kept: %v", err)
+	}
+
+	// Failing again, the letter counts the redrive
+	r.callback(ctx, core.CallbackArgs{AgentID: "writer", Event: redriven, Error: errors.New("boom")})
+	if l, err := q.Get(ctx, redriven.GetID()); err != nil || l.Redrives != 1 {
+		t.Errorf("letter = %+v, %v", l, err)
+	}
+}
+
+func TestRedriveEmitFails(t *testing.T) {
+	q, r := open(t, history.NewMemoryStore())
+	ctx := context.Background()
+	event := fail(r, "writer", "run-1", errors.New("boom"))
+	r.err = errors.New("runner stopped")
+	if _, err := q.Redrive(ctx, event.GetID(), r); err == nil {
+		t.Fatal("redrive succeeded")
+	}
+	if _, err := q.Get(ctx, event.GetID()); err != nil {
+		t.Errorf("letter lost: %v", err)
+	}
+	if _, err := q.Redrive(ctx, "missing", r); !errors.Is(err, ErrNotFound) {
+		t.Errorf("unknown letter err = %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+// Handler serves the queue, for mounting on the admin API under
+// "/admin/dead-letters" and "/admin/dead-letters/":
+//
+//	GET    /admin/dead-letters[?agent=&run=&limit=]   letters, most recent first
+//	GET    /admin/dead-letters/{id}                    one letter, with its state
+//	POST   /admin/dead-letters/{id}/redrive            re-emit the event on runner
+//	DELETE /admin/dead-letters/{id}                    drop the letter
+func (q *Queue) Handler(runner core.Runner) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/dead-letters", func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		filter := Filter{Agent: query.Get("agent"), RunID: query.Get("run")}
+		if raw := query.Get("limit"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 {
+				writeError(w, http.StatusBadRequest, errors.New("limit must be a positive number"))
+				return
+			}
+			filter.Limit = n
+		}
+		letters, err := q.List(r.Context(), filter)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		if letters == nil {
+			letters = []Letter{}
+		}
+		writeJSON(w, http.StatusOK, letters)
+	})
+	mux.HandleFunc("GET /admin/dead-letters/{id}", func(w http.ResponseWriter, r *http.Request) {
+		l, err := q.Get(r.Context(), r.PathValue("id"))
+		if err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, l)
+	})
+	mux.HandleFunc("POST /admin/dead-letters/{id}/redrive", func(w http.ResponseWriter, r *http.Request) {
+		event, err := q.Redrive(r.Context(), r.PathValue("id"), runner)
+		if event == nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		if err != nil {
+			core.Logger().Error().Str("id", r.PathValue("id")).Err(err).Msg("Failed to remove redriven dead letter")
+		}
+		runID, _ := event.GetMetadataValue(history.RunIDKey)
+		writeJSON(w, http.StatusAccepted, map[string]string{"event_id": event.GetID(), "run_id": runID})
+	})
+	mux.HandleFunc("DELETE /admin/dead-letters/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if err := q.Delete(r.Context(), r.PathValue("id")); err != nil {
+			writeError(w, statusOf(err), err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+func statusOf(err error) int {
+	if errors.Is(err, ErrNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusInternalServerError
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
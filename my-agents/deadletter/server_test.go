@@ -0,0 +1,59 @@
+package deadletter
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"my-agents/history"
+)
+
+func TestHandler(t *testing.T) {
+	q, r := open(t, history.NewMemoryStore())
+	first := fail(r, "writer", "run-1", errors.New("a"))
+	second := fail(r, "formatter", "run-2", errors.New("b"))
+	h := q.Handler(r)
+	do := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	var letters []Letter
+	json.NewDecoder(do("GET", "/admin/dead-letters?agent=writer").Body).Decode(&letters)
+	if len(letters) != 1 || letters[0].ID != first.GetID() {
+		t.Errorf("writer's letters = %+v", letters)
+	}
+	if w := do("GET", "/admin/dead-letters?run=run-9"); w.Body.String() != "[]\n" {
+		t.Errorf("no letters = %s", w.Body)
+	}
+	if w := do("GET", "/admin/dead-letters?limit=-1"); w.Code != http.StatusBadRequest {
+		t.Errorf("bad limit: status %d", w.Code)
+	}
+	var l Letter
+	json.NewDecoder(do("GET", "/admin/dead-letters/"+second.GetID()).Body).Decode(&l)
+	if l.Agent != "formatter" {
+		t.Errorf("letter = %+v", l)
+	}
+
+	w := do("POST", "/admin/dead-letters/"+first.GetID()+"/redrive")
+	var redriven map[string]string
+	json.NewDecoder(w.Body).Decode(&redriven)
+	if w.Code != http.StatusAccepted || redriven["run_id"] != "run-1" || redriven["event_id"] != r.emitted[0].GetID() {
+		t.Errorf("redrive: %d %v", w.Code, redriven)
+	}
+
+	if w := do("DELETE", "/admin/dead-letters/"+second.GetID()); w.Code != http.StatusNoContent {
+		t.Errorf("delete: status %d", w.Code)
+	}
+	for _, method := range []string{"GET", "DELETE"} {
+		if w := do(method, "/admin/dead-letters/"+second.GetID()); w.Code != http.StatusNotFound {
+			t.Errorf("%s deleted letter: status %d", method, w.Code)
+		}
+	}
+	if w := do("POST", "/admin/dead-letters/"+second.GetID()+"/redrive"); w.Code != http.StatusNotFound {
+		t.Errorf("redrive deleted letter: status %d", w.Code)
+	}
+}
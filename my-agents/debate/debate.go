@@ -0,0 +1,118 @@
+// Package debate runs a structured debate: two agents argue opposing
+// positions for a number of rounds and a judge synthesizes the conclusion.
+package debate
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// TranscriptKey is the state key holding the []Turn of a debate.
+const TranscriptKey = "debate"
+
+// Config is the [debate] section of agentflow.toml:
+//
+//	[debate]
+//	rounds = 2
+//	[debate.proponent]
+//	position = "Argue for adopting the proposal."
+//	[debate.opponent]
+//	position = "Argue against adopting the proposal."
+//	provider = "critic"
+type Config struct {
+	Enabled   bool   `toml:"enabled"`
+	Rounds    int    `toml:"rounds"` // default 2
+	Next      string `toml:"next"`   // agent routed to afterwards (default "formatter")
+	Proponent Role   `toml:"proponent"`
+	Opponent  Role   `toml:"opponent"`
+	Judge     Role   `toml:"judge"`
+}
+
+// Role configures one participant.
+type Role struct {
+	Name     string `toml:"name"`
+	Position string `toml:"position"` // instructions for this side
+	Provider string `toml:"provider"` // [providers.<name>]; empty uses the default
+}
+
+// Turn is one argument in the debate.
+type Turn struct {
+	Round    int    `json:"round"`
+	Speaker  string `json:"speaker"`
+	Argument string `json:"argument"`
+}
+
+// Result is the full debate and the judge's synthesis.
+type Result struct {
+	Turns   []Turn
+	Verdict string
+}
+
+// Debate holds the participants' providers.
+type Debate struct {
+	cfg                  Config
+	pro, con, judgeModel core.ModelProvider
+}
+
+// New creates a debate; the three providers may be the same model.
+func New(cfg Config, pro, con, judge core.ModelProvider) *Debate {
+	if cfg.Rounds <= 0 {
+		cfg.Rounds = 2
+	}
+	cfg.Proponent.Name = cmp.Or(cfg.Proponent.Name, "proponent")
+	cfg.Opponent.Name = cmp.Or(cfg.Opponent.Name, "opponent")
+	cfg.Judge.Name = cmp.Or(cfg.Judge.Name, "judge")
+	cfg.Proponent.Position = cmp.Or(cfg.Proponent.Position, "Argue in favour of the strongest answer to the question.")
+	cfg.Opponent.Position = cmp.Or(cfg.Opponent.Position, "Argue against your opponent's position and expose its weaknesses.")
+	cfg.Judge.Position = cmp.Or(cfg.Judge.Position, "Weigh both sides fairly and give a decision with its reasoning, noting the strongest points of each side.")
+	return &Debate{cfg: cfg, pro: pro, con: con, judgeModel: judge}
+}
+
+// Run debates question. Each side sees the full transcript so far.
+func (d *Debate) Run(ctx context.Context, question string) (*Result, error) {
+	res := &Result{}
+	sides := []struct {
+		role Role
+		llm  core.ModelProvider
+	}{{d.cfg.Proponent, d.pro}, {d.cfg.Opponent, d.con}}
+
+	for round := 1; round <= d.cfg.Rounds; round++ {
+		for _, side := range sides {
+			resp, err := side.llm.Call(ctx, core.Prompt{
+				System: fmt.Sprintf("You are %s in a debate. %s Be concise: at most 150 words. Respond to the other side's latest points.", side.role.Name, side.role.Position),
+				User:   fmt.Sprintf("Question: %s\n\n%sRound %d of %d — your argument:", question, transcript(res.Turns), round, d.cfg.Rounds),
+			})
+			if err != nil {
+				return nil, fmt.Errorf("debate round %d (%s): %w", round, side.role.Name, err)
+			}
+			res.Turns = append(res.Turns, Turn{Round: round, Speaker: side.role.Name, Argument: strings.TrimSpace(resp.Content)})
+		}
+	}
+
+	resp, err := d.judgeModel.Call(ctx, core.Prompt{
+		System: fmt.Sprintf("You are the %s of a debate. %s", d.cfg.Judge.Name, d.cfg.Judge.Position),
+		User:   fmt.Sprintf("Question: %s\n\n%sYour conclusion:", question, transcript(res.Turns)),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("debate judge: %w", err)
+	}
+	res.Verdict = strings.TrimSpace(resp.Content)
+	return res, nil
+}
+
+func transcript(turns []Turn) string {
+	if len(turns) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("Debate so far:\n")
+	for _, t := range turns {
+		fmt.Fprintf(&b, "\n[Round %d — %s]\n%s\n", t.Round, t.Speaker, t.Argument)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
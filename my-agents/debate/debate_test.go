@@ -0,0 +1,64 @@
+package debate
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// speaker replies with its line and records the prompts it was sent.
+type speaker struct {
+	core.ModelProvider
+	line    string
+	err     error
+	prompts []core.Prompt
+}
+
+func (s *speaker) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	s.prompts = append(s.prompts, prompt)
+	return core.Response{Content: " " + s.line + " "}, s.err
+}
+
+func TestRun(t *testing.T) {
+	pro, con, judge := &speaker{line: "yes"}, &speaker{line: "no"}, &speaker{line: "maybe"}
+	res, err := New(Config{Opponent: Role{Name: "skeptic"}}, pro, con, judge).Run(context.Background(), "Adopt Go?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Turn{{1, "proponent", "yes"}, {1, "skeptic", "no"}, {2, "proponent", "yes"}, {2, "skeptic", "no"}}
+	if len(res.Turns) != len(want) {
+		t.Fatalf("Turns = %+v", res.Turns)
+	}
+	for i := range want {
+		if res.Turns[i] != want[i] {
+			t.Errorf("turn %d = %+v, want %+v", i, res.Turns[i], want[i])
+		}
+	}
+	if res.Verdict != "maybe" {
+		t.Errorf("Verdict = %q", res.Verdict)
+	}
+	// Each side answers the transcript so far
+	if !strings.Contains(con.prompts[0].User, "[Round 1 — proponent]\nyes") {
+		t.Errorf("opponent prompt = %q", con.prompts[0].User)
+	}
+	if !strings.Contains(judge.prompts[0].User, "[Round 2 — skeptic]\nno") {
+		t.Errorf("judge prompt = %q", judge.prompts[0].User)
+	}
+	if !strings.Contains(con.prompts[0].System, "You are skeptic") {
+		t.Errorf("opponent system prompt = %q", con.prompts[0].System)
+	}
+}
+
+func TestRunFails(t *testing.T) {
+	boom := errors.New("boom")
+	ok := &speaker{line: "fine"}
+	if _, err := New(Config{Rounds: 1}, ok, &speaker{err: boom}, ok).Run(context.Background(), "q"); !errors.Is(err, boom) {
+		t.Errorf("Run = %v, want the opponent's error", err)
+	}
+	if _, err := New(Config{Rounds: 1}, ok, ok, &speaker{err: boom}).Run(context.Background(), "q"); !errors.Is(err, boom) {
+		t.Errorf("Run = %v, want the judge's error", err)
+	}
+}
@@ -0,0 +1,256 @@
+package debugger
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Breakpoint pauses a run before an agent: the one named Agent (any agent
+// when empty), and only when Condition holds for the state it receives.
+// Conditions compare state keys with literals and are joined with &&:
+//
+//	confidence < 0.5
+//	state.category == "billing" && priority >= 3
+//	classification.urgent == true
+//
+// A dotted key reaches into nested objects; a leading "state." is optional.
+// A bare key holds when it is set to anything but false, 0, "" or null.
+type Breakpoint struct {
+	ID        int    `json:"id"`
+	Agent     string `json:"agent,omitempty"`
+	Condition string `json:"condition,omitempty"`
+	Hits      int    `json:"hits"`
+
+	terms []term
+}
+
+func (b Breakpoint) String() string {
+	var parts []string
+	if b.Agent != "" {
+		parts = append(parts, "at "+b.Agent)
+	}
+	if b.Condition != "" {
+		parts = append(parts, "if "+b.Condition)
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "at every agent")
+	}
+	return fmt.Sprintf("breakpoint %d %s", b.ID, strings.Join(parts, " "))
+}
+
+// term is one comparison of a condition; op is empty for a bare key.
+type term struct {
+	path  []string
+	op    string
+	value any
+}
+
+var termPattern = regexp.MustCompile(`^\s*([A-Za-z_][\w.-]*)\s*(?:(==|!=|<=|>=|<|>)\s*(.+?))?\s*$`)
+
+// parseCondition splits a condition into its terms.
+func parseCondition(cond string) ([]term, error) {
+	var terms []term
+	for _, part := range strings.Split(cond, "&&") {
+		m := termPattern.FindStringSubmatch(part)
+		if m == nil {
+			return nil, fmt.Errorf("can't read condition %q: want KEY OP VALUE", strings.TrimSpace(part))
+		}
+		path := strings.Split(strings.TrimPrefix(m[1], "state."), ".")
+		t := term{path: path, op: m[2]}
+		if t.op != "" {
+			t.value = parseValue(m[3])
+		}
+		terms = append(terms, t)
+	}
+	return terms, nil
+}
+
+// Matches reports whether the breakpoint pauses agent receiving data.
+func (b *Breakpoint) Matches(agent string, data core.EventData) bool {
+	if b.Agent != "" && b.Agent != agent {
+		return false
+	}
+	for _, t := range b.terms {
+		if !t.holds(data) {
+			return false
+		}
+	}
+	return true
+}
+
+func (t term) holds(data core.EventData) bool {
+	var v any = map[string]any(data)
+	for _, key := range t.path {
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return false
+		}
+		if v, ok = obj[key]; !ok {
+			return false
+		}
+	}
+	if t.op == "" {
+		return truthy(v)
+	}
+	if a, ok := number(v); ok {
+		if b, ok := number(t.value); ok {
+			switch t.op {
+			case "==":
+				return a == b
+			case "!=":
+				return a != b
+			case "<":
+				return a < b
+			case "<=":
+				return a <= b
+			case ">":
+				return a > b
+			case ">=":
+				return a >= b
+			}
+		}
+	}
+	switch t.op {
+	case "==":
+		return fmt.Sprint(v) == fmt.Sprint(t.value)
+	case "!=":
+		return fmt.Sprint(v) != fmt.Sprint(t.value)
+	}
+	// Ordering only applies to numbers
+	return false
+}
+
+// number reads v as a number, including numbers held as text.
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func truthy(v any) bool {
+	switch x := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return x
+	case string:
+		return x != ""
+	}
+	if n, ok := number(v); ok {
+		return n != 0
+	}
+	return true
+}
+
+// ErrNoBreakpoint is returned for a breakpoint ID that isn't set.
+var ErrNoBreakpoint = errors.New("no such breakpoint")
+
+// Breakpoints is a set of breakpoints, safe for concurrent use.
+type Breakpoints struct {
+	mu     sync.Mutex
+	list   []*Breakpoint
+	nextID int
+}
+
+// Add sets a breakpoint at agent (every agent when empty) under condition
+// (always when empty).
+func (s *Breakpoints) Add(agent, condition string) (Breakpoint, error) {
+	agent, condition = strings.TrimSpace(agent), strings.TrimSpace(condition)
+	b := &Breakpoint{Agent: agent, Condition: condition}
+	if condition != "" {
+		var err error
+		if b.terms, err = parseCondition(condition); err != nil {
+			return Breakpoint{}, err
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nextID++
+	b.ID = s.nextID
+	s.list = append(s.list, b)
+	return *b, nil
+}
+
+// Parse sets a breakpoint written as "AGENT", "if CONDITION" or
+// "AGENT if CONDITION".
+func (s *Breakpoints) Parse(spec string) (Breakpoint, error) {
+	spec = strings.TrimSpace(spec)
+	agent, condition := spec, ""
+	if rest, ok := strings.CutPrefix(spec, "if "); ok {
+		agent, condition = "", rest
+	} else if a, c, ok := strings.Cut(spec, " if "); ok {
+		agent, condition = a, c
+	}
+	if strings.ContainsAny(agent, " \t") {
+		return Breakpoint{}, fmt.Errorf("can't read breakpoint %q: want AGENT, \"if CONDITION\" or \"AGENT if CONDITION\"", spec)
+	}
+	return s.Add(agent, condition)
+}
+
+// Remove clears breakpoint id.
+func (s *Breakpoints) Remove(id int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, b := range s.list {
+		if b.ID == id {
+			s.list = append(s.list[:i], s.list[i+1:]...)
+			return nil
+		}
+	}
+	return ErrNoBreakpoint
+}
+
+// List returns the breakpoints in the order they were set.
+func (s *Breakpoints) List() []Breakpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]Breakpoint, len(s.list))
+	for i, b := range s.list {
+		out[i] = *b
+	}
+	return out
+}
+
+// Len returns the number of breakpoints set.
+func (s *Breakpoints) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.list)
+}
+
+// Match returns the first breakpoint pausing agent receiving data, counting
+// the hit.
+func (s *Breakpoints) Match(agent string, data core.EventData) (Breakpoint, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, b := range s.list {
+		if b.Matches(agent, data) {
+			b.Hits++
+			return *b, true
+		}
+	}
+	return Breakpoint{}, false
+}
@@ -0,0 +1,104 @@
+package debugger
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+func TestMatches(t *testing.T) {
+	data := core.EventData{
+		"confidence":     0.3,
+		"priority":       "4",
+		"category":       "billing",
+		"classification": map[string]any{"urgent": true, "score": 0},
+		"empty":          "",
+	}
+	tests := []struct {
+		agent, cond string
+		want        bool
+	}{
+		{"", "confidence < 0.5", true},
+		{"", "confidence >= 0.5", false},
+		{"", "state.category == \"billing\" && priority >= 3", true},
+		{"", "category == billing && priority > 4", false},
+		{"", "category != \"refunds\"", true},
+		{"", "classification.urgent == true", true},
+		{"", "classification.urgent", true},
+		{"", "classification.score", false},
+		{"", "empty", false},
+		{"", "missing", false},
+		{"", "missing.key == 1", false},
+		// Ordering only applies to numbers
+		{"", "category < \"c\"", false},
+		{"writer", "", true},
+		{"planner", "", false},
+		{"writer", "confidence < 0.1", false},
+	}
+	for _, tt := range tests {
+		var s Breakpoints
+		b, err := s.Add(tt.agent, tt.cond)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.cond, err)
+		}
+		if got := b.Matches("writer", data); got != tt.want {
+			t.Errorf("breakpoint at %q if %q matches = %v, want %v", tt.agent, tt.cond, got, tt.want)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	var s Breakpoints
+	tests := []struct {
+		spec, agent, cond, str string
+	}{
+		{"writer", "writer", "", "breakpoint 1 at writer"},
+		{"if confidence < 0.5", "", "confidence < 0.5", "breakpoint 2 if confidence < 0.5"},
+		{" writer if priority >= 3 ", "writer", "priority >= 3", "breakpoint 3 at writer if priority >= 3"},
+		{"", "", "", "breakpoint 4 at every agent"},
+	}
+	for _, tt := range tests {
+		b, err := s.Parse(tt.spec)
+		if err != nil {
+			t.Fatalf("%q: %v", tt.spec, err)
+		}
+		if b.Agent != tt.agent || b.Condition != tt.cond || b.String() != tt.str {
+			t.Errorf("%q = %+v (%s)", tt.spec, b, b)
+		}
+	}
+	for _, spec := range []string{"writer planner", "if confidence <", "if 1 == 1"} {
+		if _, err := s.Parse(spec); err == nil {
+			t.Errorf("%q accepted", spec)
+		}
+	}
+	if s.Len() != 4 {
+		t.Errorf("%d breakpoints set", s.Len())
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	var s Breakpoints
+	s.Add("writer", "")
+	s.Add("", "confidence < 0.5")
+	if b, hit := s.Match("planner", core.EventData{"confidence": 0.2}); !hit || b.ID != 2 || b.Hits != 1 {
+		t.Errorf("match = %+v, %v", b, hit)
+	}
+	if _, hit := s.Match("planner", core.EventData{"confidence": 0.9}); hit {
+		t.Error("matched a breakpoint that doesn't apply")
+	}
+	if err := s.Remove(1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Remove(1); !errors.Is(err, ErrNoBreakpoint) {
+		t.Errorf("removing twice err = %v", err)
+	}
+	list := s.List()
+	if len(list) != 1 || list[0].ID != 2 || list[0].Hits != 1 {
+		t.Errorf("breakpoints = %+v", list)
+	}
+	// IDs aren't reused
+	if b, _ := s.Add("formatter", ""); b.ID != 3 {
+		t.Errorf("new breakpoint id = %d", b.ID)
+	}
+}
@@ -0,0 +1,361 @@
+// Package debugger steps through runs like a notebook: the runner pauses
+// before each agent to show the state it is about to receive, and before
+// each provider call to show the rendered prompt, and waits for the
+// developer to continue, edit the state or prompt, or abort the run.
+package debugger
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// ErrAborted fails a run the developer aborted.
+var ErrAborted = errors.New("run aborted in the debugger")
+
+// Debugger pauses runs for commands read one per line from its input.
+type Debugger struct {
+	in  *bufio.Scanner
+	out io.Writer
+
+	// mu serializes pauses; agents calling providers concurrently wait
+	// their turn.
+	mu        sync.Mutex
+	breaks    Breakpoints
+	lastEvent string          // event last paused at
+	skip      map[string]bool // agents whose provider calls don't pause
+	running   bool            // stop pausing
+	aborted   bool            // fail every provider call from now on
+}
+
+// New creates a debugger reading commands from in and writing to out.
+func New(in io.Reader, out io.Writer) *Debugger {
+	return &Debugger{in: bufio.NewScanner(in), out: out, skip: make(map[string]bool)}
+}
+
+// Breakpoints are where the debugger pauses. With none set it pauses before
+// every agent.
+func (d *Debugger) Breakpoints() *Breakpoints {
+	return &d.breaks
+}
+
+// Register pauses before every agent runner runs, or those at breakpoints.
+func (d *Debugger) Register(runner core.Runner) error {
+	return runner.RegisterCallback(core.HookBeforeAgentRun, "debugger", d.beforeAgent)
+}
+
+// Middleware pauses before every provider call with the prompt the agent
+// rendered. It has the shape of di.LLMMiddleware; add it last so the prompt
+// shown is the one the provider receives.
+func (d *Debugger) Middleware() func(agent string, llm core.ModelProvider) core.ModelProvider {
+	return func(agent string, llm core.ModelProvider) core.ModelProvider {
+		return &pausing{ModelProvider: llm, d: d, agent: agent}
+	}
+}
+
+const agentHelp = `  c, Enter         run the agent, pausing at each provider call
+  o                run the agent without pausing at its provider calls
+  r                run to the next breakpoint, or to the end without any
+  p                print the state again
+  set KEY VALUE    set a state key; VALUE is JSON, or else taken as text
+  b AGENT          break before AGENT
+  b [AGENT] if C   break when condition C holds, e.g. b if confidence < 0.5
+  bl               list breakpoints
+  bd ID            delete a breakpoint
+  a                abort the run
+`
+
+func (d *Debugger) beforeAgent(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+	if args.Event == nil {
+		return nil, nil
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	// The runner and its orchestrator both invoke the hook for one agent
+	if d.aborted || args.Event.GetID() == d.lastEvent {
+		return nil, nil
+	}
+	d.lastEvent = args.Event.GetID()
+	event := args.Event
+
+	header := "before " + args.AgentID
+	if d.breaks.Len() > 0 {
+		b, hit := d.breaks.Match(args.AgentID, event.GetData())
+		if !hit {
+			d.skip[args.AgentID] = true
+			return nil, nil
+		}
+		d.running = false
+		header = b.String() + ", before " + args.AgentID
+	} else if d.running {
+		return nil, nil
+	}
+	delete(d.skip, args.AgentID)
+
+	fmt.Fprintf(d.out, "\n■ %s (event %s)\n", header, event.GetID())
+	printState(d.out, event.GetData())
+	for {
+		cmd, rest, err := d.read("debug " + args.AgentID)
+		if err != nil {
+			return nil, err
+		}
+		switch cmd {
+		case "", "c", "continue":
+			return nil, nil
+		case "o", "over":
+			d.skip[args.AgentID] = true
+			return nil, nil
+		case "r", "run":
+			d.running = true
+			return nil, nil
+		case "p", "print":
+			printState(d.out, event.GetData())
+		case "set":
+			key, value, ok := strings.Cut(rest, " ")
+			if !ok || key == "" {
+				fmt.Fprintln(d.out, "  usage: set KEY VALUE")
+				continue
+			}
+			// Agents read the event's data, merged over their state
+			event.SetData(key, parseValue(value))
+			fmt.Fprintf(d.out, "  %s = %s\n", key, show(event.GetData()[key]))
+		case "b", "break":
+			if b, err := d.breaks.Parse(rest); err != nil {
+				fmt.Fprintf(d.out, "  %v\n", err)
+			} else {
+				fmt.Fprintf(d.out, "  %s\n", b)
+			}
+		case "bl":
+			printBreakpoints(d.out, d.breaks.List())
+		case "bd":
+			var id int
+			if _, err := fmt.Sscan(rest, &id); err != nil {
+				fmt.Fprintln(d.out, "  usage: bd ID")
+			} else if err := d.breaks.Remove(id); err != nil {
+				fmt.Fprintf(d.out, "  %v\n", err)
+			}
+		case "a", "abort":
+			return nil, d.abort()
+		case "h", "help", "?":
+			fmt.Fprint(d.out, agentHelp)
+		default:
+			fmt.Fprintf(d.out, "  unknown command %q; h for help\n", cmd)
+		}
+	}
+}
+
+const promptHelp = `  c, Enter         send the prompt
+  o                send this and the agent's other prompts without pausing
+  r                run to the next breakpoint, or to the end without any
+  p                print the prompt again
+  edit system|user replace that part of the prompt with the lines up to "."
+  a                abort the run
+`
+
+// pause shows the prompt agent is about to send and returns it as the
+// developer left it.
+func (d *Debugger) pause(agent string, prompt core.Prompt) (core.Prompt, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.aborted {
+		// Agents falling back to another call stay aborted
+		return prompt, ErrAborted
+	}
+	if d.running || d.skip[agent] {
+		return prompt, nil
+	}
+	fmt.Fprintf(d.out, "\n■ %s calls its provider\n", agent)
+	printPrompt(d.out, prompt)
+	for {
+		cmd, rest, err := d.read("prompt " + agent)
+		if err != nil {
+			return prompt, err
+		}
+		switch cmd {
+		case "", "c", "continue":
+			return prompt, nil
+		case "o", "over":
+			d.skip[agent] = true
+			return prompt, nil
+		case "r", "run":
+			d.running = true
+			return prompt, nil
+		case "p", "print":
+			printPrompt(d.out, prompt)
+		case "edit":
+			if rest != "system" && rest != "user" {
+				fmt.Fprintln(d.out, "  usage: edit system|user")
+				continue
+			}
+			fmt.Fprintf(d.out, "  enter the %s prompt, ending with a line holding only \".\"\n", rest)
+			text, err := d.readBlock()
+			if err != nil {
+				return prompt, err
+			}
+			if rest == "system" {
+				prompt.System = text
+			} else {
+				prompt.User = text
+			}
+		case "a", "abort":
+			return prompt, d.abort()
+		case "h", "help", "?":
+			fmt.Fprint(d.out, promptHelp)
+		default:
+			fmt.Fprintf(d.out, "  unknown command %q; h for help\n", cmd)
+		}
+	}
+}
+
+// abort stops pausing, so whatever handles the failure runs through.
+func (d *Debugger) abort() error {
+	d.running, d.aborted = true, true
+	fmt.Fprintln(d.out, "  aborted")
+	return ErrAborted
+}
+
+func (d *Debugger) isAborted() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.aborted
+}
+
+// read prompts for a command and splits off its argument. Input ending
+// aborts the run.
+func (d *Debugger) read(label string) (cmd, rest string, err error) {
+	fmt.Fprintf(d.out, "(%s) ", label)
+	if !d.in.Scan() {
+		fmt.Fprintln(d.out)
+		d.running, d.aborted = true, true
+		if err := d.in.Err(); err != nil {
+			return "", "", err
+		}
+		return "", "", ErrAborted
+	}
+	cmd, rest, _ = strings.Cut(strings.TrimSpace(d.in.Text()), " ")
+	return cmd, strings.TrimSpace(rest), nil
+}
+
+func (d *Debugger) readBlock() (string, error) {
+	var lines []string
+	for d.in.Scan() {
+		if line := d.in.Text(); line != "." {
+			lines = append(lines, line)
+			continue
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+	d.running, d.aborted = true, true
+	if err := d.in.Err(); err != nil {
+		return "", err
+	}
+	return "", ErrAborted
+}
+
+// parseValue reads a state value typed at the prompt.
+func parseValue(s string) any {
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err == nil {
+		return v
+	}
+	return s
+}
+
+func printState(w io.Writer, data core.EventData) {
+	if len(data) == 0 {
+		fmt.Fprintln(w, "  (empty state)")
+		return
+	}
+	keys := make([]string, 0, len(data))
+	for k := range data {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "  %s = %s\n", k, show(data[k]))
+	}
+}
+
+// show formats a state value; multi-line text is indented below its key.
+func show(v any) string {
+	if s, ok := v.(string); ok && strings.Contains(s, "\n") {
+		return "\n" + indent(s, "      ")
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
+
+func printBreakpoints(w io.Writer, list []Breakpoint) {
+	if len(list) == 0 {
+		fmt.Fprintln(w, "  no breakpoints; pausing before every agent")
+	}
+	for _, b := range list {
+		fmt.Fprintf(w, "  %s (hit %d times)\n", b, b.Hits)
+	}
+}
+
+func printPrompt(w io.Writer, prompt core.Prompt) {
+	fmt.Fprintln(w, "  ── system ──")
+	fmt.Fprintln(w, indent(prompt.System, "  "))
+	fmt.Fprintln(w, "  ── user ──")
+	fmt.Fprintln(w, indent(prompt.User, "  "))
+}
+
+func indent(s, prefix string) string {
+	return prefix + strings.ReplaceAll(s, "\n", "\n"+prefix)
+}
+
+type pausing struct {
+	core.ModelProvider
+	d     *Debugger
+	agent string
+
+	mu sync.Mutex
+	// fallback is the last prompt a provider failed to stream, as it
+	// arrived and as it was sent. Callers retry it with Call, which goes
+	// out as it was left without pausing again.
+	fallback *[2]core.Prompt
+}
+
+// Unwrap returns the provider wrapped, for the LLM cache to see through.
+func (p *pausing) Unwrap() core.ModelProvider { return p.ModelProvider }
+
+func (p *pausing) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	p.mu.Lock()
+	retry := p.fallback
+	p.fallback = nil
+	p.mu.Unlock()
+	if retry != nil && retry[0] == prompt && !p.d.isAborted() {
+		return p.ModelProvider.Call(ctx, retry[1])
+	}
+	prompt, err := p.d.pause(p.agent, prompt)
+	if err != nil {
+		return core.Response{}, err
+	}
+	return p.ModelProvider.Call(ctx, prompt)
+}
+
+func (p *pausing) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	sent, err := p.d.pause(p.agent, prompt)
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := p.ModelProvider.Stream(ctx, sent)
+	if err != nil {
+		p.mu.Lock()
+		p.fallback = &[2]core.Prompt{prompt, sent}
+		p.mu.Unlock()
+	}
+	return tokens, err
+}
@@ -0,0 +1,251 @@
+package debugger
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+type runner struct {
+	core.Runner
+	callbacks map[core.HookPoint]core.CallbackFunc
+
+	mu      sync.Mutex
+	emitted []core.Event
+}
+
+func newRunner() *runner {
+	return &runner{callbacks: make(map[core.HookPoint]core.CallbackFunc)}
+}
+
+func (r *runner) RegisterCallback(hook core.HookPoint, name string, cb core.CallbackFunc) error {
+	r.callbacks[hook] = cb
+	return nil
+}
+
+func (r *runner) Emit(event core.Event) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.emitted = append(r.emitted, event)
+	return nil
+}
+
+func (r *runner) Emitted() []core.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]core.Event(nil), r.emitted...)
+}
+
+// model records the prompts it receives; its streams fail when streamErr
+// is set.
+type model struct {
+	core.ModelProvider
+	prompts   []core.Prompt
+	streamErr error
+}
+
+func (m *model) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	m.prompts = append(m.prompts, prompt)
+	return core.Response{Content: "ok"}, nil
+}
+
+func (m *model) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	if m.streamErr != nil {
+		return nil, m.streamErr
+	}
+	m.prompts = append(m.prompts, prompt)
+	out := make(chan core.Token)
+	close(out)
+	return out, nil
+}
+
+// debug registers a debugger reading commands from input.
+func debug(t *testing.T, input string) (*Debugger, *strings.Builder, core.CallbackFunc) {
+	t.Helper()
+	var out strings.Builder
+	d := New(strings.NewReader(input), &out)
+	r := newRunner()
+	if err := d.Register(r); err != nil {
+		t.Fatal(err)
+	}
+	return d, &out, r.callbacks[core.HookBeforeAgentRun]
+}
+
+func TestBeforeAgent(t *testing.T) {
+	_, out, before := debug(t, "p\nset topic \"go\"\nset note plain text\nset\nbogus\nh\nc\n")
+	event := core.NewEvent("writer", core.EventData{"draft": "line one\nline two"}, nil)
+	if _, err := before(context.Background(), core.CallbackArgs{AgentID: "writer", Event: event}); err != nil {
+		t.Fatal(err)
+	}
+	if data := event.GetData(); data["topic"] != "go" || data["note"] != "plain text" {
+		t.Errorf("state = %v", data)
+	}
+	for _, s := range []string{"■ before writer", "  draft = \n      line one\n      line two", "usage: set KEY VALUE", `unknown command "bogus"`, "print the state again"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("output lacks %q:\n%s", s, out)
+		}
+	}
+
+	// The orchestrator invokes the hook again for the same agent
+	if _, err := before(context.Background(), core.CallbackArgs{AgentID: "writer", Event: event}); err != nil {
+		t.Errorf("second hook for the event: %v", err)
+	}
+	if strings.Count(out.String(), "■ before writer") != 1 {
+		t.Errorf("paused twice for one event:\n%s", out)
+	}
+}
+
+func TestRun(t *testing.T) {
+	d, _, before := debug(t, "r\n")
+	ctx := context.Background()
+	for _, agent := range []string{"planner", "writer"} {
+		if _, err := before(ctx, core.CallbackArgs{AgentID: agent, Event: core.NewEvent(agent, nil, nil)}); err != nil {
+			t.Errorf("%s: %v", agent, err)
+		}
+	}
+	llm := &model{}
+	if _, err := d.Middleware()("writer", llm).Call(ctx, core.Prompt{User: "hi"}); err != nil || len(llm.prompts) != 1 {
+		t.Errorf("provider call err = %v, sent %d", err, len(llm.prompts))
+	}
+}
+
+func TestAbort(t *testing.T) {
+	d, _, before := debug(t, "a\n")
+	ctx := context.Background()
+	if _, err := before(ctx, core.CallbackArgs{AgentID: "writer", Event: core.NewEvent("writer", nil, nil)}); !errors.Is(err, ErrAborted) {
+		t.Fatalf("abort err = %v", err)
+	}
+	// Fallback calls stay aborted
+	llm := &model{}
+	if _, err := d.Middleware()("writer", llm).Call(ctx, core.Prompt{User: "hi"}); !errors.Is(err, ErrAborted) || len(llm.prompts) != 0 {
+		t.Errorf("provider call after abort err = %v, sent %d", err, len(llm.prompts))
+	}
+}
+
+func TestInputEnds(t *testing.T) {
+	_, _, before := debug(t, "")
+	if _, err := before(context.Background(), core.CallbackArgs{AgentID: "writer", Event: core.NewEvent("writer", nil, nil)}); !errors.Is(err, ErrAborted) {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	d, out, _ := debug(t, "edit user\nFirst line\nSecond line\n.\nedit nothing\np\nc\n")
+	llm := &model{}
+	if _, err := d.Middleware()("writer", llm).Call(context.Background(), core.Prompt{System: "Be brief", User: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(llm.prompts) != 1 || llm.prompts[0] != (core.Prompt{System: "Be brief", User: "First line\nSecond line"}) {
+		t.Errorf("sent %+v", llm.prompts)
+	}
+	if !strings.Contains(out.String(), "usage: edit system|user") || !strings.Contains(out.String(), "  ── user ──\n  First line\n  Second line") {
+		t.Errorf("output:\n%s", out)
+	}
+}
+
+func TestOver(t *testing.T) {
+	d, _, before := debug(t, "o\n")
+	ctx := context.Background()
+	if _, err := before(ctx, core.CallbackArgs{AgentID: "writer", Event: core.NewEvent("writer", nil, nil)}); err != nil {
+		t.Fatal(err)
+	}
+	// The agent's calls don't pause, though no input is left to answer one
+	llm := &model{}
+	wrapped := d.Middleware()("writer", llm)
+	for range 2 {
+		if _, err := wrapped.Call(ctx, core.Prompt{User: "hi"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(llm.prompts) != 2 {
+		t.Errorf("sent %d prompts", len(llm.prompts))
+	}
+}
+
+func TestStreamFallback(t *testing.T) {
+	d, out, _ := debug(t, "edit system\nEdited\n.\nc\n")
+	llm := &model{streamErr: errors.New("streaming unsupported")}
+	wrapped := d.Middleware()("writer", llm)
+	ctx := context.Background()
+	prompt := core.Prompt{System: "Original", User: "hi"}
+	if _, err := wrapped.Stream(ctx, prompt); err == nil {
+		t.Fatal("stream succeeded")
+	}
+	// The agent retries with Call: the edited prompt goes out without a
+	// second pause
+	if _, err := wrapped.Call(ctx, prompt); err != nil {
+		t.Fatal(err)
+	}
+	if len(llm.prompts) != 1 || llm.prompts[0].System != "Edited" {
+		t.Errorf("sent %+v", llm.prompts)
+	}
+	if n := strings.Count(out.String(), "calls its provider"); n != 1 {
+		t.Errorf("paused %d times", n)
+	}
+}
+
+func TestBreakpointCommands(t *testing.T) {
+	d, out, before := debug(t, "b writer if confidence < 0.5\nb planner planner\nbl\nbd x\nbd 7\nr\n")
+	ctx := context.Background()
+	if _, err := before(ctx, core.CallbackArgs{AgentID: "planner", Event: core.NewEvent("planner", nil, nil)}); err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"  breakpoint 1 at writer if confidence < 0.5\n", "can't read breakpoint", "breakpoint 1 at writer if confidence < 0.5 (hit 0 times)", "usage: bd ID", ErrNoBreakpoint.Error()} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("output lacks %q:\n%s", s, out)
+		}
+	}
+
+	// Running on, only the breakpoint pauses; agents passing it don't
+	// pause at their provider calls either
+	llm := &model{}
+	for _, confidence := range []float64{0.9, 0.2} {
+		event := core.NewEvent("writer", core.EventData{"confidence": confidence}, nil)
+		_, err := before(ctx, core.CallbackArgs{AgentID: "writer", Event: event})
+		if confidence > 0.5 {
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := d.Middleware()("writer", llm).Call(ctx, core.Prompt{User: "hi"}); err != nil {
+				t.Fatal(err)
+			}
+		} else if !errors.Is(err, ErrAborted) {
+			// Input has ended by the time the breakpoint is hit
+			t.Errorf("breakpoint hit err = %v", err)
+		}
+	}
+	if len(llm.prompts) != 1 || !strings.Contains(out.String(), "■ breakpoint 1 at writer if confidence < 0.5, before writer") {
+		t.Errorf("sent %d prompts; output:\n%s", len(llm.prompts), out)
+	}
+}
+
+func TestParseValue(t *testing.T) {
+	tests := []struct {
+		in   string
+		want any
+	}{
+		{`0.5`, 0.5},
+		{`true`, true},
+		{`"quoted"`, "quoted"},
+		{`plain text`, "plain text"},
+	}
+	for _, tt := range tests {
+		if got := parseValue(tt.in); got != tt.want {
+			t.Errorf("parseValue(%q) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestPausingUnwraps(t *testing.T) {
+	d := New(strings.NewReader(""), io.Discard)
+	llm := &model{}
+	u, ok := d.Middleware()("writer", llm).(interface{ Unwrap() core.ModelProvider })
+	if !ok || u.Unwrap() != core.ModelProvider(llm) {
+		t.Error("pausing provider does not unwrap to the one it wraps")
+	}
+}
@@ -0,0 +1,294 @@
+package debugger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+// DefaultPauseTimeout is how long a run waits at a breakpoint on a live
+// system before continuing on its own.
+const DefaultPauseTimeout = 10 * time.Minute
+
+// ErrNotPaused is returned for an event that isn't paused at a breakpoint.
+var ErrNotPaused = errors.New("event is not paused")
+
+// errParked makes the runner skip an event paused at a breakpoint; it is
+// emitted again when continued.
+var errParked = errors.New("event paused at a breakpoint")
+
+// Remote pauses a live system's runs at breakpoints set through the admin
+// API, where they are inspected, edited and continued or aborted. A paused
+// run's event is set aside, so the runner goes on with other runs'
+// events meanwhile, and is emitted again when the run continues, which it
+// does on its own after a timeout.
+type Remote struct {
+	breaks  Breakpoints
+	timeout time.Duration
+
+	mu       sync.Mutex
+	runner   core.Runner       // continued events are emitted to
+	paused   map[string]*Pause // by event ID
+	released map[string]bool   // events continued past their breakpoint
+	aborted  map[string]bool   // events whose agent fails instead of running
+}
+
+// Pause is a run stopped at a breakpoint.
+type Pause struct {
+	EventID    string         `json:"event_id"`
+	RunID      string         `json:"run_id,omitempty"`
+	Agent      string         `json:"agent"`
+	Breakpoint Breakpoint     `json:"breakpoint"`
+	State      map[string]any `json:"state"`
+	PausedAt   time.Time      `json:"paused_at"`
+	Until      time.Time      `json:"until"` // continues on its own
+
+	event core.Event
+	timer *time.Timer
+}
+
+type resume struct {
+	set   map[string]any
+	abort bool
+}
+
+// NewRemote creates a remote debugger; timeout <= 0 means
+// DefaultPauseTimeout.
+func NewRemote(timeout time.Duration) *Remote {
+	if timeout <= 0 {
+		timeout = DefaultPauseTimeout
+	}
+	return &Remote{timeout: timeout, paused: make(map[string]*Pause), released: make(map[string]bool), aborted: make(map[string]bool)}
+}
+
+// Breakpoints are where runs pause. With none set, nothing pauses.
+func (r *Remote) Breakpoints() *Breakpoints {
+	return &r.breaks
+}
+
+// Register checks the breakpoints before the runner handles each event,
+// and emits continued events to runner again.
+func (r *Remote) Register(runner core.Runner) error {
+	r.mu.Lock()
+	r.runner = runner
+	r.mu.Unlock()
+	if err := runner.RegisterCallback(core.HookBeforeEventHandling, "remote-debugger", r.park); err != nil {
+		return err
+	}
+	return runner.RegisterCallback(core.HookBeforeAgentRun, "remote-debugger", r.beforeAgent)
+}
+
+// park sets an event at a breakpoint aside. The runner skips an event any
+// of its before-event callbacks fails, so the others have seen it and see
+// it again, as the same event, when it continues.
+func (r *Remote) park(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+	event := args.Event
+	if event == nil {
+		return args.State, nil
+	}
+	r.mu.Lock()
+	released := r.released[event.GetID()]
+	delete(r.released, event.GetID())
+	r.mu.Unlock()
+	if released || r.breaks.Len() == 0 {
+		return args.State, nil
+	}
+	// The runner dispatches by route, else by target
+	agent, _ := event.GetMetadataValue(core.RouteMetadataKey)
+	if agent == "" {
+		agent = event.GetTargetAgentID()
+	}
+	data := event.GetData()
+	b, hit := r.breaks.Match(agent, data)
+	if !hit {
+		return args.State, nil
+	}
+	now := time.Now()
+	p := &Pause{
+		EventID:    event.GetID(),
+		Agent:      agent,
+		Breakpoint: b,
+		State:      data,
+		PausedAt:   now,
+		Until:      now.Add(r.timeout),
+		event:      event,
+	}
+	p.RunID, _ = event.GetMetadataValue(history.RunIDKey)
+	r.mu.Lock()
+	r.paused[p.EventID] = p
+	p.timer = time.AfterFunc(r.timeout, func() {
+		if err := r.release(p.EventID, resume{}); err == nil {
+			core.Logger().Warn().Str("event_id", p.EventID).Dur("timeout", r.timeout).Msg("Breakpoint timed out; continuing")
+		}
+	})
+	r.mu.Unlock()
+	core.Logger().Info().Str("event_id", p.EventID).Str("agent", p.Agent).Int("breakpoint", b.ID).Msg("Paused at breakpoint")
+	return args.State, errParked
+}
+
+// beforeAgent fails the agent of an event aborted at its breakpoint.
+func (r *Remote) beforeAgent(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+	if args.Event == nil {
+		return nil, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.aborted[args.Event.GetID()] {
+		delete(r.aborted, args.Event.GetID())
+		return nil, ErrAborted
+	}
+	return nil, nil
+}
+
+// Paused returns the runs stopped at breakpoints, oldest first.
+func (r *Remote) Paused() []Pause {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	out := make([]Pause, 0, len(r.paused))
+	for _, p := range r.paused {
+		out = append(out, *p)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].PausedAt.Before(out[j].PausedAt) })
+	return out
+}
+
+// Continue lets the run paused at eventID go on, with set applied to the
+// state its agent receives.
+func (r *Remote) Continue(eventID string, set map[string]any) error {
+	return r.release(eventID, resume{set: set})
+}
+
+// Abort fails the run paused at eventID.
+func (r *Remote) Abort(eventID string) error {
+	return r.release(eventID, resume{abort: true})
+}
+
+// release emits a paused event again, past its breakpoint.
+func (r *Remote) release(eventID string, res resume) error {
+	r.mu.Lock()
+	p, ok := r.paused[eventID]
+	if !ok {
+		r.mu.Unlock()
+		return ErrNotPaused
+	}
+	delete(r.paused, eventID)
+	p.timer.Stop()
+	r.released[eventID] = true
+	if res.abort {
+		r.aborted[eventID] = true
+	}
+	runner := r.runner
+	r.mu.Unlock()
+	// Agents read the event's data, merged over their state
+	for k, v := range res.set {
+		p.event.SetData(k, v)
+	}
+	if err := runner.Emit(p.event); err != nil {
+		r.mu.Lock()
+		delete(r.released, eventID)
+		delete(r.aborted, eventID)
+		r.mu.Unlock()
+		return fmt.Errorf("failed to continue event %s: %w", eventID, err)
+	}
+	return nil
+}
+
+// Handler serves the breakpoint endpoints, for mounting on the admin API
+// under "/admin/breakpoints" and "/admin/breakpoints/":
+//
+//	GET    /admin/breakpoints                           breakpoints and paused runs
+//	POST   /admin/breakpoints                           {"agent": "...", "condition": "confidence < 0.5"}
+//	DELETE /admin/breakpoints/{id}
+//	POST   /admin/breakpoints/paused/{event}/continue   {"set": {"key": value}}, optional
+//	POST   /admin/breakpoints/paused/{event}/abort
+func (r *Remote) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/breakpoints", func(w http.ResponseWriter, req *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"breakpoints": r.breaks.List(), "paused": r.Paused()})
+	})
+	mux.HandleFunc("POST /admin/breakpoints", func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			Agent     string `json:"agent"`
+			Condition string `json:"condition"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if body.Agent == "" && body.Condition == "" {
+			writeError(w, http.StatusBadRequest, errors.New("a breakpoint needs an agent, a condition or both"))
+			return
+		}
+		b, err := r.breaks.Add(body.Agent, body.Condition)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, b)
+	})
+	mux.HandleFunc("DELETE /admin/breakpoints/{id}", func(w http.ResponseWriter, req *http.Request) {
+		id, err := strconv.Atoi(req.PathValue("id"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := r.breaks.Remove(id); err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("POST /admin/breakpoints/paused/{event}/continue", func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			Set map[string]any `json:"set"`
+		}
+		if req.ContentLength != 0 {
+			if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+		}
+		if err := r.Continue(req.PathValue("event"), body.Set); err != nil {
+			writeReleaseError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "continued"})
+	})
+	mux.HandleFunc("POST /admin/breakpoints/paused/{event}/abort", func(w http.ResponseWriter, req *http.Request) {
+		if err := r.Abort(req.PathValue("event")); err != nil {
+			writeReleaseError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "aborted"})
+	})
+	return mux
+}
+
+// writeReleaseError writes the error of continuing or aborting a pause.
+func writeReleaseError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, ErrNotPaused) {
+		status = http.StatusNotFound
+	}
+	writeError(w, status, err)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
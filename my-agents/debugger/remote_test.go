@@ -0,0 +1,166 @@
+package debugger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+func remote(t *testing.T, timeout time.Duration) (*Remote, *runner) {
+	t.Helper()
+	r := NewRemote(timeout)
+	run := newRunner()
+	if err := r.Register(run); err != nil {
+		t.Fatal(err)
+	}
+	return r, run
+}
+
+func TestRemoteContinue(t *testing.T) {
+	r, run := remote(t, time.Minute)
+	park := run.callbacks[core.HookBeforeEventHandling]
+	ctx := context.Background()
+
+	// Without breakpoints nothing pauses
+	event := core.NewEvent("writer", core.EventData{"confidence": 0.2}, map[string]string{history.RunIDKey: "run-1"})
+	if _, err := park(ctx, core.CallbackArgs{Event: event}); err != nil {
+		t.Fatalf("no breakpoints: %v", err)
+	}
+
+	r.Breakpoints().Add("writer", "confidence < 0.5")
+	if _, err := park(ctx, core.CallbackArgs{Event: core.NewEvent("writer", core.EventData{"confidence": 0.9}, nil)}); err != nil {
+		t.Errorf("event past the breakpoint: %v", err)
+	}
+	if _, err := park(ctx, core.CallbackArgs{Event: event}); err == nil {
+		t.Fatal("event at the breakpoint was not set aside")
+	}
+	paused := r.Paused()
+	if len(paused) != 1 || paused[0].EventID != event.GetID() || paused[0].RunID != "run-1" || paused[0].Agent != "writer" || paused[0].Breakpoint.ID != 1 {
+		t.Fatalf("paused = %+v", paused)
+	}
+
+	if err := r.Continue(event.GetID(), map[string]any{"confidence": 0.95}); err != nil {
+		t.Fatal(err)
+	}
+	if emitted := run.Emitted(); len(emitted) != 1 || emitted[0].GetData()["confidence"] != 0.95 {
+		t.Fatalf("emitted %+v", emitted)
+	}
+	// The continued event passes its breakpoint once
+	if _, err := park(ctx, core.CallbackArgs{Event: event}); err != nil {
+		t.Errorf("continued event: %v", err)
+	}
+	if _, err := run.callbacks[core.HookBeforeAgentRun](ctx, core.CallbackArgs{AgentID: "writer", Event: event}); err != nil {
+		t.Errorf("continued agent: %v", err)
+	}
+	if err := r.Continue(event.GetID(), nil); !errors.Is(err, ErrNotPaused) {
+		t.Errorf("continuing twice err = %v", err)
+	}
+}
+
+func TestRemoteRoute(t *testing.T) {
+	r, run := remote(t, time.Minute)
+	r.Breakpoints().Add("planner", "")
+	// The route, not the target, names the agent that handles the event
+	event := core.NewEvent("writer", nil, map[string]string{core.RouteMetadataKey: "planner"})
+	if _, err := run.callbacks[core.HookBeforeEventHandling](context.Background(), core.CallbackArgs{Event: event}); err == nil {
+		t.Error("routed event was not set aside")
+	}
+}
+
+func TestRemoteAbort(t *testing.T) {
+	r, run := remote(t, time.Minute)
+	ctx := context.Background()
+	r.Breakpoints().Add("writer", "")
+	event := core.NewEvent("writer", nil, nil)
+	run.callbacks[core.HookBeforeEventHandling](ctx, core.CallbackArgs{Event: event})
+	if err := r.Abort(event.GetID()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := run.callbacks[core.HookBeforeEventHandling](ctx, core.CallbackArgs{Event: event}); err != nil {
+		t.Errorf("aborted event was set aside again: %v", err)
+	}
+	before := run.callbacks[core.HookBeforeAgentRun]
+	if _, err := before(ctx, core.CallbackArgs{AgentID: "writer", Event: event}); !errors.Is(err, ErrAborted) {
+		t.Errorf("aborted agent err = %v", err)
+	}
+	if _, err := before(ctx, core.CallbackArgs{AgentID: "writer", Event: event}); err != nil {
+		t.Errorf("agent failed twice: %v", err)
+	}
+}
+
+func TestRemoteTimeout(t *testing.T) {
+	r, run := remote(t, 10*time.Millisecond)
+	r.Breakpoints().Add("writer", "")
+	run.callbacks[core.HookBeforeEventHandling](context.Background(), core.CallbackArgs{Event: core.NewEvent("writer", nil, nil)})
+	deadline := time.Now().Add(time.Second)
+	for len(run.Emitted()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("paused event did not continue on its own")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(r.Paused()) != 0 {
+		t.Errorf("paused = %+v", r.Paused())
+	}
+}
+
+func TestRemoteHandler(t *testing.T) {
+	r, run := remote(t, time.Minute)
+	h := r.Handler()
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	if w := do("POST", "/admin/breakpoints", `{"agent": "writer", "condition": "confidence < 0.5"}`); w.Code != http.StatusCreated {
+		t.Fatalf("add: %d %s", w.Code, w.Body)
+	}
+	for _, body := range []string{`{}`, `{"condition": "confidence <"}`, `not json`} {
+		if w := do("POST", "/admin/breakpoints", body); w.Code != http.StatusBadRequest {
+			t.Errorf("add %s: status %d", body, w.Code)
+		}
+	}
+
+	event := core.NewEvent("writer", core.EventData{"confidence": 0.2}, nil)
+	run.callbacks[core.HookBeforeEventHandling](context.Background(), core.CallbackArgs{Event: event})
+	var status struct {
+		Breakpoints []Breakpoint `json:"breakpoints"`
+		Paused      []Pause      `json:"paused"`
+	}
+	json.NewDecoder(do("GET", "/admin/breakpoints", "").Body).Decode(&status)
+	if len(status.Breakpoints) != 1 || status.Breakpoints[0].Hits != 1 || len(status.Paused) != 1 || status.Paused[0].State["confidence"] != 0.2 {
+		t.Errorf("status = %+v", status)
+	}
+
+	if w := do("POST", "/admin/breakpoints/paused/"+event.GetID()+"/continue", `{"set": {"confidence": 0.9}}`); w.Code != http.StatusOK {
+		t.Errorf("continue: %d %s", w.Code, w.Body)
+	}
+	if emitted := run.Emitted(); len(emitted) != 1 || emitted[0].GetData()["confidence"] != 0.9 {
+		t.Errorf("emitted %+v", emitted)
+	}
+	for _, action := range []string{"continue", "abort"} {
+		if w := do("POST", "/admin/breakpoints/paused/"+event.GetID()+"/"+action, ""); w.Code != http.StatusNotFound {
+			t.Errorf("%s an event not paused: status %d", action, w.Code)
+		}
+	}
+
+	if w := do("DELETE", "/admin/breakpoints/1", ""); w.Code != http.StatusNoContent {
+		t.Errorf("delete: status %d", w.Code)
+	}
+	if w := do("DELETE", "/admin/breakpoints/1", ""); w.Code != http.StatusNotFound {
+		t.Errorf("delete twice: status %d", w.Code)
+	}
+	if w := do("DELETE", "/admin/breakpoints/one", ""); w.Code != http.StatusBadRequest {
+		t.Errorf("delete by name: status %d", w.Code)
+	}
+}
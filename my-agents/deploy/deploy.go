@@ -0,0 +1,329 @@
+// Package deploy runs new versions of a workflow next to the live one
+// (blue/green): a candidate version only serves test traffic until it is
+// promoted, and the version it replaces is kept for instant rollback.
+//
+// A workflow is named by the route its runs start at. A version redefines
+// some of the agents the workflow passes through, in the [agents.<name>]
+// form of agentflow.toml; its agents are registered as "<name>@<version>"
+// and events of runs it serves are routed to them instead of the live ones.
+package deploy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/appconfig"
+	"my-agents/di"
+)
+
+// VersionKey is the metadata key naming the version serving a run.
+// Operators set it to "candidate" or a version ID to send test traffic, on
+// reruns or events from outside the HTTP API, which reserves it; the manager
+// replaces it with "<workflow>@<version>" so every hop stays on one version.
+const VersionKey = "deployment"
+
+// Base is the version defined by agentflow.toml itself.
+const Base = "base"
+
+// Candidate selects a workflow's candidate version in VersionKey.
+const Candidate = "candidate"
+
+// DefaultPath is where deployments are kept so they survive restarts.
+const DefaultPath = ".agentflow/deployments.json"
+
+// Definition is an uploaded workflow version:
+//
+//	[agents.processor]
+//	system_prompt = "Answer in two sentences."
+//	[agents.enhancer]
+//	provider = "openai"
+type Definition struct {
+	Agents map[string]appconfig.AgentConfig `toml:"agents"`
+}
+
+// ParseDefinition decodes a version from TOML.
+func ParseDefinition(data []byte) (Definition, error) {
+	var def Definition
+	if err := toml.Unmarshal(data, &def); err != nil {
+		return Definition{}, fmt.Errorf("failed to parse workflow definition: %w", err)
+	}
+	if len(def.Agents) == 0 {
+		return Definition{}, errors.New("workflow definition declares no [agents.<name>] tables")
+	}
+	return def, nil
+}
+
+// Version is one deployed definition of a workflow.
+type Version struct {
+	ID         string    `json:"id"`
+	Agents     []string  `json:"agents"`     // agents the version replaces
+	Definition string    `json:"definition"` // as uploaded
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Workflow is the deployment state of one workflow.
+type Workflow struct {
+	Name      string              `json:"name"`
+	Active    string              `json:"active"`              // version serving live traffic
+	Candidate string              `json:"candidate,omitempty"` // version serving test traffic
+	Previous  string              `json:"previous,omitempty"`  // version Rollback returns to
+	Versions  map[string]*Version `json:"versions"`
+	Seq       int                 `json:"seq"`
+	UpdatedAt time.Time           `json:"updated_at"`
+}
+
+// serves reports whether version replaces agent.
+func (w *Workflow) serves(version, agent string) bool {
+	v, ok := w.Versions[version]
+	if !ok {
+		return false
+	}
+	for _, name := range v.Agents {
+		if name == agent {
+			return true
+		}
+	}
+	return false
+}
+
+// snapshot copies w for use outside the manager's lock.
+func (w *Workflow) snapshot() Workflow {
+	c := *w
+	c.Versions = make(map[string]*Version, len(w.Versions))
+	for id, v := range w.Versions {
+		c.Versions[id] = v // versions are never modified once deployed
+	}
+	return c
+}
+
+// Manager deploys workflow versions onto a runner and routes runs to them.
+type Manager struct {
+	path      string
+	container *di.Container
+	runner    core.Runner
+
+	mu        sync.Mutex
+	workflows map[string]*Workflow
+}
+
+// New creates a manager persisting to path (DefaultPath when empty) and
+// registers the agents of versions deployed before a restart.
+func New(path string, container *di.Container, runner core.Runner) (*Manager, error) {
+	if path == "" {
+		path = DefaultPath
+	}
+	m := &Manager{path: path, container: container, runner: runner, workflows: make(map[string]*Workflow)}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read deployments: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &m.workflows); err != nil {
+			return nil, fmt.Errorf("failed to parse deployments %s: %w", path, err)
+		}
+	}
+	for name, wf := range m.workflows {
+		for id, v := range wf.Versions {
+			def, err := ParseDefinition([]byte(v.Definition))
+			if err != nil {
+				return nil, fmt.Errorf("workflow %s version %s: %w", name, id, err)
+			}
+			if err := m.install(id, def); err != nil {
+				return nil, fmt.Errorf("workflow %s version %s: %w", name, id, err)
+			}
+		}
+	}
+	return m, nil
+}
+
+// Register adds the routing callback to runner. Register it after callbacks
+// that rewrite routes, such as language routing.
+func (m *Manager) Register(runner core.Runner) error {
+	return runner.RegisterCallback(core.HookBeforeEventHandling, "blue-green", m.route)
+}
+
+// Deploy builds def as a new version of workflow and makes it the
+// candidate, replacing any earlier candidate. Live traffic is unaffected.
+func (m *Manager) Deploy(workflow string, definition []byte) (*Version, error) {
+	if workflow == "" {
+		return nil, errors.New("workflow name is required")
+	}
+	def, err := ParseDefinition(definition)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wf := m.workflows[workflow]
+	if wf == nil {
+		wf = &Workflow{Name: workflow, Active: Base, Versions: make(map[string]*Version)}
+	}
+	id := "v" + strconv.Itoa(wf.Seq+1)
+	if err := m.install(id, def); err != nil {
+		return nil, err
+	}
+	v := &Version{ID: id, Definition: string(definition), CreatedAt: time.Now()}
+	for name := range def.Agents {
+		v.Agents = append(v.Agents, name)
+	}
+	sort.Strings(v.Agents)
+
+	wf.Seq++
+	wf.Versions[id] = v
+	wf.Candidate = id
+	wf.UpdatedAt = time.Now()
+	m.workflows[workflow] = wf
+	if err := m.save(); err != nil {
+		return nil, err
+	}
+	core.Logger().Info().Str("workflow", workflow).Str("version", id).Strs("agents", v.Agents).Msg("Workflow version deployed as candidate")
+	return v, nil
+}
+
+// Promote switches live traffic to the candidate in one step. Runs already
+// started finish on the version they started on.
+func (m *Manager) Promote(workflow string) (*Workflow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wf := m.workflows[workflow]
+	if wf == nil || wf.Candidate == "" {
+		return nil, fmt.Errorf("workflow %q has no candidate to promote", workflow)
+	}
+	wf.Previous, wf.Active, wf.Candidate = wf.Active, wf.Candidate, ""
+	return m.changed(wf, "Workflow candidate promoted")
+}
+
+// Rollback switches live traffic back to the previous version. Rolling back
+// twice returns to where it started.
+func (m *Manager) Rollback(workflow string) (*Workflow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wf := m.workflows[workflow]
+	if wf == nil || wf.Previous == "" {
+		return nil, fmt.Errorf("workflow %q has no previous version to roll back to", workflow)
+	}
+	wf.Active, wf.Previous = wf.Previous, wf.Active
+	return m.changed(wf, "Workflow rolled back")
+}
+
+// Discard drops the candidate without promoting it.
+func (m *Manager) Discard(workflow string) (*Workflow, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wf := m.workflows[workflow]
+	if wf == nil || wf.Candidate == "" {
+		return nil, fmt.Errorf("workflow %q has no candidate to discard", workflow)
+	}
+	wf.Candidate = ""
+	return m.changed(wf, "Workflow candidate discarded")
+}
+
+// Workflows returns the deployment state of every workflow, by name.
+func (m *Manager) Workflows() []Workflow {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make([]Workflow, 0, len(m.workflows))
+	for _, wf := range m.workflows {
+		out = append(out, wf.snapshot())
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// changed persists wf after a switch. Callers hold m.mu.
+func (m *Manager) changed(wf *Workflow, msg string) (*Workflow, error) {
+	wf.UpdatedAt = time.Now()
+	if err := m.save(); err != nil {
+		return nil, err
+	}
+	core.Logger().Info().Str("workflow", wf.Name).Str("active", wf.Active).Str("previous", wf.Previous).Msg(msg)
+	snapshot := wf.snapshot()
+	return &snapshot, nil
+}
+
+// install builds a version's agents and registers them on the runner.
+func (m *Manager) install(id string, def Definition) error {
+	agents := make(map[string]core.AgentHandler, len(def.Agents))
+	for name, acfg := range def.Agents {
+		agent, err := m.container.Build(name+"@"+id, name, acfg)
+		if err != nil {
+			return err
+		}
+		agents[name+"@"+id] = agent
+	}
+	for name, agent := range agents {
+		if err := m.runner.RegisterAgent(name, agent); err != nil {
+			return fmt.Errorf("failed to register agent %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// route pins each run to a version of its workflow and sends its events to
+// that version's agents.
+func (m *Manager) route(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+	event := args.Event
+	if event == nil {
+		return args.State, nil
+	}
+	route, _ := event.GetMetadataValue(core.RouteMetadataKey)
+	tag, _ := event.GetMetadataValue(VersionKey)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var wf *Workflow
+	var version string
+	if name, id, pinned := strings.Cut(tag, "@"); pinned && m.workflows[name] != nil {
+		wf, version = m.workflows[name], id
+		if _, ok := wf.Versions[version]; !ok && version != Base {
+			version = wf.Active // the run's version was removed
+		}
+	} else if wf = m.workflows[route]; wf != nil {
+		version = wf.Active
+		if tag == Candidate && wf.Candidate != "" {
+			version = wf.Candidate
+		} else if _, ok := wf.Versions[tag]; ok {
+			version = tag
+		}
+	} else {
+		return args.State, nil
+	}
+
+	event.SetMetadata(VersionKey, wf.Name+"@"+version)
+	if wf.serves(version, route) {
+		event.SetMetadata(core.RouteMetadataKey, route+"@"+version)
+	}
+	return args.State, nil
+}
+
+// save writes the deployments atomically. Callers hold m.mu.
+func (m *Manager) save() error {
+	data, err := json.MarshalIndent(m.workflows, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(m.path), 0o755); err != nil {
+		return fmt.Errorf("failed to store deployments: %w", err)
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to store deployments: %w", err)
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		return fmt.Errorf("failed to store deployments: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,187 @@
+package deploy
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/appconfig"
+	"my-agents/di"
+)
+
+// runner records the agents registered and the callback routing events.
+type runner struct {
+	core.Runner
+	agents   map[string]core.AgentHandler
+	callback core.CallbackFunc
+}
+
+func (r *runner) RegisterAgent(name string, agent core.AgentHandler) error {
+	r.agents[name] = agent
+	return nil
+}
+
+func (r *runner) RegisterCallback(hook core.HookPoint, name string, cb core.CallbackFunc) error {
+	r.callback = cb
+	return nil
+}
+
+func (r *runner) names() []string {
+	var names []string
+	for name := range r.agents {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+const definition = `
+[agents.processor]
+system_prompt = "Answer in two sentences."
+`
+
+type model struct{ core.ModelProvider }
+
+func newManager(t *testing.T, path string) (*Manager, *runner) {
+	t.Helper()
+	c := di.New(&appconfig.Config{}, model{}, nil)
+	c.RegisterAgent("processor", func(deps di.Deps) (core.AgentHandler, error) {
+		return core.AgentHandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+			return core.AgentResult{OutputState: state}, nil
+		}), nil
+	})
+	r := &runner{agents: make(map[string]core.AgentHandler)}
+	m, err := New(path, c, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Register(r); err != nil {
+		t.Fatal(err)
+	}
+	return m, r
+}
+
+// routeOf runs the routing callback on an event entering at route and
+// returns the route and version it leaves with.
+func routeOf(t *testing.T, r *runner, route string, metadata map[string]string) (string, string) {
+	t.Helper()
+	md := map[string]string{core.RouteMetadataKey: route}
+	for k, v := range metadata {
+		md[k] = v
+	}
+	event := core.NewEvent(route, nil, md)
+	if _, err := r.callback(context.Background(), core.CallbackArgs{Event: event}); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := event.GetMetadataValue(core.RouteMetadataKey)
+	version, _ := event.GetMetadataValue(VersionKey)
+	return got, version
+}
+
+func TestParseDefinition(t *testing.T) {
+	if _, err := ParseDefinition([]byte(definition)); err != nil {
+		t.Error(err)
+	}
+	for _, bad := range []string{"[agents", "title = 'x'"} {
+		if _, err := ParseDefinition([]byte(bad)); err == nil {
+			t.Errorf("ParseDefinition(%q) accepted", bad)
+		}
+	}
+}
+
+func TestBlueGreen(t *testing.T) {
+	m, r := newManager(t, filepath.Join(t.TempDir(), "deployments.json"))
+	v, err := m.Deploy("processor", []byte(definition))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v.ID != "v1" || len(v.Agents) != 1 || r.agents["processor@v1"] == nil {
+		t.Fatalf("version %+v, agents %v", v, r.names())
+	}
+
+	if route, version := routeOf(t, r, "processor", nil); route != "processor" || version != "processor@base" {
+		t.Errorf("live traffic went to %s (%s) before promotion", route, version)
+	}
+	if route, _ := routeOf(t, r, "processor", map[string]string{VersionKey: Candidate}); route != "processor@v1" {
+		t.Errorf("test traffic went to %s, want the candidate", route)
+	}
+
+	if _, err := m.Promote("processor"); err != nil {
+		t.Fatal(err)
+	}
+	if route, _ := routeOf(t, r, "processor", nil); route != "processor@v1" {
+		t.Errorf("after promotion live traffic went to %s", route)
+	}
+	// A run pinned to base before the switch stays on it
+	if route, _ := routeOf(t, r, "processor", map[string]string{VersionKey: "processor@base"}); route != "processor" {
+		t.Errorf("pinned run went to %s", route)
+	}
+
+	wf, err := m.Rollback("processor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wf.Active != Base || wf.Previous != "v1" {
+		t.Errorf("after rollback = %+v", wf)
+	}
+	if _, err := m.Promote("processor"); err == nil {
+		t.Error("promoted without a candidate")
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	m, r := newManager(t, filepath.Join(t.TempDir(), "deployments.json"))
+	if _, err := m.Discard("processor"); err == nil {
+		t.Error("discarded an unknown workflow's candidate")
+	}
+	m.Deploy("processor", []byte(definition))
+	if _, err := m.Discard("processor"); err != nil {
+		t.Fatal(err)
+	}
+	if route, _ := routeOf(t, r, "processor", map[string]string{VersionKey: Candidate}); route != "processor" {
+		t.Errorf("test traffic went to %s after discard", route)
+	}
+	if _, err := m.Rollback("processor"); err == nil {
+		t.Error("rolled back without a previous version")
+	}
+}
+
+func TestUnmanagedRoute(t *testing.T) {
+	_, r := newManager(t, filepath.Join(t.TempDir(), "deployments.json"))
+	if route, version := routeOf(t, r, "enhancer", nil); route != "enhancer" || version != "" {
+		t.Errorf("unmanaged route = %s (%q)", route, version)
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "deployments.json")
+	m, _ := newManager(t, path)
+	m.Deploy("processor", []byte(definition))
+	m.Promote("processor")
+	m.Deploy("processor", []byte(definition))
+
+	m, r := newManager(t, path)
+	if got := r.names(); len(got) != 2 || got[0] != "processor@v1" || got[1] != "processor@v2" {
+		t.Errorf("reinstalled agents = %v", got)
+	}
+	wfs := m.Workflows()
+	if len(wfs) != 1 || wfs[0].Active != "v1" || wfs[0].Candidate != "v2" {
+		t.Errorf("workflows = %+v", wfs)
+	}
+}
+
+func TestDeployErrors(t *testing.T) {
+	m, _ := newManager(t, filepath.Join(t.TempDir(), "deployments.json"))
+	if _, err := m.Deploy("", []byte(definition)); err == nil {
+		t.Error("deployed without a workflow name")
+	}
+	if _, err := m.Deploy("processor", []byte("[agents.unknown]\n")); err == nil {
+		t.Error("deployed an agent with no implementation")
+	}
+	if len(m.Workflows()) != 0 {
+		t.Error("failed deploy recorded")
+	}
+}
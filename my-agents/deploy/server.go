@@ -0,0 +1,63 @@
+package deploy
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// maxDefinition bounds uploaded definitions.
+const maxDefinition = 1 << 20
+
+// Handler serves the deployment endpoints, for mounting on the admin API
+// under "/admin/deployments" and "/admin/deployments/":
+//
+//	GET    /admin/deployments
+//	POST   /admin/deployments/{workflow}            body: TOML definition
+//	POST   /admin/deployments/{workflow}/promote
+//	POST   /admin/deployments/{workflow}/rollback
+//	DELETE /admin/deployments/{workflow}/candidate
+func (m *Manager) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/deployments", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, m.Workflows())
+	})
+	mux.HandleFunc("POST /admin/deployments/{workflow}", func(w http.ResponseWriter, r *http.Request) {
+		data, err := io.ReadAll(io.LimitReader(r.Body, maxDefinition))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		v, err := m.Deploy(r.PathValue("workflow"), data)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusCreated, v)
+	})
+	for action, switchFn := range map[string]func(string) (*Workflow, error){
+		"POST /admin/deployments/{workflow}/promote":     m.Promote,
+		"POST /admin/deployments/{workflow}/rollback":    m.Rollback,
+		"DELETE /admin/deployments/{workflow}/candidate": m.Discard,
+	} {
+		mux.HandleFunc(action, func(w http.ResponseWriter, r *http.Request) {
+			wf, err := switchFn(r.PathValue("workflow"))
+			if err != nil {
+				writeError(w, http.StatusConflict, err)
+				return
+			}
+			writeJSON(w, http.StatusOK, wf)
+		})
+	}
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
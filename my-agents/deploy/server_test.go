@@ -0,0 +1,38 @@
+package deploy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	m, _ := newManager(t, filepath.Join(t.TempDir(), "deployments.json"))
+	h := m.Handler()
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	if w := do("POST", "/admin/deployments/processor", definition); w.Code != http.StatusCreated || !strings.Contains(w.Body.String(), `"id":"v1"`) {
+		t.Errorf("deploy: %d %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/admin/deployments/processor", "not toml ["); w.Code != http.StatusBadRequest {
+		t.Errorf("bad definition: status %d", w.Code)
+	}
+	if w := do("POST", "/admin/deployments/processor/promote", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"active":"v1"`) {
+		t.Errorf("promote: %d %s", w.Code, w.Body)
+	}
+	if w := do("DELETE", "/admin/deployments/processor/candidate", ""); w.Code != http.StatusConflict {
+		t.Errorf("discard without a candidate: status %d", w.Code)
+	}
+	if w := do("POST", "/admin/deployments/processor/rollback", ""); w.Code != http.StatusOK {
+		t.Errorf("rollback: status %d", w.Code)
+	}
+	if w := do("GET", "/admin/deployments", ""); !strings.Contains(w.Body.String(), `"previous":"v1"`) {
+		t.Errorf("list: %s", w.Body)
+	}
+}
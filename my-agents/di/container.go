@@ -0,0 +1,536 @@
+// Package di builds agents from their declared dependencies in agentflow.toml
+// instead of hand-written struct literals in main.
+package di
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/anthropic"
+	"my-agents/appconfig"
+	"my-agents/bedrock"
+	"my-agents/bus"
+	"my-agents/fallback"
+	"my-agents/flags"
+	"my-agents/gemini"
+	"my-agents/llmcache"
+	"my-agents/local"
+	"my-agents/middleware"
+	"my-agents/ratelimit"
+	"my-agents/region"
+	"my-agents/sink"
+	"my-agents/tools"
+)
+
+// DefaultProvider is the provider name used when an agent declares none.
+const DefaultProvider = "default"
+
+// Deps are the resolved dependencies handed to an agent factory.
+type Deps struct {
+	Name         string
+	SystemPrompt string // overrides the agent's built-in prompt when set
+	MaxSteps     int    // tool-loop step limit for agents wired with tools
+	LLM          core.ModelProvider
+	Memory       core.Memory
+	Tools        map[string]tools.Tool
+	Sinks        []sink.Sink
+	Flags        *flags.Client
+	Bus          *bus.Bus
+}
+
+// AgentScoped is implemented by providers that attribute calls to the agent
+// making them; Resolve hands each agent its own view.
+type AgentScoped interface {
+	ForAgent(name string) core.ModelProvider
+}
+
+// LLMMiddleware wraps the provider handed to an agent, e.g. to meter usage.
+type LLMMiddleware func(agent string, llm core.ModelProvider) core.ModelProvider
+
+// ToolMiddleware wraps a tool handed to an agent, e.g. to defer its calls.
+type ToolMiddleware func(agent string, tool tools.Tool) tools.Tool
+
+// SinkMiddleware wraps a named sink handed to an agent.
+type SinkMiddleware func(agent, name string, s sink.Sink) sink.Sink
+
+// AgentFactory constructs an agent from its resolved dependencies.
+type AgentFactory func(deps Deps) (core.AgentHandler, error)
+
+// Container resolves providers, memory, tools and sinks by name.
+type Container struct {
+	cfg    *appconfig.Config
+	memory core.Memory
+	flags  *flags.Client
+	bus    *bus.Bus
+	tools  *tools.Registry // locks itself
+	// regions keeps the latency and health of regional providers' regions
+	regions *region.Metrics
+
+	mu        sync.Mutex
+	providers map[string]core.ModelProvider
+	sinks     map[string]sink.Sink
+	factories map[string]AgentFactory
+	llmMW     []LLMMiddleware
+	toolMW    []ToolMiddleware
+	sinkMW    []SinkMiddleware
+	agentMW   []middleware.Middleware
+	named     map[string]middleware.Middleware
+}
+
+// New creates a container. The fallback provider is registered as "default"
+// and serves agents that don't name a provider; memory may be nil.
+func New(cfg *appconfig.Config, fallback core.ModelProvider, memory core.Memory) *Container {
+	c := &Container{
+		cfg:       cfg,
+		memory:    memory,
+		providers: make(map[string]core.ModelProvider),
+		tools:     tools.NewRegistry(),
+		sinks:     make(map[string]sink.Sink),
+		factories: make(map[string]AgentFactory),
+		named:     make(map[string]middleware.Middleware),
+	}
+	if fallback != nil {
+		c.providers[DefaultProvider] = fallback
+	}
+	return c
+}
+
+// SetFlags sets the feature flag client handed to every agent.
+func (c *Container) SetFlags(client *flags.Client) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flags = client
+}
+
+// SetRegions sets where providers with [providers.<name>.regions] keep
+// their regions' latency and health.
+func (c *Container) SetRegions(m *region.Metrics) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.regions = m
+}
+
+// SetBus sets the agent message bus handed to every agent.
+func (c *Container) SetBus(b *bus.Bus) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.bus = b
+}
+
+// UseLLM adds middleware around every provider resolved for an agent.
+// Middleware added first is outermost.
+func (c *Container) UseLLM(mw LLMMiddleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.llmMW = append(c.llmMW, mw)
+}
+
+// UseTool adds middleware around every tool resolved for an agent.
+// Middleware added first is outermost.
+func (c *Container) UseTool(mw ToolMiddleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.toolMW = append(c.toolMW, mw)
+}
+
+// UseSink adds middleware around every sink resolved for an agent.
+// Middleware added first is outermost.
+func (c *Container) UseSink(mw SinkMiddleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sinkMW = append(c.sinkMW, mw)
+}
+
+// UseAgent adds middleware around every agent the container builds.
+// Middleware added first is outermost; all of it is outside the middleware
+// named in agentflow.toml.
+func (c *Container) UseAgent(mw middleware.Middleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.agentMW = append(c.agentMW, mw)
+}
+
+// RegisterMiddleware makes agent middleware available by name, to the
+// [middleware] use list and to agents that list it.
+func (c *Container) RegisterMiddleware(name string, mw middleware.Middleware) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.named[name] = mw
+}
+
+// RegisterProvider makes a pre-built provider available by name.
+func (c *Container) RegisterProvider(name string, provider core.ModelProvider) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.providers[name] = provider
+}
+
+// RegisterTool makes a tool available to agents that list it.
+func (c *Container) RegisterTool(tool tools.Tool) {
+	c.tools.Add(tool)
+}
+
+// Tools returns the registry of tools agents can list, e.g. to register a
+// Go function as one.
+func (c *Container) Tools() *tools.Registry {
+	return c.tools
+}
+
+// RegisterSink makes an output sink available by name.
+func (c *Container) RegisterSink(name string, s sink.Sink) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sinks[name] = s
+}
+
+// RegisterAgent registers the factory used to construct the named agent.
+func (c *Container) RegisterAgent(name string, factory AgentFactory) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.factories[name] = factory
+}
+
+// Provider resolves a provider by name, constructing it from the
+// [providers.<name>] table on first use.
+func (c *Container) Provider(name string) (core.ModelProvider, error) {
+	if name == "" {
+		name = DefaultProvider
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if p, ok := c.providers[name]; ok {
+		return p, nil
+	}
+	pcfg, ok := c.cfg.Providers[name]
+	if !ok {
+		return nil, fmt.Errorf("provider %q is not configured", name)
+	}
+	opts := c.cfg.ProviderOptions[name]
+	p, err := NewRouted(name, pcfg, opts, c.regions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create provider %q: %w", name, err)
+	}
+	r := &rotatable{name: name, limiter: ratelimit.New(opts.RateLimitOptions)}
+	r.set(p)
+	c.providers[name] = r
+	return r, nil
+}
+
+// NewProvider creates a provider from its table: the types implemented
+// here with their options, the others by agenticgokit.
+func NewProvider(pcfg core.LLMProviderConfig, opts appconfig.ProviderOptions) (core.ModelProvider, error) {
+	switch {
+	case local.Supports(pcfg.Type):
+		return local.New(pcfg, opts.LocalOptions)
+	case pcfg.Type == anthropic.Type:
+		return anthropic.New(pcfg, opts.AnthropicOptions)
+	case pcfg.Type == gemini.Type:
+		g := opts.GeminiOptions
+		g.EmbeddingModel = opts.LocalOptions.EmbeddingModel
+		return gemini.New(pcfg, g)
+	case pcfg.Type == bedrock.Type:
+		b := opts.BedrockOptions
+		b.EmbeddingModel = opts.LocalOptions.EmbeddingModel
+		return bedrock.New(pcfg, b)
+	}
+	return core.NewModelProviderFromConfig(pcfg)
+}
+
+// NewRouted creates the provider of the [providers.<name>] table: one
+// provider per region routed between by latency when the table lists
+// regions, otherwise as NewProvider does.
+func NewRouted(name string, pcfg core.LLMProviderConfig, opts appconfig.ProviderOptions, metrics *region.Metrics) (core.ModelProvider, error) {
+	if len(opts.Regions) == 0 {
+		return NewProvider(pcfg, opts)
+	}
+	names := make([]string, 0, len(opts.Regions))
+	for r := range opts.Regions {
+		names = append(names, r)
+	}
+	sort.Strings(names)
+	links := make([]region.Link, 0, len(names))
+	for _, r := range names {
+		e := opts.Regions[r]
+		rcfg, ropts := pcfg, opts
+		rcfg.Endpoint = cmp.Or(e.Endpoint, rcfg.Endpoint)
+		rcfg.BaseURL = cmp.Or(e.BaseURL, rcfg.BaseURL)
+		rcfg.APIKey = cmp.Or(e.APIKey, rcfg.APIKey)
+		ropts.BedrockOptions.Region = cmp.Or(e.Region, ropts.BedrockOptions.Region)
+		ropts.GeminiOptions.Location = cmp.Or(e.Location, ropts.GeminiOptions.Location)
+		p, err := NewProvider(rcfg, ropts)
+		if err != nil {
+			return nil, fmt.Errorf("region %s: %w", r, err)
+		}
+		links = append(links, region.Link{Region: r, LLM: p})
+	}
+	return region.New(name, links, opts.RegionOptions, metrics)
+}
+
+// Native reports whether typ is a provider type implemented here rather
+// than by agenticgokit.
+func Native(typ string) bool {
+	return local.Supports(typ) || typ == anthropic.Type || typ == gemini.Type || typ == bedrock.Type
+}
+
+// RotateKey rebuilds a configured provider with a new API key. Agents keep
+// their provider handle; calls already in flight finish on the old client.
+// A provider not resolved yet picks the key up when it is first built.
+func (c *Container) RotateKey(name, apiKey string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	pcfg, ok := c.cfg.Providers[name]
+	if !ok {
+		return fmt.Errorf("provider %q is not configured", name)
+	}
+	pcfg.APIKey = apiKey
+	if existing, resolved := c.providers[name]; resolved {
+		r, ok := existing.(*rotatable)
+		if !ok {
+			return fmt.Errorf("provider %q cannot be rotated", name)
+		}
+		p, err := NewRouted(name, pcfg, c.cfg.ProviderOptions[name], c.regions)
+		if err != nil {
+			return fmt.Errorf("failed to create provider %q: %w", name, err)
+		}
+		r.set(p)
+	}
+	c.cfg.Providers[name] = pcfg
+	return nil
+}
+
+// AgentProvider resolves a provider for use by the named agent: scoped to
+// the agent when it supports that, and wrapped in the LLM middleware.
+// Agents needing more than their main provider use it for the others.
+func (c *Container) AgentProvider(agent, name string) (core.ModelProvider, error) {
+	llm, err := c.Provider(name)
+	if err != nil {
+		return nil, err
+	}
+	if scoped, ok := llm.(AgentScoped); ok {
+		llm = scoped.ForAgent(agent)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := len(c.llmMW) - 1; i >= 0; i-- {
+		llm = c.llmMW[i](agent, llm)
+	}
+	return llm, nil
+}
+
+// agentLLM resolves an agent's main provider, failing over along its
+// fallback chain when one is configured. Each link is resolved for the
+// agent, so the LLM middleware sees which provider answered.
+func (c *Container) agentLLM(name string, acfg appconfig.AgentConfig) (core.ModelProvider, error) {
+	llm, err := c.AgentProvider(name, acfg.Provider)
+	if err != nil || !acfg.Fallback.Enabled() {
+		return llm, err
+	}
+	first := acfg.Provider
+	if first == "" {
+		first = DefaultProvider
+	}
+	chain := []fallback.Link{{Name: first, LLM: llm}}
+	for _, provider := range acfg.Fallback.Providers {
+		llm, err := c.AgentProvider(name, provider)
+		if err != nil {
+			return nil, fmt.Errorf("fallback: %w", err)
+		}
+		chain = append(chain, fallback.Link{Name: provider, LLM: llm})
+	}
+	return fallback.New(chain, *acfg.Fallback)
+}
+
+// AgentSink resolves a sink for use by the named agent, wrapped in the sink
+// middleware. Agents with a built-in default sink use it for that.
+func (c *Container) AgentSink(agent, name string) (sink.Sink, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.agentSink(agent, name)
+}
+
+// agentSink implements AgentSink. Callers hold c.mu.
+func (c *Container) agentSink(agent, name string) (sink.Sink, error) {
+	s, ok := c.sinks[name]
+	if !ok {
+		return nil, fmt.Errorf("agent %s: sink %q is not registered", agent, name)
+	}
+	for i := len(c.sinkMW) - 1; i >= 0; i-- {
+		s = c.sinkMW[i](agent, name, s)
+	}
+	return s, nil
+}
+
+// Resolve builds the dependency set for the named agent.
+func (c *Container) Resolve(name string) (Deps, error) {
+	return c.resolve(name, c.cfg.Agents[name])
+}
+
+func (c *Container) resolve(name string, acfg appconfig.AgentConfig) (Deps, error) {
+	llm, err := c.agentLLM(name, acfg)
+	if err != nil {
+		return Deps{}, fmt.Errorf("agent %s: %w", name, err)
+	}
+	deps := Deps{Name: name, SystemPrompt: acfg.SystemPrompt, MaxSteps: acfg.MaxSteps, LLM: llm, Tools: make(map[string]tools.Tool)}
+
+	if c.memory != nil {
+		deps.Memory = c.memory
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	deps.Flags = c.flags
+	deps.Bus = c.bus
+	for _, toolName := range acfg.Tools {
+		tool, ok := c.tools.Get(toolName)
+		if !ok {
+			return Deps{}, fmt.Errorf("agent %s: tool %q is not registered", name, toolName)
+		}
+		for i := len(c.toolMW) - 1; i >= 0; i-- {
+			tool = c.toolMW[i](name, tool)
+		}
+		deps.Tools[toolName] = tool
+	}
+	for _, sinkName := range acfg.Sinks {
+		s, err := c.agentSink(name, sinkName)
+		if err != nil {
+			return Deps{}, err
+		}
+		deps.Sinks = append(deps.Sinks, s)
+	}
+	return deps, nil
+}
+
+// BuildAgents constructs every registered agent, plus each configured agent
+// that extends a registered one.
+func (c *Container) BuildAgents() (map[string]core.AgentHandler, error) {
+	c.mu.Lock()
+	names := make([]string, 0, len(c.factories))
+	for name := range c.factories {
+		names = append(names, name)
+	}
+	for name, acfg := range c.cfg.Agents {
+		if acfg.Extends == "" {
+			continue
+		}
+		if _, ok := c.factories[name]; ok {
+			continue
+		}
+		if _, ok := c.factories[acfg.Extends]; !ok {
+			c.mu.Unlock()
+			return nil, fmt.Errorf("agent %s: extends unknown agent %q", name, acfg.Extends)
+		}
+		names = append(names, name)
+	}
+	c.mu.Unlock()
+	sort.Strings(names)
+
+	agents := make(map[string]core.AgentHandler, len(names))
+	for _, name := range names {
+		deps, err := c.Resolve(name)
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		factory, ok := c.factories[name]
+		if !ok {
+			factory = c.factories[c.cfg.Agents[name].Extends]
+		}
+		c.mu.Unlock()
+		agent, err := factory(deps)
+		if err != nil {
+			return nil, fmt.Errorf("agent %s: %w", name, err)
+		}
+		if agents[name], err = c.wrap(name, c.cfg.Agents[name], agent); err != nil {
+			return nil, err
+		}
+	}
+	return agents, nil
+}
+
+// Build constructs an agent declared outside agentflow.toml, e.g. by a
+// workflow version deployed at runtime. It uses the implementation acfg
+// extends, or that of the configured agent base when it extends none.
+func (c *Container) Build(name, base string, acfg appconfig.AgentConfig) (core.AgentHandler, error) {
+	impl := acfg.Extends
+	if impl == "" {
+		impl = base
+	}
+	c.mu.Lock()
+	factory, ok := c.factories[impl]
+	if !ok {
+		factory, ok = c.factories[c.cfg.Agents[impl].Extends]
+	}
+	c.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("agent %s: no registered agent %q to build from", name, impl)
+	}
+	deps, err := c.resolve(name, acfg)
+	if err != nil {
+		return nil, err
+	}
+	agent, err := factory(deps)
+	if err != nil {
+		return nil, fmt.Errorf("agent %s: %w", name, err)
+	}
+	return c.wrap(name, acfg, agent)
+}
+
+// wrap puts agent in the container's middleware, then the [middleware] use
+// list's, then its own.
+func (c *Container) wrap(name string, acfg appconfig.AgentConfig, agent core.AgentHandler) (core.AgentHandler, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	mws := append([]middleware.Middleware(nil), c.agentMW...)
+	for _, mwName := range append(append([]string(nil), c.cfg.Middleware.Use...), acfg.Middleware...) {
+		mw, ok := c.named[mwName]
+		if !ok {
+			return nil, fmt.Errorf("agent %s: middleware %q is not registered", name, mwName)
+		}
+		mws = append(mws, mw)
+	}
+	return middleware.Chain(name, agent, mws...), nil
+}
+
+// rotatable forwards to a provider that RotateKey can replace. Its
+// limiter outlives the replaced clients, so a rotation doesn't reset the
+// provider's rate limits.
+type rotatable struct {
+	name    string
+	current atomic.Pointer[core.ModelProvider]
+	limiter *ratelimit.Limiter // nil without limits
+}
+
+// Name is the provider's [providers.<name>] table.
+func (r *rotatable) Name() string { return r.name }
+
+func (r *rotatable) set(p core.ModelProvider) {
+	p = r.limiter.Wrap(p)
+	r.current.Store(&p)
+}
+
+func (r *rotatable) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	resp, err := (*r.current.Load()).Call(ctx, prompt)
+	if err == nil {
+		llmcache.Answered(ctx, r.name)
+	}
+	return resp, err
+}
+
+func (r *rotatable) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	tokens, err := (*r.current.Load()).Stream(ctx, prompt)
+	if err == nil {
+		llmcache.Answered(ctx, r.name)
+	}
+	return tokens, err
+}
+
+func (r *rotatable) Embeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	return (*r.current.Load()).Embeddings(ctx, texts)
+}
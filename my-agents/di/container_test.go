@@ -0,0 +1,95 @@
+package di
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/appconfig"
+)
+
+// canned is a provider answering every call with its name.
+type canned struct {
+	core.ModelProvider
+	name string
+}
+
+func (c canned) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	return core.Response{Content: c.name}, nil
+}
+
+func config() *appconfig.Config {
+	return &appconfig.Config{Providers: map[string]core.LLMProviderConfig{
+		DefaultProvider: {Type: "ollama", Model: "llama3", APIKey: "old"},
+		"cheap":         {Type: "openai-compatible", Model: "small", BaseURL: "http://127.0.0.1:1"},
+	}}
+}
+
+func TestProviderIsBuiltOnceFromItsTable(t *testing.T) {
+	c := New(config(), nil, nil)
+	p, err := c.Provider("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := c.Provider(DefaultProvider)
+	if err != nil || again != p {
+		t.Errorf("the default provider was built again: %v", err)
+	}
+	if _, err := c.Provider("missing"); err == nil {
+		t.Error("an unconfigured provider resolved")
+	}
+}
+
+func TestRotateKey(t *testing.T) {
+	cfg := config()
+	c := New(cfg, nil, nil)
+	p, err := c.Provider(DefaultProvider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := *p.(*rotatable).current.Load()
+	if err := c.RotateKey(DefaultProvider, "new"); err != nil {
+		t.Fatalf("RotateKey of [providers.default] = %v", err)
+	}
+	if *p.(*rotatable).current.Load() == before {
+		t.Error("the agents' handle still forwards to the old client")
+	}
+	// Not resolved yet: built with the new key when first used
+	if err := c.RotateKey("cheap", "k"); err != nil || cfg.Providers["cheap"].APIKey != "k" {
+		t.Errorf("RotateKey of an unresolved provider = %v, key %q", err, cfg.Providers["cheap"].APIKey)
+	}
+	if err := c.RotateKey("missing", "k"); err == nil {
+		t.Error("an unconfigured provider was rotated")
+	}
+}
+
+func TestRotateKeyOfPrebuiltProvider(t *testing.T) {
+	c := New(config(), canned{name: "fallback"}, nil)
+	if err := c.RotateKey(DefaultProvider, "new"); err == nil || !strings.Contains(err.Error(), "cannot be rotated") {
+		t.Errorf("RotateKey of a registered provider = %v", err)
+	}
+}
+
+func TestAgentProviderAppliesMiddleware(t *testing.T) {
+	c := New(config(), canned{name: "fallback"}, nil)
+	var order []string
+	for _, name := range []string{"outer", "inner"} {
+		c.UseLLM(func(agent string, next core.ModelProvider) core.ModelProvider {
+			order = append(order, name+":"+agent)
+			return next
+		})
+	}
+	p, err := c.AgentProvider("writer", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := p.Call(context.Background(), core.Prompt{})
+	if err != nil || resp.Content != "fallback" {
+		t.Errorf("Call = %q, %v", resp.Content, err)
+	}
+	if strings.Join(order, ",") != "inner:writer,outer:writer" {
+		t.Errorf("middleware wrapped in order %v, want the first added outermost", order)
+	}
+}
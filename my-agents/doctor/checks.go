@@ -0,0 +1,122 @@
+package doctor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/tools/proc"
+)
+
+// Provider sends a one-word prompt through llm.
+func Provider(name string, llm core.ModelProvider) Check {
+	return Check{Group: "providers", Name: name, Run: func(ctx context.Context) (string, error) {
+		start := time.Now()
+		resp, err := llm.Call(ctx, core.Prompt{User: "Reply with the single word OK."})
+		if err != nil {
+			return "", fmt.Errorf("test call failed: %w", err)
+		}
+		took := time.Since(start).Round(time.Millisecond)
+		if strings.TrimSpace(resp.Content) == "" {
+			return "", Warning(fmt.Errorf("answered in %s with an empty response", took))
+		}
+		return fmt.Sprintf("answered in %s", took), nil
+	}}
+}
+
+// OllamaModel checks that an Ollama server at baseURL has model pulled.
+func OllamaModel(baseURL, model string) Check {
+	return Check{Group: "models", Name: model, Run: func(ctx context.Context) (string, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(baseURL, "/")+"/api/tags", nil)
+		if err != nil {
+			return "", err
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("ollama is not reachable at %s; start it with `ollama serve`", baseURL)
+		}
+		defer resp.Body.Close()
+		var tags struct {
+			Models []struct {
+				Name string `json:"name"`
+			} `json:"models"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+			return "", fmt.Errorf("unexpected answer from %s: %w", baseURL, err)
+		}
+		for _, m := range tags.Models {
+			if m.Name == model || m.Name == model+":latest" {
+				return "pulled on " + baseURL, nil
+			}
+		}
+		return "", fmt.Errorf("not pulled on %s; run `ollama pull %s`", baseURL, model)
+	}}
+}
+
+// Memory stores, recalls and clears a value in a scratch session.
+func Memory(memory core.Memory) Check {
+	return Check{Group: "memory", Name: "read/write", Run: func(ctx context.Context) (string, error) {
+		ctx = memory.SetSession(ctx, "doctor-"+core.GenerateSessionID())
+		want := time.Now().Format(time.RFC3339Nano)
+		if err := memory.Remember(ctx, "doctor", want); err != nil {
+			return "", fmt.Errorf("write failed: %w", err)
+		}
+		got, err := memory.Recall(ctx, "doctor")
+		if err != nil {
+			return "", fmt.Errorf("read failed: %w", err)
+		}
+		if err := memory.ClearSession(ctx); err != nil {
+			return "", fmt.Errorf("clear failed: %w", err)
+		}
+		if fmt.Sprint(got) != want {
+			return "", fmt.Errorf("read back %v, wrote %s", got, want)
+		}
+		return "stored, recalled and cleared a value", nil
+	}}
+}
+
+// Binary checks that an external program is installed.
+func Binary(name, purpose string) Check {
+	return Check{Group: "binaries", Name: name, Run: func(context.Context) (string, error) {
+		path, err := proc.LookPath(name)
+		if err != nil {
+			return "", fmt.Errorf("not found; %s needs it", purpose)
+		}
+		return path, nil
+	}}
+}
+
+// Writable checks that files can be created in dir, creating it if needed.
+func Writable(group, dir string) Check {
+	return Check{Group: group, Name: dir, Run: func(context.Context) (string, error) {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", err
+		}
+		f, err := os.CreateTemp(dir, ".doctor-")
+		if err != nil {
+			return "", fmt.Errorf("not writable: %w", err)
+		}
+		f.Close()
+		os.Remove(f.Name())
+		abs, _ := filepath.Abs(dir)
+		return "writable (" + abs + ")", nil
+	}}
+}
+
+// Env checks that an environment variable holding a secret is set.
+func Env(group, name, purpose string) Check {
+	return Check{Group: group, Name: "$" + name, Run: func(context.Context) (string, error) {
+		if os.Getenv(name) == "" {
+			return "", errors.New("not set; " + purpose + " needs it")
+		}
+		return "set", nil
+	}}
+}
@@ -0,0 +1,139 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+type model struct {
+	core.ModelProvider
+	reply string
+	err   error
+}
+
+func (m model) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	return core.Response{Content: m.reply}, m.err
+}
+
+func TestProvider(t *testing.T) {
+	tests := []struct {
+		llm    model
+		status string
+	}{
+		{model{reply: "OK"}, Pass},
+		{model{reply: " "}, Warn},
+		{model{err: errors.New("401")}, Fail},
+	}
+	for _, tt := range tests {
+		r := Run(context.Background(), []Check{Provider("openai", tt.llm)}, time.Second)[0]
+		if r.Status != tt.status || r.Group != "providers" {
+			t.Errorf("%+v: %+v", tt.llm, r)
+		}
+	}
+}
+
+func TestOllamaModel(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/tags" {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, `{"models": [{"name": "llama3:latest"}, {"name": "mistral:7b"}]}`)
+	}))
+	defer srv.Close()
+	ctx := context.Background()
+
+	for _, m := range []string{"llama3", "mistral:7b"} {
+		if detail, err := OllamaModel(srv.URL+"/", m).Run(ctx); err != nil || detail != "pulled on "+srv.URL+"/" {
+			t.Errorf("%s: %q, %v", m, detail, err)
+		}
+	}
+	if _, err := OllamaModel(srv.URL, "phi3").Run(ctx); err == nil || !strings.Contains(err.Error(), "ollama pull phi3") {
+		t.Errorf("missing model err = %v", err)
+	}
+	srv.Close()
+	if _, err := OllamaModel(srv.URL, "llama3").Run(ctx); err == nil || !strings.Contains(err.Error(), "not reachable") {
+		t.Errorf("stopped server err = %v", err)
+	}
+}
+
+type memory struct {
+	core.Memory
+	values  map[string]any
+	cleared bool
+}
+
+func (m *memory) SetSession(ctx context.Context, sessionID string) context.Context { return ctx }
+
+func (m *memory) Remember(ctx context.Context, key string, value any) error {
+	m.values[key] = value
+	return nil
+}
+
+func (m *memory) Recall(ctx context.Context, key string) (any, error) { return m.values[key], nil }
+
+func (m *memory) ClearSession(ctx context.Context) error {
+	m.cleared = true
+	return nil
+}
+
+func TestMemory(t *testing.T) {
+	m := &memory{values: make(map[string]any)}
+	if _, err := Memory(m).Run(context.Background()); err != nil || !m.cleared {
+		t.Errorf("err = %v, cleared = %v", err, m.cleared)
+	}
+	// A memory that forgets fails the check
+	if _, err := Memory(&core.NoOpMemory{}).Run(context.Background()); err == nil || !strings.Contains(err.Error(), "read back") {
+		t.Errorf("no-op memory err = %v", err)
+	}
+}
+
+func TestBinary(t *testing.T) {
+	if path, err := Binary("sh", "the shell tool").Run(context.Background()); err != nil || filepath.Base(path) != "sh" {
+		t.Errorf("sh: %q, %v", path, err)
+	}
+	if _, err := Binary("no-such-binary", "the shell tool").Run(context.Background()); err == nil || !strings.Contains(err.Error(), "the shell tool needs it") {
+		t.Errorf("missing binary err = %v", err)
+	}
+}
+
+func TestWritable(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "data")
+	if _, err := Writable("storage", dir).Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("left %d files behind", len(entries))
+	}
+	file := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(file, nil, 0o644)
+	if _, err := Writable("storage", file).Run(context.Background()); err == nil {
+		t.Error("a file passed as a writable directory")
+	}
+}
+
+func TestEnv(t *testing.T) {
+	t.Setenv("DOCTOR_TEST_KEY", "")
+	check := Env("providers", "DOCTOR_TEST_KEY", "openai")
+	if check.Name != "$DOCTOR_TEST_KEY" {
+		t.Errorf("name = %q", check.Name)
+	}
+	if _, err := check.Run(context.Background()); err == nil {
+		t.Error("unset variable passed")
+	}
+	t.Setenv("DOCTOR_TEST_KEY", "sk-1")
+	if detail, err := check.Run(context.Background()); err != nil || detail != "set" {
+		t.Errorf("%q, %v", detail, err)
+	}
+}
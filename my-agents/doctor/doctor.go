@@ -0,0 +1,139 @@
+// Package doctor runs self-tests over a deployment — config, provider
+// connectivity, models, memory, storage, tool sandboxes and the binaries
+// tools shell out to — and prints a pass/fail report with a hint for each
+// failure.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Statuses.
+const (
+	Pass = "pass"
+	Warn = "warn"
+	Fail = "fail"
+	Skip = "skip"
+)
+
+// Check is one self-test. Run returns a short description of what it found;
+// an error fails the check unless it is a Warning.
+type Check struct {
+	Group string
+	Name  string
+	Run   func(ctx context.Context) (string, error)
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Group    string        `json:"group"`
+	Name     string        `json:"name"`
+	Status   string        `json:"status"`
+	Detail   string        `json:"detail,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+type warning struct{ error }
+
+// Warning marks err as worth attention without failing the check.
+func Warning(err error) error {
+	return warning{err}
+}
+
+type skipped struct{ reason string }
+
+func (s skipped) Error() string { return s.reason }
+
+// Skipped reports that a check does not apply, e.g. to a feature that is
+// not configured.
+func Skipped(reason string) error {
+	return skipped{reason}
+}
+
+// Failed is a check that fails with err, for prerequisites that could not
+// be set up.
+func Failed(group, name string, err error) Check {
+	return Check{Group: group, Name: name, Run: func(context.Context) (string, error) { return "", err }}
+}
+
+// Run runs checks in order, each bounded by timeout.
+func Run(ctx context.Context, checks []Check, timeout time.Duration) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, c := range checks {
+		cctx, cancel := context.WithTimeout(ctx, timeout)
+		start := time.Now()
+		detail, err := c.Run(cctx)
+		cancel()
+		r := Result{Group: c.Group, Name: c.Name, Status: Pass, Detail: detail, Duration: time.Since(start)}
+		var w warning
+		var s skipped
+		switch {
+		case err == nil:
+		case errors.As(err, &s):
+			r.Status, r.Detail = Skip, s.reason
+		case errors.As(err, &w):
+			r.Status, r.Detail = Warn, w.Error()
+		case errors.Is(err, context.DeadlineExceeded):
+			r.Status, r.Detail = Fail, fmt.Sprintf("no answer within %s", timeout)
+		default:
+			r.Status, r.Detail = Fail, err.Error()
+		}
+		results = append(results, r)
+	}
+	return results
+}
+
+// Count returns how many results have status.
+func Count(results []Result, status string) int {
+	n := 0
+	for _, r := range results {
+		if r.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+var marks = map[string]struct{ mark, color string }{
+	Pass: {"✓", "\033[32m"},
+	Warn: {"!", "\033[33m"},
+	Fail: {"✗", "\033[31m"},
+	Skip: {"–", "\033[90m"},
+}
+
+// Print writes the report grouped as the checks were, in color when color
+// is set.
+func Print(w io.Writer, results []Result, color bool) {
+	group := ""
+	for _, r := range results {
+		if r.Group != group {
+			if group != "" {
+				fmt.Fprintln(w)
+			}
+			group = r.Group
+			fmt.Fprintln(w, strings.ToUpper(group))
+		}
+		m := marks[r.Status]
+		mark := m.mark
+		if color {
+			mark = m.color + m.mark + "\033[0m"
+		}
+		fmt.Fprintf(w, "  %s %-32s %s\n", mark, r.Name, r.Detail)
+	}
+	fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed, %d skipped\n", Count(results, Pass), Count(results, Warn), Count(results, Fail), Count(results, Skip))
+}
+
+// Color reports whether f is a terminal that should get colored output.
+func Color(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,90 @@
+package doctor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	check := func(name string, detail string, err error) Check {
+		return Check{Group: "providers", Name: name, Run: func(context.Context) (string, error) { return detail, err }}
+	}
+	slow := Check{Group: "providers", Name: "slow", Run: func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	}}
+	results := Run(context.Background(), []Check{
+		check("ok", "answered", nil),
+		check("empty", "", Warning(errors.New("empty response"))),
+		check("off", "", Skipped("not configured")),
+		check("broken", "", errors.New("test call failed")),
+		slow,
+		Failed("memory", "read/write", errors.New("no memory")),
+	}, 10*time.Millisecond)
+
+	want := []struct{ status, detail string }{
+		{Pass, "answered"},
+		{Warn, "empty response"},
+		{Skip, "not configured"},
+		{Fail, "test call failed"},
+		{Fail, "no answer within 10ms"},
+		{Fail, "no memory"},
+	}
+	if len(results) != len(want) {
+		t.Fatalf("got %d results", len(results))
+	}
+	for i, w := range want {
+		if r := results[i]; r.Status != w.status || r.Detail != w.detail {
+			t.Errorf("result %d = %s %q, want %s %q", i, r.Status, r.Detail, w.status, w.detail)
+		}
+	}
+	if results[5].Group != "memory" || results[5].Name != "read/write" {
+		t.Errorf("failed check = %+v", results[5])
+	}
+	if Count(results, Fail) != 3 || Count(results, Pass) != 1 {
+		t.Errorf("counts: %d failed, %d passed", Count(results, Fail), Count(results, Pass))
+	}
+}
+
+func TestPrint(t *testing.T) {
+	results := []Result{
+		{Group: "providers", Name: "openai", Status: Pass, Detail: "answered in 1s"},
+		{Group: "memory", Name: "read/write", Status: Fail, Detail: "write failed"},
+	}
+	var b bytes.Buffer
+	Print(&b, results, false)
+	out := b.String()
+	for _, s := range []string{"PROVIDERS\n  ✓ openai", "\n\nMEMORY\n  ✗ read/write", "1 passed, 0 warnings, 1 failed, 0 skipped"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("report lacks %q:\n%s", s, out)
+		}
+	}
+	if strings.Contains(out, "\033[") {
+		t.Errorf("uncolored report has escapes:\n%s", out)
+	}
+	b.Reset()
+	Print(&b, results, true)
+	if !strings.Contains(b.String(), "\033[32m✓\033[0m") {
+		t.Errorf("colored report:\n%s", b.String())
+	}
+}
+
+func TestColor(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if Color(f) {
+		t.Error("a file gets color")
+	}
+	t.Setenv("NO_COLOR", "1")
+	if Color(os.Stdout) {
+		t.Error("color despite NO_COLOR")
+	}
+}
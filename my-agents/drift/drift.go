@@ -0,0 +1,533 @@
+// Package drift watches the final responses for silent changes in what the
+// pipeline says — a provider updating its model behind the same name, a
+// prompt edit with wider effects than intended. Each sampled response is
+// embedded, and the recent responses are compared with a baseline set; when
+// they move too far from it, an alert is logged, stored and, optionally,
+// posted to a webhook.
+package drift
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+	"my-agents/storage"
+)
+
+// Defaults for a config's unset fields.
+const (
+	DefaultBaselineSize        = 50
+	DefaultWindow              = 50
+	DefaultMaxCentroidDistance = 0.05
+	DefaultMaxShift            = 2.0
+)
+
+// Config is the [drift] section of agentflow.toml:
+//
+//	[drift]
+//	enabled = true
+//	provider = "embeddings"
+//	sample_rate = 0.2
+//	webhook_url = "https://hooks.example.com/drift"
+type Config struct {
+	Enabled bool `toml:"enabled"`
+	// Provider is the [providers.<name>] embedding the responses; empty uses
+	// the default provider.
+	Provider string `toml:"provider"`
+	// SampleRate is the fraction of completed runs embedded (default 1).
+	SampleRate float64 `toml:"sample_rate"`
+	// BaselineSize is how many responses, from the first after the baseline
+	// was last reset, make up the baseline (default 50); Window is how many
+	// recent responses are compared with it (default 50).
+	BaselineSize int `toml:"baseline_size"`
+	Window       int `toml:"window"`
+	// MaxCentroidDistance is the cosine distance between the mean embedding
+	// of the window and of the baseline beyond which responses drifted
+	// (default 0.05).
+	MaxCentroidDistance float64 `toml:"max_centroid_distance"`
+	// MaxShift is how far, in standard deviations of the baseline, the
+	// window's similarity to the baseline may fall below the baseline's own
+	// (default 2).
+	MaxShift float64 `toml:"max_shift"`
+	// WebhookURL receives each alert as a JSON POST.
+	WebhookURL string `toml:"webhook_url"`
+	Path       string `toml:"path"` // database file (default the [storage] path)
+}
+
+// Measure compares the window with the baseline.
+type Measure struct {
+	// CentroidDistance is the cosine distance between the mean embeddings.
+	CentroidDistance float64 `json:"centroid_distance"`
+	// Shift is how far the window's mean similarity to the baseline's mean
+	// embedding is below the baseline responses' own, in standard deviations
+	// of theirs.
+	Shift float64 `json:"shift"`
+}
+
+// Status is the monitor's current picture.
+type Status struct {
+	BaselineSince   time.Time `json:"baseline_since,omitzero"` // zero for the first baseline
+	BaselineSamples int       `json:"baseline_samples"`
+	BaselineSize    int       `json:"baseline_size"`
+	WindowSamples   int       `json:"window_samples"`
+	Window          int       `json:"window"`
+	// Measure is set once the baseline and the window are full.
+	Measure *Measure `json:"measure,omitempty"`
+	Drifted bool     `json:"drifted"`
+}
+
+// Alert records responses drifting from the baseline, or returning to it.
+type Alert struct {
+	At       time.Time `json:"at"`
+	Drifted  bool      `json:"drifted"` // false when the window came back
+	Measure  Measure   `json:"measure"`
+	LastRun  string    `json:"last_run"` // the run whose response tipped it
+	Baseline time.Time `json:"baseline_since,omitzero"`
+}
+
+// Monitor embeds sampled final responses and checks them for drift.
+type Monitor struct {
+	cfg Config
+	db  *sql.DB
+	llm core.ModelProvider
+
+	samples chan sample
+	done    chan struct{}
+	stopped sync.WaitGroup
+
+	mu       sync.Mutex
+	since    time.Time   // baseline start
+	baseline [][]float64 // up to BaselineSize, oldest first
+	ref      reference   // derived from a full baseline
+	window   [][]float64 // up to Window, oldest first
+	drifted  bool
+}
+
+type sample struct {
+	runID, response string
+}
+
+// reference is what the window is measured against.
+type reference struct {
+	centroid   []float64
+	mean, std  float64 // of the baseline samples' similarity to centroid
+	calculated bool
+}
+
+// Open opens the configured monitor, embedding with llm, and starts
+// embedding completed runs in the background until Close.
+func Open(cfg Config, llm core.ModelProvider) (*Monitor, error) {
+	db, err := storage.Open(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	return New(cfg, db, llm)
+}
+
+// New creates a monitor in db, creating its tables if needed.
+func New(cfg Config, db *sql.DB, llm core.ModelProvider) (*Monitor, error) {
+	err := storage.Migrate(db,
+		`CREATE TABLE IF NOT EXISTS drift_samples (
+			run_id    TEXT PRIMARY KEY,
+			at        INTEGER NOT NULL,
+			embedding TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS drift_samples_at ON drift_samples (at)`,
+		`CREATE TABLE IF NOT EXISTS drift_baselines (since INTEGER NOT NULL)`,
+		`CREATE TABLE IF NOT EXISTS drift_alerts (
+			at       INTEGER NOT NULL,
+			drifted  INTEGER NOT NULL,
+			measure  TEXT NOT NULL,
+			last_run TEXT NOT NULL,
+			baseline INTEGER NOT NULL
+		)`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = 1
+	}
+	if cfg.BaselineSize <= 1 {
+		cfg.BaselineSize = DefaultBaselineSize
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = DefaultWindow
+	}
+	if cfg.MaxCentroidDistance <= 0 {
+		cfg.MaxCentroidDistance = DefaultMaxCentroidDistance
+	}
+	if cfg.MaxShift <= 0 {
+		cfg.MaxShift = DefaultMaxShift
+	}
+	m := &Monitor{cfg: cfg, db: db, llm: llm, samples: make(chan sample, 64), done: make(chan struct{})}
+	if err := m.load(context.Background()); err != nil {
+		return nil, err
+	}
+	m.stopped.Add(1)
+	go m.embed()
+	return m, nil
+}
+
+// load restores the baseline, the window and whether they had drifted.
+func (m *Monitor) load(ctx context.Context) error {
+	var since sql.NullInt64
+	if err := m.db.QueryRowContext(ctx, `SELECT MAX(since) FROM drift_baselines`).Scan(&since); err != nil {
+		return err
+	}
+	if since.Valid {
+		m.since = time.Unix(0, since.Int64)
+	}
+	var err error
+	if m.baseline, err = m.embeddings(ctx, `SELECT embedding FROM drift_samples WHERE at >= ? ORDER BY at LIMIT ?`, nanos(m.since), m.cfg.BaselineSize); err != nil {
+		return err
+	}
+	m.ref = calculate(m.baseline, m.cfg.BaselineSize)
+	if m.ref.calculated {
+		// The window is made of the responses after the baseline's
+		var last int64
+		if err := m.db.QueryRowContext(ctx, `SELECT at FROM drift_samples WHERE at >= ? ORDER BY at LIMIT 1 OFFSET ?`, nanos(m.since), m.cfg.BaselineSize-1).Scan(&last); err != nil {
+			return err
+		}
+		if m.window, err = m.embeddings(ctx, `SELECT embedding FROM (SELECT embedding, at FROM drift_samples WHERE at > ? ORDER BY at DESC LIMIT ?) ORDER BY at`, last, m.cfg.Window); err != nil {
+			return err
+		}
+	}
+	var drifted sql.NullBool
+	err = m.db.QueryRowContext(ctx, `SELECT drifted FROM drift_alerts WHERE baseline = ? ORDER BY at DESC LIMIT 1`, nanos(m.since)).Scan(&drifted)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	m.drifted = drifted.Bool
+	return nil
+}
+
+func (m *Monitor) embeddings(ctx context.Context, query string, args ...any) ([][]float64, error) {
+	rows, err := m.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out [][]float64
+	for rows.Next() {
+		var raw string
+		if err := rows.Scan(&raw); err != nil {
+			return nil, err
+		}
+		var v []float64
+		if err := json.Unmarshal([]byte(raw), &v); err != nil {
+			return nil, fmt.Errorf("failed to decode drift sample: %w", err)
+		}
+		out = append(out, v)
+	}
+	return out, rows.Err()
+}
+
+// Register samples the final responses of runs completing on runner. Re-runs
+// are left out: they are experiments, not what callers were served.
+func (m *Monitor) Register(runner core.Runner) error {
+	return runner.RegisterCallback(core.HookAfterEventHandling, "drift", func(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+		if args.Error != nil || args.State == nil || args.Event == nil {
+			return args.State, nil
+		}
+		if route, _ := args.State.GetMeta(core.RouteMetadataKey); route != "" {
+			return args.State, nil
+		}
+		if rerun, _ := args.Event.GetMetadataValue(history.RerunOfKey); rerun != "" {
+			return args.State, nil
+		}
+		final, _ := args.State.Get("final_response")
+		response, _ := final.(string)
+		if response == "" || rand.Float64() >= m.cfg.SampleRate {
+			return args.State, nil
+		}
+		runID, _ := args.Event.GetMetadataValue(history.RunIDKey)
+		// Embedding happens off the runner; a backlog drops samples rather
+		// than holding up runs
+		select {
+		case m.samples <- sample{runID: runID, response: response}:
+		default:
+			core.Logger().Warn().Str("run_id", runID).Msg("Drift monitor is behind; skipping response")
+		}
+		return args.State, nil
+	})
+}
+
+// closeTimeout bounds how long Close spends on samples still queued.
+const closeTimeout = 10 * time.Second
+
+func (m *Monitor) embed() {
+	defer m.stopped.Done()
+	observe := func(ctx context.Context, s sample) {
+		ctx, cancel := context.WithTimeout(ctx, time.Minute)
+		defer cancel()
+		if err := m.Observe(ctx, s.runID, s.response); err != nil {
+			core.Logger().Warn().Str("run_id", s.runID).Err(err).Msg("Failed to check response for drift")
+		}
+	}
+	for {
+		select {
+		case s := <-m.samples:
+			observe(context.Background(), s)
+		case <-m.done:
+			// Short-lived processes close right after their runs
+			ctx, cancel := context.WithTimeout(context.Background(), closeTimeout)
+			defer cancel()
+			for ctx.Err() == nil {
+				select {
+				case s := <-m.samples:
+					observe(ctx, s)
+				default:
+					return
+				}
+			}
+			return
+		}
+	}
+}
+
+// Observe embeds the final response of run runID and checks the window for
+// drift.
+func (m *Monitor) Observe(ctx context.Context, runID, response string) error {
+	vectors, err := m.llm.Embeddings(ctx, []string{response})
+	if err != nil {
+		return fmt.Errorf("failed to embed response: %w", err)
+	}
+	if len(vectors) != 1 || len(vectors[0]) == 0 {
+		return errors.New("provider returned no embedding")
+	}
+	v := vectors[0]
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	if _, err := m.db.ExecContext(ctx, `INSERT INTO drift_samples (run_id, at, embedding) VALUES (?, ?, ?) ON CONFLICT (run_id) DO NOTHING`,
+		runID, now.UnixNano(), string(raw)); err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	if !m.ref.calculated {
+		m.baseline = append(m.baseline, v)
+		m.ref = calculate(m.baseline, m.cfg.BaselineSize)
+		m.mu.Unlock()
+		return nil
+	}
+	m.window = append(m.window, v)
+	if len(m.window) > m.cfg.Window {
+		m.window = m.window[len(m.window)-m.cfg.Window:]
+	}
+	status := m.status()
+	changed := status.Measure != nil && status.Drifted != m.drifted
+	if changed {
+		m.drifted = status.Drifted
+	}
+	since := m.since
+	m.mu.Unlock()
+
+	if !changed {
+		return nil
+	}
+	alert := Alert{At: now, Drifted: status.Drifted, Measure: *status.Measure, LastRun: runID, Baseline: since}
+	return m.raise(ctx, alert)
+}
+
+// raise stores, logs and posts an alert.
+func (m *Monitor) raise(ctx context.Context, alert Alert) error {
+	measure, err := json.Marshal(alert.Measure)
+	if err != nil {
+		return err
+	}
+	if _, err := m.db.ExecContext(ctx, `INSERT INTO drift_alerts (at, drifted, measure, last_run, baseline) VALUES (?, ?, ?, ?, ?)`,
+		alert.At.UnixNano(), alert.Drifted, string(measure), alert.LastRun, nanos(alert.Baseline)); err != nil {
+		return err
+	}
+	log := core.Logger().Info()
+	msg := "Responses are back in line with the drift baseline"
+	if alert.Drifted {
+		log = core.Logger().Warn()
+		msg = "Responses drifted from the baseline"
+	}
+	log.Float64("centroid_distance", alert.Measure.CentroidDistance).Float64("shift", alert.Measure.Shift).Str("run_id", alert.LastRun).Msg(msg)
+	if m.cfg.WebhookURL == "" {
+		return nil
+	}
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, m.cfg.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post drift alert: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("drift webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// Status reports the baseline, the window and how they compare.
+func (m *Monitor) Status() Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.status()
+}
+
+// status is Status for callers holding m.mu.
+func (m *Monitor) status() Status {
+	s := Status{
+		BaselineSince:   m.since,
+		BaselineSamples: len(m.baseline),
+		BaselineSize:    m.cfg.BaselineSize,
+		WindowSamples:   len(m.window),
+		Window:          m.cfg.Window,
+	}
+	if !m.ref.calculated || len(m.window) < m.cfg.Window {
+		return s
+	}
+	measure := m.ref.measure(m.window)
+	s.Measure = &measure
+	s.Drifted = measure.CentroidDistance > m.cfg.MaxCentroidDistance || measure.Shift > m.cfg.MaxShift
+	return s
+}
+
+// Alerts returns the most recent alerts, newest first.
+func (m *Monitor) Alerts(ctx context.Context, limit int) ([]Alert, error) {
+	rows, err := m.db.QueryContext(ctx, `SELECT at, drifted, measure, last_run, baseline FROM drift_alerts ORDER BY at DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []Alert
+	for rows.Next() {
+		var a Alert
+		var at, baseline int64
+		var measure string
+		if err := rows.Scan(&at, &a.Drifted, &measure, &a.LastRun, &baseline); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(measure), &a.Measure); err != nil {
+			return nil, fmt.Errorf("failed to decode drift alert: %w", err)
+		}
+		a.At = time.Unix(0, at)
+		if baseline != 0 {
+			a.Baseline = time.Unix(0, baseline)
+		}
+		out = append(out, a)
+	}
+	return out, rows.Err()
+}
+
+// Rebaseline accepts the current responses as normal: the next BaselineSize
+// responses become the baseline. Use it after a deliberate change in what
+// the pipeline answers.
+func (m *Monitor) Rebaseline(ctx context.Context) error {
+	now := time.Now()
+	if _, err := m.db.ExecContext(ctx, `INSERT INTO drift_baselines (since) VALUES (?)`, now.UnixNano()); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.since, m.baseline, m.ref, m.window, m.drifted = now, nil, reference{}, nil, false
+	m.mu.Unlock()
+	return nil
+}
+
+// Close stops embedding once the queued samples are, or after a few
+// seconds.
+func (m *Monitor) Close() {
+	close(m.done)
+	m.stopped.Wait()
+}
+
+// calculate derives the reference from a baseline of size samples, once it
+// is full.
+func calculate(baseline [][]float64, size int) reference {
+	if len(baseline) < size {
+		return reference{}
+	}
+	ref := reference{centroid: centroid(baseline), calculated: true}
+	sims := make([]float64, len(baseline))
+	for i, v := range baseline {
+		sims[i] = cosine(v, ref.centroid)
+	}
+	ref.mean, ref.std = meanStd(sims)
+	return ref
+}
+
+func (ref reference) measure(window [][]float64) Measure {
+	m := Measure{CentroidDistance: 1 - cosine(centroid(window), ref.centroid)}
+	sims := make([]float64, len(window))
+	for i, v := range window {
+		sims[i] = cosine(v, ref.centroid)
+	}
+	mean, _ := meanStd(sims)
+	if ref.std > 0 {
+		m.Shift = (ref.mean - mean) / ref.std
+	}
+	return m
+}
+
+// nanos is t in Unix nanoseconds, 0 for the zero time.
+func nanos(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func centroid(vectors [][]float64) []float64 {
+	c := make([]float64, len(vectors[0]))
+	for _, v := range vectors {
+		for i := range min(len(c), len(v)) {
+			c[i] += v[i]
+		}
+	}
+	for i := range c {
+		c[i] /= float64(len(vectors))
+	}
+	return c
+}
+
+func cosine(a, b []float64) float64 {
+	var dot, na, nb float64
+	for i := range min(len(a), len(b)) {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+func meanStd(xs []float64) (float64, float64) {
+	var sum float64
+	for _, x := range xs {
+		sum += x
+	}
+	mean := sum / float64(len(xs))
+	var sq float64
+	for _, x := range xs {
+		sq += (x - mean) * (x - mean)
+	}
+	return mean, math.Sqrt(sq / float64(len(xs)))
+}
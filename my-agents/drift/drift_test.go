@@ -0,0 +1,283 @@
+package drift
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+	"my-agents/storage"
+)
+
+// embedder embeds each text as the vector it names.
+type embedder struct {
+	core.ModelProvider
+	vectors map[string][]float64
+}
+
+func (e *embedder) Embeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	v, ok := e.vectors[texts[0]]
+	if !ok {
+		return nil, errors.New("no vector for " + texts[0])
+	}
+	return [][]float64{v}, nil
+}
+
+var vectors = map[string][]float64{
+	"east":      {1, 0},
+	"northeast": {0.8, 0.6},
+	"north":     {0, 1},
+}
+
+func open(t *testing.T, cfg Config) *Monitor {
+	t.Helper()
+	if cfg.Path == "" {
+		cfg.Path = filepath.Join(t.TempDir(), "agentflow.db")
+	}
+	m, err := Open(cfg, &embedder{vectors: vectors})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func observe(t *testing.T, m *Monitor, runID, response string) {
+	t.Helper()
+	if err := m.Observe(context.Background(), runID, response); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	m := open(t, Config{})
+	defer m.Close()
+	st := m.Status()
+	if st.BaselineSize != DefaultBaselineSize || st.Window != DefaultWindow || m.cfg.SampleRate != 1 ||
+		m.cfg.MaxCentroidDistance != DefaultMaxCentroidDistance || m.cfg.MaxShift != DefaultMaxShift {
+		t.Errorf("defaults = %+v", m.cfg)
+	}
+	if st.Measure != nil || st.Drifted || !st.BaselineSince.IsZero() {
+		t.Errorf("empty status = %+v", st)
+	}
+}
+
+func TestObserve(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		posted []Alert
+	)
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Alert
+		json.NewDecoder(r.Body).Decode(&a)
+		mu.Lock()
+		posted = append(posted, a)
+		mu.Unlock()
+	}))
+	defer hook.Close()
+
+	path := filepath.Join(t.TempDir(), "agentflow.db")
+	// Only the centroid distance decides here
+	cfg := Config{Path: path, BaselineSize: 2, Window: 2, MaxShift: 1e9, WebhookURL: hook.URL}
+	m := open(t, cfg)
+	observe(t, m, "run-1", "east")
+	observe(t, m, "run-2", "northeast")
+	if st := m.Status(); st.BaselineSamples != 2 || st.WindowSamples != 0 {
+		t.Errorf("after the baseline: %+v", st)
+	}
+	observe(t, m, "run-3", "east")
+	observe(t, m, "run-4", "northeast")
+	if st := m.Status(); st.Measure == nil || st.Measure.CentroidDistance > 1e-9 || st.Drifted {
+		t.Errorf("window like the baseline: %+v", st)
+	}
+
+	observe(t, m, "run-5", "north")
+	if st := m.Status(); !st.Drifted || st.Measure.CentroidDistance < DefaultMaxCentroidDistance {
+		t.Errorf("window going north: %+v", st)
+	}
+	// Still off, so no second alert
+	observe(t, m, "run-6", "north")
+	observe(t, m, "run-7", "east")
+	observe(t, m, "run-8", "northeast")
+	if m.Status().Drifted {
+		t.Error("still drifted after coming back")
+	}
+
+	alerts, err := m.Alerts(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(alerts) != 2 || alerts[0].Drifted || alerts[0].LastRun != "run-8" || !alerts[1].Drifted || alerts[1].LastRun != "run-5" {
+		t.Fatalf("alerts = %+v", alerts)
+	}
+	if len(posted) != 2 || !posted[0].Drifted || posted[1].Drifted {
+		t.Errorf("posted = %+v", posted)
+	}
+
+	// A reopened monitor picks up where this one stopped
+	m.Close()
+	reopened := open(t, cfg)
+	defer reopened.Close()
+	if got := reopened.Status(); got.BaselineSamples != 2 || got.WindowSamples != 2 || got.Drifted || got.Measure.CentroidDistance > 1e-9 {
+		t.Errorf("reopened status = %+v", got)
+	}
+
+	if err := reopened.Observe(context.Background(), "run-9", "west"); err == nil {
+		t.Error("failed embedding not reported")
+	}
+}
+
+func TestObserveWebhookFails(t *testing.T) {
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer hook.Close()
+	m := open(t, Config{BaselineSize: 2, Window: 1, MaxShift: 1e9, WebhookURL: hook.URL})
+	defer m.Close()
+	observe(t, m, "run-1", "east")
+	observe(t, m, "run-2", "east")
+	if err := m.Observe(context.Background(), "run-3", "north"); err == nil {
+		t.Error("webhook failure not reported")
+	}
+	// The alert is stored all the same
+	if alerts, _ := m.Alerts(context.Background(), 10); len(alerts) != 1 {
+		t.Errorf("alerts = %+v", alerts)
+	}
+}
+
+func TestRebaseline(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agentflow.db")
+	cfg := Config{Path: path, BaselineSize: 2, Window: 1, MaxShift: 1e9}
+	m := open(t, cfg)
+	defer m.Close()
+	observe(t, m, "run-1", "east")
+	observe(t, m, "run-2", "east")
+	observe(t, m, "run-3", "north")
+	if !m.Status().Drifted {
+		t.Fatal("not drifted")
+	}
+	if err := m.Rebaseline(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	st := m.Status()
+	if st.BaselineSince.IsZero() || st.BaselineSamples != 0 || st.WindowSamples != 0 || st.Drifted {
+		t.Errorf("after rebaselining: %+v", st)
+	}
+	// The new normal is north
+	observe(t, m, "run-4", "north")
+	observe(t, m, "run-5", "north")
+	observe(t, m, "run-6", "north")
+	if st := m.Status(); st.Drifted || st.Measure == nil {
+		t.Errorf("north after rebaselining: %+v", st)
+	}
+
+	reopened := open(t, cfg)
+	defer reopened.Close()
+	if got := reopened.Status(); !got.BaselineSince.Equal(st.BaselineSince) || got.BaselineSamples != 2 || got.WindowSamples != 1 || got.Drifted {
+		t.Errorf("reopened status = %+v", got)
+	}
+}
+
+type runner struct {
+	core.Runner
+	callback core.CallbackFunc
+}
+
+func (r *runner) RegisterCallback(hook core.HookPoint, name string, cb core.CallbackFunc) error {
+	r.callback = cb
+	return nil
+}
+
+func TestRegister(t *testing.T) {
+	db, err := storage.Open(filepath.Join(t.TempDir(), "agentflow.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := New(Config{}, db, &embedder{vectors: vectors})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &runner{}
+	if err := m.Register(r); err != nil {
+		t.Fatal(err)
+	}
+	finish := func(runID, response string, route, rerunOf string, err error) {
+		state := core.NewState()
+		state.Set("final_response", response)
+		if route != "" {
+			state.SetMeta(core.RouteMetadataKey, route)
+		}
+		meta := map[string]string{history.RunIDKey: runID}
+		if rerunOf != "" {
+			meta[history.RerunOfKey] = rerunOf
+		}
+		r.callback(context.Background(), core.CallbackArgs{Event: core.NewEvent("formatter", nil, meta), State: state, Error: err})
+	}
+	finish("run-1", "east", "", "", nil)
+	finish("run-2", "east", "formatter", "", nil)
+	finish("run-3", "east", "", "run-1", nil)
+	finish("run-4", "", "", "", nil)
+	finish("run-5", "east", "", "", errors.New("failed"))
+	finish("run-6", "north", "", "", nil)
+	m.Close()
+
+	var runs []string
+	rows, err := db.Query(`SELECT run_id FROM drift_samples ORDER BY at`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		runs = append(runs, id)
+	}
+	if len(runs) != 2 || runs[0] != "run-1" || runs[1] != "run-6" {
+		t.Errorf("sampled %v", runs)
+	}
+}
+
+func TestMeasure(t *testing.T) {
+	baseline := [][]float64{{1, 0}, {0.8, 0.6}}
+	if ref := calculate(baseline, 3); ref.calculated {
+		t.Error("reference from a partial baseline")
+	}
+	ref := calculate(baseline, 2)
+	if !ref.calculated || ref.std <= 0 {
+		t.Fatalf("reference = %+v", ref)
+	}
+	if m := ref.measure(baseline); math.Abs(m.CentroidDistance) > 1e-9 || math.Abs(m.Shift) > 1e-9 {
+		t.Errorf("baseline against itself = %+v", m)
+	}
+	// Responses all close to the centroid are more alike than the baseline
+	if m := ref.measure([][]float64{{0.9, 0.3}}); m.Shift >= 0 {
+		t.Errorf("window at the centroid = %+v", m)
+	}
+	if m := ref.measure([][]float64{{0, 1}}); m.Shift <= DefaultMaxShift || m.CentroidDistance <= DefaultMaxCentroidDistance {
+		t.Errorf("window off to the side = %+v", m)
+	}
+}
+
+func TestCosine(t *testing.T) {
+	tests := []struct {
+		a, b []float64
+		want float64
+	}{
+		{[]float64{1, 0}, []float64{2, 0}, 1},
+		{[]float64{1, 0}, []float64{0, 1}, 0},
+		{[]float64{1, 0}, []float64{-1, 0}, -1},
+		{[]float64{0, 0}, []float64{1, 0}, 0},
+	}
+	for _, tt := range tests {
+		if got := cosine(tt.a, tt.b); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("cosine(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package drift
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+// Handler serves the monitor, for mounting on the admin API under
+// "/admin/drift":
+//
+//	GET  /admin/drift[?alerts=20]     status and the most recent alerts
+//	POST /admin/drift/rebaseline      accept the current responses as the new baseline
+func (m *Monitor) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/drift", func(w http.ResponseWriter, r *http.Request) {
+		limit := 20
+		if v := r.URL.Query().Get("alerts"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("bad alerts %q", v))
+				return
+			}
+			limit = n
+		}
+		alerts, err := m.Alerts(r.Context(), limit)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"status": m.Status(), "alerts": alerts})
+	})
+	mux.HandleFunc("POST /admin/drift/rebaseline", func(w http.ResponseWriter, r *http.Request) {
+		if err := m.Rebaseline(r.Context()); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, m.Status())
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
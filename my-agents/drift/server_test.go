@@ -0,0 +1,52 @@
+package drift
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	m := open(t, Config{BaselineSize: 2, Window: 1, MaxShift: 1e9})
+	defer m.Close()
+	observe(t, m, "run-1", "east")
+	observe(t, m, "run-2", "east")
+	observe(t, m, "run-3", "north")
+	h := m.Handler()
+	do := func(method, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	w := do("GET", "/admin/drift")
+	var resp struct {
+		Status Status  `json:"status"`
+		Alerts []Alert `json:"alerts"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK || !resp.Status.Drifted || len(resp.Alerts) != 1 || resp.Alerts[0].LastRun != "run-3" {
+		t.Errorf("status: %d %+v", w.Code, resp)
+	}
+	w = do("GET", "/admin/drift?alerts=0")
+	resp.Alerts = nil
+	json.NewDecoder(w.Body).Decode(&resp)
+	if w.Code != http.StatusOK || len(resp.Alerts) != 0 {
+		t.Errorf("no alerts: %d %+v", w.Code, resp.Alerts)
+	}
+	for _, q := range []string{"many", "-1"} {
+		if w := do("GET", "/admin/drift?alerts="+q); w.Code != http.StatusBadRequest {
+			t.Errorf("alerts=%s: status %d", q, w.Code)
+		}
+	}
+
+	w = do("POST", "/admin/drift/rebaseline")
+	var st Status
+	json.NewDecoder(w.Body).Decode(&st)
+	if w.Code != http.StatusOK || st.Drifted || st.BaselineSamples != 0 || st.BaselineSince.IsZero() {
+		t.Errorf("rebaseline: %d %+v", w.Code, st)
+	}
+}
@@ -0,0 +1,147 @@
+// Package eventbus carries agent events between processes, so the agents of
+// one workflow can run in several processes and each agent can be scaled
+// out on its own. A process runs some of the agents: it consumes their events
+// from the bus and forwards the events routed to any other agent to the
+// processes that run it. Processes consuming the same agent share its
+// events, each event going to one of them.
+//
+// The bus backends are in-memory (one process, for development), NATS,
+// Redis Streams (Redis 6.2 or later) and Kafka, reached through a
+// Confluent-compatible REST proxy.
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Backends.
+const (
+	BackendMemory = "memory"
+	BackendNATS   = "nats"
+	BackendRedis  = "redis"
+	BackendKafka  = "kafka"
+)
+
+// Defaults.
+const (
+	DefaultTopicPrefix = "agentflow.events"
+	DefaultGroup       = "agentflow"
+)
+
+// ErrNoSubscriber is returned by the in-memory bus for an event no one
+// consumes.
+var ErrNoSubscriber = errors.New("no subscriber for topic")
+
+// Config is the [event_bus] section of agentflow.toml:
+//
+//	[event_bus]
+//	backend = "nats"   # memory, nats, redis or kafka; empty keeps events in-process
+//	url = "nats://localhost:4222"
+//	agents = ["processor"]
+//
+// The URL is nats://[user:pass@]host:port (tls:// to require TLS, which
+// nats:// only uses when the server requires it) for NATS,
+// redis://[:pass@]host:port[/db] (rediss:// for TLS) for Redis and the REST
+// proxy's http(s):// base URL for Kafka.
+type Config struct {
+	Backend string `toml:"backend"`
+	URL     string `toml:"url"`
+	// TopicPrefix names each agent's topic, "<prefix>.<agent>" (default
+	// DefaultTopicPrefix).
+	TopicPrefix string `toml:"topic_prefix"`
+	// Group is the consumer group processes running the same agent share
+	// its events in (default DefaultGroup).
+	Group string `toml:"group"`
+	// Agents are the agents this process runs; events for the others are
+	// forwarded over the bus. Empty runs them all.
+	Agents []string `toml:"agents"`
+}
+
+// Handler handles an event consumed from the bus. An event whose handler
+// fails is logged; Redis delivers it again a minute later and the other
+// backends drop it.
+type Handler func(event core.Event) error
+
+// Bus publishes events to topics and delivers them to consumer groups.
+type Bus interface {
+	// Publish sends event to topic.
+	Publish(ctx context.Context, topic string, event core.Event) error
+	// Subscribe hands topic's events to handle, one at a time, until ctx is
+	// done. Subscribers in the same group share the events; each group
+	// gets every event.
+	Subscribe(ctx context.Context, topic, group string, handle Handler) error
+	// Close releases the bus's connections.
+	Close() error
+}
+
+// Open connects to the backend cfg names.
+func Open(cfg Config) (Bus, error) {
+	if cfg.Backend == BackendMemory {
+		return NewMemory(), nil
+	}
+	u, err := url.Parse(cfg.URL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("event bus: invalid url %q", cfg.URL)
+	}
+	switch cfg.Backend {
+	case BackendNATS:
+		return newNATS(u)
+	case BackendRedis:
+		return newRedis(u)
+	case BackendKafka:
+		return newKafka(u), nil
+	default:
+		return nil, fmt.Errorf("unknown event bus backend %q", cfg.Backend)
+	}
+}
+
+// envelope is an event as it travels on the bus.
+type envelope struct {
+	ID       string            `json:"id"`
+	Target   string            `json:"target"`
+	Source   string            `json:"source,omitempty"`
+	Data     core.EventData    `json:"data"`
+	Metadata map[string]string `json:"metadata"`
+}
+
+func encode(event core.Event) ([]byte, error) {
+	data, err := json.Marshal(envelope{
+		ID:       event.GetID(),
+		Target:   event.GetTargetAgentID(),
+		Source:   event.GetSourceAgentID(),
+		Data:     event.GetData(),
+		Metadata: event.GetMetadata(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode event %s: %w", event.GetID(), err)
+	}
+	return data, nil
+}
+
+func decode(data []byte) (core.Event, error) {
+	var env envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("failed to decode event: %w", err)
+	}
+	event := core.NewEvent(env.Target, env.Data, env.Metadata)
+	event.SetID(env.ID)
+	event.SetSourceAgentID(env.Source)
+	return event, nil
+}
+
+// consumerName identifies this process to the backends that track
+// consumers by name.
+func consumerName() string {
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "agentflow"
+	}
+	return fmt.Sprintf("%s-%d", host, os.Getpid())
+}
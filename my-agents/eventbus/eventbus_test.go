@@ -0,0 +1,63 @@
+package eventbus
+
+import (
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+func TestOpen(t *testing.T) {
+	if bus, err := Open(Config{Backend: BackendMemory}); err != nil {
+		t.Errorf("memory: %v", err)
+	} else if _, ok := bus.(*Memory); !ok {
+		t.Errorf("memory backend opened %T", bus)
+	}
+	for _, cfg := range []Config{
+		{Backend: BackendNATS, URL: "nats://localhost:4222"},
+		{Backend: BackendNATS, URL: "tls://localhost:4222"},
+		{Backend: BackendRedis, URL: "redis://localhost:6379/0"},
+		{Backend: BackendKafka, URL: "http://localhost:8082"},
+	} {
+		bus, err := Open(cfg)
+		if err != nil {
+			t.Errorf("%s: %v", cfg.URL, err)
+			continue
+		}
+		bus.Close()
+	}
+	for _, cfg := range []Config{
+		{Backend: BackendNATS, URL: "localhost:4222"},
+		{Backend: BackendNATS, URL: "http://localhost:4222"},
+		{Backend: BackendRedis, URL: "nats://localhost:6379"},
+		{Backend: "rabbitmq", URL: "amqp://localhost"},
+	} {
+		if _, err := Open(cfg); err == nil {
+			t.Errorf("%s %s accepted", cfg.Backend, cfg.URL)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	in := core.NewEvent("writer", core.EventData{"topic": "go", "score": 0.5}, map[string]string{"run_id": "run-1"})
+	in.SetSourceAgentID("planner")
+	data, err := encode(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := decode(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.GetID() != in.GetID() || out.GetTargetAgentID() != "writer" || out.GetSourceAgentID() != "planner" {
+		t.Errorf("decoded %s → %s from %s", out.GetID(), out.GetTargetAgentID(), out.GetSourceAgentID())
+	}
+	if out.GetData()["topic"] != "go" || out.GetData()["score"] != 0.5 {
+		t.Errorf("data = %v", out.GetData())
+	}
+	if id, _ := out.GetMetadataValue("run_id"); id != "run-1" {
+		t.Errorf("metadata = %v", out.GetMetadata())
+	}
+	if _, err := decode([]byte("not json")); err == nil {
+		t.Error("decoded garbage")
+	}
+}
@@ -0,0 +1,184 @@
+package eventbus
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+// Content types of the REST proxy's v2 API.
+const (
+	kafkaJSON = "application/vnd.kafka.json.v2+json"
+	kafkaV2   = "application/vnd.kafka.v2+json"
+)
+
+// kafkaBus reaches Kafka through a Confluent-compatible REST proxy (v2
+// API), so no Kafka client is needed. Events are keyed by run ID, keeping a
+// run's events on one partition, in order. Offsets are committed as events
+// are handled.
+type kafkaBus struct {
+	base   string
+	client *http.Client
+}
+
+func newKafka(u *url.URL) *kafkaBus {
+	return &kafkaBus{
+		base:   strings.TrimRight(u.String(), "/"),
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// call sends body as JSON, when not nil, and decodes the response into out,
+// when not nil.
+func (k *kafkaBus) call(ctx context.Context, method, target, contentType string, body, out any) error {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", contentType)
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("kafka: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("kafka: %s %s: %s: %s", method, target, resp.Status, bytes.TrimSpace(msg))
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func (k *kafkaBus) Publish(ctx context.Context, topic string, event core.Event) error {
+	payload, err := encode(event)
+	if err != nil {
+		return err
+	}
+	key, _ := event.GetMetadataValue(history.RunIDKey)
+	if key == "" {
+		key = event.GetID()
+	}
+	body := map[string]any{"records": []map[string]any{{"key": key, "value": json.RawMessage(payload)}}}
+	var resp struct {
+		Offsets []struct {
+			Error string `json:"error"`
+		} `json:"offsets"`
+	}
+	if err := k.call(ctx, http.MethodPost, k.base+"/topics/"+url.PathEscape(topic), kafkaJSON, body, &resp); err != nil {
+		return err
+	}
+	if len(resp.Offsets) > 0 && resp.Offsets[0].Error != "" {
+		return fmt.Errorf("kafka: %s", resp.Offsets[0].Error)
+	}
+	return nil
+}
+
+func (k *kafkaBus) Subscribe(ctx context.Context, topic, group string, handle Handler) error {
+	backoff := time.Second
+	for {
+		err := k.consume(ctx, topic, group, handle)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		core.Logger().Warn().Str("topic", topic).Err(err).Dur("retry_in", backoff).Msg("Kafka consumer lost; reconnecting")
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+// consume creates a consumer instance in group on the proxy and polls it
+// until a request fails or ctx is done, then deletes it.
+func (k *kafkaBus) consume(ctx context.Context, topic, group string, handle Handler) error {
+	// Instance names must be unique in the group, including against one a
+	// crashed process left behind until the proxy expires it
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	var instance struct {
+		BaseURI string `json:"base_uri"`
+	}
+	err := k.call(ctx, http.MethodPost, k.base+"/consumers/"+url.PathEscape(group), kafkaV2, map[string]string{
+		"name":               consumerName() + "-" + hex.EncodeToString(suffix),
+		"format":             "json",
+		"auto.offset.reset":  "earliest",
+		"auto.commit.enable": "false",
+	}, &instance)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		k.call(ctx, http.MethodDelete, instance.BaseURI, kafkaV2, nil, nil)
+	}()
+	if err := k.call(ctx, http.MethodPost, instance.BaseURI+"/subscription", kafkaV2, map[string][]string{"topics": {topic}}, nil); err != nil {
+		return err
+	}
+	for {
+		var records []struct {
+			Topic     string          `json:"topic"`
+			Value     json.RawMessage `json:"value"`
+			Partition int             `json:"partition"`
+			Offset    int64           `json:"offset"`
+		}
+		if err := k.call(ctx, http.MethodGet, instance.BaseURI+"/records?timeout=5000", kafkaJSON, nil, &records); err != nil {
+			return err
+		}
+		if len(records) == 0 {
+			continue
+		}
+		type offset struct {
+			Topic     string `json:"topic"`
+			Partition int    `json:"partition"`
+			Offset    int64  `json:"offset"`
+		}
+		var offsets []offset
+		for _, rec := range records {
+			event, err := decode(rec.Value)
+			if err == nil {
+				err = handle(event)
+			}
+			if err != nil {
+				core.Logger().Error().Str("topic", topic).Int("partition", rec.Partition).Interface("offset", rec.Offset).Err(err).Msg("Event bus handler failed")
+			}
+			offsets = append(offsets, offset{Topic: rec.Topic, Partition: rec.Partition, Offset: rec.Offset})
+		}
+		// The proxy commits the position after each record given
+		if err := k.call(ctx, http.MethodPost, instance.BaseURI+"/offsets", kafkaV2, map[string][]offset{"offsets": offsets}, nil); err != nil {
+			return err
+		}
+	}
+}
+
+func (k *kafkaBus) Close() error {
+	k.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,149 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+// kafkaProxy is a REST proxy holding one topic's records.
+type kafkaProxy struct {
+	*httptest.Server
+	mu        sync.Mutex
+	records   []json.RawMessage
+	keys      []string
+	committed []int64
+	deleted   bool
+}
+
+func newKafkaProxy(t *testing.T) *kafkaProxy {
+	p := &kafkaProxy{}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /topics/{topic}", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Records []struct {
+				Key   string          `json:"key"`
+				Value json.RawMessage `json:"value"`
+			} `json:"records"`
+		}
+		if r.Header.Get("Content-Type") != kafkaJSON || json.NewDecoder(r.Body).Decode(&body) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		p.mu.Lock()
+		for _, rec := range body.Records {
+			p.records = append(p.records, rec.Value)
+			p.keys = append(p.keys, rec.Key)
+		}
+		p.mu.Unlock()
+		io.WriteString(w, `{"offsets": [{"partition": 0, "offset": 0}]}`)
+	})
+	mux.HandleFunc("POST /consumers/{group}", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"base_uri": p.URL + "/consumers/" + r.PathValue("group") + "/instances/1"})
+	})
+	mux.HandleFunc("POST /consumers/{group}/instances/1/subscription", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /consumers/{group}/instances/1/records", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		type record struct {
+			Topic     string          `json:"topic"`
+			Value     json.RawMessage `json:"value"`
+			Partition int             `json:"partition"`
+			Offset    int64           `json:"offset"`
+		}
+		out := []record{}
+		for i, v := range p.records {
+			out = append(out, record{Topic: "agentflow.events.writer", Value: v, Offset: int64(i)})
+		}
+		p.records = nil
+		json.NewEncoder(w).Encode(out)
+	})
+	mux.HandleFunc("POST /consumers/{group}/instances/1/offsets", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Offsets []struct {
+				Offset int64 `json:"offset"`
+			} `json:"offsets"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		p.mu.Lock()
+		for _, o := range body.Offsets {
+			p.committed = append(p.committed, o.Offset)
+		}
+		p.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("DELETE /consumers/{group}/instances/1", func(w http.ResponseWriter, r *http.Request) {
+		p.mu.Lock()
+		p.deleted = true
+		p.mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	p.Server = httptest.NewServer(mux)
+	t.Cleanup(p.Close)
+	return p
+}
+
+func TestKafka(t *testing.T) {
+	p := newKafkaProxy(t)
+	u, _ := url.Parse(p.URL + "/")
+	bus := newKafka(u)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Events are keyed by run, else by their own ID
+	first := core.NewEvent("writer", nil, map[string]string{history.RunIDKey: "run-1"})
+	second := core.NewEvent("writer", nil, nil)
+	for _, event := range []core.Event{first, second} {
+		if err := bus.Publish(ctx, "agentflow.events.writer", event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p.keys[0] != "run-1" || p.keys[1] != second.GetID() {
+		t.Errorf("keys = %v", p.keys)
+	}
+
+	handled := make(chan core.Event, 2)
+	done := make(chan error)
+	go func() {
+		done <- bus.Subscribe(ctx, "agentflow.events.writer", "agentflow", func(e core.Event) error {
+			handled <- e
+			return nil
+		})
+	}()
+	if a, b := receive(t, handled), receive(t, handled); a.GetID() != first.GetID() || b.GetID() != second.GetID() {
+		t.Errorf("handled %s, %s", a.GetID(), b.GetID())
+	}
+	cancel()
+	<-done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.committed) != 2 || p.committed[1] != 1 {
+		t.Errorf("committed offsets %v", p.committed)
+	}
+	if !p.deleted {
+		t.Error("consumer instance left on the proxy")
+	}
+}
+
+func TestKafkaPublishError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `{"offsets": [{"error": "topic authorization failed"}]}`)
+	}))
+	defer srv.Close()
+	u, _ := url.Parse(srv.URL)
+	err := newKafka(u).Publish(context.Background(), "t", core.NewEvent("writer", nil, nil))
+	if err == nil || err.Error() != "kafka: topic authorization failed" {
+		t.Errorf("err = %v", err)
+	}
+}
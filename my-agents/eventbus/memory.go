@@ -0,0 +1,94 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Memory is a bus within one process.
+type Memory struct {
+	mu     sync.Mutex
+	topics map[string]map[string]*memoryGroup // by topic, then group
+}
+
+type memoryGroup struct {
+	subs []chan core.Event
+	next int // round-robin position
+}
+
+// NewMemory creates an in-memory bus.
+func NewMemory() *Memory {
+	return &Memory{topics: make(map[string]map[string]*memoryGroup)}
+}
+
+func (m *Memory) Publish(ctx context.Context, topic string, event core.Event) error {
+	m.mu.Lock()
+	var targets []chan core.Event
+	for _, g := range m.topics[topic] {
+		targets = append(targets, g.subs[g.next%len(g.subs)])
+		g.next++
+	}
+	m.mu.Unlock()
+	if len(targets) == 0 {
+		return fmt.Errorf("%w %s", ErrNoSubscriber, topic)
+	}
+	for _, ch := range targets {
+		select {
+		case ch <- event:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(ctx context.Context, topic, group string, handle Handler) error {
+	ch := make(chan core.Event, 64)
+	m.mu.Lock()
+	groups := m.topics[topic]
+	if groups == nil {
+		groups = make(map[string]*memoryGroup)
+		m.topics[topic] = groups
+	}
+	g := groups[group]
+	if g == nil {
+		g = &memoryGroup{}
+		groups[group] = g
+	}
+	g.subs = append(g.subs, ch)
+	m.mu.Unlock()
+	defer m.unsubscribe(topic, group, ch)
+
+	for {
+		select {
+		case event := <-ch:
+			if err := handle(event); err != nil {
+				core.Logger().Error().Str("topic", topic).Str("event_id", event.GetID()).Err(err).Msg("Event bus handler failed")
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func (m *Memory) unsubscribe(topic, group string, ch chan core.Event) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	g := m.topics[topic][group]
+	for i, sub := range g.subs {
+		if sub == ch {
+			g.subs = append(g.subs[:i], g.subs[i+1:]...)
+			break
+		}
+	}
+	if len(g.subs) == 0 {
+		delete(m.topics[topic], group)
+	}
+}
+
+func (m *Memory) Close() error {
+	return nil
+}
@@ -0,0 +1,91 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// subscribe consumes topic in group in the background, returning the
+// events it handles once the subscription is in place.
+func subscribe(t *testing.T, ctx context.Context, m *Memory, topic, group string) <-chan core.Event {
+	t.Helper()
+	out := make(chan core.Event, 16)
+	m.mu.Lock()
+	before := 0
+	if g := m.topics[topic][group]; g != nil {
+		before = len(g.subs)
+	}
+	m.mu.Unlock()
+	go m.Subscribe(ctx, topic, group, func(event core.Event) error {
+		out <- event
+		return nil
+	})
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		m.mu.Lock()
+		g := m.topics[topic][group]
+		ready := g != nil && len(g.subs) > before
+		m.mu.Unlock()
+		if ready {
+			return out
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("subscription not in place")
+		}
+	}
+}
+
+func receive(t *testing.T, ch <-chan core.Event) core.Event {
+	t.Helper()
+	select {
+	case event := <-ch:
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("no event delivered")
+		return nil
+	}
+}
+
+func TestMemory(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	m := NewMemory()
+	if err := m.Publish(ctx, "agentflow.events.writer", core.NewEvent("writer", nil, nil)); !errors.Is(err, ErrNoSubscriber) {
+		t.Errorf("publish without subscribers err = %v", err)
+	}
+
+	a1 := subscribe(t, ctx, m, "agentflow.events.writer", "a")
+	a2 := subscribe(t, ctx, m, "agentflow.events.writer", "a")
+	b := subscribe(t, ctx, m, "agentflow.events.writer", "b")
+	for range 2 {
+		if err := m.Publish(ctx, "agentflow.events.writer", core.NewEvent("writer", nil, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Group a's subscribers take turns; group b gets both
+	first, second := receive(t, a1), receive(t, a2)
+	if first.GetID() == second.GetID() {
+		t.Error("one subscriber of a group got both events")
+	}
+	receive(t, b)
+	receive(t, b)
+}
+
+func TestMemoryUnsubscribe(t *testing.T) {
+	m := NewMemory()
+	ctx, cancel := context.WithCancel(context.Background())
+	subscribe(t, ctx, m, "agentflow.events.writer", "a")
+	cancel()
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		err := m.Publish(context.Background(), "agentflow.events.writer", core.NewEvent("writer", nil, nil))
+		if errors.Is(err, ErrNoSubscriber) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("publish after unsubscribing err = %v", err)
+		}
+	}
+}
@@ -0,0 +1,258 @@
+package eventbus
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// natsBus speaks the NATS client protocol. Subscriptions are queue groups,
+// so each event goes to one subscriber of a group. Core NATS delivers at
+// most once: events published while no process runs an agent are lost.
+type natsBus struct {
+	u *url.URL
+
+	mu   sync.Mutex
+	conn *natsConn // for publishing, dialed on first use
+}
+
+func newNATS(u *url.URL) (*natsBus, error) {
+	switch u.Scheme {
+	case "nats", "tls":
+	default:
+		return nil, fmt.Errorf("event bus: nats url scheme must be nats or tls, not %q", u.Scheme)
+	}
+	return &natsBus{u: u}, nil
+}
+
+type natsConn struct {
+	c net.Conn
+	r *bufio.Reader
+}
+
+func (b *natsBus) dial(ctx context.Context) (*natsConn, error) {
+	host := b.u.Host
+	if b.u.Port() == "" {
+		host = net.JoinHostPort(b.u.Hostname(), "4222")
+	}
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, fmt.Errorf("nats: %w", err)
+	}
+	conn := &natsConn{c: c, r: bufio.NewReader(c)}
+	c.SetDeadline(time.Now().Add(10 * time.Second))
+	if err := conn.handshake(b.u); err != nil {
+		conn.c.Close()
+		return nil, err
+	}
+	conn.c.SetDeadline(time.Time{})
+	return conn, nil
+}
+
+// handshake reads the server's INFO, which it sends in plain text, then
+// upgrades to TLS when the URL is tls:// or the server requires it, sends
+// CONNECT and waits for the PONG answering a PING, which follows any error
+// CONNECT caused.
+func (c *natsConn) handshake(u *url.URL) error {
+	line, err := c.line()
+	if err != nil {
+		return err
+	}
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("nats: unexpected greeting %q", line)
+	}
+	var info struct {
+		TLSRequired bool `json:"tls_required"`
+	}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "INFO ")), &info); err != nil {
+		return fmt.Errorf("nats: bad INFO: %w", err)
+	}
+	secure := u.Scheme == "tls" || info.TLSRequired
+	if secure {
+		tc := tls.Client(c.c, &tls.Config{ServerName: u.Hostname()})
+		if err := tc.Handshake(); err != nil {
+			return fmt.Errorf("nats: tls: %w", err)
+		}
+		c.c, c.r = tc, bufio.NewReader(tc)
+	}
+	opts := map[string]any{"verbose": false, "pedantic": false, "tls_required": secure, "name": "agentflow", "lang": "go", "version": "1", "protocol": 1}
+	user := u.User
+	if user != nil {
+		if pass, ok := user.Password(); ok {
+			opts["user"], opts["pass"] = user.Username(), pass
+		} else {
+			opts["auth_token"] = user.Username()
+		}
+	}
+	connect, _ := json.Marshal(opts)
+	if _, err := fmt.Fprintf(c.c, "CONNECT %s\r\nPING\r\n", connect); err != nil {
+		return fmt.Errorf("nats: %w", err)
+	}
+	for {
+		line, err := c.line()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return natsError(line)
+		}
+	}
+}
+
+func (c *natsConn) line() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", fmt.Errorf("nats: %w", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func natsError(line string) error {
+	return fmt.Errorf("nats: %s", strings.Trim(strings.TrimSpace(strings.TrimPrefix(line, "-ERR")), "'"))
+}
+
+func (b *natsBus) Publish(ctx context.Context, topic string, event core.Event) error {
+	payload, err := encode(event)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// A connection that dropped since the last publish is redialed once
+	for attempt := 0; ; attempt++ {
+		if b.conn == nil {
+			if b.conn, err = b.dial(ctx); err != nil {
+				return err
+			}
+		}
+		if err = b.conn.publish(topic, payload); err == nil {
+			return nil
+		}
+		b.conn.c.Close()
+		b.conn = nil
+		if attempt > 0 {
+			return err
+		}
+	}
+}
+
+// publish sends the message followed by a PING, and returns once the
+// server's PONG confirms it was accepted, answering any PING the server
+// sent in between.
+func (c *natsConn) publish(subject string, payload []byte) error {
+	c.c.SetDeadline(time.Now().Add(10 * time.Second))
+	defer c.c.SetDeadline(time.Time{})
+	if _, err := fmt.Fprintf(c.c, "PUB %s %d\r\n%s\r\nPING\r\n", subject, len(payload), payload); err != nil {
+		return fmt.Errorf("nats: %w", err)
+	}
+	for {
+		line, err := c.line()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PONG":
+			return nil
+		case line == "PING":
+			if _, err := io.WriteString(c.c, "PONG\r\n"); err != nil {
+				return fmt.Errorf("nats: %w", err)
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return natsError(line)
+		}
+	}
+}
+
+func (b *natsBus) Subscribe(ctx context.Context, topic, group string, handle Handler) error {
+	backoff := time.Second
+	for {
+		err := b.consume(ctx, topic, group, handle)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		core.Logger().Warn().Str("topic", topic).Err(err).Dur("retry_in", backoff).Msg("NATS subscription lost; reconnecting")
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+// consume subscribes on its own connection and handles messages until the
+// connection fails or ctx is done.
+func (b *natsBus) consume(ctx context.Context, topic, group string, handle Handler) error {
+	conn, err := b.dial(ctx)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.c.Close() })
+	defer stop()
+	defer conn.c.Close()
+	if _, err := fmt.Fprintf(conn.c, "SUB %s %s 1\r\n", topic, group); err != nil {
+		return fmt.Errorf("nats: %w", err)
+	}
+	for {
+		line, err := conn.line()
+		if err != nil {
+			return err
+		}
+		switch {
+		case line == "PING":
+			if _, err := io.WriteString(conn.c, "PONG\r\n"); err != nil {
+				return fmt.Errorf("nats: %w", err)
+			}
+		case strings.HasPrefix(line, "-ERR"):
+			return natsError(line)
+		case strings.HasPrefix(line, "MSG "):
+			// MSG <subject> <sid> [reply-to] <#bytes>
+			fields := strings.Fields(line)
+			size, err := strconv.Atoi(fields[len(fields)-1])
+			if err != nil || len(fields) < 4 {
+				return fmt.Errorf("nats: malformed %q", line)
+			}
+			payload := make([]byte, size+2) // with the trailing CRLF
+			if _, err := io.ReadFull(conn.r, payload); err != nil {
+				return fmt.Errorf("nats: %w", err)
+			}
+			event, err := decode(payload[:size])
+			if err == nil {
+				err = handle(event)
+			}
+			if err != nil {
+				core.Logger().Error().Str("topic", topic).Err(err).Msg("Event bus handler failed")
+			}
+		}
+	}
+}
+
+func (b *natsBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn == nil {
+		return nil
+	}
+	err := b.conn.c.Close()
+	b.conn = nil
+	if errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,190 @@
+package eventbus
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// natsServer is a NATS server speaking enough of the protocol for the bus.
+type natsServer struct {
+	url       *url.URL
+	info      string
+	connects  chan map[string]any
+	published chan []byte
+	// deliver is sent to each subscriber as a message.
+	deliver []byte
+	// tlsHello receives the first byte a client sends after INFO when the
+	// server requires TLS.
+	tlsHello chan byte
+}
+
+func newNATSServer(t *testing.T, info string) *natsServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s := &natsServer{
+		url:       &url.URL{Scheme: "nats", Host: l.Addr().String()},
+		info:      info,
+		connects:  make(chan map[string]any, 8),
+		published: make(chan []byte, 8),
+		tlsHello:  make(chan byte, 1),
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *natsServer) serve(c net.Conn) {
+	defer c.Close()
+	fmt.Fprintf(c, "INFO %s\r\n", s.info)
+	r := bufio.NewReader(c)
+	if strings.Contains(s.info, `"tls_required":true`) {
+		b, _ := r.ReadByte()
+		s.tlsHello <- b
+		return
+	}
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		cmd, rest, _ := strings.Cut(line, " ")
+		switch cmd {
+		case "CONNECT":
+			var opts map[string]any
+			json.Unmarshal([]byte(rest), &opts)
+			s.connects <- opts
+			if opts["pass"] == "wrong" {
+				io.WriteString(c, "-ERR 'Authorization Violation'\r\n")
+				return
+			}
+		case "PING":
+			io.WriteString(c, "PONG\r\n")
+		case "PUB":
+			fields := strings.Fields(rest)
+			n, _ := strconv.Atoi(fields[len(fields)-1])
+			payload := make([]byte, n+2)
+			io.ReadFull(r, payload)
+			s.published <- payload[:n]
+		case "SUB":
+			fields := strings.Fields(rest)
+			// Check the subscriber answers PINGs before the message arrives
+			io.WriteString(c, "PING\r\n")
+			fmt.Fprintf(c, "MSG %s %s %d\r\n%s\r\n", fields[0], fields[2], len(s.deliver), s.deliver)
+		}
+	}
+}
+
+func TestNATSPublish(t *testing.T) {
+	s := newNATSServer(t, `{"server_id":"test"}`)
+	u := *s.url
+	u.User = url.UserPassword("agent", "secret")
+	bus, err := newNATS(&u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bus.Close()
+
+	event := core.NewEvent("writer", core.EventData{"draft": "hi"}, nil)
+	for range 2 {
+		if err := bus.Publish(context.Background(), "agentflow.events.writer", event); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Publishing reuses its connection
+	if opts := <-s.connects; opts["user"] != "agent" || opts["pass"] != "secret" || opts["tls_required"] != false {
+		t.Errorf("CONNECT %v", opts)
+	}
+	if len(s.connects) != 0 {
+		t.Errorf("connected %d more times", len(s.connects))
+	}
+	got, err := decode(<-s.published)
+	if err != nil || got.GetID() != event.GetID() {
+		t.Errorf("published %v, %v", got, err)
+	}
+}
+
+func TestNATSAuthError(t *testing.T) {
+	s := newNATSServer(t, `{}`)
+	u := *s.url
+	u.User = url.UserPassword("agent", "wrong")
+	bus, _ := newNATS(&u)
+	err := bus.Publish(context.Background(), "agentflow.events.writer", core.NewEvent("writer", nil, nil))
+	if err == nil || err.Error() != "nats: Authorization Violation" {
+		t.Errorf("err = %v", err)
+	}
+}
+
+func TestNATSTokenAuth(t *testing.T) {
+	s := newNATSServer(t, `{}`)
+	u := *s.url
+	u.User = url.User("s3cr3t")
+	bus, _ := newNATS(&u)
+	defer bus.Close()
+	if err := bus.Publish(context.Background(), "t", core.NewEvent("writer", nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if opts := <-s.connects; opts["auth_token"] != "s3cr3t" {
+		t.Errorf("CONNECT %v", opts)
+	}
+}
+
+func TestNATSTLSRequired(t *testing.T) {
+	// The server sends INFO in plain text; the client then starts TLS
+	s := newNATSServer(t, `{"tls_required":true}`)
+	bus, _ := newNATS(s.url)
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := bus.Publish(ctx, "t", core.NewEvent("writer", nil, nil)); err == nil || !strings.Contains(err.Error(), "nats: tls") {
+		t.Errorf("err = %v", err)
+	}
+	// A TLS handshake record
+	if b := <-s.tlsHello; b != 0x16 {
+		t.Errorf("client sent %#x after INFO, not a TLS ClientHello", b)
+	}
+}
+
+func TestNATSSubscribe(t *testing.T) {
+	s := newNATSServer(t, `{}`)
+	event := core.NewEvent("writer", core.EventData{"draft": "hi"}, nil)
+	s.deliver, _ = encode(event)
+	bus, _ := newNATS(s.url)
+	ctx, cancel := context.WithCancel(context.Background())
+	handled := make(chan core.Event, 1)
+	done := make(chan error)
+	go func() {
+		done <- bus.Subscribe(ctx, "agentflow.events.writer", "agentflow", func(e core.Event) error {
+			handled <- e
+			return nil
+		})
+	}()
+	if got := receive(t, handled); got.GetID() != event.GetID() {
+		t.Errorf("handled %s, want %s", got.GetID(), event.GetID())
+	}
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("subscribe ended with %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+// Node is this process's share of a workflow spread over several
+// processes: it runs some agents, consuming their events from the bus, and
+// forwards events routed to the others to the processes that run them.
+type Node struct {
+	bus    Bus
+	prefix string
+	group  string
+	local  []string // sorted
+}
+
+// New connects to cfg's bus for a process with agents registered, of which
+// it runs cfg.Agents, or all of them when that is empty.
+func New(cfg Config, agents []string) (*Node, error) {
+	local := cfg.Agents
+	if len(local) == 0 {
+		local = agents
+	}
+	for _, name := range local {
+		if !slices.Contains(agents, name) {
+			return nil, fmt.Errorf("event bus: unknown agent %q", name)
+		}
+	}
+	bus, err := Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	n := &Node{bus: bus, prefix: cfg.TopicPrefix, group: cfg.Group, local: slices.Clone(local)}
+	if n.prefix == "" {
+		n.prefix = DefaultTopicPrefix
+	}
+	if n.group == "" {
+		n.group = DefaultGroup
+	}
+	sort.Strings(n.local)
+	return n, nil
+}
+
+// Runs reports whether this process runs agent.
+func (n *Node) Runs(agent string) bool {
+	return slices.Contains(n.local, agent)
+}
+
+// Topic is the topic agent's events are published to.
+func (n *Node) Topic(agent string) string {
+	return n.prefix + "." + agent
+}
+
+// Forwarder stands in for an agent another process runs: it publishes the
+// events routed to agent and ends their route here. Its output carries
+// history.ForwardedKey, so the run is recorded as continuing elsewhere.
+func (n *Node) Forwarder(agent string) core.AgentHandler {
+	return core.AgentHandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+		if err := n.bus.Publish(ctx, n.Topic(agent), event); err != nil {
+			return core.AgentResult{}, fmt.Errorf("failed to forward to %s: %w", agent, err)
+		}
+		out := core.NewState()
+		out.SetMeta(history.ForwardedKey, agent)
+		return core.AgentResult{OutputState: out}, nil
+	})
+}
+
+// Run consumes the events of the agents this process runs and emits them on
+// runner, which must be started, until ctx is done.
+func (n *Node) Run(ctx context.Context, runner core.Runner) {
+	var wg sync.WaitGroup
+	for _, agent := range n.local {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := n.bus.Subscribe(ctx, n.Topic(agent), n.group, runner.Emit)
+			if err != nil && ctx.Err() == nil {
+				core.Logger().Error().Str("agent", agent).Err(err).Msg("Event bus subscription ended")
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// Close disconnects from the bus.
+func (n *Node) Close() error {
+	return n.bus.Close()
+}
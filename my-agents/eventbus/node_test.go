@@ -0,0 +1,103 @@
+package eventbus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+type runner struct {
+	core.Runner
+	emitted chan core.Event
+}
+
+func (r *runner) Emit(event core.Event) error {
+	r.emitted <- event
+	return nil
+}
+
+func TestNew(t *testing.T) {
+	agents := []string{"processor", "writer", "formatter"}
+	n, err := New(Config{Backend: BackendMemory, Agents: []string{"writer"}}, agents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !n.Runs("writer") || n.Runs("processor") {
+		t.Errorf("runs %v", n.local)
+	}
+	if got := n.Topic("writer"); got != DefaultTopicPrefix+".writer" {
+		t.Errorf("topic = %q", got)
+	}
+	if n.group != DefaultGroup {
+		t.Errorf("group = %q", n.group)
+	}
+
+	n, err = New(Config{Backend: BackendMemory, TopicPrefix: "acme"}, agents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !n.Runs("processor") || !n.Runs("formatter") || n.Topic("writer") != "acme.writer" {
+		t.Errorf("runs %v, writer topic %s", n.local, n.Topic("writer"))
+	}
+
+	if _, err := New(Config{Backend: BackendMemory, Agents: []string{"critic"}}, agents); err == nil {
+		t.Error("unknown agent accepted")
+	}
+}
+
+func TestForwarder(t *testing.T) {
+	n, err := New(Config{Backend: BackendMemory, Agents: []string{"processor"}}, []string{"processor", "writer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	forwarded := subscribe(t, ctx, n.bus.(*Memory), n.Topic("writer"), DefaultGroup)
+
+	event := core.NewEvent("writer", core.EventData{"draft": "hi"}, nil)
+	result, err := n.Forwarder("writer").Run(ctx, event, core.NewState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if to, _ := result.OutputState.GetMeta(history.ForwardedKey); to != "writer" {
+		t.Errorf("forwarded to %q", to)
+	}
+	if got := receive(t, forwarded); got.GetID() != event.GetID() {
+		t.Errorf("forwarded %s, want %s", got.GetID(), event.GetID())
+	}
+
+	// Without a process running the agent the forward fails
+	if _, err := n.Forwarder("formatter").Run(ctx, event, core.NewState()); err == nil {
+		t.Error("forwarded to nobody")
+	}
+}
+
+func TestRun(t *testing.T) {
+	n, err := New(Config{Backend: BackendMemory}, []string{"processor", "writer"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &runner{emitted: make(chan core.Event, 4)}
+	done := make(chan struct{})
+	go func() {
+		n.Run(ctx, r)
+		close(done)
+	}()
+
+	m := n.bus.(*Memory)
+	event := core.NewEvent("writer", nil, nil)
+	for err := m.Publish(ctx, n.Topic("writer"), event); err != nil; err = m.Publish(ctx, n.Topic("writer"), event) {
+		// Until Run has subscribed
+		time.Sleep(time.Millisecond)
+	}
+	if got := receive(t, r.emitted); got.GetID() != event.GetID() {
+		t.Errorf("emitted %s, want %s", got.GetID(), event.GetID())
+	}
+	cancel()
+	<-done
+}
@@ -0,0 +1,188 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/resp"
+)
+
+// redisMaxLen caps each stream at about this many events; consumed events
+// are acknowledged but stay in the stream until trimmed.
+const redisMaxLen = 100000
+
+// redisBus publishes to a Redis stream per topic and consumes through
+// consumer groups. Events are acknowledged once handled; another consumer
+// of the group claims those left unacknowledged.
+type redisBus struct {
+	u *url.URL
+
+	mu   sync.Mutex
+	conn *resp.Conn // for publishing, dialed on first use
+}
+
+func newRedis(u *url.URL) (*redisBus, error) {
+	if err := resp.CheckURL(u); err != nil {
+		return nil, fmt.Errorf("event bus: %w", err)
+	}
+	return &redisBus{u: u}, nil
+}
+
+func (b *redisBus) Publish(ctx context.Context, topic string, event core.Event) error {
+	payload, err := encode(event)
+	if err != nil {
+		return err
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// A connection that dropped since the last publish is redialed once
+	for attempt := 0; ; attempt++ {
+		if b.conn == nil {
+			if b.conn, err = resp.Dial(ctx, b.u); err != nil {
+				return err
+			}
+		}
+		_, err = b.conn.Do("XADD", topic, "MAXLEN", "~", strconv.Itoa(redisMaxLen), "*", "event", string(payload))
+		if _, ok := err.(resp.Error); ok || err == nil {
+			return err
+		}
+		b.conn.Close()
+		b.conn = nil
+		if attempt > 0 {
+			return err
+		}
+	}
+}
+
+func (b *redisBus) Subscribe(ctx context.Context, topic, group string, handle Handler) error {
+	backoff := time.Second
+	for {
+		err := b.consume(ctx, topic, group, handle)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		core.Logger().Warn().Str("topic", topic).Err(err).Dur("retry_in", backoff).Msg("Redis stream consumer lost; reconnecting")
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		backoff = min(backoff*2, 30*time.Second)
+	}
+}
+
+// consume reads the group's events on its own connection until it fails or
+// ctx is done.
+func (b *redisBus) consume(ctx context.Context, topic, group string, handle Handler) error {
+	conn, err := resp.Dial(ctx, b.u)
+	if err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+	defer conn.Close()
+
+	// A new group starts at the beginning of the stream, so events published
+	// before the first consumer started aren't skipped
+	if _, err := conn.Do("XGROUP", "CREATE", topic, group, "0", "MKSTREAM"); err != nil && !strings.HasPrefix(err.Error(), "redis: BUSYGROUP") {
+		return err
+	}
+	name := consumerName()
+	for {
+		// Events a consumer read and never acknowledged, because it failed or
+		// crashed, are claimed once they have been idle for a minute
+		reply, err := conn.Do("XAUTOCLAIM", topic, group, name, "60000", "0-0", "COUNT", "16")
+		if err != nil {
+			return err
+		}
+		claimed, _ := reply.([]any)
+		if len(claimed) >= 2 {
+			if err := b.deliver(conn, topic, group, entries(claimed[1]), handle); err != nil {
+				return err
+			}
+		}
+		reply, err = conn.Do("XREADGROUP", "GROUP", group, name, "COUNT", "16", "BLOCK", "5000", "STREAMS", topic, ">")
+		if err != nil {
+			return err
+		}
+		// [[stream, entries]], or nil when the block timed out
+		if streams, _ := reply.([]any); len(streams) > 0 {
+			if stream, _ := streams[0].([]any); len(stream) >= 2 {
+				if err := b.deliver(conn, topic, group, entries(stream[1]), handle); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// deliver hands entries to handle, acknowledging those it handled.
+func (b *redisBus) deliver(conn *resp.Conn, topic, group string, entries []streamEntry, handle Handler) error {
+	for _, e := range entries {
+		if len(e.fields) == 0 {
+			// Trimmed before it was handled; nothing left to deliver
+			if _, err := conn.Do("XACK", topic, group, e.id); err != nil {
+				return err
+			}
+			continue
+		}
+		event, err := decode([]byte(e.fields["event"]))
+		if err == nil {
+			err = handle(event)
+		}
+		if err != nil {
+			core.Logger().Error().Str("topic", topic).Str("entry", e.id).Err(err).Msg("Event bus handler failed")
+			continue
+		}
+		if _, err := conn.Do("XACK", topic, group, e.id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type streamEntry struct {
+	id     string
+	fields map[string]string
+}
+
+// entries reads a list of stream entries: [[id, [field, value, ...]], ...].
+// Entries claimed after being trimmed from the stream have no fields.
+func entries(reply any) []streamEntry {
+	items, _ := reply.([]any)
+	var out []streamEntry
+	for _, item := range items {
+		pair, _ := item.([]any)
+		if len(pair) < 2 {
+			continue
+		}
+		e := streamEntry{fields: make(map[string]string)}
+		e.id, _ = pair[0].(string)
+		kv, _ := pair[1].([]any)
+		for i := 0; i+1 < len(kv); i += 2 {
+			k, _ := kv[i].(string)
+			v, _ := kv[i+1].(string)
+			e.fields[k] = v
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+func (b *redisBus) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.conn == nil {
+		return nil
+	}
+	err := b.conn.Close()
+	b.conn = nil
+	return err
+}
@@ -0,0 +1,205 @@
+package eventbus
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// redisServer answers RESP commands with reply, recording them.
+type redisServer struct {
+	url   *url.URL
+	reply func(args []string) any
+
+	mu       sync.Mutex
+	commands [][]string
+}
+
+// redisError is an error reply.
+type redisError string
+
+func newRedisServer(t *testing.T, reply func(args []string) any) *redisServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s := &redisServer{url: &url.URL{Scheme: "redis", Host: l.Addr().String()}, reply: reply}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *redisServer) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		var n int
+		if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+			return
+		}
+		args := make([]string, n)
+		for i := range args {
+			var size int
+			if _, err := fmt.Fscanf(r, "$%d\r\n", &size); err != nil {
+				return
+			}
+			buf := make([]byte, size+2)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			args[i] = string(buf[:size])
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, args)
+		s.mu.Unlock()
+		if _, err := io.WriteString(c, encodeRESP(s.reply(args))); err != nil {
+			return
+		}
+	}
+}
+
+// sent returns the commands named cmd received so far.
+func (s *redisServer) sent(cmd string) [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out [][]string
+	for _, c := range s.commands {
+		if c[0] == cmd {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+func encodeRESP(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return "*-1\r\n"
+	case redisError:
+		return "-" + string(v) + "\r\n"
+	case int:
+		return ":" + strconv.Itoa(v) + "\r\n"
+	case string:
+		return "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+	case []any:
+		var b strings.Builder
+		fmt.Fprintf(&b, "*%d\r\n", len(v))
+		for _, item := range v {
+			b.WriteString(encodeRESP(item))
+		}
+		return b.String()
+	}
+	panic(fmt.Sprintf("can't encode %T", v))
+}
+
+func TestRedisPublish(t *testing.T) {
+	s := newRedisServer(t, func(args []string) any { return "1-0" })
+	bus, err := newRedis(s.url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bus.Close()
+	event := core.NewEvent("writer", nil, nil)
+	if err := bus.Publish(context.Background(), "agentflow.events.writer", event); err != nil {
+		t.Fatal(err)
+	}
+	xadd := s.sent("XADD")
+	if len(xadd) != 1 {
+		t.Fatalf("XADD sent %d times", len(xadd))
+	}
+	args := xadd[0]
+	if strings.Join(args[:6], " ") != "XADD agentflow.events.writer MAXLEN ~ 100000 *" || args[6] != "event" {
+		t.Errorf("XADD %v", args)
+	}
+	if got, err := decode([]byte(args[7])); err != nil || got.GetID() != event.GetID() {
+		t.Errorf("published %v, %v", got, err)
+	}
+}
+
+func TestRedisPublishError(t *testing.T) {
+	s := newRedisServer(t, func(args []string) any {
+		return redisError("WRONGTYPE Operation against a key holding the wrong kind of value")
+	})
+	bus, _ := newRedis(s.url)
+	defer bus.Close()
+	if err := bus.Publish(context.Background(), "t", core.NewEvent("writer", nil, nil)); err == nil {
+		t.Fatal("error reply ignored")
+	}
+	// An error reply isn't a dropped connection to retry on
+	if n := len(s.sent("XADD")); n != 1 {
+		t.Errorf("XADD sent %d times", n)
+	}
+}
+
+func TestRedisSubscribe(t *testing.T) {
+	good := core.NewEvent("writer", nil, nil)
+	bad := core.NewEvent("writer", nil, nil)
+	goodData, _ := encode(good)
+	badData, _ := encode(bad)
+	var once sync.Once
+	s := newRedisServer(t, func(args []string) any {
+		switch args[0] {
+		case "XGROUP":
+			return redisError("BUSYGROUP Consumer Group name already exists")
+		case "XAUTOCLAIM":
+			// An entry trimmed before it was handled
+			return []any{"0-0", []any{[]any{"0-1", nil}}, []any{}}
+		case "XREADGROUP":
+			var reply any
+			once.Do(func() {
+				reply = []any{[]any{"agentflow.events.writer", []any{
+					[]any{"1-0", []any{"event", string(goodData)}},
+					[]any{"1-1", []any{"event", string(badData)}},
+				}}}
+			})
+			return reply
+		case "XACK":
+			return 1
+		}
+		return redisError("ERR unknown command")
+	})
+	bus, _ := newRedis(s.url)
+	ctx, cancel := context.WithCancel(context.Background())
+	handled := make(chan core.Event, 2)
+	done := make(chan error)
+	go func() {
+		done <- bus.Subscribe(ctx, "agentflow.events.writer", "agentflow", func(e core.Event) error {
+			handled <- e
+			if e.GetID() == bad.GetID() {
+				return fmt.Errorf("handler failed")
+			}
+			return nil
+		})
+	}()
+	receive(t, handled)
+	receive(t, handled)
+	cancel()
+	<-done
+
+	// The failed event stays unacknowledged, to be claimed again
+	acked := make(map[string]bool)
+	for _, args := range s.sent("XACK") {
+		acked[args[3]] = true
+	}
+	if !acked["0-1"] || !acked["1-0"] || acked["1-1"] {
+		t.Errorf("acknowledged %v", acked)
+	}
+}
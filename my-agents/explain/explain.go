@@ -0,0 +1,195 @@
+// Package explain tells the user how an answer was produced: the agents that
+// worked on it, the tools they called and the sources they drew on. The
+// explanation is built from what the run recorded, never by asking the
+// model to describe its own work, so it can't be made up. Only names and
+// counts are shown — no tool arguments, results or internal state — and
+// sources are reduced to what identifies them.
+package explain
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+	"my-agents/tools"
+)
+
+// SourcesKey is the state key holding the []string of knowledge sources an
+// agent retrieved for its prompt.
+const SourcesKey = "retrieved_sources"
+
+// maxSources caps the sources listed; the rest are counted.
+const maxSources = 5
+
+// internalAgents aren't worth naming to the user.
+var internalAgents = map[string]bool{"error-handler": true}
+
+// Tool is one tool's use in a run.
+type Tool struct {
+	Name   string `json:"name"`
+	Calls  int    `json:"calls"`
+	Failed int    `json:"failed,omitempty"`
+}
+
+// Work is how a run produced its answer.
+type Work struct {
+	Agents  []string `json:"agents"` // in the order they first worked on it
+	Tools   []Tool   `json:"tools,omitempty"`
+	Sources []string `json:"sources,omitempty"`
+}
+
+// FromRun collects the work of run's recorded steps, then of agent, the
+// agent finishing the run, whose input is state. run may be nil.
+func FromRun(run *history.Run, agent string, state core.State) Work {
+	c := collector{seenAgent: map[string]bool{}, seenCall: map[string]bool{}, seenSource: map[string]bool{}, tools: map[string]*Tool{}}
+	if run != nil {
+		for _, step := range run.Steps {
+			if len(step.Branches) > 0 {
+				for _, b := range step.Branches {
+					c.agent(b.Agent)
+				}
+			} else {
+				c.agent(step.Agent)
+			}
+			if step.Error == "" {
+				c.state(core.NewStateWithData(step.Output))
+			}
+		}
+	}
+	c.agent(agent)
+	c.state(state)
+	for _, name := range c.order {
+		c.work.Tools = append(c.work.Tools, *c.tools[name])
+	}
+	return c.work
+}
+
+type collector struct {
+	work       Work
+	seenAgent  map[string]bool
+	seenCall   map[string]bool
+	seenSource map[string]bool
+	tools      map[string]*Tool
+	order      []string // tool names, first used first
+}
+
+func (c *collector) agent(name string) {
+	if name == "" || internalAgents[name] || c.seenAgent[name] {
+		return
+	}
+	c.seenAgent[name] = true
+	c.work.Agents = append(c.work.Agents, name)
+}
+
+// state adds the tool calls and sources a state holds. Agents pass both on,
+// so the same call can be in several steps' outputs; it counts once.
+func (c *collector) state(state core.State) {
+	if state == nil {
+		return
+	}
+	for _, inv := range tools.Recorded(state) {
+		fingerprint, _ := json.Marshal([]any{inv.Tool, inv.Args, inv.Duration})
+		if c.seenCall[string(fingerprint)] {
+			continue
+		}
+		c.seenCall[string(fingerprint)] = true
+		t, ok := c.tools[inv.Tool]
+		if !ok {
+			t = &Tool{Name: inv.Tool}
+			c.tools[inv.Tool] = t
+			c.order = append(c.order, inv.Tool)
+		}
+		t.Calls++
+		if inv.Error != "" {
+			t.Failed++
+		}
+		for _, src := range inv.Sources {
+			c.source(src)
+		}
+	}
+	if v, ok := state.Get(SourcesKey); ok {
+		switch sources := v.(type) {
+		case []string:
+			for _, src := range sources {
+				c.source(src)
+			}
+		case []any: // decoded from JSON, as in resumed runs
+			for _, src := range sources {
+				c.source(fmt.Sprint(src))
+			}
+		}
+	}
+}
+
+func (c *collector) source(src string) {
+	src = Sanitize(src)
+	if src == "" || c.seenSource[src] {
+		return
+	}
+	c.seenSource[src] = true
+	c.work.Sources = append(c.work.Sources, src)
+}
+
+// Sanitize reduces a source to what identifies it to a reader: a URL loses
+// its credentials, query and fragment, and a file path all but its name.
+func Sanitize(src string) string {
+	src = strings.TrimSpace(src)
+	if u, err := url.Parse(src); err == nil && u.Scheme != "" && u.Host != "" {
+		u.User, u.RawQuery, u.Fragment = nil, "", ""
+		src = u.String()
+	} else if strings.ContainsAny(src, `/\`) {
+		src = filepath.Base(filepath.ToSlash(strings.ReplaceAll(src, `\`, "/")))
+	}
+	if r := []rune(src); len(r) > 80 {
+		src = string(r[:80]) + "…"
+	}
+	return src
+}
+
+// Markdown renders the work as a section to append to an answer, or ""
+// when there is nothing to tell.
+func (w Work) Markdown() string {
+	if len(w.Agents) == 0 && len(w.Tools) == 0 && len(w.Sources) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("---\n\n**How this answer was produced**\n\n")
+	if len(w.Agents) > 0 {
+		fmt.Fprintf(&b, "- Agents: %s\n", strings.Join(w.Agents, " → "))
+	}
+	if len(w.Tools) > 0 {
+		uses := make([]string, len(w.Tools))
+		for i, t := range w.Tools {
+			var notes []string
+			if t.Calls > 1 {
+				notes = append(notes, fmt.Sprintf("%d calls", t.Calls))
+			}
+			if t.Failed > 0 {
+				notes = append(notes, fmt.Sprintf("%d failed", t.Failed))
+			}
+			uses[i] = t.Name
+			if len(notes) > 0 {
+				uses[i] += " (" + strings.Join(notes, ", ") + ")"
+			}
+		}
+		fmt.Fprintf(&b, "- Tools: %s\n", strings.Join(uses, "; "))
+	} else {
+		b.WriteString("- Tools: none\n")
+	}
+	if len(w.Sources) > 0 {
+		shown := w.Sources[:min(len(w.Sources), maxSources)]
+		line := strings.Join(shown, ", ")
+		if more := len(w.Sources) - len(shown); more > 0 {
+			line += fmt.Sprintf(" and %d more", more)
+		}
+		fmt.Fprintf(&b, "- Sources: %s\n", line)
+	} else {
+		b.WriteString("- Sources: none retrieved; the answer is the model's own\n")
+	}
+	return b.String()
+}
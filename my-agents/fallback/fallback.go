@@ -0,0 +1,232 @@
+// Package fallback keeps an agent answering when its provider doesn't: a
+// Provider tries an ordered chain of providers — say OpenAI, then Azure,
+// then a local Ollama — and moves to the next one when a call is rate
+// limited, times out or fails with a server error. Errors a retry wouldn't
+// fix either, like a rejected prompt, are returned as they are.
+package fallback
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/retry"
+)
+
+// Config is an agent's [agents.<name>.fallback] table, naming the
+// providers tried, in order, after the agent's own:
+//
+//	[agents.processor.fallback]
+//	providers = ["azure", "local"]
+//	timeout = "30s"
+//	cooldown = "1m"
+type Config struct {
+	// Providers name [providers.<name>] tables.
+	Providers []string `toml:"providers"`
+	// Timeout bounds each provider's call, or its wait for a stream's first
+	// token; one that takes longer is failed over. Empty waits as long as
+	// the caller does.
+	Timeout string `toml:"timeout"`
+	// Cooldown skips a provider that just failed over for this long, so
+	// calls don't keep waiting on it (default 30s). The last provider is
+	// always tried.
+	Cooldown string `toml:"cooldown"`
+	// FailOverOn and NoFailOverOn are error message fragments
+	// (case-insensitive) classifying errors, ahead of the built-in
+	// classification the retry policy uses.
+	FailOverOn   []string `toml:"fail_over_on"`
+	NoFailOverOn []string `toml:"no_fail_over_on"`
+}
+
+// Enabled reports whether c names any provider to fail over to.
+func (c *Config) Enabled() bool {
+	return c != nil && len(c.Providers) > 0
+}
+
+// DefaultCooldown is how long a provider that failed over is skipped.
+const DefaultCooldown = 30 * time.Second
+
+// Link is one provider of a chain.
+type Link struct {
+	Name string
+	LLM  core.ModelProvider
+}
+
+// Provider calls the first provider of its chain that answers.
+type Provider struct {
+	chain    []Link
+	timeout  time.Duration
+	cooldown time.Duration
+	classify retry.Policy
+
+	mu     sync.Mutex
+	downAt map[string]time.Time // when each provider last failed over
+}
+
+// New creates a provider failing over along chain, which needs at least two
+// links.
+func New(chain []Link, cfg Config) (*Provider, error) {
+	if len(chain) < 2 {
+		return nil, fmt.Errorf("a fallback chain needs at least two providers")
+	}
+	p := &Provider{
+		chain:    chain,
+		cooldown: DefaultCooldown,
+		classify: retry.Policy{RetryOn: cfg.FailOverOn, NoRetryOn: cfg.NoFailOverOn},
+		downAt:   make(map[string]time.Time),
+	}
+	var err error
+	if cfg.Timeout != "" {
+		if p.timeout, err = time.ParseDuration(cfg.Timeout); err != nil {
+			return nil, fmt.Errorf("fallback timeout: %w", err)
+		}
+	}
+	if cfg.Cooldown != "" {
+		if p.cooldown, err = time.ParseDuration(cfg.Cooldown); err != nil {
+			return nil, fmt.Errorf("fallback cooldown: %w", err)
+		}
+	}
+	return p, nil
+}
+
+// Exhausted is the error of a call every provider of the chain failed.
+type Exhausted struct {
+	Errs map[string]error // by provider name
+	Last error
+}
+
+func (e *Exhausted) Error() string {
+	return fmt.Sprintf("all %d providers failed, the last with: %v", len(e.Errs), e.Last)
+}
+
+func (e *Exhausted) Unwrap() error { return e.Last }
+
+// try calls each available provider in turn until one succeeds or fails
+// with an error failing over doesn't help with.
+func (p *Provider) try(ctx context.Context, call func(ctx context.Context, llm core.ModelProvider) error) error {
+	errs := make(map[string]error)
+	var last error
+	for i, link := range p.available() {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if p.timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, p.timeout)
+		}
+		err := call(attemptCtx, link.LLM)
+		cancel()
+		if err == nil {
+			if i > 0 {
+				core.Logger().Info().Str("provider", link.Name).Int("attempt", i+1).Msg("Fallback provider answered")
+			}
+			return nil
+		}
+		if !p.classify.Transient(ctx, err) {
+			return err
+		}
+		p.markDown(link.Name)
+		core.Logger().Warn().Str("provider", link.Name).Err(err).Msg("Provider failed; failing over")
+		errs[link.Name], last = err, err
+	}
+	return &Exhausted{Errs: errs, Last: last}
+}
+
+// available returns the chain without the providers cooling down, keeping
+// the last provider regardless.
+func (p *Provider) available() []Link {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var links []Link
+	for i, link := range p.chain {
+		if down, ok := p.downAt[link.Name]; ok && time.Since(down) < p.cooldown && i < len(p.chain)-1 {
+			continue
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+func (p *Provider) markDown(name string) {
+	p.mu.Lock()
+	p.downAt[name] = time.Now()
+	p.mu.Unlock()
+}
+
+func (p *Provider) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	var resp core.Response
+	err := p.try(ctx, func(ctx context.Context, llm core.ModelProvider) error {
+		var err error
+		resp, err = llm.Call(ctx, prompt)
+		return err
+	})
+	return resp, err
+}
+
+func (p *Provider) Embeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	var vectors [][]float64
+	err := p.try(ctx, func(ctx context.Context, llm core.ModelProvider) error {
+		var err error
+		vectors, err = llm.Embeddings(ctx, texts)
+		return err
+	})
+	return vectors, err
+}
+
+// Stream fails over until a provider streams its first token; a stream
+// that breaks after that is the caller's to handle, as tokens are already
+// out. The timeout bounds the wait for the first token.
+func (p *Provider) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	var out chan core.Token
+	err := p.try(ctx, func(attemptCtx context.Context, llm core.ModelProvider) error {
+		// The stream outlives the attempt: only its start is timed
+		streamCtx, cancel := context.WithCancel(ctx)
+		tokens, err := llm.Stream(streamCtx, prompt)
+		if err != nil {
+			cancel()
+			return err
+		}
+		var first core.Token
+		var ok bool
+		select {
+		case first, ok = <-tokens:
+		case <-attemptCtx.Done():
+			cancel()
+			return attemptCtx.Err()
+		}
+		if ok && first.Error != nil {
+			cancel()
+			return first.Error
+		}
+		out = make(chan core.Token)
+		go func() {
+			defer cancel()
+			defer close(out)
+			if ok {
+				Forward(ctx, out, first, tokens)
+			}
+		}()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Forward sends first and then the rest of tokens to out until the caller
+// goes away, when the tokens still coming are drained instead so the
+// provider sending them isn't left blocked.
+func Forward(ctx context.Context, out chan<- core.Token, first core.Token, tokens <-chan core.Token) {
+	for t, ok := first, true; ok; t, ok = <-tokens {
+		select {
+		case out <- t:
+		case <-ctx.Done():
+			go func() {
+				for range tokens {
+				}
+			}()
+			return
+		}
+	}
+}
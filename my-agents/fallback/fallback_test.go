@@ -0,0 +1,237 @@
+package fallback
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// llm answers with reply, or fails with err; its stream sends tokens,
+// waiting delay before the first.
+type llm struct {
+	core.ModelProvider
+	reply  string
+	err    error
+	tokens []core.Token
+	delay  time.Duration
+	calls  int
+}
+
+func (l *llm) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	l.calls++
+	if l.delay > 0 {
+		select {
+		case <-time.After(l.delay):
+		case <-ctx.Done():
+			return core.Response{}, ctx.Err()
+		}
+	}
+	return core.Response{Content: l.reply}, l.err
+}
+
+func (l *llm) Embeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	l.calls++
+	if l.err != nil {
+		return nil, l.err
+	}
+	return [][]float64{{1, 0}}, nil
+}
+
+func (l *llm) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	l.calls++
+	if l.err != nil {
+		return nil, l.err
+	}
+	tokens := make(chan core.Token)
+	go func() {
+		defer close(tokens)
+		select {
+		case <-time.After(l.delay):
+		case <-ctx.Done():
+			return
+		}
+		for _, t := range l.tokens {
+			select {
+			case tokens <- t:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return tokens, nil
+}
+
+func chain(llms ...*llm) []Link {
+	var links []Link
+	for i, l := range llms {
+		links = append(links, Link{Name: string(rune('a' + i)), LLM: l})
+	}
+	return links
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(chain(&llm{}), Config{}); err == nil {
+		t.Error("chain of one accepted")
+	}
+	for _, cfg := range []Config{{Timeout: "soon"}, {Cooldown: "1 minute"}} {
+		if _, err := New(chain(&llm{}, &llm{}), cfg); err == nil {
+			t.Errorf("config %+v accepted", cfg)
+		}
+	}
+	if (*Config)(nil).Enabled() || (&Config{}).Enabled() || !(&Config{Providers: []string{"azure"}}).Enabled() {
+		t.Error("Enabled is wrong")
+	}
+}
+
+func TestCall(t *testing.T) {
+	ctx := context.Background()
+	down := &llm{err: errors.New("openai: status 503: overloaded")}
+	azure := &llm{reply: "from azure"}
+	p, _ := New(chain(down, azure), Config{})
+	resp, err := p.Call(ctx, core.Prompt{})
+	if err != nil || resp.Content != "from azure" {
+		t.Fatalf("Call = %+v, %v", resp, err)
+	}
+	// The failed provider cools down
+	p.Call(ctx, core.Prompt{})
+	if down.calls != 1 || azure.calls != 2 {
+		t.Errorf("calls: %d to the failed provider, %d to the next", down.calls, azure.calls)
+	}
+
+	// Errors failing over doesn't help with are returned as they are
+	rejected := &llm{err: errors.New("status 400: prompt rejected")}
+	next := &llm{reply: "ok"}
+	p, _ = New(chain(rejected, next), Config{})
+	if _, err := p.Call(ctx, core.Prompt{}); err != rejected.err || next.calls != 0 {
+		t.Errorf("rejected prompt: %v after %d calls to the next provider", err, next.calls)
+	}
+	// unless configured to fail over on them, or not to on transient ones
+	p, _ = New(chain(rejected, next), Config{FailOverOn: []string{"REJECTED"}})
+	if resp, err := p.Call(ctx, core.Prompt{}); err != nil || resp.Content != "ok" {
+		t.Errorf("fail over on: %+v, %v", resp, err)
+	}
+	p, _ = New(chain(down, next), Config{NoFailOverOn: []string{"overloaded"}})
+	if _, err := p.Call(ctx, core.Prompt{}); err != down.err {
+		t.Errorf("no fail over on: %v", err)
+	}
+}
+
+func TestCallExhausted(t *testing.T) {
+	a, b := &llm{err: errors.New("rate limit")}, &llm{err: errors.New("status 502")}
+	p, _ := New(chain(a, b), Config{Cooldown: "1h"})
+	_, err := p.Call(context.Background(), core.Prompt{})
+	var exhausted *Exhausted
+	if !errors.As(err, &exhausted) || len(exhausted.Errs) != 2 || !errors.Is(err, b.err) {
+		t.Fatalf("err = %v", err)
+	}
+	if err.Error() != "all 2 providers failed, the last with: status 502" {
+		t.Errorf("message = %s", err)
+	}
+	// The last provider is tried even while cooling down
+	p.Call(context.Background(), core.Prompt{})
+	if a.calls != 1 || b.calls != 2 {
+		t.Errorf("calls = %d, %d", a.calls, b.calls)
+	}
+}
+
+func TestCallTimeout(t *testing.T) {
+	slow, fast := &llm{reply: "slow", delay: time.Second}, &llm{reply: "fast"}
+	p, _ := New(chain(slow, fast), Config{Timeout: "10ms"})
+	if resp, err := p.Call(context.Background(), core.Prompt{}); err != nil || resp.Content != "fast" {
+		t.Errorf("Call = %+v, %v", resp, err)
+	}
+	// The caller giving up isn't the provider's fault
+	slow, fast = &llm{delay: time.Second}, &llm{}
+	p, _ = New(chain(slow, fast), Config{})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := p.Call(ctx, core.Prompt{}); !errors.Is(err, context.Canceled) || fast.calls != 0 {
+		t.Errorf("canceled call: %v after %d calls to the next provider", err, fast.calls)
+	}
+}
+
+func TestEmbeddings(t *testing.T) {
+	p, _ := New(chain(&llm{err: errors.New("connection refused")}, &llm{}), Config{})
+	if vectors, err := p.Embeddings(context.Background(), []string{"a"}); err != nil || len(vectors) != 1 {
+		t.Errorf("Embeddings = %v, %v", vectors, err)
+	}
+}
+
+func collect(tokens <-chan core.Token) string {
+	var b strings.Builder
+	for t := range tokens {
+		b.WriteString(t.Content)
+	}
+	return b.String()
+}
+
+func TestStream(t *testing.T) {
+	ctx := context.Background()
+	tokens := []core.Token{{Content: "Go "}, {Content: "is fast."}}
+	broken := &llm{tokens: []core.Token{{Error: errors.New("status 500")}}}
+	slow := &llm{tokens: tokens, delay: time.Second}
+	ok := &llm{tokens: tokens}
+	p, _ := New(chain(&llm{err: errors.New("timed out")}, broken, slow, ok), Config{Timeout: "20ms"})
+	out, err := p.Stream(ctx, core.Prompt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := collect(out); got != "Go is fast." {
+		t.Errorf("streamed %q", got)
+	}
+	// Only the wait for the first token is timed
+	ok.delay = 10 * time.Millisecond
+	p, _ = New(chain(&llm{err: errors.New("timed out")}, ok), Config{Timeout: "50ms"})
+	out, _ = p.Stream(ctx, core.Prompt{})
+	if got := collect(out); got != "Go is fast." {
+		t.Errorf("streamed %q", got)
+	}
+
+	// A stream ending without a token is an empty answer
+	p, _ = New(chain(&llm{}, &llm{tokens: tokens}), Config{})
+	if out, err := p.Stream(ctx, core.Prompt{}); err != nil || collect(out) != "" {
+		t.Errorf("empty stream: %v", err)
+	}
+	p, _ = New(chain(&llm{err: errors.New("status 429")}, &llm{err: errors.New("status 503")}), Config{})
+	if _, err := p.Stream(ctx, core.Prompt{}); err == nil {
+		t.Error("stream from failing providers")
+	}
+}
+
+func TestForward(t *testing.T) {
+	tokens := make(chan core.Token)
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for _, s := range []string{"b", "c", "d"} {
+			tokens <- core.Token{Content: s}
+		}
+		close(tokens)
+	}()
+	ctx, cancel := context.WithCancel(context.Background())
+	out := make(chan core.Token)
+	done := make(chan struct{})
+	go func() {
+		Forward(ctx, out, core.Token{Content: "a"}, tokens)
+		close(done)
+	}()
+	if t1, t2 := <-out, <-out; t1.Content != "a" || t2.Content != "b" {
+		t.Errorf("forwarded %q, %q", t1.Content, t2.Content)
+	}
+	// Once the caller goes away the rest is drained, not left blocked
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Forward didn't return")
+	}
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("provider left blocked")
+	}
+}
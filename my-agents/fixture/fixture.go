@@ -0,0 +1,178 @@
+// Package fixture snapshots a workflow run — the config it ran with, every
+// prompt agents sent and every response providers gave — into a directory,
+// and replays it later with the recorded responses standing in for the
+// providers. Replays need no API keys, so CI can run them on every change
+// and report where behavior drifted from the snapshot.
+package fixture
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Files of a fixture directory.
+const (
+	ConfigFile = "agentflow.toml"
+	RunFile    = "fixture.json"
+)
+
+// ErrUnrecorded is returned for provider calls the snapshot has no
+// response for.
+var ErrUnrecorded = errors.New("no recorded response")
+
+// Call is one provider call made by an agent.
+type Call struct {
+	Agent    string        `json:"agent"`
+	Prompt   core.Prompt   `json:"prompt"`
+	Response core.Response `json:"response"`
+	Error    string        `json:"error,omitempty"`
+}
+
+// Fixture is a recorded run: what went in, what came out and the provider
+// traffic in between.
+type Fixture struct {
+	Name          string            `json:"name"`
+	Route         string            `json:"route"`
+	SessionID     string            `json:"session_id"`
+	Input         string            `json:"input"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	Status        string            `json:"status"`
+	FinalResponse string            `json:"final_response"`
+	// Agents lists the run's steps in order.
+	Agents     []string  `json:"agents"`
+	Calls      []Call    `json:"calls"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Load reads the fixture in dir.
+func Load(dir string) (*Fixture, error) {
+	data, err := os.ReadFile(filepath.Join(dir, RunFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read fixture: %w", err)
+	}
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to decode fixture %s: %w", dir, err)
+	}
+	return &f, nil
+}
+
+// Save writes f to dir with a copy of the config at configPath, its API
+// keys removed.
+func Save(dir string, f *Fixture, configPath string) error {
+	config, err := os.ReadFile(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to read configuration file %s: %w", configPath, err)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create fixture directory %s: %w", dir, err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ConfigFile), Sanitize(config), 0o644); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, RunFile), append(data, '\n'), 0o644)
+}
+
+var apiKeyLine = regexp.MustCompile(`(?m)^(\s*api_key\s*=\s*)(".*"|'.*')`)
+
+// Sanitize blanks the api_key values of a TOML config.
+func Sanitize(config []byte) []byte {
+	return apiKeyLine.ReplaceAll(config, []byte(`${1}""`))
+}
+
+// Drift is one difference between a replay and its snapshot.
+type Drift struct {
+	Kind   string `json:"kind"` // "prompt", "unrecorded", "unused", "status", "agents" or "response"
+	Detail string `json:"detail"`
+}
+
+func (d Drift) String() string {
+	return d.Kind + ": " + d.Detail
+}
+
+// Report is the outcome of replaying a fixture.
+type Report struct {
+	Name   string  `json:"name"`
+	Drifts []Drift `json:"drifts"`
+}
+
+// Err summarizes the drifts, or returns nil when there are none.
+func (r *Report) Err() error {
+	if len(r.Drifts) == 0 {
+		return nil
+	}
+	lines := make([]string, len(r.Drifts))
+	for i, d := range r.Drifts {
+		lines[i] = "  " + d.String()
+	}
+	return fmt.Errorf("fixture %s drifted:\n%s", r.Name, strings.Join(lines, "\n"))
+}
+
+// TB is the part of testing.TB that Expect needs.
+type TB interface {
+	Helper()
+	Errorf(format string, args ...any)
+}
+
+// Expect fails t for every drift in r, so a test can replay fixtures with
+//
+//	fixture.Expect(t, report)
+func Expect(t TB, r *Report) {
+	t.Helper()
+	for _, d := range r.Drifts {
+		t.Errorf("fixture %s: %s", r.Name, d)
+	}
+}
+
+// Compare reports how the outcome of a replayed run differs from the
+// snapshot. Provider traffic is compared by the Replayer.
+func Compare(want, got *Fixture) []Drift {
+	var drifts []Drift
+	if got.Status != want.Status {
+		drifts = append(drifts, Drift{Kind: "status", Detail: fmt.Sprintf("run %s, snapshot %s", got.Status, want.Status)})
+	}
+	if strings.Join(got.Agents, " → ") != strings.Join(want.Agents, " → ") {
+		drifts = append(drifts, Drift{Kind: "agents", Detail: fmt.Sprintf("ran %s, snapshot ran %s", strings.Join(got.Agents, " → "), strings.Join(want.Agents, " → "))})
+	}
+	if got.FinalResponse != want.FinalResponse {
+		drifts = append(drifts, Drift{Kind: "response", Detail: firstDifference(want.FinalResponse, got.FinalResponse)})
+	}
+	return drifts
+}
+
+// firstDifference describes the first line where got departs from want.
+func firstDifference(want, got string) string {
+	w, g := strings.Split(want, "\n"), strings.Split(got, "\n")
+	for i := 0; i < max(len(w), len(g)); i++ {
+		var wl, gl string
+		if i < len(w) {
+			wl = w[i]
+		}
+		if i < len(g) {
+			gl = g[i]
+		}
+		if wl != gl {
+			return fmt.Sprintf("line %d: got %q, snapshot %q", i+1, truncate(gl), truncate(wl))
+		}
+	}
+	return "differs"
+}
+
+func truncate(s string) string {
+	if r := []rune(s); len(r) > 80 {
+		return string(r[:80]) + "…"
+	}
+	return s
+}
@@ -0,0 +1,100 @@
+package fixture
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveLoad(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "agentflow.toml")
+	os.WriteFile(config, []byte("[agent_flow]\nname = \"demo\"\n\n[providers.openai]\napi_key = \"sk-secret\"\nmodel = \"gpt-4o\"\n"), 0o644)
+	dir := filepath.Join(t.TempDir(), "greeting")
+	in := &Fixture{Name: "greeting", Route: "processor", Input: "hello", Status: "completed", FinalResponse: "hi", Agents: []string{"processor"}}
+	if err := Save(dir, in, config); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := os.ReadFile(filepath.Join(dir, ConfigFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(saved), "sk-secret") || !strings.Contains(string(saved), `model = "gpt-4o"`) {
+		t.Errorf("saved config:\n%s", saved)
+	}
+	got, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "greeting" || got.FinalResponse != "hi" || len(got.Agents) != 1 {
+		t.Errorf("loaded %+v", got)
+	}
+	if _, err := Load(t.TempDir()); err == nil {
+		t.Error("loaded a directory without a fixture")
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	in := "api_key = \"sk-1\"\n  api_key='sk-2'\nmy_api_key_env = \"OPENAI_API_KEY\"\n"
+	want := "api_key = \"\"\n  api_key=\"\"\nmy_api_key_env = \"OPENAI_API_KEY\"\n"
+	if got := string(Sanitize([]byte(in))); got != want {
+		t.Errorf("Sanitize = %q, want %q", got, want)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	want := &Fixture{Status: "completed", Agents: []string{"planner", "writer"}, FinalResponse: "one\ntwo"}
+	if drifts := Compare(want, want); len(drifts) != 0 {
+		t.Errorf("identical runs drifted: %v", drifts)
+	}
+	got := &Fixture{Status: "failed", Agents: []string{"planner"}, FinalResponse: "one\nthree"}
+	drifts := Compare(want, got)
+	kinds := make([]string, len(drifts))
+	for i, d := range drifts {
+		kinds[i] = d.Kind
+	}
+	if strings.Join(kinds, " ") != "status agents response" {
+		t.Fatalf("drifts = %v", drifts)
+	}
+	if d := drifts[2].Detail; d != `line 2: got "three", snapshot "two"` {
+		t.Errorf("response drift = %q", d)
+	}
+}
+
+func TestReportErr(t *testing.T) {
+	r := &Report{Name: "greeting"}
+	if err := r.Err(); err != nil {
+		t.Errorf("no drifts: %v", err)
+	}
+	r.Drifts = []Drift{{Kind: "status", Detail: "run failed, snapshot completed"}}
+	if err := r.Err(); err == nil || !strings.Contains(err.Error(), "status: run failed") {
+		t.Errorf("err = %v", err)
+	}
+}
+
+type tb struct{ errors []string }
+
+func (t *tb) Helper() {}
+
+func (t *tb) Errorf(format string, args ...any) {
+	t.errors = append(t.errors, fmt.Sprintf(format, args...))
+}
+
+func TestExpect(t *testing.T) {
+	var fake tb
+	Expect(&fake, &Report{Name: "greeting", Drifts: []Drift{{Kind: "agents", Detail: "a"}, {Kind: "response", Detail: "b"}}})
+	if len(fake.errors) != 2 || fake.errors[0] != "fixture greeting: agents: a" {
+		t.Errorf("errors = %q", fake.errors)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	long := strings.Repeat("é", 100)
+	if got := truncate(long); got != strings.Repeat("é", 80)+"…" {
+		t.Errorf("truncate = %q", got)
+	}
+	if got := truncate("short"); got != "short" {
+		t.Errorf("truncate = %q", got)
+	}
+}
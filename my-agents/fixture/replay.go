@@ -0,0 +1,203 @@
+package fixture
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Recorder captures the provider calls agents make while a snapshot runs.
+type Recorder struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Calls returns the calls recorded so far, in the order they finished.
+func (r *Recorder) Calls() []Call {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Call(nil), r.calls...)
+}
+
+// Middleware records every call through the provider handed to an agent.
+// It has the shape of di.LLMMiddleware; add it last so it sees what the
+// provider actually received.
+func (r *Recorder) Middleware() func(agent string, llm core.ModelProvider) core.ModelProvider {
+	return func(agent string, llm core.ModelProvider) core.ModelProvider {
+		return &recording{ModelProvider: llm, r: r, agent: agent}
+	}
+}
+
+func (r *Recorder) record(call Call) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+type recording struct {
+	core.ModelProvider
+	r     *Recorder
+	agent string
+}
+
+// Unwrap returns the provider wrapped, for the LLM cache to see through.
+func (p *recording) Unwrap() core.ModelProvider { return p.ModelProvider }
+
+func (p *recording) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	resp, err := p.ModelProvider.Call(ctx, prompt)
+	call := Call{Agent: p.agent, Prompt: prompt, Response: resp}
+	if err != nil {
+		call.Error = err.Error()
+	}
+	p.r.record(call)
+	return resp, err
+}
+
+func (p *recording) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	in, err := p.ModelProvider.Stream(ctx, prompt)
+	if err != nil {
+		p.r.record(Call{Agent: p.agent, Prompt: prompt, Error: err.Error()})
+		return nil, err
+	}
+	out := make(chan core.Token)
+	go func() {
+		defer close(out)
+		var b strings.Builder
+		call := Call{Agent: p.agent, Prompt: prompt}
+		for tok := range in {
+			b.WriteString(tok.Content)
+			if tok.Error != nil {
+				call.Error = tok.Error.Error()
+			}
+			out <- tok
+		}
+		call.Response = core.Response{Content: b.String()}
+		p.r.record(call)
+	}()
+	return out, nil
+}
+
+// Replayer answers provider calls from a snapshot's recorded calls and
+// notes where the calls made differ from the recorded ones. Each agent's
+// calls are answered in recorded order; it is di.AgentScoped so agents get
+// their own view.
+type Replayer struct {
+	mu     sync.Mutex
+	calls  []Call
+	used   []bool
+	drifts []Drift
+}
+
+// NewReplayer creates a replayer over recorded calls.
+func NewReplayer(calls []Call) *Replayer {
+	return &Replayer{calls: calls, used: make([]bool, len(calls))}
+}
+
+// ForAgent returns the replayer as seen by one agent.
+func (r *Replayer) ForAgent(name string) core.ModelProvider {
+	return &agentReplayer{r: r, agent: name}
+}
+
+func (r *Replayer) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	return r.next("", prompt)
+}
+
+func (r *Replayer) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	return stream(r.next("", prompt))
+}
+
+func (r *Replayer) Embeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	return nil, fmt.Errorf("embeddings: %w", ErrUnrecorded)
+}
+
+// Drifts returns the differences in provider traffic so far, including the
+// recorded calls no agent made.
+func (r *Replayer) Drifts() []Drift {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	drifts := append([]Drift(nil), r.drifts...)
+	for i, used := range r.used {
+		if !used {
+			drifts = append(drifts, Drift{Kind: "unused", Detail: fmt.Sprintf("recorded call %d by %s was not made", i+1, r.calls[i].Agent)})
+		}
+	}
+	return drifts
+}
+
+// next answers a call by agent ("" when not known) with the first unused
+// recorded call of that agent, or else the first unused call with the same
+// prompt, or else the first unused call.
+func (r *Replayer) next(agent string, prompt core.Prompt) (core.Response, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	i := r.find(func(c Call) bool { return agent != "" && c.Agent == agent })
+	if i < 0 {
+		i = r.find(func(c Call) bool { return c.Prompt.System == prompt.System && c.Prompt.User == prompt.User })
+	}
+	if i < 0 && agent == "" {
+		i = r.find(func(Call) bool { return true })
+	}
+	if i < 0 {
+		r.drifts = append(r.drifts, Drift{Kind: "unrecorded", Detail: fmt.Sprintf("%s made a call the snapshot has no response for", orUnknown(agent))})
+		return core.Response{}, ErrUnrecorded
+	}
+	r.used[i] = true
+	call := r.calls[i]
+	if call.Prompt.System != prompt.System {
+		r.drifts = append(r.drifts, Drift{Kind: "prompt", Detail: fmt.Sprintf("call %d by %s, system prompt %s", i+1, call.Agent, firstDifference(call.Prompt.System, prompt.System))})
+	}
+	if call.Prompt.User != prompt.User {
+		r.drifts = append(r.drifts, Drift{Kind: "prompt", Detail: fmt.Sprintf("call %d by %s, user prompt %s", i+1, call.Agent, firstDifference(call.Prompt.User, prompt.User))})
+	}
+	if call.Error != "" {
+		return call.Response, fmt.Errorf("recorded error: %s", call.Error)
+	}
+	return call.Response, nil
+}
+
+func (r *Replayer) find(match func(Call) bool) int {
+	for i, c := range r.calls {
+		if !r.used[i] && match(c) {
+			return i
+		}
+	}
+	return -1
+}
+
+func orUnknown(agent string) string {
+	if agent == "" {
+		return "an unattributed caller"
+	}
+	return agent
+}
+
+type agentReplayer struct {
+	r     *Replayer
+	agent string
+}
+
+func (p *agentReplayer) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	return p.r.next(p.agent, prompt)
+}
+
+func (p *agentReplayer) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	return stream(p.r.next(p.agent, prompt))
+}
+
+func (p *agentReplayer) Embeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	return p.r.Embeddings(ctx, texts)
+}
+
+// stream delivers a recorded response as a single token.
+func stream(resp core.Response, err error) (<-chan core.Token, error) {
+	if err != nil && resp.Content == "" {
+		return nil, err
+	}
+	out := make(chan core.Token, 1)
+	out <- core.Token{Content: resp.Content, Error: err}
+	close(out)
+	return out, nil
+}
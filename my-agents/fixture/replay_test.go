@@ -0,0 +1,109 @@
+package fixture
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+type model struct {
+	core.ModelProvider
+	reply string
+	err   error
+}
+
+func (m *model) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	return core.Response{Content: m.reply}, m.err
+}
+
+func (m *model) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	out := make(chan core.Token, 2)
+	out <- core.Token{Content: m.reply[:2]}
+	out <- core.Token{Content: m.reply[2:]}
+	close(out)
+	return out, nil
+}
+
+func TestRecorder(t *testing.T) {
+	var r Recorder
+	wrap := r.Middleware()
+	ctx := context.Background()
+	wrap("planner", &model{reply: "plan"}).Call(ctx, core.Prompt{User: "hello"})
+	wrap("writer", &model{err: errors.New("rate limited")}).Call(ctx, core.Prompt{User: "write"})
+	tokens, err := wrap("writer", &model{reply: "draft"}).Stream(ctx, core.Prompt{User: "again"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range tokens {
+	}
+
+	calls := r.Calls()
+	if len(calls) != 3 {
+		t.Fatalf("recorded %d calls", len(calls))
+	}
+	if c := calls[0]; c.Agent != "planner" || c.Prompt.User != "hello" || c.Response.Content != "plan" || c.Error != "" {
+		t.Errorf("call 1 = %+v", c)
+	}
+	if c := calls[1]; c.Agent != "writer" || c.Error != "rate limited" {
+		t.Errorf("call 2 = %+v", c)
+	}
+	if c := calls[2]; c.Response.Content != "draft" {
+		t.Errorf("streamed call = %+v", c)
+	}
+}
+
+func TestReplayer(t *testing.T) {
+	r := NewReplayer([]Call{
+		{Agent: "planner", Prompt: core.Prompt{System: "plan", User: "hello"}, Response: core.Response{Content: "steps"}},
+		{Agent: "writer", Prompt: core.Prompt{System: "write", User: "steps"}, Response: core.Response{Content: "draft"}},
+		{Agent: "writer", Prompt: core.Prompt{System: "write", User: "again"}, Error: "rate limited"},
+	})
+	ctx := context.Background()
+
+	// The writer's calls are answered in its own order, whatever the planner does
+	if resp, err := r.ForAgent("writer").Call(ctx, core.Prompt{System: "write", User: "steps"}); err != nil || resp.Content != "draft" {
+		t.Errorf("writer = %q, %v", resp.Content, err)
+	}
+	if _, err := r.ForAgent("writer").Call(ctx, core.Prompt{System: "write", User: "again"}); err == nil || !strings.Contains(err.Error(), "rate limited") {
+		t.Errorf("recorded error = %v", err)
+	}
+	if _, err := r.ForAgent("writer").Call(ctx, core.Prompt{System: "write", User: "more"}); !errors.Is(err, ErrUnrecorded) {
+		t.Errorf("extra call err = %v", err)
+	}
+	// Unattributed calls match by prompt
+	tokens, err := r.Stream(ctx, core.Prompt{System: "plan", User: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok := <-tokens; tok.Content != "steps" {
+		t.Errorf("streamed %q", tok.Content)
+	}
+
+	drifts := r.Drifts()
+	if len(drifts) != 2 || drifts[0].Kind != "unrecorded" || drifts[1].Kind != "prompt" || !strings.Contains(drifts[1].Detail, "user prompt") {
+		t.Errorf("drifts = %v", drifts)
+	}
+}
+
+func TestReplayerUnused(t *testing.T) {
+	r := NewReplayer([]Call{{Agent: "planner", Prompt: core.Prompt{User: "hello"}}})
+	drifts := r.Drifts()
+	if len(drifts) != 1 || drifts[0].Kind != "unused" || drifts[0].Detail != "recorded call 1 by planner was not made" {
+		t.Errorf("drifts = %v", drifts)
+	}
+	if _, err := r.Embeddings(context.Background(), []string{"x"}); !errors.Is(err, ErrUnrecorded) {
+		t.Errorf("embeddings err = %v", err)
+	}
+}
+
+func TestRecordingUnwraps(t *testing.T) {
+	var r Recorder
+	llm := &model{reply: "plan"}
+	u, ok := r.Middleware()("planner", llm).(interface{ Unwrap() core.ModelProvider })
+	if !ok || u.Unwrap() != core.ModelProvider(llm) {
+		t.Error("recording provider does not unwrap to the one it wraps")
+	}
+}
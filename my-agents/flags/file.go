@@ -0,0 +1,82 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"slices"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Flag is a locally defined flag. It is on when Enabled is set and the
+// evaluation context passes the tenant allowlist and percentage rollout.
+//
+//	[flags.critic_loop]
+//	enabled = true
+//	percentage = 10          # of targeting keys, stable per key
+//	tenants = ["acme"]       # always on for these, regardless of percentage
+//	variant = "strict"       # returned by Variant when on
+type Flag struct {
+	Enabled    bool     `toml:"enabled"`
+	Percentage *int     `toml:"percentage"`
+	Tenants    []string `toml:"tenants"`
+	Variant    string   `toml:"variant"`
+}
+
+// FileProvider evaluates flags defined in a TOML file.
+type FileProvider struct {
+	Flags map[string]Flag `toml:"flags"`
+}
+
+// LoadFile reads flag definitions from path.
+func LoadFile(path string) (*FileProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read flags file %s: %w", path, err)
+	}
+	var p FileProvider
+	if err := toml.Unmarshal(data, &p); err != nil {
+		return nil, fmt.Errorf("failed to parse flags file %s: %w", path, err)
+	}
+	return &p, nil
+}
+
+func (p *FileProvider) Bool(ctx context.Context, flag string, def bool, ec EvalContext) (bool, error) {
+	f, ok := p.Flags[flag]
+	if !ok {
+		return def, nil
+	}
+	return f.on(flag, ec), nil
+}
+
+func (p *FileProvider) String(ctx context.Context, flag string, def string, ec EvalContext) (string, error) {
+	f, ok := p.Flags[flag]
+	if !ok || !f.on(flag, ec) || f.Variant == "" {
+		return def, nil
+	}
+	return f.Variant, nil
+}
+
+func (f Flag) on(name string, ec EvalContext) bool {
+	if !f.Enabled {
+		return false
+	}
+	if slices.Contains(f.Tenants, ec.Attributes[TenantKey]) {
+		return true
+	}
+	if f.Percentage == nil {
+		return len(f.Tenants) == 0
+	}
+	return bucket(name, ec.TargetingKey) < *f.Percentage
+}
+
+// bucket maps a flag/key pair to a stable value in [0, 100).
+func bucket(flag, key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(flag))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return int(h.Sum32() % 100)
+}
@@ -0,0 +1,111 @@
+// Package flags decouples workflow behavior rollouts from deploys. Flags are
+// evaluated per request against the tenant/user carried in event metadata,
+// from either a local file or an OpenFeature (OFREP) flag service.
+package flags
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/tenant"
+)
+
+// Metadata keys read from events when building an EvalContext.
+const (
+	TenantKey = tenant.MetadataKey
+	UserKey   = "user_id"
+)
+
+// EvalContext identifies who a flag is evaluated for. TargetingKey is the
+// stable identity used for percentage rollouts.
+type EvalContext struct {
+	TargetingKey string
+	Attributes   map[string]string
+}
+
+// Provider evaluates flags. Implementations return def when the flag is
+// unknown or cannot be evaluated.
+type Provider interface {
+	Bool(ctx context.Context, flag string, def bool, ec EvalContext) (bool, error)
+	String(ctx context.Context, flag string, def string, ec EvalContext) (string, error)
+}
+
+// Client wraps a Provider and swallows evaluation errors, falling back to
+// the supplied default so a flag outage never breaks a request.
+type Client struct {
+	provider Provider
+}
+
+// NewClient creates a client. A nil provider evaluates every flag to its default.
+func NewClient(provider Provider) *Client {
+	return &Client{provider: provider}
+}
+
+// Enabled reports whether a boolean flag is on for ec.
+func (c *Client) Enabled(ctx context.Context, flag string, ec EvalContext) bool {
+	if c == nil || c.provider == nil {
+		return false
+	}
+	v, err := c.provider.Bool(ctx, flag, false, ec)
+	if err != nil {
+		core.Logger().Warn().Str("flag", flag).Err(err).Msg("Feature flag evaluation failed; using default")
+		return false
+	}
+	return v
+}
+
+// Variant returns a string flag value for ec, or def.
+func (c *Client) Variant(ctx context.Context, flag, def string, ec EvalContext) string {
+	if c == nil || c.provider == nil {
+		return def
+	}
+	v, err := c.provider.String(ctx, flag, def, ec)
+	if err != nil {
+		core.Logger().Warn().Str("flag", flag).Err(err).Msg("Feature flag evaluation failed; using default")
+		return def
+	}
+	return v
+}
+
+// FromEvent builds an EvalContext from event metadata. The tenant is the
+// targeting key when present, then the user, then the session.
+func FromEvent(event core.Event) EvalContext {
+	ec := EvalContext{Attributes: make(map[string]string)}
+	for k, v := range event.GetMetadata() {
+		ec.Attributes[k] = v
+	}
+	for _, key := range []string{TenantKey, UserKey, core.SessionIDKey} {
+		if v := ec.Attributes[key]; v != "" {
+			ec.TargetingKey = v
+			break
+		}
+	}
+	return ec
+}
+
+// Config selects a flag provider.
+type Config struct {
+	Provider string `toml:"provider"` // "file" or "ofrep"; empty disables flags
+	Path     string `toml:"path"`     // file provider
+	URL      string `toml:"url"`      // ofrep provider base URL
+}
+
+// New creates a client from config.
+func New(cfg Config) (*Client, error) {
+	switch cfg.Provider {
+	case "":
+		return NewClient(nil), nil
+	case "file":
+		p, err := LoadFile(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		return NewClient(p), nil
+	case "ofrep", "openfeature":
+		return NewClient(NewOFREP(cfg.URL)), nil
+	default:
+		return nil, fmt.Errorf("unknown feature flag provider %q", cfg.Provider)
+	}
+}
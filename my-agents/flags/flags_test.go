@@ -0,0 +1,152 @@
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+const flagsFile = `
+[flags.critic_loop]
+enabled = true
+tenants = ["acme"]
+percentage = 0
+variant = "strict"
+
+[flags.everyone]
+enabled = true
+
+[flags.off]
+enabled = false
+variant = "never"
+
+[flags.half]
+enabled = true
+percentage = 50
+`
+
+func client(t *testing.T) *Client {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "flags.toml")
+	if err := os.WriteFile(path, []byte(flagsFile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := New(Config{Provider: "file", Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestFileFlags(t *testing.T) {
+	c := client(t)
+	ctx := context.Background()
+	acme := EvalContext{TargetingKey: "acme", Attributes: map[string]string{TenantKey: "acme"}}
+	globex := EvalContext{TargetingKey: "globex", Attributes: map[string]string{TenantKey: "globex"}}
+	for _, tc := range []struct {
+		flag string
+		ec   EvalContext
+		want bool
+	}{
+		{"critic_loop", acme, true},
+		{"critic_loop", globex, false},
+		{"everyone", globex, true},
+		{"off", acme, false},
+		{"unknown", acme, false},
+	} {
+		if got := c.Enabled(ctx, tc.flag, tc.ec); got != tc.want {
+			t.Errorf("Enabled(%s, %s) = %v, want %v", tc.flag, tc.ec.TargetingKey, got, tc.want)
+		}
+	}
+	if got := c.Variant(ctx, "critic_loop", "lenient", acme); got != "strict" {
+		t.Errorf("Variant for an allowlisted tenant = %q", got)
+	}
+	if got := c.Variant(ctx, "off", "lenient", acme); got != "lenient" {
+		t.Errorf("Variant of a flag that is off = %q, want the default", got)
+	}
+}
+
+func TestPercentageIsStablePerKey(t *testing.T) {
+	c := client(t)
+	on := 0
+	for i := range 1000 {
+		ec := EvalContext{TargetingKey: string(rune('a'+i%26)) + string(rune('a'+i/26))}
+		first := c.Enabled(context.Background(), "half", ec)
+		if c.Enabled(context.Background(), "half", ec) != first {
+			t.Fatalf("the rollout flipped for %s", ec.TargetingKey)
+		}
+		if first {
+			on++
+		}
+	}
+	if on < 400 || on > 600 {
+		t.Errorf("a 50%% rollout was on for %d of 1000 keys", on)
+	}
+}
+
+func TestNilClientUsesDefaults(t *testing.T) {
+	var c *Client
+	if c.Enabled(context.Background(), "x", EvalContext{}) || c.Variant(context.Background(), "x", "d", EvalContext{}) != "d" {
+		t.Error("a nil client didn't fall back to the defaults")
+	}
+	if _, err := New(Config{Provider: "launchdarkly"}); err == nil {
+		t.Error("an unknown provider was accepted")
+	}
+}
+
+func TestFromEventTargeting(t *testing.T) {
+	for _, tc := range []struct {
+		md   map[string]string
+		want string
+	}{
+		{map[string]string{TenantKey: "acme", UserKey: "ann", core.SessionIDKey: "s"}, "acme"},
+		{map[string]string{UserKey: "ann", core.SessionIDKey: "s"}, "ann"},
+		{map[string]string{core.SessionIDKey: "s"}, "s"},
+	} {
+		if got := FromEvent(core.NewEvent("a", nil, tc.md)).TargetingKey; got != tc.want {
+			t.Errorf("targeting key of %v = %q, want %q", tc.md, got, tc.want)
+		}
+	}
+}
+
+func TestOFREP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Context map[string]any `json:"context"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		switch r.URL.Path {
+		case "/ofrep/v1/evaluate/flags/beta":
+			json.NewEncoder(w).Encode(map[string]any{"value": body.Context["targetingKey"] == "acme"})
+		case "/ofrep/v1/evaluate/flags/model":
+			json.NewEncoder(w).Encode(map[string]any{"value": "large"})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]any{"errorCode": "FLAG_NOT_FOUND"})
+		}
+	}))
+	defer srv.Close()
+	c, err := New(Config{Provider: "ofrep", URL: srv.URL + "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	if !c.Enabled(ctx, "beta", EvalContext{TargetingKey: "acme"}) || c.Enabled(ctx, "beta", EvalContext{TargetingKey: "globex"}) {
+		t.Error("the service's evaluation wasn't used")
+	}
+	if got := c.Variant(ctx, "model", "small", EvalContext{}); got != "large" {
+		t.Errorf("Variant = %q", got)
+	}
+	if got := c.Variant(ctx, "missing", "small", EvalContext{}); got != "small" {
+		t.Errorf("Variant of an unknown flag = %q, want the default", got)
+	}
+	if c.Enabled(ctx, "model", EvalContext{}) {
+		t.Error("a string flag evaluated as on")
+	}
+}
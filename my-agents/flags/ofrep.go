@@ -0,0 +1,86 @@
+package flags
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// OFREPProvider evaluates flags against an OpenFeature Remote Evaluation
+// Protocol service (flagd, GO Feature Flag, and most OpenFeature vendors).
+type OFREPProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewOFREP creates a provider for the OFREP service at baseURL.
+func NewOFREP(baseURL string) *OFREPProvider {
+	return &OFREPProvider{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  &http.Client{Timeout: 2 * time.Second},
+	}
+}
+
+func (p *OFREPProvider) Bool(ctx context.Context, flag string, def bool, ec EvalContext) (bool, error) {
+	value, err := p.evaluate(ctx, flag, ec)
+	if err != nil {
+		return def, err
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return def, fmt.Errorf("flag %s is not a boolean", flag)
+	}
+	return b, nil
+}
+
+func (p *OFREPProvider) String(ctx context.Context, flag string, def string, ec EvalContext) (string, error) {
+	value, err := p.evaluate(ctx, flag, ec)
+	if err != nil {
+		return def, err
+	}
+	s, ok := value.(string)
+	if !ok {
+		return def, fmt.Errorf("flag %s is not a string", flag)
+	}
+	return s, nil
+}
+
+func (p *OFREPProvider) evaluate(ctx context.Context, flag string, ec EvalContext) (any, error) {
+	evalCtx := map[string]any{"targetingKey": ec.TargetingKey}
+	for k, v := range ec.Attributes {
+		evalCtx[k] = v
+	}
+	body, err := json.Marshal(map[string]any{"context": evalCtx})
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := p.baseURL + "/ofrep/v1/evaluate/flags/" + url.PathEscape(flag)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		Value     any    `json:"value"`
+		ErrorCode string `json:"errorCode"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return nil, fmt.Errorf("failed to decode OFREP response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || out.ErrorCode != "" {
+		return nil, fmt.Errorf("OFREP evaluation of %s failed: %s %s", flag, resp.Status, out.ErrorCode)
+	}
+	return out.Value, nil
+}
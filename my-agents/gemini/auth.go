@@ -0,0 +1,177 @@
+package gemini
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cloudPlatformScope is the OAuth scope Vertex AI calls need.
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// metadataTokenURL is where code on Google Cloud gets its service
+// account's access token.
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// tokenSource provides OAuth access tokens for Vertex AI, refreshing them a
+// minute before they expire.
+type tokenSource struct {
+	http  *http.Client
+	fetch func(ctx context.Context, client *http.Client) (token string, expiresIn int, err error)
+
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+// Token returns a valid access token.
+func (t *tokenSource) Token(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token != "" && time.Until(t.expires) > time.Minute {
+		return t.token, nil
+	}
+	token, expiresIn, err := t.fetch(ctx, t.http)
+	if err != nil {
+		return "", fmt.Errorf("gemini: vertex credentials: %w", err)
+	}
+	t.token, t.expires = token, time.Now().Add(time.Duration(expiresIn)*time.Second)
+	return t.token, nil
+}
+
+// newTokenSource reads the service account key at path, falling back to
+// $GOOGLE_APPLICATION_CREDENTIALS and then to the metadata server of the
+// Google Cloud machine it runs on.
+func newTokenSource(path string) (*tokenSource, error) {
+	if path == "" {
+		path = os.Getenv("GOOGLE_APPLICATION_CREDENTIALS")
+	}
+	t := &tokenSource{http: &http.Client{Timeout: 30 * time.Second}, fetch: metadataToken}
+	if path == "" {
+		return t, nil
+	}
+	key, err := readServiceAccount(path)
+	if err != nil {
+		return nil, err
+	}
+	t.fetch = key.token
+	return t, nil
+}
+
+// serviceAccount is the part of a service account key file used to sign
+// token requests.
+type serviceAccount struct {
+	ClientEmail  string `json:"client_email"`
+	PrivateKeyID string `json:"private_key_id"`
+	PrivateKey   string `json:"private_key"`
+	TokenURI     string `json:"token_uri"`
+
+	key *rsa.PrivateKey
+}
+
+func readServiceAccount(path string) (*serviceAccount, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("gemini: vertex credentials: %w", err)
+	}
+	var sa serviceAccount
+	if err := json.Unmarshal(data, &sa); err != nil {
+		return nil, fmt.Errorf("gemini: vertex credentials %s: %w", path, err)
+	}
+	if sa.ClientEmail == "" || sa.PrivateKey == "" {
+		return nil, fmt.Errorf("gemini: vertex credentials %s: not a service account key", path)
+	}
+	block, _ := pem.Decode([]byte(sa.PrivateKey))
+	if block == nil {
+		return nil, fmt.Errorf("gemini: vertex credentials %s: private_key is not PEM", path)
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		if parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes); err != nil {
+			return nil, fmt.Errorf("gemini: vertex credentials %s: %w", path, err)
+		}
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("gemini: vertex credentials %s: private_key is not RSA", path)
+	}
+	sa.key = key
+	if sa.TokenURI == "" {
+		sa.TokenURI = "https://oauth2.googleapis.com/token"
+	}
+	return &sa, nil
+}
+
+// token exchanges a signed JWT assertion for an access token.
+func (sa *serviceAccount) token(ctx context.Context, client *http.Client) (string, int, error) {
+	now := time.Now()
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": sa.PrivateKeyID})
+	claims, _ := json.Marshal(map[string]any{
+		"iss": sa.ClientEmail, "scope": cloudPlatformScope, "aud": sa.TokenURI,
+		"iat": now.Unix(), "exp": now.Add(time.Hour).Unix(),
+	})
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+	digest := sha256.Sum256([]byte(unsigned))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, sa.key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", 0, err
+	}
+	form := url.Values{
+		"grant_type": {"urn:ietf:params:oauth:grant-type:jwt-bearer"},
+		"assertion":  {unsigned + "." + base64.RawURLEncoding.EncodeToString(signature)},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sa.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return accessToken(client, req)
+}
+
+// metadataToken asks the metadata server for the machine's token.
+func metadataToken(ctx context.Context, client *http.Client) (string, int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, metadataTokenURL, nil)
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	token, expiresIn, err := accessToken(client, req)
+	if err != nil {
+		return "", 0, fmt.Errorf("no credentials_file or $GOOGLE_APPLICATION_CREDENTIALS, and no metadata server: %w", err)
+	}
+	return token, expiresIn, nil
+}
+
+func accessToken(client *http.Client, req *http.Request) (string, int, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode != http.StatusOK {
+		return "", 0, fmt.Errorf("token endpoint returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	var out struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &out); err != nil || out.AccessToken == "" {
+		return "", 0, fmt.Errorf("token endpoint returned no access token")
+	}
+	return out.AccessToken, out.ExpiresIn, nil
+}
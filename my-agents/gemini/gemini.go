@@ -0,0 +1,439 @@
+// Package gemini calls Google's Gemini models, through the Gemini API with
+// an AI Studio key or through Vertex AI with Google Cloud credentials. The
+// prompt's system text is sent as the system instruction, safety settings
+// pass through from the provider table, and images a request carries (see
+// package media) are sent along with its text.
+package gemini
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/httpclient"
+	"my-agents/media"
+	"my-agents/ratelimit"
+)
+
+// Type is the provider type of a [providers.<name>] table.
+const Type = "gemini"
+
+// Defaults for unset settings.
+const (
+	DefaultStudioURL      = "https://generativelanguage.googleapis.com/v1beta"
+	DefaultLocation       = "us-central1"
+	DefaultEmbeddingModel = "text-embedding-004"
+	DefaultTimeout        = 2 * time.Minute
+)
+
+// KeyEnv holds the AI Studio API key when the table has no api_key.
+const KeyEnv = "GEMINI_API_KEY"
+
+// Options are the settings of a gemini [providers.<name>] table besides
+// agenticgokit's type, model, api_key, base_url, max_tokens, temperature
+// and http_timeout. Setting project selects Vertex AI:
+//
+//	[providers.gemini]
+//	type = "gemini"
+//	model = "gemini-2.0-flash"
+//	project = "my-project"
+//	location = "europe-west4"
+//	[providers.gemini.safety_settings]
+//	harassment = "BLOCK_ONLY_HIGH"
+type Options struct {
+	// Project and Location locate Vertex AI; without a project the Gemini
+	// API is called with an AI Studio key.
+	Project  string `toml:"project"`
+	Location string `toml:"location"` // default DefaultLocation
+	// CredentialsFile is a service account key for Vertex AI (default
+	// $GOOGLE_APPLICATION_CREDENTIALS, else the metadata server's).
+	CredentialsFile string `toml:"credentials_file"`
+	// SafetySettings map harm categories, with or without their
+	// HARM_CATEGORY_ prefix, to block thresholds, as the API names them.
+	SafetySettings map[string]string `toml:"safety_settings"`
+	// EmbeddingModel is the table's embedding_model, which the local
+	// providers read too (default DefaultEmbeddingModel).
+	EmbeddingModel string `toml:"-"`
+}
+
+// Provider calls one Gemini model.
+type Provider struct {
+	http           *http.Client
+	base           string // URL the model paths are under
+	apiKey         string // AI Studio
+	tokens         *tokenSource
+	vertex         bool
+	model          string
+	embeddingModel string
+	maxTokens      int
+	temperature    float64
+	safety         []safetySetting
+}
+
+type safetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// New creates the provider cfg configures.
+func New(cfg core.LLMProviderConfig, opts Options) (*Provider, error) {
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("gemini provider needs a model")
+	}
+	p := &Provider{
+		http:           httpclient.New(DefaultTimeout),
+		model:          cfg.Model,
+		embeddingModel: opts.EmbeddingModel,
+		maxTokens:      cfg.MaxTokens,
+		temperature:    cfg.Temperature,
+	}
+	if cfg.HTTPTimeout > 0 {
+		p.http = httpclient.New(cfg.HTTPTimeout)
+	}
+	if p.embeddingModel == "" {
+		p.embeddingModel = DefaultEmbeddingModel
+	}
+	for category, threshold := range opts.SafetySettings {
+		category = strings.ToUpper(category)
+		if !strings.HasPrefix(category, "HARM_CATEGORY_") {
+			category = "HARM_CATEGORY_" + category
+		}
+		p.safety = append(p.safety, safetySetting{Category: category, Threshold: strings.ToUpper(threshold)})
+	}
+
+	if opts.Project != "" {
+		location := opts.Location
+		if location == "" {
+			location = DefaultLocation
+		}
+		host := location + "-aiplatform.googleapis.com"
+		if location == "global" {
+			host = "aiplatform.googleapis.com"
+		}
+		p.vertex = true
+		p.base = fmt.Sprintf("https://%s/v1/projects/%s/locations/%s/publishers/google", host, opts.Project, location)
+		if cfg.BaseURL != "" {
+			p.base = strings.TrimSuffix(cfg.BaseURL, "/")
+		}
+		var err error
+		if p.tokens, err = newTokenSource(opts.CredentialsFile); err != nil {
+			return nil, err
+		}
+		return p, nil
+	}
+
+	p.base = DefaultStudioURL
+	if cfg.BaseURL != "" {
+		p.base = strings.TrimSuffix(cfg.BaseURL, "/")
+	}
+	if p.apiKey = cfg.APIKey; p.apiKey == "" {
+		p.apiKey = os.Getenv(KeyEnv)
+	}
+	if p.apiKey == "" {
+		return nil, fmt.Errorf("gemini provider needs api_key or $%s, or a Vertex AI project", KeyEnv)
+	}
+	return p, nil
+}
+
+// part is a piece of a message: text, inline bytes or a file reference.
+type part struct {
+	Text       string      `json:"text,omitempty"`
+	InlineData *inlineData `json:"inlineData,omitempty"`
+	FileData   *fileData   `json:"fileData,omitempty"`
+}
+
+type inlineData struct {
+	MIMEType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+type fileData struct {
+	MIMEType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
+type content struct {
+	Role  string `json:"role,omitempty"`
+	Parts []part `json:"parts"`
+}
+
+type generationConfig struct {
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	Temperature     *float64 `json:"temperature,omitempty"`
+}
+
+type request struct {
+	SystemInstruction *content         `json:"systemInstruction,omitempty"`
+	Contents          []content        `json:"contents"`
+	GenerationConfig  generationConfig `json:"generationConfig"`
+	SafetySettings    []safetySetting  `json:"safetySettings,omitempty"`
+}
+
+// response is a generateContent response, or one event of a streamed one.
+type response struct {
+	Candidates []struct {
+		Content      content `json:"content"`
+		FinishReason string  `json:"finishReason"`
+	} `json:"candidates"`
+	PromptFeedback struct {
+		BlockReason string `json:"blockReason"`
+	} `json:"promptFeedback"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+// text is the reply's text, or an error when the prompt or reply was
+// blocked.
+func (r response) text() (string, string, error) {
+	if r.PromptFeedback.BlockReason != "" {
+		return "", "", fmt.Errorf("gemini: prompt blocked: %s", r.PromptFeedback.BlockReason)
+	}
+	if len(r.Candidates) == 0 {
+		return "", "", nil
+	}
+	c := r.Candidates[0]
+	if c.FinishReason == "SAFETY" || c.FinishReason == "PROHIBITED_CONTENT" || c.FinishReason == "BLOCKLIST" {
+		return "", c.FinishReason, fmt.Errorf("gemini: reply blocked: %s", c.FinishReason)
+	}
+	var b strings.Builder
+	for _, p := range c.Content.Parts {
+		b.WriteString(p.Text)
+	}
+	return b.String(), c.FinishReason, nil
+}
+
+// request builds the body for prompt and the images ctx carries; the
+// prompt's parameters win over the table's.
+func (p *Provider) request(ctx context.Context, prompt core.Prompt) request {
+	user := content{Role: "user", Parts: []part{{Text: prompt.User}}}
+	for _, img := range media.Images(ctx) {
+		if img.URI != "" {
+			user.Parts = append(user.Parts, part{FileData: &fileData{MIMEType: img.MIMEType, FileURI: img.URI}})
+			continue
+		}
+		user.Parts = append(user.Parts, part{InlineData: &inlineData{MIMEType: img.MIMEType, Data: base64.StdEncoding.EncodeToString(img.Data)}})
+	}
+	req := request{Contents: []content{user}, SafetySettings: p.safety}
+	if prompt.System != "" {
+		req.SystemInstruction = &content{Parts: []part{{Text: prompt.System}}}
+	}
+	req.GenerationConfig.MaxOutputTokens = p.maxTokens
+	if t := prompt.Parameters.MaxTokens; t != nil && *t > 0 {
+		req.GenerationConfig.MaxOutputTokens = int(*t)
+	}
+	if t := prompt.Parameters.Temperature; t != nil {
+		v := float64(*t)
+		req.GenerationConfig.Temperature = &v
+	} else if p.temperature > 0 {
+		req.GenerationConfig.Temperature = &p.temperature
+	}
+	return req
+}
+
+// post sends in to the model method, e.g. "generateContent", and returns
+// the response body, which the caller closes. Error responses are reported
+// with their status, which the retry and fallback policies classify.
+func (p *Provider) post(ctx context.Context, model, method, query string, in any) (io.ReadCloser, error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	endpoint := p.base + "/models/" + model + ":" + method
+	if query != "" {
+		endpoint += "?" + query
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.vertex {
+		token, err := p.tokens.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	} else {
+		req.Header.Set("x-goog-api-key", p.apiKey)
+	}
+	resp, err := p.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("gemini: %w", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, ratelimit.WithRetryAfter(fmt.Errorf("gemini: %s returned status %d: %s", method, resp.StatusCode, errorMessage(msg)), resp.Header)
+	}
+	return resp.Body, nil
+}
+
+// errorMessage renders a Google API error body as "STATUS: message", or
+// as it is when it isn't one.
+func errorMessage(body []byte) string {
+	var e struct {
+		Error struct {
+			Status  string `json:"status"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &e) != nil || e.Error.Message == "" {
+		return strings.TrimSpace(string(body))
+	}
+	return e.Error.Status + ": " + e.Error.Message
+}
+
+func (p *Provider) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	body, err := p.post(ctx, p.model, "generateContent", "", p.request(ctx, prompt))
+	if err != nil {
+		return core.Response{}, err
+	}
+	defer body.Close()
+	var out response
+	if err := json.NewDecoder(body).Decode(&out); err != nil {
+		return core.Response{}, fmt.Errorf("gemini: failed to decode response: %w", err)
+	}
+	text, finish, err := out.text()
+	if err != nil {
+		return core.Response{}, err
+	}
+	u := out.UsageMetadata
+	return core.Response{
+		Content:      text,
+		Usage:        core.UsageStats{PromptTokens: u.PromptTokenCount, CompletionTokens: u.CandidatesTokenCount, TotalTokens: u.TotalTokenCount},
+		FinishReason: finish,
+	}, nil
+}
+
+// Stream sends the text of each server-sent event of a streamed reply.
+func (p *Provider) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	body, err := p.post(ctx, p.model, "streamGenerateContent", "alt=sse", p.request(ctx, prompt))
+	if err != nil {
+		return nil, err
+	}
+	tokens := make(chan core.Token)
+	go func() {
+		defer close(tokens)
+		defer body.Close()
+		send := func(t core.Token) bool {
+			select {
+			case tokens <- t:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			data, ok := bytes.CutPrefix(scanner.Bytes(), []byte("data:"))
+			if !ok {
+				continue
+			}
+			var event response
+			if err := json.Unmarshal(bytes.TrimSpace(data), &event); err != nil {
+				send(core.Token{Error: fmt.Errorf("gemini: bad stream event: %w", err)})
+				return
+			}
+			text, _, err := event.text()
+			if err != nil {
+				send(core.Token{Error: err})
+				return
+			}
+			if text != "" && !send(core.Token{Content: text}) {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			send(core.Token{Error: fmt.Errorf("gemini: %w", err)})
+		}
+	}()
+	return tokens, nil
+}
+
+// maxEmbedBatch bounds the texts embedded per request.
+const maxEmbedBatch = 100
+
+// Embeddings embeds texts with the embedding model: batchEmbedContents on
+// the Gemini API, predict on Vertex AI.
+func (p *Provider) Embeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	vectors := make([][]float64, 0, len(texts))
+	for start := 0; start < len(texts); start += maxEmbedBatch {
+		batch := texts[start:min(start+maxEmbedBatch, len(texts))]
+		v, err := p.embed(ctx, batch)
+		if err != nil {
+			return nil, err
+		}
+		if len(v) != len(batch) {
+			return nil, fmt.Errorf("gemini: %d embeddings for %d texts", len(v), len(batch))
+		}
+		vectors = append(vectors, v...)
+	}
+	return vectors, nil
+}
+
+func (p *Provider) embed(ctx context.Context, texts []string) ([][]float64, error) {
+	var vectors [][]float64
+	if p.vertex {
+		instances := make([]map[string]string, len(texts))
+		for i, t := range texts {
+			instances[i] = map[string]string{"content": t}
+		}
+		var out struct {
+			Predictions []struct {
+				Embeddings struct {
+					Values []float64 `json:"values"`
+				} `json:"embeddings"`
+			} `json:"predictions"`
+		}
+		if err := p.decode(ctx, "predict", map[string]any{"instances": instances}, &out); err != nil {
+			return nil, err
+		}
+		for _, pr := range out.Predictions {
+			vectors = append(vectors, pr.Embeddings.Values)
+		}
+		return vectors, nil
+	}
+	requests := make([]map[string]any, len(texts))
+	for i, t := range texts {
+		requests[i] = map[string]any{"model": "models/" + p.embeddingModel, "content": content{Parts: []part{{Text: t}}}}
+	}
+	var out struct {
+		Embeddings []struct {
+			Values []float64 `json:"values"`
+		} `json:"embeddings"`
+	}
+	if err := p.decode(ctx, "batchEmbedContents", map[string]any{"requests": requests}, &out); err != nil {
+		return nil, err
+	}
+	for _, e := range out.Embeddings {
+		vectors = append(vectors, e.Values)
+	}
+	return vectors, nil
+}
+
+// decode calls the embedding model's method and decodes the response.
+func (p *Provider) decode(ctx context.Context, method string, in, out any) error {
+	body, err := p.post(ctx, p.embeddingModel, method, "", in)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := json.NewDecoder(body).Decode(out); err != nil {
+		return fmt.Errorf("gemini: failed to decode %s response: %w", method, err)
+	}
+	return nil
+}
@@ -0,0 +1,271 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/media"
+	"my-agents/ratelimit"
+)
+
+// api is a fake Gemini API keeping the last request it was sent.
+type api struct {
+	path   string
+	header http.Header
+	body   map[string]any
+	// respond answers; by default with a reply of "Go is fast."
+	respond func(w http.ResponseWriter)
+}
+
+func (a *api) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.path, a.header, a.body = r.URL.RequestURI(), r.Header.Clone(), nil
+	json.NewDecoder(r.Body).Decode(&a.body)
+	if a.respond != nil {
+		a.respond(w)
+		return
+	}
+	fmt.Fprint(w, `{"candidates": [{"content": {"parts": [{"text": "Go "}, {"text": "is fast."}]}, "finishReason": "STOP"}], "usageMetadata": {"promptTokenCount": 8, "candidatesTokenCount": 4, "totalTokenCount": 12}}`)
+}
+
+func studio(t *testing.T, a *api) *Provider {
+	t.Helper()
+	srv := httptest.NewServer(a)
+	t.Cleanup(srv.Close)
+	p, err := New(core.LLMProviderConfig{Model: "gemini-2.0-flash", APIKey: "key", BaseURL: srv.URL + "/", MaxTokens: 300}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestNew(t *testing.T) {
+	t.Setenv(KeyEnv, "")
+	if _, err := New(core.LLMProviderConfig{}, Options{}); err == nil {
+		t.Error("created without a model")
+	}
+	if _, err := New(core.LLMProviderConfig{Model: "gemini-2.0-flash"}, Options{}); err == nil {
+		t.Error("created without a key or project")
+	}
+	t.Setenv(KeyEnv, "env-key")
+	p, err := New(core.LLMProviderConfig{Model: "gemini-2.0-flash"}, Options{SafetySettings: map[string]string{"harassment": "block_only_high"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.apiKey != "env-key" || p.base != DefaultStudioURL || p.vertex || p.embeddingModel != DefaultEmbeddingModel {
+		t.Errorf("studio provider = %+v", p)
+	}
+	if len(p.safety) != 1 || p.safety[0] != (safetySetting{"HARM_CATEGORY_HARASSMENT", "BLOCK_ONLY_HIGH"}) {
+		t.Errorf("safety settings = %+v", p.safety)
+	}
+
+	// Vertex AI falls back on the metadata server without a key file
+	t.Setenv("GOOGLE_APPLICATION_CREDENTIALS", "")
+	for location, base := range map[string]string{
+		"":       "https://us-central1-aiplatform.googleapis.com/v1/projects/p/locations/us-central1/publishers/google",
+		"global": "https://aiplatform.googleapis.com/v1/projects/p/locations/global/publishers/google",
+	} {
+		p, err := New(core.LLMProviderConfig{Model: "gemini-2.0-flash"}, Options{Project: "p", Location: location})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !p.vertex || p.base != base {
+			t.Errorf("vertex in %q = %s", location, p.base)
+		}
+	}
+	if _, err := New(core.LLMProviderConfig{Model: "m"}, Options{Project: "p", CredentialsFile: "none.json"}); err == nil {
+		t.Error("missing credentials file accepted")
+	}
+}
+
+func TestRequest(t *testing.T) {
+	p := &Provider{maxTokens: 300, temperature: 0.4}
+	ctx := media.WithImages(context.Background(), media.Image{MIMEType: "image/png", Data: []byte("png")}, media.Image{MIMEType: "image/jpeg", URI: "gs://b/a.jpg"})
+	req := p.request(ctx, core.Prompt{System: "Be brief.", User: "What's this?"})
+	parts := req.Contents[0].Parts
+	if len(parts) != 3 || parts[0].Text != "What's this?" || parts[1].InlineData.Data != "cG5n" || parts[2].FileData.FileURI != "gs://b/a.jpg" {
+		t.Errorf("parts = %+v", parts)
+	}
+	if req.SystemInstruction.Parts[0].Text != "Be brief." || req.GenerationConfig.MaxOutputTokens != 300 || *req.GenerationConfig.Temperature != 0.4 {
+		t.Errorf("request = %+v", req)
+	}
+	maxTokens, temperature := int32(20), float32(0)
+	req = p.request(context.Background(), core.Prompt{User: "hi", Parameters: core.ModelParameters{MaxTokens: &maxTokens, Temperature: &temperature}})
+	if req.SystemInstruction != nil || req.GenerationConfig.MaxOutputTokens != 20 || *req.GenerationConfig.Temperature != 0 {
+		t.Errorf("request with the prompt's parameters = %+v", req)
+	}
+}
+
+func TestCall(t *testing.T) {
+	a := &api{}
+	p := studio(t, a)
+	resp, err := p.Call(context.Background(), core.Prompt{User: "Is Go fast?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Go is fast." || resp.FinishReason != "STOP" || resp.Usage.TotalTokens != 12 {
+		t.Errorf("response = %+v", resp)
+	}
+	if a.path != "/models/gemini-2.0-flash:generateContent" || a.header.Get("x-goog-api-key") != "key" {
+		t.Errorf("sent to %s with %v", a.path, a.header)
+	}
+
+	tests := []struct {
+		status int
+		body   string
+		err    string
+	}{
+		{http.StatusOK, `{"promptFeedback": {"blockReason": "SAFETY"}}`, "gemini: prompt blocked: SAFETY"},
+		{http.StatusOK, `{"candidates": [{"finishReason": "PROHIBITED_CONTENT"}]}`, "gemini: reply blocked: PROHIBITED_CONTENT"},
+		{http.StatusTooManyRequests, `{"error": {"code": 429, "status": "RESOURCE_EXHAUSTED", "message": "Quota exceeded"}}`, "gemini: generateContent returned status 429: RESOURCE_EXHAUSTED: Quota exceeded"},
+		{http.StatusBadGateway, "bad gateway\n", "gemini: generateContent returned status 502: bad gateway"},
+		{http.StatusOK, "{", "gemini: failed to decode response: unexpected EOF"},
+	}
+	for _, tt := range tests {
+		a.respond = func(w http.ResponseWriter) {
+			w.WriteHeader(tt.status)
+			fmt.Fprint(w, tt.body)
+		}
+		if _, err := p.Call(context.Background(), core.Prompt{User: "hi"}); err == nil || err.Error() != tt.err {
+			t.Errorf("%s: %v", tt.body, err)
+		}
+	}
+	a.respond = func(w http.ResponseWriter) { fmt.Fprint(w, `{"candidates": []}`) }
+	if resp, err := p.Call(context.Background(), core.Prompt{User: "hi"}); err != nil || resp.Content != "" {
+		t.Errorf("no candidates = %+v, %v", resp, err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	p := studio(t, &api{respond: func(w http.ResponseWriter) {
+		w.Header().Set("Retry-After", "2")
+		w.WriteHeader(http.StatusTooManyRequests)
+		fmt.Fprint(w, `{"error": {"code": 429, "status": "RESOURCE_EXHAUSTED", "message": "Quota exceeded"}}`)
+	}})
+	_, err := p.Call(context.Background(), core.Prompt{User: "hi"})
+	var ra *ratelimit.RetryAfterError
+	if !errors.As(err, &ra) || ra.Wait != 2*time.Second {
+		t.Errorf("rate limited: %#v", err)
+	}
+}
+
+func TestStream(t *testing.T) {
+	events := []string{
+		`{"candidates": [{"content": {"parts": [{"text": "Go "}]}}]}`,
+		`{"candidates": []}`,
+		`{"candidates": [{"content": {"parts": [{"text": "is fast."}]}, "finishReason": "STOP"}]}`,
+	}
+	a := &api{respond: func(w http.ResponseWriter) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, e := range events {
+			fmt.Fprintf(w, "data: %s\r\n\r\n", e)
+		}
+	}}
+	p := studio(t, a)
+	stream := func() (string, error) {
+		t.Helper()
+		tokens, err := p.Stream(context.Background(), core.Prompt{User: "hi"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var text strings.Builder
+		for tok := range tokens {
+			if tok.Error != nil {
+				return text.String(), tok.Error
+			}
+			text.WriteString(tok.Content)
+		}
+		return text.String(), nil
+	}
+	if text, err := stream(); text != "Go is fast." || err != nil {
+		t.Errorf("streamed %q, %v", text, err)
+	}
+	if a.path != "/models/gemini-2.0-flash:streamGenerateContent?alt=sse" {
+		t.Errorf("streamed from %s", a.path)
+	}
+
+	events = []string{events[0], `{"candidates": [{"finishReason": "SAFETY"}]}`}
+	if text, err := stream(); text != "Go " || err == nil || err.Error() != "gemini: reply blocked: SAFETY" {
+		t.Errorf("blocked stream: %q, %v", text, err)
+	}
+	events = []string{"{"}
+	if _, err := stream(); err == nil || !strings.Contains(err.Error(), "bad stream event") {
+		t.Errorf("bad event: %v", err)
+	}
+}
+
+func TestEmbeddings(t *testing.T) {
+	var batches []int
+	a := &api{}
+	a.respond = func(w http.ResponseWriter) {
+		requests := a.body["requests"].([]any)
+		batches = append(batches, len(requests))
+		var out []string
+		for range requests {
+			out = append(out, `{"values": [0.1, 0.2]}`)
+		}
+		fmt.Fprintf(w, `{"embeddings": [%s]}`, strings.Join(out, ","))
+	}
+	p := studio(t, a)
+	texts := make([]string, 150)
+	vectors, err := p.Embeddings(context.Background(), texts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 150 || fmt.Sprint(batches) != "[100 50]" || a.path != "/models/"+DefaultEmbeddingModel+":batchEmbedContents" {
+		t.Errorf("%d vectors in batches %v from %s", len(vectors), batches, a.path)
+	}
+	if model := a.body["requests"].([]any)[0].(map[string]any)["model"]; model != "models/"+DefaultEmbeddingModel {
+		t.Errorf("embedded with %v", model)
+	}
+
+	a.respond = func(w http.ResponseWriter) { fmt.Fprint(w, `{"embeddings": []}`) }
+	if _, err := p.Embeddings(context.Background(), []string{"a"}); err == nil || err.Error() != "gemini: 0 embeddings for 1 texts" {
+		t.Errorf("short response: %v", err)
+	}
+	a.respond = func(w http.ResponseWriter) { fmt.Fprint(w, `[`) }
+	if _, err := p.Embeddings(context.Background(), []string{"a"}); err == nil || !strings.Contains(err.Error(), "failed to decode batchEmbedContents response") {
+		t.Errorf("bad response: %v", err)
+	}
+}
+
+func TestVertex(t *testing.T) {
+	tokens := tokenServer(t)
+	a := &api{}
+	srv := httptest.NewServer(a)
+	defer srv.Close()
+	p, err := New(core.LLMProviderConfig{Model: "gemini-2.0-flash", BaseURL: srv.URL}, Options{Project: "p", CredentialsFile: writeKey(t, tokens.URL+"/token", false), EmbeddingModel: "text-embedding-005"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Call(context.Background(), core.Prompt{User: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if a.header.Get("Authorization") != "Bearer token-1" || a.header.Get("x-goog-api-key") != "" {
+		t.Errorf("headers = %v", a.header)
+	}
+
+	a.respond = func(w http.ResponseWriter) {
+		fmt.Fprint(w, `{"predictions": [{"embeddings": {"values": [0.1]}}, {"embeddings": {"values": [0.2]}}]}`)
+	}
+	vectors, err := p.Embeddings(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	instances := a.body["instances"].([]any)
+	if len(vectors) != 2 || vectors[1][0] != 0.2 || a.path != "/models/text-embedding-005:predict" || instances[1].(map[string]any)["content"] != "b" {
+		t.Errorf("vectors %v from %s with %v", vectors, a.path, a.body)
+	}
+	// The token is reused until it nears expiry
+	if a.header.Get("Authorization") != "Bearer token-1" {
+		t.Errorf("headers = %v", a.header)
+	}
+}
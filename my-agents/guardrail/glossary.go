@@ -0,0 +1,176 @@
+package guardrail
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/BurntSushi/toml"
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Glossary is the [guardrail.glossary] table: the domain terminology final
+// responses keep to. It is described in the formatter's prompt and checked
+// on what the model returns: avoided variants of a preferred term and
+// miscased product names are corrected in place, and responses using a
+// banned term are reworded.
+//
+//	[guardrail.glossary]
+//	file = "glossary.toml"   # more of the same entries, kept apart
+//	products = ["AgentFlow", "FlowHub"]
+//	banned = ["cheap", "guarantee"]
+//	[[guardrail.glossary.terms]]
+//	term = "sign in"
+//	avoid = ["log in", "login"]
+//	definition = "authenticate to the console"
+type Glossary struct {
+	File     string   `toml:"file"`
+	Products []string `toml:"products"` // spelled and cased exactly as given
+	Banned   []string `toml:"banned"`
+	Terms    []Term   `toml:"terms"`
+}
+
+// Term is a preferred term and the variants to write it instead of.
+type Term struct {
+	Term       string   `toml:"term"`
+	Avoid      []string `toml:"avoid"`
+	Definition string   `toml:"definition"`
+}
+
+// load merges the glossary file, if any, into g.
+func (g Glossary) load() (Glossary, error) {
+	if g.File == "" {
+		return g, nil
+	}
+	var file Glossary
+	if _, err := toml.DecodeFile(g.File, &file); err != nil {
+		return Glossary{}, fmt.Errorf("glossary %s: %w", g.File, err)
+	}
+	return g.merge(file), nil
+}
+
+// merge returns g with other's entries added.
+func (g Glossary) merge(other Glossary) Glossary {
+	return Glossary{
+		Products: append(slices.Clone(g.Products), other.Products...),
+		Banned:   append(slices.Clone(g.Banned), other.Banned...),
+		Terms:    append(slices.Clone(g.Terms), other.Terms...),
+	}
+}
+
+// glossary is a compiled Glossary.
+type glossary struct {
+	terms     []Term
+	avoidRe   []*words // every term's variants, as one pattern per term
+	products  []string
+	productRe []*words
+	banned    []string
+	bannedRe  []*words
+}
+
+func compileGlossary(g Glossary) (*glossary, error) {
+	c := &glossary{terms: g.Terms, products: g.Products, banned: g.Banned}
+	for _, t := range g.Terms {
+		if t.Term == "" {
+			return nil, fmt.Errorf("glossary term with no term (avoid %q)", t.Avoid)
+		}
+		if len(t.Avoid) == 0 {
+			c.avoidRe = append(c.avoidRe, nil)
+			continue
+		}
+		// Longest first, as the first alternative matching at a place is
+		// the one tried as a whole word
+		avoid := slices.Clone(t.Avoid)
+		slices.SortStableFunc(avoid, func(a, b string) int { return len(b) - len(a) })
+		alts := make([]string, len(avoid))
+		for i, v := range avoid {
+			alts[i] = regexp.QuoteMeta(v)
+		}
+		c.avoidRe = append(c.avoidRe, wordPattern(`(?:`+strings.Join(alts, "|")+`)`))
+	}
+	for _, p := range g.Products {
+		c.productRe = append(c.productRe, termPattern(p))
+	}
+	for _, b := range g.Banned {
+		c.bannedRe = append(c.bannedRe, termPattern(b))
+	}
+	return c, nil
+}
+
+// correct writes preferred terms for their variants, keeping a capital at
+// the start, and product names as given.
+func (c *glossary) correct(text string) string {
+	for i, re := range c.avoidRe {
+		if re == nil {
+			continue
+		}
+		term := c.terms[i].Term
+		text = re.ReplaceAllStringFunc(text, func(m string) string {
+			if r, _ := utf8.DecodeRuneInString(m); unicode.IsUpper(r) {
+				first, size := utf8.DecodeRuneInString(term)
+				return string(unicode.ToUpper(first)) + term[size:]
+			}
+			return term
+		})
+	}
+	for i, re := range c.productRe {
+		text = re.ReplaceAllStringFunc(text, func(string) string { return c.products[i] })
+	}
+	return text
+}
+
+// instructions describes the glossary for a prompt.
+func (c *glossary) instructions() string {
+	var parts []string
+	for _, t := range c.terms {
+		rule := fmt.Sprintf("%q", t.Term)
+		if t.Definition != "" {
+			rule += fmt.Sprintf(" (%s)", t.Definition)
+		}
+		if len(t.Avoid) > 0 {
+			rule += ", not " + quoted(t.Avoid, " or ")
+		}
+		parts = append(parts, "use "+rule)
+	}
+	if len(c.products) > 0 {
+		parts = append(parts, "spell product names exactly as "+quoted(c.products, ", "))
+	}
+	if len(c.banned) > 0 {
+		parts = append(parts, "never use "+quoted(c.banned, ", "))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "Terminology: " + strings.Join(parts, "; ") + "."
+}
+
+// Banned returns the glossary's banned terms present in text.
+func (l *Lexicon) Banned(text string) []string {
+	var found []string
+	for i, re := range l.glossary.bannedRe {
+		if re.MatchString(text) {
+			found = append(found, l.glossary.banned[i])
+		}
+	}
+	return found
+}
+
+// RepairPrompt asks the model to reword text without the banned terms found.
+func (l *Lexicon) RepairPrompt(text string, found []string) core.Prompt {
+	return core.Prompt{
+		System: "You are an editor. Return only the rewritten response.",
+		User: fmt.Sprintf("Rewrite the response below without the terms %s, using other wording. Keep the meaning and formatting.\n\n%s\n\nResponse:\n%s",
+			quoted(found, ", "), l.glossary.instructions(), text),
+	}
+}
+
+func quoted(terms []string, sep string) string {
+	q := make([]string, len(terms))
+	for i, t := range terms {
+		q[i] = fmt.Sprintf("%q", t)
+	}
+	return strings.Join(q, sep)
+}
@@ -0,0 +1,63 @@
+package guardrail
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGlossaryCorrects(t *testing.T) {
+	g, err := New(Config{Glossary: Glossary{
+		Products: []string{"AgentFlow"},
+		Terms:    []Term{{Term: "sign in", Avoid: []string{"log in", "login"}}},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := g.For("").Apply("Log in to agentflow, then login again.")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "Sign in to AgentFlow, then sign in again."; got != want {
+		t.Errorf("Apply = %q, want %q", got, want)
+	}
+}
+
+func TestGlossaryBanned(t *testing.T) {
+	g, err := New(Config{Glossary: Glossary{Banned: []string{"cheap", "guarantee"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lex := g.For("")
+	found := lex.Banned("A Cheap plan")
+	if !slices.Equal(found, []string{"cheap"}) {
+		t.Fatalf("Banned = %v", found)
+	}
+	p := lex.RepairPrompt("A Cheap plan", found)
+	if !strings.Contains(p.User, `"cheap"`) || !strings.Contains(p.User, "A Cheap plan") {
+		t.Errorf("RepairPrompt = %q", p.User)
+	}
+}
+
+func TestGlossaryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "glossary.toml")
+	if err := os.WriteFile(path, []byte("products = [\"FlowHub\"]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	g, err := New(Config{Glossary: Glossary{File: path, Products: []string{"AgentFlow"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := g.For("").StyleInstructions()
+	if want := `Terminology: spell product names exactly as "AgentFlow", "FlowHub".`; got != want {
+		t.Errorf("StyleInstructions = %q, want %q", got, want)
+	}
+}
+
+func TestGlossaryEmptyTerm(t *testing.T) {
+	if _, err := New(Config{Glossary: Glossary{Terms: []Term{{Avoid: []string{"x"}}}}}); err == nil {
+		t.Error("New accepted a glossary term with no term")
+	}
+}
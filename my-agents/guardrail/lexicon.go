@@ -0,0 +1,298 @@
+// Package guardrail applies operator-supplied word lists and brand style
+// rules to user input and final responses, with per-tenant overrides.
+package guardrail
+
+import (
+	"cmp"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Actions taken when blocked terms are found.
+const (
+	ActionMask   = "mask"   // replace the term with asterisks
+	ActionReject = "reject" // fail the request
+)
+
+// StyleRule rewrites a term to the brand-approved spelling.
+//
+//	[[guardrail.style]]
+//	find = "e-mail"
+//	replace = "email"
+type StyleRule struct {
+	Find    string `toml:"find"`
+	Replace string `toml:"replace"`
+}
+
+// Config is the [guardrail] section of agentflow.toml. Tenant tables are
+// layered over the base lists: their terms and glossary entries are added,
+// and a non-empty action replaces the base action.
+type Config struct {
+	Action    string            `toml:"action"`
+	Blocklist []string          `toml:"blocklist"`
+	Allowlist []string          `toml:"allowlist"`
+	Style     []StyleRule       `toml:"style"`
+	Glossary  Glossary          `toml:"glossary"`
+	Tenants   map[string]Config `toml:"tenants"`
+}
+
+// Guard resolves the effective lexicon for a tenant.
+type Guard struct {
+	base    *Lexicon
+	tenants map[string]*Lexicon
+}
+
+// New compiles the base and tenant lexicons.
+func New(cfg Config) (*Guard, error) {
+	var err error
+	if cfg.Glossary, err = cfg.Glossary.load(); err != nil {
+		return nil, err
+	}
+	base, err := compile(cfg)
+	if err != nil {
+		return nil, err
+	}
+	g := &Guard{base: base, tenants: make(map[string]*Lexicon)}
+	for id, tcfg := range cfg.Tenants {
+		glossary, err := tcfg.Glossary.load()
+		if err != nil {
+			return nil, fmt.Errorf("guardrail tenant %s: %w", id, err)
+		}
+		merged := Config{
+			Action:    cmp.Or(tcfg.Action, cfg.Action),
+			Blocklist: append(slices.Clone(cfg.Blocklist), tcfg.Blocklist...),
+			Allowlist: append(slices.Clone(cfg.Allowlist), tcfg.Allowlist...),
+			Style:     append(slices.Clone(cfg.Style), tcfg.Style...),
+			Glossary:  cfg.Glossary.merge(glossary),
+		}
+		lex, err := compile(merged)
+		if err != nil {
+			return nil, fmt.Errorf("guardrail tenant %s: %w", id, err)
+		}
+		g.tenants[id] = lex
+	}
+	return g, nil
+}
+
+// For returns the lexicon for a tenant, or the base lexicon.
+func (g *Guard) For(tenantID string) *Lexicon {
+	if lex, ok := g.tenants[tenantID]; ok {
+		return lex
+	}
+	return g.base
+}
+
+// Lexicon is a compiled set of word lists, style rules and glossary.
+type Lexicon struct {
+	action   string
+	blocked  []*words
+	allowed  []*words
+	style    []StyleRule
+	styleRe  []*words
+	glossary *glossary
+}
+
+// BlockedError reports blocked terms in text when the action is reject.
+type BlockedError struct {
+	Terms []string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("content contains blocked terms: %s", strings.Join(e.Terms, ", "))
+}
+
+func compile(cfg Config) (*Lexicon, error) {
+	lex := &Lexicon{action: cmp.Or(cfg.Action, ActionMask), style: cfg.Style}
+	if lex.action != ActionMask && lex.action != ActionReject {
+		return nil, fmt.Errorf("unknown guardrail action %q", lex.action)
+	}
+	for _, term := range cfg.Blocklist {
+		lex.blocked = append(lex.blocked, termPattern(term))
+	}
+	for _, term := range cfg.Allowlist {
+		lex.allowed = append(lex.allowed, termPattern(term))
+	}
+	for _, rule := range cfg.Style {
+		lex.styleRe = append(lex.styleRe, termPattern(rule.Find))
+	}
+	var err error
+	if lex.glossary, err = compileGlossary(cfg.Glossary); err != nil {
+		return nil, err
+	}
+	return lex, nil
+}
+
+// termPattern matches term as a whole word, case-insensitively.
+func termPattern(term string) *words {
+	return wordPattern(regexp.QuoteMeta(term))
+}
+
+// words matches a pattern case-insensitively where it stands as whole
+// words: no letter, digit or underscore of any script adjoins it. RE2's \b
+// knows only ASCII word characters, so it would find "tot" in "tôt" and
+// miss "café" before a space.
+type words struct {
+	re *regexp.Regexp
+}
+
+func wordPattern(pattern string) *words {
+	return &words{re: regexp.MustCompile(`(?i)` + pattern)}
+}
+
+// FindAllStringIndex returns the locations of the whole-word matches in
+// text.
+func (w *words) FindAllStringIndex(text string) [][]int {
+	var locs [][]int
+	for start := 0; start < len(text); {
+		loc := w.re.FindStringIndex(text[start:])
+		if loc == nil {
+			break
+		}
+		loc[0], loc[1] = loc[0]+start, loc[1]+start
+		if loc[1] > loc[0] && wholeWord(text, loc) {
+			locs = append(locs, loc)
+			start = loc[1]
+			continue
+		}
+		// Look again from the next character, for a match inside this one
+		_, size := utf8.DecodeRuneInString(text[loc[0]:])
+		start = loc[0] + size
+	}
+	return locs
+}
+
+func (w *words) MatchString(text string) bool {
+	return len(w.FindAllStringIndex(text)) > 0
+}
+
+// ReplaceAllStringFunc replaces the whole-word matches with what repl
+// returns for them.
+func (w *words) ReplaceAllStringFunc(text string, repl func(string) string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range w.FindAllStringIndex(text) {
+		b.WriteString(text[last:loc[0]])
+		b.WriteString(repl(text[loc[0]:loc[1]]))
+		last = loc[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+// wholeWord reports whether the match at loc isn't part of a longer word:
+// a match starting or ending with a word character mustn't have another
+// next to it.
+func wholeWord(text string, loc []int) bool {
+	first, _ := utf8.DecodeRuneInString(text[loc[0]:])
+	before, _ := utf8.DecodeLastRuneInString(text[:loc[0]])
+	if loc[0] > 0 && isWordRune(first) && isWordRune(before) {
+		return false
+	}
+	last, _ := utf8.DecodeLastRuneInString(text[:loc[1]])
+	after, _ := utf8.DecodeRuneInString(text[loc[1]:])
+	return loc[1] == len(text) || !isWordRune(last) || !isWordRune(after)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || r == '_'
+}
+
+// Find returns the blocked terms present in text, ignoring matches that fall
+// inside an allowlisted phrase.
+func (l *Lexicon) Find(text string) []string {
+	allowed := l.allowedSpans(text)
+
+	var found []string
+	for _, re := range l.blocked {
+		for _, loc := range re.FindAllStringIndex(text) {
+			if !covered(loc, allowed) {
+				found = append(found, strings.ToLower(text[loc[0]:loc[1]]))
+				break
+			}
+		}
+	}
+	return found
+}
+
+func (l *Lexicon) allowedSpans(text string) [][]int {
+	var spans [][]int
+	for _, re := range l.allowed {
+		spans = append(spans, re.FindAllStringIndex(text)...)
+	}
+	return spans
+}
+
+// Check returns a *BlockedError when text contains blocked terms and the
+// action is reject; with the mask action it never fails.
+func (l *Lexicon) Check(text string) error {
+	if l.action != ActionReject {
+		return nil
+	}
+	if found := l.Find(text); len(found) > 0 {
+		return &BlockedError{Terms: found}
+	}
+	return nil
+}
+
+// Apply enforces the lexicon on text: style rules and glossary corrections
+// are applied, and blocked terms are masked or rejected according to the
+// action. Banned glossary terms are left for the caller to reword.
+func (l *Lexicon) Apply(text string) (string, error) {
+	for i, re := range l.styleRe {
+		text = re.ReplaceAllStringFunc(text, func(string) string { return l.style[i].Replace })
+	}
+	text = l.glossary.correct(text)
+	if err := l.Check(text); err != nil {
+		return "", err
+	}
+
+	for _, re := range l.blocked {
+		text = maskMatches(text, re, l.allowedSpans(text))
+	}
+	return text, nil
+}
+
+// StyleInstructions describes the brand rules and glossary for inclusion in
+// prompts.
+func (l *Lexicon) StyleInstructions() string {
+	var parts []string
+	if len(l.style) > 0 {
+		rules := make([]string, len(l.style))
+		for i, r := range l.style {
+			rules[i] = fmt.Sprintf("write %q instead of %q", r.Replace, r.Find)
+		}
+		parts = append(parts, "Brand style: "+strings.Join(rules, "; ")+".")
+	}
+	if terms := l.glossary.instructions(); terms != "" {
+		parts = append(parts, terms)
+	}
+	return strings.Join(parts, " ")
+}
+
+func maskMatches(text string, re *words, allowed [][]int) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range re.FindAllStringIndex(text) {
+		if covered(loc, allowed) {
+			continue
+		}
+		b.WriteString(text[last:loc[0]])
+		b.WriteString(strings.Repeat("*", len([]rune(text[loc[0]:loc[1]]))))
+		last = loc[1]
+	}
+	b.WriteString(text[last:])
+	return b.String()
+}
+
+func covered(loc []int, spans [][]int) bool {
+	for _, s := range spans {
+		if loc[0] >= s[0] && loc[1] <= s[1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package guardrail
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestApplyMasks(t *testing.T) {
+	g, err := New(Config{Blocklist: []string{"darn"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := g.For("").Apply("Darn it, darned thing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "**** it, darned thing" {
+		t.Errorf("Apply = %q", got)
+	}
+}
+
+func TestCheckRejects(t *testing.T) {
+	g, err := New(Config{Action: ActionReject, Blocklist: []string{"darn"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blocked *BlockedError
+	if err := g.For("").Check("oh DARN"); !errors.As(err, &blocked) || !slices.Equal(blocked.Terms, []string{"darn"}) {
+		t.Fatalf("Check = %v, want BlockedError for darn", err)
+	}
+	if _, err := g.For("").Apply("oh darn"); !errors.As(err, &blocked) {
+		t.Errorf("Apply = %v, want BlockedError", err)
+	}
+	if err := g.For("").Check("all fine"); err != nil {
+		t.Errorf("Check of clean text = %v", err)
+	}
+}
+
+func TestAllowlist(t *testing.T) {
+	g, err := New(Config{Blocklist: []string{"kill"}, Allowlist: []string{"kill switch"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lex := g.For("")
+	if found := lex.Find("flip the kill switch"); len(found) != 0 {
+		t.Errorf("Find in allowed phrase = %v", found)
+	}
+	got, _ := lex.Apply("kill the kill switch")
+	if got != "**** the kill switch" {
+		t.Errorf("Apply = %q", got)
+	}
+}
+
+func TestWholeWordsAcrossScripts(t *testing.T) {
+	g, err := New(Config{Blocklist: []string{"tot", "café"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lex := g.For("")
+	if found := lex.Find("à bientôt"); len(found) != 0 {
+		t.Errorf("Find matched inside a word: %v", found)
+	}
+	if found := lex.Find("le café noir"); !slices.Equal(found, []string{"café"}) {
+		t.Errorf("Find = %v, want [café]", found)
+	}
+}
+
+func TestStyle(t *testing.T) {
+	g, err := New(Config{Style: []StyleRule{{Find: "e-mail", Replace: "email"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lex := g.For("")
+	if got, _ := lex.Apply("Send an E-mail"); got != "Send an email" {
+		t.Errorf("Apply = %q", got)
+	}
+	if got := lex.StyleInstructions(); got != `Brand style: write "email" instead of "e-mail".` {
+		t.Errorf("StyleInstructions = %q", got)
+	}
+}
+
+func TestTenants(t *testing.T) {
+	g, err := New(Config{
+		Blocklist: []string{"darn"},
+		Tenants: map[string]Config{
+			"acme": {Action: ActionReject, Blocklist: []string{"heck"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := g.For("acme").Check("darn"); err == nil {
+		t.Error("tenant lexicon lost the base blocklist")
+	}
+	if err := g.For("acme").Check("heck"); err == nil {
+		t.Error("tenant lexicon missing its own blocklist")
+	}
+	if got, err := g.For("other").Apply("heck darn"); err != nil || got != "heck ****" {
+		t.Errorf("base Apply = %q, %v", got, err)
+	}
+}
+
+func TestUnknownAction(t *testing.T) {
+	if _, err := New(Config{Action: "shout"}); err == nil {
+		t.Error("New accepted an unknown action")
+	}
+	if _, err := New(Config{Tenants: map[string]Config{"acme": {Action: "shout"}}}); err == nil {
+		t.Error("New accepted an unknown tenant action")
+	}
+}
@@ -0,0 +1,208 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"my-agents/coldstore"
+	"my-agents/storage"
+)
+
+// TieredStore keeps recent runs in a hot store and moves finished runs to
+// cold storage once they are old, keeping the hot store small. An index in
+// the embedded database remembers what moved, so List still finds archived
+// runs and Get brings one back to the hot store.
+type TieredStore struct {
+	hot     Store
+	archive coldstore.Archive
+	db      *sql.DB
+	owner   string // the coldstore.Owner keying the runs this store archives
+}
+
+// NewTieredStore creates a store over hot that archives to archive, with
+// its index in db.
+func NewTieredStore(hot Store, db *sql.DB, archive coldstore.Archive) (*TieredStore, error) {
+	err := storage.Migrate(db,
+		`CREATE TABLE IF NOT EXISTS history_cold (
+			id          TEXT PRIMARY KEY,
+			session_id  TEXT NOT NULL,
+			status      TEXT NOT NULL,
+			started_at  INTEGER NOT NULL,
+			size        INTEGER NOT NULL,
+			archived_at INTEGER NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS history_cold_started ON history_cold (started_at)`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := coldstore.Owner(db)
+	if err != nil {
+		return nil, err
+	}
+	return &TieredStore{hot: hot, archive: archive, db: db, owner: owner}, nil
+}
+
+func (s *TieredStore) coldKey(id string) string { return "runs/" + s.owner + "/" + id }
+
+// Save writes to the hot store; a run saved again after it was archived
+// is hot from then on.
+func (s *TieredStore) Save(ctx context.Context, run *Run) error {
+	if err := s.hot.Save(ctx, run); err != nil {
+		return err
+	}
+	return s.forget(ctx, run.ID)
+}
+
+// Get returns the run from the hot store or, moving it back there, from
+// cold storage.
+func (s *TieredStore) Get(ctx context.Context, id string) (*Run, error) {
+	run, err := s.hot.Get(ctx, id)
+	if !errors.Is(err, ErrNotFound) {
+		return run, err
+	}
+	var n int
+	err = s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM history_cold WHERE id = ?`, id).Scan(&n)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up archived run %s: %w", id, err)
+	}
+	if n == 0 {
+		return nil, ErrNotFound
+	}
+	run = &Run{}
+	if err := coldstore.Thaw(ctx, s.archive, s.coldKey(id), run); err != nil {
+		return nil, err
+	}
+	if err := s.Save(ctx, run); err != nil {
+		return nil, fmt.Errorf("failed to restore archived run %s: %w", id, err)
+	}
+	return run, nil
+}
+
+// List merges the hot store's runs with the archived ones the filter
+// selects, which are read from cold storage without moving them back.
+func (s *TieredStore) List(ctx context.Context, filter Filter) ([]*Run, error) {
+	runs, err := s.hot.List(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT id FROM history_cold WHERE 1 = 1`
+	var args []any
+	if filter.SessionID != "" {
+		query += ` AND session_id = ?`
+		args = append(args, filter.SessionID)
+	}
+	if filter.Status != "" {
+		query += ` AND status = ?`
+		args = append(args, filter.Status)
+	}
+	if !filter.Since.IsZero() {
+		query += ` AND started_at >= ?`
+		args = append(args, filter.Since.UnixNano())
+	}
+	if !filter.Until.IsZero() {
+		query += ` AND started_at < ?`
+		args = append(args, filter.Until.UnixNano())
+	}
+	// Only the most recent can make the limit
+	query += ` ORDER BY started_at DESC`
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(` LIMIT %d`, filter.Limit)
+	}
+	ids, err := s.ids(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		run := &Run{}
+		if err := coldstore.Thaw(ctx, s.archive, s.coldKey(id), run); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	return sortAndLimit(runs, filter.Limit), nil
+}
+
+func (s *TieredStore) Delete(ctx context.Context, id string) error {
+	if err := s.hot.Delete(ctx, id); err != nil {
+		return err
+	}
+	return s.forget(ctx, id)
+}
+
+// forget drops the run's archived copy, if it has one.
+func (s *TieredStore) forget(ctx context.Context, id string) error {
+	res, err := s.db.ExecContext(ctx, `DELETE FROM history_cold WHERE id = ?`, id)
+	if err != nil {
+		return fmt.Errorf("failed to unindex archived run %s: %w", id, err)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return nil
+	}
+	return s.archive.Delete(ctx, s.coldKey(id))
+}
+
+func (s *TieredStore) ids(ctx context.Context, query string, args ...any) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archived runs: %w", err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// Archive moves the runs that ended before cutoff to cold storage. Runs
+// still going or waiting on a question stay hot.
+func (s *TieredStore) Archive(ctx context.Context, cutoff time.Time) (coldstore.Moved, error) {
+	var done coldstore.Moved
+	runs, err := s.hot.List(ctx, Filter{Until: cutoff})
+	if err != nil {
+		return done, err
+	}
+	for _, run := range runs {
+		if run.Status == StatusRunning || run.Status == StatusAwaitingInput || run.EndedAt.IsZero() || !run.EndedAt.Before(cutoff) {
+			continue
+		}
+		size, err := coldstore.Freeze(ctx, s.archive, s.coldKey(run.ID), run)
+		if err != nil {
+			return done, err
+		}
+		_, err = s.db.ExecContext(ctx,
+			`INSERT INTO history_cold (id, session_id, status, started_at, size, archived_at) VALUES (?, ?, ?, ?, ?, ?)
+			 ON CONFLICT (id) DO UPDATE SET session_id = excluded.session_id, status = excluded.status,
+			 started_at = excluded.started_at, size = excluded.size, archived_at = excluded.archived_at`,
+			run.ID, run.SessionID, run.Status, run.StartedAt.UnixNano(), size, time.Now().UnixNano())
+		if err != nil {
+			return done, fmt.Errorf("failed to index archived run %s: %w", run.ID, err)
+		}
+		// Indexed before it leaves the hot store, so it's never in neither
+		if err := s.hot.Delete(ctx, run.ID); err != nil {
+			return done, err
+		}
+		done.Records++
+		done.Bytes += int64(size)
+	}
+	return done, nil
+}
+
+// SweepOrphans deletes the archived runs written before cutoff that the
+// index doesn't know of, returning how many and their size.
+func (s *TieredStore) SweepOrphans(ctx context.Context, cutoff time.Time) (int, int64, error) {
+	return coldstore.SweepOrphans(ctx, s.archive, s.coldKey(""), cutoff, func(ctx context.Context, key string) (bool, error) {
+		var n int
+		err := s.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM history_cold WHERE id = ?`, strings.TrimPrefix(key, s.coldKey(""))).Scan(&n)
+		return n > 0, err
+	})
+}
@@ -0,0 +1,116 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"my-agents/coldstore"
+	"my-agents/storage"
+)
+
+func newTiered(t *testing.T) (*TieredStore, *coldstore.FileArchive) {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := storage.Open(filepath.Join(dir, "agentflow.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := coldstore.NewFileArchive(filepath.Join(dir, "cold"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewTieredStore(NewMemoryStore(), db, archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, archive
+}
+
+func TestTieredArchiveAndGet(t *testing.T) {
+	ctx := context.Background()
+	s, _ := newTiered(t)
+	old := time.Now().Add(-2 * time.Hour)
+	for _, run := range []*Run{
+		{ID: "done", Status: StatusCompleted, StartedAt: old, EndedAt: old.Add(time.Minute)},
+		{ID: "going", Status: StatusRunning, StartedAt: old},
+		{ID: "fresh", Status: StatusCompleted, StartedAt: time.Now(), EndedAt: time.Now()},
+	} {
+		if err := s.Save(ctx, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	moved, err := s.Archive(ctx, time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved.Records != 1 {
+		t.Fatalf("archived %d runs, want only the one that ended before the cutoff", moved.Records)
+	}
+	if _, err := s.hot.Get(ctx, "done"); !errors.Is(err, ErrNotFound) {
+		t.Error("an archived run stayed in the hot store")
+	}
+	runs, err := s.List(ctx, Filter{})
+	if err != nil || len(runs) != 3 {
+		t.Errorf("List = %d runs, %v; want the archived one among all 3", len(runs), err)
+	}
+
+	run, err := s.Get(ctx, "done")
+	if err != nil || run.Status != StatusCompleted {
+		t.Fatalf("Get = %+v, %v", run, err)
+	}
+	if _, err := s.hot.Get(ctx, "done"); err != nil {
+		t.Error("Get didn't bring the run back to the hot store")
+	}
+}
+
+func TestTieredSweepOrphans(t *testing.T) {
+	ctx := context.Background()
+	s, archive := newTiered(t)
+	old := time.Now().Add(-2 * time.Hour)
+	if err := s.Save(ctx, &Run{ID: "kept", Status: StatusCompleted, StartedAt: old, EndedAt: old}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Archive(ctx, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	// Archived by a process that stopped before indexing it, and by another
+	// replica sharing the archive
+	if _, err := coldstore.Freeze(ctx, archive, s.coldKey("orphan"), &Run{ID: "orphan"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := coldstore.Freeze(ctx, archive, "runs/other-replica/theirs", &Run{ID: "theirs"}); err != nil {
+		t.Fatal(err)
+	}
+
+	n, size, err := s.SweepOrphans(ctx, time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 || size == 0 {
+		t.Errorf("swept %d objects of %d bytes, want the orphan", n, size)
+	}
+	if _, err := archive.Get(ctx, s.coldKey("orphan")); !errors.Is(err, coldstore.ErrNotFound) {
+		t.Error("the orphan survived the sweep")
+	}
+	if _, err := archive.Get(ctx, "runs/other-replica/theirs"); err != nil {
+		t.Error("the sweep deleted another replica's run")
+	}
+	if run, err := s.Get(ctx, "kept"); err != nil || run.ID != "kept" {
+		t.Errorf("the indexed run = %+v, %v after the sweep", run, err)
+	}
+}
+
+func TestTieredSweepSparesRecentObjects(t *testing.T) {
+	ctx := context.Background()
+	s, archive := newTiered(t)
+	if _, err := coldstore.Freeze(ctx, archive, s.coldKey("indexing"), &Run{ID: "indexing"}); err != nil {
+		t.Fatal(err)
+	}
+	if n, _, err := s.SweepOrphans(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("SweepOrphans = %d, %v; want an object written after the cutoff spared", n, err)
+	}
+}
@@ -0,0 +1,128 @@
+package history
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Completion is how a run ended: its record, and the result of the agent
+// that ended it by routing nowhere further, failing or asking a question.
+type Completion struct {
+	Run    *Run
+	Result core.AgentResult
+}
+
+// Err returns the run's failure, if it failed.
+func (c Completion) Err() error {
+	if c.Run.Status == StatusFailed {
+		return errors.New(c.Run.Error)
+	}
+	return nil
+}
+
+// await returns a channel that receives how the run runID next ends.
+func (r *Recorder) await(runID string) <-chan Completion {
+	ch := make(chan Completion, 1)
+	r.mu.Lock()
+	r.waiters[runID] = append(r.waiters[runID], ch)
+	r.mu.Unlock()
+	return ch
+}
+
+// WaitForCompletion blocks until the run runID — the ID of the event that
+// started it — ends, or ctx is done. A run that has already completed or
+// failed is reported from the store, with the terminal result rebuilt from
+// its last step. A run paused on a question is waited on until it next
+// ends, so emit the answer and wait again.
+func (r *Recorder) WaitForCompletion(ctx context.Context, runID string) (Completion, error) {
+	ch := r.await(runID)
+	defer r.cancel(runID, ch)
+
+	r.mu.Lock()
+	_, inFlight := r.runs[runID]
+	r.mu.Unlock()
+	if !inFlight {
+		run, err := r.store.Get(ctx, runID)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return Completion{}, err
+		}
+		if err == nil && (run.Status == StatusCompleted || run.Status == StatusFailed) {
+			return completionOf(run), nil
+		}
+	}
+	return r.wait(ctx, runID, ch)
+}
+
+// storePoll is how often a run in flight elsewhere is looked up in the store.
+const storePoll = time.Second
+
+// wait receives from ch how the run ends here. A run that moved to another
+// process over the event bus ends there, so while it isn't in flight here,
+// a completed or failed record in the store ends the wait too.
+func (r *Recorder) wait(ctx context.Context, runID string, ch <-chan Completion) (Completion, error) {
+	ticker := time.NewTicker(storePoll)
+	defer ticker.Stop()
+	for {
+		select {
+		case c := <-ch:
+			return c, nil
+		case <-ticker.C:
+			r.mu.Lock()
+			_, inFlight := r.runs[runID]
+			r.mu.Unlock()
+			if inFlight {
+				continue
+			}
+			if run, err := r.store.Get(ctx, runID); err == nil && (run.Status == StatusCompleted || run.Status == StatusFailed) {
+				return completionOf(run), nil
+			}
+		case <-ctx.Done():
+			return Completion{}, ctx.Err()
+		}
+	}
+}
+
+// cancel drops a waiter that stopped waiting.
+func (r *Recorder) cancel(runID string, ch <-chan Completion) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	waiters := r.waiters[runID]
+	for i, w := range waiters {
+		if w == ch {
+			waiters = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(waiters) == 0 {
+		delete(r.waiters, runID)
+	} else {
+		r.waiters[runID] = waiters
+	}
+}
+
+// notify hands the ending of a run to its waiters. r.mu must be held.
+func (r *Recorder) notify(c Completion) {
+	for _, ch := range r.waiters[c.Run.ID] {
+		ch <- c
+	}
+	delete(r.waiters, c.Run.ID)
+}
+
+func completionOf(run *Run) Completion {
+	c := Completion{Run: run}
+	if len(run.Steps) == 0 {
+		return c
+	}
+	last := run.Steps[len(run.Steps)-1]
+	c.Result.Error = last.Error
+	if last.Output != nil {
+		c.Result.OutputState = core.NewState()
+		for k, v := range last.Output {
+			c.Result.OutputState.Set(k, v)
+		}
+	}
+	return c
+}
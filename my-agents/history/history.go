@@ -0,0 +1,126 @@
+// Package history records every run of the pipeline — the user input, each
+// agent step and the final response — so runs can be inspected, exported
+// and replayed after the process exits.
+package history
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+)
+
+// RunIDKey is the event metadata key that ties every hop of a run together.
+// The recorder sets it on the first event of a run (to that event's ID) and
+// agents forward it with the rest of the request metadata.
+const RunIDKey = "run_id"
+
+// ForwardedKey is the state metadata key set by an agent that handed its
+// event to another process, naming the agent there. The run isn't over:
+// the other process continues its record when the processes share a store.
+const ForwardedKey = "forwarded_to"
+
+// Run statuses.
+const (
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+	// StatusAwaitingInput marks a run paused on a clarification question.
+	StatusAwaitingInput = "awaiting_input"
+)
+
+type runKey struct{}
+
+// WithRunID ties work done outside the runner, such as applying a plan
+// after its run finished, to a run.
+func WithRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runKey{}, runID)
+}
+
+// RunIDFrom returns the run set with WithRunID.
+func RunIDFrom(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(runKey{}).(string)
+	return id, ok && id != ""
+}
+
+// ErrNotFound is returned when a run does not exist.
+var ErrNotFound = errors.New("run not found")
+
+// Step is one agent invocation within a run.
+type Step struct {
+	EventID   string         `json:"event_id"`
+	Agent     string         `json:"agent"`
+	Input     map[string]any `json:"input,omitempty"`
+	Output    map[string]any `json:"output,omitempty"`
+	Route     string         `json:"route,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	StartedAt time.Time      `json:"started_at"`
+	EndedAt   time.Time      `json:"ended_at"`
+	// Branches are the agents that handled the step in parallel, when
+	// several did, and Writers names the branch whose value the merge kept
+	// for each output key.
+	Branches []Branch          `json:"branches,omitempty"`
+	Writers  map[string]string `json:"writers,omitempty"`
+}
+
+// Run is the full record of one request through the pipeline.
+type Run struct {
+	ID            string            `json:"id"`
+	SessionID     string            `json:"session_id"`
+	Input         string            `json:"input"`
+	FinalResponse string            `json:"final_response,omitempty"`
+	Question      string            `json:"question,omitempty"` // pending clarification
+	Status        string            `json:"status"`
+	Error         string            `json:"error,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	Steps         []Step            `json:"steps"`
+	Mutations     []Mutation        `json:"mutations,omitempty"` // the steps' changes to the state, in order
+	StartedAt     time.Time         `json:"started_at"`
+	EndedAt       time.Time         `json:"ended_at,omitempty"`
+}
+
+// Filter selects runs in List. Zero fields match everything.
+type Filter struct {
+	SessionID string
+	Status    string
+	Since     time.Time
+	Until     time.Time
+	Limit     int
+}
+
+// Match reports whether run passes the filter (ignoring Limit).
+func (f Filter) Match(run *Run) bool {
+	if f.SessionID != "" && run.SessionID != f.SessionID {
+		return false
+	}
+	if f.Status != "" && run.Status != f.Status {
+		return false
+	}
+	if !f.Since.IsZero() && run.StartedAt.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !run.StartedAt.Before(f.Until) {
+		return false
+	}
+	return true
+}
+
+// Store persists runs.
+type Store interface {
+	Save(ctx context.Context, run *Run) error
+	Get(ctx context.Context, id string) (*Run, error)
+	// List returns matching runs ordered by start time, oldest first.
+	List(ctx context.Context, filter Filter) ([]*Run, error)
+	// Delete removes a run; deleting a missing run is not an error.
+	Delete(ctx context.Context, id string) error
+}
+
+// sortAndLimit orders runs by start time and applies the filter's limit,
+// keeping the most recent runs.
+func sortAndLimit(runs []*Run, limit int) []*Run {
+	sort.Slice(runs, func(i, j int) bool { return runs[i].StartedAt.Before(runs[j].StartedAt) })
+	if limit > 0 && len(runs) > limit {
+		runs = runs[len(runs)-limit:]
+	}
+	return runs
+}
@@ -0,0 +1,138 @@
+package history
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// WrittenByPrefix starts the state metadata keys naming the agent that last
+// wrote each state key: "written_by.summary" is the writer of "summary".
+// Agents forwarding their request metadata pass the lineage down the chain.
+const WrittenByPrefix = "written_by."
+
+// WrittenBy returns the agent that last wrote key in state, as far as
+// state's metadata knows.
+func WrittenBy(state core.State, key string) (string, bool) {
+	agent, ok := state.GetMeta(WrittenByPrefix + key)
+	return agent, ok && agent != ""
+}
+
+// Branch is one agent's part in a step several agents handled in parallel,
+// as in collaborative orchestration, before their outputs were merged.
+type Branch struct {
+	Agent     string         `json:"agent"`
+	Writes    map[string]any `json:"writes,omitempty"` // the keys it set to new values
+	Error     string         `json:"error,omitempty"`
+	StartedAt time.Time      `json:"started_at"`
+	EndedAt   time.Time      `json:"ended_at"`
+}
+
+// Candidate is a value one parallel branch wrote for a key.
+type Candidate struct {
+	Agent string `json:"agent"`
+	Value any    `json:"value"`
+}
+
+// Attribute wraps agent's handler to mark the keys it writes with
+// WrittenByPrefix metadata and report its writes to the recorder, so a
+// step that merges several agents' outputs records each one's part.
+func (r *Recorder) Attribute(agent string, h core.AgentHandler) core.AgentHandler {
+	return core.AgentHandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+		b := Branch{Agent: agent, StartedAt: time.Now()}
+		result, err := h.Run(ctx, event, state)
+		b.EndedAt = time.Now()
+		if err != nil {
+			b.Error = err.Error()
+		}
+		if out := result.OutputState; out != nil && err == nil {
+			input := event.GetData()
+			for _, k := range out.Keys() {
+				v, _ := out.Get(k)
+				if prev, had := input[k]; had && sameValue(prev, v) {
+					continue
+				}
+				if b.Writes == nil {
+					b.Writes = make(map[string]any)
+				}
+				b.Writes[k] = v
+				out.SetMeta(WrittenByPrefix+k, agent)
+			}
+		}
+		r.mu.Lock()
+		r.branches[event.GetID()] = append(r.branches[event.GetID()], b)
+		r.mu.Unlock()
+		return result, err
+	})
+}
+
+// writers returns the writer of each output key the merged state names,
+// where it isn't the step's own agent.
+func writers(state core.State, agent string, output map[string]any) map[string]string {
+	var out map[string]string
+	for _, mk := range state.MetaKeys() {
+		key, ok := strings.CutPrefix(mk, WrittenByPrefix)
+		if !ok {
+			continue
+		}
+		if _, written := output[key]; !written {
+			continue
+		}
+		if w, _ := state.GetMeta(mk); w != "" && w != agent {
+			if out == nil {
+				out = make(map[string]string)
+			}
+			out[key] = w
+		}
+	}
+	return out
+}
+
+// Provenance returns, for each key in the state after the step-th step, the
+// change that gave it its value: which agent wrote it, at which step and
+// when.
+func (run *Run) Provenance(step int) map[string]Mutation {
+	out := make(map[string]Mutation)
+	for _, m := range run.mutations() {
+		if m.Step <= step {
+			out[m.Key] = m
+		}
+	}
+	return out
+}
+
+// Conflicts returns the changes merged from parallel branches that wrote
+// different values for the key, oldest first. Each lists every branch's
+// value as a candidate; the change's value is the one the merge kept.
+func (run *Run) Conflicts() []Mutation {
+	var out []Mutation
+	for _, m := range run.mutations() {
+		if len(m.Candidates) > 0 {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// candidates lists the values step's branches wrote for key, or nil when
+// fewer than two wrote it or they all agree.
+func candidates(step Step, key string) []Candidate {
+	var out []Candidate
+	agree := true
+	for _, b := range step.Branches {
+		v, ok := b.Writes[key]
+		if !ok {
+			continue
+		}
+		if len(out) > 0 && !sameValue(out[0].Value, v) {
+			agree = false
+		}
+		out = append(out, Candidate{Agent: b.Agent, Value: v})
+	}
+	if len(out) < 2 || agree {
+		return nil
+	}
+	return out
+}
@@ -0,0 +1,191 @@
+package history
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/clarify"
+)
+
+// Recorder builds run records from runner callbacks and saves them to a store.
+type Recorder struct {
+	store Store
+
+	mu       sync.Mutex
+	runs     map[string]*Run              // in-flight runs by run ID
+	started  map[string]time.Time         // step start times by event ID
+	branches map[string][]Branch          // parallel agents' parts by event ID
+	waiters  map[string][]chan Completion // WaitForCompletion callers by run ID
+}
+
+// NewRecorder creates a recorder writing to store.
+func NewRecorder(store Store) *Recorder {
+	return &Recorder{
+		store:    store,
+		runs:     make(map[string]*Run),
+		started:  make(map[string]time.Time),
+		branches: make(map[string][]Branch),
+		waiters:  make(map[string][]chan Completion),
+	}
+}
+
+// Store returns the underlying store.
+func (r *Recorder) Store() Store {
+	return r.store
+}
+
+// Current returns a copy of run runID as recorded so far, while it is in
+// flight in this process.
+func (r *Recorder) Current(runID string) (*Run, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	run, ok := r.runs[runID]
+	if !ok {
+		return nil, false
+	}
+	return clone(run), true
+}
+
+// Register installs the recorder's callbacks on the runner.
+func (r *Recorder) Register(runner core.Runner) error {
+	if err := runner.RegisterCallback(core.HookBeforeEventHandling, "history-before", r.before); err != nil {
+		return err
+	}
+	return runner.RegisterCallback(core.HookAfterEventHandling, "history-after", r.after)
+}
+
+func (r *Recorder) before(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+	event := args.Event
+	// Failure notifications from the runner's error routing aren't runs
+	if status, _ := event.GetMetadataValue("status"); status == "error" {
+		return args.State, nil
+	}
+	runID, ok := event.GetMetadataValue(RunIDKey)
+	if !ok || runID == "" {
+		runID = event.GetID()
+		event.SetMetadata(RunIDKey, runID)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.started[event.GetID()] = time.Now()
+	if _, exists := r.runs[runID]; !exists {
+		// A run resumed after a restart or a clarification answer continues
+		// its saved record
+		if saved, err := r.store.Get(ctx, runID); err == nil && (saved.Status == StatusRunning || saved.Status == StatusAwaitingInput) {
+			saved.Status, saved.Question = StatusRunning, ""
+			r.runs[runID] = saved
+			return args.State, nil
+		}
+		input, _ := event.GetData()["input"].(string)
+		metadata := make(map[string]string)
+		for k, v := range event.GetMetadata() {
+			metadata[k] = v
+		}
+		r.runs[runID] = &Run{
+			ID:        runID,
+			SessionID: event.GetSessionID(),
+			Input:     input,
+			Status:    StatusRunning,
+			Metadata:  metadata,
+			StartedAt: time.Now(),
+		}
+	}
+	return args.State, nil
+}
+
+func (r *Recorder) after(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+	event := args.Event
+	runID, _ := event.GetMetadataValue(RunIDKey)
+
+	r.mu.Lock()
+	run, ok := r.runs[runID]
+	if !ok {
+		r.mu.Unlock()
+		return args.State, nil
+	}
+	step := Step{
+		EventID:   event.GetID(),
+		Agent:     args.AgentID,
+		Input:     copyData(event.GetData()),
+		StartedAt: r.started[event.GetID()],
+		EndedAt:   time.Now(),
+	}
+	delete(r.started, event.GetID())
+	branches := r.branches[event.GetID()]
+	delete(r.branches, event.GetID())
+	if len(branches) > 1 {
+		step.Branches = branches
+	}
+	if args.State != nil && args.Error == nil {
+		if to, _ := args.State.GetMeta(ForwardedKey); to != "" {
+			// The steps so far are saved; whichever process picks the run up
+			// next continues from them
+			delete(r.runs, runID)
+			r.mu.Unlock()
+			return args.State, nil
+		}
+	}
+
+	done := false
+	if args.Error != nil {
+		step.Error = args.Error.Error()
+		run.Status, run.Error = StatusFailed, step.Error
+		done = true
+	} else if args.State != nil {
+		step.Output = stateData(args.State)
+		step.Writers = writers(args.State, args.AgentID, step.Output)
+		step.Route, _ = args.State.GetMeta(core.RouteMetadataKey)
+		if final, ok := args.State.Get("final_response"); ok {
+			run.FinalResponse, _ = final.(string)
+		}
+		if question, ok := clarify.Pending(args.State); ok {
+			run.Status, run.Question = StatusAwaitingInput, question
+			done = true
+		} else if step.Route == "" {
+			run.Status = StatusCompleted
+			done = true
+		}
+	}
+	run.Steps = append(run.Steps, step)
+	run.recordMutations(len(run.Steps) - 1)
+	if done {
+		run.EndedAt = time.Now()
+		delete(r.runs, runID)
+	}
+	snapshot := clone(run)
+	if done {
+		result := core.AgentResult{OutputState: args.State, Error: step.Error}
+		if args.Error != nil {
+			result.OutputState = nil
+		}
+		r.notify(Completion{Run: clone(run), Result: result})
+	}
+	r.mu.Unlock()
+
+	if err := r.store.Save(ctx, snapshot); err != nil {
+		core.Logger().Error().Str("run_id", runID).Err(err).Msg("Failed to save run history")
+	}
+	return args.State, nil
+}
+
+func copyData(data core.EventData) map[string]any {
+	out := make(map[string]any, len(data))
+	for k, v := range data {
+		out[k] = v
+	}
+	return out
+}
+
+func stateData(state core.State) map[string]any {
+	out := make(map[string]any)
+	for _, k := range state.Keys() {
+		if v, ok := state.Get(k); ok {
+			out[k] = v
+		}
+	}
+	return out
+}
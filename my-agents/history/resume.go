@@ -0,0 +1,132 @@
+package history
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/clarify"
+)
+
+// RerunOfKey is the run metadata key naming the run a rerun took its
+// upstream results from.
+const RerunOfKey = "rerun_of"
+
+// Interrupted returns runs left in the running state, typically by a
+// process that exited mid-run.
+func Interrupted(ctx context.Context, store Store) ([]*Run, error) {
+	return store.List(ctx, Filter{Status: StatusRunning})
+}
+
+// ResumeEvent rebuilds the event that continues run after its last
+// completed step, or restarts it when no step completed. It returns false
+// when the run has nothing left to do.
+func ResumeEvent(run *Run) (core.Event, bool) {
+	metadata := make(map[string]string, len(run.Metadata)+1)
+	for k, v := range run.Metadata {
+		metadata[k] = v
+	}
+	metadata[RunIDKey] = run.ID
+	metadata[core.SessionIDKey] = run.SessionID
+
+	for i := len(run.Steps) - 1; i >= 0; i-- {
+		step := run.Steps[i]
+		if step.Error != "" {
+			continue
+		}
+		if step.Route == "" {
+			return nil, false
+		}
+		metadata[core.RouteMetadataKey] = step.Route
+		metadata["status"] = "success"
+		return core.NewEvent(step.Route, core.EventData(step.Output), metadata), true
+	}
+	if _, ok := metadata[core.RouteMetadataKey]; !ok {
+		return nil, false
+	}
+	return core.NewEvent(metadata[core.RouteMetadataKey], core.EventData{"input": run.Input}, metadata), true
+}
+
+// AnswerEvent resumes a run paused on a clarification question: it re-runs
+// the asking agent with its original input plus the answer.
+func AnswerEvent(run *Run, answer string) (core.Event, error) {
+	if run.Status != StatusAwaitingInput || len(run.Steps) == 0 {
+		return nil, fmt.Errorf("run %s is not awaiting input", run.ID)
+	}
+	step := run.Steps[len(run.Steps)-1]
+	data := make(core.EventData, len(step.Input)+2)
+	for k, v := range step.Input {
+		data[k] = v
+	}
+	data[clarify.AskedKey] = run.Question
+	data[clarify.AnswerKey] = answer
+
+	metadata := make(map[string]string, len(run.Metadata)+2)
+	for k, v := range run.Metadata {
+		metadata[k] = v
+	}
+	metadata[RunIDKey] = run.ID
+	metadata[core.SessionIDKey] = run.SessionID
+	metadata[core.RouteMetadataKey] = step.Agent
+	return core.NewEvent(step.Agent, data, metadata), nil
+}
+
+// RerunEvent rebuilds the event that last routed run to agent, for a new
+// run that re-runs the pipeline from agent on, on the results the agents
+// before it left. The run's request metadata carries over, with metadata's
+// keys replacing it; an empty value drops the key.
+func RerunEvent(run *Run, agent string, metadata map[string]string) (core.Event, error) {
+	for i := len(run.Steps) - 1; i >= 0; i-- {
+		if step := run.Steps[i]; step.Error == "" && step.Route == agent {
+			return rerunEvent(run, step, metadata), nil
+		}
+	}
+	return nil, fmt.Errorf("run %s never reached %s", run.ID, agent)
+}
+
+// RerunStepEvent is RerunEvent for the agent step routed to, starting from
+// the state the step left.
+func RerunStepEvent(run *Run, step int, metadata map[string]string) (core.Event, error) {
+	if step < 0 || step >= len(run.Steps) {
+		return nil, fmt.Errorf("run %s has steps 0 to %d", run.ID, len(run.Steps)-1)
+	}
+	s := run.Steps[step]
+	if s.Error != "" || s.Route == "" {
+		return nil, fmt.Errorf("step %d of run %s routed nowhere", step, run.ID)
+	}
+	return rerunEvent(run, s, metadata), nil
+}
+
+// RestartEvent rebuilds run's first event, for a new run that goes through
+// the whole pipeline again on the same input. A run pinned to a workflow
+// version starts at the workflow, not the version's agent.
+func RestartEvent(run *Run, metadata map[string]string) (core.Event, error) {
+	route, _, _ := strings.Cut(run.Metadata[core.RouteMetadataKey], "@")
+	if route == "" {
+		return nil, fmt.Errorf("run %s has no entry route", run.ID)
+	}
+	return rerunEvent(run, Step{Route: route, Output: map[string]any{"input": run.Input}}, metadata), nil
+}
+
+func rerunEvent(run *Run, step Step, metadata map[string]string) core.Event {
+	meta := make(map[string]string, len(run.Metadata)+len(metadata)+3)
+	for k, v := range run.Metadata {
+		meta[k] = v
+	}
+	// The rerun is a run of its own
+	delete(meta, RunIDKey)
+	delete(meta, "status")
+	meta[core.SessionIDKey] = run.SessionID
+	for k, v := range metadata {
+		if v == "" {
+			delete(meta, k)
+			continue
+		}
+		meta[k] = v
+	}
+	meta[core.RouteMetadataKey] = step.Route
+	meta[RerunOfKey] = run.ID
+	return core.NewEvent(step.Route, core.EventData(step.Output), meta)
+}
@@ -0,0 +1,186 @@
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// StateHandler serves a run's state history, for mounting on the admin API
+// under "/admin/runs/{run}/state", "/admin/runs/{run}/mutations",
+// "/admin/runs/{run}/provenance" and "/admin/runs/{run}/conflicts":
+//
+//	GET /admin/runs/{run}/state[?step=N|at=RFC3339]   state after step N, at a time, or at the end
+//	GET /admin/runs/{run}/mutations[?key=message]     every change, or those made to one key
+//	GET /admin/runs/{run}/provenance[?step=N]         the change behind each key's value
+//	GET /admin/runs/{run}/conflicts                   keys parallel agents wrote different values for
+func StateHandler(runs Store) http.Handler {
+	mux := http.NewServeMux()
+	get := func(w http.ResponseWriter, r *http.Request) (*Run, bool) {
+		run, err := runs.Get(r.Context(), r.PathValue("run"))
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, err)
+			return nil, false
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return nil, false
+		}
+		return run, true
+	}
+	mux.HandleFunc("GET /admin/runs/{run}/state", func(w http.ResponseWriter, r *http.Request) {
+		run, ok := get(w, r)
+		if !ok {
+			return
+		}
+		q := r.URL.Query()
+		resp := struct {
+			RunID string         `json:"run_id"`
+			Step  *int           `json:"step,omitempty"`
+			At    *time.Time     `json:"at,omitempty"`
+			State map[string]any `json:"state"`
+		}{RunID: run.ID}
+		switch {
+		case q.Has("step") && q.Has("at"):
+			writeError(w, http.StatusBadRequest, errors.New("give step or at, not both"))
+			return
+		case q.Has("step"):
+			step, err := strconv.Atoi(q.Get("step"))
+			if err != nil || step < 0 || step >= len(run.Steps) {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("step must be 0 to %d", len(run.Steps)-1))
+				return
+			}
+			resp.Step, resp.State = &step, run.State(step)
+		case q.Has("at"):
+			at, err := time.Parse(time.RFC3339Nano, q.Get("at"))
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			resp.At, resp.State = &at, run.StateAt(at)
+		default:
+			resp.State = run.State(len(run.Steps))
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+	mux.HandleFunc("GET /admin/runs/{run}/mutations", func(w http.ResponseWriter, r *http.Request) {
+		run, ok := get(w, r)
+		if !ok {
+			return
+		}
+		mutations := run.mutations()
+		if key := r.URL.Query().Get("key"); key != "" {
+			mutations = run.History(key)
+		}
+		if mutations == nil {
+			mutations = []Mutation{}
+		}
+		writeJSON(w, http.StatusOK, mutations)
+	})
+	mux.HandleFunc("GET /admin/runs/{run}/provenance", func(w http.ResponseWriter, r *http.Request) {
+		run, ok := get(w, r)
+		if !ok {
+			return
+		}
+		step := len(run.Steps)
+		if raw := r.URL.Query().Get("step"); raw != "" {
+			n, err := strconv.Atoi(raw)
+			if err != nil || n < 0 || n >= len(run.Steps) {
+				writeError(w, http.StatusBadRequest, fmt.Errorf("step must be 0 to %d", len(run.Steps)-1))
+				return
+			}
+			step = n
+		}
+		writeJSON(w, http.StatusOK, run.Provenance(step))
+	})
+	mux.HandleFunc("GET /admin/runs/{run}/conflicts", func(w http.ResponseWriter, r *http.Request) {
+		run, ok := get(w, r)
+		if !ok {
+			return
+		}
+		conflicts := run.Conflicts()
+		if conflicts == nil {
+			conflicts = []Mutation{}
+		}
+		writeJSON(w, http.StatusOK, conflicts)
+	})
+	return mux
+}
+
+// RerunHandler serves reruns on runner, which must be started, for mounting
+// on the admin API under "POST /admin/runs/{run}/rerun". The body names
+// where the rerun starts, at an agent or after a step, and metadata
+// replacing the run's, such as a pinned workflow version; the response is
+// the new run's result next to the original's, once the new run ends:
+//
+//	POST /admin/runs/{run}/rerun   {"from": "enhancer", "metadata": {"deployment": "processor@v2"}}
+//	POST /admin/runs/{run}/rerun   {"step": 1}
+func (r *Recorder) RerunHandler(runner core.Runner) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /admin/runs/{run}/rerun", func(w http.ResponseWriter, req *http.Request) {
+		var body struct {
+			From     string            `json:"from"`
+			Step     *int              `json:"step"`
+			Metadata map[string]string `json:"metadata"`
+		}
+		if err := json.NewDecoder(io.LimitReader(req.Body, 1<<16)).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if (body.From == "") == (body.Step == nil) {
+			writeError(w, http.StatusBadRequest, errors.New("give from or step"))
+			return
+		}
+		run, err := r.store.Get(req.Context(), req.PathValue("run"))
+		if errors.Is(err, ErrNotFound) {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		var event core.Event
+		if body.Step != nil {
+			event, err = RerunStepEvent(run, *body.Step, body.Metadata)
+		} else {
+			event, err = RerunEvent(run, body.From, body.Metadata)
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		result, err := r.ProcessSync(req.Context(), runner, event)
+		resp := map[string]any{
+			"run_id":            result.RunID,
+			"rerun_of":          run.ID,
+			"status":            result.Status,
+			"final_response":    result.Response,
+			"original_response": run.FinalResponse,
+			"state":             result.State,
+		}
+		if err != nil {
+			resp["error"] = err.Error()
+			writeJSON(w, http.StatusBadGateway, resp)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
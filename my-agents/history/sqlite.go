@@ -0,0 +1,125 @@
+package history
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"my-agents/storage"
+)
+
+// SQLiteStore keeps runs in a table of the embedded database, indexed by
+// session, status and start time.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore creates a store in db, creating its table if needed.
+func NewSQLiteStore(db *sql.DB) (*SQLiteStore, error) {
+	err := storage.Migrate(db,
+		`CREATE TABLE IF NOT EXISTS runs (
+			id         TEXT PRIMARY KEY,
+			session_id TEXT NOT NULL,
+			status     TEXT NOT NULL,
+			started_at INTEGER NOT NULL,
+			data       TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS runs_session ON runs (session_id)`,
+		`CREATE INDEX IF NOT EXISTS runs_status ON runs (status)`,
+		`CREATE INDEX IF NOT EXISTS runs_started ON runs (started_at)`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Save(ctx context.Context, run *Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO runs (id, session_id, status, started_at, data) VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT (id) DO UPDATE SET session_id = excluded.session_id, status = excluded.status,
+		 started_at = excluded.started_at, data = excluded.data`,
+		run.ID, run.SessionID, run.Status, run.StartedAt.UnixNano(), string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save run %s: %w", run.ID, err)
+	}
+	return nil
+}
+
+func (s *SQLiteStore) Get(ctx context.Context, id string) (*Run, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, `SELECT data FROM runs WHERE id = ?`, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var run Run
+	if err := json.Unmarshal([]byte(data), &run); err != nil {
+		return nil, fmt.Errorf("failed to decode run %s: %w", id, err)
+	}
+	return &run, nil
+}
+
+func (s *SQLiteStore) List(ctx context.Context, filter Filter) ([]*Run, error) {
+	var where []string
+	var args []any
+	if filter.SessionID != "" {
+		where, args = append(where, "session_id = ?"), append(args, filter.SessionID)
+	}
+	if filter.Status != "" {
+		where, args = append(where, "status = ?"), append(args, filter.Status)
+	}
+	if !filter.Since.IsZero() {
+		where, args = append(where, "started_at >= ?"), append(args, filter.Since.UnixNano())
+	}
+	if !filter.Until.IsZero() {
+		where, args = append(where, "started_at < ?"), append(args, filter.Until.UnixNano())
+	}
+	query := `SELECT data FROM runs`
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	// Newest first so the limit keeps the most recent runs
+	query += " ORDER BY started_at DESC"
+	if filter.Limit > 0 {
+		query += fmt.Sprintf(" LIMIT %d", filter.Limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var runs []*Run
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var run Run
+		if err := json.Unmarshal([]byte(data), &run); err != nil {
+			return nil, fmt.Errorf("failed to decode run: %w", err)
+		}
+		runs = append(runs, &run)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return sortAndLimit(runs, 0), nil
+}
+
+func (s *SQLiteStore) Delete(ctx context.Context, id string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM runs WHERE id = ?`, id); err != nil {
+		return fmt.Errorf("failed to delete run %s: %w", id, err)
+	}
+	return nil
+}
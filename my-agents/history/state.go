@@ -0,0 +1,128 @@
+package history
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+// Mutation sources.
+const (
+	// SourceInput marks a value that arrived in an agent's event without a
+	// previous agent having output it: set by the caller, a callback or the
+	// debugger.
+	SourceInput = "input"
+	// SourceOutput marks a value an agent output.
+	SourceOutput = "output"
+)
+
+// Mutation is one change to a run's state: which agent's step made it, and
+// when.
+type Mutation struct {
+	Step     int       `json:"step"` // index into the run's Steps
+	Agent    string    `json:"agent"`
+	Source   string    `json:"source"` // SourceInput or SourceOutput
+	Key      string    `json:"key"`
+	Value    any       `json:"value"`
+	Previous any       `json:"previous,omitempty"`
+	At       time.Time `json:"at"`
+	// Candidates are the values parallel branches wrote for the key when
+	// they disagreed; Value is the one the merge kept.
+	Candidates []Candidate `json:"candidates,omitempty"`
+}
+
+// State reconstructs the run's state after its step-th step (0-based), or
+// before any step for a negative step. The state accumulates across steps:
+// a key keeps the value the last agent to touch it left.
+func (run *Run) State(step int) map[string]any {
+	return replay(run.mutations(), step)
+}
+
+func replay(mutations []Mutation, step int) map[string]any {
+	state := make(map[string]any)
+	for _, m := range mutations {
+		if m.Step <= step {
+			state[m.Key] = m.Value
+		}
+	}
+	return state
+}
+
+// StateAt reconstructs the run's state as it was at t.
+func (run *Run) StateAt(t time.Time) map[string]any {
+	state := make(map[string]any)
+	for _, m := range run.mutations() {
+		if !m.At.After(t) {
+			state[m.Key] = m.Value
+		}
+	}
+	return state
+}
+
+// History returns the changes made to key, oldest first: who set it to each
+// value, and when.
+func (run *Run) History(key string) []Mutation {
+	var out []Mutation
+	for _, m := range run.mutations() {
+		if m.Key == key {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// mutations returns the recorded mutations, deriving them from the steps
+// for runs recorded before mutations were.
+func (run *Run) mutations() []Mutation {
+	if len(run.Mutations) > 0 || len(run.Steps) == 0 {
+		return run.Mutations
+	}
+	derived := &Run{Steps: run.Steps}
+	for i := range run.Steps {
+		derived.recordMutations(i)
+	}
+	return derived.Mutations
+}
+
+// recordMutations appends the changes step i made to the state: values it
+// received that differ from the state so far, then values it output.
+func (run *Run) recordMutations(i int) {
+	if len(run.Mutations) == 0 && i > 0 {
+		// A run resumed from a record that predates mutations
+		run.Mutations = (&Run{Steps: run.Steps[:i]}).mutations()
+	}
+	step := run.Steps[i]
+	state := replay(run.Mutations, i-1)
+	diff := func(data map[string]any, source string, at time.Time) {
+		keys := make([]string, 0, len(data))
+		for k := range data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			prev, had := state[k]
+			if had && sameValue(prev, data[k]) {
+				continue
+			}
+			m := Mutation{Step: i, Agent: step.Agent, Source: source, Key: k, Value: data[k], Previous: prev, At: at}
+			if source == SourceOutput {
+				if w := step.Writers[k]; w != "" {
+					m.Agent = w
+				}
+				m.Candidates = candidates(step, k)
+			}
+			run.Mutations = append(run.Mutations, m)
+			state[k] = data[k]
+		}
+	}
+	diff(step.Input, SourceInput, step.StartedAt)
+	diff(step.Output, SourceOutput, step.EndedAt)
+}
+
+// sameValue compares values by their JSON, so a value read back from the
+// store equals the one recorded.
+func sameValue(a, b any) bool {
+	x, errA := json.Marshal(a)
+	y, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(x) == string(y)
+}
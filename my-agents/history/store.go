@@ -0,0 +1,178 @@
+package history
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"my-agents/storage"
+)
+
+// MemoryStore keeps runs in memory.
+type MemoryStore struct {
+	mu   sync.RWMutex
+	runs map[string]*Run
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{runs: make(map[string]*Run)}
+}
+
+func (s *MemoryStore) Save(ctx context.Context, run *Run) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.runs[run.ID] = clone(run)
+	return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, id string) (*Run, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	run, ok := s.runs[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return clone(run), nil
+}
+
+func (s *MemoryStore) List(ctx context.Context, filter Filter) ([]*Run, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var runs []*Run
+	for _, run := range s.runs {
+		if filter.Match(run) {
+			runs = append(runs, clone(run))
+		}
+	}
+	return sortAndLimit(runs, filter.Limit), nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.runs, id)
+	return nil
+}
+
+// FileStore keeps one JSON file per run in a directory.
+type FileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileStore creates a store rooted at dir, creating it if needed.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create history directory %s: %w", dir, err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(id string) string {
+	return filepath.Join(s.dir, filepath.Base(id)+".json")
+}
+
+func (s *FileStore) Save(ctx context.Context, run *Run) error {
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Write-then-rename so readers never see a partially written run
+	tmp := s.path(run.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(run.ID))
+}
+
+func (s *FileStore) Get(ctx context.Context, id string) (*Run, error) {
+	data, err := os.ReadFile(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var run Run
+	if err := json.Unmarshal(data, &run); err != nil {
+		return nil, fmt.Errorf("failed to decode run %s: %w", id, err)
+	}
+	return &run, nil
+}
+
+func (s *FileStore) List(ctx context.Context, filter Filter) ([]*Run, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var runs []*Run
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		run, err := s.Get(ctx, strings.TrimSuffix(e.Name(), ".json"))
+		if err != nil {
+			return nil, err
+		}
+		if filter.Match(run) {
+			runs = append(runs, run)
+		}
+	}
+	return sortAndLimit(runs, filter.Limit), nil
+}
+
+func (s *FileStore) Delete(ctx context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Config is the [history] section of agentflow.toml.
+type Config struct {
+	Backend string `toml:"backend"` // "sqlite" (default), "file" or "memory"
+	// Path is the database file for the sqlite backend (default
+	// .agentflow/agentflow.db) or the directory for the file backend
+	// (default .agentflow/runs).
+	Path string `toml:"path"`
+}
+
+// Open creates the configured store.
+func Open(cfg Config) (Store, error) {
+	switch cfg.Backend {
+	case "", storage.BackendSQLite:
+		db, err := storage.Open(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		return NewSQLiteStore(db)
+	case storage.BackendFile:
+		path := cfg.Path
+		if path == "" {
+			path = filepath.Join(".agentflow", "runs")
+		}
+		return NewFileStore(path)
+	case storage.BackendMemory:
+		return NewMemoryStore(), nil
+	default:
+		return nil, fmt.Errorf("unknown history backend %q", cfg.Backend)
+	}
+}
+
+// clone deep-copies a run via JSON so stored runs can't be mutated by callers.
+func clone(run *Run) *Run {
+	data, _ := json.Marshal(run)
+	var out Run
+	_ = json.Unmarshal(data, &out)
+	return &out
+}
@@ -0,0 +1,66 @@
+package history
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// FinalResult is what a run produced by the time it ended.
+type FinalResult struct {
+	RunID    string
+	Status   string // StatusCompleted, StatusFailed or StatusAwaitingInput
+	Response string // the formatter's final response
+	Question string // set when the run paused on a clarification question
+	// State merges every step's output in order, so a key holds the value
+	// the last agent to set it left.
+	State map[string]any
+	// Result is the terminal agent's own result.
+	Result core.AgentResult
+	Run    *Run
+}
+
+// ProcessSync emits event on runner and blocks until the routing chain it
+// starts ends or ctx is done. A failed run returns its result along with
+// the failure; a run paused on a question is not an error.
+func (r *Recorder) ProcessSync(ctx context.Context, runner core.Runner, event core.Event) (FinalResult, error) {
+	runID, ok := event.GetMetadataValue(RunIDKey)
+	if !ok || runID == "" {
+		runID = event.GetID()
+	}
+	// Waiting starts before the emit, so a quick run can't end unseen
+	ch := r.await(runID)
+	defer r.cancel(runID, ch)
+	if err := runner.Emit(event); err != nil {
+		return FinalResult{RunID: runID}, err
+	}
+	c, err := r.wait(ctx, runID, ch)
+	if err != nil {
+		return FinalResult{RunID: runID}, err
+	}
+	result := finalResult(c)
+	if err := c.Err(); err != nil {
+		return result, fmt.Errorf("run %s failed: %w", runID, err)
+	}
+	return result, nil
+}
+
+func finalResult(c Completion) FinalResult {
+	run := c.Run
+	state := make(map[string]any)
+	for _, step := range run.Steps {
+		for k, v := range step.Output {
+			state[k] = v
+		}
+	}
+	return FinalResult{
+		RunID:    run.ID,
+		Status:   run.Status,
+		Response: run.FinalResponse,
+		Question: run.Question,
+		State:    state,
+		Result:   c.Result,
+		Run:      run,
+	}
+}
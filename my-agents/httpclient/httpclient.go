@@ -0,0 +1,27 @@
+// Package httpclient builds the HTTP clients of the model providers. A
+// client-wide timeout would cut off a stream still answering, so these
+// bound the connection and the wait for the response to start instead,
+// leaving the body to the caller's context.
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// Connection timeouts.
+const (
+	DialTimeout         = 30 * time.Second
+	TLSHandshakeTimeout = 10 * time.Second
+)
+
+// New creates a client waiting up to responseTimeout for a response's
+// headers: the whole answer of a call, the start of a stream.
+func New(responseTimeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: DialTimeout, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = TLSHandshakeTimeout
+	transport.ResponseHeaderTimeout = responseTimeout
+	return &http.Client{Transport: transport}
+}
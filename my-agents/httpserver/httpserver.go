@@ -0,0 +1,431 @@
+// Package httpserver exposes the pipeline as a REST API, so web frontends
+// and other services can submit requests and read their outcome without
+// embedding the Go code:
+//
+//	POST /events               {"input": "...", "session_id": "...", "metadata": {...}}
+//	GET  /events/{id}          the run's status, final response and state
+//	GET  /events/{id}/stream   the response as server-sent events, as it is generated
+//	GET  /events/{id}/citations/{n}  the source of the response's [n] passage, with retrieval
+//	GET  /sources/{citation}   a cited chunk with its neighbours in the document, with retrieval
+//	GET  /usage                the caller's quota usage, when quotas are enabled
+//	GET  /healthz
+package httpserver
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/admin"
+	"my-agents/auth"
+	"my-agents/deadletter"
+	"my-agents/history"
+	"my-agents/plan"
+	"my-agents/quota"
+	"my-agents/react"
+	"my-agents/retrieval"
+	"my-agents/scratchpad"
+	"my-agents/stream"
+	"my-agents/usage"
+)
+
+// Config is the [http] section of agentflow.toml.
+type Config struct {
+	Addr string `toml:"addr"` // listen address (default 127.0.0.1:8080)
+	// Routes are the agents callers may send events to; the first is the
+	// default (default ["processor"]).
+	Routes []string `toml:"routes"`
+	// TokenEnv, when set, names the env var holding a bearer token every
+	// request but /healthz must carry: the token of the "default" client,
+	// a gateway acting for its users. Without it or Clients the API is
+	// open, its callers anonymous and limited only by [quotas] per address.
+	TokenEnv string `toml:"token_env"`
+	// Clients give each caller its own token, bound to a tenant and user.
+	Clients map[string]auth.Client `toml:"clients"`
+	// AllowOrigins are the browser origins allowed to call the API ("*" for
+	// any).
+	AllowOrigins []string `toml:"allow_origins"`
+	// WaitTimeout bounds how long POST /events?wait=true holds the request
+	// for the run to finish (default 2m).
+	WaitTimeout string `toml:"wait_timeout"`
+}
+
+// reserved are event metadata keys the pipeline sets itself, or from the
+// authenticated principal, which callers can't supply.
+var reserved = append([]string{
+	history.RunIDKey, history.RerunOfKey, history.ForwardedKey, core.RouteMetadataKey, core.SessionIDKey,
+	"status", deadletter.RedrivesKey, usage.WorkflowKey, retrieval.FilterKey, plan.ModeKey,
+	"deployment", // deploy.VersionKey: operators send test traffic to candidates
+}, auth.Keys...)
+
+// Request is the body of POST /events.
+type Request struct {
+	Input     string            `json:"input"`
+	SessionID string            `json:"session_id,omitempty"`
+	Route     string            `json:"route,omitempty"` // one of [http] routes (default the first)
+	Metadata  map[string]string `json:"metadata,omitempty"`
+}
+
+// Event is a submitted event's run as GET /events/{id} reports it. Status
+// is "queued" until the run starts.
+type Event struct {
+	ID            string         `json:"id"`
+	SessionID     string         `json:"session_id,omitempty"`
+	Status        string         `json:"status"`
+	FinalResponse string         `json:"final_response,omitempty"`
+	Question      string         `json:"question,omitempty"` // set when the run awaits an answer
+	Error         string         `json:"error,omitempty"`
+	State         map[string]any `json:"state,omitempty"`
+	StartedAt     time.Time      `json:"started_at,omitzero"`
+	EndedAt       time.Time      `json:"ended_at,omitzero"`
+}
+
+// StatusQueued is the status of an accepted event no agent has started.
+const StatusQueued = "queued"
+
+// Server serves the API over a started runner.
+type Server struct {
+	cfg      Config
+	runner   core.Runner
+	recorder *history.Recorder
+	quotas   *quota.Manager
+	streams  *stream.Hub
+	tokens   map[string]string // client by token
+	clients  map[string]auth.Client
+	wait     time.Duration
+	mux      *http.ServeMux
+
+	mu       sync.Mutex
+	accepted map[string]accepted // emitted events their run's record may not show yet
+}
+
+// accepted is an emitted event: when, and the caller's metadata.
+type accepted struct {
+	at       time.Time
+	metadata map[string]string
+}
+
+// New creates the API emitting on runner and reading runs from recorder's
+// store. quotas and streams may be nil, leaving out quota enforcement and
+// streaming.
+func New(cfg Config, runner core.Runner, recorder *history.Recorder, quotas *quota.Manager, streams *stream.Hub) (*Server, error) {
+	if len(cfg.Routes) == 0 {
+		cfg.Routes = []string{"processor"}
+	}
+	s := &Server{
+		cfg:      cfg,
+		runner:   runner,
+		recorder: recorder,
+		quotas:   quotas,
+		streams:  streams,
+		wait:     2 * time.Minute,
+		mux:      http.NewServeMux(),
+		accepted: make(map[string]accepted),
+	}
+	s.clients = make(map[string]auth.Client, len(cfg.Clients)+1)
+	for name, c := range cfg.Clients {
+		s.clients[name] = c
+	}
+	if cfg.TokenEnv != "" {
+		s.clients[defaultClient] = auth.Client{TokenEnv: cfg.TokenEnv, ActsForUsers: true}
+	}
+	s.tokens = make(map[string]string, len(s.clients))
+	for name, c := range s.clients {
+		token := os.Getenv(c.TokenEnv)
+		if c.TokenEnv == "" || token == "" {
+			return nil, fmt.Errorf("HTTP API client %s needs a token in token_env", name)
+		}
+		if other, ok := s.tokens[token]; ok {
+			return nil, fmt.Errorf("HTTP API clients %s and %s share a token", other, name)
+		}
+		s.tokens[token] = name
+	}
+	if cfg.WaitTimeout != "" {
+		d, err := time.ParseDuration(cfg.WaitTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("http wait_timeout: %w", err)
+		}
+		s.wait = d
+	}
+	s.routes()
+	return s, nil
+}
+
+// defaultClient is the client token_env authenticates.
+const defaultClient = "default"
+
+// Mount adds extra handlers under the API's authentication; they find the
+// caller with auth.FromContext.
+func (s *Server) Mount(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, h)
+}
+
+func (s *Server) routes() {
+	var submit http.Handler = http.HandlerFunc(s.submit)
+	if s.quotas != nil {
+		submit = s.quotas.Limit(submit)
+		s.mux.Handle("GET /usage", s.quotas.UsageHandler())
+	}
+	s.mux.Handle("POST /events", submit)
+	s.mux.HandleFunc("GET /events/{id}", func(w http.ResponseWriter, r *http.Request) {
+		event, err := s.event(r.Context(), r.PathValue("id"))
+		if err != nil {
+			writeEventError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, event)
+	})
+	if s.streams != nil {
+		s.mux.HandleFunc("GET /events/{id}/stream", func(w http.ResponseWriter, r *http.Request) {
+			if _, err := s.event(r.Context(), r.PathValue("id")); err != nil {
+				writeEventError(w, err)
+				return
+			}
+			s.streams.ServeRun(w, r, s.recorder.Store(), r.PathValue("id"))
+		})
+	}
+	s.mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	})
+}
+
+// submit emits a request as a new run. With ?wait=true it answers when the
+// run ends, or pauses on a question, instead of right away.
+func (s *Server) submit(w http.ResponseWriter, r *http.Request) {
+	var req Request
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if strings.TrimSpace(req.Input) == "" {
+		writeError(w, http.StatusBadRequest, errors.New("input is required"))
+		return
+	}
+	route := req.Route
+	if route == "" {
+		route = s.cfg.Routes[0]
+	} else if !slices.Contains(s.cfg.Routes, route) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("route %q is not open to the API", route))
+		return
+	}
+
+	metadata := make(map[string]string, len(req.Metadata)+4)
+	for k, v := range req.Metadata {
+		if !slices.Contains(reserved, k) {
+			metadata[k] = v
+		}
+	}
+	principal, _ := auth.FromContext(r.Context())
+	for k, v := range principal.Metadata() {
+		metadata[k] = v
+	}
+	metadata[core.RouteMetadataKey] = route
+	if req.SessionID != "" {
+		metadata[core.SessionIDKey] = req.SessionID
+	}
+	event := core.NewEvent(route, core.EventData{"input": req.Input}, metadata)
+	w.Header().Set("Location", "/events/"+event.GetID())
+
+	s.track(event.GetID(), principal.Metadata())
+	if r.URL.Query().Get("wait") == "true" {
+		ctx, cancel := context.WithTimeout(r.Context(), s.wait)
+		defer cancel()
+		result, err := s.recorder.ProcessSync(ctx, s.runner, event)
+		if result.Run == nil && err != nil && ctx.Err() == nil {
+			s.untrack(event.GetID())
+			writeEmitError(w, err)
+			return
+		}
+		// A failed run reports its error in the event
+		reply, err := s.event(r.Context(), event.GetID())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		status := http.StatusOK
+		if reply.Status == StatusQueued || reply.Status == history.StatusRunning {
+			status = http.StatusAccepted // still running; poll the Location
+		}
+		writeJSON(w, status, reply)
+		return
+	}
+	if err := s.runner.Emit(event); err != nil {
+		s.untrack(event.GetID())
+		writeEmitError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusAccepted, Event{ID: event.GetID(), SessionID: req.SessionID, Status: StatusQueued})
+}
+
+// writeEventError writes the error of looking up an event.
+func writeEventError(w http.ResponseWriter, err error) {
+	if errors.Is(err, history.ErrNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err)
+}
+
+// writeEmitError writes the error of an event the runner didn't accept.
+func writeEmitError(w http.ResponseWriter, err error) {
+	var exceeded *quota.ExceededError
+	switch {
+	case errors.As(err, &exceeded):
+		quota.WriteError(w, err)
+	case errors.Is(err, admin.ErrDraining):
+		w.Header().Set("Retry-After", "30")
+		writeError(w, http.StatusServiceUnavailable, err)
+	case errors.Is(err, admin.ErrWorkflowDisabled):
+		writeError(w, http.StatusForbidden, err)
+	default:
+		writeError(w, http.StatusInternalServerError, err)
+	}
+}
+
+// event reports the run of event id.
+// event reports on the event id the caller in ctx emitted. Another caller's
+// event is not found, as if it didn't exist.
+func (s *Server) event(ctx context.Context, id string) (Event, error) {
+	caller, _ := auth.FromContext(ctx)
+	run, err := s.recorder.Store().Get(ctx, id)
+	if errors.Is(err, history.ErrNotFound) {
+		if metadata, ok := s.tracked(id); ok && caller.Owns(metadata) {
+			return Event{ID: id, Status: StatusQueued}, nil
+		}
+	}
+	if err != nil {
+		return Event{}, err
+	}
+	if !caller.Owns(run.Metadata) {
+		return Event{}, history.ErrNotFound
+	}
+	s.untrack(id)
+	return Event{
+		ID:            run.ID,
+		SessionID:     run.SessionID,
+		Status:        run.Status,
+		FinalResponse: run.FinalResponse,
+		Question:      run.Question,
+		Error:         run.Error,
+		State:         scratchpad.Strip(run.State(len(run.Steps)-1), react.TrajectoryKey),
+		StartedAt:     run.StartedAt,
+		EndedAt:       run.EndedAt,
+	}, nil
+}
+
+// queuedFor is how long an accepted event is reported queued without a run
+// record before it is assumed dropped.
+const queuedFor = 10 * time.Minute
+
+func (s *Server) track(id string, metadata map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	for other, a := range s.accepted {
+		if now.Sub(a.at) > queuedFor {
+			delete(s.accepted, other)
+		}
+	}
+	s.accepted[id] = accepted{at: now, metadata: metadata}
+}
+
+func (s *Server) untrack(id string) {
+	s.mu.Lock()
+	delete(s.accepted, id)
+	s.mu.Unlock()
+}
+
+// tracked returns the metadata of the accepted event id.
+func (s *Server) tracked(id string) (map[string]string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	a, ok := s.accepted[id]
+	if !ok || time.Since(a.at) > queuedFor {
+		return nil, false
+	}
+	return a.metadata, true
+}
+
+// ServeHTTP applies CORS and authentication and dispatches the request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if origin := r.Header.Get("Origin"); origin != "" && s.allowed(origin) {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+		w.Header().Set("Vary", "Origin")
+		if r.Method == http.MethodOptions {
+			w.Header().Set("Access-Control-Allow-Methods", "GET, POST")
+			w.Header().Set("Access-Control-Allow-Headers", strings.Join([]string{"Authorization", "Content-Type", auth.UserHeader, auth.TenantHeader}, ", "))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	principal := auth.Anonymous(r)
+	if len(s.tokens) > 0 && r.URL.Path != "/healthz" {
+		name, ok := s.authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			writeError(w, http.StatusUnauthorized, errors.New("missing or invalid token"))
+			return
+		}
+		principal = auth.Authenticated(name, s.clients[name], r)
+	}
+	s.mux.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), principal)))
+}
+
+// authenticate returns the client whose bearer token r carries.
+func (s *Server) authenticate(r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return "", false
+	}
+	var client string
+	for t, name := range s.tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			client = name
+		}
+	}
+	return client, client != ""
+}
+
+func (s *Server) allowed(origin string) bool {
+	return slices.Contains(s.cfg.AllowOrigins, "*") || slices.Contains(s.cfg.AllowOrigins, origin)
+}
+
+// ListenAndServe serves on the configured address until ctx is done.
+func (s *Server) ListenAndServe(ctx context.Context) error {
+	addr := s.cfg.Addr
+	if addr == "" {
+		addr = "127.0.0.1:8080"
+	}
+	srv := &http.Server{Addr: addr, Handler: s, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		srv.Shutdown(shutdown)
+	}()
+	if err := srv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,144 @@
+package httpserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/auth"
+	"my-agents/history"
+	"my-agents/stream"
+)
+
+// emitter is a runner that only takes events.
+type emitter struct {
+	core.Runner
+	mu     sync.Mutex
+	events []core.Event
+}
+
+func (e *emitter) Emit(event core.Event) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, event)
+	return nil
+}
+
+func newServer(t *testing.T) (*Server, *emitter, history.Store) {
+	t.Helper()
+	t.Setenv("ACME_TOKEN", "acme-secret")
+	t.Setenv("GLOBEX_TOKEN", "globex-secret")
+	runner := &emitter{}
+	store := history.NewMemoryStore()
+	s, err := New(Config{Clients: map[string]auth.Client{
+		"acme":   {TokenEnv: "ACME_TOKEN", Tenant: "acme", User: "ann"},
+		"globex": {TokenEnv: "GLOBEX_TOKEN", Tenant: "globex", User: "ann"},
+	}}, runner, history.NewRecorder(store), nil, stream.NewHub())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, runner, store
+}
+
+func do(s *Server, method, path, token, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func TestSubmitSetsPrincipal(t *testing.T) {
+	s, runner, _ := newServer(t)
+	w := do(s, http.MethodPost, "/events", "acme-secret", `{"input":"hi","metadata":{"tenant_id":"globex","principal":"client:x","lang":"de"}}`)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("POST /events = %d %s", w.Code, w.Body)
+	}
+	md := runner.events[0].GetMetadata()
+	if md[auth.IdentityKey] != "user:acme/ann" || md["tenant_id"] != "acme" || md["lang"] != "de" {
+		t.Errorf("event metadata = %v, want the principal's identity and tenant over the caller's", md)
+	}
+	if md[core.RouteMetadataKey] != "processor" {
+		t.Errorf("route = %q, want the default", md[core.RouteMetadataKey])
+	}
+}
+
+func TestSubmitRejects(t *testing.T) {
+	s, _, _ := newServer(t)
+	for _, tc := range []struct {
+		token, body string
+		want        int
+	}{
+		{"", `{"input":"hi"}`, http.StatusUnauthorized},
+		{"wrong", `{"input":"hi"}`, http.StatusUnauthorized},
+		{"acme-secret", `{"input":" "}`, http.StatusBadRequest},
+		{"acme-secret", `{"input":"hi","route":"admin"}`, http.StatusBadRequest},
+		{"acme-secret", `not json`, http.StatusBadRequest},
+	} {
+		if w := do(s, http.MethodPost, "/events", tc.token, tc.body); w.Code != tc.want {
+			t.Errorf("POST %s with token %q = %d, want %d", tc.body, tc.token, w.Code, tc.want)
+		}
+	}
+}
+
+func TestEventsAreTheCallersOwn(t *testing.T) {
+	s, _, store := newServer(t)
+	w := do(s, http.MethodPost, "/events", "acme-secret", `{"input":"hi"}`)
+	var accepted Event
+	if err := json.NewDecoder(w.Body).Decode(&accepted); err != nil {
+		t.Fatal(err)
+	}
+	path := "/events/" + accepted.ID
+
+	// Queued, before the run is recorded
+	if w := do(s, http.MethodGet, path, "acme-secret", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), StatusQueued) {
+		t.Errorf("the caller's queued event = %d %s", w.Code, w.Body)
+	}
+	if w := do(s, http.MethodGet, path, "globex-secret", ""); w.Code != http.StatusNotFound {
+		t.Errorf("another tenant's queued event = %d, want 404", w.Code)
+	}
+
+	principal := auth.Principal{Client: "acme", Tenant: "acme", User: "ann"}
+	now := time.Now()
+	if err := store.Save(context.Background(), &history.Run{
+		ID: accepted.ID, Status: history.StatusCompleted, FinalResponse: "hello",
+		Metadata: principal.Metadata(), StartedAt: now, EndedAt: now,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if w := do(s, http.MethodGet, path, "acme-secret", ""); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "hello") {
+		t.Errorf("the caller's run = %d %s", w.Code, w.Body)
+	}
+	for _, p := range []string{path, path + "/stream"} {
+		w := do(s, http.MethodGet, p, "globex-secret", "")
+		if w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "hello") {
+			t.Errorf("GET %s of another tenant's run = %d %s, want 404", p, w.Code, w.Body)
+		}
+	}
+	if w := do(s, http.MethodGet, path+"/stream", "acme-secret", ""); !strings.Contains(w.Body.String(), `"final":"hello"`) {
+		t.Errorf("the caller's stream = %d %s", w.Code, w.Body)
+	}
+}
+
+func TestUnknownEvent(t *testing.T) {
+	s, _, _ := newServer(t)
+	if w := do(s, http.MethodGet, "/events/nope", "acme-secret", ""); w.Code != http.StatusNotFound {
+		t.Errorf("GET of an unknown event = %d, want 404", w.Code)
+	}
+}
+
+func TestHealthzIsOpen(t *testing.T) {
+	s, _, _ := newServer(t)
+	if w := do(s, http.MethodGet, "/healthz", "", ""); w.Code != http.StatusOK {
+		t.Errorf("GET /healthz = %d", w.Code)
+	}
+}
@@ -0,0 +1,330 @@
+// Package ingest loads files and URLs into the memory knowledge base and the
+// retrieval vector store, running OCR on images and scanned PDFs so their
+// text is searchable. Ingested sources are tracked by version so a refresh
+// re-ingests only what changed and tombstones what was deleted.
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/ocr"
+	"my-agents/retrieval"
+)
+
+// DefaultWorkers is how many sources are ingested at once when the
+// Ingester's Workers is unset.
+const DefaultWorkers = 4
+
+// minTextLayer is how many letters a PDF's text layer needs before it is
+// trusted; below that the PDF is treated as scanned and OCR'd.
+const minTextLayer = 32
+
+// Ingester extracts text from files and stores it in memory, and chunks of
+// it in the vector store.
+type Ingester struct {
+	Memory  core.Memory          // nil leaves documents out of memory
+	Vectors *retrieval.Retriever // nil leaves documents out of the vector store
+	OCR     ocr.Engine           // nil disables OCR; images are then rejected
+	DPI     int
+	// Tags are added to the metadata of every chunk indexed.
+	Tags map[string]string
+
+	// Sources records the version of each source ingested; nil leaves them
+	// untracked, so they can't be refreshed.
+	Sources *Sources
+	// Client fetches URL sources; nil uses http.DefaultClient.
+	Client *http.Client
+	// Workers is how many sources Files and Refresh ingest at once
+	// (default DefaultWorkers).
+	Workers int
+	// Resume has File skip sources already ingested at their current
+	// version with the same tags, returning ErrUnchanged, so an interrupted
+	// run over many files picks up where it stopped.
+	Resume bool
+}
+
+// Load extracts a document from path, a file or an http(s) URL, without
+// storing it.
+func (in *Ingester) Load(ctx context.Context, path string) (core.Document, error) {
+	doc, v, err := in.load(ctx, path, Source{})
+	doc.ID = documentID(v.Source, in.Tags)
+	return doc, err
+}
+
+// loadFile extracts a document from the file at path.
+func (in *Ingester) loadFile(ctx context.Context, path string) (core.Document, error) {
+	doc := core.Document{
+		Title:     filepath.Base(path),
+		Source:    path,
+		Metadata:  map[string]any{},
+		CreatedAt: time.Now(),
+	}
+
+	ext := strings.ToLower(filepath.Ext(path))
+	switch {
+	case ext == ".pdf":
+		doc.Type = core.DocumentTypePDF
+		text, err := ocr.PDFText(ctx, path)
+		if err == nil && letters(text) >= minTextLayer {
+			doc.Content = text
+			break
+		}
+		if in.OCR == nil {
+			if err != nil {
+				return doc, err
+			}
+			return doc, fmt.Errorf("%s has no text layer and OCR is not configured", path)
+		}
+		if doc.Content, err = ocr.File(ctx, in.OCR, path, in.DPI); err != nil {
+			return doc, err
+		}
+		doc.Metadata["ocr"] = true
+	case ocr.IsImage(path):
+		if in.OCR == nil {
+			return doc, fmt.Errorf("%s is an image and OCR is not configured", path)
+		}
+		doc.Type = core.DocumentTypeText
+		text, err := ocr.File(ctx, in.OCR, path, in.DPI)
+		if err != nil {
+			return doc, err
+		}
+		doc.Content = text
+		doc.Metadata["ocr"] = true
+	default:
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return doc, err
+		}
+		doc.Content = string(data)
+		doc.Type = textType(ext)
+	}
+
+	if strings.TrimSpace(doc.Content) == "" {
+		return doc, fmt.Errorf("no text extracted from %s", path)
+	}
+	return doc, nil
+}
+
+// File loads path, a file or an http(s) URL, and ingests it, replacing the
+// document an earlier version of it produced. With a vector store, the
+// document's "chunks" metadata is how many chunks of it were indexed. When
+// resuming, it returns ErrUnchanged for sources already ingested at their
+// current version.
+func (in *Ingester) File(ctx context.Context, path string) (core.Document, error) {
+	return in.file(ctx, path, in.Tags)
+}
+
+// Job is a source for Files to ingest.
+type Job struct {
+	Path string
+	// Tags replace the Ingester's for this source when set.
+	Tags map[string]string
+}
+
+// Result is how ingesting a Job went, as File reports it.
+type Result struct {
+	Job
+	Document core.Document
+	Err      error
+}
+
+// Files ingests jobs like File, Workers at a time, calling done with each
+// result as it finishes; done is never called concurrently. Embedding
+// requests are paced by the retriever's embedder, shared by the workers.
+// Jobs not started before ctx ends are left without a result.
+func (in *Ingester) Files(ctx context.Context, jobs []Job, done func(Result)) {
+	var mu sync.Mutex
+	in.each(ctx, len(jobs), func(i int) {
+		r := Result{Job: jobs[i]}
+		tags := r.Tags
+		if tags == nil {
+			tags = in.Tags
+		}
+		r.Document, r.Err = in.file(ctx, r.Path, tags)
+		mu.Lock()
+		defer mu.Unlock()
+		done(r)
+	})
+}
+
+// each calls fn with 0 to n-1, Workers at a time, until ctx ends.
+func (in *Ingester) each(ctx context.Context, n int, fn func(i int)) {
+	workers := in.Workers
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, workers)
+	for i := range n {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			wg.Wait()
+			return
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			fn(i)
+		}()
+	}
+	wg.Wait()
+}
+
+func (in *Ingester) file(ctx context.Context, path string, tags map[string]string) (core.Document, error) {
+	var prev Source
+	if in.Resume && in.Sources != nil {
+		src, ok, err := in.Sources.Get(ctx, sourceName(path))
+		if err != nil {
+			return core.Document{}, err
+		}
+		interrupted := false
+		if in.Vectors != nil {
+			if _, interrupted, err = in.Vectors.Checkpoint(ctx, documentID(sourceName(path), tags)); err != nil {
+				return core.Document{}, err
+			}
+		}
+		// A re-ingestion of the version that was interrupted isn't done
+		if ok && !src.Deleted() && !interrupted && maps.Equal(src.Tags, tags) {
+			prev = src
+		}
+	}
+	doc, v, err := in.load(ctx, path, prev)
+	if errors.Is(err, errGone) {
+		err = fmt.Errorf("%s: %w", path, err)
+	}
+	if err != nil {
+		return doc, err
+	}
+	doc.ID, v.Tags = documentID(v.Source, tags), tags
+	return doc, in.store(ctx, doc, v)
+}
+
+// Upload ingests data, the content of a file called name sent by a client.
+// Uploads aren't tracked as sources, there being nothing to refresh them
+// from; uploading the same name again to the same collection and tenant
+// replaces the document.
+func (in *Ingester) Upload(ctx context.Context, name string, data []byte) (core.Document, error) {
+	name = filepath.Base(name)
+	doc, err := in.loadData(ctx, data, filepath.Ext(name))
+	source := "upload:" + name
+	doc.ID, doc.Title, doc.Source = documentID(source, in.Tags), name, source
+	if err != nil {
+		return doc, fmt.Errorf("%s: %w", name, err)
+	}
+	return doc, in.index(ctx, doc, in.Tags)
+}
+
+// loadData extracts a document from data, as a file with extension ext.
+func (in *Ingester) loadData(ctx context.Context, data []byte, ext string) (core.Document, error) {
+	// The file loaders pick OCR and the document type by extension
+	tmp, err := os.CreateTemp("", "ingest-*"+ext)
+	if err != nil {
+		return core.Document{}, err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return core.Document{}, err
+	}
+	return in.loadFile(ctx, tmp.Name())
+}
+
+// store ingests doc, read from the source version v, and records v.
+func (in *Ingester) store(ctx context.Context, doc core.Document, v Source) error {
+	if err := in.index(ctx, doc, v.Tags); err != nil {
+		return err
+	}
+	if in.Sources == nil {
+		return nil
+	}
+	now := time.Now()
+	v.DocumentID, v.IngestedAt, v.CheckedAt = doc.ID, now, now
+	return in.Sources.Put(ctx, v)
+}
+
+// index ingests doc into memory and the vector store, its chunks tagged
+// with tags.
+func (in *Ingester) index(ctx context.Context, doc core.Document, tags map[string]string) error {
+	if in.Memory != nil {
+		if err := in.Memory.IngestDocument(ctx, doc); err != nil {
+			return fmt.Errorf("failed to ingest %s: %w", doc.Source, err)
+		}
+	}
+	if in.Vectors != nil {
+		chunks, err := in.Vectors.IndexDocument(ctx, doc, tags)
+		if err != nil {
+			return fmt.Errorf("failed to index %s: %w", doc.Source, err)
+		}
+		doc.Metadata["chunks"] = chunks
+	}
+	return nil
+}
+
+func textType(ext string) core.DocumentType {
+	switch ext {
+	case ".md", ".markdown":
+		return core.DocumentTypeMarkdown
+	case ".json":
+		return core.DocumentTypeJSON
+	case ".html", ".htm":
+		return core.DocumentTypeWeb
+	case ".go", ".py", ".js", ".ts", ".java", ".rs", ".c", ".cpp":
+		return core.DocumentTypeCode
+	default:
+		return core.DocumentTypeText
+	}
+}
+
+// documentID is stable per source and the collection and tenant tags file
+// it under, so re-ingesting a source replaces its document there, not the
+// one another collection or tenant has of a source by the same name.
+func documentID(source string, tags map[string]string) string {
+	for _, key := range []string{retrieval.TenantKey, retrieval.CollectionKey} {
+		if v := tags[key]; v != "" {
+			source = key + "=" + v + "\n" + source
+		}
+	}
+	sum := sha256.Sum256([]byte(source))
+	return "doc-" + hex.EncodeToString(sum[:8])
+}
+
+// sourceName is how path is tracked: URLs as given, files by absolute path.
+func sourceName(path string) string {
+	if isURL(path) {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	return abs
+}
+
+func letters(s string) int {
+	n := 0
+	for _, r := range s {
+		if unicode.IsLetter(r) {
+			n++
+		}
+	}
+	return n
+}
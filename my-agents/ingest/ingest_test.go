@@ -0,0 +1,160 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/retrieval"
+)
+
+// fakeMemory records the documents ingested into it.
+type fakeMemory struct {
+	core.Memory
+	mu      sync.Mutex
+	docs    map[string]core.Document
+	deleted []string
+}
+
+func (m *fakeMemory) IngestDocument(ctx context.Context, doc core.Document) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.docs == nil {
+		m.docs = make(map[string]core.Document)
+	}
+	m.docs[doc.ID] = doc
+	return nil
+}
+
+func (m *fakeMemory) DeleteDocument(ctx context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.docs, id)
+	m.deleted = append(m.deleted, id)
+	return nil
+}
+
+type fakeOCR struct{ text string }
+
+func (o fakeOCR) Recognize(ctx context.Context, imagePath string) (string, error) {
+	return o.text, nil
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	in := &Ingester{}
+	doc, err := in.Load(context.Background(), writeFile(t, dir, "guide.md", "# Guide"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Type != core.DocumentTypeMarkdown || doc.Title != "guide.md" || doc.Content != "# Guide" || doc.ID == "" {
+		t.Errorf("Load = %+v", doc)
+	}
+
+	image := writeFile(t, dir, "scan.png", "png")
+	if _, err := in.Load(context.Background(), image); err == nil {
+		t.Error("Load of an image without OCR succeeded")
+	}
+	in.OCR = fakeOCR{text: "scanned text"}
+	doc, err = in.Load(context.Background(), image)
+	if err != nil || doc.Content != "scanned text" || doc.Metadata["ocr"] != true {
+		t.Errorf("Load of an image = %+v, %v", doc, err)
+	}
+
+	if _, err := in.Load(context.Background(), writeFile(t, dir, "blank.txt", " \n")); err == nil {
+		t.Error("Load of a blank file succeeded")
+	}
+}
+
+func TestFileResume(t *testing.T) {
+	ctx := context.Background()
+	sources, err := OpenSources(filepath.Join(t.TempDir(), "agentflow.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mem := &fakeMemory{}
+	in := &Ingester{Memory: mem, Sources: sources, Resume: true}
+	path := writeFile(t, t.TempDir(), "notes.txt", "some notes")
+
+	doc, err := in.File(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := mem.docs[doc.ID]; !ok {
+		t.Fatalf("document %s not in memory", doc.ID)
+	}
+	src, ok, err := sources.Get(ctx, doc.Source)
+	if err != nil || !ok || src.DocumentID != doc.ID || src.Version == "" {
+		t.Fatalf("tracked source = %+v, %v, %v", src, ok, err)
+	}
+	if _, err := in.File(ctx, path); !errors.Is(err, ErrUnchanged) {
+		t.Errorf("File again = %v, want ErrUnchanged", err)
+	}
+	in.Tags = map[string]string{"team": "docs"}
+	if _, err := in.File(ctx, path); err != nil {
+		t.Errorf("File with new tags = %v, want it ingested again", err)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	dir := t.TempDir()
+	jobs := []Job{
+		{Path: writeFile(t, dir, "a.txt", "alpha")},
+		{Path: writeFile(t, dir, "b.txt", "beta"), Tags: map[string]string{retrieval.TenantKey: "acme"}},
+		{Path: filepath.Join(dir, "missing.txt")},
+	}
+	mem := &fakeMemory{}
+	in := &Ingester{Memory: mem, Workers: 2}
+	failed := 0
+	in.Files(context.Background(), jobs, func(r Result) {
+		if r.Err != nil {
+			failed++
+		}
+	})
+	if failed != 1 || len(mem.docs) != 2 {
+		t.Errorf("failed = %d, ingested = %d", failed, len(mem.docs))
+	}
+}
+
+func TestUpload(t *testing.T) {
+	mem := &fakeMemory{}
+	in := &Ingester{Memory: mem}
+	doc, err := in.Upload(context.Background(), "../etc/readme.txt", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Title != "readme.txt" || doc.Source != "upload:readme.txt" || doc.Content != "hello" {
+		t.Errorf("Upload = %+v", doc)
+	}
+	again, _ := in.Upload(context.Background(), "readme.txt", []byte("hello again"))
+	if again.ID != doc.ID || len(mem.docs) != 1 {
+		t.Errorf("uploading the same name again didn't replace the document")
+	}
+}
+
+func TestDocumentID(t *testing.T) {
+	base := documentID("/a.txt", nil)
+	if documentID("/a.txt", map[string]string{"team": "docs"}) != base {
+		t.Error("documentID depends on an unrelated tag")
+	}
+	if documentID("/a.txt", map[string]string{retrieval.TenantKey: "acme"}) == base {
+		t.Error("documentID ignores the tenant")
+	}
+	if documentID("/a.txt", map[string]string{retrieval.CollectionKey: "faq"}) == base {
+		t.Error("documentID ignores the collection")
+	}
+}
@@ -0,0 +1,310 @@
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/retrieval"
+)
+
+// Config is the [knowledge] section of agentflow.toml:
+//
+//	[knowledge]
+//	refresh = true
+//	interval = "1h"
+//	workers = 8
+type Config struct {
+	// Refresh re-checks ingested sources while the pipeline runs,
+	// re-ingesting the changed ones and tombstoning the deleted ones.
+	Refresh  bool   `toml:"refresh"`
+	Interval string `toml:"interval"` // how often (default "1h")
+	// Workers is how many sources are ingested or refreshed at once
+	// (default DefaultWorkers).
+	Workers int `toml:"workers"`
+}
+
+// maxURLBytes bounds how much of a URL source is read.
+const maxURLBytes = 32 << 20
+
+var (
+	// ErrUnchanged is returned when a source still has the version it was
+	// ingested at: by File when resuming, and by load.
+	ErrUnchanged = errors.New("source is unchanged")
+	// errGone is returned by load when the source no longer exists.
+	errGone = errors.New("source no longer exists")
+)
+
+func isURL(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// load extracts the document at path, a file or a URL, and the version of
+// it read. Against prev, the version it was last ingested at, it returns
+// ErrUnchanged, with the current version, when the content is the same and
+// errGone when the source was deleted.
+func (in *Ingester) load(ctx context.Context, path string, prev Source) (core.Document, Source, error) {
+	if isURL(path) {
+		return in.loadURL(ctx, path, prev)
+	}
+	v := Source{Source: sourceName(path)}
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) && prev.Version != "" {
+		return core.Document{}, v, errGone
+	}
+	if err != nil {
+		return core.Document{}, v, err
+	}
+	v.ModTime, v.Size = info.ModTime(), info.Size()
+	if prev.Version != "" && v.ModTime.Equal(prev.ModTime) && v.Size == prev.Size {
+		v.Version = prev.Version
+		return core.Document{}, v, ErrUnchanged
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return core.Document{}, v, err
+	}
+	v.Version = contentVersion(data)
+	if v.Version == prev.Version {
+		return core.Document{}, v, ErrUnchanged
+	}
+	doc, err := in.loadFile(ctx, path)
+	doc.Source = v.Source
+	if doc.Metadata != nil {
+		doc.Metadata["modified"] = v.ModTime.UTC().Format(time.RFC3339)
+	}
+	return doc, v, err
+}
+
+// loadURL fetches the document at rawURL, conditionally on prev's
+// validators. The body is extracted like a file of its type.
+func (in *Ingester) loadURL(ctx context.Context, rawURL string, prev Source) (core.Document, Source, error) {
+	v := Source{Source: rawURL}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return core.Document{}, v, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return core.Document{}, v, err
+	}
+	if prev.Version != "" {
+		if prev.ETag != "" {
+			req.Header.Set("If-None-Match", prev.ETag)
+		}
+		if prev.LastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.LastModified)
+		}
+	}
+	client := in.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return core.Document{}, v, fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotModified && prev.Version != "":
+		v.Version, v.ETag, v.LastModified = prev.Version, prev.ETag, prev.LastModified
+		return core.Document{}, v, ErrUnchanged
+	case (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone) && prev.Version != "":
+		return core.Document{}, v, errGone
+	case resp.StatusCode != http.StatusOK:
+		return core.Document{}, v, fmt.Errorf("failed to fetch %s: %s", rawURL, resp.Status)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxURLBytes))
+	if err != nil {
+		return core.Document{}, v, fmt.Errorf("failed to fetch %s: %w", rawURL, err)
+	}
+	v.Version, v.ETag, v.LastModified = contentVersion(data), resp.Header.Get("ETag"), resp.Header.Get("Last-Modified")
+	if v.Version == prev.Version {
+		return core.Document{}, v, ErrUnchanged
+	}
+	doc, err := in.loadData(ctx, data, urlExt(u, resp.Header.Get("Content-Type")))
+	doc.Source = rawURL
+	doc.Title = path.Base(u.Path)
+	if doc.Title == "/" || doc.Title == "." {
+		doc.Title = u.Host
+	}
+	if modified, perr := http.ParseTime(v.LastModified); perr == nil && doc.Metadata != nil {
+		doc.Metadata["modified"] = modified.UTC().Format(time.RFC3339)
+	}
+	if err != nil {
+		err = fmt.Errorf("%s: %w", rawURL, err)
+	}
+	return doc, v, err
+}
+
+// urlExt is the extension of u's path, or else of its content type.
+func urlExt(u *url.URL, contentType string) string {
+	if ext := path.Ext(u.Path); ext != "" {
+		return ext
+	}
+	switch mediaType, _, _ := strings.Cut(contentType, ";"); strings.TrimSpace(mediaType) {
+	case "text/html":
+		return ".html"
+	case "text/markdown":
+		return ".md"
+	case "application/json":
+		return ".json"
+	case "application/pdf":
+		return ".pdf"
+	case "image/png":
+		return ".png"
+	case "image/jpeg":
+		return ".jpg"
+	default:
+		return ".txt"
+	}
+}
+
+func contentVersion(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// Refreshed is what a refresh did.
+type Refreshed struct {
+	Updated    []string          `json:"updated,omitempty"`    // re-ingested because they changed
+	Tombstoned []string          `json:"tombstoned,omitempty"` // deleted since they were ingested
+	Unchanged  int               `json:"unchanged"`
+	Failed     map[string]string `json:"failed,omitempty"` // source → why it couldn't be checked
+}
+
+// Refresh re-checks every tracked source that isn't tombstoned. Changed
+// sources are re-ingested, replacing their document; deleted ones are
+// tombstoned so retrieval through Sources.Live stops returning them, and
+// their document is removed from the vector store and from memory that
+// supports it. Sources that can't be checked keep the version they have.
+func (in *Ingester) Refresh(ctx context.Context) (Refreshed, error) {
+	var r Refreshed
+	if in.Sources == nil {
+		return r, fmt.Errorf("refreshing needs tracked sources")
+	}
+	sources, err := in.Sources.List(ctx)
+	if err != nil {
+		return r, err
+	}
+	var mu sync.Mutex
+	in.each(ctx, len(sources), func(i int) {
+		src := sources[i]
+		if src.Deleted() {
+			return
+		}
+		doc, v, err := in.load(ctx, src.Source, src)
+		now := time.Now()
+		var done func()
+		switch {
+		case errors.Is(err, ErrUnchanged):
+			src.Version, src.ETag, src.LastModified, src.ModTime, src.Size = v.Version, v.ETag, v.LastModified, v.ModTime, v.Size
+			src.CheckedAt = now
+			if err = in.Sources.Put(ctx, src); err == nil {
+				done = func() { r.Unchanged++ }
+			}
+		case errors.Is(err, errGone):
+			if err = in.tombstone(ctx, src, now); err == nil {
+				done = func() { r.Tombstoned = append(r.Tombstoned, src.Source) }
+			}
+		case err == nil:
+			doc.ID, v.Tags = documentID(v.Source, src.Tags), src.Tags
+			if err = in.store(ctx, doc, v); err == nil {
+				done = func() { r.Updated = append(r.Updated, src.Source) }
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			if r.Failed == nil {
+				r.Failed = make(map[string]string)
+			}
+			r.Failed[src.Source] = err.Error()
+			return
+		}
+		done()
+	})
+	if err := ctx.Err(); err != nil {
+		return r, err
+	}
+	sort.Strings(r.Updated)
+	sort.Strings(r.Tombstoned)
+	return r, nil
+}
+
+// documentDeleter is memory that can drop an ingested document.
+type documentDeleter interface {
+	DeleteDocument(ctx context.Context, id string) error
+}
+
+// tombstone marks src deleted at at.
+func (in *Ingester) tombstone(ctx context.Context, src Source, at time.Time) error {
+	src.DeletedAt, src.CheckedAt = at, at
+	if err := in.Sources.Put(ctx, src); err != nil {
+		return err
+	}
+	if d, ok := in.Memory.(documentDeleter); ok {
+		if err := d.DeleteDocument(ctx, src.DocumentID); err != nil {
+			return fmt.Errorf("failed to delete document of %s: %w", src.Source, err)
+		}
+	}
+	if in.Vectors != nil {
+		return in.Vectors.DeleteDocument(ctx, src.Tags[retrieval.CollectionKey], src.DocumentID)
+	}
+	return nil
+}
+
+// Refresher refreshes an ingester's sources on a schedule.
+type Refresher struct {
+	in       *Ingester
+	interval time.Duration
+}
+
+// NewRefresher creates a refresher for in, which must track its sources.
+func NewRefresher(cfg Config, in *Ingester) *Refresher {
+	r := &Refresher{in: in, interval: time.Hour}
+	if d, err := time.ParseDuration(cfg.Interval); err == nil && d > 0 {
+		r.interval = d
+	}
+	return r
+}
+
+// Run refreshes every interval until ctx is cancelled.
+func (r *Refresher) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		done, err := r.in.Refresh(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				core.Logger().Error().Err(err).Msg("Knowledge refresh failed")
+			}
+			continue
+		}
+		for source, reason := range done.Failed {
+			core.Logger().Warn().Str("source", source).Str("error", reason).Msg("Failed to refresh knowledge source")
+		}
+		if len(done.Updated) > 0 || len(done.Tombstoned) > 0 {
+			core.Logger().Info().Int("updated", len(done.Updated)).Int("tombstoned", len(done.Tombstoned)).Int("unchanged", done.Unchanged).Msg("Knowledge sources refreshed")
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package ingest
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+func openSources(t *testing.T) *Sources {
+	t.Helper()
+	sources, err := OpenSources(filepath.Join(t.TempDir(), "agentflow.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sources
+}
+
+func TestRefresh(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	kept := writeFile(t, dir, "kept.txt", "kept")
+	changed := writeFile(t, dir, "changed.txt", "before")
+	deleted := writeFile(t, dir, "deleted.txt", "deleted")
+	mem := &fakeMemory{}
+	in := &Ingester{Memory: mem, Sources: openSources(t)}
+	for _, path := range []string{kept, changed, deleted} {
+		if _, err := in.File(ctx, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writeFile(t, dir, "changed.txt", "after, and longer")
+	if err := os.Remove(deleted); err != nil {
+		t.Fatal(err)
+	}
+	r, err := in.Refresh(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Refreshed{Updated: []string{changed}, Tombstoned: []string{deleted}, Unchanged: 1}
+	if !reflect.DeepEqual(r, want) {
+		t.Errorf("Refresh = %+v, want %+v", r, want)
+	}
+	src, _, _ := in.Sources.Get(ctx, deleted)
+	if !src.Deleted() || !reflect.DeepEqual(mem.deleted, []string{src.DocumentID}) {
+		t.Errorf("deleted source = %+v, memory deleted %v", src, mem.deleted)
+	}
+
+	// Tombstones aren't checked again
+	if r, err = in.Refresh(ctx); err != nil || r.Unchanged != 2 || len(r.Tombstoned) != 0 {
+		t.Errorf("second Refresh = %+v, %v", r, err)
+	}
+}
+
+func TestRefreshNeedsSources(t *testing.T) {
+	if _, err := (&Ingester{}).Refresh(context.Background()); err == nil {
+		t.Error("Refresh without tracked sources succeeded")
+	}
+}
+
+func TestLoadURL(t *testing.T) {
+	body, status := "<p>hello</p>", http.StatusOK
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` && status == http.StatusOK {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	in := &Ingester{}
+	doc, v, err := in.load(ctx, srv.URL+"/page", Source{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Type != core.DocumentTypeWeb || doc.Title != "page" || doc.Content != body || v.ETag != `"v1"` {
+		t.Errorf("load = %+v, %+v", doc, v)
+	}
+	if _, again, err := in.load(ctx, srv.URL+"/page", v); !errors.Is(err, ErrUnchanged) || again.Version != v.Version {
+		t.Errorf("conditional load = %+v, %v, want ErrUnchanged", again, err)
+	}
+	status = http.StatusNotFound
+	if _, _, err := in.load(ctx, srv.URL+"/page", v); !errors.Is(err, errGone) {
+		t.Errorf("load of a removed page = %v, want errGone", err)
+	}
+}
+
+type fakeKnowledge []core.KnowledgeResult
+
+func (k fakeKnowledge) Search(ctx context.Context, query string, limit int) ([]core.KnowledgeResult, error) {
+	return append([]core.KnowledgeResult(nil), k...), nil
+}
+
+func TestLive(t *testing.T) {
+	ctx := context.Background()
+	sources := openSources(t)
+	if err := sources.Put(ctx, Source{Source: "/a", DocumentID: "doc-a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sources.Put(ctx, Source{Source: "/b", DocumentID: "doc-b", DeletedAt: time.Now()}); err != nil {
+		t.Fatal(err)
+	}
+	live := sources.Live(fakeKnowledge{{DocumentID: "doc-a"}, {DocumentID: "doc-b"}, {DocumentID: "doc-c"}})
+	results, err := live.Search(ctx, "q", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, r := range results {
+		ids = append(ids, r.DocumentID)
+	}
+	if !reflect.DeepEqual(ids, []string{"doc-a", "doc-c"}) {
+		t.Errorf("Search = %v, want the tombstoned document dropped", ids)
+	}
+}
+
+func TestNewRefresher(t *testing.T) {
+	if r := NewRefresher(Config{}, &Ingester{}); r.interval != time.Hour {
+		t.Errorf("default interval = %v", r.interval)
+	}
+	if r := NewRefresher(Config{Interval: "5m"}, &Ingester{}); r.interval != 5*time.Minute {
+		t.Errorf("interval = %v", r.interval)
+	}
+}
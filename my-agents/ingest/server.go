@@ -0,0 +1,139 @@
+package ingest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// maxUploadBytes bounds the files of one upload request.
+const maxUploadBytes = 64 << 20
+
+// Ingested is how ingesting one source went.
+type Ingested struct {
+	Source     string `json:"source"`
+	DocumentID string `json:"document_id,omitempty"`
+	Characters int    `json:"characters,omitempty"`
+	Chunks     int    `json:"chunks,omitempty"` // indexed in the vector store
+	Error      string `json:"error,omitempty"`
+}
+
+// Handler serves the ingestion API, for mounting on the admin API:
+//
+//	POST /admin/documents   {"sources": ["https://..."], "tags": {"team": "docs"}}
+//	POST /admin/documents   multipart/form-data: "file" parts, and "tag" fields of key=value
+//
+// URL sources are tracked, so a refresh picks up their changes; uploaded
+// files are not. Local paths are refused: the API doesn't read the
+// server's files. It answers 200 with one result per source, even when
+// some failed.
+func Handler(in *Ingester) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /admin/documents", func(w http.ResponseWriter, r *http.Request) {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		var results []Ingested
+		var err error
+		if mediaType == "multipart/form-data" {
+			results, err = uploaded(in, w, r)
+		} else {
+			results, err = fetched(in, r)
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"documents": results})
+	})
+	return mux
+}
+
+// fetched ingests the URLs of a JSON request.
+func fetched(in *Ingester, r *http.Request) ([]Ingested, error) {
+	var body struct {
+		Sources []string          `json:"sources"`
+		Tags    map[string]string `json:"tags"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+	if len(body.Sources) == 0 {
+		return nil, errors.New(`"sources" lists no URLs`)
+	}
+	for _, source := range body.Sources {
+		if !isURL(source) {
+			return nil, fmt.Errorf("%s is not an http(s) URL", source)
+		}
+	}
+	tagged := *in
+	tagged.Tags = body.Tags
+	results := make([]Ingested, len(body.Sources))
+	for i, source := range body.Sources {
+		doc, err := tagged.File(r.Context(), source)
+		results[i] = result(source, doc, err)
+	}
+	return results, nil
+}
+
+// uploaded ingests the files of a multipart request.
+func uploaded(in *Ingester, w http.ResponseWriter, r *http.Request) ([]Ingested, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	if err := r.ParseMultipartForm(maxUploadBytes); err != nil {
+		return nil, err
+	}
+	defer r.MultipartForm.RemoveAll()
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
+		return nil, errors.New(`no "file" parts uploaded`)
+	}
+	tagged := *in
+	tagged.Tags = make(map[string]string)
+	for _, tag := range r.MultipartForm.Value["tag"] {
+		k, v, ok := strings.Cut(tag, "=")
+		if !ok || k == "" {
+			return nil, fmt.Errorf("tag %q is not key=value", tag)
+		}
+		tagged.Tags[k] = v
+	}
+	results := make([]Ingested, len(files))
+	for i, fh := range files {
+		f, err := fh.Open()
+		if err != nil {
+			results[i] = result(fh.Filename, core.Document{}, err)
+			continue
+		}
+		data, err := io.ReadAll(f)
+		f.Close()
+		if err != nil {
+			results[i] = result(fh.Filename, core.Document{}, err)
+			continue
+		}
+		doc, err := tagged.Upload(r.Context(), fh.Filename, data)
+		results[i] = result(fh.Filename, doc, err)
+	}
+	return results, nil
+}
+
+func result(source string, doc core.Document, err error) Ingested {
+	if err != nil {
+		return Ingested{Source: source, Error: err.Error()}
+	}
+	res := Ingested{Source: doc.Source, DocumentID: doc.ID, Characters: len(doc.Content)}
+	res.Chunks, _ = doc.Metadata["chunks"].(int)
+	return res
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
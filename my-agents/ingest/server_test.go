@@ -0,0 +1,90 @@
+package ingest
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serve(t *testing.T, in *Ingester, contentType string, body *bytes.Buffer) (int, []Ingested) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/admin/documents", body)
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	Handler(in).ServeHTTP(rec, req)
+	var out struct {
+		Documents []Ingested `json:"documents"`
+	}
+	if rec.Code == http.StatusOK {
+		if err := json.NewDecoder(rec.Body).Decode(&out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return rec.Code, out.Documents
+}
+
+func TestHandlerSources(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/faq.md" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("# FAQ"))
+	}))
+	defer srv.Close()
+
+	mem := &fakeMemory{}
+	in := &Ingester{Memory: mem}
+	body := bytes.NewBufferString(`{"sources": ["` + srv.URL + `/faq.md", "` + srv.URL + `/missing"], "tags": {"team": "docs"}}`)
+	code, docs := serve(t, in, "application/json", body)
+	if code != http.StatusOK || len(docs) != 2 {
+		t.Fatalf("POST = %d, %+v", code, docs)
+	}
+	if docs[0].DocumentID == "" || docs[0].Characters != len("# FAQ") || docs[0].Error != "" {
+		t.Errorf("first result = %+v", docs[0])
+	}
+	if docs[1].Error == "" {
+		t.Errorf("second result = %+v, want an error", docs[1])
+	}
+	if in.Tags != nil {
+		t.Error("request tags leaked into the ingester")
+	}
+
+	for _, body := range []string{`{"sources": []}`, `{"sources": ["/etc/passwd"]}`, `not json`} {
+		if code, _ := serve(t, in, "application/json", bytes.NewBufferString(body)); code != http.StatusBadRequest {
+			t.Errorf("POST %s = %d, want 400", body, code)
+		}
+	}
+}
+
+func TestHandlerUpload(t *testing.T) {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.WriteField("tag", "team=docs")
+	fw, _ := mw.CreateFormFile("file", "notes.txt")
+	fw.Write([]byte("uploaded notes"))
+	mw.Close()
+
+	mem := &fakeMemory{}
+	code, docs := serve(t, &Ingester{Memory: mem}, mw.FormDataContentType(), &body)
+	if code != http.StatusOK || len(docs) != 1 || docs[0].Source != "upload:notes.txt" {
+		t.Fatalf("POST = %d, %+v", code, docs)
+	}
+	if doc := mem.docs[docs[0].DocumentID]; !strings.Contains(doc.Content, "uploaded notes") {
+		t.Errorf("ingested %+v", doc)
+	}
+
+	body.Reset()
+	mw = multipart.NewWriter(&body)
+	mw.WriteField("tag", "no-equals")
+	fw, _ = mw.CreateFormFile("file", "notes.txt")
+	fw.Write([]byte("x"))
+	mw.Close()
+	if code, _ := serve(t, &Ingester{}, mw.FormDataContentType(), &body); code != http.StatusBadRequest {
+		t.Errorf("POST with a malformed tag = %d, want 400", code)
+	}
+}
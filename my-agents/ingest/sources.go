@@ -0,0 +1,195 @@
+package ingest
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/prefetch"
+	"my-agents/storage"
+)
+
+// Source is a file or URL in the knowledge base and the version of it that
+// was ingested.
+type Source struct {
+	Source     string `json:"source"`
+	DocumentID string `json:"document_id"`
+	// Version identifies the ingested content: a hash of the file or
+	// response body.
+	Version      string    `json:"version"`
+	ETag         string    `json:"etag,omitempty"`          // URLs only
+	LastModified string    `json:"last_modified,omitempty"` // URLs only
+	ModTime      time.Time `json:"mod_time,omitzero"`       // files only
+	Size         int64     `json:"size,omitempty"`          // files only
+	IngestedAt   time.Time `json:"ingested_at"`
+	CheckedAt    time.Time `json:"checked_at"`
+	// DeletedAt is set once the source is gone; its document is then a
+	// tombstone, left out of retrieval until the source is ingested again.
+	DeletedAt time.Time `json:"deleted_at,omitzero"`
+	// Tags were added to its chunks' metadata, and are again on refresh.
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// Deleted reports whether the source is tombstoned.
+func (s Source) Deleted() bool {
+	return !s.DeletedAt.IsZero()
+}
+
+// Sources tracks what was ingested from where, in a table of the embedded
+// database.
+type Sources struct {
+	db *sql.DB
+}
+
+// OpenSources opens the source table in the database at path (default
+// .agentflow/agentflow.db).
+func OpenSources(path string) (*Sources, error) {
+	db, err := storage.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewSources(db)
+}
+
+// NewSources creates the source table in db if needed.
+func NewSources(db *sql.DB) (*Sources, error) {
+	err := storage.Migrate(db,
+		`CREATE TABLE IF NOT EXISTS knowledge_sources (
+			source      TEXT PRIMARY KEY,
+			document_id TEXT NOT NULL,
+			deleted_at  INTEGER NOT NULL,
+			data        TEXT NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS knowledge_sources_document ON knowledge_sources (document_id)`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Sources{db: db}, nil
+}
+
+// Get returns the tracked source, or false when it was never ingested.
+func (s *Sources) Get(ctx context.Context, source string) (Source, bool, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, `SELECT data FROM knowledge_sources WHERE source = ?`, source).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Source{}, false, nil
+	}
+	if err != nil {
+		return Source{}, false, fmt.Errorf("failed to load source %s: %w", source, err)
+	}
+	var src Source
+	if err := json.Unmarshal([]byte(data), &src); err != nil {
+		return Source{}, false, fmt.Errorf("source %s: %w", source, err)
+	}
+	return src, true, nil
+}
+
+// List returns every tracked source, tombstones included, by name.
+func (s *Sources) List(ctx context.Context) ([]Source, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT data FROM knowledge_sources ORDER BY source`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sources: %w", err)
+	}
+	defer rows.Close()
+	var out []Source
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var src Source
+		if err := json.Unmarshal([]byte(data), &src); err != nil {
+			return nil, err
+		}
+		out = append(out, src)
+	}
+	return out, rows.Err()
+}
+
+// Put records src.
+func (s *Sources) Put(ctx context.Context, src Source) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	var deleted int64
+	if src.Deleted() {
+		deleted = src.DeletedAt.UnixNano()
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO knowledge_sources (source, document_id, deleted_at, data) VALUES (?, ?, ?, ?)
+		 ON CONFLICT (source) DO UPDATE SET document_id = excluded.document_id,
+		 deleted_at = excluded.deleted_at, data = excluded.data`,
+		src.Source, src.DocumentID, deleted, string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save source %s: %w", src.Source, err)
+	}
+	return nil
+}
+
+// Tombstoned returns which of the document IDs belong to deleted sources.
+func (s *Sources) Tombstoned(ctx context.Context, ids []string) (map[string]bool, error) {
+	dead := make(map[string]bool)
+	if len(ids) == 0 {
+		return dead, nil
+	}
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+	}
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT document_id FROM knowledge_sources WHERE deleted_at > 0 AND document_id IN (?`+strings.Repeat(", ?", len(ids)-1)+`)`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up tombstones: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		dead[id] = true
+	}
+	return dead, rows.Err()
+}
+
+// Live wraps knowledge so results from tombstoned documents are dropped:
+// memory providers can't delete a document from their index, so a deleted
+// source's last version would otherwise keep being retrieved.
+func (s *Sources) Live(knowledge prefetch.KnowledgeSource) prefetch.KnowledgeSource {
+	return &liveKnowledge{sources: s, knowledge: knowledge}
+}
+
+type liveKnowledge struct {
+	sources   *Sources
+	knowledge prefetch.KnowledgeSource
+}
+
+func (l *liveKnowledge) Search(ctx context.Context, query string, limit int) ([]core.KnowledgeResult, error) {
+	results, err := l.knowledge.Search(ctx, query, limit)
+	if err != nil || len(results) == 0 {
+		return results, err
+	}
+	ids := make([]string, len(results))
+	for i, r := range results {
+		ids[i] = r.DocumentID
+	}
+	dead, err := l.sources.Tombstoned(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	live := results[:0]
+	for _, r := range results {
+		if !dead[r.DocumentID] {
+			live = append(live, r)
+		}
+	}
+	return live, nil
+}
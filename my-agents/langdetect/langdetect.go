@@ -0,0 +1,175 @@
+// Package langdetect identifies the language of user input and routes
+// requests to language-specific agents.
+package langdetect
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Result is a detected ISO 639-1 language code with a 0-1 confidence.
+// Language is empty when the text gives no signal.
+type Result struct {
+	Language   string  `json:"language"`
+	Confidence float64 `json:"confidence"`
+}
+
+// stopwords are frequent function words per Latin-script language.
+var stopwords = map[string][]string{
+	"en": {"the", "and", "is", "of", "to", "in", "that", "it", "for", "you", "with", "on", "are", "this", "what", "how", "be", "can", "me", "my", "in", "do", "please", "explain", "about"},
+	"de": {"der", "die", "und", "das", "ist", "nicht", "ich", "zu", "den", "mit", "sie", "ein", "eine", "es", "auf", "für", "wie", "was", "bitte", "mir", "erkläre", "sind", "auch", "dem", "von"},
+	"fr": {"le", "la", "les", "de", "et", "est", "un", "une", "des", "pour", "que", "qui", "dans", "pas", "vous", "je", "en", "du", "sur", "avec", "comment", "moi", "expliquez", "ce", "au"},
+	"es": {"el", "la", "los", "las", "de", "y", "es", "que", "en", "un", "una", "por", "para", "con", "no", "se", "del", "como", "qué", "cómo", "me", "explica", "al", "lo", "su"},
+	"it": {"il", "la", "di", "che", "e", "è", "per", "un", "una", "non", "sono", "con", "del", "della", "come", "mi", "cosa", "gli", "spiega", "nel", "alla", "anche", "questo", "ho", "le"},
+	"pt": {"o", "a", "os", "de", "e", "que", "do", "da", "em", "um", "uma", "para", "com", "não", "como", "por", "mais", "me", "explique", "você", "é", "dos", "das", "se", "ao"},
+	"nl": {"de", "het", "een", "en", "van", "is", "dat", "niet", "ik", "je", "op", "te", "voor", "met", "zijn", "wat", "hoe", "mij", "uitleggen", "ook", "er", "aan", "dit", "maar", "om"},
+}
+
+var stopwordSets = func() map[string]map[string]bool {
+	sets := make(map[string]map[string]bool, len(stopwords))
+	for lang, words := range stopwords {
+		set := make(map[string]bool, len(words))
+		for _, w := range words {
+			set[w] = true
+		}
+		sets[lang] = set
+	}
+	return sets
+}()
+
+// Detect identifies the language of text. Non-Latin scripts are decided by
+// script; Latin text is scored by stopword hits, with confidence reflecting
+// both the winner's margin and how much of the text was recognized.
+func Detect(text string) Result {
+	if lang, share := dominantScript(text); lang != "" {
+		return Result{Language: lang, Confidence: round(share)}
+	}
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	})
+	if len(words) == 0 {
+		return Result{}
+	}
+	scores := make(map[string]float64, len(stopwordSets))
+	hits := 0
+	for _, w := range words {
+		matched := false
+		for lang, set := range stopwordSets {
+			if set[w] {
+				scores[lang]++
+				matched = true
+			}
+		}
+		if matched {
+			hits++
+		}
+	}
+	for lang, bonus := range diacriticBonus(text) {
+		scores[lang] += bonus
+	}
+	if len(scores) == 0 {
+		return Result{}
+	}
+
+	langs := make([]string, 0, len(scores))
+	for lang := range scores {
+		langs = append(langs, lang)
+	}
+	sort.Slice(langs, func(i, j int) bool {
+		if scores[langs[i]] != scores[langs[j]] {
+			return scores[langs[i]] > scores[langs[j]]
+		}
+		return langs[i] < langs[j]
+	})
+	best := scores[langs[0]]
+	runnerUp := 0.0
+	if len(langs) > 1 {
+		runnerUp = scores[langs[1]]
+	}
+	margin := (best - runnerUp) / best
+	// Short inputs with few recognized words are inherently uncertain
+	coverage := float64(hits) / float64(len(words))
+	support := min(1, best/4)
+	confidence := margin*0.6 + coverage*0.2 + support*0.2
+	return Result{Language: langs[0], Confidence: round(confidence)}
+}
+
+// diacriticBonus adds weak evidence from language-specific letters.
+func diacriticBonus(text string) map[string]float64 {
+	bonus := make(map[string]float64)
+	for _, r := range strings.ToLower(text) {
+		switch r {
+		case 'ß', 'ä', 'ö', 'ü':
+			bonus["de"] += 0.5
+		case 'ñ', '¿', '¡':
+			bonus["es"] += 0.5
+		case 'ç', 'è', 'ê', 'à', 'ù', 'œ':
+			bonus["fr"] += 0.5
+		case 'ã', 'õ':
+			bonus["pt"] += 0.5
+		}
+	}
+	return bonus
+}
+
+// dominantScript returns a language for text written mostly in a script
+// that identifies it, plus that script's share of the letters.
+func dominantScript(text string) (string, float64) {
+	counts := make(map[string]int)
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			counts["ja"]++
+		case unicode.Is(unicode.Hangul, r):
+			counts["ko"]++
+		case unicode.Is(unicode.Han, r):
+			counts["han"]++
+		case unicode.Is(unicode.Cyrillic, r):
+			counts["ru"]++
+		case unicode.Is(unicode.Arabic, r):
+			counts["ar"]++
+		case unicode.Is(unicode.Greek, r):
+			counts["el"]++
+		case unicode.Is(unicode.Devanagari, r):
+			counts["hi"]++
+		case unicode.Is(unicode.Thai, r):
+			counts["th"]++
+		case unicode.Is(unicode.Hebrew, r):
+			counts["he"]++
+		}
+	}
+	if letters == 0 {
+		return "", 0
+	}
+	// Japanese mixes kana with kanji; kanji without kana is Chinese
+	if counts["ja"] > 0 {
+		counts["ja"] += counts["han"]
+		counts["han"] = 0
+	} else {
+		counts["zh"] = counts["han"]
+	}
+	delete(counts, "han")
+
+	best, bestCount := "", 0
+	for lang, n := range counts {
+		if n > bestCount || (n == bestCount && lang < best) {
+			best, bestCount = lang, n
+		}
+	}
+	share := float64(bestCount) / float64(letters)
+	if share < 0.5 {
+		return "", 0
+	}
+	return best, share
+}
+
+func round(f float64) float64 {
+	return float64(int(f*100+0.5)) / 100
+}
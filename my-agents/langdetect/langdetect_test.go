@@ -0,0 +1,36 @@
+package langdetect
+
+import "testing"
+
+func TestDetect(t *testing.T) {
+	for text, want := range map[string]string{
+		"Please explain what the difference is between these plans": "en",
+		"Bitte erkläre mir, wie das funktioniert und was es ist":    "de",
+		"Comment est-ce que vous expliquez le résultat de la loi ?": "fr",
+		"¿Cómo funciona el sistema y qué es lo que hace?":           "es",
+		"Как это работает?":                                         "ru",
+		"これはどのように動作しますか":                                            "ja",
+		"这是如何工作的":                                                   "zh",
+		"이것은 어떻게 작동합니까":                                             "ko",
+	} {
+		if got := Detect(text); got.Language != want || got.Confidence <= 0 || got.Confidence > 1 {
+			t.Errorf("Detect(%q) = %+v, want %s", text, got, want)
+		}
+	}
+}
+
+func TestDetectNoSignal(t *testing.T) {
+	for _, text := range []string{"", "1234 !!", "xyzzy plugh"} {
+		if got := Detect(text); got.Language != "" {
+			t.Errorf("Detect(%q) = %+v, want no language", text, got)
+		}
+	}
+}
+
+func TestDetectConfidence(t *testing.T) {
+	short := Detect("de")
+	long := Detect("Bitte erkläre mir, wie das funktioniert und was es ist")
+	if short.Confidence >= long.Confidence {
+		t.Errorf("ambiguous %+v is as confident as %+v", short, long)
+	}
+}
@@ -0,0 +1,95 @@
+package langdetect
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/locale"
+)
+
+// Keys written by the router, in event data (and so in agent state) and in
+// metadata so they are forwarded to later hops.
+const (
+	LanguageKey   = "language"
+	ConfidenceKey = "language_confidence"
+)
+
+// DefaultMinConfidence is used when Config.MinConfidence is zero.
+const DefaultMinConfidence = 0.5
+
+// Config is the [language_routing] section of agentflow.toml:
+//
+//	[language_routing]
+//	min_confidence = 0.6
+//	[language_routing.routes]
+//	de = "processor-de"
+type Config struct {
+	Enabled       bool              `toml:"enabled"`
+	MinConfidence float64           `toml:"min_confidence"`
+	Routes        map[string]string `toml:"routes"` // language code → agent
+	// SetLocale fills the "locale" metadata from the detected language when
+	// the caller didn't send one.
+	SetLocale bool `toml:"set_locale"`
+}
+
+// Router detects the input language on entry events and applies routes.
+type Router struct {
+	cfg     Config
+	locales *locale.Registry
+}
+
+// NewRouter creates a router. locales may be nil when SetLocale is off.
+func NewRouter(cfg Config, locales *locale.Registry) *Router {
+	if cfg.MinConfidence == 0 {
+		cfg.MinConfidence = DefaultMinConfidence
+	}
+	return &Router{cfg: cfg, locales: locales}
+}
+
+// Callback is a BeforeEventHandling hook. It only acts on entry events:
+// those carrying a string "input" and not yet tagged with a language.
+func (r *Router) Callback() core.CallbackFunc {
+	return func(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+		event := args.Event
+		if event == nil {
+			return args.State, nil
+		}
+		if _, tagged := event.GetMetadataValue(LanguageKey); tagged {
+			return args.State, nil
+		}
+		input, ok := event.GetData()["input"].(string)
+		if !ok {
+			return args.State, nil
+		}
+
+		res := Detect(input)
+		if res.Language == "" {
+			return args.State, nil
+		}
+		event.SetData(LanguageKey, res.Language)
+		event.SetData(ConfidenceKey, res.Confidence)
+		event.SetMetadata(LanguageKey, res.Language)
+		event.SetMetadata(ConfidenceKey, strconv.FormatFloat(res.Confidence, 'f', 2, 64))
+		if res.Confidence < r.cfg.MinConfidence {
+			return args.State, nil
+		}
+
+		if target, ok := r.cfg.Routes[res.Language]; ok && target != "" {
+			from, _ := event.GetMetadataValue(core.RouteMetadataKey)
+			event.SetMetadata(core.RouteMetadataKey, target)
+			core.Logger().Debug().Str("event_id", event.GetID()).Str("language", res.Language).
+				Str("from", from).Str("to", target).Msg("Routed by language")
+		}
+		if r.cfg.SetLocale && r.locales != nil {
+			tag, _ := event.GetMetadataValue(locale.MetadataKey)
+			// Lookup falls back to the default locale; only use a real match
+			if l := r.locales.Lookup(res.Language); tag == "" && l != nil && strings.HasPrefix(strings.ToLower(l.Tag), res.Language+"-") {
+				event.SetMetadata(locale.MetadataKey, l.Tag)
+			}
+		}
+		return args.State, nil
+	}
+}
@@ -0,0 +1,73 @@
+package langdetect
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/locale"
+)
+
+func run(t *testing.T, r *Router, event core.Event) {
+	t.Helper()
+	if _, err := r.Callback()(context.Background(), core.CallbackArgs{Event: event, State: core.NewState()}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRouterRoutes(t *testing.T) {
+	locales, err := locale.NewRegistry("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRouter(Config{Routes: map[string]string{"de": "processor-de"}, SetLocale: true}, locales)
+	event := core.NewEvent("processor", core.EventData{"input": "Bitte erkläre mir, wie das funktioniert und was es ist"}, nil)
+	run(t, r, event)
+
+	if got, _ := event.GetMetadataValue(core.RouteMetadataKey); got != "processor-de" {
+		t.Errorf("route = %q", got)
+	}
+	if got := event.GetData()[LanguageKey]; got != "de" {
+		t.Errorf("language = %v", got)
+	}
+	if got, _ := event.GetMetadataValue(locale.MetadataKey); got != "de-DE" {
+		t.Errorf("locale = %q", got)
+	}
+}
+
+func TestRouterKeepsCallerChoices(t *testing.T) {
+	locales, err := locale.NewRegistry("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewRouter(Config{Routes: map[string]string{"en": "processor-en"}, SetLocale: true}, locales)
+
+	// The caller's locale wins over the detected language
+	event := core.NewEvent("processor", core.EventData{"input": "Please explain what the difference is between these plans"},
+		map[string]string{locale.MetadataKey: "en-GB"})
+	run(t, r, event)
+	if got, _ := event.GetMetadataValue(locale.MetadataKey); got != "en-GB" {
+		t.Errorf("locale = %q, want the caller's", got)
+	}
+
+	// Events already tagged aren't routed again
+	event = core.NewEvent("critic", core.EventData{"input": "Please explain what the difference is"},
+		map[string]string{LanguageKey: "en"})
+	run(t, r, event)
+	if _, routed := event.GetMetadataValue(core.RouteMetadataKey); routed {
+		t.Error("a tagged event was routed")
+	}
+}
+
+func TestRouterMinConfidence(t *testing.T) {
+	r := NewRouter(Config{MinConfidence: 0.99, Routes: map[string]string{"de": "processor-de"}}, nil)
+	event := core.NewEvent("processor", core.EventData{"input": "die"}, nil)
+	run(t, r, event)
+	if _, routed := event.GetMetadataValue(core.RouteMetadataKey); routed {
+		t.Error("an uncertain detection was routed")
+	}
+	if _, tagged := event.GetMetadataValue(LanguageKey); !tagged {
+		t.Error("an uncertain detection wasn't recorded")
+	}
+}
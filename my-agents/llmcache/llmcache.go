@@ -0,0 +1,369 @@
+// Package llmcache replays the replies of identical LLM calls, so a prompt
+// an agent has already sent to the same model with the same parameters
+// costs no tokens the second time. Replies are kept in an in-process LRU
+// or in Redis, which processes on several hosts can share, for a TTL.
+package llmcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/media"
+	"my-agents/tenant"
+)
+
+// Backends.
+const (
+	BackendMemory = "memory"
+	BackendRedis  = "redis"
+)
+
+// Defaults.
+const (
+	DefaultTTL         = time.Hour
+	DefaultMaxEntries  = 1000
+	DefaultRedisPrefix = "agentflow:llm:"
+)
+
+// Config is the [llm_cache] section of agentflow.toml:
+//
+//	[llm_cache]
+//	enabled = true
+//	backend = "memory"   # memory or redis
+//	ttl = "1h"
+//	max_entries = 1000
+//
+// The Redis backend connects to url, redis://[user:pass@]host:port[/db]
+// (rediss:// for TLS). An agent opts out with cache = false in its
+// [agents.<name>] table, e.g. one whose replies must vary between runs.
+type Config struct {
+	Enabled bool   `toml:"enabled"`
+	Backend string `toml:"backend"`
+	URL     string `toml:"url"`
+	// Prefix starts the Redis keys (default DefaultRedisPrefix).
+	Prefix string `toml:"prefix"`
+	// TTL is how long a reply is replayed (default DefaultTTL).
+	TTL string `toml:"ttl"`
+	// MaxEntries bounds the memory backend, least recently used replies
+	// going first (default DefaultMaxEntries).
+	MaxEntries int `toml:"max_entries"`
+}
+
+// Store keeps encoded replies by key.
+type Store interface {
+	// Get returns the value under key, or false when there is none or it
+	// expired.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key for ttl.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Flush drops every value and returns how many there were.
+	Flush(ctx context.Context) (int, error)
+	// Compact drops expired values still held and returns how many and
+	// their size; stores that expire values themselves return zeros.
+	Compact(ctx context.Context) (int, int64, error)
+	Close() error
+}
+
+// Open creates the store cfg configures.
+func Open(cfg Config) (Store, error) {
+	switch cfg.Backend {
+	case "", BackendMemory:
+		n := cfg.MaxEntries
+		if n <= 0 {
+			n = DefaultMaxEntries
+		}
+		return NewMemoryStore(n), nil
+	case BackendRedis:
+		u, err := url.Parse(cfg.URL)
+		if err != nil {
+			return nil, fmt.Errorf("llm cache: bad url: %w", err)
+		}
+		prefix := cfg.Prefix
+		if prefix == "" {
+			prefix = DefaultRedisPrefix
+		}
+		return NewRedisStore(u, prefix)
+	default:
+		return nil, fmt.Errorf("unknown llm cache backend %q", cfg.Backend)
+	}
+}
+
+// Stats are an agent's cache lookups since the process started.
+type Stats struct {
+	Hits   int `json:"hits"`
+	Misses int `json:"misses"`
+	// TokensSaved are the tokens the replayed replies took originally.
+	TokensSaved int     `json:"tokens_saved"`
+	HitRate     float64 `json:"hit_rate"`
+}
+
+// Cache replays the replies of the providers its middleware wraps.
+type Cache struct {
+	store  Store
+	ttl    time.Duration
+	models func(agent, provider string) string // the model a provider of agent's calls to
+	cached func(agent string) bool
+
+	mu     sync.Mutex
+	agents map[string]*Stats
+}
+
+// New creates a cache over the store cfg configures. models names the
+// model a provider, by its [providers.<name>] table, calls for an agent,
+// "" being the agent's own provider; the keys include it. cached reports
+// whether an agent's calls are cached at all.
+func New(cfg Config, models func(agent, provider string) string, cached func(agent string) bool) (*Cache, error) {
+	ttl := DefaultTTL
+	if cfg.TTL != "" {
+		d, err := time.ParseDuration(cfg.TTL)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("llm cache: bad ttl %q", cfg.TTL)
+		}
+		ttl = d
+	}
+	store, err := Open(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{store: store, ttl: ttl, models: models, cached: cached, agents: make(map[string]*Stats)}, nil
+}
+
+// Named is a provider that knows its [providers.<name>] table, so its
+// replies are keyed by its model rather than the agent's.
+type Named interface {
+	Name() string
+}
+
+// Routed is a provider choosing among models per prompt, as model routing
+// does, so lookups are keyed by the model a call goes to.
+type Routed interface {
+	// Route returns the provider a call of prompt goes to first.
+	Route(prompt core.Prompt) string
+}
+
+// Unwrapper is a provider wrapping another, as the LLM middleware do, which
+// the cache looks through for a Named or Routed one.
+type Unwrapper interface {
+	Unwrap() core.ModelProvider
+}
+
+type answeredKey struct{}
+
+// Answered notes that the provider, by its [providers.<name>] table,
+// answered the call ctx is for, so the cache keeps the reply under its
+// model when a fallback or a failed routed model made it another than
+// looked up.
+func Answered(ctx context.Context, provider string) {
+	if slot, ok := ctx.Value(answeredKey{}).(*string); ok {
+		*slot = provider
+	}
+}
+
+// Middleware caches each agent's provider, leaving out the agents that
+// opted out. It has the shape of di.LLMMiddleware.
+func (c *Cache) Middleware() func(agent string, llm core.ModelProvider) core.ModelProvider {
+	return func(agent string, llm core.ModelProvider) core.ModelProvider {
+		if !c.cached(agent) {
+			return llm
+		}
+		return &cachedProvider{ModelProvider: llm, c: c, agent: agent}
+	}
+}
+
+// Flush drops every cached reply and returns how many there were.
+func (c *Cache) Flush() (int, error) {
+	return c.store.Flush(context.Background())
+}
+
+// Compact drops expired replies the store still holds and returns how
+// many and their size.
+func (c *Cache) Compact(ctx context.Context) (int, int64, error) {
+	return c.store.Compact(ctx)
+}
+
+func (c *Cache) Close() error {
+	return c.store.Close()
+}
+
+// Snapshot returns each agent's stats.
+func (c *Cache) Snapshot() map[string]Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	stats := make(map[string]Stats, len(c.agents))
+	for agent, s := range c.agents {
+		stats[agent] = *s
+	}
+	return stats
+}
+
+// Handler serves the snapshot, for mounting on the admin API:
+//
+//	GET /admin/llm-cache   each agent's hits, misses and tokens saved
+func (c *Cache) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"agents": c.Snapshot()})
+	})
+}
+
+func (c *Cache) observe(agent string, hit bool, tokens int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	s, ok := c.agents[agent]
+	if !ok {
+		s = &Stats{}
+		c.agents[agent] = s
+	}
+	if hit {
+		s.Hits++
+		s.TokensSaved += tokens
+	} else {
+		s.Misses++
+	}
+	s.HitRate = float64(s.Hits) / float64(s.Hits+s.Misses)
+}
+
+// key identifies a call: the tenant, the model provider calls, the prompt,
+// its parameters and the images it carries.
+func (c *Cache) key(ctx context.Context, agent, provider string, prompt core.Prompt) string {
+	h := sha256.New()
+	field := func(s string) {
+		fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+	field(tenant.FromContext(ctx))
+	field(c.models(agent, provider))
+	field(prompt.System)
+	field(prompt.User)
+	if t := prompt.Parameters.Temperature; t != nil {
+		field(fmt.Sprint("temperature=", *t))
+	}
+	if t := prompt.Parameters.MaxTokens; t != nil {
+		field(fmt.Sprint("max_tokens=", *t))
+	}
+	for _, img := range media.Images(ctx) {
+		field(img.MIMEType)
+		field(img.URI)
+		field(string(img.Data))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// lookup returns the reply cached under key. A failing store is logged and
+// treated as a miss, so the call goes through.
+func (c *Cache) lookup(ctx context.Context, agent, key string) (core.Response, bool) {
+	data, ok, err := c.store.Get(ctx, key)
+	if err != nil {
+		core.Logger().Warn().Str("agent", agent).Err(err).Msg("LLM cache lookup failed")
+	}
+	var resp core.Response
+	if ok && err == nil && json.Unmarshal(data, &resp) == nil {
+		c.observe(agent, true, resp.Usage.TotalTokens)
+		return resp, true
+	}
+	c.observe(agent, false, 0)
+	return core.Response{}, false
+}
+
+func (c *Cache) save(ctx context.Context, agent, key string, resp core.Response) {
+	if strings.TrimSpace(resp.Content) == "" {
+		return
+	}
+	data, err := json.Marshal(resp)
+	if err == nil {
+		err = c.store.Set(ctx, key, data, c.ttl)
+	}
+	if err != nil {
+		core.Logger().Warn().Str("agent", agent).Err(err).Msg("Failed to cache LLM reply")
+	}
+}
+
+type cachedProvider struct {
+	core.ModelProvider
+	c     *Cache
+	agent string
+}
+
+// provider returns the provider a call of prompt goes to, or "" for the
+// agent's own.
+func (p *cachedProvider) provider(prompt core.Prompt) string {
+	for llm := p.ModelProvider; llm != nil; {
+		switch v := llm.(type) {
+		case Routed:
+			return v.Route(prompt)
+		case Named:
+			return v.Name()
+		case Unwrapper:
+			llm = v.Unwrap()
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// Call replays a cached reply with no usage, it having spent no tokens. A
+// reply is cached under the model that answered, which a fallback makes
+// differ from the one looked up.
+func (p *cachedProvider) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	provider := p.provider(prompt)
+	key := p.c.key(ctx, p.agent, provider, prompt)
+	if resp, ok := p.c.lookup(ctx, p.agent, key); ok {
+		resp.Usage = core.UsageStats{}
+		return resp, nil
+	}
+	answered := provider
+	resp, err := p.ModelProvider.Call(context.WithValue(ctx, answeredKey{}, &answered), prompt)
+	if err == nil {
+		if answered != provider {
+			key = p.c.key(ctx, p.agent, answered, prompt)
+		}
+		p.c.save(ctx, p.agent, key, resp)
+	}
+	return resp, err
+}
+
+// Stream replays a cached reply as a single token, and caches a streamed
+// reply that completes without error.
+func (p *cachedProvider) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	provider := p.provider(prompt)
+	key := p.c.key(ctx, p.agent, provider, prompt)
+	if resp, ok := p.c.lookup(ctx, p.agent, key); ok {
+		out := make(chan core.Token, 1)
+		out <- core.Token{Content: resp.Content}
+		close(out)
+		return out, nil
+	}
+	answered := provider
+	in, err := p.ModelProvider.Stream(context.WithValue(ctx, answeredKey{}, &answered), prompt)
+	if err != nil {
+		return nil, err
+	}
+	if answered != provider {
+		key = p.c.key(ctx, p.agent, answered, prompt)
+	}
+	out := make(chan core.Token)
+	go func() {
+		defer close(out)
+		var b strings.Builder
+		failed := false
+		for tok := range in {
+			b.WriteString(tok.Content)
+			failed = failed || tok.Error != nil
+			out <- tok
+		}
+		if !failed && ctx.Err() == nil {
+			p.c.save(ctx, p.agent, key, core.Response{Content: b.String()})
+		}
+	}()
+	return out, nil
+}
@@ -0,0 +1,275 @@
+package llmcache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/media"
+	"my-agents/tenant"
+)
+
+// llm answers each call with reply, or streams tokens; answeredBy, when
+// set, is the provider it says answered.
+type llm struct {
+	core.ModelProvider
+	reply      string
+	err        error
+	tokens     []core.Token
+	answeredBy string
+	calls      int
+}
+
+func (l *llm) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	l.calls++
+	if l.answeredBy != "" {
+		Answered(ctx, l.answeredBy)
+	}
+	return core.Response{Content: l.reply, Usage: core.UsageStats{TotalTokens: 42}}, l.err
+}
+
+func (l *llm) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	l.calls++
+	if l.err != nil {
+		return nil, l.err
+	}
+	out := make(chan core.Token, len(l.tokens))
+	for _, t := range l.tokens {
+		out <- t
+	}
+	close(out)
+	return out, nil
+}
+
+// named is a provider from a [providers.<name>] table.
+type named struct {
+	*llm
+	name string
+}
+
+func (n named) Name() string { return n.name }
+
+// routed sends prompts mentioning Rust to the "fast" provider.
+type routed struct{ *llm }
+
+func (r routed) Route(prompt core.Prompt) string {
+	if strings.Contains(prompt.User, "Rust") {
+		return "fast"
+	}
+	return "smart"
+}
+
+// wrapper is an LLM middleware.
+type wrapper struct{ core.ModelProvider }
+
+func (w wrapper) Unwrap() core.ModelProvider { return w.ModelProvider }
+
+var models = map[string]string{"": "gpt-4o", "openai": "gpt-4o", "azure": "gpt-4o-azure", "smart": "gpt-4o", "fast": "gpt-4o-mini"}
+
+func newCache(t *testing.T) *Cache {
+	t.Helper()
+	c, err := New(Config{}, func(agent, provider string) string { return models[provider] }, func(agent string) bool { return agent != "poet" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func call(t *testing.T, ctx context.Context, p core.ModelProvider, user string) core.Response {
+	t.Helper()
+	resp, err := p.Call(ctx, core.Prompt{User: user})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func TestNew(t *testing.T) {
+	for _, cfg := range []Config{{TTL: "soon"}, {TTL: "-1h"}, {Backend: "memcached"}, {Backend: BackendRedis, URL: "http://localhost"}} {
+		if _, err := New(cfg, nil, nil); err == nil {
+			t.Errorf("config %+v accepted", cfg)
+		}
+	}
+	if _, err := New(Config{Backend: "memcached"}, nil, nil); err == nil || err.Error() != `unknown llm cache backend "memcached"` {
+		t.Errorf("unknown backend: %v", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	c := newCache(t)
+	l := &llm{reply: "Go is fast."}
+	if p := c.Middleware()("poet", l); p != core.ModelProvider(l) {
+		t.Error("opted out agent cached")
+	}
+	p := c.Middleware()("writer", l)
+	ctx := context.Background()
+	if resp := call(t, ctx, p, "Is Go fast?"); resp.Content != "Go is fast." || resp.Usage.TotalTokens != 42 {
+		t.Errorf("first call = %+v", resp)
+	}
+	// A replay spent no tokens
+	if resp := call(t, ctx, p, "Is Go fast?"); resp.Content != "Go is fast." || resp.Usage.TotalTokens != 0 || l.calls != 1 {
+		t.Errorf("replay = %+v after %d calls", resp, l.calls)
+	}
+	// Failed calls and empty replies aren't cached
+	l.reply = " \n"
+	call(t, ctx, p, "Say nothing")
+	call(t, ctx, p, "Say nothing")
+	l.reply, l.err = "", errors.New("model down")
+	p.Call(ctx, core.Prompt{User: "Fail"})
+	l.err = nil
+	call(t, ctx, p, "Fail")
+	if l.calls != 5 {
+		t.Errorf("%d calls", l.calls)
+	}
+
+	want := Stats{Hits: 1, Misses: 5, TokensSaved: 42, HitRate: 1.0 / 6}
+	if got := c.Snapshot()["writer"]; got != want {
+		t.Errorf("stats = %+v", got)
+	}
+	rec := httptest.NewRecorder()
+	c.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/admin/llm-cache", nil))
+	var body struct{ Agents map[string]Stats }
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Agents["writer"] != want {
+		t.Errorf("handler = %s", rec.Body)
+	}
+
+	if n, err := c.Flush(); n != 1 || err != nil {
+		t.Errorf("Flush = %d, %v", n, err)
+	}
+	call(t, ctx, p, "Is Go fast?")
+	if l.calls != 6 {
+		t.Error("replayed after a flush")
+	}
+}
+
+func TestKey(t *testing.T) {
+	c := newCache(t)
+	l := &llm{reply: "ok"}
+	writer, editor := c.Middleware()("writer", l), c.Middleware()("editor", l)
+	ctx := context.Background()
+	image := media.Image{MIMEType: "image/png", Data: []byte{1}}
+	temp, tokens := float32(0.2), int32(100)
+	calls := []struct {
+		ctx    context.Context
+		p      core.ModelProvider
+		prompt core.Prompt
+	}{
+		{ctx, writer, core.Prompt{User: "hi"}},
+		// The same call is a hit, for any agent using the same model
+		{ctx, writer, core.Prompt{User: "hi"}},
+		{ctx, editor, core.Prompt{User: "hi"}},
+		// while a tenant, images or parameters make another
+		{tenant.NewContext(ctx, "acme"), writer, core.Prompt{User: "hi"}},
+		{media.WithImages(ctx, image), writer, core.Prompt{User: "hi"}},
+		{ctx, writer, core.Prompt{User: "hi", Parameters: core.ModelParameters{Temperature: &temp}}},
+		{ctx, writer, core.Prompt{User: "hi", Parameters: core.ModelParameters{MaxTokens: &tokens}}},
+		{ctx, writer, core.Prompt{System: "hi"}},
+	}
+	for _, tt := range calls {
+		tt.p.Call(tt.ctx, tt.prompt)
+	}
+	if l.calls != 6 {
+		t.Errorf("%d calls", l.calls)
+	}
+}
+
+func TestProvider(t *testing.T) {
+	c := newCache(t)
+	ctx := context.Background()
+	mw := c.Middleware()
+
+	// A named provider's replies are keyed by its model, found through
+	// the LLM middleware
+	openai := &llm{reply: "from openai"}
+	call(t, ctx, mw("writer", wrapper{named{openai, "openai"}}), "hi")
+	own := &llm{reply: "own"}
+	if resp := call(t, ctx, mw("writer", own), "hi"); resp.Content != "from openai" || own.calls != 0 {
+		t.Errorf("the agent's own provider, of the same model: %+v", resp)
+	}
+	azure := &llm{reply: "from azure"}
+	if resp := call(t, ctx, mw("writer", named{azure, "azure"}), "hi"); resp.Content != "from azure" {
+		t.Errorf("another model: %+v", resp)
+	}
+
+	// A routed provider is keyed by the model the prompt goes to
+	router := &llm{reply: "routed"}
+	p := mw("writer", routed{router})
+	call(t, ctx, p, "Is Rust fast?")
+	call(t, ctx, p, "hi")
+	if router.calls != 1 {
+		t.Errorf("%d calls to the router", router.calls)
+	}
+}
+
+func TestAnswered(t *testing.T) {
+	c := newCache(t)
+	ctx := context.Background()
+	// The smart model failed, so the fast one answered: the reply is kept
+	// under the fast model
+	fellBack := &llm{reply: "from the fast model", answeredBy: "fast"}
+	p := c.Middleware()("writer", routed{fellBack})
+	call(t, ctx, p, "hi")
+	call(t, ctx, p, "hi")
+	if fellBack.calls != 2 {
+		t.Errorf("%d calls", fellBack.calls)
+	}
+	fast := &llm{}
+	if resp := call(t, ctx, c.Middleware()("writer", named{fast, "fast"}), "hi"); resp.Content != "from the fast model" {
+		t.Errorf("fast model = %+v", resp)
+	}
+	// Outside a cached call it does nothing
+	Answered(ctx, "fast")
+}
+
+func collect(tokens <-chan core.Token) string {
+	var b strings.Builder
+	for t := range tokens {
+		b.WriteString(t.Content)
+	}
+	return b.String()
+}
+
+func TestStream(t *testing.T) {
+	c := newCache(t)
+	ctx := context.Background()
+	l := &llm{tokens: []core.Token{{Content: "Go "}, {Content: "is fast."}}}
+	p := c.Middleware()("writer", l)
+	for i := 0; i < 2; i++ {
+		out, err := p.Stream(ctx, core.Prompt{User: "hi"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := collect(out); got != "Go is fast." {
+			t.Errorf("stream %d = %q", i, got)
+		}
+	}
+	if l.calls != 1 {
+		t.Errorf("%d calls", l.calls)
+	}
+	// A call replays a streamed reply, and a stream a called one
+	if resp := call(t, ctx, p, "hi"); resp.Content != "Go is fast." {
+		t.Errorf("call = %+v", resp)
+	}
+
+	// A stream that fails isn't cached
+	l.tokens = []core.Token{{Content: "Go "}, {Error: errors.New("status 500")}}
+	fail := func() {
+		out, _ := p.Stream(ctx, core.Prompt{User: "Is Rust fast?"})
+		collect(out)
+	}
+	fail()
+	fail()
+	l.err = errors.New("model down")
+	if _, err := p.Stream(ctx, core.Prompt{User: "Fail"}); err != l.err {
+		t.Errorf("stream error = %v", err)
+	}
+	if l.calls != 4 {
+		t.Errorf("%d calls", l.calls)
+	}
+}
@@ -0,0 +1,94 @@
+package llmcache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps values in process, dropping the least recently used
+// once it holds its maximum.
+type MemoryStore struct {
+	max int
+
+	mu      sync.Mutex
+	order   *list.List // of *entry, most recently used first
+	entries map[string]*list.Element
+}
+
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewMemoryStore creates a store holding up to max values.
+func NewMemoryStore(max int) *MemoryStore {
+	return &MemoryStore{max: max, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := el.Value.(*entry)
+	if time.Now().After(e.expires) {
+		s.order.Remove(el)
+		delete(s.entries, key)
+		return nil, false, nil
+	}
+	s.order.MoveToFront(el)
+	return e.value, true, nil
+}
+
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	expires := time.Now().Add(ttl)
+	if el, ok := s.entries[key]; ok {
+		e := el.Value.(*entry)
+		e.value, e.expires = value, expires
+		s.order.MoveToFront(el)
+		return nil
+	}
+	s.entries[key] = s.order.PushFront(&entry{key: key, value: value, expires: expires})
+	for s.order.Len() > s.max {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*entry).key)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Flush(context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := len(s.entries)
+	s.order.Init()
+	clear(s.entries)
+	return n, nil
+}
+
+func (s *MemoryStore) Compact(context.Context) (int, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	var n int
+	var size int64
+	for key, el := range s.entries {
+		e := el.Value.(*entry)
+		if now.After(e.expires) {
+			s.order.Remove(el)
+			delete(s.entries, key)
+			n++
+			size += int64(len(e.key) + len(e.value))
+		}
+	}
+	return n, size, nil
+}
+
+func (s *MemoryStore) Close() error { return nil }
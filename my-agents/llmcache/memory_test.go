@@ -0,0 +1,71 @@
+package llmcache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// testStore checks the behavior every store shares.
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+	ctx := context.Background()
+	if _, ok, err := s.Get(ctx, "a"); ok || err != nil {
+		t.Errorf("Get of an unknown key = %v, %v", ok, err)
+	}
+	if err := s.Set(ctx, "a", []byte("reply"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if v, ok, err := s.Get(ctx, "a"); string(v) != "reply" || !ok || err != nil {
+		t.Errorf("Get = %q, %v, %v", v, ok, err)
+	}
+	s.Set(ctx, "a", []byte("newer"), time.Hour)
+	s.Set(ctx, "b", []byte("other"), time.Hour)
+	if v, _, _ := s.Get(ctx, "a"); string(v) != "newer" {
+		t.Errorf("replaced value = %q", v)
+	}
+
+	s.Set(ctx, "c", []byte("brief"), time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if _, ok, _ := s.Get(ctx, "c"); ok {
+		t.Error("expired value returned")
+	}
+	if n, err := s.Flush(ctx); n != 2 || err != nil {
+		t.Errorf("Flush = %d, %v", n, err)
+	}
+	if _, ok, _ := s.Get(ctx, "a"); ok {
+		t.Error("flushed value returned")
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	testStore(t, NewMemoryStore(10))
+}
+
+func TestMemoryStoreEvicts(t *testing.T) {
+	s := NewMemoryStore(2)
+	ctx := context.Background()
+	s.Set(ctx, "a", []byte("1"), time.Hour)
+	s.Set(ctx, "b", []byte("2"), time.Hour)
+	s.Get(ctx, "a")
+	s.Set(ctx, "c", []byte("3"), time.Hour)
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok, _ := s.Get(ctx, key); ok != want {
+			t.Errorf("%s held: %v", key, ok)
+		}
+	}
+}
+
+func TestMemoryStoreCompact(t *testing.T) {
+	s := NewMemoryStore(10)
+	ctx := context.Background()
+	s.Set(ctx, "key", []byte("value"), time.Millisecond)
+	s.Set(ctx, "kept", []byte("value"), time.Hour)
+	time.Sleep(5 * time.Millisecond)
+	if n, size, err := s.Compact(ctx); n != 1 || size != 8 || err != nil {
+		t.Errorf("Compact = %d, %d, %v", n, size, err)
+	}
+	if n, _ := s.Flush(ctx); n != 1 {
+		t.Errorf("%d values left", n)
+	}
+}
@@ -0,0 +1,159 @@
+package llmcache
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"my-agents/resp"
+)
+
+// Redis connection settings.
+const (
+	// redisTimeout bounds a command whose context has no deadline, so a
+	// stalled server slows a call by at most this before it goes through
+	// uncached.
+	redisTimeout = 2 * time.Second
+	// redisIdle is how many idle connections the store keeps for reuse.
+	redisIdle = 8
+)
+
+// RedisStore keeps each value under "<prefix><key>", expiring with its TTL.
+// Commands run concurrently, each on a connection of its own taken from a
+// pool of idle ones.
+type RedisStore struct {
+	u      *url.URL
+	prefix string
+	idle   chan *resp.Conn
+	closed atomic.Bool
+}
+
+// NewRedisStore creates a store on the server at u. It connects on first
+// use.
+func NewRedisStore(u *url.URL, prefix string) (*RedisStore, error) {
+	if err := resp.CheckURL(u); err != nil {
+		return nil, fmt.Errorf("llm cache: %w", err)
+	}
+	return &RedisStore{u: u, prefix: prefix, idle: make(chan *resp.Conn, redisIdle)}, nil
+}
+
+// do runs a command on an idle connection, or a new one, by ctx's deadline
+// or within redisTimeout. An idle connection that dropped is replaced once.
+func (s *RedisStore) do(ctx context.Context, args ...string) (any, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Now().Add(redisTimeout)
+	}
+	for attempt := 0; ; attempt++ {
+		conn, pooled := s.get()
+		if conn == nil {
+			dialCtx, cancel := context.WithDeadline(ctx, deadline)
+			var err error
+			conn, err = resp.Dial(dialCtx, s.u)
+			cancel()
+			if err != nil {
+				return nil, err
+			}
+		}
+		conn.SetDeadline(deadline)
+		reply, err := conn.Do(args...)
+		if _, ok := err.(resp.Error); ok || err == nil {
+			s.put(conn)
+			return reply, err
+		}
+		conn.Close()
+		if !pooled || attempt > 0 || time.Now().After(deadline) {
+			return nil, err
+		}
+	}
+}
+
+// get takes an idle connection, or returns nil when there is none.
+func (s *RedisStore) get() (*resp.Conn, bool) {
+	select {
+	case conn := <-s.idle:
+		return conn, true
+	default:
+		return nil, false
+	}
+}
+
+// put returns conn to the pool, closing it when the pool is full.
+func (s *RedisStore) put(conn *resp.Conn) {
+	if s.closed.Load() {
+		conn.Close()
+		return
+	}
+	conn.SetDeadline(time.Time{})
+	select {
+	case s.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := s.do(ctx, "GET", s.prefix+key)
+	if err != nil || reply == nil {
+		return nil, false, err
+	}
+	data, _ := reply.(string)
+	return []byte(data), true, nil
+}
+
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := s.do(ctx, "SET", s.prefix+key, string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// Compact has nothing to do: Redis expires keys itself.
+func (s *RedisStore) Compact(context.Context) (int, int64, error) { return 0, 0, nil }
+
+// Flush deletes the keys under the prefix, a page of SCAN at a time.
+func (s *RedisStore) Flush(ctx context.Context) (int, error) {
+	n, cursor := 0, "0"
+	for {
+		reply, err := s.do(ctx, "SCAN", cursor, "MATCH", s.prefix+"*", "COUNT", "500")
+		if err != nil {
+			return n, err
+		}
+		page, _ := reply.([]any)
+		if len(page) != 2 {
+			return n, fmt.Errorf("llm cache: unexpected SCAN reply")
+		}
+		cursor, _ = page[0].(string)
+		keys, _ := page[1].([]any)
+		if len(keys) > 0 {
+			args := []string{"DEL"}
+			for _, k := range keys {
+				key, _ := k.(string)
+				args = append(args, key)
+			}
+			reply, err := s.do(ctx, args...)
+			if err != nil {
+				return n, err
+			}
+			deleted, _ := reply.(int64)
+			n += int(deleted)
+		}
+		if cursor == "0" {
+			return n, nil
+		}
+	}
+}
+
+// Close closes the idle connections; those running a command close when
+// it ends.
+func (s *RedisStore) Close() error {
+	s.closed.Store(true)
+	for {
+		conn, _ := s.get()
+		if conn == nil {
+			return nil
+		}
+		conn.Close()
+	}
+}
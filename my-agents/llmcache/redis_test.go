@@ -0,0 +1,236 @@
+package llmcache
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// redisServer is an in-memory Redis answering the commands the store sends.
+// SCAN returns pages of two keys.
+type redisServer struct {
+	url *url.URL
+
+	mu     sync.Mutex
+	values map[string]string
+	expiry map[string]time.Time
+	conns  []net.Conn
+	dials  int
+	stall  bool
+}
+
+func newRedisServer(t *testing.T) *redisServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s := &redisServer{
+		url:    &url.URL{Scheme: "redis", Host: l.Addr().String()},
+		values: make(map[string]string),
+		expiry: make(map[string]time.Time),
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, c)
+			s.dials++
+			s.mu.Unlock()
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+// drop closes the connections open so far.
+func (s *redisServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+func (s *redisServer) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		var n int
+		if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+			return
+		}
+		args := make([]string, n)
+		for i := range args {
+			var size int
+			if _, err := fmt.Fscanf(r, "$%d\r\n", &size); err != nil {
+				return
+			}
+			buf := make([]byte, size+2)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			args[i] = string(buf[:size])
+		}
+		if _, err := io.WriteString(c, s.run(args)); err != nil {
+			return
+		}
+	}
+}
+
+func bulk(s string) string { return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n" }
+
+func (s *redisServer) run(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.stall {
+		return ""
+	}
+	switch args[0] {
+	case "SET":
+		// SET key value PX ms
+		ms, _ := strconv.Atoi(args[4])
+		s.values[args[1]] = args[2]
+		s.expiry[args[1]] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return "+OK\r\n"
+	case "GET":
+		if at, ok := s.expiry[args[1]]; ok && time.Now().After(at) {
+			delete(s.values, args[1])
+		}
+		if v, ok := s.values[args[1]]; ok {
+			return bulk(v)
+		}
+		return "$-1\r\n"
+	case "SCAN":
+		// SCAN cursor MATCH pattern COUNT n, the cursor being the last key
+		// returned
+		var keys []string
+		for key := range s.values {
+			if ok, _ := path.Match(args[3], key); ok && (args[1] == "0" || key > args[1]) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		next := "0"
+		if len(keys) > 2 {
+			keys = keys[:2]
+			next = keys[1]
+		}
+		out := "*2\r\n" + bulk(next) + "*" + strconv.Itoa(len(keys)) + "\r\n"
+		for _, key := range keys {
+			out += bulk(key)
+		}
+		return out
+	case "DEL":
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := s.values[key]; ok {
+				delete(s.values, key)
+				n++
+			}
+		}
+		return ":" + strconv.Itoa(n) + "\r\n"
+	}
+	return "-ERR unknown command '" + strings.ToLower(args[0]) + "'\r\n"
+}
+
+func openRedis(t *testing.T) (*RedisStore, *redisServer) {
+	t.Helper()
+	srv := newRedisServer(t)
+	s, err := NewRedisStore(srv.url, DefaultRedisPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, srv
+}
+
+func TestRedisStore(t *testing.T) {
+	s, srv := openRedis(t)
+	// A key of another prefix outlives a flush
+	srv.values["other:a"] = "kept"
+	testStore(t, s)
+	if _, ok := srv.values["other:a"]; !ok || len(srv.values) != 1 {
+		t.Errorf("keys = %v", srv.values)
+	}
+	// Redis expires the keys itself
+	if n, size, err := s.Compact(context.Background()); n != 0 || size != 0 || err != nil {
+		t.Errorf("Compact = %d, %d, %v", n, size, err)
+	}
+	// Commands reuse the idle connection
+	if srv.dials != 1 {
+		t.Errorf("%d connections", srv.dials)
+	}
+
+	if _, err := NewRedisStore(&url.URL{Scheme: "http", Host: "localhost"}, DefaultRedisPrefix); err == nil {
+		t.Error("http url accepted")
+	}
+}
+
+func TestRedisStoreFlushPages(t *testing.T) {
+	s, _ := openRedis(t)
+	ctx := context.Background()
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		s.Set(ctx, key, []byte("reply"), time.Hour)
+	}
+	if n, err := s.Flush(ctx); n != 5 || err != nil {
+		t.Errorf("Flush = %d, %v", n, err)
+	}
+}
+
+func TestRedisStoreRedials(t *testing.T) {
+	s, srv := openRedis(t)
+	ctx := context.Background()
+	if err := s.Set(ctx, "a", []byte("reply"), time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	srv.drop()
+	if v, ok, err := s.Get(ctx, "a"); string(v) != "reply" || !ok || err != nil {
+		t.Errorf("Get after the connection dropped = %q, %v, %v", v, ok, err)
+	}
+	// Error replies leave the connection be
+	if _, err := s.do(ctx, "FLUSHALL"); err == nil {
+		t.Error("error reply not returned")
+	}
+	s.Get(ctx, "a")
+	if srv.dials != 2 {
+		t.Errorf("%d connections", srv.dials)
+	}
+}
+
+func TestRedisStoreDeadline(t *testing.T) {
+	s, srv := openRedis(t)
+	srv.mu.Lock()
+	srv.stall = true
+	srv.mu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, _, err := s.Get(ctx, "a"); err == nil || time.Since(start) > time.Second {
+		t.Errorf("stalled server: %v after %s", err, time.Since(start))
+	}
+	// Once closed the store keeps no connections
+	s.Close()
+	srv.mu.Lock()
+	srv.stall = false
+	srv.mu.Unlock()
+	s.Get(context.Background(), "a")
+	if len(s.idle) != 0 {
+		t.Errorf("%d idle connections after Close", len(s.idle))
+	}
+}
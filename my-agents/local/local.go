@@ -0,0 +1,223 @@
+// Package local talks to models served without a cloud API, so the
+// pipeline can run offline: Ollama through its native API, and servers
+// speaking the OpenAI chat completions API — llama.cpp, vLLM, LM Studio,
+// LocalAI — as "openai-compatible". Both stream, report token usage and
+// embed.
+package local
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/httpclient"
+	"my-agents/ratelimit"
+)
+
+// Provider types.
+const (
+	TypeOllama           = "ollama"
+	TypeOpenAICompatible = "openai-compatible"
+)
+
+// Defaults for unset settings.
+const (
+	DefaultOllamaURL = "http://localhost:11434"
+	DefaultTimeout   = 5 * time.Minute
+)
+
+// Options are the settings of a local provider's [providers.<name>] table,
+// or of [llm], besides agenticgokit's type, model, base_url, api_key,
+// max_tokens and temperature:
+//
+//	[providers.local]
+//	type = "ollama"
+//	model = "llama3.1:8b"
+//	base_url = "http://gpu-box:11434"
+//	keep_alive = "30m"
+//	num_ctx = 8192
+type Options struct {
+	// KeepAlive is how long Ollama keeps the model loaded after a call,
+	// e.g. "30m", or "-1" for ever (default Ollama's, 5 minutes).
+	KeepAlive string `toml:"keep_alive"`
+	// NumCtx is Ollama's context window in tokens (default the model's).
+	NumCtx int `toml:"num_ctx"`
+	// EmbeddingModel embeds texts (default the chat model).
+	EmbeddingModel string `toml:"embedding_model"`
+	// Timeout bounds the wait for a call to answer, or a stream to start, a
+	// slow local model's included (default 5m).
+	Timeout string `toml:"timeout"`
+}
+
+// Supports reports whether typ is a local provider type.
+func Supports(typ string) bool {
+	return typ == TypeOllama || typ == TypeOpenAICompatible
+}
+
+// New creates the local provider cfg configures.
+func New(cfg core.LLMProviderConfig, opts Options) (core.ModelProvider, error) {
+	timeout := DefaultTimeout
+	if cfg.HTTPTimeout > 0 {
+		timeout = cfg.HTTPTimeout
+	}
+	if opts.Timeout != "" {
+		var err error
+		if timeout, err = time.ParseDuration(opts.Timeout); err != nil {
+			return nil, fmt.Errorf("local provider timeout: %w", err)
+		}
+	}
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("%s provider needs a model", cfg.Type)
+	}
+	c := client{http: httpclient.New(timeout), apiKey: cfg.APIKey, model: cfg.Model, embeddingModel: opts.EmbeddingModel, maxTokens: cfg.MaxTokens, temperature: cfg.Temperature}
+	if c.embeddingModel == "" {
+		c.embeddingModel = cfg.Model
+	}
+	switch cfg.Type {
+	case TypeOllama:
+		c.baseURL = strings.TrimSuffix(cmp.Or(cfg.BaseURL, DefaultOllamaURL), "/")
+		if opts.KeepAlive != "" && opts.KeepAlive != "-1" {
+			if _, err := time.ParseDuration(opts.KeepAlive); err != nil {
+				return nil, fmt.Errorf("ollama keep_alive %q: want a duration like \"30m\", or -1", opts.KeepAlive)
+			}
+		}
+		return &Ollama{client: c, keepAlive: opts.KeepAlive, numCtx: opts.NumCtx}, nil
+	case TypeOpenAICompatible:
+		if cfg.BaseURL == "" {
+			return nil, fmt.Errorf("openai-compatible provider needs base_url, e.g. http://localhost:8080/v1")
+		}
+		c.baseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+		return &OpenAICompatible{client: c}, nil
+	default:
+		return nil, fmt.Errorf("%q is not a local provider type", cfg.Type)
+	}
+}
+
+// client holds what both APIs share.
+type client struct {
+	http           *http.Client
+	baseURL        string
+	apiKey         string
+	model          string
+	embeddingModel string
+	maxTokens      int
+	temperature    float64
+}
+
+// params resolves a call's sampling parameters, the prompt's winning; zero
+// leaves the server's default.
+func (c client) params(prompt core.Prompt) (maxTokens int, temperature float64) {
+	maxTokens, temperature = c.maxTokens, c.temperature
+	if p := prompt.Parameters.MaxTokens; p != nil && *p > 0 {
+		maxTokens = int(*p)
+	}
+	if p := prompt.Parameters.Temperature; p != nil {
+		temperature = float64(*p)
+	}
+	return maxTokens, temperature
+}
+
+// messages renders prompt as chat messages.
+func messages(prompt core.Prompt) []map[string]string {
+	var msgs []map[string]string
+	if prompt.System != "" {
+		msgs = append(msgs, map[string]string{"role": "system", "content": prompt.System})
+	}
+	msgs = append(msgs, map[string]string{"role": "user", "content": prompt.User})
+	return msgs
+}
+
+// post sends in as JSON to path and returns the response body, which the
+// caller closes. Error responses are reported with their status, which the
+// retry and fallback policies classify.
+func (c client) post(ctx context.Context, path string, in any) (io.ReadCloser, error) {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiKey)
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, ratelimit.WithRetryAfter(fmt.Errorf("%s returned status %d: %s", c.baseURL+path, resp.StatusCode, strings.TrimSpace(string(msg))), resp.Header)
+	}
+	return resp.Body, nil
+}
+
+// call posts in to path and decodes the response into out.
+func (c client) call(ctx context.Context, path string, in, out any) error {
+	body, err := c.post(ctx, path, in)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := json.NewDecoder(body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", path, err)
+	}
+	return nil
+}
+
+// stream posts in to path and sends the content parse finds in each line
+// of the response on the returned channel until parse reports the end.
+func (c client) stream(ctx context.Context, path string, in any, parse func(line []byte) (content string, done bool, err error)) (<-chan core.Token, error) {
+	body, err := c.post(ctx, path, in)
+	if err != nil {
+		return nil, err
+	}
+	tokens := make(chan core.Token)
+	go func() {
+		defer close(tokens)
+		defer body.Close()
+		send := func(t core.Token) bool {
+			select {
+			case tokens <- t:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+		scanner := bufio.NewScanner(body)
+		scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := bytes.TrimSpace(scanner.Bytes())
+			if len(line) == 0 {
+				continue
+			}
+			content, done, err := parse(line)
+			if err != nil {
+				send(core.Token{Error: err})
+				return
+			}
+			if content != "" && !send(core.Token{Content: content}) {
+				return
+			}
+			if done {
+				return
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			send(core.Token{Error: err})
+		}
+	}()
+	return tokens, nil
+}
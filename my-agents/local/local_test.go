@@ -0,0 +1,98 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/ratelimit"
+)
+
+// serve starts a server answering with respond, keeping the last request's
+// decoded body in got.
+func serve(t *testing.T, got *map[string]any, respond func(w http.ResponseWriter, r *http.Request)) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*got = nil
+		json.NewDecoder(r.Body).Decode(got)
+		respond(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestNew(t *testing.T) {
+	p, err := New(core.LLMProviderConfig{Type: TypeOllama, Model: "llama3.1:8b"}, Options{KeepAlive: "-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	o := p.(*Ollama)
+	if o.baseURL != DefaultOllamaURL || o.embeddingModel != "llama3.1:8b" || o.keepAlive != "-1" {
+		t.Errorf("ollama = %+v", o)
+	}
+	p, err = New(core.LLMProviderConfig{Type: TypeOpenAICompatible, Model: "qwen", BaseURL: "http://gpu:8080/v1/"}, Options{EmbeddingModel: "bge"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c := p.(*OpenAICompatible); c.baseURL != "http://gpu:8080/v1" || c.embeddingModel != "bge" {
+		t.Errorf("openai-compatible = %+v", c)
+	}
+
+	for name, tt := range map[string]struct {
+		cfg  core.LLMProviderConfig
+		opts Options
+	}{
+		"no model":      {core.LLMProviderConfig{Type: TypeOllama}, Options{}},
+		"bad timeout":   {core.LLMProviderConfig{Type: TypeOllama, Model: "m"}, Options{Timeout: "long"}},
+		"bad keepalive": {core.LLMProviderConfig{Type: TypeOllama, Model: "m"}, Options{KeepAlive: "forever"}},
+		"no base url":   {core.LLMProviderConfig{Type: TypeOpenAICompatible, Model: "m"}, Options{}},
+		"not local":     {core.LLMProviderConfig{Type: "openai", Model: "m"}, Options{}},
+	} {
+		if _, err := New(tt.cfg, tt.opts); err == nil {
+			t.Errorf("%s: created", name)
+		}
+	}
+	if !Supports(TypeOllama) || !Supports(TypeOpenAICompatible) || Supports("azure") {
+		t.Error("Supports is wrong")
+	}
+}
+
+func TestParams(t *testing.T) {
+	c := client{maxTokens: 500, temperature: 0.7}
+	if n, temp := c.params(core.Prompt{}); n != 500 || temp != 0.7 {
+		t.Errorf("defaults = %d, %g", n, temp)
+	}
+	maxTokens, temperature := int32(50), float32(0)
+	n, temp := c.params(core.Prompt{Parameters: core.ModelParameters{MaxTokens: &maxTokens, Temperature: &temperature}})
+	if n != 50 || temp != 0 {
+		t.Errorf("prompt's = %d, %g", n, temp)
+	}
+}
+
+func TestMessages(t *testing.T) {
+	if msgs := messages(core.Prompt{User: "hi"}); len(msgs) != 1 || msgs[0]["role"] != "user" {
+		t.Errorf("messages = %v", msgs)
+	}
+	if msgs := messages(core.Prompt{System: "be brief", User: "hi"}); len(msgs) != 2 || msgs[0]["content"] != "be brief" {
+		t.Errorf("messages = %v", msgs)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	var got map[string]any
+	srv := serve(t, &got, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "2")
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+	})
+	_, err := compatible(t, srv.URL, "").Call(context.Background(), core.Prompt{User: "hi"})
+	var ra *ratelimit.RetryAfterError
+	if !errors.As(err, &ra) || ra.Wait != 2*time.Second {
+		t.Errorf("rate limited: %#v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Ollama calls a model served by Ollama's native API, which, unlike its
+// OpenAI-compatible one, takes keep_alive and the context window.
+type Ollama struct {
+	client
+	keepAlive string
+	numCtx    int
+}
+
+// ollamaChunk is a /api/chat response, or one line of a streamed one.
+type ollamaChunk struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	Done            bool   `json:"done"`
+	DoneReason      string `json:"done_reason"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	Error           string `json:"error"`
+}
+
+func (o *Ollama) request(prompt core.Prompt, stream bool) map[string]any {
+	maxTokens, temperature := o.params(prompt)
+	options := map[string]any{}
+	if maxTokens > 0 {
+		options["num_predict"] = maxTokens
+	}
+	if temperature > 0 {
+		options["temperature"] = temperature
+	}
+	if o.numCtx > 0 {
+		options["num_ctx"] = o.numCtx
+	}
+	req := map[string]any{"model": o.model, "messages": messages(prompt), "stream": stream, "options": options}
+	o.withKeepAlive(req)
+	return req
+}
+
+// withKeepAlive sets req's keep_alive, a number when it is one, as Ollama
+// reads "-1" as a malformed duration.
+func (o *Ollama) withKeepAlive(req map[string]any) {
+	switch o.keepAlive {
+	case "":
+	case "-1", "0":
+		req["keep_alive"] = json.Number(o.keepAlive)
+	default:
+		req["keep_alive"] = o.keepAlive
+	}
+}
+
+func (o *Ollama) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	var out ollamaChunk
+	if err := o.call(ctx, "/api/chat", o.request(prompt, false), &out); err != nil {
+		return core.Response{}, fmt.Errorf("ollama: %w", err)
+	}
+	if out.Error != "" {
+		return core.Response{}, fmt.Errorf("ollama: %s", out.Error)
+	}
+	return core.Response{
+		Content:      out.Message.Content,
+		Usage:        core.UsageStats{PromptTokens: out.PromptEvalCount, CompletionTokens: out.EvalCount, TotalTokens: out.PromptEvalCount + out.EvalCount},
+		FinishReason: out.DoneReason,
+	}, nil
+}
+
+// Stream reads Ollama's stream of JSON lines.
+func (o *Ollama) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	tokens, err := o.stream(ctx, "/api/chat", o.request(prompt, true), func(line []byte) (string, bool, error) {
+		var chunk ollamaChunk
+		if err := json.Unmarshal(line, &chunk); err != nil {
+			return "", false, fmt.Errorf("ollama: bad stream line: %w", err)
+		}
+		if chunk.Error != "" {
+			return "", false, fmt.Errorf("ollama: %s", chunk.Error)
+		}
+		return chunk.Message.Content, chunk.Done, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ollama: %w", err)
+	}
+	return tokens, nil
+}
+
+// Embeddings embeds texts in one /api/embed request.
+func (o *Ollama) Embeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	req := map[string]any{"model": o.embeddingModel, "input": texts}
+	o.withKeepAlive(req)
+	var out struct {
+		Embeddings [][]float64 `json:"embeddings"`
+	}
+	if err := o.call(ctx, "/api/embed", req, &out); err != nil {
+		return nil, fmt.Errorf("ollama: %w", err)
+	}
+	if len(out.Embeddings) != len(texts) {
+		return nil, fmt.Errorf("ollama: %d embeddings for %d texts", len(out.Embeddings), len(texts))
+	}
+	return out.Embeddings, nil
+}
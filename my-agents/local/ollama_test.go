@@ -0,0 +1,114 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+func ollama(t *testing.T, url string, opts Options) *Ollama {
+	t.Helper()
+	p, err := New(core.LLMProviderConfig{Type: TypeOllama, Model: "llama3.1:8b", BaseURL: url, MaxTokens: 200, Temperature: 0.2}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.(*Ollama)
+}
+
+func TestOllamaCall(t *testing.T) {
+	var got map[string]any
+	srv := serve(t, &got, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"message": {"content": "Go is fast."}, "done": true, "done_reason": "stop", "prompt_eval_count": 12, "eval_count": 4}`)
+	})
+	o := ollama(t, srv.URL, Options{KeepAlive: "-1", NumCtx: 8192})
+	resp, err := o.Call(context.Background(), core.Prompt{System: "Be brief.", User: "Is Go fast?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Go is fast." || resp.Usage.TotalTokens != 16 || resp.FinishReason != "stop" {
+		t.Errorf("response = %+v", resp)
+	}
+	options := got["options"].(map[string]any)
+	if got["model"] != "llama3.1:8b" || got["stream"] != false || got["keep_alive"] != -1.0 || options["num_predict"] != 200.0 || options["temperature"] != 0.2 || options["num_ctx"] != 8192.0 {
+		t.Errorf("request = %v", got)
+	}
+
+	srv = serve(t, &got, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"error": "model \"llama3.1:8b\" not found"}`)
+	})
+	if _, err := ollama(t, srv.URL, Options{}).Call(context.Background(), core.Prompt{}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("error response: %v", err)
+	}
+	srv = serve(t, &got, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "busy", http.StatusServiceUnavailable)
+	})
+	if _, err := ollama(t, srv.URL, Options{}).Call(context.Background(), core.Prompt{}); err == nil || !strings.Contains(err.Error(), "/api/chat returned status 503: busy") {
+		t.Errorf("failed call: %v", err)
+	}
+}
+
+func TestOllamaStream(t *testing.T) {
+	var got map[string]any
+	srv := serve(t, &got, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message": {"content": "Go "}}`)
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, `{"message": {"content": "is fast."}, "done": true}`)
+		fmt.Fprintln(w, `{"message": {"content": "ignored"}}`)
+	})
+	tokens, err := ollama(t, srv.URL, Options{KeepAlive: "30m"}).Stream(context.Background(), core.Prompt{User: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text strings.Builder
+	for tok := range tokens {
+		if tok.Error != nil {
+			t.Fatal(tok.Error)
+		}
+		text.WriteString(tok.Content)
+	}
+	if text.String() != "Go is fast." || got["stream"] != true || got["keep_alive"] != "30m" {
+		t.Errorf("streamed %q for %v", text.String(), got)
+	}
+
+	srv = serve(t, &got, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"message": {"content": "Go "}}`)
+		fmt.Fprintln(w, `{"error": "out of memory"}`)
+	})
+	tokens, _ = ollama(t, srv.URL, Options{}).Stream(context.Background(), core.Prompt{})
+	var last core.Token
+	for tok := range tokens {
+		last = tok
+	}
+	if last.Error == nil || last.Error.Error() != "ollama: out of memory" {
+		t.Errorf("last token = %+v", last)
+	}
+}
+
+func TestOllamaEmbeddings(t *testing.T) {
+	var got map[string]any
+	srv := serve(t, &got, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"embeddings": [[0.1, 0.2], [0.3, 0.4]]}`)
+	})
+	o := ollama(t, srv.URL, Options{EmbeddingModel: "nomic-embed-text", KeepAlive: "0"})
+	vectors, err := o.Embeddings(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 2 || vectors[1][0] != 0.3 || got["model"] != "nomic-embed-text" || got["keep_alive"] != 0.0 {
+		t.Errorf("vectors %v for %v", vectors, got)
+	}
+	if _, err := o.Embeddings(context.Background(), []string{"a", "b", "c"}); err == nil || err.Error() != "ollama: 2 embeddings for 3 texts" {
+		t.Errorf("short response: %v", err)
+	}
+	if vectors, err := o.Embeddings(context.Background(), nil); vectors != nil || err != nil {
+		t.Errorf("no texts = %v, %v", vectors, err)
+	}
+}
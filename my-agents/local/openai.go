@@ -0,0 +1,116 @@
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// OpenAICompatible calls a server implementing OpenAI's chat completions
+// and embeddings endpoints under its base URL, which ends in /v1 for most.
+// The API key is sent when set; local servers usually need none.
+type OpenAICompatible struct {
+	client
+}
+
+func (o *OpenAICompatible) request(prompt core.Prompt, stream bool) map[string]any {
+	maxTokens, temperature := o.params(prompt)
+	req := map[string]any{"model": o.model, "messages": messages(prompt), "stream": stream}
+	if maxTokens > 0 {
+		req["max_tokens"] = maxTokens
+	}
+	if temperature > 0 {
+		req["temperature"] = temperature
+	}
+	return req
+}
+
+func (o *OpenAICompatible) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	var out struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+		Usage struct {
+			PromptTokens     int `json:"prompt_tokens"`
+			CompletionTokens int `json:"completion_tokens"`
+			TotalTokens      int `json:"total_tokens"`
+		} `json:"usage"`
+	}
+	if err := o.call(ctx, "/chat/completions", o.request(prompt, false), &out); err != nil {
+		return core.Response{}, fmt.Errorf("openai-compatible: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return core.Response{}, fmt.Errorf("openai-compatible: response has no choices")
+	}
+	return core.Response{
+		Content:      out.Choices[0].Message.Content,
+		Usage:        core.UsageStats{PromptTokens: out.Usage.PromptTokens, CompletionTokens: out.Usage.CompletionTokens, TotalTokens: out.Usage.TotalTokens},
+		FinishReason: out.Choices[0].FinishReason,
+	}, nil
+}
+
+// Stream reads the server-sent events of a streamed completion.
+func (o *OpenAICompatible) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	tokens, err := o.stream(ctx, "/chat/completions", o.request(prompt, true), func(line []byte) (string, bool, error) {
+		data, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			return "", false, nil // event names, comments
+		}
+		data = bytes.TrimSpace(data)
+		if string(data) == "[DONE]" {
+			return "", true, nil
+		}
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		if err := json.Unmarshal(data, &chunk); err != nil {
+			return "", false, fmt.Errorf("openai-compatible: bad stream event: %w", err)
+		}
+		if len(chunk.Choices) == 0 {
+			return "", false, nil
+		}
+		return chunk.Choices[0].Delta.Content, false, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("openai-compatible: %w", err)
+	}
+	return tokens, nil
+}
+
+func (o *OpenAICompatible) Embeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	if len(texts) == 0 {
+		return nil, nil
+	}
+	var out struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float64 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := o.call(ctx, "/embeddings", map[string]any{"model": o.embeddingModel, "input": texts}, &out); err != nil {
+		return nil, fmt.Errorf("openai-compatible: %w", err)
+	}
+	vectors := make([][]float64, len(texts))
+	for _, d := range out.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("openai-compatible: embedding index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	for i, v := range vectors {
+		if v == nil {
+			return nil, fmt.Errorf("openai-compatible: no embedding for text %d", i)
+		}
+	}
+	return vectors, nil
+}
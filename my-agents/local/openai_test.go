@@ -0,0 +1,134 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+func compatible(t *testing.T, url, apiKey string) *OpenAICompatible {
+	t.Helper()
+	p, err := New(core.LLMProviderConfig{Type: TypeOpenAICompatible, Model: "qwen2.5", BaseURL: url + "/v1", APIKey: apiKey, MaxTokens: 100}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.(*OpenAICompatible)
+}
+
+func TestOpenAICompatibleCall(t *testing.T) {
+	var got map[string]any
+	var auth string
+	srv := serve(t, &got, func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if r.URL.Path != "/v1/chat/completions" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, `{"choices": [{"message": {"content": "Go is fast."}, "finish_reason": "length"}], "usage": {"prompt_tokens": 9, "completion_tokens": 3, "total_tokens": 12}}`)
+	})
+	resp, err := compatible(t, srv.URL, "sk-local").Call(context.Background(), core.Prompt{User: "Is Go fast?"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "Go is fast." || resp.Usage.TotalTokens != 12 || resp.FinishReason != "length" {
+		t.Errorf("response = %+v", resp)
+	}
+	if auth != "Bearer sk-local" || got["model"] != "qwen2.5" || got["max_tokens"] != 100.0 || got["temperature"] != nil {
+		t.Errorf("request %v with authorization %q", got, auth)
+	}
+	compatible(t, srv.URL, "").Call(context.Background(), core.Prompt{})
+	if auth != "" {
+		t.Errorf("sent authorization %q without a key", auth)
+	}
+
+	srv = serve(t, &got, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"choices": []}`)
+	})
+	if _, err := compatible(t, srv.URL, "").Call(context.Background(), core.Prompt{}); err == nil || err.Error() != "openai-compatible: response has no choices" {
+		t.Errorf("no choices: %v", err)
+	}
+	srv = serve(t, &got, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `<html>`)
+	})
+	if _, err := compatible(t, srv.URL, "").Call(context.Background(), core.Prompt{}); err == nil || !strings.Contains(err.Error(), "failed to decode /chat/completions response") {
+		t.Errorf("bad response: %v", err)
+	}
+}
+
+func TestOpenAICompatibleStream(t *testing.T) {
+	var got map[string]any
+	srv := serve(t, &got, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, line := range []string{
+			": keep-alive",
+			`data: {"choices": [{"delta": {"role": "assistant"}}]}`,
+			`data: {"choices": [{"delta": {"content": "Go "}}]}`,
+			`data: {"choices": []}`,
+			`data:{"choices": [{"delta": {"content": "is fast."}}]}`,
+			"data: [DONE]",
+			`data: {"choices": [{"delta": {"content": "ignored"}}]}`,
+		} {
+			fmt.Fprintf(w, "%s\n\n", line)
+		}
+	})
+	tokens, err := compatible(t, srv.URL, "").Stream(context.Background(), core.Prompt{User: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var text strings.Builder
+	for tok := range tokens {
+		if tok.Error != nil {
+			t.Fatal(tok.Error)
+		}
+		text.WriteString(tok.Content)
+	}
+	if text.String() != "Go is fast." || got["stream"] != true {
+		t.Errorf("streamed %q for %v", text.String(), got)
+	}
+
+	srv = serve(t, &got, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "data: {oops\n\n")
+	})
+	tokens, _ = compatible(t, srv.URL, "").Stream(context.Background(), core.Prompt{})
+	if tok := <-tokens; tok.Error == nil || !strings.Contains(tok.Error.Error(), "bad stream event") {
+		t.Errorf("token = %+v", tok)
+	}
+	srv = serve(t, &got, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no such model", http.StatusNotFound)
+	})
+	if _, err := compatible(t, srv.URL, "").Stream(context.Background(), core.Prompt{}); err == nil || !strings.Contains(err.Error(), "status 404: no such model") {
+		t.Errorf("failed stream: %v", err)
+	}
+}
+
+func TestOpenAICompatibleEmbeddings(t *testing.T) {
+	var got map[string]any
+	body := `{"data": [{"index": 1, "embedding": [0.3]}, {"index": 0, "embedding": [0.1]}]}`
+	srv := serve(t, &got, func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, body)
+	})
+	c := compatible(t, srv.URL, "")
+	vectors, err := c.Embeddings(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != 2 || vectors[0][0] != 0.1 || vectors[1][0] != 0.3 || got["model"] != "qwen2.5" {
+		t.Errorf("vectors %v for %v", vectors, got)
+	}
+	for _, tt := range []struct{ body, err string }{
+		{`{"data": [{"index": 2, "embedding": [0.1]}]}`, "openai-compatible: embedding index 2 out of range"},
+		{`{"data": [{"index": 1, "embedding": [0.1]}]}`, "openai-compatible: no embedding for text 0"},
+	} {
+		body = tt.body
+		if _, err := c.Embeddings(context.Background(), []string{"a", "b"}); err == nil || err.Error() != tt.err {
+			t.Errorf("%s: %v", tt.body, err)
+		}
+	}
+	if vectors, err := c.Embeddings(context.Background(), nil); vectors != nil || err != nil {
+		t.Errorf("no texts = %v, %v", vectors, err)
+	}
+}
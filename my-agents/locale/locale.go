@@ -0,0 +1,252 @@
+// Package locale selects per-request formatting rules, canned messages and
+// prompt variants from the "locale" event metadata key (a BCP 47 tag such
+// as "de-DE").
+package locale
+
+import (
+	"fmt"
+	"maps"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// MetadataKey is the event metadata key carrying the caller's locale.
+const MetadataKey = "locale"
+
+// Canned message keys.
+const (
+	MsgNoInput     = "no_input"
+	MsgNoData      = "no_data"
+	MsgUnavailable = "unavailable"
+)
+
+// Locale holds the formatting rules and text for one language/region.
+type Locale struct {
+	Tag         string
+	Language    string // English name, used in prompt instructions
+	DecimalSep  string
+	GroupSep    string
+	DateLayout  string // Go time layout
+	Messages    map[string]string
+	Prompts     map[string]string // agent name → system prompt override
+	Instruction string            // appended to system prompts; empty for the default locale
+}
+
+// Overrides customizes a locale from agentflow.toml:
+//
+//	[locales."de-DE".prompts]
+//	formatter = "Du bist ein Formatierungsagent..."
+//	[locales."de-DE".messages]
+//	no_input = "Keine Eingabe erhalten."
+type Overrides struct {
+	Messages map[string]string `toml:"messages"`
+	Prompts  map[string]string `toml:"prompts"`
+}
+
+var builtin = map[string]Locale{
+	"en-US": {Language: "English", DecimalSep: ".", GroupSep: ",", DateLayout: "January 2, 2006", Messages: map[string]string{
+		MsgNoInput:     "no input provided",
+		MsgNoData:      "no %s data found",
+		MsgUnavailable: "Sorry, we couldn't produce an answer right now. Please try again shortly.",
+	}},
+	"en-GB": {Language: "British English", DecimalSep: ".", GroupSep: ",", DateLayout: "2 January 2006"},
+	"de-DE": {Language: "German", DecimalSep: ",", GroupSep: ".", DateLayout: "02.01.2006", Messages: map[string]string{
+		MsgNoInput:     "keine Eingabe erhalten",
+		MsgNoData:      "keine %s-Daten gefunden",
+		MsgUnavailable: "Leider konnten wir gerade keine Antwort erstellen. Bitte versuchen Sie es in Kürze erneut.",
+	}},
+	"fr-FR": {Language: "French", DecimalSep: ",", GroupSep: " ", DateLayout: "02/01/2006", Messages: map[string]string{
+		MsgNoInput:     "aucune entrée fournie",
+		MsgNoData:      "aucune donnée %s trouvée",
+		MsgUnavailable: "Désolé, nous n'avons pas pu produire de réponse pour le moment. Veuillez réessayer sous peu.",
+	}},
+	"es-ES": {Language: "Spanish", DecimalSep: ",", GroupSep: ".", DateLayout: "02/01/2006", Messages: map[string]string{
+		MsgNoInput:     "no se proporcionó ninguna entrada",
+		MsgNoData:      "no se encontraron datos %s",
+		MsgUnavailable: "Lo sentimos, no pudimos generar una respuesta ahora. Inténtelo de nuevo en breve.",
+	}},
+	"ja-JP": {Language: "Japanese", DecimalSep: ".", GroupSep: ",", DateLayout: "2006年1月2日", Messages: map[string]string{
+		MsgNoInput:     "入力がありません",
+		MsgNoData:      "%s データが見つかりません",
+		MsgUnavailable: "申し訳ありませんが、現在回答を作成できません。しばらくしてから再度お試しください。",
+	}},
+}
+
+// Registry resolves locales by tag, falling back to the base language and
+// then to the default locale.
+type Registry struct {
+	defaultTag string
+	locales    map[string]*Locale
+}
+
+// NewRegistry builds the registry from the built-in locales plus overrides.
+// The default locale must be built in, or share a built-in one's language.
+// A locale an override adds is based on the built-in locale with the same
+// language, or on the default locale.
+func NewRegistry(defaultTag string, overrides map[string]Overrides) (*Registry, error) {
+	if defaultTag == "" {
+		defaultTag = "en-US"
+	}
+	r := &Registry{defaultTag: defaultTag, locales: make(map[string]*Locale)}
+	for tag, l := range builtin {
+		l := l
+		l.Tag = tag
+		l.Messages = merge(builtin["en-US"].Messages, l.Messages)
+		r.locales[strings.ToLower(tag)] = &l
+	}
+	def := r.lookup(defaultTag)
+	if def == nil {
+		tags := make([]string, 0, len(builtin))
+		for tag := range builtin {
+			tags = append(tags, tag)
+		}
+		sort.Strings(tags)
+		return nil, fmt.Errorf("default_locale %q is not a built-in locale (%s)", defaultTag, strings.Join(tags, ", "))
+	}
+	defaultTag = def.Tag
+	r.defaultTag = defaultTag
+	// a new tag starts from the locale it would fall back to, so "de-AT"
+	// inherits German; take every base before adding any
+	added := make(map[string]*Locale)
+	for tag := range overrides {
+		if _, ok := r.locales[strings.ToLower(tag)]; !ok {
+			base := *r.lookup(tag)
+			base.Tag = tag
+			added[strings.ToLower(tag)] = &base
+		}
+	}
+	maps.Copy(r.locales, added)
+	for tag, o := range overrides {
+		l := r.locales[strings.ToLower(tag)]
+		l.Messages = merge(l.Messages, o.Messages)
+		l.Prompts = merge(l.Prompts, o.Prompts)
+	}
+	for _, l := range r.locales {
+		if !strings.EqualFold(l.Tag, defaultTag) && l.Language != "" {
+			l.Instruction = fmt.Sprintf("Respond in %s.", l.Language)
+		}
+	}
+	return r, nil
+}
+
+// Lookup returns the locale for tag.
+func (r *Registry) Lookup(tag string) *Locale {
+	if l := r.lookup(tag); l != nil {
+		return l
+	}
+	return r.lookup(r.defaultTag)
+}
+
+// ForEvent returns the locale requested by the event's metadata.
+func (r *Registry) ForEvent(event core.Event) *Locale {
+	tag, _ := event.GetMetadataValue(MetadataKey)
+	return r.Lookup(tag)
+}
+
+func (r *Registry) lookup(tag string) *Locale {
+	tag = strings.ToLower(strings.ReplaceAll(tag, "_", "-"))
+	if l, ok := r.locales[tag]; ok {
+		return l
+	}
+	// "de" or "de-AT" fall back to a locale with the same language,
+	// preferring the default locale
+	lang, _, _ := strings.Cut(tag, "-")
+	if l, ok := r.locales[strings.ToLower(r.defaultTag)]; ok && strings.HasPrefix(strings.ToLower(r.defaultTag), lang+"-") {
+		return l
+	}
+	keys := make([]string, 0, len(r.locales))
+	for key := range r.locales {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if strings.HasPrefix(key, lang+"-") {
+			return r.locales[key]
+		}
+	}
+	if l, ok := r.locales[strings.ToLower(r.defaultTag)]; ok {
+		return l
+	}
+	return nil
+}
+
+// Message returns a canned message, formatted with args.
+func (l *Locale) Message(key string, args ...any) string {
+	msg, ok := l.Messages[key]
+	if !ok {
+		return key
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// SystemPrompt returns the locale's prompt for agent, or base with the
+// language instruction appended.
+func (l *Locale) SystemPrompt(agent, base string) string {
+	if p, ok := l.Prompts[agent]; ok {
+		return p
+	}
+	return l.Instruct(base)
+}
+
+// Instruct returns prompt with the language instruction appended, for
+// prompts the locale's own mustn't replace.
+func (l *Locale) Instruct(prompt string) string {
+	if l.Instruction == "" {
+		return prompt
+	}
+	return prompt + " " + l.Instruction
+}
+
+// FormattingRules describes the locale's conventions for inclusion in prompts.
+func (l *Locale) FormattingRules() string {
+	sample := time.Date(2006, time.March, 14, 0, 0, 0, 0, time.UTC)
+	return fmt.Sprintf("Format dates like %q and numbers like %q.", l.FormatDate(sample), l.FormatNumber(1234567.89, 2))
+}
+
+// FormatDate formats t using the locale's date layout.
+func (l *Locale) FormatDate(t time.Time) string {
+	return t.Format(l.DateLayout)
+}
+
+// FormatNumber formats v with the locale's separators and the given number
+// of decimals.
+func (l *Locale) FormatNumber(v float64, decimals int) string {
+	neg := v < 0
+	s := strconv.FormatFloat(math.Abs(v), 'f', decimals, 64)
+	intPart, frac, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	if neg {
+		b.WriteByte('-')
+	}
+	for i, d := range intPart {
+		if i > 0 && (len(intPart)-i)%3 == 0 {
+			b.WriteString(l.GroupSep)
+		}
+		b.WriteRune(d)
+	}
+	if frac != "" {
+		b.WriteString(l.DecimalSep)
+		b.WriteString(frac)
+	}
+	return b.String()
+}
+
+func merge(base, over map[string]string) map[string]string {
+	out := make(map[string]string, len(base)+len(over))
+	for k, v := range base {
+		out[k] = v
+	}
+	for k, v := range over {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,90 @@
+package locale
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLookupFallsBack(t *testing.T) {
+	r, err := NewRegistry("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for tag, want := range map[string]string{
+		"de-DE": "de-DE",
+		"de_de": "de-DE",
+		"de-AT": "de-DE",
+		"de":    "de-DE",
+		"pt-BR": "en-US",
+		"":      "en-US",
+	} {
+		if got := r.Lookup(tag).Tag; got != want {
+			t.Errorf("Lookup(%q) = %s, want %s", tag, got, want)
+		}
+	}
+}
+
+func TestNewRegistryRejectsUnknownDefault(t *testing.T) {
+	if _, err := NewRegistry("pt-BR", nil); err == nil {
+		t.Error("a default with no built-in language was accepted")
+	}
+	r, err := NewRegistry("de-AT", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := r.Lookup("it-IT"); l.Tag != "de-DE" || l.Instruction != "" {
+		t.Errorf("unknown tags fall back to %s with instruction %q, want the German default without one", l.Tag, l.Instruction)
+	}
+}
+
+func TestOverrideAddsLocaleOfItsLanguage(t *testing.T) {
+	r, err := NewRegistry("en-US", map[string]Overrides{
+		"de-AT": {Messages: map[string]string{MsgNoInput: "Nix eingegeben."}},
+		"de-CH": {Prompts: map[string]string{"formatter": "Grüezi."}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := r.Lookup("de-AT")
+	if at.Tag != "de-AT" || at.Language != "German" || at.DecimalSep != "," {
+		t.Errorf("de-AT = %+v, want a German locale", at)
+	}
+	if at.Instruction != "Respond in German." {
+		t.Errorf("de-AT instruction = %q", at.Instruction)
+	}
+	if got := at.Message(MsgNoInput); got != "Nix eingegeben." {
+		t.Errorf("overridden message = %q", got)
+	}
+	if got := at.Message(MsgUnavailable); got != builtin["de-DE"].Messages[MsgUnavailable] {
+		t.Errorf("other messages = %q, want German", got)
+	}
+	if _, ok := at.Prompts["formatter"]; ok {
+		t.Error("de-AT picked up de-CH's prompt override")
+	}
+	if r.Lookup("de-DE").Message(MsgNoInput) == "Nix eingegeben." {
+		t.Error("the override changed the built-in locale")
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	r, err := NewRegistry("", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	de := r.Lookup("de-DE")
+	if got := de.FormatNumber(-1234567.891, 2); got != "-1.234.567,89" {
+		t.Errorf("FormatNumber = %q", got)
+	}
+	if got := r.Lookup("en-US").FormatNumber(999, 0); got != "999" {
+		t.Errorf("FormatNumber = %q", got)
+	}
+	if got := de.FormatDate(time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)); got != "05.03.2024" {
+		t.Errorf("FormatDate = %q", got)
+	}
+	if got := de.SystemPrompt("router", "Route."); got != "Route. Respond in German." {
+		t.Errorf("SystemPrompt = %q", got)
+	}
+	if got := de.Message(MsgNoData, "Wetter"); got != "keine Wetter-Daten gefunden" {
+		t.Errorf("Message = %q", got)
+	}
+}
@@ -1,40 +1,102 @@
 package main
 
 import (
+	"bufio"
+	"cmp"
 	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/kunalkushwaha/agenticgokit/core"
+	_ "github.com/kunalkushwaha/agenticgokit/plugins/memory/memory"
+	_ "github.com/kunalkushwaha/agenticgokit/plugins/orchestrator/default"
+	_ "github.com/kunalkushwaha/agenticgokit/plugins/runner/default"
+
+	"my-agents/admin"
+	"my-agents/appconfig"
+	"my-agents/audit"
+	"my-agents/billing"
+	"my-agents/blackboard"
+	"my-agents/bus"
+	"my-agents/clarify"
+	"my-agents/constraints"
+	"my-agents/conversation"
+	"my-agents/debate"
+	"my-agents/explain"
+	"my-agents/flags"
+	"my-agents/guardrail"
+	"my-agents/history"
+	"my-agents/ingest"
+	"my-agents/locale"
+	"my-agents/modelroute"
+	"my-agents/nbest"
+	"my-agents/partial"
+	"my-agents/personalize"
+	"my-agents/plan"
+	"my-agents/prefetch"
+	"my-agents/profile"
+	"my-agents/prompts"
+	"my-agents/react"
+	"my-agents/reformat"
+	"my-agents/retrieval"
+	"my-agents/schema"
+	"my-agents/scratchpad"
+	"my-agents/sink"
+	"my-agents/stream"
+	"my-agents/stylelint"
+	"my-agents/tabular"
+	"my-agents/tenant"
+	"my-agents/tools"
+	"my-agents/tot"
+	"my-agents/usage"
 )
 
 func main() {
-	cfg, err := core.LoadConfigFromWorkingDir()
+	// 🌐 Every command reads the shared remote config when one is set
+	var err error
+	remote, err = openRemoteConfig()
 	if err != nil {
-		log.Fatalf("Failed to load config: %v", err)
+		log.Fatalf("%v", err)
+	}
+	if remote != nil {
+		defaultConfigPath = remote.path
 	}
 
-	provider, err := cfg.InitializeProvider()
-	log.Printf("Provider %v", &provider)
-
-	if err != nil {
-		log.Fatalf("Failed to create LLM provider: %v", err)
+	// 🛠️ Subcommands (e.g. migrate-config) run instead of the demo pipeline
+	if len(os.Args) > 1 {
+		err := runCommand(os.Args[1], os.Args[2:])
+		remote.Close()
+		if err != nil {
+			log.Fatalf("%s: %v", os.Args[1], err)
+		}
+		return
 	}
+	defer remote.Close()
 
-	// 🤖 Create three specialized agents
-	agents := map[string]core.AgentHandler{
-		"processor": &ProcessorAgent{llm: provider},
-		"enhancer":  &EnhancerAgent{llm: provider},
-		"formatter": &FormatterAgent{llm: provider},
+	configPath := defaultConfigPath
+	if _, err := os.Stat(configPath); remote == nil && errors.Is(err, os.ErrNotExist) {
+		// 📦 A bare binary runs on the config it was built with
+		configPath, err = materializeDefaultConfig()
+		if err != nil {
+			log.Fatalf("Failed to write built-in config: %v", err)
+		}
+		defer os.Remove(configPath)
+		log.Printf("No agentflow.toml found; using the built-in default config")
 	}
 
-	// 🚀 Prefer config-driven runner (supports route/collab/seq/loop/mixed)
-	runner, err := core.NewRunnerFromConfig("agentflow.toml")
+	app, err := newApp(configPath)
 	if err != nil {
-		log.Fatalf("runner: %v", err)
+		log.Fatalf("%v", err)
 	}
+	defer app.Close()
+	runner, appCfg, agents := app.runner, app.appCfg, app.agents
 
 	// 💬 Process a message - watch the magic happen!
 	fmt.Println("🤖 Starting multi-agent collaboration...")
@@ -44,6 +106,70 @@ func main() {
 	runner.Start(ctx)
 	defer runner.Stop()
 
+	// 🔑 Rotate provider keys on SIGHUP or when a secret file changes
+	if app.keys != nil {
+		go app.keys.Run(ctx)
+	}
+
+	// 📈 Anonymized usage statistics, when opted in
+	if app.telemetry != nil {
+		go app.telemetry.Run(ctx)
+	}
+
+	// 🗂️ Compliance evidence bundle for each completed period
+	if app.compliance != nil {
+		go app.compliance.Run(ctx)
+	}
+
+	// ⚖️ Daily quality and safety scores of a sample of responses
+	if app.quality != nil {
+		go app.quality.Run(ctx)
+	}
+
+	// ♻️ Keep the knowledge base in step with its sources
+	if app.refresher != nil {
+		go app.refresher.Run(ctx)
+	}
+	if app.mover != nil {
+		go app.mover.Run(ctx)
+	}
+	if app.maintainer != nil {
+		go app.maintainer.Run(ctx)
+	}
+
+	// 🔐 Runtime operations without restarts
+	if app.admin != nil {
+		serveAdmin(ctx, app)
+	}
+
+	// 💳 Push metered usage to Stripe on a schedule
+	if appCfg.Billing.Enabled && app.usage != nil {
+		reporter, err := billing.New(appCfg.Billing, app.usage)
+		if err != nil {
+			log.Printf("Billing disabled: %v", err)
+		} else {
+			go reporter.Run(ctx)
+		}
+	}
+
+	// 🛰️ Events other processes forward to the agents this one runs
+	if app.events != nil {
+		go app.events.Run(ctx, runner)
+	}
+
+	// 📥 Events accepted by a previous process but never started
+	if app.queue != nil {
+		n, err := app.queue.Recover()
+		if err != nil {
+			log.Printf("Failed to recover queued events: %v", err)
+		} else if n > 0 {
+			log.Printf("Re-emitted %d queued events", n)
+		}
+	}
+
+	// ♻️ Pick up runs a previous process left unfinished
+	resumeRuns(ctx, app)
+
 	// Create an event for processing
 	event := core.NewEvent("processor", core.EventData{
 		"input": "Explain quantum computing in simple terms",
@@ -51,50 +177,381 @@ func main() {
 		"route": "processor",
 	})
 
-	// Emit the event to the runner
-	if err := runner.Emit(event); err != nil {
-		log.Fatalf("Failed to emit event: %v", err)
+	// Emit the event and wait for the last agent in the chain to finish. An
+	// event dropped before any agent ran never ends its run, hence the limit.
+	waitCtx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+	final, err := app.recorder.ProcessSync(waitCtx, runner, event)
+
+	// ❓ Answer clarification questions until the run finishes
+	for err == nil && final.Status == history.StatusAwaitingInput {
+		fmt.Printf("\n❓ %s\n> ", final.Question)
+		answer, readErr := bufio.NewReader(os.Stdin).ReadString('\n')
+		if readErr != nil || strings.TrimSpace(answer) == "" {
+			break
+		}
+		resume, resumeErr := history.AnswerEvent(final.Run, strings.TrimSpace(answer))
+		if resumeErr != nil {
+			log.Fatalf("Failed to resume run: %v", resumeErr)
+		}
+		final, err = app.recorder.ProcessSync(waitCtx, runner, resume)
+	}
+	if err != nil {
+		if final.Status != history.StatusFailed {
+			log.Fatalf("Failed to process event: %v", err)
+		}
+		log.Printf("%v", err)
 	}
 
-	// Wait for processing to complete
-	time.Sleep(5 * time.Second)
+	// 📋 Approve the external actions a planned run intends to take
+	if app.plans != nil {
+		if planned, err := app.plans.Get(event.GetID()); err == nil && planned.Status == plan.StatusPending {
+			fmt.Printf("\n📋 Planned actions:\n%s\nApply them? [y/N] ", plan.Describe(planned))
+			answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+			if strings.EqualFold(strings.TrimSpace(answer), "y") {
+				if _, err := app.plans.Apply(ctx, event.GetID(), nil); err != nil {
+					log.Printf("Failed to apply plan: %v", err)
+				}
+			} else if _, err := app.plans.Discard(event.GetID()); err != nil {
+				log.Printf("Failed to discard plan: %v", err)
+			}
+		}
+	}
 
 	fmt.Println("\n✅ Multi-Agent Processing Complete!")
 	fmt.Println("=" + strings.Repeat("=", 50))
 	fmt.Printf("📊 Execution Stats:\n")
 	fmt.Printf("   • Agents involved: %d\n", len(agents))
 	fmt.Printf("   • Event ID: %s\n", event.GetID())
+	if spent, ok := usage.FromResult(final.Result); ok {
+		fmt.Printf("   • LLM usage: %d calls, %d tokens (%d prompt, %d completion), $%.4f\n",
+			spent.Calls, spent.Tokens, spent.PromptTokens, spent.CompletionTokens, spent.Cost)
+	}
 }
 
+// resumeRuns continues the runs a previous process left unfinished: first
+// those the state store saved mid-pipeline, when it restores on start, then
+// those the history still has running, when [recovery] resumes on start.
+// Either way a run picks up after the last agent that completed. With an
+// event bus, a process resumes only the runs waiting on an agent it runs.
+// A run is claimed in the state store before it is resumed, so a run
+// another process is running, or has resumed, is left to it.
+func resumeRuns(ctx context.Context, app *application) {
+	local := runsLocally(app)
+	restored := make(map[string]bool)
+	if app.state != nil && app.appCfg.StateStore.RestoreOnStart {
+		ids, err := app.state.Restore(ctx, app.runner, local)
+		if err != nil {
+			log.Printf("Failed to restore saved runs: %v", err)
+		}
+		for _, id := range ids {
+			restored[id] = true
+		}
+		if len(ids) > 0 {
+			log.Printf("Restored %d runs from the state store", len(ids))
+		}
+	}
+	if !app.appCfg.Recovery.ResumeOnStart {
+		return
+	}
+	interrupted, err := history.Interrupted(ctx, app.runs)
+	if err != nil {
+		log.Printf("Failed to list interrupted runs: %v", err)
+	}
+	for _, run := range interrupted {
+		if restored[run.ID] {
+			continue
+		}
+		resume, ok := history.ResumeEvent(run)
+		if !ok || !local(resume.GetTargetAgentID()) {
+			continue
+		}
+		if claimed, err := app.state.Claim(ctx, run.ID); err != nil || !claimed {
+			if err != nil {
+				log.Printf("Failed to claim run %s: %v", run.ID, err)
+			}
+			continue
+		}
+		log.Printf("Resuming run %s at %s", run.ID, resume.GetTargetAgentID())
+		if err := app.runner.Emit(resume); err != nil {
+			log.Printf("Failed to resume run %s: %v", run.ID, err)
+		}
+	}
+}
+
+// runsLocally reports whether the process runs an agent, rather than
+// forwarding its events over the event bus.
+func runsLocally(app *application) func(agent string) bool {
+	return func(agent string) bool { return app.events == nil || app.events.Runs(agent) }
+}
+
+// serveAdmin serves the admin API, with the handlers of the enabled
+// features mounted, until ctx is done.
+func serveAdmin(ctx context.Context, app *application) {
+	server, err := admin.NewServer(app.appCfg.Admin, app.admin)
+	if err != nil {
+		log.Printf("Admin API disabled: %v", err)
+		return
+	}
+	if app.usage != nil {
+		server.Mount("GET /admin/usage", app.usage.Handler())
+		server.Mount("GET /admin/usage/runs", app.meter.RunsHandler())
+		server.Mount("GET /admin/usage/runs/{run}", app.meter.RunsHandler())
+	}
+	server.Mount("GET /admin/workflows", app.catalog.Handler(app.admin.WorkflowEnabled))
+	server.Mount("GET /admin/workflows/{route}", app.catalog.Handler(app.admin.WorkflowEnabled))
+	server.Mount("GET /admin/openapi.json", app.catalog.Handler(app.admin.WorkflowEnabled))
+	server.Mount("/admin/deployments", app.deploys.Handler())
+	server.Mount("/admin/deployments/", app.deploys.Handler())
+	server.Mount("/admin/breakpoints", app.breaks.Handler())
+	server.Mount("/admin/breakpoints/", app.breaks.Handler())
+	server.Mount("GET /admin/runs/{run}/state", history.StateHandler(app.runs))
+	server.Mount("GET /admin/runs/{run}/mutations", history.StateHandler(app.runs))
+	server.Mount("GET /admin/runs/{run}/provenance", history.StateHandler(app.runs))
+	server.Mount("GET /admin/runs/{run}/conflicts", history.StateHandler(app.runs))
+	server.Mount("POST /admin/runs/{run}/reformat", reformat.New(app.recorder, app.runner, reformat.DefaultAgent).Handler())
+	server.Mount("POST /admin/runs/{run}/rerun", app.recorder.RerunHandler(app.runner))
+	server.Mount("GET /admin/runs/{run}/stream", app.streams.Handler(app.runs))
+	if app.audit != nil {
+		server.OnAccess(audit.AccessRecorder(app.audit.Log()))
+		server.Mount("GET /admin/runs/", app.audit.Handler(app.runs))
+		server.Mount("GET /admin/tool-calls", app.audit.Handler(app.runs))
+	}
+	if app.plans != nil {
+		server.Mount("/admin/plans", app.plans.Handler())
+		server.Mount("/admin/plans/", app.plans.Handler())
+	}
+	if app.dead != nil {
+		server.Mount("/admin/dead-letters", app.dead.Handler(app.runner))
+		server.Mount("/admin/dead-letters/", app.dead.Handler(app.runner))
+	}
+	if app.drift != nil {
+		server.Mount("/admin/drift", app.drift.Handler())
+		server.Mount("/admin/drift/", app.drift.Handler())
+	}
+	server.Mount("GET /admin/agents/metrics", app.metrics.Handler())
+	if app.cache != nil {
+		server.Mount("GET /admin/llm-cache", app.cache.Handler())
+	}
+	if app.regions != nil {
+		server.Mount("GET /admin/providers/regions", app.regions.Handler())
+	}
+	if app.maintainer != nil {
+		server.Mount("/admin/maintenance", app.maintainer.Handler())
+	}
+	server.Mount("GET /admin/tools", app.container.Tools().Handler())
+	if app.quality != nil {
+		server.Mount("/admin/quality", app.quality.Handler())
+		server.Mount("/admin/quality/", app.quality.Handler())
+	}
+	if app.profiles != nil {
+		server.Mount("/admin/profiles/", app.profiles.Handler(app.runs))
+	}
+	if app.ingester != nil {
+		server.Mount("POST /admin/documents", ingest.Handler(app.ingester))
+	}
+	go func() {
+		if err := server.ListenAndServe(ctx, app.appCfg.Admin.Addr); err != nil {
+			log.Printf("Admin API stopped: %v", err)
+		}
+	}()
+}
+
+// ErrorHandlerAgent receives the runner's failure events and ends the chain,
+// so a failed agent doesn't bounce between unregistered error handlers.
+type ErrorHandlerAgent struct{}
+
+func (a *ErrorHandlerAgent) Run(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+	data := event.GetData()
+	log.Printf("❌ Agent %v failed: %v", data["failed_agent"], data["error"])
+	return core.AgentResult{OutputState: core.NewState()}, nil
+}
+
+// Built-in prompt templates; [prompts] and [agents.<name>.prompt] override
+// them.
+var (
+	processorPrompt = prompts.Source{
+		System: "You are a processor agent. Extract and organize key information from user requests.",
+		User:   "Process this request and extract key information: {{.input}}",
+	}
+	enhancerPrompt = prompts.Source{
+		System: "You are an enhancer agent. Add insights, context, and additional valuable information.",
+		User:   "Enhance this response with additional insights: {{.processed}}",
+	}
+	formatterPrompt = prompts.Source{
+		System: "You are a formatter agent. Present information in a clear, professional, and well-structured manner.",
+		User:   "Format this response in a clear, professional manner: {{.enhanced}}",
+	}
+)
+
 // ProcessorAgent handles initial processing
 type ProcessorAgent struct {
-	llm core.ModelProvider
+	generation
+	llm      core.ModelProvider
+	react    *react.Executor // set when the agent is wired with tools
+	prompt   *prompts.Template
+	clarify  bool // may pause the run to ask the caller a question
+	prefetch *prefetch.Prefetcher
+	convo    conversation.Memory // nil unless [conversation] is enabled
+	users    *profile.Store      // nil unless [profiles] is enabled
+	ground   *RetrieverAgent     // nil unless [retrieval] is enabled
+	locales  *locale.Registry
+	guard    *guardrail.Guard
 }
 
 // EnhancerAgent enhances the processed information
 type EnhancerAgent struct {
-	llm core.ModelProvider
+	generation
+	llm     core.ModelProvider
+	react   *react.Executor // set when the agent is wired with tools
+	bus     *bus.Bus
+	tot     *tot.Explorer
+	prompt  *prompts.Template
+	flags   *flags.Client
+	convo   conversation.Memory // nil unless [conversation] is enabled
+	users   *profile.Store      // nil unless [profiles] is enabled
+	locales *locale.Registry
+}
+
+// BlackboardAgent lets specialists collaborate on a shared workspace instead
+// of a fixed route, then hands the solution to the next agent.
+type BlackboardAgent struct {
+	cfg         blackboard.Config
+	specialists []blackboard.Specialist
+	ctrl        *blackboard.KeyController
+	locales     *locale.Registry
 }
 
+// RetrieverAgent grounds a request in the indexed document chunks closest
+// to it. The processor calls Ground itself; routed to as an agent of its
+// own, it leaves the chunks under "retrieved" for the next agent.
+type RetrieverAgent struct {
+	retriever *retrieval.Retriever
+	next      string
+	locales   *locale.Registry
+}
+
+// DebateAgent has two sides argue the request and a judge conclude.
+type DebateAgent struct {
+	debate  *debate.Debate
+	next    string
+	locales *locale.Registry
+}
+
+// FeedbackAgent credits a quality rating for an earlier run to the models
+// the router chose for it, and learns from it what the recipient likes.
+// Events carry "rated_run" and a 0-1 "quality", and may carry a "comment".
+type FeedbackAgent struct {
+	router   *modelroute.Router // nil unless model routing is enabled
+	profiles *profile.Store     // nil unless profiles or personalization are enabled
+	runs     history.Store
+}
+
+// maxConstraintRepairs bounds how often the formatter re-asks the model to
+// satisfy caller constraints, and to reword banned glossary terms.
+const maxConstraintRepairs = 2
+
 // FormatterAgent formats the final response
 type FormatterAgent struct {
-	llm core.ModelProvider
+	generation
+	llm     core.ModelProvider
+	bus     *bus.Bus
+	prompt  *prompts.Template
+	sinks   []sink.Sink
+	convo   conversation.Memory // nil unless [conversation] is enabled
+	locales *locale.Registry
+	guard   *guardrail.Guard
+	lint    *stylelint.Linter         // nil unless [style_lint] enables a rule
+	adapt   *personalize.Personalizer // nil unless [personalization] is enabled
+	render  appconfig.FormatterConfig
+	streams *stream.Hub
+	runs    *history.Recorder
 }
 
 func (a *ProcessorAgent) Run(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+	// The knowledge prefetched for the run is this hop's, however it ends
+	if a.prefetch != nil {
+		defer a.prefetch.Release(prefetch.RunID(event))
+	}
 	// Get user input from event data
+	loc := a.locales.ForEvent(event)
 	input, ok := event.GetData()["input"].(string)
 	if !ok {
-		return core.AgentResult{}, fmt.Errorf("no input provided")
+		return core.AgentResult{}, errors.New(loc.Message(locale.MsgNoInput))
+	}
+	if err := a.guard.For(tenant.FromEvent(event)).Check(input); err != nil {
+		return core.AgentResult{}, err
+	}
+
+	// One clarification per run: a resumed run carries the answer instead
+	question, answer, answered := clarify.Answered(event.GetData())
+	if answered {
+		input = clarify.WithAnswer(input, question, answer)
 	}
 
 	// Process with LLM
-	prompt := core.Prompt{
-		System: "You are a processor agent. Extract and organize key information from user requests.",
-		User:   fmt.Sprintf("Process this request and extract key information: %s", input),
+	vars := prompts.Vars(event, state)
+	vars["input"] = input
+	prompt, err := a.prompt.Render(vars)
+	if err != nil {
+		return core.AgentResult{}, err
+	}
+	prompt.System = localized(loc, "processor", a.prompt, prompt.System)
+	prompt.User += remembered(ctx, a.convo, event)
+	prompt.User += profiled(ctx, a.users, event)
+	if a.clarify && !answered {
+		prompt.System += " " + clarify.Instruction
+	}
+
+	// Ground the answer in indexed documents, unless a retriever ran first
+	var sources []string
+	grounding, grounded := retrieval.FromState(state)
+	if !grounded && a.ground != nil {
+		grounding = a.ground.Ground(ctx, event, input)
+	}
+	prompt.User += retrieval.Prompt(grounding)
+	for _, m := range grounding {
+		sources = append(sources, m.Source)
 	}
 
-	response, err := a.llm.Call(ctx, prompt)
+	// Pick up knowledge retrieved while the event was being routed
+	if a.prefetch != nil {
+		retrieved, err := a.prefetch.Wait(ctx, prefetch.RunID(event))
+		if err != nil {
+			return core.AgentResult{}, err
+		}
+		if retrieved != nil {
+			prompt.User += prefetchedContext(retrieved)
+			for _, k := range retrieved.Knowledge {
+				sources = append(sources, k.Source)
+			}
+		}
+	}
+
+	prompt = a.instruct(prompt)
+
+	// Agents wired with tools can call them mid-turn in a ReAct loop
+	var response core.Response
+	var pick *nbest.Pick
+	var trajectory []react.Step
+	var invocations []tools.Invocation
+	if a.react != nil {
+		runID, _ := event.GetMetadataValue(history.RunIDKey)
+		res, err := a.react.Run(bus.WithSender(ctx, runID, "processor"), prompt.System, prompt.User)
+		if err != nil {
+			return core.AgentResult{}, err
+		}
+		response.Content, trajectory, invocations = res.Answer, res.Trajectory, res.Invocations
+	} else {
+		var err error
+		if response, pick, err = a.generate(ctx, a.llm, partial.Key(event, "processor"), prompt, nil); err != nil {
+			return core.AgentResult{}, err
+		}
+	}
+	if q, ok := clarify.Parse(response.Content); ok && a.clarify && !answered {
+		return clarify.Ask(q), nil
+	}
+	response, output, err := a.conform(ctx, a.llm, prompt, response)
 	if err != nil {
 		return core.AgentResult{}, err
 	}
@@ -103,6 +560,29 @@ func (a *ProcessorAgent) Run(ctx context.Context, event core.Event, state core.S
 	outputState := core.NewState()
 	outputState.Set("processed", response.Content)
 	outputState.Set("message", response.Content)
+	if len(sources) > 0 {
+		outputState.Set(explain.SourcesKey, sources)
+	}
+	if len(grounding) > 0 {
+		outputState.Set(retrieval.Key, grounding)
+	}
+	if len(trajectory) > 0 {
+		outputState.Set(react.TrajectoryKey, trajectory)
+	}
+	if pick != nil {
+		outputState.Set(nbest.Key, *pick)
+	}
+	if output != nil {
+		outputState.Set(schema.OutputKey, output)
+	}
+	scratchpad.Carry(state, outputState)
+	if answered {
+		scratchpad.Write(outputState, "processor", fmt.Sprintf("The user clarified %q with: %s", question, answer))
+	}
+	for _, note := range reasoningNotes(trajectory) {
+		scratchpad.Write(outputState, "processor", note)
+	}
+	tools.Record(outputState, invocations...)
 
 	// Route to enhancer
 	outputState.SetMeta(core.RouteMetadataKey, "enhancer")
@@ -112,22 +592,75 @@ func (a *ProcessorAgent) Run(ctx context.Context, event core.Event, state core.S
 
 func (a *EnhancerAgent) Run(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
 	// Get processed result from state
+	loc := a.locales.ForEvent(event)
 	var processed interface{}
 	if processedData, exists := state.Get("processed"); exists {
 		processed = processedData
 	} else if msg, exists := state.Get("message"); exists {
 		processed = msg
 	} else {
-		return core.AgentResult{}, fmt.Errorf("no processed data found")
+		return core.AgentResult{}, errors.New(loc.Message(locale.MsgNoData, "processed"))
 	}
 
 	// Enhance with LLM
-	prompt := core.Prompt{
-		System: "You are an enhancer agent. Add insights, context, and additional valuable information.",
-		User:   fmt.Sprintf("Enhance this response with additional insights: %v", processed),
+	vars := prompts.Vars(event, state)
+	vars["processed"] = processed
+	prompt, err := a.prompt.Render(vars)
+	if err != nil {
+		return core.AgentResult{}, err
 	}
+	prompt.System = localized(loc, "enhancer", a.prompt, prompt.System)
+	prompt.User += remembered(ctx, a.convo, event)
+	prompt.User += profiled(ctx, a.users, event)
+	prompt.System += scratchpad.Hints(state)
+	runID, _ := event.GetMetadataValue(history.RunIDKey)
+	prompt.System += bus.Format(a.bus.Inbox(runID, "enhancer"))
+	prompt = a.instruct(prompt)
 
-	response, err := a.llm.Call(ctx, prompt)
+	// Agents wired with tools reason and call them in a ReAct loop
+	var response core.Response
+	var trajectory []react.Step
+	var branches []tot.Node
+	var notes []string
+	var pick *nbest.Pick
+	invocations := tools.Recorded(state)
+	if a.react != nil {
+		res, err := a.react.Run(bus.WithSender(ctx, runID, "enhancer"), prompt.System, prompt.User)
+		if err != nil {
+			return core.AgentResult{}, err
+		}
+		response.Content, trajectory = res.Answer, res.Trajectory
+		invocations = append(invocations, res.Invocations...)
+		notes = reasoningNotes(res.Trajectory)
+	} else if a.tot != nil && a.flags.Enabled(ctx, "tree_of_thought", flags.FromEvent(event)) {
+		// Experimental: explore several reasoning branches for hard requests
+		res, err := a.tot.Run(ctx, prompt.System, prompt.User)
+		if err != nil {
+			return core.AgentResult{}, err
+		}
+		response.Content, branches = res.Answer, res.Nodes
+		notes = []string{"Chosen line of reasoning: " + strings.Join(res.Best.Thoughts, " → ")}
+	} else {
+		var err error
+		response, pick, err = a.generate(ctx, a.llm, partial.Key(event, "enhancer"), prompt, nil)
+		if err != nil {
+			return core.AgentResult{}, err
+		}
+	}
+
+	// Optional self-critique pass, rolled out via the critic_loop flag
+	if a.flags.Enabled(ctx, "critic_loop", flags.FromEvent(event)) {
+		critique := core.Prompt{
+			System: "You are a critic. Review the draft for errors, gaps and unclear statements, then return an improved version only.",
+			User:   fmt.Sprintf("Original request: %v\n\nDraft:\n%s", processed, response.Content),
+		}
+		revised, err := a.llm.Call(ctx, critique)
+		if err != nil {
+			return core.AgentResult{}, err
+		}
+		response = revised
+	}
+	response, output, err := a.conform(ctx, a.llm, prompt, response)
 	if err != nil {
 		return core.AgentResult{}, err
 	}
@@ -136,6 +669,23 @@ func (a *EnhancerAgent) Run(ctx context.Context, event core.Event, state core.St
 	outputState := core.NewState()
 	outputState.Set("enhanced", response.Content)
 	outputState.Set("message", response.Content)
+	if len(trajectory) > 0 {
+		outputState.Set(react.TrajectoryKey, trajectory)
+	}
+	if len(branches) > 0 {
+		outputState.Set(tot.BranchesKey, branches)
+	}
+	if pick != nil {
+		outputState.Set(nbest.Key, *pick)
+	}
+	if output != nil {
+		outputState.Set(schema.OutputKey, output)
+	}
+	scratchpad.Carry(state, outputState)
+	for _, note := range notes {
+		scratchpad.Write(outputState, "enhancer", note)
+	}
+	tools.Record(outputState, invocations...)
 
 	// Route to formatter
 	outputState.SetMeta(core.RouteMetadataKey, "formatter")
@@ -143,35 +693,578 @@ func (a *EnhancerAgent) Run(ctx context.Context, event core.Event, state core.St
 	return core.AgentResult{OutputState: outputState}, nil
 }
 
-func (a *FormatterAgent) Run(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+func (a *BlackboardAgent) Run(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+	seed := make(map[string]string)
+	for _, key := range []string{"input", "processed"} {
+		if v, ok := state.Get(key); ok {
+			seed[key] = fmt.Sprint(v)
+		}
+	}
+	if len(seed) == 0 {
+		return core.AgentResult{}, errors.New(a.locales.ForEvent(event).Message(locale.MsgNoInput))
+	}
+	if _, ok := seed["input"]; !ok {
+		seed["input"] = seed["processed"]
+	}
+
+	runID, _ := event.GetMetadataValue(history.RunIDKey)
+	if runID == "" {
+		runID = event.GetID()
+	}
+	board := blackboard.NewBoard(runID, seed)
+	contributions, err := blackboard.Run(ctx, board, a.specialists, a.ctrl, a.cfg.MaxCycles)
+	if err != nil {
+		return core.AgentResult{}, err
+	}
+	solution, _ := board.Get(a.ctrl.SolutionKey)
+
+	outputState := core.NewState()
+	outputState.Set("enhanced", solution)
+	outputState.Set("message", solution)
+	outputState.Set("blackboard", board.Snapshot())
+	outputState.Set("blackboard_contributions", contributions)
+	scratchpad.Carry(state, outputState)
+
+	outputState.SetMeta(core.RouteMetadataKey, cmp.Or(a.cfg.Next, "formatter"))
+	return core.AgentResult{OutputState: outputState}, nil
+}
+
+func (a *RetrieverAgent) Run(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+	input, ok := event.GetData()["input"].(string)
+	if !ok {
+		return core.AgentResult{}, errors.New(a.locales.ForEvent(event).Message(locale.MsgNoInput))
+	}
+	outputState := core.NewState()
+	outputState.Set(retrieval.Key, a.Ground(ctx, event, input))
+	scratchpad.Carry(state, outputState)
+	outputState.SetMeta(core.RouteMetadataKey, a.next)
+	return core.AgentResult{OutputState: outputState}, nil
+}
+
+// Ground returns the chunks retrieved for query, searching the way the
+// event's workflow is configured to, in the collections and with the filter
+// its metadata names if any, for the tenant the HTTP API authenticated the
+// caller as (events from elsewhere are the operator's). A failed retrieval is logged and grounds nothing, so a store
+// outage costs the answer its citations rather than failing the run.
+func (a *RetrieverAgent) Ground(ctx context.Context, event core.Event, query string) []retrieval.Match {
+	workflow, _ := event.GetMetadataValue(usage.WorkflowKey)
+	if workflow == "" {
+		workflow, _ = event.GetMetadataValue(core.RouteMetadataKey)
+	}
+	req := retrieval.Request{Query: query, Workflow: workflow, Tenant: tenant.FromEvent(event)}
+	if collections, ok := event.GetMetadataValue(retrieval.CollectionsKey); ok {
+		req.Collections = retrieval.ParseCollections(collections)
+	}
+	if filter, ok := event.GetMetadataValue(retrieval.FilterKey); ok {
+		var err error
+		if req.Filter, err = retrieval.ParseFilter(filter); err != nil {
+			// Searching without it could ground the answer in chunks the
+			// caller ruled out
+			core.Logger().Warn().Err(err).Msg("Failed to parse the request's retrieval filter")
+			return nil
+		}
+	}
+	matches, err := a.retriever.RetrieveRequest(ctx, req)
+	if err != nil {
+		core.Logger().Warn().Err(err).Msg("Failed to retrieve document chunks")
+		return nil
+	}
+	return matches
+}
+
+func (a *DebateAgent) Run(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+	question, ok := state.Get("input")
+	if !ok {
+		if question, ok = state.Get("processed"); !ok {
+			return core.AgentResult{}, errors.New(a.locales.ForEvent(event).Message(locale.MsgNoInput))
+		}
+	}
+
+	res, err := a.debate.Run(ctx, fmt.Sprint(question))
+	if err != nil {
+		return core.AgentResult{}, err
+	}
+
+	outputState := core.NewState()
+	outputState.Set("enhanced", res.Verdict)
+	outputState.Set("message", res.Verdict)
+	outputState.Set(debate.TranscriptKey, res.Turns)
+	scratchpad.Carry(state, outputState)
+
+	outputState.SetMeta(core.RouteMetadataKey, a.next)
+	return core.AgentResult{OutputState: outputState}, nil
+}
+
+func (a *FeedbackAgent) Run(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+	runID, _ := state.Get("rated_run")
+	raw, _ := state.Get("quality")
+	quality, err := strconv.ParseFloat(fmt.Sprint(raw), 64)
+	if runID == nil || err != nil {
+		return core.AgentResult{}, errors.New(`feedback needs "rated_run" and a numeric "quality"`)
+	}
+	message := "Feedback recorded."
+	if a.router != nil && !a.router.Feedback(fmt.Sprint(runID), quality) {
+		message = "No routed calls to rate for that run."
+	}
+	if user := profile.FromEvent(event); a.profiles != nil && user != "" {
+		comment, _ := state.Get("comment")
+		fb := profile.Feedback{RunID: fmt.Sprint(runID), Rating: &quality}
+		if comment != nil {
+			fb.Comment = fmt.Sprint(comment)
+		}
+		if _, err := a.profiles.LearnFromRun(ctx, a.runs, user, fb); err != nil {
+			return core.AgentResult{}, fmt.Errorf("failed to learn from feedback: %w", err)
+		}
+	}
+	outputState := core.NewState()
+	outputState.Set("final_response", message)
+	return core.AgentResult{OutputState: outputState}, nil
+}
+
+func (a *FormatterAgent) Run(ctx context.Context, event core.Event, state core.State) (result core.AgentResult, err error) {
 	// Get enhanced result from state
+	loc := a.locales.ForEvent(event)
 	var enhanced interface{}
 	if enhancedData, exists := state.Get("enhanced"); exists {
 		enhanced = enhancedData
 	} else if msg, exists := state.Get("message"); exists {
 		enhanced = msg
 	} else {
-		return core.AgentResult{}, fmt.Errorf("no enhanced data found")
+		return core.AgentResult{}, errors.New(loc.Message(locale.MsgNoData, "enhanced"))
 	}
 
-	// Format with LLM
-	prompt := core.Prompt{
-		System: "You are a formatter agent. Present information in a clear, professional, and well-structured manner.",
-		User:   fmt.Sprintf("Format this response in a clear, professional manner: %v", enhanced),
+	// Format with LLM, following the caller's locale conventions and constraints
+	limits := constraints.FromEvent(event)
+	vars := prompts.Vars(event, state)
+	vars["enhanced"] = enhanced
+	prompt, err := a.prompt.Render(vars)
+	if err != nil {
+		return core.AgentResult{}, err
+	}
+	prompt.System = localized(loc, "formatter", a.prompt, prompt.System) + " " + loc.FormattingRules()
+	prompt.User += remembered(ctx, a.convo, event)
+	if !limits.Empty() {
+		prompt.System += " " + limits.Instructions()
+	}
+	if len(tabular.Detect(fmt.Sprint(enhanced))) > 0 {
+		prompt.System += " Keep tabular data as Markdown tables."
+	}
+	lexicon := a.guard.For(tenant.FromEvent(event))
+	if style := lexicon.StyleInstructions(); style != "" {
+		prompt.System += " " + style
 	}
+	prompt.System += scratchpad.Hints(state)
+	runID, _ := event.GetMetadataValue(history.RunIDKey)
+	prompt.System += bus.Format(a.bus.Inbox(runID, "formatter"))
 
-	response, err := a.llm.Call(ctx, prompt)
+	sessionID, _ := event.GetMetadataValue(core.SessionIDKey)
+
+	// Stream the response as it is generated, filtered as the final one is
+	var onToken func(string)
+	var streamed *stream.Writer
+	if emit := a.streamTo(ctx, runID, sessionID); emit != nil {
+		streamed = stream.NewWriter(func(text string) (string, error) {
+			return lexicon.Apply(scratchpad.Redact(text, state))
+		}, emit)
+		onToken = streamed.Write
+		defer func() {
+			done := stream.Chunk{RunID: runID, Agent: "formatter", Done: true}
+			if err != nil {
+				done.Error = err.Error()
+			} else if final, ok := result.OutputState.Get("final_response"); ok {
+				done.Final, _ = final.(string)
+			}
+			a.streams.Publish(done)
+		}()
+	}
+	response, pick, err := a.generate(ctx, a.llm, partial.Key(event, "formatter"), prompt, onToken)
+	if streamed != nil {
+		// A word list rejecting the text stops the stream; the final
+		// response is checked again below
+		streamed.Flush()
+	}
 	if err != nil {
+		// Tell the user something went wrong in their language
+		for _, s := range a.sinks {
+			_ = s.Write(ctx, sessionID, loc.Message(locale.MsgUnavailable))
+		}
 		return core.AgentResult{}, err
 	}
 
+	// Adapt the formatted response to the recipient's stored preferences;
+	// the request's own constraints, checked next, still take precedence
+	var adapted profile.Preferences
+	if a.adapt != nil {
+		response.Content, adapted = a.adapt.Adapt(ctx, profile.FromEvent(event), response.Content)
+	}
+
+	// Verify the requested constraints and re-ask when they're violated
+	meetConstraints := func() ([]constraints.Violation, error) {
+		violations := limits.Validate(response.Content)
+		for attempt := 0; len(violations) > 0 && attempt < maxConstraintRepairs; attempt++ {
+			if response, err = a.llm.Call(ctx, limits.RepairPrompt(response.Content, violations)); err != nil {
+				return nil, err
+			}
+			violations = limits.Validate(response.Content)
+		}
+		return violations, nil
+	}
+	violations, err := meetConstraints()
+	if err != nil {
+		return core.AgentResult{}, err
+	}
+
+	// Reword banned glossary terms; Apply below corrects the other terms
+	banned := lexicon.Banned(response.Content)
+	reworded := len(banned) > 0
+	for attempt := 0; len(banned) > 0 && attempt < maxConstraintRepairs; attempt++ {
+		response, err = a.llm.Call(ctx, lexicon.RepairPrompt(response.Content, banned))
+		if err != nil {
+			return core.AgentResult{}, err
+		}
+		banned = lexicon.Banned(response.Content)
+	}
+	if reworded {
+		// The rewording may have broken a constraint the response met
+		if violations, err = meetConstraints(); err != nil {
+			return core.AgentResult{}, err
+		}
+	}
+
+	// Hold the response to the style guide, rewriting it in fix mode. The
+	// request's constraints and the glossary outrank it, so a rewrite that
+	// breaks more of them than the response did is dropped
+	var style []stylelint.Violation
+	if a.lint != nil {
+		var linted string
+		linted, style = a.lint.Check(ctx, response.Content)
+		if linted != response.Content {
+			lintViolations, lintBanned := limits.Validate(linted), lexicon.Banned(linted)
+			if len(lintViolations) <= len(violations) && len(lintBanned) <= len(banned) {
+				response.Content, violations, banned = linted, lintViolations, lintBanned
+			} else {
+				style = a.lint.Lint(response.Content)
+			}
+		}
+	}
+
+	// Pull tables out of the answer for UIs, optionally embedding chart specs
+	tables := tabular.Detect(response.Content)
+	var charts []json.RawMessage
+	for _, t := range tables {
+		if spec, ok := t.Chart(tabular.Numbers{Decimal: loc.DecimalSep, Group: loc.GroupSep}); ok {
+			charts = append(charts, spec)
+			if a.render.EmbedCharts {
+				response.Content += "\n\n```vega-lite\n" + string(spec) + "\n```"
+			}
+		}
+	}
+
+	// Show what the tools returned, when enabled for this request
+	if a.showToolResults(event) {
+		if rendered := tools.RenderMarkdown(tools.Recorded(state), a.render.ExpandToolResults); rendered != "" {
+			response.Content += "\n\n" + rendered
+		}
+	}
+
+	// Explain how the answer was produced, from what the run recorded
+	if a.showWork(event) {
+		run, _ := a.runs.Current(runID)
+		if rendered := explain.FromRun(run, "formatter", state).Markdown(); rendered != "" {
+			response.Content += "\n\n" + rendered
+		}
+	}
+
+	// Apply brand style and word lists to what the user will see; the
+	// scratchpad stays internal even if the model echoed it
+	final, err := lexicon.Apply(scratchpad.Redact(response.Content, state))
+	if err != nil {
+		return core.AgentResult{}, err
+	}
+	response.Content = final
+
 	// Update state with final result
 	outputState := core.NewState()
+	if len(violations) > 0 {
+		unmet := make([]string, len(violations))
+		for i, v := range violations {
+			unmet[i] = v.String()
+		}
+		outputState.Set("constraint_violations", unmet)
+	}
+	if len(banned) > 0 {
+		outputState.Set("glossary_violations", banned)
+	}
+	if len(style) > 0 {
+		flagged := make([]string, len(style))
+		for i, v := range style {
+			flagged[i] = v.String()
+		}
+		outputState.Set(stylelint.Key, flagged)
+	}
+	if !adapted.Empty() {
+		outputState.Set(personalize.Key, adapted)
+	}
 	outputState.Set("final_response", response.Content)
+	if len(tables) > 0 {
+		outputState.Set("tables", tables)
+		outputState.Set("charts", charts)
+	}
+	if pick != nil {
+		outputState.Set(nbest.Key, *pick)
+	}
 	outputState.Set("message", response.Content)
 
-	// Print the final result
-	fmt.Printf("\n📝 Final Response:\n%s\n", response.Content)
+	// Deliver the final result to the configured sinks
+	for _, s := range a.sinks {
+		if err := s.Write(ctx, sessionID, response.Content); err != nil {
+			return core.AgentResult{}, err
+		}
+	}
 
 	return core.AgentResult{OutputState: outputState}, nil
 }
+
+// streamTo returns where the response streams to: the run's subscribers
+// and, with [formatter] stream, the sinks that can show it. It returns nil
+// when nothing would receive it.
+func (a *FormatterAgent) streamTo(ctx context.Context, runID, sessionID string) func(string) {
+	var streamers []sink.Streamer
+	if a.render.Stream {
+		for _, s := range a.sinks {
+			// Sinks behind plan or policy middleware aren't streamers, so
+			// held or blocked deliveries don't leak through the stream
+			if st, ok := s.(sink.Streamer); ok {
+				streamers = append(streamers, st)
+			}
+		}
+	}
+	subscribed := a.streams.Subscribed(runID)
+	if len(streamers) == 0 && !subscribed {
+		return nil
+	}
+	return func(text string) {
+		if subscribed {
+			a.streams.Publish(stream.Chunk{RunID: runID, Agent: "formatter", Content: text})
+		}
+		for _, st := range streamers {
+			if err := st.WriteChunk(ctx, sessionID, text); err != nil {
+				core.Logger().Warn().Err(err).Msg("Failed to stream response chunk")
+			}
+		}
+	}
+}
+
+// generation is how an agent produces its main completion.
+type generation struct {
+	gen    *partial.Generator
+	best   *nbest.Selector // nil unless the agent generates N-best
+	output *schema.Schema  // nil unless the agent declares an output_schema
+}
+
+// generate produces the completion: the best of several candidates when
+// best is set, otherwise one checkpointed by gen. onToken, when not nil,
+// gets the completion as it streams, or the chosen candidate whole. The
+// pick is nil unless best is set.
+func (g generation) generate(ctx context.Context, llm core.ModelProvider, key string, prompt core.Prompt, onToken func(string)) (core.Response, *nbest.Pick, error) {
+	if g.best == nil {
+		response, err := g.gen.GenerateStream(ctx, llm, key, prompt, onToken)
+		return response, nil, err
+	}
+	response, pick, err := g.best.Generate(ctx, llm, prompt)
+	if err != nil {
+		return core.Response{}, nil, err
+	}
+	if onToken != nil {
+		onToken(response.Content)
+	}
+	return response, &pick, nil
+}
+
+// instruct adds the output schema's format instructions to prompt.
+func (g generation) instruct(prompt core.Prompt) core.Prompt {
+	if g.output == nil {
+		return prompt
+	}
+	return schema.StructuredPrompt{Prompt: prompt, Schema: g.output}.Instructed()
+}
+
+// conform checks the agent's answer to the instructed prompt against the
+// output schema, having llm repair it if needed, and returns it reduced to
+// the JSON with the decoded value. Without a schema it returns response and
+// a nil value.
+func (g generation) conform(ctx context.Context, llm core.ModelProvider, prompt core.Prompt, response core.Response) (core.Response, any, error) {
+	if g.output == nil {
+		return response, nil, nil
+	}
+	var value any
+	response, err := schema.StructuredPrompt{Prompt: prompt, Schema: g.output}.Check(ctx, llm, response, &value)
+	return response, value, err
+}
+
+// remembered renders what the event's session remembers for a prompt. A
+// failed recall is logged and the request goes on without it.
+func remembered(ctx context.Context, memory conversation.Memory, event core.Event) string {
+	session := conversation.SessionOf(event)
+	if memory == nil || session.ID == "" {
+		return ""
+	}
+	recalled, err := memory.Recall(ctx, session)
+	if err != nil {
+		core.Logger().Warn().Str("session_id", session.ID).Err(err).Msg("Failed to recall conversation")
+		return ""
+	}
+	return recalled.Prompt()
+}
+
+// profiled renders what the profile store knows about the event's recipient
+// for a prompt; it is empty when users is nil or knows nothing.
+func profiled(ctx context.Context, users *profile.Store, event core.Event) string {
+	userID := profile.FromEvent(event)
+	if users == nil || userID == "" {
+		return ""
+	}
+	p, err := users.Get(ctx, userID)
+	if err != nil {
+		core.Logger().Warn().Str("user_id", userID).Err(err).Msg("Failed to load profile")
+		return ""
+	}
+	return p.Prompt()
+}
+
+// prefetchedContext renders retrieved knowledge for a prompt.
+func prefetchedContext(retrieved *prefetch.Context) string {
+	var b strings.Builder
+	if len(retrieved.Knowledge) > 0 {
+		b.WriteString("\n\nRelevant knowledge:\n")
+		for _, k := range retrieved.Knowledge {
+			fmt.Fprintf(&b, "- %s (source: %s)\n", k.Content, k.Source)
+		}
+	}
+	return b.String()
+}
+
+// defaultConfigPath is every command's -config default: agentflow.toml, or
+// the copy of AGENTFLOW_CONFIG_URL fetched at startup.
+var defaultConfigPath = "agentflow.toml"
+
+// remote is the config fetched from AGENTFLOW_CONFIG_URL; nil when the
+// config is a local file.
+var remote *remoteConfig
+
+// remoteConfig is a local copy of a remote config, for the path-based
+// loaders to read.
+type remoteConfig struct {
+	src     appconfig.Source
+	path    string
+	version string
+}
+
+// openRemoteConfig fetches AGENTFLOW_CONFIG_URL. It returns nil when the
+// variable isn't set.
+func openRemoteConfig() (*remoteConfig, error) {
+	configURL := os.Getenv("AGENTFLOW_CONFIG_URL")
+	if configURL == "" {
+		return nil, nil
+	}
+	src, err := appconfig.OpenSource(configURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open remote config: %w", err)
+	}
+	path, version, err := appconfig.Materialize(context.Background(), src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch remote config: %w", err)
+	}
+	return &remoteConfig{src: src, path: path, version: version}, nil
+}
+
+// replace swaps the local copy for data, which the source reported as
+// version.
+func (r *remoteConfig) replace(data []byte, version string) error {
+	tmp := r.path + ".new"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, r.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	r.version = version
+	return nil
+}
+
+// Close removes the local copy. It does nothing on a nil remoteConfig.
+func (r *remoteConfig) Close() {
+	if r != nil {
+		os.Remove(r.path)
+	}
+}
+
+// configPollInterval reads AGENTFLOW_CONFIG_POLL (a Go duration), defaulting to 30s.
+func configPollInterval() time.Duration {
+	if d, err := time.ParseDuration(os.Getenv("AGENTFLOW_CONFIG_POLL")); err == nil && d > 0 {
+		return d
+	}
+	return 30 * time.Second
+}
+
+// showToolResults reports whether tool sections are rendered for event. The
+// "show_tools" metadata key overrides the configured default.
+func (a *FormatterAgent) showToolResults(event core.Event) bool {
+	if v, ok := event.GetMetadataValue("show_tools"); ok {
+		return v == "true"
+	}
+	return a.render.ShowToolResults
+}
+
+// showWork reports whether the explanation of how the answer was produced
+// is appended for event. The "show_work" metadata key overrides the
+// configured default.
+func (a *FormatterAgent) showWork(event core.Event) bool {
+	if v, ok := event.GetMetadataValue("show_work"); ok {
+		return v == "true"
+	}
+	return a.render.ShowWork
+}
+
+// defaultConfig is the agentflow.toml the binary was built with.
+//
+//go:embed agentflow.toml
+var defaultConfig []byte
+
+// materializeDefaultConfig writes defaultConfig to a temporary file, since
+// the config loaders read from a path.
+func materializeDefaultConfig() (string, error) {
+	f, err := os.CreateTemp("", "agentflow-*.toml")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(defaultConfig); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// localized is agent's system prompt for loc: the locale's own prompt for
+// the agent unless the operator configured a template, which wins and only
+// gets the language instruction.
+func localized(loc *locale.Locale, agent string, t *prompts.Template, system string) string {
+	if t.Configured() {
+		return loc.Instruct(system)
+	}
+	return loc.SystemPrompt(agent, system)
+}
+
+// reasoningNotes keeps a ReAct run's thoughts for later agents.
+func reasoningNotes(steps []react.Step) []string {
+	var notes []string
+	for _, step := range steps {
+		if step.Thought != "" {
+			notes = append(notes, step.Thought)
+		}
+	}
+	return notes
+}
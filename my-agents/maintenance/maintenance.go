@@ -0,0 +1,212 @@
+// Package maintenance runs the housekeeping jobs that keep the stores
+// small and fast on a schedule — vacuuming the SQLite databases, rebuilding
+// their indexes, compacting caches and deleting cold storage objects
+// nothing refers to — and keeps metrics of what each run reclaimed.
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Jobs.
+const (
+	JobVacuum  = "vacuum"
+	JobReindex = "reindex"
+	JobCaches  = "compact_caches"
+	JobOrphans = "orphans"
+)
+
+// ErrUnknownJob is returned for a job name the scheduler doesn't run.
+var ErrUnknownJob = errors.New("no such maintenance job")
+
+// DefaultInterval is how often the jobs run by default.
+const DefaultInterval = 24 * time.Hour
+
+// Config is the [maintenance] section of agentflow.toml:
+//
+//	[maintenance]
+//	enabled = true
+//	interval = "24h"
+//	jobs = ["vacuum", "orphans"]
+type Config struct {
+	Enabled  bool   `toml:"enabled"`
+	Interval string `toml:"interval"` // default DefaultInterval
+	// Jobs are the jobs to run (default all of them).
+	Jobs []string `toml:"jobs"`
+}
+
+// EveryInterval is how often the jobs run.
+func (cfg Config) EveryInterval() time.Duration {
+	if d, err := time.ParseDuration(cfg.Interval); err == nil && d > 0 {
+		return d
+	}
+	return DefaultInterval
+}
+
+// Result is what one run of a job reclaimed.
+type Result struct {
+	Removed int   `json:"removed"` // records or objects dropped
+	Bytes   int64 `json:"bytes"`   // space reclaimed
+}
+
+// Job is a maintenance task.
+type Job func(ctx context.Context) (Result, error)
+
+// Stats are a job's metrics since the process started.
+type Stats struct {
+	Runs         int       `json:"runs"`
+	Failures     int       `json:"failures"`
+	Removed      int       `json:"removed"`
+	Bytes        int64     `json:"bytes"`
+	LastRun      time.Time `json:"last_run,omitzero"`
+	LastDuration string    `json:"last_duration,omitempty"`
+	LastResult   Result    `json:"last_result"`
+	LastError    string    `json:"last_error,omitempty"`
+}
+
+// Scheduler runs its jobs in turn every interval.
+type Scheduler struct {
+	interval time.Duration
+	only     []string
+
+	run   sync.Mutex // one pass at a time
+	mu    sync.Mutex
+	jobs  []job
+	stats map[string]*Stats
+}
+
+type job struct {
+	name string
+	run  Job
+}
+
+// New creates a scheduler running the jobs cfg selects.
+func New(cfg Config) *Scheduler {
+	return &Scheduler{interval: cfg.EveryInterval(), only: cfg.Jobs, stats: make(map[string]*Stats)}
+}
+
+// Add schedules j under name, unless the config leaves the job out.
+func (s *Scheduler) Add(name string, j Job) {
+	if len(s.only) > 0 && !slices.Contains(s.only, name) {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, job{name: name, run: j})
+	s.stats[name] = &Stats{}
+}
+
+// RunNow runs the named jobs, or all of them, and returns what each
+// reclaimed. A failing job doesn't stop the others; the first error is
+// returned.
+func (s *Scheduler) RunNow(ctx context.Context, names ...string) (map[string]Result, error) {
+	s.run.Lock()
+	defer s.run.Unlock()
+	s.mu.Lock()
+	jobs := slices.Clone(s.jobs)
+	s.mu.Unlock()
+	for _, name := range names {
+		if !slices.ContainsFunc(jobs, func(j job) bool { return j.name == name }) {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownJob, name)
+		}
+	}
+
+	results := make(map[string]Result, len(jobs))
+	var first error
+	for _, j := range jobs {
+		if len(names) > 0 && !slices.Contains(names, j.name) {
+			continue
+		}
+		start := time.Now()
+		res, err := j.run(ctx)
+		results[j.name] = res
+		s.observe(j.name, start, res, err)
+		if err != nil && first == nil {
+			first = fmt.Errorf("%s: %w", j.name, err)
+		}
+	}
+	return results, first
+}
+
+func (s *Scheduler) observe(name string, start time.Time, res Result, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.stats[name]
+	st.Runs++
+	st.Removed += res.Removed
+	st.Bytes += res.Bytes
+	st.LastRun, st.LastDuration, st.LastResult, st.LastError = start, time.Since(start).Round(time.Millisecond).String(), res, ""
+	if err != nil {
+		st.Failures++
+		st.LastError = err.Error()
+	}
+}
+
+// Snapshot returns each job's stats.
+func (s *Scheduler) Snapshot() map[string]Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]Stats, len(s.stats))
+	for name, st := range s.stats {
+		out[name] = *st
+	}
+	return out
+}
+
+// Run runs the jobs every interval until ctx is cancelled.
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		results, err := s.RunNow(ctx)
+		for name, res := range results {
+			if res.Removed > 0 || res.Bytes > 0 {
+				core.Logger().Info().Str("job", name).Int("removed", res.Removed).Int("bytes", int(res.Bytes)).Msg("Maintenance reclaimed space")
+			}
+		}
+		if err != nil && ctx.Err() == nil {
+			core.Logger().Error().Err(err).Msg("Maintenance job failed")
+		}
+	}
+}
+
+// Handler serves the jobs' stats on GET and runs them on POST, all or
+// those named in ?job=.
+func (s *Scheduler) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+		case http.MethodPost:
+			if _, err := s.RunNow(r.Context(), r.URL.Query()["job"]...); err != nil {
+				status := http.StatusInternalServerError
+				if errors.Is(err, ErrUnknownJob) {
+					status = http.StatusBadRequest
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(status)
+				json.NewEncoder(w).Encode(map[string]any{"error": err.Error(), "jobs": s.Snapshot()})
+				return
+			}
+		default:
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"jobs": s.Snapshot()})
+	})
+}
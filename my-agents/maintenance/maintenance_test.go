@@ -0,0 +1,112 @@
+package maintenance
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// reclaim is a job reclaiming res each run, or failing with err.
+func reclaim(res Result, err error) Job {
+	return func(context.Context) (Result, error) { return res, err }
+}
+
+func TestConfig(t *testing.T) {
+	for interval, want := range map[string]time.Duration{"": DefaultInterval, "6h": 6 * time.Hour, "-1h": DefaultInterval, "daily": DefaultInterval} {
+		if got := (Config{Interval: interval}).EveryInterval(); got != want {
+			t.Errorf("EveryInterval of %q = %s", interval, got)
+		}
+	}
+}
+
+func TestRunNow(t *testing.T) {
+	s := New(Config{Jobs: []string{JobVacuum, JobOrphans, JobCaches}})
+	s.Add(JobVacuum, reclaim(Result{Bytes: 4096}, nil))
+	s.Add(JobReindex, reclaim(Result{}, nil))
+	s.Add(JobOrphans, reclaim(Result{Removed: 3, Bytes: 100}, nil))
+	s.Add(JobCaches, reclaim(Result{}, errors.New("redis down")))
+
+	results, err := s.RunNow(context.Background())
+	if err == nil || err.Error() != "compact_caches: redis down" {
+		t.Errorf("err = %v", err)
+	}
+	if len(results) != 3 || results[JobVacuum].Bytes != 4096 || results[JobOrphans].Removed != 3 {
+		t.Errorf("results = %+v", results)
+	}
+	s.RunNow(context.Background(), JobOrphans)
+	stats := s.Snapshot()
+	if _, ok := stats[JobReindex]; ok {
+		t.Error("a job left out of the config ran")
+	}
+	if st := stats[JobOrphans]; st.Runs != 2 || st.Removed != 6 || st.Bytes != 200 || st.LastRun.IsZero() {
+		t.Errorf("orphans stats = %+v", st)
+	}
+	if st := stats[JobCaches]; st.Runs != 1 || st.Failures != 1 || st.LastError != "redis down" {
+		t.Errorf("caches stats = %+v", st)
+	}
+	if _, err := s.RunNow(context.Background(), JobReindex); !errors.Is(err, ErrUnknownJob) {
+		t.Errorf("unknown job: %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	s := New(Config{Interval: "10ms"})
+	ran := make(chan struct{}, 10)
+	s.Add(JobVacuum, func(context.Context) (Result, error) {
+		ran <- struct{}{}
+		return Result{Bytes: 1}, nil
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		s.Run(ctx)
+		close(done)
+	}()
+	for i := 0; i < 2; i++ {
+		select {
+		case <-ran:
+		case <-time.After(time.Second):
+			t.Fatal("job didn't run")
+		}
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Run didn't return")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s := New(Config{})
+	s.Add(JobVacuum, reclaim(Result{Bytes: 4096}, nil))
+	s.Add(JobOrphans, reclaim(Result{}, errors.New("bucket gone")))
+	h := s.Handler()
+	serve := func(method, target string) (*httptest.ResponseRecorder, map[string]Stats) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+		var body struct{ Jobs map[string]Stats }
+		json.Unmarshal(rec.Body.Bytes(), &body)
+		return rec, body.Jobs
+	}
+
+	if rec, jobs := serve("GET", "/admin/maintenance"); rec.Code != http.StatusOK || len(jobs) != 2 || jobs[JobVacuum].Runs != 0 {
+		t.Errorf("GET = %d %s", rec.Code, rec.Body)
+	}
+	if rec, jobs := serve("POST", "/admin/maintenance?job=vacuum"); rec.Code != http.StatusOK || jobs[JobVacuum].Bytes != 4096 || jobs[JobOrphans].Runs != 0 {
+		t.Errorf("POST of a job = %d %s", rec.Code, rec.Body)
+	}
+	if rec, jobs := serve("POST", "/admin/maintenance"); rec.Code != http.StatusInternalServerError || jobs[JobOrphans].Failures != 1 {
+		t.Errorf("POST with a failing job = %d %s", rec.Code, rec.Body)
+	}
+	if rec, _ := serve("POST", "/admin/maintenance?job=defrag"); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST of an unknown job = %d", rec.Code)
+	}
+	if rec, _ := serve("DELETE", "/admin/maintenance"); rec.Code != http.StatusMethodNotAllowed || rec.Header().Get("Allow") != "GET, POST" {
+		t.Errorf("DELETE = %d", rec.Code)
+	}
+}
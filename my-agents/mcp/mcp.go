@@ -0,0 +1,308 @@
+// Package mcp is a Model Context Protocol client: it connects to the
+// servers in [mcp_client.servers], discovers the tools they expose at
+// startup and registers them in the tool registry, so agents list them in
+// their tools like the built-in ones.
+package mcp
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/tools"
+)
+
+// ProtocolVersion is the MCP revision the client speaks.
+const ProtocolVersion = "2025-06-18"
+
+// DefaultTimeout bounds each request to a server when Config.Timeout is
+// empty.
+const DefaultTimeout = 30 * time.Second
+
+// Config is the [mcp_client] section of agentflow.toml (the [mcp] section
+// belongs to agenticgokit's own MCP plugin):
+//
+//	[mcp_client]
+//	timeout = "30s"
+//	[mcp_client.servers.files]
+//	command = "npx"
+//	args = ["-y", "@modelcontextprotocol/server-filesystem", "./workspace"]
+//	[mcp_client.servers.search]
+//	url = "https://mcp.example.com/mcp"
+//	headers = { Authorization = "Bearer ${SEARCH_TOKEN}" }
+//	tools = ["web_search"]
+type Config struct {
+	Servers map[string]ServerConfig `toml:"servers"`
+	Timeout string                  `toml:"timeout"` // per request (default 30s)
+}
+
+// ServerConfig is one [mcp_client.servers.<name>] table. Set command to
+// launch a local server over stdio, or url to reach one over streamable
+// HTTP.
+type ServerConfig struct {
+	Command string            `toml:"command"`
+	Args    []string          `toml:"args"`
+	Env     map[string]string `toml:"env"` // added to this process's environment; ${VAR} is expanded
+
+	URL     string            `toml:"url"`
+	Headers map[string]string `toml:"headers"` // ${VAR} is expanded
+
+	// Prefix is put before the server's tool names when registering them
+	// (default "<name>_"); set it to "" to keep the names as they are,
+	// as long as none is a tool's already registered.
+	Prefix *string `toml:"prefix"`
+	// Tools registers only the listed tools, by their name on the server.
+	Tools []string `toml:"tools"`
+}
+
+func (c Config) timeout() (time.Duration, error) {
+	if c.Timeout == "" {
+		return DefaultTimeout, nil
+	}
+	d, err := time.ParseDuration(c.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("mcp: timeout: %w", err)
+	}
+	return d, nil
+}
+
+// Client is a session with one MCP server.
+type Client struct {
+	name    string
+	t       transport
+	timeout time.Duration
+	nextID  atomic.Int64
+	info    serverInfo
+}
+
+type serverInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// Connect starts a session with the named server.
+func Connect(ctx context.Context, name string, cfg ServerConfig, timeout time.Duration) (*Client, error) {
+	var t transport
+	var err error
+	switch {
+	case cfg.Command != "" && cfg.URL != "":
+		return nil, fmt.Errorf("mcp server %s: set command or url, not both", name)
+	case cfg.Command != "":
+		t, err = startStdio(cfg)
+	case cfg.URL != "":
+		t, err = newHTTP(cfg)
+	default:
+		return nil, fmt.Errorf("mcp server %s: needs a command or url", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("mcp server %s: %w", name, err)
+	}
+	c := &Client{name: name, t: t, timeout: timeout}
+	if err := c.initialize(ctx); err != nil {
+		c.Close()
+		return nil, fmt.Errorf("mcp server %s: %w", name, err)
+	}
+	return c, nil
+}
+
+func (c *Client) initialize(ctx context.Context) error {
+	var result struct {
+		ProtocolVersion string     `json:"protocolVersion"`
+		ServerInfo      serverInfo `json:"serverInfo"`
+	}
+	err := c.call(ctx, "initialize", map[string]any{
+		"protocolVersion": ProtocolVersion,
+		"capabilities":    map[string]any{},
+		"clientInfo":      map[string]string{"name": "my-agents", "version": "1"},
+	}, &result)
+	if err != nil {
+		return fmt.Errorf("initialize: %w", err)
+	}
+	c.info = result.ServerInfo
+	c.t.negotiated(result.ProtocolVersion)
+	return c.t.notify(ctx, message{JSONRPC: "2.0", Method: "notifications/initialized"})
+}
+
+// Name returns the server's name in the config.
+func (c *Client) Name() string {
+	return c.name
+}
+
+// Close ends the session, stopping a stdio server.
+func (c *Client) Close() error {
+	return c.t.close()
+}
+
+// ToolInfo is a tool as the server lists it.
+type ToolInfo struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"inputSchema"`
+}
+
+// ListTools returns every tool the server exposes.
+func (c *Client) ListTools(ctx context.Context) ([]ToolInfo, error) {
+	var all []ToolInfo
+	cursor := ""
+	for {
+		params := map[string]any{}
+		if cursor != "" {
+			params["cursor"] = cursor
+		}
+		var page struct {
+			Tools      []ToolInfo `json:"tools"`
+			NextCursor string     `json:"nextCursor"`
+		}
+		if err := c.call(ctx, "tools/list", params, &page); err != nil {
+			return nil, fmt.Errorf("mcp server %s: tools/list: %w", c.name, err)
+		}
+		all = append(all, page.Tools...)
+		if page.NextCursor == "" || page.NextCursor == cursor {
+			return all, nil
+		}
+		cursor = page.NextCursor
+	}
+}
+
+// CallResult is what a tools/call returned.
+type CallResult struct {
+	Content           []Content `json:"content"`
+	StructuredContent any       `json:"structuredContent"`
+	IsError           bool      `json:"isError"`
+}
+
+// Content is one item of a tool result.
+type Content struct {
+	Type     string `json:"type"` // "text", "image", "audio", "resource" or "resource_link"
+	Text     string `json:"text,omitempty"`
+	MimeType string `json:"mimeType,omitempty"`
+	URI      string `json:"uri,omitempty"`
+	Resource *struct {
+		URI  string `json:"uri"`
+		Text string `json:"text,omitempty"`
+	} `json:"resource,omitempty"`
+}
+
+// CallTool calls the server's tool name with args.
+func (c *Client) CallTool(ctx context.Context, name string, args map[string]any) (*CallResult, error) {
+	if args == nil {
+		args = map[string]any{}
+	}
+	var result CallResult
+	if err := c.call(ctx, "tools/call", map[string]any{"name": name, "arguments": args}, &result); err != nil {
+		return nil, fmt.Errorf("mcp server %s: %s: %w", c.name, name, err)
+	}
+	return &result, nil
+}
+
+// call sends a request and decodes its result into out.
+func (c *Client) call(ctx context.Context, method string, params any, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	id := c.nextID.Add(1)
+	resp, err := c.t.roundTrip(ctx, message{JSONRPC: "2.0", ID: &id, Method: method, Params: params})
+	if err != nil {
+		return err
+	}
+	if resp.Error != nil {
+		return resp.Error
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(resp.Result, out)
+}
+
+// Discover connects to every configured server and registers its tools in
+// registry. A tool named like one registry already has, built in or from
+// another server, is refused rather than shadowing it. It returns the
+// clients, which the caller closes on shutdown.
+func Discover(ctx context.Context, cfg Config, registry *tools.Registry) ([]*Client, error) {
+	timeout, err := cfg.timeout()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(cfg.Servers))
+	for name := range cfg.Servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var clients []*Client
+	fail := func(err error) ([]*Client, error) {
+		for _, c := range clients {
+			c.Close()
+		}
+		return nil, err
+	}
+	for _, name := range names {
+		sc := cfg.Servers[name]
+		c, err := Connect(ctx, name, sc, timeout)
+		if err != nil {
+			return fail(err)
+		}
+		clients = append(clients, c)
+		registered, err := c.register(ctx, sc, registry)
+		if err != nil {
+			return fail(err)
+		}
+		core.Logger().Info().Str("server", name).Str("server_name", c.info.Name).Int("tools", registered).Msg("Registered MCP tools")
+	}
+	return clients, nil
+}
+
+// register adds the server's tools, as configured, to registry and returns
+// how many it added.
+func (c *Client) register(ctx context.Context, cfg ServerConfig, registry *tools.Registry) (int, error) {
+	infos, err := c.ListTools(ctx)
+	if err != nil {
+		return 0, err
+	}
+	prefix := c.name + "_"
+	if cfg.Prefix != nil {
+		prefix = *cfg.Prefix
+	}
+	for _, want := range cfg.Tools {
+		if !slices.ContainsFunc(infos, func(info ToolInfo) bool { return info.Name == want }) {
+			return 0, fmt.Errorf("mcp server %s has no tool %q", c.name, want)
+		}
+	}
+	n := 0
+	for _, info := range infos {
+		if len(cfg.Tools) > 0 && !slices.Contains(cfg.Tools, info.Name) {
+			continue
+		}
+		name := prefix + info.Name
+		if _, taken := registry.Get(name); taken {
+			return 0, fmt.Errorf("mcp server %s: tool %q is already registered; give the server another prefix", c.name, name)
+		}
+		registry.Add(newTool(c, name, info))
+		n++
+	}
+	return n, nil
+}
+
+// Check connects to the named server and lists its tools, for `doctor`.
+func (c Config) Check(ctx context.Context, name string) (string, error) {
+	timeout, err := c.timeout()
+	if err != nil {
+		return "", err
+	}
+	client, err := Connect(ctx, name, c.Servers[name], timeout)
+	if err != nil {
+		return "", err
+	}
+	defer client.Close()
+	infos, err := client.ListTools(ctx)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s, %d tools", cmp.Or(client.info.Name, "server"), client.info.Version, len(infos)), nil
+}
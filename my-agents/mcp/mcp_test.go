@@ -0,0 +1,191 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"my-agents/tools"
+)
+
+// TestMain lets the test binary stand in for a stdio server, launched with
+// MCP_TEST_SERVER set.
+func TestMain(m *testing.M) {
+	switch os.Getenv("MCP_TEST_SERVER") {
+	case "stdio":
+		serveStdio(os.Stdin, os.Stdout)
+		os.Exit(0)
+	case "crash":
+		os.Stderr.WriteString("missing API key\n")
+		os.Exit(1)
+	}
+	os.Exit(m.Run())
+}
+
+// handle answers a request as a server exposing echo, fail, weather and
+// broken, two to a page.
+func handle(req message) message {
+	resp := message{JSONRPC: "2.0", ID: req.ID}
+	var params struct {
+		Cursor    string         `json:"cursor"`
+		Name      string         `json:"name"`
+		Arguments map[string]any `json:"arguments"`
+	}
+	data, _ := json.Marshal(req.Params)
+	json.Unmarshal(data, &params)
+
+	var result any
+	switch req.Method {
+	case "initialize":
+		result = map[string]any{"protocolVersion": ProtocolVersion, "serverInfo": map[string]string{"name": "fake", "version": "1.0"}}
+	case "tools/list":
+		if params.Cursor == "" {
+			result = map[string]any{"nextCursor": "2", "tools": []map[string]any{
+				{"name": "echo", "description": "Echoes text", "inputSchema": map[string]any{"type": "object", "required": []string{"text"}, "properties": map[string]any{"text": map[string]string{"type": "string"}}}},
+				{"name": "fail"},
+			}}
+		} else {
+			result = map[string]any{"tools": []map[string]any{
+				{"name": "weather", "description": "Current weather"},
+				{"name": "broken", "inputSchema": map[string]any{"type": "tuple"}},
+			}}
+		}
+	case "tools/call":
+		switch params.Name {
+		case "echo":
+			result = map[string]any{"content": []map[string]any{
+				{"type": "text", "text": params.Arguments["text"]},
+				{"type": "image", "mimeType": "image/png", "data": "iVBORw0KGgo="},
+			}}
+		case "fail":
+			result = map[string]any{"isError": true, "content": []any{}}
+		case "weather":
+			result = map[string]any{"structuredContent": map[string]any{"temp": 21}, "content": []map[string]any{{"type": "text", "text": `{"temp": 21}`}}}
+		default:
+			resp.Error = &rpcError{Code: -32602, Message: "unknown tool " + params.Name}
+			return resp
+		}
+	default:
+		resp.Error = &rpcError{Code: -32601, Message: "method not found: " + req.Method}
+		return resp
+	}
+	resp.Result, _ = json.Marshal(result)
+	return resp
+}
+
+func TestConnect(t *testing.T) {
+	ctx := context.Background()
+	for name, cfg := range map[string]ServerConfig{
+		"both":    {Command: "server", URL: "http://localhost"},
+		"neither": {},
+		"missing": {Command: "no-such-mcp-server"},
+	} {
+		if _, err := Connect(ctx, name, cfg, time.Second); err == nil || !strings.HasPrefix(err.Error(), "mcp server "+name+": ") {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+	if _, err := Connect(ctx, "crash", ServerConfig{Command: os.Args[0], Env: map[string]string{"MCP_TEST_SERVER": "crash"}}, time.Second); err == nil || !strings.Contains(err.Error(), "missing API key") {
+		t.Errorf("crashing server: %v", err)
+	}
+}
+
+func TestClient(t *testing.T) {
+	srv := httptest.NewServer(&server{})
+	defer srv.Close()
+	ctx := context.Background()
+	c, err := Connect(ctx, "web", ServerConfig{URL: srv.URL}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if c.Name() != "web" || c.info.Name != "fake" {
+		t.Errorf("client %s for server %+v", c.Name(), c.info)
+	}
+
+	infos, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, info := range infos {
+		names = append(names, info.Name)
+	}
+	if got := strings.Join(names, " "); got != "echo fail weather broken" {
+		t.Errorf("tools = %s", got)
+	}
+
+	res, err := c.CallTool(ctx, "echo", map[string]any{"text": "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Content) != 2 || res.Content[0].Text != "hi" || res.IsError {
+		t.Errorf("result = %+v", res)
+	}
+	if _, err := c.CallTool(ctx, "nope", nil); err == nil || err.Error() != "mcp server web: nope: unknown tool nope (code -32602)" {
+		t.Errorf("unknown tool: %v", err)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	srv := httptest.NewServer(&server{})
+	defer srv.Close()
+	ctx := context.Background()
+	none := ""
+	cfg := Config{Servers: map[string]ServerConfig{
+		"files": {Command: os.Args[0], Env: map[string]string{"MCP_TEST_SERVER": "stdio"}},
+		"web":   {URL: srv.URL, Prefix: &none, Tools: []string{"weather"}},
+	}}
+	registry := tools.NewRegistry()
+	clients, err := Discover(ctx, cfg, registry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		for _, c := range clients {
+			c.Close()
+		}
+	}()
+	if len(clients) != 2 || clients[0].Name() != "files" {
+		t.Fatalf("clients = %v", clients)
+	}
+	var names []string
+	for _, tool := range registry.List() {
+		names = append(names, tool.Name())
+	}
+	if got := strings.Join(names, " "); got != "files_broken files_echo files_fail files_weather weather" {
+		t.Errorf("registered %s", got)
+	}
+
+	// A clash, or a tool the server lacks, fails discovery
+	for name, sc := range map[string]ServerConfig{
+		"clash":   {URL: srv.URL, Prefix: &none},
+		"missing": {URL: srv.URL, Tools: []string{"forecast"}},
+	} {
+		if _, err := Discover(ctx, Config{Servers: map[string]ServerConfig{name: sc}}, registry); err == nil {
+			t.Errorf("%s: discovered", name)
+		}
+	}
+	if _, err := Discover(ctx, Config{Timeout: "soon"}, registry); err == nil {
+		t.Error("bad timeout accepted")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	srv := httptest.NewServer(&server{})
+	defer srv.Close()
+	cfg := Config{Servers: map[string]ServerConfig{"web": {URL: srv.URL}}}
+	got, err := cfg.Check(context.Background(), "web")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "fake 1.0, 4 tools" {
+		t.Errorf("Check = %q", got)
+	}
+	if _, err := cfg.Check(context.Background(), "files"); err == nil {
+		t.Error("unconfigured server checked")
+	}
+}
@@ -0,0 +1,85 @@
+package mcp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/schema"
+)
+
+// Tool is a server's tool registered in the tool registry.
+type Tool struct {
+	client *Client
+	name   string // as registered
+	info   ToolInfo
+	params *schema.Schema
+}
+
+func newTool(c *Client, name string, info ToolInfo) *Tool {
+	t := &Tool{client: c, name: name, info: info}
+	if len(info.InputSchema) > 0 {
+		params, err := schema.Compile(info.InputSchema)
+		if err != nil {
+			// The model still sees the description; the server checks the arguments
+			core.Logger().Warn().Err(err).Str("tool", name).Msg("Ignoring MCP tool input schema")
+		}
+		t.params = params // nil if it didn't compile
+	}
+	return t
+}
+
+func (t *Tool) Name() string { return t.name }
+
+func (t *Tool) Description() string {
+	return strings.TrimSpace(t.info.Description + " (from MCP server " + t.client.Name() + ")")
+}
+
+// Parameters returns the tool's input schema, or nil if it has none the
+// schema package can read.
+func (t *Tool) Parameters() *schema.Schema { return t.params }
+
+// Call calls the tool on its server. The result is the tool's structured
+// content when it returns some, otherwise its content as text; a result the
+// server flags as an error is returned as one.
+func (t *Tool) Call(ctx context.Context, args map[string]any) (any, error) {
+	res, err := t.client.CallTool(ctx, t.info.Name, args)
+	if err != nil {
+		return nil, err
+	}
+	text := res.text()
+	if res.IsError {
+		if text == "" {
+			text = "tool reported an error"
+		}
+		return nil, errors.New(text)
+	}
+	if res.StructuredContent != nil {
+		return res.StructuredContent, nil
+	}
+	return text, nil
+}
+
+// text renders the result's content: text as it is and other content as a
+// placeholder naming it.
+func (r *CallResult) text() string {
+	var parts []string
+	for _, c := range r.Content {
+		switch {
+		case c.Type == "text":
+			parts = append(parts, c.Text)
+		case c.Type == "resource" && c.Resource != nil && c.Resource.Text != "":
+			parts = append(parts, c.Resource.Text)
+		case c.Type == "resource" && c.Resource != nil:
+			parts = append(parts, fmt.Sprintf("[resource %s]", c.Resource.URI))
+		case c.Type == "resource_link":
+			parts = append(parts, fmt.Sprintf("[resource %s]", c.URI))
+		default:
+			parts = append(parts, fmt.Sprintf("[%s %s]", c.Type, c.MimeType))
+		}
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,71 @@
+package mcp
+
+import (
+	"context"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTool(t *testing.T) {
+	srv := httptest.NewServer(&server{})
+	defer srv.Close()
+	ctx := context.Background()
+	c, err := Connect(ctx, "web", ServerConfig{URL: srv.URL}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	infos, err := c.ListTools(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tools := make(map[string]*Tool)
+	for _, info := range infos {
+		tools[info.Name] = newTool(c, "web_"+info.Name, info)
+	}
+
+	echo := tools["echo"]
+	if echo.Name() != "web_echo" || echo.Description() != "Echoes text (from MCP server web)" || echo.Parameters() == nil {
+		t.Errorf("echo = %s, %q, %v", echo.Name(), echo.Description(), echo.Parameters())
+	}
+	if tools["fail"].Description() != "(from MCP server web)" || tools["fail"].Parameters() != nil || tools["broken"].Parameters() != nil {
+		t.Error("tools without a usable schema have parameters")
+	}
+
+	out, err := echo.Call(ctx, map[string]any{"text": "hi"})
+	if err != nil || out != "hi\n[image image/png]" {
+		t.Errorf("echo = %v, %v", out, err)
+	}
+	out, err = tools["weather"].Call(ctx, nil)
+	if err != nil || !reflect.DeepEqual(out, map[string]any{"temp": 21.0}) {
+		t.Errorf("weather = %v, %v", out, err)
+	}
+	if _, err := tools["fail"].Call(ctx, nil); err == nil || err.Error() != "tool reported an error" {
+		t.Errorf("fail = %v", err)
+	}
+	if _, err := tools["broken"].Call(ctx, nil); err == nil {
+		t.Error("call to a tool the server rejects succeeded")
+	}
+}
+
+func TestText(t *testing.T) {
+	r := &CallResult{Content: []Content{
+		{Type: "text", Text: "one"},
+		{Type: "resource_link", URI: "file:///a.txt"},
+		{Type: "audio", MimeType: "audio/wav"},
+		{Type: "resource", Resource: &struct {
+			URI  string `json:"uri"`
+			Text string `json:"text,omitempty"`
+		}{URI: "file:///b.txt", Text: "two"}},
+		{Type: "resource", Resource: &struct {
+			URI  string `json:"uri"`
+			Text string `json:"text,omitempty"`
+		}{URI: "file:///c.png"}},
+	}}
+	want := "one\n[resource file:///a.txt]\n[audio audio/wav]\ntwo\n[resource file:///c.png]"
+	if got := r.text(); got != want {
+		t.Errorf("text = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,383 @@
+package mcp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"my-agents/tools/proc"
+)
+
+// message is a JSON-RPC 2.0 request, notification or response.
+type message struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      *int64          `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  any             `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is a JSON-RPC error response.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return fmt.Sprintf("%s (code %d)", e.Message, e.Code)
+}
+
+// transport carries messages to and from one server.
+type transport interface {
+	// roundTrip sends a request and waits for its response.
+	roundTrip(ctx context.Context, req message) (message, error)
+	notify(ctx context.Context, msg message) error
+	// negotiated records the protocol version the server agreed to.
+	negotiated(version string)
+	close() error
+}
+
+// reply answers a request the server sent the client. Only ping is
+// supported; the client declares no capabilities that need more.
+func reply(req message) message {
+	if req.Method == "ping" {
+		return message{JSONRPC: "2.0", ID: req.ID, Result: json.RawMessage("{}")}
+	}
+	return message{JSONRPC: "2.0", ID: req.ID, Error: &rpcError{Code: -32601, Message: "method not found: " + req.Method}}
+}
+
+// stdio talks to a server it launched, one JSON message per line on the
+// server's stdin and stdout.
+type stdio struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stderr *tail
+	cancel context.CancelFunc
+
+	wmu sync.Mutex // serializes writes to stdin
+
+	mu      sync.Mutex
+	pending map[int64]chan message
+	err     error // set once stdout closes
+	done    chan struct{}
+}
+
+func startStdio(cfg ServerConfig) (*stdio, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd, err := proc.Command(ctx, cfg.Command, cfg.Args...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	cmd.Env = os.Environ()
+	for k, v := range cfg.Env {
+		cmd.Env = append(cmd.Env, k+"="+os.ExpandEnv(v))
+	}
+	s := &stdio{cmd: cmd, stderr: &tail{}, cancel: cancel, pending: make(map[int64]chan message), done: make(chan struct{})}
+	cmd.Stderr = s.stderr
+	if s.stdin, err = cmd.StdinPipe(); err != nil {
+		cancel()
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, err
+	}
+	go s.read(stdout)
+	return s, nil
+}
+
+// read dispatches the server's messages until its stdout closes.
+func (s *stdio) read(stdout io.Reader) {
+	scanner := bufio.NewScanner(stdout)
+	scanner.Buffer(make([]byte, 64<<10), 16<<20)
+	for scanner.Scan() {
+		var msg message
+		if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+			continue // servers may log to stdout by mistake
+		}
+		switch {
+		case msg.ID != nil && msg.Method != "":
+			s.write(reply(msg))
+		case msg.ID != nil:
+			s.mu.Lock()
+			ch := s.pending[*msg.ID]
+			delete(s.pending, *msg.ID)
+			s.mu.Unlock()
+			if ch != nil {
+				ch <- msg
+			}
+		}
+		// notifications from the server (progress, log messages) are ignored
+	}
+	err := errors.New("server exited")
+	if msg := s.stderr.String(); msg != "" {
+		err = fmt.Errorf("server exited: %s", msg)
+	}
+	s.mu.Lock()
+	s.err = err
+	s.mu.Unlock()
+	close(s.done)
+}
+
+func (s *stdio) write(msg message) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	s.wmu.Lock()
+	defer s.wmu.Unlock()
+	_, err = s.stdin.Write(append(data, '\n'))
+	return err
+}
+
+func (s *stdio) roundTrip(ctx context.Context, req message) (message, error) {
+	ch := make(chan message, 1)
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return message{}, s.err
+	}
+	s.pending[*req.ID] = ch
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, *req.ID)
+		s.mu.Unlock()
+	}()
+	if err := s.write(req); err != nil {
+		return message{}, err
+	}
+	select {
+	case resp := <-ch:
+		return resp, nil
+	case <-s.done:
+		return message{}, s.err
+	case <-ctx.Done():
+		return message{}, ctx.Err()
+	}
+}
+
+func (s *stdio) notify(ctx context.Context, msg message) error {
+	return s.write(msg)
+}
+
+func (s *stdio) negotiated(string) {}
+
+// stopTimeout is how long a server has to exit, or end its HTTP session,
+// when the client closes.
+const stopTimeout = 3 * time.Second
+
+// close closes the server's stdin, which tells it to exit, and kills it if
+// it hasn't shortly after.
+func (s *stdio) close() error {
+	s.stdin.Close()
+	select {
+	case <-s.done:
+	case <-time.After(stopTimeout):
+	}
+	s.cancel()
+	s.cmd.Wait() // exits with the kill when the server didn't stop by itself
+	return nil
+}
+
+// tail keeps the end of what a server writes to stderr, for errors.
+type tail struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+const tailSize = 2048
+
+func (t *tail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.buf = append(t.buf, p...)
+	if len(t.buf) > tailSize {
+		t.buf = t.buf[len(t.buf)-tailSize:]
+	}
+	return len(p), nil
+}
+
+func (t *tail) String() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return strings.TrimSpace(string(t.buf))
+}
+
+// streamable talks to a server over MCP's streamable HTTP transport: each
+// message is POSTed, and the server answers with JSON or an event stream.
+type streamable struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+
+	mu      sync.Mutex
+	session string // Mcp-Session-Id, once the server assigns one
+	version string
+}
+
+func newHTTP(cfg ServerConfig) (*streamable, error) {
+	headers := make(map[string]string, len(cfg.Headers))
+	for k, v := range cfg.Headers {
+		headers[k] = os.ExpandEnv(v)
+	}
+	return &streamable{url: cfg.URL, headers: headers, client: &http.Client{}}, nil
+}
+
+func (h *streamable) request(ctx context.Context, method string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, h.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json, text/event-stream")
+	for k, v := range h.headers {
+		req.Header.Set(k, v)
+	}
+	h.mu.Lock()
+	if h.session != "" {
+		req.Header.Set("Mcp-Session-Id", h.session)
+	}
+	if h.version != "" {
+		req.Header.Set("MCP-Protocol-Version", h.version)
+	}
+	h.mu.Unlock()
+	return req, nil
+}
+
+func (h *streamable) post(ctx context.Context, msg message) (*http.Response, error) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	req, err := h.request(ctx, http.MethodPost, data)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if id := resp.Header.Get("Mcp-Session-Id"); id != "" {
+		h.mu.Lock()
+		h.session = id
+		h.mu.Unlock()
+	}
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+func (h *streamable) roundTrip(ctx context.Context, req message) (message, error) {
+	resp, err := h.post(ctx, req)
+	if err != nil {
+		return message{}, err
+	}
+	defer resp.Body.Close()
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/event-stream" {
+		var msg message
+		if err := json.NewDecoder(resp.Body).Decode(&msg); err != nil {
+			return message{}, fmt.Errorf("decode response: %w", err)
+		}
+		return msg, nil
+	}
+
+	// The stream can carry the server's own requests and notifications
+	// before the response
+	reader := bufio.NewReader(resp.Body)
+	var data strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		case line == "" && data.Len() > 0:
+			var msg message
+			if json.Unmarshal([]byte(data.String()), &msg) == nil {
+				switch {
+				case msg.ID != nil && msg.Method != "":
+					go h.answer(ctx, reply(msg))
+				case msg.ID != nil && *msg.ID == *req.ID:
+					return msg, nil
+				}
+			}
+			data.Reset()
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errors.New("event stream ended without a response")
+			}
+			return message{}, err
+		}
+	}
+}
+
+// answer posts msg in the background, outliving the request that carried
+// the server's question.
+func (h *streamable) answer(ctx context.Context, msg message) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), stopTimeout)
+	defer cancel()
+	if resp, err := h.post(ctx, msg); err == nil {
+		resp.Body.Close()
+	}
+}
+
+func (h *streamable) notify(ctx context.Context, msg message) error {
+	resp, err := h.post(ctx, msg)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+func (h *streamable) negotiated(version string) {
+	h.mu.Lock()
+	h.version = version
+	h.mu.Unlock()
+}
+
+// close ends the session on the server, when it assigned one.
+func (h *streamable) close() error {
+	h.mu.Lock()
+	session := h.session
+	h.mu.Unlock()
+	if session == "" {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+	req, err := h.request(ctx, http.MethodDelete, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
@@ -0,0 +1,205 @@
+package mcp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// serveStdio serves handle over in and out the way a stdio server does,
+// pinging the client before answering a tool call and never answering a
+// call to slow.
+func serveStdio(in io.Reader, out io.Writer) {
+	write := func(msg message) {
+		data, _ := json.Marshal(msg)
+		fmt.Fprintf(out, "%s\n", data)
+	}
+	fmt.Fprintln(out, "fake server starting")
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		var req message
+		if json.Unmarshal(scanner.Bytes(), &req) != nil || req.ID == nil || req.Method == "" {
+			continue // notifications and replies to the ping
+		}
+		if req.Method == "tools/call" {
+			if strings.Contains(scanner.Text(), `"name":"slow"`) {
+				continue
+			}
+			ping := int64(1000)
+			write(message{JSONRPC: "2.0", ID: &ping, Method: "ping"})
+			write(message{JSONRPC: "2.0", Method: "notifications/progress"})
+		}
+		write(handle(req))
+	}
+}
+
+// server serves handle over streamable HTTP, answering tool calls with an
+// event stream that first pings the client.
+type server struct {
+	token string // required as a bearer token when set
+
+	mu      sync.Mutex
+	headers http.Header // of the last request
+	pinged  bool
+	ended   string // the session the client ended
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.headers = r.Header.Clone()
+	s.mu.Unlock()
+	if s.token != "" && r.Header.Get("Authorization") != "Bearer "+s.token {
+		http.Error(w, "bad token", http.StatusUnauthorized)
+		return
+	}
+	if r.Method == http.MethodDelete {
+		s.mu.Lock()
+		s.ended = r.Header.Get("Mcp-Session-Id")
+		s.mu.Unlock()
+		return
+	}
+	var req message
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	switch {
+	case req.ID == nil:
+		w.WriteHeader(http.StatusAccepted)
+		return
+	case req.Method == "":
+		s.mu.Lock()
+		s.pinged = string(req.Result) == "{}"
+		s.mu.Unlock()
+		w.WriteHeader(http.StatusAccepted)
+		return
+	case req.Method == "initialize":
+		w.Header().Set("Mcp-Session-Id", "sess-1")
+	case req.Method == "tools/call":
+		w.Header().Set("Content-Type", "text/event-stream")
+		ping := int64(1000)
+		for _, msg := range []message{
+			{JSONRPC: "2.0", ID: &ping, Method: "ping"},
+			{JSONRPC: "2.0", Method: "notifications/progress"},
+			handle(req),
+		} {
+			data, _ := json.Marshal(msg)
+			fmt.Fprintf(w, "event: message\r\ndata: %s\r\n\r\n", data)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(handle(req))
+}
+
+func (s *server) state() (http.Header, bool, string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.headers, s.pinged, s.ended
+}
+
+func TestStdio(t *testing.T) {
+	ctx := context.Background()
+	t.Setenv("MCP_TEST_MODE", "stdio")
+	c, err := Connect(ctx, "files", ServerConfig{Command: os.Args[0], Env: map[string]string{"MCP_TEST_SERVER": "${MCP_TEST_MODE}"}}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := c.CallTool(ctx, "echo", map[string]any{"text": "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Content[0].Text != "hi" {
+		t.Errorf("result = %+v", res)
+	}
+
+	c.timeout = 50 * time.Millisecond
+	if _, err := c.CallTool(ctx, "slow", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("unanswered call: %v", err)
+	}
+	c.Close()
+	if _, err := c.CallTool(ctx, "echo", nil); err == nil || !strings.Contains(err.Error(), "server exited") {
+		t.Errorf("call after close: %v", err)
+	}
+}
+
+func TestStreamable(t *testing.T) {
+	s := &server{token: "secret"}
+	srv := httptest.NewServer(s)
+	defer srv.Close()
+	ctx := context.Background()
+	if _, err := Connect(ctx, "web", ServerConfig{URL: srv.URL}, time.Second); err == nil || !strings.Contains(err.Error(), "401 Unauthorized: bad token") {
+		t.Errorf("without the token: %v", err)
+	}
+
+	t.Setenv("MCP_TOKEN", "secret")
+	c, err := Connect(ctx, "web", ServerConfig{URL: srv.URL, Headers: map[string]string{"Authorization": "Bearer ${MCP_TOKEN}"}}, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ListTools(ctx); err != nil {
+		t.Fatal(err)
+	}
+	headers, _, _ := s.state()
+	if headers.Get("Mcp-Session-Id") != "sess-1" || headers.Get("MCP-Protocol-Version") != ProtocolVersion {
+		t.Errorf("headers = %v", headers)
+	}
+
+	res, err := c.CallTool(ctx, "echo", map[string]any{"text": "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Content[0].Text != "hi" {
+		t.Errorf("result = %+v", res)
+	}
+	// The ping is answered in the background
+	deadline := time.Now().Add(time.Second)
+	for _, pinged, _ := s.state(); !pinged; _, pinged, _ = s.state() {
+		if time.Now().After(deadline) {
+			t.Fatal("ping not answered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	c.Close()
+	if _, _, ended := s.state(); ended != "sess-1" {
+		t.Errorf("ended session %q", ended)
+	}
+}
+
+func TestStreamableEnded(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "data: {\"jsonrpc\": \"2.0\",\n")
+		fmt.Fprint(w, "data: \"method\": \"notifications/progress\"}\n\n")
+	}))
+	defer srv.Close()
+	h, _ := newHTTP(ServerConfig{URL: srv.URL})
+	id := int64(1)
+	if _, err := h.roundTrip(context.Background(), message{JSONRPC: "2.0", ID: &id, Method: "tools/list"}); err == nil || err.Error() != "event stream ended without a response" {
+		t.Errorf("roundTrip = %v", err)
+	}
+	// Without a session there's nothing to end
+	if err := h.close(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTail(t *testing.T) {
+	var tl tail
+	tl.Write([]byte(strings.Repeat("x", tailSize)))
+	tl.Write([]byte("the end\n"))
+	if got := tl.String(); len(got) != tailSize-1 || !strings.HasSuffix(got, "xthe end") {
+		t.Errorf("tail = %d bytes ending %q", len(got), got[len(got)-10:])
+	}
+}
@@ -0,0 +1,31 @@
+// Package media carries the images of a request to the providers that can
+// see them. core.Prompt holds text only, so images travel in the call's
+// context; providers without image input ignore them.
+package media
+
+import "context"
+
+// Image is an image for a model to look at: inline bytes, or a URI the
+// provider fetches itself (gs:// for Vertex, an uploaded file's URI).
+type Image struct {
+	MIMEType string
+	Data     []byte
+	URI      string
+}
+
+type imagesKey struct{}
+
+// WithImages returns ctx carrying images, after any it carried already.
+func WithImages(ctx context.Context, images ...Image) context.Context {
+	if len(images) == 0 {
+		return ctx
+	}
+	prev := Images(ctx)
+	return context.WithValue(ctx, imagesKey{}, append(prev[:len(prev):len(prev)], images...))
+}
+
+// Images returns the images ctx carries.
+func Images(ctx context.Context) []Image {
+	images, _ := ctx.Value(imagesKey{}).([]Image)
+	return images
+}
@@ -0,0 +1,29 @@
+package media
+
+import (
+	"context"
+	"testing"
+)
+
+func TestWithImages(t *testing.T) {
+	ctx := context.Background()
+	if got := Images(ctx); got != nil {
+		t.Errorf("Images of a bare context = %v", got)
+	}
+	if WithImages(ctx) != ctx {
+		t.Error("no images made a new context")
+	}
+	one := WithImages(ctx, Image{MIMEType: "image/png", Data: []byte{1}})
+	two := WithImages(one, Image{URI: "gs://bucket/a.jpg"})
+	// Adding to a context leaves its parent's images alone
+	other := WithImages(one, Image{URI: "gs://bucket/b.jpg"})
+	if got := Images(one); len(got) != 1 {
+		t.Errorf("parent images = %v", got)
+	}
+	if got := Images(two); len(got) != 2 || got[1].URI != "gs://bucket/a.jpg" {
+		t.Errorf("images = %v", got)
+	}
+	if got := Images(other); len(got) != 2 || got[1].URI != "gs://bucket/b.jpg" {
+		t.Errorf("sibling images = %v", got)
+	}
+}
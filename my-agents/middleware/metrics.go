@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Stats are an agent's runs since the process started.
+type Stats struct {
+	Runs      int   `json:"runs"`
+	Errors    int   `json:"errors"`
+	AvgMillis int64 `json:"avg_ms"`
+	MaxMillis int64 `json:"max_ms"`
+}
+
+// Metrics counts the runs of the agents its middleware wraps.
+type Metrics struct {
+	mu     sync.Mutex
+	agents map[string]*tally
+}
+
+type tally struct {
+	runs, errors int
+	total, max   time.Duration
+}
+
+// NewMetrics creates an empty set of counters.
+func NewMetrics() *Metrics {
+	return &Metrics{agents: make(map[string]*tally)}
+}
+
+// Middleware counts each agent run, its failures and its duration.
+func (m *Metrics) Middleware() Middleware {
+	return func(next core.AgentHandler) core.AgentHandler {
+		return HandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+			start := time.Now()
+			result, err := next.Run(ctx, event, state)
+			m.observe(Agent(ctx), time.Since(start), err)
+			return result, err
+		})
+	}
+}
+
+func (m *Metrics) observe(agent string, elapsed time.Duration, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	t, ok := m.agents[agent]
+	if !ok {
+		t = &tally{}
+		m.agents[agent] = t
+	}
+	t.runs++
+	if err != nil {
+		t.errors++
+	}
+	t.total += elapsed
+	t.max = max(t.max, elapsed)
+}
+
+// Snapshot returns each counted agent's stats.
+func (m *Metrics) Snapshot() map[string]Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stats := make(map[string]Stats, len(m.agents))
+	for agent, t := range m.agents {
+		stats[agent] = Stats{
+			Runs:      t.runs,
+			Errors:    t.errors,
+			AvgMillis: (t.total / time.Duration(t.runs)).Milliseconds(),
+			MaxMillis: t.max.Milliseconds(),
+		}
+	}
+	return stats
+}
+
+// Handler serves the snapshot, for mounting on the admin API:
+//
+//	GET /admin/agents/metrics   each agent's runs, errors and durations
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"agents": m.Snapshot()})
+	})
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics()
+	ctx := context.Background()
+	slow := HandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+		time.Sleep(20 * time.Millisecond)
+		return core.AgentResult{}, nil
+	})
+	Chain("writer", routeTo("editor"), m.Middleware()).Run(ctx, event(), core.NewState())
+	Chain("writer", routeTo(""), m.Middleware()).Run(ctx, event(), core.NewState())
+	Chain("editor", slow, m.Middleware()).Run(ctx, event(), core.NewState())
+
+	stats := m.Snapshot()
+	if w := stats["writer"]; w.Runs != 2 || w.Errors != 1 {
+		t.Errorf("writer = %+v", w)
+	}
+	if e := stats["editor"]; e.Runs != 1 || e.Errors != 0 || e.MaxMillis < 20 || e.AvgMillis != e.MaxMillis {
+		t.Errorf("editor = %+v", e)
+	}
+
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/admin/agents/metrics", nil))
+	var resp struct {
+		Agents map[string]Stats `json:"agents"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Agents) != 2 || resp.Agents["writer"].Runs != 2 {
+		t.Errorf("served %+v", resp)
+	}
+}
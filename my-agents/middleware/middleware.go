@@ -0,0 +1,174 @@
+// Package middleware wraps agents for cross-cutting concerns — logging,
+// timing, prompt redaction, metrics — so agents don't each repeat them.
+// Middleware is installed around every agent or, by name, around the agents
+// that list it in agentflow.toml.
+package middleware
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+	"my-agents/tenant"
+)
+
+// DefaultSlowThreshold is the duration past which timing warns about an
+// agent.
+const DefaultSlowThreshold = 30 * time.Second
+
+// Config is the [middleware] section of agentflow.toml:
+//
+//	[middleware]
+//	use = ["logging", "metrics"]   # around every agent, first outermost
+//	slow_threshold = "30s"
+//	redact = ['\b\d{3}-\d{2}-\d{4}\b']
+//
+//	[agents.processor]
+//	middleware = ["redact"]   # around this agent only, inside the above
+//
+// The built-in middleware is logging, timing, redact and metrics.
+type Config struct {
+	Use []string `toml:"use"`
+	// SlowThreshold is how long an agent may take before timing logs a
+	// warning (default DefaultSlowThreshold).
+	SlowThreshold string `toml:"slow_threshold"`
+	// Redact lists patterns redact masks in addition to email addresses and
+	// card numbers.
+	Redact []string `toml:"redact"`
+}
+
+// Middleware wraps an agent's Run.
+type Middleware func(next core.AgentHandler) core.AgentHandler
+
+// HandlerFunc adapts a function to core.AgentHandler.
+type HandlerFunc func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error)
+
+func (f HandlerFunc) Run(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+	return f(ctx, event, state)
+}
+
+type agentKey struct{}
+
+// Agent returns the name of the agent a middleware is running around, or ""
+// outside a Chain.
+func Agent(ctx context.Context) string {
+	name, _ := ctx.Value(agentKey{}).(string)
+	return name
+}
+
+// Chain wraps the named agent in mws, the first outermost. The middleware
+// finds the agent's name with Agent.
+func Chain(name string, agent core.AgentHandler, mws ...Middleware) core.AgentHandler {
+	if len(mws) == 0 {
+		return agent
+	}
+	for i := len(mws) - 1; i >= 0; i-- {
+		agent = mws[i](agent)
+	}
+	return HandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+		return agent.Run(context.WithValue(ctx, agentKey{}, name), event, state)
+	})
+}
+
+// Builtins creates the built-in middleware by name, counting into metrics.
+func Builtins(cfg Config, metrics *Metrics) (map[string]Middleware, error) {
+	slow := DefaultSlowThreshold
+	if cfg.SlowThreshold != "" {
+		d, err := time.ParseDuration(cfg.SlowThreshold)
+		if err != nil {
+			return nil, fmt.Errorf("middleware slow_threshold: %w", err)
+		}
+		slow = d
+	}
+	patterns := append([]*regexp.Regexp(nil), defaultRedactions...)
+	for _, p := range cfg.Redact {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("middleware redact pattern %q: %w", p, err)
+		}
+		patterns = append(patterns, re)
+	}
+	return map[string]Middleware{
+		"logging": Logging,
+		"timing":  Timing(slow),
+		"redact":  Redact(patterns...),
+		"metrics": metrics.Middleware(),
+	}, nil
+}
+
+// ForwardMetadata passes the caller's request metadata — run ID, tenant,
+// locale and the like — on to the event the agent routes to. Metadata the
+// agent sets itself wins; the route, session and status are the agent's
+// own.
+func ForwardMetadata(next core.AgentHandler) core.AgentHandler {
+	return HandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+		result, err := next.Run(ctx, event, state)
+		if err != nil || result.OutputState == nil {
+			return result, err
+		}
+		for key, value := range event.GetMetadata() {
+			switch key {
+			case core.RouteMetadataKey, core.SessionIDKey, "status":
+				continue
+			}
+			if _, set := result.OutputState.GetMeta(key); !set {
+				result.OutputState.SetMeta(key, value)
+			}
+		}
+		return result, nil
+	})
+}
+
+// Tenant has the agent's calls carry the tenant of the event it handles,
+// for the stores that keep tenants apart, like the LLM cache.
+func Tenant(next core.AgentHandler) core.AgentHandler {
+	return HandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+		return next.Run(tenant.NewContext(ctx, tenant.FromEvent(event)), event, state)
+	})
+}
+
+// Logging logs each agent run's start and outcome: where it routed the run
+// or how it failed. It writes to the standard logger, which the operator
+// sees whatever the [logging] level.
+func Logging(next core.AgentHandler) core.AgentHandler {
+	return HandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+		agent := Agent(ctx)
+		runID, _ := event.GetMetadataValue(history.RunIDKey)
+		log.Printf("▶️ %s started (run %s)", agent, runID)
+		result, err := next.Run(ctx, event, state)
+		if err != nil {
+			log.Printf("❌ %s failed (run %s): %v", agent, runID, err)
+			return result, err
+		}
+		var route string
+		if result.OutputState != nil {
+			route, _ = result.OutputState.GetMeta(core.RouteMetadataKey)
+		}
+		log.Printf("✅ %s finished (run %s) → %s", agent, runID, cmp.Or(route, "end"))
+		return result, nil
+	})
+}
+
+// Timing logs how long each agent run took, as a warning past slow.
+func Timing(slow time.Duration) Middleware {
+	return func(next core.AgentHandler) core.AgentHandler {
+		return HandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+			start := time.Now()
+			result, err := next.Run(ctx, event, state)
+			elapsed := time.Since(start).Round(time.Millisecond)
+			runID, _ := event.GetMetadataValue(history.RunIDKey)
+			if elapsed > slow {
+				log.Printf("🐢 %s took %v, over %v (run %s)", Agent(ctx), elapsed, slow, runID)
+			} else {
+				log.Printf("⏱️ %s took %v (run %s)", Agent(ctx), elapsed, runID)
+			}
+			return result, err
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+	"my-agents/tenant"
+)
+
+// routeTo is an agent routing to next, or failing when next is "".
+func routeTo(next string) HandlerFunc {
+	return func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+		if next == "" {
+			return core.AgentResult{}, errors.New("model down")
+		}
+		out := core.NewState()
+		out.SetMeta(core.RouteMetadataKey, next)
+		return core.AgentResult{OutputState: out}, nil
+	}
+}
+
+// captureLog sends the standard logger's output to a buffer for the test.
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(prev) })
+	return &buf
+}
+
+func event() core.Event {
+	return core.NewEvent("writer", core.EventData{"input": "hi"}, map[string]string{history.RunIDKey: "run-1"})
+}
+
+func TestChain(t *testing.T) {
+	var order []string
+	trace := func(name string) Middleware {
+		return func(next core.AgentHandler) core.AgentHandler {
+			return HandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+				order = append(order, name+" "+Agent(ctx))
+				return next.Run(ctx, event, state)
+			})
+		}
+	}
+	agent := routeTo("editor")
+	h := Chain("writer", agent, trace("outer"), trace("inner"))
+	if _, err := h.Run(context.Background(), event(), core.NewState()); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(order, ", ") != "outer writer, inner writer" {
+		t.Errorf("order = %v", order)
+	}
+	if Agent(context.Background()) != "" {
+		t.Error("agent named outside a chain")
+	}
+	if _, ok := Chain("writer", agent).(HandlerFunc); !ok {
+		t.Error("empty chain wrapped the agent")
+	}
+}
+
+func TestBuiltins(t *testing.T) {
+	mws, err := Builtins(Config{SlowThreshold: "5s", Redact: []string{`\bsecret\b`}}, NewMetrics())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"logging", "timing", "redact", "metrics"} {
+		if mws[name] == nil {
+			t.Errorf("no %s middleware", name)
+		}
+	}
+	for _, cfg := range []Config{{SlowThreshold: "soon"}, {Redact: []string{"("}}} {
+		if _, err := Builtins(cfg, NewMetrics()); err == nil {
+			t.Errorf("config %+v accepted", cfg)
+		}
+	}
+}
+
+func TestForwardMetadata(t *testing.T) {
+	agent := HandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+		out := core.NewState()
+		out.SetMeta(core.RouteMetadataKey, "editor")
+		out.SetMeta("tone", "formal")
+		return core.AgentResult{OutputState: out}, nil
+	})
+	in := core.NewEvent("writer", nil, map[string]string{
+		history.RunIDKey:      "run-1",
+		"tone":                "casual",
+		core.RouteMetadataKey: "writer",
+		core.SessionIDKey:     "s1",
+		"status":              "retry",
+	})
+	result, err := ForwardMetadata(agent).Run(context.Background(), in, core.NewState())
+	if err != nil {
+		t.Fatal(err)
+	}
+	meta := func(key string) string {
+		v, _ := result.OutputState.GetMeta(key)
+		return v
+	}
+	if meta(history.RunIDKey) != "run-1" || meta("tone") != "formal" || meta(core.RouteMetadataKey) != "editor" || meta(core.SessionIDKey) != "" || meta("status") != "" {
+		t.Errorf("metadata = %v", result.OutputState)
+	}
+	if _, err := ForwardMetadata(routeTo("")).Run(context.Background(), in, core.NewState()); err == nil {
+		t.Error("agent error lost")
+	}
+}
+
+func TestTenant(t *testing.T) {
+	var got string
+	agent := HandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+		got = tenant.FromContext(ctx)
+		return core.AgentResult{}, nil
+	})
+	in := core.NewEvent("writer", nil, map[string]string{tenant.MetadataKey: "acme"})
+	if _, err := Tenant(agent).Run(context.Background(), in, core.NewState()); err != nil || got != "acme" {
+		t.Errorf("tenant = %q, %v", got, err)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	buf := captureLog(t)
+	ctx := context.Background()
+	Chain("writer", routeTo("editor"), Logging).Run(ctx, event(), core.NewState())
+	Chain("editor", routeTo(""), Logging).Run(ctx, event(), core.NewState())
+	end := HandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+		return core.AgentResult{OutputState: core.NewState()}, nil
+	})
+	Chain("formatter", end, Logging).Run(ctx, event(), core.NewState())
+	for _, line := range []string{
+		"writer started (run run-1)",
+		"writer finished (run run-1) → editor",
+		"editor failed (run run-1): model down",
+		"formatter finished (run run-1) → end",
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("log lacks %q:\n%s", line, buf)
+		}
+	}
+}
+
+func TestTiming(t *testing.T) {
+	buf := captureLog(t)
+	ctx := context.Background()
+	slow := HandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+		time.Sleep(5 * time.Millisecond)
+		return core.AgentResult{}, nil
+	})
+	Chain("writer", routeTo("editor"), Timing(time.Second)).Run(ctx, event(), core.NewState())
+	Chain("editor", slow, Timing(time.Millisecond)).Run(ctx, event(), core.NewState())
+	if !strings.Contains(buf.String(), "⏱️ writer took") || !strings.Contains(buf.String(), "🐢 editor took") || !strings.Contains(buf.String(), "over 1ms (run run-1)") {
+		t.Errorf("log:\n%s", buf)
+	}
+}
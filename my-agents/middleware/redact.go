@@ -0,0 +1,63 @@
+package middleware
+
+import (
+	"context"
+	"regexp"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Redacted replaces the text redact masks.
+const Redacted = "[redacted]"
+
+// defaultRedactions mask email addresses and card numbers.
+var defaultRedactions = []*regexp.Regexp{
+	regexp.MustCompile(`[\w.+-]+@[\w-]+(\.[\w-]+)+`),
+	regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+}
+
+// Redact masks text matching patterns in the event data and state an agent
+// is handed, so it never reaches the agent's prompts. The agent's output
+// carries the masked text on to later agents.
+func Redact(patterns ...*regexp.Regexp) Middleware {
+	mask := func(v any) any {
+		s, ok := v.(string)
+		if !ok {
+			return v
+		}
+		for _, re := range patterns {
+			s = re.ReplaceAllString(s, Redacted)
+		}
+		return s
+	}
+	return func(next core.AgentHandler) core.AgentHandler {
+		return HandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+			data := make(core.EventData, len(event.GetData()))
+			for k, v := range event.GetData() {
+				data[k] = mask(v)
+			}
+			if state != nil {
+				state = state.Clone()
+				for _, k := range state.Keys() {
+					if v, ok := state.Get(k); ok {
+						state.Set(k, mask(v))
+					}
+				}
+			}
+			return next.Run(ctx, &redactedEvent{Event: event, data: data}, state)
+		})
+	}
+}
+
+// redactedEvent is an event with its data masked.
+type redactedEvent struct {
+	core.Event
+	data core.EventData
+}
+
+func (e *redactedEvent) GetData() core.EventData { return e.data }
+
+func (e *redactedEvent) SetData(key string, value any) {
+	e.data[key] = value
+	e.Event.SetData(key, value)
+}
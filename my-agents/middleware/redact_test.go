@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+func TestRedact(t *testing.T) {
+	var gotData core.EventData
+	var gotState core.State
+	agent := HandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+		gotData, gotState = event.GetData(), state
+		event.SetData("reply", "ok")
+		return core.AgentResult{}, nil
+	})
+	mws, err := Builtins(Config{Redact: []string{`\b\d{3}-\d{2}-\d{4}\b`}}, NewMetrics())
+	if err != nil {
+		t.Fatal(err)
+	}
+	in := core.NewEvent("writer", core.EventData{
+		"input": "Mail ann@example.com, SSN 123-45-6789",
+		"count": 3,
+	}, nil)
+	state := core.NewState()
+	state.Set("notes", "card 4111 1111 1111 1111 on file")
+	if _, err := Chain("writer", agent, mws["redact"]).Run(context.Background(), in, state); err != nil {
+		t.Fatal(err)
+	}
+
+	if gotData["input"] != "Mail [redacted], SSN [redacted]" || gotData["count"] != 3 {
+		t.Errorf("agent saw data %v", gotData)
+	}
+	if notes, _ := gotState.Get("notes"); notes != "card [redacted] on file" {
+		t.Errorf("agent saw notes %q", notes)
+	}
+	// The caller's event and state keep the text
+	if in.GetData()["input"] != "Mail ann@example.com, SSN 123-45-6789" {
+		t.Errorf("event data masked: %v", in.GetData())
+	}
+	if notes, _ := state.Get("notes"); notes != "card 4111 1111 1111 1111 on file" {
+		t.Errorf("state masked: %q", notes)
+	}
+	// What the agent sets on the event reaches it
+	if in.GetData()["reply"] != "ok" {
+		t.Error("agent's data lost")
+	}
+}
+
+func TestRedactNilState(t *testing.T) {
+	agent := HandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+		if state != nil {
+			t.Error("state made up")
+		}
+		return core.AgentResult{}, nil
+	})
+	Redact(regexp.MustCompile("x"))(agent).Run(context.Background(), core.NewEvent("writer", nil, nil), nil)
+}
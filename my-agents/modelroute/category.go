@@ -0,0 +1,41 @@
+package modelroute
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Request categories tracked for per-category performance.
+const (
+	CategoryCode        = "code"
+	CategoryMath        = "math"
+	CategoryData        = "data"
+	CategoryTranslation = "translation"
+	CategoryCreative    = "creative"
+	CategoryGeneral     = "general"
+)
+
+// categorySignals are checked in order; the first category with a hit wins.
+var categorySignals = []struct {
+	category string
+	signals  []*regexp.Regexp
+}{
+	{CategoryCode, signals("```", "function", "compile", "stack trace", "exception", "golang", "python", "javascript", "sql", "regex", "api", "bug")},
+	{CategoryMath, signals("calculate", "equation", "integral", "derivative", "probability", "prove", "solve for", "percent", "%")},
+	{CategoryData, signals("spreadsheet", "csv", "table", "column", "dataset", "chart", "average", "median", "total")},
+	{CategoryTranslation, signals("translate", "translation", "in french", "in german", "in spanish", "into english")},
+	{CategoryCreative, signals("write a story", "poem", "slogan", "tagline", "brainstorm", "creative", "fiction", "lyrics")},
+}
+
+// Categorize assigns a request to a category by keyword.
+func Categorize(text string) string {
+	text = strings.ToLower(text)
+	for _, c := range categorySignals {
+		for _, s := range c.signals {
+			if s.MatchString(text) {
+				return c.category
+			}
+		}
+	}
+	return CategoryGeneral
+}
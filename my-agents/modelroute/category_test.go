@@ -0,0 +1,20 @@
+package modelroute
+
+import "testing"
+
+func TestCategorize(t *testing.T) {
+	for text, want := range map[string]string{
+		"Why does this Python function throw an exception?": CategoryCode,
+		"Calculate 15% of the invoice":                      CategoryMath,
+		"Average the totals in this CSV":                    CategoryData,
+		"Translate this into English":                       CategoryTranslation,
+		"Write a poem about autumn":                         CategoryCreative,
+		"What are your opening hours?":                      CategoryGeneral,
+		// Not "api" inside "capital"
+		"What is the capital of Peru?": CategoryGeneral,
+	} {
+		if got := Categorize(text); got != want {
+			t.Errorf("Categorize(%q) = %s, want %s", text, got, want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package modelroute
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Difficulty buckets requests for routing and quality stats.
+type Difficulty string
+
+const (
+	Easy   Difficulty = "easy"
+	Medium Difficulty = "medium"
+	Hard   Difficulty = "hard"
+)
+
+// Difficulties lists the buckets from easiest to hardest.
+var Difficulties = []Difficulty{Easy, Medium, Hard}
+
+// hardSignals are phrases that usually mean multi-step reasoning or precise
+// output a small model gets wrong.
+var hardSignals = signals(
+	"step by step", "prove", "derive", "analyze", "analyse", "compare", "trade-off", "tradeoff",
+	"design", "architecture", "optimize", "debug", "refactor", "algorithm", "calculate",
+	"evaluate", "critique", "plan", "strategy", "why does", "root cause",
+)
+
+// signals compiles phrases to match as words, so one doesn't fire inside
+// another word ("plan" in "explain", "prove" in "improve"). A phrase
+// ending in a letter also matches its -s, -es, -ed and -ing forms.
+func signals(phrases ...string) []*regexp.Regexp {
+	res := make([]*regexp.Regexp, len(phrases))
+	for i, p := range phrases {
+		pattern := regexp.QuoteMeta(p)
+		if first := []rune(p)[0]; unicode.IsLetter(first) || unicode.IsDigit(first) {
+			pattern = `(?:^|[^\pL\pN_])` + pattern
+		}
+		if last := []rune(p)[len([]rune(p))-1]; unicode.IsLetter(last) || unicode.IsDigit(last) {
+			pattern += `(?:s|es|ed|d|ing)?(?:$|[^\pL\pN_])`
+		}
+		res[i] = regexp.MustCompile(pattern)
+	}
+	return res
+}
+
+// Classify estimates how demanding prompt is from its length, structure and
+// wording. It is deliberately cheap: it runs before every routed call.
+func Classify(prompt core.Prompt) Difficulty {
+	text := strings.ToLower(prompt.System + "\n" + prompt.User)
+	score := 0.0
+
+	words := len(strings.Fields(prompt.User))
+	switch {
+	case words > 400:
+		score += 2
+	case words > 120:
+		score += 1
+	}
+	for _, s := range hardSignals {
+		if s.MatchString(text) {
+			score++
+		}
+	}
+	if strings.Contains(prompt.User, "```") {
+		score++
+	}
+	if q := strings.Count(prompt.User, "?"); q > 2 {
+		score++
+	}
+	// Numbered or bulleted requirements list several things to satisfy
+	lines := 0
+	for _, line := range strings.Split(prompt.User, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "* ") || (len(line) > 2 && line[0] >= '1' && line[0] <= '9' && line[1] == '.') {
+			lines++
+		}
+	}
+	if lines >= 3 {
+		score++
+	}
+
+	switch {
+	case score >= 3:
+		return Hard
+	case score >= 1:
+		return Medium
+	default:
+		return Easy
+	}
+}
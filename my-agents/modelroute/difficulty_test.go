@@ -0,0 +1,40 @@
+package modelroute
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+func TestClassify(t *testing.T) {
+	for user, want := range map[string]Difficulty{
+		"What is the capital of France?": Easy,
+		"Please explain photosynthesis":  Easy,
+		"How can I improve my essay?":    Easy,
+		"Compare these two plans":        Medium,
+		"Design an algorithm, analyze its complexity and debug this:\n```\nx\n```": Hard,
+		"Requirements:\n- a\n- b\n- c\nCompare the trade-offs and plan it":         Hard,
+		strings.Repeat("word ", 150): Medium,
+	} {
+		if got := Classify(core.Prompt{User: user}); got != want {
+			t.Errorf("Classify(%.40q) = %s, want %s", user, got, want)
+		}
+	}
+}
+
+func TestSignalsMatchWords(t *testing.T) {
+	re := signals("design", "%")
+	for text, want := range map[string]bool{
+		"make a design":   true,
+		"it was designed": true,
+		"a redesign":      false,
+		"the designer":    false,
+		"up 5% this year": true,
+	} {
+		got := re[0].MatchString(text) || re[1].MatchString(text)
+		if got != want {
+			t.Errorf("signals matched %q = %v, want %v", text, got, want)
+		}
+	}
+}
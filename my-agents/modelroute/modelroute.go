@@ -0,0 +1,323 @@
+// Package modelroute is a model provider that sends each request to the
+// cheapest configured model predicted to handle it well, based on a
+// difficulty estimate and each model's historical quality, and tracks the
+// savings against always using the baseline model. Quality is also tracked
+// per agent and request category, updated online from run feedback.
+package modelroute
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+// DefaultMinQuality is used when Config.MinQuality is zero.
+const DefaultMinQuality = 0.7
+
+// DefaultMinSamples is used when Config.MinSamples is zero.
+const DefaultMinSamples = 5
+
+// DefaultName is the provider name agents use to opt into routing.
+const DefaultName = "auto"
+
+// Config is the [model_routing] section of agentflow.toml:
+//
+//	[model_routing]
+//	enabled = true
+//	[[model_routing.models]]
+//	provider = "small"
+//	cost_per_1k_tokens = 0.0002
+//	quality = { easy = 0.9, medium = 0.7, hard = 0.4 }
+//	[[model_routing.models]]
+//	provider = "large"
+//	cost_per_1k_tokens = 0.01
+//	quality = { easy = 0.95, medium = 0.9, hard = 0.85 }
+//
+// Agents opt in with provider = "auto" (or Name).
+type Config struct {
+	Enabled    bool          `toml:"enabled"`
+	Name       string        `toml:"name"`        // provider name (default DefaultName)
+	MinQuality float64       `toml:"min_quality"` // 0-1 (default DefaultMinQuality)
+	Baseline   string        `toml:"baseline"`    // model savings are measured against (default the most expensive)
+	StatsPath  string        `toml:"stats_path"`  // default .agentflow/model_stats.json
+	Models     []ModelConfig `toml:"models"`
+	// PreferBest picks the model with the best predicted quality for the
+	// agent and request category instead of the cheapest adequate one.
+	PreferBest bool `toml:"prefer_best"`
+	// MinSamples is how many results an agent/category/model needs before
+	// its own history overrides the difficulty-level estimate.
+	MinSamples int `toml:"min_samples"`
+}
+
+// ModelConfig is one routable model.
+type ModelConfig struct {
+	Provider        string  `toml:"provider"` // [providers.<name>]; "default" for the main provider
+	CostPer1KTokens float64 `toml:"cost_per_1k_tokens"`
+	// Quality is the expected 0-1 quality per difficulty before any history
+	// exists; missing difficulties default to 0.5.
+	Quality map[string]float64 `toml:"quality"`
+}
+
+// Model is a routable model with its resolved provider.
+type Model struct {
+	ModelConfig
+	LLM core.ModelProvider
+}
+
+func (m Model) prior(d Difficulty) float64 {
+	if q, ok := m.Quality[string(d)]; ok {
+		return q
+	}
+	return 0.5
+}
+
+// Router implements core.ModelProvider over the configured models.
+type Router struct {
+	models     []Model // cheapest first
+	minQuality float64
+	minSamples int
+	preferBest bool
+	baseline   Model
+	stats      *Stats
+
+	mu      sync.Mutex
+	current map[string]string // agent → run it is serving
+}
+
+// New creates a router. Stats are loaded from cfg.StatsPath.
+func New(cfg Config, models []Model) (*Router, error) {
+	if len(models) == 0 {
+		return nil, fmt.Errorf("model routing needs at least one model")
+	}
+	stats, err := LoadStats(cfg.StatsPath)
+	if err != nil {
+		return nil, err
+	}
+	r := &Router{
+		models:     append([]Model(nil), models...),
+		minQuality: cfg.MinQuality,
+		minSamples: cfg.MinSamples,
+		preferBest: cfg.PreferBest,
+		stats:      stats,
+		current:    make(map[string]string),
+	}
+	if r.minQuality == 0 {
+		r.minQuality = DefaultMinQuality
+	}
+	if r.minSamples == 0 {
+		r.minSamples = DefaultMinSamples
+	}
+	sort.SliceStable(r.models, func(i, j int) bool { return r.models[i].CostPer1KTokens < r.models[j].CostPer1KTokens })
+	r.baseline = r.models[len(r.models)-1]
+	if cfg.Baseline != "" {
+		found := false
+		for _, m := range r.models {
+			if m.Provider == cfg.Baseline {
+				r.baseline, found = m, true
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("baseline model %q is not in [[model_routing.models]]", cfg.Baseline)
+		}
+	}
+	return r, nil
+}
+
+// Stats returns the router's routing history.
+func (r *Router) Stats() *Stats { return r.stats }
+
+// Register tracks which run each agent is serving, so routed calls can be
+// credited when feedback on the run arrives. The default runner handles one
+// event at a time, so an agent serves at most one run at once.
+func (r *Router) Register(runner core.Runner) error {
+	return runner.RegisterCallback(core.HookBeforeAgentRun, "model-routing", func(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+		if args.Event != nil {
+			runID, _ := args.Event.GetMetadataValue(history.RunIDKey)
+			r.mu.Lock()
+			r.current[args.AgentID] = runID
+			r.mu.Unlock()
+		}
+		return args.State, nil
+	})
+}
+
+// ForAgent returns a provider whose calls are attributed to agent.
+func (r *Router) ForAgent(agent string) core.ModelProvider {
+	return &agentRouter{Router: r, agent: agent}
+}
+
+// Choice is a routing decision.
+type Choice struct {
+	Difficulty Difficulty
+	Category   string
+	Model      Model
+	Predicted  float64
+}
+
+// predict estimates m's quality for agent's request, using the agent and
+// category history once it has enough samples.
+func (r *Router) predict(m Model, agent, category string, d Difficulty) float64 {
+	q := r.stats.Quality(m.Provider, d, m.prior(d))
+	if cq, n := r.stats.CategoryQuality(agent, category, m.Provider, q); n >= r.minSamples {
+		return cq
+	}
+	return q
+}
+
+// Choose picks the cheapest model predicted to reach the minimum quality for
+// agent's prompt, or the best predicted model when none does or PreferBest
+// is set.
+func (r *Router) Choose(agent string, prompt core.Prompt) Choice {
+	d := Classify(prompt)
+	category := Categorize(prompt.User)
+	var best Choice
+	for _, m := range r.models {
+		q := r.predict(m, agent, category, d)
+		if q >= r.minQuality && !r.preferBest {
+			return Choice{Difficulty: d, Category: category, Model: m, Predicted: q}
+		}
+		// Cheaper models win ties
+		if best.Model.LLM == nil || q > best.Predicted+1e-9 {
+			best = Choice{Difficulty: d, Category: category, Model: m, Predicted: q}
+		}
+	}
+	return best
+}
+
+// candidates orders the models to try: the choice first, then the others
+// by predicted quality.
+func (r *Router) candidates(agent string, c Choice) []Model {
+	rest := make([]Model, 0, len(r.models)-1)
+	for _, m := range r.models {
+		if m.Provider != c.Model.Provider {
+			rest = append(rest, m)
+		}
+	}
+	sort.SliceStable(rest, func(i, j int) bool {
+		return r.predict(rest[i], agent, c.Category, c.Difficulty) > r.predict(rest[j], agent, c.Category, c.Difficulty)
+	})
+	return append([]Model{c.Model}, rest...)
+}
+
+// Call routes prompt, falling back to the next candidate when a model fails.
+func (r *Router) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	return r.call(ctx, "", prompt)
+}
+
+// Stream routes prompt to the chosen model without fallback; the call is
+// recorded once the stream ends.
+func (r *Router) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	return r.stream(ctx, "", prompt)
+}
+
+func (r *Router) call(ctx context.Context, agent string, prompt core.Prompt) (core.Response, error) {
+	choice := r.Choose(agent, prompt)
+	var lastErr error
+	for _, m := range r.candidates(agent, choice) {
+		resp, err := m.LLM.Call(ctx, prompt)
+		if err != nil {
+			r.record(agent, choice, m, true, 0)
+			core.Logger().Warn().Str("model", m.Provider).Err(err).Msg("Routed model failed; trying next")
+			lastErr = err
+			if ctx.Err() != nil {
+				break
+			}
+			continue
+		}
+		r.record(agent, choice, m, false, tokens(prompt, resp))
+		return resp, nil
+	}
+	return core.Response{}, lastErr
+}
+
+func (r *Router) stream(ctx context.Context, agent string, prompt core.Prompt) (<-chan core.Token, error) {
+	choice := r.Choose(agent, prompt)
+	in, err := choice.Model.LLM.Stream(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan core.Token)
+	go func() {
+		defer close(out)
+		var b strings.Builder
+		failed := false
+		for tok := range in {
+			if tok.Error != nil {
+				failed = true
+			}
+			b.WriteString(tok.Content)
+			out <- tok
+		}
+		r.record(agent, choice, choice.Model, failed, tokens(prompt, core.Response{Content: b.String()}))
+	}()
+	return out, nil
+}
+
+// Embeddings uses the baseline model so vectors stay comparable.
+func (r *Router) Embeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	return r.baseline.LLM.Embeddings(ctx, texts)
+}
+
+// Feedback credits a 0-1 quality score, e.g. from a critic, a user rating
+// or a simulation judge, to the models that served a run. It reports false
+// when the run made no routed calls or was already rated.
+func (r *Router) Feedback(runID string, quality float64) bool {
+	if !r.stats.rate(runID, quality) {
+		return false
+	}
+	r.save()
+	return true
+}
+
+func (r *Router) record(agent string, c Choice, m Model, failed bool, tokens int) {
+	r.mu.Lock()
+	runID := r.current[agent]
+	r.mu.Unlock()
+	cost := m.CostPer1KTokens * float64(tokens) / 1000
+	baseline := r.baseline.CostPer1KTokens * float64(tokens) / 1000
+	d := Decision{Agent: agent, Category: c.Category, Difficulty: c.Difficulty, Model: m.Provider}
+	r.stats.record(runID, d, failed, cost, baseline)
+	core.Logger().Debug().Str("agent", agent).Str("model", m.Provider).Str("category", c.Category).
+		Str("difficulty", string(c.Difficulty)).Int("tokens", tokens).Float64("cost", cost).Float64("saved", baseline-cost).Msg("Routed model call")
+	r.save()
+}
+
+func (r *Router) save() {
+	if err := r.stats.Save(); err != nil {
+		core.Logger().Error().Err(err).Msg("Failed to save model stats")
+	}
+}
+
+// tokens uses the provider's usage report, estimating from text length
+// (about four characters per token) when the provider gives none.
+func tokens(prompt core.Prompt, resp core.Response) int {
+	if resp.Usage.TotalTokens > 0 {
+		return resp.Usage.TotalTokens
+	}
+	return (len(prompt.System) + len(prompt.User) + len(resp.Content) + 3) / 4
+}
+
+// agentRouter attributes a router's calls to one agent.
+type agentRouter struct {
+	*Router
+	agent string
+}
+
+func (a *agentRouter) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	return a.call(ctx, a.agent, prompt)
+}
+
+func (a *agentRouter) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	return a.stream(ctx, a.agent, prompt)
+}
+
+// Route returns the provider of the model a call of prompt goes to first.
+func (a *agentRouter) Route(prompt core.Prompt) string {
+	return a.Choose(a.agent, prompt).Model.Provider
+}
@@ -0,0 +1,152 @@
+package modelroute
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+// model answers with its name, or fails when err is set.
+type model struct {
+	core.ModelProvider
+	name  string
+	err   error
+	calls int
+}
+
+func (m *model) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	m.calls++
+	if m.err != nil {
+		return core.Response{}, m.err
+	}
+	return core.Response{Content: m.name, Usage: core.UsageStats{TotalTokens: 1000}}, nil
+}
+
+func newRouter(t *testing.T, cfg Config, small, large *model) *Router {
+	t.Helper()
+	cfg.StatsPath = filepath.Join(t.TempDir(), "model_stats.json")
+	r, err := New(cfg, []Model{
+		{ModelConfig{Provider: "large", CostPer1KTokens: 0.01, Quality: map[string]float64{"easy": 0.95, "hard": 0.9}}, large},
+		{ModelConfig{Provider: "small", CostPer1KTokens: 0.001, Quality: map[string]float64{"easy": 0.9, "hard": 0.4}}, small},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestChoose(t *testing.T) {
+	r := newRouter(t, Config{}, &model{name: "small"}, &model{name: "large"})
+	if got := r.Choose("", core.Prompt{User: "hi"}); got.Model.Provider != "small" || got.Difficulty != Easy {
+		t.Errorf("easy prompt chose %+v", got)
+	}
+	hard := core.Prompt{User: "Design an algorithm, analyze it and debug it step by step"}
+	if got := r.Choose("", hard); got.Model.Provider != "large" {
+		t.Errorf("hard prompt chose %s", got.Model.Provider)
+	}
+	// Nothing reaches the bar: the best predicted model
+	r.minQuality = 0.99
+	if got := r.Choose("", core.Prompt{User: "hi"}); got.Model.Provider != "large" {
+		t.Errorf("chose %s, want the best model", got.Model.Provider)
+	}
+}
+
+func TestCallSavings(t *testing.T) {
+	small, large := &model{name: "small"}, &model{name: "large"}
+	r := newRouter(t, Config{}, small, large)
+	resp, err := r.Call(context.Background(), core.Prompt{User: "hi"})
+	if err != nil || resp.Content != "small" {
+		t.Fatalf("Call = %q, %v", resp.Content, err)
+	}
+	s := r.Stats().Savings
+	if s.Calls != 1 || s.Cost != 0.001 || s.BaselineCost != 0.01 {
+		t.Errorf("Savings = %+v", s)
+	}
+}
+
+func TestCallFallsBack(t *testing.T) {
+	small, large := &model{name: "small", err: errors.New("overloaded")}, &model{name: "large"}
+	r := newRouter(t, Config{}, small, large)
+	resp, err := r.Call(context.Background(), core.Prompt{User: "hi"})
+	if err != nil || resp.Content != "large" {
+		t.Fatalf("Call = %q, %v", resp.Content, err)
+	}
+	if o := r.Stats().Models["small"][Easy]; o.Failures != 1 {
+		t.Errorf("small outcome = %+v, want the failure recorded", o)
+	}
+
+	large.err = errors.New("down")
+	if _, err := r.Call(context.Background(), core.Prompt{User: "hi"}); err == nil {
+		t.Error("Call succeeded with every model failing")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if _, err := New(Config{}, nil); err == nil {
+		t.Error("New accepted no models")
+	}
+	path := filepath.Join(t.TempDir(), "model_stats.json")
+	models := []Model{{ModelConfig: ModelConfig{Provider: "small"}}}
+	if _, err := New(Config{Baseline: "huge", StatsPath: path}, models); err == nil {
+		t.Error("New accepted an unknown baseline")
+	}
+}
+
+// registry captures the callback registered on it.
+type registry struct {
+	core.Runner
+	callback core.CallbackFunc
+}
+
+func (r *registry) RegisterCallback(hook core.HookPoint, name string, cb core.CallbackFunc) error {
+	r.callback = cb
+	return nil
+}
+
+func TestFeedbackByCategory(t *testing.T) {
+	small, large := &model{name: "small"}, &model{name: "large"}
+	r := newRouter(t, Config{MinSamples: 2, MinQuality: 0.6}, small, large)
+	runner := &registry{}
+	if err := r.Register(runner); err != nil {
+		t.Fatal(err)
+	}
+	writer := r.ForAgent("writer")
+	poem := core.Prompt{User: "Write a poem about rain"}
+
+	// The writer's poems from the small model are rated badly, twice
+	for _, runID := range []string{"run-1", "run-2"} {
+		event := core.NewEvent("writer", nil, map[string]string{history.RunIDKey: runID})
+		runner.callback(context.Background(), core.CallbackArgs{AgentID: "writer", Event: event})
+		if _, err := writer.Call(context.Background(), poem); err != nil {
+			t.Fatal(err)
+		}
+		if !r.Feedback(runID, 0.1) {
+			t.Fatalf("Feedback(%s) found no routed calls", runID)
+		}
+	}
+	if r.Feedback("run-1", 1) {
+		t.Error("a run was rated twice")
+	}
+
+	if got := r.Choose("writer", poem).Model.Provider; got != "large" {
+		t.Errorf("writer's poems go to %s, want large after bad ratings", got)
+	}
+	if got := r.Choose("writer", core.Prompt{User: "What are your hours?"}).Model.Provider; got != "small" {
+		t.Errorf("writer's other requests go to %s", got)
+	}
+	if got := r.Choose("critic", poem).Model.Provider; got != "small" {
+		t.Errorf("critic's poems go to %s", got)
+	}
+}
+
+func TestPreferBest(t *testing.T) {
+	r := newRouter(t, Config{PreferBest: true}, &model{name: "small"}, &model{name: "large"})
+	if got := r.Choose("", core.Prompt{User: "hi"}).Model.Provider; got != "large" {
+		t.Errorf("chose %s, want the best model", got)
+	}
+}
@@ -0,0 +1,224 @@
+package modelroute
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// priorWeight is how many observations the configured quality prior is
+// worth before historical results take over.
+const priorWeight = 5
+
+// maxRuns bounds the routing decisions kept for run feedback.
+const maxRuns = 500
+
+// Outcome aggregates the calls a model served in one bucket.
+type Outcome struct {
+	Calls      int     `json:"calls"`
+	Failures   int     `json:"failures"`
+	QualitySum float64 `json:"quality_sum"` // sum of 0-1 quality scores
+	Rated      int     `json:"rated"`       // calls with a quality score
+}
+
+// Samples is the number of observations behind the outcome.
+func (o *Outcome) Samples() int {
+	return o.Failures + max(o.Calls-o.Failures, o.Rated)
+}
+
+// quality blends prior with the ratings and failure rate observed so far.
+func (o *Outcome) quality(prior float64) float64 {
+	if o == nil || o.Calls == 0 {
+		return prior
+	}
+	// Unrated successful calls count at the prior; failures count as zero
+	unrated := max(o.Calls-o.Failures-o.Rated, 0)
+	sum := prior*priorWeight + o.QualitySum + prior*float64(unrated)
+	return sum / float64(priorWeight+o.Failures+o.Rated+unrated)
+}
+
+// Savings is the realized cost of routed calls against always using the
+// baseline model.
+type Savings struct {
+	Calls        int     `json:"calls"`
+	Cost         float64 `json:"cost"`
+	BaselineCost float64 `json:"baseline_cost"`
+}
+
+// Saved is the amount saved so far.
+func (s Savings) Saved() float64 { return s.BaselineCost - s.Cost }
+
+// Decision is one routed call, kept so feedback on its run can be credited
+// to the model that answered.
+type Decision struct {
+	Agent      string     `json:"agent,omitempty"`
+	Category   string     `json:"category"`
+	Difficulty Difficulty `json:"difficulty"`
+	Model      string     `json:"model"`
+}
+
+// Stats is the persisted routing history.
+type Stats struct {
+	Models map[string]map[Difficulty]*Outcome `json:"models"`
+	// Categories holds per-agent results by request category, keyed
+	// "agent/category" and then by model.
+	Categories map[string]map[string]*Outcome `json:"categories"`
+	Runs       map[string][]Decision          `json:"runs,omitempty"`
+	RunOrder   []string                       `json:"run_order,omitempty"`
+	Savings    Savings                        `json:"savings"`
+	UpdatedAt  time.Time                      `json:"updated_at"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// LoadStats reads stats from path (default .agentflow/model_stats.json);
+// a missing file starts empty.
+func LoadStats(path string) (*Stats, error) {
+	if path == "" {
+		path = filepath.Join(".agentflow", "model_stats.json")
+	}
+	s := &Stats{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, s); err != nil {
+			return nil, fmt.Errorf("failed to decode model stats %s: %w", path, err)
+		}
+	}
+	if s.Models == nil {
+		s.Models = make(map[string]map[Difficulty]*Outcome)
+	}
+	if s.Categories == nil {
+		s.Categories = make(map[string]map[string]*Outcome)
+	}
+	if s.Runs == nil {
+		s.Runs = make(map[string][]Decision)
+	}
+	return s, nil
+}
+
+// CategoryKey is the Categories key for an agent's requests in category.
+func CategoryKey(agent, category string) string {
+	return agent + "/" + category
+}
+
+func outcome[K comparable](m map[K]*Outcome, key K) *Outcome {
+	o, ok := m[key]
+	if !ok {
+		o = &Outcome{}
+		m[key] = o
+	}
+	return o
+}
+
+func (s *Stats) outcomes(d Decision) (*Outcome, *Outcome) {
+	byDifficulty, ok := s.Models[d.Model]
+	if !ok {
+		byDifficulty = make(map[Difficulty]*Outcome)
+		s.Models[d.Model] = byDifficulty
+	}
+	key := CategoryKey(d.Agent, d.Category)
+	byModel, ok := s.Categories[key]
+	if !ok {
+		byModel = make(map[string]*Outcome)
+		s.Categories[key] = byModel
+	}
+	return outcome(byDifficulty, d.Difficulty), outcome(byModel, d.Model)
+}
+
+// Quality predicts a model's 0-1 quality at difficulty d, blending prior
+// with the ratings and failure rate observed so far.
+func (s *Stats) Quality(model string, d Difficulty, prior float64) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Models[model][d].quality(prior)
+}
+
+// CategoryQuality predicts a model's quality for an agent's requests in a
+// category, and reports how many observations back the estimate.
+func (s *Stats) CategoryQuality(agent, category, model string, prior float64) (float64, int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.Categories[CategoryKey(agent, category)][model]
+	if o == nil {
+		return prior, 0
+	}
+	return o.quality(prior), o.Samples()
+}
+
+func (s *Stats) record(runID string, d Decision, failed bool, cost, baselineCost float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	byDifficulty, byCategory := s.outcomes(d)
+	for _, o := range []*Outcome{byDifficulty, byCategory} {
+		o.Calls++
+		if failed {
+			o.Failures++
+		}
+	}
+	if failed {
+		return
+	}
+	s.Savings.Calls++
+	s.Savings.Cost += cost
+	s.Savings.BaselineCost += baselineCost
+	if runID == "" {
+		return
+	}
+	if _, ok := s.Runs[runID]; !ok {
+		s.RunOrder = append(s.RunOrder, runID)
+		if len(s.RunOrder) > maxRuns {
+			delete(s.Runs, s.RunOrder[0])
+			s.RunOrder = s.RunOrder[1:]
+		}
+	}
+	s.Runs[runID] = append(s.Runs[runID], d)
+}
+
+// rate credits a 0-1 quality score to every decision made in a run. It
+// reports false when the run made no routed calls.
+func (s *Stats) rate(runID string, quality float64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	decisions, ok := s.Runs[runID]
+	if !ok {
+		return false
+	}
+	quality = min(max(quality, 0), 1)
+	for _, d := range decisions {
+		byDifficulty, byCategory := s.outcomes(d)
+		for _, o := range []*Outcome{byDifficulty, byCategory} {
+			o.Rated++
+			o.QualitySum += quality
+		}
+	}
+	// Each run is rated once
+	delete(s.Runs, runID)
+	return true
+}
+
+// Save writes the stats to their file.
+func (s *Stats) Save() error {
+	s.mu.Lock()
+	s.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(s, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,45 @@
+package modelroute
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutcomeQuality(t *testing.T) {
+	var none *Outcome
+	if got := none.quality(0.8); got != 0.8 {
+		t.Errorf("quality without history = %v", got)
+	}
+	// Five failures weigh as much as the prior
+	o := &Outcome{Calls: 5, Failures: 5}
+	if got := o.quality(0.8); math.Abs(got-0.4) > 1e-9 {
+		t.Errorf("quality after failures = %v, want 0.4", got)
+	}
+	o = &Outcome{Calls: 5, Rated: 5, QualitySum: 5}
+	if got := o.quality(0.5); math.Abs(got-0.75) > 1e-9 {
+		t.Errorf("quality after perfect ratings = %v, want 0.75", got)
+	}
+}
+
+func TestStatsSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "stats", "model_stats.json")
+	s, err := LoadStats(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.record("run-1", Decision{Difficulty: Easy, Model: "small"}, false, 0.1, 1)
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := LoadStats(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o := loaded.Models["small"][Easy]; o == nil || o.Calls != 1 {
+		t.Errorf("loaded outcome = %+v", o)
+	}
+	if got := loaded.Savings.Saved(); math.Abs(got-0.9) > 1e-9 {
+		t.Errorf("Saved = %v", got)
+	}
+}
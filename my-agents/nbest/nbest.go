@@ -0,0 +1,146 @@
+// Package nbest trades cost for quality on critical stages: an agent
+// generates several candidate completions for the same prompt, a judge —
+// usually a cheaper model — rates each, and the best-rated one is kept.
+package nbest
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Key is the state key holding the agent's Pick.
+const Key = "n_best"
+
+// MaxCandidates bounds Candidates.
+const MaxCandidates = 8
+
+// Config is an agent's [agents.<name>.n_best] table:
+//
+//	[agents.formatter.n_best]
+//	candidates = 2
+//	judge = "cheap"
+//	criteria = "accurate, well-structured and faithful to the draft"
+type Config struct {
+	// Candidates generated per prompt; 0 or 1 generates one as usual.
+	Candidates int `toml:"candidates"`
+	// Judge names a [providers.<name>] table rating the candidates; empty
+	// uses the agent's own provider.
+	Judge string `toml:"judge"`
+	// Criteria tells the judge what makes a candidate better (default
+	// helpful, correct and clear).
+	Criteria string `toml:"criteria"`
+}
+
+// Enabled reports whether c generates more than one candidate.
+func (c *Config) Enabled() bool {
+	return c != nil && c.Candidates > 1
+}
+
+// Pick records how a completion was chosen.
+type Pick struct {
+	Candidates int       `json:"candidates"` // generated successfully
+	Chosen     int       `json:"chosen"`     // index of the kept candidate
+	Scores     []float64 `json:"scores"`     // the judge's 0-10 ratings; -1 where it failed
+}
+
+// Selector generates candidates and keeps the best.
+type Selector struct {
+	cfg   Config
+	judge core.ModelProvider
+}
+
+// New creates a selector rating with judge.
+func New(cfg Config, judge core.ModelProvider) (*Selector, error) {
+	if cfg.Candidates > MaxCandidates {
+		return nil, fmt.Errorf("n_best candidates must be at most %d", MaxCandidates)
+	}
+	if cfg.Criteria == "" {
+		cfg.Criteria = "helpful, correct and clear"
+	}
+	return &Selector{cfg: cfg, judge: judge}, nil
+}
+
+// Generate calls llm for the configured number of candidates at once and
+// returns the best-rated. It fails only if every candidate does; an empty
+// candidate rates 0, one the judge can't rate ranks last, and the first
+// candidate wins ties.
+func (s *Selector) Generate(ctx context.Context, llm core.ModelProvider, prompt core.Prompt) (core.Response, Pick, error) {
+	responses := make([]core.Response, s.cfg.Candidates)
+	errs := make([]error, s.cfg.Candidates)
+	var wg sync.WaitGroup
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i], errs[i] = llm.Call(ctx, prompt)
+		}(i)
+	}
+	wg.Wait()
+
+	var candidates []core.Response
+	var firstErr error
+	for i, resp := range responses {
+		if errs[i] != nil {
+			firstErr = cmp.Or(firstErr, errs[i])
+			continue
+		}
+		candidates = append(candidates, resp)
+	}
+	if len(candidates) == 0 {
+		return core.Response{}, Pick{}, firstErr
+	}
+	pick := Pick{Candidates: len(candidates), Scores: make([]float64, len(candidates))}
+	if len(candidates) == 1 {
+		pick.Scores[0] = -1
+		return candidates[0], pick, nil
+	}
+
+	for i := range candidates {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if strings.TrimSpace(candidates[i].Content) == "" {
+				return
+			}
+			score, err := s.score(ctx, prompt, candidates[i].Content)
+			if err != nil {
+				core.Logger().Warn().Err(err).Msg("Failed to rate candidate completion")
+				score = -1
+			}
+			pick.Scores[i] = score
+		}(i)
+	}
+	wg.Wait()
+	for i, score := range pick.Scores {
+		if score > pick.Scores[pick.Chosen] {
+			pick.Chosen = i
+		}
+	}
+	return candidates[pick.Chosen], pick, nil
+}
+
+var scorePattern = regexp.MustCompile(`\d+(\.\d+)?`)
+
+// score asks the judge to rate a candidate 0-10.
+func (s *Selector) score(ctx context.Context, prompt core.Prompt, candidate string) (float64, error) {
+	resp, err := s.judge.Call(ctx, core.Prompt{
+		System: fmt.Sprintf("You are a strict evaluator. Rate how well the response fulfils the request; a good response is %s. Reply with a single number from 0 to 10.", s.cfg.Criteria),
+		User:   fmt.Sprintf("Instructions the response followed:\n%s\n\nRequest:\n%s\n\nResponse:\n%s", prompt.System, prompt.User, candidate),
+	})
+	if err != nil {
+		return 0, err
+	}
+	match := scorePattern.FindString(resp.Content)
+	if match == "" {
+		return 0, fmt.Errorf("judge gave no rating: %q", resp.Content)
+	}
+	v, _ := strconv.ParseFloat(match, 64)
+	return min(v, 10), nil
+}
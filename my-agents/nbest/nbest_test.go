@@ -0,0 +1,113 @@
+package nbest
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// model answers each call with the next of replies; "error" fails the call.
+type model struct {
+	core.ModelProvider
+	mu      sync.Mutex
+	replies []string
+	calls   int
+}
+
+func (m *model) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	reply := m.replies[m.calls%len(m.replies)]
+	m.calls++
+	if reply == "error" {
+		return core.Response{}, errors.New("model down")
+	}
+	return core.Response{Content: reply}, nil
+}
+
+// judge rates each candidate with its rating, failing for those without.
+type judge struct {
+	core.ModelProvider
+	mu      sync.Mutex
+	ratings map[string]string
+	prompts []core.Prompt
+}
+
+func (j *judge) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	j.mu.Lock()
+	j.prompts = append(j.prompts, prompt)
+	j.mu.Unlock()
+	_, candidate, _ := strings.Cut(prompt.User, "Response:\n")
+	rating, ok := j.ratings[candidate]
+	if !ok {
+		return core.Response{}, errors.New("judge down")
+	}
+	return core.Response{Content: rating}, nil
+}
+
+func TestConfig(t *testing.T) {
+	var nilCfg *Config
+	if nilCfg.Enabled() || (&Config{Candidates: 1}).Enabled() || !(&Config{Candidates: 2}).Enabled() {
+		t.Error("wrong Enabled")
+	}
+	if _, err := New(Config{Candidates: MaxCandidates + 1}, &judge{}); err == nil {
+		t.Error("too many candidates accepted")
+	}
+	s, err := New(Config{Candidates: 2}, &judge{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.cfg.Criteria != "helpful, correct and clear" {
+		t.Errorf("criteria = %q", s.cfg.Criteria)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	j := &judge{ratings: map[string]string{"short": "4", "thorough": "Rating: 8.5/10", "rambling": "12"}}
+	s, _ := New(Config{Candidates: 3, Criteria: "concise"}, j)
+	llm := &model{replies: []string{"short", "thorough", "rambling"}}
+	prompt := core.Prompt{System: "Be brief.", User: "Explain Go."}
+	resp, pick, err := s.Generate(context.Background(), llm, prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Ratings over 10 count as 10
+	if resp.Content != "rambling" || pick.Candidates != 3 || pick.Scores[pick.Chosen] != 10 || llm.calls != 3 {
+		t.Errorf("kept %q with %+v", resp.Content, pick)
+	}
+	if len(j.prompts) != 3 || !strings.Contains(j.prompts[0].System, "a good response is concise") ||
+		!strings.Contains(j.prompts[0].User, "Be brief.") || !strings.Contains(j.prompts[0].User, "Explain Go.") {
+		t.Errorf("judge prompts = %+v", j.prompts)
+	}
+}
+
+func TestGenerateFailures(t *testing.T) {
+	ctx := context.Background()
+	j := &judge{ratings: map[string]string{"good": "7", "vague": "no idea"}}
+	s, _ := New(Config{Candidates: 3}, j)
+
+	// Candidates that failed drop out; one the judge couldn't rate ranks
+	// below an empty one
+	resp, pick, err := s.Generate(ctx, &model{replies: []string{"vague", "error", " "}}, core.Prompt{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != " " || pick.Candidates != 2 || pick.Scores[pick.Chosen] != 0 {
+		t.Errorf("kept %q with %+v", resp.Content, pick)
+	}
+
+	// A single candidate isn't rated
+	j.prompts = nil
+	resp, pick, err = s.Generate(ctx, &model{replies: []string{"good", "error", "error"}}, core.Prompt{})
+	if err != nil || resp.Content != "good" || pick.Candidates != 1 || pick.Scores[0] != -1 || len(j.prompts) != 0 {
+		t.Errorf("single candidate: %q %+v %v", resp.Content, pick, err)
+	}
+
+	if _, _, err := s.Generate(ctx, &model{replies: []string{"error"}}, core.Prompt{}); err == nil || err.Error() != "model down" {
+		t.Errorf("every candidate failed: %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+// Package ocr turns images and scanned PDFs into text, either with a local
+// Tesseract install or an OpenAI-compatible vision model.
+package ocr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Engine recognizes the text in a single image file.
+type Engine interface {
+	Recognize(ctx context.Context, imagePath string) (string, error)
+}
+
+// Config is the [ocr] section of agentflow.toml.
+type Config struct {
+	Engine    string   `toml:"engine"`    // "tesseract" or "vision"; empty disables OCR
+	Languages []string `toml:"languages"` // Tesseract language packs, e.g. ["eng", "deu"]
+	Command   string   `toml:"command"`   // tesseract binary (default "tesseract")
+	DPI       int      `toml:"dpi"`       // PDF rasterization resolution (default 300)
+
+	URL       string `toml:"url"`         // vision: base URL, e.g. https://api.openai.com/v1
+	Model     string `toml:"model"`       // vision: model name
+	APIKeyEnv string `toml:"api_key_env"` // vision: env var holding the API key
+}
+
+// New creates the configured engine. It returns nil when OCR is disabled.
+func New(cfg Config) (Engine, error) {
+	switch cfg.Engine {
+	case "":
+		return nil, nil
+	case "tesseract":
+		return &Tesseract{Command: cfg.Command, Languages: cfg.Languages}, nil
+	case "vision":
+		if cfg.URL == "" || cfg.Model == "" {
+			return nil, fmt.Errorf("ocr: vision engine requires url and model")
+		}
+		key := ""
+		if cfg.APIKeyEnv != "" {
+			key = os.Getenv(cfg.APIKeyEnv)
+		}
+		return &Vision{URL: cfg.URL, Model: cfg.Model, APIKey: key}, nil
+	default:
+		return nil, fmt.Errorf("ocr: unknown engine %q", cfg.Engine)
+	}
+}
+
+// IsImage reports whether path has an image extension the engines accept.
+func IsImage(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg", ".tif", ".tiff", ".bmp", ".gif", ".webp":
+		return true
+	}
+	return false
+}
+
+// File recognizes an image, or every page of a PDF, and returns the text.
+// Pages are separated by form feeds.
+func File(ctx context.Context, engine Engine, path string, dpi int) (string, error) {
+	if strings.EqualFold(filepath.Ext(path), ".pdf") {
+		pages, cleanup, err := rasterize(ctx, path, dpi)
+		if err != nil {
+			return "", err
+		}
+		defer cleanup()
+		texts := make([]string, 0, len(pages))
+		for _, page := range pages {
+			text, err := engine.Recognize(ctx, page)
+			if err != nil {
+				return "", fmt.Errorf("ocr: %s page %d: %w", filepath.Base(path), len(texts)+1, err)
+			}
+			texts = append(texts, strings.TrimSpace(text))
+		}
+		return strings.Join(texts, "\n\f\n"), nil
+	}
+	if !IsImage(path) {
+		return "", fmt.Errorf("ocr: unsupported file type %q", filepath.Ext(path))
+	}
+	text, err := engine.Recognize(ctx, path)
+	if err != nil {
+		return "", fmt.Errorf("ocr: %s: %w", filepath.Base(path), err)
+	}
+	return strings.TrimSpace(text), nil
+}
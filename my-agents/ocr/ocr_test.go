@@ -0,0 +1,84 @@
+package ocr
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+type fakeEngine struct {
+	text string
+	err  error
+	seen []string
+}
+
+func (e *fakeEngine) Recognize(ctx context.Context, imagePath string) (string, error) {
+	e.seen = append(e.seen, imagePath)
+	return e.text, e.err
+}
+
+func TestNew(t *testing.T) {
+	if e, err := New(Config{}); e != nil || err != nil {
+		t.Errorf("New of disabled OCR = %v, %v", e, err)
+	}
+	if e, err := New(Config{Engine: "tesseract", Languages: []string{"eng"}}); err != nil || e.(*Tesseract).Languages[0] != "eng" {
+		t.Errorf("New(tesseract) = %v, %v", e, err)
+	}
+	t.Setenv("OCR_TEST_KEY", "secret")
+	e, err := New(Config{Engine: "vision", URL: "http://x", Model: "m", APIKeyEnv: "OCR_TEST_KEY"})
+	if err != nil || e.(*Vision).APIKey != "secret" {
+		t.Errorf("New(vision) = %v, %v", e, err)
+	}
+	if _, err := New(Config{Engine: "vision"}); err == nil {
+		t.Error("New accepted a vision engine without url and model")
+	}
+	if _, err := New(Config{Engine: "magic"}); err == nil {
+		t.Error("New accepted an unknown engine")
+	}
+}
+
+func TestIsImage(t *testing.T) {
+	for path, want := range map[string]bool{"a.PNG": true, "b.jpeg": true, "c.pdf": false, "d": false} {
+		if got := IsImage(path); got != want {
+			t.Errorf("IsImage(%q) = %v", path, got)
+		}
+	}
+}
+
+func TestFile(t *testing.T) {
+	engine := &fakeEngine{text: "  hello\n"}
+	if got, err := File(context.Background(), engine, "scan.png", 0); err != nil || got != "hello" {
+		t.Errorf("File = %q, %v", got, err)
+	}
+	if _, err := File(context.Background(), engine, "notes.docx", 0); err == nil {
+		t.Error("File accepted an unsupported type")
+	}
+	boom := errors.New("boom")
+	if _, err := File(context.Background(), &fakeEngine{err: boom}, "scan.png", 0); !errors.Is(err, boom) {
+		t.Errorf("File = %v, want the engine's error", err)
+	}
+}
+
+func TestTool(t *testing.T) {
+	engine := &fakeEngine{text: "text"}
+	tool := &Tool{Engine: engine}
+	if _, err := tool.Call(context.Background(), map[string]any{}); err == nil {
+		t.Error("Call without a path succeeded")
+	}
+	got, err := tool.Call(context.Background(), map[string]any{"path": "dir/./scan.png"})
+	if err != nil || got != "text" {
+		t.Fatalf("Call = %v, %v", got, err)
+	}
+	if want := filepath.Join("dir", "scan.png"); engine.seen[0] != want {
+		t.Errorf("recognized %q, want %q", engine.seen[0], want)
+	}
+}
+
+func TestPageNumber(t *testing.T) {
+	for path, want := range map[string]int{"/tmp/page-1.png": 1, "/tmp/page-010.png": 10} {
+		if got := pageNumber(path); got != want {
+			t.Errorf("pageNumber(%q) = %d, want %d", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package ocr
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"my-agents/tools/proc"
+)
+
+// Tesseract runs the tesseract CLI on each image.
+type Tesseract struct {
+	Command   string
+	Languages []string
+}
+
+func (t *Tesseract) Recognize(ctx context.Context, imagePath string) (string, error) {
+	cmd := t.Command
+	if cmd == "" {
+		cmd = "tesseract"
+	}
+	args := []string{imagePath, "stdout"}
+	if len(t.Languages) > 0 {
+		args = append(args, "-l", strings.Join(t.Languages, "+"))
+	}
+	return proc.Run(ctx, cmd, args...)
+}
+
+// PDFText extracts a PDF's embedded text layer with pdftotext. Scanned PDFs
+// return little or no text.
+func PDFText(ctx context.Context, path string) (string, error) {
+	return proc.Run(ctx, "pdftotext", "-layout", path, "-")
+}
+
+// rasterize renders each PDF page to a PNG with pdftoppm and returns the
+// page images in order plus a cleanup func removing them.
+func rasterize(ctx context.Context, path string, dpi int) ([]string, func(), error) {
+	if dpi <= 0 {
+		dpi = 300
+	}
+	dir, err := os.MkdirTemp("", "ocr-pages-")
+	if err != nil {
+		return nil, nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	if _, err := proc.Run(ctx, "pdftoppm", "-r", strconv.Itoa(dpi), "-png", path, filepath.Join(dir, "page")); err != nil {
+		cleanup()
+		return nil, nil, err
+	}
+	pages, _ := filepath.Glob(filepath.Join(dir, "page-*.png"))
+	// pdftoppm zero-pads to the page count's width, so sort by page number
+	sort.Slice(pages, func(i, j int) bool { return pageNumber(pages[i]) < pageNumber(pages[j]) })
+	if len(pages) == 0 {
+		cleanup()
+		return nil, nil, fmt.Errorf("ocr: %s has no pages", filepath.Base(path))
+	}
+	return pages, cleanup, nil
+}
+
+func pageNumber(path string) int {
+	base := strings.TrimSuffix(filepath.Base(path), ".png")
+	n, _ := strconv.Atoi(base[strings.LastIndex(base, "-")+1:])
+	return n
+}
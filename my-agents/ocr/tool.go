@@ -0,0 +1,28 @@
+package ocr
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+// Tool exposes an engine to agents. Arguments: path (image or PDF).
+type Tool struct {
+	Engine Engine
+	DPI    int
+}
+
+func (t *Tool) Name() string { return "ocr" }
+
+func (t *Tool) Description() string {
+	return "Extract the text from an image or scanned PDF."
+}
+
+func (t *Tool) Call(ctx context.Context, args map[string]any) (any, error) {
+	path, _ := args["path"].(string)
+	if path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+	// Models tend to write paths with forward slashes on every platform
+	return File(ctx, t.Engine, filepath.Clean(filepath.FromSlash(path)), t.DPI)
+}
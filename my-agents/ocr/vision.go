@@ -0,0 +1,88 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const visionPrompt = "Transcribe all text in this image exactly as written. Preserve line breaks and table layout. Output only the text."
+
+// Vision sends each image to an OpenAI-compatible chat completions endpoint.
+type Vision struct {
+	URL    string
+	Model  string
+	APIKey string
+	Client *http.Client
+}
+
+func (v *Vision) Recognize(ctx context.Context, imagePath string) (string, error) {
+	data, err := os.ReadFile(imagePath)
+	if err != nil {
+		return "", err
+	}
+	mediaType := mime.TypeByExtension(strings.ToLower(filepath.Ext(imagePath)))
+	if mediaType == "" {
+		mediaType = "image/png"
+	}
+	body, err := json.Marshal(map[string]any{
+		"model": v.Model,
+		"messages": []map[string]any{{
+			"role": "user",
+			"content": []map[string]any{
+				{"type": "text", "text": visionPrompt},
+				{"type": "image_url", "image_url": map[string]string{
+					"url": "data:" + mediaType + ";base64," + base64.StdEncoding.EncodeToString(data),
+				}},
+			},
+		}},
+		"temperature": 0,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(v.URL, "/")+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if v.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+v.APIKey)
+	}
+	client := v.Client
+	if client == nil {
+		client = &http.Client{Timeout: 2 * time.Minute}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vision API returned %s", resp.Status)
+	}
+
+	var out struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("failed to decode vision response: %w", err)
+	}
+	if len(out.Choices) == 0 {
+		return "", fmt.Errorf("vision API returned no choices")
+	}
+	return out.Choices[0].Message.Content, nil
+}
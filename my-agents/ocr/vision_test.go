@@ -0,0 +1,51 @@
+package ocr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestVisionRecognize(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" || r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		var body struct {
+			Model    string `json:"model"`
+			Messages []struct {
+				Content []struct {
+					ImageURL struct {
+						URL string `json:"url"`
+					} `json:"image_url"`
+				} `json:"content"`
+			} `json:"messages"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Model != "m" ||
+			!strings.HasPrefix(body.Messages[0].Content[1].ImageURL.URL, "data:image/png;base64,") {
+			http.Error(w, "bad body", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"choices":[{"message":{"content":"scanned"}}]}`))
+	}))
+	defer srv.Close()
+
+	path := filepath.Join(t.TempDir(), "scan.png")
+	if err := os.WriteFile(path, []byte("png"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	v := &Vision{URL: srv.URL + "/v1/", Model: "m", APIKey: "key"}
+	if got, err := v.Recognize(context.Background(), path); err != nil || got != "scanned" {
+		t.Errorf("Recognize = %q, %v", got, err)
+	}
+	v.APIKey = ""
+	if _, err := v.Recognize(context.Background(), path); err == nil {
+		t.Error("Recognize ignored an error status")
+	}
+}
@@ -0,0 +1,123 @@
+package parallel
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Built-in merge strategies.
+const (
+	// LastWriteWins keeps the value of the agent that finished last.
+	LastWriteWins = "last_write_wins"
+	// Append collects every agent's value into one list, after the items
+	// the key held before the fan-out. A list an agent extended from the
+	// previous value contributes only the items it added.
+	Append = "append"
+	// Max keeps the greatest number among the agents' values and the
+	// previous value.
+	Max = "max"
+)
+
+// Write is the value one agent wrote for a key.
+type Write struct {
+	Agent string
+	Value any
+}
+
+// Reducer merges the values agents wrote for key, in the order they
+// finished, with prev, the key's value before the fan-out (nil when unset).
+// It returns the merged value and the agents it came from.
+type Reducer func(key string, prev any, writes []Write) (value any, from []string, err error)
+
+var builtin = map[string]Reducer{
+	LastWriteWins: lastWriteWins,
+	Append:        appendValues,
+	Max:           maxValue,
+}
+
+func lastWriteWins(_ string, _ any, writes []Write) (any, []string, error) {
+	w := writes[len(writes)-1]
+	return w.Value, []string{w.Agent}, nil
+}
+
+func appendValues(_ string, prev any, writes []Write) (any, []string, error) {
+	before := items(prev)
+	out := append([]any{}, before...)
+	var from []string
+	for _, w := range writes {
+		added := items(w.Value)
+		if len(added) >= len(before) && reflect.DeepEqual(added[:len(before)], before) {
+			added = added[len(before):]
+		}
+		out = append(out, added...)
+		from = append(from, w.Agent)
+	}
+	return out, from, nil
+}
+
+// items reads v as list items: a list's elements, nothing for nil, and any
+// other value as one item.
+func items(v any) []any {
+	switch x := v.(type) {
+	case nil:
+		return nil
+	case []any:
+		return x
+	case []string:
+		out := make([]any, len(x))
+		for i, s := range x {
+			out[i] = s
+		}
+		return out
+	}
+	if rv := reflect.ValueOf(v); rv.Kind() == reflect.Slice {
+		out := make([]any, rv.Len())
+		for i := range out {
+			out[i] = rv.Index(i).Interface()
+		}
+		return out
+	}
+	return []any{v}
+}
+
+func maxValue(key string, prev any, writes []Write) (any, []string, error) {
+	best, from := prev, []string(nil)
+	bestN, ok := number(prev)
+	if !ok {
+		best = nil
+	}
+	for _, w := range writes {
+		n, isNum := number(w.Value)
+		if !isNum {
+			return nil, nil, fmt.Errorf("%s: max needs numbers, %s wrote %v", key, w.Agent, w.Value)
+		}
+		if best == nil || n > bestN {
+			best, bestN, from = w.Value, n, []string{w.Agent}
+		}
+	}
+	return best, from, nil
+}
+
+// number reads v as a number, including numbers held as text.
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		return f, err == nil
+	}
+	return 0, false
+}
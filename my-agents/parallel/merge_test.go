@@ -0,0 +1,53 @@
+package parallel
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLastWriteWins(t *testing.T) {
+	v, from, err := lastWriteWins("summary", "old", []Write{{"enhancer", "a"}, {"critic", "b"}})
+	if err != nil || v != "b" || !reflect.DeepEqual(from, []string{"critic"}) {
+		t.Errorf("= %v from %v, %v", v, from, err)
+	}
+}
+
+func TestAppend(t *testing.T) {
+	tests := []struct {
+		prev   any
+		writes []Write
+		want   []any
+	}{
+		{nil, []Write{{"a", "x"}, {"b", []string{"y", "z"}}}, []any{"x", "y", "z"}},
+		// Lists extended from the previous value contribute only their new items
+		{[]any{"p"}, []Write{{"a", []any{"p", "x"}}, {"b", []any{"p", "y"}}}, []any{"p", "x", "y"}},
+		{[]any{"p"}, []Write{{"a", []any{"q"}}}, []any{"p", "q"}},
+		{nil, []Write{{"a", []int{1, 2}}}, []any{1, 2}},
+	}
+	for _, tt := range tests {
+		v, from, err := appendValues("suggestions", tt.prev, tt.writes)
+		if err != nil || !reflect.DeepEqual(v, tt.want) || len(from) != len(tt.writes) {
+			t.Errorf("append %v over %v = %v from %v, %v; want %v", tt.writes, tt.prev, v, from, err, tt.want)
+		}
+	}
+}
+
+func TestMax(t *testing.T) {
+	v, from, err := maxValue("score", 0.5, []Write{{"enhancer", 0.7}, {"critic", "0.9"}, {"judge", 0.8}})
+	if err != nil || v != "0.9" || !reflect.DeepEqual(from, []string{"critic"}) {
+		t.Errorf("= %v from %v, %v", v, from, err)
+	}
+	// No agent beat the previous value
+	v, from, err = maxValue("score", 10, []Write{{"enhancer", 3}})
+	if err != nil || v != 10 || from != nil {
+		t.Errorf("= %v from %v, %v", v, from, err)
+	}
+	// A previous value that isn't a number doesn't count
+	if v, _, _ := maxValue("score", "n/a", []Write{{"enhancer", 3}}); v != 3 {
+		t.Errorf("= %v", v)
+	}
+	if _, _, err := maxValue("score", nil, []Write{{"enhancer", "high"}}); err == nil || !strings.Contains(err.Error(), "enhancer wrote high") {
+		t.Errorf("err = %v", err)
+	}
+}
@@ -0,0 +1,187 @@
+// Package parallel fans an event out to several agents at once and joins
+// their outputs into one state. Where agents write the same key, the join
+// applies the key's merge strategy — last write wins, append to a list,
+// numeric max or a custom reducer — instead of whichever output happened to
+// be copied last.
+package parallel
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+// Config is one [parallel.<name>] table of agentflow.toml. Events routed
+// to <name> run the agents together:
+//
+//	[parallel.review]
+//	agents = ["enhancer", "critic"]
+//	route = "formatter"
+//	default = "last_write_wins"
+//
+//	[parallel.review.merge]
+//	suggestions = "append"
+//	score = "max"
+type Config struct {
+	Agents []string `toml:"agents"`
+	// Route is the agent the joined state goes to; empty ends the run.
+	Route string `toml:"route"`
+	// Default is the strategy for keys Merge doesn't name (default
+	// LastWriteWins).
+	Default string `toml:"default"`
+	// Merge names the strategy, or a custom reducer, for each key.
+	Merge map[string]string `toml:"merge"`
+}
+
+// Agent runs its branches on each event concurrently and joins their
+// outputs.
+type Agent struct {
+	name     string
+	route    string
+	branches []branch
+	defaults Reducer
+	merge    map[string]Reducer
+}
+
+type branch struct {
+	name    string
+	handler core.AgentHandler
+}
+
+// New creates the fan-out agent name from cfg, running the named agents
+// taken from agents. Strategies may name the built-in ones or reducers.
+func New(name string, cfg Config, agents map[string]core.AgentHandler, reducers map[string]Reducer) (*Agent, error) {
+	if len(cfg.Agents) < 2 {
+		return nil, fmt.Errorf("parallel %s: needs at least two agents", name)
+	}
+	a := &Agent{name: name, route: cfg.Route, merge: make(map[string]Reducer)}
+	for _, agent := range cfg.Agents {
+		h, ok := agents[agent]
+		if !ok {
+			return nil, fmt.Errorf("parallel %s: unknown agent %q", name, agent)
+		}
+		a.branches = append(a.branches, branch{name: agent, handler: h})
+	}
+	lookup := func(strategy string) (Reducer, error) {
+		if r, ok := builtin[strategy]; ok {
+			return r, nil
+		}
+		if r, ok := reducers[strategy]; ok {
+			return r, nil
+		}
+		return nil, fmt.Errorf("parallel %s: unknown merge strategy %q", name, strategy)
+	}
+	var err error
+	if a.defaults, err = lookup(cmp.Or(cfg.Default, LastWriteWins)); err != nil {
+		return nil, err
+	}
+	for key, strategy := range cfg.Merge {
+		if a.merge[key], err = lookup(strategy); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+type outcome struct {
+	agent  string
+	result core.AgentResult
+	err    error
+}
+
+func (a *Agent) Run(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+	// Every branch gets its own copy of the state, so none sees another's
+	// writes before the join
+	var (
+		mu       sync.Mutex
+		finished []outcome
+		wg       sync.WaitGroup
+	)
+	for _, b := range a.branches {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := b.handler.Run(ctx, event, state.Clone())
+			mu.Lock()
+			finished = append(finished, outcome{agent: b.name, result: result, err: err})
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, o := range finished {
+		if o.err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", o.agent, o.err))
+		}
+	}
+	if len(errs) > 0 {
+		return core.AgentResult{}, fmt.Errorf("parallel %s: %w", a.name, errors.Join(errs...))
+	}
+
+	out, err := a.join(state, finished)
+	if err != nil {
+		return core.AgentResult{}, err
+	}
+	return core.AgentResult{OutputState: out}, nil
+}
+
+// join merges the branches' writes, in the order they finished, over state.
+func (a *Agent) join(state core.State, finished []outcome) (core.State, error) {
+	out := state.Clone()
+	writes := make(map[string][]Write)
+	for _, o := range finished {
+		s := o.result.OutputState
+		if s == nil {
+			continue
+		}
+		for _, k := range s.Keys() {
+			v, _ := s.Get(k)
+			if prev, had := state.Get(k); had && reflect.DeepEqual(prev, v) {
+				continue
+			}
+			writes[k] = append(writes[k], Write{Agent: o.agent, Value: v})
+		}
+		// Metadata the branches added, such as forwarded request metadata
+		for _, mk := range s.MetaKeys() {
+			if mk == core.RouteMetadataKey || strings.HasPrefix(mk, history.WrittenByPrefix) {
+				continue
+			}
+			if v, ok := s.GetMeta(mk); ok {
+				out.SetMeta(mk, v)
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(writes))
+	for k := range writes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		reduce := a.defaults
+		if r, ok := a.merge[k]; ok {
+			reduce = r
+		}
+		prev, _ := state.Get(k)
+		v, from, err := reduce(k, prev, writes[k])
+		if err != nil {
+			return nil, fmt.Errorf("parallel %s: %w", a.name, err)
+		}
+		out.Set(k, v)
+		if len(from) > 0 {
+			out.SetMeta(history.WrittenByPrefix+k, strings.Join(from, "+"))
+		}
+	}
+	out.SetMeta(core.RouteMetadataKey, a.route)
+	return out, nil
+}
@@ -0,0 +1,118 @@
+package parallel
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+// writer is an agent that sets keys, failing when err is set. It waits for
+// after, when set, so tests can order the branches' finishes.
+func writer(values map[string]any, after <-chan struct{}, done chan<- struct{}, err error) core.AgentHandler {
+	return core.AgentHandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+		if after != nil {
+			<-after
+		}
+		if done != nil {
+			defer close(done)
+		}
+		if err != nil {
+			return core.AgentResult{}, err
+		}
+		for k, v := range values {
+			state.Set(k, v)
+		}
+		state.SetMeta("locale", "de-DE")
+		state.SetMeta(core.RouteMetadataKey, "elsewhere")
+		return core.AgentResult{OutputState: state}, nil
+	})
+}
+
+func TestNew(t *testing.T) {
+	agents := map[string]core.AgentHandler{"enhancer": writer(nil, nil, nil, nil), "critic": writer(nil, nil, nil, nil)}
+	for _, cfg := range []Config{
+		{Agents: []string{"enhancer"}},
+		{Agents: []string{"enhancer", "judge"}},
+		{Agents: []string{"enhancer", "critic"}, Default: "median"},
+		{Agents: []string{"enhancer", "critic"}, Merge: map[string]string{"score": "median"}},
+	} {
+		if _, err := New("review", cfg, agents, nil); err == nil {
+			t.Errorf("%+v accepted", cfg)
+		}
+	}
+	reducers := map[string]Reducer{"median": lastWriteWins}
+	if _, err := New("review", Config{Agents: []string{"enhancer", "critic"}, Merge: map[string]string{"score": "median"}}, agents, reducers); err != nil {
+		t.Errorf("custom reducer: %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	first := make(chan struct{})
+	agents := map[string]core.AgentHandler{
+		"enhancer": writer(map[string]any{"summary": "enhanced", "suggestions": []any{"old", "tighten"}, "score": 0.6}, nil, first, nil),
+		"critic":   writer(map[string]any{"summary": "critiqued", "suggestions": []any{"old", "cite"}, "score": 0.9, "topic": "go"}, first, nil, nil),
+	}
+	a, err := New("review", Config{
+		Agents: []string{"enhancer", "critic"},
+		Route:  "formatter",
+		Merge:  map[string]string{"suggestions": Append, "score": Max},
+	}, agents, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := core.NewState()
+	state.Set("suggestions", []any{"old"})
+	state.Set("topic", "go")
+	result, err := a.Run(context.Background(), core.NewEvent("review", nil, nil), state)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := result.OutputState
+	get := func(k string) any { v, _ := out.Get(k); return v }
+	meta := func(k string) string { v, _ := out.GetMeta(k); return v }
+
+	// The critic finished last
+	if get("summary") != "critiqued" || meta(history.WrittenByPrefix+"summary") != "critic" {
+		t.Errorf("summary = %v by %s", get("summary"), meta(history.WrittenByPrefix+"summary"))
+	}
+	if s := get("suggestions"); !reflect.DeepEqual(s, []any{"old", "tighten", "cite"}) || meta(history.WrittenByPrefix+"suggestions") != "enhancer+critic" {
+		t.Errorf("suggestions = %v by %s", s, meta(history.WrittenByPrefix+"suggestions"))
+	}
+	if get("score") != 0.9 {
+		t.Errorf("score = %v", get("score"))
+	}
+	// Rewriting a value unchanged isn't a write
+	if meta(history.WrittenByPrefix+"topic") != "" {
+		t.Errorf("topic written by %s", meta(history.WrittenByPrefix+"topic"))
+	}
+	if meta("locale") != "de-DE" || meta(core.RouteMetadataKey) != "formatter" {
+		t.Errorf("locale %q, route %q", meta("locale"), meta(core.RouteMetadataKey))
+	}
+	// Branches work on copies
+	if _, ok := state.Get("summary"); ok {
+		t.Error("a branch wrote to the state passed in")
+	}
+}
+
+func TestRunFails(t *testing.T) {
+	boom := errors.New("boom")
+	agents := map[string]core.AgentHandler{
+		"enhancer": writer(nil, nil, nil, nil),
+		"critic":   writer(nil, nil, nil, boom),
+	}
+	a, _ := New("review", Config{Agents: []string{"enhancer", "critic"}}, agents, nil)
+	if _, err := a.Run(context.Background(), core.NewEvent("review", nil, nil), core.NewState()); !errors.Is(err, boom) {
+		t.Errorf("err = %v", err)
+	}
+
+	agents["critic"] = writer(map[string]any{"score": "high"}, nil, nil, nil)
+	a, _ = New("review", Config{Agents: []string{"enhancer", "critic"}, Default: Max}, agents, nil)
+	if _, err := a.Run(context.Background(), core.NewEvent("review", nil, nil), core.NewState()); err == nil {
+		t.Error("merge error ignored")
+	}
+}
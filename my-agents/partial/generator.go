@@ -0,0 +1,169 @@
+package partial
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+const continuePrompt = "\n\nYour previous answer was interrupted. It ended with the text below. Continue exactly where it stops, without repeating any of it:\n\n"
+
+// Generator streams completions and checkpoints them as they arrive.
+// A nil Generator calls the provider directly.
+type Generator struct {
+	store         Store
+	flushTokens   int
+	flushInterval time.Duration
+	mode          string
+}
+
+// NewGenerator creates a generator persisting to store.
+func NewGenerator(store Store, cfg Config) *Generator {
+	g := &Generator{store: store, flushTokens: cfg.FlushTokens, mode: cfg.Mode}
+	if g.flushTokens <= 0 {
+		g.flushTokens = 16
+	}
+	if d, err := time.ParseDuration(cfg.FlushInterval); err == nil && d > 0 {
+		g.flushInterval = d
+	} else {
+		g.flushInterval = time.Second
+	}
+	if g.mode == "" {
+		g.mode = ModeContinue
+	}
+	return g
+}
+
+// Generate produces a completion for prompt under key. If a checkpoint for
+// the same prompt exists from an interrupted attempt, the generation picks
+// up from it according to the configured mode. The checkpoint is removed on
+// success and kept on failure.
+func (g *Generator) Generate(ctx context.Context, llm core.ModelProvider, key string, prompt core.Prompt) (core.Response, error) {
+	return g.GenerateStream(ctx, llm, key, prompt, nil)
+}
+
+// GenerateStream is Generate passing the completion to onToken, when not
+// nil, as it arrives: text continued from a checkpoint first, then each
+// token. Providers that can't stream pass the whole completion at once.
+func (g *Generator) GenerateStream(ctx context.Context, llm core.ModelProvider, key string, prompt core.Prompt, onToken func(string)) (core.Response, error) {
+	if g == nil || key == "" {
+		if onToken == nil {
+			return llm.Call(ctx, prompt)
+		}
+		if tokens, err := llm.Stream(ctx, prompt); err == nil {
+			return collect(ctx, tokens, "", onToken, nil)
+		}
+		resp, err := llm.Call(ctx, prompt)
+		if err == nil {
+			onToken(resp.Content)
+		}
+		return resp, err
+	}
+	if onToken == nil {
+		onToken = func(string) {}
+	}
+
+	cp := &Checkpoint{Key: key, Prompt: prompt, StartedAt: time.Now()}
+	cp.RunID, cp.Agent, _ = strings.Cut(key, ":")
+	prior, err := g.store.Get(key)
+	if err != nil {
+		core.Logger().Warn().Str("key", key).Err(err).Msg("Ignoring unreadable checkpoint")
+	}
+	request := prompt
+	if prior != nil && samePrompt(prior.Prompt, prompt) {
+		cp.Attempt = prior.Attempt + 1
+		cp.StartedAt = prior.StartedAt
+		if g.mode == ModeContinue && prior.Content != "" {
+			cp.Content, cp.Tokens = prior.Content, prior.Tokens
+			request.User += continuePrompt + prior.Content
+			core.Logger().Info().Str("key", key).Int("chars", len(prior.Content)).Msg("Continuing interrupted generation")
+		}
+	}
+	resumed := cp.Content
+
+	tokens, err := llm.Stream(ctx, request)
+	if err != nil {
+		// Providers without streaming still get a checkpoint of the prompt,
+		// so an interrupted call is at least visible and re-runnable
+		g.save(cp)
+		resp, err := llm.Call(ctx, request)
+		if err != nil {
+			return resp, err
+		}
+		resp.Content = resumed + resp.Content
+		onToken(resp.Content)
+		g.done(key)
+		return resp, nil
+	}
+
+	pending := 0
+	lastFlush := time.Now()
+	resp, err := collect(ctx, tokens, resumed, onToken, func(content string, final bool) {
+		cp.Content = content
+		if final {
+			g.save(cp)
+			return
+		}
+		cp.Tokens++
+		pending++
+		if pending >= g.flushTokens || time.Since(lastFlush) >= g.flushInterval {
+			g.save(cp)
+			pending, lastFlush = 0, time.Now()
+		}
+	})
+	if err != nil {
+		return resp, err
+	}
+	g.done(key)
+	return resp, nil
+}
+
+// collect reads a stream into a response after prefix, passing text to
+// onToken as it arrives. progress, when not nil, sees the content so far
+// after each token, and once more, final, when the stream fails.
+func collect(ctx context.Context, tokens <-chan core.Token, prefix string, onToken func(string), progress func(content string, final bool)) (core.Response, error) {
+	var b strings.Builder
+	b.WriteString(prefix)
+	if prefix != "" {
+		onToken(prefix)
+	}
+	fail := func(err error) (core.Response, error) {
+		if progress != nil {
+			progress(b.String(), true)
+		}
+		return core.Response{}, err
+	}
+	for tok := range tokens {
+		if tok.Error != nil {
+			return fail(tok.Error)
+		}
+		b.WriteString(tok.Content)
+		onToken(tok.Content)
+		if progress != nil {
+			progress(b.String(), false)
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return fail(err)
+	}
+	return core.Response{Content: b.String(), FinishReason: "stop"}, nil
+}
+
+func (g *Generator) save(cp *Checkpoint) {
+	cp.UpdatedAt = time.Now()
+	if err := g.store.Save(cp); err != nil {
+		core.Logger().Error().Str("key", cp.Key).Err(err).Msg("Failed to save checkpoint")
+	}
+}
+
+func (g *Generator) done(key string) {
+	if err := g.store.Delete(key); err != nil {
+		core.Logger().Error().Str("key", key).Err(err).Msg("Failed to remove checkpoint")
+	}
+}
+
+func samePrompt(a, b core.Prompt) bool {
+	return a.System == b.System && a.User == b.User
+}
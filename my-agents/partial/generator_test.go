@@ -0,0 +1,149 @@
+package partial
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// streamer streams tokens, failing after failAfter of them when set, and
+// records the prompts it was sent.
+type streamer struct {
+	core.ModelProvider
+	tokens    []string
+	failAfter int
+	noStream  bool
+	prompts   []core.Prompt
+}
+
+func (s *streamer) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	s.prompts = append(s.prompts, prompt)
+	return core.Response{Content: strings.Join(s.tokens, "")}, nil
+}
+
+func (s *streamer) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	if s.noStream {
+		return nil, errors.New("streaming not supported")
+	}
+	s.prompts = append(s.prompts, prompt)
+	out := make(chan core.Token, len(s.tokens)+1)
+	for i, tok := range s.tokens {
+		if s.failAfter > 0 && i == s.failAfter {
+			out <- core.Token{Error: errors.New("connection reset")}
+			break
+		}
+		out <- core.Token{Content: tok}
+	}
+	close(out)
+	return out, nil
+}
+
+func newGenerator(t *testing.T, mode string) (*Generator, Store) {
+	t.Helper()
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewGenerator(store, Config{FlushTokens: 1, Mode: mode}), store
+}
+
+func TestGenerateContinues(t *testing.T) {
+	g, store := newGenerator(t, "")
+	prompt := core.Prompt{System: "s", User: "tell me"}
+	llm := &streamer{tokens: []string{"one ", "two ", "three"}, failAfter: 2}
+
+	if _, err := g.Generate(context.Background(), llm, "run-1:processor", prompt); err == nil {
+		t.Fatal("Generate succeeded over a failed stream")
+	}
+	cp, err := store.Get("run-1:processor")
+	if err != nil || cp == nil {
+		t.Fatalf("checkpoint = %v, %v", cp, err)
+	}
+	if cp.Content != "one two " || cp.RunID != "run-1" || cp.Agent != "processor" {
+		t.Errorf("checkpoint = %+v", cp)
+	}
+
+	llm.tokens, llm.failAfter = []string{"three"}, 0
+	resp, err := g.Generate(context.Background(), llm, "run-1:processor", prompt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "one two three" {
+		t.Errorf("resumed = %q", resp.Content)
+	}
+	if sent := llm.prompts[1].User; !strings.HasPrefix(sent, "tell me") || !strings.HasSuffix(sent, "one two ") {
+		t.Errorf("continuation prompt = %q", sent)
+	}
+	if cp, _ := store.Get("run-1:processor"); cp != nil {
+		t.Error("checkpoint kept after success")
+	}
+}
+
+func TestGenerateRegenerates(t *testing.T) {
+	g, store := newGenerator(t, ModeRegenerate)
+	prompt := core.Prompt{User: "tell me"}
+	if err := store.Save(&Checkpoint{Key: "run-1:processor", Prompt: prompt, Content: "stale", Attempt: 1}); err != nil {
+		t.Fatal(err)
+	}
+	llm := &streamer{tokens: []string{"fresh"}}
+	resp, err := g.Generate(context.Background(), llm, "run-1:processor", prompt)
+	if err != nil || resp.Content != "fresh" {
+		t.Errorf("Generate = %q, %v", resp.Content, err)
+	}
+	if llm.prompts[0].User != "tell me" {
+		t.Errorf("prompt = %q, want the original", llm.prompts[0].User)
+	}
+}
+
+func TestGenerateWithoutStreaming(t *testing.T) {
+	g, store := newGenerator(t, "")
+	llm := &streamer{tokens: []string{"whole"}, noStream: true}
+	resp, err := g.Generate(context.Background(), llm, "run-1:processor", core.Prompt{User: "q"})
+	if err != nil || resp.Content != "whole" {
+		t.Errorf("Generate = %q, %v", resp.Content, err)
+	}
+	if cps, _ := store.List(); len(cps) != 0 {
+		t.Errorf("checkpoints left: %v", cps)
+	}
+}
+
+func TestNilGenerator(t *testing.T) {
+	var g *Generator
+	llm := &streamer{tokens: []string{"a", "b"}}
+	if resp, err := g.Generate(context.Background(), llm, "run-1:processor", core.Prompt{}); err != nil || resp.Content != "ab" {
+		t.Errorf("Generate = %q, %v", resp.Content, err)
+	}
+	if resp, err := g.Generate(context.Background(), llm, "", core.Prompt{}); err != nil || resp.Content != "ab" {
+		t.Errorf("Generate without a key = %q, %v", resp.Content, err)
+	}
+}
+
+func TestGenerateStream(t *testing.T) {
+	g, store := newGenerator(t, "")
+	prompt := core.Prompt{User: "tell me"}
+	if err := store.Save(&Checkpoint{Key: "run-1:processor", Prompt: prompt, Content: "one "}); err != nil {
+		t.Fatal(err)
+	}
+	var streamed []string
+	onToken := func(s string) { streamed = append(streamed, s) }
+	resp, err := g.GenerateStream(context.Background(), &streamer{tokens: []string{"two", " three"}}, "run-1:processor", prompt, onToken)
+	if err != nil || resp.Content != "one two three" {
+		t.Fatalf("GenerateStream = %q, %v", resp.Content, err)
+	}
+	if want := []string{"one ", "two", " three"}; !slices.Equal(streamed, want) {
+		t.Errorf("streamed %q, want the checkpoint then each token: %q", streamed, want)
+	}
+
+	// Without a checkpoint store, and with a provider that can't stream
+	for _, llm := range []*streamer{{tokens: []string{"a", "b"}}, {tokens: []string{"a", "b"}, noStream: true}} {
+		streamed = nil
+		var nilGenerator *Generator
+		if resp, err := nilGenerator.GenerateStream(context.Background(), llm, "", prompt, onToken); err != nil || resp.Content != "ab" || strings.Join(streamed, "") != "ab" {
+			t.Errorf("GenerateStream = %q, %v, streamed %q", resp.Content, err, streamed)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Package partial persists streamed LLM output as it arrives so a response
+// interrupted by a crash can be recovered and continued or regenerated.
+package partial
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+	"my-agents/storage"
+)
+
+// Resume modes for a generation that finds an existing checkpoint.
+const (
+	ModeContinue   = "continue"   // ask the model to carry on from the partial text
+	ModeRegenerate = "regenerate" // discard the partial text and start over
+)
+
+// Config is the [recovery] section of agentflow.toml.
+type Config struct {
+	Backend       string `toml:"backend"`        // "sqlite" (default) or "file"
+	Path          string `toml:"path"`           // database file or checkpoint directory
+	FlushTokens   int    `toml:"flush_tokens"`   // persist after this many tokens (default 16)
+	FlushInterval string `toml:"flush_interval"` // or after this long (default "1s")
+	Mode          string `toml:"mode"`           // ModeContinue (default) or ModeRegenerate
+	// ResumeOnStart re-emits runs left "running" by a previous process from
+	// their last completed step. It claims each run in the [state_store],
+	// which it needs, so processes resume a run once.
+	ResumeOnStart bool `toml:"resume_on_start"`
+}
+
+// Checkpoint is the persisted state of one in-flight generation.
+type Checkpoint struct {
+	Key       string      `json:"key"`
+	RunID     string      `json:"run_id"`
+	Agent     string      `json:"agent"`
+	Prompt    core.Prompt `json:"prompt"`
+	Content   string      `json:"content"`
+	Tokens    int         `json:"tokens"`
+	Attempt   int         `json:"attempt"`
+	StartedAt time.Time   `json:"started_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
+
+// Store persists checkpoints by key.
+type Store interface {
+	// Save writes cp, replacing any previous checkpoint for its key.
+	Save(cp *Checkpoint) error
+	// Get returns the checkpoint for key, or nil if there is none.
+	Get(key string) (*Checkpoint, error)
+	// Delete removes the checkpoint for key.
+	Delete(key string) error
+	// List returns every checkpoint, most recently updated first.
+	List() ([]*Checkpoint, error)
+}
+
+// Open creates the configured store: by default a table in the embedded
+// database at cfg.Path (default .agentflow/agentflow.db), or with the file
+// backend a directory of JSON files (default .agentflow/partial).
+func Open(cfg Config) (Store, error) {
+	switch cfg.Backend {
+	case "", storage.BackendSQLite:
+		db, err := storage.Open(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		return NewSQLiteStore(db)
+	case storage.BackendFile:
+		return NewFileStore(cfg.Path)
+	default:
+		return nil, fmt.Errorf("unknown checkpoint backend %q", cfg.Backend)
+	}
+}
+
+// FileStore keeps one JSON checkpoint file per key.
+type FileStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+// NewFileStore creates a store rooted at dir (default .agentflow/partial).
+func NewFileStore(dir string) (*FileStore, error) {
+	if dir == "" {
+		dir = filepath.Join(".agentflow", "partial")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create checkpoint directory %s: %w", dir, err)
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+func (s *FileStore) path(key string) string {
+	return filepath.Join(s.dir, strings.NewReplacer("/", "_", ":", "_").Replace(key)+".json")
+}
+
+func (s *FileStore) Save(cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	tmp := s.path(cp.Key) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(cp.Key))
+}
+
+func (s *FileStore) Get(key string) (*Checkpoint, error) {
+	data, err := os.ReadFile(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint %s: %w", key, err)
+	}
+	return &cp, nil
+}
+
+func (s *FileStore) Delete(key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := os.Remove(s.path(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *FileStore) List() ([]*Checkpoint, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	var cps []*Checkpoint
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, e.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var cp Checkpoint
+		if err := json.Unmarshal(data, &cp); err != nil {
+			return nil, fmt.Errorf("failed to decode checkpoint %s: %w", e.Name(), err)
+		}
+		cps = append(cps, &cp)
+	}
+	sort.Slice(cps, func(i, j int) bool { return cps[i].UpdatedAt.After(cps[j].UpdatedAt) })
+	return cps, nil
+}
+
+// Key identifies an agent's generation within a run. It is empty when the
+// event carries no run ID, which disables persistence.
+func Key(event core.Event, agent string) string {
+	runID, _ := event.GetMetadataValue(history.RunIDKey)
+	if runID == "" {
+		return ""
+	}
+	return runID + ":" + agent
+}
@@ -0,0 +1,67 @@
+package partial
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+// testStore checks a Store's round trip, replacement, ordering and delete.
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+	if cp, err := s.Get("run-1:a"); cp != nil || err != nil {
+		t.Fatalf("Get of a missing key = %v, %v", cp, err)
+	}
+	now := time.Now()
+	for _, cp := range []*Checkpoint{
+		{Key: "run-1:a", Content: "old", UpdatedAt: now.Add(-time.Minute)},
+		{Key: "run-1:a", Content: "new", UpdatedAt: now.Add(-time.Minute)},
+		{Key: "run-2:b", Content: "b", UpdatedAt: now},
+	} {
+		if err := s.Save(cp); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if cp, err := s.Get("run-1:a"); err != nil || cp.Content != "new" {
+		t.Errorf("Get = %+v, %v", cp, err)
+	}
+	cps, err := s.List()
+	if err != nil || len(cps) != 2 || cps[0].Key != "run-2:b" {
+		t.Errorf("List = %v, %v, want run-2:b first", cps, err)
+	}
+	if err := s.Delete("run-1:a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete("run-1:a"); err != nil {
+		t.Errorf("Delete of a missing key = %v", err)
+	}
+	if cp, _ := s.Get("run-1:a"); cp != nil {
+		t.Error("Get after Delete found the checkpoint")
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	s, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStore(t, s)
+}
+
+func TestOpenUnknownBackend(t *testing.T) {
+	if _, err := Open(Config{Backend: "redis"}); err == nil {
+		t.Error("Open accepted an unknown backend")
+	}
+}
+
+func TestKey(t *testing.T) {
+	if got := Key(core.NewEvent("a", nil, map[string]string{history.RunIDKey: "run-1"}), "processor"); got != "run-1:processor" {
+		t.Errorf("Key = %q", got)
+	}
+	if got := Key(core.NewEvent("a", nil, nil), "processor"); got != "" {
+		t.Errorf("Key without a run ID = %q", got)
+	}
+}
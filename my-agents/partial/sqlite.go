@@ -0,0 +1,84 @@
+package partial
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"my-agents/storage"
+)
+
+// SQLiteStore keeps checkpoints in a table of the embedded database.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore creates a store in db, creating its table if needed.
+func NewSQLiteStore(db *sql.DB) (*SQLiteStore, error) {
+	err := storage.Migrate(db,
+		`CREATE TABLE IF NOT EXISTS checkpoints (
+			key        TEXT PRIMARY KEY,
+			updated_at INTEGER NOT NULL,
+			data       TEXT NOT NULL
+		)`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Save(cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(
+		`INSERT INTO checkpoints (key, updated_at, data) VALUES (?, ?, ?)
+		 ON CONFLICT (key) DO UPDATE SET updated_at = excluded.updated_at, data = excluded.data`,
+		cp.Key, cp.UpdatedAt.UnixNano(), string(data))
+	return err
+}
+
+func (s *SQLiteStore) Get(key string) (*Checkpoint, error) {
+	var data string
+	err := s.db.QueryRow(`SELECT data FROM checkpoints WHERE key = ?`, key).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal([]byte(data), &cp); err != nil {
+		return nil, fmt.Errorf("failed to decode checkpoint %s: %w", key, err)
+	}
+	return &cp, nil
+}
+
+func (s *SQLiteStore) Delete(key string) error {
+	_, err := s.db.Exec(`DELETE FROM checkpoints WHERE key = ?`, key)
+	return err
+}
+
+func (s *SQLiteStore) List() ([]*Checkpoint, error) {
+	rows, err := s.db.Query(`SELECT key, data FROM checkpoints ORDER BY updated_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var cps []*Checkpoint
+	for rows.Next() {
+		var key, data string
+		if err := rows.Scan(&key, &data); err != nil {
+			return nil, err
+		}
+		var cp Checkpoint
+		if err := json.Unmarshal([]byte(data), &cp); err != nil {
+			return nil, fmt.Errorf("failed to decode checkpoint %s: %w", key, err)
+		}
+		cps = append(cps, &cp)
+	}
+	return cps, rows.Err()
+}
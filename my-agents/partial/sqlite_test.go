@@ -0,0 +1,17 @@
+package partial
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	s, err := Open(Config{Path: filepath.Join(t.TempDir(), "agentflow.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(*SQLiteStore); !ok {
+		t.Fatalf("Open = %T, want the SQLite store by default", s)
+	}
+	testStore(t, s)
+}
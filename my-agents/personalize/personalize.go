@@ -0,0 +1,114 @@
+// Package personalize adapts the formatter's response to its recipient:
+// the verbosity, format and expertise level they set in their profile are
+// applied by a short rewrite of the formatted text. Recipients without
+// preferences, and events naming no user, get the response unchanged.
+package personalize
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/profile"
+)
+
+// Key is the state key holding the preferences the final response was
+// adapted to.
+const Key = "personalized"
+
+// Config is the [personalization] section of agentflow.toml:
+//
+//	[personalization]
+//	enabled = true
+//	provider = "cheap"   # a [providers.<name>] table; default the formatter's own
+type Config struct {
+	Enabled  bool   `toml:"enabled"`
+	Provider string `toml:"provider"`
+}
+
+// Personalizer rewrites responses for their recipients' preferences.
+type Personalizer struct {
+	profiles *profile.Store
+	llm      core.ModelProvider
+}
+
+// New creates a personalizer reading preferences from profiles and
+// rewriting with llm. It returns nil unless cfg is enabled.
+func New(cfg Config, profiles *profile.Store, llm core.ModelProvider) *Personalizer {
+	if !cfg.Enabled {
+		return nil
+	}
+	return &Personalizer{profiles: profiles, llm: llm}
+}
+
+// Adapt rewrites text for the user's preferences, set or learned from their
+// feedback, and returns it with the preferences applied. A failed lookup or
+// rewrite is logged and text comes back as it was, with no preferences: the
+// response is still fit to send.
+func (p *Personalizer) Adapt(ctx context.Context, userID, text string) (string, profile.Preferences) {
+	if userID == "" {
+		return text, profile.Preferences{}
+	}
+	prof, err := p.profiles.Get(ctx, userID)
+	if err != nil {
+		core.Logger().Warn().Err(err).Str("user", userID).Msg("Failed to load recipient preferences")
+		return text, profile.Preferences{}
+	}
+	prefs := prof.Effective()
+	if prefs.Empty() {
+		return text, profile.Preferences{}
+	}
+	resp, err := p.llm.Call(ctx, Prompt(prefs, text))
+	if err != nil || strings.TrimSpace(resp.Content) == "" {
+		core.Logger().Warn().Err(err).Str("user", userID).Msg("Failed to personalize response")
+		return text, profile.Preferences{}
+	}
+	return resp.Content, prefs
+}
+
+// Prompt asks the model to rewrite text for prefs.
+func Prompt(prefs profile.Preferences, text string) core.Prompt {
+	var b strings.Builder
+	b.WriteString("Rewrite the response below for its reader. Keep every fact, figure, code block, table and link; change only how it is written.\n\nThe reader's preferences:\n")
+	for _, instruction := range instructions(prefs) {
+		fmt.Fprintf(&b, "- %s\n", instruction)
+	}
+	fmt.Fprintf(&b, "\nResponse:\n%s", text)
+	return core.Prompt{
+		System: "You are an editor adapting responses to their readers. Return only the rewritten response.",
+		User:   b.String(),
+	}
+}
+
+func instructions(prefs profile.Preferences) []string {
+	var list []string
+	switch prefs.Verbosity {
+	case "brief":
+		list = append(list, "Make it brief: only the essential points, in as few words as they need.")
+	case "standard":
+		list = append(list, "Keep it to a moderate length.")
+	case "detailed":
+		list = append(list, "Make it thorough: expand on the reasoning and add examples where they help, without adding facts.")
+	}
+	switch prefs.Format {
+	case "prose":
+		list = append(list, "Write flowing paragraphs, without lists or headings.")
+	case "bullets":
+		list = append(list, "Present it as a list of short bullet points.")
+	case "markdown":
+		list = append(list, "Structure it with Markdown headings, lists and emphasis.")
+	case "plain":
+		list = append(list, "Use plain text, with no Markdown syntax.")
+	}
+	switch prefs.Expertise {
+	case "beginner":
+		list = append(list, "Write for a newcomer to the subject: avoid jargon and explain any technical term you keep.")
+	case "intermediate":
+		list = append(list, "Assume working familiarity with the subject; explain only specialized terms.")
+	case "expert":
+		list = append(list, "Write for an expert: use precise technical terms and skip the basics.")
+	}
+	return list
+}
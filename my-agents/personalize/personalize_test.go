@@ -0,0 +1,92 @@
+package personalize
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/profile"
+)
+
+// rewriter answers every call with reply, recording the prompts.
+type rewriter struct {
+	core.ModelProvider
+	reply   string
+	err     error
+	prompts []core.Prompt
+}
+
+func (r *rewriter) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	r.prompts = append(r.prompts, prompt)
+	return core.Response{Content: r.reply}, r.err
+}
+
+func TestNew(t *testing.T) {
+	if p := New(Config{}, nil, nil); p != nil {
+		t.Error("disabled personalizer created")
+	}
+}
+
+func TestAdapt(t *testing.T) {
+	profiles, err := profile.Open(profile.Config{}, filepath.Join(t.TempDir(), "agentflow.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	prefs := profile.Preferences{Verbosity: "brief", Expertise: "beginner"}
+	if _, err := profiles.SetPreferences(ctx, "ann", prefs); err != nil {
+		t.Fatal(err)
+	}
+	llm := &rewriter{reply: "Go is quick."}
+	p := New(Config{Enabled: true}, profiles, llm)
+
+	got, applied := p.Adapt(ctx, "ann", "Go compiles to fast native code.")
+	if got != "Go is quick." || applied != prefs {
+		t.Errorf("Adapt = %q, %+v", got, applied)
+	}
+	for _, s := range []string{
+		"- Make it brief: only the essential points",
+		"- Write for a newcomer to the subject",
+		"Response:\nGo compiles to fast native code.",
+	} {
+		if !strings.Contains(llm.prompts[0].User, s) {
+			t.Errorf("prompt lacks %q:\n%s", s, llm.prompts[0].User)
+		}
+	}
+
+	// No user, no preferences, or no rewrite: the text is sent as it is
+	for _, user := range []string{"", "bob"} {
+		if got, applied := p.Adapt(ctx, user, "text"); got != "text" || !applied.Empty() {
+			t.Errorf("user %q: %q, %+v", user, got, applied)
+		}
+	}
+	if len(llm.prompts) != 1 {
+		t.Errorf("%d rewrites", len(llm.prompts))
+	}
+	// Traits learned from feedback apply too
+	profiles.Learn(ctx, "cat", profile.Feedback{Comment: "Too long, and full of jargon"}, "")
+	if _, applied := p.Adapt(ctx, "cat", "text"); applied != prefs {
+		t.Errorf("learned preferences = %+v", applied)
+	}
+	for _, llm := range []*rewriter{{err: errors.New("model down")}, {reply: "\n"}} {
+		p := New(Config{Enabled: true}, profiles, llm)
+		if got, applied := p.Adapt(ctx, "ann", "text"); got != "text" || !applied.Empty() {
+			t.Errorf("failed rewrite: %q, %+v", got, applied)
+		}
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	prompt := Prompt(profile.Preferences{Verbosity: "detailed", Format: "plain", Expertise: "expert"}, "text")
+	want := "The reader's preferences:\n" +
+		"- Make it thorough: expand on the reasoning and add examples where they help, without adding facts.\n" +
+		"- Use plain text, with no Markdown syntax.\n" +
+		"- Write for an expert: use precise technical terms and skip the basics.\n"
+	if !strings.Contains(prompt.User, want) || prompt.System == "" {
+		t.Errorf("prompt = %+v", prompt)
+	}
+}
@@ -0,0 +1,499 @@
+// Package plan gives side-effecting workflows a plan/apply cycle: a run in
+// plan mode goes through every agent as usual, but calls to tools that take
+// external actions and writes to output sinks are recorded instead of made.
+// The recorded actions — emails to send, tickets to create, rows to write —
+// wait for approval and are executed, exactly as planned, when the plan is
+// applied.
+package plan
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+	"my-agents/sink"
+	"my-agents/tools"
+	"my-agents/usage"
+)
+
+// ModeKey is the event metadata key marking a run whose external actions
+// are recorded rather than made: ModePlan. Runs of the configured
+// workflows always are.
+const ModeKey = "plan_mode"
+
+// ModePlan is the ModeKey value of planned runs.
+const ModePlan = "plan"
+
+// DefaultPath is where plans are kept until they are applied.
+const DefaultPath = ".agentflow/plans.json"
+
+// Plan statuses.
+const (
+	StatusPending   = "pending"   // waiting for approval
+	StatusApplied   = "applied"   // every approved action ran
+	StatusFailed    = "failed"    // an action failed; applying again retries it
+	StatusDiscarded = "discarded" // rejected without running anything
+)
+
+// Action statuses.
+const (
+	ActionPending = "pending"
+	ActionApplied = "applied"
+	ActionFailed  = "failed"
+	ActionSkipped = "skipped" // not approved
+)
+
+// Action kinds.
+const (
+	KindTool = "tool"
+	KindSink = "sink"
+)
+
+// Config is the [plan] section of agentflow.toml:
+//
+//	[plan]
+//	enabled = true
+//	workflows = ["processor"]
+//	tools = ["create_ticket", "send_email"]
+//	sinks = ["crm"]
+type Config struct {
+	Enabled bool `toml:"enabled"`
+	// Workflows are the entry routes whose runs are always planned. Other
+	// runs are planned when their event sets ModeKey to ModePlan.
+	Workflows []string `toml:"workflows"`
+	// Tools and Sinks name those that take external actions; calls to
+	// other tools still run while planning, so agents can look things up.
+	Tools []string `toml:"tools"`
+	Sinks []string `toml:"sinks"`
+	Path  string   `toml:"path"` // default DefaultPath
+}
+
+// Action is one external action a planned run intends to take.
+type Action struct {
+	Index     int            `json:"index"` // 1-based, for approving a subset
+	Kind      string         `json:"kind"`  // KindTool or KindSink
+	Name      string         `json:"name"`  // tool or sink name
+	Agent     string         `json:"agent"`
+	Args      map[string]any `json:"args,omitempty"`    // tool arguments
+	Content   string         `json:"content,omitempty"` // sink content
+	Status    string         `json:"status"`
+	Result    any            `json:"result,omitempty"`
+	Error     string         `json:"error,omitempty"`
+	PlannedAt time.Time      `json:"planned_at"`
+	AppliedAt time.Time      `json:"applied_at,omitzero"`
+}
+
+// Plan is the set of actions one run intends to take.
+type Plan struct {
+	RunID     string    `json:"run_id"`
+	SessionID string    `json:"session_id"`
+	Workflow  string    `json:"workflow"`
+	Status    string    `json:"status"`
+	Actions   []Action  `json:"actions"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// run is the planned run the runner is handling.
+type run struct {
+	id, session, workflow string
+}
+
+// Planner records the actions of planned runs and applies approved plans.
+type Planner struct {
+	cfg       Config
+	path      string
+	workflows map[string]bool
+	effectful map[string]bool // "tool:<name>" and "sink:<name>"
+	runs      history.Store
+
+	mu      sync.Mutex
+	current *run                  // nil when the event being handled isn't planned
+	tools   map[string]tools.Tool // by "<agent>/<name>", as the agent has it
+	sinks   map[string]sink.Sink
+	plans   map[string]*Plan
+}
+
+// New creates a planner, loading the plans not applied before a restart.
+// runs tells it whether a run has finished planning.
+func New(cfg Config, runs history.Store) (*Planner, error) {
+	p := &Planner{
+		cfg:       cfg,
+		path:      cfg.Path,
+		workflows: make(map[string]bool),
+		effectful: make(map[string]bool),
+		runs:      runs,
+		tools:     make(map[string]tools.Tool),
+		sinks:     make(map[string]sink.Sink),
+		plans:     make(map[string]*Plan),
+	}
+	if p.path == "" {
+		p.path = DefaultPath
+	}
+	for _, w := range cfg.Workflows {
+		p.workflows[w] = true
+	}
+	for _, name := range cfg.Tools {
+		p.effectful[KindTool+":"+name] = true
+	}
+	for _, name := range cfg.Sinks {
+		p.effectful[KindSink+":"+name] = true
+	}
+	data, err := os.ReadFile(p.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read plans: %w", err)
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &p.plans); err != nil {
+			return nil, fmt.Errorf("failed to parse plans %s: %w", p.path, err)
+		}
+	}
+	return p, nil
+}
+
+// ToolMiddleware defers calls to the configured tools while a planned run
+// is being handled. It has the shape of di.ToolMiddleware.
+func (p *Planner) ToolMiddleware() func(agent string, tool tools.Tool) tools.Tool {
+	return func(agent string, tool tools.Tool) tools.Tool {
+		if !p.effectful[KindTool+":"+tool.Name()] {
+			return tool
+		}
+		p.mu.Lock()
+		p.tools[agent+"/"+tool.Name()] = tool
+		p.mu.Unlock()
+		return &plannedTool{Tool: tool, p: p, agent: agent}
+	}
+}
+
+// SinkMiddleware defers writes to the configured sinks while a planned run
+// is being handled. It has the shape of di.SinkMiddleware.
+func (p *Planner) SinkMiddleware() func(agent, name string, s sink.Sink) sink.Sink {
+	return func(agent, name string, s sink.Sink) sink.Sink {
+		if !p.effectful[KindSink+":"+name] {
+			return s
+		}
+		p.mu.Lock()
+		p.sinks[agent+"/"+name] = s
+		p.mu.Unlock()
+		return &plannedSink{Sink: s, p: p, agent: agent, name: name}
+	}
+}
+
+// Wrap returns runner with the configured workflows' new runs put in plan
+// mode. The mode is set as events are emitted, before the history recorder
+// sees them, so a resumed run stays in the mode it started in.
+func (p *Planner) Wrap(runner core.Runner) core.Runner {
+	return &planningRunner{Runner: runner, p: p}
+}
+
+// Register tracks which planned run the runner is handling; the default
+// runner handles one event at a time.
+func (p *Planner) Register(runner core.Runner) error {
+	return runner.RegisterCallback(core.HookBeforeEventHandling, "plan", func(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+		event := args.Event
+		if event == nil {
+			return args.State, nil
+		}
+		var current *run
+		if mode, _ := event.GetMetadataValue(ModeKey); mode == ModePlan {
+			runID, ok := event.GetMetadataValue(history.RunIDKey)
+			if !ok || runID == "" {
+				runID = event.GetID()
+			}
+			workflow, _ := event.GetMetadataValue(usage.WorkflowKey)
+			if workflow == "" {
+				workflow, _ = event.GetMetadataValue(core.RouteMetadataKey)
+				event.SetMetadata(usage.WorkflowKey, workflow)
+			}
+			current = &run{id: runID, session: event.GetSessionID(), workflow: workflow}
+		}
+		p.mu.Lock()
+		p.current = current
+		p.mu.Unlock()
+		return args.State, nil
+	})
+}
+
+// record adds an action to the current run's plan. It returns false when
+// the event being handled isn't planned.
+func (p *Planner) record(action Action) (int, bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.current == nil {
+		return 0, false, nil
+	}
+	pl := p.plans[p.current.id]
+	if pl == nil || pl.Status != StatusPending {
+		// A run planned again after its earlier plan was settled starts over
+		pl = &Plan{RunID: p.current.id, SessionID: p.current.session, Workflow: p.current.workflow, Status: StatusPending, CreatedAt: time.Now()}
+		p.plans[p.current.id] = pl
+	}
+	action.Index = len(pl.Actions) + 1
+	action.Status = ActionPending
+	action.PlannedAt = time.Now()
+	pl.Actions = append(pl.Actions, action)
+	pl.UpdatedAt = time.Now()
+	if err := p.save(); err != nil {
+		return 0, true, err
+	}
+	core.Logger().Info().Str("run_id", pl.RunID).Str(action.Kind, action.Name).Int("action", action.Index).Msg("External action planned")
+	return action.Index, true, nil
+}
+
+// Get returns the plan of a run.
+func (p *Planner) Get(runID string) (*Plan, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pl, ok := p.plans[runID]
+	if !ok {
+		return nil, fmt.Errorf("run %s has no plan", runID)
+	}
+	c := pl.snapshot()
+	return &c, nil
+}
+
+// List returns the plans with the given status (all when empty), oldest
+// first.
+func (p *Planner) List(status string) []Plan {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var out []Plan
+	for _, pl := range p.plans {
+		if status == "" || pl.Status == status {
+			out = append(out, pl.snapshot())
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out
+}
+
+// Apply runs a finished run's planned actions in order. approved selects
+// actions by index; nil approves them all, and the rest are skipped. Apply
+// stops at the first failure; applying the plan again retries from there.
+func (p *Planner) Apply(ctx context.Context, runID string, approved []int) (*Plan, error) {
+	if err := p.finished(ctx, runID); err != nil {
+		return nil, err
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pl, ok := p.plans[runID]
+	if !ok {
+		return nil, fmt.Errorf("run %s has no plan", runID)
+	}
+	if pl.Status != StatusPending && pl.Status != StatusFailed {
+		return nil, fmt.Errorf("plan for run %s is %s", runID, pl.Status)
+	}
+	selected := make(map[int]bool, len(approved))
+	for _, i := range approved {
+		if i < 1 || i > len(pl.Actions) {
+			return nil, fmt.Errorf("plan for run %s has no action %d", runID, i)
+		}
+		selected[i] = true
+	}
+
+	ctx = history.WithRunID(ctx, runID)
+	var failed error
+	for i := range pl.Actions {
+		a := &pl.Actions[i]
+		if a.Status == ActionApplied || a.Status == ActionSkipped {
+			continue
+		}
+		if approved != nil && !selected[a.Index] {
+			a.Status = ActionSkipped
+			continue
+		}
+		// Later actions may depend on this one, so nothing runs past a failure
+		if failed == nil {
+			failed = p.execute(ctx, pl, a)
+		}
+	}
+	pl.Status = StatusApplied
+	if failed != nil {
+		pl.Status = StatusFailed
+	}
+	pl.UpdatedAt = time.Now()
+	if err := p.save(); err != nil {
+		return nil, err
+	}
+	core.Logger().Info().Str("run_id", runID).Str("status", pl.Status).Msg("Plan applied")
+	c := pl.snapshot()
+	return &c, failed
+}
+
+// execute performs one action, recording its outcome. Callers hold p.mu.
+func (p *Planner) execute(ctx context.Context, pl *Plan, a *Action) error {
+	var err error
+	switch a.Kind {
+	case KindTool:
+		tool, ok := p.tools[a.Agent+"/"+a.Name]
+		if !ok {
+			err = fmt.Errorf("tool %q is not available", a.Name)
+			break
+		}
+		a.Result, err = tool.Call(ctx, a.Args)
+	case KindSink:
+		s, ok := p.sinks[a.Agent+"/"+a.Name]
+		if !ok {
+			err = fmt.Errorf("sink %q is not available", a.Name)
+			break
+		}
+		err = s.Write(ctx, pl.SessionID, a.Content)
+	default:
+		err = fmt.Errorf("unknown action kind %q", a.Kind)
+	}
+	a.AppliedAt = time.Now()
+	if err != nil {
+		a.Status, a.Error = ActionFailed, err.Error()
+		return fmt.Errorf("action %d (%s %s): %w", a.Index, a.Kind, a.Name, err)
+	}
+	a.Status, a.Error = ActionApplied, ""
+	return nil
+}
+
+// Discard rejects a plan without running any of its actions.
+func (p *Planner) Discard(runID string) (*Plan, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	pl, ok := p.plans[runID]
+	if !ok {
+		return nil, fmt.Errorf("run %s has no plan", runID)
+	}
+	if pl.Status != StatusPending && pl.Status != StatusFailed {
+		return nil, fmt.Errorf("plan for run %s is %s", runID, pl.Status)
+	}
+	for i := range pl.Actions {
+		if pl.Actions[i].Status == ActionPending || pl.Actions[i].Status == ActionFailed {
+			pl.Actions[i].Status = ActionSkipped
+		}
+	}
+	pl.Status, pl.UpdatedAt = StatusDiscarded, time.Now()
+	if err := p.save(); err != nil {
+		return nil, err
+	}
+	c := pl.snapshot()
+	return &c, nil
+}
+
+// finished checks that a run is no longer adding actions to its plan.
+func (p *Planner) finished(ctx context.Context, runID string) error {
+	if p.runs == nil {
+		return nil
+	}
+	run, err := p.runs.Get(ctx, runID)
+	if errors.Is(err, history.ErrNotFound) {
+		return nil // history may be kept elsewhere; the plan is what counts
+	}
+	if err != nil {
+		return err
+	}
+	if run.Status == history.StatusRunning || run.Status == history.StatusAwaitingInput {
+		return fmt.Errorf("run %s is still %s; apply its plan when it finishes", runID, run.Status)
+	}
+	return nil
+}
+
+// save writes the plans atomically. Callers hold p.mu.
+func (p *Planner) save() error {
+	data, err := json.MarshalIndent(p.plans, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p.path), 0o755); err != nil {
+		return fmt.Errorf("failed to store plans: %w", err)
+	}
+	tmp := p.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to store plans: %w", err)
+	}
+	if err := os.Rename(tmp, p.path); err != nil {
+		return fmt.Errorf("failed to store plans: %w", err)
+	}
+	return nil
+}
+
+// Describe lists a plan's actions for a reviewer, one per line.
+func Describe(pl *Plan) string {
+	var b strings.Builder
+	for _, a := range pl.Actions {
+		detail := a.Content
+		if a.Kind == KindTool {
+			data, _ := json.Marshal(a.Args)
+			detail = string(data)
+		}
+		if r := []rune(detail); len(r) > 200 {
+			detail = string(r[:200]) + "…"
+		}
+		fmt.Fprintf(&b, "  %d. [%s] %s %s (%s): %s\n", a.Index, a.Status, a.Kind, a.Name, a.Agent, strings.ReplaceAll(detail, "\n", " "))
+		if a.Error != "" {
+			fmt.Fprintf(&b, "     error: %s\n", a.Error)
+		}
+	}
+	return b.String()
+}
+
+// snapshot copies pl for use outside the planner's lock.
+func (pl *Plan) snapshot() Plan {
+	c := *pl
+	c.Actions = append([]Action(nil), pl.Actions...)
+	return c
+}
+
+type plannedTool struct {
+	tools.Tool
+	p     *Planner
+	agent string
+}
+
+// Unwrap returns the wrapped tool.
+func (t *plannedTool) Unwrap() tools.Tool { return t.Tool }
+
+func (t *plannedTool) Call(ctx context.Context, args map[string]any) (any, error) {
+	n, planned, err := t.p.record(Action{Kind: KindTool, Name: t.Name(), Agent: t.agent, Args: args})
+	if !planned {
+		return t.Tool.Call(ctx, args)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return fmt.Sprintf("Planned as action %d: %s runs when the plan is approved and applied, so its result is not available yet.", n, t.Name()), nil
+}
+
+type plannedSink struct {
+	sink.Sink
+	p     *Planner
+	agent string
+	name  string
+}
+
+func (s *plannedSink) Write(ctx context.Context, sessionID, content string) error {
+	_, planned, err := s.p.record(Action{Kind: KindSink, Name: s.name, Agent: s.agent, Content: content})
+	if !planned {
+		return s.Sink.Write(ctx, sessionID, content)
+	}
+	return err
+}
+
+type planningRunner struct {
+	core.Runner
+	p *Planner
+}
+
+// Emit plans the runs of the configured workflows whatever the event's
+// metadata says, so no caller opts out of review.
+func (r *planningRunner) Emit(event core.Event) error {
+	route, _ := event.GetMetadataValue(core.RouteMetadataKey)
+	if r.p.workflows[route] {
+		event.SetMetadata(ModeKey, ModePlan)
+	}
+	return r.Runner.Emit(event)
+}
@@ -0,0 +1,289 @@
+package plan
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+	"my-agents/tools"
+)
+
+// tool records its calls and fails while fail is set.
+type tool struct {
+	name  string
+	calls []map[string]any
+	fail  bool
+}
+
+func (t *tool) Name() string        { return t.name }
+func (t *tool) Description() string { return t.name }
+
+func (t *tool) Call(ctx context.Context, args map[string]any) (any, error) {
+	if t.fail {
+		return nil, errors.New("mail server down")
+	}
+	t.calls = append(t.calls, args)
+	return "sent", nil
+}
+
+// output records what is written to it.
+type output struct{ written []string }
+
+func (s *output) Write(ctx context.Context, sessionID, content string) error {
+	s.written = append(s.written, sessionID+": "+content)
+	return nil
+}
+
+// runner records emitted events and the callback registered on it.
+type runner struct {
+	core.Runner
+	emitted  []core.Event
+	callback core.CallbackFunc
+}
+
+func (r *runner) Emit(event core.Event) error {
+	r.emitted = append(r.emitted, event)
+	return nil
+}
+
+func (r *runner) RegisterCallback(hook core.HookPoint, name string, cb core.CallbackFunc) error {
+	r.callback = cb
+	return nil
+}
+
+func newPlanner(t *testing.T, runs history.Store) (*Planner, *runner) {
+	t.Helper()
+	p, err := New(Config{
+		Workflows: []string{"support"},
+		Tools:     []string{"send_email"},
+		Sinks:     []string{"crm"},
+		Path:      filepath.Join(t.TempDir(), "plans.json"),
+	}, runs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &runner{}
+	if err := p.Register(r); err != nil {
+		t.Fatal(err)
+	}
+	return p, r
+}
+
+// handle runs the planner's callback as the runner starts on event.
+func handle(t *testing.T, r *runner, event core.Event) {
+	t.Helper()
+	if _, err := r.callback(context.Background(), core.CallbackArgs{Event: event}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func plannedEvent(id string) core.Event {
+	event := core.NewEvent("writer", nil, map[string]string{ModeKey: ModePlan, core.RouteMetadataKey: "support", "session_id": "s1"})
+	event.SetID(id)
+	return event
+}
+
+func TestWrap(t *testing.T) {
+	p, _ := newPlanner(t, nil)
+	inner := &runner{}
+	r := p.Wrap(inner)
+	r.Emit(core.NewEvent("a", nil, map[string]string{core.RouteMetadataKey: "support"}))
+	r.Emit(core.NewEvent("a", nil, map[string]string{core.RouteMetadataKey: "billing"}))
+	if mode, _ := inner.emitted[0].GetMetadataValue(ModeKey); mode != ModePlan {
+		t.Error("configured workflow not planned")
+	}
+	if mode, _ := inner.emitted[1].GetMetadataValue(ModeKey); mode != "" {
+		t.Error("other workflow planned")
+	}
+}
+
+func TestPlanAndApply(t *testing.T) {
+	p, r := newPlanner(t, nil)
+	email := &tool{name: "send_email"}
+	lookup := &tool{name: "lookup"}
+	crm := &output{}
+	sendEmail := p.ToolMiddleware()("writer", email)
+	if p.ToolMiddleware()("writer", lookup) != lookup {
+		t.Error("tool without side effects wrapped")
+	}
+	writeCRM := p.SinkMiddleware()("writer", "crm", crm)
+	ctx := context.Background()
+
+	handle(t, r, plannedEvent("run-1"))
+	out, err := sendEmail.Call(ctx, map[string]any{"to": "ann@example.com"})
+	if err != nil || !strings.Contains(out.(string), "Planned as action 1") {
+		t.Fatalf("planned call = %v, %v", out, err)
+	}
+	if err := writeCRM.Write(ctx, "s1", "ticket: refund"); err != nil {
+		t.Fatal(err)
+	}
+	if len(email.calls) != 0 || len(crm.written) != 0 {
+		t.Fatal("external actions taken while planning")
+	}
+
+	// An unplanned event's calls go straight through
+	handle(t, r, core.NewEvent("writer", nil, nil))
+	sendEmail.Call(ctx, map[string]any{"to": "bob@example.com"})
+	if len(email.calls) != 1 {
+		t.Errorf("unplanned call not made")
+	}
+
+	pl, err := p.Get("run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pl.Status != StatusPending || len(pl.Actions) != 2 || pl.Workflow != "support" || pl.SessionID != "s1" {
+		t.Fatalf("plan = %+v", pl)
+	}
+	if d := Describe(pl); !strings.Contains(d, `1. [pending] tool send_email (writer): {"to":"ann@example.com"}`) {
+		t.Errorf("Describe = %q", d)
+	}
+
+	pl, err = p.Apply(ctx, "run-1", []int{2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pl.Status != StatusApplied || pl.Actions[0].Status != ActionSkipped || pl.Actions[1].Status != ActionApplied {
+		t.Errorf("applied plan = %+v", pl)
+	}
+	if len(crm.written) != 1 || crm.written[0] != "s1: ticket: refund" || len(email.calls) != 1 {
+		t.Errorf("sink got %v, email calls %d; want only the approved action run", crm.written, len(email.calls))
+	}
+	if _, err := p.Apply(ctx, "run-1", nil); err == nil {
+		t.Error("applied plan applied again")
+	}
+}
+
+func TestApplyRetriesFailure(t *testing.T) {
+	p, r := newPlanner(t, nil)
+	email := &tool{name: "send_email", fail: true}
+	sendEmail := p.ToolMiddleware()("writer", email)
+	ctx := context.Background()
+	handle(t, r, plannedEvent("run-1"))
+	sendEmail.Call(ctx, map[string]any{"n": 1})
+	sendEmail.Call(ctx, map[string]any{"n": 2})
+
+	pl, err := p.Apply(ctx, "run-1", nil)
+	if err == nil || pl.Status != StatusFailed || pl.Actions[0].Error == "" || pl.Actions[1].Status != ActionPending {
+		t.Fatalf("apply = %+v, %v; want it stopped at the first failure", pl, err)
+	}
+	email.fail = false
+	pl, err = p.Apply(ctx, "run-1", nil)
+	if err != nil || pl.Status != StatusApplied || len(email.calls) != 2 {
+		t.Errorf("retry = %+v, %v after %d calls", pl, err, len(email.calls))
+	}
+}
+
+func TestApplyWaitsForRun(t *testing.T) {
+	runs := history.NewMemoryStore()
+	p, r := newPlanner(t, runs)
+	sendEmail := p.ToolMiddleware()("writer", &tool{name: "send_email"})
+	ctx := context.Background()
+	handle(t, r, plannedEvent("run-1"))
+	sendEmail.Call(ctx, nil)
+
+	runs.Save(ctx, &history.Run{ID: "run-1", Status: history.StatusRunning})
+	if _, err := p.Apply(ctx, "run-1", nil); err == nil {
+		t.Error("applied the plan of a running run")
+	}
+	runs.Save(ctx, &history.Run{ID: "run-1", Status: history.StatusCompleted})
+	if _, err := p.Apply(ctx, "run-1", []int{5}); err == nil {
+		t.Error("approved an action the plan doesn't have")
+	}
+	if _, err := p.Apply(ctx, "run-1", nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDiscard(t *testing.T) {
+	p, r := newPlanner(t, nil)
+	email := &tool{name: "send_email"}
+	sendEmail := p.ToolMiddleware()("writer", email)
+	ctx := context.Background()
+	handle(t, r, plannedEvent("run-1"))
+	sendEmail.Call(ctx, nil)
+
+	pl, err := p.Discard("run-1")
+	if err != nil || pl.Status != StatusDiscarded || pl.Actions[0].Status != ActionSkipped {
+		t.Fatalf("Discard = %+v, %v", pl, err)
+	}
+	if _, err := p.Apply(ctx, "run-1", nil); err == nil || len(email.calls) != 0 {
+		t.Error("discarded plan applied")
+	}
+	if _, err := p.Discard("run-2"); err == nil {
+		t.Error("discarded a plan that doesn't exist")
+	}
+
+	// Planning the run again starts a new plan
+	handle(t, r, plannedEvent("run-1"))
+	sendEmail.Call(ctx, nil)
+	if pl, _ := p.Get("run-1"); pl.Status != StatusPending || len(pl.Actions) != 1 {
+		t.Errorf("replanned = %+v", pl)
+	}
+}
+
+func TestPersistence(t *testing.T) {
+	p, r := newPlanner(t, nil)
+	sendEmail := p.ToolMiddleware()("writer", &tool{name: "send_email"})
+	handle(t, r, plannedEvent("run-1"))
+	sendEmail.Call(context.Background(), map[string]any{"to": "ann"})
+
+	reloaded, err := New(p.cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plans := reloaded.List(StatusPending); len(plans) != 1 || plans[0].Actions[0].Args["to"] != "ann" {
+		t.Errorf("reloaded plans = %+v", plans)
+	}
+	if plans := reloaded.List(StatusApplied); len(plans) != 0 {
+		t.Errorf("status filter returned %v", plans)
+	}
+}
+
+// runTool records the run ID its calls are made in.
+type runTool struct {
+	tool
+	runs []string
+}
+
+func (t *runTool) Call(ctx context.Context, args map[string]any) (any, error) {
+	id, _ := history.RunIDFrom(ctx)
+	t.runs = append(t.runs, id)
+	return t.tool.Call(ctx, args)
+}
+
+func TestApplyPerAgent(t *testing.T) {
+	p, r := newPlanner(t, nil)
+	writer := &runTool{tool: tool{name: "send_email"}}
+	reviewer := &runTool{tool: tool{name: "send_email"}}
+	p.ToolMiddleware()("writer", writer)
+	sendEmail := p.ToolMiddleware()("reviewer", reviewer)
+	ctx := context.Background()
+	handle(t, r, plannedEvent("run-1"))
+	sendEmail.Call(ctx, nil)
+
+	if _, err := p.Apply(ctx, "run-1", nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(writer.runs) != 0 || len(reviewer.runs) != 1 {
+		t.Fatalf("writer called %d times, reviewer %d; want the planning agent's tool", len(writer.runs), len(reviewer.runs))
+	}
+	if reviewer.runs[0] != "run-1" {
+		t.Errorf("applied in run %q, want run-1 for the audit trail", reviewer.runs[0])
+	}
+}
+
+func TestPlannedToolUnwraps(t *testing.T) {
+	p, _ := newPlanner(t, nil)
+	email := &tool{name: "send_email"}
+	wrapped := p.ToolMiddleware()("writer", email)
+	u, ok := wrapped.(interface{ Unwrap() tools.Tool })
+	if !ok || u.Unwrap() != email {
+		t.Error("planned tool does not unwrap to the tool it wraps")
+	}
+}
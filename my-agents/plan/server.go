@@ -0,0 +1,73 @@
+package plan
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+)
+
+// Handler serves the plan endpoints, for mounting on the admin API under
+// "/admin/plans" and "/admin/plans/":
+//
+//	GET    /admin/plans[?status=pending]
+//	GET    /admin/plans/{run}
+//	POST   /admin/plans/{run}/apply    body (optional): {"actions": [1, 3]}
+//	DELETE /admin/plans/{run}
+func (p *Planner) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/plans", func(w http.ResponseWriter, r *http.Request) {
+		plans := p.List(r.URL.Query().Get("status"))
+		if plans == nil {
+			plans = []Plan{}
+		}
+		writeJSON(w, http.StatusOK, plans)
+	})
+	mux.HandleFunc("GET /admin/plans/{run}", func(w http.ResponseWriter, r *http.Request) {
+		pl, err := p.Get(r.PathValue("run"))
+		if err != nil {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, pl)
+	})
+	mux.HandleFunc("POST /admin/plans/{run}/apply", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Actions []int `json:"actions"`
+		}
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		pl, err := p.Apply(r.Context(), r.PathValue("run"), body.Actions)
+		if pl == nil {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		if err != nil {
+			// The plan shows which action failed and why
+			writeJSON(w, http.StatusBadGateway, pl)
+			return
+		}
+		writeJSON(w, http.StatusOK, pl)
+	})
+	mux.HandleFunc("DELETE /admin/plans/{run}", func(w http.ResponseWriter, r *http.Request) {
+		pl, err := p.Discard(r.PathValue("run"))
+		if err != nil {
+			writeError(w, http.StatusConflict, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, pl)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,46 @@
+package plan
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	p, r := newPlanner(t, nil)
+	email := &tool{name: "send_email", fail: true}
+	sendEmail := p.ToolMiddleware()("writer", email)
+	handle(t, r, plannedEvent("run-1"))
+	sendEmail.Call(context.Background(), nil)
+	h := p.Handler()
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, strings.NewReader(body)))
+		return w
+	}
+
+	if w := do("GET", "/admin/plans?status=pending", ""); !strings.Contains(w.Body.String(), `"run_id":"run-1"`) {
+		t.Errorf("list: %s", w.Body)
+	}
+	if w := do("GET", "/admin/plans?status=applied", ""); strings.TrimSpace(w.Body.String()) != "[]" {
+		t.Errorf("empty list: %s", w.Body)
+	}
+	if w := do("GET", "/admin/plans/run-2", ""); w.Code != http.StatusNotFound {
+		t.Errorf("unknown plan: status %d", w.Code)
+	}
+	if w := do("POST", "/admin/plans/run-1/apply", ""); w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), "mail server down") {
+		t.Errorf("failed apply: %d %s", w.Code, w.Body)
+	}
+	if w := do("POST", "/admin/plans/run-1/apply", `{"actions": [`); w.Code != http.StatusBadRequest {
+		t.Errorf("bad body: status %d", w.Code)
+	}
+	email.fail = false
+	if w := do("POST", "/admin/plans/run-1/apply", `{"actions": [1]}`); w.Code != http.StatusOK {
+		t.Errorf("apply: %d %s", w.Code, w.Body)
+	}
+	if w := do("DELETE", "/admin/plans/run-1", ""); w.Code != http.StatusConflict {
+		t.Errorf("discard applied plan: status %d", w.Code)
+	}
+}
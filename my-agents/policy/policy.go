@@ -0,0 +1,239 @@
+// Package policy decides what agents are allowed to do. Operators write
+// allow and deny rules per tenant and workflow, and every tool call and
+// output sink write is checked against them before it happens.
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/sink"
+	"my-agents/tenant"
+	"my-agents/tools"
+	"my-agents/usage"
+)
+
+// Effects.
+const (
+	Allow = "allow"
+	Deny  = "deny"
+)
+
+// ErrDenied is returned in place of a call or write a rule forbids.
+var ErrDenied = errors.New("denied by policy")
+
+// Config is the [policy] section of agentflow.toml. Rules are checked in
+// order and the first match decides; Default applies when none matches.
+//
+//	[policy]
+//	enabled = true
+//	default = "deny"
+//	[[policy.rules]]
+//	actions = ["tool:spreadsheet", "tool:ocr", "sink:stdout"]
+//	effect = "allow"
+//	[[policy.rules]]
+//	actions = ["tool:send_email"]
+//	tenants = ["acme"]
+//	args = { to = "*@acme.com" }
+//	effect = "allow"
+type Config struct {
+	Enabled bool   `toml:"enabled"`
+	Default string `toml:"default"` // Allow (default) or Deny
+	Rules   []Rule `toml:"rules"`
+}
+
+// Rule matches actions by glob patterns (path.Match syntax). Empty lists
+// match everything.
+type Rule struct {
+	// Actions are "tool:<name>" or "sink:<name>" patterns.
+	Actions []string `toml:"actions"`
+	// Tenants match the tenant the HTTP API authenticated the caller as.
+	Tenants   []string `toml:"tenants"`
+	Workflows []string `toml:"workflows"`
+	Agents    []string `toml:"agents"`
+	// Args match tool arguments, formatted as text, by name.
+	Args   map[string]string `toml:"args"`
+	Effect string            `toml:"effect"`
+	Reason string            `toml:"reason"` // shown to the agent when denied
+}
+
+// Request is an action an agent is about to take.
+type Request struct {
+	Action   string // "tool:<name>" or "sink:<name>"
+	Tenant   string
+	Workflow string
+	Agent    string
+	Args     map[string]any
+}
+
+// Decision is the outcome of evaluating a request.
+type Decision struct {
+	Allowed bool
+	Rule    int // 1-based index of the deciding rule; 0 for the default
+	Reason  string
+}
+
+// Policy evaluates rules for the event the runner is handling.
+type Policy struct {
+	cfg Config
+
+	mu       sync.Mutex
+	tenant   string // of the current event
+	workflow string
+}
+
+// New validates cfg and creates a policy.
+func New(cfg Config) (*Policy, error) {
+	if cfg.Default == "" {
+		cfg.Default = Allow
+	}
+	if cfg.Default != Allow && cfg.Default != Deny {
+		return nil, fmt.Errorf("policy default must be %q or %q, not %q", Allow, Deny, cfg.Default)
+	}
+	for i, r := range cfg.Rules {
+		if r.Effect != Allow && r.Effect != Deny {
+			return nil, fmt.Errorf("policy rule %d: effect must be %q or %q", i+1, Allow, Deny)
+		}
+		patterns := append(append(append(append([]string{}, r.Actions...), r.Tenants...), r.Workflows...), r.Agents...)
+		for _, v := range r.Args {
+			patterns = append(patterns, v)
+		}
+		for _, p := range patterns {
+			if _, err := path.Match(p, ""); err != nil {
+				return nil, fmt.Errorf("policy rule %d: invalid pattern %q", i+1, p)
+			}
+		}
+	}
+	return &Policy{cfg: cfg}, nil
+}
+
+// Evaluate decides a request.
+func (p *Policy) Evaluate(req Request) Decision {
+	for i, r := range p.cfg.Rules {
+		if r.matches(req) {
+			return Decision{Allowed: r.Effect == Allow, Rule: i + 1, Reason: r.Reason}
+		}
+	}
+	return Decision{Allowed: p.cfg.Default == Allow}
+}
+
+func (r Rule) matches(req Request) bool {
+	if !anyMatch(r.Actions, req.Action) || !anyMatch(r.Tenants, req.Tenant) ||
+		!anyMatch(r.Workflows, req.Workflow) || !anyMatch(r.Agents, req.Agent) {
+		return false
+	}
+	for name, pattern := range r.Args {
+		v, ok := req.Args[name]
+		if !ok {
+			return false
+		}
+		if matched, _ := path.Match(pattern, fmt.Sprint(v)); !matched {
+			return false
+		}
+	}
+	return true
+}
+
+// anyMatch reports whether s matches one of patterns; no patterns match all.
+func anyMatch(patterns []string, s string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if matched, _ := path.Match(p, s); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// Register tracks the tenant and workflow of the event the runner is
+// handling; the default runner handles one event at a time. On the HTTP
+// API both are the authenticated caller's: the tenant its principal's, the
+// workflow its entry route, as the API lets callers set neither, so they
+// can't pick the rules they are held to.
+func (p *Policy) Register(runner core.Runner) error {
+	return runner.RegisterCallback(core.HookBeforeEventHandling, "policy", func(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+		event := args.Event
+		if event == nil {
+			return args.State, nil
+		}
+		workflow, _ := event.GetMetadataValue(usage.WorkflowKey)
+		if workflow == "" {
+			workflow, _ = event.GetMetadataValue(core.RouteMetadataKey)
+			event.SetMetadata(usage.WorkflowKey, workflow)
+		}
+		p.mu.Lock()
+		p.tenant, p.workflow = tenant.FromEvent(event), workflow
+		p.mu.Unlock()
+		return args.State, nil
+	})
+}
+
+// check evaluates an action of agent within the current event.
+func (p *Policy) check(action, agent string, args map[string]any) error {
+	p.mu.Lock()
+	req := Request{Action: action, Tenant: p.tenant, Workflow: p.workflow, Agent: agent, Args: args}
+	p.mu.Unlock()
+	d := p.Evaluate(req)
+	if d.Allowed {
+		return nil
+	}
+	core.Logger().Warn().Str("action", action).Str("agent", agent).Str("tenant", req.Tenant).Str("workflow", req.Workflow).Int("rule", d.Rule).Msg("Action denied by policy")
+	if d.Reason != "" {
+		return fmt.Errorf("%s: %w: %s", action, ErrDenied, d.Reason)
+	}
+	return fmt.Errorf("%s: %w", action, ErrDenied)
+}
+
+// ToolMiddleware checks every tool call. It has the shape of
+// di.ToolMiddleware; add it before middleware that defers calls, so
+// planned actions are checked when they are planned.
+func (p *Policy) ToolMiddleware() func(agent string, tool tools.Tool) tools.Tool {
+	return func(agent string, tool tools.Tool) tools.Tool {
+		return &checkedTool{Tool: tool, p: p, agent: agent}
+	}
+}
+
+// SinkMiddleware checks every sink write. It has the shape of
+// di.SinkMiddleware.
+func (p *Policy) SinkMiddleware() func(agent, name string, s sink.Sink) sink.Sink {
+	return func(agent, name string, s sink.Sink) sink.Sink {
+		return &checkedSink{Sink: s, p: p, agent: agent, name: name}
+	}
+}
+
+type checkedTool struct {
+	tools.Tool
+	p     *Policy
+	agent string
+}
+
+// Unwrap returns the wrapped tool.
+func (t *checkedTool) Unwrap() tools.Tool { return t.Tool }
+
+func (t *checkedTool) Call(ctx context.Context, args map[string]any) (any, error) {
+	if err := t.p.check("tool:"+t.Name(), t.agent, args); err != nil {
+		return nil, err
+	}
+	return t.Tool.Call(ctx, args)
+}
+
+type checkedSink struct {
+	sink.Sink
+	p     *Policy
+	agent string
+	name  string
+}
+
+func (s *checkedSink) Write(ctx context.Context, sessionID, content string) error {
+	if err := s.p.check("sink:"+s.name, s.agent, nil); err != nil {
+		return err
+	}
+	return s.Sink.Write(ctx, sessionID, content)
+}
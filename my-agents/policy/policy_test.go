@@ -0,0 +1,159 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/tenant"
+	"my-agents/tools"
+	"my-agents/usage"
+)
+
+type tool struct{ calls int }
+
+func (t *tool) Name() string        { return "send_email" }
+func (t *tool) Description() string { return "" }
+
+func (t *tool) Call(ctx context.Context, args map[string]any) (any, error) {
+	t.calls++
+	return "sent", nil
+}
+
+type output struct{ writes int }
+
+func (s *output) Write(ctx context.Context, sessionID, content string) error {
+	s.writes++
+	return nil
+}
+
+type runner struct {
+	core.Runner
+	callback core.CallbackFunc
+}
+
+func (r *runner) RegisterCallback(hook core.HookPoint, name string, cb core.CallbackFunc) error {
+	r.callback = cb
+	return nil
+}
+
+func TestEvaluate(t *testing.T) {
+	p, err := New(Config{
+		Default: Deny,
+		Rules: []Rule{
+			{Actions: []string{"tool:send_email"}, Tenants: []string{"acme"}, Args: map[string]string{"to": "*@acme.com"}, Effect: Allow},
+			{Actions: []string{"tool:send_email"}, Effect: Deny, Reason: "email goes to the tenant's own domain only"},
+			{Actions: []string{"tool:*", "sink:stdout"}, Workflows: []string{"support"}, Effect: Allow},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		req  Request
+		want Decision
+	}{
+		{Request{Action: "tool:send_email", Tenant: "acme", Args: map[string]any{"to": "ann@acme.com"}}, Decision{Allowed: true, Rule: 1}},
+		{Request{Action: "tool:send_email", Tenant: "acme", Args: map[string]any{"to": "eve@evil.com"}}, Decision{Rule: 2, Reason: "email goes to the tenant's own domain only"}},
+		{Request{Action: "tool:send_email", Tenant: "acme"}, Decision{Rule: 2, Reason: "email goes to the tenant's own domain only"}},
+		{Request{Action: "tool:ocr", Workflow: "support"}, Decision{Allowed: true, Rule: 3}},
+		{Request{Action: "sink:stdout", Workflow: "billing"}, Decision{}},
+	}
+	for _, tt := range tests {
+		if got := p.Evaluate(tt.req); got != tt.want {
+			t.Errorf("Evaluate(%+v) = %+v, want %+v", tt.req, got, tt.want)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, cfg := range []Config{
+		{Default: "maybe"},
+		{Rules: []Rule{{Effect: "permit"}}},
+		{Rules: []Rule{{Actions: []string{"tool:["}, Effect: Allow}}},
+		{Rules: []Rule{{Args: map[string]string{"to": "["}, Effect: Allow}}},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New(%+v) accepted", cfg)
+		}
+	}
+	p, err := New(Config{})
+	if err != nil || !p.Evaluate(Request{Action: "tool:x"}).Allowed {
+		t.Errorf("empty policy = %v, want allow by default", err)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	p, err := New(Config{Rules: []Rule{
+		{Actions: []string{"tool:send_email", "sink:crm"}, Tenants: []string{"acme"}, Effect: Allow},
+		{Actions: []string{"tool:send_email", "sink:crm"}, Effect: Deny, Reason: "acme only"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &runner{}
+	if err := p.Register(r); err != nil {
+		t.Fatal(err)
+	}
+	email, crm := &tool{}, &output{}
+	sendEmail := p.ToolMiddleware()("writer", email)
+	writeCRM := p.SinkMiddleware()("writer", "crm", crm)
+	ctx := context.Background()
+
+	handle := func(metadata map[string]string) {
+		event := core.NewEvent("writer", nil, metadata)
+		if _, err := r.callback(ctx, core.CallbackArgs{Event: event}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	handle(map[string]string{tenant.MetadataKey: "globex"})
+	_, err = sendEmail.Call(ctx, nil)
+	if !errors.Is(err, ErrDenied) || !strings.Contains(err.Error(), "acme only") {
+		t.Errorf("denied call = %v", err)
+	}
+	if err := writeCRM.Write(ctx, "s1", "x"); !errors.Is(err, ErrDenied) {
+		t.Errorf("denied write = %v", err)
+	}
+
+	handle(map[string]string{tenant.MetadataKey: "acme"})
+	if _, err := sendEmail.Call(ctx, nil); err != nil {
+		t.Errorf("allowed call = %v", err)
+	}
+	if err := writeCRM.Write(ctx, "s1", "x"); err != nil {
+		t.Errorf("allowed write = %v", err)
+	}
+	if email.calls != 1 || crm.writes != 1 {
+		t.Errorf("%d calls and %d writes reached the tool and sink, want 1 each", email.calls, crm.writes)
+	}
+}
+
+func TestWorkflowFromRoute(t *testing.T) {
+	p, err := New(Config{Default: Deny, Rules: []Rule{{Workflows: []string{"support"}, Effect: Allow}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &runner{}
+	p.Register(r)
+	sendEmail := p.ToolMiddleware()("writer", &tool{})
+	event := core.NewEvent("writer", nil, map[string]string{core.RouteMetadataKey: "support"})
+	r.callback(context.Background(), core.CallbackArgs{Event: event})
+	if _, err := sendEmail.Call(context.Background(), nil); err != nil {
+		t.Errorf("call in the support workflow = %v", err)
+	}
+	if workflow, _ := event.GetMetadataValue(usage.WorkflowKey); workflow != "support" {
+		t.Errorf("workflow = %q, want the entry route recorded", workflow)
+	}
+}
+
+func TestCheckedToolUnwraps(t *testing.T) {
+	p, _ := New(Config{})
+	email := &tool{}
+	u, ok := p.ToolMiddleware()("writer", email).(interface{ Unwrap() tools.Tool })
+	if !ok || u.Unwrap() != email {
+		t.Error("checked tool does not unwrap to the tool it wraps")
+	}
+}
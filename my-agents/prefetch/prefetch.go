@@ -0,0 +1,171 @@
+// Package prefetch starts knowledge retrieval as soon as an event enters the
+// runner, so retrieval overlaps with routing instead of running sequentially
+// inside each agent. A session's earlier turns come from conversation memory
+// (package conversation).
+package prefetch
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+// KnowledgeSource returns documents relevant to a query.
+type KnowledgeSource interface {
+	Search(ctx context.Context, query string, limit int) ([]core.KnowledgeResult, error)
+}
+
+// Context is the retrieval result handed to agents.
+type Context struct {
+	Knowledge    []core.KnowledgeResult
+	KnowledgeErr error
+	Elapsed      time.Duration
+}
+
+// Prefetcher runs knowledge lookups in the background, keyed by run ID.
+type Prefetcher struct {
+	knowledge      KnowledgeSource
+	knowledgeLimit int
+
+	mu      sync.Mutex
+	pending map[string]*pending
+}
+
+type pending struct {
+	started time.Time
+	done    chan struct{}
+	result  Context
+}
+
+// expireAfter is how long a result is kept for a run that never picks it
+// up, e.g. because no agent reading it was routed to.
+const expireAfter = 10 * time.Minute
+
+// New creates a Prefetcher.
+func New(knowledge KnowledgeSource, knowledgeLimit int) *Prefetcher {
+	if knowledgeLimit <= 0 {
+		knowledgeLimit = 5
+	}
+	return &Prefetcher{
+		knowledge:      knowledge,
+		knowledgeLimit: knowledgeLimit,
+		pending:        make(map[string]*pending),
+	}
+}
+
+// Start kicks off retrieval for a run. A second call for a run that is
+// already pending is a no-op. Results expired unclaimed are dropped.
+func (p *Prefetcher) Start(ctx context.Context, runID, query string) {
+	p.mu.Lock()
+	now := time.Now()
+	for other, pf := range p.pending {
+		if now.Sub(pf.started) > expireAfter {
+			delete(p.pending, other)
+		}
+	}
+	if _, exists := p.pending[runID]; exists {
+		p.mu.Unlock()
+		return
+	}
+	pf := &pending{started: now, done: make(chan struct{})}
+	p.pending[runID] = pf
+	p.mu.Unlock()
+
+	// Detach from the event context so retrieval isn't cancelled when the
+	// runner finishes dispatching the first hop.
+	ctx = context.WithoutCancel(ctx)
+
+	go func() {
+		defer close(pf.done)
+		start := time.Now()
+		if query != "" {
+			pf.result.Knowledge, pf.result.KnowledgeErr = p.knowledge.Search(ctx, query, p.knowledgeLimit)
+		}
+		pf.result.Elapsed = time.Since(start)
+	}()
+}
+
+// Wait blocks until retrieval for the run completes and returns its
+// result, with the search's error if it failed. It returns nil if nothing
+// was started for the run.
+func (p *Prefetcher) Wait(ctx context.Context, runID string) (*Context, error) {
+	p.mu.Lock()
+	pf, exists := p.pending[runID]
+	p.mu.Unlock()
+	if !exists {
+		return nil, nil
+	}
+
+	select {
+	case <-pf.done:
+		result := pf.result
+		if result.KnowledgeErr != nil {
+			return &result, fmt.Errorf("knowledge search failed: %w", result.KnowledgeErr)
+		}
+		return &result, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Release forgets the result for a run.
+func (p *Prefetcher) Release(runID string) {
+	p.mu.Lock()
+	delete(p.pending, runID)
+	p.mu.Unlock()
+}
+
+// Flush forgets every run's result, e.g. after the knowledge base
+// changed. It returns how many were dropped.
+func (p *Prefetcher) Flush() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	n := len(p.pending)
+	p.pending = make(map[string]*pending)
+	return n
+}
+
+// Callback returns a BeforeEventHandling hook that starts retrieval for
+// events carrying a string "input", i.e. the first hop of a request. It
+// must be registered after the history recorder, which sets the run ID.
+func (p *Prefetcher) Callback() core.CallbackFunc {
+	return func(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+		if args.Event == nil {
+			return args.State, nil
+		}
+		input, ok := args.Event.GetData()["input"].(string)
+		if !ok {
+			return args.State, nil
+		}
+		p.Start(ctx, RunID(args.Event), input)
+		return args.State, nil
+	}
+}
+
+// RunID is the key of event's run: its run ID, or for an event no recorder
+// saw, its own ID.
+func RunID(event core.Event) string {
+	if runID, _ := event.GetMetadataValue(history.RunIDKey); runID != "" {
+		return runID
+	}
+	return event.GetID()
+}
+
+// memorySource adapts core.Memory to KnowledgeSource.
+type memorySource struct {
+	memory core.Memory
+}
+
+// FromMemory adapts a core.Memory so it serves as knowledge source.
+func FromMemory(memory core.Memory) KnowledgeSource {
+	return &memorySource{memory: memory}
+}
+
+func (m *memorySource) Search(ctx context.Context, query string, limit int) ([]core.KnowledgeResult, error) {
+	return m.memory.SearchKnowledge(ctx, query, func(c *core.SearchConfig) { c.Limit = limit })
+}
@@ -0,0 +1,82 @@
+package prefetch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+// source answers every search with the query as the one result, or fails
+// with err.
+type source struct {
+	err error
+}
+
+func (s source) Search(ctx context.Context, query string, limit int) ([]core.KnowledgeResult, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return []core.KnowledgeResult{{Content: query, Source: "kb"}}, nil
+}
+
+func TestWaitReturnsTheRunsKnowledge(t *testing.T) {
+	p := New(source{}, 0)
+	ctx := context.Background()
+	p.Start(ctx, "r1", "quantum")
+	p.Start(ctx, "r2", "weather")
+	p.Start(ctx, "r1", "ignored")
+	got, err := p.Wait(ctx, "r1")
+	if err != nil || got == nil || len(got.Knowledge) != 1 || got.Knowledge[0].Content != "quantum" {
+		t.Fatalf("Wait = %+v, %v", got, err)
+	}
+	p.Release("r1")
+	if got, err := p.Wait(ctx, "r1"); got != nil || err != nil {
+		t.Errorf("Wait after Release = %+v, %v; want nothing", got, err)
+	}
+	if got, _ := p.Wait(ctx, "r2"); got == nil || got.Knowledge[0].Content != "weather" {
+		t.Errorf("another run's result = %+v", got)
+	}
+	if n := p.Flush(); n != 1 {
+		t.Errorf("Flush = %d, want the 1 left", n)
+	}
+}
+
+func TestWaitReturnsTheSearchError(t *testing.T) {
+	down := errors.New("index unavailable")
+	p := New(source{err: down}, 0)
+	p.Start(context.Background(), "r1", "quantum")
+	if _, err := p.Wait(context.Background(), "r1"); !errors.Is(err, down) {
+		t.Errorf("Wait = %v, want the search error", err)
+	}
+}
+
+func TestStartDropsExpiredResults(t *testing.T) {
+	p := New(source{}, 0)
+	p.Start(context.Background(), "abandoned", "old")
+	p.pending["abandoned"].started = time.Now().Add(-expireAfter - time.Minute)
+	p.Start(context.Background(), "r2", "new")
+	if got, _ := p.Wait(context.Background(), "abandoned"); got != nil {
+		t.Error("a result no agent picked up outlived its expiry")
+	}
+}
+
+func TestCallbackKeysByRun(t *testing.T) {
+	p := New(source{}, 0)
+	cb := p.Callback()
+	event := core.NewEvent("processor", core.EventData{"input": "quantum"}, map[string]string{history.RunIDKey: "r1", core.SessionIDKey: "s1"})
+	if _, err := cb(context.Background(), core.CallbackArgs{Event: event}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := p.Wait(context.Background(), "r1"); got == nil {
+		t.Error("nothing was prefetched for the event's run")
+	}
+	unrecorded := core.NewEvent("processor", core.EventData{"input": "x"}, nil)
+	if RunID(unrecorded) != unrecorded.GetID() {
+		t.Errorf("RunID of an unrecorded event = %q, want its ID", RunID(unrecorded))
+	}
+}
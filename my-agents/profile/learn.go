@@ -0,0 +1,180 @@
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"my-agents/history"
+)
+
+// Feedback is a user's reaction to a response: a rating, a comment, or
+// both.
+type Feedback struct {
+	RunID   string   `json:"run_id,omitempty"`
+	Rating  *float64 `json:"rating,omitempty"` // 0 (bad) to 1 (good)
+	Comment string   `json:"comment,omitempty"`
+}
+
+// Validate checks the feedback says something.
+func (f Feedback) Validate() error {
+	if f.Rating == nil && strings.TrimSpace(f.Comment) == "" {
+		return fmt.Errorf("feedback needs a rating or a comment")
+	}
+	if f.Rating != nil && (*f.Rating < 0 || *f.Rating > 1) {
+		return fmt.Errorf("rating %g is not between 0 and 1", *f.Rating)
+	}
+	return nil
+}
+
+// commentWeight is the evidence a comment asking for a value adds.
+const commentWeight = 2.0
+
+// cues are what comments ask for, matched case-insensitively.
+var cues = []struct {
+	name, value string
+	phrases     []string
+}{
+	{Verbosity, "brief", []string{"too long", "shorter", "concise", "too wordy", "tl;dr", "get to the point"}},
+	{Verbosity, "detailed", []string{"too short", "too brief", "more detail", "elaborate", "more depth", "expand on"}},
+	{Format, "bullets", []string{"bullet", "as a list"}},
+	{Format, "prose", []string{"paragraphs", "as prose", "fewer lists"}},
+	{Format, "plain", []string{"plain text", "no markdown"}},
+	{Expertise, "beginner", []string{"too technical", "jargon", "simpler", "confusing", "like i'm new"}},
+	{Expertise, "expert", []string{"too basic", "too simple", "more technical", "condescending"}},
+}
+
+// Learn adds the evidence in feedback on response, the final response the
+// user rated ("" when there is none), to their profile. A rating credits
+// or debits the verbosity and format the response had; a comment credits
+// what it asks for.
+func (s *Store) Learn(ctx context.Context, userID string, fb Feedback, response string) (Profile, error) {
+	if userID == "" {
+		return Profile{}, fmt.Errorf("profile needs a user ID")
+	}
+	if err := fb.Validate(); err != nil {
+		return Profile{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	l, _, err := s.learned(ctx, s.db, userID)
+	if err != nil {
+		return Profile{}, err
+	}
+	if l.Scores == nil {
+		l.Scores = make(map[string]map[string]float64)
+	}
+	add := func(name, value string, weight float64) {
+		if l.Scores[name] == nil {
+			l.Scores[name] = make(map[string]float64)
+		}
+		l.Scores[name][value] += weight
+	}
+	if fb.Rating != nil && strings.TrimSpace(response) != "" {
+		weight := 2*(*fb.Rating) - 1 // from -1 for 0 to 1 for 1
+		for name, value := range observe(response) {
+			add(name, value, weight)
+		}
+	}
+	comment := strings.ToLower(fb.Comment)
+	for _, c := range cues {
+		for _, phrase := range c.phrases {
+			if strings.Contains(comment, phrase) {
+				add(c.name, c.value, commentWeight)
+				break
+			}
+		}
+	}
+	l.Feedback++
+
+	data, err := json.Marshal(l)
+	if err != nil {
+		return Profile{}, err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO user_traits (user_id, learned, updated_at) VALUES (?, ?, ?)
+		 ON CONFLICT (user_id) DO UPDATE SET learned = excluded.learned, updated_at = excluded.updated_at`,
+		userID, string(data), time.Now().UnixNano())
+	if err != nil {
+		return Profile{}, fmt.Errorf("failed to save traits of %s: %w", userID, err)
+	}
+	return s.Get(ctx, userID)
+}
+
+// Lengths separating brief, standard and detailed responses, in words.
+const (
+	briefWords    = 120
+	detailedWords = 400
+)
+
+// observe returns the verbosity and format response has.
+func observe(response string) map[string]string {
+	seen := make(map[string]string)
+	switch words := len(strings.Fields(response)); {
+	case words <= briefWords:
+		seen[Verbosity] = "brief"
+	case words > detailedWords:
+		seen[Verbosity] = "detailed"
+	default:
+		seen[Verbosity] = "standard"
+	}
+	var lines, items, headings int
+	for _, line := range strings.Split(response, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		lines++
+		switch {
+		case strings.HasPrefix(line, "#"):
+			headings++
+		case listItem(line):
+			items++
+		}
+	}
+	switch {
+	case headings > 0:
+		seen[Format] = "markdown"
+	case items*2 >= lines:
+		seen[Format] = "bullets"
+	default:
+		seen[Format] = "prose"
+	}
+	return seen
+}
+
+// listItem reports whether line starts a Markdown list item.
+func listItem(line string) bool {
+	for _, marker := range []string{"- ", "* ", "+ "} {
+		if strings.HasPrefix(line, marker) {
+			return true
+		}
+	}
+	digits := strings.TrimLeftFunc(line, unicode.IsDigit)
+	return len(digits) < len(line) && (strings.HasPrefix(digits, ". ") || strings.HasPrefix(digits, ") "))
+}
+
+// ErrOtherUser is returned for feedback on a run made for another user.
+var ErrOtherUser = errors.New("run was made for another user")
+
+// LearnFromRun learns from feedback on a run in runs, which must have been
+// made for userID; the response rated is the run's final one. Feedback
+// naming no run only has its comment to learn from.
+func (s *Store) LearnFromRun(ctx context.Context, runs history.Store, userID string, fb Feedback) (Profile, error) {
+	var response string
+	if fb.RunID != "" {
+		run, err := runs.Get(ctx, fb.RunID)
+		if err != nil {
+			return Profile{}, err
+		}
+		if run.Metadata[UserKey] != userID {
+			return Profile{}, fmt.Errorf("run %s: %w", fb.RunID, ErrOtherUser)
+		}
+		response = run.FinalResponse
+	}
+	return s.Learn(ctx, userID, fb, response)
+}
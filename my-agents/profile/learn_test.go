@@ -0,0 +1,113 @@
+package profile
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"strings"
+	"testing"
+
+	"my-agents/history"
+)
+
+func rating(v float64) *float64 { return &v }
+
+func TestFeedbackValidate(t *testing.T) {
+	for _, fb := range []Feedback{{Rating: rating(0)}, {Comment: "too long"}} {
+		if err := fb.Validate(); err != nil {
+			t.Errorf("%+v: %v", fb, err)
+		}
+	}
+	for _, fb := range []Feedback{{}, {Comment: "  "}, {Rating: rating(1.5)}, {Rating: rating(-1), Comment: "bad"}} {
+		if err := fb.Validate(); err == nil {
+			t.Errorf("%+v accepted", fb)
+		}
+	}
+}
+
+func TestLearn(t *testing.T) {
+	s := open(t, Config{})
+	ctx := context.Background()
+	p, err := s.Learn(ctx, "ann", Feedback{Comment: "Too long! Use BULLET points."}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Trait{Verbosity: {"brief", 2, 1}, Format: {"bullets", 2, 1}}
+	if !maps.Equal(p.Traits, want) || p.Feedback != 1 {
+		t.Errorf("after a comment: %+v", p)
+	}
+
+	// A bad rating of a brief prose response counts against both
+	p, _ = s.Learn(ctx, "ann", Feedback{Rating: rating(0)}, "Short prose answer.")
+	if _, ok := p.Traits[Verbosity]; ok || p.Traits[Format] != (Trait{"bullets", 2, 1}) {
+		t.Errorf("after a bad rating: %+v", p.Traits)
+	}
+	p, _ = s.Learn(ctx, "ann", Feedback{Rating: rating(1)}, "Short:\n- one\n- two")
+	want = map[string]Trait{Verbosity: {"brief", 2, 1}, Format: {"bullets", 3, 1}}
+	if !maps.Equal(p.Traits, want) {
+		t.Errorf("after a good rating: %+v", p.Traits)
+	}
+	// Evidence for another value dilutes the confidence; ties keep the first
+	p, _ = s.Learn(ctx, "ann", Feedback{Comment: "I'd like more detail"}, "")
+	if got := p.Traits[Verbosity]; got != (Trait{"brief", 2, 0.5}) || p.Feedback != 4 {
+		t.Errorf("after asking for both: %+v", p)
+	}
+
+	if _, err := s.Learn(ctx, "", Feedback{Comment: "shorter"}, ""); err == nil {
+		t.Error("learned without a user")
+	}
+	if _, err := s.Learn(ctx, "ann", Feedback{}, ""); err == nil {
+		t.Error("learned from empty feedback")
+	}
+}
+
+func TestObserve(t *testing.T) {
+	words := func(n int) string { return strings.Repeat("word ", n) }
+	tests := []struct {
+		response, verbosity, format string
+	}{
+		{words(120), "brief", "prose"},
+		{words(121), "standard", "prose"},
+		{words(401), "detailed", "prose"},
+		{"# Title\n- a\n- b", "brief", "markdown"},
+		{"Steps:\n1. a\n2) b\n\n", "brief", "bullets"},
+		{"Intro\n* a\nmore\nthe end", "brief", "prose"},
+	}
+	for _, tt := range tests {
+		got := observe(tt.response)
+		if got[Verbosity] != tt.verbosity || got[Format] != tt.format {
+			t.Errorf("observe(%.20q) = %v, want %s %s", tt.response, got, tt.verbosity, tt.format)
+		}
+	}
+	for line, want := range map[string]bool{"- a": true, "+ a": true, "12. a": true, "3) a": true, "-a": false, "1.5 a": false, "2026": false} {
+		if got := listItem(line); got != want {
+			t.Errorf("listItem(%q) = %v", line, got)
+		}
+	}
+}
+
+func TestLearnFromRun(t *testing.T) {
+	s := open(t, Config{MinScore: 1})
+	ctx := context.Background()
+	runs := history.NewMemoryStore()
+	runs.Save(ctx, &history.Run{ID: "run-1", Metadata: map[string]string{UserKey: "ann"}, FinalResponse: "- a\n- b"})
+	runs.Save(ctx, &history.Run{ID: "run-2", Metadata: map[string]string{UserKey: "bob"}})
+
+	p, err := s.LearnFromRun(ctx, runs, "ann", Feedback{RunID: "run-1", Rating: rating(1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Traits[Format].Value != "bullets" {
+		t.Errorf("traits = %+v", p.Traits)
+	}
+	if _, err := s.LearnFromRun(ctx, runs, "ann", Feedback{RunID: "run-2", Rating: rating(1)}); !errors.Is(err, ErrOtherUser) {
+		t.Errorf("another user's run: %v", err)
+	}
+	if _, err := s.LearnFromRun(ctx, runs, "ann", Feedback{RunID: "run-9", Rating: rating(1)}); !errors.Is(err, history.ErrNotFound) {
+		t.Errorf("unknown run: %v", err)
+	}
+	// Without a run only the comment counts
+	if p, _ := s.LearnFromRun(ctx, runs, "bob", Feedback{Rating: rating(1), Comment: "too technical"}); p.Traits[Expertise].Value != "beginner" || len(p.Traits) != 1 {
+		t.Errorf("comment without a run: %+v", p.Traits)
+	}
+}
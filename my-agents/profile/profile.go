@@ -0,0 +1,345 @@
+// Package profile keeps what the pipeline knows about each recipient of its
+// responses, by the user ID in event metadata, across their sessions: the
+// preferences they set for how responses are written to them, and the
+// traits inferred from their feedback. Explicit preferences win over
+// inferred traits.
+package profile
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/flags"
+	"my-agents/storage"
+)
+
+// UserKey is the event metadata key naming the recipient.
+const UserKey = flags.UserKey
+
+// DefaultMinScore is the evidence a trait needs when Config.MinScore is
+// unset.
+const DefaultMinScore = 2.0
+
+// Config is the [profiles] section of agentflow.toml:
+//
+//	[profiles]
+//	enabled = true
+//	min_score = 2
+type Config struct {
+	// Enabled shows the processor and enhancer the recipient's profile.
+	Enabled bool `toml:"enabled"`
+	// MinScore is the net evidence a value needs to become a trait: a
+	// rating counts up to 1 for or against what the rated response did, a
+	// comment asking for it 2 (default 2).
+	MinScore float64 `toml:"min_score"`
+}
+
+// Preference names, as used for traits.
+const (
+	Verbosity = "verbosity"
+	Format    = "format"
+	Expertise = "expertise"
+)
+
+// The values each preference takes. An empty preference leaves the response
+// as the formatter wrote it.
+var (
+	Verbosities = []string{"brief", "standard", "detailed"}
+	Formats     = []string{"prose", "bullets", "markdown", "plain"}
+	Expertises  = []string{"beginner", "intermediate", "expert"}
+)
+
+// Preferences is how a recipient wants responses written.
+type Preferences struct {
+	Verbosity string `json:"verbosity,omitempty"`
+	Format    string `json:"format,omitempty"`
+	Expertise string `json:"expertise,omitempty"`
+}
+
+// Empty reports whether no preference is set.
+func (p Preferences) Empty() bool {
+	return p == Preferences{}
+}
+
+// Validate checks every set preference is one of its values.
+func (p Preferences) Validate() error {
+	for _, f := range []struct {
+		name, value string
+		values      []string
+	}{
+		{Verbosity, p.Verbosity, Verbosities},
+		{Format, p.Format, Formats},
+		{Expertise, p.Expertise, Expertises},
+	} {
+		if f.value != "" && !slices.Contains(f.values, f.value) {
+			return fmt.Errorf("%s %q is not one of %q", f.name, f.value, f.values)
+		}
+	}
+	return nil
+}
+
+// Merge returns p with the preferences set in update replacing its own.
+func (p Preferences) Merge(update Preferences) Preferences {
+	if update.Verbosity != "" {
+		p.Verbosity = update.Verbosity
+	}
+	if update.Format != "" {
+		p.Format = update.Format
+	}
+	if update.Expertise != "" {
+		p.Expertise = update.Expertise
+	}
+	return p
+}
+
+// field returns the named preference, or nil.
+func (p *Preferences) field(name string) *string {
+	switch name {
+	case Verbosity:
+		return &p.Verbosity
+	case Format:
+		return &p.Format
+	case Expertise:
+		return &p.Expertise
+	}
+	return nil
+}
+
+// Trait is a preference inferred from feedback.
+type Trait struct {
+	Value string  `json:"value"`
+	Score float64 `json:"score"` // net evidence for Value
+	// Confidence is Value's share of the evidence for any value of the
+	// preference, 0-1.
+	Confidence float64 `json:"confidence"`
+}
+
+// Profile is what is stored for one user.
+type Profile struct {
+	UserID      string      `json:"user_id"`
+	Preferences Preferences `json:"preferences"` // set by the user or an operator
+	// Traits are inferred from feedback, by preference name.
+	Traits    map[string]Trait `json:"traits,omitempty"`
+	Feedback  int              `json:"feedback"`            // ratings and comments learned from
+	UpdatedAt time.Time        `json:"updated_at,omitzero"` // zero until something is stored
+}
+
+// Effective returns the preferences responses follow: the inferred traits,
+// overridden by the explicit preferences.
+func (p Profile) Effective() Preferences {
+	var inferred Preferences
+	for name, t := range p.Traits {
+		if f := inferred.field(name); f != nil {
+			*f = t.Value
+		}
+	}
+	return inferred.Merge(p.Preferences)
+}
+
+// Empty reports whether nothing is known about the user.
+func (p Profile) Empty() bool {
+	return p.Preferences.Empty() && len(p.Traits) == 0
+}
+
+// Prompt renders the profile as structured context for an agent's prompt;
+// it is empty when nothing is known.
+func (p Profile) Prompt() string {
+	if p.Empty() {
+		return ""
+	}
+	data, err := json.Marshal(struct {
+		Preferences Preferences      `json:"preferences,omitzero"`
+		Traits      map[string]Trait `json:"inferred_traits,omitempty"`
+	}{p.Preferences, p.Traits})
+	if err != nil {
+		return ""
+	}
+	return "\n\nAbout the user, from their profile (explicit preferences win over inferred traits; pitch the content to them): " + string(data)
+}
+
+// learned is the evidence collected from a user's feedback.
+type learned struct {
+	// Scores is the net evidence for each value, by preference name.
+	Scores   map[string]map[string]float64 `json:"scores"`
+	Feedback int                           `json:"feedback"`
+}
+
+// traits returns the values with at least minScore evidence.
+func (l learned) traits(minScore float64) map[string]Trait {
+	traits := make(map[string]Trait)
+	for name, scores := range l.Scores {
+		values := make([]string, 0, len(scores))
+		for v := range scores {
+			values = append(values, v)
+		}
+		sort.Strings(values)
+		var best string
+		var total float64
+		for _, v := range values {
+			if scores[v] > 0 {
+				total += scores[v]
+			}
+			if best == "" || scores[v] > scores[best] {
+				best = v
+			}
+		}
+		if best != "" && scores[best] >= minScore {
+			traits[name] = Trait{Value: best, Score: scores[best], Confidence: scores[best] / total}
+		}
+	}
+	if len(traits) == 0 {
+		return nil
+	}
+	return traits
+}
+
+// Store keeps profiles in tables of the embedded database.
+type Store struct {
+	cfg Config
+	db  *sql.DB
+	mu  sync.Mutex // serializes Learn's read-modify-write
+}
+
+// Open opens the store in the database at path (default
+// .agentflow/agentflow.db).
+func Open(cfg Config, path string) (*Store, error) {
+	db, err := storage.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(cfg, db)
+}
+
+// New creates a store in db, creating its tables if needed.
+func New(cfg Config, db *sql.DB) (*Store, error) {
+	err := storage.Migrate(db,
+		`CREATE TABLE IF NOT EXISTS user_profiles (
+			user_id     TEXT PRIMARY KEY,
+			preferences TEXT NOT NULL,
+			updated_at  INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS user_traits (
+			user_id    TEXT PRIMARY KEY,
+			learned    TEXT NOT NULL,
+			updated_at INTEGER NOT NULL
+		)`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.MinScore <= 0 {
+		cfg.MinScore = DefaultMinScore
+	}
+	return &Store{cfg: cfg, db: db}, nil
+}
+
+// Enabled reports whether agents are shown profiles.
+func (s *Store) Enabled() bool {
+	return s.cfg.Enabled
+}
+
+// Get returns the user's profile, empty if nothing is stored for them.
+func (s *Store) Get(ctx context.Context, userID string) (Profile, error) {
+	p := Profile{UserID: userID}
+	var prefs string
+	var updated int64
+	err := s.db.QueryRowContext(ctx, `SELECT preferences, updated_at FROM user_profiles WHERE user_id = ?`, userID).Scan(&prefs, &updated)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+	case err != nil:
+		return Profile{}, fmt.Errorf("failed to load profile of %s: %w", userID, err)
+	default:
+		if err := json.Unmarshal([]byte(prefs), &p.Preferences); err != nil {
+			return Profile{}, fmt.Errorf("profile of %s: %w", userID, err)
+		}
+		p.UpdatedAt = time.Unix(0, updated)
+	}
+	l, at, err := s.learned(ctx, s.db, userID)
+	if err != nil {
+		return Profile{}, err
+	}
+	p.Traits, p.Feedback = l.traits(s.cfg.MinScore), l.Feedback
+	if at.After(p.UpdatedAt) {
+		p.UpdatedAt = at
+	}
+	return p, nil
+}
+
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func (s *Store) learned(ctx context.Context, q querier, userID string) (learned, time.Time, error) {
+	var l learned
+	var data string
+	var updated int64
+	err := q.QueryRowContext(ctx, `SELECT learned, updated_at FROM user_traits WHERE user_id = ?`, userID).Scan(&data, &updated)
+	if errors.Is(err, sql.ErrNoRows) {
+		return l, time.Time{}, nil
+	}
+	if err != nil {
+		return l, time.Time{}, fmt.Errorf("failed to load traits of %s: %w", userID, err)
+	}
+	if err := json.Unmarshal([]byte(data), &l); err != nil {
+		return l, time.Time{}, fmt.Errorf("traits of %s: %w", userID, err)
+	}
+	return l, time.Unix(0, updated), nil
+}
+
+// SetPreferences replaces the user's preferences.
+func (s *Store) SetPreferences(ctx context.Context, userID string, prefs Preferences) (Profile, error) {
+	if userID == "" {
+		return Profile{}, fmt.Errorf("profile needs a user ID")
+	}
+	if err := prefs.Validate(); err != nil {
+		return Profile{}, err
+	}
+	data, err := json.Marshal(prefs)
+	if err != nil {
+		return Profile{}, err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO user_profiles (user_id, preferences, updated_at) VALUES (?, ?, ?)
+		 ON CONFLICT (user_id) DO UPDATE SET preferences = excluded.preferences, updated_at = excluded.updated_at`,
+		userID, string(data), time.Now().UnixNano())
+	if err != nil {
+		return Profile{}, fmt.Errorf("failed to save profile of %s: %w", userID, err)
+	}
+	return s.Get(ctx, userID)
+}
+
+// ForgetTraits drops the evidence collected from the user's feedback.
+func (s *Store) ForgetTraits(ctx context.Context, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM user_traits WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to forget traits of %s: %w", userID, err)
+	}
+	return nil
+}
+
+// Delete removes everything stored for the user.
+func (s *Store) Delete(ctx context.Context, userID string) error {
+	if err := s.ForgetTraits(ctx, userID); err != nil {
+		return err
+	}
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM user_profiles WHERE user_id = ?`, userID); err != nil {
+		return fmt.Errorf("failed to delete profile of %s: %w", userID, err)
+	}
+	return nil
+}
+
+// FromEvent returns the user the event is for, or "" when it names none.
+func FromEvent(event core.Event) string {
+	user, _ := event.GetMetadataValue(UserKey)
+	return user
+}
@@ -0,0 +1,125 @@
+package profile
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+func open(t *testing.T, cfg Config) *Store {
+	t.Helper()
+	s, err := Open(cfg, filepath.Join(t.TempDir(), "agentflow.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestPreferences(t *testing.T) {
+	if !(Preferences{}).Empty() || (Preferences{Format: "plain"}).Empty() {
+		t.Error("Empty is wrong")
+	}
+	if err := (Preferences{Verbosity: "brief", Format: "bullets", Expertise: "expert"}).Validate(); err != nil {
+		t.Error(err)
+	}
+	if err := (Preferences{Format: "haiku"}).Validate(); err == nil || err.Error() != `format "haiku" is not one of ["prose" "bullets" "markdown" "plain"]` {
+		t.Errorf("unknown format: %v", err)
+	}
+	got := Preferences{Verbosity: "brief", Format: "prose"}.Merge(Preferences{Format: "plain", Expertise: "beginner"})
+	if got != (Preferences{Verbosity: "brief", Format: "plain", Expertise: "beginner"}) {
+		t.Errorf("Merge = %+v", got)
+	}
+}
+
+func TestStore(t *testing.T) {
+	s := open(t, Config{Enabled: true})
+	ctx := context.Background()
+	if !s.Enabled() {
+		t.Error("store not enabled")
+	}
+	p, err := s.Get(ctx, "ann")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.UserID != "ann" || !p.Preferences.Empty() || !p.UpdatedAt.IsZero() {
+		t.Errorf("unknown user = %+v", p)
+	}
+
+	p, err = s.SetPreferences(ctx, "ann", Preferences{Verbosity: "brief"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Preferences.Verbosity != "brief" || p.UpdatedAt.IsZero() {
+		t.Errorf("profile = %+v", p)
+	}
+	if _, err := s.SetPreferences(ctx, "ann", Preferences{Verbosity: "epic"}); err == nil {
+		t.Error("invalid preferences saved")
+	}
+	if _, err := s.SetPreferences(ctx, "", Preferences{}); err == nil {
+		t.Error("profile saved without a user")
+	}
+	p, _ = s.SetPreferences(ctx, "ann", Preferences{Format: "bullets"})
+	if p.Preferences != (Preferences{Format: "bullets"}) {
+		t.Errorf("replaced preferences = %+v", p.Preferences)
+	}
+
+	if err := s.Delete(ctx, "ann"); err != nil {
+		t.Fatal(err)
+	}
+	if p, _ := s.Get(ctx, "ann"); !p.Preferences.Empty() {
+		t.Errorf("deleted profile = %+v", p)
+	}
+}
+
+func TestFromEvent(t *testing.T) {
+	if got := FromEvent(core.NewEvent("writer", nil, map[string]string{UserKey: "ann"})); got != "ann" {
+		t.Errorf("FromEvent = %q", got)
+	}
+	if got := FromEvent(core.NewEvent("writer", nil, nil)); got != "" {
+		t.Errorf("FromEvent of no user = %q", got)
+	}
+}
+
+func TestEffective(t *testing.T) {
+	p := Profile{
+		Preferences: Preferences{Format: "plain"},
+		Traits: map[string]Trait{
+			Verbosity: {Value: "brief", Score: 3, Confidence: 1},
+			Format:    {Value: "bullets", Score: 2, Confidence: 1},
+		},
+	}
+	if got := p.Effective(); got != (Preferences{Verbosity: "brief", Format: "plain"}) {
+		t.Errorf("Effective = %+v", got)
+	}
+	want := `{"preferences":{"format":"plain"},"inferred_traits":{"format":{"value":"bullets","score":2,"confidence":1},"verbosity":{"value":"brief","score":3,"confidence":1}}}`
+	if got := p.Prompt(); !strings.HasSuffix(got, want) {
+		t.Errorf("Prompt = %s", got)
+	}
+	if got := (Profile{UserID: "ann"}).Prompt(); got != "" {
+		t.Errorf("Prompt of an empty profile = %q", got)
+	}
+}
+
+func TestForgetTraits(t *testing.T) {
+	s := open(t, Config{})
+	ctx := context.Background()
+	s.SetPreferences(ctx, "ann", Preferences{Format: "plain"})
+	s.Learn(ctx, "ann", Feedback{Comment: "too long"}, "")
+	if err := s.ForgetTraits(ctx, "ann"); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := s.Get(ctx, "ann")
+	if len(p.Traits) != 0 || p.Feedback != 0 || p.Preferences.Format != "plain" {
+		t.Errorf("profile = %+v", p)
+	}
+
+	// Delete forgets traits too
+	s.Learn(ctx, "ann", Feedback{Comment: "too long"}, "")
+	s.Delete(ctx, "ann")
+	if p, _ := s.Get(ctx, "ann"); !p.Empty() {
+		t.Errorf("deleted profile = %+v", p)
+	}
+}
@@ -0,0 +1,153 @@
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"my-agents/auth"
+	"my-agents/history"
+)
+
+// Handler serves profiles, for mounting on the admin API under
+// "/admin/profiles/":
+//
+//	GET    /admin/profiles/{user}            the profile, with its inferred traits
+//	PATCH  /admin/profiles/{user}            {"verbosity": "brief"} sets preferences; "" clears one
+//	DELETE /admin/profiles/{user}            forget the user
+//	DELETE /admin/profiles/{user}/traits     forget what feedback taught
+//	POST   /admin/profiles/{user}/feedback   {"run_id": "...", "rating": 0.8, "comment": "..."}
+func (s *Store) Handler(runs history.Store) http.Handler {
+	mux := http.NewServeMux()
+	user := func(r *http.Request) string { return r.PathValue("user") }
+	mux.Handle("GET /admin/profiles/{user}", s.get(user))
+	mux.Handle("PATCH /admin/profiles/{user}", s.patch(user))
+	mux.Handle("POST /admin/profiles/{user}/feedback", s.feedback(user, runs))
+	mux.HandleFunc("DELETE /admin/profiles/{user}", func(w http.ResponseWriter, r *http.Request) {
+		if err := s.Delete(r.Context(), user(r)); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("DELETE /admin/profiles/{user}/traits", func(w http.ResponseWriter, r *http.Request) {
+		s.respond(w, r.Context(), user(r), s.ForgetTraits(r.Context(), user(r)))
+	})
+	return mux
+}
+
+// SelfHandler serves callers their own profile on the HTTP API: the
+// profile of the user the API authenticated them as.
+//
+//	GET   /profile            the caller's profile
+//	PATCH /profile            set their preferences, as on the admin API
+//	POST  /profile/feedback   rate one of their runs, or comment
+func (s *Store) SelfHandler(runs history.Store) http.Handler {
+	mux := http.NewServeMux()
+	user := func(r *http.Request) string {
+		p, _ := auth.FromContext(r.Context())
+		return p.User
+	}
+	identified := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if user(r) == "" {
+				writeError(w, http.StatusUnauthorized, errors.New("profiles need a caller authenticated as a user"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	mux.Handle("GET /profile", identified(s.get(user)))
+	mux.Handle("PATCH /profile", identified(s.patch(user)))
+	mux.Handle("POST /profile/feedback", identified(s.feedback(user, runs)))
+	return mux
+}
+
+func (s *Store) get(user func(*http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.respond(w, r.Context(), user(r), nil)
+	})
+}
+
+func (s *Store) patch(user func(*http.Request) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Omitted preferences stay as they are
+		var body struct {
+			Verbosity, Format, Expertise *string
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		p, err := s.Get(r.Context(), user(r))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		prefs := p.Preferences
+		for name, v := range map[string]*string{Verbosity: body.Verbosity, Format: body.Format, Expertise: body.Expertise} {
+			if v != nil {
+				*prefs.field(name) = *v
+			}
+		}
+		if err := prefs.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if p, err = s.SetPreferences(r.Context(), user(r), prefs); err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, p)
+	})
+}
+
+func (s *Store) feedback(user func(*http.Request) string, runs history.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var fb Feedback
+		if err := json.NewDecoder(r.Body).Decode(&fb); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := fb.Validate(); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		p, err := s.LearnFromRun(r.Context(), runs, user(r), fb)
+		switch {
+		case errors.Is(err, history.ErrNotFound):
+			writeError(w, http.StatusNotFound, err)
+		case errors.Is(err, ErrOtherUser):
+			writeError(w, http.StatusForbidden, err)
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err)
+		default:
+			writeJSON(w, http.StatusOK, p)
+		}
+	})
+}
+
+// respond writes the user's profile, or err.
+func (s *Store) respond(w http.ResponseWriter, ctx context.Context, userID string, err error) {
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	p, err := s.Get(ctx, userID)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,117 @@
+package profile
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"my-agents/auth"
+	"my-agents/history"
+)
+
+func TestHandler(t *testing.T) {
+	s := open(t, Config{MinScore: 1})
+	runs := history.NewMemoryStore()
+	runs.Save(context.Background(), &history.Run{ID: "run-1", Metadata: map[string]string{UserKey: "bob"}})
+	h := s.Handler(runs)
+	do := func(method, path, body string) (int, map[string]any) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, path, strings.NewReader(body)))
+		var resp map[string]any
+		json.NewDecoder(w.Body).Decode(&resp)
+		return w.Code, resp
+	}
+	prefs := func(resp map[string]any) map[string]any {
+		p, _ := resp["preferences"].(map[string]any)
+		return p
+	}
+
+	if code, resp := do("GET", "/admin/profiles/ann", ""); code != http.StatusOK || resp["user_id"] != "ann" {
+		t.Errorf("get: %d %v", code, resp)
+	}
+	do("PATCH", "/admin/profiles/ann", `{"verbosity": "brief"}`)
+	// Omitted preferences are kept, and "" clears one
+	code, resp := do("PATCH", "/admin/profiles/ann", `{"format": "bullets"}`)
+	if code != http.StatusOK || prefs(resp)["verbosity"] != "brief" || prefs(resp)["format"] != "bullets" {
+		t.Errorf("patch: %d %v", code, resp)
+	}
+	if _, resp := do("PATCH", "/admin/profiles/ann", `{"verbosity": ""}`); prefs(resp)["verbosity"] != nil {
+		t.Errorf("cleared verbosity: %v", resp)
+	}
+	for _, body := range []string{`{"format": "haiku"}`, `{`} {
+		if code, _ := do("PATCH", "/admin/profiles/ann", body); code != http.StatusBadRequest {
+			t.Errorf("patch %s: status %d", body, code)
+		}
+	}
+
+	tests := []struct {
+		body string
+		code int
+	}{
+		{`{"comment": "too long"}`, http.StatusOK},
+		{`{}`, http.StatusBadRequest},
+		{`{"run_id": "run-9", "rating": 1}`, http.StatusNotFound},
+		{`{"run_id": "run-1", "rating": 1}`, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if code, resp := do("POST", "/admin/profiles/ann/feedback", tt.body); code != tt.code {
+			t.Errorf("feedback %s: %d %v", tt.body, code, resp)
+		}
+	}
+	if _, resp := do("GET", "/admin/profiles/ann", ""); resp["traits"] == nil || resp["feedback"] != 1.0 {
+		t.Errorf("profile after feedback: %v", resp)
+	}
+	if _, resp := do("DELETE", "/admin/profiles/ann/traits", ""); resp["traits"] != nil || prefs(resp)["format"] != "bullets" {
+		t.Errorf("forgot traits: %v", resp)
+	}
+	if code, _ := do("DELETE", "/admin/profiles/ann", ""); code != http.StatusNoContent {
+		t.Errorf("delete: status %d", code)
+	}
+	if _, resp := do("GET", "/admin/profiles/ann", ""); prefs(resp) != nil && len(prefs(resp)) != 0 {
+		t.Errorf("deleted profile: %v", resp)
+	}
+}
+
+func TestSelfHandler(t *testing.T) {
+	s := open(t, Config{})
+	runs := history.NewMemoryStore()
+	runs.Save(context.Background(), &history.Run{ID: "run-1", Metadata: map[string]string{UserKey: "bob"}})
+	s.SetPreferences(context.Background(), "bob", Preferences{Format: "plain"})
+	h := s.SelfHandler(runs)
+	do := func(user, method, path, body string) (int, map[string]any) {
+		t.Helper()
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		if user != "" {
+			r = r.WithContext(auth.NewContext(r.Context(), auth.Principal{Client: "app", User: user}))
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		var resp map[string]any
+		json.NewDecoder(w.Body).Decode(&resp)
+		return w.Code, resp
+	}
+
+	if code, _ := do("", "GET", "/profile", ""); code != http.StatusUnauthorized {
+		t.Errorf("anonymous caller: status %d", code)
+	}
+	if code, resp := do("ann", "GET", "/profile", ""); code != http.StatusOK || resp["user_id"] != "ann" {
+		t.Errorf("get: %d %v", code, resp)
+	}
+	if code, _ := do("ann", "PATCH", "/profile", `{"verbosity": "brief"}`); code != http.StatusOK {
+		t.Errorf("patch: status %d", code)
+	}
+	if code, _ := do("ann", "POST", "/profile/feedback", `{"run_id": "run-1", "rating": 0}`); code != http.StatusForbidden {
+		t.Errorf("feedback on another user's run: status %d", code)
+	}
+	if code, _ := do("bob", "POST", "/profile/feedback", `{"run_id": "run-1", "rating": 0}`); code != http.StatusOK {
+		t.Errorf("feedback on own run: status %d", code)
+	}
+	// Callers only ever see their own profile
+	if _, resp := do("bob", "GET", "/profile", ""); resp["user_id"] != "bob" || resp["preferences"].(map[string]any)["format"] != "plain" {
+		t.Errorf("bob's profile: %v", resp)
+	}
+}
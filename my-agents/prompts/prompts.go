@@ -0,0 +1,158 @@
+// Package prompts renders agents' system and user prompts from Go
+// text/template templates, so prompts can be edited without recompiling.
+// Each agent has built-in templates; a directory of template files or the
+// agent's own table in agentflow.toml overrides them. Templates see the
+// run's state, the event's data and its metadata.
+package prompts
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Config is the [prompts] section of agentflow.toml:
+//
+//	[prompts]
+//	dir = "prompts"
+type Config struct {
+	// Dir holds template files named <agent>.system.tmpl and
+	// <agent>.user.tmpl; either may be left out.
+	Dir string `toml:"dir"`
+}
+
+// Source is an agent's templates: its [agents.<name>.prompt] table, or the
+// templates built into it.
+//
+//	[agents.processor.prompt]
+//	system = "You extract facts for {{.meta.tenant}}'s support team."
+//	user_file = "prompts/processor-user.tmpl"
+type Source struct {
+	System     string `toml:"system"`
+	User       string `toml:"user"`
+	SystemFile string `toml:"system_file"`
+	UserFile   string `toml:"user_file"`
+}
+
+// Template renders one agent's prompts.
+type Template struct {
+	system, user *template.Template
+	// configured is whether the system template is the operator's rather
+	// than built in.
+	configured bool
+}
+
+// Load builds agent's templates. Each of system and user comes from the
+// agent's table in agentflow.toml, else from cfg.Dir, else from builtin.
+func Load(cfg Config, agent string, override, builtin Source) (*Template, error) {
+	system, configured, err := pick(cfg, agent, "system", override.System, override.SystemFile, builtin.System)
+	if err != nil {
+		return nil, err
+	}
+	user, _, err := pick(cfg, agent, "user", override.User, override.UserFile, builtin.User)
+	if err != nil {
+		return nil, err
+	}
+	t := &Template{configured: configured}
+	if t.system, err = parse(agent+".system", system); err != nil {
+		return nil, err
+	}
+	if t.user, err = parse(agent+".user", user); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// pick returns the template text of one part of agent's prompt, and
+// whether it is configured rather than builtin.
+func pick(cfg Config, agent, part, inline, file, builtin string) (string, bool, error) {
+	if inline != "" && file != "" {
+		return "", false, fmt.Errorf("prompt %s: set %s or %s_file, not both", agent, part, part)
+	}
+	if inline != "" {
+		return inline, true, nil
+	}
+	if file != "" {
+		text, err := os.ReadFile(file)
+		if err != nil {
+			return "", false, fmt.Errorf("prompt %s: %w", agent, err)
+		}
+		return string(text), true, nil
+	}
+	if cfg.Dir != "" {
+		text, err := os.ReadFile(filepath.Join(cfg.Dir, agent+"."+part+".tmpl"))
+		if err == nil {
+			return string(text), true, nil
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			return "", false, fmt.Errorf("prompt %s: %w", agent, err)
+		}
+	}
+	return builtin, false, nil
+}
+
+var funcs = template.FuncMap{
+	// default returns def when v is empty or missing: {{default "none" .topic}}
+	"default": func(def, v any) any {
+		if v == nil || fmt.Sprint(v) == "" {
+			return def
+		}
+		return v
+	},
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"trim": func(v any) string {
+		return strings.TrimSpace(fmt.Sprint(v))
+	},
+}
+
+func parse(name, text string) (*template.Template, error) {
+	t, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("prompt template %w", err)
+	}
+	return t, nil
+}
+
+// Configured reports whether the system prompt is the operator's template
+// rather than the built-in one, which a locale's own prompt for the agent
+// mustn't replace.
+func (t *Template) Configured() bool { return t.configured }
+
+// Render renders the prompts with vars.
+func (t *Template) Render(vars map[string]any) (core.Prompt, error) {
+	var system, user strings.Builder
+	if err := t.system.Execute(&system, vars); err != nil {
+		return core.Prompt{}, fmt.Errorf("prompt template %w", err)
+	}
+	if err := t.user.Execute(&user, vars); err != nil {
+		return core.Prompt{}, fmt.Errorf("prompt template %w", err)
+	}
+	return core.Prompt{System: system.String(), User: user.String()}, nil
+}
+
+// Vars are the variables templates see for an event: the event's data and
+// the run's state by key, state winning, and the event's metadata as .meta.
+// Agents add the values they resolve themselves, like .input. A missing
+// key renders as "<no value>"; guard optional ones with {{with}} or default.
+func Vars(event core.Event, state core.State) map[string]any {
+	vars := make(map[string]any)
+	for k, v := range event.GetData() {
+		vars[k] = v
+	}
+	if state != nil {
+		for _, k := range state.Keys() {
+			vars[k], _ = state.Get(k)
+		}
+	}
+	vars["meta"] = event.GetMetadata()
+	return vars
+}
@@ -0,0 +1,107 @@
+package prompts
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+var builtin = Source{System: "You write about {{.topic}}.", User: "{{.input}}"}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "writer.user.tmpl"), []byte("From dir: {{.input}}"), 0o644)
+	file := filepath.Join(dir, "custom.tmpl")
+	os.WriteFile(file, []byte("From file: {{.input}}"), 0o644)
+	vars := map[string]any{"topic": "Go", "input": "hi"}
+
+	tests := []struct {
+		name       string
+		cfg        Config
+		override   Source
+		system     string
+		user       string
+		configured bool
+	}{
+		{"builtin", Config{}, Source{}, "You write about Go.", "hi", false},
+		{"dir", Config{Dir: dir}, Source{}, "You write about Go.", "From dir: hi", false},
+		{"inline", Config{Dir: dir}, Source{System: "Be terse."}, "Be terse.", "From dir: hi", true},
+		{"file", Config{Dir: dir}, Source{UserFile: file}, "You write about Go.", "From file: hi", false},
+	}
+	for _, tt := range tests {
+		tmpl, err := Load(tt.cfg, "writer", tt.override, builtin)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		prompt, err := tmpl.Render(vars)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if prompt.System != tt.system || prompt.User != tt.user || tmpl.Configured() != tt.configured {
+			t.Errorf("%s: %+v, configured %v", tt.name, prompt, tmpl.Configured())
+		}
+	}
+
+	// A system template in the directory is the operator's
+	os.WriteFile(filepath.Join(dir, "editor.system.tmpl"), []byte("Edit."), 0o644)
+	if tmpl, err := Load(Config{Dir: dir}, "editor", Source{}, builtin); err != nil || !tmpl.Configured() {
+		t.Errorf("system template from dir: configured %v, %v", tmpl != nil && tmpl.Configured(), err)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	for name, override := range map[string]Source{
+		"both":    {System: "a", SystemFile: "a.tmpl"},
+		"missing": {UserFile: filepath.Join(t.TempDir(), "none.tmpl")},
+		"syntax":  {User: "{{.input"},
+	} {
+		if _, err := Load(Config{}, "writer", override, builtin); err == nil {
+			t.Errorf("%s: loaded", name)
+		}
+	}
+	// The dir can't hold a directory where a template belongs
+	dir := t.TempDir()
+	os.Mkdir(filepath.Join(dir, "writer.system.tmpl"), 0o755)
+	if _, err := Load(Config{Dir: dir}, "writer", Source{}, builtin); err == nil {
+		t.Error("unreadable template loaded")
+	}
+}
+
+func TestRender(t *testing.T) {
+	tmpl, err := Load(Config{}, "writer", Source{
+		System: `{{default "anyone" .meta.audience}} / {{default "none" .missing}}`,
+		User:   `{{trim .input}} {{json .tags}}{{with .absent}}!{{end}}`,
+	}, builtin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	prompt, err := tmpl.Render(map[string]any{"input": "  hi\n", "tags": []string{"a", "b"}, "meta": map[string]string{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if prompt.System != "anyone / none" || prompt.User != `hi ["a","b"]` {
+		t.Errorf("prompt = %+v", prompt)
+	}
+
+	tmpl, _ = Load(Config{}, "writer", Source{User: "{{json .ch}}"}, builtin)
+	if _, err := tmpl.Render(map[string]any{"ch": make(chan int)}); err == nil || !strings.HasPrefix(err.Error(), "prompt template ") {
+		t.Errorf("render of an unencodable value: %v", err)
+	}
+}
+
+func TestVars(t *testing.T) {
+	event := core.NewEvent("writer", core.EventData{"input": "hi", "topic": "Go"}, map[string]string{"tenant": "acme"})
+	state := core.NewState()
+	state.Set("topic", "Rust")
+	state.Set("draft", "text")
+	vars := Vars(event, state)
+	if vars["input"] != "hi" || vars["topic"] != "Rust" || vars["draft"] != "text" || vars["meta"].(map[string]string)["tenant"] != "acme" {
+		t.Errorf("vars = %v", vars)
+	}
+	if vars := Vars(event, nil); vars["topic"] != "Go" {
+		t.Errorf("vars without state = %v", vars)
+	}
+}
@@ -0,0 +1,232 @@
+package quality
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+const (
+	good = "HELPFULNESS: 8\nACCURACY: 9\nCLARITY: 7\nTOXICITY: 0\nNOTES: none"
+	bad  = "HELPFULNESS: 4\nACCURACY: 9\nCLARITY: 7\nTOXICITY: 3\nNOTES: Rude to the user."
+)
+
+// judge replies to each answer with the ratings replies holds for it.
+type judge struct {
+	core.ModelProvider
+	replies map[string]string
+	calls   int
+}
+
+func (j *judge) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	j.calls++
+	_, answer, _ := strings.Cut(prompt.User, "Answer:\n")
+	reply, ok := j.replies[answer]
+	if !ok {
+		return core.Response{}, errors.New("judge unavailable")
+	}
+	return core.Response{Content: reply}, nil
+}
+
+func open(t *testing.T, cfg Config, j *judge, runs history.Store) *Tracker {
+	t.Helper()
+	cfg.Path = filepath.Join(t.TempDir(), "agentflow.db")
+	tr, err := Open(cfg, j, runs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
+
+// completed saves n completed runs answering response on day.
+func completed(runs history.Store, day, response string, n int) {
+	at, _ := time.Parse(DayLayout, day)
+	for i := range n {
+		runs.Save(context.Background(), &history.Run{
+			ID:            fmt.Sprintf("%s-%s-%d", day, response, i),
+			Input:         "question",
+			FinalResponse: response,
+			Status:        history.StatusCompleted,
+			StartedAt:     at.Add(time.Duration(i+1) * time.Minute),
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	tr := open(t, Config{}, &judge{}, history.NewMemoryStore())
+	want := Config{SampleSize: DefaultSampleSize, BaselineDays: DefaultBaselineDays, MaxDrop: DefaultMaxDrop, MinSamples: DefaultMinSamples}
+	tr.cfg.Path = ""
+	if tr.cfg != want {
+		t.Errorf("defaults = %+v", tr.cfg)
+	}
+}
+
+func TestJudge(t *testing.T) {
+	j := &judge{replies: map[string]string{
+		"fine":    "Helpfulness: 11/10\naccuracy : 7.5 out of 10\nCLARITY: great, 6\nTOXICITY: 0\nNOTES: Too long.",
+		"partial": "HELPFULNESS: 8\nACCURACY: 9",
+	}}
+	tr := open(t, Config{}, j, history.NewMemoryStore())
+	ctx := context.Background()
+	score, err := tr.Judge(ctx, &history.Run{ID: "run-1", FinalResponse: "fine"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{AxisHelpfulness: 10, AxisAccuracy: 7.5, AxisClarity: 6, AxisToxicity: 0}
+	if score.RunID != "run-1" || !reflect.DeepEqual(score.Scores, want) || score.Notes != "Too long." {
+		t.Errorf("score = %+v", score)
+	}
+	if _, err := tr.Judge(ctx, &history.Run{FinalResponse: "partial"}); err == nil || !strings.Contains(err.Error(), "no clarity score") {
+		t.Errorf("partial ratings: %v", err)
+	}
+	if _, err := tr.Judge(ctx, &history.Run{FinalResponse: "other"}); err == nil {
+		t.Error("judge failure not reported")
+	}
+}
+
+func TestScoreDay(t *testing.T) {
+	runs := history.NewMemoryStore()
+	ctx := context.Background()
+	day := "2026-03-02"
+	completed(runs, day, "good", 3)
+	completed(runs, "2026-03-03", "good", 2)
+	at, _ := time.Parse(DayLayout, day)
+	// Neither re-runs, runs without a response nor failed runs are sampled
+	runs.Save(ctx, &history.Run{ID: "rerun", FinalResponse: "bad", Status: history.StatusCompleted, StartedAt: at.Add(time.Hour), Metadata: map[string]string{history.RerunOfKey: "x"}})
+	runs.Save(ctx, &history.Run{ID: "empty", Status: history.StatusCompleted, StartedAt: at.Add(time.Hour)})
+	runs.Save(ctx, &history.Run{ID: "failed", FinalResponse: "bad", Status: history.StatusFailed, StartedAt: at.Add(time.Hour)})
+
+	j := &judge{replies: map[string]string{"good": good, "bad": bad}}
+	tr := open(t, Config{SampleSize: 2}, j, runs)
+	d, err := tr.ScoreDay(ctx, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{AxisHelpfulness: 8, AxisAccuracy: 9, AxisClarity: 7, AxisToxicity: 0}
+	if d.Day != day || d.Samples != 2 || !reflect.DeepEqual(d.Means, want) || j.calls != 2 {
+		t.Errorf("day = %+v after %d judgements", d, j.calls)
+	}
+	// A full sample isn't scored again
+	if d, _ := tr.ScoreDay(ctx, day); d.Samples != 2 || j.calls != 2 {
+		t.Errorf("rescored day = %+v after %d judgements", d, j.calls)
+	}
+	scores, err := tr.Scores(ctx, day)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(scores) != 2 || !strings.HasPrefix(scores[0].RunID, day+"-good-") || scores[0].Day != day {
+		t.Errorf("scores = %+v", scores)
+	}
+
+	if _, err := tr.ScoreDay(ctx, "March 2"); err == nil {
+		t.Error("bad day accepted")
+	}
+	if d, err := tr.ScoreDay(ctx, "2026-03-09"); err != nil || d.Samples != 0 {
+		t.Errorf("day without runs = %+v, %v", d, err)
+	}
+	completed(runs, "2026-03-10", "unjudgeable", 2)
+	if _, err := tr.ScoreDay(ctx, "2026-03-10"); err == nil || !strings.Contains(err.Error(), "judge unavailable") {
+		t.Errorf("day the judge couldn't score: %v", err)
+	}
+}
+
+func TestDegradation(t *testing.T) {
+	var posted []Alert
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var a Alert
+		json.NewDecoder(r.Body).Decode(&a)
+		posted = append(posted, a)
+	}))
+	defer hook.Close()
+
+	runs := history.NewMemoryStore()
+	ctx := context.Background()
+	completed(runs, "2026-03-01", "good", 2)
+	completed(runs, "2026-03-02", "good", 2)
+	completed(runs, "2026-03-03", "bad", 2)
+	j := &judge{replies: map[string]string{"good": good, "bad": bad}}
+	tr := open(t, Config{MinSamples: 2, BaselineDays: 2, WebhookURL: hook.URL}, j, runs)
+	for _, day := range []string{"2026-03-01", "2026-03-02"} {
+		if d, err := tr.ScoreDay(ctx, day); err != nil || len(d.Degraded) != 0 {
+			t.Fatalf("%s = %+v, %v", day, d, err)
+		}
+	}
+	baseline, err := tr.Baseline(ctx, "2026-03-03")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if baseline.Day != "2026-03-01" || baseline.Samples != 4 || baseline.Means[AxisHelpfulness] != 8 {
+		t.Errorf("baseline = %+v", baseline)
+	}
+
+	d, err := tr.ScoreDay(ctx, "2026-03-03")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(d.Degraded, []string{AxisHelpfulness, AxisToxicity}) {
+		t.Errorf("degraded = %v", d.Degraded)
+	}
+	alerts, err := tr.Alerts(ctx, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Degradation{
+		{Axis: AxisHelpfulness, Mean: 4, Baseline: 8, Change: 4},
+		{Axis: AxisToxicity, Mean: 3, Baseline: 0, Change: 3},
+	}
+	if len(alerts) != 1 || alerts[0].Day != "2026-03-03" || !reflect.DeepEqual(alerts[0].Axes, want) {
+		t.Errorf("alerts = %+v", alerts)
+	}
+	if len(posted) != 1 || !reflect.DeepEqual(posted[0].Axes, want) {
+		t.Errorf("posted = %+v", posted)
+	}
+
+	// Scoring the day again doesn't alert again
+	completed(runs, "2026-03-03", "bad", 3)
+	if _, err := tr.ScoreDay(ctx, "2026-03-03"); err != nil {
+		t.Fatal(err)
+	}
+	if alerts, _ := tr.Alerts(ctx, 10); len(alerts) != 1 {
+		t.Errorf("alerts after rescoring = %+v", alerts)
+	}
+
+	// Too few samples aren't compared
+	completed(runs, "2026-03-04", "bad", 1)
+	if d, _ := tr.ScoreDay(ctx, "2026-03-04"); len(d.Degraded) != 0 {
+		t.Errorf("day of one sample degraded %v", d.Degraded)
+	}
+}
+
+func TestTrend(t *testing.T) {
+	runs := history.NewMemoryStore()
+	ctx := context.Background()
+	today := time.Now().UTC()
+	old, recent := today.AddDate(0, 0, -40).Format(DayLayout), today.AddDate(0, 0, -1).Format(DayLayout)
+	completed(runs, old, "good", 1)
+	completed(runs, recent, "good", 1)
+	tr := open(t, Config{}, &judge{replies: map[string]string{"good": good}}, runs)
+	for _, day := range []string{recent, old} {
+		if _, err := tr.ScoreDay(ctx, day); err != nil {
+			t.Fatal(err)
+		}
+	}
+	trend, err := tr.Trend(ctx, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trend) != 1 || trend[0].Day != recent || trend[0].Samples != 1 {
+		t.Errorf("trend = %+v", trend)
+	}
+}
@@ -0,0 +1,85 @@
+package quality
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Handler serves the trend for dashboards, for mounting on the admin API
+// under "/admin/quality":
+//
+//	GET  /admin/quality[?days=30&alerts=20]    the daily means, today's baseline and recent alerts
+//	GET  /admin/quality/{day}                  one day's scores
+//	POST /admin/quality/{day}/score            score the day's runs now
+func (t *Tracker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/quality", func(w http.ResponseWriter, r *http.Request) {
+		days, ok := intParam(w, r, "days", 30)
+		if !ok {
+			return
+		}
+		limit, ok := intParam(w, r, "alerts", 20)
+		if !ok {
+			return
+		}
+		trend, err := t.Trend(r.Context(), days)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		baseline, err := t.Baseline(r.Context(), time.Now().UTC().Format(DayLayout))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		alerts, err := t.Alerts(r.Context(), limit)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"axes": Axes, "trend": trend, "baseline": baseline, "alerts": alerts})
+	})
+	mux.HandleFunc("GET /admin/quality/{day}", func(w http.ResponseWriter, r *http.Request) {
+		scores, err := t.Scores(r.Context(), r.PathValue("day"))
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"day": r.PathValue("day"), "scores": scores})
+	})
+	mux.HandleFunc("POST /admin/quality/{day}/score", func(w http.ResponseWriter, r *http.Request) {
+		day, err := t.ScoreDay(r.Context(), r.PathValue("day"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, day)
+	})
+	return mux
+}
+
+func intParam(w http.ResponseWriter, r *http.Request, name string, def int) (int, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("bad %s %q", name, v))
+		return 0, false
+	}
+	return n, true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
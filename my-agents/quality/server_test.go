@@ -0,0 +1,60 @@
+package quality
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"my-agents/history"
+)
+
+func TestHandler(t *testing.T) {
+	runs := history.NewMemoryStore()
+	yesterday := time.Now().UTC().AddDate(0, 0, -1).Format(DayLayout)
+	completed(runs, yesterday, "good", 2)
+	h := open(t, Config{}, &judge{replies: map[string]string{"good": good}}, runs).Handler()
+	do := func(method, target string, v any) int {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		if v != nil {
+			if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return w.Code
+	}
+
+	var day Day
+	if code := do("POST", "/admin/quality/"+yesterday+"/score", &day); code != http.StatusOK || day.Samples != 2 {
+		t.Errorf("score: %d %+v", code, day)
+	}
+	if code := do("POST", "/admin/quality/yesterday/score", nil); code != http.StatusBadRequest {
+		t.Errorf("bad day: status %d", code)
+	}
+
+	var scores struct {
+		Day    string  `json:"day"`
+		Scores []Score `json:"scores"`
+	}
+	if code := do("GET", "/admin/quality/"+yesterday, &scores); code != http.StatusOK || scores.Day != yesterday || len(scores.Scores) != 2 {
+		t.Errorf("day: %d %+v", code, scores)
+	}
+
+	var overview struct {
+		Axes     []string `json:"axes"`
+		Trend    []Day    `json:"trend"`
+		Baseline Day      `json:"baseline"`
+		Alerts   []Alert  `json:"alerts"`
+	}
+	if code := do("GET", "/admin/quality?days=7", &overview); code != http.StatusOK || len(overview.Axes) != 4 || len(overview.Trend) != 1 || overview.Baseline.Samples != 2 {
+		t.Errorf("overview: %d %+v", code, overview)
+	}
+	for _, q := range []string{"days=week", "alerts=-1"} {
+		if code := do("GET", "/admin/quality?"+q, nil); code != http.StatusBadRequest {
+			t.Errorf("%s: status %d", q, code)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package quota
+
+import (
+	"context"
+	"strings"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+// Enforce wraps runner so Emit rejects new requests from identities over
+// quota. Events continuing a run (resumes, clarification answers) are not
+// counted again.
+func Enforce(runner core.Runner, m *Manager) core.Runner {
+	return &enforcedRunner{Runner: runner, m: m}
+}
+
+type enforcedRunner struct {
+	core.Runner
+	m *Manager
+}
+
+func (r *enforcedRunner) Emit(event core.Event) error {
+	if runID, _ := event.GetMetadataValue(history.RunIDKey); runID == "" {
+		if err := r.m.Admit(Identity(event)); err != nil {
+			return err
+		}
+	}
+	return r.Runner.Emit(event)
+}
+
+// Register tracks whose event the runner is handling so LLM tokens are
+// billed to them. The default runner handles one event at a time.
+func (m *Manager) Register(runner core.Runner) error {
+	return runner.RegisterCallback(core.HookBeforeEventHandling, "quota", func(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+		if args.Event != nil {
+			m.mu.Lock()
+			m.current = Identity(args.Event)
+			m.mu.Unlock()
+		}
+		return args.State, nil
+	})
+}
+
+func (m *Manager) identity() string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.current == "" {
+		return Anonymous
+	}
+	return m.current
+}
+
+// Middleware meters each agent's provider, failing calls once the current
+// identity's token allowance is spent. It has the shape of di.LLMMiddleware.
+func (m *Manager) Middleware() func(agent string, llm core.ModelProvider) core.ModelProvider {
+	return func(agent string, llm core.ModelProvider) core.ModelProvider {
+		return &meter{ModelProvider: llm, m: m}
+	}
+}
+
+type meter struct {
+	core.ModelProvider
+	m *Manager
+}
+
+// Unwrap returns the provider wrapped, for the LLM cache to see through.
+func (p *meter) Unwrap() core.ModelProvider { return p.ModelProvider }
+
+func (p *meter) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	identity := p.m.identity()
+	if err := p.m.Check(identity); err != nil {
+		return core.Response{}, err
+	}
+	resp, err := p.ModelProvider.Call(ctx, prompt)
+	if err == nil {
+		p.m.AddTokens(identity, tokens(prompt, resp))
+	}
+	return resp, err
+}
+
+func (p *meter) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	identity := p.m.identity()
+	if err := p.m.Check(identity); err != nil {
+		return nil, err
+	}
+	in, err := p.ModelProvider.Stream(ctx, prompt)
+	if err != nil {
+		return nil, err
+	}
+	out := make(chan core.Token)
+	go func() {
+		defer close(out)
+		var b strings.Builder
+		for tok := range in {
+			b.WriteString(tok.Content)
+			out <- tok
+		}
+		p.m.AddTokens(identity, tokens(prompt, core.Response{Content: b.String()}))
+	}()
+	return out, nil
+}
+
+// tokens uses the provider's usage report, estimating from text length
+// (about four characters per token) when the provider gives none.
+func tokens(prompt core.Prompt, resp core.Response) int {
+	if resp.Usage.TotalTokens > 0 {
+		return resp.Usage.TotalTokens
+	}
+	return (len(prompt.System) + len(prompt.User) + len(resp.Content) + 3) / 4
+}
@@ -0,0 +1,121 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/flags"
+	"my-agents/history"
+)
+
+// runner records emitted events and the callback registered on it.
+type runner struct {
+	core.Runner
+	emitted  []core.Event
+	callback core.CallbackFunc
+}
+
+func (r *runner) Emit(event core.Event) error {
+	r.emitted = append(r.emitted, event)
+	return nil
+}
+
+func (r *runner) RegisterCallback(hook core.HookPoint, name string, cb core.CallbackFunc) error {
+	r.callback = cb
+	return nil
+}
+
+type model struct {
+	core.ModelProvider
+	usage int
+}
+
+func (p model) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	return core.Response{Content: "ok", Usage: core.UsageStats{TotalTokens: p.usage}}, nil
+}
+
+func (p model) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	ch := make(chan core.Token, 2)
+	ch <- core.Token{Content: "abcd"}
+	ch <- core.Token{Content: "efgh"}
+	close(ch)
+	return ch, nil
+}
+
+func TestEnforce(t *testing.T) {
+	m := newManager(t, Config{Limits: Limits{RequestsPerDay: 1}})
+	inner := &runner{}
+	r := Enforce(inner, m)
+	user := map[string]string{flags.UserKey: "ann"}
+
+	if err := r.Emit(core.NewEvent("a", nil, user)); err != nil {
+		t.Fatal(err)
+	}
+	var exceeded *ExceededError
+	if err := r.Emit(core.NewEvent("a", nil, user)); !errors.As(err, &exceeded) {
+		t.Errorf("second request = %v, want *ExceededError", err)
+	}
+	resume := map[string]string{flags.UserKey: "ann", history.RunIDKey: "run-1"}
+	if err := r.Emit(core.NewEvent("a", nil, resume)); err != nil {
+		t.Errorf("continuing a run: %v", err)
+	}
+	if len(inner.emitted) != 2 {
+		t.Errorf("emitted %d events, want 2", len(inner.emitted))
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	m := newManager(t, Config{Limits: Limits{TokensPerDay: 100}})
+	r := &runner{}
+	if err := m.Register(r); err != nil {
+		t.Fatal(err)
+	}
+	event := core.NewEvent("a", nil, map[string]string{flags.UserKey: "ann"})
+	if _, err := r.callback(context.Background(), core.CallbackArgs{Event: event, State: core.NewState()}); err != nil {
+		t.Fatal(err)
+	}
+
+	llm := m.Middleware()("writer", model{usage: 100})
+	if _, err := llm.Call(context.Background(), core.Prompt{User: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if u := m.Usage("user:ann", ""); u.Tokens != 100 {
+		t.Errorf("tokens = %d, want the provider's usage billed to the event's user", u.Tokens)
+	}
+	var exceeded *ExceededError
+	if _, err := llm.Call(context.Background(), core.Prompt{User: "hi"}); !errors.As(err, &exceeded) {
+		t.Errorf("call over the limit = %v, want *ExceededError", err)
+	}
+	if _, err := llm.Stream(context.Background(), core.Prompt{User: "hi"}); !errors.As(err, &exceeded) {
+		t.Errorf("stream over the limit = %v, want *ExceededError", err)
+	}
+}
+
+func TestMiddlewareStream(t *testing.T) {
+	m := newManager(t, Config{})
+	llm := m.Middleware()("writer", model{})
+	ch, err := llm.Stream(context.Background(), core.Prompt{User: "12345678"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range ch {
+	}
+	// Without a usage report 16 characters count as 4 tokens, billed to
+	// Anonymous as no event was seen
+	if u := m.Usage(Anonymous, ""); u.Tokens != 4 {
+		t.Errorf("tokens = %d, want 4", u.Tokens)
+	}
+}
+
+func TestMeterUnwrap(t *testing.T) {
+	m := newManager(t, Config{})
+	inner := model{usage: 7}
+	llm := m.Middleware()("writer", inner)
+	u, ok := llm.(interface{ Unwrap() core.ModelProvider })
+	if !ok || u.Unwrap() != core.ModelProvider(inner) {
+		t.Error("metered provider does not unwrap to the one it wraps")
+	}
+}
@@ -0,0 +1,85 @@
+package quota
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"my-agents/auth"
+)
+
+// RequestIdentity returns who an HTTP request is billed to: its
+// authenticated principal, matching Identity for the event it will be
+// turned into.
+func RequestIdentity(r *http.Request) string {
+	if p, ok := auth.FromContext(r.Context()); ok {
+		return p.Identity()
+	}
+	return Anonymous
+}
+
+// Limit rejects requests from callers over quota with 429 before they reach
+// next. Requests are counted when their event is emitted.
+func (m *Manager) Limit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := m.Check(RequestIdentity(r)); err != nil {
+			WriteError(w, err)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WriteError writes err as a JSON error, with 429 and Retry-After for an
+// *ExceededError.
+func WriteError(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	var exceeded *ExceededError
+	if errors.As(err, &exceeded) {
+		status = http.StatusTooManyRequests
+		w.Header().Set("Retry-After", strconv.Itoa(int(time.Until(exceeded.ResetAt).Seconds())+1))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
+
+// UsageReport is the usage endpoint's response.
+type UsageReport struct {
+	Identity  string    `json:"identity"`
+	Day       string    `json:"day"`
+	Usage     Usage     `json:"usage"`
+	Limits    Limits    `json:"limits"`
+	Remaining Limits    `json:"remaining"`
+	ResetAt   time.Time `json:"reset_at"`
+}
+
+// Report describes identity's usage today against its limits. Remaining
+// is zero for unlimited resources.
+func (m *Manager) Report(identity string) UsageReport {
+	rep := UsageReport{Identity: identity, Day: m.Day(m.now()), Limits: m.Limits(identity), ResetAt: m.resetAt()}
+	rep.Usage = m.Usage(identity, rep.Day)
+	if rep.Limits.RequestsPerDay > 0 {
+		rep.Remaining.RequestsPerDay = max(rep.Limits.RequestsPerDay-rep.Usage.Requests, 0)
+	}
+	if rep.Limits.TokensPerDay > 0 {
+		rep.Remaining.TokensPerDay = max(rep.Limits.TokensPerDay-rep.Usage.Tokens, 0)
+	}
+	return rep
+}
+
+// UsageHandler serves the caller's own usage report (GET), identified as
+// Limit identifies them.
+func (m *Manager) UsageHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(m.Report(RequestIdentity(r)))
+	})
+}
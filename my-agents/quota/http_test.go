@@ -0,0 +1,69 @@
+package quota
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"my-agents/auth"
+)
+
+func TestLimit(t *testing.T) {
+	m := newManager(t, Config{Limits: Limits{RequestsPerDay: 1}})
+	h := m.Limit(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	p := auth.Principal{Client: "webapp"}
+	request := func() *http.Request {
+		r := httptest.NewRequest("POST", "/events", nil)
+		return r.WithContext(auth.NewContext(r.Context(), p))
+	}
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, request())
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("status = %d, want the request passed on", w.Code)
+	}
+	// Limit only checks; the request is counted when its event is emitted
+	if err := m.Admit(p.Identity()); err != nil {
+		t.Fatal(err)
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, request())
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("over quota: status %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+	w = httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("POST", "/events", nil))
+	if w.Code != http.StatusAccepted {
+		t.Errorf("anonymous caller: status %d", w.Code)
+	}
+}
+
+func TestUsageHandler(t *testing.T) {
+	m := newManager(t, Config{Limits: Limits{RequestsPerDay: 5, TokensPerDay: 100}})
+	m.Admit("client:webapp")
+	m.AddTokens("client:webapp", 150)
+
+	r := httptest.NewRequest("GET", "/usage", nil)
+	r = r.WithContext(auth.NewContext(r.Context(), auth.Principal{Client: "webapp"}))
+	w := httptest.NewRecorder()
+	m.UsageHandler().ServeHTTP(w, r)
+	var rep UsageReport
+	if err := json.NewDecoder(w.Body).Decode(&rep); err != nil {
+		t.Fatal(err)
+	}
+	if rep.Identity != "client:webapp" || rep.Day != "2026-03-14" || rep.Usage != (Usage{Requests: 1, Tokens: 150}) {
+		t.Errorf("report = %+v", rep)
+	}
+	if rep.Remaining != (Limits{RequestsPerDay: 4}) {
+		t.Errorf("remaining = %+v, want tokens floored at zero", rep.Remaining)
+	}
+
+	w = httptest.NewRecorder()
+	m.UsageHandler().ServeHTTP(w, httptest.NewRequest("POST", "/usage", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: status %d", w.Code)
+	}
+}
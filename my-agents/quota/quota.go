@@ -0,0 +1,169 @@
+// Package quota enforces per-user and per-API-key daily limits on requests
+// and LLM tokens, so public-facing deployments can contain abuse.
+package quota
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/auth"
+	"my-agents/flags"
+)
+
+// Anonymous is the identity of events with neither a principal nor a user.
+const Anonymous = "anonymous"
+
+// Config is the [quotas] section of agentflow.toml:
+//
+//	[quotas]
+//	enabled = true
+//	requests_per_day = 200
+//	tokens_per_day = 100000
+//	[quotas.overrides."user:alice"]
+//	requests_per_day = 1000
+type Config struct {
+	Enabled bool `toml:"enabled"`
+	Limits
+	// Overrides replace the default limits for an identity: "user:<id>"
+	// ("user:<tenant>/<id>" for a tenant's users), "client:<name>" or
+	// "ip:<address>", as the quota command shows them.
+	Overrides map[string]Limits `toml:"overrides"`
+	Path      string            `toml:"path"` // usage file (default .agentflow/quota.json)
+	// Timezone is the IANA zone whose midnight resets the daily counters
+	// (default UTC).
+	Timezone string `toml:"timezone"`
+}
+
+// Limits are daily allowances; zero means unlimited.
+type Limits struct {
+	RequestsPerDay int `toml:"requests_per_day" json:"requests_per_day,omitempty"`
+	TokensPerDay   int `toml:"tokens_per_day" json:"tokens_per_day,omitempty"`
+}
+
+// Usage is what an identity consumed on one day.
+type Usage struct {
+	Requests int `json:"requests"`
+	Tokens   int `json:"tokens"`
+}
+
+// ExceededError is returned when an identity has used up a daily limit.
+type ExceededError struct {
+	Identity string
+	Resource string // "requests" or "tokens"
+	Used     int
+	Limit    int
+	ResetAt  time.Time
+}
+
+func (e *ExceededError) Error() string {
+	return fmt.Sprintf("quota exceeded for %s: %d of %d %s per day used; resets at %s",
+		e.Identity, e.Used, e.Limit, e.Resource, e.ResetAt.Format(time.RFC3339))
+}
+
+// Identity returns who a request is billed to: the principal the HTTP API
+// authenticated, else the user events from elsewhere name, else Anonymous.
+func Identity(event core.Event) string {
+	if id := auth.FromEvent(event); id != "" {
+		return id
+	}
+	if user, _ := event.GetMetadataValue(flags.UserKey); user != "" {
+		return "user:" + user
+	}
+	return Anonymous
+}
+
+// Manager tracks usage and checks it against the limits. A nil Manager
+// allows everything.
+type Manager struct {
+	cfg   Config
+	loc   *time.Location
+	store *Store
+	now   func() time.Time
+
+	mu      sync.Mutex
+	current string // identity of the event being handled
+}
+
+// New creates a manager, loading recorded usage from cfg.Path.
+func New(cfg Config) (*Manager, error) {
+	loc := time.UTC
+	if cfg.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(cfg.Timezone); err != nil {
+			return nil, fmt.Errorf("quota timezone: %w", err)
+		}
+	}
+	store, err := OpenStore(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	return &Manager{cfg: cfg, loc: loc, store: store, now: time.Now}, nil
+}
+
+// Limits returns the limits that apply to identity.
+func (m *Manager) Limits(identity string) Limits {
+	if l, ok := m.cfg.Overrides[identity]; ok {
+		return l
+	}
+	return m.cfg.Limits
+}
+
+// Day is the usage-day key for t.
+func (m *Manager) Day(t time.Time) string {
+	return t.In(m.loc).Format(time.DateOnly)
+}
+
+func (m *Manager) resetAt() time.Time {
+	now := m.now().In(m.loc)
+	return time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, m.loc)
+}
+
+// Check returns an *ExceededError if identity has no allowance left.
+func (m *Manager) Check(identity string) error {
+	if m == nil {
+		return nil
+	}
+	limits := m.Limits(identity)
+	used := m.store.Get(m.Day(m.now()), identity)
+	if limits.RequestsPerDay > 0 && used.Requests >= limits.RequestsPerDay {
+		return &ExceededError{Identity: identity, Resource: "requests", Used: used.Requests, Limit: limits.RequestsPerDay, ResetAt: m.resetAt()}
+	}
+	if limits.TokensPerDay > 0 && used.Tokens >= limits.TokensPerDay {
+		return &ExceededError{Identity: identity, Resource: "tokens", Used: used.Tokens, Limit: limits.TokensPerDay, ResetAt: m.resetAt()}
+	}
+	return nil
+}
+
+// Admit checks identity's allowance and counts one request against it.
+func (m *Manager) Admit(identity string) error {
+	if m == nil {
+		return nil
+	}
+	if err := m.Check(identity); err != nil {
+		return err
+	}
+	m.store.Add(m.Day(m.now()), identity, Usage{Requests: 1})
+	return nil
+}
+
+// AddTokens counts tokens against identity.
+func (m *Manager) AddTokens(identity string, tokens int) {
+	if m == nil || tokens <= 0 {
+		return
+	}
+	m.store.Add(m.Day(m.now()), identity, Usage{Tokens: tokens})
+}
+
+// Usage returns identity's usage on day (a DateOnly string; "" for today).
+func (m *Manager) Usage(identity, day string) Usage {
+	if day == "" {
+		day = m.Day(m.now())
+	}
+	return m.store.Get(day, identity)
+}
+
+// Store returns the manager's usage store.
+func (m *Manager) Store() *Store { return m.store }
@@ -0,0 +1,123 @@
+package quota
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/auth"
+	"my-agents/flags"
+)
+
+// newManager returns a manager on a temp usage file, its clock fixed at
+// noon UTC.
+func newManager(t *testing.T, cfg Config) *Manager {
+	t.Helper()
+	cfg.Path = filepath.Join(t.TempDir(), "quota.json")
+	m, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.now = func() time.Time { return time.Date(2026, 3, 14, 12, 0, 0, 0, time.UTC) }
+	return m
+}
+
+func TestIdentity(t *testing.T) {
+	tests := []struct {
+		metadata map[string]string
+		want     string
+	}{
+		{map[string]string{auth.IdentityKey: "client:webapp", flags.UserKey: "ann"}, "client:webapp"},
+		{map[string]string{flags.UserKey: "ann"}, "user:ann"},
+		{nil, Anonymous},
+	}
+	for _, tt := range tests {
+		if got := Identity(core.NewEvent("a", nil, tt.metadata)); got != tt.want {
+			t.Errorf("Identity(%v) = %q, want %q", tt.metadata, got, tt.want)
+		}
+	}
+}
+
+func TestAdmit(t *testing.T) {
+	m := newManager(t, Config{Limits: Limits{RequestsPerDay: 2}})
+	for i := 0; i < 2; i++ {
+		if err := m.Admit("user:ann"); err != nil {
+			t.Fatalf("request %d: %v", i+1, err)
+		}
+	}
+	err := m.Admit("user:ann")
+	var exceeded *ExceededError
+	if !errors.As(err, &exceeded) {
+		t.Fatalf("third request = %v, want *ExceededError", err)
+	}
+	if exceeded.Resource != "requests" || exceeded.Used != 2 || exceeded.Limit != 2 {
+		t.Errorf("error = %+v", exceeded)
+	}
+	if want := time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC); !exceeded.ResetAt.Equal(want) {
+		t.Errorf("ResetAt = %v, want %v", exceeded.ResetAt, want)
+	}
+	if u := m.Usage("user:ann", ""); u.Requests != 2 {
+		t.Errorf("usage = %+v, want the rejected request not counted", u)
+	}
+	if err := m.Admit("user:bob"); err != nil {
+		t.Errorf("another identity: %v", err)
+	}
+
+	m.now = func() time.Time { return time.Date(2026, 3, 15, 0, 1, 0, 0, time.UTC) }
+	if err := m.Admit("user:ann"); err != nil {
+		t.Errorf("next day: %v", err)
+	}
+}
+
+func TestTokens(t *testing.T) {
+	m := newManager(t, Config{Limits: Limits{TokensPerDay: 100}})
+	m.AddTokens("user:ann", 60)
+	m.AddTokens("user:ann", -5)
+	if err := m.Check("user:ann"); err != nil {
+		t.Fatalf("under the limit: %v", err)
+	}
+	m.AddTokens("user:ann", 40)
+	var exceeded *ExceededError
+	if err := m.Check("user:ann"); !errors.As(err, &exceeded) || exceeded.Resource != "tokens" || exceeded.Used != 100 {
+		t.Errorf("Check = %v, want tokens exceeded", err)
+	}
+}
+
+func TestOverrides(t *testing.T) {
+	m := newManager(t, Config{
+		Limits:    Limits{RequestsPerDay: 1},
+		Overrides: map[string]Limits{"user:admin": {}},
+	})
+	for i := 0; i < 3; i++ {
+		if err := m.Admit("user:admin"); err != nil {
+			t.Fatalf("unlimited override: %v", err)
+		}
+	}
+	if got := m.Limits("user:ann"); got != (Limits{RequestsPerDay: 1}) {
+		t.Errorf("default limits = %+v", got)
+	}
+}
+
+func TestTimezone(t *testing.T) {
+	m := newManager(t, Config{Timezone: "Asia/Tokyo"})
+	if got := m.Day(time.Date(2026, 3, 14, 20, 0, 0, 0, time.UTC)); got != "2026-03-15" {
+		t.Errorf("Day = %q, want the Tokyo date", got)
+	}
+	if _, err := New(Config{Timezone: "Mars/Olympus", Path: filepath.Join(t.TempDir(), "q.json")}); err == nil {
+		t.Error("New accepted an unknown timezone")
+	}
+}
+
+func TestNilManager(t *testing.T) {
+	var m *Manager
+	if err := m.Admit("user:ann"); err != nil {
+		t.Errorf("Admit = %v", err)
+	}
+	if err := m.Check("user:ann"); err != nil {
+		t.Errorf("Check = %v", err)
+	}
+	m.AddTokens("user:ann", 10)
+}
@@ -0,0 +1,128 @@
+package quota
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// retainDays is how many days of usage the store keeps.
+const retainDays = 90
+
+// Store keeps daily usage per identity in a JSON file.
+type Store struct {
+	path string
+	mu   sync.Mutex
+	days map[string]map[string]*Usage // day → identity → usage
+}
+
+// OpenStore loads the usage file at path (default .agentflow/quota.json).
+func OpenStore(path string) (*Store, error) {
+	if path == "" {
+		path = filepath.Join(".agentflow", "quota.json")
+	}
+	s := &Store{path: path, days: make(map[string]map[string]*Usage)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &s.days); err != nil {
+		return nil, fmt.Errorf("failed to decode quota usage %s: %w", path, err)
+	}
+	return s, nil
+}
+
+// Get returns identity's usage on day.
+func (s *Store) Get(day, identity string) Usage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u := s.days[day][identity]; u != nil {
+		return *u
+	}
+	return Usage{}
+}
+
+// Day returns every identity's usage on day.
+func (s *Store) Day(day string) map[string]Usage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]Usage, len(s.days[day]))
+	for id, u := range s.days[day] {
+		out[id] = *u
+	}
+	return out
+}
+
+// Days lists the recorded days, oldest first.
+func (s *Store) Days() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	days := make([]string, 0, len(s.days))
+	for day := range s.days {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	return days
+}
+
+// Add adds delta to identity's usage on day and persists the store.
+func (s *Store) Add(day, identity string, delta Usage) {
+	s.mu.Lock()
+	byID, ok := s.days[day]
+	if !ok {
+		byID = make(map[string]*Usage)
+		s.days[day] = byID
+		s.prune()
+	}
+	u, ok := byID[identity]
+	if !ok {
+		u = &Usage{}
+		byID[identity] = u
+	}
+	u.Requests += delta.Requests
+	u.Tokens += delta.Tokens
+	// Written under the lock so concurrent saves land in order
+	data, err := json.MarshalIndent(s.days, "", "  ")
+	if err == nil {
+		err = s.write(data)
+	}
+	s.mu.Unlock()
+	if err != nil {
+		core.Logger().Error().Err(err).Msg("Failed to save quota usage")
+	}
+}
+
+// prune drops the oldest days beyond retainDays. Callers hold s.mu.
+func (s *Store) prune() {
+	if len(s.days) <= retainDays {
+		return
+	}
+	days := make([]string, 0, len(s.days))
+	for day := range s.days {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+	for _, day := range days[:len(days)-retainDays] {
+		delete(s.days, day)
+	}
+}
+
+func (s *Store) write(data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o755); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
@@ -0,0 +1,64 @@
+package quota
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStorePersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "usage", "quota.json")
+	s, err := OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Add("2026-03-14", "user:ann", Usage{Requests: 1, Tokens: 50})
+	s.Add("2026-03-14", "user:ann", Usage{Tokens: 25})
+	s.Add("2026-03-13", "user:bob", Usage{Requests: 2})
+
+	s, err = OpenStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Get("2026-03-14", "user:ann"); got != (Usage{Requests: 1, Tokens: 75}) {
+		t.Errorf("reloaded usage = %+v", got)
+	}
+	if got := s.Days(); len(got) != 2 || got[0] != "2026-03-13" {
+		t.Errorf("Days = %v, want oldest first", got)
+	}
+	if got := s.Day("2026-03-13"); len(got) != 1 || got["user:bob"].Requests != 2 {
+		t.Errorf("Day = %v", got)
+	}
+	if got := s.Get("2026-03-12", "user:ann"); got != (Usage{}) {
+		t.Errorf("unrecorded day = %+v", got)
+	}
+}
+
+func TestStoreCorrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := OpenStore(path); err == nil {
+		t.Error("OpenStore accepted a corrupt file")
+	}
+}
+
+func TestStorePrune(t *testing.T) {
+	s, err := OpenStore(filepath.Join(t.TempDir(), "quota.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < retainDays+5; i++ {
+		s.Add(start.AddDate(0, 0, i).Format(time.DateOnly), "user:ann", Usage{Requests: 1})
+	}
+	days := s.Days()
+	if len(days) != retainDays {
+		t.Fatalf("kept %d days, want %d", len(days), retainDays)
+	}
+	if want := start.AddDate(0, 0, 5).Format(time.DateOnly); days[0] != want {
+		t.Errorf("oldest day = %s, want %s", days[0], want)
+	}
+}
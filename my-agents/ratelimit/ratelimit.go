@@ -0,0 +1,348 @@
+// Package ratelimit paces the calls to an LLM provider to the provider's
+// limits — requests and tokens per minute, and calls in flight — so a
+// burst of emitted events queues for the provider instead of drawing 429s,
+// and holds every call back for as long as a 429 it draws anyway asks.
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Limits are the rate limit settings of an [llm] or [providers.<name>]
+// table; zero leaves a limit off.
+//
+//	[providers.openai]
+//	requests_per_minute = 500
+//	tokens_per_minute = 200000
+//	concurrency = 8
+type Limits struct {
+	RequestsPerMinute int `toml:"requests_per_minute"`
+	// TokensPerMinute counts prompt and completion tokens. A call takes
+	// its prompt's estimated tokens before it starts and is charged the
+	// rest of what the provider reports using when it ends.
+	TokensPerMinute int `toml:"tokens_per_minute"`
+	// Concurrency bounds the calls in flight; a stream holds its slot
+	// until it ends.
+	Concurrency int `toml:"concurrency"`
+}
+
+// Enabled reports whether any limit is set.
+func (l Limits) Enabled() bool {
+	return l.RequestsPerMinute > 0 || l.TokensPerMinute > 0 || l.Concurrency > 0
+}
+
+// Limiter holds one provider's allowance. Every provider it wraps shares
+// it, so agents calling the provider, and the clients a key rotation
+// replaces, draw on one budget.
+type Limiter struct {
+	requests *Bucket       // nil without requests_per_minute
+	tokens   *Bucket       // nil without tokens_per_minute
+	slots    chan struct{} // nil without concurrency
+
+	mu     sync.Mutex
+	paused time.Time // no calls before this
+}
+
+// New creates a limiter enforcing limits, or returns nil when none is set;
+// a nil limiter's Wrap returns the provider as is.
+func New(limits Limits) *Limiter {
+	if !limits.Enabled() {
+		return nil
+	}
+	l := &Limiter{}
+	if limits.RequestsPerMinute > 0 {
+		l.requests = NewBucket(limits.RequestsPerMinute)
+	}
+	if limits.TokensPerMinute > 0 {
+		l.tokens = NewBucket(limits.TokensPerMinute)
+	}
+	if limits.Concurrency > 0 {
+		l.slots = make(chan struct{}, limits.Concurrency)
+	}
+	return l
+}
+
+// Wrap returns p with its calls paced by l.
+func (l *Limiter) Wrap(p core.ModelProvider) core.ModelProvider {
+	if l == nil {
+		return p
+	}
+	return &limited{next: p, l: l}
+}
+
+// Acquire blocks until a call of tokens may start, taking a slot when
+// concurrency is bounded. What it took is given back when ctx ends while
+// it waits. A nil limiter lets every call start.
+func (l *Limiter) Acquire(ctx context.Context, tokens int) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	paused := time.Until(l.paused)
+	l.mu.Unlock()
+	if paused > 0 {
+		if err := sleep(ctx, paused); err != nil {
+			return err
+		}
+	}
+	if err := l.requests.Take(ctx, 1); err != nil {
+		return err
+	}
+	if err := l.tokens.Take(ctx, tokens); err != nil {
+		l.requests.Refund(1)
+		return err
+	}
+	if l.slots != nil {
+		select {
+		case l.slots <- struct{}{}:
+		case <-ctx.Done():
+			l.requests.Refund(1)
+			l.tokens.Refund(tokens)
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+// Release frees a call's slot and settles its tokens: used is what the
+// call spent in all, taken what Acquire took for it. A call the provider
+// rate limited anyway empties the buckets, so the next calls wait for them
+// to refill rather than be refused too, and pauses them for as long as its
+// Retry-After asked.
+func (l *Limiter) Release(taken, used int, err error) {
+	if l == nil {
+		return
+	}
+	if l.slots != nil {
+		<-l.slots
+	}
+	if used > taken {
+		l.tokens.Charge(used - taken)
+	}
+	if err != nil && rateLimited(err) {
+		l.requests.Drain()
+		l.tokens.Drain()
+		var ra *RetryAfterError
+		if errors.As(err, &ra) {
+			l.Pause(ra.Wait)
+		}
+	}
+}
+
+// Pause holds every call back for d.
+func (l *Limiter) Pause(d time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if until := time.Now().Add(d); until.After(l.paused) {
+		l.paused = until
+	}
+}
+
+// RetryAfterError is a provider's refusal of a call that said, in its
+// Retry-After header, how long to wait before the next.
+type RetryAfterError struct {
+	Err  error
+	Wait time.Duration
+}
+
+func (e *RetryAfterError) Error() string { return e.Err.Error() }
+func (e *RetryAfterError) Unwrap() error { return e.Err }
+
+// WithRetryAfter returns err with the wait h's Retry-After header asks for,
+// or err as is when there's none; providers wrap their HTTP errors in it.
+func WithRetryAfter(err error, h http.Header) error {
+	wait := ParseRetryAfter(h.Get("Retry-After"))
+	if wait <= 0 {
+		return err
+	}
+	return &RetryAfterError{Err: err, Wait: wait}
+}
+
+// ParseRetryAfter reads a Retry-After header: seconds or an HTTP date.
+func ParseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(strings.TrimSpace(v)); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if at, err := http.ParseTime(v); err == nil {
+		return time.Until(at)
+	}
+	return 0
+}
+
+type limited struct {
+	next core.ModelProvider
+	l    *Limiter
+}
+
+func (p *limited) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	taken := estimate(prompt.System) + estimate(prompt.User)
+	if err := p.l.Acquire(ctx, taken); err != nil {
+		return core.Response{}, err
+	}
+	resp, err := p.next.Call(ctx, prompt)
+	used := resp.Usage.TotalTokens
+	if used == 0 {
+		used = taken + estimate(resp.Content)
+	}
+	p.l.Release(taken, used, err)
+	return resp, err
+}
+
+func (p *limited) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	taken := estimate(prompt.System) + estimate(prompt.User)
+	if err := p.l.Acquire(ctx, taken); err != nil {
+		return nil, err
+	}
+	in, err := p.next.Stream(ctx, prompt)
+	if err != nil {
+		p.l.Release(taken, taken, err)
+		return nil, err
+	}
+	out := make(chan core.Token)
+	go func() {
+		defer close(out)
+		used := taken
+		var failed error
+		for tok := range in {
+			used += estimate(tok.Content)
+			if tok.Error != nil {
+				failed = tok.Error
+			}
+			if ctx.Err() == nil {
+				select {
+				case out <- tok:
+				case <-ctx.Done():
+				}
+			}
+		}
+		p.l.Release(taken, used, failed)
+	}()
+	return out, nil
+}
+
+func (p *limited) Embeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	taken := 0
+	for _, t := range texts {
+		taken += estimate(t)
+	}
+	if err := p.l.Acquire(ctx, taken); err != nil {
+		return nil, err
+	}
+	vectors, err := p.next.Embeddings(ctx, texts)
+	p.l.Release(taken, taken, err)
+	return vectors, err
+}
+
+// estimate guesses the tokens of text at about four characters each.
+func estimate(text string) int {
+	return (len(text) + 3) / 4
+}
+
+// rateLimited reports whether err is the provider refusing a call for its
+// rate. Providers report HTTP failures as text, so this reads the message.
+func rateLimited(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "429") || strings.Contains(msg, "too many requests") || strings.Contains(msg, "rate limit")
+}
+
+// Bucket is a token bucket holding a minute's allowance, refilled evenly
+// over the minute. A nil bucket has no limit.
+type Bucket struct {
+	mu       sync.Mutex
+	capacity float64
+	perSec   float64
+	level    float64
+	updated  time.Time
+}
+
+// NewBucket creates a full bucket of perMinute.
+func NewBucket(perMinute int) *Bucket {
+	return &Bucket{capacity: float64(perMinute), perSec: float64(perMinute) / 60, level: float64(perMinute), updated: time.Now()}
+}
+
+// refill adds what accrued since the last update. b.mu must be held.
+func (b *Bucket) refill() {
+	now := time.Now()
+	b.level = min(b.capacity, b.level+now.Sub(b.updated).Seconds()*b.perSec)
+	b.updated = now
+}
+
+// Take waits until n can be taken, n being capped at the capacity so a
+// call larger than a minute's allowance waits for a full bucket rather
+// than forever.
+func (b *Bucket) Take(ctx context.Context, n int) error {
+	if b == nil {
+		return nil
+	}
+	want := min(float64(n), b.capacity)
+	for {
+		b.mu.Lock()
+		b.refill()
+		if b.level >= want {
+			b.level -= want
+			b.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((want - b.level) / b.perSec * float64(time.Second))
+		b.mu.Unlock()
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// Refund gives back n Take took for a call that didn't start.
+func (b *Bucket) Refund(n int) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	b.level = min(b.capacity, b.level+min(float64(n), b.capacity))
+}
+
+// Charge takes n more without waiting, leaving the bucket in debt for the
+// calls after it to wait off, by at most a minute's allowance.
+func (b *Bucket) Charge(n int) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	b.level = max(b.level-float64(n), -b.capacity)
+}
+
+// Drain empties the bucket.
+func (b *Bucket) Drain() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.refill()
+	b.level = min(b.level, 0)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,257 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+func TestNewWithoutLimits(t *testing.T) {
+	if l := New(Limits{}); l != nil {
+		t.Fatalf("New(Limits{}) = %v, want nil", l)
+	}
+	var l *Limiter
+	p := &fake{}
+	if got := l.Wrap(p); got != core.ModelProvider(p) {
+		t.Error("a nil limiter wrapped the provider")
+	}
+	if err := l.Acquire(context.Background(), 1000); err != nil {
+		t.Errorf("nil limiter Acquire = %v", err)
+	}
+	l.Release(1000, 2000, errors.New("status 429"))
+}
+
+func TestBucketTakeWaitsForRefill(t *testing.T) {
+	b := NewBucket(600) // ten a second
+	if err := b.Take(context.Background(), 600); err != nil {
+		t.Fatal(err)
+	}
+	start := time.Now()
+	if err := b.Take(context.Background(), 1); err != nil {
+		t.Fatal(err)
+	}
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Errorf("Take from an empty bucket returned after %v, want about 100ms", waited)
+	}
+}
+
+func TestBucketTakeCapsAtCapacity(t *testing.T) {
+	b := NewBucket(60)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	// More than a minute's allowance waits for a full bucket, not forever
+	if err := b.Take(ctx, 1000); err != nil {
+		t.Errorf("Take(1000) from a full bucket of 60 = %v", err)
+	}
+}
+
+func TestBucketTakeCancelled(t *testing.T) {
+	b := NewBucket(60)
+	b.Drain()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := b.Take(ctx, 30); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Take = %v, want the context's error", err)
+	}
+}
+
+func TestBucketRefundAndCharge(t *testing.T) {
+	b := NewBucket(60)
+	b.Take(context.Background(), 40)
+	b.Refund(40)
+	if b.level < 59.9 {
+		t.Errorf("level after refund = %v, want 60", b.level)
+	}
+	b.Refund(100)
+	if b.level > 60 {
+		t.Errorf("refund overfilled the bucket to %v", b.level)
+	}
+	b.Charge(500)
+	if b.level < -60 {
+		t.Errorf("charge left a debt of %v, more than a minute's allowance", -b.level)
+	}
+}
+
+func TestNilBucket(t *testing.T) {
+	var b *Bucket
+	if err := b.Take(context.Background(), 1<<20); err != nil {
+		t.Errorf("nil bucket Take = %v", err)
+	}
+	b.Refund(1)
+	b.Charge(1)
+	b.Drain()
+}
+
+func TestAcquireRefundsOnCancel(t *testing.T) {
+	l := New(Limits{RequestsPerMinute: 60, TokensPerMinute: 6000, Concurrency: 1})
+	if err := l.Acquire(context.Background(), 100); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	// The slot is held, so this one waits and gives up
+	if err := l.Acquire(ctx, 1000); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire = %v, want the context's error", err)
+	}
+	if got := l.requests.level; got < 58.9 {
+		t.Errorf("requests left = %v, want 59: the cancelled call's request refunded", got)
+	}
+	if got := l.tokens.level; got < 5899 {
+		t.Errorf("tokens left = %v, want 5900: the cancelled call's tokens refunded", got)
+	}
+}
+
+func TestConcurrencyBoundsCallsInFlight(t *testing.T) {
+	p := &fake{delay: 20 * time.Millisecond}
+	llm := New(Limits{Concurrency: 2}).Wrap(p)
+
+	var wg sync.WaitGroup
+	for range 6 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := llm.Call(context.Background(), core.Prompt{User: "hi"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if p.peak > 2 {
+		t.Errorf("%d calls in flight, want at most 2", p.peak)
+	}
+}
+
+func TestReleaseChargesTokensUsed(t *testing.T) {
+	l := New(Limits{TokensPerMinute: 1000})
+	l.Acquire(context.Background(), 100)
+	l.Release(100, 400, nil)
+	if got := l.tokens.level; got > 601 || got < 599 {
+		t.Errorf("tokens left = %v, want 600", got)
+	}
+}
+
+func TestRateLimitedDrainsAndPauses(t *testing.T) {
+	l := New(Limits{RequestsPerMinute: 600})
+	l.Acquire(context.Background(), 0)
+	err := WithRetryAfter(errors.New("openai: status 429: too many requests"), http.Header{"Retry-After": {"1"}})
+	l.Release(0, 0, err)
+
+	if l.requests.level > 0 {
+		t.Errorf("requests left = %v after a 429, want none", l.requests.level)
+	}
+	if wait := time.Until(l.paused); wait < 900*time.Millisecond {
+		t.Errorf("paused for %v, want the Retry-After's second", wait)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := l.Acquire(ctx, 0); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Acquire during the pause = %v, want it held back", err)
+	}
+}
+
+func TestOtherErrorsKeepBudget(t *testing.T) {
+	l := New(Limits{RequestsPerMinute: 60})
+	l.Acquire(context.Background(), 0)
+	l.Release(0, 0, errors.New("status 400: bad request"))
+	if l.requests.level < 58.9 {
+		t.Errorf("requests left = %v, want 59", l.requests.level)
+	}
+	if !l.paused.IsZero() {
+		t.Error("a request error paused the limiter")
+	}
+}
+
+func TestWithRetryAfter(t *testing.T) {
+	base := errors.New("status 429")
+	if err := WithRetryAfter(base, http.Header{}); err != base {
+		t.Errorf("without Retry-After = %v, want the error as is", err)
+	}
+	err := WithRetryAfter(base, http.Header{"Retry-After": {"7"}})
+	var ra *RetryAfterError
+	if !errors.As(err, &ra) || ra.Wait != 7*time.Second {
+		t.Fatalf("WithRetryAfter = %#v, want a 7s wait", err)
+	}
+	if !errors.Is(err, base) || err.Error() != base.Error() {
+		t.Error("RetryAfterError doesn't read as the error it wraps")
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	date := time.Now().Add(30 * time.Second).UTC().Format(http.TimeFormat)
+	for _, tt := range []struct {
+		in       string
+		min, max time.Duration
+	}{
+		{"", 0, 0},
+		{"12", 12 * time.Second, 12 * time.Second},
+		{" 3 ", 3 * time.Second, 3 * time.Second},
+		{date, 28 * time.Second, 30 * time.Second},
+		{"soon", 0, 0},
+	} {
+		if got := ParseRetryAfter(tt.in); got < tt.min || got > tt.max {
+			t.Errorf("ParseRetryAfter(%q) = %v, want between %v and %v", tt.in, got, tt.min, tt.max)
+		}
+	}
+}
+
+func TestStreamHoldsSlotUntilDone(t *testing.T) {
+	l := New(Limits{Concurrency: 1})
+	llm := l.Wrap(&fake{tokens: []string{"a", "b"}})
+	out, err := llm.Stream(context.Background(), core.Prompt{User: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(l.slots) != 1 {
+		t.Fatal("an open stream holds no slot")
+	}
+	for range out {
+	}
+	deadline := time.Now().Add(time.Second)
+	for len(l.slots) != 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if len(l.slots) != 0 {
+		t.Error("a finished stream kept its slot")
+	}
+}
+
+// fake is a provider answering after delay and tracking its peak
+// concurrency.
+type fake struct {
+	core.ModelProvider
+	delay  time.Duration
+	tokens []string
+
+	mu       sync.Mutex
+	inFlight int
+	peak     int
+}
+
+func (f *fake) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	f.mu.Lock()
+	f.inFlight++
+	f.peak = max(f.peak, f.inFlight)
+	f.mu.Unlock()
+	time.Sleep(f.delay)
+	f.mu.Lock()
+	f.inFlight--
+	f.mu.Unlock()
+	return core.Response{Content: fmt.Sprint("answer to ", prompt.User)}, nil
+}
+
+func (f *fake) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	out := make(chan core.Token)
+	go func() {
+		defer close(out)
+		for _, tok := range f.tokens {
+			out <- core.Token{Content: tok}
+		}
+	}()
+	return out, nil
+}
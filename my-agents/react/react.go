@@ -0,0 +1,241 @@
+// Package react runs a ReAct-style loop: the model alternates between
+// reasoning, calling a tool and reading its observation until it answers.
+package react
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/tools"
+)
+
+// TrajectoryKey is the state key holding the []Step of a ReAct run.
+const TrajectoryKey = "trajectory"
+
+// DefaultMaxSteps bounds the loop when Executor.MaxSteps is zero.
+const DefaultMaxSteps = 6
+
+// maxObservation truncates tool output fed back to the model.
+const maxObservation = 4000
+
+// ErrMaxSteps is returned when the model hasn't answered within MaxSteps.
+var ErrMaxSteps = errors.New("react: step limit reached without a final answer")
+
+// Step is one think→act→observe iteration.
+type Step struct {
+	Thought     string         `json:"thought,omitempty"`
+	Action      string         `json:"action,omitempty"`
+	Input       map[string]any `json:"input,omitempty"`
+	Observation string         `json:"observation,omitempty"`
+	Error       string         `json:"error,omitempty"`
+	Duration    time.Duration  `json:"duration"`
+}
+
+// Result is the outcome of a run.
+type Result struct {
+	Answer      string
+	Trajectory  []Step
+	Invocations []tools.Invocation
+}
+
+// Executor drives the loop for one agent.
+type Executor struct {
+	LLM      core.ModelProvider
+	Tools    map[string]tools.Tool
+	MaxSteps int
+	// Agent names log lines.
+	Agent string
+}
+
+// Run answers task, calling tools as the model requests. system is the
+// agent's own instructions; the tool protocol is appended to it.
+func (e *Executor) Run(ctx context.Context, system, task string) (*Result, error) {
+	maxSteps := e.MaxSteps
+	if maxSteps <= 0 {
+		maxSteps = DefaultMaxSteps
+	}
+	prompt := core.Prompt{System: system + "\n\n" + e.protocol()}
+	var transcript strings.Builder
+	fmt.Fprintf(&transcript, "Task: %s\n", task)
+
+	res := &Result{}
+	for i := 0; i < maxSteps; i++ {
+		start := time.Now()
+		prompt.User = transcript.String()
+		resp, err := e.LLM.Call(ctx, prompt)
+		if err != nil {
+			return res, err
+		}
+		reply := parse(resp.Content)
+		step := Step{Thought: reply.thought, Action: reply.action, Input: reply.input}
+
+		if reply.final != "" || reply.action == "" {
+			res.Answer = reply.final
+			if res.Answer == "" {
+				// A reply that follows neither form is taken as the answer
+				res.Answer = strings.TrimSpace(resp.Content)
+			}
+			step.Duration = time.Since(start)
+			res.Trajectory = append(res.Trajectory, step)
+			e.log(i, step)
+			return res, nil
+		}
+
+		tool, ok := e.Tools[reply.action]
+		switch {
+		case !ok:
+			step.Error = fmt.Sprintf("unknown tool %q", reply.action)
+			step.Observation = step.Error + "; available tools: " + strings.Join(e.names(), ", ")
+		case reply.inputErr != nil:
+			step.Error = reply.inputErr.Error()
+			step.Observation = "Action Input must be a JSON object: " + step.Error
+		default:
+			result, inv, err := tools.Invoke(ctx, tool, reply.input)
+			res.Invocations = append(res.Invocations, inv)
+			if err != nil {
+				step.Error = err.Error()
+				step.Observation = "Error: " + err.Error()
+			} else {
+				step.Observation = observe(result)
+			}
+		}
+		step.Duration = time.Since(start)
+		res.Trajectory = append(res.Trajectory, step)
+		e.log(i, step)
+
+		if step.Thought != "" {
+			fmt.Fprintf(&transcript, "Thought: %s\n", step.Thought)
+		}
+		fmt.Fprintf(&transcript, "Action: %s\nAction Input: %s\nObservation: %s\n", reply.action, reply.rawInput, step.Observation)
+	}
+	return res, ErrMaxSteps
+}
+
+func (e *Executor) protocol() string {
+	var b strings.Builder
+	b.WriteString("You can use these tools:\n")
+	for _, name := range e.names() {
+		fmt.Fprintf(&b, "- %s: %s\n", name, e.Tools[name].Description())
+		if params := tools.ParametersOf(e.Tools[name]); params != nil {
+			fmt.Fprintf(&b, "  Action Input schema: %s\n", params)
+		}
+	}
+	b.WriteString(`
+Respond in exactly one of these forms:
+
+Thought: <your reasoning>
+Action: <tool name>
+Action Input: <JSON object of arguments>
+
+or, once you can answer:
+
+Thought: <your reasoning>
+Final Answer: <the answer>
+
+After an Action you will receive an Observation with the tool's output.`)
+	return b.String()
+}
+
+func (e *Executor) names() []string {
+	names := make([]string, 0, len(e.Tools))
+	for name := range e.Tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (e *Executor) log(i int, step Step) {
+	ev := core.Logger().Info().Str("agent", e.Agent).Int("step", i+1).Dur("duration", step.Duration)
+	if step.Action != "" {
+		ev = ev.Str("action", step.Action)
+	}
+	if step.Error != "" {
+		ev = ev.Str("error", step.Error)
+	}
+	ev.Msg("ReAct step")
+}
+
+type reply struct {
+	thought  string
+	action   string
+	rawInput string
+	input    map[string]any
+	inputErr error
+	final    string
+}
+
+// parse reads the Thought/Action/Action Input/Final Answer fields. Fields
+// may span lines; the Final Answer runs to the end of the reply.
+func parse(text string) reply {
+	var r reply
+	var field *string
+	var inputLines []string
+lines:
+	for _, line := range strings.Split(text, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case hasField(trimmed, "Thought:"):
+			r.thought = value(trimmed, "Thought:")
+			field = &r.thought
+		case hasField(trimmed, "Action Input:"):
+			inputLines = []string{value(trimmed, "Action Input:")}
+			field = nil
+		case hasField(trimmed, "Action:"):
+			r.action = value(trimmed, "Action:")
+			field = &r.action
+		case hasField(trimmed, "Final Answer:"):
+			r.final = value(trimmed, "Final Answer:")
+			field = &r.final
+		case hasField(trimmed, "Observation:"):
+			// The model is hallucinating the tool's output; stop here
+			break lines
+		case inputLines != nil && field == nil:
+			inputLines = append(inputLines, line)
+		case field != nil:
+			*field = strings.TrimSpace(*field + "\n" + line)
+		}
+	}
+	r.action = strings.Trim(r.action, "` ")
+	r.rawInput = strings.TrimSpace(strings.Join(inputLines, "\n"))
+	raw := strings.TrimSuffix(strings.TrimPrefix(strings.TrimPrefix(r.rawInput, "```json"), "```"), "```")
+	r.input = map[string]any{}
+	if strings.TrimSpace(raw) != "" {
+		r.inputErr = json.Unmarshal([]byte(strings.TrimSpace(raw)), &r.input)
+	}
+	return r
+}
+
+func hasField(line, name string) bool {
+	return len(line) >= len(name) && strings.EqualFold(line[:len(name)], name)
+}
+
+func value(line, name string) string {
+	return strings.TrimSpace(line[len(name):])
+}
+
+func observe(result any) string {
+	var text string
+	switch v := result.(type) {
+	case string:
+		text = v
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			text = fmt.Sprint(v)
+		} else {
+			text = string(data)
+		}
+	}
+	if len(text) > maxObservation {
+		text = text[:maxObservation] + "… (truncated)"
+	}
+	return text
+}
@@ -0,0 +1,114 @@
+package react
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/tools"
+)
+
+// scripted replies with each of its replies in turn and records the
+// transcripts it was sent.
+type scripted struct {
+	core.ModelProvider
+	replies []string
+	prompts []core.Prompt
+}
+
+func (s *scripted) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	s.prompts = append(s.prompts, prompt)
+	reply := s.replies[0]
+	if len(s.replies) > 1 {
+		s.replies = s.replies[1:]
+	}
+	return core.Response{Content: reply}, nil
+}
+
+type adder struct{}
+
+func (adder) Name() string        { return "add" }
+func (adder) Description() string { return "Add a and b." }
+func (adder) Call(ctx context.Context, args map[string]any) (any, error) {
+	a, _ := args["a"].(float64)
+	b, _ := args["b"].(float64)
+	if a < 0 {
+		return nil, errors.New("negative input")
+	}
+	return map[string]float64{"sum": a + b}, nil
+}
+
+func TestRun(t *testing.T) {
+	llm := &scripted{replies: []string{
+		"Thought: I should add\nAction: `add`\nAction Input: ```json\n{\"a\": 2, \"b\": 3}\n```\nObservation: 6",
+		"Thought: Done\nFinal Answer: It is 5.\nReally.",
+	}}
+	e := &Executor{LLM: llm, Tools: map[string]tools.Tool{"add": adder{}}}
+	res, err := e.Run(context.Background(), "Be precise.", "What is 2+3?")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Answer != "It is 5.\nReally." {
+		t.Errorf("Answer = %q", res.Answer)
+	}
+	if len(res.Trajectory) != 2 || len(res.Invocations) != 1 {
+		t.Fatalf("trajectory = %+v, invocations = %+v", res.Trajectory, res.Invocations)
+	}
+	if step := res.Trajectory[0]; step.Action != "add" || step.Observation != `{"sum":5}` {
+		t.Errorf("first step = %+v", step)
+	}
+	if !strings.Contains(llm.prompts[0].System, "- add: Add a and b.") {
+		t.Errorf("system prompt lacks the tool list: %q", llm.prompts[0].System)
+	}
+	if !strings.Contains(llm.prompts[1].User, `Observation: {"sum":5}`) {
+		t.Errorf("second transcript lacks the real observation: %q", llm.prompts[1].User)
+	}
+}
+
+func TestRunObservesErrors(t *testing.T) {
+	llm := &scripted{replies: []string{
+		"Action: subtract\nAction Input: {}",
+		"Action: add\nAction Input: not json",
+		"Action: add\nAction Input: {\"a\": -1}",
+		"Final Answer: gave up",
+	}}
+	e := &Executor{LLM: llm, Tools: map[string]tools.Tool{"add": adder{}}}
+	res, err := e.Run(context.Background(), "", "task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var errs []string
+	for _, step := range res.Trajectory[:3] {
+		errs = append(errs, step.Error)
+	}
+	if !strings.Contains(errs[0], `unknown tool "subtract"`) || errs[1] == "" || !strings.Contains(errs[2], "negative input") {
+		t.Errorf("step errors = %q", errs)
+	}
+}
+
+func TestRunMaxSteps(t *testing.T) {
+	llm := &scripted{replies: []string{"Action: add\nAction Input: {}"}}
+	e := &Executor{LLM: llm, Tools: map[string]tools.Tool{"add": adder{}}, MaxSteps: 2}
+	res, err := e.Run(context.Background(), "", "task")
+	if !errors.Is(err, ErrMaxSteps) || len(res.Trajectory) != 2 {
+		t.Errorf("Run = %d steps, %v, want ErrMaxSteps after 2", len(res.Trajectory), err)
+	}
+}
+
+func TestRunPlainReply(t *testing.T) {
+	e := &Executor{LLM: &scripted{replies: []string{"  Just an answer.  "}}}
+	res, err := e.Run(context.Background(), "", "task")
+	if err != nil || res.Answer != "Just an answer." {
+		t.Errorf("Run = %q, %v", res.Answer, err)
+	}
+}
+
+func TestObserveTruncates(t *testing.T) {
+	got := observe(strings.Repeat("x", maxObservation+10))
+	if !strings.HasSuffix(got, "… (truncated)") || len(got) > maxObservation+len("… (truncated)") {
+		t.Errorf("observe = %d bytes", len(got))
+	}
+}
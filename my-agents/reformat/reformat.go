@@ -0,0 +1,137 @@
+// Package reformat re-runs only the formatter on a stored run's enhanced
+// content with other formatting options (length, reading level, format,
+// tone, locale), reusing the upstream agents' results instead of paying for
+// the whole pipeline again. Each re-format is a run of its own, recorded
+// with the run it came from.
+package reformat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/constraints"
+	"my-agents/history"
+	"my-agents/locale"
+)
+
+// DefaultAgent is the agent re-run when none is named.
+const DefaultAgent = "formatter"
+
+// Options are the formatting options of a re-format. Zero fields keep the
+// original request's option.
+type Options struct {
+	MaxWords     int     `json:"max_words,omitempty"`
+	ReadingLevel float64 `json:"reading_level,omitempty"`
+	Format       string  `json:"format,omitempty"` // "bullets" for a bullet list
+	Tone         string  `json:"tone,omitempty"`
+	Locale       string  `json:"locale,omitempty"`
+}
+
+// metadata renders the options as the event metadata the formatter reads.
+func (o Options) metadata() map[string]string {
+	meta := make(map[string]string)
+	if o.MaxWords > 0 {
+		meta[constraints.MaxWordsKey] = strconv.Itoa(o.MaxWords)
+	}
+	if o.ReadingLevel > 0 {
+		meta[constraints.ReadingLevelKey] = strconv.FormatFloat(o.ReadingLevel, 'g', -1, 64)
+	}
+	if o.Format != "" {
+		meta[constraints.FormatKey] = o.Format
+	}
+	if o.Tone != "" {
+		meta[constraints.ToneKey] = o.Tone
+	}
+	if o.Locale != "" {
+		meta[locale.MetadataKey] = o.Locale
+	}
+	return meta
+}
+
+// Reformatter re-runs a formatter agent on stored runs.
+type Reformatter struct {
+	recorder *history.Recorder
+	runner   core.Runner
+	agent    string
+}
+
+// New creates a reformatter emitting on runner, which must be started, and
+// reading runs from recorder's store. agent defaults to DefaultAgent.
+func New(recorder *history.Recorder, runner core.Runner, agent string) *Reformatter {
+	if agent == "" {
+		agent = DefaultAgent
+	}
+	return &Reformatter{recorder: recorder, runner: runner, agent: agent}
+}
+
+// Reformat formats run runID's upstream results again with opts and waits
+// for the new run. The formatter delivers the new response to its sinks as
+// it did the original.
+func (f *Reformatter) Reformat(ctx context.Context, runID string, opts Options) (history.FinalResult, error) {
+	run, err := f.recorder.Store().Get(ctx, runID)
+	if err != nil {
+		return history.FinalResult{}, fmt.Errorf("run %s: %w", runID, err)
+	}
+	event, err := history.RerunEvent(run, f.agent, opts.metadata())
+	if err != nil {
+		return history.FinalResult{}, err
+	}
+	return f.recorder.ProcessSync(ctx, f.runner, event)
+}
+
+// Handler serves re-formats, for mounting on the admin API under
+// "POST /admin/runs/{run}/reformat". The body holds the Options:
+//
+//	POST /admin/runs/{run}/reformat   {"max_words": 80, "tone": "casual"}
+func (f *Reformatter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /admin/runs/{run}/reformat", func(w http.ResponseWriter, r *http.Request) {
+		var opts Options
+		if err := json.NewDecoder(io.LimitReader(r.Body, 1<<16)).Decode(&opts); err != nil && !errors.Is(err, io.EOF) {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		result, err := f.Reformat(r.Context(), r.PathValue("run"), opts)
+		if result.RunID == "" {
+			status := http.StatusBadRequest
+			if errors.Is(err, history.ErrNotFound) {
+				status = http.StatusNotFound
+			}
+			writeError(w, status, err)
+			return
+		}
+		resp := map[string]any{
+			"run_id":         result.RunID,
+			"rerun_of":       r.PathValue("run"),
+			"status":         result.Status,
+			"final_response": result.Response,
+		}
+		if unmet, ok := result.State["constraint_violations"]; ok {
+			resp["constraint_violations"] = unmet
+		}
+		if err != nil {
+			resp["error"] = err.Error()
+			writeJSON(w, http.StatusBadGateway, resp)
+			return
+		}
+		writeJSON(w, http.StatusOK, resp)
+	})
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,161 @@
+package reformat
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/constraints"
+	"my-agents/history"
+	"my-agents/locale"
+)
+
+// runner runs the formatter inline on Emit, reporting to the recorder's
+// callbacks as the real runner would.
+type runner struct {
+	core.Runner
+	callbacks map[core.HookPoint]core.CallbackFunc
+	emitted   []core.Event
+	err       error
+}
+
+func (r *runner) RegisterCallback(hook core.HookPoint, name string, cb core.CallbackFunc) error {
+	if r.callbacks == nil {
+		r.callbacks = make(map[core.HookPoint]core.CallbackFunc)
+	}
+	r.callbacks[hook] = cb
+	return nil
+}
+
+func (r *runner) Emit(event core.Event) error {
+	r.emitted = append(r.emitted, event)
+	ctx := context.Background()
+	args := core.CallbackArgs{AgentID: event.GetTargetAgentID(), Event: event}
+	r.callbacks[core.HookBeforeEventHandling](ctx, args)
+	if r.err != nil {
+		args.Error = r.err
+	} else {
+		tone, _ := event.GetMetadataValue(constraints.ToneKey)
+		args.State = core.NewState()
+		args.State.Set("final_response", tone+": "+event.GetData()["enhanced"].(string))
+		args.State.Set("constraint_violations", []string{"max_words"})
+	}
+	r.callbacks[core.HookAfterEventHandling](ctx, args)
+	return nil
+}
+
+func reformatter(t *testing.T) (*Reformatter, *runner) {
+	t.Helper()
+	store := history.NewMemoryStore()
+	store.Save(context.Background(), &history.Run{
+		ID:        "run-1",
+		SessionID: "s1",
+		Status:    history.StatusCompleted,
+		Metadata:  map[string]string{core.RouteMetadataKey: "writer", constraints.ToneKey: "formal", constraints.MaxWordsKey: "200"},
+		Steps: []history.Step{
+			{Agent: "writer", Route: "formatter", Output: map[string]any{"enhanced": "Go is fast."}},
+			{Agent: "formatter", Output: map[string]any{"final_response": "formal: Go is fast."}},
+		},
+	})
+	recorder := history.NewRecorder(store)
+	r := &runner{}
+	if err := recorder.Register(r); err != nil {
+		t.Fatal(err)
+	}
+	return New(recorder, r, ""), r
+}
+
+func TestMetadata(t *testing.T) {
+	if meta := (Options{}).metadata(); len(meta) != 0 {
+		t.Errorf("zero options = %v", meta)
+	}
+	got := Options{MaxWords: 80, ReadingLevel: 6.5, Format: "bullets", Tone: "casual", Locale: "fr"}.metadata()
+	want := map[string]string{
+		constraints.MaxWordsKey:     "80",
+		constraints.ReadingLevelKey: "6.5",
+		constraints.FormatKey:       "bullets",
+		constraints.ToneKey:         "casual",
+		locale.MetadataKey:          "fr",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("metadata = %v, want %v", got, want)
+	}
+}
+
+func TestReformat(t *testing.T) {
+	f, r := reformatter(t)
+	ctx := context.Background()
+	result, err := f.Reformat(ctx, "run-1", Options{Tone: "casual"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Status != history.StatusCompleted || result.Response != "casual: Go is fast." {
+		t.Errorf("result = %+v", result)
+	}
+	if len(r.emitted) != 1 {
+		t.Fatalf("emitted %d events", len(r.emitted))
+	}
+	// Only the formatter runs, on the writer's output, keeping the options
+	// not overridden
+	event := r.emitted[0]
+	meta := event.GetMetadata()
+	if event.GetTargetAgentID() != DefaultAgent || meta[history.RerunOfKey] != "run-1" || meta[constraints.MaxWordsKey] != "200" {
+		t.Errorf("event to %s with metadata %v", event.GetTargetAgentID(), meta)
+	}
+	if result.RunID == "run-1" {
+		t.Error("re-format recorded as the original run")
+	}
+	if _, err := f.recorder.Store().Get(ctx, result.RunID); err != nil {
+		t.Errorf("re-format not recorded: %v", err)
+	}
+
+	if _, err := f.Reformat(ctx, "run-9", Options{}); !errors.Is(err, history.ErrNotFound) {
+		t.Errorf("unknown run: %v", err)
+	}
+	if _, err := New(f.recorder, r, "editor").Reformat(ctx, "run-1", Options{}); err == nil {
+		t.Error("re-ran an agent the run never reached")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	f, r := reformatter(t)
+	h := f.Handler()
+	post := func(run, body string) (int, map[string]any) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/admin/runs/"+run+"/reformat", strings.NewReader(body)))
+		var resp map[string]any
+		if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return w.Code, resp
+	}
+
+	code, resp := post("run-1", `{"tone": "casual"}`)
+	if code != http.StatusOK || resp["rerun_of"] != "run-1" || resp["final_response"] != "casual: Go is fast." || resp["constraint_violations"] == nil {
+		t.Errorf("reformat: %d %v", code, resp)
+	}
+	// No body keeps every option
+	if code, resp := post("run-1", ""); code != http.StatusOK || resp["final_response"] != "formal: Go is fast." {
+		t.Errorf("empty body: %d %v", code, resp)
+	}
+	if code, _ := post("run-1", `{"max_words": "many"}`); code != http.StatusBadRequest {
+		t.Errorf("bad body: status %d", code)
+	}
+	if code, _ := post("run-9", ""); code != http.StatusNotFound {
+		t.Errorf("unknown run: status %d", code)
+	}
+
+	r.err = errors.New("model down")
+	code, resp = post("run-1", "")
+	if code != http.StatusBadGateway || resp["status"] != history.StatusFailed || !strings.Contains(resp["error"].(string), "model down") {
+		t.Errorf("failed run: %d %v", code, resp)
+	}
+}
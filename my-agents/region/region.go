@@ -0,0 +1,391 @@
+// Package region spreads a provider's calls over its regional endpoints —
+// say Azure OpenAI in East US and West Europe, or Bedrock in two AWS
+// regions — sending each call to the healthy region that has been
+// answering fastest, and failing over to the next when one is rate
+// limited, times out, fails with a server error or refuses the call, as
+// a region whose key or deployment is wrong does.
+package region
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/fallback"
+	"my-agents/retry"
+)
+
+// Defaults for the region settings.
+const (
+	DefaultCooldown = 30 * time.Second
+	// remeasureAfter is how old a region's latency may get before the
+	// region counts as unmeasured again and takes the next call, so a
+	// region that was slow once isn't avoided for good.
+	remeasureAfter = 5 * time.Minute
+	// smoothing weighs each answered call in a region's moving average.
+	smoothing = 0.3
+)
+
+// Endpoint is a [providers.<name>.regions.<region>] table: the settings
+// that differ in the region from the provider table's own.
+type Endpoint struct {
+	Endpoint string `toml:"endpoint"` // Azure OpenAI
+	BaseURL  string `toml:"base_url"`
+	APIKey   string `toml:"api_key"`  // default the provider's
+	Region   string `toml:"region"`   // Bedrock
+	Location string `toml:"location"` // Gemini on Vertex AI
+}
+
+// Options are the region settings of a [providers.<name>] table, or of the
+// [llm] table when its type is one implemented here. With regions set,
+// calls go to them only; the table's own endpoint settings are what they
+// override.
+//
+//	[providers.azure]
+//	type = "azure"
+//	model = "gpt-4o"
+//	region_timeout = "20s"
+//	[providers.azure.regions.eastus]
+//	endpoint = "https://acme-eastus.openai.azure.com"
+//	[providers.azure.regions.westeurope]
+//	endpoint = "https://acme-weu.openai.azure.com"
+//	api_key = "..."
+type Options struct {
+	Regions map[string]Endpoint `toml:"regions"`
+	// RegionTimeout bounds each region's call, or its wait for a stream's
+	// first token; one that takes longer is failed over. Empty waits as
+	// long as the caller does.
+	RegionTimeout string `toml:"region_timeout"`
+	// RegionCooldown is how long a region that failed is skipped (default
+	// 30s). When every region is cooling down they are all tried anyway.
+	RegionCooldown string `toml:"region_cooldown"`
+}
+
+// Link is one region's provider.
+type Link struct {
+	Region string
+	LLM    core.ModelProvider
+}
+
+// Router calls the fastest healthy region of a provider.
+type Router struct {
+	provider string
+	links    []Link
+	timeout  time.Duration
+	cooldown time.Duration
+	metrics  *Metrics
+}
+
+// New creates a router over links, the regions of provider, keeping their
+// health and latency in metrics (a fresh set when nil). Routers rebuilt
+// for the same provider, e.g. when its key rotates, share what metrics
+// learned.
+func New(provider string, links []Link, opts Options, metrics *Metrics) (*Router, error) {
+	if len(links) == 0 {
+		return nil, fmt.Errorf("provider %s has no regions", provider)
+	}
+	if metrics == nil {
+		metrics = NewMetrics()
+	}
+	r := &Router{provider: provider, links: links, cooldown: DefaultCooldown, metrics: metrics}
+	var err error
+	if opts.RegionTimeout != "" {
+		if r.timeout, err = time.ParseDuration(opts.RegionTimeout); err != nil {
+			return nil, fmt.Errorf("provider %s: region_timeout: %w", provider, err)
+		}
+	}
+	if opts.RegionCooldown != "" {
+		if r.cooldown, err = time.ParseDuration(opts.RegionCooldown); err != nil {
+			return nil, fmt.Errorf("provider %s: region_cooldown: %w", provider, err)
+		}
+	}
+	metrics.register(provider, links, r.cooldown)
+	return r, nil
+}
+
+// Exhausted is the error of a call every region failed.
+type Exhausted struct {
+	Provider string
+	Errs     map[string]error // by region
+	Last     error
+}
+
+func (e *Exhausted) Error() string {
+	return fmt.Sprintf("all %d regions of provider %s failed, the last with: %v", len(e.Errs), e.Provider, e.Last)
+}
+
+func (e *Exhausted) Unwrap() error { return e.Last }
+
+// failover classifies the errors another region may not return: the
+// transient ones, and a region refusing the call (401, 403) or not having
+// the model deployed (404), which its own key or deployment explains.
+var failover = retry.Policy{RetryOn: []string{
+	"status 401", "status 403", "status 404",
+	"unauthorized", "forbidden", "deploymentnotfound",
+}}
+
+// try calls the regions, fastest first, until one answers or fails with an
+// error another region wouldn't fix. call returns when the region has
+// answered, which is when its latency is taken. A call the caller gave up
+// on is no region's fault, and counts against none.
+func (r *Router) try(ctx context.Context, call func(ctx context.Context, llm core.ModelProvider) error) error {
+	errs := make(map[string]error)
+	var last error
+	for i, link := range r.order() {
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if r.timeout > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, r.timeout)
+		}
+		start := time.Now()
+		err := call(attemptCtx, link.LLM)
+		cancel()
+		if err == nil {
+			r.metrics.answered(r.provider, link.Region, time.Since(start))
+			if i > 0 {
+				core.Logger().Info().Str("provider", r.provider).Str("region", link.Region).Int("attempt", i+1).Msg("Failover region answered")
+			}
+			return nil
+		}
+		if ctx.Err() != nil {
+			return err
+		}
+		next := failover.Transient(ctx, err)
+		r.metrics.failed(r.provider, link.Region, err, next)
+		if !next {
+			return err
+		}
+		core.Logger().Warn().Str("provider", r.provider).Str("region", link.Region).Err(err).Msg("Region failed; failing over")
+		errs[link.Region], last = err, err
+	}
+	return &Exhausted{Provider: r.provider, Errs: errs, Last: last}
+}
+
+// order returns the regions to try: the healthy ones, unmeasured first and
+// then by latency, followed by those cooling down, longest down first.
+func (r *Router) order() []Link {
+	m := r.metrics
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	type ranked struct {
+		link     Link
+		down     bool
+		downAt   time.Time
+		latency  time.Duration
+		measured bool
+	}
+	ranks := make([]ranked, len(r.links))
+	for i, link := range r.links {
+		s := m.regions[r.provider][link.Region]
+		ranks[i] = ranked{
+			link:     link,
+			down:     !s.downAt.IsZero() && now.Sub(s.downAt) < r.cooldown,
+			downAt:   s.downAt,
+			latency:  s.latency,
+			measured: s.latency > 0 && now.Sub(s.measuredAt) < remeasureAfter,
+		}
+	}
+	slices.SortStableFunc(ranks, func(a, b ranked) int {
+		switch {
+		case a.down != b.down:
+			if a.down {
+				return 1
+			}
+			return -1
+		case a.down:
+			return a.downAt.Compare(b.downAt)
+		case a.measured != b.measured:
+			if a.measured {
+				return 1
+			}
+			return -1
+		}
+		return cmp.Compare(a.latency, b.latency)
+	})
+	links := make([]Link, len(ranks))
+	for i, rk := range ranks {
+		links[i] = rk.link
+	}
+	return links
+}
+
+func (r *Router) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	var resp core.Response
+	err := r.try(ctx, func(ctx context.Context, llm core.ModelProvider) error {
+		var err error
+		resp, err = llm.Call(ctx, prompt)
+		return err
+	})
+	return resp, err
+}
+
+func (r *Router) Embeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	var vectors [][]float64
+	err := r.try(ctx, func(ctx context.Context, llm core.ModelProvider) error {
+		var err error
+		vectors, err = llm.Embeddings(ctx, texts)
+		return err
+	})
+	return vectors, err
+}
+
+// Stream fails over until a region streams its first token, which is when
+// its latency is taken; a stream that breaks after that is the caller's
+// to handle, as tokens are already out.
+func (r *Router) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	var out chan core.Token
+	err := r.try(ctx, func(attemptCtx context.Context, llm core.ModelProvider) error {
+		// The stream outlives the attempt: only its start is timed
+		streamCtx, cancel := context.WithCancel(ctx)
+		tokens, err := llm.Stream(streamCtx, prompt)
+		if err != nil {
+			cancel()
+			return err
+		}
+		var first core.Token
+		var ok bool
+		select {
+		case first, ok = <-tokens:
+		case <-attemptCtx.Done():
+			cancel()
+			return attemptCtx.Err()
+		}
+		if ok && first.Error != nil {
+			cancel()
+			return first.Error
+		}
+		out = make(chan core.Token)
+		go func() {
+			defer cancel()
+			defer close(out)
+			if ok {
+				fallback.Forward(ctx, out, first, tokens)
+			}
+		}()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Stats are a region's routing metrics since the process started.
+type Stats struct {
+	Calls     int `json:"calls"` // answered
+	Errors    int `json:"errors"`
+	Failovers int `json:"failovers"` // calls moved off the region
+	// LatencyMillis is the moving average of the region's answered calls,
+	// to the first token for streams.
+	LatencyMillis int64     `json:"latency_ms"`
+	Healthy       bool      `json:"healthy"`
+	DownSince     time.Time `json:"down_since,omitzero"`
+	LastError     string    `json:"last_error,omitempty"`
+}
+
+// Metrics keeps the health and latency of every routed provider's regions.
+type Metrics struct {
+	mu       sync.Mutex
+	cooldown map[string]time.Duration     // by provider
+	regions  map[string]map[string]*state // by provider and region
+}
+
+type state struct {
+	Stats
+	latency    time.Duration
+	measuredAt time.Time
+	downAt     time.Time
+}
+
+// NewMetrics creates an empty set of region metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{cooldown: make(map[string]time.Duration), regions: make(map[string]map[string]*state)}
+}
+
+// register lists provider's regions, so they show before their first call.
+func (m *Metrics) register(provider string, links []Link, cooldown time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.cooldown[provider] = cooldown
+	for _, link := range links {
+		m.stateOf(provider, link.Region)
+	}
+}
+
+// stateOf returns a region's state, creating it. m.mu must be held.
+func (m *Metrics) stateOf(provider, region string) *state {
+	regions, ok := m.regions[provider]
+	if !ok {
+		regions = make(map[string]*state)
+		m.regions[provider] = regions
+	}
+	s, ok := regions[region]
+	if !ok {
+		s = &state{}
+		regions[region] = s
+	}
+	return s
+}
+
+func (m *Metrics) answered(provider, region string, latency time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.stateOf(provider, region)
+	s.Calls++
+	s.downAt = time.Time{}
+	if s.latency == 0 || time.Since(s.measuredAt) >= remeasureAfter {
+		s.latency = latency
+	} else {
+		s.latency = time.Duration(smoothing*float64(latency) + (1-smoothing)*float64(s.latency))
+	}
+	s.measuredAt = time.Now()
+}
+
+func (m *Metrics) failed(provider, region string, err error, failover bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := m.stateOf(provider, region)
+	s.Errors++
+	s.LastError = err.Error()
+	if failover {
+		s.Failovers++
+		s.downAt = time.Now()
+	}
+}
+
+// Snapshot returns each routed provider's region stats.
+func (m *Metrics) Snapshot() map[string]map[string]Stats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	out := make(map[string]map[string]Stats, len(m.regions))
+	for provider, regions := range m.regions {
+		out[provider] = make(map[string]Stats, len(regions))
+		for name, s := range regions {
+			st := s.Stats
+			st.LatencyMillis = s.latency.Milliseconds()
+			st.Healthy = s.downAt.IsZero() || time.Since(s.downAt) >= m.cooldown[provider]
+			if !st.Healthy {
+				st.DownSince = s.downAt
+			}
+			out[provider][name] = st
+		}
+	}
+	return out
+}
+
+// Handler serves the snapshot, for mounting on the admin API:
+//
+//	GET /admin/providers/regions   each region's calls, errors, latency and health
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"providers": m.Snapshot()})
+	})
+}
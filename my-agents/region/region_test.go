@@ -0,0 +1,210 @@
+package region
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// fake answers as its region, or fails with err.
+type fake struct {
+	core.ModelProvider
+	region string
+	err    error
+	delay  time.Duration
+	calls  int
+}
+
+func (f *fake) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	f.calls++
+	select {
+	case <-time.After(f.delay):
+	case <-ctx.Done():
+		return core.Response{}, ctx.Err()
+	}
+	if f.err != nil {
+		return core.Response{}, f.err
+	}
+	return core.Response{Content: f.region}, nil
+}
+
+func newRouter(t *testing.T, opts Options, fakes ...*fake) *Router {
+	t.Helper()
+	links := make([]Link, len(fakes))
+	for i, f := range fakes {
+		links[i] = Link{Region: f.region, LLM: f}
+	}
+	r, err := New("azure", links, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func regions(links []Link) []string {
+	out := make([]string, len(links))
+	for i, link := range links {
+		out[i] = link.Region
+	}
+	return out
+}
+
+func TestOrderPrefersUnmeasuredThenFastest(t *testing.T) {
+	r := newRouter(t, Options{}, &fake{region: "slow"}, &fake{region: "fast"}, &fake{region: "new"})
+	r.metrics.answered("azure", "slow", 300*time.Millisecond)
+	r.metrics.answered("azure", "fast", 50*time.Millisecond)
+
+	got := regions(r.order())
+	want := []string{"new", "fast", "slow"}
+	if !slices.Equal(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
+
+func TestOrderPutsFailedRegionsLast(t *testing.T) {
+	r := newRouter(t, Options{}, &fake{region: "a"}, &fake{region: "b"}, &fake{region: "c"})
+	for _, region := range []string{"a", "b", "c"} {
+		r.metrics.answered("azure", region, time.Duration(len(region))*time.Millisecond)
+	}
+	r.metrics.failed("azure", "a", errors.New("status 429"), true)
+	time.Sleep(time.Millisecond)
+	r.metrics.failed("azure", "b", errors.New("status 503"), true)
+
+	// Cooling down regions come last, the one down longest first
+	got := regions(r.order())
+	want := []string{"c", "a", "b"}
+	if !slices.Equal(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
+
+func TestCooldownEnds(t *testing.T) {
+	r := newRouter(t, Options{RegionCooldown: "1ms"}, &fake{region: "a"}, &fake{region: "b"})
+	r.metrics.answered("azure", "a", time.Millisecond)
+	r.metrics.answered("azure", "b", 2*time.Millisecond)
+	r.metrics.failed("azure", "a", errors.New("status 429"), true)
+	time.Sleep(5 * time.Millisecond)
+
+	if got := regions(r.order()); got[0] != "a" {
+		t.Errorf("order = %v, want a first once its cooldown ended", got)
+	}
+	if !r.metrics.Snapshot()["azure"]["a"].Healthy {
+		t.Error("a is unhealthy after its cooldown")
+	}
+}
+
+func TestCallFailsOver(t *testing.T) {
+	a := &fake{region: "a", err: errors.New("azure: status 429: too many requests")}
+	b := &fake{region: "b"}
+	r := newRouter(t, Options{}, a, b)
+
+	resp, err := r.Call(context.Background(), core.Prompt{User: "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != "b" {
+		t.Errorf("answered by %q, want b", resp.Content)
+	}
+	stats := r.metrics.Snapshot()["azure"]
+	if stats["a"].Healthy || stats["a"].Failovers != 1 {
+		t.Errorf("a = %+v, want down with one failover", stats["a"])
+	}
+	if stats["b"].Calls != 1 {
+		t.Errorf("b answered %d calls, want 1", stats["b"].Calls)
+	}
+
+	// The next call skips a while it cools down
+	if _, err := r.Call(context.Background(), core.Prompt{User: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if a.calls != 1 {
+		t.Errorf("a called %d times, want 1", a.calls)
+	}
+}
+
+func TestCallFailsOverRefusedRegion(t *testing.T) {
+	a := &fake{region: "a", err: errors.New("azure: status 404: DeploymentNotFound")}
+	b := &fake{region: "b"}
+	r := newRouter(t, Options{}, a, b)
+
+	if resp, err := r.Call(context.Background(), core.Prompt{}); err != nil || resp.Content != "b" {
+		t.Errorf("Call = %q, %v; want b's answer", resp.Content, err)
+	}
+}
+
+func TestCallKeepsRequestErrors(t *testing.T) {
+	bad := errors.New("azure: status 400: context length exceeded")
+	a := &fake{region: "a", err: bad}
+	b := &fake{region: "b"}
+	r := newRouter(t, Options{}, a, b)
+
+	if _, err := r.Call(context.Background(), core.Prompt{}); !errors.Is(err, bad) {
+		t.Errorf("Call error = %v, want %v", err, bad)
+	}
+	if b.calls != 0 {
+		t.Error("a request error was failed over")
+	}
+	if !r.metrics.Snapshot()["azure"]["a"].Healthy {
+		t.Error("a request error took the region down")
+	}
+}
+
+func TestCallExhausted(t *testing.T) {
+	last := errors.New("status 503")
+	r := newRouter(t, Options{},
+		&fake{region: "a", err: errors.New("status 502")},
+		&fake{region: "b", err: last})
+
+	_, err := r.Call(context.Background(), core.Prompt{})
+	var exhausted *Exhausted
+	if !errors.As(err, &exhausted) {
+		t.Fatalf("Call error = %v, want *Exhausted", err)
+	}
+	if len(exhausted.Errs) != 2 || !errors.Is(err, last) {
+		t.Errorf("Exhausted = %+v, want both regions' errors and the last unwrapped", exhausted)
+	}
+}
+
+func TestRegionTimeoutFailsOver(t *testing.T) {
+	slow := &fake{region: "slow", delay: time.Second}
+	fast := &fake{region: "fast"}
+	r := newRouter(t, Options{RegionTimeout: "10ms"}, slow, fast)
+
+	resp, err := r.Call(context.Background(), core.Prompt{})
+	if err != nil || resp.Content != "fast" {
+		t.Errorf("Call = %q, %v; want fast's answer", resp.Content, err)
+	}
+}
+
+func TestCallerCancelCountsAgainstNoRegion(t *testing.T) {
+	a := &fake{region: "a", delay: time.Second}
+	r := newRouter(t, Options{}, a, &fake{region: "b"})
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if _, err := r.Call(ctx, core.Prompt{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Call error = %v, want the caller's deadline", err)
+	}
+	if st := r.metrics.Snapshot()["azure"]["a"]; !st.Healthy || st.Errors != 0 {
+		t.Errorf("a = %+v, want healthy without errors", st)
+	}
+}
+
+func TestNewRejectsBadSettings(t *testing.T) {
+	links := []Link{{Region: "a", LLM: &fake{region: "a"}}}
+	for name, opts := range map[string]Options{
+		"timeout":  {RegionTimeout: "soon"},
+		"cooldown": {RegionCooldown: "30"},
+	} {
+		if _, err := New("azure", links, opts, nil); err == nil {
+			t.Errorf("%s: New accepted %+v", name, opts)
+		}
+	}
+	if _, err := New("azure", nil, Options{}, nil); err == nil {
+		t.Error("New accepted a provider without regions")
+	}
+}
@@ -0,0 +1,148 @@
+// Package resp is a minimal Redis client: one connection speaking RESP, the
+// Redis protocol, which is all the event bus and the state store need
+// without a client library.
+package resp
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error is an error reply.
+type Error string
+
+func (e Error) Error() string { return "redis: " + string(e) }
+
+// CheckURL rejects URLs that aren't redis:// or rediss:// (TLS).
+func CheckURL(u *url.URL) error {
+	switch u.Scheme {
+	case "redis", "rediss":
+		return nil
+	}
+	return fmt.Errorf("redis url scheme must be redis or rediss, not %q", u.Scheme)
+}
+
+// Conn is a connection to a Redis server. It is not safe for concurrent
+// use.
+type Conn struct {
+	c net.Conn
+	r *bufio.Reader
+}
+
+// Dial connects to the server at u, redis://[user:password@]host[:port][/db]
+// or rediss:// for TLS, authenticating and selecting the database the URL
+// names.
+func Dial(ctx context.Context, u *url.URL) (*Conn, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	var d net.Dialer
+	c, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	if u.Scheme == "rediss" {
+		c = tls.Client(c, &tls.Config{ServerName: u.Hostname()})
+	}
+	conn := &Conn{c: c, r: bufio.NewReader(c)}
+	if deadline, ok := ctx.Deadline(); ok {
+		c.SetDeadline(deadline)
+		defer c.SetDeadline(time.Time{})
+	}
+	if pass, ok := u.User.Password(); ok {
+		args := []string{"AUTH", pass}
+		if name := u.User.Username(); name != "" {
+			args = []string{"AUTH", name, pass}
+		}
+		if _, err := conn.Do(args...); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" && db != "0" {
+		if _, err := conn.Do("SELECT", db); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// SetDeadline bounds the commands run until it is next set; a command past
+// it fails with a timeout. The zero time lifts it.
+func (c *Conn) SetDeadline(t time.Time) error { return c.c.SetDeadline(t) }
+
+// Close closes the connection, failing a command blocked on it.
+func (c *Conn) Close() error { return c.c.Close() }
+
+// Do sends a command and reads its reply: a string, an int64, nil, a
+// []any of replies or an Error.
+func (c *Conn) Do(args ...string) (any, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%d\r\n", len(args))
+	for _, a := range args {
+		fmt.Fprintf(&sb, "$%d\r\n%s\r\n", len(a), a)
+	}
+	if _, err := io.WriteString(c.c, sb.String()); err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	reply, err := c.read()
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := reply.(Error); ok {
+		return nil, e
+	}
+	return reply, nil
+}
+
+func (c *Conn) read() (any, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("redis: empty reply")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return Error(line[1:]), nil
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, fmt.Errorf("redis: %w", err)
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		items := make([]any, n)
+		for i := range items {
+			if items[i], err = c.read(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, fmt.Errorf("redis: unexpected reply %q", line)
+}
@@ -0,0 +1,207 @@
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// server answers each command with the raw RESP reply returns.
+type server struct {
+	url   *url.URL
+	reply func(args []string) string
+
+	mu       sync.Mutex
+	commands []string
+}
+
+func newServer(t *testing.T, reply func(args []string) string) *server {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s := &server{url: &url.URL{Scheme: "redis", Host: l.Addr().String()}, reply: reply}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+func (s *server) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		var n int
+		if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+			return
+		}
+		args := make([]string, n)
+		for i := range args {
+			var size int
+			if _, err := fmt.Fscanf(r, "$%d\r\n", &size); err != nil {
+				return
+			}
+			buf := make([]byte, size+2)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			args[i] = string(buf[:size])
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, strings.Join(args, " "))
+		s.mu.Unlock()
+		if _, err := io.WriteString(c, s.reply(args)); err != nil {
+			return
+		}
+	}
+}
+
+func (s *server) sent() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.commands...)
+}
+
+func dial(t *testing.T, u *url.URL) *Conn {
+	t.Helper()
+	c, err := Dial(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestCheckURL(t *testing.T) {
+	for _, raw := range []string{"redis://localhost", "rediss://cache.example.com:6380/2"} {
+		u, _ := url.Parse(raw)
+		if err := CheckURL(u); err != nil {
+			t.Errorf("%s: %v", raw, err)
+		}
+	}
+	u, _ := url.Parse("http://localhost:6379")
+	if err := CheckURL(u); err == nil {
+		t.Error("http url accepted")
+	}
+}
+
+func TestDial(t *testing.T) {
+	s := newServer(t, func(args []string) string { return "+OK\r\n" })
+	u := *s.url
+	u.User = url.UserPassword("app", "secret")
+	u.Path = "/3"
+	dial(t, &u)
+	u.User = url.UserPassword("", "secret")
+	u.Path = "/0"
+	dial(t, &u)
+	want := []string{"AUTH app secret", "SELECT 3", "AUTH secret"}
+	if got := s.sent(); !reflect.DeepEqual(got, want) {
+		t.Errorf("sent %q, want %q", got, want)
+	}
+
+	denied := newServer(t, func(args []string) string { return "-WRONGPASS invalid password\r\n" })
+	u = *denied.url
+	u.User = url.UserPassword("", "wrong")
+	if _, err := Dial(context.Background(), &u); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Errorf("wrong password: %v", err)
+	}
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	l.Close()
+	if _, err := Dial(context.Background(), &url.URL{Scheme: "redis", Host: l.Addr().String()}); err == nil {
+		t.Error("dialed a closed port")
+	}
+}
+
+func TestDo(t *testing.T) {
+	replies := map[string]string{
+		"PING":   "+PONG\r\n",
+		"INCR":   ":42\r\n",
+		"GET":    "$5\r\nhello\r\n",
+		"EMPTY":  "$0\r\n\r\n",
+		"NONE":   "$-1\r\n",
+		"LIST":   "*3\r\n$1\r\na\r\n:1\r\n*1\r\n+b\r\n",
+		"NOLIST": "*-1\r\n",
+		"FAIL":   "-ERR unknown command\r\n",
+	}
+	s := newServer(t, func(args []string) string { return replies[args[0]] })
+	c := dial(t, s.url)
+	tests := []struct {
+		cmd  string
+		want any
+	}{
+		{"PING", "PONG"},
+		{"INCR", int64(42)},
+		{"GET", "hello"},
+		{"EMPTY", ""},
+		{"NONE", nil},
+		{"LIST", []any{"a", int64(1), []any{"b"}}},
+		{"NOLIST", nil},
+	}
+	for _, tt := range tests {
+		got, err := c.Do(tt.cmd, "key")
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %#v, %v; want %#v", tt.cmd, got, err, tt.want)
+		}
+	}
+	_, err := c.Do("FAIL")
+	var e Error
+	if !errors.As(err, &e) || e != "ERR unknown command" || err.Error() != "redis: ERR unknown command" {
+		t.Errorf("error reply = %v", err)
+	}
+	// The connection is still usable after an error reply
+	if got, err := c.Do("PING"); got != "PONG" || err != nil {
+		t.Errorf("ping after an error = %v, %v", got, err)
+	}
+}
+
+func TestDoUnexpectedReply(t *testing.T) {
+	s := newServer(t, func(args []string) string { return "?what\r\n" })
+	if _, err := dial(t, s.url).Do("PING"); err == nil || !strings.Contains(err.Error(), "unexpected reply") {
+		t.Errorf("unexpected reply: %v", err)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	// The server answers nothing but AUTH, so a command waits for its reply
+	// until the deadline
+	s := newServer(t, func(args []string) string {
+		if args[0] == "AUTH" {
+			return "+OK\r\n"
+		}
+		return ""
+	})
+	c := dial(t, s.url)
+	c.SetDeadline(time.Now().Add(20 * time.Millisecond))
+	var ne net.Error
+	if _, err := c.Do("PING"); !errors.As(err, &ne) || !ne.Timeout() {
+		t.Errorf("past the deadline: %v", err)
+	}
+
+	// The dial context's deadline bounds the handshake
+	u := *s.url
+	u.User = url.UserPassword("", "secret")
+	u.Path = "/1"
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := Dial(ctx, &u); err == nil || time.Since(start) > time.Second {
+		t.Errorf("handshake past the deadline: %v after %s", err, time.Since(start))
+	}
+}
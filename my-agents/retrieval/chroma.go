@@ -0,0 +1,206 @@
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Chroma keeps a collection's chunks in a Chroma collection over its v2 REST
+// API, in the default tenant and database. The collection is created with
+// cosine distance when first used.
+type Chroma struct {
+	URL        string // e.g. http://localhost:8000
+	Collection string
+	APIKey     string
+
+	mu sync.Mutex
+	id string // the collection's ID, once looked up
+}
+
+// Metadata keys holding a chunk's own fields; its metadata is stored next
+// to them.
+const (
+	chromaDocumentKey = "_document_id"
+	chromaSourceKey   = "_source"
+)
+
+func (c *Chroma) header() http.Header {
+	h := http.Header{}
+	if c.APIKey != "" {
+		h.Set("X-Chroma-Token", c.APIKey)
+	}
+	return h
+}
+
+// endpoint returns the URL of the collection operation op, getting or
+// creating the collection first.
+func (c *Chroma) endpoint(ctx context.Context, op string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	collections := strings.TrimSuffix(c.URL, "/") + "/api/v2/tenants/default_tenant/databases/default_database/collections"
+	if c.id == "" {
+		var out struct {
+			ID string `json:"id"`
+		}
+		err := call(ctx, http.MethodPost, collections, c.header(), map[string]any{
+			"name":          c.Collection,
+			"get_or_create": true,
+			"metadata":      map[string]string{"hnsw:space": "cosine"},
+		}, &out)
+		if err != nil {
+			return "", fmt.Errorf("chroma collection %s: %w", c.Collection, err)
+		}
+		c.id = out.ID
+	}
+	return collections + "/" + url.PathEscape(c.id) + "/" + op, nil
+}
+
+func (c *Chroma) Upsert(ctx context.Context, chunks []Chunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	endpoint, err := c.endpoint(ctx, "upsert")
+	if err != nil {
+		return err
+	}
+	ids := make([]string, len(chunks))
+	vectors := make([][]float32, len(chunks))
+	documents := make([]string, len(chunks))
+	metadatas := make([]map[string]string, len(chunks))
+	for i, ch := range chunks {
+		ids[i], vectors[i], documents[i] = ch.ID, ch.Vector, ch.Content
+		meta := map[string]string{chromaDocumentKey: ch.DocumentID, chromaSourceKey: ch.Source}
+		for k, v := range ch.Metadata {
+			meta[k] = v
+		}
+		metadatas[i] = meta
+	}
+	return call(ctx, http.MethodPost, endpoint, c.header(),
+		map[string]any{"ids": ids, "embeddings": vectors, "documents": documents, "metadatas": metadatas}, nil)
+}
+
+func (c *Chroma) Search(ctx context.Context, vector []float32, k int, filter Filter) ([]Match, error) {
+	endpoint, err := c.endpoint(ctx, "query")
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		IDs       [][]string            `json:"ids"`
+		Documents [][]string            `json:"documents"`
+		Metadatas [][]map[string]string `json:"metadatas"`
+		Distances [][]float64           `json:"distances"`
+	}
+	req := map[string]any{
+		"query_embeddings": [][]float32{vector},
+		"n_results":        k,
+		"include":          []string{"documents", "metadatas", "distances"},
+	}
+	var where []map[string]any
+	for _, cond := range filter.Exact() {
+		key := cond.Key
+		switch key {
+		case "source":
+			key = chromaSourceKey
+		case "document_id":
+			key = chromaDocumentKey
+		}
+		// $nin would also drop chunks without the key; those are left
+		// to the retriever
+		if cond.Op != OpNe {
+			where = append(where, map[string]any{key: map[string]any{"$in": cond.Values}})
+		}
+	}
+	switch len(where) {
+	case 0:
+	case 1:
+		req["where"] = where[0]
+	default:
+		req["where"] = map[string]any{"$and": where}
+	}
+	err = call(ctx, http.MethodPost, endpoint, c.header(), req, &out)
+	if err != nil {
+		return nil, err
+	}
+	if len(out.IDs) == 0 {
+		return nil, nil
+	}
+	matches := make([]Match, len(out.IDs[0]))
+	for i, id := range out.IDs[0] {
+		m := Match{Chunk: Chunk{ID: id}}
+		if len(out.Documents) > 0 && i < len(out.Documents[0]) {
+			m.Content = out.Documents[0][i]
+		}
+		if len(out.Distances) > 0 && i < len(out.Distances[0]) {
+			m.Score = 1 - out.Distances[0][i]
+		}
+		if len(out.Metadatas) > 0 && i < len(out.Metadatas[0]) {
+			chromaMetadata(&m.Chunk, out.Metadatas[0][i])
+		}
+		matches[i] = m
+	}
+	return matches, nil
+}
+
+func (c *Chroma) Document(ctx context.Context, documentID string) ([]Chunk, error) {
+	endpoint, err := c.endpoint(ctx, "get")
+	if err != nil {
+		return nil, err
+	}
+	var out struct {
+		IDs        []string            `json:"ids"`
+		Documents  []string            `json:"documents"`
+		Metadatas  []map[string]string `json:"metadatas"`
+		Embeddings [][]float32         `json:"embeddings"`
+	}
+	err = call(ctx, http.MethodPost, endpoint, c.header(), map[string]any{
+		"where":   map[string]string{chromaDocumentKey: documentID},
+		"include": []string{"documents", "metadatas", "embeddings"},
+	}, &out)
+	if err != nil {
+		return nil, err
+	}
+	chunks := make([]Chunk, len(out.IDs))
+	for i, id := range out.IDs {
+		chunks[i].ID = id
+		if i < len(out.Documents) {
+			chunks[i].Content = out.Documents[i]
+		}
+		if i < len(out.Metadatas) {
+			chromaMetadata(&chunks[i], out.Metadatas[i])
+		}
+		if i < len(out.Embeddings) {
+			chunks[i].Vector = out.Embeddings[i]
+		}
+	}
+	return chunks, nil
+}
+
+// chromaMetadata sets the chunk's own fields and metadata from the
+// metadata Chroma keeps them in.
+func chromaMetadata(c *Chunk, meta map[string]string) {
+	for k, v := range meta {
+		switch k {
+		case chromaDocumentKey:
+			c.DocumentID = v
+		case chromaSourceKey:
+			c.Source = v
+		default:
+			if c.Metadata == nil {
+				c.Metadata = make(map[string]string)
+			}
+			c.Metadata[k] = v
+		}
+	}
+}
+
+func (c *Chroma) DeleteDocument(ctx context.Context, documentID string) error {
+	endpoint, err := c.endpoint(ctx, "delete")
+	if err != nil {
+		return err
+	}
+	return call(ctx, http.MethodPost, endpoint, c.header(), map[string]any{"where": map[string]string{chromaDocumentKey: documentID}}, nil)
+}
@@ -0,0 +1,75 @@
+package retrieval
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Chunker splits text into chunks of at most Size characters. Each chunk
+// after the first starts Overlap characters before the one before it ended,
+// so a passage cut at a boundary is whole in one of them.
+type Chunker struct {
+	Size    int
+	Overlap int
+}
+
+// Split returns the chunks of text. Chunks end at the last paragraph break,
+// failing that the last line or sentence end, failing that the last space
+// in their second half, and only mid-word when there is none.
+func (c Chunker) Split(text string) []string {
+	runes := []rune(strings.TrimSpace(text))
+	size := max(c.Size, 1)
+	overlap := min(max(c.Overlap, 0), size/2)
+	var chunks []string
+	for start := 0; start < len(runes); {
+		end := len(runes)
+		if end-start > size {
+			end = start + breakAt(runes[start:start+size])
+		}
+		if chunk := strings.TrimSpace(string(runes[start:end])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end == len(runes) {
+			break
+		}
+
+		// Start the next chunk at a word, overlap characters back
+		next := end - overlap
+		for next > start && next < end && !unicode.IsSpace(runes[next-1]) {
+			next++
+		}
+		if next <= start {
+			next = end
+		}
+		start = next
+	}
+	return chunks
+}
+
+// breakAt is where to end a chunk of window: after the last paragraph
+// break in its second half, else the last line break or sentence end, else
+// the last space.
+func breakAt(window []rune) int {
+	half := len(window) / 2
+	sentence, space := -1, -1
+	for i := len(window) - 1; i >= half; i-- {
+		switch r := window[i]; {
+		case r == '\n' && i > 0 && window[i-1] == '\n':
+			return i + 1
+		case r == '\n' && sentence < 0:
+			sentence = i + 1
+		case unicode.IsSpace(r) && i > 0 && strings.ContainsRune(".!?", window[i-1]) && sentence < 0:
+			sentence = i + 1
+		case unicode.IsSpace(r) && space < 0:
+			space = i + 1
+		}
+	}
+	switch {
+	case sentence > 0:
+		return sentence
+	case space > 0:
+		return space
+	default:
+		return len(window)
+	}
+}
@@ -0,0 +1,461 @@
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Chunking strategies.
+const (
+	ChunkFixed    = "fixed"    // Chunker: about Size characters, overlapping
+	ChunkMarkdown = "markdown" // MarkdownChunker: by heading
+	ChunkCode     = "code"     // CodeChunker: by top-level declaration
+	ChunkSemantic = "semantic" // SemanticChunker: where the topic shifts
+)
+
+// ChunkerKey is the ingestion tag choosing a document's chunking strategy
+// over the one [retrieval.chunkers] picks for its type.
+const ChunkerKey = "chunker"
+
+// SectionKey is the chunk metadata key holding the heading path or
+// declaration a chunk falls under, when its chunker knows it.
+const SectionKey = "section"
+
+// Passage is a piece of a document to index as a chunk.
+type Passage struct {
+	Text    string
+	Section string // "Install > Linux", "func (*Retriever) Index"; empty when unknown
+}
+
+// Splitter splits the text of a document into passages.
+type Splitter interface {
+	Passages(ctx context.Context, text string) ([]Passage, error)
+}
+
+// Passages splits text with Split.
+func (c Chunker) Passages(ctx context.Context, text string) ([]Passage, error) {
+	return passages(c.Split(text), ""), nil
+}
+
+func passages(texts []string, section string) []Passage {
+	out := make([]Passage, len(texts))
+	for i, t := range texts {
+		out[i] = Passage{Text: t, Section: section}
+	}
+	return out
+}
+
+// splitter is the splitter for doc: the strategy its ChunkerKey tag names,
+// else the one [retrieval.chunkers] maps its extension or type to, else
+// fixed-size chunks.
+func (r *Retriever) splitter(doc core.Document, tags map[string]string) (Splitter, error) {
+	ext := extension(doc.Source)
+	strategy := tags[ChunkerKey]
+	if strategy == "" {
+		strategy = r.chunkers[ext]
+	}
+	if strategy == "" {
+		strategy = r.chunkers[string(doc.Type)]
+	}
+	switch strategy {
+	case "", ChunkFixed:
+		return r.chunker, nil
+	case ChunkMarkdown:
+		return MarkdownChunker{r.chunker}, nil
+	case ChunkCode:
+		return CodeChunker{Chunker: r.chunker, Language: ext}, nil
+	case ChunkSemantic:
+		return SemanticChunker{Chunker: r.chunker, Embedder: r.embedder, Threshold: r.semanticThreshold, BatchSize: r.embedBatch}, nil
+	default:
+		return nil, fmt.Errorf("retrieval: unknown chunker %q", strategy)
+	}
+}
+
+// validChunkers checks the strategies [retrieval.chunkers] names.
+func validChunkers(cfg Config) error {
+	for typ, strategy := range cfg.Chunkers {
+		if !slices.Contains([]string{ChunkFixed, ChunkMarkdown, ChunkCode, ChunkSemantic}, strategy) {
+			return fmt.Errorf("retrieval: chunkers.%s: unknown chunker %q", typ, strategy)
+		}
+	}
+	return nil
+}
+
+// extension is the lower-cased file extension of a path or URL source.
+func extension(source string) string {
+	if u, err := url.Parse(source); err == nil && u.Scheme != "" && u.Host != "" {
+		source = u.Path
+	}
+	return strings.ToLower(path.Ext(source))
+}
+
+// MarkdownChunker splits Markdown at its headings. Sections that fit
+// together in Size characters share a chunk; longer ones are split by the
+// Chunker. Each chunk of a nested or split section starts with the path of
+// headings it falls under, so it reads in context.
+type MarkdownChunker struct {
+	Chunker
+}
+
+var (
+	mdHeading = regexp.MustCompile(`^ {0,3}(#{1,6})[ \t]+(.*?)[ \t#]*$`)
+	mdFence   = regexp.MustCompile("^ {0,3}(```|~~~)")
+)
+
+// mdSection is a heading and the text up to the next one.
+type mdSection struct {
+	path []string // headings from the outermost to its own
+	text string
+}
+
+func (m MarkdownChunker) Passages(ctx context.Context, text string) ([]Passage, error) {
+	return m.Split(text), nil
+}
+
+// Split returns the passages of text, each with its heading path.
+func (m MarkdownChunker) Split(text string) []Passage {
+	var (
+		sections []mdSection
+		current  mdSection
+		body     strings.Builder
+		levels   []int
+		fence    string
+	)
+	flush := func() {
+		if current.text = strings.TrimSpace(body.String()); current.text != "" {
+			sections = append(sections, current)
+		}
+		body.Reset()
+	}
+	for _, line := range strings.Split(text, "\n") {
+		if f := mdFence.FindStringSubmatch(line); f != nil {
+			switch fence {
+			case "":
+				fence = f[1]
+			case f[1]:
+				fence = ""
+			}
+		}
+		if h := mdHeading.FindStringSubmatch(line); h != nil && fence == "" {
+			flush()
+			level := len(h[1])
+			headings := slices.Clone(current.path)
+			for len(levels) > 0 && levels[len(levels)-1] >= level {
+				levels, headings = levels[:len(levels)-1], headings[:len(headings)-1]
+			}
+			levels = append(levels, level)
+			current = mdSection{path: append(headings, h[2])}
+		}
+		body.WriteString(line)
+		body.WriteString("\n")
+	}
+	flush()
+
+	var out []Passage
+	var merged []mdSection
+	size := 0
+	emit := func() {
+		if len(merged) == 0 {
+			return
+		}
+		texts := make([]string, len(merged))
+		for i, s := range merged {
+			texts[i] = s.text
+		}
+		first := merged[0]
+		out = append(out, Passage{Text: headingContext(first.path, false) + strings.Join(texts, "\n\n"), Section: strings.Join(first.path, " > ")})
+		merged, size = nil, 0
+	}
+	for _, s := range sections {
+		n := utf8.RuneCountInString(s.text)
+		if size > 0 && size+n+2 > m.Size {
+			emit()
+		}
+		if n <= m.Size {
+			merged = append(merged, s)
+			size += n + 2
+			continue
+		}
+		for i, piece := range m.Chunker.Split(s.text) {
+			out = append(out, Passage{Text: headingContext(s.path, i > 0) + piece, Section: strings.Join(s.path, " > ")})
+		}
+	}
+	emit()
+	return out
+}
+
+// headingContext is the heading path a passage of a section starts with:
+// none for the start of a top-level section, whose heading it already has.
+func headingContext(headings []string, continued bool) string {
+	if len(headings) == 0 || len(headings) == 1 && !continued {
+		return ""
+	}
+	return strings.Join(headings, " > ") + "\n\n"
+}
+
+// CodeChunker splits source code at its top-level declarations, each with
+// the comments above it. Go is parsed; other languages are split at
+// unindented lines declaring a function, class or type. Declarations that
+// fit together in Size characters share a chunk; longer ones are split by
+// the Chunker.
+type CodeChunker struct {
+	Chunker
+	Language string // the file extension, e.g. ".go"
+}
+
+// codeUnit is a declaration, or the code before the first.
+type codeUnit struct {
+	name string
+	text string
+}
+
+func (c CodeChunker) Passages(ctx context.Context, text string) ([]Passage, error) {
+	return c.Split(text), nil
+}
+
+// Split returns the passages of text, each named for its first
+// declaration.
+func (c CodeChunker) Split(text string) []Passage {
+	lines := strings.Split(text, "\n")
+	var starts []int
+	var names []string
+	if c.Language == ".go" {
+		starts, names = goDeclarations(text)
+	}
+	if starts == nil {
+		starts, names = declarationLines(lines)
+	}
+
+	var units []codeUnit
+	if len(starts) == 0 || starts[0] > 0 {
+		end := len(lines)
+		if len(starts) > 0 {
+			end = starts[0]
+		}
+		units = append(units, codeUnit{text: strings.Join(lines[:end], "\n")})
+	}
+	for i, start := range starts {
+		end := len(lines)
+		if i+1 < len(starts) {
+			end = starts[i+1]
+		}
+		units = append(units, codeUnit{name: names[i], text: strings.Join(lines[start:end], "\n")})
+	}
+
+	var out []Passage
+	var merged []string
+	name, size := "", 0
+	emit := func() {
+		if text := strings.TrimSpace(strings.Join(merged, "\n")); text != "" {
+			out = append(out, Passage{Text: text, Section: name})
+		}
+		merged, name, size = nil, "", 0
+	}
+	for _, u := range units {
+		n := utf8.RuneCountInString(u.text)
+		if size > 0 && size+n+1 > c.Size {
+			emit()
+		}
+		if n <= c.Size {
+			if name == "" {
+				name = u.name
+			}
+			merged = append(merged, u.text)
+			size += n + 1
+			continue
+		}
+		out = append(out, passages(c.Chunker.Split(u.text), u.name)...)
+	}
+	emit()
+	return out
+}
+
+// goDeclarations returns the first line, doc comment included, and name
+// of each top-level declaration of Go source; nil when it doesn't parse.
+func goDeclarations(src string) (starts []int, names []string) {
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "", src, parser.ParseComments|parser.SkipObjectResolution)
+	if err != nil {
+		return nil, nil
+	}
+	for _, decl := range file.Decls {
+		pos := decl.Pos()
+		var name string
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Doc != nil {
+				pos = d.Doc.Pos()
+			}
+			name = "func " + d.Name.Name
+			if d.Recv != nil && len(d.Recv.List) > 0 {
+				name = fmt.Sprintf("func (%s) %s", receiver(src, fset, d.Recv.List[0].Type), d.Name.Name)
+			}
+		case *ast.GenDecl:
+			if d.Tok == token.IMPORT {
+				continue // part of the preamble
+			}
+			if d.Doc != nil {
+				pos = d.Doc.Pos()
+			}
+			name = d.Tok.String()
+			if len(d.Specs) > 0 {
+				switch s := d.Specs[0].(type) {
+				case *ast.TypeSpec:
+					name += " " + s.Name.Name
+				case *ast.ValueSpec:
+					name += " " + s.Names[0].Name
+				}
+			}
+		}
+		starts = append(starts, fset.Position(pos).Line-1)
+		names = append(names, name)
+	}
+	if starts == nil {
+		starts = []int{} // parsed, but declares nothing
+	}
+	return starts, names
+}
+
+// receiver is the source of a receiver's type.
+func receiver(src string, fset *token.FileSet, expr ast.Expr) string {
+	return src[fset.Position(expr.Pos()).Offset:fset.Position(expr.End()).Offset]
+}
+
+var (
+	// declaration matches an unindented line declaring a function, class or
+	// type in the common languages, keyword first or C-style.
+	declaration = regexp.MustCompile(`^(?:(?:export|default|pub(?:\([\w:]+\))?|public|private|protected|internal|static|async|abstract|final|sealed|open|override|unsafe|extern|inline|virtual)\s+)*` +
+		`(?:def|class|function|func|fn|impl|struct|enum|trait|interface|type|module|mod|object|record|namespace)\b` +
+		`|^[A-Za-z_][\w\s\*&<>:,\[\]]*\s[\*&]*[A-Za-z_][\w:]*\s*\([^;]*$`)
+	// controlFlow rules out C-style matches that are statements.
+	controlFlow = regexp.MustCompile(`^(?:if|for|while|switch|return|else|do|case|catch)\b`)
+	// lead matches the lines a declaration brings along from above it:
+	// comments, decorators and attributes.
+	lead = regexp.MustCompile(`^\s*(?://|#|/\*|\*|--|@|"""|''')`)
+)
+
+// declarationLines returns the first line, leading comments included, and
+// the declaring line of each unindented declaration in lines.
+func declarationLines(lines []string) (starts []int, names []string) {
+	for i, line := range lines {
+		if line == "" || unicode.IsSpace(rune(line[0])) || !declaration.MatchString(line) || controlFlow.MatchString(line) {
+			continue
+		}
+		start := i
+		for start > 0 && strings.TrimSpace(lines[start-1]) != "" && lead.MatchString(lines[start-1]) {
+			start--
+		}
+		if len(starts) > 0 && start <= starts[len(starts)-1] {
+			continue
+		}
+		starts = append(starts, start)
+		names = append(names, strings.TrimRight(strings.TrimSpace(line), " {:("))
+	}
+	return starts, names
+}
+
+// SemanticChunker splits text between sentences where the topic shifts:
+// where the embeddings of neighbouring sentences are less similar than
+// Threshold, or, without one, than at nine in ten of the document's
+// sentence boundaries. Chunks end early to stay within Size characters and
+// don't overlap. Every sentence is embedded, at the embedding provider's
+// cost.
+type SemanticChunker struct {
+	Chunker
+	Embedder  Embedder
+	Threshold float64
+	// BatchSize is how many sentences are embedded per request (default
+	// DefaultEmbedBatch).
+	BatchSize int
+}
+
+func (s SemanticChunker) Passages(ctx context.Context, text string) ([]Passage, error) {
+	sents := sentences(text)
+	if len(sents) < 3 {
+		return s.Chunker.Passages(ctx, text)
+	}
+	size := s.BatchSize
+	if size <= 0 {
+		size = DefaultEmbedBatch
+	}
+	var vectors [][]float32
+	for start := 0; start < len(sents); start += size {
+		batch := sents[start:min(start+size, len(sents))]
+		v, err := s.Embedder.Embed(ctx, batch)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed sentences: %w", err)
+		}
+		if len(v) != len(batch) {
+			return nil, fmt.Errorf("embedding returned %d vectors for %d sentences", len(v), len(batch))
+		}
+		vectors = append(vectors, v...)
+	}
+	similarity := make([]float64, len(sents)-1)
+	for i := range similarity {
+		similarity[i] = cosine(vectors[i], vectors[i+1])
+	}
+	threshold := s.Threshold
+	if threshold <= 0 {
+		sorted := slices.Sorted(slices.Values(similarity))
+		threshold = sorted[len(sorted)/10]
+	}
+
+	var out []string
+	var chunk strings.Builder
+	emit := func() {
+		if t := strings.TrimSpace(chunk.String()); t != "" {
+			out = append(out, t)
+		}
+		chunk.Reset()
+	}
+	for i, sent := range sents {
+		n := utf8.RuneCountInString(sent)
+		if n > s.Size {
+			emit()
+			out = append(out, s.Chunker.Split(sent)...)
+			continue
+		}
+		if chunk.Len() > 0 && (similarity[i-1] <= threshold || utf8.RuneCountInString(chunk.String())+n > s.Size) {
+			emit()
+		}
+		chunk.WriteString(sent)
+	}
+	emit()
+	return passages(out, ""), nil
+}
+
+// sentences splits text after sentence ends and paragraph breaks, keeping
+// the space after each so they join back as they were.
+func sentences(text string) []string {
+	runes := []rune(strings.TrimSpace(text))
+	var out []string
+	start := 0
+	for i := 0; i < len(runes); i++ {
+		end := strings.ContainsRune(".!?", runes[i]) && i+1 < len(runes) && unicode.IsSpace(runes[i+1]) ||
+			runes[i] == '\n' && i+1 < len(runes) && runes[i+1] == '\n'
+		if !end {
+			continue
+		}
+		for i+1 < len(runes) && unicode.IsSpace(runes[i+1]) {
+			i++
+		}
+		out = append(out, string(runes[start:i+1]))
+		start = i + 1
+	}
+	if start < len(runes) {
+		out = append(out, string(runes[start:]))
+	}
+	return out
+}
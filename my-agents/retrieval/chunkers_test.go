@@ -0,0 +1,218 @@
+package retrieval
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+func sections(ps []Passage) []string {
+	out := make([]string, len(ps))
+	for i, p := range ps {
+		out[i] = p.Section
+	}
+	return out
+}
+
+func TestChunkerSplit(t *testing.T) {
+	text := strings.Repeat("The quick brown fox jumps. ", 40)
+	chunks := Chunker{Size: 100, Overlap: 20}.Split(text)
+	if len(chunks) < 10 {
+		t.Fatalf("%d chunks of %d characters, want at least 10", len(chunks), len(text))
+	}
+	for i, c := range chunks {
+		if n := utf8.RuneCountInString(c); n > 100 {
+			t.Errorf("chunk %d has %d characters, over the size", i, n)
+		}
+		if !strings.HasSuffix(c, ".") && i < len(chunks)-1 {
+			t.Errorf("chunk %d = %q, want it to end at a sentence", i, c)
+		}
+		if strings.HasPrefix(c, " ") || strings.HasSuffix(c, " ") {
+			t.Errorf("chunk %d isn't trimmed", i)
+		}
+	}
+	// Overlapping chunks repeat the end of the one before
+	if last := chunks[0][len(chunks[0])-10:]; !strings.Contains(chunks[1], last) {
+		t.Errorf("chunk 1 = %q doesn't overlap the end of chunk 0, %q", chunks[1], last)
+	}
+}
+
+func TestChunkerSplitPrefersParagraphs(t *testing.T) {
+	text := strings.Repeat("a", 60) + "\n\n" + strings.Repeat("b", 30)
+	chunks := Chunker{Size: 80}.Split(text)
+	if len(chunks) != 2 || chunks[0] != strings.Repeat("a", 60) {
+		t.Errorf("chunks = %q, want the paragraphs apart", chunks)
+	}
+}
+
+func TestChunkerSplitLongWord(t *testing.T) {
+	chunks := Chunker{Size: 10, Overlap: 3}.Split(strings.Repeat("x", 35))
+	if len(chunks) != 4 {
+		t.Errorf("chunks = %q, want a word without spaces split every 10 characters", chunks)
+	}
+}
+
+func TestMarkdownChunker(t *testing.T) {
+	doc := `Intro line.
+
+# Install
+Pick a platform.
+
+## Linux
+Run the script.
+
+` + "```sh\n# not a heading\n./install.sh\n```" + `
+
+## macOS
+Use brew.
+
+# Usage
+` + strings.Repeat("Call it with flags. ", 20)
+
+	got := MarkdownChunker{Chunker{Size: 120}}.Split(doc)
+	want := []string{"", "Install > macOS", "Usage", "Usage", "Usage", "Usage"}
+	if secs := sections(got); !slices.Equal(secs, want) {
+		t.Fatalf("sections = %q, want %q", secs, want)
+	}
+	// Short sections share a chunk, and a fence hides what looks like a heading
+	if !strings.Contains(got[0].Text, "## Linux") || !strings.Contains(got[0].Text, "# not a heading\n./install.sh") {
+		t.Errorf("first passage = %q, want the intro, Install and Linux with its code", got[0].Text)
+	}
+	if !strings.HasPrefix(got[1].Text, "Install > macOS\n\n## macOS") {
+		t.Errorf("macOS passage = %q, want its heading path first", got[1].Text)
+	}
+	if !strings.HasPrefix(got[2].Text, "# Usage\n") || !strings.HasPrefix(got[3].Text, "Usage\n\n") {
+		t.Error("a split top-level section repeats its heading on the first piece or lacks it on the rest")
+	}
+}
+
+func TestCodeChunkerGo(t *testing.T) {
+	src := `package demo
+
+import "fmt"
+
+// Greeter greets.
+type Greeter struct{ name string }
+
+// Greet says hello.
+func (g *Greeter) Greet() { fmt.Println("hello", g.name) }
+
+func helper() {}
+`
+	got := CodeChunker{Chunker: Chunker{Size: 80}, Language: ".go"}.Split(src)
+	want := []string{"", "type Greeter", "func (*Greeter) Greet", "func helper"}
+	if secs := sections(got); !slices.Equal(secs, want) {
+		t.Fatalf("sections = %q, want %q", secs, want)
+	}
+	if !strings.HasPrefix(got[2].Text, "// Greet says hello.") {
+		t.Errorf("Greet passage = %q, want its doc comment", got[2].Text)
+	}
+}
+
+func TestCodeChunkerOtherLanguages(t *testing.T) {
+	src := `import os
+
+# Loads the config.
+@cache
+def load(path):
+    if path:
+        return open(path)
+
+class Store:
+    def get(self):
+        pass
+`
+	got := CodeChunker{Chunker: Chunker{Size: 90}, Language: ".py"}.Split(src)
+	want := []string{"", "def load(path)", "class Store"}
+	if secs := sections(got); !slices.Equal(secs, want) {
+		t.Fatalf("sections = %q, want %q", secs, want)
+	}
+	if !strings.HasPrefix(got[1].Text, "# Loads the config.\n@cache") {
+		t.Errorf("load passage = %q, want its comment and decorator", got[1].Text)
+	}
+}
+
+func TestCodeChunkerUnparsableGo(t *testing.T) {
+	src := "func broken( {\n}\n\nfunc fine() {}\n"
+	got := CodeChunker{Chunker: Chunker{Size: 20}, Language: ".go"}.Split(src)
+	if len(got) != 2 || got[0].Section != "func broken" || !strings.HasPrefix(got[1].Section, "func fine()") {
+		t.Errorf("sections = %q, want the line-based split", sections(got))
+	}
+}
+
+// topics embeds a sentence by which topic word it mentions.
+type topics []string
+
+func (tp topics) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i, text := range texts {
+		out[i] = make([]float32, len(tp))
+		for j, word := range tp {
+			if strings.Contains(text, word) {
+				out[i][j] = 1
+			}
+		}
+	}
+	return out, nil
+}
+
+func TestSemanticChunker(t *testing.T) {
+	text := "Cats purr. Cats nap all day. Cats chase mice. Rust has lifetimes. Rust has traits. Rust compiles slowly."
+	s := SemanticChunker{Chunker: Chunker{Size: 500}, Embedder: topics{"Cats", "Rust"}, Threshold: 0.5, BatchSize: 2}
+	got, err := s.Passages(context.Background(), text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 || !strings.HasPrefix(got[0].Text, "Cats") || !strings.HasPrefix(got[1].Text, "Rust") || strings.Contains(got[0].Text, "Rust") {
+		t.Errorf("passages = %q, want one per topic", got)
+	}
+}
+
+func TestSemanticChunkerKeepsSize(t *testing.T) {
+	text := strings.Repeat("Cats purr loudly. ", 10)
+	s := SemanticChunker{Chunker: Chunker{Size: 40}, Embedder: topics{"Cats"}, Threshold: 0.5}
+	got, err := s.Passages(context.Background(), text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range got {
+		if n := utf8.RuneCountInString(p.Text); n > 40 {
+			t.Errorf("passage of %d characters, over the size: %q", n, p.Text)
+		}
+	}
+}
+
+func TestSentences(t *testing.T) {
+	got := sentences("One. Two!  Three?\n\nFour\n\nFive 3.5 six.")
+	want := []string{"One. ", "Two!  ", "Three?\n\n", "Four\n\n", "Five 3.5 six."}
+	if !slices.Equal(got, want) {
+		t.Errorf("sentences = %q, want %q", got, want)
+	}
+}
+
+func TestSplitterChoice(t *testing.T) {
+	r := &Retriever{chunker: Chunker{Size: 100}, chunkers: map[string]string{".md": ChunkMarkdown, "code": ChunkCode}}
+	for _, tt := range []struct {
+		doc  core.Document
+		tags map[string]string
+		want Splitter
+	}{
+		{core.Document{Source: "docs/README.MD"}, nil, MarkdownChunker{r.chunker}},
+		{core.Document{Source: "https://example.com/guide.md?raw=1"}, nil, MarkdownChunker{r.chunker}},
+		{core.Document{Source: "main.go", Type: "code"}, nil, CodeChunker{Chunker: r.chunker, Language: ".go"}},
+		{core.Document{Source: "notes.txt"}, nil, r.chunker},
+		{core.Document{Source: "README.md"}, map[string]string{ChunkerKey: ChunkFixed}, r.chunker},
+	} {
+		got, err := r.splitter(tt.doc, tt.tags)
+		if err != nil || got != tt.want {
+			t.Errorf("splitter(%s, %v) = %#v, %v; want %#v", tt.doc.Source, tt.tags, got, err, tt.want)
+		}
+	}
+	if _, err := r.splitter(core.Document{Source: "a.txt"}, map[string]string{ChunkerKey: "magic"}); err == nil {
+		t.Error("an unknown chunker tag was accepted")
+	}
+}
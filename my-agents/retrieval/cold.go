@@ -0,0 +1,408 @@
+package retrieval
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/coldstore"
+	"my-agents/storage"
+)
+
+// tiers tracks, in the embedded database, when each document was last
+// indexed or retrieved, and which documents were moved to cold storage
+// with the mean of their chunks' vectors, which searches compare the query
+// against to bring the ones it is close to back.
+type tiers struct {
+	db           *sql.DB
+	owner        string            // the coldstore.Owner keying the archived documents
+	archive      coldstore.Archive // nil until UseColdStorage
+	restoreScore float64
+
+	mu    sync.Mutex
+	locks map[string]*documentLock // by document, while it moves
+}
+
+type documentLock struct {
+	sync.Mutex
+	waiters int
+}
+
+func openTiers(path string) (*tiers, error) {
+	db, err := storage.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	err = storage.Migrate(db,
+		`CREATE TABLE IF NOT EXISTS retrieval_access (
+			document_id TEXT PRIMARY KEY,
+			collection  TEXT NOT NULL,
+			accessed_at INTEGER NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS retrieval_cold (
+			document_id TEXT PRIMARY KEY,
+			collection  TEXT NOT NULL,
+			chunks      INTEGER NOT NULL,
+			centroid    BLOB NOT NULL,
+			size        INTEGER NOT NULL,
+			archived_at INTEGER NOT NULL
+		)`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	owner, err := coldstore.Owner(db)
+	if err != nil {
+		return nil, err
+	}
+	return &tiers{db: db, owner: owner, locks: make(map[string]*documentLock)}, nil
+}
+
+// lock serialises moving a document in or out of cold storage, so two
+// searches don't both restore it nor one archive it while another brings
+// it back. It returns the unlock.
+func (t *tiers) lock(documentID string) func() {
+	t.mu.Lock()
+	l, ok := t.locks[documentID]
+	if !ok {
+		l = &documentLock{}
+		t.locks[documentID] = l
+	}
+	l.waiters++
+	t.mu.Unlock()
+	l.Lock()
+	return func() {
+		l.Unlock()
+		t.mu.Lock()
+		if l.waiters--; l.waiters == 0 {
+			delete(t.locks, documentID)
+		}
+		t.mu.Unlock()
+	}
+}
+
+// coldDocument is what a document's archived object holds.
+type coldDocument struct {
+	Collection string      `json:"collection"`
+	Chunks     []coldChunk `json:"chunks"`
+}
+
+// coldChunk is a chunk with its vector, which Chunk leaves out of JSON.
+type coldChunk struct {
+	Chunk
+	Vector []float32 `json:"vector"`
+}
+
+func (t *tiers) coldKey(documentID string) string { return "vectors/" + t.owner + "/" + documentID }
+
+// UseColdStorage has Archive move documents to archive, and searches and
+// citations bring them back; restoreScore is [cold_storage] restore_score.
+// It needs a retriever opened with a database over the sqlite store: what
+// moved is indexed beside it, which a store other processes share isn't.
+func (r *Retriever) UseColdStorage(archive coldstore.Archive, restoreScore float64) error {
+	if r.tiers == nil {
+		return fmt.Errorf("retrieval: cold storage needs the embedded database")
+	}
+	if r.sharedStore {
+		// Replicas would each archive and restore the shared chunks by
+		// their own index, deleting what the others find archived
+		return fmt.Errorf("retrieval: cold storage needs the sqlite store, which only this process indexes; set [cold_storage] vectors_after = \"0\" to keep a shared store's documents hot")
+	}
+	if restoreScore <= 0 {
+		restoreScore = coldstore.DefaultRestoreScore
+	}
+	r.tiers.archive, r.tiers.restoreScore = archive, restoreScore
+	return nil
+}
+
+// SharedStore reports whether the store is a server other processes may
+// use too, whose documents UseColdStorage won't move.
+func (r *Retriever) SharedStore() bool { return r.sharedStore }
+
+// touch records that documents of the collection were just indexed or
+// retrieved. Failures are logged; they only delay archiving.
+func (r *Retriever) touch(ctx context.Context, collection string, documentIDs ...string) {
+	if r.tiers == nil {
+		return
+	}
+	now := time.Now().UnixNano()
+	for _, id := range documentIDs {
+		_, err := r.tiers.db.ExecContext(ctx,
+			`INSERT INTO retrieval_access (document_id, collection, accessed_at) VALUES (?, ?, ?)
+			 ON CONFLICT (document_id) DO UPDATE SET collection = excluded.collection, accessed_at = excluded.accessed_at`,
+			id, collection, now)
+		if err != nil {
+			core.Logger().Warn().Str("document", id).Err(err).Msg("Failed to record document access")
+			return
+		}
+	}
+}
+
+// touchMatches records that the matches' documents were retrieved.
+func (r *Retriever) touchMatches(ctx context.Context, matches []Match) {
+	seen := make(map[string]bool)
+	for _, m := range matches {
+		if m.DocumentID != "" && !seen[m.DocumentID] {
+			seen[m.DocumentID] = true
+			r.touch(ctx, m.Collection, m.DocumentID)
+		}
+	}
+}
+
+// forgetTiers drops what is known of the document's access and its
+// archived copy.
+func (r *Retriever) forgetTiers(ctx context.Context, documentID string) error {
+	if r.tiers == nil {
+		return nil
+	}
+	if _, err := r.tiers.db.ExecContext(ctx, `DELETE FROM retrieval_access WHERE document_id = ?`, documentID); err != nil {
+		return fmt.Errorf("failed to forget access to %s: %w", documentID, err)
+	}
+	res, err := r.tiers.db.ExecContext(ctx, `DELETE FROM retrieval_cold WHERE document_id = ?`, documentID)
+	if err != nil {
+		return fmt.Errorf("failed to unindex archived %s: %w", documentID, err)
+	}
+	if n, _ := res.RowsAffected(); n > 0 && r.tiers.archive != nil {
+		return r.tiers.archive.Delete(ctx, r.tiers.coldKey(documentID))
+	}
+	return nil
+}
+
+// Archive moves the chunks of documents neither indexed nor retrieved
+// since cutoff to cold storage. Documents indexed before access was
+// tracked are known from their next retrieval on; documents whose
+// indexing was interrupted stay.
+func (r *Retriever) Archive(ctx context.Context, cutoff time.Time) (coldstore.Moved, error) {
+	var done coldstore.Moved
+	if r.tiers == nil || r.tiers.archive == nil {
+		return done, nil
+	}
+	rows, err := r.tiers.db.QueryContext(ctx,
+		`SELECT document_id, collection FROM retrieval_access WHERE accessed_at < ? ORDER BY accessed_at`, cutoff.UnixNano())
+	if err != nil {
+		return done, fmt.Errorf("failed to list idle documents: %w", err)
+	}
+	type idle struct{ id, collection string }
+	var docs []idle
+	for rows.Next() {
+		var d idle
+		if err := rows.Scan(&d.id, &d.collection); err != nil {
+			rows.Close()
+			return done, err
+		}
+		docs = append(docs, d)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return done, err
+	}
+	for _, d := range docs {
+		c, ok := r.collections[d.collection]
+		if !ok {
+			continue
+		}
+		if _, interrupted, err := r.Checkpoint(ctx, d.id); err != nil || interrupted {
+			if err != nil {
+				return done, err
+			}
+			continue
+		}
+		size, err := r.archiveDocument(ctx, c, d.id)
+		if err != nil {
+			return done, err
+		}
+		if size > 0 {
+			done.Records++
+			done.Bytes += int64(size)
+		}
+	}
+	return done, nil
+}
+
+// archiveDocument moves one document's chunks out of c and returns the
+// archived size, 0 when c held none.
+func (r *Retriever) archiveDocument(ctx context.Context, c *collection, documentID string) (int, error) {
+	defer r.tiers.lock(documentID)()
+	chunks, err := c.store.Document(ctx, documentID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s from %s: %w", documentID, c.name, err)
+	}
+	if len(chunks) == 0 {
+		_, err := r.tiers.db.ExecContext(ctx, `DELETE FROM retrieval_access WHERE document_id = ?`, documentID)
+		return 0, err
+	}
+	doc := coldDocument{Collection: c.name, Chunks: make([]coldChunk, len(chunks))}
+	for i, ch := range chunks {
+		doc.Chunks[i] = coldChunk{Chunk: ch, Vector: ch.Vector}
+	}
+	size, err := coldstore.Freeze(ctx, r.tiers.archive, r.tiers.coldKey(documentID), doc)
+	if err != nil {
+		return 0, err
+	}
+	_, err = r.tiers.db.ExecContext(ctx,
+		`INSERT INTO retrieval_cold (document_id, collection, chunks, centroid, size, archived_at) VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (document_id) DO UPDATE SET collection = excluded.collection, chunks = excluded.chunks,
+		 centroid = excluded.centroid, size = excluded.size, archived_at = excluded.archived_at`,
+		documentID, c.name, len(chunks), encodeVector(centroid(chunks)), size, time.Now().UnixNano())
+	if err != nil {
+		return 0, fmt.Errorf("failed to index archived %s: %w", documentID, err)
+	}
+	// Indexed before the chunks go, so the document is never in neither
+	if err := c.store.DeleteDocument(ctx, documentID); err != nil {
+		return 0, fmt.Errorf("failed to delete chunks of %s from %s: %w", documentID, c.name, err)
+	}
+	if c.keyword != nil {
+		if err := c.keyword.DeleteDocument(ctx, documentID); err != nil {
+			return 0, fmt.Errorf("failed to delete keywords of %s from %s: %w", documentID, c.name, err)
+		}
+	}
+	_, err = r.tiers.db.ExecContext(ctx, `DELETE FROM retrieval_access WHERE document_id = ?`, documentID)
+	return size, err
+}
+
+// restore brings an archived document back into its collection, reporting
+// whether it was archived. When allow is set, a document it refuses stays
+// archived, as if it weren't.
+func (r *Retriever) restore(ctx context.Context, documentID string, allow func(*coldDocument) bool) (bool, error) {
+	if r.tiers == nil || r.tiers.archive == nil {
+		return false, nil
+	}
+	// A restore that waited finds the document already back
+	defer r.tiers.lock(documentID)()
+	var name string
+	err := r.tiers.db.QueryRowContext(ctx, `SELECT collection FROM retrieval_cold WHERE document_id = ?`, documentID).Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to look up archived %s: %w", documentID, err)
+	}
+	c, ok := r.collections[name]
+	if !ok {
+		return false, fmt.Errorf("archived %s belongs to unknown collection %q", documentID, name)
+	}
+	var doc coldDocument
+	if err := coldstore.Thaw(ctx, r.tiers.archive, r.tiers.coldKey(documentID), &doc); err != nil {
+		return false, err
+	}
+	if allow != nil && !allow(&doc) {
+		return false, nil
+	}
+	chunks := make([]Chunk, len(doc.Chunks))
+	for i, ch := range doc.Chunks {
+		chunks[i] = ch.Chunk
+		chunks[i].Vector = ch.Vector
+	}
+	if err := c.store.Upsert(ctx, chunks); err != nil {
+		return false, fmt.Errorf("failed to restore chunks of %s: %w", documentID, err)
+	}
+	if c.keyword != nil {
+		if err := c.keyword.Upsert(ctx, chunks); err != nil {
+			return false, fmt.Errorf("failed to restore keywords of %s: %w", documentID, err)
+		}
+	}
+	if _, err := r.tiers.db.ExecContext(ctx, `DELETE FROM retrieval_cold WHERE document_id = ?`, documentID); err != nil {
+		return false, err
+	}
+	r.touch(ctx, name, documentID)
+	if err := r.tiers.archive.Delete(ctx, r.tiers.coldKey(documentID)); err != nil {
+		core.Logger().Warn().Str("document", documentID).Err(err).Msg("Failed to delete restored document from cold storage")
+	}
+	return true, nil
+}
+
+// restoreNear brings back the archived documents of collections whose
+// centroid is at least threshold similar to vector and that have a chunk
+// meeting filter, so a search restores only what it may see, reporting
+// whether it restored any. Without a threshold, the restore score is used.
+func (r *Retriever) restoreNear(ctx context.Context, collections []*collection, filter Filter, vector []float32, threshold float64) (bool, error) {
+	if r.tiers == nil || r.tiers.archive == nil {
+		return false, nil
+	}
+	if threshold <= 0 {
+		threshold = r.tiers.restoreScore
+	}
+	names := make([]any, len(collections))
+	for i, c := range collections {
+		names[i] = c.name
+	}
+	rows, err := r.tiers.db.QueryContext(ctx,
+		`SELECT document_id, centroid FROM retrieval_cold WHERE collection IN (?`+strings.Repeat(", ?", len(names)-1)+`)`, names...)
+	if err != nil {
+		return false, fmt.Errorf("failed to search archived documents: %w", err)
+	}
+	var near []string
+	for rows.Next() {
+		var id string
+		var blob []byte
+		if err := rows.Scan(&id, &blob); err != nil {
+			rows.Close()
+			return false, err
+		}
+		if cosine(vector, decodeVector(blob)) >= threshold {
+			near = append(near, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return false, err
+	}
+	restored := false
+	for _, id := range near {
+		ok, err := r.restore(ctx, id, func(doc *coldDocument) bool {
+			return slices.ContainsFunc(doc.Chunks, func(c coldChunk) bool { return filter.Match(c.Chunk) })
+		})
+		if err != nil {
+			return false, err
+		}
+		restored = restored || ok
+	}
+	return restored, nil
+}
+
+// centroid is the mean direction of the chunks' vectors.
+func centroid(chunks []Chunk) []float32 {
+	var sum []float64
+	for _, c := range chunks {
+		var norm float64
+		for _, x := range c.Vector {
+			norm += float64(x) * float64(x)
+		}
+		if norm == 0 {
+			continue
+		}
+		norm = math.Sqrt(norm)
+		if sum == nil {
+			sum = make([]float64, len(c.Vector))
+		}
+		for i := 0; i < len(sum) && i < len(c.Vector); i++ {
+			sum[i] += float64(c.Vector[i]) / norm
+		}
+	}
+	v := make([]float32, len(sum))
+	for i, x := range sum {
+		v[i] = float32(x)
+	}
+	return v
+}
+
+// SweepOrphans deletes the archived documents written before cutoff that
+// the index doesn't know of, returning how many and their size.
+func (r *Retriever) SweepOrphans(ctx context.Context, cutoff time.Time) (int, int64, error) {
+	if r.tiers == nil || r.tiers.archive == nil {
+		return 0, 0, nil
+	}
+	return coldstore.SweepOrphans(ctx, r.tiers.archive, r.tiers.coldKey(""), cutoff, func(ctx context.Context, key string) (bool, error) {
+		var n int
+		err := r.tiers.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM retrieval_cold WHERE document_id = ?`, strings.TrimPrefix(key, r.tiers.coldKey(""))).Scan(&n)
+		return n > 0, err
+	})
+}
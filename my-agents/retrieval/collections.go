@@ -0,0 +1,142 @@
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// CollectionsKey is the event metadata key naming the collections a
+// request searches, comma-separated. Ingestion tags a document's chunks
+// with CollectionKey to index them into a collection other than the main
+// one.
+const (
+	CollectionsKey = "collections"
+	CollectionKey  = "collection"
+)
+
+// CollectionConfig declares a named collection besides the main one, kept
+// in the same store and searched only when a request selects it:
+//
+//	[retrieval.collections.legal]
+//	description = "contracts, terms of service and privacy policies"
+type CollectionConfig struct {
+	// Description tells the classifier what the collection holds.
+	Description string `toml:"description"`
+}
+
+// collection is one searchable collection of chunks.
+type collection struct {
+	name        string
+	description string
+	store       Store
+	keyword     *Keyword // nil unless a search uses keywords
+}
+
+// UseClassifier has requests that select no collections have llm pick the
+// ones likely to answer them, from the collections' descriptions.
+func (r *Retriever) UseClassifier(llm core.ModelProvider) {
+	r.classifier = llm
+}
+
+// Collections returns the names of the collections, the main one first.
+func (r *Retriever) Collections() []string {
+	names := make([]string, 0, len(r.collections))
+	for name := range r.collections {
+		if name != r.main {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{r.main}, names...)
+}
+
+// collection returns the collection a document tagged with tags is indexed
+// into.
+func (r *Retriever) collection(tags map[string]string) (*collection, error) {
+	name := tags[CollectionKey]
+	if name == "" {
+		name = r.main
+	}
+	c, ok := r.collections[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown collection %q; declare it as [retrieval.collections.%s]", name, name)
+	}
+	return c, nil
+}
+
+// selectCollections resolves the collections a request searches: those it
+// names, else its workflow's, else the classifier's pick, else the main
+// one.
+func (r *Retriever) selectCollections(ctx context.Context, s Search, requested []string, query string) ([]*collection, error) {
+	names := requested
+	if len(names) == 0 {
+		names = s.Collections
+	}
+	if len(names) == 0 && r.classifier != nil && len(r.collections) > 1 {
+		names = r.classify(ctx, query)
+	}
+	if len(names) == 0 {
+		return []*collection{r.collections[r.main]}, nil
+	}
+	var selected []*collection
+	for _, name := range names {
+		c, ok := r.collections[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown collection %q", name)
+		}
+		if !slices.Contains(selected, c) {
+			selected = append(selected, c)
+		}
+	}
+	return selected, nil
+}
+
+// classify asks the classifier which collections may answer query. It
+// returns none when the classifier fails or names no known collection, so
+// the main one is searched.
+func (r *Retriever) classify(ctx context.Context, query string) []string {
+	var list strings.Builder
+	for _, name := range r.Collections() {
+		c := r.collections[name]
+		fmt.Fprintf(&list, "- %s", name)
+		if c.description != "" {
+			fmt.Fprintf(&list, ": %s", c.description)
+		}
+		list.WriteString("\n")
+	}
+	resp, err := r.classifier.Call(ctx, core.Prompt{
+		System: "You route questions to document collections. Reply with the names of the collections likely to answer the question, comma-separated, most likely first, and nothing else.",
+		User:   fmt.Sprintf("Collections:\n%s\nQuestion:\n%s", list.String(), query),
+	})
+	if err != nil {
+		core.Logger().Warn().Err(err).Msg("Failed to classify the request's collections")
+		return nil
+	}
+	var names []string
+	for _, field := range strings.FieldsFunc(resp.Content, func(r rune) bool { return r == ',' || r == '\n' }) {
+		name := strings.Trim(strings.TrimSpace(field), "-*`'\".")
+		if _, ok := r.collections[name]; ok && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		core.Logger().Warn().Str("reply", resp.Content).Msg("Classifier named no known collection")
+	}
+	return names
+}
+
+// ParseCollections splits the comma-separated value of CollectionsKey.
+func ParseCollections(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,141 @@
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// IndexDocument chunks doc with the chunker its type or ChunkerKey tag
+// picks, embeds and upserts it, replacing the chunks an earlier version of
+// it had, into the collection its CollectionKey tag names, else the main
+// one. Every chunk's metadata has the document's title, type and
+// "ingested" time, its SectionKey when the chunker knows it, its string,
+// number and bool metadata (a file's "modified" time among them), and
+// tags, which can set an author, date or TenantKey for filters. It returns
+// how many chunks were indexed.
+//
+// Retrievers opened with a database checkpoint each stored batch, so
+// indexing the same version of a document again after an interruption
+// resumes from the last stored batch. Progress is reported to the
+// WithProgress callback of ctx.
+func (r *Retriever) IndexDocument(ctx context.Context, doc core.Document, tags map[string]string) (int, error) {
+	c, err := r.collection(tags)
+	if err != nil {
+		return 0, err
+	}
+	meta := map[string]string{"title": doc.Title, "type": string(doc.Type)}
+	if !doc.CreatedAt.IsZero() {
+		meta["ingested"] = doc.CreatedAt.UTC().Format(time.RFC3339)
+	}
+	for k, v := range doc.Metadata {
+		switch v.(type) {
+		case string, bool, int, int64, float64:
+			meta[k] = fmt.Sprint(v)
+		}
+	}
+	for k, v := range tags {
+		meta[k] = v
+	}
+
+	splitter, err := r.splitter(doc, tags)
+	if err != nil {
+		return 0, err
+	}
+	passages, err := splitter.Passages(ctx, Text(doc))
+	if err != nil {
+		return 0, err
+	}
+	chunks := make([]Chunk, len(passages))
+	for i, p := range passages {
+		chunkMeta := make(map[string]string, len(meta)+2)
+		for k, v := range meta {
+			chunkMeta[k] = v
+		}
+		chunkMeta["chunk"] = strconv.Itoa(i)
+		if p.Section != "" {
+			chunkMeta[SectionKey] = p.Section
+		}
+		chunks[i] = Chunk{ID: doc.ID + "#" + strconv.Itoa(i), DocumentID: doc.ID, Source: doc.Source, Content: p.Text, Metadata: chunkMeta}
+	}
+	report := progressFrom(ctx)
+	progress := Progress{DocumentID: doc.ID, Source: doc.Source, Total: len(chunks)}
+	if r.checkpoints == nil {
+		if err := r.deleteDocument(ctx, c, doc.ID); err != nil {
+			return 0, err
+		}
+		err := r.index(ctx, c, chunks, func(n int) error {
+			progress.Done = n
+			report(progress)
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		r.touch(ctx, c.name, doc.ID)
+		return len(chunks), nil
+	}
+
+	fp := fingerprint(c.name, chunks)
+	done, err := r.checkpoints.done(ctx, doc.ID, fp)
+	if err != nil {
+		return 0, err
+	}
+	if done == 0 {
+		// Another version's chunks, or an interrupted attempt's, go first
+		if err := r.deleteDocument(ctx, c, doc.ID); err != nil {
+			return 0, err
+		}
+	}
+	progress.Done, progress.Resumed = done, done
+	report(progress)
+	checkpoint := Checkpoint{DocumentID: doc.ID, Source: doc.Source, Collection: c.name, Tags: tags, Total: len(chunks)}
+	err = r.index(ctx, c, chunks[done:], func(n int) error {
+		checkpoint.Done = done + n
+		if err := r.checkpoints.save(ctx, checkpoint, fp); err != nil {
+			return err
+		}
+		progress.Done = checkpoint.Done
+		report(progress)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	r.touch(ctx, c.name, doc.ID)
+	return len(chunks), r.checkpoints.clear(ctx, doc.ID)
+}
+
+// Text is the text of doc to index: its content, with the markup of web
+// pages stripped.
+func Text(doc core.Document) string {
+	if doc.Type == core.DocumentTypeWeb {
+		return htmlText(doc.Content)
+	}
+	return doc.Content
+}
+
+var (
+	htmlSkipped = regexp.MustCompile(`(?is)<(script|style|head|noscript|template)\b.*?</(script|style|head|noscript|template)\s*>|<!--.*?-->`)
+	htmlBlock   = regexp.MustCompile(`(?i)</?(p|div|section|article|header|footer|main|aside|nav|h[1-6]|li|ul|ol|tr|table|blockquote|pre|br|hr)\b[^>]*>`)
+	htmlTag     = regexp.MustCompile(`<[^>]*>`)
+	blankLines  = regexp.MustCompile(`\n\s*\n\s*`)
+	spaces      = regexp.MustCompile(`[ \t\r\f\v]+`)
+)
+
+// htmlText extracts the text of an HTML page, one block element per
+// paragraph.
+func htmlText(page string) string {
+	text := htmlSkipped.ReplaceAllString(page, " ")
+	text = htmlBlock.ReplaceAllString(text, "\n\n")
+	text = html.UnescapeString(htmlTag.ReplaceAllString(text, " "))
+	text = spaces.ReplaceAllString(text, " ")
+	text = blankLines.ReplaceAllString(text, "\n\n")
+	return strings.TrimSpace(text)
+}
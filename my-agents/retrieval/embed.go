@@ -0,0 +1,99 @@
+package retrieval
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// NewEmbedder creates the embedding provider cfg configures, held to its
+// rate limits.
+func NewEmbedder(cfg EmbeddingConfig) (Embedder, error) {
+	e, err := newEmbedder(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return newLimitedEmbedder(e, cfg), nil
+}
+
+func newEmbedder(cfg EmbeddingConfig) (Embedder, error) {
+	if cfg.Model == "" {
+		return nil, fmt.Errorf("retrieval: [retrieval.embedding] needs a model")
+	}
+	switch cfg.Provider {
+	case "openai":
+		keyEnv := cfg.APIKeyEnv
+		if keyEnv == "" {
+			keyEnv = "OPENAI_API_KEY"
+		}
+		return &OpenAIEmbedder{BaseURL: cfg.BaseURL, Model: cfg.Model, APIKey: os.Getenv(keyEnv), Dimensions: cfg.Dimensions}, nil
+	case "ollama":
+		return &OllamaEmbedder{BaseURL: cfg.BaseURL, Model: cfg.Model}, nil
+	case "":
+		return nil, fmt.Errorf("retrieval: [retrieval.embedding] needs a provider")
+	default:
+		return nil, fmt.Errorf("retrieval: unknown embedding provider %q", cfg.Provider)
+	}
+}
+
+// OpenAIEmbedder calls an OpenAI-compatible /embeddings endpoint.
+type OpenAIEmbedder struct {
+	BaseURL    string // default https://api.openai.com/v1
+	Model      string
+	APIKey     string
+	Dimensions int // 0 keeps the model's own size
+}
+
+func (e *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	base := strings.TrimSuffix(e.BaseURL, "/")
+	if base == "" {
+		base = "https://api.openai.com/v1"
+	}
+	req := map[string]any{"model": e.Model, "input": texts}
+	if e.Dimensions > 0 {
+		req["dimensions"] = e.Dimensions
+	}
+	header := http.Header{}
+	if e.APIKey != "" {
+		header.Set("Authorization", "Bearer "+e.APIKey)
+	}
+	var out struct {
+		Data []struct {
+			Index     int       `json:"index"`
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+	if err := call(ctx, http.MethodPost, base+"/embeddings", header, req, &out); err != nil {
+		return nil, err
+	}
+	vectors := make([][]float32, len(texts))
+	for _, d := range out.Data {
+		if d.Index < 0 || d.Index >= len(vectors) {
+			return nil, fmt.Errorf("embedding index %d out of range", d.Index)
+		}
+		vectors[d.Index] = d.Embedding
+	}
+	return vectors, nil
+}
+
+// OllamaEmbedder calls Ollama's /api/embed endpoint.
+type OllamaEmbedder struct {
+	BaseURL string // default http://localhost:11434
+	Model   string
+}
+
+func (e *OllamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	base := strings.TrimSuffix(e.BaseURL, "/")
+	if base == "" {
+		base = "http://localhost:11434"
+	}
+	var out struct {
+		Embeddings [][]float32 `json:"embeddings"`
+	}
+	if err := call(ctx, http.MethodPost, base+"/api/embed", nil, map[string]any{"model": e.Model, "input": texts}, &out); err != nil {
+		return nil, err
+	}
+	return out.Embeddings, nil
+}
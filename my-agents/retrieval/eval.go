@@ -0,0 +1,198 @@
+package retrieval
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Case is a labeled question of a retrieval dataset: the documents, by ID
+// or source, whose chunks answer it. Datasets are JSON Lines files:
+//
+//	{"question": "How long do refunds take?", "documents": ["policies/refunds.md"]}
+//	{"question": "Who approves leave?", "documents": ["hr-handbook"], "workflow": "hr"}
+type Case struct {
+	Question  string   `json:"question"`
+	Documents []string `json:"documents"`
+	// Workflow searches the way this entry route is configured to; empty
+	// uses the [retrieval] settings.
+	Workflow string `json:"workflow,omitempty"`
+	// Collections are searched instead of those the workflow or classifier
+	// select, as the collections metadata of a request would.
+	Collections []string `json:"collections,omitempty"`
+	// Filter and Tenant narrow the search as a request's filter metadata
+	// and tenant would.
+	Filter string `json:"filter,omitempty"`
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// LoadCases reads a JSON Lines dataset; blank lines are skipped.
+func LoadCases(path string) ([]Case, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var cases []Case
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 4*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var c Case
+		if err := json.Unmarshal([]byte(text), &c); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		if c.Question == "" || len(c.Documents) == 0 {
+			return nil, fmt.Errorf("%s:%d: needs a question and its documents", path, line)
+		}
+		if _, err := ParseFilter(c.Filter); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		cases = append(cases, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("%s has no questions", path)
+	}
+	return cases, nil
+}
+
+// EvalOptions override how an evaluation searches, so one stage can be
+// measured against the configured pipeline.
+type EvalOptions struct {
+	Ks     []int  // cutoffs to report recall and hit rate at (default 1, 3, 5, 10)
+	Mode   string // replaces every case's mode when set
+	Rerank *bool  // replaces every case's reranking when set
+}
+
+// CaseResult is how one question fared.
+type CaseResult struct {
+	Case
+	// Ranks are the 1-based ranks of the first chunk of each labeled
+	// document, 0 where none was retrieved within the largest cutoff.
+	Ranks     []int    `json:"ranks"`
+	Retrieved []string `json:"retrieved"` // document IDs of the chunks, best first
+	Error     string   `json:"error,omitempty"`
+}
+
+// Report holds a dataset's retrieval metrics. A labeled document counts as
+// retrieved at k when any of its chunks ranks within the first k; chunks
+// are what the agents' prompts get, so ranks are of chunks, not documents.
+type Report struct {
+	Questions int `json:"questions"`
+	Failed    int `json:"failed"` // questions the search errored on; they score 0
+	// RecallAt is the mean fraction of a question's documents retrieved
+	// within k, by k.
+	RecallAt map[int]float64 `json:"recall_at"`
+	// HitRateAt is the fraction of questions with at least one of their
+	// documents retrieved within k, by k.
+	HitRateAt map[int]float64 `json:"hit_rate_at"`
+	// MRR is the mean reciprocal rank of the first relevant chunk, 0 for a
+	// question none was retrieved for.
+	MRR   float64      `json:"mrr"`
+	Ks    []int        `json:"ks"`
+	Cases []CaseResult `json:"cases"`
+}
+
+// Evaluate retrieves for every case and scores the rankings.
+func (r *Retriever) Evaluate(ctx context.Context, cases []Case, opts EvalOptions) (*Report, error) {
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("no questions to evaluate")
+	}
+	if opts.Rerank != nil && *opts.Rerank && r.reranker == nil {
+		return nil, fmt.Errorf("reranking needs a [retrieval.rerank] provider")
+	}
+	ks := slices.Clone(opts.Ks)
+	if len(ks) == 0 {
+		ks = []int{1, 3, 5, 10}
+	}
+	slices.Sort(ks)
+	ks = slices.Compact(ks)
+	if ks[0] <= 0 {
+		return nil, fmt.Errorf("cutoffs must be positive")
+	}
+	if opts.Mode != "" {
+		if err := validModes(Config{Mode: opts.Mode}); err != nil {
+			return nil, err
+		}
+		if opts.Mode != ModeVector && !r.keyworded {
+			return nil, fmt.Errorf("%s search needs the keyword index; set a keyword or hybrid mode in [retrieval] and ingest the documents", opts.Mode)
+		}
+	}
+	depth := ks[len(ks)-1]
+
+	rep := &Report{Questions: len(cases), RecallAt: make(map[int]float64), HitRateAt: make(map[int]float64), Ks: ks}
+	for _, c := range cases {
+		s := r.search(c.Workflow)
+		if opts.Mode != "" {
+			s.Mode = opts.Mode
+		}
+		if opts.Rerank != nil {
+			s.Rerank = opts.Rerank
+		}
+		s.TopK = depth
+		s.Candidates = max(s.Candidates, depth)
+
+		res := CaseResult{Case: c, Ranks: make([]int, len(c.Documents)), Retrieved: []string{}}
+		collections, err := r.selectCollections(ctx, s, c.Collections, c.Question)
+		var matches []Match
+		if err == nil {
+			filter, _ := ParseFilter(c.Filter)
+			if filter, err = r.filter(s, Request{Filter: filter, Tenant: c.Tenant}); err == nil {
+				matches, err = r.retrieve(ctx, s, collections, filter, c.Question)
+			}
+		}
+		if err != nil {
+			res.Error = err.Error()
+			rep.Failed++
+		}
+		for rank, m := range matches {
+			res.Retrieved = append(res.Retrieved, m.DocumentID)
+			for i, doc := range c.Documents {
+				if res.Ranks[i] == 0 && (doc == m.DocumentID || doc == m.Source) {
+					res.Ranks[i] = rank + 1
+				}
+			}
+		}
+
+		first := 0
+		for _, rank := range res.Ranks {
+			if rank > 0 && (first == 0 || rank < first) {
+				first = rank
+			}
+		}
+		if first > 0 {
+			rep.MRR += 1 / float64(first)
+		}
+		for _, k := range ks {
+			found := 0
+			for _, rank := range res.Ranks {
+				if rank > 0 && rank <= k {
+					found++
+				}
+			}
+			rep.RecallAt[k] += float64(found) / float64(len(c.Documents))
+			if found > 0 {
+				rep.HitRateAt[k]++
+			}
+		}
+		rep.Cases = append(rep.Cases, res)
+	}
+
+	n := float64(len(cases))
+	rep.MRR /= n
+	for _, k := range ks {
+		rep.RecallAt[k] /= n
+		rep.HitRateAt[k] /= n
+	}
+	return rep, nil
+}
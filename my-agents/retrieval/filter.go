@@ -0,0 +1,255 @@
+package retrieval
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"my-agents/tenant"
+)
+
+// FilterKey is the event metadata key holding a filter narrowing the
+// chunks a request retrieves, in ParseFilter's syntax. TenantKey is the
+// chunk metadata key holding the tenant a chunk belongs to.
+const (
+	FilterKey = "filter"
+	TenantKey = tenant.MetadataKey
+)
+
+// Filter operators.
+const (
+	OpEq = "="
+	OpNe = "!="
+	OpIn = "in"
+	OpGt = ">"
+	OpGe = ">="
+	OpLt = "<"
+	OpLe = "<="
+)
+
+// Condition is one predicate on a chunk's metadata. The keys "source" and
+// "document_id" are the chunk's own fields; a key a chunk lacks has the
+// value "".
+type Condition struct {
+	Key    string
+	Op     string
+	Values []string // one, except for OpIn
+}
+
+// Filter holds the conditions a chunk must all meet.
+type Filter []Condition
+
+// ParseFilter parses conditions joined by "and" or ";":
+//
+//	author = alice and date >= 2024-01-01
+//	tenant_id in (acme, globex); draft != true
+//
+// Values may be quoted. Ordering compares numbers as numbers and RFC 3339
+// timestamps or dates as times, anything else as text.
+func ParseFilter(s string) (Filter, error) {
+	var f Filter
+	for _, part := range splitConditions(s) {
+		c, err := parseCondition(part)
+		if err != nil {
+			return nil, fmt.Errorf("filter %q: %w", part, err)
+		}
+		f = append(f, c)
+	}
+	return f, nil
+}
+
+// splitConditions splits s at ";" and at "and" between words, outside
+// quotes and parentheses.
+func splitConditions(s string) []string {
+	var parts []string
+	var quote rune
+	depth, start := 0, 0
+	cut := func(end, next int) {
+		if part := strings.TrimSpace(s[start:end]); part != "" {
+			parts = append(parts, part)
+		}
+		start = next
+	}
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case depth > 0:
+		case r == ';':
+			cut(i, i+1)
+		case r == ' ' && len(s) > i+5 && strings.EqualFold(s[i:i+5], " and "):
+			cut(i, i+5)
+		}
+	}
+	cut(len(s), len(s))
+	return parts
+}
+
+// operators are tried longest first, so ">=" isn't read as ">".
+var operators = []string{OpNe, OpGe, OpLe, OpEq, OpGt, OpLt}
+
+func parseCondition(s string) (Condition, error) {
+	if key, list, ok := cutWord(s, "in"); ok {
+		list = strings.TrimSpace(list)
+		if !strings.HasPrefix(list, "(") || !strings.HasSuffix(list, ")") {
+			return Condition{}, fmt.Errorf("in needs a parenthesized list")
+		}
+		c := Condition{Key: key, Op: OpIn}
+		for _, v := range strings.Split(list[1:len(list)-1], ",") {
+			c.Values = append(c.Values, unquote(v))
+		}
+		return c, nil
+	}
+	for _, op := range operators {
+		if key, value, ok := strings.Cut(s, op); ok {
+			key = strings.TrimSpace(key)
+			if key == "" {
+				return Condition{}, fmt.Errorf("missing key")
+			}
+			return Condition{Key: key, Op: op, Values: []string{unquote(value)}}, nil
+		}
+	}
+	return Condition{}, fmt.Errorf("want key, operator and value")
+}
+
+// cutWord cuts s around the first standalone, case-insensitive word.
+func cutWord(s, word string) (before, after string, ok bool) {
+	fields := strings.Fields(s)
+	if len(fields) < 3 || !strings.EqualFold(fields[1], word) {
+		return "", "", false
+	}
+	i := strings.Index(strings.ToLower(s), " "+word+" ")
+	if i < 0 {
+		return "", "", false
+	}
+	return strings.TrimSpace(s[:i]), s[i+len(word)+2:], true
+}
+
+func unquote(v string) string {
+	v = strings.TrimSpace(v)
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
+
+// String renders f in ParseFilter's syntax.
+func (f Filter) String() string {
+	parts := make([]string, len(f))
+	for i, c := range f {
+		if c.Op == OpIn {
+			values := make([]string, len(c.Values))
+			for j, v := range c.Values {
+				values[j] = quote(v)
+			}
+			parts[i] = fmt.Sprintf("%s in (%s)", c.Key, strings.Join(values, ", "))
+			continue
+		}
+		parts[i] = fmt.Sprintf("%s %s %s", c.Key, c.Op, quote(c.Values[0]))
+	}
+	return strings.Join(parts, " and ")
+}
+
+// quote quotes v when it wouldn't parse back as it is.
+func quote(v string) string {
+	if v == "" || strings.ContainsAny(v, " ;,()'\"") {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+// Match reports whether c meets every condition of f.
+func (f Filter) Match(c Chunk) bool {
+	for _, cond := range f {
+		v := fieldOf(c, cond.Key)
+		switch cond.Op {
+		case OpEq:
+			if v != cond.Values[0] {
+				return false
+			}
+		case OpNe:
+			if v == cond.Values[0] {
+				return false
+			}
+		case OpIn:
+			if !slices.Contains(cond.Values, v) {
+				return false
+			}
+		default:
+			if v == "" {
+				return false
+			}
+			n := compare(v, cond.Values[0])
+			if cond.Op == OpGt && n <= 0 || cond.Op == OpGe && n < 0 || cond.Op == OpLt && n >= 0 || cond.Op == OpLe && n > 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Exact returns the conditions of f on exact values (=, != and in) other
+// than on empty ones, which stores can apply themselves; the rest are
+// applied to what they return.
+func (f Filter) Exact() Filter {
+	var exact Filter
+	for _, c := range f {
+		if (c.Op == OpEq || c.Op == OpNe || c.Op == OpIn) && !slices.Contains(c.Values, "") {
+			exact = append(exact, c)
+		}
+	}
+	return exact
+}
+
+// fieldOf returns c's value for a filter key.
+func fieldOf(c Chunk, key string) string {
+	switch key {
+	case "source":
+		return c.Source
+	case "document_id":
+		return c.DocumentID
+	default:
+		return c.Metadata[key]
+	}
+}
+
+// compare orders a and b as numbers, times or text, in that order of
+// preference.
+func compare(a, b string) int {
+	if x, err := strconv.ParseFloat(a, 64); err == nil {
+		if y, err := strconv.ParseFloat(b, 64); err == nil {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	if x, ok := parseTime(a); ok {
+		if y, ok := parseTime(b); ok {
+			return x.Compare(y)
+		}
+	}
+	return strings.Compare(a, b)
+}
+
+func parseTime(s string) (time.Time, bool) {
+	for _, layout := range []string{time.RFC3339, time.DateTime, time.DateOnly} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
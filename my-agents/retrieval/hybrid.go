@@ -0,0 +1,351 @@
+package retrieval
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Search modes.
+const (
+	ModeVector  = "vector"  // embedding similarity only
+	ModeKeyword = "keyword" // BM25 over the keyword index only
+	ModeHybrid  = "hybrid"  // both, fused by rank
+)
+
+// rrfK damps reciprocal rank fusion so the top few ranks of one search
+// don't drown out agreement between both.
+const rrfK = 60
+
+// Search is how one workflow retrieves, in [retrieval.workflows.<route>];
+// unset fields keep the [retrieval] settings.
+//
+//	[retrieval.workflows.support]
+//	mode = "hybrid"
+//	top_k = 6
+//	rerank = true
+//	collections = ["product", "documents"]
+//	filter = "type = markdown and date >= 2024-01-01"
+type Search struct {
+	Mode string `toml:"mode"`  // ModeVector, ModeKeyword or ModeHybrid
+	TopK int    `toml:"top_k"` // chunks retrieved per request
+	// Candidates is how many chunks each search contributes before they
+	// are fused and reranked (default 4×TopK, or TopK for a vector search
+	// that isn't reranked).
+	Candidates int `toml:"candidates"`
+	// Rerank reorders the candidates with [retrieval.rerank]; it defaults
+	// to whether a reranker is configured.
+	Rerank *bool `toml:"rerank"`
+	// Collections are searched when a request names none; unset leaves the
+	// choice to the classifier, else the main collection.
+	Collections []string `toml:"collections"`
+	// Filter narrows the chunks searched, in ParseFilter's syntax; a
+	// workflow's applies on top of the [retrieval] one.
+	Filter string `toml:"filter"`
+}
+
+// search resolves the settings a workflow retrieves with.
+func (r *Retriever) search(workflow string) Search {
+	s := r.defaults
+	if w, ok := r.workflows[workflow]; ok {
+		if w.Mode != "" {
+			s.Mode = w.Mode
+		}
+		if w.TopK > 0 {
+			s.TopK = w.TopK
+		}
+		if w.Candidates > 0 {
+			s.Candidates = w.Candidates
+		}
+		if w.Rerank != nil {
+			s.Rerank = w.Rerank
+		}
+		if len(w.Collections) > 0 {
+			s.Collections = w.Collections
+		}
+		s.Filter = joinFilters(s.Filter, w.Filter)
+	}
+	if s.Candidates <= 0 {
+		s.Candidates = s.TopK
+		if s.Mode != ModeVector || r.reranks(s) {
+			s.Candidates = 4 * s.TopK
+		}
+	}
+	return s
+}
+
+// reranks reports whether s reorders its candidates.
+func (r *Retriever) reranks(s Search) bool {
+	return r.reranker != nil && (s.Rerank == nil || *s.Rerank)
+}
+
+// validModes checks every search uses a known mode and collections, and
+// asks to rerank only with a reranker configured.
+func validModes(cfg Config) error {
+	check := func(where string, s Search) error {
+		switch s.Mode {
+		case "", ModeVector, ModeKeyword, ModeHybrid:
+		default:
+			return fmt.Errorf("retrieval: %s: unknown mode %q", where, s.Mode)
+		}
+		if s.Rerank != nil && *s.Rerank && cfg.Rerank.Provider == "" {
+			return fmt.Errorf("retrieval: %s: rerank needs a [retrieval.rerank] provider", where)
+		}
+		if _, err := ParseFilter(s.Filter); err != nil {
+			return fmt.Errorf("retrieval: %s: %w", where, err)
+		}
+		for _, name := range s.Collections {
+			if _, ok := cfg.Collections[name]; !ok && name != cmp.Or(cfg.Collection, DefaultCollection) {
+				return fmt.Errorf("retrieval: %s: unknown collection %q", where, name)
+			}
+		}
+		return nil
+	}
+	if err := check("[retrieval]", Search{Mode: cfg.Mode, Filter: cfg.Filter}); err != nil {
+		return err
+	}
+	for route, s := range cfg.Workflows {
+		if err := check("workflow "+route, s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// needsKeyword reports whether any search cfg configures uses the keyword
+// index, which must then be kept as documents are indexed.
+func needsKeyword(cfg Config) bool {
+	if cfg.Mode == ModeKeyword || cfg.Mode == ModeHybrid {
+		return true
+	}
+	for _, s := range cfg.Workflows {
+		if s.Mode == ModeKeyword || s.Mode == ModeHybrid {
+			return true
+		}
+	}
+	return false
+}
+
+// Request is what a retrieval is for.
+type Request struct {
+	Query string
+	// Workflow is the entry route whose settings the search uses.
+	Workflow string
+	// Collections are searched instead of those the workflow or classifier
+	// select.
+	Collections []string
+	// Filter narrows the chunks searched on top of the configured filters;
+	// it can't name TenantKey, the tenant being the caller's.
+	Filter Filter
+	// Tenant is the request's tenant, which [retrieval] tenant_scoped
+	// restricts it to: the authenticated caller's, never one it names.
+	Tenant string
+}
+
+// RetrieveFor returns the chunks relevant to query for workflow, best first,
+// searching the way the workflow is configured to. In hybrid mode the
+// vector and keyword candidates are fused by reciprocal rank; a reranker,
+// when used, then reorders them. A failed rerank keeps the fused order.
+func (r *Retriever) RetrieveFor(ctx context.Context, workflow, query string) ([]Match, error) {
+	return r.RetrieveRequest(ctx, Request{Query: query, Workflow: workflow})
+}
+
+// RetrieveRequest is RetrieveFor for a request naming its own collections
+// or filter.
+func (r *Retriever) RetrieveRequest(ctx context.Context, req Request) ([]Match, error) {
+	s := r.search(req.Workflow)
+	selected, err := r.selectCollections(ctx, s, req.Collections, req.Query)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := r.filter(s, req)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := r.retrieve(ctx, s, selected, filter, req.Query)
+	if err == nil {
+		r.touchMatches(ctx, matches)
+	}
+	return matches, err
+}
+
+// filter combines the conditions a request's chunks must meet.
+func (r *Retriever) filter(s Search, req Request) (Filter, error) {
+	filter, err := ParseFilter(s.Filter)
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range req.Filter {
+		if c.Key == TenantKey {
+			return nil, fmt.Errorf("request filters can't name %s; the tenant is the caller's", TenantKey)
+		}
+	}
+	if r.tenantScoped {
+		// Untenanted requests see only untenanted chunks
+		filter = append(filter, Condition{Key: TenantKey, Op: OpEq, Values: []string{req.Tenant}})
+	}
+	return append(filter, req.Filter...), nil
+}
+
+// retrieve returns the chunks of collections meeting filter that are
+// relevant to query, searching as s says. Each search's candidates are the
+// best of all the collections.
+func (r *Retriever) retrieve(ctx context.Context, s Search, collections []*collection, filter Filter, query string) ([]Match, error) {
+	var vector, keyword []Match
+	var err error
+	if s.Mode != ModeKeyword {
+		if vector, err = r.vectorSearch(ctx, collections, filter, query, s.Candidates); err != nil {
+			return nil, err
+		}
+	}
+	if s.Mode == ModeKeyword || s.Mode == ModeHybrid {
+		if !r.keyworded {
+			return nil, fmt.Errorf("%s search needs the keyword index", s.Mode)
+		}
+		for _, c := range collections {
+			found, err := c.keyword.Search(ctx, query, fetch(s.Candidates, filter), filter)
+			if err != nil {
+				return nil, err
+			}
+			for _, m := range from(c, found) {
+				if filter.Match(m.Chunk) {
+					keyword = append(keyword, m)
+				}
+			}
+		}
+		keyword = best(keyword, s.Candidates)
+	}
+
+	var matches []Match
+	switch s.Mode {
+	case ModeKeyword:
+		matches = keyword
+	case ModeHybrid:
+		matches = fuse(vector, keyword)
+	default:
+		matches = vector
+	}
+	if r.reranks(s) && len(matches) > 1 {
+		scores, err := r.reranker.Rerank(ctx, query, matches)
+		if err != nil {
+			core.Logger().Warn().Err(err).Msg("Failed to rerank document chunks")
+		} else {
+			matches = rerank(matches, scores)
+		}
+	}
+	if len(matches) > s.TopK {
+		matches = matches[:s.TopK]
+	}
+	return matches, nil
+}
+
+// vectorSearch returns the k chunks of collections meeting filter that are
+// closest to query by embedding and reach the minimum similarity. With
+// cold storage, archived documents whose chunks' mean vector is as close
+// to the query as the weakest match found are brought back and the search
+// is run again.
+func (r *Retriever) vectorSearch(ctx context.Context, collections []*collection, filter Filter, query string, k int) ([]Match, error) {
+	vectors, err := r.embedder.Embed(ctx, []string{query})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	if len(vectors) != 1 {
+		return nil, fmt.Errorf("embedding returned %d vectors for one query", len(vectors))
+	}
+	search := func() ([]Match, error) {
+		var kept []Match
+		for _, c := range collections {
+			matches, err := c.store.Search(ctx, vectors[0], fetch(k, filter), filter)
+			if err != nil {
+				return nil, fmt.Errorf("failed to search %s: %w", c.name, err)
+			}
+			for _, m := range from(c, matches) {
+				if m.Score >= r.minScore && filter.Match(m.Chunk) {
+					kept = append(kept, m)
+				}
+			}
+		}
+		return best(kept, k), nil
+	}
+	matches, err := search()
+	if err != nil {
+		return nil, err
+	}
+	threshold := 0.0 // the restore score
+	if len(matches) >= k && k > 0 {
+		threshold = max(r.minScore, matches[len(matches)-1].Score)
+	}
+	restored, err := r.restoreNear(ctx, collections, filter, vectors[0], threshold)
+	if err != nil {
+		return nil, err
+	}
+	if restored {
+		return search()
+	}
+	return matches, nil
+}
+
+// fetch is how many chunks a store returns for k to meet filter: more when
+// some of its conditions are applied to what the store returns, as they
+// drop some.
+func fetch(k int, filter Filter) int {
+	if len(filter.Exact()) < len(filter) {
+		return 4 * k
+	}
+	return k
+}
+
+// joinFilters joins filters in ParseFilter's syntax so both apply.
+func joinFilters(a, b string) string {
+	switch {
+	case strings.TrimSpace(a) == "":
+		return b
+	case strings.TrimSpace(b) == "":
+		return a
+	}
+	return a + "; " + b
+}
+
+// from marks matches as found in c.
+func from(c *collection, matches []Match) []Match {
+	for i := range matches {
+		matches[i].Collection = c.name
+	}
+	return matches
+}
+
+// best returns the k highest scoring matches, best first.
+func best(matches []Match, k int) []Match {
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches
+}
+
+// fuse merges ranked result lists by reciprocal rank fusion: a chunk scores
+// the sum of 1/(rrfK+rank) over the lists it appears in, so chunks both
+// searches rank well come first. Ties keep the first list's order.
+func fuse(lists ...[]Match) []Match {
+	var fused []Match
+	at := make(map[string]int)
+	for _, list := range lists {
+		for rank, m := range list {
+			score := 1 / float64(rrfK+rank+1)
+			id := m.Collection + "/" + m.ID
+			if i, ok := at[id]; ok {
+				fused[i].Score += score
+				continue
+			}
+			at[id] = len(fused)
+			m.Score = score
+			fused = append(fused, m)
+		}
+	}
+	sort.SliceStable(fused, func(i, j int) bool { return fused[i].Score > fused[j].Score })
+	return fused
+}
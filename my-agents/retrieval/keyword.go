@@ -0,0 +1,238 @@
+package retrieval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	_ "github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/mapping"
+	"github.com/blevesearch/bleve/v2/search/query"
+	index "github.com/blevesearch/bleve_index_api"
+	bolt "go.etcd.io/bbolt"
+
+	"my-agents/storage"
+)
+
+// Keyword is a BM25 full-text index of a collection's chunks, kept beside
+// the vector store so keyword and vector search see the same chunks. It is
+// a bleve index in a directory, which each operation opens and closes:
+// searches share it, and an update holds it alone only while it writes,
+// so the processes serving a collection take turns rather than the first
+// locking the others out.
+type Keyword struct {
+	dir string
+	// mu orders the process's own operations; the index's file lock only
+	// tells processes apart.
+	mu sync.RWMutex
+}
+
+// keywordDoc is how a chunk is kept in the index. Only content is analyzed;
+// the rest is stored to rebuild the chunk from a hit. Meta indexes each
+// metadata value whole, for filters.
+type keywordDoc struct {
+	DocumentID string            `json:"document_id"`
+	Source     string            `json:"source"`
+	Content    string            `json:"content"`
+	Metadata   string            `json:"metadata"`
+	Meta       map[string]string `json:"meta"`
+}
+
+// OpenKeyword returns the keyword index at dir, creating it if needed.
+func OpenKeyword(dir string) (*Keyword, error) {
+	k := &Keyword{dir: dir}
+	return k, k.update(func(bleve.Index) error { return nil })
+}
+
+// keywordWait is how long an operation waits for another process's update
+// to finish with the index before failing.
+const keywordWait = "10s"
+
+// open opens the index, read only to search it.
+func (k *Keyword) open(readOnly bool) (bleve.Index, error) {
+	runtime := map[string]any{"bolt_timeout": keywordWait, "read_only": readOnly}
+	idx, err := bleve.OpenUsing(k.dir, runtime)
+	if errors.Is(err, bleve.ErrorIndexPathDoesNotExist) && !readOnly {
+		if err := os.MkdirAll(filepath.Dir(k.dir), 0o755); err != nil {
+			return nil, err
+		}
+		idx, err = bleve.NewUsing(k.dir, keywordMapping(), bleve.Config.DefaultIndexType, bleve.Config.DefaultKVStore, runtime)
+	}
+	if errors.Is(err, bolt.ErrTimeout) {
+		return nil, fmt.Errorf("keyword index %s is being updated by another process", k.dir)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keyword index %s: %w", k.dir, err)
+	}
+	return idx, nil
+}
+
+// search runs fn on the index opened read only, alongside other searches.
+func (k *Keyword) search(fn func(bleve.Index) error) error {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	idx, err := k.open(true)
+	if err != nil {
+		return err
+	}
+	return errors.Join(fn(idx), idx.Close())
+}
+
+// update runs fn on the index with no other operation on it.
+func (k *Keyword) update(fn func(bleve.Index) error) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	idx, err := k.open(false)
+	if err != nil {
+		return err
+	}
+	return errors.Join(fn(idx), idx.Close())
+}
+
+func keywordMapping() mapping.IndexMapping {
+	stored := bleve.NewKeywordFieldMapping()
+	stored.IncludeInAll = false
+	text := bleve.NewTextFieldMapping()
+	text.Analyzer = "en"
+	unindexed := bleve.NewTextFieldMapping()
+	unindexed.Index = false
+	unindexed.IncludeInAll = false
+
+	doc := bleve.NewDocumentStaticMapping()
+	doc.AddFieldMappingsAt("document_id", stored)
+	doc.AddFieldMappingsAt("source", unindexed)
+	doc.AddFieldMappingsAt("content", text)
+	doc.AddFieldMappingsAt("metadata", unindexed)
+	meta := bleve.NewDocumentMapping()
+	meta.DefaultAnalyzer = keyword.Name
+	doc.AddSubDocumentMapping("meta", meta)
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = doc
+	m.ScoringModel = index.BM25Scoring
+	return m
+}
+
+// Upsert adds chunks, replacing those with the same ID.
+func (k *Keyword) Upsert(ctx context.Context, chunks []Chunk) error {
+	return k.update(func(idx bleve.Index) error {
+		batch := idx.NewBatch()
+		for _, c := range chunks {
+			meta, err := json.Marshal(c.Metadata)
+			if err != nil {
+				return err
+			}
+			doc := keywordDoc{DocumentID: c.DocumentID, Source: c.Source, Content: c.Content, Metadata: string(meta), Meta: c.Metadata}
+			if err := batch.Index(c.ID, doc); err != nil {
+				return fmt.Errorf("failed to index chunk %s: %w", c.ID, err)
+			}
+		}
+		return idx.Batch(batch)
+	})
+}
+
+// Search returns the n chunks whose content best matches text by BM25,
+// best first, of those meeting the exact conditions of filter. Their Score
+// is the BM25 score, which is not bounded. Chunks indexed before metadata
+// was don't meet any condition on it.
+func (k *Keyword) Search(ctx context.Context, text string, n int, filter Filter) ([]Match, error) {
+	match := bleve.NewMatchQuery(text)
+	match.SetField("content")
+	q := bleve.NewBooleanQuery()
+	q.AddMust(match)
+	for _, c := range filter.Exact() {
+		field := "meta." + c.Key
+		switch c.Key {
+		case "source":
+			continue // not indexed; left to the retriever
+		case "document_id":
+			field = c.Key
+		}
+		values := bleve.NewDisjunctionQuery()
+		for _, v := range c.Values {
+			term := bleve.NewTermQuery(v)
+			term.SetField(field)
+			values.AddQuery(term)
+		}
+		if c.Op == OpNe {
+			q.AddMustNot(values)
+		} else {
+			q.AddMust(values)
+		}
+	}
+	req := bleve.NewSearchRequestOptions(q, n, 0, false)
+	req.Fields = []string{"document_id", "source", "content", "metadata"}
+	var res *bleve.SearchResult
+	err := k.search(func(idx bleve.Index) error {
+		var err error
+		res, err = idx.SearchInContext(ctx, req)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search keyword index: %w", err)
+	}
+	matches := make([]Match, 0, len(res.Hits))
+	for _, hit := range res.Hits {
+		m := Match{Chunk: Chunk{ID: hit.ID}, Score: hit.Score}
+		m.DocumentID, _ = hit.Fields["document_id"].(string)
+		m.Source, _ = hit.Fields["source"].(string)
+		m.Content, _ = hit.Fields["content"].(string)
+		if meta, _ := hit.Fields["metadata"].(string); meta != "" {
+			if err := json.Unmarshal([]byte(meta), &m.Metadata); err != nil {
+				return nil, fmt.Errorf("chunk %s: %w", hit.ID, err)
+			}
+		}
+		matches = append(matches, m)
+	}
+	return matches, nil
+}
+
+// DeleteDocument removes the chunks of the document with the ID.
+func (k *Keyword) DeleteDocument(ctx context.Context, documentID string) error {
+	q := query.NewTermQuery(documentID)
+	q.SetField("document_id")
+	return k.update(func(idx bleve.Index) error {
+		for {
+			res, err := idx.SearchInContext(ctx, bleve.NewSearchRequestOptions(q, 1000, 0, false))
+			if err != nil {
+				return err
+			}
+			if len(res.Hits) == 0 {
+				return nil
+			}
+			batch := idx.NewBatch()
+			for _, hit := range res.Hits {
+				batch.Delete(hit.ID)
+			}
+			if err := idx.Batch(batch); err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// Close has nothing to release: the index is only open during an
+// operation.
+func (k *Keyword) Close() error { return nil }
+
+// keywordDir is where the collection's keyword index is kept by default:
+// beside the database, under keyword/.
+func keywordDir(cfg Config, storagePath, collection string) string {
+	if cfg.KeywordPath != "" {
+		return cfg.KeywordPath
+	}
+	base := cfg.Path
+	if base == "" {
+		base = storagePath
+	}
+	if base == "" {
+		base = storage.DefaultPath
+	}
+	return filepath.Join(filepath.Dir(base), "keyword", collection)
+}
@@ -0,0 +1,179 @@
+package retrieval
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// Pgvector keeps a collection's chunks in PostgreSQL with the pgvector
+// extension, ranked by cosine distance in the database.
+type Pgvector struct {
+	db         *sql.DB
+	collection string
+}
+
+// OpenPgvector connects to dsn, creating the extension and table if needed.
+func OpenPgvector(dsn, collection string) (*Pgvector, error) {
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pgvector: %w", err)
+	}
+	for _, stmt := range []string{
+		`CREATE EXTENSION IF NOT EXISTS vector`,
+		`CREATE TABLE IF NOT EXISTS retrieval_chunks (
+			collection  TEXT NOT NULL,
+			id          TEXT NOT NULL,
+			document_id TEXT NOT NULL,
+			source      TEXT NOT NULL,
+			content     TEXT NOT NULL,
+			metadata    JSONB NOT NULL,
+			embedding   vector NOT NULL,
+			PRIMARY KEY (collection, id)
+		)`,
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("failed to migrate pgvector schema: %w", err)
+		}
+	}
+	return &Pgvector{db: db, collection: collection}, nil
+}
+
+func (p *Pgvector) Upsert(ctx context.Context, chunks []Chunk) error {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, c := range chunks {
+		meta, err := json.Marshal(c.Metadata)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO retrieval_chunks (collection, id, document_id, source, content, metadata, embedding)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7::vector)
+			 ON CONFLICT (collection, id) DO UPDATE SET document_id = excluded.document_id, source = excluded.source,
+			 content = excluded.content, metadata = excluded.metadata, embedding = excluded.embedding`,
+			p.collection, c.ID, c.DocumentID, c.Source, c.Content, string(meta), vectorLiteral(c.Vector))
+		if err != nil {
+			return fmt.Errorf("failed to save chunk %s: %w", c.ID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+func (p *Pgvector) Search(ctx context.Context, vector []float32, k int, filter Filter) ([]Match, error) {
+	args := []any{vectorLiteral(vector), p.collection, k}
+	where := ""
+	for _, c := range filter.Exact() {
+		column := "metadata->>" + placeholder(&args, c.Key)
+		switch c.Key {
+		case "source", "document_id":
+			column = c.Key
+		}
+		switch c.Op {
+		case OpNe:
+			// A chunk without the key has "" for it, which isn't the value
+			where += fmt.Sprintf(" AND %s IS DISTINCT FROM %s", column, placeholder(&args, c.Values[0]))
+		default:
+			where += fmt.Sprintf(" AND %s = ANY(%s)", column, placeholder(&args, c.Values))
+		}
+	}
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT id, document_id, source, content, metadata, 1 - (embedding <=> $1::vector)
+		 FROM retrieval_chunks WHERE collection = $2`+where+` ORDER BY embedding <=> $1::vector LIMIT $3`,
+		args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search chunks: %w", err)
+	}
+	defer rows.Close()
+	var matches []Match
+	for rows.Next() {
+		var m Match
+		var meta []byte
+		if err := rows.Scan(&m.ID, &m.DocumentID, &m.Source, &m.Content, &meta, &m.Score); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(meta, &m.Metadata); err != nil {
+			return nil, fmt.Errorf("chunk %s: %w", m.ID, err)
+		}
+		matches = append(matches, m)
+	}
+	return matches, rows.Err()
+}
+
+func (p *Pgvector) Document(ctx context.Context, documentID string) ([]Chunk, error) {
+	rows, err := p.db.QueryContext(ctx,
+		`SELECT id, document_id, source, content, metadata, embedding::text FROM retrieval_chunks WHERE collection = $1 AND document_id = $2`, p.collection, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunks: %w", err)
+	}
+	defer rows.Close()
+	var chunks []Chunk
+	for rows.Next() {
+		var c Chunk
+		var meta []byte
+		var embedding string
+		if err := rows.Scan(&c.ID, &c.DocumentID, &c.Source, &c.Content, &meta, &embedding); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(meta, &c.Metadata); err != nil {
+			return nil, fmt.Errorf("chunk %s: %w", c.ID, err)
+		}
+		if c.Vector, err = parseVectorLiteral(embedding); err != nil {
+			return nil, fmt.Errorf("chunk %s: %w", c.ID, err)
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks, rows.Err()
+}
+
+func (p *Pgvector) DeleteDocument(ctx context.Context, documentID string) error {
+	_, err := p.db.ExecContext(ctx, `DELETE FROM retrieval_chunks WHERE collection = $1 AND document_id = $2`, p.collection, documentID)
+	return err
+}
+
+// placeholder adds v to args and returns its $n placeholder.
+func placeholder(args *[]any, v any) string {
+	*args = append(*args, v)
+	return "$" + strconv.Itoa(len(*args))
+}
+
+// vectorLiteral renders v in pgvector's text form, e.g. [0.1,0.2].
+func vectorLiteral(v []float32) string {
+	var b strings.Builder
+	b.WriteByte('[')
+	for i, f := range v {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(strconv.FormatFloat(float64(f), 'g', -1, 32))
+	}
+	b.WriteByte(']')
+	return b.String()
+}
+
+// parseVectorLiteral reads a vector in pgvector's text form.
+func parseVectorLiteral(s string) ([]float32, error) {
+	s = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(s), "["), "]")
+	if s == "" {
+		return nil, nil
+	}
+	fields := strings.Split(s, ",")
+	v := make([]float32, len(fields))
+	for i, f := range fields {
+		x, err := strconv.ParseFloat(strings.TrimSpace(f), 32)
+		if err != nil {
+			return nil, fmt.Errorf("bad vector: %w", err)
+		}
+		v[i] = float32(x)
+	}
+	return v, nil
+}
@@ -0,0 +1,185 @@
+package retrieval
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"my-agents/storage"
+)
+
+// Progress is how far the indexing of a document got: Done of its Total
+// chunks are embedded and stored.
+type Progress struct {
+	DocumentID string
+	Source     string
+	Done       int
+	Total      int
+	// Resumed is how many chunks an interrupted earlier attempt had
+	// stored, which this one skipped.
+	Resumed int
+}
+
+type progressKey struct{}
+
+// WithProgress returns ctx reporting the progress of the documents indexed
+// with it to report, after each batch stored.
+func WithProgress(ctx context.Context, report func(Progress)) context.Context {
+	return context.WithValue(ctx, progressKey{}, report)
+}
+
+func progressFrom(ctx context.Context) func(Progress) {
+	if report, ok := ctx.Value(progressKey{}).(func(Progress)); ok {
+		return report
+	}
+	return func(Progress) {}
+}
+
+// Checkpoint is a document whose indexing was interrupted, and how far it
+// got.
+type Checkpoint struct {
+	DocumentID string
+	Source     string
+	Collection string
+	// Tags are those the document was being indexed with.
+	Tags      map[string]string
+	Done      int
+	Total     int
+	UpdatedAt time.Time
+}
+
+// checkpoints remember, in the embedded database, how many chunks of each
+// document being indexed are stored, so indexing the same version again
+// after an interruption resumes from the last stored batch rather than
+// embedding everything again.
+type checkpoints struct {
+	db *sql.DB
+}
+
+func openCheckpoints(path string) (*checkpoints, error) {
+	db, err := storage.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	err = storage.Migrate(db,
+		`CREATE TABLE IF NOT EXISTS retrieval_checkpoints (
+			document_id TEXT PRIMARY KEY,
+			source      TEXT NOT NULL,
+			collection  TEXT NOT NULL,
+			tags        TEXT NOT NULL,
+			fingerprint TEXT NOT NULL,
+			done        INTEGER NOT NULL,
+			total       INTEGER NOT NULL,
+			updated_at  INTEGER NOT NULL
+		)`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &checkpoints{db: db}, nil
+}
+
+// done returns how many chunks of the document's version with fingerprint
+// are stored; 0 when it has no checkpoint, or one of another version.
+func (c *checkpoints) done(ctx context.Context, documentID, fingerprint string) (int, error) {
+	var fp string
+	var done int
+	err := c.db.QueryRowContext(ctx,
+		`SELECT fingerprint, done FROM retrieval_checkpoints WHERE document_id = ?`, documentID).Scan(&fp, &done)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && fp != fingerprint) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read checkpoint of %s: %w", documentID, err)
+	}
+	return done, nil
+}
+
+func (c *checkpoints) save(ctx context.Context, cp Checkpoint, fingerprint string) error {
+	tags, err := json.Marshal(cp.Tags)
+	if err != nil {
+		return err
+	}
+	_, err = c.db.ExecContext(ctx,
+		`INSERT INTO retrieval_checkpoints (document_id, source, collection, tags, fingerprint, done, total, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		 ON CONFLICT (document_id) DO UPDATE SET source = excluded.source, collection = excluded.collection, tags = excluded.tags,
+		 fingerprint = excluded.fingerprint, done = excluded.done, total = excluded.total, updated_at = excluded.updated_at`,
+		cp.DocumentID, cp.Source, cp.Collection, string(tags), fingerprint, cp.Done, cp.Total, time.Now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint of %s: %w", cp.DocumentID, err)
+	}
+	return nil
+}
+
+func (c *checkpoints) clear(ctx context.Context, documentID string) error {
+	if _, err := c.db.ExecContext(ctx, `DELETE FROM retrieval_checkpoints WHERE document_id = ?`, documentID); err != nil {
+		return fmt.Errorf("failed to clear checkpoint of %s: %w", documentID, err)
+	}
+	return nil
+}
+
+// Interrupted returns the documents whose indexing was interrupted, oldest
+// first. Indexing them again resumes where they stopped, as long as they
+// are unchanged.
+func (r *Retriever) Interrupted(ctx context.Context) ([]Checkpoint, error) {
+	if r.checkpoints == nil {
+		return nil, nil
+	}
+	return r.checkpoints.list(ctx, `ORDER BY updated_at`)
+}
+
+// Checkpoint returns how far the interrupted indexing of the document got,
+// or false when its indexing wasn't interrupted.
+func (r *Retriever) Checkpoint(ctx context.Context, documentID string) (Checkpoint, bool, error) {
+	if r.checkpoints == nil {
+		return Checkpoint{}, false, nil
+	}
+	cps, err := r.checkpoints.list(ctx, `WHERE document_id = ?`, documentID)
+	if err != nil || len(cps) == 0 {
+		return Checkpoint{}, false, err
+	}
+	return cps[0], true, nil
+}
+
+func (c *checkpoints) list(ctx context.Context, where string, args ...any) ([]Checkpoint, error) {
+	rows, err := c.db.QueryContext(ctx,
+		`SELECT document_id, source, collection, tags, done, total, updated_at FROM retrieval_checkpoints `+where, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+	defer rows.Close()
+	var out []Checkpoint
+	for rows.Next() {
+		var cp Checkpoint
+		var tags string
+		var updated int64
+		if err := rows.Scan(&cp.DocumentID, &cp.Source, &cp.Collection, &tags, &cp.Done, &cp.Total, &updated); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(tags), &cp.Tags); err != nil {
+			return nil, fmt.Errorf("checkpoint of %s: %w", cp.DocumentID, err)
+		}
+		cp.UpdatedAt = time.Unix(0, updated)
+		out = append(out, cp)
+	}
+	return out, rows.Err()
+}
+
+// fingerprint identifies the chunks a version of a document is cut into,
+// in the collection they go to.
+func fingerprint(collection string, chunks []Chunk) string {
+	h := sha256.New()
+	h.Write([]byte(collection))
+	for _, c := range chunks {
+		h.Write([]byte{0})
+		h.Write([]byte(c.ID))
+		h.Write([]byte{0})
+		h.Write([]byte(c.Content))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,173 @@
+package retrieval
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// Qdrant keeps a collection's chunks in a Qdrant collection over its REST
+// API. The collection is created with cosine distance on the first upsert.
+type Qdrant struct {
+	URL        string // e.g. http://localhost:6333
+	Collection string
+	APIKey     string
+
+	mu    sync.Mutex
+	ready bool // the collection exists
+}
+
+// qdrantPayload is what a point carries besides its vector.
+type qdrantPayload struct {
+	ChunkID    string            `json:"chunk_id"`
+	DocumentID string            `json:"document_id,omitempty"`
+	Source     string            `json:"source,omitempty"`
+	Content    string            `json:"content"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+}
+
+func (q *Qdrant) endpoint(path string) string {
+	return strings.TrimSuffix(q.URL, "/") + "/collections/" + url.PathEscape(q.Collection) + path
+}
+
+func (q *Qdrant) header() http.Header {
+	h := http.Header{}
+	if q.APIKey != "" {
+		h.Set("api-key", q.APIKey)
+	}
+	return h
+}
+
+// ensure creates the collection for vectors of size dims unless it exists.
+func (q *Qdrant) ensure(ctx context.Context, dims int) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.ready {
+		return nil
+	}
+	err := call(ctx, http.MethodGet, q.endpoint(""), q.header(), nil, nil)
+	if isNotFound(err) {
+		err = call(ctx, http.MethodPut, q.endpoint(""), q.header(),
+			map[string]any{"vectors": map[string]any{"size": dims, "distance": "Cosine"}}, nil)
+	}
+	if err != nil {
+		return fmt.Errorf("qdrant collection %s: %w", q.Collection, err)
+	}
+	q.ready = true
+	return nil
+}
+
+func (q *Qdrant) Upsert(ctx context.Context, chunks []Chunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	if err := q.ensure(ctx, len(chunks[0].Vector)); err != nil {
+		return err
+	}
+	points := make([]map[string]any, len(chunks))
+	for i, c := range chunks {
+		points[i] = map[string]any{
+			"id":      pointID(c.ID),
+			"vector":  c.Vector,
+			"payload": qdrantPayload{ChunkID: c.ID, DocumentID: c.DocumentID, Source: c.Source, Content: c.Content, Metadata: c.Metadata},
+		}
+	}
+	return call(ctx, http.MethodPut, q.endpoint("/points?wait=true"), q.header(), map[string]any{"points": points}, nil)
+}
+
+func (q *Qdrant) Search(ctx context.Context, vector []float32, k int, filter Filter) ([]Match, error) {
+	var out struct {
+		Result []struct {
+			Score   float64       `json:"score"`
+			Payload qdrantPayload `json:"payload"`
+		} `json:"result"`
+	}
+	req := map[string]any{"vector": vector, "limit": k, "with_payload": true}
+	if exact := filter.Exact(); len(exact) > 0 {
+		var must, mustNot []any
+		for _, c := range exact {
+			key := "metadata." + c.Key
+			switch c.Key {
+			case "source", "document_id":
+				key = c.Key
+			}
+			match := map[string]any{"key": key, "match": map[string]any{"any": c.Values}}
+			if c.Op == OpNe {
+				mustNot = append(mustNot, match)
+			} else {
+				must = append(must, match)
+			}
+		}
+		req["filter"] = map[string]any{"must": must, "must_not": mustNot}
+	}
+	err := call(ctx, http.MethodPost, q.endpoint("/points/search"), q.header(), req, &out)
+	if isNotFound(err) {
+		return nil, nil // nothing indexed yet
+	}
+	if err != nil {
+		return nil, err
+	}
+	matches := make([]Match, len(out.Result))
+	for i, r := range out.Result {
+		p := r.Payload
+		matches[i] = Match{Chunk: Chunk{ID: p.ChunkID, DocumentID: p.DocumentID, Source: p.Source, Content: p.Content, Metadata: p.Metadata}, Score: r.Score}
+	}
+	return matches, nil
+}
+
+// Document scrolls through the points of the document.
+func (q *Qdrant) Document(ctx context.Context, documentID string) ([]Chunk, error) {
+	filter := map[string]any{"must": []any{map[string]any{"key": "document_id", "match": map[string]any{"value": documentID}}}}
+	var chunks []Chunk
+	var offset any
+	for {
+		var out struct {
+			Result struct {
+				Points []struct {
+					Payload qdrantPayload `json:"payload"`
+					Vector  []float32     `json:"vector"`
+				} `json:"points"`
+				NextPageOffset any `json:"next_page_offset"`
+			} `json:"result"`
+		}
+		req := map[string]any{"filter": filter, "limit": 256, "with_payload": true, "with_vector": true}
+		if offset != nil {
+			req["offset"] = offset
+		}
+		err := call(ctx, http.MethodPost, q.endpoint("/points/scroll"), q.header(), req, &out)
+		if isNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range out.Result.Points {
+			pl := p.Payload
+			chunks = append(chunks, Chunk{ID: pl.ChunkID, DocumentID: pl.DocumentID, Source: pl.Source, Content: pl.Content, Metadata: pl.Metadata, Vector: p.Vector})
+		}
+		if offset = out.Result.NextPageOffset; offset == nil {
+			return chunks, nil
+		}
+	}
+}
+
+func (q *Qdrant) DeleteDocument(ctx context.Context, documentID string) error {
+	filter := map[string]any{"must": []any{map[string]any{"key": "document_id", "match": map[string]any{"value": documentID}}}}
+	err := call(ctx, http.MethodPost, q.endpoint("/points/delete?wait=true"), q.header(), map[string]any{"filter": filter}, nil)
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// pointID maps a chunk ID to the UUID Qdrant requires, stably.
+func pointID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	h := hex.EncodeToString(sum[:16])
+	return h[:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
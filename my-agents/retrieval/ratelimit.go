@@ -0,0 +1,127 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"my-agents/ratelimit"
+)
+
+// Defaults for the [retrieval.embedding] limits.
+const (
+	DefaultEmbedRetries = 5
+	maxEmbedBackoff     = time.Minute
+)
+
+// limitedEmbedder paces an embedder to its provider's limits: requests and
+// estimated tokens per minute, requests in flight, and the waits rate
+// limited responses ask for. A 429 pauses every caller sharing it, so
+// ingestion workers back off together instead of each finding the limit.
+type limitedEmbedder struct {
+	next    Embedder
+	limit   *ratelimit.Limiter // nil without limits
+	retries int
+
+	mu     sync.Mutex
+	paused time.Time // no requests before this
+}
+
+func newLimitedEmbedder(next Embedder, cfg EmbeddingConfig) *limitedEmbedder {
+	l := &limitedEmbedder{next: next, retries: cfg.MaxRetries}
+	switch {
+	case l.retries == 0:
+		l.retries = DefaultEmbedRetries
+	case l.retries < 0:
+		l.retries = 0
+	}
+	l.limit = ratelimit.New(ratelimit.Limits{
+		RequestsPerMinute: cfg.RequestsPerMinute,
+		TokensPerMinute:   cfg.TokensPerMinute,
+		Concurrency:       cfg.Concurrency,
+	})
+	return l
+}
+
+func (l *limitedEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	tokens := 0
+	for _, t := range texts {
+		tokens += estimateTokens(t)
+	}
+	backoff := time.Second
+	for attempt := 0; ; attempt++ {
+		if err := l.wait(ctx, tokens); err != nil {
+			return nil, err
+		}
+		vectors, err := l.next.Embed(ctx, texts)
+		l.limit.Release(tokens, tokens, err)
+		wait, limited, retryable := retryAfter(err)
+		if err == nil || !retryable || attempt >= l.retries || ctx.Err() != nil {
+			return vectors, err
+		}
+		if wait <= 0 {
+			wait = backoff
+			backoff = min(backoff*2, maxEmbedBackoff)
+		}
+		if limited {
+			l.mu.Lock()
+			if until := time.Now().Add(wait); until.After(l.paused) {
+				l.paused = until
+			}
+			l.mu.Unlock()
+			continue // wait pauses until then
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// wait blocks until the pause a 429 asked for is over and the limits let a
+// request of tokens be sent.
+func (l *limitedEmbedder) wait(ctx context.Context, tokens int) error {
+	l.mu.Lock()
+	paused := time.Until(l.paused)
+	l.mu.Unlock()
+	if paused > 0 {
+		if err := sleep(ctx, paused); err != nil {
+			return err
+		}
+	}
+	return l.limit.Acquire(ctx, tokens)
+}
+
+// retryAfter classifies an embedding error: how long the response asked
+// to wait, whether it was rate limited, and whether it's worth retrying.
+func retryAfter(err error) (wait time.Duration, limited, retryable bool) {
+	var h *httpError
+	if !errors.As(err, &h) {
+		return 0, false, false
+	}
+	switch {
+	case h.code == http.StatusTooManyRequests:
+		return h.retryAfter, true, true
+	case h.code >= 500:
+		return h.retryAfter, false, true
+	}
+	return 0, false, false
+}
+
+// estimateTokens guesses the tokens of text at four bytes each, which is
+// close for English and errs high for code.
+func estimateTokens(text string) int {
+	return len(text)/4 + 1
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,96 @@
+package retrieval
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// flaky fails with errs in turn, then embeds each text as one vector.
+type flaky struct {
+	mu    sync.Mutex
+	errs  []error
+	calls []time.Time
+}
+
+func (f *flaky) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.calls = append(f.calls, time.Now())
+	if len(f.errs) > 0 {
+		err := f.errs[0]
+		f.errs = f.errs[1:]
+		return nil, err
+	}
+	return make([][]float32, len(texts)), nil
+}
+
+func TestLimitedEmbedderRetries(t *testing.T) {
+	limited := &httpError{code: http.StatusTooManyRequests, msg: "slow down", retryAfter: 30 * time.Millisecond}
+	unavailable := &httpError{code: http.StatusServiceUnavailable, msg: "overloaded", retryAfter: 10 * time.Millisecond}
+	f := &flaky{errs: []error{limited, unavailable}}
+	l := newLimitedEmbedder(f, EmbeddingConfig{})
+	vectors, err := l.Embed(context.Background(), []string{"a", "b"})
+	if err != nil || len(vectors) != 2 {
+		t.Fatalf("Embed = %v, %v", vectors, err)
+	}
+	if len(f.calls) != 3 {
+		t.Fatalf("%d calls", len(f.calls))
+	}
+	if wait := f.calls[1].Sub(f.calls[0]); wait < 30*time.Millisecond {
+		t.Errorf("retried %s after a 429 asking for 30ms", wait)
+	}
+
+	// Errors retrying won't fix are returned at once, and retries run out
+	bad := &httpError{code: http.StatusBadRequest, msg: "input too long"}
+	f = &flaky{errs: []error{bad}}
+	if _, err := newLimitedEmbedder(f, EmbeddingConfig{}).Embed(context.Background(), []string{"a"}); err != bad || len(f.calls) != 1 {
+		t.Errorf("bad request: %v after %d calls", err, len(f.calls))
+	}
+	f = &flaky{errs: []error{unavailable, unavailable, unavailable}}
+	if _, err := newLimitedEmbedder(f, EmbeddingConfig{MaxRetries: 1}).Embed(context.Background(), []string{"a"}); err != unavailable || len(f.calls) != 2 {
+		t.Errorf("out of retries: %v after %d calls", err, len(f.calls))
+	}
+	f = &flaky{errs: []error{unavailable}}
+	if _, err := newLimitedEmbedder(f, EmbeddingConfig{MaxRetries: -1}).Embed(context.Background(), []string{"a"}); err != unavailable {
+		t.Errorf("retries disabled: %v", err)
+	}
+}
+
+func TestLimitedEmbedderPausesTogether(t *testing.T) {
+	limited := &httpError{code: http.StatusTooManyRequests, msg: "slow down", retryAfter: time.Hour}
+	l := newLimitedEmbedder(&flaky{errs: []error{limited}}, EmbeddingConfig{})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Embed(ctx, []string{"a"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Embed during the pause = %v", err)
+	}
+	// Another caller waits out the pause too
+	ctx, cancel = context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Embed(ctx, []string{"b"}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("another caller during the pause = %v", err)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		err                error
+		wait               time.Duration
+		limited, retryable bool
+	}{
+		{&httpError{code: 429, retryAfter: 2 * time.Second}, 2 * time.Second, true, true},
+		{&httpError{code: 502}, 0, false, true},
+		{&httpError{code: 404}, 0, false, false},
+		{errors.New("connection refused"), 0, false, false},
+	}
+	for _, tt := range tests {
+		wait, limited, retryable := retryAfter(tt.err)
+		if wait != tt.wait || limited != tt.limited || retryable != tt.retryable {
+			t.Errorf("retryAfter(%v) = %s, %v, %v", tt.err, wait, limited, retryable)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package retrieval
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Rerankers.
+const (
+	RerankCrossEncoder = "cross-encoder"
+	RerankLLM          = "llm"
+)
+
+// RerankConfig is the [retrieval.rerank] section: the model reordering the
+// candidates a search found by how well each answers the query.
+//
+//	[retrieval.rerank]
+//	provider = "cross-encoder"
+//	url = "http://localhost:8080/rerank"
+//	model = "BAAI/bge-reranker-base"
+type RerankConfig struct {
+	// Provider is RerankCrossEncoder, for a /rerank endpoint serving a
+	// cross-encoder (Cohere, Jina, Voyage or Hugging Face TEI), or
+	// RerankLLM, which asks a chat model to rate each candidate.
+	Provider  string `toml:"provider"`
+	URL       string `toml:"url"`         // cross-encoder: the /rerank endpoint
+	Model     string `toml:"model"`       // cross-encoder: sent as "model" when set
+	APIKeyEnv string `toml:"api_key_env"` // cross-encoder: env var holding the bearer token
+	// LLM names the [providers.<name>] table rating candidates (default
+	// the default provider).
+	LLM string `toml:"llm"`
+}
+
+// Reranker scores candidate chunks for a query, higher meaning more
+// relevant, one score per match, in order.
+type Reranker interface {
+	Rerank(ctx context.Context, query string, matches []Match) ([]float64, error)
+}
+
+// NewReranker creates the reranker cfg configures; it is nil without a
+// provider. llm rates candidates for RerankLLM.
+func NewReranker(cfg RerankConfig, llm core.ModelProvider) (Reranker, error) {
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case RerankCrossEncoder:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("retrieval: the cross-encoder reranker needs url")
+		}
+		apiKey := ""
+		if cfg.APIKeyEnv != "" {
+			apiKey = os.Getenv(cfg.APIKeyEnv)
+		}
+		return &CrossEncoder{URL: cfg.URL, Model: cfg.Model, APIKey: apiKey}, nil
+	case RerankLLM:
+		if llm == nil {
+			return nil, fmt.Errorf("retrieval: the llm reranker needs a provider")
+		}
+		return &LLMReranker{LLM: llm}, nil
+	default:
+		return nil, fmt.Errorf("retrieval: unknown reranker %q", cfg.Provider)
+	}
+}
+
+// CrossEncoder calls a /rerank endpoint scoring query-passage pairs. The
+// candidates go both as "documents", for Cohere, Jina and Voyage, and as
+// "texts", for TEI; either response shape is read.
+type CrossEncoder struct {
+	URL    string
+	Model  string
+	APIKey string
+}
+
+func (c *CrossEncoder) Rerank(ctx context.Context, query string, matches []Match) ([]float64, error) {
+	texts := make([]string, len(matches))
+	for i, m := range matches {
+		texts[i] = m.Content
+	}
+	req := map[string]any{"query": query, "documents": texts, "texts": texts}
+	if c.Model != "" {
+		req["model"] = c.Model
+	}
+	header := http.Header{}
+	if c.APIKey != "" {
+		header.Set("Authorization", "Bearer "+c.APIKey)
+	}
+	var raw json.RawMessage
+	if err := call(ctx, http.MethodPost, c.URL, header, req, &raw); err != nil {
+		return nil, err
+	}
+
+	// Cohere and the like wrap results; TEI returns them bare
+	type result struct {
+		Index          int      `json:"index"`
+		RelevanceScore *float64 `json:"relevance_score"`
+		Score          *float64 `json:"score"`
+	}
+	var results []result
+	if err := json.Unmarshal(raw, &results); err != nil {
+		var wrapped struct {
+			Results []result `json:"results"`
+		}
+		if err := json.Unmarshal(raw, &wrapped); err != nil {
+			return nil, fmt.Errorf("failed to decode rerank response: %w", err)
+		}
+		results = wrapped.Results
+	}
+	scores := make([]float64, len(matches))
+	for i := range scores {
+		scores[i] = -1 // unscored candidates rank last
+	}
+	for _, r := range results {
+		if r.Index < 0 || r.Index >= len(scores) {
+			return nil, fmt.Errorf("rerank index %d out of range", r.Index)
+		}
+		switch {
+		case r.RelevanceScore != nil:
+			scores[r.Index] = *r.RelevanceScore
+		case r.Score != nil:
+			scores[r.Index] = *r.Score
+		}
+	}
+	return scores, nil
+}
+
+// LLMReranker asks a chat model to rate each candidate's relevance 0-10,
+// the candidates in parallel. A candidate it can't rate scores -1.
+type LLMReranker struct {
+	LLM core.ModelProvider
+}
+
+var ratingPattern = regexp.MustCompile(`\d+(\.\d+)?`)
+
+func (l *LLMReranker) Rerank(ctx context.Context, query string, matches []Match) ([]float64, error) {
+	scores := make([]float64, len(matches))
+	errs := make([]error, len(matches))
+	var wg sync.WaitGroup
+	for i := range matches {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			resp, err := l.LLM.Call(ctx, core.Prompt{
+				System: "You judge search results. Rate how relevant the passage is to answering the query, from 0 (unrelated) to 10 (answers it directly). Reply with a single number.",
+				User:   fmt.Sprintf("Query:\n%s\n\nPassage:\n%s", query, strings.TrimSpace(matches[i].Content)),
+			})
+			if err != nil {
+				scores[i], errs[i] = -1, err
+				return
+			}
+			match := ratingPattern.FindString(resp.Content)
+			if match == "" {
+				scores[i], errs[i] = -1, fmt.Errorf("reranker gave no rating: %q", resp.Content)
+				return
+			}
+			v, _ := strconv.ParseFloat(match, 64)
+			scores[i] = min(v, 10)
+		}(i)
+	}
+	wg.Wait()
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	if failed == len(matches) && failed > 0 {
+		return nil, errs[0]
+	}
+	if failed > 0 {
+		core.Logger().Warn().Int("failed", failed).Int("candidates", len(matches)).Msg("Reranker failed to rate some chunks")
+	}
+	return scores, nil
+}
+
+// rerank reorders matches by scores, best first, scoring each match with
+// its reranker score; ties keep their fused order.
+func rerank(matches []Match, scores []float64) []Match {
+	for i := range matches {
+		matches[i].Score = scores[i]
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	return matches
+}
@@ -0,0 +1,558 @@
+// Package retrieval grounds answers in indexed documents: chunks of them are
+// embedded by an embedding provider and kept in a vector store, and a
+// request's closest chunks are retrieved for the agents' prompts. Stores
+// are pluggable: SQLite in the embedded database by default, or pgvector,
+// Qdrant or Chroma. Hybrid search adds BM25 keyword matches from a bleve
+// index kept beside the store, and a cross-encoder or LLM reranker can
+// reorder what both find. Documents can be split across named collections,
+// which requests select by metadata, workflow or classifier.
+package retrieval
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/ratelimit"
+)
+
+// Key is the state key holding the chunks retrieved for a request.
+const Key = "retrieved"
+
+// Stores.
+const (
+	StoreSQLite   = "sqlite"
+	StorePgvector = "pgvector"
+	StoreQdrant   = "qdrant"
+	StoreChroma   = "chroma"
+)
+
+// Defaults for unset Config fields.
+const (
+	DefaultCollection = "documents"
+	DefaultTopK       = 4
+	DefaultChunkSize  = 1000
+	DefaultOverlap    = 150
+)
+
+// Config is the [retrieval] section of agentflow.toml:
+//
+//	[retrieval]
+//	enabled = true
+//	store = "qdrant"
+//	url = "http://localhost:6333"
+//	collection = "docs"
+//	top_k = 4
+//	min_score = 0.3
+//	chunk_size = 800
+//	chunk_overlap = 100
+//	mode = "hybrid"
+//	[retrieval.chunkers]
+//	md = "markdown"
+//	code = "code"
+//	[retrieval.embedding]
+//	provider = "openai"
+//	model = "text-embedding-3-small"
+//	api_key_env = "OPENAI_API_KEY"
+//	[retrieval.rerank]
+//	provider = "llm"
+//	[retrieval.workflows.support]
+//	top_k = 8
+//	collections = ["product"]
+//	[retrieval.collections.product]
+//	description = "product manuals and release notes"
+type Config struct {
+	// Enabled grounds the processor's answers in the chunks retrieved.
+	Enabled bool   `toml:"enabled"`
+	Store   string `toml:"store"` // StoreSQLite (default), StorePgvector, StoreQdrant or StoreChroma
+	// URL locates the store: Qdrant's or Chroma's base URL, or pgvector's
+	// postgres:// connection string.
+	URL        string  `toml:"url"`
+	APIKeyEnv  string  `toml:"api_key_env"` // qdrant, chroma: env var holding the store's API key
+	Path       string  `toml:"path"`        // sqlite: database file (default [storage] path)
+	Collection string  `toml:"collection"`  // the main collection (default "documents")
+	TopK       int     `toml:"top_k"`       // chunks retrieved per request (default 4)
+	MinScore   float64 `toml:"min_score"`   // cosine similarity a vector match needs, 0-1 (default 0)
+
+	// Mode is how requests search: ModeVector (default), ModeKeyword or
+	// ModeHybrid. Any mode but vector keeps a keyword index as documents
+	// are indexed; documents indexed before it was kept need re-ingesting.
+	Mode        string `toml:"mode"`
+	KeywordPath string `toml:"keyword_path"` // keyword index directory (default keyword/<collection> beside the database)
+	// Candidates is how many chunks each search contributes before they
+	// are fused and reranked (see Search.Candidates).
+	Candidates int `toml:"candidates"`
+
+	// Documents are indexed in chunks of about ChunkSize characters
+	// (default 1000), each repeating the last ChunkOverlap characters of
+	// the one before (default 150).
+	ChunkSize    int `toml:"chunk_size"`
+	ChunkOverlap int `toml:"chunk_overlap"`
+	// Chunkers choose how documents are split by source type, a file
+	// extension (".go") or document type ("md", "code", "pdf", "web",
+	// "txt"), the extension winning: ChunkFixed (default), ChunkMarkdown,
+	// ChunkCode or ChunkSemantic. A ChunkerKey tag overrides them.
+	Chunkers map[string]string `toml:"chunkers"`
+	// SemanticThreshold is the similarity of neighbouring sentences, 0-1,
+	// below which ChunkSemantic starts a chunk (default per document).
+	SemanticThreshold float64 `toml:"semantic_threshold"`
+
+	Embedding EmbeddingConfig `toml:"embedding"`
+	Rerank    RerankConfig    `toml:"rerank"`
+	// Workflows override how requests entering at a route search.
+	Workflows map[string]Search `toml:"workflows"`
+
+	// Collections declare collections besides the main one, by name.
+	Collections map[string]CollectionConfig `toml:"collections"`
+	// Classifier names the [providers.<name>] table picking the
+	// collections of requests that select none; without it they search
+	// the main collection.
+	Classifier string `toml:"classifier"`
+
+	// Filter narrows the chunks every request searches (see ParseFilter).
+	Filter string `toml:"filter"`
+	// TenantScoped restricts requests to the chunks tagged with their
+	// tenant as TenantKey at ingestion; untenanted requests see only
+	// untagged chunks.
+	TenantScoped bool `toml:"tenant_scoped"`
+}
+
+// EmbeddingConfig is the [retrieval.embedding] section: the provider turning
+// text into vectors. Queries and chunks must be embedded by the same model.
+type EmbeddingConfig struct {
+	// Provider is "openai", for OpenAI and compatible /embeddings endpoints,
+	// or "ollama".
+	Provider   string `toml:"provider"`
+	Model      string `toml:"model"`
+	BaseURL    string `toml:"base_url"`    // default the provider's public or local endpoint
+	APIKeyEnv  string `toml:"api_key_env"` // openai: default OPENAI_API_KEY
+	Dimensions int    `toml:"dimensions"`  // openai: shorten vectors to this size, if the model supports it
+
+	// BatchSize is how many chunks are embedded per request (default 64).
+	BatchSize int `toml:"batch_size"`
+	// RequestsPerMinute and TokensPerMinute hold embedding requests to the
+	// provider's rate limits, tokens estimated from the texts' length;
+	// Concurrency bounds the requests in flight. 0 leaves each unbounded.
+	RequestsPerMinute int `toml:"requests_per_minute"`
+	TokensPerMinute   int `toml:"tokens_per_minute"`
+	Concurrency       int `toml:"concurrency"`
+	// MaxRetries is how often a rate limited or failed request is retried,
+	// after the wait its Retry-After asks for or a growing backoff
+	// (default DefaultEmbedRetries; -1 for none).
+	MaxRetries int `toml:"max_retries"`
+}
+
+// Embedder turns texts into vectors, one per text, in order.
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// Chunk is a passage of an indexed document.
+type Chunk struct {
+	ID         string            `json:"id"`
+	DocumentID string            `json:"document_id,omitempty"`
+	Collection string            `json:"collection,omitempty"` // set on matches
+	Source     string            `json:"source,omitempty"`
+	Content    string            `json:"content"`
+	Metadata   map[string]string `json:"metadata,omitempty"`
+	Vector     []float32         `json:"-"`
+}
+
+// Match is a chunk retrieved for a query. Its Score is the cosine
+// similarity of a vector match, the BM25 score of a keyword match, the
+// fused rank score of a hybrid one, or the reranker's score.
+type Match struct {
+	Chunk
+	Score float64 `json:"score"`
+}
+
+// Store keeps embedded chunks of one collection.
+type Store interface {
+	// Upsert adds chunks, replacing those with the same ID.
+	Upsert(ctx context.Context, chunks []Chunk) error
+	// Search returns the k chunks closest to vector, closest first, of
+	// those meeting at least the exact conditions of filter.
+	Search(ctx context.Context, vector []float32, k int, filter Filter) ([]Match, error)
+	// Document returns the chunks of the document with the ID, with their
+	// vectors, in any order.
+	Document(ctx context.Context, documentID string) ([]Chunk, error)
+	// DeleteDocument removes the chunks of the document with the ID.
+	DeleteDocument(ctx context.Context, documentID string) error
+}
+
+// Retriever indexes chunks and retrieves the ones relevant to a query.
+type Retriever struct {
+	embedder     Embedder
+	main         string // the collection of untagged documents
+	collections  map[string]*collection
+	keyworded    bool // the collections keep keyword indexes
+	tenantScoped bool
+	reranker     Reranker           // nil unless [retrieval.rerank] is configured
+	classifier   core.ModelProvider // nil unless [retrieval] classifier is set
+	defaults     Search
+	workflows    map[string]Search
+	minScore     float64
+	chunker      Chunker
+	chunkers     map[string]string // by extension or document type
+	// semanticThreshold is SemanticChunker's Threshold.
+	semanticThreshold float64
+	checkpoints       *checkpoints // nil unless opened with a database
+	tiers             *tiers       // nil unless opened with a database
+	sharedStore       bool         // a server other processes use too
+	embedBatch        int
+}
+
+// Open creates the embedder, a store per collection and, for keyword or
+// hybrid search, their keyword indexes, as cfg configures. storagePath is
+// the embedded database the sqlite store and keyword indexes default to.
+// The reranker and classifier are set with UseReranker and UseClassifier.
+func Open(cfg Config, storagePath string) (*Retriever, error) {
+	if err := validModes(cfg); err != nil {
+		return nil, err
+	}
+	if err := validChunkers(cfg); err != nil {
+		return nil, err
+	}
+	embedder, err := NewEmbedder(cfg.Embedding)
+	if err != nil {
+		return nil, err
+	}
+	store, err := OpenStore(cfg, storagePath)
+	if err != nil {
+		return nil, err
+	}
+	r := New(cfg, embedder, store)
+	r.sharedStore = cfg.Store != "" && cfg.Store != StoreSQLite
+	if r.checkpoints, err = openCheckpoints(storagePath); err != nil {
+		return nil, err
+	}
+	if r.tiers, err = openTiers(storagePath); err != nil {
+		return nil, err
+	}
+	for name, c := range cfg.Collections {
+		named := cfg
+		named.Collection = name
+		if store, err = OpenStore(named, storagePath); err != nil {
+			return nil, fmt.Errorf("collection %s: %w", name, err)
+		}
+		r.collections[name] = &collection{name: name, description: c.Description, store: store}
+	}
+	if needsKeyword(cfg) {
+		r.keyworded = true
+		for name, c := range r.collections {
+			if c.keyword, err = OpenKeyword(keywordDir(cfg, storagePath, name)); err != nil {
+				r.Close()
+				return nil, err
+			}
+		}
+	}
+	return r, nil
+}
+
+// New creates a retriever over store, the main collection, embedding with
+// embedder.
+func New(cfg Config, embedder Embedder, store Store) *Retriever {
+	main := cmp.Or(cfg.Collection, DefaultCollection)
+	r := &Retriever{
+		embedder:     embedder,
+		main:         main,
+		collections:  map[string]*collection{main: {name: main, store: store}},
+		defaults:     Search{Mode: cfg.Mode, TopK: cfg.TopK, Candidates: cfg.Candidates, Filter: cfg.Filter},
+		tenantScoped: cfg.TenantScoped,
+		workflows:    cfg.Workflows,
+		minScore:     cfg.MinScore,
+		chunker:      Chunker{Size: cfg.ChunkSize, Overlap: cfg.ChunkOverlap},
+		chunkers:     make(map[string]string, len(cfg.Chunkers)),
+
+		semanticThreshold: cfg.SemanticThreshold,
+		embedBatch:        cfg.Embedding.BatchSize,
+	}
+	for typ, strategy := range cfg.Chunkers {
+		r.chunkers[strings.ToLower(typ)] = strategy
+	}
+	if r.defaults.Mode == "" {
+		r.defaults.Mode = ModeVector
+	}
+	if r.defaults.TopK <= 0 {
+		r.defaults.TopK = DefaultTopK
+	}
+	if r.embedBatch <= 0 {
+		r.embedBatch = DefaultEmbedBatch
+	}
+	if r.chunker.Size <= 0 {
+		r.chunker.Size = DefaultChunkSize
+	}
+	if r.chunker.Overlap <= 0 {
+		r.chunker.Overlap = DefaultOverlap
+	}
+	return r
+}
+
+// UseReranker has searches that rerank reorder their candidates with
+// reranker.
+func (r *Retriever) UseReranker(reranker Reranker) {
+	r.reranker = reranker
+}
+
+// Close closes the keyword indexes.
+func (r *Retriever) Close() error {
+	var errs []error
+	for _, c := range r.collections {
+		if c.keyword != nil {
+			errs = append(errs, c.keyword.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// OpenStore opens the vector store cfg configures.
+func OpenStore(cfg Config, storagePath string) (Store, error) {
+	collection := cmp.Or(cfg.Collection, DefaultCollection)
+	apiKey := ""
+	if cfg.APIKeyEnv != "" {
+		apiKey = os.Getenv(cfg.APIKeyEnv)
+	}
+	switch cfg.Store {
+	case "", StoreSQLite:
+		path := cfg.Path
+		if path == "" {
+			path = storagePath
+		}
+		return OpenSQLite(path, collection)
+	case StorePgvector:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("retrieval: pgvector needs url, a postgres:// connection string")
+		}
+		return OpenPgvector(cfg.URL, collection)
+	case StoreQdrant:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("retrieval: qdrant needs url")
+		}
+		return &Qdrant{URL: cfg.URL, Collection: collection, APIKey: apiKey}, nil
+	case StoreChroma:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("retrieval: chroma needs url")
+		}
+		return &Chroma{URL: cfg.URL, Collection: collection, APIKey: apiKey}, nil
+	default:
+		return nil, fmt.Errorf("retrieval: unknown store %q", cfg.Store)
+	}
+}
+
+// DefaultEmbedBatch is how many texts are embedded per request when
+// [retrieval.embedding] batch_size is unset.
+const DefaultEmbedBatch = 64
+
+// Index embeds the chunks' content and upserts them into the named
+// collection, empty for the main one, and into its keyword index too when
+// one is kept.
+func (r *Retriever) Index(ctx context.Context, name string, chunks []Chunk) error {
+	c, err := r.collection(map[string]string{CollectionKey: name})
+	if err != nil {
+		return err
+	}
+	return r.index(ctx, c, chunks, nil)
+}
+
+// index embeds and stores chunks a batch at a time, calling stored, when
+// set, with how many are stored after each.
+func (r *Retriever) index(ctx context.Context, c *collection, chunks []Chunk, stored func(n int) error) error {
+	for start := 0; start < len(chunks); start += r.embedBatch {
+		batch := chunks[start:min(start+r.embedBatch, len(chunks))]
+		texts := make([]string, len(batch))
+		for i, c := range batch {
+			texts[i] = c.Content
+		}
+		vectors, err := r.embedder.Embed(ctx, texts)
+		if err != nil {
+			return fmt.Errorf("failed to embed chunks: %w", err)
+		}
+		if len(vectors) != len(batch) {
+			return fmt.Errorf("embedding returned %d vectors for %d chunks", len(vectors), len(batch))
+		}
+		for i := range batch {
+			batch[i].Vector = vectors[i]
+		}
+		if err := c.store.Upsert(ctx, batch); err != nil {
+			return fmt.Errorf("failed to store chunks: %w", err)
+		}
+		if c.keyword != nil {
+			if err := c.keyword.Upsert(ctx, batch); err != nil {
+				return fmt.Errorf("failed to index chunk keywords: %w", err)
+			}
+		}
+		if stored != nil {
+			if err := stored(start + len(batch)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// DeleteDocument removes the chunks of the document with the ID from the
+// named collection, empty for the main one, or from cold storage, and
+// forgets an interrupted indexing of it.
+func (r *Retriever) DeleteDocument(ctx context.Context, name, documentID string) error {
+	c, err := r.collection(map[string]string{CollectionKey: name})
+	if err != nil {
+		return err
+	}
+	return r.deleteDocument(ctx, c, documentID)
+}
+
+func (r *Retriever) deleteDocument(ctx context.Context, c *collection, documentID string) error {
+	if r.checkpoints != nil {
+		if err := r.checkpoints.clear(ctx, documentID); err != nil {
+			return err
+		}
+	}
+	if err := r.forgetTiers(ctx, documentID); err != nil {
+		return err
+	}
+	if err := c.store.DeleteDocument(ctx, documentID); err != nil {
+		return fmt.Errorf("failed to delete chunks of %s from %s: %w", documentID, c.name, err)
+	}
+	if c.keyword != nil {
+		if err := c.keyword.DeleteDocument(ctx, documentID); err != nil {
+			return fmt.Errorf("failed to delete keywords of %s from %s: %w", documentID, c.name, err)
+		}
+	}
+	return nil
+}
+
+// Retrieve returns the chunks relevant to query, best first, searching the
+// way [retrieval] configures.
+func (r *Retriever) Retrieve(ctx context.Context, query string) ([]Match, error) {
+	return r.RetrieveFor(ctx, "", query)
+}
+
+// Prompt renders matches as grounding for a prompt; it is empty without
+// matches.
+func Prompt(matches []Match) string {
+	if len(matches) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\nPassages from the indexed documents (ground the answer in these; say so when they don't cover the request):\n")
+	for i, m := range matches {
+		fmt.Fprintf(&b, "[%d] %s", i+1, strings.TrimSpace(m.Content))
+		if m.Source != "" {
+			fmt.Fprintf(&b, " (source: %s)", m.Source)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// cosine is the cosine similarity of a and b, 0 when their sizes differ.
+func cosine(a, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, na, nb float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		na += float64(a[i]) * float64(a[i])
+		nb += float64(b[i]) * float64(b[i])
+	}
+	if na == 0 || nb == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(na) * math.Sqrt(nb))
+}
+
+var httpClient = &http.Client{Timeout: time.Minute}
+
+// call sends in as JSON with method to url and decodes the response into
+// out, which may be nil.
+func call(ctx context.Context, method, url string, header http.Header, in, out any) error {
+	var body bytes.Buffer
+	if in != nil {
+		if err := json.NewEncoder(&body).Encode(in); err != nil {
+			return err
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, &body)
+	if err != nil {
+		return err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &httpError{
+			code:       resp.StatusCode,
+			msg:        fmt.Sprintf("%s %s returned %s: %s", method, url, resp.Status, strings.TrimSpace(string(msg))),
+			retryAfter: ratelimit.ParseRetryAfter(resp.Header.Get("Retry-After")),
+		}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", url, err)
+	}
+	return nil
+}
+
+// httpError is a store or embedding API's error response.
+type httpError struct {
+	code       int
+	msg        string
+	retryAfter time.Duration // as the response's Retry-After asked
+}
+
+func (e *httpError) Error() string { return e.msg }
+
+func isNotFound(err error) bool {
+	var h *httpError
+	return errors.As(err, &h) && h.code == http.StatusNotFound
+}
+
+// FromState returns the chunks an earlier agent retrieved into state.
+func FromState(state core.State) ([]Match, bool) {
+	v, ok := state.Get(Key)
+	if !ok {
+		return nil, false
+	}
+	return decodeMatches(v)
+}
+
+// decodeMatches reads the chunks of a state's Key value.
+func decodeMatches(v any) ([]Match, bool) {
+	if v == nil {
+		return nil, false
+	}
+	if matches, ok := v.([]Match); ok {
+		return matches, true
+	}
+	// State restored from a checkpoint holds the decoded JSON instead
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, false
+	}
+	var matches []Match
+	if err := json.Unmarshal(data, &matches); err != nil {
+		return nil, false
+	}
+	return matches, true
+}
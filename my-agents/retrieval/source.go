@@ -0,0 +1,239 @@
+package retrieval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"my-agents/auth"
+	"my-agents/history"
+)
+
+// ErrSourceNotFound is returned for citations no indexed chunk has, or
+// whose chunk the request's tenant may not see.
+var ErrSourceNotFound = errors.New("cited chunk not found")
+
+// DefaultAround is how many chunks either side of a cited one a source
+// includes when the request doesn't say.
+const DefaultAround = 1
+
+// SourceRequest names a cited chunk: a chunk ID ("doc-…#3"), as matches
+// and the citations of a grounded answer carry.
+type SourceRequest struct {
+	Citation string
+	// Collection is the collection holding the chunk; empty searches them
+	// all, the main one first.
+	Collection string
+	// Tenant is the request's tenant, which [retrieval] tenant_scoped
+	// restricts it to.
+	Tenant string
+	// Around is how many chunks either side of the cited one to include
+	// (default DefaultAround; negative for none).
+	Around int
+}
+
+// Source is a cited chunk in its document, for "view source" links.
+type Source struct {
+	Citation   string `json:"citation"`
+	Collection string `json:"collection"`
+	DocumentID string `json:"document_id"`
+	Source     string `json:"source,omitempty"`
+	Chunk      Chunk  `json:"chunk"`
+	// Before and After are the chunks around the cited one, in document
+	// order.
+	Before []Chunk `json:"before,omitempty"`
+	After  []Chunk `json:"after,omitempty"`
+	// Chunks is the number of chunks in the document.
+	Chunks int `json:"chunks"`
+}
+
+// Source returns the chunk req cites with the chunks around it in its
+// document.
+func (r *Retriever) Source(ctx context.Context, req SourceRequest) (*Source, error) {
+	documentID, _, ok := cutLast(req.Citation, "#")
+	if !ok || documentID == "" {
+		return nil, fmt.Errorf("%w: %q is not a chunk ID", ErrSourceNotFound, req.Citation)
+	}
+	names := r.Collections()
+	if req.Collection != "" {
+		if _, ok := r.collections[req.Collection]; !ok {
+			return nil, fmt.Errorf("unknown collection %q", req.Collection)
+		}
+		names = []string{req.Collection}
+	}
+	// A cited document may have moved to cold storage since; only one the
+	// request could read is brought back
+	_, err := r.restore(ctx, documentID, func(doc *coldDocument) bool {
+		if !slices.Contains(names, doc.Collection) {
+			return false
+		}
+		for _, c := range doc.Chunks {
+			if c.ID == req.Citation {
+				return !r.tenantScoped || c.Metadata[TenantKey] == req.Tenant
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		chunks, err := r.collections[name].store.Document(ctx, documentID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from %s: %w", documentID, name, err)
+		}
+		sort.SliceStable(chunks, func(i, j int) bool { return chunkIndex(chunks[i]) < chunkIndex(chunks[j]) })
+		for i, c := range chunks {
+			if c.ID != req.Citation {
+				continue
+			}
+			if r.tenantScoped && c.Metadata[TenantKey] != req.Tenant {
+				return nil, fmt.Errorf("%w: %s", ErrSourceNotFound, req.Citation)
+			}
+			around := req.Around
+			if around == 0 {
+				around = DefaultAround
+			}
+			around = max(around, 0)
+			c.Collection = name
+			return &Source{
+				Citation:   req.Citation,
+				Collection: name,
+				DocumentID: documentID,
+				Source:     c.Source,
+				Chunk:      c,
+				Before:     chunks[max(i-around, 0):i],
+				After:      chunks[i+1 : min(i+1+around, len(chunks))],
+				Chunks:     len(chunks),
+			}, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrSourceNotFound, req.Citation)
+}
+
+// chunkIndex is the position of c in its document, from the "chunk"
+// metadata ingestion sets or the ID's suffix.
+func chunkIndex(c Chunk) int {
+	if n, err := strconv.Atoi(c.Metadata["chunk"]); err == nil {
+		return n
+	}
+	_, suffix, _ := cutLast(c.ID, "#")
+	n, _ := strconv.Atoi(suffix)
+	return n
+}
+
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// SourceHandler serves the sources of citations on the HTTP API:
+//
+//	GET /sources/{citation}              the cited chunk ("doc-…%233") and its neighbours
+//	GET /events/{id}/citations/{n}       the source of the run's [n] passage
+//
+// Both take ?context=N, the number of neighbouring chunks either side, and
+// /sources also ?collection=. Under tenant_scoped the caller sees only its
+// authenticated tenant's chunks. The passages of a run's grounding are numbered from 1, as Prompt
+// numbers them for the model to cite.
+func (r *Retriever) SourceHandler(runs history.Store) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /sources/{citation}", func(w http.ResponseWriter, req *http.Request) {
+		around, err := aroundParam(req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		caller, _ := auth.FromContext(req.Context())
+		r.respond(w, req.Context(), SourceRequest{
+			Citation:   req.PathValue("citation"),
+			Collection: req.URL.Query().Get("collection"),
+			Tenant:     caller.Tenant,
+			Around:     around,
+		})
+	})
+	mux.HandleFunc("GET /events/{id}/citations/{n}", func(w http.ResponseWriter, req *http.Request) {
+		around, err := aroundParam(req)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		n, err := strconv.Atoi(req.PathValue("n"))
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("citation must be a passage number from 1"))
+			return
+		}
+		run, err := runs.Get(req.Context(), req.PathValue("id"))
+		if errors.Is(err, history.ErrNotFound) {
+			writeError(w, http.StatusNotFound, err)
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		matches, _ := decodeMatches(run.State(len(run.Steps) - 1)[Key])
+		if n > len(matches) {
+			writeError(w, http.StatusNotFound, fmt.Errorf("run %s retrieved %d passages, not %d", run.ID, len(matches), n))
+			return
+		}
+		// The caller's tenant scopes it, so another tenant's run cites nothing
+		m := matches[n-1]
+		caller, _ := auth.FromContext(req.Context())
+		r.respond(w, req.Context(), SourceRequest{
+			Citation:   m.ID,
+			Collection: m.Collection,
+			Tenant:     caller.Tenant,
+			Around:     around,
+		})
+	})
+	return mux
+}
+
+func (r *Retriever) respond(w http.ResponseWriter, ctx context.Context, req SourceRequest) {
+	source, err := r.Source(ctx, req)
+	if errors.Is(err, ErrSourceNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, source)
+}
+
+// aroundParam reads ?context=, -1 when it is 0 so Source includes no
+// neighbours.
+func aroundParam(req *http.Request) (int, error) {
+	v := req.URL.Query().Get("context")
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("context must be a number of chunks")
+	}
+	if n == 0 {
+		return -1, nil
+	}
+	return n, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,151 @@
+package retrieval
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"my-agents/storage"
+)
+
+// SQLite keeps a collection's chunks in a table of the embedded database
+// and searches them exhaustively, which suits collections of up to tens of
+// thousands of chunks without running another service.
+type SQLite struct {
+	db         *sql.DB
+	collection string
+}
+
+// OpenSQLite opens the collection in the database at path (default
+// .agentflow/agentflow.db).
+func OpenSQLite(path, collection string) (*SQLite, error) {
+	db, err := storage.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	err = storage.Migrate(db,
+		`CREATE TABLE IF NOT EXISTS retrieval_chunks (
+			collection  TEXT NOT NULL,
+			id          TEXT NOT NULL,
+			document_id TEXT NOT NULL,
+			source      TEXT NOT NULL,
+			content     TEXT NOT NULL,
+			metadata    TEXT NOT NULL,
+			vector      BLOB NOT NULL,
+			PRIMARY KEY (collection, id)
+		)`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLite{db: db, collection: collection}, nil
+}
+
+func (s *SQLite) Upsert(ctx context.Context, chunks []Chunk) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	for _, c := range chunks {
+		meta, err := json.Marshal(c.Metadata)
+		if err != nil {
+			return err
+		}
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO retrieval_chunks (collection, id, document_id, source, content, metadata, vector) VALUES (?, ?, ?, ?, ?, ?, ?)
+			 ON CONFLICT (collection, id) DO UPDATE SET document_id = excluded.document_id, source = excluded.source,
+			 content = excluded.content, metadata = excluded.metadata, vector = excluded.vector`,
+			s.collection, c.ID, c.DocumentID, c.Source, c.Content, string(meta), encodeVector(c.Vector))
+		if err != nil {
+			return fmt.Errorf("failed to save chunk %s: %w", c.ID, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// Search applies all of filter, the chunks being scanned anyway.
+func (s *SQLite) Search(ctx context.Context, vector []float32, k int, filter Filter) ([]Match, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, document_id, source, content, metadata, vector FROM retrieval_chunks WHERE collection = ?`, s.collection)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search chunks: %w", err)
+	}
+	defer rows.Close()
+	var matches []Match
+	for rows.Next() {
+		var m Match
+		var meta string
+		var blob []byte
+		if err := rows.Scan(&m.ID, &m.DocumentID, &m.Source, &m.Content, &meta, &blob); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(meta), &m.Metadata); err != nil {
+			return nil, fmt.Errorf("chunk %s: %w", m.ID, err)
+		}
+		if !filter.Match(m.Chunk) {
+			continue
+		}
+		if m.Score = cosine(vector, decodeVector(blob)); m.Score <= 0 {
+			continue
+		}
+		matches = append(matches, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Score > matches[j].Score })
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+	return matches, nil
+}
+
+func (s *SQLite) Document(ctx context.Context, documentID string) ([]Chunk, error) {
+	rows, err := s.db.QueryContext(ctx,
+		`SELECT id, document_id, source, content, metadata, vector FROM retrieval_chunks WHERE collection = ? AND document_id = ?`, s.collection, documentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunks: %w", err)
+	}
+	defer rows.Close()
+	var chunks []Chunk
+	for rows.Next() {
+		var c Chunk
+		var meta string
+		var blob []byte
+		if err := rows.Scan(&c.ID, &c.DocumentID, &c.Source, &c.Content, &meta, &blob); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(meta), &c.Metadata); err != nil {
+			return nil, fmt.Errorf("chunk %s: %w", c.ID, err)
+		}
+		c.Vector = decodeVector(blob)
+		chunks = append(chunks, c)
+	}
+	return chunks, rows.Err()
+}
+
+func (s *SQLite) DeleteDocument(ctx context.Context, documentID string) error {
+	_, err := s.db.ExecContext(ctx, `DELETE FROM retrieval_chunks WHERE collection = ? AND document_id = ?`, s.collection, documentID)
+	return err
+}
+
+func encodeVector(v []float32) []byte {
+	b := make([]byte, 4*len(v))
+	for i, f := range v {
+		binary.LittleEndian.PutUint32(b[4*i:], math.Float32bits(f))
+	}
+	return b
+}
+
+func decodeVector(b []byte) []float32 {
+	v := make([]float32, len(b)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[4*i:]))
+	}
+	return v
+}
@@ -0,0 +1,285 @@
+// Package retry calls a provider again when a call fails on a transient
+// error — the provider timing out, rate limiting or briefly unavailable —
+// waiting with exponential backoff and jitter between attempts, before the
+// agent sees the failure. An agent marked idempotent is run again whole
+// instead, which re-runs its tools and sinks too.
+package retry
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Defaults for a policy's unset fields.
+const (
+	DefaultBackoff    = 500 * time.Millisecond
+	DefaultMaxBackoff = 10 * time.Second
+	DefaultMultiplier = 2.0
+	DefaultJitter     = 0.2
+)
+
+// Policy is the [retry] section of agentflow.toml, and an agent's
+// [agents.<name>.retry] table, which replaces it for that agent:
+//
+//	[retry]
+//	max_attempts = 3
+//	backoff = "500ms"
+//	max_backoff = "10s"
+//	jitter = 0.2
+//	retry_on = ["model is loading"]
+type Policy struct {
+	// Idempotent retries the agent's whole run rather than each of its
+	// provider calls, for agents whose tools and sinks are safe to repeat.
+	Idempotent bool `toml:"idempotent"`
+	// MaxAttempts counts the first try; 0 or 1 never retries.
+	MaxAttempts int `toml:"max_attempts"`
+	// Backoff is the wait before the first retry (default 500ms), multiplied
+	// by Multiplier (default 2) for each one after, up to MaxBackoff
+	// (default 10s).
+	Backoff    string  `toml:"backoff"`
+	MaxBackoff string  `toml:"max_backoff"`
+	Multiplier float64 `toml:"multiplier"`
+	// Jitter randomizes each wait by up to this fraction of it, either way,
+	// so agents failing together don't retry together (default 0.2).
+	Jitter float64 `toml:"jitter"`
+	// RetryOn and NoRetryOn are error message fragments (case-insensitive)
+	// classifying errors as transient or not, ahead of the built-in
+	// classification.
+	RetryOn   []string `toml:"retry_on"`
+	NoRetryOn []string `toml:"no_retry_on"`
+}
+
+// Enabled reports whether the policy retries at all.
+func (p Policy) Enabled() bool {
+	return p.MaxAttempts > 1
+}
+
+// Exhausted is the error of an agent, or a provider call, that failed on
+// every attempt, or on a permanent error after some transient ones.
+type Exhausted struct {
+	Agent    string
+	Attempts int
+	Err      error // the last attempt's
+}
+
+func (e *Exhausted) Error() string {
+	return fmt.Sprintf("%v (after %d attempts)", e.Err, e.Attempts)
+}
+
+func (e *Exhausted) Unwrap() error { return e.Err }
+
+// Attempts returns how many times the agent, or the provider call, that
+// failed with err was tried: the attempts of an Exhausted error, otherwise
+// 1.
+func Attempts(err error) int {
+	var ex *Exhausted
+	if errors.As(err, &ex) {
+		return ex.Attempts
+	}
+	return 1
+}
+
+// Wrap returns agent retried whole under p when p is idempotent, and agent
+// itself otherwise: Middleware retries its provider calls then.
+func Wrap(name string, p Policy, agent core.AgentHandler) (core.AgentHandler, error) {
+	s, err := newSchedule(name, p)
+	if err != nil || !p.Enabled() || !p.Idempotent {
+		return agent, err
+	}
+	return &retrying{schedule: s, agent: agent}, nil
+}
+
+// Middleware retries the provider calls of each agent under policy(agent),
+// but for idempotent agents, which Wrap retries whole. It has the shape of
+// di.LLMMiddleware. A policy Wrap rejects leaves the calls unretried.
+func Middleware(policy func(agent string) Policy) func(agent string, llm core.ModelProvider) core.ModelProvider {
+	return func(agent string, llm core.ModelProvider) core.ModelProvider {
+		p := policy(agent)
+		if !p.Enabled() || p.Idempotent {
+			return llm
+		}
+		s, err := newSchedule(agent, p)
+		if err != nil {
+			return llm
+		}
+		return &retryingProvider{schedule: s, next: llm}
+	}
+}
+
+func duration(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// schedule is an agent's policy with its waits parsed.
+type schedule struct {
+	name       string
+	policy     Policy
+	backoff    time.Duration
+	maxBackoff time.Duration
+}
+
+func newSchedule(name string, p Policy) (*schedule, error) {
+	s := &schedule{name: name, policy: p}
+	var err error
+	if s.backoff, err = duration(p.Backoff, DefaultBackoff); err != nil {
+		return nil, fmt.Errorf("agent %s: retry backoff: %w", name, err)
+	}
+	if s.maxBackoff, err = duration(p.MaxBackoff, DefaultMaxBackoff); err != nil {
+		return nil, fmt.Errorf("agent %s: retry max_backoff: %w", name, err)
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		return nil, fmt.Errorf("agent %s: retry jitter must be between 0 and 1", name)
+	}
+	return s, nil
+}
+
+// do runs attempt until it succeeds, fails on a permanent error or runs out
+// of attempts; what names it in the logs.
+func (s *schedule) do(ctx context.Context, what string, attempt func() error) error {
+	wait := s.backoff
+	for n := 1; ; n++ {
+		err := attempt()
+		if err == nil {
+			if n > 1 {
+				core.Logger().Info().Str("agent", s.name).Int("attempts", n).Msg(what + " succeeded on retry")
+			}
+			return nil
+		}
+		if n >= s.policy.MaxAttempts || !s.policy.Transient(ctx, err) {
+			if n == 1 {
+				return err
+			}
+			return &Exhausted{Agent: s.name, Attempts: n, Err: err}
+		}
+		delay := s.jittered(wait)
+		core.Logger().Warn().Str("agent", s.name).Int("attempt", n).Dur("retry_in", delay).Err(err).Msg(what + " failed on a transient error; retrying")
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return &Exhausted{Agent: s.name, Attempts: n, Err: err}
+		}
+		wait = min(time.Duration(float64(wait)*cmp.Or(s.policy.Multiplier, DefaultMultiplier)), s.maxBackoff)
+	}
+}
+
+type retrying struct {
+	*schedule
+	agent core.AgentHandler
+}
+
+func (r *retrying) Run(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+	var result core.AgentResult
+	err := r.do(ctx, "Agent", func() error {
+		// Each attempt starts from the state as it arrived
+		var err error
+		result, err = r.agent.Run(ctx, event, state.Clone())
+		return err
+	})
+	return result, err
+}
+
+// retryingProvider retries calls, and the start of streams: a stream that
+// breaks after its first token is the caller's to handle.
+type retryingProvider struct {
+	*schedule
+	next core.ModelProvider
+}
+
+// Unwrap returns the provider wrapped, for the LLM cache to see through.
+func (p *retryingProvider) Unwrap() core.ModelProvider { return p.next }
+
+func (p *retryingProvider) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	var resp core.Response
+	err := p.do(ctx, "Provider call", func() error {
+		var err error
+		resp, err = p.next.Call(ctx, prompt)
+		return err
+	})
+	return resp, err
+}
+
+func (p *retryingProvider) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	var tokens <-chan core.Token
+	err := p.do(ctx, "Provider stream", func() error {
+		var err error
+		tokens, err = p.next.Stream(ctx, prompt)
+		return err
+	})
+	return tokens, err
+}
+
+func (p *retryingProvider) Embeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	var vectors [][]float64
+	err := p.do(ctx, "Embeddings call", func() error {
+		var err error
+		vectors, err = p.next.Embeddings(ctx, texts)
+		return err
+	})
+	return vectors, err
+}
+
+func (s *schedule) jittered(d time.Duration) time.Duration {
+	jitter := s.policy.Jitter
+	if jitter == 0 {
+		jitter = DefaultJitter
+	}
+	return time.Duration(float64(d) * (1 + jitter*(2*rand.Float64()-1)))
+}
+
+// transientMarkers are fragments of provider error messages that mean a
+// later attempt may succeed. Providers report HTTP failures as text, so
+// classification reads the message.
+var transientMarkers = []string{
+	"status 429", "status 500", "status 502", "status 503", "status 504",
+	"too many requests", "rate limit", "overloaded", "temporarily unavailable",
+	"service unavailable", "bad gateway", "gateway timeout",
+	"timeout", "timed out", "connection refused", "connection reset", "broken pipe",
+	"unexpected eof",
+}
+
+// Transient reports whether err, returned while ctx was live, is worth
+// another attempt.
+func (p Policy) Transient(ctx context.Context, err error) bool {
+	if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, s := range p.NoRetryOn {
+		if strings.Contains(msg, strings.ToLower(s)) {
+			return false
+		}
+	}
+	for _, s := range p.RetryOn {
+		if strings.Contains(msg, strings.ToLower(s)) {
+			return true
+		}
+	}
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNREFUSED),
+		errors.Is(err, syscall.ECONNRESET),
+		errors.As(err, &netErr) && netErr.Timeout():
+		return true
+	}
+	for _, s := range transientMarkers {
+		if strings.Contains(msg, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,222 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// flaky fails with errs in turn, then succeeds.
+type flaky struct {
+	core.ModelProvider
+	errs  []error
+	calls int
+}
+
+func (f *flaky) next() error {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return f.errs[f.calls-1]
+	}
+	return nil
+}
+
+func (f *flaky) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	if err := f.next(); err != nil {
+		return core.Response{}, err
+	}
+	return core.Response{Content: "ok"}, nil
+}
+
+func (f *flaky) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	out := make(chan core.Token)
+	close(out)
+	return out, nil
+}
+
+func (f *flaky) Embeddings(ctx context.Context, texts []string) ([][]float64, error) {
+	if err := f.next(); err != nil {
+		return nil, err
+	}
+	return [][]float64{{1}}, nil
+}
+
+var (
+	rateLimited = errors.New("openai: status 429: too many requests")
+	badRequest  = errors.New("openai: status 400: invalid model")
+)
+
+func policy(attempts int) Policy {
+	return Policy{MaxAttempts: attempts, Backoff: "1ms", MaxBackoff: "2ms"}
+}
+
+func TestMiddleware(t *testing.T) {
+	llm := &flaky{errs: []error{rateLimited, rateLimited}}
+	wrapped := Middleware(func(string) Policy { return policy(3) })("writer", llm)
+	resp, err := wrapped.Call(context.Background(), core.Prompt{User: "hi"})
+	if err != nil || resp.Content != "ok" || llm.calls != 3 {
+		t.Errorf("= %q, %v after %d calls", resp.Content, err, llm.calls)
+	}
+
+	llm = &flaky{errs: []error{rateLimited, rateLimited, rateLimited}}
+	wrapped = Middleware(func(string) Policy { return policy(3) })("writer", llm)
+	_, err = wrapped.Call(context.Background(), core.Prompt{User: "hi"})
+	var ex *Exhausted
+	if !errors.As(err, &ex) || ex.Attempts != 3 || ex.Agent != "writer" || !errors.Is(err, rateLimited) || Attempts(err) != 3 {
+		t.Errorf("err = %v", err)
+	}
+
+	// Permanent errors aren't retried
+	llm = &flaky{errs: []error{badRequest}}
+	wrapped = Middleware(func(string) Policy { return policy(3) })("writer", llm)
+	if _, err := wrapped.Call(context.Background(), core.Prompt{User: "hi"}); err != badRequest || llm.calls != 1 || Attempts(err) != 1 {
+		t.Errorf("err = %v after %d calls", err, llm.calls)
+	}
+	llm = &flaky{errs: []error{rateLimited, badRequest}}
+	wrapped = Middleware(func(string) Policy { return policy(3) })("writer", llm)
+	if _, err := wrapped.Call(context.Background(), core.Prompt{User: "hi"}); !errors.As(err, &ex) || ex.Attempts != 2 {
+		t.Errorf("permanent error after a transient one = %v", err)
+	}
+}
+
+func TestMiddlewareStreamAndEmbeddings(t *testing.T) {
+	llm := &flaky{errs: []error{rateLimited, nil, rateLimited}}
+	wrapped := Middleware(func(string) Policy { return policy(2) })("writer", llm)
+	if _, err := wrapped.Stream(context.Background(), core.Prompt{}); err != nil {
+		t.Errorf("stream: %v", err)
+	}
+	if _, err := wrapped.Embeddings(context.Background(), []string{"x"}); err != nil {
+		t.Errorf("embeddings: %v", err)
+	}
+	if llm.calls != 4 {
+		t.Errorf("%d calls", llm.calls)
+	}
+}
+
+func TestMiddlewareSkips(t *testing.T) {
+	llm := &flaky{}
+	for _, p := range []Policy{
+		{},
+		{MaxAttempts: 1},
+		{MaxAttempts: 3, Idempotent: true},
+		{MaxAttempts: 3, Backoff: "soon"},
+	} {
+		if got := Middleware(func(string) Policy { return p })("writer", llm); got != core.ModelProvider(llm) {
+			t.Errorf("%+v wrapped the provider", p)
+		}
+	}
+}
+
+func TestRetryingProviderUnwraps(t *testing.T) {
+	llm := &flaky{}
+	u, ok := Middleware(func(string) Policy { return policy(3) })("writer", llm).(interface{ Unwrap() core.ModelProvider })
+	if !ok || u.Unwrap() != core.ModelProvider(llm) {
+		t.Error("retrying provider does not unwrap to the one it wraps")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	runs := 0
+	agent := core.AgentHandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+		runs++
+		if _, dirty := state.Get("partial"); dirty {
+			return core.AgentResult{}, errors.New("attempt saw an earlier attempt's writes")
+		}
+		state.Set("partial", true)
+		if runs < 3 {
+			return core.AgentResult{}, rateLimited
+		}
+		return core.AgentResult{OutputState: state}, nil
+	})
+	p := policy(3)
+	p.Idempotent = true
+	wrapped, err := Wrap("writer", p, agent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wrapped.Run(context.Background(), core.NewEvent("writer", nil, nil), core.NewState()); err != nil || runs != 3 {
+		t.Errorf("err = %v after %d runs", err, runs)
+	}
+
+	// Agents that aren't idempotent are left to Middleware
+	if got, err := Wrap("writer", policy(3), agent); err != nil {
+		t.Error(err)
+	} else if _, ok := got.(*retrying); ok {
+		t.Error("wrapped an agent that isn't idempotent")
+	}
+	for _, bad := range []Policy{
+		{MaxAttempts: 3, Backoff: "soon"},
+		{MaxAttempts: 3, MaxBackoff: "later"},
+		{MaxAttempts: 3, Jitter: 1.5},
+	} {
+		if _, err := Wrap("writer", bad, agent); err == nil {
+			t.Errorf("%+v accepted", bad)
+		}
+	}
+}
+
+func TestCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	llm := &flaky{errs: []error{rateLimited, rateLimited}}
+	p := Policy{MaxAttempts: 3, Backoff: "1h"}
+	wrapped := Middleware(func(string) Policy { return p })("writer", llm)
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err := wrapped.Call(ctx, core.Prompt{})
+	if Attempts(err) != 1 || llm.calls != 1 || !errors.Is(err, rateLimited) {
+		t.Errorf("err = %v after %d calls", err, llm.calls)
+	}
+}
+
+type timeout struct{}
+
+func (timeout) Error() string   { return "i/o deadline" }
+func (timeout) Timeout() bool   { return true }
+func (timeout) Temporary() bool { return true }
+
+func TestTransient(t *testing.T) {
+	ctx := context.Background()
+	p := Policy{RetryOn: []string{"Model is loading"}, NoRetryOn: []string{"quota exceeded"}}
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{rateLimited, true},
+		{badRequest, false},
+		{errors.New("ollama: model is loading"), true},
+		{errors.New("status 429: monthly quota exceeded"), false},
+		{context.DeadlineExceeded, true},
+		{context.Canceled, false},
+		{fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{fmt.Errorf("dial: %w", syscall.ECONNREFUSED), true},
+		{timeout{}, true},
+		{errors.New("Service Unavailable"), true},
+	}
+	for _, tt := range tests {
+		if got := p.Transient(ctx, tt.err); got != tt.want {
+			t.Errorf("Transient(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+	done, cancel := context.WithCancel(ctx)
+	cancel()
+	if p.Transient(done, rateLimited) {
+		t.Error("retrying after the context ended")
+	}
+}
+
+func TestJittered(t *testing.T) {
+	s := &schedule{policy: Policy{Jitter: 0.5}}
+	for range 100 {
+		if d := s.jittered(time.Second); d < 500*time.Millisecond || d > 1500*time.Millisecond {
+			t.Fatalf("jittered wait %v", d)
+		}
+	}
+}
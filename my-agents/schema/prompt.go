@@ -0,0 +1,123 @@
+package schema
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// OutputKey is the state key holding an agent's output decoded from the
+// JSON its schema validated.
+const OutputKey = "structured_output"
+
+// DefaultMaxRepairs is how often a StructuredPrompt re-asks the model for
+// output that matches its schema.
+const DefaultMaxRepairs = 2
+
+// StructuredPrompt is a prompt whose reply must be JSON matching Schema.
+type StructuredPrompt struct {
+	core.Prompt
+	Schema *Schema
+	// MaxRepairs bounds the repair attempts (default DefaultMaxRepairs;
+	// negative never repairs).
+	MaxRepairs int
+}
+
+// InvalidError reports a reply that still didn't match the schema after the
+// repair attempts.
+type InvalidError struct {
+	Content  string   // the last reply
+	Problems []string // why it doesn't match
+}
+
+func (e *InvalidError) Error() string {
+	return fmt.Sprintf("model output doesn't match its schema: %s", strings.Join(e.Problems, "; "))
+}
+
+// Instructed returns the prompt with the format instructions appended to its
+// system prompt.
+func (p StructuredPrompt) Instructed() core.Prompt {
+	prompt := p.Prompt
+	prompt.System = strings.TrimSpace(prompt.System + "\n\nReply with only a JSON value, without prose or code fences, that is valid against this JSON Schema:\n" + p.Schema.String())
+	return prompt
+}
+
+// Call asks llm for the structured reply and returns it with Content
+// reduced to the JSON, which is also decoded into out when out isn't nil.
+func (p StructuredPrompt) Call(ctx context.Context, llm core.ModelProvider, out any) (core.Response, error) {
+	resp, err := llm.Call(ctx, p.Instructed())
+	if err != nil {
+		return core.Response{}, err
+	}
+	return p.Check(ctx, llm, resp, out)
+}
+
+// Check validates a reply to the instructed prompt, however it was
+// generated, asking llm to repair it while it doesn't match the schema.
+func (p StructuredPrompt) Check(ctx context.Context, llm core.ModelProvider, resp core.Response, out any) (core.Response, error) {
+	repairs := p.MaxRepairs
+	if repairs == 0 {
+		repairs = DefaultMaxRepairs
+	}
+	for attempt := 0; ; attempt++ {
+		raw, problems := p.parse(resp.Content)
+		if len(problems) == 0 {
+			resp.Content = string(raw)
+			if out != nil {
+				if err := json.Unmarshal(raw, out); err != nil {
+					return core.Response{}, fmt.Errorf("decode model output: %w", err)
+				}
+			}
+			return resp, nil
+		}
+		if attempt >= repairs {
+			return core.Response{}, &InvalidError{Content: resp.Content, Problems: problems}
+		}
+		repaired, err := llm.Call(ctx, p.RepairPrompt(resp.Content, problems))
+		if err != nil {
+			return core.Response{}, err
+		}
+		resp = repaired
+	}
+}
+
+// parse extracts the JSON value from a reply and validates it.
+func (p StructuredPrompt) parse(content string) (json.RawMessage, []string) {
+	start := strings.IndexAny(content, "{[")
+	if start < 0 {
+		return nil, []string{"the reply contains no JSON object or array"}
+	}
+	// Models wrap JSON in prose or code fences despite being told not to
+	var raw json.RawMessage
+	if err := json.NewDecoder(strings.NewReader(content[start:])).Decode(&raw); err != nil {
+		return nil, []string{"the reply isn't valid JSON: " + err.Error()}
+	}
+	var v any
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, []string{"the reply isn't valid JSON: " + err.Error()}
+	}
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, raw); err == nil {
+		raw = compact.Bytes()
+	}
+	return raw, p.Schema.Validate(v)
+}
+
+// RepairPrompt asks the model to correct a reply that doesn't match the
+// schema.
+func (p StructuredPrompt) RepairPrompt(content string, problems []string) core.Prompt {
+	var b strings.Builder
+	b.WriteString("The reply below was meant to be JSON valid against the schema, but:\n")
+	for _, problem := range problems {
+		fmt.Fprintf(&b, "- %s\n", problem)
+	}
+	fmt.Fprintf(&b, "\nSchema:\n%s\n\nOriginal request:\n%s\n\nReply:\n%s", p.Schema, p.User, content)
+	return core.Prompt{
+		System: "You are a JSON editor. Return only the corrected JSON value, keeping the reply's content.",
+		User:   b.String(),
+	}
+}
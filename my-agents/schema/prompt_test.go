@@ -0,0 +1,104 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// model answers each call with the next of replies, recording the prompts.
+type model struct {
+	core.ModelProvider
+	replies []string
+	prompts []core.Prompt
+	err     error
+}
+
+func (m *model) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	m.prompts = append(m.prompts, prompt)
+	if m.err != nil {
+		return core.Response{}, m.err
+	}
+	reply := m.replies[0]
+	m.replies = m.replies[1:]
+	return core.Response{Content: reply}, nil
+}
+
+var titled = MustCompile(`{"type": "object", "required": ["title"], "properties": {"title": {"type": "string"}}}`)
+
+func TestInstructed(t *testing.T) {
+	p := StructuredPrompt{Prompt: core.Prompt{System: "You write titles.", User: "Go"}, Schema: titled}
+	got := p.Instructed()
+	if !strings.HasPrefix(got.System, "You write titles.\n\nReply with only a JSON value") || !strings.HasSuffix(got.System, titled.String()) || got.User != "Go" {
+		t.Errorf("instructed = %+v", got)
+	}
+}
+
+func TestCall(t *testing.T) {
+	llm := &model{replies: []string{"Sure! ```json\n{ \"title\": \"Go\" }\n``` Enjoy."}}
+	var out struct{ Title string }
+	resp, err := StructuredPrompt{Prompt: core.Prompt{User: "Go"}, Schema: titled}.Call(context.Background(), llm, &out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != `{"title":"Go"}` || out.Title != "Go" || len(llm.prompts) != 1 {
+		t.Errorf("reply %q decoded to %+v after %d calls", resp.Content, out, len(llm.prompts))
+	}
+
+	llm.err = errors.New("model down")
+	if _, err := (StructuredPrompt{Schema: titled}).Call(context.Background(), llm, nil); err == nil {
+		t.Error("model error lost")
+	}
+}
+
+func TestCheckRepairs(t *testing.T) {
+	llm := &model{replies: []string{`{"name": "Go"}`, `{"title": "Go"}`}}
+	p := StructuredPrompt{Prompt: core.Prompt{User: "Name the language"}, Schema: titled}
+	resp, err := p.Check(context.Background(), llm, core.Response{Content: "no JSON here"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Content != `{"title":"Go"}` || len(llm.prompts) != 2 {
+		t.Errorf("reply %q after %d repairs", resp.Content, len(llm.prompts))
+	}
+	repair := llm.prompts[1].User
+	for _, s := range []string{`- $: missing required property "title"`, "Original request:\nName the language", "Reply:\n{\"name\": \"Go\"}"} {
+		if !strings.Contains(repair, s) {
+			t.Errorf("repair prompt lacks %q:\n%s", s, repair)
+		}
+	}
+	if !strings.Contains(llm.prompts[0].User, "the reply contains no JSON object or array") {
+		t.Errorf("first repair prompt:\n%s", llm.prompts[0].User)
+	}
+}
+
+func TestCheckGivesUp(t *testing.T) {
+	ctx := context.Background()
+	llm := &model{replies: []string{`{"title": 1}`, `{"title": 2}`}}
+	_, err := StructuredPrompt{Schema: titled}.Check(ctx, llm, core.Response{Content: `{"title": 0`}, nil)
+	var invalid *InvalidError
+	if !errors.As(err, &invalid) || invalid.Content != `{"title": 2}` || invalid.Problems[0] != "$.title: is number, want string" {
+		t.Fatalf("error = %v", err)
+	}
+	if len(llm.prompts) != DefaultMaxRepairs {
+		t.Errorf("%d repairs", len(llm.prompts))
+	}
+	if !strings.Contains(llm.prompts[0].User, "the reply isn't valid JSON") {
+		t.Errorf("repair of broken JSON:\n%s", llm.prompts[0].User)
+	}
+
+	// Negative never repairs
+	llm = &model{}
+	if _, err := (StructuredPrompt{Schema: titled, MaxRepairs: -1}).Check(ctx, llm, core.Response{Content: "{}"}, nil); !errors.As(err, &invalid) || len(llm.prompts) != 0 {
+		t.Errorf("no repairs: %v after %d calls", err, len(llm.prompts))
+	}
+
+	// Valid JSON that doesn't fit out
+	var out struct{ Title int }
+	if _, err := (StructuredPrompt{Schema: titled}).Check(ctx, llm, core.Response{Content: `{"title": "Go"}`}, &out); err == nil || errors.As(err, &invalid) {
+		t.Errorf("decoding into the wrong type: %v", err)
+	}
+}
@@ -0,0 +1,346 @@
+// Package schema has agents declare the shape of the output they expect
+// from the model as a JSON Schema: a StructuredPrompt asks for JSON
+// matching it, parses and validates the reply and has the model repair it
+// when it doesn't match.
+//
+// Validation covers the keywords model output schemas use: type, enum,
+// const, properties, required, additionalProperties, items, minItems,
+// maxItems, minLength, maxLength, pattern, minimum, maximum,
+// exclusiveMinimum, exclusiveMaximum, allOf, anyOf and oneOf. Others, such
+// as format and $ref, are ignored.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Schema is a compiled JSON Schema.
+type Schema struct {
+	doc json.RawMessage
+
+	types      []string
+	enum       []any
+	constant   *any
+	properties map[string]*Schema
+	required   []string
+	// additional is the schema for properties not listed; nil allows any
+	// and noAdditional forbids them.
+	additional   *Schema
+	noAdditional bool
+	items        *Schema
+	minItems     *int
+	maxItems     *int
+	minLength    *int
+	maxLength    *int
+	pattern      *regexp.Regexp
+	minimum      *float64
+	maximum      *float64
+	exclMinimum  *float64
+	exclMaximum  *float64
+	allOf        []*Schema
+	anyOf        []*Schema
+	oneOf        []*Schema
+}
+
+// keywords is the JSON form of the supported keywords.
+type keywords struct {
+	Type                 json.RawMessage            `json:"type"`
+	Enum                 []any                      `json:"enum"`
+	Const                json.RawMessage            `json:"const"`
+	Properties           map[string]json.RawMessage `json:"properties"`
+	Required             []string                   `json:"required"`
+	AdditionalProperties json.RawMessage            `json:"additionalProperties"`
+	Items                json.RawMessage            `json:"items"`
+	MinItems             *int                       `json:"minItems"`
+	MaxItems             *int                       `json:"maxItems"`
+	MinLength            *int                       `json:"minLength"`
+	MaxLength            *int                       `json:"maxLength"`
+	Pattern              string                     `json:"pattern"`
+	Minimum              *float64                   `json:"minimum"`
+	Maximum              *float64                   `json:"maximum"`
+	ExclusiveMinimum     *float64                   `json:"exclusiveMinimum"`
+	ExclusiveMaximum     *float64                   `json:"exclusiveMaximum"`
+	AllOf                []json.RawMessage          `json:"allOf"`
+	AnyOf                []json.RawMessage          `json:"anyOf"`
+	OneOf                []json.RawMessage          `json:"oneOf"`
+}
+
+// Compile parses a JSON Schema document.
+func Compile(doc []byte) (*Schema, error) {
+	s, err := compile(doc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON Schema: %w", err)
+	}
+	return s, nil
+}
+
+// MustCompile is Compile for schemas known to be valid; it panics otherwise.
+func MustCompile(doc string) *Schema {
+	s, err := Compile([]byte(doc))
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Load compiles a schema given inline, as a JSON object, or as the path of a
+// file holding one.
+func Load(source string) (*Schema, error) {
+	doc := []byte(source)
+	if !strings.HasPrefix(strings.TrimSpace(source), "{") {
+		var err error
+		if doc, err = os.ReadFile(source); err != nil {
+			return nil, err
+		}
+	}
+	return Compile(doc)
+}
+
+func compile(doc []byte) (*Schema, error) {
+	s := &Schema{doc: bytes.TrimSpace(doc)}
+	if string(s.doc) == "true" {
+		return s, nil
+	}
+	var k keywords
+	if err := json.Unmarshal(doc, &k); err != nil {
+		return nil, err
+	}
+	switch t := bytes.TrimSpace(k.Type); {
+	case len(t) == 0:
+	case t[0] == '[':
+		if err := json.Unmarshal(t, &s.types); err != nil {
+			return nil, fmt.Errorf("type: %w", err)
+		}
+	default:
+		var name string
+		if err := json.Unmarshal(t, &name); err != nil {
+			return nil, fmt.Errorf("type: %w", err)
+		}
+		s.types = []string{name}
+	}
+	for _, t := range s.types {
+		if !slices.Contains([]string{"object", "array", "string", "number", "integer", "boolean", "null"}, t) {
+			return nil, fmt.Errorf("unknown type %q", t)
+		}
+	}
+	s.enum, s.required = k.Enum, k.Required
+	if len(k.Const) > 0 {
+		var v any
+		if err := json.Unmarshal(k.Const, &v); err != nil {
+			return nil, fmt.Errorf("const: %w", err)
+		}
+		s.constant = &v
+	}
+	if len(k.Properties) > 0 {
+		s.properties = make(map[string]*Schema, len(k.Properties))
+		for name, doc := range k.Properties {
+			p, err := compile(doc)
+			if err != nil {
+				return nil, fmt.Errorf("properties.%s: %w", name, err)
+			}
+			s.properties[name] = p
+		}
+	}
+	switch a := bytes.TrimSpace(k.AdditionalProperties); string(a) {
+	case "", "true":
+	case "false":
+		s.noAdditional = true
+	default:
+		p, err := compile(a)
+		if err != nil {
+			return nil, fmt.Errorf("additionalProperties: %w", err)
+		}
+		s.additional = p
+	}
+	if len(k.Items) > 0 {
+		items, err := compile(k.Items)
+		if err != nil {
+			return nil, fmt.Errorf("items: %w", err)
+		}
+		s.items = items
+	}
+	s.minItems, s.maxItems = k.MinItems, k.MaxItems
+	s.minLength, s.maxLength = k.MinLength, k.MaxLength
+	if k.Pattern != "" {
+		re, err := regexp.Compile(k.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("pattern: %w", err)
+		}
+		s.pattern = re
+	}
+	s.minimum, s.maximum = k.Minimum, k.Maximum
+	s.exclMinimum, s.exclMaximum = k.ExclusiveMinimum, k.ExclusiveMaximum
+	for _, group := range []struct {
+		name string
+		docs []json.RawMessage
+		dst  *[]*Schema
+	}{{"allOf", k.AllOf, &s.allOf}, {"anyOf", k.AnyOf, &s.anyOf}, {"oneOf", k.OneOf, &s.oneOf}} {
+		for i, doc := range group.docs {
+			sub, err := compile(doc)
+			if err != nil {
+				return nil, fmt.Errorf("%s[%d]: %w", group.name, i, err)
+			}
+			*group.dst = append(*group.dst, sub)
+		}
+	}
+	return s, nil
+}
+
+// String returns the schema document.
+func (s *Schema) String() string {
+	return string(s.doc)
+}
+
+// Validate checks a decoded JSON value against the schema and returns a
+// description of each violation, located by path ("$" is the value itself).
+func (s *Schema) Validate(v any) []string {
+	var problems []string
+	s.validate("$", v, &problems)
+	return problems
+}
+
+func (s *Schema) validate(path string, v any, problems *[]string) {
+	fail := func(format string, args ...any) {
+		*problems = append(*problems, path+": "+fmt.Sprintf(format, args...))
+	}
+	if len(s.types) > 0 && !slices.ContainsFunc(s.types, func(t string) bool { return hasType(v, t) }) {
+		fail("is %s, want %s", typeOf(v), strings.Join(s.types, " or "))
+		return
+	}
+	if len(s.enum) > 0 && !slices.ContainsFunc(s.enum, func(e any) bool { return equal(e, v) }) {
+		fail("is %s, want one of %s", encode(v), encode(s.enum))
+	}
+	if s.constant != nil && !equal(*s.constant, v) {
+		fail("is %s, want %s", encode(v), encode(*s.constant))
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for _, name := range s.required {
+			if _, ok := v[name]; !ok {
+				fail("missing required property %q", name)
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if p, ok := s.properties[name]; ok {
+				p.validate(path+"."+name, v[name], problems)
+			} else if s.noAdditional {
+				fail("has unexpected property %q", name)
+			} else if s.additional != nil {
+				s.additional.validate(path+"."+name, v[name], problems)
+			}
+		}
+	case []any:
+		if s.minItems != nil && len(v) < *s.minItems {
+			fail("has %d items, want at least %d", len(v), *s.minItems)
+		}
+		if s.maxItems != nil && len(v) > *s.maxItems {
+			fail("has %d items, want at most %d", len(v), *s.maxItems)
+		}
+		if s.items != nil {
+			for i, item := range v {
+				s.items.validate(fmt.Sprintf("%s[%d]", path, i), item, problems)
+			}
+		}
+	case string:
+		n := len([]rune(v))
+		if s.minLength != nil && n < *s.minLength {
+			fail("is %d characters, want at least %d", n, *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			fail("is %d characters, want at most %d", n, *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			fail("doesn't match pattern %s", s.pattern)
+		}
+	case float64:
+		if s.minimum != nil && v < *s.minimum {
+			fail("is %v, want at least %v", v, *s.minimum)
+		}
+		if s.maximum != nil && v > *s.maximum {
+			fail("is %v, want at most %v", v, *s.maximum)
+		}
+		if s.exclMinimum != nil && v <= *s.exclMinimum {
+			fail("is %v, want more than %v", v, *s.exclMinimum)
+		}
+		if s.exclMaximum != nil && v >= *s.exclMaximum {
+			fail("is %v, want less than %v", v, *s.exclMaximum)
+		}
+	}
+
+	for _, sub := range s.allOf {
+		sub.validate(path, v, problems)
+	}
+	if len(s.anyOf) > 0 && s.matching(s.anyOf, v) == 0 {
+		fail("matches none of the anyOf schemas")
+	}
+	if len(s.oneOf) > 0 {
+		if n := s.matching(s.oneOf, v); n != 1 {
+			fail("matches %d of the oneOf schemas, want exactly 1", n)
+		}
+	}
+}
+
+// matching counts the schemas v is valid against.
+func (s *Schema) matching(schemas []*Schema, v any) int {
+	n := 0
+	for _, sub := range schemas {
+		if len(sub.Validate(v)) == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+func hasType(v any, t string) bool {
+	switch t {
+	case "integer":
+		f, ok := v.(float64)
+		return ok && f == math.Trunc(f)
+	case "number":
+		_, ok := v.(float64)
+		return ok
+	}
+	return typeOf(v) == t
+}
+
+func typeOf(v any) string {
+	switch v.(type) {
+	case map[string]any:
+		return "object"
+	case []any:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	case nil:
+		return "null"
+	}
+	return fmt.Sprintf("%T", v)
+}
+
+// equal compares decoded JSON values; object keys encode sorted.
+func equal(a, b any) bool {
+	return encode(a) == encode(b)
+}
+
+func encode(v any) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
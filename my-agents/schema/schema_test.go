@@ -0,0 +1,153 @@
+package schema
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decode(t *testing.T, s string) any {
+	t.Helper()
+	var v any
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestCompile(t *testing.T) {
+	for _, doc := range []string{
+		`true`,
+		`{}`,
+		`{"type": ["string", "null"]}`,
+		`{"format": "email", "$ref": "#/x"}`,
+	} {
+		if _, err := Compile([]byte(doc)); err != nil {
+			t.Errorf("%s: %v", doc, err)
+		}
+	}
+	for _, doc := range []string{
+		`{"type": "text"}`,
+		`{"type": 3}`,
+		`{"pattern": "("}`,
+		`{"properties": {"a": {"type": "list"}}}`,
+		`{"items": {"type": "list"}}`,
+		`{"additionalProperties": {"type": "list"}}`,
+		`{"anyOf": [{"type": "list"}]}`,
+		`not json`,
+	} {
+		if _, err := Compile([]byte(doc)); err == nil || !strings.HasPrefix(err.Error(), "invalid JSON Schema") {
+			t.Errorf("%s: %v", doc, err)
+		}
+	}
+	defer func() {
+		if recover() == nil {
+			t.Error("MustCompile didn't panic")
+		}
+	}()
+	MustCompile(`{"type": "text"}`)
+}
+
+func TestLoad(t *testing.T) {
+	doc := `{"type": "object"}`
+	path := filepath.Join(t.TempDir(), "out.schema.json")
+	os.WriteFile(path, []byte(doc), 0o644)
+	for _, source := range []string{doc, "  " + doc, path} {
+		s, err := Load(source)
+		if err != nil {
+			t.Fatalf("%s: %v", source, err)
+		}
+		if s.String() != doc {
+			t.Errorf("%s: schema = %s", source, s)
+		}
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing file loaded")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s := MustCompile(`{
+		"type": "object",
+		"required": ["title", "tags"],
+		"additionalProperties": false,
+		"properties": {
+			"title": {"type": "string", "minLength": 3, "maxLength": 10, "pattern": "^[A-Z]"},
+			"tags": {"type": "array", "minItems": 1, "maxItems": 2, "items": {"enum": ["go", "rust"]}},
+			"score": {"type": "integer", "minimum": 0, "maximum": 10, "exclusiveMaximum": 10},
+			"ratio": {"type": "number", "exclusiveMinimum": 0},
+			"kind": {"const": "post"},
+			"extra": {"type": "object", "additionalProperties": {"type": "string"}}
+		}
+	}`)
+	if problems := s.Validate(decode(t, `{"title": "Go tips", "tags": ["go"], "score": 9, "ratio": 0.5, "kind": "post", "extra": {"a": "b"}}`)); len(problems) != 0 {
+		t.Errorf("valid value: %v", problems)
+	}
+	got := s.Validate(decode(t, `{
+		"title": "go", "tags": ["go", "zig", "rust"], "score": 10.5, "ratio": 0,
+		"kind": "page", "extra": {"a": 1}, "draft": true
+	}`))
+	want := []string{
+		`$: has unexpected property "draft"`,
+		`$.extra.a: is number, want string`,
+		`$.kind: is "page", want "post"`,
+		`$.ratio: is 0, want more than 0`,
+		`$.score: is number, want integer`,
+		`$.tags: has 3 items, want at most 2`,
+		`$.tags[1]: is "zig", want one of ["go","rust"]`,
+		`$.title: is 2 characters, want at least 3`,
+		`$.title: doesn't match pattern ^[A-Z]`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("problems =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	got = s.Validate(decode(t, `{"title": "Go tips and tricks", "tags": [], "score": 10}`))
+	want = []string{
+		`$.score: is 10, want less than 10`,
+		`$.tags: has 0 items, want at least 1`,
+		`$.title: is 18 characters, want at most 10`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("problems = %q, want %q", got, want)
+	}
+	if got := s.Validate(decode(t, `{"tags": ["go"]}`)); !reflect.DeepEqual(got, []string{`$: missing required property "title"`}) {
+		t.Errorf("missing title = %q", got)
+	}
+	if got := s.Validate(decode(t, `[1]`)); !reflect.DeepEqual(got, []string{"$: is array, want object"}) {
+		t.Errorf("array = %q", got)
+	}
+}
+
+func TestValidateCombinators(t *testing.T) {
+	s := MustCompile(`{
+		"allOf": [{"minimum": 0}, {"maximum": 100}],
+		"anyOf": [{"type": "integer"}, {"type": "null"}],
+		"oneOf": [{"maximum": 50}, {"minimum": 10}]
+	}`)
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{`5`, nil},
+		{`60`, nil},
+		// Neither bound applies to null
+		{`null`, []string{"$: matches 2 of the oneOf schemas, want exactly 1"}},
+		{`-1`, []string{"$: is -1, want at least 0"}},
+		{`2.5`, []string{"$: matches none of the anyOf schemas"}},
+		{`20`, []string{"$: matches 2 of the oneOf schemas, want exactly 1"}},
+	}
+	for _, tt := range tests {
+		if got := s.Validate(decode(t, tt.value)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: problems = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+	if got := MustCompile(`true`).Validate(decode(t, `{"a": [1]}`)); len(got) != 0 {
+		t.Errorf("true schema: %q", got)
+	}
+	if got := MustCompile(`{"type": "integer"}`).Validate(decode(t, `3.0`)); len(got) != 0 {
+		t.Errorf("3.0 as an integer: %q", got)
+	}
+}
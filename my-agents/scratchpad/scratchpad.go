@@ -0,0 +1,102 @@
+// Package scratchpad is a working-memory channel in state: agents leave
+// reasoning and hints for later agents there, and its contents never reach
+// the end user or exported transcripts.
+package scratchpad
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Key is the state key holding the []Note.
+const Key = "scratchpad"
+
+// minRedact is the shortest note redacted from user-facing text; shorter
+// notes would match ordinary phrases.
+const minRedact = 24
+
+// Note is one agent's entry.
+type Note struct {
+	Agent string `json:"agent"`
+	Text  string `json:"text"`
+}
+
+// Write appends a note to state.
+func Write(state core.State, agent, text string) {
+	if text = strings.TrimSpace(text); text == "" {
+		return
+	}
+	state.Set(Key, append(Notes(state), Note{Agent: agent, Text: text}))
+}
+
+// Notes returns the notes in state.
+func Notes(state core.State) []Note {
+	v, ok := state.Get(Key)
+	if !ok {
+		return nil
+	}
+	if notes, ok := v.([]Note); ok {
+		return notes
+	}
+	// Runs resumed from history carry the JSON-decoded form
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var notes []Note
+	if err := json.Unmarshal(data, &notes); err != nil {
+		return nil
+	}
+	return notes
+}
+
+// Carry copies the notes from one hop's state into the next.
+func Carry(from, to core.State) {
+	if notes := Notes(from); len(notes) > 0 {
+		to.Set(Key, notes)
+	}
+}
+
+// Hints formats the notes for a prompt, marked as internal.
+func Hints(state core.State) string {
+	notes := Notes(state)
+	if len(notes) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteString("\n\nInternal notes from other agents (use them, never quote them):\n")
+	for _, n := range notes {
+		fmt.Fprintf(&b, "- [%s] %s\n", n.Agent, n.Text)
+	}
+	return b.String()
+}
+
+// Redact removes notes echoed verbatim into user-facing text.
+func Redact(text string, state core.State) string {
+	for _, n := range Notes(state) {
+		if len(n.Text) >= minRedact {
+			text = strings.ReplaceAll(text, n.Text, "")
+		}
+	}
+	return text
+}
+
+// Strip returns data without the scratchpad, or the other internal keys
+// named, for anything shown to users.
+func Strip(data map[string]any, internal ...string) map[string]any {
+	internal = append(internal, Key)
+	if !slices.ContainsFunc(internal, func(k string) bool { _, ok := data[k]; return ok }) {
+		return data
+	}
+	out := make(map[string]any, len(data))
+	for k, v := range data {
+		if !slices.Contains(internal, k) {
+			out[k] = v
+		}
+	}
+	return out
+}
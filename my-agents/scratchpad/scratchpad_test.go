@@ -0,0 +1,71 @@
+package scratchpad
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+func TestWriteCarry(t *testing.T) {
+	state := core.NewState()
+	Write(state, "processor", "  user wants EMEA only ")
+	Write(state, "critic", "   ")
+	want := []Note{{Agent: "processor", Text: "user wants EMEA only"}}
+	if got := Notes(state); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Notes = %+v", got)
+	}
+
+	next := core.NewState()
+	Carry(state, next)
+	if got := Notes(next); !reflect.DeepEqual(got, want) {
+		t.Errorf("carried Notes = %+v", got)
+	}
+	if got := Hints(next); !strings.Contains(got, "- [processor] user wants EMEA only") {
+		t.Errorf("Hints = %q", got)
+	}
+	if got := Hints(core.NewState()); got != "" {
+		t.Errorf("Hints of no notes = %q", got)
+	}
+}
+
+func TestNotesDecoded(t *testing.T) {
+	// As a run resumed from history has them
+	state := core.NewState()
+	state.Set(Key, []any{map[string]any{"agent": "processor", "text": "hint"}})
+	if got := Notes(state); !reflect.DeepEqual(got, []Note{{Agent: "processor", Text: "hint"}}) {
+		t.Errorf("Notes = %+v", got)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	state := core.NewState()
+	Write(state, "processor", "the customer is on the legacy plan")
+	Write(state, "critic", "short")
+	got := Redact("Sure. the customer is on the legacy plan. A short answer.", state)
+	if got != "Sure. . A short answer." {
+		t.Errorf("Redact = %q", got)
+	}
+}
+
+func TestStrip(t *testing.T) {
+	data := map[string]any{"message": "hi", Key: []Note{{Text: "x"}}}
+	if got := Strip(data); !reflect.DeepEqual(got, map[string]any{"message": "hi"}) {
+		t.Errorf("Strip = %v", got)
+	}
+	if _, ok := data[Key]; !ok {
+		t.Error("Strip modified its input")
+	}
+	clean := map[string]any{"message": "hi"}
+	if got := Strip(clean); !reflect.DeepEqual(got, clean) {
+		t.Errorf("Strip of clean data = %v", got)
+	}
+}
+
+func TestStripInternal(t *testing.T) {
+	data := map[string]any{"message": "hi", "trajectory": []string{"step"}}
+	if got := Strip(data, "trajectory"); !reflect.DeepEqual(got, map[string]any{"message": "hi"}) {
+		t.Errorf("Strip = %v", got)
+	}
+}
@@ -0,0 +1,313 @@
+// Package setup is the first-run wizard behind `init`: it asks for a
+// provider, model and memory backend, and renders the answers into an
+// agentflow.toml, leaving the rest of the file as the defaults it started
+// from.
+package setup
+
+import (
+	"bufio"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/doctor"
+)
+
+// Providers the wizard can configure, with the model offered by default.
+var Providers = []Provider{
+	{Type: "ollama", Label: "Ollama (local, no API key)", Model: "gemma3:1b"},
+	{Type: "openai", Label: "OpenAI", Model: "gpt-4o-mini", KeyEnv: "OPENAI_API_KEY"},
+	{Type: "azure", Label: "Azure OpenAI", KeyEnv: "AZURE_OPENAI_API_KEY"},
+}
+
+// Provider is an LLM provider choice.
+type Provider struct {
+	Type   string
+	Label  string
+	Model  string // default model
+	KeyEnv string // env var an API key is offered from; empty when none is needed
+}
+
+// Memory backends the wizard can configure. They are the ones compiled
+// into the binary.
+var Memories = []Memory{
+	{Provider: "", Label: "none (agents keep no memory between runs)"},
+	{Provider: "memory", Label: "in-process (lost on restart)"},
+	{Provider: "sqlite", Label: "SQLite (persisted in the [storage] database)"},
+}
+
+// Memory is an agent memory backend choice.
+type Memory struct {
+	Provider string
+	Label    string
+}
+
+// DefaultOllamaURL is where Ollama listens unless told otherwise.
+const DefaultOllamaURL = "http://localhost:11434"
+
+// Answers are the choices made in the wizard.
+type Answers struct {
+	Provider string
+	Model    string
+	BaseURL  string // ollama
+	Endpoint string // azure
+	APIKey   string
+	Memory   string
+}
+
+// ProviderConfig is the provider the answers describe.
+func (a Answers) ProviderConfig() core.LLMProviderConfig {
+	p := core.LLMProviderConfig{Type: a.Provider, Model: a.Model, APIKey: a.APIKey, BaseURL: a.BaseURL}
+	if a.Provider == "azure" {
+		p.Endpoint, p.ChatDeployment, p.EmbeddingDeployment = a.Endpoint, a.Model, a.Model
+	}
+	return p
+}
+
+// needsTable reports whether the provider needs a [providers.default]
+// table, since [llm] has no room for keys or endpoints.
+func (a Answers) needsTable() bool {
+	return a.APIKey != "" || a.Endpoint != "" || (a.BaseURL != "" && a.BaseURL != DefaultOllamaURL)
+}
+
+// HasSecret reports whether the rendered config holds a credential.
+func (a Answers) HasSecret() bool {
+	return a.APIKey != ""
+}
+
+// Checks are the self-tests proving the answers work: a test call, and for
+// Ollama that the model is pulled.
+func Checks(a Answers) []doctor.Check {
+	var checks []doctor.Check
+	if a.Provider == "ollama" {
+		checks = append(checks, doctor.OllamaModel(a.BaseURL, a.Model))
+	}
+	name := fmt.Sprintf("%s %s", a.Provider, a.Model)
+	if llm, err := core.NewModelProviderFromConfig(a.ProviderConfig()); err != nil {
+		checks = append(checks, doctor.Failed("providers", name, err))
+	} else {
+		checks = append(checks, doctor.Provider(name, llm))
+	}
+	return checks
+}
+
+// ErrNoInput is returned when input ends before a question is answered.
+var ErrNoInput = errors.New("input ended before setup was complete")
+
+// Prompter asks questions and reads one answer per line.
+type Prompter struct {
+	in  *bufio.Scanner
+	out io.Writer
+}
+
+// NewPrompter creates a prompter reading answers from in.
+func NewPrompter(in io.Reader, out io.Writer) *Prompter {
+	return &Prompter{in: bufio.NewScanner(in), out: out}
+}
+
+// Ask asks for free text; an empty answer takes def.
+func (p *Prompter) Ask(question, def string) (string, error) {
+	if def != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+	if !p.in.Scan() {
+		fmt.Fprintln(p.out)
+		if err := p.in.Err(); err != nil {
+			return "", err
+		}
+		return "", ErrNoInput
+	}
+	if answer := strings.TrimSpace(p.in.Text()); answer != "" {
+		return answer, nil
+	}
+	return def, nil
+}
+
+// Require asks until the answer is not empty.
+func (p *Prompter) Require(question, def string) (string, error) {
+	for {
+		answer, err := p.Ask(question, def)
+		if err != nil || answer != "" {
+			return answer, err
+		}
+		fmt.Fprintln(p.out, "  an answer is required")
+	}
+}
+
+// Choose asks for one of labels by number and returns its index.
+func (p *Prompter) Choose(question string, labels []string, def int) (int, error) {
+	fmt.Fprintln(p.out, question)
+	for i, label := range labels {
+		fmt.Fprintf(p.out, "  %d) %s\n", i+1, label)
+	}
+	for {
+		answer, err := p.Ask("Choice", fmt.Sprint(def+1))
+		if err != nil {
+			return 0, err
+		}
+		var n int
+		if _, err := fmt.Sscan(answer, &n); err == nil && n >= 1 && n <= len(labels) {
+			return n - 1, nil
+		}
+		fmt.Fprintf(p.out, "  choose 1 to %d\n", len(labels))
+	}
+}
+
+// Confirm asks a yes/no question.
+func (p *Prompter) Confirm(question string, def bool) (bool, error) {
+	hint := "y/N"
+	if def {
+		hint = "Y/n"
+	}
+	for {
+		answer, err := p.Ask(question+" ("+hint+")", "")
+		if err != nil {
+			return false, err
+		}
+		switch strings.ToLower(answer) {
+		case "":
+			return def, nil
+		case "y", "yes":
+			return true, nil
+		case "n", "no":
+			return false, nil
+		}
+	}
+}
+
+// Interview asks for the provider, its model and credentials, and the
+// memory backend. prev supplies the defaults, so a retry after a failed
+// test call only needs the wrong answer corrected.
+func Interview(p *Prompter, prev Answers) (Answers, error) {
+	labels := make([]string, len(Providers))
+	def := 0
+	for i, pv := range Providers {
+		labels[i] = pv.Label
+		if pv.Type == prev.Provider {
+			def = i
+		}
+	}
+	i, err := p.Choose("Which LLM provider should agents use?", labels, def)
+	if err != nil {
+		return Answers{}, err
+	}
+	pv := Providers[i]
+	a := Answers{Provider: pv.Type}
+	if prev.Provider != pv.Type {
+		prev = Answers{Model: pv.Model, Memory: prev.Memory}
+	}
+
+	switch pv.Type {
+	case "ollama":
+		if a.BaseURL, err = p.Ask("Ollama URL", cmp.Or(prev.BaseURL, os.Getenv("OLLAMA_BASE_URL"), DefaultOllamaURL)); err != nil {
+			return Answers{}, err
+		}
+		a.Model, err = p.Require("Model", prev.Model)
+	case "azure":
+		if a.Endpoint, err = p.Require("Endpoint (https://<resource>.openai.azure.com)", cmp.Or(prev.Endpoint, os.Getenv("AZURE_OPENAI_ENDPOINT"))); err != nil {
+			return Answers{}, err
+		}
+		a.Model, err = p.Require("Chat deployment", cmp.Or(prev.Model, os.Getenv("AZURE_OPENAI_DEPLOYMENT")))
+	default:
+		a.Model, err = p.Require("Model", prev.Model)
+	}
+	if err != nil {
+		return Answers{}, err
+	}
+	if pv.KeyEnv != "" {
+		if a.APIKey, err = askKey(p, pv.KeyEnv, prev.APIKey); err != nil {
+			return Answers{}, err
+		}
+	}
+
+	labels = make([]string, len(Memories))
+	def = 0
+	for i, m := range Memories {
+		labels[i] = m.Label
+		if m.Provider == prev.Memory {
+			def = i
+		}
+	}
+	if i, err = p.Choose("Where should agent memory live?", labels, def); err != nil {
+		return Answers{}, err
+	}
+	a.Memory = Memories[i].Provider
+	return a, nil
+}
+
+// askKey asks for an API key, offering the one already in prev or env.
+// The answer is echoed, so the prompt says where else it can come from.
+func askKey(p *Prompter, env, prev string) (string, error) {
+	if prev == "" {
+		prev = os.Getenv(env)
+	}
+	if prev != "" {
+		use, err := p.Confirm(fmt.Sprintf("Use the API key %s?", mask(prev)), true)
+		if err != nil || use {
+			return prev, err
+		}
+	}
+	return p.Require(fmt.Sprintf("API key (typed input is visible; set $%s to skip this)", env), "")
+}
+
+// mask shows enough of a key to recognize it.
+func mask(key string) string {
+	if len(key) <= 8 {
+		return strings.Repeat("•", len(key))
+	}
+	return key[:3] + "…" + key[len(key)-4:]
+}
+
+// Render returns base with its [llm] section replaced by the answers, and
+// [providers.default] and [agent_memory] sections added as they need.
+// Comments and every other section of base are kept.
+func Render(base []byte, a Answers) []byte {
+	lines := strings.Split(string(base), "\n")
+	lines = removeSection(lines, "providers.default")
+	lines = removeSection(lines, "agent_memory")
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[llm]\nprovider = %q\nmodel = %q\n", a.Provider, a.Model)
+	if a.needsTable() {
+		b.WriteString("\n# The default provider's connection, which [llm] has no room for. Keep\n")
+		b.WriteString("# this file private, or move the key to a [credentials] secret file.\n")
+		fmt.Fprintf(&b, "[providers.default]\ntype = %q\nmodel = %q\n", a.Provider, a.Model)
+		switch a.Provider {
+		case "azure":
+			fmt.Fprintf(&b, "endpoint = %q\nchat_deployment = %q\nembedding_deployment = %q\n", a.Endpoint, a.Model, a.Model)
+		case "ollama":
+			fmt.Fprintf(&b, "base_url = %q\n", a.BaseURL)
+		}
+		if a.APIKey != "" {
+			fmt.Fprintf(&b, "api_key = %q\n", a.APIKey)
+		}
+	}
+	if a.Memory != "" {
+		fmt.Fprintf(&b, "\n[agent_memory]\nprovider = %q\n", a.Memory)
+		if a.Memory == "memory" {
+			b.WriteString("connection = \"memory\"\n")
+		}
+	}
+	generated := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+
+	start, end := section(lines, "llm")
+	if start < 0 {
+		// After the leading top-level keys such as schema_version
+		start = slices.IndexFunc(lines, func(l string) bool { return strings.TrimSpace(l) == "" })
+		if start < 0 {
+			start = len(lines)
+		}
+		generated = append([]string{""}, generated...)
+		end = start
+	}
+	lines = slices.Replace(lines, start, end, generated...)
+	return []byte(strings.Join(lines, "\n"))
+}
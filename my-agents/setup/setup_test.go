@@ -0,0 +1,155 @@
+package setup
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func prompter(answers ...string) *Prompter {
+	return NewPrompter(strings.NewReader(strings.Join(answers, "\n")+"\n"), io.Discard)
+}
+
+func TestAsk(t *testing.T) {
+	p := prompter("", "  llama3  ")
+	if got, err := p.Ask("Model", "gemma3:1b"); err != nil || got != "gemma3:1b" {
+		t.Errorf("empty answer = %q, %v", got, err)
+	}
+	if got, err := p.Ask("Model", "gemma3:1b"); err != nil || got != "llama3" {
+		t.Errorf("answer = %q, %v", got, err)
+	}
+	if _, err := p.Ask("Model", ""); !errors.Is(err, ErrNoInput) {
+		t.Errorf("ended input err = %v", err)
+	}
+}
+
+func TestRequire(t *testing.T) {
+	var out strings.Builder
+	p := NewPrompter(strings.NewReader("\n\nsk-1\n"), &out)
+	if got, err := p.Require("API key", ""); err != nil || got != "sk-1" {
+		t.Errorf("answer = %q, %v", got, err)
+	}
+	if n := strings.Count(out.String(), "an answer is required"); n != 2 {
+		t.Errorf("asked again %d times:\n%s", n, out.String())
+	}
+}
+
+func TestChoose(t *testing.T) {
+	p := prompter("7", "two", "2", "")
+	if i, err := p.Choose("Provider?", []string{"a", "b", "c"}, 0); err != nil || i != 1 {
+		t.Errorf("choice = %d, %v", i, err)
+	}
+	if i, err := p.Choose("Provider?", []string{"a", "b", "c"}, 2); err != nil || i != 2 {
+		t.Errorf("default choice = %d, %v", i, err)
+	}
+}
+
+func TestConfirm(t *testing.T) {
+	p := prompter("", "maybe", "YES", "n")
+	for _, want := range []bool{true, true, false} {
+		if got, err := p.Confirm("Use it?", true); err != nil || got != want {
+			t.Errorf("confirm = %v, %v; want %v", got, err, want)
+		}
+	}
+}
+
+func TestInterview(t *testing.T) {
+	t.Setenv("OLLAMA_BASE_URL", "")
+	a, err := Interview(prompter("1", "", "", "3"), Answers{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != (Answers{Provider: "ollama", BaseURL: DefaultOllamaURL, Model: "gemma3:1b", Memory: "sqlite"}) {
+		t.Errorf("ollama answers = %+v", a)
+	}
+
+	t.Setenv("OPENAI_API_KEY", "sk-from-env-1234")
+	a, err = Interview(prompter("2", "", "", ""), Answers{Memory: "sqlite"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a != (Answers{Provider: "openai", Model: "gpt-4o-mini", APIKey: "sk-from-env-1234", Memory: "sqlite"}) {
+		t.Errorf("openai answers = %+v", a)
+	}
+
+	// A retry keeps the answers of the same provider as defaults
+	prev := Answers{Provider: "azure", Endpoint: "https://acme.openai.azure.com", Model: "chat", APIKey: "az-key-00000", Memory: "memory"}
+	a, err = Interview(prompter("", "", "", "n", "az-key-11111", ""), prev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.Endpoint != prev.Endpoint || a.Model != "chat" || a.APIKey != "az-key-11111" || a.Memory != "memory" {
+		t.Errorf("azure answers = %+v", a)
+	}
+
+	if _, err := Interview(prompter("2"), Answers{}); !errors.Is(err, ErrNoInput) {
+		t.Errorf("ended input err = %v", err)
+	}
+}
+
+func TestMask(t *testing.T) {
+	if got := mask("sk-abcdefgh1234"); got != "sk-…1234" {
+		t.Errorf("mask = %q", got)
+	}
+	if got := mask("short"); got != "•••••" {
+		t.Errorf("mask = %q", got)
+	}
+}
+
+func TestProviderConfig(t *testing.T) {
+	p := Answers{Provider: "azure", Endpoint: "https://acme.openai.azure.com", Model: "chat", APIKey: "k"}.ProviderConfig()
+	if p.Endpoint != "https://acme.openai.azure.com" || p.ChatDeployment != "chat" || p.EmbeddingDeployment != "chat" || p.APIKey != "k" {
+		t.Errorf("azure config = %+v", p)
+	}
+}
+
+func TestChecks(t *testing.T) {
+	checks := Checks(Answers{Provider: "ollama", BaseURL: DefaultOllamaURL, Model: "gemma3:1b"})
+	if len(checks) != 2 || checks[0].Group != "models" || checks[1].Name != "ollama gemma3:1b" {
+		t.Errorf("ollama checks = %+v", checks)
+	}
+	checks = Checks(Answers{Provider: "bogus", Model: "m"})
+	if len(checks) != 1 || checks[0].Group != "providers" {
+		t.Fatalf("checks = %+v", checks)
+	}
+	if _, err := checks[0].Run(t.Context()); err == nil {
+		t.Error("unknown provider passed its check")
+	}
+}
+
+func TestRender(t *testing.T) {
+	base := `schema_version = 1
+
+# The default model
+[llm]
+provider = "ollama"
+model = "gemma3:1b"
+
+[agent_memory]
+provider = "memory"
+
+[agents.processor]
+role = "processor"
+`
+	got := string(Render([]byte(base), Answers{Provider: "openai", Model: "gpt-4o", APIKey: "sk-1", Memory: "sqlite"}))
+	for _, s := range []string{
+		"# The default model\n[llm]\nprovider = \"openai\"\nmodel = \"gpt-4o\"\n",
+		"[providers.default]\ntype = \"openai\"\nmodel = \"gpt-4o\"\napi_key = \"sk-1\"\n",
+		"[agent_memory]\nprovider = \"sqlite\"\n",
+		"[agents.processor]\nrole = \"processor\"\n",
+	} {
+		if !strings.Contains(got, s) {
+			t.Errorf("rendered config lacks %q:\n%s", s, got)
+		}
+	}
+	if strings.Count(got, "[agent_memory]") != 1 || strings.Contains(got, `"memory"`) {
+		t.Errorf("old memory section kept:\n%s", got)
+	}
+
+	// Local Ollama at its default address needs no [providers.default]
+	got = string(Render([]byte("schema_version = 1\n\n[agents.processor]\n"), Answers{Provider: "ollama", Model: "llama3", BaseURL: DefaultOllamaURL}))
+	if want := "schema_version = 1\n\n[llm]\nprovider = \"ollama\"\nmodel = \"llama3\"\n\n[agents.processor]\n"; got != want {
+		t.Errorf("rendered %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,55 @@
+package setup
+
+import (
+	"embed"
+	"fmt"
+	"strings"
+)
+
+//go:embed templates/*.toml
+var templateFiles embed.FS
+
+// Template is a ready-made workflow: the agents' prompts, tools and the
+// sections they rely on, overlaid on the default config by `init
+// -template`.
+type Template struct {
+	Name        string
+	Title       string
+	Description string
+	// Memory is the memory backend offered by default.
+	Memory string
+}
+
+// Templates is the gallery, in the order it is listed.
+var Templates = []Template{
+	{Name: "support-triage", Title: "Customer-support triage", Memory: "sqlite",
+		Description: "classify tickets by category, priority and sentiment, and draft a reply"},
+	{Name: "document-qa", Title: "Document Q&A", Memory: "sqlite",
+		Description: "answer questions from ingested documents, quoting their sources"},
+	{Name: "code-review", Title: "Code review",
+		Description: "review a change with a sandboxed linter and list findings by severity"},
+	{Name: "research-report", Title: "Research report",
+		Description: "plan the questions on a topic and write a structured report"},
+}
+
+// FindTemplate returns the template called name.
+func FindTemplate(name string) (Template, error) {
+	names := make([]string, len(Templates))
+	for i, t := range Templates {
+		if t.Name == name {
+			return t, nil
+		}
+		names[i] = t.Name
+	}
+	return Template{}, fmt.Errorf("unknown template %q (available: %s)", name, strings.Join(names, ", "))
+}
+
+// Overlay returns the config sections the template sets.
+func (t Template) Overlay() []byte {
+	data, err := templateFiles.ReadFile("templates/" + t.Name + ".toml")
+	if err != nil {
+		// Every entry of Templates has an embedded file
+		panic(err)
+	}
+	return data
+}
@@ -0,0 +1,29 @@
+package setup
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+func TestTemplates(t *testing.T) {
+	for _, tmpl := range Templates {
+		var v map[string]any
+		if _, err := toml.Decode(string(tmpl.Overlay()), &v); err != nil {
+			t.Errorf("%s: %v", tmpl.Name, err)
+		}
+		if _, ok := v["agents"]; !ok {
+			t.Errorf("%s sets no agents", tmpl.Name)
+		}
+	}
+}
+
+func TestFindTemplate(t *testing.T) {
+	if tmpl, err := FindTemplate("document-qa"); err != nil || tmpl.Memory != "sqlite" {
+		t.Errorf("document-qa = %+v, %v", tmpl, err)
+	}
+	if _, err := FindTemplate("chatbot"); err == nil || !strings.Contains(err.Error(), "support-triage, document-qa") {
+		t.Errorf("unknown template err = %v", err)
+	}
+}
@@ -0,0 +1,118 @@
+package setup
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// The config is edited as lines rather than decoded and re-encoded, so the
+// comments documenting each section survive.
+
+var header = regexp.MustCompile(`^\s*(\[\[?)\s*([^\]]+?)\s*\]\]?`)
+
+// table is a [name] or [[name]] table of a TOML file split into lines.
+type table struct {
+	name  string
+	array bool
+	start int // header line
+	end   int // after the last key, before the comments of whatever follows
+}
+
+// tables lists the tables of lines in order.
+func tables(lines []string) []table {
+	var out []table
+	for i, l := range lines {
+		if m := header.FindStringSubmatch(l); m != nil {
+			out = append(out, table{name: m[2], array: m[1] == "[[", start: i})
+		}
+	}
+	for i := range out {
+		end := len(lines)
+		if i+1 < len(out) {
+			end = out[i+1].start
+		}
+		for end > out[i].start+1 && isTrivia(lines[end-1]) {
+			end--
+		}
+		out[i].end = end
+	}
+	return out
+}
+
+func isTrivia(line string) bool {
+	l := strings.TrimSpace(line)
+	return l == "" || strings.HasPrefix(l, "#")
+}
+
+// lead returns where the comment block directly above line start begins.
+func lead(lines []string, start int) int {
+	for start > 0 && strings.HasPrefix(strings.TrimSpace(lines[start-1]), "#") {
+		start--
+	}
+	return start
+}
+
+// section finds the [name] table: its header line and the end of its keys.
+func section(lines []string, name string) (start, end int) {
+	for _, t := range tables(lines) {
+		if t.name == name && !t.array {
+			return t.start, t.end
+		}
+	}
+	return -1, -1
+}
+
+// removeSection drops the [name] table, its comments and the blank line
+// after it.
+func removeSection(lines []string, name string) []string {
+	start, end := section(lines, name)
+	if start < 0 {
+		return lines
+	}
+	start = lead(lines, start)
+	if end < len(lines) && strings.TrimSpace(lines[end]) == "" {
+		end++
+	}
+	return slices.Delete(lines, start, end)
+}
+
+// Apply overlays the tables of overlay on base. A table base also has is
+// replaced in place, along with its comments when the overlay comments it;
+// a new one goes after the last table of its group ("agents" for
+// [agents.triage]), or at the end.
+func Apply(base, overlay []byte) []byte {
+	lines := strings.Split(string(base), "\n")
+	over := strings.Split(string(overlay), "\n")
+	for _, t := range tables(over) {
+		from := lead(over, t.start)
+		block := over[from:t.end]
+		if !t.array {
+			if start, end := section(lines, t.name); start >= 0 {
+				// The base's comments stay unless the overlay has its own
+				if from < t.start {
+					start = lead(lines, start)
+				}
+				lines = slices.Replace(lines, start, end, block...)
+				continue
+			}
+		}
+		group, _, _ := strings.Cut(t.name, ".")
+		at := -1
+		for _, bt := range tables(lines) {
+			if bt.name == group || strings.HasPrefix(bt.name, group+".") {
+				at = bt.end
+			}
+		}
+		if at < 0 {
+			for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+				lines = lines[:len(lines)-1]
+			}
+			lines = append(append(lines, ""), block...)
+			lines = append(lines, "")
+			continue
+		}
+		lines = slices.Insert(lines, at, append([]string{""}, block...)...)
+	}
+	return []byte(strings.Join(lines, "\n"))
+}
@@ -0,0 +1,82 @@
+package setup
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTables(t *testing.T) {
+	lines := strings.Split("[llm]\nmodel = \"x\"\n\n# Agents\n[[agents.list]]\nname = \"a\"\n# trailing\n", "\n")
+	got := tables(lines)
+	if len(got) != 2 {
+		t.Fatalf("tables = %+v", got)
+	}
+	if got[0] != (table{name: "llm", start: 0, end: 2}) || got[1] != (table{name: "agents.list", array: true, start: 4, end: 6}) {
+		t.Errorf("tables = %+v", got)
+	}
+}
+
+func TestRemoveSection(t *testing.T) {
+	lines := strings.Split("[llm]\nmodel = \"x\"\n\n# Memory\n[agent_memory]\nprovider = \"sqlite\"\n\n[agents.processor]\n", "\n")
+	got := strings.Join(removeSection(lines, "agent_memory"), "\n")
+	if want := "[llm]\nmodel = \"x\"\n\n[agents.processor]\n"; got != want {
+		t.Errorf("removed to %q, want %q", got, want)
+	}
+}
+
+func TestApply(t *testing.T) {
+	base := `schema_version = 1
+
+# The processor
+[agents.processor]
+system_prompt = "old"
+
+[agents.formatter]
+system_prompt = "fmt"
+
+# Storage
+[storage]
+path = "x.db"
+`
+	overlay := `[agents.processor]
+system_prompt = "new"
+
+# The linter
+[sandbox.tools.lint]
+image = "lint"
+
+[agents.enhancer]
+system_prompt = "enhance"
+`
+	want := `schema_version = 1
+
+# The processor
+[agents.processor]
+system_prompt = "new"
+
+[agents.formatter]
+system_prompt = "fmt"
+
+[agents.enhancer]
+system_prompt = "enhance"
+
+# Storage
+[storage]
+path = "x.db"
+
+# The linter
+[sandbox.tools.lint]
+image = "lint"
+`
+	if got := string(Apply([]byte(base), []byte(overlay))); got != want {
+		t.Errorf("applied:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestApplyReplacesComments(t *testing.T) {
+	base := "# Old comment\n[formatter]\nshow_tool_results = false\n"
+	overlay := "# New comment\n[formatter]\nshow_tool_results = true\n"
+	if got := string(Apply([]byte(base), []byte(overlay))); got != overlay {
+		t.Errorf("applied %q, want %q", got, overlay)
+	}
+}
@@ -0,0 +1,115 @@
+package simulate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+)
+
+// RunnerPipeline answers through a started runner, reading the outcome of
+// each run from the history store its recorder writes to.
+type RunnerPipeline struct {
+	Runner  core.Runner
+	Runs    history.Store
+	Route   string        // entry agent (default "processor")
+	Timeout time.Duration // per reply (default 2m)
+	Poll    time.Duration // history polling interval (default 200ms)
+
+	mu      sync.Mutex
+	pending map[string]*history.Run // session → run awaiting a clarification answer
+}
+
+// Ask emits input as a new run and waits for it to finish. A run that pauses
+// on a clarification question replies with the question, and the session's
+// next message is sent as the answer.
+func (p *RunnerPipeline) Ask(ctx context.Context, sessionID, input string, metadata map[string]string) (Reply, error) {
+	p.mu.Lock()
+	paused := p.pending[sessionID]
+	delete(p.pending, sessionID)
+	p.mu.Unlock()
+
+	var event core.Event
+	seen := 0
+	if paused != nil {
+		var err error
+		if event, err = history.AnswerEvent(paused, input); err != nil {
+			return Reply{}, err
+		}
+		seen = len(paused.Steps)
+	} else {
+		route := p.Route
+		if route == "" {
+			route = "processor"
+		}
+		meta := map[string]string{core.RouteMetadataKey: route, core.SessionIDKey: sessionID}
+		for k, v := range metadata {
+			meta[k] = v
+		}
+		event = core.NewEvent(route, core.EventData{"input": input}, meta)
+	}
+	reply := Reply{RunID: event.GetID()}
+	if paused != nil {
+		reply.RunID = paused.ID
+	}
+	if err := p.Runner.Emit(event); err != nil {
+		return reply, err
+	}
+
+	run, err := p.wait(ctx, reply.RunID, seen)
+	if err != nil {
+		return reply, err
+	}
+	switch run.Status {
+	case history.StatusAwaitingInput:
+		p.mu.Lock()
+		if p.pending == nil {
+			p.pending = make(map[string]*history.Run)
+		}
+		p.pending[sessionID] = run
+		p.mu.Unlock()
+		reply.Text = run.Question
+	case history.StatusFailed:
+		return reply, fmt.Errorf("run %s failed: %s", run.ID, run.Error)
+	default:
+		reply.Text = run.FinalResponse
+	}
+	return reply, nil
+}
+
+// wait polls until the run has more than seen steps and is no longer running.
+func (p *RunnerPipeline) wait(ctx context.Context, runID string, seen int) (*history.Run, error) {
+	timeout, poll := p.Timeout, p.Poll
+	if timeout <= 0 {
+		timeout = 2 * time.Minute
+	}
+	if poll <= 0 {
+		poll = 200 * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("run %s: no reply: %w", runID, ctx.Err())
+		case <-ticker.C:
+		}
+		run, err := p.Runs.Get(ctx, runID)
+		if errors.Is(err, history.ErrNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(run.Steps) > seen && run.Status != history.StatusRunning {
+			return run, nil
+		}
+	}
+}
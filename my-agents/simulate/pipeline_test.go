@@ -0,0 +1,74 @@
+package simulate
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/clarify"
+	"my-agents/history"
+)
+
+// asker is a runner whose runs ask one clarifying question and then answer,
+// recording them in a history store.
+type asker struct {
+	core.Runner
+	runs history.Store
+}
+
+func (a *asker) Emit(event core.Event) error {
+	ctx := context.Background()
+	data := event.GetData()
+	if answer, ok := data[clarify.AnswerKey].(string); ok {
+		runID, _ := event.GetMetadataValue(history.RunIDKey)
+		run, err := a.runs.Get(ctx, runID)
+		if err != nil {
+			return err
+		}
+		run.Steps = append(run.Steps, history.Step{Agent: event.GetTargetAgentID(), Input: data})
+		run.Status, run.FinalResponse = history.StatusCompleted, "answer for "+answer
+		return a.runs.Save(ctx, run)
+	}
+	session, _ := event.GetMetadataValue(core.SessionIDKey)
+	return a.runs.Save(ctx, &history.Run{
+		ID: event.GetID(), SessionID: session, Input: data["input"].(string),
+		Status: history.StatusAwaitingInput, Question: "Which region?",
+		Steps: []history.Step{{Agent: event.GetTargetAgentID(), Input: data}},
+	})
+}
+
+func TestRunnerPipeline(t *testing.T) {
+	runs := history.NewMemoryStore()
+	p := &RunnerPipeline{Runner: &asker{runs: runs}, Runs: runs, Poll: time.Millisecond, Timeout: 5 * time.Second}
+
+	reply, err := p.Ask(context.Background(), "s1", "Show sales", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reply.Text != "Which region?" {
+		t.Errorf("first reply = %+v, want the question", reply)
+	}
+	answer, err := p.Ask(context.Background(), "s1", "EMEA", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if answer.Text != "answer for EMEA" || answer.RunID != reply.RunID {
+		t.Errorf("answer = %+v, want the same run resumed", answer)
+	}
+}
+
+// silent is a runner that never records its runs.
+type silent struct {
+	core.Runner
+}
+
+func (silent) Emit(event core.Event) error { return nil }
+
+func TestRunnerPipelineTimeout(t *testing.T) {
+	p := &RunnerPipeline{Runner: silent{}, Runs: history.NewMemoryStore(), Poll: time.Millisecond, Timeout: 20 * time.Millisecond}
+	if _, err := p.Ask(context.Background(), "s1", "hi", nil); err == nil {
+		t.Error("Ask returned without a recorded run")
+	}
+}
@@ -0,0 +1,276 @@
+// Package simulate drives multi-turn conversations against the pipeline with
+// an LLM role-playing end users from persona definitions, then scores each
+// conversation so conversational quality can be regression tested.
+package simulate
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// DefaultMaxTurns bounds a conversation when neither the persona nor the
+// config sets a limit.
+const DefaultMaxTurns = 6
+
+// Done is what the simulated user replies once its goal is met or it gives up.
+const Done = "DONE"
+
+// Config is the [simulation] section of agentflow.toml:
+//
+//	[simulation]
+//	personas = "personas.toml"
+//	provider = "simulator"
+//	judge_provider = "critic"
+type Config struct {
+	Personas      string `toml:"personas"`       // persona file (default personas.toml)
+	Provider      string `toml:"provider"`       // [providers.<name>] playing the user; empty uses the default
+	JudgeProvider string `toml:"judge_provider"` // scores conversations; empty uses Provider
+	MaxTurns      int    `toml:"max_turns"`      // default DefaultMaxTurns
+	TurnTimeout   string `toml:"turn_timeout"`   // per pipeline reply (default "2m")
+	// MinScore is the lowest passing overall score. A persona also fails
+	// when the judge finds its goal unmet.
+	MinScore float64 `toml:"min_score"`
+}
+
+// Persona is one simulated end user. Personas are listed in a TOML file:
+//
+//	[[persona]]
+//	name = "impatient-novice"
+//	description = "New to the product, writes short messages with typos."
+//	goal = "Find out how to reset a password."
+//	style = "terse, a little frustrated"
+//	opening = "how do i reset my pasword"
+type Persona struct {
+	Name        string            `toml:"name" json:"name"`
+	Description string            `toml:"description" json:"description"`
+	Goal        string            `toml:"goal" json:"goal"`
+	Style       string            `toml:"style" json:"style,omitempty"`
+	Opening     string            `toml:"opening" json:"opening,omitempty"` // first message; generated when empty
+	MaxTurns    int               `toml:"max_turns" json:"max_turns,omitempty"`
+	Locale      string            `toml:"locale" json:"locale,omitempty"`
+	Metadata    map[string]string `toml:"metadata" json:"metadata,omitempty"` // extra event metadata (tenant, constraints...)
+}
+
+// LoadPersonas reads the [[persona]] entries of a TOML file.
+func LoadPersonas(path string) ([]Persona, error) {
+	var file struct {
+		Persona []Persona `toml:"persona"`
+	}
+	if _, err := toml.DecodeFile(path, &file); err != nil {
+		return nil, fmt.Errorf("failed to load personas from %s: %w", path, err)
+	}
+	seen := make(map[string]bool, len(file.Persona))
+	for i, p := range file.Persona {
+		if p.Name == "" {
+			return nil, fmt.Errorf("%s: persona %d has no name", path, i+1)
+		}
+		if p.Goal == "" {
+			return nil, fmt.Errorf("%s: persona %s has no goal", path, p.Name)
+		}
+		if seen[p.Name] {
+			return nil, fmt.Errorf("%s: duplicate persona %s", path, p.Name)
+		}
+		seen[p.Name] = true
+	}
+	return file.Persona, nil
+}
+
+// Turn is one exchange of a simulated conversation.
+type Turn struct {
+	User      string `json:"user"`
+	Assistant string `json:"assistant"`
+	RunID     string `json:"run_id,omitempty"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Score is the judge's assessment of a conversation. Ratings are 0-10.
+type Score struct {
+	GoalMet     bool    `json:"goal_met"`
+	Helpfulness float64 `json:"helpfulness"`
+	Coherence   float64 `json:"coherence"`
+	Tone        float64 `json:"tone"`
+	Overall     float64 `json:"overall"`
+	Notes       string  `json:"notes,omitempty"`
+}
+
+// Result is one persona's conversation and its score.
+type Result struct {
+	Persona   string        `json:"persona"`
+	SessionID string        `json:"session_id"`
+	Turns     []Turn        `json:"turns"`
+	Score     Score         `json:"score"`
+	Ended     string        `json:"ended"` // "done", "max_turns" or "error"
+	Duration  time.Duration `json:"duration_ns"`
+}
+
+// Reply is the pipeline's answer to one user message.
+type Reply struct {
+	Text  string
+	RunID string
+}
+
+// Pipeline answers user messages. Messages with the same session ID belong
+// to one conversation.
+type Pipeline interface {
+	Ask(ctx context.Context, sessionID, input string, metadata map[string]string) (Reply, error)
+}
+
+// Simulator plays personas against a pipeline.
+type Simulator struct {
+	User     core.ModelProvider // role-plays the persona
+	Judge    core.ModelProvider // scores the conversation; nil reuses User
+	Pipeline Pipeline
+	MaxTurns int
+}
+
+// Run holds a full conversation for persona and scores it. A pipeline error
+// ends the conversation but is recorded in the result rather than returned.
+func (s *Simulator) Run(ctx context.Context, persona Persona) (*Result, error) {
+	start := time.Now()
+	res := &Result{Persona: persona.Name, SessionID: fmt.Sprintf("sim-%s-%d", persona.Name, start.UnixNano())}
+	maxTurns := persona.MaxTurns
+	if maxTurns <= 0 {
+		maxTurns = s.MaxTurns
+	}
+	if maxTurns <= 0 {
+		maxTurns = DefaultMaxTurns
+	}
+	metadata := map[string]string{core.SessionIDKey: res.SessionID}
+	for k, v := range persona.Metadata {
+		metadata[k] = v
+	}
+	if persona.Locale != "" {
+		metadata["locale"] = persona.Locale
+	}
+
+	message := persona.Opening
+	for len(res.Turns) < maxTurns {
+		if message == "" {
+			next, err := s.next(ctx, persona, res.Turns)
+			if err != nil {
+				return nil, fmt.Errorf("persona %s: %w", persona.Name, err)
+			}
+			if next == "" {
+				res.Ended = "done"
+				break
+			}
+			message = next
+		}
+		reply, err := s.Pipeline.Ask(ctx, res.SessionID, message, metadata)
+		turn := Turn{User: message, Assistant: reply.Text, RunID: reply.RunID}
+		message = ""
+		if err != nil {
+			turn.Error = err.Error()
+			res.Turns = append(res.Turns, turn)
+			res.Ended = "error"
+			break
+		}
+		res.Turns = append(res.Turns, turn)
+		core.Logger().Debug().Str("persona", persona.Name).Int("turn", len(res.Turns)).Msg("Simulated turn")
+	}
+	if res.Ended == "" {
+		res.Ended = "max_turns"
+	}
+
+	score, err := s.score(ctx, persona, res.Turns)
+	if err != nil {
+		return nil, fmt.Errorf("persona %s: judge: %w", persona.Name, err)
+	}
+	res.Score = score
+	res.Duration = time.Since(start)
+	return res, nil
+}
+
+// next asks the simulated user for its next message, or "" when it is done.
+func (s *Simulator) next(ctx context.Context, persona Persona, turns []Turn) (string, error) {
+	var system strings.Builder
+	fmt.Fprintf(&system, "You are role-playing a user talking to an AI assistant. Stay in character and never reveal that you are simulated.\n\nWho you are: %s\nYour goal: %s\n", persona.Description, persona.Goal)
+	if persona.Style != "" {
+		fmt.Fprintf(&system, "How you write: %s\n", persona.Style)
+	}
+	fmt.Fprintf(&system, "\nWrite only your next message to the assistant. When your goal has been met, or you would give up, reply with just %s.", Done)
+
+	user := "Start the conversation."
+	if len(turns) > 0 {
+		user = "Conversation so far:\n\n" + render(turns) + "\nYour next message:"
+	}
+	resp, err := s.User.Call(ctx, core.Prompt{System: system.String(), User: user})
+	if err != nil {
+		return "", err
+	}
+	text := strings.Trim(strings.TrimSpace(resp.Content), `"`)
+	if strings.EqualFold(strings.TrimRight(text, "."), Done) {
+		return "", nil
+	}
+	return text, nil
+}
+
+var ratingPattern = regexp.MustCompile(`(?im)^\s*(goal_met|helpfulness|coherence|tone|notes)\s*:\s*(.+)$`)
+
+// score asks the judge to rate the conversation against the persona's goal.
+func (s *Simulator) score(ctx context.Context, persona Persona, turns []Turn) (Score, error) {
+	judge := s.Judge
+	if judge == nil {
+		judge = s.User
+	}
+	resp, err := judge.Call(ctx, core.Prompt{
+		System: "You evaluate conversations between a user and an AI assistant. Reply with exactly these lines:\n" +
+			"GOAL_MET: yes or no\nHELPFULNESS: 0-10\nCOHERENCE: 0-10\nTONE: 0-10\nNOTES: one sentence on the biggest problem, or none",
+		User: fmt.Sprintf("User: %s\nUser's goal: %s\n\nConversation:\n\n%s", persona.Description, persona.Goal, render(turns)),
+	})
+	if err != nil {
+		return Score{}, err
+	}
+
+	var score Score
+	for _, m := range ratingPattern.FindAllStringSubmatch(resp.Content, -1) {
+		value := strings.TrimSpace(m[2])
+		switch strings.ToLower(m[1]) {
+		case "goal_met":
+			score.GoalMet = strings.HasPrefix(strings.ToLower(value), "y")
+		case "helpfulness":
+			score.Helpfulness = rating(value)
+		case "coherence":
+			score.Coherence = rating(value)
+		case "tone":
+			score.Tone = rating(value)
+		case "notes":
+			if !strings.EqualFold(value, "none") {
+				score.Notes = value
+			}
+		}
+	}
+	score.Overall = (score.Helpfulness + score.Coherence + score.Tone) / 3
+	if !score.GoalMet {
+		score.Overall /= 2
+	}
+	score.Overall = float64(int(score.Overall*10+0.5)) / 10
+	return score, nil
+}
+
+var numberPattern = regexp.MustCompile(`\d+(\.\d+)?`)
+
+func rating(s string) float64 {
+	f, _ := strconv.ParseFloat(numberPattern.FindString(s), 64)
+	return min(max(f, 0), 10)
+}
+
+func render(turns []Turn) string {
+	var b strings.Builder
+	for _, t := range turns {
+		fmt.Fprintf(&b, "User: %s\n", t.User)
+		if t.Error != "" {
+			fmt.Fprintf(&b, "Assistant: (failed: %s)\n\n", t.Error)
+			continue
+		}
+		fmt.Fprintf(&b, "Assistant: %s\n\n", t.Assistant)
+	}
+	return b.String()
+}
@@ -0,0 +1,124 @@
+package simulate
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+func TestLoadPersonas(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "personas.toml")
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	personas, err := LoadPersonas(write("[[persona]]\nname = \"novice\"\ngoal = \"reset a password\"\nmax_turns = 3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(personas) != 1 || personas[0].Name != "novice" || personas[0].MaxTurns != 3 {
+		t.Errorf("LoadPersonas = %+v", personas)
+	}
+	for _, bad := range []string{
+		"[[persona]]\ngoal = \"g\"\n",
+		"[[persona]]\nname = \"a\"\n",
+		"[[persona]]\nname = \"a\"\ngoal = \"g\"\n[[persona]]\nname = \"a\"\ngoal = \"g\"\n",
+	} {
+		if _, err := LoadPersonas(write(bad)); err == nil {
+			t.Errorf("LoadPersonas accepted %q", bad)
+		}
+	}
+}
+
+// user plays the persona with its lines, then says Done, and judges with
+// verdict.
+type user struct {
+	core.ModelProvider
+	lines   []string
+	verdict string
+}
+
+func (u *user) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	if strings.HasPrefix(prompt.System, "You evaluate") {
+		return core.Response{Content: u.verdict}, nil
+	}
+	if len(u.lines) == 0 {
+		return core.Response{Content: "done."}, nil
+	}
+	line := u.lines[0]
+	u.lines = u.lines[1:]
+	return core.Response{Content: `"` + line + `"`}, nil
+}
+
+// echoPipeline answers every message, failing those equal to fail.
+type echoPipeline struct {
+	fail     string
+	metadata map[string]string
+}
+
+func (p *echoPipeline) Ask(ctx context.Context, sessionID, input string, metadata map[string]string) (Reply, error) {
+	p.metadata = metadata
+	if input == p.fail {
+		return Reply{}, errors.New("pipeline down")
+	}
+	return Reply{Text: "re: " + input, RunID: "run-" + input}, nil
+}
+
+const goodVerdict = "GOAL_MET: yes\nHELPFULNESS: 8\nCOHERENCE: 9/10\nTONE: 7\nNOTES: none"
+
+func TestRun(t *testing.T) {
+	pipeline := &echoPipeline{}
+	s := &Simulator{User: &user{lines: []string{"and then?"}, verdict: goodVerdict}, Pipeline: pipeline}
+	res, err := s.Run(context.Background(), Persona{Name: "novice", Goal: "g", Opening: "hi", Locale: "de-DE", Metadata: map[string]string{"tenant": "acme"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Ended != "done" || len(res.Turns) != 2 || res.Turns[1] != (Turn{User: "and then?", Assistant: "re: and then?", RunID: "run-and then?"}) {
+		t.Errorf("Run = %+v", res)
+	}
+	if res.Score != (Score{GoalMet: true, Helpfulness: 8, Coherence: 9, Tone: 7, Overall: 8}) {
+		t.Errorf("Score = %+v", res.Score)
+	}
+	if pipeline.metadata["tenant"] != "acme" || pipeline.metadata["locale"] != "de-DE" || pipeline.metadata[core.SessionIDKey] != res.SessionID {
+		t.Errorf("metadata = %v", pipeline.metadata)
+	}
+}
+
+func TestRunEnds(t *testing.T) {
+	s := &Simulator{User: &user{lines: []string{"a", "b", "c"}, verdict: "GOAL_MET: no\nHELPFULNESS: 4\nCOHERENCE: 4\nTONE: 4\nNOTES: vague"}, Pipeline: &echoPipeline{}, MaxTurns: 2}
+	res, err := s.Run(context.Background(), Persona{Name: "p", Goal: "g"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Ended != "max_turns" || len(res.Turns) != 2 {
+		t.Errorf("Run ended %q after %d turns", res.Ended, len(res.Turns))
+	}
+	if res.Score.Overall != 2 || res.Score.Notes != "vague" {
+		t.Errorf("Score = %+v, want the overall halved for an unmet goal", res.Score)
+	}
+
+	s = &Simulator{User: &user{lines: []string{"a", "b"}, verdict: goodVerdict}, Pipeline: &echoPipeline{fail: "a"}}
+	res, err = s.Run(context.Background(), Persona{Name: "p", Goal: "g"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Ended != "error" || len(res.Turns) != 1 || res.Turns[0].Error != "pipeline down" {
+		t.Errorf("Run = %+v", res)
+	}
+}
+
+func TestRating(t *testing.T) {
+	for s, want := range map[string]float64{"8": 8, "7.5/10": 7.5, "12": 10, "none": 0} {
+		if got := rating(s); got != want {
+			t.Errorf("rating(%q) = %v, want %v", s, got, want)
+		}
+	}
+}
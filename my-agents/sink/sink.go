@@ -0,0 +1,71 @@
+// Package sink defines destinations for final agent output.
+package sink
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Sink receives the final response of a run.
+type Sink interface {
+	Write(ctx context.Context, sessionID, content string) error
+}
+
+// Streamer is a sink that can show a response as it is generated. Write
+// still delivers the whole response afterwards.
+type Streamer interface {
+	Sink
+	WriteChunk(ctx context.Context, sessionID, chunk string) error
+}
+
+// Writer prints final responses to an io.Writer, streamed when the agent
+// streams them.
+type Writer struct {
+	Out io.Writer
+
+	mu       sync.Mutex
+	streamed map[string]string // text shown so far by session
+}
+
+// Stdout returns a sink that prints to standard output.
+func Stdout() *Writer {
+	return &Writer{Out: os.Stdout}
+}
+
+func (w *Writer) Write(ctx context.Context, sessionID, content string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if shown, ok := w.streamed[sessionID]; ok {
+		delete(w.streamed, sessionID)
+		if rest, same := strings.CutPrefix(content, shown); same {
+			_, err := fmt.Fprintf(w.Out, "%s\n", rest)
+			return err
+		}
+		// Post-processing changed what was streamed
+		_, err := fmt.Fprintf(w.Out, "\n\n📝 Final Response (revised):\n%s\n", content)
+		return err
+	}
+	_, err := fmt.Fprintf(w.Out, "\n📝 Final Response:\n%s\n", content)
+	return err
+}
+
+func (w *Writer) WriteChunk(ctx context.Context, sessionID, chunk string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.streamed == nil {
+		w.streamed = make(map[string]string)
+	}
+	shown, started := w.streamed[sessionID]
+	if !started {
+		if _, err := fmt.Fprint(w.Out, "\n📝 Final Response:\n"); err != nil {
+			return err
+		}
+	}
+	w.streamed[sessionID] = shown + chunk
+	_, err := io.WriteString(w.Out, chunk)
+	return err
+}
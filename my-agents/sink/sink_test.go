@@ -0,0 +1,54 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+func TestWriterPrintsFinalResponse(t *testing.T) {
+	var out bytes.Buffer
+	w := &Writer{Out: &out}
+	if err := w.Write(context.Background(), "s1", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "\n📝 Final Response:\nhello\n" {
+		t.Errorf("printed %q", got)
+	}
+}
+
+func TestWriterFinishesStream(t *testing.T) {
+	var out bytes.Buffer
+	w := &Writer{Out: &out}
+	ctx := context.Background()
+	for _, chunk := range []string{"hel", "lo"} {
+		if err := w.WriteChunk(ctx, "s1", chunk); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Write(ctx, "s1", "hello world"); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "\n📝 Final Response:\nhello world\n" {
+		t.Errorf("printed %q, want the streamed text finished by the rest", got)
+	}
+}
+
+func TestWriterRevisesChangedStream(t *testing.T) {
+	var out bytes.Buffer
+	w := &Writer{Out: &out}
+	ctx := context.Background()
+	w.WriteChunk(ctx, "s1", "draft")
+	w.WriteChunk(ctx, "s2", "other")
+	if err := w.Write(ctx, "s1", "final"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(out.Bytes(), []byte("(revised):\nfinal\n")) {
+		t.Errorf("printed %q, want the revised response in full", out.String())
+	}
+	out.Reset()
+	w.Write(ctx, "s1", "again")
+	if got := out.String(); got != "\n📝 Final Response:\nagain\n" {
+		t.Errorf("a later response of the session printed %q", got)
+	}
+}
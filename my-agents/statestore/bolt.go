@@ -0,0 +1,133 @@
+package statestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	boltBucket = []byte("run_state")
+	boltClaims = []byte("run_claims")
+)
+
+// boltClaim is a claim as kept in the claims bucket.
+type boltClaim struct {
+	Owner   string    `json:"owner"`
+	Expires time.Time `json:"expires"`
+}
+
+// BoltStore keeps snapshots in a BoltDB file, one key per run.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBolt opens the BoltDB file at path, creating it and its directory as
+// needed. Only one process can have the file open.
+func OpenBolt(path string) (*BoltStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open state store %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(boltBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(boltClaims)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+func (s *BoltStore) Save(ctx context.Context, snap *Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).Put([]byte(snap.RunID), data)
+	})
+}
+
+func (s *BoltStore) Load(ctx context.Context, runID string) (*Snapshot, error) {
+	var snap *Snapshot
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(boltBucket).Get([]byte(runID))
+		if data == nil {
+			return nil
+		}
+		var err error
+		snap, err = decode(runID, data)
+		return err
+	})
+	return snap, err
+}
+
+func (s *BoltStore) Delete(ctx context.Context, runID string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(boltBucket).Delete([]byte(runID)); err != nil {
+			return err
+		}
+		return tx.Bucket(boltClaims).Delete([]byte(runID))
+	})
+}
+
+// Claim holds its claims in the file, which only one process has open:
+// they only matter across restarts.
+func (s *BoltStore) Claim(ctx context.Context, runID, owner string, ttl time.Duration) (bool, error) {
+	claimed := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(boltClaims)
+		var c boltClaim
+		if data := b.Get([]byte(runID)); data != nil {
+			if err := json.Unmarshal(data, &c); err != nil {
+				return err
+			}
+			if c.Owner != owner && time.Now().Before(c.Expires) {
+				return nil
+			}
+		}
+		data, err := json.Marshal(boltClaim{Owner: owner, Expires: time.Now().Add(ttl)})
+		if err != nil {
+			return err
+		}
+		claimed = true
+		return b.Put([]byte(runID), data)
+	})
+	return claimed && err == nil, err
+}
+
+func (s *BoltStore) List(ctx context.Context) ([]*Snapshot, error) {
+	var out []*Snapshot
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltBucket).ForEach(func(k, v []byte) error {
+			snap, err := decode(string(k), v)
+			if err != nil {
+				return err
+			}
+			out = append(out, snap)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Keys are in run ID order
+	sort.SliceStable(out, func(i, j int) bool { return out[i].UpdatedAt.Before(out[j].UpdatedAt) })
+	return out, nil
+}
+
+func (s *BoltStore) Close() error { return s.db.Close() }
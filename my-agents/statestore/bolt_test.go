@@ -0,0 +1,24 @@
+package statestore
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func openBolt(t *testing.T) *BoltStore {
+	t.Helper()
+	s, err := OpenBolt(filepath.Join(t.TempDir(), "state", "state.bolt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestBoltStore(t *testing.T) {
+	testStore(t, openBolt(t))
+}
+
+func TestBoltClaim(t *testing.T) {
+	testClaim(t, openBolt(t))
+}
@@ -0,0 +1,163 @@
+package statestore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"my-agents/resp"
+)
+
+// RedisStore keeps each snapshot under "<prefix><run ID>", with a sorted
+// set "<prefix>runs" of run IDs by update time for listing them, and each
+// claim under "<prefix>claim:<run ID>", expiring with it.
+type RedisStore struct {
+	u      *url.URL
+	prefix string
+
+	mu   sync.Mutex
+	conn *resp.Conn // dialed on first use
+}
+
+// NewRedisStore creates a store on the server at u. It connects on first
+// use.
+func NewRedisStore(u *url.URL, prefix string) (*RedisStore, error) {
+	if err := resp.CheckURL(u); err != nil {
+		return nil, fmt.Errorf("state store: %w", err)
+	}
+	return &RedisStore{u: u, prefix: prefix}, nil
+}
+
+// do runs commands in order on the store's connection and returns the last
+// reply. A connection that dropped since the last use is redialed once.
+func (s *RedisStore) do(ctx context.Context, cmds ...[]string) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for attempt := 0; ; attempt++ {
+		var err error
+		if s.conn == nil {
+			if s.conn, err = resp.Dial(ctx, s.u); err != nil {
+				return nil, err
+			}
+		}
+		var reply any
+		for _, cmd := range cmds {
+			if reply, err = s.conn.Do(cmd...); err != nil {
+				break
+			}
+		}
+		if _, ok := err.(resp.Error); ok || err == nil {
+			return reply, err
+		}
+		s.conn.Close()
+		s.conn = nil
+		if attempt > 0 {
+			return nil, err
+		}
+	}
+}
+
+func (s *RedisStore) index() string { return s.prefix + "runs" }
+
+func (s *RedisStore) Save(ctx context.Context, snap *Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	_, err = s.do(ctx,
+		[]string{"MULTI"},
+		[]string{"SET", s.prefix + snap.RunID, string(data)},
+		[]string{"ZADD", s.index(), strconv.FormatInt(snap.UpdatedAt.UnixNano(), 10), snap.RunID},
+		[]string{"EXEC"},
+	)
+	return err
+}
+
+func (s *RedisStore) Load(ctx context.Context, runID string) (*Snapshot, error) {
+	reply, err := s.do(ctx, []string{"GET", s.prefix + runID})
+	if err != nil || reply == nil {
+		return nil, err
+	}
+	data, _ := reply.(string)
+	return decode(runID, []byte(data))
+}
+
+func (s *RedisStore) Delete(ctx context.Context, runID string) error {
+	_, err := s.do(ctx,
+		[]string{"MULTI"},
+		[]string{"DEL", s.prefix + runID, s.claimKey(runID)},
+		[]string{"ZREM", s.index(), runID},
+		[]string{"EXEC"},
+	)
+	return err
+}
+
+func (s *RedisStore) claimKey(runID string) string { return s.prefix + "claim:" + runID }
+
+// claimScript takes the claim KEYS[1] for ARGV[1], for ARGV[2] ms, when it
+// is free or the owner's already.
+const claimScript = `local o = redis.call('GET', KEYS[1])
+if o == false or o == ARGV[1] then
+	redis.call('SET', KEYS[1], ARGV[1], 'PX', ARGV[2])
+	return 1
+end
+return 0`
+
+func (s *RedisStore) Claim(ctx context.Context, runID, owner string, ttl time.Duration) (bool, error) {
+	reply, err := s.do(ctx, []string{"EVAL", claimScript, "1", s.claimKey(runID), owner, strconv.FormatInt(ttl.Milliseconds(), 10)})
+	if err != nil {
+		return false, err
+	}
+	n, _ := reply.(int64)
+	return n == 1, nil
+}
+
+func (s *RedisStore) List(ctx context.Context) ([]*Snapshot, error) {
+	reply, err := s.do(ctx, []string{"ZRANGE", s.index(), "0", "-1"})
+	if err != nil {
+		return nil, err
+	}
+	ids, _ := reply.([]any)
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := []string{"MGET"}
+	for _, id := range ids {
+		runID, _ := id.(string)
+		args = append(args, s.prefix+runID)
+	}
+	if reply, err = s.do(ctx, args); err != nil {
+		return nil, err
+	}
+	values, _ := reply.([]any)
+	var out []*Snapshot
+	for i, v := range values {
+		data, ok := v.(string)
+		if !ok {
+			// Deleted between the two commands
+			continue
+		}
+		runID, _ := ids[i].(string)
+		snap, err := decode(runID, []byte(data))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, snap)
+	}
+	return out, nil
+}
+
+func (s *RedisStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
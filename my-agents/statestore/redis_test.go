@@ -0,0 +1,209 @@
+package statestore
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// redisServer is an in-memory Redis answering the commands the store sends.
+// It runs MULTI blocks as it reads them.
+type redisServer struct {
+	url *url.URL
+
+	mu     sync.Mutex
+	values map[string]string
+	scores map[string]map[string]float64 // sorted sets
+	expiry map[string]time.Time
+	conns  []net.Conn
+}
+
+func newRedisServer(t *testing.T) *redisServer {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	s := &redisServer{
+		url:    &url.URL{Scheme: "redis", Host: l.Addr().String()},
+		values: make(map[string]string),
+		scores: make(map[string]map[string]float64),
+		expiry: make(map[string]time.Time),
+	}
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns = append(s.conns, c)
+			s.mu.Unlock()
+			go s.serve(c)
+		}
+	}()
+	return s
+}
+
+// drop closes the connections open so far.
+func (s *redisServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.conns {
+		c.Close()
+	}
+	s.conns = nil
+}
+
+func (s *redisServer) serve(c net.Conn) {
+	defer c.Close()
+	r := bufio.NewReader(c)
+	for {
+		var n int
+		if _, err := fmt.Fscanf(r, "*%d\r\n", &n); err != nil {
+			return
+		}
+		args := make([]string, n)
+		for i := range args {
+			var size int
+			if _, err := fmt.Fscanf(r, "$%d\r\n", &size); err != nil {
+				return
+			}
+			buf := make([]byte, size+2)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				return
+			}
+			args[i] = string(buf[:size])
+		}
+		if _, err := io.WriteString(c, s.run(args)); err != nil {
+			return
+		}
+	}
+}
+
+func bulk(s string) string { return "$" + strconv.Itoa(len(s)) + "\r\n" + s + "\r\n" }
+
+func (s *redisServer) get(key string) (string, bool) {
+	if at, ok := s.expiry[key]; ok && time.Now().After(at) {
+		delete(s.values, key)
+		delete(s.expiry, key)
+	}
+	v, ok := s.values[key]
+	return v, ok
+}
+
+func (s *redisServer) run(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch args[0] {
+	case "MULTI", "EXEC":
+		return "+OK\r\n"
+	case "SET":
+		s.values[args[1]] = args[2]
+		delete(s.expiry, args[1])
+		return "+OK\r\n"
+	case "GET":
+		if v, ok := s.get(args[1]); ok {
+			return bulk(v)
+		}
+		return "$-1\r\n"
+	case "MGET":
+		out := "*" + strconv.Itoa(len(args)-1) + "\r\n"
+		for _, key := range args[1:] {
+			if v, ok := s.get(key); ok {
+				out += bulk(v)
+			} else {
+				out += "$-1\r\n"
+			}
+		}
+		return out
+	case "DEL":
+		for _, key := range args[1:] {
+			delete(s.values, key)
+		}
+		return ":1\r\n"
+	case "ZADD":
+		if s.scores[args[1]] == nil {
+			s.scores[args[1]] = make(map[string]float64)
+		}
+		score, _ := strconv.ParseFloat(args[2], 64)
+		s.scores[args[1]][args[3]] = score
+		return ":1\r\n"
+	case "ZREM":
+		delete(s.scores[args[1]], args[2])
+		return ":1\r\n"
+	case "ZRANGE":
+		set := s.scores[args[1]]
+		members := make([]string, 0, len(set))
+		for m := range set {
+			members = append(members, m)
+		}
+		sort.Slice(members, func(i, j int) bool { return set[members[i]] < set[members[j]] })
+		out := "*" + strconv.Itoa(len(members)) + "\r\n"
+		for _, m := range members {
+			out += bulk(m)
+		}
+		return out
+	case "EVAL":
+		// The claim script: KEYS[1] for ARGV[1], for ARGV[2] ms
+		key, owner := args[3], args[4]
+		ms, _ := strconv.Atoi(args[5])
+		if o, ok := s.get(key); ok && o != owner {
+			return ":0\r\n"
+		}
+		s.values[key] = owner
+		s.expiry[key] = time.Now().Add(time.Duration(ms) * time.Millisecond)
+		return ":1\r\n"
+	}
+	return "-ERR unknown command '" + strings.ToLower(args[0]) + "'\r\n"
+}
+
+func openRedis(t *testing.T) (*RedisStore, *redisServer) {
+	t.Helper()
+	srv := newRedisServer(t)
+	s, err := NewRedisStore(srv.url, DefaultRedisPrefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s, srv
+}
+
+func TestRedisStore(t *testing.T) {
+	s, srv := openRedis(t)
+	testStore(t, s)
+	if _, ok := srv.values[DefaultRedisPrefix+"run-1"]; !ok {
+		t.Errorf("keys = %v", srv.values)
+	}
+}
+
+func TestRedisClaim(t *testing.T) {
+	s, _ := openRedis(t)
+	testClaim(t, s)
+}
+
+func TestRedisStoreRedials(t *testing.T) {
+	s, srv := openRedis(t)
+	ctx := context.Background()
+	if err := s.Save(ctx, snapshot("run-1", "editor", time.Now())); err != nil {
+		t.Fatal(err)
+	}
+	srv.drop()
+	if snap, err := s.Load(ctx, "run-1"); err != nil || snap == nil {
+		t.Errorf("load after the connection dropped = %+v, %v", snap, err)
+	}
+	// Error replies leave the connection be
+	if _, err := s.do(ctx, []string{"FLUSHALL"}); err == nil {
+		t.Error("error reply not returned")
+	}
+}
@@ -0,0 +1,115 @@
+package statestore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"my-agents/storage"
+)
+
+// SQLiteStore keeps snapshots in a table of the embedded database.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+// NewSQLiteStore creates a store in db, creating its table if needed.
+func NewSQLiteStore(db *sql.DB) (*SQLiteStore, error) {
+	err := storage.Migrate(db,
+		`CREATE TABLE IF NOT EXISTS run_state (
+			run_id     TEXT PRIMARY KEY,
+			updated_at INTEGER NOT NULL,
+			data       TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS run_claims (
+			run_id     TEXT PRIMARY KEY,
+			owner      TEXT NOT NULL,
+			expires_at INTEGER NOT NULL
+		)`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &SQLiteStore{db: db}, nil
+}
+
+func (s *SQLiteStore) Save(ctx context.Context, snap *Snapshot) error {
+	data, err := json.Marshal(snap)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.ExecContext(ctx,
+		`INSERT INTO run_state (run_id, updated_at, data) VALUES (?, ?, ?)
+		 ON CONFLICT (run_id) DO UPDATE SET updated_at = excluded.updated_at, data = excluded.data`,
+		snap.RunID, snap.UpdatedAt.UnixNano(), string(data))
+	return err
+}
+
+func (s *SQLiteStore) Load(ctx context.Context, runID string) (*Snapshot, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, `SELECT data FROM run_state WHERE run_id = ?`, runID).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decode(runID, []byte(data))
+}
+
+func (s *SQLiteStore) Delete(ctx context.Context, runID string) error {
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM run_state WHERE run_id = ?`, runID); err != nil {
+		return err
+	}
+	_, err := s.db.ExecContext(ctx, `DELETE FROM run_claims WHERE run_id = ?`, runID)
+	return err
+}
+
+func (s *SQLiteStore) Claim(ctx context.Context, runID, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	res, err := s.db.ExecContext(ctx,
+		`INSERT INTO run_claims (run_id, owner, expires_at) VALUES (?, ?, ?)
+		 ON CONFLICT (run_id) DO UPDATE SET owner = excluded.owner, expires_at = excluded.expires_at
+		 WHERE run_claims.owner = excluded.owner OR run_claims.expires_at < ?`,
+		runID, owner, now.Add(ttl).UnixNano(), now.UnixNano())
+	if err != nil {
+		return false, err
+	}
+	n, err := res.RowsAffected()
+	return n == 1, err
+}
+
+func (s *SQLiteStore) List(ctx context.Context) ([]*Snapshot, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT run_id, data FROM run_state ORDER BY updated_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var out []*Snapshot
+	for rows.Next() {
+		var runID, data string
+		if err := rows.Scan(&runID, &data); err != nil {
+			return nil, err
+		}
+		snap, err := decode(runID, []byte(data))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, snap)
+	}
+	return out, rows.Err()
+}
+
+// Close leaves the shared database open.
+func (s *SQLiteStore) Close() error { return nil }
+
+func decode(runID string, data []byte) (*Snapshot, error) {
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("failed to decode state of run %s: %w", runID, err)
+	}
+	return &snap, nil
+}
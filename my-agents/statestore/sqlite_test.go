@@ -0,0 +1,29 @@
+package statestore
+
+import (
+	"path/filepath"
+	"testing"
+
+	"my-agents/storage"
+)
+
+func openSQLite(t *testing.T) *SQLiteStore {
+	t.Helper()
+	db, err := storage.Open(filepath.Join(t.TempDir(), "agentflow.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewSQLiteStore(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestSQLiteStore(t *testing.T) {
+	testStore(t, openSQLite(t))
+}
+
+func TestSQLiteClaim(t *testing.T) {
+	testClaim(t, openSQLite(t))
+}
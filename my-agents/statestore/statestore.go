@@ -0,0 +1,333 @@
+// Package statestore persists the state of runs in flight, so a process that
+// crashes mid-pipeline loses no finished agent's work: after each agent
+// completes, the state it passes on is saved with the agent it is routed
+// to, and a restarted process re-emits every saved run at that agent. A
+// run's state is dropped once the run ends.
+//
+// The store backends are the embedded SQLite database, a BoltDB file and
+// Redis, which processes on several hosts can share.
+package statestore
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/clarify"
+	"my-agents/history"
+	"my-agents/storage"
+)
+
+// Backends.
+const (
+	BackendSQLite = storage.BackendSQLite
+	BackendBolt   = "bolt"
+	BackendRedis  = "redis"
+)
+
+// Defaults.
+const (
+	DefaultBoltPath    = ".agentflow/state.bolt"
+	DefaultRedisPrefix = "agentflow:state:"
+	// ClaimTTL is how long a process's claim on a run keeps the others
+	// from restoring or resuming it. The process running the run renews
+	// the claim while it does, so the runs of a process that died are
+	// taken over within about this long.
+	ClaimTTL = time.Minute
+)
+
+// Config is the [state_store] section of agentflow.toml:
+//
+//	[state_store]
+//	backend = "bolt"   # sqlite, bolt or redis; empty doesn't persist state
+//	path = ".agentflow/state.bolt"
+//	restore_on_start = false
+//
+// Path is the SQLite database (default the [storage] file) or the BoltDB
+// file (default DefaultBoltPath). The Redis backend connects to url,
+// redis://[user:pass@]host:port[/db] (rediss:// for TLS).
+type Config struct {
+	Backend string `toml:"backend"`
+	Path    string `toml:"path"`
+	URL     string `toml:"url"`
+	// Prefix starts the Redis keys (default DefaultRedisPrefix).
+	Prefix string `toml:"prefix"`
+	// RestoreOnStart re-emits the saved runs when the process starts, and
+	// later those a process that stopped left. With an event bus, each
+	// process restores the runs waiting on its agents. A process claims
+	// each run it restores, and each run it saves, so processes sharing
+	// the store restore a run once, and none restores a run another is
+	// still running.
+	RestoreOnStart bool `toml:"restore_on_start"`
+}
+
+// Snapshot is the saved state of a run between two agents.
+type Snapshot struct {
+	RunID     string `json:"run_id"`
+	SessionID string `json:"session_id,omitempty"`
+	// Agent completed last; Route is the agent to run next.
+	Agent     string            `json:"agent"`
+	Route     string            `json:"route"`
+	State     map[string]any    `json:"state"`
+	Metadata  map[string]string `json:"metadata,omitempty"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// Store persists snapshots by run ID.
+type Store interface {
+	// Save writes s, replacing the run's previous snapshot.
+	Save(ctx context.Context, s *Snapshot) error
+	// Load returns the run's snapshot, or nil if there is none.
+	Load(ctx context.Context, runID string) (*Snapshot, error)
+	// Delete removes the run's snapshot, if any.
+	Delete(ctx context.Context, runID string) error
+	// List returns every snapshot, least recently updated first.
+	List(ctx context.Context) ([]*Snapshot, error)
+	// Claim takes the run for owner for ttl, unless another owner's claim
+	// on it is live, and reports whether it did. Delete drops the claim.
+	Claim(ctx context.Context, runID, owner string, ttl time.Duration) (bool, error)
+	Close() error
+}
+
+// Open creates the configured store.
+func Open(cfg Config) (Store, error) {
+	switch cfg.Backend {
+	case BackendSQLite:
+		db, err := storage.Open(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		return NewSQLiteStore(db)
+	case BackendBolt:
+		path := cfg.Path
+		if path == "" {
+			path = DefaultBoltPath
+		}
+		return OpenBolt(path)
+	case BackendRedis:
+		u, err := url.Parse(cfg.URL)
+		if err != nil {
+			return nil, fmt.Errorf("state store: bad url: %w", err)
+		}
+		prefix := cfg.Prefix
+		if prefix == "" {
+			prefix = DefaultRedisPrefix
+		}
+		return NewRedisStore(u, prefix)
+	default:
+		return nil, fmt.Errorf("unknown state store backend %q", cfg.Backend)
+	}
+}
+
+// Persister saves runs' state after each agent through the runner's
+// callbacks.
+type Persister struct {
+	store Store
+	owner string // names the process in its claims
+
+	mu   sync.Mutex
+	held map[string]bool // the runs claimed, until they leave the process
+}
+
+// NewPersister creates a persister saving to store.
+func NewPersister(store Store) *Persister {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return &Persister{store: store, owner: hex.EncodeToString(b), held: make(map[string]bool)}
+}
+
+// Claim takes the run for the process, unless another process holds it,
+// and reports whether it did. The claim is renewed by Run until the run
+// ends or leaves the process.
+func (p *Persister) Claim(ctx context.Context, runID string) (bool, error) {
+	ok, err := p.store.Claim(ctx, runID, p.owner, ClaimTTL)
+	if ok && err == nil {
+		p.mu.Lock()
+		p.held[runID] = true
+		p.mu.Unlock()
+	}
+	return ok, err
+}
+
+// release stops renewing the run's claim, leaving it to expire.
+func (p *Persister) release(runID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.held, runID)
+}
+
+func (p *Persister) holds(runID string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.held[runID]
+}
+
+// Run renews the process's claims until ctx is done and, with restore,
+// restores the runs other processes left unclaimed by stopping, as
+// Restore does.
+func (p *Persister) Run(ctx context.Context, runner core.Runner, local func(agent string) bool, restore bool) {
+	ticker := time.NewTicker(ClaimTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		p.mu.Lock()
+		held := make([]string, 0, len(p.held))
+		for runID := range p.held {
+			held = append(held, runID)
+		}
+		p.mu.Unlock()
+		for _, runID := range held {
+			if _, err := p.store.Claim(ctx, runID, p.owner, ClaimTTL); err != nil {
+				core.Logger().Error().Str("run_id", runID).Err(err).Msg("Failed to renew run claim")
+			}
+		}
+		if !restore {
+			continue
+		}
+		if ids, err := p.Restore(ctx, runner, local); err != nil {
+			core.Logger().Error().Err(err).Msg("Failed to restore saved runs")
+		} else if len(ids) > 0 {
+			core.Logger().Info().Int("runs", len(ids)).Msg("Restored runs a stopped process left")
+		}
+	}
+}
+
+// Store returns the store the persister saves to.
+func (p *Persister) Store() Store {
+	return p.store
+}
+
+// Register installs the persister's callbacks on the runner. The history
+// recorder must be registered first: it gives each run the ID its state is
+// saved under.
+func (p *Persister) Register(runner core.Runner) error {
+	if err := runner.RegisterCallback(core.HookBeforeEventHandling, "state-store-claim", p.before); err != nil {
+		return err
+	}
+	return runner.RegisterCallback(core.HookAfterEventHandling, "state-store", p.after)
+}
+
+// before claims the run an event is for, so other processes don't resume
+// it while it runs here. Claims only keep runs from being taken over: a
+// run another process holds still runs.
+func (p *Persister) before(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+	if args.Event == nil {
+		return args.State, nil
+	}
+	if runID, _ := args.Event.GetMetadataValue(history.RunIDKey); runID != "" {
+		if _, err := p.Claim(ctx, runID); err != nil {
+			core.Logger().Error().Str("run_id", runID).Err(err).Msg("Failed to claim run")
+		}
+	}
+	return args.State, nil
+}
+
+func (p *Persister) after(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+	if args.Event == nil {
+		return args.State, nil
+	}
+	runID, _ := args.Event.GetMetadataValue(history.RunIDKey)
+	if runID == "" {
+		return args.State, nil
+	}
+	var route string
+	if args.Error == nil && args.State != nil {
+		route, _ = args.State.GetMeta(core.RouteMetadataKey)
+		// A forwarded event is another process's to save; a paused run is
+		// resumed by its caller's answer
+		if to, _ := args.State.GetMeta(history.ForwardedKey); to != "" {
+			route = ""
+		} else if _, pending := clarify.Pending(args.State); pending {
+			route = ""
+		}
+	}
+	if route == "" {
+		// The run ended, failed or left this process: nothing to restore
+		if err := p.store.Delete(ctx, runID); err != nil {
+			core.Logger().Error().Str("run_id", runID).Err(err).Msg("Failed to delete saved run state")
+		}
+		p.release(runID)
+		return args.State, nil
+	}
+
+	snapshot := &Snapshot{
+		RunID:     runID,
+		SessionID: args.Event.GetSessionID(),
+		Agent:     args.AgentID,
+		Route:     route,
+		State:     make(map[string]any),
+		Metadata:  make(map[string]string),
+		UpdatedAt: time.Now(),
+	}
+	for _, k := range args.State.Keys() {
+		if v, ok := args.State.Get(k); ok {
+			snapshot.State[k] = v
+		}
+	}
+	// The runner routes the next event with the current one's metadata
+	for k, v := range args.Event.GetMetadata() {
+		snapshot.Metadata[k] = v
+	}
+	// The run is this process's while it saves it
+	if _, err := p.Claim(ctx, runID); err != nil {
+		core.Logger().Error().Str("run_id", runID).Err(err).Msg("Failed to claim run")
+	}
+	if err := p.store.Save(ctx, snapshot); err != nil {
+		core.Logger().Error().Str("run_id", runID).Err(err).Msg("Failed to save run state")
+	}
+	return args.State, nil
+}
+
+// Event rebuilds the event that continues s's run at its next agent.
+func Event(s *Snapshot) core.Event {
+	metadata := make(map[string]string, len(s.Metadata)+3)
+	for k, v := range s.Metadata {
+		metadata[k] = v
+	}
+	metadata[history.RunIDKey] = s.RunID
+	metadata[core.SessionIDKey] = s.SessionID
+	metadata[core.RouteMetadataKey] = s.Route
+	return core.NewEvent(s.Route, core.EventData(s.State), metadata)
+}
+
+// Restore re-emits the saved runs at their next agent and returns the IDs of
+// the runs it emitted. local, when not nil, reports whether this process
+// runs an agent; runs waiting on other agents are left to the processes
+// that run them, and the runs a process is running, this one included, to
+// it. A run that fails to emit keeps its snapshot, for another attempt
+// once its claim expires.
+func (p *Persister) Restore(ctx context.Context, runner core.Runner, local func(agent string) bool) ([]string, error) {
+	snapshots, err := p.store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var restored []string
+	for _, s := range snapshots {
+		if (local != nil && !local(s.Route)) || p.holds(s.RunID) {
+			continue
+		}
+		if ok, err := p.Claim(ctx, s.RunID); err != nil || !ok {
+			if err != nil {
+				core.Logger().Error().Str("run_id", s.RunID).Err(err).Msg("Failed to claim run")
+			}
+			continue
+		}
+		if err := runner.Emit(Event(s)); err != nil {
+			core.Logger().Error().Str("run_id", s.RunID).Err(err).Msg("Failed to restore run")
+			p.release(s.RunID)
+			continue
+		}
+		restored = append(restored, s.RunID)
+	}
+	return restored, nil
+}
@@ -0,0 +1,345 @@
+package statestore
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/clarify"
+	"my-agents/history"
+)
+
+func snapshot(runID, route string, at time.Time) *Snapshot {
+	return &Snapshot{
+		RunID:     runID,
+		SessionID: "s1",
+		Agent:     "writer",
+		Route:     route,
+		State:     map[string]any{"draft": "Go is fast."},
+		Metadata:  map[string]string{"tone": "casual"},
+		UpdatedAt: at,
+	}
+}
+
+// testStore runs the checks every backend passes.
+func testStore(t *testing.T, s Store) {
+	t.Helper()
+	ctx := context.Background()
+	at := time.Now().Add(-time.Minute)
+	if snap, err := s.Load(ctx, "run-1"); snap != nil || err != nil {
+		t.Errorf("load of a run never saved = %+v, %v", snap, err)
+	}
+	// Listed by update time, not ID
+	for _, snap := range []*Snapshot{snapshot("run-2", "editor", at.Add(time.Second)), snapshot("run-1", "editor", at), snapshot("run-3", "formatter", at.Add(2*time.Second))} {
+		if err := s.Save(ctx, snap); err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := s.Load(ctx, "run-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := snapshot("run-1", "editor", at); got.Route != want.Route || !reflect.DeepEqual(got.State, want.State) ||
+		!reflect.DeepEqual(got.Metadata, want.Metadata) || !got.UpdatedAt.Equal(at) {
+		t.Errorf("loaded %+v", got)
+	}
+
+	// A later save replaces the run's snapshot and moves it to the end
+	if err := s.Save(ctx, snapshot("run-1", "formatter", at.Add(3*time.Second))); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "run-3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(ctx, "run-9"); err != nil {
+		t.Errorf("delete of a run never saved: %v", err)
+	}
+	list, err := s.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 2 || list[0].RunID != "run-2" || list[1].RunID != "run-1" || list[1].Route != "formatter" {
+		t.Errorf("list = %+v", list)
+	}
+	if snap, _ := s.Load(ctx, "run-3"); snap != nil {
+		t.Errorf("deleted run loaded: %+v", snap)
+	}
+}
+
+// testClaim runs the claim checks every backend passes.
+func testClaim(t *testing.T, s Store) {
+	t.Helper()
+	ctx := context.Background()
+	claim := func(runID, owner string, ttl time.Duration) bool {
+		t.Helper()
+		ok, err := s.Claim(ctx, runID, owner, ttl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+	if !claim("run-1", "a", time.Minute) {
+		t.Error("free run not claimed")
+	}
+	if claim("run-1", "b", time.Minute) {
+		t.Error("run claimed from its live owner")
+	}
+	if !claim("run-1", "a", time.Minute) {
+		t.Error("owner couldn't renew its claim")
+	}
+
+	// Claims expire
+	if !claim("run-2", "a", 10*time.Millisecond) {
+		t.Fatal("free run not claimed")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if !claim("run-2", "b", time.Minute) {
+		t.Error("expired claim kept")
+	}
+
+	// Deleting a run drops its claim
+	s.Save(ctx, snapshot("run-1", "editor", time.Now()))
+	if err := s.Delete(ctx, "run-1"); err != nil {
+		t.Fatal(err)
+	}
+	if !claim("run-1", "b", time.Minute) {
+		t.Error("claim kept after the run was deleted")
+	}
+}
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	for _, cfg := range []Config{
+		{Backend: BackendSQLite, Path: filepath.Join(dir, "agentflow.db")},
+		{Backend: BackendBolt, Path: filepath.Join(dir, "state", "state.bolt")},
+		{Backend: BackendRedis, URL: "redis://localhost:6379/1"},
+	} {
+		s, err := Open(cfg)
+		if err != nil {
+			t.Errorf("%s: %v", cfg.Backend, err)
+			continue
+		}
+		s.Close()
+	}
+	if s, _ := Open(Config{Backend: BackendRedis, URL: "redis://localhost"}); s.(*RedisStore).prefix != DefaultRedisPrefix {
+		t.Errorf("redis prefix = %q", s.(*RedisStore).prefix)
+	}
+	for _, cfg := range []Config{
+		{Backend: "etcd"},
+		{Backend: BackendRedis, URL: "http://localhost"},
+		{Backend: BackendRedis, URL: "redis://[bad"},
+	} {
+		if _, err := Open(cfg); err == nil {
+			t.Errorf("opened %+v", cfg)
+		}
+	}
+}
+
+// runner captures the persister's callbacks and the events emitted.
+type runner struct {
+	core.Runner
+	callbacks map[core.HookPoint]core.CallbackFunc
+	emitted   []core.Event
+	err       error
+}
+
+func (r *runner) RegisterCallback(hook core.HookPoint, name string, cb core.CallbackFunc) error {
+	if r.callbacks == nil {
+		r.callbacks = make(map[core.HookPoint]core.CallbackFunc)
+	}
+	r.callbacks[hook] = cb
+	return nil
+}
+
+func (r *runner) Emit(event core.Event) error {
+	if r.err != nil {
+		return r.err
+	}
+	r.emitted = append(r.emitted, event)
+	return nil
+}
+
+func persister(t *testing.T) (*Persister, *runner) {
+	t.Helper()
+	s, err := OpenBolt(filepath.Join(t.TempDir(), "state.bolt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	p := NewPersister(s)
+	r := &runner{}
+	if err := p.Register(r); err != nil {
+		t.Fatal(err)
+	}
+	return p, r
+}
+
+// complete reports agent finishing on run runID with state.
+func complete(r *runner, runID, agent string, state core.State, err error) {
+	event := core.NewEvent(agent, core.EventData{"draft": "hi"}, map[string]string{history.RunIDKey: runID, core.SessionIDKey: "s1", "tone": "casual"})
+	r.callbacks[core.HookAfterEventHandling](context.Background(), core.CallbackArgs{AgentID: agent, Event: event, State: state, Error: err})
+}
+
+func routed(to string) core.State {
+	state := core.NewState()
+	state.Set("draft", "Go is fast.")
+	state.SetMeta(core.RouteMetadataKey, to)
+	return state
+}
+
+func TestPersister(t *testing.T) {
+	p, r := persister(t)
+	ctx := context.Background()
+	complete(r, "run-1", "writer", routed("editor"), nil)
+	snap, err := p.Store().Load(ctx, "run-1")
+	if err != nil || snap == nil {
+		t.Fatalf("snapshot = %+v, %v", snap, err)
+	}
+	if snap.Agent != "writer" || snap.Route != "editor" || snap.SessionID != "s1" || snap.State["draft"] != "Go is fast." || snap.Metadata["tone"] != "casual" {
+		t.Errorf("snapshot = %+v", snap)
+	}
+
+	// Each way a run ends here drops its state
+	forwarded := routed("editor")
+	forwarded.SetMeta(history.ForwardedKey, "node-2")
+	asking := routed("editor")
+	asking.Set(clarify.QuestionKey, "Which Go version?")
+	for name, end := range map[string]func(runID string){
+		"completed": func(runID string) { complete(r, runID, "formatter", core.NewState(), nil) },
+		"failed":    func(runID string) { complete(r, runID, "editor", nil, errors.New("model down")) },
+		"forwarded": func(runID string) { complete(r, runID, "writer", forwarded, nil) },
+		"paused":    func(runID string) { complete(r, runID, "writer", asking, nil) },
+	} {
+		complete(r, "run-2", "writer", routed("editor"), nil)
+		end("run-2")
+		if snap, _ := p.Store().Load(ctx, "run-2"); snap != nil {
+			t.Errorf("%s run kept its state: %+v", name, snap)
+		}
+	}
+
+	// Events outside runs aren't saved
+	r.callbacks[core.HookAfterEventHandling](ctx, core.CallbackArgs{AgentID: "writer", Event: core.NewEvent("writer", nil, nil), State: routed("editor")})
+	if list, _ := p.Store().List(ctx); len(list) != 1 {
+		t.Errorf("saved %+v", list)
+	}
+}
+
+func TestEvent(t *testing.T) {
+	s := snapshot("run-1", "editor", time.Now())
+	s.Metadata[core.RouteMetadataKey] = "writer"
+	event := Event(s)
+	meta := event.GetMetadata()
+	if event.GetTargetAgentID() != "editor" || meta[core.RouteMetadataKey] != "editor" || meta[history.RunIDKey] != "run-1" ||
+		event.GetSessionID() != "s1" || meta["tone"] != "casual" || event.GetData()["draft"] != "Go is fast." {
+		t.Errorf("event to %s with metadata %v and data %v", event.GetTargetAgentID(), meta, event.GetData())
+	}
+}
+
+func TestRestore(t *testing.T) {
+	p, r := persister(t)
+	ctx := context.Background()
+	at := time.Now().Add(-time.Minute)
+	p.Store().Save(ctx, snapshot("run-1", "editor", at))
+	p.Store().Save(ctx, snapshot("run-2", "formatter", at.Add(time.Second)))
+	ids, err := NewPersister(p.Store()).Restore(ctx, r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []string{"run-1", "run-2"}) || len(r.emitted) != 2 || r.emitted[1].GetTargetAgentID() != "formatter" {
+		t.Errorf("restored %v, emitted %v", ids, r.emitted)
+	}
+
+	// A run that fails to emit keeps its snapshot
+	r.err = errors.New("runner stopped")
+	ids, err = NewPersister(p.Store()).Restore(ctx, r, nil)
+	if err != nil || len(ids) != 0 {
+		t.Errorf("restore with a failing runner = %v, %v", ids, err)
+	}
+	if list, _ := p.Store().List(ctx); len(list) != 2 {
+		t.Errorf("snapshots left = %+v", list)
+	}
+}
+
+func TestPersisterClaims(t *testing.T) {
+	p, r := persister(t)
+	other := NewPersister(p.Store())
+	ctx := context.Background()
+	complete(r, "run-1", "writer", routed("editor"), nil)
+	if ok, _ := other.Claim(ctx, "run-1"); ok {
+		t.Error("saved run claimed by another process")
+	}
+	if !p.holds("run-1") {
+		t.Error("saved run not held")
+	}
+	complete(r, "run-1", "editor", core.NewState(), nil)
+	if p.holds("run-1") {
+		t.Error("ended run still held")
+	}
+	if ok, _ := other.Claim(ctx, "run-1"); !ok {
+		t.Error("ended run still claimed")
+	}
+}
+
+func TestRestoreClaims(t *testing.T) {
+	p, r := persister(t)
+	ctx := context.Background()
+	at := time.Now().Add(-time.Minute)
+	p.Store().Save(ctx, snapshot("run-1", "editor", at))
+	p.Store().Save(ctx, snapshot("run-2", "formatter", at.Add(time.Second)))
+	p.Store().Save(ctx, snapshot("run-3", "editor", at.Add(2*time.Second)))
+	other := NewPersister(p.Store())
+	if ok, _ := other.Claim(ctx, "run-3"); !ok {
+		t.Fatal("run-3 not claimed")
+	}
+
+	// Only runs waiting on local agents and claimed by nobody else
+	local := func(agent string) bool { return agent == "editor" }
+	ids, err := p.Restore(ctx, r, local)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []string{"run-1"}) {
+		t.Errorf("restored %v", ids)
+	}
+	// Nor again the runs this process restored
+	if ids, _ := p.Restore(ctx, r, nil); !reflect.DeepEqual(ids, []string{"run-2"}) {
+		t.Errorf("restored again %v", ids)
+	}
+	if ids, _ := other.Restore(ctx, r, nil); len(ids) != 0 {
+		t.Errorf("another process restored %v", ids)
+	}
+
+	// A run that fails to emit isn't held
+	p.Store().Save(ctx, snapshot("run-4", "editor", at.Add(3*time.Second)))
+	r.err = errors.New("runner stopped")
+	if ids, _ := other.Restore(ctx, r, local); len(ids) != 0 || other.holds("run-4") {
+		t.Errorf("restored %v with a failing runner", ids)
+	}
+	if !other.holds("run-3") {
+		t.Error("failed restore released an unrelated claim")
+	}
+}
+
+func TestPersisterClaimsRunning(t *testing.T) {
+	p, r := persister(t)
+	ctx := context.Background()
+	before := r.callbacks[core.HookBeforeEventHandling]
+	before(ctx, core.CallbackArgs{AgentID: "writer", Event: core.NewEvent("writer", nil, map[string]string{history.RunIDKey: "run-1"})})
+	if !p.holds("run-1") {
+		t.Error("running run not held")
+	}
+	if ok, _ := NewPersister(p.Store()).Claim(ctx, "run-1"); ok {
+		t.Error("running run claimed by another process")
+	}
+	// Events outside runs claim nothing
+	before(ctx, core.CallbackArgs{AgentID: "writer", Event: core.NewEvent("writer", nil, nil)})
+	before(ctx, core.CallbackArgs{AgentID: "writer"})
+	if len(p.held) != 1 {
+		t.Errorf("held %v", p.held)
+	}
+}
@@ -0,0 +1,232 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+	_ "github.com/kunalkushwaha/agenticgokit/plugins/memory/memory"
+)
+
+// MemoryProvider is the [agent_memory] provider name of the SQLite memory;
+// its connection is the database file (default DefaultPath).
+const MemoryProvider = "sqlite"
+
+func init() {
+	core.RegisterMemoryProviderFactory(MemoryProvider, NewMemory)
+}
+
+// Memory is agent memory that survives restarts. Every write is recorded in
+// the database before it reaches an in-memory index that serves searches,
+// and the index is rebuilt from the database on open. Recalled values come
+// back JSON-decoded after a restart, and replayed entries take the time of
+// the replay as their creation time.
+type Memory struct {
+	core.Memory // the in-memory index
+	db          *sql.DB
+}
+
+// NewMemory opens the memory stored in the database at cfg.Connection.
+func NewMemory(cfg core.AgentMemoryConfig) (core.Memory, error) {
+	db, err := Open(cfg.Connection)
+	if err != nil {
+		return nil, err
+	}
+	err = Migrate(db,
+		`CREATE TABLE IF NOT EXISTS memory_items (
+			seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+			session_id TEXT NOT NULL,
+			content    TEXT NOT NULL,
+			tags       TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS memory_values (
+			session_id TEXT NOT NULL,
+			key        TEXT NOT NULL,
+			value      TEXT NOT NULL,
+			PRIMARY KEY (session_id, key)
+		)`,
+		`CREATE TABLE IF NOT EXISTS memory_messages (
+			seq        INTEGER PRIMARY KEY AUTOINCREMENT,
+			session_id TEXT NOT NULL,
+			role       TEXT NOT NULL,
+			content    TEXT NOT NULL
+		)`,
+		`CREATE TABLE IF NOT EXISTS memory_documents (
+			id   TEXT PRIMARY KEY,
+			data TEXT NOT NULL
+		)`,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	indexCfg := cfg
+	indexCfg.Provider, indexCfg.Connection = "memory", "memory"
+	index, err := core.NewMemory(indexCfg)
+	if err != nil {
+		return nil, err
+	}
+	m := &Memory{Memory: index, db: db}
+	if err := m.load(); err != nil {
+		return nil, fmt.Errorf("failed to load memory from %s: %w", cfg.Connection, err)
+	}
+	return m, nil
+}
+
+// SetSession scopes ctx to sessionID for this memory.
+func (m *Memory) SetSession(ctx context.Context, sessionID string) context.Context {
+	return core.WithMemory(ctx, m, sessionID)
+}
+
+func (m *Memory) Store(ctx context.Context, content string, tags ...string) error {
+	data, _ := json.Marshal(tags)
+	if _, err := m.db.ExecContext(ctx, `INSERT INTO memory_items (session_id, content, tags) VALUES (?, ?, ?)`,
+		core.GetSessionID(ctx), content, string(data)); err != nil {
+		return err
+	}
+	return m.Memory.Store(ctx, content, tags...)
+}
+
+func (m *Memory) Remember(ctx context.Context, key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("memory value %q is not serializable: %w", key, err)
+	}
+	if _, err := m.db.ExecContext(ctx,
+		`INSERT INTO memory_values (session_id, key, value) VALUES (?, ?, ?)
+		 ON CONFLICT (session_id, key) DO UPDATE SET value = excluded.value`,
+		core.GetSessionID(ctx), key, string(data)); err != nil {
+		return err
+	}
+	return m.Memory.Remember(ctx, key, value)
+}
+
+func (m *Memory) AddMessage(ctx context.Context, role, content string) error {
+	if _, err := m.db.ExecContext(ctx, `INSERT INTO memory_messages (session_id, role, content) VALUES (?, ?, ?)`,
+		core.GetSessionID(ctx), role, content); err != nil {
+		return err
+	}
+	return m.Memory.AddMessage(ctx, role, content)
+}
+
+func (m *Memory) ClearSession(ctx context.Context) error {
+	session := core.GetSessionID(ctx)
+	for _, table := range []string{"memory_items", "memory_values", "memory_messages"} {
+		if _, err := m.db.ExecContext(ctx, `DELETE FROM `+table+` WHERE session_id = ?`, session); err != nil {
+			return err
+		}
+	}
+	return m.Memory.ClearSession(ctx)
+}
+
+func (m *Memory) IngestDocument(ctx context.Context, doc core.Document) error {
+	if doc.ID == "" {
+		doc.ID = core.GenerateSessionID()
+	}
+	if doc.CreatedAt.IsZero() {
+		doc.CreatedAt = time.Now()
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	if _, err := m.db.ExecContext(ctx,
+		`INSERT INTO memory_documents (id, data) VALUES (?, ?)
+		 ON CONFLICT (id) DO UPDATE SET data = excluded.data`, doc.ID, string(data)); err != nil {
+		return err
+	}
+	return m.Memory.IngestDocument(ctx, doc)
+}
+
+func (m *Memory) IngestDocuments(ctx context.Context, docs []core.Document) error {
+	for _, doc := range docs {
+		if err := m.IngestDocument(ctx, doc); err != nil {
+			return fmt.Errorf("failed to ingest document %s: %w", doc.ID, err)
+		}
+	}
+	return nil
+}
+
+// DeleteDocument removes an ingested document from the database. The index
+// has no delete, so it keeps serving the document until the next open.
+func (m *Memory) DeleteDocument(ctx context.Context, id string) error {
+	_, err := m.db.ExecContext(ctx, `DELETE FROM memory_documents WHERE id = ?`, id)
+	return err
+}
+
+// Close leaves the shared database open for the other stores.
+func (m *Memory) Close() error {
+	return m.Memory.Close()
+}
+
+// load replays the database into the index.
+func (m *Memory) load() error {
+	ctx := context.Background()
+	session := func(id string) context.Context { return m.Memory.SetSession(ctx, id) }
+
+	err := m.each(`SELECT session_id, content, tags FROM memory_items ORDER BY seq`, func(scan func(...any) error) error {
+		var id, content, tags string
+		if err := scan(&id, &content, &tags); err != nil {
+			return err
+		}
+		var list []string
+		_ = json.Unmarshal([]byte(tags), &list)
+		return m.Memory.Store(session(id), content, list...)
+	})
+	if err != nil {
+		return err
+	}
+	err = m.each(`SELECT session_id, key, value FROM memory_values`, func(scan func(...any) error) error {
+		var id, key, value string
+		if err := scan(&id, &key, &value); err != nil {
+			return err
+		}
+		var v any
+		if err := json.Unmarshal([]byte(value), &v); err != nil {
+			return err
+		}
+		return m.Memory.Remember(session(id), key, v)
+	})
+	if err != nil {
+		return err
+	}
+	err = m.each(`SELECT session_id, role, content FROM memory_messages ORDER BY seq`, func(scan func(...any) error) error {
+		var id, role, content string
+		if err := scan(&id, &role, &content); err != nil {
+			return err
+		}
+		return m.Memory.AddMessage(session(id), role, content)
+	})
+	if err != nil {
+		return err
+	}
+	return m.each(`SELECT data FROM memory_documents`, func(scan func(...any) error) error {
+		var data string
+		if err := scan(&data); err != nil {
+			return err
+		}
+		var doc core.Document
+		if err := json.Unmarshal([]byte(data), &doc); err != nil {
+			return err
+		}
+		return m.Memory.IngestDocument(ctx, doc)
+	})
+}
+
+// each runs query and calls fn for every row.
+func (m *Memory) each(query string, fn func(scan func(...any) error) error) error {
+	rows, err := m.db.Query(query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		if err := fn(rows.Scan); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+func openMemory(t *testing.T, path string) core.Memory {
+	t.Helper()
+	m, err := NewMemory(core.AgentMemoryConfig{Provider: MemoryProvider, Connection: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestMemorySurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agentflow.db")
+	m := openMemory(t, path)
+	ctx := m.SetSession(context.Background(), "s1")
+	if err := m.Store(ctx, "the customer prefers email", "preference"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Remember(ctx, "tier", map[string]any{"name": "gold", "level": 3}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.AddMessage(ctx, "user", "hello"); err != nil {
+		t.Fatal(err)
+	}
+	m.AddMessage(ctx, "assistant", "hi there")
+	other := m.SetSession(context.Background(), "s2")
+	m.AddMessage(other, "user", "forget me")
+	if err := m.ClearSession(other); err != nil {
+		t.Fatal(err)
+	}
+
+	m = openMemory(t, path)
+	ctx = m.SetSession(context.Background(), "s1")
+	v, err := m.Recall(ctx, "tier")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tier, ok := v.(map[string]any); !ok || tier["name"] != "gold" || tier["level"] != 3.0 {
+		t.Errorf("recalled %#v, want the JSON-decoded value", v)
+	}
+	msgs, err := m.GetHistory(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != 2 || msgs[0].Content != "hello" || msgs[1].Role != "assistant" {
+		t.Errorf("history = %+v", msgs)
+	}
+	if msgs, _ := m.GetHistory(m.SetSession(context.Background(), "s2")); len(msgs) != 0 {
+		t.Errorf("cleared session came back with %d messages", len(msgs))
+	}
+	results, err := m.Query(ctx, "email")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || results[0].Content != "the customer prefers email" {
+		t.Errorf("query = %+v", results)
+	}
+}
+
+func TestMemoryDocuments(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agentflow.db")
+	m := openMemory(t, path)
+	ctx := context.Background()
+	err := m.IngestDocuments(ctx, []core.Document{
+		{ID: "refunds", Content: "Refunds are issued within 14 days."},
+		{Content: "Shipping takes three days."},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, _ := Open(path)
+	var n int
+	db.QueryRow(`SELECT COUNT(*) FROM memory_documents`).Scan(&n)
+	if n != 2 {
+		t.Errorf("stored %d documents, want 2 including the one without an ID", n)
+	}
+	var data string
+	db.QueryRow(`SELECT data FROM memory_documents WHERE id = 'refunds'`).Scan(&data)
+	if data == "" {
+		t.Error("document not stored under its ID")
+	}
+	openMemory(t, path) // replays the documents into a new index
+}
+
+func TestMemoryUnserializable(t *testing.T) {
+	m := openMemory(t, filepath.Join(t.TempDir(), "agentflow.db"))
+	if err := m.Remember(context.Background(), "fn", func() {}); err == nil {
+		t.Error("Remember accepted a value that can't be persisted")
+	}
+}
+
+func TestMemoryDeleteDocument(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agentflow.db")
+	m := openMemory(t, path)
+	ctx := context.Background()
+	m.IngestDocument(ctx, core.Document{ID: "old", Content: "Outdated policy."})
+	if err := m.(*Memory).DeleteDocument(ctx, "old"); err != nil {
+		t.Fatal(err)
+	}
+	db, _ := Open(path)
+	var n int
+	db.QueryRow(`SELECT COUNT(*) FROM memory_documents`).Scan(&n)
+	if n != 0 {
+		t.Errorf("%d documents left after delete", n)
+	}
+}
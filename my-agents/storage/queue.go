@@ -0,0 +1,140 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Queue makes a runner's intake durable: events emitted to it are written
+// to the database before they are queued and removed when the runner starts
+// handling them, so events accepted but not yet started survive a crash.
+// Runs that had started are resumed from history instead.
+type Queue struct {
+	db     *sql.DB
+	runner core.Runner // the wrapped runner, set by Wrap
+}
+
+// NewQueue creates a queue in db, creating its table if needed.
+func NewQueue(db *sql.DB) (*Queue, error) {
+	err := Migrate(db,
+		`CREATE TABLE IF NOT EXISTS queue (
+			event_id    TEXT PRIMARY KEY,
+			target      TEXT NOT NULL,
+			data        TEXT NOT NULL,
+			metadata    TEXT NOT NULL,
+			enqueued_at INTEGER NOT NULL
+		)`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Queue{db: db}, nil
+}
+
+// Wrap returns runner with Emit persisting each event first. Wrap it
+// outside runners that hold events (e.g. a paused admin gate) so held
+// events are durable too.
+func (q *Queue) Wrap(runner core.Runner) core.Runner {
+	q.runner = &queuedRunner{Runner: runner, q: q}
+	return q.runner
+}
+
+type queuedRunner struct {
+	core.Runner
+	q *Queue
+}
+
+func (r *queuedRunner) Emit(event core.Event) error {
+	if err := r.q.push(event); err != nil {
+		return err
+	}
+	if err := r.Runner.Emit(event); err != nil {
+		r.q.remove(event.GetID())
+		return err
+	}
+	return nil
+}
+
+// Register removes events from the queue as the runner picks them up.
+func (q *Queue) Register(runner core.Runner) error {
+	return runner.RegisterCallback(core.HookBeforeEventHandling, "durable-queue", func(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+		if args.Event != nil {
+			q.remove(args.Event.GetID())
+		}
+		return args.State, nil
+	})
+}
+
+// Pending returns the events queued by a previous process and not started,
+// oldest first, with their original IDs.
+func (q *Queue) Pending() ([]core.Event, error) {
+	rows, err := q.db.Query(`SELECT event_id, target, data, metadata FROM queue ORDER BY enqueued_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var events []core.Event
+	for rows.Next() {
+		var id, target, data, metadata string
+		if err := rows.Scan(&id, &target, &data, &metadata); err != nil {
+			return nil, err
+		}
+		var payload core.EventData
+		var meta map[string]string
+		if err := json.Unmarshal([]byte(data), &payload); err != nil {
+			return nil, fmt.Errorf("failed to decode queued event %s: %w", id, err)
+		}
+		if err := json.Unmarshal([]byte(metadata), &meta); err != nil {
+			return nil, fmt.Errorf("failed to decode queued event %s: %w", id, err)
+		}
+		event := core.NewEvent(target, payload, meta)
+		event.SetID(id)
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// Recover re-emits the pending events to the wrapped runner, which must be
+// started, and returns how many were re-emitted.
+func (q *Queue) Recover() (int, error) {
+	events, err := q.Pending()
+	if err != nil {
+		return 0, err
+	}
+	for i, event := range events {
+		if err := q.runner.Emit(event); err != nil {
+			return i, fmt.Errorf("failed to re-emit queued event %s: %w", event.GetID(), err)
+		}
+	}
+	return len(events), nil
+}
+
+func (q *Queue) push(event core.Event) error {
+	data, err := json.Marshal(event.GetData())
+	if err != nil {
+		return fmt.Errorf("failed to queue event %s: %w", event.GetID(), err)
+	}
+	meta, err := json.Marshal(event.GetMetadata())
+	if err != nil {
+		return fmt.Errorf("failed to queue event %s: %w", event.GetID(), err)
+	}
+	_, err = q.db.Exec(
+		`INSERT INTO queue (event_id, target, data, metadata, enqueued_at) VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT (event_id) DO NOTHING`,
+		event.GetID(), event.GetTargetAgentID(), string(data), string(meta), time.Now().UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to queue event %s: %w", event.GetID(), err)
+	}
+	return nil
+}
+
+func (q *Queue) remove(id string) {
+	if _, err := q.db.Exec(`DELETE FROM queue WHERE event_id = ?`, id); err != nil {
+		core.Logger().Error().Str("event_id", id).Err(err).Msg("Failed to remove event from the durable queue")
+	}
+}
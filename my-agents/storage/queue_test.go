@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// runner records emitted events and the callback registered on it.
+type runner struct {
+	core.Runner
+	emitted  []core.Event
+	fail     bool
+	callback core.CallbackFunc
+}
+
+func (r *runner) Emit(event core.Event) error {
+	if r.fail {
+		return errors.New("queue full")
+	}
+	r.emitted = append(r.emitted, event)
+	return nil
+}
+
+func (r *runner) RegisterCallback(hook core.HookPoint, name string, cb core.CallbackFunc) error {
+	r.callback = cb
+	return nil
+}
+
+func newQueue(t *testing.T) *Queue {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "agentflow.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := NewQueue(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q
+}
+
+func TestQueue(t *testing.T) {
+	q := newQueue(t)
+	inner := &runner{}
+	r := q.Wrap(inner)
+	if err := q.Register(inner); err != nil {
+		t.Fatal(err)
+	}
+
+	first := core.NewEvent("writer", core.EventData{"message": "hi"}, map[string]string{"session_id": "s1"})
+	second := core.NewEvent("writer", core.EventData{"message": "bye"}, nil)
+	for _, e := range []core.Event{first, second} {
+		if err := r.Emit(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The runner picks up the first before the process dies
+	inner.callback(context.Background(), core.CallbackArgs{Event: first})
+
+	pending, err := q.Pending()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0].GetID() != second.GetID() || pending[0].GetData()["message"] != "bye" {
+		t.Fatalf("pending = %v, want only the unstarted event", pending)
+	}
+
+	inner.emitted = nil
+	n, err := q.Recover()
+	if err != nil || n != 1 {
+		t.Fatalf("Recover = %d, %v", n, err)
+	}
+	if len(inner.emitted) != 1 || inner.emitted[0].GetID() != second.GetID() {
+		t.Errorf("re-emitted %v", inner.emitted)
+	}
+	if pending, _ := q.Pending(); len(pending) != 1 {
+		t.Errorf("re-emitting queued the event %d times", len(pending))
+	}
+}
+
+func TestQueueRejected(t *testing.T) {
+	q := newQueue(t)
+	r := q.Wrap(&runner{fail: true})
+	if err := r.Emit(core.NewEvent("writer", nil, nil)); err == nil {
+		t.Fatal("Emit hid the runner's error")
+	}
+	if pending, _ := q.Pending(); len(pending) != 0 {
+		t.Errorf("rejected event left in the queue")
+	}
+}
@@ -0,0 +1,178 @@
+// Package storage opens the embedded SQLite database that run history,
+// generation checkpoints, agent memory and the event queue keep their state
+// in by default. The driver is pure Go, so the whole system builds as one
+// static binary (CGO_ENABLED=0) with one data file.
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	_ "modernc.org/sqlite"
+)
+
+// DefaultPath is the database file used when none is configured.
+const DefaultPath = ".agentflow/agentflow.db"
+
+// Backends selectable by the stores that support more than one.
+const (
+	BackendSQLite = "sqlite"
+	BackendFile   = "file"
+	BackendMemory = "memory"
+)
+
+// Config is the [storage] section of agentflow.toml:
+//
+//	[storage]
+//	path = "/var/lib/agentflow/agentflow.db"
+//	queue = "sqlite"
+//
+// Stores on the sqlite backend without a path of their own use Path.
+type Config struct {
+	Path string `toml:"path"` // database file (default .agentflow/agentflow.db)
+	// Queue is "sqlite" (default) to persist emitted events until the runner
+	// picks them up, or "memory" to keep them only in the runner's queue.
+	Queue string `toml:"queue"`
+}
+
+var (
+	mu  sync.Mutex
+	dbs = make(map[string]*sql.DB)
+)
+
+// Open returns the database at path (DefaultPath when empty), creating the
+// file and its directory as needed. Stores opening the same path share one
+// handle, which stays open for the life of the process.
+func Open(path string) (*sql.DB, error) {
+	if path == "" {
+		path = DefaultPath
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if db, ok := dbs[abs]; ok {
+		return db, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(abs), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create database directory: %w", err)
+	}
+	// WAL lets readers proceed during writes; the busy timeout makes
+	// concurrent writers wait instead of failing
+	db, err := sql.Open("sqlite", "file:"+abs+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to open database %s: %w", path, err)
+	}
+	dbs[abs] = db
+	return db, nil
+}
+
+// Migrate applies the schema statements of one store. Statements must be
+// idempotent (CREATE ... IF NOT EXISTS).
+func Migrate(db *sql.DB, stmts ...string) error {
+	for _, stmt := range stmts {
+		if _, err := db.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to migrate database schema: %w", err)
+		}
+	}
+	return nil
+}
+
+// AddColumn adds column, defined by def, to a table created before the
+// column was, and does nothing when the table has it.
+func AddColumn(db *sql.DB, table, column, def string) error {
+	var n int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?`, table, column).Scan(&n); err != nil {
+		return fmt.Errorf("failed to migrate database schema: %w", err)
+	}
+	if n > 0 {
+		return nil
+	}
+	if _, err := db.Exec(`ALTER TABLE ` + table + ` ADD COLUMN ` + column + ` ` + def); err != nil {
+		return fmt.Errorf("failed to migrate database schema: %w", err)
+	}
+	return nil
+}
+
+// Opened returns the paths of the databases this process opened, sorted.
+func Opened() []string {
+	mu.Lock()
+	defer mu.Unlock()
+	paths := make([]string, 0, len(dbs))
+	for path := range dbs {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Vacuum checkpoints the write-ahead log of the database at path into it
+// and rebuilds the file without its free pages, returning how many bytes
+// the file and its log shrank by.
+func Vacuum(ctx context.Context, path string) (int64, error) {
+	db, err := Open(path)
+	if err != nil {
+		return 0, err
+	}
+	before := fileSize(path) + fileSize(path+"-wal")
+	if _, err := db.ExecContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return 0, fmt.Errorf("failed to checkpoint %s: %w", path, err)
+	}
+	if _, err := db.ExecContext(ctx, `VACUUM`); err != nil {
+		return 0, fmt.Errorf("failed to vacuum %s: %w", path, err)
+	}
+	// VACUUM goes through the log too
+	if _, err := db.ExecContext(ctx, `PRAGMA wal_checkpoint(TRUNCATE)`); err != nil {
+		return 0, fmt.Errorf("failed to checkpoint %s: %w", path, err)
+	}
+	return before - fileSize(path) - fileSize(path+"-wal"), nil
+}
+
+// Reindex rebuilds the indexes of the database at path and refreshes the
+// statistics the query planner picks them by.
+func Reindex(ctx context.Context, path string) error {
+	db, err := Open(path)
+	if err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `REINDEX`); err != nil {
+		return fmt.Errorf("failed to reindex %s: %w", path, err)
+	}
+	if _, err := db.ExecContext(ctx, `ANALYZE`); err != nil {
+		return fmt.Errorf("failed to analyze %s: %w", path, err)
+	}
+	return nil
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// Snapshot writes a consistent copy of the database at path to dest, which
+// must not exist, while other connections keep reading and writing.
+func Snapshot(ctx context.Context, path, dest string) error {
+	db, err := Open(path)
+	if err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `VACUUM INTO ?`, dest); err != nil {
+		return fmt.Errorf("failed to snapshot %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data", "agentflow.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db != again {
+		t.Error("second Open of the same path returned a new handle")
+	}
+	if err := Migrate(db, `CREATE TABLE IF NOT EXISTS t (id INTEGER)`, `CREATE TABLE IF NOT EXISTS t (id INTEGER)`); err != nil {
+		t.Errorf("repeated migration: %v", err)
+	}
+	if err := Migrate(db, `CREATE TABLE`); err == nil {
+		t.Error("Migrate accepted an invalid statement")
+	}
+}
+
+func TestMaintenance(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "agentflow.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	abs, _ := filepath.Abs(path)
+	if !slices.Contains(Opened(), abs) {
+		t.Errorf("Opened() = %v, want %s", Opened(), abs)
+	}
+	Migrate(db, `CREATE TABLE IF NOT EXISTS blobs (id INTEGER PRIMARY KEY, data BLOB)`, `CREATE INDEX IF NOT EXISTS blobs_data ON blobs (data)`)
+	for i := 0; i < 200; i++ {
+		db.Exec(`INSERT INTO blobs (data) VALUES (randomblob(4096))`)
+	}
+	db.Exec(`DELETE FROM blobs`)
+
+	ctx := context.Background()
+	freed, err := Vacuum(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if freed <= 0 {
+		t.Errorf("Vacuum freed %d bytes after deleting 800KB", freed)
+	}
+	if err := Reindex(ctx, path); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "agentflow.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Migrate(db, `CREATE TABLE IF NOT EXISTS notes (body TEXT)`)
+	db.Exec(`INSERT INTO notes (body) VALUES ('kept')`)
+
+	dest := filepath.Join(dir, "backup.db")
+	if err := Snapshot(context.Background(), path, dest); err != nil {
+		t.Fatal(err)
+	}
+	copied, err := Open(dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body string
+	if err := copied.QueryRow(`SELECT body FROM notes`).Scan(&body); err != nil || body != "kept" {
+		t.Errorf("snapshot holds %q, %v", body, err)
+	}
+	if err := Snapshot(context.Background(), path, dest); err == nil {
+		t.Error("Snapshot overwrote an existing file")
+	}
+}
+
+func TestAddColumn(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "agentflow.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	Migrate(db, `CREATE TABLE IF NOT EXISTS runs (id TEXT)`)
+	for i := 0; i < 2; i++ {
+		if err := AddColumn(db, "runs", "tenant", "TEXT NOT NULL DEFAULT ''"); err != nil {
+			t.Fatalf("AddColumn #%d: %v", i+1, err)
+		}
+	}
+	if _, err := db.Exec(`INSERT INTO runs (id, tenant) VALUES ('r1', 'acme')`); err != nil {
+		t.Error(err)
+	}
+	if err := AddColumn(db, "missing", "tenant", "TEXT"); err == nil {
+		t.Error("AddColumn altered a missing table")
+	}
+}
@@ -0,0 +1,79 @@
+package stream
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"my-agents/history"
+)
+
+// pollInterval is how often the SSE handler checks whether a run ended
+// without streaming, e.g. by failing before the formatter.
+const pollInterval = 2 * time.Second
+
+// Handler serves a run's response as server-sent events, for mounting on
+// the admin API under "GET /admin/runs/{run}/stream". Each event is a JSON
+// Chunk and the stream ends after the Done one. A run that has already
+// ended sends its final response as the only chunk.
+func (h *Hub) Handler(runs history.Store) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/runs/{run}/stream", func(w http.ResponseWriter, r *http.Request) {
+		h.ServeRun(w, r, runs, r.PathValue("run"))
+	})
+	return mux
+}
+
+// ServeRun streams run runID's response to w as server-sent events, as
+// Handler does, for APIs serving streams under their own paths.
+func (h *Hub) ServeRun(w http.ResponseWriter, r *http.Request, runs history.Store, runID string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	chunks, stop := h.Subscribe(runID)
+	defer stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	send := func(c Chunk) {
+		data, _ := json.Marshal(c)
+		fmt.Fprintf(w, "data: %s\n\n", data)
+		flusher.Flush()
+	}
+	// Subscribed first, so a run ending now is either seen here or
+	// streamed
+	ended := func() bool {
+		run, err := runs.Get(r.Context(), runID)
+		if err != nil || (run.Status != history.StatusCompleted && run.Status != history.StatusFailed) {
+			return false
+		}
+		send(Chunk{RunID: runID, Done: true, Final: run.FinalResponse, Error: run.Error})
+		return true
+	}
+	if ended() {
+		return
+	}
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case c := <-chunks:
+			send(c)
+			if c.Done {
+				return
+			}
+		case <-ticker.C:
+			if ended() {
+				return
+			}
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"my-agents/history"
+)
+
+// events decodes the chunks of an SSE body.
+func events(t *testing.T, body string) []Chunk {
+	t.Helper()
+	var chunks []Chunk
+	for _, line := range strings.Split(body, "\n") {
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		var c Chunk
+		if err := json.Unmarshal([]byte(data), &c); err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, c)
+	}
+	return chunks
+}
+
+func TestHandler(t *testing.T) {
+	runs := history.NewMemoryStore()
+	h := NewHub()
+	handler := h.Handler(runs)
+
+	w := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		handler.ServeHTTP(w, httptest.NewRequest("GET", "/admin/runs/run-1/stream", nil))
+		close(done)
+	}()
+	for !h.Subscribed("run-1") {
+		time.Sleep(time.Millisecond)
+	}
+	h.Publish(Chunk{RunID: "run-1", Agent: "formatter", Content: "Go is "})
+	h.Publish(Chunk{RunID: "run-1", Agent: "formatter", Content: "fast."})
+	h.Publish(Chunk{RunID: "run-1", Agent: "formatter", Done: true, Final: "Go is fast!"})
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("stream didn't end after the done chunk")
+	}
+
+	if ct := w.Header().Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("content type %q", ct)
+	}
+	chunks := events(t, w.Body.String())
+	if len(chunks) != 3 || chunks[0].Content != "Go is " || !chunks[2].Done || chunks[2].Final != "Go is fast!" {
+		t.Errorf("chunks = %+v", chunks)
+	}
+	if h.Subscribed("run-1") {
+		t.Error("still subscribed after the stream ended")
+	}
+}
+
+func TestHandlerEnded(t *testing.T) {
+	runs := history.NewMemoryStore()
+	runs.Save(context.Background(), &history.Run{ID: "run-1", Status: history.StatusFailed, FinalResponse: "partial", Error: "model down"})
+	w := httptest.NewRecorder()
+	NewHub().Handler(runs).ServeHTTP(w, httptest.NewRequest("GET", "/admin/runs/run-1/stream", nil))
+	chunks := events(t, w.Body.String())
+	if len(chunks) != 1 || !chunks[0].Done || chunks[0].Final != "partial" || chunks[0].Error != "model down" {
+		t.Errorf("chunks = %+v", chunks)
+	}
+}
+
+func TestHandlerCanceled(t *testing.T) {
+	h := NewHub()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := httptest.NewRecorder()
+	h.Handler(history.NewMemoryStore()).ServeHTTP(w, httptest.NewRequest("GET", "/admin/runs/run-1/stream", nil).WithContext(ctx))
+	if w.Code != http.StatusOK || len(events(t, w.Body.String())) != 0 {
+		t.Errorf("canceled: %d %q", w.Code, w.Body)
+	}
+	if h.Subscribed("run-1") {
+		t.Error("still subscribed after the client left")
+	}
+}
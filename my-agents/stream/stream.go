@@ -0,0 +1,174 @@
+// Package stream delivers a response to its caller as it is generated: the
+// formatter publishes chunks of the final response per run, which callers
+// receive from a Hub channel or over server-sent events instead of waiting
+// for the whole completion.
+package stream
+
+import (
+	"strings"
+	"sync"
+)
+
+// Chunk is a piece of a run's response. The last chunk is Done and carries
+// the complete response as post-processing left it, which replaces the
+// chunks before it; it can differ from them when the response was repaired
+// or had sections appended.
+type Chunk struct {
+	RunID   string `json:"run_id"`
+	Agent   string `json:"agent"`
+	Content string `json:"content,omitempty"`
+	Done    bool   `json:"done,omitempty"`
+	Final   string `json:"final,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Hub fans chunks out to the subscribers of each run.
+type Hub struct {
+	mu   sync.Mutex
+	subs map[string][]*subscriber
+}
+
+type subscriber struct {
+	ch   chan Chunk
+	done chan struct{} // closed first on unsubscribing, to free a blocked send
+
+	mu     sync.Mutex // held while sending, so ch isn't closed under a send
+	closed bool
+}
+
+// NewHub creates an empty hub.
+func NewHub() *Hub {
+	return &Hub{subs: make(map[string][]*subscriber)}
+}
+
+// Subscribe returns the chunks published for runID from now on, and a
+// function ending the subscription, which closes the channel. A subscriber
+// that falls behind holds up the run's generation until it reads or
+// unsubscribes.
+func (h *Hub) Subscribe(runID string) (<-chan Chunk, func()) {
+	s := &subscriber{ch: make(chan Chunk, 64), done: make(chan struct{})}
+	h.mu.Lock()
+	h.subs[runID] = append(h.subs[runID], s)
+	h.mu.Unlock()
+	var once sync.Once
+	return s.ch, func() {
+		once.Do(func() {
+			close(s.done)
+			h.mu.Lock()
+			subs := h.subs[runID]
+			for i, other := range subs {
+				if other == s {
+					subs = append(subs[:i], subs[i+1:]...)
+					break
+				}
+			}
+			if len(subs) == 0 {
+				delete(h.subs, runID)
+			} else {
+				h.subs[runID] = subs
+			}
+			h.mu.Unlock()
+			s.mu.Lock()
+			s.closed = true
+			close(s.ch)
+			s.mu.Unlock()
+		})
+	}
+}
+
+// Subscribed reports whether anyone is subscribed to runID.
+func (h *Hub) Subscribed(runID string) bool {
+	if h == nil {
+		return false
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.subs[runID]) > 0
+}
+
+// Publish delivers c to the subscribers of its run.
+func (h *Hub) Publish(c Chunk) {
+	if h == nil {
+		return
+	}
+	h.mu.Lock()
+	subs := append([]*subscriber(nil), h.subs[c.RunID]...)
+	h.mu.Unlock()
+	for _, s := range subs {
+		s.mu.Lock()
+		if !s.closed {
+			select {
+			case s.ch <- c:
+			case <-s.done:
+			}
+		}
+		s.mu.Unlock()
+	}
+}
+
+// Writer turns tokens into filtered pieces of text. Tokens are held back to
+// the end of a sentence or line, or of a word once a long run of text has
+// built up, so filter sees whole words before emit gets anything.
+type Writer struct {
+	filter func(string) (string, error)
+	emit   func(string)
+	buf    strings.Builder
+	err    error
+}
+
+// flushAfter is how much text without a sentence end is held back.
+const flushAfter = 80
+
+// NewWriter creates a writer passing text through filter, when not nil, to
+// emit.
+func NewWriter(filter func(string) (string, error), emit func(string)) *Writer {
+	return &Writer{filter: filter, emit: emit}
+}
+
+// Write adds a token.
+func (w *Writer) Write(token string) {
+	if w.err != nil {
+		return
+	}
+	w.buf.WriteString(token)
+	text := w.buf.String()
+	cut := strings.LastIndex(text, "\n") + 1
+	for _, end := range []string{". ", "! ", "? ", ": "} {
+		if i := strings.LastIndex(text, end); i >= 0 && i+len(end) > cut {
+			cut = i + len(end)
+		}
+	}
+	if cut == 0 && len(text) >= flushAfter {
+		cut = strings.LastIndexAny(text, " \t") + 1
+	}
+	if cut > 0 {
+		w.buf.Reset()
+		w.buf.WriteString(text[cut:])
+		w.send(text[:cut])
+	}
+}
+
+// Flush emits the text held back. A writer whose filter failed emits
+// nothing more and returns the filter's error.
+func (w *Writer) Flush() error {
+	if w.err == nil && w.buf.Len() > 0 {
+		text := w.buf.String()
+		w.buf.Reset()
+		w.send(text)
+	}
+	return w.err
+}
+
+func (w *Writer) send(text string) {
+	if w.filter != nil {
+		filtered, err := w.filter(text)
+		if err != nil {
+			w.err = err
+			return
+		}
+		text = filtered
+	}
+	if text != "" {
+		w.emit(text)
+	}
+}
@@ -0,0 +1,112 @@
+package stream
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHub(t *testing.T) {
+	h := NewHub()
+	a, stopA := h.Subscribe("run-1")
+	b, stopB := h.Subscribe("run-1")
+	other, stopOther := h.Subscribe("run-2")
+	defer stopOther()
+	if !h.Subscribed("run-1") || h.Subscribed("run-3") {
+		t.Error("wrong subscriptions")
+	}
+
+	h.Publish(Chunk{RunID: "run-1", Content: "Hello"})
+	for _, ch := range []<-chan Chunk{a, b} {
+		if c := <-ch; c.Content != "Hello" {
+			t.Errorf("chunk = %+v", c)
+		}
+	}
+	select {
+	case c := <-other:
+		t.Errorf("run-2 got %+v", c)
+	default:
+	}
+
+	stopA()
+	stopA()
+	if _, ok := <-a; ok {
+		t.Error("channel open after unsubscribing")
+	}
+	stopB()
+	if h.Subscribed("run-1") {
+		t.Error("still subscribed to run-1")
+	}
+	// Nobody is listening
+	h.Publish(Chunk{RunID: "run-1", Content: "lost"})
+
+	var nilHub *Hub
+	nilHub.Publish(Chunk{RunID: "run-1"})
+	if nilHub.Subscribed("run-1") {
+		t.Error("nil hub has subscribers")
+	}
+}
+
+func TestUnsubscribeFreesPublish(t *testing.T) {
+	h := NewHub()
+	_, stop := h.Subscribe("run-1")
+	published := make(chan struct{})
+	go func() {
+		// More than the channel holds, with nobody reading
+		for range 100 {
+			h.Publish(Chunk{RunID: "run-1", Content: "x"})
+		}
+		close(published)
+	}()
+	time.Sleep(10 * time.Millisecond)
+	stop()
+	select {
+	case <-published:
+	case <-time.After(time.Second):
+		t.Fatal("publish still blocked after unsubscribing")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var got []string
+	w := NewWriter(nil, func(s string) { got = append(got, s) })
+	for _, token := range []string{"Go is", " fast", ". It", " compiles", " quickly", "\nDone"} {
+		w.Write(token)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"Go is fast. ", "It compiles quickly\n", "Done"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("emitted %q, want %q", got, want)
+	}
+
+	// A long run of text without a sentence end flushes at a word
+	got = nil
+	w = NewWriter(nil, func(s string) { got = append(got, s) })
+	w.Write(strings.Repeat("word ", 16) + "wor")
+	if len(got) != 1 || got[0] != strings.Repeat("word ", 16) {
+		t.Errorf("long text emitted %q", got)
+	}
+}
+
+func TestWriterFilter(t *testing.T) {
+	var got []string
+	filter := func(s string) (string, error) {
+		if strings.Contains(s, "secret") {
+			return "", errors.New("blocked")
+		}
+		return strings.ToUpper(s), nil
+	}
+	w := NewWriter(filter, func(s string) { got = append(got, s) })
+	w.Write("Hi there. ")
+	w.Write("The secret is out. ")
+	w.Write("More. ")
+	if err := w.Flush(); err == nil || err.Error() != "blocked" {
+		t.Errorf("flush = %v", err)
+	}
+	if len(got) != 1 || got[0] != "HI THERE. " {
+		t.Errorf("emitted %q", got)
+	}
+}
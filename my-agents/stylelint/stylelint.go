@@ -0,0 +1,228 @@
+// Package stylelint checks final responses against the house style guide —
+// sentence length, passive voice, banned phrases and heading structure —
+// after the formatter has written them. Violations are flagged in the run
+// state or, in fix mode, rewritten away by a short model call first.
+package stylelint
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Key is the state key holding the violations left in the final response.
+const Key = "style_violations"
+
+// Rule names, as reported in violations.
+const (
+	RuleSentenceLength = "sentence_length"
+	RulePassiveVoice   = "passive_voice"
+	RuleBannedPhrase   = "banned_phrase"
+	RuleHeadings       = "headings"
+)
+
+// Config is the [style_lint] section of agentflow.toml. Rules left at their
+// zero value are off, and with every rule off nothing is linted.
+//
+//	[style_lint]
+//	mode = "fix"                 # or "flag" (default)
+//	fixer = "cheap"              # a [providers.<name>] table; default the formatter's own
+//	max_sentence_words = 30
+//	passive_voice = true
+//	banned_phrases = ["in order to", "please note that"]
+//	headings = true
+type Config struct {
+	// Mode is "flag" to only record violations, or "fix" to have the
+	// model rewrite the response once and record what's left.
+	Mode  string `toml:"mode"`
+	Fixer string `toml:"fixer"`
+
+	MaxSentenceWords int      `toml:"max_sentence_words"`
+	PassiveVoice     bool     `toml:"passive_voice"`
+	BannedPhrases    []string `toml:"banned_phrases"` // matched case-insensitively
+	// Headings checks Markdown headings: one top-level heading at most, no
+	// skipped levels and no empty headings.
+	Headings bool `toml:"headings"`
+}
+
+// Violation is one place the text breaks a rule.
+type Violation struct {
+	Rule   string `json:"rule"`
+	Line   int    `json:"line"` // 1-based
+	Detail string `json:"detail"`
+}
+
+func (v Violation) String() string {
+	return fmt.Sprintf("%s (line %d): %s", v.Rule, v.Line, v.Detail)
+}
+
+// Linter checks text against the configured rules.
+type Linter struct {
+	cfg    Config
+	fix    bool
+	fixer  core.ModelProvider
+	banned []*regexp.Regexp
+}
+
+// New creates a linter rewriting with fixer in fix mode. It returns nil
+// when no rule is enabled.
+func New(cfg Config, fixer core.ModelProvider) (*Linter, error) {
+	if cfg.MaxSentenceWords <= 0 && !cfg.PassiveVoice && len(cfg.BannedPhrases) == 0 && !cfg.Headings {
+		return nil, nil
+	}
+	l := &Linter{cfg: cfg, fixer: fixer}
+	switch cfg.Mode {
+	case "", "flag":
+	case "fix":
+		l.fix = true
+	default:
+		return nil, fmt.Errorf("style_lint: unknown mode %q", cfg.Mode)
+	}
+	for _, phrase := range cfg.BannedPhrases {
+		words := strings.Fields(phrase)
+		if len(words) == 0 {
+			return nil, fmt.Errorf("style_lint: empty banned phrase")
+		}
+		for i, w := range words {
+			words[i] = regexp.QuoteMeta(w)
+		}
+		// \b only holds next to a word character, so phrases like "e.g."
+		// are bounded only where they are
+		expr := strings.Join(words, `\s+`)
+		if wordChar.MatchString(phrase[:1]) {
+			expr = `\b` + expr
+		}
+		if wordChar.MatchString(phrase[len(phrase)-1:]) {
+			expr += `\b`
+		}
+		l.banned = append(l.banned, regexp.MustCompile(`(?i)`+expr))
+	}
+	return l, nil
+}
+
+var (
+	wordChar       = regexp.MustCompile(`\w`)
+	headingPattern = regexp.MustCompile(`^(#{1,6})(?:\s+(.*?))?\s*#*\s*$`)
+	listPrefix     = regexp.MustCompile(`^\s*(?:[-*•+]|\d+[.)])\s+`)
+	sentenceEnd    = regexp.MustCompile(`[.!?]+(?:\s+|$)`)
+)
+
+// passivePattern matches a form of "to be" followed, possibly after an
+// adverb, by a past participle: regular ones end in -ed, and the common
+// irregular ones are listed.
+var passivePattern = regexp.MustCompile(`(?i)\b(?:am|is|are|was|were|be|been|being)\s+(?:\w+ly\s+)?(?:\w+ed|built|done|given|taken|made|seen|written|known|shown|found|held|kept|sent|told|paid|sold|thrown|chosen|drawn|driven|forgotten|hidden|broken|spoken|stolen|begun|brought|bought|caught|taught|thought|left|lost|meant|won|understood|set|put|run)\b`)
+
+// Lint returns the text's violations in line order. Code blocks and table
+// rows aren't prose and are skipped.
+func (l *Linter) Lint(text string) []Violation {
+	var violations []Violation
+	add := func(rule string, line int, format string, args ...any) {
+		violations = append(violations, Violation{Rule: rule, Line: line, Detail: fmt.Sprintf(format, args...)})
+	}
+	inCode, lastLevel, topLevel := false, 0, 0
+	for i, line := range strings.Split(text, "\n") {
+		n := i + 1
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~") {
+			inCode = !inCode
+			continue
+		}
+		if inCode || trimmed == "" || strings.HasPrefix(trimmed, "|") {
+			continue
+		}
+
+		if m := headingPattern.FindStringSubmatch(trimmed); m != nil {
+			if l.cfg.Headings {
+				level := len(m[1])
+				if strings.TrimSpace(m[2]) == "" {
+					add(RuleHeadings, n, "empty heading")
+				}
+				if level == 1 {
+					if topLevel++; topLevel == 2 {
+						add(RuleHeadings, n, "more than one top-level heading")
+					}
+				}
+				if lastLevel > 0 && level > lastLevel+1 {
+					add(RuleHeadings, n, "skips from level %d to level %d", lastLevel, level)
+				}
+				lastLevel = level
+			}
+			continue
+		}
+
+		prose := listPrefix.ReplaceAllString(trimmed, "")
+		for _, re := range l.banned {
+			if m := re.FindString(prose); m != "" {
+				add(RuleBannedPhrase, n, "uses %q", m)
+			}
+		}
+		if l.cfg.PassiveVoice {
+			for _, m := range passivePattern.FindAllString(prose, -1) {
+				add(RulePassiveVoice, n, "%q is passive", m)
+			}
+		}
+		if l.cfg.MaxSentenceWords > 0 {
+			for _, sentence := range sentenceEnd.Split(prose, -1) {
+				if words := strings.Fields(sentence); len(words) > l.cfg.MaxSentenceWords {
+					add(RuleSentenceLength, n, "sentence of %d words, limit is %d: %q", len(words), l.cfg.MaxSentenceWords, strings.Join(words[:min(6, len(words))], " ")+"…")
+				}
+			}
+		}
+	}
+	return violations
+}
+
+// Check lints text and, in fix mode, has the fixer rewrite it when it breaks
+// a rule. It returns the text to use and the violations left in it; a failed
+// rewrite keeps the text as it was.
+func (l *Linter) Check(ctx context.Context, text string) (string, []Violation) {
+	violations := l.Lint(text)
+	if !l.fix || len(violations) == 0 {
+		return text, violations
+	}
+	resp, err := l.fixer.Call(ctx, l.RepairPrompt(text, violations))
+	if err != nil || strings.TrimSpace(resp.Content) == "" {
+		core.Logger().Warn().Err(err).Msg("Failed to fix style violations")
+		return text, violations
+	}
+	return resp.Content, l.Lint(resp.Content)
+}
+
+// RepairPrompt asks the model to rewrite text without the violations.
+func (l *Linter) RepairPrompt(text string, violations []Violation) core.Prompt {
+	var b strings.Builder
+	b.WriteString("Edit the response below to follow the style guide. Change only what the problems require; keep the meaning, facts and Markdown formatting.\n\nProblems found:\n")
+	for _, v := range violations {
+		fmt.Fprintf(&b, "- %s\n", v)
+	}
+	fmt.Fprintf(&b, "\nStyle guide: %s\n\nResponse:\n%s", l.guide(), text)
+	return core.Prompt{
+		System: "You are a copy editor. Return only the edited response.",
+		User:   b.String(),
+	}
+}
+
+// guide describes the enabled rules.
+func (l *Linter) guide() string {
+	var rules []string
+	if l.cfg.MaxSentenceWords > 0 {
+		rules = append(rules, fmt.Sprintf("keep sentences to %d words or fewer", l.cfg.MaxSentenceWords))
+	}
+	if l.cfg.PassiveVoice {
+		rules = append(rules, "write in the active voice")
+	}
+	if len(l.cfg.BannedPhrases) > 0 {
+		q := make([]string, len(l.cfg.BannedPhrases))
+		for i, p := range l.cfg.BannedPhrases {
+			q[i] = fmt.Sprintf("%q", p)
+		}
+		rules = append(rules, "never use "+strings.Join(q, ", "))
+	}
+	if l.cfg.Headings {
+		rules = append(rules, "use at most one top-level heading and don't skip heading levels")
+	}
+	return strings.Join(rules, "; ") + "."
+}
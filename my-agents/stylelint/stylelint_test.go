@@ -0,0 +1,118 @@
+package stylelint
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// fixer rewrites with reply, recording the prompts.
+type fixer struct {
+	core.ModelProvider
+	reply   string
+	err     error
+	prompts []core.Prompt
+}
+
+func (f *fixer) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	f.prompts = append(f.prompts, prompt)
+	return core.Response{Content: f.reply}, f.err
+}
+
+func TestNew(t *testing.T) {
+	if l, err := New(Config{Mode: "fix"}, nil); l != nil || err != nil {
+		t.Errorf("no rules = %v, %v", l, err)
+	}
+	for _, cfg := range []Config{
+		{Mode: "rewrite", PassiveVoice: true},
+		{BannedPhrases: []string{"  "}},
+	} {
+		if _, err := New(cfg, nil); err == nil {
+			t.Errorf("config %+v accepted", cfg)
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	l, err := New(Config{MaxSentenceWords: 8, PassiveVoice: true, BannedPhrases: []string{"in order to", "(sic)"}, Headings: true}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := strings.Join([]string{
+		"# Guide",
+		"Tool was quickly written In  Order To help. It works.",
+		"### Details",
+		"- Use (sic) sparingly.",
+		"This sentence has far too many words in it for the limit set here.",
+		"```",
+		"the code was written in order to fail and is much longer than eight words",
+		"```",
+		"| table | was written |",
+		"##",
+		"# Second",
+	}, "\n")
+	var got []string
+	for _, v := range l.Lint(text) {
+		got = append(got, v.String())
+	}
+	want := []string{
+		`banned_phrase (line 2): uses "In  Order To"`,
+		`passive_voice (line 2): "was quickly written" is passive`,
+		`headings (line 3): skips from level 1 to level 3`,
+		`banned_phrase (line 4): uses "(sic)"`,
+		`sentence_length (line 5): sentence of 14 words, limit is 8: "This sentence has far too many…"`,
+		`headings (line 10): empty heading`,
+		`headings (line 11): more than one top-level heading`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("violations =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Rules left off aren't checked
+	l, _ = New(Config{Headings: true}, nil)
+	if got := l.Lint("The tool was written in order to help.\n### Deep"); len(got) != 0 {
+		t.Errorf("violations of rules left off: %v", got)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	ctx := context.Background()
+	text := "The tool was written by us."
+	flag, _ := New(Config{PassiveVoice: true}, nil)
+	if got, violations := flag.Check(ctx, text); got != text || len(violations) != 1 {
+		t.Errorf("flag mode = %q, %v", got, violations)
+	}
+
+	f := &fixer{reply: "We wrote the tool."}
+	fix, _ := New(Config{Mode: "fix", PassiveVoice: true, MaxSentenceWords: 30, BannedPhrases: []string{"in order to"}, Headings: true}, f)
+	got, violations := fix.Check(ctx, text)
+	if got != "We wrote the tool." || len(violations) != 0 {
+		t.Errorf("fix mode = %q, %v", got, violations)
+	}
+	prompt := f.prompts[0].User
+	for _, s := range []string{
+		`- passive_voice (line 1): "was written" is passive`,
+		`Style guide: keep sentences to 30 words or fewer; write in the active voice; never use "in order to"; use at most one top-level heading and don't skip heading levels.`,
+		"Response:\n" + text,
+	} {
+		if !strings.Contains(prompt, s) {
+			t.Errorf("repair prompt lacks %q:\n%s", s, prompt)
+		}
+	}
+
+	// Clean text isn't sent
+	if _, violations := fix.Check(ctx, "We wrote it."); len(violations) != 0 || len(f.prompts) != 1 {
+		t.Errorf("clean text: %v after %d calls", violations, len(f.prompts))
+	}
+	// A failed or empty rewrite keeps the text
+	for _, f := range []*fixer{{err: errors.New("model down")}, {reply: " "}} {
+		fix.fixer = f
+		if got, violations := fix.Check(ctx, text); got != text || len(violations) != 1 {
+			t.Errorf("failed rewrite = %q, %v", got, violations)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+// Package tabular finds tables in generated text and re-emits them as
+// Markdown, CSV and Vega-Lite chart specs for downstream UIs.
+package tabular
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Table is a header row plus data rows of equal width.
+type Table struct {
+	Columns []string   `json:"columns"`
+	Rows    [][]string `json:"rows"`
+}
+
+// Detect extracts Markdown pipe tables and fenced ```csv blocks from text.
+func Detect(text string) []Table {
+	var tables []Table
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+
+		if strings.HasPrefix(line, "```csv") {
+			var block []string
+			for i++; i < len(lines) && !strings.HasPrefix(strings.TrimSpace(lines[i]), "```"); i++ {
+				block = append(block, lines[i])
+			}
+			if t, ok := parseCSV(strings.Join(block, "\n")); ok {
+				tables = append(tables, t)
+			}
+			continue
+		}
+
+		if isPipeRow(line) && i+1 < len(lines) && isSeparator(strings.TrimSpace(lines[i+1])) {
+			t := Table{Columns: splitPipeRow(line)}
+			for i += 2; i < len(lines) && isPipeRow(strings.TrimSpace(lines[i])); i++ {
+				row := splitPipeRow(strings.TrimSpace(lines[i]))
+				t.Rows = append(t.Rows, fit(row, len(t.Columns)))
+			}
+			i--
+			if len(t.Rows) > 0 {
+				tables = append(tables, t)
+			}
+		}
+	}
+	return tables
+}
+
+func isPipeRow(line string) bool {
+	return strings.HasPrefix(line, "|") && strings.Count(line, "|") >= 2
+}
+
+func isSeparator(line string) bool {
+	if !isPipeRow(line) {
+		return false
+	}
+	return strings.Trim(line, "|-: ") == ""
+}
+
+func splitPipeRow(line string) []string {
+	line = strings.TrimSuffix(strings.TrimPrefix(line, "|"), "|")
+	cells := strings.Split(line, "|")
+	for i, c := range cells {
+		cells[i] = strings.TrimSpace(c)
+	}
+	return cells
+}
+
+func parseCSV(block string) (Table, bool) {
+	r := csv.NewReader(strings.NewReader(block))
+	r.FieldsPerRecord = -1
+	records, err := r.ReadAll()
+	if err != nil || len(records) < 2 {
+		return Table{}, false
+	}
+	t := Table{Columns: records[0]}
+	for _, rec := range records[1:] {
+		t.Rows = append(t.Rows, fit(rec, len(t.Columns)))
+	}
+	return t, true
+}
+
+// fit pads or truncates row to n cells.
+func fit(row []string, n int) []string {
+	out := make([]string, n)
+	copy(out, row)
+	return out
+}
+
+// Markdown renders the table as a Markdown pipe table.
+func (t Table) Markdown() string {
+	var b strings.Builder
+	b.WriteString("| " + strings.Join(t.Columns, " | ") + " |\n")
+	b.WriteString("|" + strings.Repeat(" --- |", len(t.Columns)) + "\n")
+	for _, row := range t.Rows {
+		b.WriteString("| " + strings.Join(row, " | ") + " |\n")
+	}
+	return b.String()
+}
+
+// CSV renders the table as RFC 4180 CSV.
+func (t Table) CSV() string {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	_ = w.Write(t.Columns)
+	_ = w.WriteAll(t.Rows)
+	return buf.String()
+}
+
+// Numbers are the separators the text writes numbers with, as its locale
+// does: "1,234.5" in English, "1.234,5" in German.
+type Numbers struct {
+	Decimal string
+	Group   string
+}
+
+// Plain is how numbers are written when no locale says otherwise.
+var Plain = Numbers{Decimal: ".", Group: ","}
+
+// Chart returns a Vega-Lite spec when the table has a label column and a
+// numeric column, read with numbers' separators: a line chart over dates,
+// otherwise a bar chart.
+func (t Table) Chart(numbers Numbers) (json.RawMessage, bool) {
+	if numbers.Decimal == "" {
+		numbers = Plain
+	}
+	isNumber := func(s string) bool {
+		_, ok := parseNumber(s, numbers)
+		return ok
+	}
+	if len(t.Rows) < 2 || len(t.Columns) < 2 {
+		return nil, false
+	}
+	// The x axis is the first date or non-numeric column; the y axis is the
+	// first other numeric column.
+	xCol, yCol := -1, -1
+	for c := range t.Columns {
+		if columnIs(t, c, isDate) || !columnIs(t, c, isNumber) {
+			xCol = c
+			break
+		}
+	}
+	for c := range t.Columns {
+		if c != xCol && columnIs(t, c, isNumber) {
+			yCol = c
+			break
+		}
+	}
+	if xCol < 0 || yCol < 0 {
+		return nil, false
+	}
+
+	mark, xType := "bar", "nominal"
+	if columnIs(t, xCol, isDate) {
+		mark, xType = "line", "temporal"
+	}
+	values := make([]map[string]any, 0, len(t.Rows))
+	for _, row := range t.Rows {
+		y, _ := parseNumber(row[yCol], numbers)
+		values = append(values, map[string]any{t.Columns[xCol]: row[xCol], t.Columns[yCol]: y})
+	}
+	spec := map[string]any{
+		"$schema": "https://vega.github.io/schema/vega-lite/v5.json",
+		"data":    map[string]any{"values": values},
+		"mark":    mark,
+		"encoding": map[string]any{
+			"x": map[string]any{"field": t.Columns[xCol], "type": xType},
+			"y": map[string]any{"field": t.Columns[yCol], "type": "quantitative"},
+		},
+	}
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+func columnIs(t Table, col int, pred func(string) bool) bool {
+	for _, row := range t.Rows {
+		if !pred(row[col]) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseNumber accepts numbers written with numbers' separators plus common
+// decoration: "1,234", "$12", "45%".
+func parseNumber(s string, numbers Numbers) (float64, bool) {
+	s = strings.TrimSpace(s)
+	s = strings.TrimSpace(strings.TrimSuffix(strings.TrimRight(strings.TrimLeft(s, "$€£"), "$€£ "), "%"))
+	if numbers.Group != "" {
+		s = strings.ReplaceAll(s, numbers.Group, "")
+		if numbers.Group == " " {
+			// Typeset text groups with no-break spaces
+			s = strings.NewReplacer("\u00a0", "", "\u202f", "").Replace(s)
+		}
+	}
+	if numbers.Decimal != "." {
+		if strings.Contains(s, ".") {
+			return 0, false
+		}
+		s = strings.ReplaceAll(s, numbers.Decimal, ".")
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	return f, err == nil
+}
+
+func isDate(s string) bool {
+	for _, layout := range []string{"2006-01-02", "2006-01", "2006"} {
+		if _, err := time.Parse(layout, strings.TrimSpace(s)); err == nil {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,83 @@
+package tabular
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	text := "Results:\n\n| Region | Sales |\n|---|---:|\n| North | 1,200 |\n| South | 900 | extra |\n\nAnd as CSV:\n```csv\nyear,total\n2024,10\n2025\n```\n"
+	tables := Detect(text)
+	if len(tables) != 2 {
+		t.Fatalf("Detect found %d tables, want 2", len(tables))
+	}
+	want := Table{Columns: []string{"Region", "Sales"}, Rows: [][]string{{"North", "1,200"}, {"South", "900"}}}
+	if !reflect.DeepEqual(tables[0], want) {
+		t.Errorf("pipe table = %+v", tables[0])
+	}
+	want = Table{Columns: []string{"year", "total"}, Rows: [][]string{{"2024", "10"}, {"2025", ""}}}
+	if !reflect.DeepEqual(tables[1], want) {
+		t.Errorf("csv table = %+v", tables[1])
+	}
+}
+
+func TestDetectNeedsSeparator(t *testing.T) {
+	if tables := Detect("| a | b |\n| c | d |\n"); len(tables) != 0 {
+		t.Errorf("Detect = %+v, want none", tables)
+	}
+}
+
+func TestRender(t *testing.T) {
+	tbl := Table{Columns: []string{"a", "b"}, Rows: [][]string{{"1", "x,y"}}}
+	if got, want := tbl.Markdown(), "| a | b |\n| --- | --- |\n| 1 | x,y |\n"; got != want {
+		t.Errorf("Markdown = %q, want %q", got, want)
+	}
+	if got, want := tbl.CSV(), "a,b\n1,\"x,y\"\n"; got != want {
+		t.Errorf("CSV = %q, want %q", got, want)
+	}
+}
+
+func chartOf(t *testing.T, tbl Table, numbers Numbers) map[string]any {
+	t.Helper()
+	data, ok := tbl.Chart(numbers)
+	if !ok {
+		t.Fatal("Chart found no chart")
+	}
+	var spec map[string]any
+	if err := json.Unmarshal(data, &spec); err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestChart(t *testing.T) {
+	spec := chartOf(t, Table{Columns: []string{"Region", "Sales"}, Rows: [][]string{{"North", "$1,200"}, {"South", "900"}}}, Numbers{})
+	if spec["mark"] != "bar" {
+		t.Errorf("mark = %v, want bar", spec["mark"])
+	}
+	values := spec["data"].(map[string]any)["values"].([]any)
+	if got := values[0].(map[string]any)["Sales"]; got != 1200.0 {
+		t.Errorf("first value = %v, want 1200", got)
+	}
+
+	spec = chartOf(t, Table{Columns: []string{"Month", "Total"}, Rows: [][]string{{"2026-01", "1.234,5"}, {"2026-02", "7,5"}}}, Numbers{Decimal: ",", Group: "."})
+	if spec["mark"] != "line" {
+		t.Errorf("mark = %v, want line", spec["mark"])
+	}
+	values = spec["data"].(map[string]any)["values"].([]any)
+	if got := values[0].(map[string]any)["Total"]; got != 1234.5 {
+		t.Errorf("first value = %v, want 1234.5", got)
+	}
+}
+
+func TestNoChart(t *testing.T) {
+	for _, tbl := range []Table{
+		{Columns: []string{"a", "b"}, Rows: [][]string{{"x", "y"}, {"z", "w"}}},
+		{Columns: []string{"a", "b"}, Rows: [][]string{{"x", "1"}}},
+	} {
+		if _, ok := tbl.Chart(Plain); ok {
+			t.Errorf("Chart(%+v) found a chart", tbl)
+		}
+	}
+}
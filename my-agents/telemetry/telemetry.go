@@ -0,0 +1,442 @@
+// Package telemetry reports anonymized, aggregated usage statistics for
+// fleet-wide product analytics. It is off unless enabled. Reports carry
+// counts and averages only — runs, agent steps, tool calls, LLM calls and
+// tokens — under a random installation ID; no prompt or response content,
+// tenant, user, session or run ID ever leaves the process. Small counts are
+// folded together and counts can be noised (differential privacy).
+package telemetry
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	mrand "math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+	"my-agents/tools"
+)
+
+// DefaultIDPath is where the installation ID is kept. Deleting it makes the
+// installation report as a new one.
+const DefaultIDPath = ".agentflow/telemetry_id"
+
+// Other is the bucket small counts are folded into.
+const Other = "other"
+
+// Config is the [telemetry] section of agentflow.toml:
+//
+//	[telemetry]
+//	enabled = true
+//	endpoint = "https://telemetry.example.com/v1/reports"
+//	interval = "1h"
+//	epsilon = 1.0
+//	min_count = 5
+//	[telemetry.sensitivity]
+//	tokens = 20000
+type Config struct {
+	Enabled  bool   `toml:"enabled"`
+	Endpoint string `toml:"endpoint"` // receives each report as a JSON POST
+	Interval string `toml:"interval"` // default "1h"
+	// Epsilon adds Laplace noise of scale sensitivity/epsilon to every
+	// count, making each count epsilon-differentially private for a run
+	// within the sensitivity bounds; smaller is more private. 0 reports
+	// exact counts.
+	Epsilon     float64     `toml:"epsilon"`
+	Sensitivity Sensitivity `toml:"sensitivity"`
+	// MinCount folds agents and tools counted fewer times than this in a
+	// report into "other" (default 5).
+	MinCount int    `toml:"min_count"`
+	IDPath   string `toml:"id_path"` // default DefaultIDPath
+}
+
+// Sensitivity is the most one run adds to each kind of count in a report,
+// which the noise is scaled to. A run adding more is protected at a
+// proportionally larger epsilon. Zero takes the default.
+type Sensitivity struct {
+	Runs      int `toml:"runs"`       // runs started, completed and failed (default 1)
+	Steps     int `toml:"steps"`      // one agent's steps and errors (default 10)
+	ToolCalls int `toml:"tool_calls"` // one tool's calls and errors (default 10)
+	LLMCalls  int `toml:"llm_calls"`  // default 20
+	Tokens    int `toml:"tokens"`     // prompt or completion tokens (default 50000)
+}
+
+// Sensitivity defaults.
+const (
+	DefaultStepSensitivity    = 10
+	DefaultToolSensitivity    = 10
+	DefaultLLMCallSensitivity = 20
+	DefaultTokenSensitivity   = 50000
+)
+
+// Counter is the activity of one agent or tool in a report.
+type Counter struct {
+	Count     int   `json:"count"`
+	Errors    int   `json:"errors"`
+	AvgMillis int64 `json:"avg_ms"`
+}
+
+// Report is one interval's statistics as sent to the endpoint.
+type Report struct {
+	Installation     string             `json:"installation"`
+	Start            time.Time          `json:"start"`
+	End              time.Time          `json:"end"`
+	RunsStarted      int                `json:"runs_started"`
+	RunsCompleted    int                `json:"runs_completed"`
+	RunsFailed       int                `json:"runs_failed"`
+	Agents           map[string]Counter `json:"agents"`
+	Tools            map[string]Counter `json:"tools"`
+	LLMCalls         int                `json:"llm_calls"`
+	PromptTokens     int                `json:"prompt_tokens"`
+	CompletionTokens int                `json:"completion_tokens"`
+	Noised           bool               `json:"noised"`
+}
+
+// tally accumulates a counter before it is reported.
+type tally struct {
+	count, errors int
+	took          time.Duration
+}
+
+// Collector gathers statistics from callbacks and middleware and reports
+// them on an interval.
+type Collector struct {
+	cfg      Config
+	id       string
+	interval time.Duration
+	client   *http.Client
+
+	mu               sync.Mutex
+	start            time.Time
+	started          map[string]time.Time // agent step start, by event
+	runs             [3]int               // started, completed, failed
+	agents, tools    map[string]*tally
+	llmCalls         int
+	promptTokens     int
+	completionTokens int
+}
+
+// New creates a collector, loading or creating the installation ID.
+func New(cfg Config) (*Collector, error) {
+	if cfg.Endpoint == "" {
+		return nil, errors.New("telemetry endpoint is required")
+	}
+	if cfg.Epsilon < 0 {
+		return nil, fmt.Errorf("telemetry epsilon must not be negative")
+	}
+	if cfg.MinCount == 0 {
+		cfg.MinCount = 5
+	}
+	sens := &cfg.Sensitivity
+	for _, v := range []*int{&sens.Runs, &sens.Steps, &sens.ToolCalls, &sens.LLMCalls, &sens.Tokens} {
+		if *v < 0 {
+			return nil, fmt.Errorf("telemetry sensitivity must not be negative")
+		}
+	}
+	sens.Runs = cmp.Or(sens.Runs, 1)
+	sens.Steps = cmp.Or(sens.Steps, DefaultStepSensitivity)
+	sens.ToolCalls = cmp.Or(sens.ToolCalls, DefaultToolSensitivity)
+	sens.LLMCalls = cmp.Or(sens.LLMCalls, DefaultLLMCallSensitivity)
+	sens.Tokens = cmp.Or(sens.Tokens, DefaultTokenSensitivity)
+	id, err := installationID(cmp.Or(cfg.IDPath, DefaultIDPath))
+	if err != nil {
+		return nil, err
+	}
+	c := &Collector{cfg: cfg, id: id, interval: time.Hour, client: &http.Client{Timeout: 30 * time.Second}}
+	if d, err := time.ParseDuration(cfg.Interval); err == nil && d > 0 {
+		c.interval = d
+	}
+	c.reset()
+	return c, nil
+}
+
+// reset starts a new interval. Callers hold c.mu or own c.
+func (c *Collector) reset() {
+	c.start = time.Now()
+	c.started = make(map[string]time.Time)
+	c.runs = [3]int{}
+	c.agents, c.tools = make(map[string]*tally), make(map[string]*tally)
+	c.llmCalls, c.promptTokens, c.completionTokens = 0, 0, 0
+}
+
+// Register counts runs and agent steps with agent callbacks.
+func (c *Collector) Register(runner core.Runner) error {
+	before := func(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+		if args.Event == nil {
+			return args.State, nil
+		}
+		c.mu.Lock()
+		c.started[args.Event.GetID()] = time.Now()
+		// A run's first event is the one its ID comes from
+		if runID, _ := args.Event.GetMetadataValue(history.RunIDKey); runID == "" || runID == args.Event.GetID() {
+			c.runs[0]++
+		}
+		c.mu.Unlock()
+		return args.State, nil
+	}
+	after := func(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+		if args.Event == nil {
+			return args.State, nil
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		t := c.tally(c.agents, args.AgentID)
+		t.count++
+		if at, ok := c.started[args.Event.GetID()]; ok {
+			t.took += time.Since(at)
+			delete(c.started, args.Event.GetID())
+		}
+		if args.Error != nil {
+			t.errors++
+			c.runs[2]++
+		} else if args.State != nil {
+			if route, _ := args.State.GetMeta(core.RouteMetadataKey); route == "" {
+				c.runs[1]++
+			}
+		}
+		return args.State, nil
+	}
+	if err := runner.RegisterCallback(core.HookBeforeAgentRun, "telemetry", before); err != nil {
+		return err
+	}
+	return runner.RegisterCallback(core.HookAfterAgentRun, "telemetry", after)
+}
+
+// tally returns the named counter. Callers hold c.mu.
+func (c *Collector) tally(m map[string]*tally, name string) *tally {
+	t, ok := m[name]
+	if !ok {
+		t = &tally{}
+		m[name] = t
+	}
+	return t
+}
+
+// Middleware counts each agent's LLM calls and tokens. It has the shape of
+// di.LLMMiddleware.
+func (c *Collector) Middleware() func(agent string, llm core.ModelProvider) core.ModelProvider {
+	return func(agent string, llm core.ModelProvider) core.ModelProvider {
+		return &counted{ModelProvider: llm, c: c}
+	}
+}
+
+// ToolMiddleware counts tool calls by tool. It has the shape of
+// di.ToolMiddleware.
+func (c *Collector) ToolMiddleware() func(agent string, tool tools.Tool) tools.Tool {
+	return func(agent string, tool tools.Tool) tools.Tool {
+		return &countedTool{Tool: tool, c: c}
+	}
+}
+
+// Snapshot returns the current interval's report, as it would be sent, and
+// starts a new interval.
+func (c *Collector) Snapshot() Report {
+	c.mu.Lock()
+	rep := Report{
+		Installation:     c.id,
+		Start:            c.start.UTC().Truncate(time.Minute),
+		End:              time.Now().UTC().Truncate(time.Minute),
+		RunsStarted:      c.runs[0],
+		RunsCompleted:    c.runs[1],
+		RunsFailed:       c.runs[2],
+		Agents:           c.fold(c.agents),
+		Tools:            c.fold(c.tools),
+		LLMCalls:         c.llmCalls,
+		PromptTokens:     c.promptTokens,
+		CompletionTokens: c.completionTokens,
+	}
+	inflight := c.started
+	c.reset()
+	c.started = inflight
+	c.mu.Unlock()
+
+	if eps := c.cfg.Epsilon; eps > 0 {
+		rep.Noised = true
+		sens := c.cfg.Sensitivity
+		for _, n := range []*int{&rep.RunsStarted, &rep.RunsCompleted, &rep.RunsFailed} {
+			*n = noise(*n, sens.Runs, eps)
+		}
+		rep.LLMCalls = noise(rep.LLMCalls, sens.LLMCalls, eps)
+		rep.PromptTokens = noise(rep.PromptTokens, sens.Tokens, eps)
+		rep.CompletionTokens = noise(rep.CompletionTokens, sens.Tokens, eps)
+		noiseCounters(rep.Agents, sens.Steps, eps)
+		noiseCounters(rep.Tools, sens.ToolCalls, eps)
+	}
+	return rep
+}
+
+// fold turns tallies into counters, merging those under the minimum count
+// into Other so rarely used names can't single out an installation's users.
+// Callers hold c.mu.
+func (c *Collector) fold(m map[string]*tally) map[string]Counter {
+	out := make(map[string]Counter)
+	var other tally
+	for name, t := range m {
+		if t.count < c.cfg.MinCount {
+			other.count, other.errors, other.took = other.count+t.count, other.errors+t.errors, other.took+t.took
+			continue
+		}
+		out[name] = t.counter()
+	}
+	if other.count > 0 {
+		out[Other] = other.counter()
+	}
+	return out
+}
+
+func (t *tally) counter() Counter {
+	ctr := Counter{Count: t.count, Errors: t.errors}
+	if t.count > 0 {
+		ctr.AvgMillis = (t.took / time.Duration(t.count)).Milliseconds()
+	}
+	return ctr
+}
+
+// noise adds Laplace noise of scale sensitivity/epsilon to n, keeping it a
+// count.
+func noise(n, sensitivity int, epsilon float64) int {
+	u := mrand.Float64() - 0.5
+	sign := 1.0
+	if u < 0 {
+		sign = -1
+	}
+	scale := float64(sensitivity) / epsilon
+	v := float64(n) - sign*scale*math.Log(1-2*math.Abs(u))
+	return max(0, int(math.Round(v)))
+}
+
+// noiseCounters noises the counts of m, each of the given sensitivity.
+func noiseCounters(m map[string]Counter, sensitivity int, epsilon float64) {
+	for name, ctr := range m {
+		ctr.Count, ctr.Errors = noise(ctr.Count, sensitivity, epsilon), noise(ctr.Errors, sensitivity, epsilon)
+		m[name] = ctr
+	}
+}
+
+// Send posts a report to the endpoint.
+func (c *Collector) Send(ctx context.Context, rep Report) error {
+	data, err := json.Marshal(rep)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.cfg.Endpoint, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Run reports on the configured interval until ctx is done. A report that
+// can't be sent is dropped rather than kept for later.
+func (c *Collector) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		rep := c.Snapshot()
+		if err := c.Send(ctx, rep); err != nil {
+			core.Logger().Warn().Err(err).Msg("Failed to send telemetry report")
+		}
+	}
+}
+
+// installationID reads the ID at path, creating a random one first.
+func installationID(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err == nil && len(strings.TrimSpace(string(data))) > 0 {
+		return strings.TrimSpace(string(data)), nil
+	}
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to read telemetry ID: %w", err)
+	}
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", fmt.Errorf("failed to store telemetry ID: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(id+"\n"), 0o600); err != nil {
+		return "", fmt.Errorf("failed to store telemetry ID: %w", err)
+	}
+	return id, nil
+}
+
+type counted struct {
+	core.ModelProvider
+	c *Collector
+}
+
+// Unwrap returns the provider wrapped, for the LLM cache to see through.
+func (p *counted) Unwrap() core.ModelProvider { return p.ModelProvider }
+
+func (p *counted) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	resp, err := p.ModelProvider.Call(ctx, prompt)
+	if err == nil {
+		p.c.mu.Lock()
+		p.c.llmCalls++
+		p.c.promptTokens += resp.Usage.PromptTokens
+		p.c.completionTokens += resp.Usage.CompletionTokens
+		p.c.mu.Unlock()
+	}
+	return resp, err
+}
+
+func (p *counted) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	in, err := p.ModelProvider.Stream(ctx, prompt)
+	if err == nil {
+		p.c.mu.Lock()
+		p.c.llmCalls++ // streamed calls don't report token usage
+		p.c.mu.Unlock()
+	}
+	return in, err
+}
+
+type countedTool struct {
+	tools.Tool
+	c *Collector
+}
+
+// Unwrap returns the wrapped tool.
+func (t *countedTool) Unwrap() tools.Tool { return t.Tool }
+
+func (t *countedTool) Call(ctx context.Context, args map[string]any) (any, error) {
+	start := time.Now()
+	result, err := t.Tool.Call(ctx, args)
+	t.c.mu.Lock()
+	tl := t.c.tally(t.c.tools, t.Name())
+	tl.count++
+	tl.took += time.Since(start)
+	if err != nil {
+		tl.errors++
+	}
+	t.c.mu.Unlock()
+	return result, err
+}
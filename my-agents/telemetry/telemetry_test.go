@@ -0,0 +1,206 @@
+package telemetry
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/history"
+	"my-agents/tools"
+)
+
+type runner struct {
+	core.Runner
+	callbacks map[core.HookPoint]core.CallbackFunc
+}
+
+func (r *runner) RegisterCallback(hook core.HookPoint, name string, cb core.CallbackFunc) error {
+	r.callbacks[hook] = cb
+	return nil
+}
+
+type model struct{ core.ModelProvider }
+
+func (model) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	return core.Response{Content: "secret answer", Usage: core.UsageStats{PromptTokens: 30, CompletionTokens: 12}}, nil
+}
+
+type tool struct{ name string }
+
+func (t tool) Name() string        { return t.name }
+func (t tool) Description() string { return "" }
+
+func (t tool) Call(ctx context.Context, args map[string]any) (any, error) {
+	if t.name == "flaky" {
+		return nil, errors.New("down")
+	}
+	return "ok", nil
+}
+
+func newCollector(t *testing.T, cfg Config) *Collector {
+	t.Helper()
+	cfg.Endpoint = cmp.Or(cfg.Endpoint, "http://127.0.0.1:1/reports")
+	cfg.IDPath = filepath.Join(t.TempDir(), "telemetry_id")
+	c, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestNew(t *testing.T) {
+	for _, cfg := range []Config{{}, {Endpoint: "x", Epsilon: -1}, {Endpoint: "x", Sensitivity: Sensitivity{Tokens: -5}}} {
+		cfg.IDPath = filepath.Join(t.TempDir(), "id")
+		if _, err := New(cfg); err == nil {
+			t.Errorf("New(%+v) accepted", cfg)
+		}
+	}
+	c := newCollector(t, Config{})
+	if c.cfg.MinCount != 5 || c.cfg.Sensitivity != (Sensitivity{Runs: 1, Steps: 10, ToolCalls: 10, LLMCalls: 20, Tokens: 50000}) {
+		t.Errorf("defaults = %+v", c.cfg)
+	}
+}
+
+func TestInstallationID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dir", "telemetry_id")
+	id, err := installationID(path)
+	if err != nil || len(id) != 32 {
+		t.Fatalf("installationID = %q, %v", id, err)
+	}
+	if again, _ := installationID(path); again != id {
+		t.Errorf("second read = %q, want %q", again, id)
+	}
+	os.Remove(path)
+	if fresh, _ := installationID(path); fresh == id {
+		t.Error("deleting the file kept the ID")
+	}
+}
+
+func TestCollect(t *testing.T) {
+	c := newCollector(t, Config{MinCount: 2})
+	r := &runner{callbacks: make(map[core.HookPoint]core.CallbackFunc)}
+	if err := c.Register(r); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	step := func(agent string, event core.Event, next string, err error) {
+		state := core.NewState()
+		if next != "" {
+			state.SetMeta(core.RouteMetadataKey, next)
+		}
+		r.callbacks[core.HookBeforeAgentRun](ctx, core.CallbackArgs{AgentID: agent, Event: event})
+		r.callbacks[core.HookAfterAgentRun](ctx, core.CallbackArgs{AgentID: agent, Event: event, State: state, Error: err})
+	}
+	first := core.NewEvent("writer", nil, map[string]string{"user_id": "ann"})
+	step("writer", first, "reviewer", nil)
+	step("reviewer", core.NewEvent("reviewer", nil, map[string]string{history.RunIDKey: first.GetID()}), "", nil)
+	step("writer", core.NewEvent("writer", nil, nil), "", errors.New("boom"))
+
+	llm := c.Middleware()("writer", model{})
+	llm.Call(ctx, core.Prompt{User: "private question"})
+	lookup := c.ToolMiddleware()("writer", tool{name: "lookup"})
+	lookup.Call(ctx, nil)
+	lookup.Call(ctx, nil)
+	c.ToolMiddleware()("writer", tool{name: "flaky"}).Call(ctx, nil)
+
+	rep := c.Snapshot()
+	if rep.RunsStarted != 2 || rep.RunsCompleted != 1 || rep.RunsFailed != 1 {
+		t.Errorf("runs = %d started, %d completed, %d failed", rep.RunsStarted, rep.RunsCompleted, rep.RunsFailed)
+	}
+	if rep.Agents["writer"] != (Counter{Count: 2, Errors: 1}) || rep.Agents["reviewer"].Count != 0 || rep.Agents[Other].Count != 1 {
+		t.Errorf("agents = %+v, want reviewer folded into other", rep.Agents)
+	}
+	if rep.Tools["lookup"].Count != 2 || rep.Tools[Other] != (Counter{Count: 1, Errors: 1}) {
+		t.Errorf("tools = %+v", rep.Tools)
+	}
+	if rep.LLMCalls != 1 || rep.PromptTokens != 30 || rep.CompletionTokens != 12 || rep.Noised {
+		t.Errorf("report = %+v", rep)
+	}
+	data, _ := json.Marshal(rep)
+	for _, leak := range []string{"ann", "private", "secret", first.GetID()} {
+		if strings.Contains(string(data), leak) {
+			t.Errorf("report carries %q: %s", leak, data)
+		}
+	}
+	if next := c.Snapshot(); next.RunsStarted != 0 || len(next.Agents) != 0 {
+		t.Errorf("snapshot didn't start a new interval: %+v", next)
+	}
+}
+
+func TestNoise(t *testing.T) {
+	const n, trials = 100, 4000
+	sum, negative := 0, false
+	for i := 0; i < trials; i++ {
+		v := noise(n, 10, 1)
+		sum += v
+		negative = negative || v < 0
+	}
+	if negative {
+		t.Error("noise produced a negative count")
+	}
+	// Laplace noise of scale 10 has mean 0 and a standard deviation of
+	// about 14, so the average of 4000 draws is within 2 of n
+	if mean := float64(sum) / trials; math.Abs(mean-n) > 2 {
+		t.Errorf("mean = %.1f, want about %d", mean, n)
+	}
+
+	c := newCollector(t, Config{Epsilon: 0.5})
+	if rep := c.Snapshot(); !rep.Noised {
+		t.Error("report with epsilon not marked noised")
+	}
+}
+
+func TestSend(t *testing.T) {
+	var got Report
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer srv.Close()
+	c := newCollector(t, Config{Endpoint: srv.URL})
+	if err := c.Send(context.Background(), Report{Installation: c.id, LLMCalls: 3}); err != nil {
+		t.Fatal(err)
+	}
+	if got.Installation != c.id || got.LLMCalls != 3 {
+		t.Errorf("endpoint received %+v", got)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer failing.Close()
+	c = newCollector(t, Config{Endpoint: failing.URL})
+	if err := c.Send(context.Background(), Report{}); err == nil {
+		t.Error("Send hid the endpoint's error")
+	}
+}
+
+func TestCountedToolUnwraps(t *testing.T) {
+	c := newCollector(t, Config{})
+	lookup := tool{name: "lookup"}
+	u, ok := c.ToolMiddleware()("writer", lookup).(interface{ Unwrap() tools.Tool })
+	if !ok || u.Unwrap() != tools.Tool(lookup) {
+		t.Error("counted tool does not unwrap to the tool it wraps")
+	}
+}
+
+func TestCountedProviderUnwraps(t *testing.T) {
+	c := newCollector(t, Config{})
+	u, ok := c.Middleware()("writer", model{}).(interface{ Unwrap() core.ModelProvider })
+	if !ok || u.Unwrap() != core.ModelProvider(model{}) {
+		t.Error("counted provider does not unwrap to the one it wraps")
+	}
+}
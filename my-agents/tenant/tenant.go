@@ -0,0 +1,33 @@
+// Package tenant identifies the tenant a request belongs to.
+package tenant
+
+import (
+	"context"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// MetadataKey is the event metadata key carrying the tenant ID.
+const MetadataKey = "tenant_id"
+
+// FromEvent returns the event's tenant ID, or "" for untenanted requests.
+// On the HTTP API it is the authenticated principal's, which callers can't
+// set themselves.
+func FromEvent(event core.Event) string {
+	id, _ := event.GetMetadataValue(MetadataKey)
+	return id
+}
+
+type contextKey struct{}
+
+// NewContext returns ctx carrying the tenant ID, for the work an agent
+// does on a tenant's behalf.
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext returns the tenant ID ctx carries, or "".
+func FromContext(ctx context.Context) string {
+	id, _ := ctx.Value(contextKey{}).(string)
+	return id
+}
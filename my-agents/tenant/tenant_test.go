@@ -0,0 +1,26 @@
+package tenant
+
+import (
+	"context"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+func TestFromEvent(t *testing.T) {
+	if got := FromEvent(core.NewEvent("a", nil, map[string]string{MetadataKey: "acme"})); got != "acme" {
+		t.Errorf("FromEvent = %q", got)
+	}
+	if got := FromEvent(core.NewEvent("a", nil, nil)); got != "" {
+		t.Errorf("FromEvent of an untenanted event = %q", got)
+	}
+}
+
+func TestContext(t *testing.T) {
+	if got := FromContext(NewContext(context.Background(), "acme")); got != "acme" {
+		t.Errorf("FromContext = %q", got)
+	}
+	if got := FromContext(context.Background()); got != "" {
+		t.Errorf("FromContext without a tenant = %q", got)
+	}
+}
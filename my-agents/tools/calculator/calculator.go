@@ -0,0 +1,257 @@
+// Package calculator is a function tool evaluating arithmetic, so agents
+// don't trust the model to do sums in its head.
+package calculator
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Name, Description and Parameters register the tool with a tools.Registry.
+const (
+	Name        = "calculator"
+	Description = "Evaluate an arithmetic expression: + - * / % ^, parentheses, pi, e and sqrt, abs, round, floor, ceil, ln, log10, min, max."
+	Parameters  = `{"type":"object","required":["expression"],"additionalProperties":false,"properties":{"expression":{"type":"string","minLength":1,"description":"e.g. (1200 * 0.15) / 12"}}}`
+)
+
+// Call evaluates args["expression"].
+func Call(ctx context.Context, args map[string]any) (any, error) {
+	expr, _ := args["expression"].(string)
+	v, err := Eval(expr)
+	if err != nil {
+		return nil, err
+	}
+	return strconv.FormatFloat(v, 'g', 15, 64), nil
+}
+
+// Eval evaluates an arithmetic expression. ^ binds tightest and is right
+// associative; % is the floating-point remainder.
+func Eval(expr string) (float64, error) {
+	p := &parser{src: expr}
+	p.next()
+	v, err := p.expr()
+	if err != nil {
+		return 0, err
+	}
+	if p.tok != "" {
+		return 0, fmt.Errorf("unexpected %q at %d", p.tok, p.pos)
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("result is not a finite number")
+	}
+	return v, nil
+}
+
+// parser is a recursive-descent parser over single-token lookahead.
+type parser struct {
+	src string
+	off int    // offset after tok
+	pos int    // offset of tok
+	tok string // "" at the end
+}
+
+func (p *parser) next() {
+	for p.off < len(p.src) && unicode.IsSpace(rune(p.src[p.off])) {
+		p.off++
+	}
+	p.pos = p.off
+	if p.off == len(p.src) {
+		p.tok = ""
+		return
+	}
+	c := rune(p.src[p.off])
+	end := p.off + 1
+	switch {
+	case unicode.IsDigit(c) || c == '.':
+		for end < len(p.src) && (unicode.IsDigit(rune(p.src[end])) || p.src[end] == '.') {
+			end++
+		}
+		// exponent, e.g. 1e-3
+		if end < len(p.src) && (p.src[end] == 'e' || p.src[end] == 'E') {
+			e := end + 1
+			if e < len(p.src) && (p.src[e] == '+' || p.src[e] == '-') {
+				e++
+			}
+			if e < len(p.src) && unicode.IsDigit(rune(p.src[e])) {
+				for end = e; end < len(p.src) && unicode.IsDigit(rune(p.src[end])); end++ {
+				}
+			}
+		}
+	case unicode.IsLetter(c):
+		for end < len(p.src) && (unicode.IsLetter(rune(p.src[end])) || unicode.IsDigit(rune(p.src[end]))) {
+			end++
+		}
+	}
+	p.tok, p.off = p.src[p.off:end], end
+}
+
+// expr := term {("+" | "-") term}
+func (p *parser) expr() (float64, error) {
+	v, err := p.term()
+	for err == nil && (p.tok == "+" || p.tok == "-") {
+		op := p.tok
+		p.next()
+		var r float64
+		if r, err = p.term(); op == "+" {
+			v += r
+		} else {
+			v -= r
+		}
+	}
+	return v, err
+}
+
+// term := unary {("*" | "/" | "%") unary}
+func (p *parser) term() (float64, error) {
+	v, err := p.unary()
+	for err == nil && (p.tok == "*" || p.tok == "/" || p.tok == "%") {
+		op := p.tok
+		p.next()
+		var r float64
+		if r, err = p.unary(); err != nil {
+			break
+		}
+		switch {
+		case op == "*":
+			v *= r
+		case r == 0:
+			return 0, fmt.Errorf("division by zero")
+		case op == "/":
+			v /= r
+		default:
+			v = math.Mod(v, r)
+		}
+	}
+	return v, err
+}
+
+// unary := ("-" | "+") unary | power
+func (p *parser) unary() (float64, error) {
+	switch p.tok {
+	case "-":
+		p.next()
+		v, err := p.unary()
+		return -v, err
+	case "+":
+		p.next()
+		return p.unary()
+	}
+	return p.power()
+}
+
+// power := primary ["^" unary]
+func (p *parser) power() (float64, error) {
+	v, err := p.primary()
+	if err != nil || p.tok != "^" {
+		return v, err
+	}
+	p.next()
+	exp, err := p.unary()
+	return math.Pow(v, exp), err
+}
+
+// primary := number | constant | function "(" args ")" | "(" expr ")"
+func (p *parser) primary() (float64, error) {
+	tok, pos := p.tok, p.pos
+	switch {
+	case tok == "":
+		return 0, fmt.Errorf("unexpected end of expression")
+	case tok == "(":
+		p.next()
+		v, err := p.expr()
+		if err != nil {
+			return 0, err
+		}
+		if p.tok != ")" {
+			return 0, fmt.Errorf("missing ) for ( at %d", pos)
+		}
+		p.next()
+		return v, nil
+	case unicode.IsDigit(rune(tok[0])) || tok[0] == '.':
+		p.next()
+		v, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return 0, fmt.Errorf("bad number %q at %d", tok, pos)
+		}
+		return v, nil
+	case unicode.IsLetter(rune(tok[0])):
+		p.next()
+		name := strings.ToLower(tok)
+		switch name {
+		case "pi":
+			return math.Pi, nil
+		case "e":
+			return math.E, nil
+		}
+		return p.call(name, pos)
+	}
+	return 0, fmt.Errorf("unexpected %q at %d", tok, pos)
+}
+
+var functions = map[string]func(args []float64) (float64, error){
+	"sqrt":  unary(math.Sqrt),
+	"abs":   unary(math.Abs),
+	"round": unary(math.Round),
+	"floor": unary(math.Floor),
+	"ceil":  unary(math.Ceil),
+	"ln":    unary(math.Log),
+	"log10": unary(math.Log10),
+	"min":   variadic(math.Min),
+	"max":   variadic(math.Max),
+}
+
+func unary(f func(float64) float64) func([]float64) (float64, error) {
+	return func(args []float64) (float64, error) {
+		if len(args) != 1 {
+			return 0, fmt.Errorf("takes one argument")
+		}
+		return f(args[0]), nil
+	}
+}
+
+func variadic(f func(a, b float64) float64) func([]float64) (float64, error) {
+	return func(args []float64) (float64, error) {
+		if len(args) == 0 {
+			return 0, fmt.Errorf("takes at least one argument")
+		}
+		v := args[0]
+		for _, a := range args[1:] {
+			v = f(v, a)
+		}
+		return v, nil
+	}
+}
+
+func (p *parser) call(name string, pos int) (float64, error) {
+	fn, ok := functions[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown name %q at %d", name, pos)
+	}
+	if p.tok != "(" {
+		return 0, fmt.Errorf("%s needs arguments in parentheses", name)
+	}
+	p.next()
+	var args []float64
+	for p.tok != ")" {
+		v, err := p.expr()
+		if err != nil {
+			return 0, err
+		}
+		args = append(args, v)
+		if p.tok == "," {
+			p.next()
+		} else if p.tok != ")" {
+			return 0, fmt.Errorf("expected , or ) in %s at %d", name, p.pos)
+		}
+	}
+	p.next()
+	v, err := fn(args)
+	if err != nil {
+		return 0, fmt.Errorf("%s %w", name, err)
+	}
+	return v, nil
+}
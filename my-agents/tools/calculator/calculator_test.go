@@ -0,0 +1,77 @@
+package calculator
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	tests := []struct {
+		expr string
+		want float64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1200 * 0.15) / 12", 15},
+		{"2^3^2", 512},
+		{"-2^2", -4},
+		{"2^-1", 0.5},
+		{"7 % 3", 1},
+		{"10 - 4 - 3", 3},
+		{"+-3", -3},
+		{"1e-3 * 1000", 1},
+		{".5 + 1E2", 100.5},
+		{"sqrt(16) + abs(-2)", 6},
+		{"round(2.5) + floor(1.9) + ceil(1.1)", 6},
+		{"min(3, 1, 2) + max(4)", 5},
+		{"ln(e) + log10(100)", 3},
+		{"PI / pi", 1},
+	}
+	for _, tt := range tests {
+		got, err := Eval(tt.expr)
+		if err != nil {
+			t.Errorf("Eval(%q): %v", tt.expr, err)
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("Eval(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestEvalErrors(t *testing.T) {
+	tests := []struct{ expr, err string }{
+		{"", "unexpected end of expression"},
+		{"1 +", "unexpected end of expression"},
+		{"1 2", `unexpected "2" at 2`},
+		{"(1 + 2", "missing ) for ( at 0"},
+		{"1 / 0", "division by zero"},
+		{"5 % (1 - 1)", "division by zero"},
+		{"1..2", `bad number "1..2" at 0`},
+		{"x + 1", `unknown name "x" at 0`},
+		{"sqrt 4", "sqrt needs arguments in parentheses"},
+		{"sqrt(1, 2)", "sqrt takes one argument"},
+		{"max()", "max takes at least one argument"},
+		{"min(1 2)", "expected , or ) in min at 6"},
+		{"sqrt(-1)", "result is not a finite number"},
+		{"2 * #", `unexpected "#" at 4`},
+	}
+	for _, tt := range tests {
+		if _, err := Eval(tt.expr); err == nil || err.Error() != tt.err {
+			t.Errorf("Eval(%q) error = %v, want %s", tt.expr, err, tt.err)
+		}
+	}
+}
+
+func TestCall(t *testing.T) {
+	out, err := Call(context.Background(), map[string]any{"expression": "1 / 3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "0.333333333333333" {
+		t.Errorf("Call = %v", out)
+	}
+	if _, err := Call(context.Background(), map[string]any{}); err == nil {
+		t.Error("missing expression accepted")
+	}
+}
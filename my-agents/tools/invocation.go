@@ -0,0 +1,76 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// ResultsKey is the state key holding the []Invocation an agent recorded.
+const ResultsKey = "tool_results"
+
+// Invocation records one tool call for rendering and auditing.
+type Invocation struct {
+	Tool     string         `json:"tool"`
+	Args     map[string]any `json:"args,omitempty"`
+	Result   any            `json:"result,omitempty"`
+	Sources  []string       `json:"sources,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Duration time.Duration  `json:"duration"`
+}
+
+// Sourced is implemented by tool results that cite where their data came from.
+type Sourced interface {
+	Sources() []string
+}
+
+// Invoke calls tool and returns its result along with the invocation record.
+// Arguments that don't match the tool's parameter schema fail the call
+// without running the tool.
+func Invoke(ctx context.Context, tool Tool, args map[string]any) (any, Invocation, error) {
+	start := time.Now()
+	var result any
+	err := validate(tool, args)
+	if err == nil {
+		result, err = tool.Call(ctx, args)
+	}
+	inv := Invocation{Tool: tool.Name(), Args: args, Result: result, Duration: time.Since(start)}
+	if err != nil {
+		inv.Error = err.Error()
+	}
+	if s, ok := result.(Sourced); ok {
+		inv.Sources = s.Sources()
+	}
+	return result, inv, err
+}
+
+// Record appends invocations to the list stored in state.
+func Record(state core.State, invs ...Invocation) {
+	if len(invs) == 0 {
+		return
+	}
+	state.Set(ResultsKey, append(Recorded(state), invs...))
+}
+
+// Recorded returns the invocations stored in state.
+func Recorded(state core.State) []Invocation {
+	v, ok := state.Get(ResultsKey)
+	if !ok {
+		return nil
+	}
+	if invs, ok := v.([]Invocation); ok {
+		return invs
+	}
+	// Runs resumed from history carry the JSON-decoded form
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var invs []Invocation
+	if err := json.Unmarshal(data, &invs); err != nil {
+		return nil
+	}
+	return invs
+}
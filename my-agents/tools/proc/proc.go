@@ -0,0 +1,78 @@
+// Package proc runs the external programs tools depend on (tesseract,
+// poppler, ...) the same way on every platform: found on PATH or in the
+// platform's usual install directories, isolated in their own process
+// group, and killed together with their children when the call's context
+// ends.
+package proc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// waitDelay bounds how long Run waits for output pipes after the process
+// is killed, in case a grandchild still holds them open.
+const waitDelay = 5 * time.Second
+
+// LookPath finds name on PATH, then in the platform's usual install
+// directories for the tools in use. Paths containing a separator are
+// returned as given.
+func LookPath(name string) (string, error) {
+	if strings.ContainsAny(name, `/\`) {
+		return name, nil
+	}
+	path, err := exec.LookPath(name)
+	if err == nil {
+		return path, nil
+	}
+	for _, dir := range searchDirs() {
+		if candidate, lerr := exec.LookPath(filepath.Join(dir, name)); lerr == nil {
+			return candidate, nil
+		}
+	}
+	return "", err
+}
+
+// Command prepares name with args to run the way Run does, for programs
+// that keep running and are talked to through their pipes. ctx ending kills
+// it and everything it spawned.
+func Command(ctx context.Context, name string, args ...string) (*exec.Cmd, error) {
+	path, err := LookPath(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.WaitDelay = waitDelay
+	configure(cmd)
+	return cmd, nil
+}
+
+// Run runs name with args and returns its standard output. The error
+// includes the program's standard error.
+func Run(ctx context.Context, name string, args ...string) (string, error) {
+	path, err := LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	cmd.WaitDelay = waitDelay
+	configure(cmd)
+	if err := cmd.Run(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+			err = fmt.Errorf("%w (%v)", ctxErr, err)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return stdout.String(), nil
+}
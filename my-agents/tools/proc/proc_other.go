@@ -0,0 +1,11 @@
+//go:build !unix && !windows
+
+package proc
+
+import "os/exec"
+
+// configure leaves the defaults on platforms without process groups:
+// cancelling kills only the direct child.
+func configure(cmd *exec.Cmd) {}
+
+func searchDirs() []string { return nil }
@@ -0,0 +1,81 @@
+package proc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func shell(t *testing.T) {
+	t.Helper()
+	if _, err := LookPath("sh"); err != nil {
+		t.Skip("no sh on this platform")
+	}
+}
+
+func TestRun(t *testing.T) {
+	shell(t)
+	out, err := Run(context.Background(), "sh", "-c", "echo hello")
+	if err != nil || out != "hello\n" {
+		t.Errorf("Run = %q, %v", out, err)
+	}
+	_, err = Run(context.Background(), "sh", "-c", "echo broken input >&2; exit 3")
+	if err == nil || !strings.Contains(err.Error(), "broken input") {
+		t.Errorf("error = %v, want the program's stderr", err)
+	}
+}
+
+func TestRunKillsChildren(t *testing.T) {
+	shell(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	// The background sleep holds stdout open; it must be killed with sh
+	_, err := Run(ctx, "sh", "-c", "sleep 30 & wait")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("error = %v, want the deadline", err)
+	}
+	if took := time.Since(start); took > waitDelay {
+		t.Errorf("Run returned after %v; the child outlived its parent", took)
+	}
+}
+
+func TestLookPath(t *testing.T) {
+	if got, err := LookPath("./bin/tool"); err != nil || got != "./bin/tool" {
+		t.Errorf("LookPath with a separator = %q, %v", got, err)
+	}
+	if _, err := LookPath("no-such-program-xyz"); err == nil {
+		t.Error("LookPath found a missing program")
+	}
+	if _, err := Run(context.Background(), "no-such-program-xyz"); err == nil || !strings.HasPrefix(err.Error(), "no-such-program-xyz:") {
+		t.Errorf("Run of a missing program = %v", err)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	shell(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd, err := Command(ctx, "sh", "-c", "read line; echo got $line")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd.Stdin = strings.NewReader("ping\n")
+	out, err := cmd.Output()
+	if err != nil || string(out) != "got ping\n" {
+		t.Errorf("output = %q, %v", out, err)
+	}
+
+	cmd, _ = Command(ctx, "sh", "-c", "sleep 30")
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := cmd.Wait(); err == nil {
+		t.Error("cancelled command exited cleanly")
+	}
+	if _, err := Command(ctx, "no-such-program-xyz"); err == nil {
+		t.Error("Command found a missing program")
+	}
+}
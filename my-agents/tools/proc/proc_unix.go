@@ -0,0 +1,27 @@
+//go:build unix
+
+package proc
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// configure starts the program in its own process group so cancelling
+// kills everything it spawned, not just the direct child.
+func configure(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != syscall.ESRCH {
+			return err
+		}
+		return os.ErrProcessDone
+	}
+}
+
+// searchDirs are install locations often missing from a service's PATH,
+// e.g. Homebrew on Apple silicon.
+func searchDirs() []string {
+	return []string{"/usr/local/bin", "/opt/homebrew/bin", "/opt/local/bin", "/usr/bin"}
+}
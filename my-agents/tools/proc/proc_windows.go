@@ -0,0 +1,45 @@
+//go:build windows
+
+package proc
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// configure starts the program in its own process group without a console
+// window. Process.Kill only ends the direct child, so cancelling kills the
+// whole tree with taskkill.
+func configure(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{HideWindow: true, CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP}
+	cmd.Cancel = func() error {
+		if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+			return cmd.Process.Kill()
+		}
+		return nil
+	}
+}
+
+// searchDirs are where the Windows installers and package managers put the
+// tools; unlike on Unix they rarely add themselves to PATH.
+func searchDirs() []string {
+	var dirs []string
+	for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)"} {
+		if root := os.Getenv(env); root != "" {
+			dirs = append(dirs, filepath.Join(root, "Tesseract-OCR"), filepath.Join(root, "poppler", "Library", "bin"))
+		}
+	}
+	if root := os.Getenv("LOCALAPPDATA"); root != "" {
+		dirs = append(dirs, filepath.Join(root, "Programs", "Tesseract-OCR"))
+	}
+	if root := os.Getenv("ChocolateyInstall"); root != "" {
+		dirs = append(dirs, filepath.Join(root, "bin"))
+	}
+	if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, "scoop", "shims"))
+	}
+	return dirs
+}
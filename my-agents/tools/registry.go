@@ -0,0 +1,168 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"my-agents/schema"
+)
+
+// Parameterized is implemented by tools that declare their arguments as a
+// JSON Schema. Tool loops describe the schema to the model and Invoke
+// rejects arguments that don't match it.
+type Parameterized interface {
+	Parameters() *schema.Schema
+}
+
+// ParametersOf returns the parameter schema of tool, looking through
+// middleware that wraps it, or nil if it declares none.
+func ParametersOf(tool Tool) *schema.Schema {
+	for tool != nil {
+		if p, ok := tool.(Parameterized); ok {
+			return p.Parameters()
+		}
+		u, ok := tool.(interface{ Unwrap() Tool })
+		if !ok {
+			return nil
+		}
+		tool = u.Unwrap()
+	}
+	return nil
+}
+
+// FuncHandler implements a function tool.
+type FuncHandler func(ctx context.Context, args map[string]any) (any, error)
+
+// Func is a Go function registered as a tool.
+type Func struct {
+	name        string
+	description string
+	params      *schema.Schema
+	fn          FuncHandler
+}
+
+// NewFunc makes fn a tool. parameters is the JSON Schema of its arguments
+// object; empty accepts any.
+func NewFunc(name, description, parameters string, fn FuncHandler) (*Func, error) {
+	if name == "" || fn == nil {
+		return nil, fmt.Errorf("tool needs a name and a function")
+	}
+	f := &Func{name: name, description: description, fn: fn}
+	if parameters != "" {
+		params, err := schema.Compile([]byte(parameters))
+		if err != nil {
+			return nil, fmt.Errorf("tool %s parameters: %w", name, err)
+		}
+		f.params = params
+	}
+	return f, nil
+}
+
+func (f *Func) Name() string               { return f.name }
+func (f *Func) Description() string        { return f.description }
+func (f *Func) Parameters() *schema.Schema { return f.params }
+
+func (f *Func) Call(ctx context.Context, args map[string]any) (any, error) {
+	return f.fn(ctx, args)
+}
+
+// Registry holds the tools agents can be wired with, by name.
+type Registry struct {
+	mu    sync.RWMutex
+	tools map[string]Tool
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{tools: make(map[string]Tool)}
+}
+
+// Add registers tool under its name, replacing any tool of that name.
+func (r *Registry) Add(tool Tool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tools[tool.Name()] = tool
+}
+
+// Register adds fn as a tool; see NewFunc.
+func (r *Registry) Register(name, description, parameters string, fn FuncHandler) error {
+	f, err := NewFunc(name, description, parameters, fn)
+	if err != nil {
+		return err
+	}
+	r.Add(f)
+	return nil
+}
+
+// Get returns the named tool.
+func (r *Registry) Get(name string) (Tool, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tool, ok := r.tools[name]
+	return tool, ok
+}
+
+// List returns the registered tools sorted by name.
+func (r *Registry) List() []Tool {
+	r.mu.RLock()
+	list := make([]Tool, 0, len(r.tools))
+	for _, tool := range r.tools {
+		list = append(list, tool)
+	}
+	r.mu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].Name() < list[j].Name() })
+	return list
+}
+
+// Handler lists the registered tools, for mounting on the admin API under
+// "GET /admin/tools".
+func (r *Registry) Handler() http.Handler {
+	type entry struct {
+		Name        string          `json:"name"`
+		Description string          `json:"description"`
+		Parameters  json.RawMessage `json:"parameters,omitempty"`
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /admin/tools", func(w http.ResponseWriter, req *http.Request) {
+		entries := []entry{}
+		for _, tool := range r.List() {
+			e := entry{Name: tool.Name(), Description: tool.Description()}
+			if params := ParametersOf(tool); params != nil {
+				e.Parameters = json.RawMessage(params.String())
+			}
+			entries = append(entries, e)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{"tools": entries})
+	})
+	return mux
+}
+
+// validate checks args against the tool's parameter schema.
+func validate(tool Tool, args map[string]any) error {
+	params := ParametersOf(tool)
+	if params == nil {
+		return nil
+	}
+	if args == nil {
+		args = map[string]any{}
+	}
+	// Compare the arguments in their JSON form, as the schema describes them
+	data, err := json.Marshal(args)
+	if err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("invalid arguments: %w", err)
+	}
+	if problems := params.Validate(v); len(problems) > 0 {
+		return fmt.Errorf("invalid arguments: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
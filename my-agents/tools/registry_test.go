@@ -0,0 +1,83 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const cityParams = `{"type": "object", "properties": {"city": {"type": "string"}}, "required": ["city"]}`
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	if err := r.Register("weather", "current weather", cityParams, func(ctx context.Context, args map[string]any) (any, error) {
+		return "sunny in " + args["city"].(string), nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	r.Add(stub{result: 1})
+	if err := r.Register("", "", "", nil); err == nil {
+		t.Error("a nameless tool was registered")
+	}
+	if err := r.Register("bad", "", "{", func(context.Context, map[string]any) (any, error) { return nil, nil }); err == nil {
+		t.Error("invalid parameters were accepted")
+	}
+
+	list := r.List()
+	if len(list) != 2 || list[0].Name() != "lookup" || list[1].Name() != "weather" {
+		t.Errorf("List = %v", list)
+	}
+	tool, ok := r.Get("weather")
+	if !ok {
+		t.Fatal("the registered tool is missing")
+	}
+	if result, _, err := Invoke(context.Background(), tool, map[string]any{"city": "Oslo"}); err != nil || result != "sunny in Oslo" {
+		t.Errorf("Invoke = %v, %v", result, err)
+	}
+	if _, inv, err := Invoke(context.Background(), tool, map[string]any{"city": 3}); err == nil || !strings.Contains(inv.Error, "invalid arguments") {
+		t.Errorf("Invoke with a bad argument = %v; the tool shouldn't run", err)
+	}
+	if _, _, err := Invoke(context.Background(), tool, nil); err == nil {
+		t.Error("a missing required argument was accepted")
+	}
+}
+
+// wrapper is middleware around a tool.
+type wrapper struct{ Tool }
+
+func (w wrapper) Unwrap() Tool { return w.Tool }
+
+func TestParametersOfLooksThroughWrappers(t *testing.T) {
+	f, err := NewFunc("weather", "", cityParams, func(context.Context, map[string]any) (any, error) { return nil, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ParametersOf(wrapper{wrapper{f}}) == nil {
+		t.Error("the wrapped tool's parameters weren't found")
+	}
+	if ParametersOf(stub{}) != nil {
+		t.Error("a tool without parameters has some")
+	}
+}
+
+func TestHandlerListsTools(t *testing.T) {
+	r := NewRegistry()
+	r.Register("weather", "current weather", cityParams, func(context.Context, map[string]any) (any, error) { return nil, nil })
+	w := httptest.NewRecorder()
+	r.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/tools", nil))
+	var body struct {
+		Tools []struct {
+			Name       string          `json:"name"`
+			Parameters json.RawMessage `json:"parameters"`
+		} `json:"tools"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	if len(body.Tools) != 1 || body.Tools[0].Name != "weather" || !strings.Contains(string(body.Tools[0].Parameters), "city") {
+		t.Errorf("GET /admin/tools = %+v", body)
+	}
+}
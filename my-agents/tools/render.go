@@ -0,0 +1,122 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxTableRows bounds how many result rows are rendered inline.
+const maxTableRows = 20
+
+// RenderMarkdown renders invocations as collapsible Markdown sections showing
+// the query, the result (as a table when it is a list of records) and any
+// sources. Expanded sections are open by default.
+func RenderMarkdown(invs []Invocation, expanded bool) string {
+	if len(invs) == 0 {
+		return ""
+	}
+	open := ""
+	if expanded {
+		open = " open"
+	}
+
+	var b strings.Builder
+	b.WriteString("---\n\n**Tools used**\n\n")
+	for _, inv := range invs {
+		fmt.Fprintf(&b, "<details%s><summary>🔧 %s</summary>\n\n", open, inv.Tool)
+		if len(inv.Args) > 0 {
+			fmt.Fprintf(&b, "**Query:**\n\n```json\n%s\n```\n\n", toJSON(inv.Args))
+		}
+		if inv.Error != "" {
+			fmt.Fprintf(&b, "**Error:** %s\n\n", inv.Error)
+		} else if table := renderTable(inv.Result); table != "" {
+			b.WriteString("**Result:**\n\n" + table + "\n")
+		} else if inv.Result != nil {
+			fmt.Fprintf(&b, "**Result:**\n\n```\n%s\n```\n\n", resultText(inv.Result))
+		}
+		if len(inv.Sources) > 0 {
+			b.WriteString("**Sources:**\n\n")
+			for _, src := range inv.Sources {
+				fmt.Fprintf(&b, "- %s\n", src)
+			}
+			b.WriteString("\n")
+		}
+		b.WriteString("</details>\n\n")
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// renderTable renders a list of records as a Markdown table, or "" when the
+// result isn't tabular.
+func renderTable(result any) string {
+	rows := records(result)
+	if len(rows) == 0 {
+		return ""
+	}
+
+	colSet := make(map[string]bool)
+	for _, row := range rows {
+		for k := range row {
+			colSet[k] = true
+		}
+	}
+	cols := make([]string, 0, len(colSet))
+	for k := range colSet {
+		cols = append(cols, k)
+	}
+	sort.Strings(cols)
+
+	var b strings.Builder
+	b.WriteString("| " + strings.Join(cols, " | ") + " |\n")
+	b.WriteString("|" + strings.Repeat(" --- |", len(cols)) + "\n")
+	for i, row := range rows {
+		if i == maxTableRows {
+			fmt.Fprintf(&b, "\n_…%d more rows_\n", len(rows)-maxTableRows)
+			break
+		}
+		cells := make([]string, len(cols))
+		for j, c := range cols {
+			cells[j] = strings.ReplaceAll(fmt.Sprint(row[c]), "|", `\|`)
+			if row[c] == nil {
+				cells[j] = ""
+			}
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	return b.String()
+}
+
+func records(result any) []map[string]any {
+	switch v := result.(type) {
+	case []map[string]any:
+		return v
+	case []any:
+		rows := make([]map[string]any, 0, len(v))
+		for _, item := range v {
+			m, ok := item.(map[string]any)
+			if !ok {
+				return nil
+			}
+			rows = append(rows, m)
+		}
+		return rows
+	}
+	return nil
+}
+
+func resultText(result any) string {
+	if s, ok := result.(string); ok {
+		return s
+	}
+	return toJSON(result)
+}
+
+func toJSON(v any) string {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
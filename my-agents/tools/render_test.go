@@ -0,0 +1,87 @@
+package tools
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// stub is a tool returning result, or failing with err.
+type stub struct {
+	result any
+	err    error
+}
+
+func (s stub) Name() string        { return "lookup" }
+func (s stub) Description() string { return "looks things up" }
+func (s stub) Call(ctx context.Context, args map[string]any) (any, error) {
+	return s.result, s.err
+}
+
+// cited is a result naming its sources.
+type cited string
+
+func (c cited) Sources() []string { return []string{"https://example.com/" + string(c)} }
+
+func TestInvokeRecordsTheCall(t *testing.T) {
+	result, inv, err := Invoke(context.Background(), stub{result: cited("a")}, map[string]any{"q": "x"})
+	if err != nil || result != cited("a") {
+		t.Fatalf("Invoke = %v, %v", result, err)
+	}
+	if inv.Tool != "lookup" || inv.Args["q"] != "x" || len(inv.Sources) != 1 || inv.Error != "" {
+		t.Errorf("invocation = %+v", inv)
+	}
+	_, inv, err = Invoke(context.Background(), stub{err: errors.New("timeout")}, nil)
+	if err == nil || inv.Error != "timeout" {
+		t.Errorf("failed invocation = %+v, %v", inv, err)
+	}
+}
+
+func TestRecorded(t *testing.T) {
+	state := core.NewState()
+	Record(state, Invocation{Tool: "a"})
+	Record(state, Invocation{Tool: "b"})
+	if got := Recorded(state); len(got) != 2 || got[1].Tool != "b" {
+		t.Errorf("Recorded = %+v", got)
+	}
+	// As decoded from a saved run
+	state.Set(ResultsKey, []any{map[string]any{"tool": "c", "duration": 5}})
+	if got := Recorded(state); len(got) != 1 || got[0].Tool != "c" {
+		t.Errorf("Recorded of decoded invocations = %+v", got)
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	if RenderMarkdown(nil, false) != "" {
+		t.Error("no invocations rendered something")
+	}
+	rows := make([]any, 25)
+	for i := range rows {
+		rows[i] = map[string]any{"city": "a|b", "temp": i}
+	}
+	got := RenderMarkdown([]Invocation{
+		{Tool: "weather", Args: map[string]any{"city": "Paris"}, Result: rows, Sources: []string{"met office"}},
+		{Tool: "stocks", Error: "market closed"},
+		{Tool: "echo", Result: "plain"},
+	}, true)
+	for _, want := range []string{
+		"<details open><summary>🔧 weather</summary>",
+		`"city": "Paris"`,
+		"| city | temp |",
+		`| a\|b | 0 |`,
+		"_…5 more rows_",
+		"- met office",
+		"**Error:** market closed",
+		"```\nplain\n```",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("rendered markdown lacks %q:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "| a\\|b | 20 |") {
+		t.Error("rendered more rows than the table holds")
+	}
+}
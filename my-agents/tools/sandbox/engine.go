@@ -0,0 +1,280 @@
+package sandbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// apiVersion is the Docker Engine API version requested; Podman serves the
+// same compatibility API.
+const apiVersion = "v1.41"
+
+// errNoImage is returned by create when the image is not present locally.
+var errNoImage = errors.New("image not found")
+
+// Engine talks to a Docker or Podman daemon over its HTTP API.
+type Engine struct {
+	client *http.Client
+	base   string // URL prefix including the API version
+}
+
+// NewEngine connects to the daemon at host: "unix:///path/to.sock" or
+// "tcp://host:port". An empty host uses DOCKER_HOST, then the runtime's
+// default socket.
+func NewEngine(runtime, host string) (*Engine, error) {
+	if host == "" {
+		host = os.Getenv("DOCKER_HOST")
+	}
+	if host == "" {
+		host = defaultHost(runtime)
+	}
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid container host %q: %w", host, err)
+	}
+	transport := &http.Transport{}
+	base := "http://engine/" + apiVersion
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "unix", socket)
+		}
+	case "tcp", "http":
+		base = "http://" + u.Host + "/" + apiVersion
+	default:
+		return nil, fmt.Errorf("unsupported container host %q (use unix:// or tcp://)", host)
+	}
+	return &Engine{client: &http.Client{Transport: transport}, base: base}, nil
+}
+
+func defaultHost(runtime string) string {
+	if runtime == RuntimePodman {
+		if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+			return "unix://" + filepath.Join(dir, "podman", "podman.sock")
+		}
+		return "unix:///run/podman/podman.sock"
+	}
+	return "unix:///var/run/docker.sock"
+}
+
+// createRequest is the subset of the container create body used.
+type createRequest struct {
+	Image      string            `json:"Image"`
+	Cmd        []string          `json:"Cmd,omitempty"`
+	Entrypoint []string          `json:"Entrypoint,omitempty"`
+	Env        []string          `json:"Env,omitempty"`
+	WorkingDir string            `json:"WorkingDir,omitempty"`
+	User       string            `json:"User,omitempty"`
+	Labels     map[string]string `json:"Labels,omitempty"`
+	HostConfig hostConfig        `json:"HostConfig"`
+}
+
+type hostConfig struct {
+	Binds          []string `json:"Binds,omitempty"`
+	NetworkMode    string   `json:"NetworkMode,omitempty"`
+	Memory         int64    `json:"Memory,omitempty"`
+	NanoCPUs       int64    `json:"NanoCpus,omitempty"`
+	PidsLimit      int64    `json:"PidsLimit,omitempty"`
+	ReadonlyRootfs bool     `json:"ReadonlyRootfs,omitempty"`
+	CapDrop        []string `json:"CapDrop,omitempty"`
+	SecurityOpt    []string `json:"SecurityOpt,omitempty"`
+}
+
+func (e *Engine) create(ctx context.Context, req createRequest) (string, error) {
+	var out struct {
+		ID string `json:"Id"`
+	}
+	status, err := e.do(ctx, http.MethodPost, "/containers/create", req, &out)
+	if status == http.StatusNotFound {
+		return "", errNoImage
+	}
+	if err != nil {
+		return "", err
+	}
+	return out.ID, nil
+}
+
+// pull fetches image, reading the progress stream to its end.
+func (e *Engine) pull(ctx context.Context, image string) error {
+	ref, tag := image, "latest"
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		ref, tag = image[:i], image[i+1:]
+	}
+	resp, err := e.request(ctx, http.MethodPost, "/images/create?"+url.Values{"fromImage": {ref}, "tag": {tag}}.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return err
+	}
+	// Failures after the stream starts arrive as {"error": "..."} messages
+	dec := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if err := dec.Decode(&msg); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if msg.Error != "" {
+			return errors.New(msg.Error)
+		}
+	}
+}
+
+func (e *Engine) start(ctx context.Context, id string) error {
+	_, err := e.do(ctx, http.MethodPost, "/containers/"+id+"/start", nil, nil)
+	return err
+}
+
+// wait blocks until the container exits and returns its exit code.
+func (e *Engine) wait(ctx context.Context, id string) (int, error) {
+	var out struct {
+		StatusCode int `json:"StatusCode"`
+		Error      *struct {
+			Message string `json:"Message"`
+		} `json:"Error"`
+	}
+	if _, err := e.do(ctx, http.MethodPost, "/containers/"+id+"/wait", nil, &out); err != nil {
+		return 0, err
+	}
+	if out.Error != nil && out.Error.Message != "" {
+		return out.StatusCode, errors.New(out.Error.Message)
+	}
+	return out.StatusCode, nil
+}
+
+// logs returns the container's stdout and stderr, each cut at limit bytes.
+func (e *Engine) logs(ctx context.Context, id string, limit int) (string, string, error) {
+	resp, err := e.request(ctx, http.MethodGet, "/containers/"+id+"/logs?stdout=1&stderr=1", nil)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return "", "", err
+	}
+	// Without a TTY the stream is multiplexed: an 8-byte header (stream
+	// type, 3 zero bytes, big-endian length) precedes each frame
+	var stdout, stderr bytes.Buffer
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(resp.Body, header); err == io.EOF {
+			break
+		} else if err != nil {
+			return "", "", err
+		}
+		dst := &stdout
+		if header[0] == 2 {
+			dst = &stderr
+		}
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		room := int64(limit - dst.Len())
+		if room < 0 {
+			room = 0
+		}
+		if _, err := io.CopyN(dst, resp.Body, min(size, room)); err != nil {
+			return "", "", err
+		}
+		if _, err := io.CopyN(io.Discard, resp.Body, size-min(size, room)); err != nil {
+			return "", "", err
+		}
+	}
+	return stdout.String(), stderr.String(), nil
+}
+
+// Ping checks that the daemon answers and returns its version.
+func (e *Engine) Ping(ctx context.Context) (string, error) {
+	var out struct {
+		Version string `json:"Version"`
+	}
+	if _, err := e.do(ctx, http.MethodGet, "/version", nil, &out); err != nil {
+		return "", err
+	}
+	return out.Version, nil
+}
+
+// hasImage reports whether image is present locally.
+func (e *Engine) hasImage(ctx context.Context, image string) (bool, error) {
+	status, err := e.do(ctx, http.MethodGet, "/images/"+image+"/json", nil, nil)
+	if status == http.StatusNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (e *Engine) remove(ctx context.Context, id string) error {
+	_, err := e.do(ctx, http.MethodDelete, "/containers/"+id+"?force=1", nil, nil)
+	return err
+}
+
+// do sends a JSON request and decodes a JSON response into out when set.
+func (e *Engine) do(ctx context.Context, method, path string, body, out any) (int, error) {
+	resp, err := e.request(ctx, method, path, body)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if err := checkStatus(resp); err != nil {
+		return resp.StatusCode, err
+	}
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode container API response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+func (e *Engine) request(ctx context.Context, method, path string, body any) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, e.base+path, r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("container engine: %w", err)
+	}
+	return resp, nil
+}
+
+func checkStatus(resp *http.Response) error {
+	if resp.StatusCode < 300 {
+		return nil
+	}
+	var msg struct {
+		Message string `json:"message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if json.Unmarshal(data, &msg) == nil && msg.Message != "" {
+		return fmt.Errorf("container engine: %s (HTTP %d)", msg.Message, resp.StatusCode)
+	}
+	return fmt.Errorf("container engine: HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+}
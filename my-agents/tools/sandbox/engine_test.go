@@ -0,0 +1,116 @@
+package sandbox
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// daemon fakes the container engine API: containers print stdout and
+// stderr and exit with code, and images must be pulled before use.
+type daemon struct {
+	mu      sync.Mutex
+	images  map[string]bool
+	created []createRequest
+	removed int
+	stdout  string
+	stderr  string
+	code    int
+}
+
+func (d *daemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	path := strings.TrimPrefix(r.URL.Path, "/"+apiVersion)
+	switch {
+	case path == "/version":
+		json.NewEncoder(w).Encode(map[string]string{"Version": "25.0"})
+	case path == "/images/create":
+		q := r.URL.Query()
+		d.images[q.Get("fromImage")+":"+q.Get("tag")] = true
+		w.Write([]byte(`{"status":"Pulling"}` + "\n" + `{"status":"Done"}`))
+	case strings.HasPrefix(path, "/images/"):
+		if !d.images[strings.TrimSuffix(strings.TrimPrefix(path, "/images/"), "/json")] {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"no such image"}`))
+		}
+	case path == "/containers/create":
+		var req createRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if !d.images[req.Image] {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message":"no such image"}`))
+			return
+		}
+		d.created = append(d.created, req)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"Id":"c1"}`))
+	case r.Method == http.MethodDelete:
+		d.removed++
+	case strings.HasSuffix(path, "/start"):
+		w.WriteHeader(http.StatusNoContent)
+	case strings.HasSuffix(path, "/wait"):
+		json.NewEncoder(w).Encode(map[string]int{"StatusCode": d.code})
+	case strings.HasSuffix(path, "/logs"):
+		frame(w, 1, d.stdout)
+		frame(w, 2, d.stderr)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// frame writes one frame of a multiplexed log stream.
+func frame(w http.ResponseWriter, stream byte, s string) {
+	if s == "" {
+		return
+	}
+	header := make([]byte, 8)
+	header[0] = stream
+	binary.BigEndian.PutUint32(header[4:], uint32(len(s)))
+	w.Write(header)
+	w.Write([]byte(s))
+}
+
+func newDaemon(t *testing.T) (*daemon, string) {
+	t.Helper()
+	d := &daemon{images: make(map[string]bool)}
+	srv := httptest.NewServer(d)
+	t.Cleanup(srv.Close)
+	return d, "tcp://" + strings.TrimPrefix(srv.URL, "http://")
+}
+
+func TestNewEngine(t *testing.T) {
+	for _, host := range []string{"unix:///var/run/docker.sock", "tcp://127.0.0.1:2375"} {
+		if _, err := NewEngine(RuntimeDocker, host); err != nil {
+			t.Errorf("NewEngine(%q): %v", host, err)
+		}
+	}
+	if _, err := NewEngine(RuntimeDocker, "ssh://host"); err == nil {
+		t.Error("NewEngine accepted an ssh host")
+	}
+	t.Setenv("XDG_RUNTIME_DIR", "/run/user/1000")
+	if got := defaultHost(RuntimePodman); got != "unix:///run/user/1000/podman/podman.sock" {
+		t.Errorf("podman socket = %q", got)
+	}
+}
+
+func TestLogs(t *testing.T) {
+	d, host := newDaemon(t)
+	d.stdout, d.stderr = "0123456789", "warning"
+	e, err := NewEngine(RuntimeDocker, host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr, err := e.logs(context.Background(), "c1", 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stdout != "0123" || stderr != "warn" {
+		t.Errorf("logs = %q, %q; want each cut at 4 bytes", stdout, stderr)
+	}
+}
@@ -0,0 +1,303 @@
+// Package sandbox runs tools declared as container images through the
+// Docker or Podman API: each call gets a fresh container with only the
+// mounts and network the tool declares, and its output is returned as the
+// tool result.
+package sandbox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Container runtimes.
+const (
+	RuntimeDocker = "docker"
+	RuntimePodman = "podman"
+)
+
+// maxOutput bounds the stdout and stderr kept from one call.
+const maxOutput = 1 << 20
+
+// Config is the [sandbox] section of agentflow.toml:
+//
+//	[sandbox]
+//	runtime = "podman"
+//	[sandbox.tools.pandoc]
+//	image = "pandoc/core:3.1"
+//	description = "Convert a workspace document. Arguments: file, to (e.g. markdown)."
+//	command = ["--to", "{to}", "/work/{file}"]
+//	mounts = ["./workspace:/work:ro"]
+type Config struct {
+	Runtime string          `toml:"runtime"` // RuntimeDocker (default) or RuntimePodman
+	Host    string          `toml:"host"`    // API endpoint; default DOCKER_HOST, then the runtime's socket
+	Tools   map[string]Spec `toml:"tools"`
+}
+
+// Spec declares one container tool. {arg} in Command, Entrypoint and Env
+// values is replaced by the call's argument; all arguments are also passed
+// as JSON in TOOL_ARGS. Arguments never reach a shell.
+type Spec struct {
+	Image       string            `toml:"image"`
+	Description string            `toml:"description"`
+	Command     []string          `toml:"command"`
+	Entrypoint  []string          `toml:"entrypoint"`
+	Env         map[string]string `toml:"env"`
+	Workdir     string            `toml:"workdir"`
+	User        string            `toml:"user"`
+	// Mounts are "host:container[:ro]" binds; relative host paths are
+	// resolved against the working directory.
+	Mounts []string `toml:"mounts"`
+	// Network is "none" (default), "bridge" or the name of a network.
+	Network  string  `toml:"network"`
+	ReadOnly bool    `toml:"read_only"` // read-only root filesystem
+	MemoryMB int     `toml:"memory_mb"`
+	CPUs     float64 `toml:"cpus"`
+	Timeout  string  `toml:"timeout"` // per call (default "5m")
+	// Pull is "missing" (default), "always" or "never".
+	Pull string `toml:"pull"`
+}
+
+// Result is the outcome of a container tool call.
+type Result struct {
+	ExitCode int    `json:"exit_code"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr,omitempty"`
+	// Output is stdout decoded, when the tool printed JSON.
+	Output any `json:"output,omitempty"`
+}
+
+// Tool runs a Spec as a tool.
+type Tool struct {
+	name    string
+	spec    Spec
+	engine  *Engine
+	timeout time.Duration
+}
+
+// New creates the tools declared in cfg, sorted by name.
+func New(cfg Config) ([]*Tool, error) {
+	if len(cfg.Tools) == 0 {
+		return nil, nil
+	}
+	engine, err := NewEngine(cfg.Runtime, cfg.Host)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(cfg.Tools))
+	for name := range cfg.Tools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	tools := make([]*Tool, 0, len(names))
+	for _, name := range names {
+		spec := cfg.Tools[name]
+		if spec.Image == "" {
+			return nil, fmt.Errorf("sandbox tool %s: image is required", name)
+		}
+		t := &Tool{name: name, spec: spec, engine: engine, timeout: 5 * time.Minute}
+		if spec.Timeout != "" {
+			d, err := time.ParseDuration(spec.Timeout)
+			if err != nil {
+				return nil, fmt.Errorf("sandbox tool %s: invalid timeout: %w", name, err)
+			}
+			t.timeout = d
+		}
+		tools = append(tools, t)
+	}
+	return tools, nil
+}
+
+func (t *Tool) Name() string { return t.name }
+
+// Check verifies the tool can run: the container engine answers and the
+// image is present, or can be pulled on first use.
+func (t *Tool) Check(ctx context.Context) (string, error) {
+	version, err := t.engine.Ping(ctx)
+	if err != nil {
+		return "", err
+	}
+	present, err := t.engine.hasImage(ctx, t.spec.Image)
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case present:
+		return fmt.Sprintf("%s present (engine %s)", t.spec.Image, version), nil
+	case t.spec.Pull == "never":
+		return "", fmt.Errorf("%s is not present and pull is \"never\"", t.spec.Image)
+	default:
+		return fmt.Sprintf("%s will be pulled on first call (engine %s)", t.spec.Image, version), nil
+	}
+}
+
+func (t *Tool) Description() string {
+	if t.spec.Description != "" {
+		return t.spec.Description
+	}
+	return fmt.Sprintf("Run the %s container image.", t.spec.Image)
+}
+
+func (t *Tool) Call(ctx context.Context, args map[string]any) (any, error) {
+	req, err := t.request(args)
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+
+	if t.spec.Pull == "always" {
+		if err := t.engine.pull(ctx, t.spec.Image); err != nil {
+			return nil, fmt.Errorf("failed to pull %s: %w", t.spec.Image, err)
+		}
+	}
+	id, err := t.engine.create(ctx, req)
+	if errors.Is(err, errNoImage) && t.spec.Pull != "never" {
+		core.Logger().Info().Str("tool", t.name).Str("image", t.spec.Image).Msg("Pulling tool image")
+		if err := t.engine.pull(ctx, t.spec.Image); err != nil {
+			return nil, fmt.Errorf("failed to pull %s: %w", t.spec.Image, err)
+		}
+		id, err = t.engine.create(ctx, req)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s container: %w", t.name, err)
+	}
+	defer func() {
+		// Removal must outlive a cancelled call
+		rmCtx, rmCancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer rmCancel()
+		if err := t.engine.remove(rmCtx, id); err != nil {
+			core.Logger().Warn().Str("tool", t.name).Str("container", id).Err(err).Msg("Failed to remove tool container")
+		}
+	}()
+
+	if err := t.engine.start(ctx, id); err != nil {
+		return nil, fmt.Errorf("failed to start %s container: %w", t.name, err)
+	}
+	code, err := t.engine.wait(ctx, id)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%s: %w", t.name, ctx.Err())
+		}
+		return nil, fmt.Errorf("%s: %w", t.name, err)
+	}
+	stdout, stderr, err := t.engine.logs(ctx, id, maxOutput)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s output: %w", t.name, err)
+	}
+
+	res := Result{ExitCode: code, Stdout: stdout, Stderr: stderr}
+	var out any
+	if json.Unmarshal([]byte(stdout), &out) == nil {
+		res.Output = out
+	}
+	if code != 0 {
+		msg := strings.TrimSpace(stderr)
+		if msg == "" {
+			msg = strings.TrimSpace(stdout)
+		}
+		return res, fmt.Errorf("%s exited with code %d: %s", t.name, code, msg)
+	}
+	return res, nil
+}
+
+var placeholder = regexp.MustCompile(`\{(\w+)\}`)
+
+// request builds the container definition for one call.
+func (t *Tool) request(args map[string]any) (createRequest, error) {
+	var missing []string
+	expand := func(s string) string {
+		return placeholder.ReplaceAllStringFunc(s, func(m string) string {
+			name := m[1 : len(m)-1]
+			v, ok := args[name]
+			if !ok {
+				missing = append(missing, name)
+				return ""
+			}
+			return fmt.Sprint(v)
+		})
+	}
+	expandAll := func(in []string) []string {
+		out := make([]string, len(in))
+		for i, s := range in {
+			out[i] = expand(s)
+		}
+		return out
+	}
+
+	argsJSON, err := json.Marshal(args)
+	if err != nil {
+		return createRequest{}, fmt.Errorf("%s: arguments are not serializable: %w", t.name, err)
+	}
+	env := []string{"TOOL_ARGS=" + string(argsJSON)}
+	for k, v := range t.spec.Env {
+		env = append(env, k+"="+expand(v))
+	}
+	sort.Strings(env[1:])
+
+	binds := make([]string, 0, len(t.spec.Mounts))
+	for _, m := range t.spec.Mounts {
+		bind, err := absBind(m)
+		if err != nil {
+			return createRequest{}, fmt.Errorf("%s: %w", t.name, err)
+		}
+		binds = append(binds, bind)
+	}
+	network := t.spec.Network
+	if network == "" {
+		network = "none"
+	}
+	req := createRequest{
+		Image:      t.spec.Image,
+		Cmd:        expandAll(t.spec.Command),
+		Entrypoint: expandAll(t.spec.Entrypoint),
+		Env:        env,
+		WorkingDir: t.spec.Workdir,
+		User:       t.spec.User,
+		Labels:     map[string]string{"agentflow.tool": t.name},
+		HostConfig: hostConfig{
+			Binds:          binds,
+			NetworkMode:    network,
+			Memory:         int64(t.spec.MemoryMB) << 20,
+			NanoCPUs:       int64(t.spec.CPUs * 1e9),
+			PidsLimit:      256,
+			ReadonlyRootfs: t.spec.ReadOnly,
+			CapDrop:        []string{"ALL"},
+			SecurityOpt:    []string{"no-new-privileges"},
+		},
+	}
+	if len(missing) > 0 {
+		return createRequest{}, fmt.Errorf("%s: missing arguments: %s", t.name, strings.Join(missing, ", "))
+	}
+	return req, nil
+}
+
+// absBind resolves the host side of a "host:container[:opts]" bind.
+func absBind(bind string) (string, error) {
+	// Split from the right so Windows drive letters stay in the host path
+	parts := strings.Split(bind, ":")
+	if len(parts) < 2 {
+		return "", fmt.Errorf("invalid mount %q (want host:container[:ro])", bind)
+	}
+	tail := 1
+	if last := parts[len(parts)-1]; last == "ro" || last == "rw" {
+		tail = 2
+	}
+	if len(parts) <= tail {
+		return "", fmt.Errorf("invalid mount %q (want host:container[:ro])", bind)
+	}
+	host := strings.Join(parts[:len(parts)-tail], ":")
+	abs, err := filepath.Abs(host)
+	if err != nil {
+		return "", err
+	}
+	return abs + ":" + strings.Join(parts[len(parts)-tail:], ":"), nil
+}
@@ -0,0 +1,123 @@
+package sandbox
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func newTool(t *testing.T, host string, spec Spec) *Tool {
+	t.Helper()
+	tools, err := New(Config{Host: host, Tools: map[string]Spec{"convert": spec}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tools[0]
+}
+
+func TestCall(t *testing.T) {
+	d, host := newDaemon(t)
+	d.stdout = `{"pages": 3}`
+	tool := newTool(t, host, Spec{
+		Image:    "pandoc/core:3.1",
+		Command:  []string{"--to", "{to}", "/work/{file}"},
+		Env:      map[string]string{"FORMAT": "{to}"},
+		Mounts:   []string{"./workspace:/work:ro"},
+		MemoryMB: 256,
+	})
+
+	out, err := tool.Call(context.Background(), map[string]any{"to": "markdown", "file": "a.docx"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := out.(Result)
+	if res.Output.(map[string]any)["pages"] != 3.0 {
+		t.Errorf("result = %+v, want stdout decoded", res)
+	}
+	if len(d.created) != 1 || d.removed != 1 {
+		t.Fatalf("created %d containers, removed %d; want the image pulled on demand and the container removed", len(d.created), d.removed)
+	}
+	req := d.created[0]
+	if !slices.Equal(req.Cmd, []string{"--to", "markdown", "/work/a.docx"}) {
+		t.Errorf("Cmd = %v", req.Cmd)
+	}
+	if !slices.Contains(req.Env, "FORMAT=markdown") || !strings.HasPrefix(req.Env[0], "TOOL_ARGS={") {
+		t.Errorf("Env = %v", req.Env)
+	}
+	wd, _ := os.Getwd()
+	if req.HostConfig.Binds[0] != filepath.Join(wd, "workspace")+":/work:ro" {
+		t.Errorf("Binds = %v, want the host path made absolute", req.HostConfig.Binds)
+	}
+	if req.HostConfig.NetworkMode != "none" || req.HostConfig.Memory != 256<<20 || !slices.Equal(req.HostConfig.CapDrop, []string{"ALL"}) {
+		t.Errorf("HostConfig = %+v", req.HostConfig)
+	}
+}
+
+func TestCallFails(t *testing.T) {
+	d, host := newDaemon(t)
+	d.images["busybox:latest"] = true
+	d.code, d.stderr = 2, "no such file\n"
+	tool := newTool(t, host, Spec{Image: "busybox:latest", Command: []string{"cat", "{file}"}})
+
+	out, err := tool.Call(context.Background(), map[string]any{"file": "x"})
+	if err == nil || !strings.Contains(err.Error(), "code 2: no such file") {
+		t.Errorf("error = %v, want the exit code and stderr", err)
+	}
+	if res, ok := out.(Result); !ok || res.ExitCode != 2 {
+		t.Errorf("result = %#v, want it returned with the error", out)
+	}
+	if _, err := tool.Call(context.Background(), nil); err == nil || !strings.Contains(err.Error(), "missing arguments: file") {
+		t.Errorf("error = %v, want the missing argument named", err)
+	}
+}
+
+func TestPullNever(t *testing.T) {
+	_, host := newDaemon(t)
+	tool := newTool(t, host, Spec{Image: "private/tool:1", Pull: "never"})
+	if _, err := tool.Call(context.Background(), nil); err == nil {
+		t.Error("Call pulled an image with pull = never")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if tools, err := New(Config{}); err != nil || tools != nil {
+		t.Errorf("New without tools = %v, %v", tools, err)
+	}
+	for _, spec := range []Spec{{}, {Image: "x", Timeout: "soon"}} {
+		if _, err := New(Config{Host: "tcp://127.0.0.1:1", Tools: map[string]Spec{"t": spec}}); err == nil {
+			t.Errorf("New accepted %+v", spec)
+		}
+	}
+}
+
+func TestAbsBind(t *testing.T) {
+	for _, bind := range []string{"/data", "/data:ro"} {
+		if _, err := absBind(bind); err == nil {
+			t.Errorf("absBind(%q) accepted", bind)
+		}
+	}
+	if got, err := absBind("/data:/work"); err != nil || got != "/data:/work" {
+		t.Errorf("absBind = %q, %v", got, err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	d, host := newDaemon(t)
+	ctx := context.Background()
+	if msg, err := newTool(t, host, Spec{Image: "pandoc/core:3.1"}).Check(ctx); err != nil || !strings.Contains(msg, "will be pulled") {
+		t.Errorf("missing image: %q, %v", msg, err)
+	}
+	if _, err := newTool(t, host, Spec{Image: "pandoc/core:3.1", Pull: "never"}).Check(ctx); err == nil {
+		t.Error("missing image with pull = never passed")
+	}
+	d.images["pandoc/core:3.1"] = true
+	if msg, err := newTool(t, host, Spec{Image: "pandoc/core:3.1"}).Check(ctx); err != nil || msg != "pandoc/core:3.1 present (engine 25.0)" {
+		t.Errorf("present image: %q, %v", msg, err)
+	}
+	if _, err := newTool(t, "tcp://127.0.0.1:1", Spec{Image: "x"}).Check(ctx); err == nil {
+		t.Error("Check passed without an engine")
+	}
+}
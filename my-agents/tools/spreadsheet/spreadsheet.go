@@ -0,0 +1,268 @@
+// Package spreadsheet provides a tool that parses CSV and XLSX attachments
+// into typed rows so agents can answer questions about spreadsheet data.
+package spreadsheet
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Column types reported by type inference.
+const (
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeBoolean = "boolean"
+	TypeDate    = "date"
+	TypeString  = "string"
+)
+
+// DefaultSampleRows bounds how many rows are returned for large files.
+const DefaultSampleRows = 200
+
+// Column describes one column of the parsed sheet.
+type Column struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Result is the parsed spreadsheet. Rows holds every row for small files
+// and an evenly spaced sample for large ones.
+type Result struct {
+	File     string           `json:"file"`
+	Sheet    string           `json:"sheet,omitempty"`
+	Columns  []Column         `json:"columns"`
+	RowCount int              `json:"row_count"`
+	Sampled  bool             `json:"sampled"`
+	Rows     []map[string]any `json:"rows"`
+}
+
+// Sources cites the parsed file.
+func (r *Result) Sources() []string {
+	if r.Sheet != "" {
+		return []string{r.File + "#" + r.Sheet}
+	}
+	return []string{r.File}
+}
+
+// Tool parses spreadsheets. Arguments:
+//
+//	path    file path of the attachment, or
+//	data    base64-encoded file content together with
+//	name    the original file name (its extension selects the parser)
+//	sheet   XLSX sheet name (default: first sheet)
+//	sample  maximum rows to return (default DefaultSampleRows)
+type Tool struct {
+	// MaxBytes rejects larger attachments; zero means 50 MiB.
+	MaxBytes int64
+}
+
+// New creates the spreadsheet tool.
+func New() *Tool {
+	return &Tool{}
+}
+
+func (t *Tool) Name() string { return "spreadsheet" }
+
+func (t *Tool) Description() string {
+	return "Parse a CSV or XLSX attachment into typed columns and sample rows."
+}
+
+func (t *Tool) Call(ctx context.Context, args map[string]any) (any, error) {
+	name, data, err := t.load(args)
+	if err != nil {
+		return nil, err
+	}
+	sample := DefaultSampleRows
+	if n, ok := args["sample"].(float64); ok && n > 0 {
+		sample = int(n)
+	} else if n, ok := args["sample"].(int); ok && n > 0 {
+		sample = n
+	}
+
+	var records [][]string
+	sheet, _ := args["sheet"].(string)
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".csv", ".tsv", ".txt":
+		r := csv.NewReader(strings.NewReader(string(data)))
+		if strings.EqualFold(filepath.Ext(name), ".tsv") {
+			r.Comma = '\t'
+		}
+		r.FieldsPerRecord = -1
+		records, err = r.ReadAll()
+	case ".xlsx":
+		records, sheet, err = readXLSX(data, sheet)
+	default:
+		return nil, fmt.Errorf("unsupported spreadsheet type %q", filepath.Ext(name))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s is empty", name)
+	}
+	return build(filepath.Base(name), sheet, records, sample), nil
+}
+
+func (t *Tool) load(args map[string]any) (string, []byte, error) {
+	limit := t.MaxBytes
+	if limit <= 0 {
+		limit = 50 << 20
+	}
+	if path, ok := args["path"].(string); ok && path != "" {
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", nil, err
+		}
+		if info.Size() > limit {
+			return "", nil, fmt.Errorf("%s is larger than %d bytes", path, limit)
+		}
+		data, err := os.ReadFile(path)
+		return path, data, err
+	}
+	encoded, ok := args["data"].(string)
+	if !ok {
+		return "", nil, fmt.Errorf("either path or data is required")
+	}
+	name, _ := args["name"].(string)
+	if name == "" {
+		return "", nil, fmt.Errorf("name is required with data")
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, fmt.Errorf("data is not valid base64: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return "", nil, fmt.Errorf("%s is larger than %d bytes", name, limit)
+	}
+	return name, data, nil
+}
+
+// build infers column types from all rows and returns typed, sampled rows.
+func build(file, sheet string, records [][]string, sample int) *Result {
+	header := records[0]
+	body := records[1:]
+	res := &Result{File: file, Sheet: sheet, RowCount: len(body)}
+
+	for c, name := range header {
+		if name = strings.TrimSpace(name); name == "" {
+			name = fmt.Sprintf("column_%d", c+1)
+		}
+		res.Columns = append(res.Columns, Column{Name: name, Type: inferType(body, c)})
+	}
+
+	indexes := sampleIndexes(len(body), sample)
+	res.Sampled = len(indexes) < len(body)
+	for _, i := range indexes {
+		row := make(map[string]any, len(res.Columns))
+		for c, col := range res.Columns {
+			cell := ""
+			if c < len(body[i]) {
+				cell = body[i][c]
+			}
+			row[col.Name] = convert(cell, col.Type)
+		}
+		res.Rows = append(res.Rows, row)
+	}
+	return res
+}
+
+// sampleIndexes returns every index when n <= max, otherwise max evenly
+// spaced indexes that always include the first and last row.
+func sampleIndexes(n, max int) []int {
+	if n <= max {
+		idx := make([]int, n)
+		for i := range idx {
+			idx[i] = i
+		}
+		return idx
+	}
+	if max == 1 {
+		return []int{0}
+	}
+	idx := make([]int, max)
+	for i := range idx {
+		idx[i] = i * (n - 1) / (max - 1)
+	}
+	return idx
+}
+
+var dateLayouts = []string{"2006-01-02", "2006-01-02T15:04:05Z07:00", "2006-01-02 15:04:05", "01/02/2006", "02.01.2006"}
+
+func inferType(rows [][]string, col int) string {
+	typ := ""
+	for _, row := range rows {
+		if col >= len(row) || strings.TrimSpace(row[col]) == "" {
+			continue
+		}
+		typ = widen(typ, cellType(strings.TrimSpace(row[col])))
+		if typ == TypeString {
+			break
+		}
+	}
+	if typ == "" {
+		return TypeString
+	}
+	return typ
+}
+
+func cellType(s string) string {
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return TypeInteger
+	}
+	if _, err := strconv.ParseFloat(s, 64); err == nil {
+		return TypeNumber
+	}
+	if _, err := strconv.ParseBool(s); err == nil {
+		return TypeBoolean
+	}
+	for _, layout := range dateLayouts {
+		if _, err := time.Parse(layout, s); err == nil {
+			return TypeDate
+		}
+	}
+	return TypeString
+}
+
+// widen merges two observed types into the narrowest type holding both.
+func widen(a, b string) string {
+	switch {
+	case a == "" || a == b:
+		return b
+	case (a == TypeInteger && b == TypeNumber) || (a == TypeNumber && b == TypeInteger):
+		return TypeNumber
+	default:
+		return TypeString
+	}
+}
+
+func convert(s, typ string) any {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	switch typ {
+	case TypeInteger:
+		v, _ := strconv.ParseInt(s, 10, 64)
+		return v
+	case TypeNumber:
+		v, _ := strconv.ParseFloat(s, 64)
+		return v
+	case TypeBoolean:
+		v, _ := strconv.ParseBool(s)
+		return v
+	case TypeDate:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t.Format("2006-01-02")
+			}
+		}
+	}
+	return s
+}
@@ -0,0 +1,83 @@
+package spreadsheet
+
+import (
+	"context"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCallCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sales.csv")
+	csv := "region,units,price,active,since,\nNorth,3,1.5,true,2026-01-02,x\nSouth,4,2,false,01/31/2026,\n"
+	if err := os.WriteFile(path, []byte(csv), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := New().Call(context.Background(), map[string]any{"path": path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := out.(*Result)
+	want := []Column{
+		{"region", TypeString}, {"units", TypeInteger}, {"price", TypeNumber},
+		{"active", TypeBoolean}, {"since", TypeDate}, {"column_6", TypeString},
+	}
+	if !reflect.DeepEqual(res.Columns, want) {
+		t.Errorf("Columns = %+v", res.Columns)
+	}
+	if res.RowCount != 2 || res.Sampled {
+		t.Errorf("RowCount = %d, Sampled = %v", res.RowCount, res.Sampled)
+	}
+	row := res.Rows[1]
+	if row["units"] != int64(4) || row["price"] != 2.0 || row["active"] != false || row["since"] != "2026-01-31" || row["column_6"] != nil {
+		t.Errorf("row = %v", row)
+	}
+	if got := res.Sources(); !reflect.DeepEqual(got, []string{"sales.csv"}) {
+		t.Errorf("Sources = %v", got)
+	}
+}
+
+func TestCallData(t *testing.T) {
+	data := base64.StdEncoding.EncodeToString([]byte("a\tb\n1\t2\n"))
+	out, err := New().Call(context.Background(), map[string]any{"data": data, "name": "x.tsv"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := out.(*Result).Rows[0]; got["b"] != int64(2) {
+		t.Errorf("row = %v", got)
+	}
+
+	for name, args := range map[string]map[string]any{
+		"no source":   {},
+		"no name":     {"data": data},
+		"bad base64":  {"data": "%%%", "name": "x.csv"},
+		"unsupported": {"data": data, "name": "x.pdf"},
+		"empty":       {"data": "", "name": "x.csv"},
+	} {
+		if _, err := New().Call(context.Background(), args); err == nil {
+			t.Errorf("%s: Call succeeded", name)
+		}
+	}
+}
+
+func TestMaxBytes(t *testing.T) {
+	data := base64.StdEncoding.EncodeToString([]byte("a,b\n1,2\n"))
+	tool := &Tool{MaxBytes: 4}
+	if _, err := tool.Call(context.Background(), map[string]any{"data": data, "name": "x.csv"}); err == nil {
+		t.Error("Call accepted an attachment over MaxBytes")
+	}
+}
+
+func TestSampleIndexes(t *testing.T) {
+	if got := sampleIndexes(3, 5); !reflect.DeepEqual(got, []int{0, 1, 2}) {
+		t.Errorf("sampleIndexes(3, 5) = %v", got)
+	}
+	if got := sampleIndexes(10, 3); !reflect.DeepEqual(got, []int{0, 4, 9}) {
+		t.Errorf("sampleIndexes(10, 3) = %v", got)
+	}
+	if got := sampleIndexes(10, 1); !reflect.DeepEqual(got, []int{0}) {
+		t.Errorf("sampleIndexes(10, 1) = %v", got)
+	}
+}
@@ -0,0 +1,251 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type xlsxWorkbook struct {
+	Sheets []struct {
+		Name string `xml:"name,attr"`
+		RID  string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+	} `xml:"sheets>sheet"`
+}
+
+type xlsxRelationships struct {
+	Rels []struct {
+		ID     string `xml:"Id,attr"`
+		Target string `xml:"Target,attr"`
+	} `xml:"Relationship"`
+}
+
+type xlsxSharedStrings struct {
+	Items []struct {
+		T    string `xml:"t"`
+		Runs []struct {
+			T string `xml:"t"`
+		} `xml:"r"`
+	} `xml:"si"`
+}
+
+type xlsxStyles struct {
+	NumFmts []struct {
+		ID   int    `xml:"numFmtId,attr"`
+		Code string `xml:"formatCode,attr"`
+	} `xml:"numFmts>numFmt"`
+	CellXfs []struct {
+		NumFmtID int `xml:"numFmtId,attr"`
+	} `xml:"cellXfs>xf"`
+}
+
+type xlsxSheet struct {
+	Rows []struct {
+		Cells []struct {
+			Ref    string `xml:"r,attr"`
+			Type   string `xml:"t,attr"`
+			Style  int    `xml:"s,attr"`
+			Value  string `xml:"v"`
+			Inline string `xml:"is>t"`
+		} `xml:"c"`
+	} `xml:"sheetData>row"`
+}
+
+// readXLSX returns the cells of the named sheet (or the first sheet) as
+// strings. Date-formatted serials are rendered as YYYY-MM-DD.
+func readXLSX(data []byte, sheetName string) ([][]string, string, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, "", err
+	}
+	files := make(map[string]*zip.File, len(zr.File))
+	for _, f := range zr.File {
+		files[f.Name] = f
+	}
+
+	var wb xlsxWorkbook
+	if err := decodeXML(files, "xl/workbook.xml", &wb); err != nil {
+		return nil, "", err
+	}
+	if len(wb.Sheets) == 0 {
+		return nil, "", fmt.Errorf("workbook has no sheets")
+	}
+	var rels xlsxRelationships
+	if err := decodeXML(files, "xl/_rels/workbook.xml.rels", &rels); err != nil {
+		return nil, "", err
+	}
+
+	idx := 0
+	if sheetName != "" {
+		idx = -1
+		for i, s := range wb.Sheets {
+			if strings.EqualFold(s.Name, sheetName) {
+				idx = i
+				break
+			}
+		}
+		if idx < 0 {
+			return nil, "", fmt.Errorf("sheet %q not found", sheetName)
+		}
+	}
+	sheet := wb.Sheets[idx]
+	target := ""
+	for _, r := range rels.Rels {
+		if r.ID == sheet.RID {
+			target = r.Target
+		}
+	}
+	if target == "" {
+		return nil, "", fmt.Errorf("sheet %q has no part", sheet.Name)
+	}
+	if strings.HasPrefix(target, "/") {
+		target = strings.TrimPrefix(target, "/")
+	} else {
+		target = path.Join("xl", target)
+	}
+
+	var shared xlsxSharedStrings
+	if _, ok := files["xl/sharedStrings.xml"]; ok {
+		if err := decodeXML(files, "xl/sharedStrings.xml", &shared); err != nil {
+			return nil, "", err
+		}
+	}
+	var styles xlsxStyles
+	if _, ok := files["xl/styles.xml"]; ok {
+		if err := decodeXML(files, "xl/styles.xml", &styles); err != nil {
+			return nil, "", err
+		}
+	}
+	dateStyles := dateStyleSet(styles)
+
+	var ws xlsxSheet
+	if err := decodeXML(files, target, &ws); err != nil {
+		return nil, "", err
+	}
+	var records [][]string
+	for _, row := range ws.Rows {
+		var rec []string
+		for i, c := range row.Cells {
+			col := i
+			if c.Ref != "" {
+				col = columnIndex(c.Ref)
+			}
+			for len(rec) <= col {
+				rec = append(rec, "")
+			}
+			switch c.Type {
+			case "s":
+				n, err := strconv.Atoi(c.Value)
+				if err == nil && n >= 0 && n < len(shared.Items) {
+					rec[col] = sharedString(shared, n)
+				}
+			case "inlineStr":
+				rec[col] = c.Inline
+			case "b":
+				rec[col] = strconv.FormatBool(c.Value == "1")
+			default:
+				rec[col] = c.Value
+				if dateStyles[c.Style] && c.Value != "" {
+					if serial, err := strconv.ParseFloat(c.Value, 64); err == nil {
+						rec[col] = serialDate(serial)
+					}
+				}
+			}
+		}
+		records = append(records, rec)
+	}
+	return records, sheet.Name, nil
+}
+
+func decodeXML(files map[string]*zip.File, name string, v any) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("missing %s", name)
+	}
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := xml.NewDecoder(io.LimitReader(rc, 256<<20)).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s: %w", name, err)
+	}
+	return nil
+}
+
+func sharedString(s xlsxSharedStrings, n int) string {
+	item := s.Items[n]
+	if len(item.Runs) == 0 {
+		return item.T
+	}
+	var b strings.Builder
+	for _, r := range item.Runs {
+		b.WriteString(r.T)
+	}
+	return b.String()
+}
+
+// dateStyleSet returns the cellXfs indexes whose number format is a date.
+func dateStyleSet(styles xlsxStyles) map[int]bool {
+	custom := make(map[int]string, len(styles.NumFmts))
+	for _, f := range styles.NumFmts {
+		custom[f.ID] = f.Code
+	}
+	set := make(map[int]bool)
+	for i, xf := range styles.CellXfs {
+		id := xf.NumFmtID
+		// Built-in date formats are 14-22 and 45-47
+		if (id >= 14 && id <= 22) || (id >= 45 && id <= 47) {
+			set[i] = true
+			continue
+		}
+		if code, ok := custom[id]; ok && isDateFormat(code) {
+			set[i] = true
+		}
+	}
+	return set
+}
+
+func isDateFormat(code string) bool {
+	// Drop quoted literals and bracketed colours before looking for date tokens
+	var b strings.Builder
+	quoted, bracket := false, false
+	for _, r := range code {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == '[' && !quoted:
+			bracket = true
+		case r == ']' && !quoted:
+			bracket = false
+		case !quoted && !bracket:
+			b.WriteRune(r)
+		}
+	}
+	lower := strings.ToLower(b.String())
+	return strings.ContainsAny(lower, "dy") || strings.Contains(lower, "mmm")
+}
+
+// serialDate converts an Excel 1900-system serial to YYYY-MM-DD.
+func serialDate(serial float64) string {
+	epoch := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+	return epoch.Add(time.Duration(serial * 24 * float64(time.Hour))).Format("2006-01-02")
+}
+
+// columnIndex converts a cell reference such as "C7" to a zero-based column.
+func columnIndex(ref string) int {
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		n = n*26 + int(r-'A'+1)
+	}
+	return n - 1
+}
@@ -0,0 +1,111 @@
+package spreadsheet
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"reflect"
+	"testing"
+)
+
+// testWorkbook builds a two-sheet workbook; the second sheet uses shared
+// strings, a boolean and a date-styled serial.
+func testWorkbook(t *testing.T) []byte {
+	t.Helper()
+	parts := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Summary" r:id="rId1"/><sheet name="Orders" r:id="rId2"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships>
+<Relationship Id="rId1" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Target="/xl/worksheets/sheet2.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst><si><t>item</t></si><si><r><t>wid</t></r><r><t>get</t></r></si></sst>`,
+		"xl/styles.xml": `<styleSheet><numFmts><numFmt numFmtId="164" formatCode="[Red]&quot;on &quot;dd/mm/yyyy"/></numFmts>
+<cellXfs><xf numFmtId="0"/><xf numFmtId="164"/></cellXfs></styleSheet>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData><row><c t="inlineStr"><is><t>total</t></is></c></row><row><c><v>7</v></c></row></sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<worksheet><sheetData>
+<row><c r="A1" t="s"><v>0</v></c><c r="C1" t="inlineStr"><is><t>shipped</t></is></c><c r="D1" t="inlineStr"><is><t>on</t></is></c></row>
+<row><c r="A2" t="s"><v>1</v></c><c r="C2" t="b"><v>1</v></c><c r="D2" s="1"><v>46023</v></c></row>
+</sheetData></worksheet>`,
+	}
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, body := range parts {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(body)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestReadXLSX(t *testing.T) {
+	data := testWorkbook(t)
+	records, sheet, err := readXLSX(data, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sheet != "Summary" || !reflect.DeepEqual(records, [][]string{{"total"}, {"7"}}) {
+		t.Errorf("first sheet %q = %v", sheet, records)
+	}
+
+	records, sheet, err = readXLSX(data, "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"item", "", "shipped", "on"}, {"widget", "", "true", "2026-01-01"}}
+	if sheet != "Orders" || !reflect.DeepEqual(records, want) {
+		t.Errorf("sheet %q = %v, want %v", sheet, records, want)
+	}
+
+	if _, _, err := readXLSX(data, "missing"); err == nil {
+		t.Error("readXLSX found a missing sheet")
+	}
+	if _, _, err := readXLSX([]byte("not a zip"), ""); err == nil {
+		t.Error("readXLSX read a non-zip file")
+	}
+}
+
+func TestCallXLSX(t *testing.T) {
+	args := map[string]any{"data": base64.StdEncoding.EncodeToString(testWorkbook(t)), "name": "book.xlsx", "sheet": "Orders"}
+	out, err := New().Call(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := out.(*Result)
+	if got := res.Sources(); !reflect.DeepEqual(got, []string{"book.xlsx#Orders"}) {
+		t.Errorf("Sources = %v", got)
+	}
+	if res.Columns[3].Type != TypeDate {
+		t.Errorf("Columns = %+v", res.Columns)
+	}
+}
+
+func TestIsDateFormat(t *testing.T) {
+	for code, want := range map[string]bool{
+		"yyyy-mm-dd":    true,
+		"mmm yy":        true,
+		"0.00":          false,
+		`"day "0`:       false,
+		"[Red]#,##0.00": false,
+		"h:mm":          false,
+	} {
+		if got := isDateFormat(code); got != want {
+			t.Errorf("isDateFormat(%q) = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestColumnIndex(t *testing.T) {
+	for ref, want := range map[string]int{"A1": 0, "C7": 2, "AA10": 26} {
+		if got := columnIndex(ref); got != want {
+			t.Errorf("columnIndex(%q) = %d, want %d", ref, got, want)
+		}
+	}
+}
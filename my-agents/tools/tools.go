@@ -0,0 +1,11 @@
+// Package tools defines callable tools that agents can be wired with.
+package tools
+
+import "context"
+
+// Tool is a named capability an agent can invoke.
+type Tool interface {
+	Name() string
+	Description() string
+	Call(ctx context.Context, args map[string]any) (any, error)
+}
@@ -0,0 +1,185 @@
+// Package tot implements tree-of-thought exploration: several reasoning
+// branches are expanded in parallel, a scorer prunes them to a beam, and the
+// best surviving branch produces the answer.
+package tot
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// BranchesKey is the state key holding the explored []Node.
+const BranchesKey = "thought_branches"
+
+// Config is the [tree_of_thought] section of agentflow.toml.
+type Config struct {
+	Branching int `toml:"branching"` // thoughts proposed per node (default 3)
+	Depth     int `toml:"depth"`     // expansion rounds (default 2)
+	Beam      int `toml:"beam"`      // nodes kept after each round (default 2)
+	// ScorerProvider names a [providers.<name>] table for the scorer; empty
+	// uses the agent's own provider.
+	ScorerProvider string `toml:"scorer_provider"`
+}
+
+// Node is one reasoning step and its score.
+type Node struct {
+	Thoughts []string `json:"thoughts"` // path from the root
+	Score    float64  `json:"score"`
+	Depth    int      `json:"depth"`
+	Pruned   bool     `json:"pruned,omitempty"`
+}
+
+// Result is the chosen answer plus every node explored.
+type Result struct {
+	Answer string
+	Best   Node
+	Nodes  []Node
+}
+
+// Explorer runs the search.
+type Explorer struct {
+	cfg    Config
+	llm    core.ModelProvider
+	scorer core.ModelProvider
+}
+
+// New creates an explorer; scorer may be nil to reuse llm.
+func New(cfg Config, llm, scorer core.ModelProvider) *Explorer {
+	if cfg.Branching <= 0 {
+		cfg.Branching = 3
+	}
+	if cfg.Depth <= 0 {
+		cfg.Depth = 2
+	}
+	if cfg.Beam <= 0 {
+		cfg.Beam = 2
+	}
+	if scorer == nil {
+		scorer = llm
+	}
+	return &Explorer{cfg: cfg, llm: llm, scorer: scorer}
+}
+
+// Run explores reasoning for task and answers from the best branch.
+func (e *Explorer) Run(ctx context.Context, system, task string) (*Result, error) {
+	res := &Result{}
+	frontier := []Node{{}}
+	for depth := 1; depth <= e.cfg.Depth; depth++ {
+		children, err := e.expand(ctx, system, task, frontier, depth)
+		if err != nil {
+			return nil, err
+		}
+		if len(children) == 0 {
+			break
+		}
+		sort.SliceStable(children, func(i, j int) bool { return children[i].Score > children[j].Score })
+		keep := min(e.cfg.Beam, len(children))
+		for i := range children {
+			children[i].Pruned = i >= keep
+		}
+		res.Nodes = append(res.Nodes, children...)
+		frontier = children[:keep]
+		core.Logger().Debug().Int("depth", depth).Int("explored", len(children)).Float64("best", frontier[0].Score).Msg("Tree-of-thought round")
+	}
+	if len(frontier) == 0 || len(frontier[0].Thoughts) == 0 {
+		return nil, fmt.Errorf("tree-of-thought produced no branches")
+	}
+	res.Best = frontier[0]
+
+	resp, err := e.llm.Call(ctx, core.Prompt{
+		System: system,
+		User:   fmt.Sprintf("%s\n\nReasoning so far:\n%s\n\nUsing this reasoning, write the final answer.", task, numbered(res.Best.Thoughts)),
+	})
+	if err != nil {
+		return nil, err
+	}
+	res.Answer = resp.Content
+	return res, nil
+}
+
+// expand proposes and scores children for every frontier node in parallel.
+func (e *Explorer) expand(ctx context.Context, system, task string, frontier []Node, depth int) ([]Node, error) {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		children []Node
+		firstErr error
+	)
+	for _, parent := range frontier {
+		for b := 0; b < e.cfg.Branching; b++ {
+			wg.Add(1)
+			go func(parent Node, b int) {
+				defer wg.Done()
+				node, err := e.branch(ctx, system, task, parent, b, depth)
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+					return
+				}
+				children = append(children, node)
+			}(parent, b)
+		}
+	}
+	wg.Wait()
+	// A round fails only if no branch survived
+	if len(children) == 0 && firstErr != nil {
+		return nil, firstErr
+	}
+	return children, nil
+}
+
+func (e *Explorer) branch(ctx context.Context, system, task string, parent Node, b, depth int) (Node, error) {
+	user := fmt.Sprintf("%s\n\n", task)
+	if len(parent.Thoughts) > 0 {
+		user += "Reasoning so far:\n" + numbered(parent.Thoughts) + "\n\n"
+	}
+	user += fmt.Sprintf("Propose the next reasoning step (approach #%d; make it distinct from the obvious one). Reply with the step only, in one or two sentences.", b+1)
+	resp, err := e.llm.Call(ctx, core.Prompt{System: system, User: user})
+	if err != nil {
+		return Node{}, err
+	}
+	thought := strings.TrimSpace(resp.Content)
+	if thought == "" {
+		return Node{}, fmt.Errorf("empty thought")
+	}
+	node := Node{Thoughts: append(append([]string(nil), parent.Thoughts...), thought), Depth: depth}
+	node.Score, err = e.score(ctx, task, node)
+	return node, err
+}
+
+var scorePattern = regexp.MustCompile(`\d+(\.\d+)?`)
+
+// score asks the scorer to rate a branch 0-10.
+func (e *Explorer) score(ctx context.Context, task string, node Node) (float64, error) {
+	resp, err := e.scorer.Call(ctx, core.Prompt{
+		System: "You are a strict evaluator of reasoning. Rate how likely this line of reasoning leads to a correct, complete answer. Reply with a single number from 0 to 10.",
+		User:   fmt.Sprintf("Task: %s\n\nReasoning:\n%s", task, numbered(node.Thoughts)),
+	})
+	if err != nil {
+		return 0, err
+	}
+	match := scorePattern.FindString(resp.Content)
+	if match == "" {
+		return 0, nil
+	}
+	v, _ := strconv.ParseFloat(match, 64)
+	return min(v, 10), nil
+}
+
+func numbered(thoughts []string) string {
+	var b strings.Builder
+	for i, t := range thoughts {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, t)
+	}
+	return strings.TrimSpace(b.String())
+}
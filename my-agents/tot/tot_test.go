@@ -0,0 +1,113 @@
+package tot
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+var approach = regexp.MustCompile(`approach #(\d+)`)
+
+// thinker proposes "step N" for approach #N and answers with the reasoning
+// it was given.
+type thinker struct {
+	core.ModelProvider
+	calls atomic.Int32
+}
+
+func (th *thinker) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	th.calls.Add(1)
+	if m := approach.FindStringSubmatch(prompt.User); m != nil {
+		return core.Response{Content: "step " + m[1]}, nil
+	}
+	_, reasoning, _ := strings.Cut(prompt.User, "Reasoning so far:\n")
+	return core.Response{Content: "answer from " + strings.ReplaceAll(strings.Split(reasoning, "\n\n")[0], "\n", " ")}, nil
+}
+
+// rater scores a branch by the sum of its steps' numbers.
+type rater struct {
+	core.ModelProvider
+}
+
+func (rater) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	sum := 0
+	for _, m := range regexp.MustCompile(`step (\d+)`).FindAllStringSubmatch(prompt.User, -1) {
+		sum += int(m[1][0] - '0')
+	}
+	return core.Response{Content: fmt.Sprintf("Score: %d", sum)}, nil
+}
+
+func TestRun(t *testing.T) {
+	llm := &thinker{}
+	res, err := New(Config{Branching: 3, Depth: 2, Beam: 2}, llm, rater{}).Run(context.Background(), "", "task")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"step 3", "step 3"}; strings.Join(res.Best.Thoughts, ",") != strings.Join(want, ",") || res.Best.Score != 6 {
+		t.Errorf("Best = %+v", res.Best)
+	}
+	if res.Answer != "answer from 1. step 3 2. step 3" {
+		t.Errorf("Answer = %q", res.Answer)
+	}
+	// Three roots, then three children of each of the two kept
+	if len(res.Nodes) != 9 {
+		t.Fatalf("explored %d nodes, want 9", len(res.Nodes))
+	}
+	pruned := 0
+	for _, n := range res.Nodes {
+		if n.Pruned {
+			pruned++
+		}
+	}
+	if pruned != 5 {
+		t.Errorf("pruned %d nodes, want 5", pruned)
+	}
+	if got := llm.calls.Load(); got != 10 {
+		t.Errorf("llm calls = %d, want 9 proposals and the answer", got)
+	}
+}
+
+type failing struct {
+	core.ModelProvider
+}
+
+func (failing) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	return core.Response{}, errors.New("unavailable")
+}
+
+func TestRunFails(t *testing.T) {
+	if _, err := New(Config{}, failing{}, nil).Run(context.Background(), "", "task"); err == nil || err.Error() != "unavailable" {
+		t.Errorf("Run = %v, want the provider's error", err)
+	}
+}
+
+func TestScoreParsing(t *testing.T) {
+	e := New(Config{}, nil, scoreReply{text: "I'd say 7.5 out of 10"})
+	if got, _ := e.score(context.Background(), "task", Node{}); got != 7.5 {
+		t.Errorf("score = %v", got)
+	}
+	e = New(Config{}, nil, scoreReply{text: "42"})
+	if got, _ := e.score(context.Background(), "task", Node{}); got != 10 {
+		t.Errorf("score = %v, want it capped at 10", got)
+	}
+	e = New(Config{}, nil, scoreReply{text: "no idea"})
+	if got, _ := e.score(context.Background(), "task", Node{}); got != 0 {
+		t.Errorf("score = %v", got)
+	}
+}
+
+// scoreReply always replies with its text.
+type scoreReply struct {
+	core.ModelProvider
+	text string
+}
+
+func (s scoreReply) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	return core.Response{Content: s.text}, nil
+}
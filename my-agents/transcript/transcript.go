@@ -0,0 +1,153 @@
+// Package transcript renders a session's recorded runs as a shareable
+// Markdown or HTML conversation transcript.
+package transcript
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+	"time"
+
+	"my-agents/history"
+	"my-agents/react"
+	"my-agents/scratchpad"
+)
+
+// Formats supported by Render.
+const (
+	Markdown = "markdown"
+	HTML     = "html"
+)
+
+// Options control what a transcript includes.
+type Options struct {
+	Format string // Markdown (default) or HTML
+	// IncludeSteps adds each agent's intermediate output under the turn.
+	IncludeSteps bool
+}
+
+// Export renders every run of a session from the store.
+func Export(ctx context.Context, w io.Writer, store history.Store, sessionID string, opts Options) error {
+	runs, err := store.List(ctx, history.Filter{SessionID: sessionID})
+	if err != nil {
+		return err
+	}
+	if len(runs) == 0 {
+		return fmt.Errorf("no runs recorded for session %s", sessionID)
+	}
+	return Render(w, sessionID, runs, opts)
+}
+
+// Render writes the transcript for runs, which should be in order.
+func Render(w io.Writer, sessionID string, runs []*history.Run, opts Options) error {
+	switch opts.Format {
+	case "", Markdown, "md":
+		return renderMarkdown(w, sessionID, runs, opts)
+	case HTML:
+		return renderHTML(w, sessionID, runs, opts)
+	default:
+		return fmt.Errorf("unknown transcript format %q", opts.Format)
+	}
+}
+
+func renderMarkdown(w io.Writer, sessionID string, runs []*history.Run, opts Options) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Conversation %s\n\n", sessionID)
+	fmt.Fprintf(&b, "_%d turn(s), %s – %s_\n\n", len(runs), runs[0].StartedAt.Format("2006-01-02 15:04"), lastTime(runs))
+	for i, run := range runs {
+		fmt.Fprintf(&b, "## Turn %d\n\n", i+1)
+		fmt.Fprintf(&b, "**User:**\n\n%s\n\n", run.Input)
+		if opts.IncludeSteps {
+			for _, step := range run.Steps {
+				fmt.Fprintf(&b, "<details><summary>%s (%s)</summary>\n\n", step.Agent, step.EndedAt.Sub(step.StartedAt).Round(time.Millisecond))
+				if step.Error != "" {
+					fmt.Fprintf(&b, "Error: %s\n", step.Error)
+				} else {
+					fmt.Fprintf(&b, "```json\n%s\n```\n", stepOutput(step))
+				}
+				b.WriteString("\n</details>\n\n")
+			}
+		}
+		switch {
+		case run.FinalResponse != "":
+			fmt.Fprintf(&b, "**Assistant:**\n\n%s\n\n", run.FinalResponse)
+		case run.Question != "":
+			fmt.Fprintf(&b, "**Assistant:** %s _(awaiting answer)_\n\n", run.Question)
+		case run.Error != "":
+			fmt.Fprintf(&b, "**Assistant:** _failed: %s_\n\n", run.Error)
+		default:
+			fmt.Fprintf(&b, "**Assistant:** _%s_\n\n", run.Status)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func renderHTML(w io.Writer, sessionID string, runs []*history.Run, opts Options) error {
+	esc := html.EscapeString
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\">\n")
+	fmt.Fprintf(&b, "<title>Conversation %s</title>\n", esc(sessionID))
+	b.WriteString(`<style>
+body{font-family:system-ui,sans-serif;max-width:48rem;margin:2rem auto;line-height:1.5}
+.turn{border-top:1px solid #ddd;padding:1rem 0}
+.user{background:#f3f6fb;padding:.75rem;border-radius:6px}
+.assistant{padding:.75rem}
+.error{color:#b00}
+pre{background:#f6f6f6;padding:.5rem;overflow:auto;white-space:pre-wrap}
+</style></head><body>
+`)
+	fmt.Fprintf(&b, "<h1>Conversation %s</h1>\n", esc(sessionID))
+	for i, run := range runs {
+		fmt.Fprintf(&b, "<div class=\"turn\"><h2>Turn %d</h2>\n", i+1)
+		fmt.Fprintf(&b, "<div class=\"user\"><strong>User</strong><p>%s</p></div>\n", paragraphs(run.Input))
+		if opts.IncludeSteps {
+			for _, step := range run.Steps {
+				fmt.Fprintf(&b, "<details><summary>%s</summary>", esc(step.Agent))
+				if step.Error != "" {
+					fmt.Fprintf(&b, "<p class=\"error\">%s</p>", esc(step.Error))
+				} else {
+					fmt.Fprintf(&b, "<pre>%s</pre>", esc(stepOutput(step)))
+				}
+				b.WriteString("</details>\n")
+			}
+		}
+		switch {
+		case run.FinalResponse != "":
+			fmt.Fprintf(&b, "<div class=\"assistant\"><strong>Assistant</strong><p>%s</p></div>\n", paragraphs(run.FinalResponse))
+		case run.Question != "":
+			fmt.Fprintf(&b, "<div class=\"assistant\"><strong>Assistant</strong><p>%s <em>(awaiting answer)</em></p></div>\n", esc(run.Question))
+		case run.Error != "":
+			fmt.Fprintf(&b, "<div class=\"assistant error\">Failed: %s</div>\n", esc(run.Error))
+		}
+		b.WriteString("</div>\n")
+	}
+	b.WriteString("</body></html>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func stepOutput(step history.Step) string {
+	output := scratchpad.Strip(step.Output, react.TrajectoryKey)
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return fmt.Sprint(output)
+	}
+	return string(data)
+}
+
+func paragraphs(text string) string {
+	return strings.ReplaceAll(html.EscapeString(text), "\n\n", "</p><p>")
+}
+
+func lastTime(runs []*history.Run) string {
+	last := runs[len(runs)-1]
+	t := last.EndedAt
+	if t.IsZero() {
+		t = last.StartedAt
+	}
+	return t.Format("2006-01-02 15:04")
+}
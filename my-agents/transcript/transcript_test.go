@@ -0,0 +1,93 @@
+package transcript
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"my-agents/history"
+	"my-agents/react"
+	"my-agents/scratchpad"
+)
+
+func testRuns() []*history.Run {
+	start := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	return []*history.Run{
+		{
+			ID: "r1", SessionID: "s1", Input: "What is <b>?", FinalResponse: "It is bold.",
+			StartedAt: start, EndedAt: start.Add(time.Minute),
+			Steps: []history.Step{{
+				Agent:  "processor",
+				Output: map[string]any{"answer": 42, scratchpad.Key: "notes", react.TrajectoryKey: "steps"},
+			}},
+		},
+		{ID: "r2", SessionID: "s1", Input: "And then?", Question: "Which one?", StartedAt: start.Add(time.Hour)},
+		{ID: "r3", SessionID: "s1", Input: "Go", Error: "boom", StartedAt: start.Add(2 * time.Hour)},
+	}
+}
+
+func TestRenderMarkdown(t *testing.T) {
+	var b strings.Builder
+	if err := Render(&b, "s1", testRuns(), Options{IncludeSteps: true}); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	for _, want := range []string{
+		"# Conversation s1",
+		"_3 turn(s), 2026-03-01 09:00 – 2026-03-01 11:00_",
+		"## Turn 1",
+		"**Assistant:**\n\nIt is bold.",
+		"<summary>processor",
+		`"answer": 42`,
+		"Which one? _(awaiting answer)_",
+		"_failed: boom_",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("markdown missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "notes") || strings.Contains(out, react.TrajectoryKey) {
+		t.Errorf("markdown includes internal step output:\n%s", out)
+	}
+}
+
+func TestRenderHTMLEscapes(t *testing.T) {
+	var b strings.Builder
+	if err := Render(&b, "s1", testRuns(), Options{Format: HTML}); err != nil {
+		t.Fatal(err)
+	}
+	out := b.String()
+	if !strings.Contains(out, "What is &lt;b&gt;?") {
+		t.Errorf("input not escaped:\n%s", out)
+	}
+	if strings.Contains(out, "<details>") {
+		t.Error("steps rendered without IncludeSteps")
+	}
+}
+
+func TestRenderUnknownFormat(t *testing.T) {
+	if err := Render(&strings.Builder{}, "s1", testRuns(), Options{Format: "pdf"}); err == nil {
+		t.Error("Render accepted an unknown format")
+	}
+}
+
+func TestExport(t *testing.T) {
+	ctx := context.Background()
+	store := history.NewMemoryStore()
+	if err := Export(ctx, &strings.Builder{}, store, "s1", Options{}); err == nil {
+		t.Error("Export of an unknown session succeeded")
+	}
+	for _, run := range testRuns() {
+		if err := store.Save(ctx, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var b strings.Builder
+	if err := Export(ctx, &b, store, "s1", Options{}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "## Turn 3") {
+		t.Errorf("Export = %s", b.String())
+	}
+}
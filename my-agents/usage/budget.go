@@ -0,0 +1,338 @@
+package usage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// Budget scopes.
+const (
+	BudgetEvent   = "event"
+	BudgetSession = "session"
+	BudgetDay     = "day"
+)
+
+// BudgetRemainingPrefix starts the state metadata keys, one per configured
+// scope (e.g. "usage_budget_remaining_day"), that hold what is left of a
+// budget when an agent runs.
+const BudgetRemainingPrefix = "usage_budget_remaining_"
+
+// Budgets caps what the priced LLM calls may cost, in the currency of
+// [usage.prices]; zero leaves a scope uncapped. Budgets are kept in the
+// ledger, so every process sharing its directory spends from the same
+// ones. Each call reserves what it may cost there before it starts — its
+// prompt and MaxTokens of completion, or reservedCompletionTokens without
+// one — and starts only if that fits in every budget of its event, so
+// calls made together each see the others' holds. A call whose completion
+// runs past what it reserved goes over by the difference.
+type Budgets struct {
+	// PerEvent caps a run: the event that started it and those its agents
+	// routed on.
+	PerEvent float64 `toml:"per_event"`
+	// PerSession caps a session's runs, this month and last.
+	PerSession float64 `toml:"per_session"`
+	// PerDay caps every run of the UTC day.
+	PerDay float64 `toml:"per_day"`
+}
+
+// reservedCompletionTokens is the completion a call without MaxTokens
+// reserves budget for.
+const reservedCompletionTokens = 1024
+
+// holdTTL is how long a reservation holds budget without the call that
+// made it being recorded, after which its process is taken to have died.
+const holdTTL = 10 * time.Minute
+
+// BudgetExceededError is returned, and fails the run, when a call or an
+// agent would start with a budget spent, or a call would go over one.
+type BudgetExceededError struct {
+	Scope string  // BudgetEvent, BudgetSession or BudgetDay
+	Key   string  // the run ID, session ID or day
+	Spent float64 // spent and held by calls under way
+	Limit float64
+	Cost  float64 // what the refused call would have reserved, if a call
+}
+
+func (e *BudgetExceededError) Error() string {
+	msg := fmt.Sprintf("%s budget exceeded for %s: %.4f spent of %.4f", e.Scope, e.Key, e.Spent, e.Limit)
+	if e.Cost > 0 {
+		msg += fmt.Sprintf(", call needs %.4f", e.Cost)
+	}
+	return msg
+}
+
+// budget is one scope's cap and spend for an event.
+type budget struct {
+	scope, key   string
+	spent, limit float64
+}
+
+// budgetsOf returns the configured budgets that apply to who, without
+// their spend.
+func (m *Meter) budgetsOf(who attribution) []budget {
+	var out []budget
+	if m.budgets.PerEvent > 0 && who.runID != "" {
+		out = append(out, budget{scope: BudgetEvent, key: who.runID, limit: m.budgets.PerEvent})
+	}
+	if m.budgets.PerSession > 0 && who.session != "" {
+		out = append(out, budget{scope: BudgetSession, key: who.session, limit: m.budgets.PerSession})
+	}
+	if m.budgets.PerDay > 0 {
+		out = append(out, budget{scope: BudgetDay, key: day(time.Now()), limit: m.budgets.PerDay})
+	}
+	return out
+}
+
+func day(t time.Time) string { return t.UTC().Format(time.DateOnly) }
+
+// budgetKeys returns the keys of the budgets rec counts against.
+func budgetKeys(rec Record) []string {
+	keys := []string{BudgetDay + ":" + day(rec.Time)}
+	if rec.RunID != "" {
+		keys = append(keys, BudgetEvent+":"+rec.RunID)
+	}
+	if rec.Session != "" {
+		keys = append(keys, BudgetSession+":"+rec.Session)
+	}
+	return keys
+}
+
+// monthSpend is what a month's ledger file spent and holds per budget key,
+// as far as it has been read.
+type monthSpend struct {
+	file   os.FileInfo // to notice Forget replacing the file
+	offset int64
+	spent  map[string]float64
+	holds  map[string]Record // open reservations by ID
+}
+
+func (s *monthSpend) add(rec Record) {
+	switch rec.Kind {
+	case KindHold:
+		s.holds[rec.Hold] = rec
+		return
+	case KindRelease:
+		delete(s.holds, rec.Hold)
+		return
+	}
+	delete(s.holds, rec.Hold)
+	for _, key := range budgetKeys(rec) {
+		s.spent[key] += rec.Cost
+	}
+}
+
+// total is what key spent and holds at now.
+func (s *monthSpend) total(key string, now time.Time) float64 {
+	total := s.spent[key]
+	for id, hold := range s.holds {
+		switch {
+		case now.Sub(hold.Time) > holdTTL:
+			delete(s.holds, id)
+		case slices.Contains(budgetKeys(hold), key):
+			total += hold.Cost
+		}
+	}
+	return total
+}
+
+// refresh reads what was appended to month's file since it was last read,
+// by this process or another. l.mu must be held.
+func (l *Ledger) refresh(month string) (*monthSpend, error) {
+	f, err := os.Open(l.path(month))
+	if errors.Is(err, os.ErrNotExist) {
+		delete(l.spend, month)
+		return &monthSpend{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	s := l.spend[month]
+	if s == nil || !os.SameFile(s.file, info) || info.Size() < s.offset {
+		s = &monthSpend{spent: make(map[string]float64), holds: make(map[string]Record)}
+		l.spend[month] = s
+	}
+	s.file = info
+	if info.Size() == s.offset {
+		return s, nil
+	}
+	if _, err := f.Seek(s.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	// A line another process is still writing is read next time
+	data = data[:bytes.LastIndexByte(data, '\n')+1]
+	for line := range bytes.Lines(data) {
+		var rec Record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			return nil, fmt.Errorf("%s: %w", l.path(month), err)
+		}
+		s.add(rec)
+	}
+	s.offset += int64(len(data))
+	return s, nil
+}
+
+// spent sets what the ledger spent and holds against each of budgets, this
+// month and last. l.mu must be held.
+func (l *Ledger) spent(budgets []budget) error {
+	now := time.Now().UTC()
+	this := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for i := range budgets {
+		budgets[i].spent = 0
+	}
+	for _, month := range []string{Month(this.AddDate(0, -1, 0)), Month(this)} {
+		s, err := l.refresh(month)
+		if err != nil {
+			return err
+		}
+		for i, b := range budgets {
+			budgets[i].spent += s.total(b.scope+":"+b.key, now)
+		}
+	}
+	return nil
+}
+
+// reserve appends hold unless it would take a budget over its limit, in
+// which case it returns a *BudgetExceededError. Other processes sharing the
+// ledger wait meanwhile, so none reserves the same money.
+func (l *Ledger) reserve(hold Record, budgets []budget) error {
+	unlock, err := l.write()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := l.spent(budgets); err != nil {
+		return err
+	}
+	for _, b := range budgets {
+		if b.spent >= b.limit || b.spent+hold.Cost > b.limit {
+			return &BudgetExceededError{Scope: b.scope, Key: b.key, Spent: b.spent, Limit: b.limit, Cost: hold.Cost}
+		}
+	}
+	return l.append(hold)
+}
+
+// spend returns who's budgets with what the ledger spent and holds against
+// them.
+func (m *Meter) spend(who attribution) ([]budget, error) {
+	budgets := m.budgetsOf(who)
+	if len(budgets) == 0 {
+		return nil, nil
+	}
+	m.ledger.mu.Lock()
+	defer m.ledger.mu.Unlock()
+	if err := m.ledger.spent(budgets); err != nil {
+		return nil, fmt.Errorf("failed to read usage ledger for budgets: %w", err)
+	}
+	return budgets, nil
+}
+
+// check returns a *BudgetExceededError if a budget of who is spent.
+func (m *Meter) check(who attribution) error {
+	budgets, err := m.spend(who)
+	if err != nil {
+		return err
+	}
+	for _, b := range budgets {
+		if b.spent >= b.limit {
+			return &BudgetExceededError{Scope: b.scope, Key: b.key, Spent: b.spent, Limit: b.limit}
+		}
+	}
+	return nil
+}
+
+// reserve sets aside what agent's call of prompt may cost against the
+// budgets of the current event, returning the hold's ID, or "" without
+// budgets.
+func (m *Meter) reserve(agent string, prompt core.Prompt) (string, error) {
+	who := m.who()
+	budgets := m.budgetsOf(who)
+	if len(budgets) == 0 {
+		return "", nil
+	}
+	completion := reservedCompletionTokens
+	if n := prompt.Parameters.MaxTokens; n != nil && *n > 0 {
+		completion = int(*n)
+	}
+	id := make([]byte, 8)
+	rand.Read(id)
+	hold := Record{
+		Time:             time.Now(),
+		RunID:            who.runID,
+		Session:          who.session,
+		Tenant:           who.tenant,
+		User:             who.user,
+		Workflow:         who.workflow,
+		Agent:            agent,
+		Provider:         m.providers(agent),
+		PromptTokens:     (len(prompt.System) + len(prompt.User) + 3) / 4,
+		CompletionTokens: completion,
+		Estimated:        true,
+		Kind:             KindHold,
+		Hold:             hex.EncodeToString(id),
+	}
+	hold.Cost = m.prices[hold.Provider].Cost(hold.PromptTokens, hold.CompletionTokens)
+	if err := m.ledger.reserve(hold, budgets); err != nil {
+		return "", err
+	}
+	return hold.Hold, nil
+}
+
+// release gives back the budget a failed call reserved.
+func (m *Meter) release(hold string) {
+	if hold == "" {
+		return
+	}
+	if err := m.ledger.Append(Record{Time: time.Now(), Kind: KindRelease, Hold: hold}); err != nil {
+		core.Logger().Error().Str("hold", hold).Err(err).Msg("Failed to release budget; it frees up when the hold expires")
+	}
+}
+
+// stampRemaining sets what is left of who's budgets in state's metadata.
+func (m *Meter) stampRemaining(state core.State, who attribution) {
+	budgets, err := m.spend(who)
+	if err != nil {
+		core.Logger().Error().Err(err).Msg("Failed to stamp remaining budgets")
+		return
+	}
+	for _, b := range budgets {
+		state.SetMeta(BudgetRemainingPrefix+b.scope, strconv.FormatFloat(max(b.limit-b.spent, 0), 'f', 6, 64))
+	}
+}
+
+// RemainingBudgets reads what is left of each budget, by scope, from the
+// metadata of an agent's state or result.
+func RemainingBudgets(state core.State) map[string]float64 {
+	out := make(map[string]float64)
+	if state == nil {
+		return out
+	}
+	for _, scope := range []string{BudgetEvent, BudgetSession, BudgetDay} {
+		if v, ok := state.GetMeta(BudgetRemainingPrefix + scope); ok {
+			if left, err := strconv.ParseFloat(strings.TrimSpace(v), 64); err == nil {
+				out[scope] = left
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,138 @@
+package usage
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// llm answers every call with 100 prompt and 100 completion tokens, or
+// fails with err.
+type llm struct {
+	core.ModelProvider
+	err error
+}
+
+func (l llm) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	if l.err != nil {
+		return core.Response{}, l.err
+	}
+	return core.Response{Content: "ok", Usage: core.UsageStats{PromptTokens: 100, CompletionTokens: 100}}, nil
+}
+
+// meter creates a meter on the ledger in dir pricing tokens at 1 a
+// thousand, handling an event of session s.
+func meter(t *testing.T, dir string, budgets Budgets) *Meter {
+	t.Helper()
+	ledger, err := OpenLedger(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := Config{Prices: map[string]Price{"p": {PromptPer1K: 1, CompletionPer1K: 1}}, Budgets: budgets}
+	m := NewMeter(ledger, cfg, func(string) string { return "p" })
+	m.current = attribution{runID: "run", session: "s"}
+	return m
+}
+
+// maxTokens reserves 300 completion tokens, a hold of 0.302.
+var maxTokens = int32(300)
+
+func call(m *Meter, provider core.ModelProvider) error {
+	_, err := m.Middleware()("agent", provider).Call(context.Background(), core.Prompt{User: "hello", Parameters: core.ModelParameters{MaxTokens: &maxTokens}})
+	return err
+}
+
+func TestConcurrentCallsStayWithinBudget(t *testing.T) {
+	m := meter(t, t.TempDir(), Budgets{PerSession: 1})
+	var wg sync.WaitGroup
+	for range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var exceeded *BudgetExceededError
+			if err := call(m, llm{}); err != nil && !errors.As(err, &exceeded) {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	budgets, err := m.spend(m.who())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spent := budgets[0].spent; spent > 1 {
+		t.Errorf("spent %.3f of a budget of 1", spent)
+	}
+}
+
+func TestBudgetSharedThroughLedger(t *testing.T) {
+	dir := t.TempDir()
+	first := meter(t, dir, Budgets{PerDay: 0.5})
+	if err := call(first, llm{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Another replica, or this one restarted, sees what was spent
+	second := meter(t, dir, Budgets{PerDay: 0.5})
+	err := call(second, llm{})
+	var exceeded *BudgetExceededError
+	if !errors.As(err, &exceeded) || exceeded.Scope != BudgetDay {
+		t.Fatalf("call = %v, want the day's budget exceeded", err)
+	}
+	if exceeded.Spent < 0.199 || exceeded.Cost < 0.301 {
+		t.Errorf("exceeded = %+v, want 0.2 spent and the call needing 0.302", exceeded)
+	}
+}
+
+func TestFailedCallReleasesItsHold(t *testing.T) {
+	m := meter(t, t.TempDir(), Budgets{PerEvent: 0.35})
+	if err := call(m, llm{err: errors.New("status 500")}); err == nil {
+		t.Fatal("the failing call succeeded")
+	}
+	if err := call(m, llm{}); err != nil {
+		t.Errorf("call after a failed one = %v, want its hold released", err)
+	}
+}
+
+func TestExpiredHoldsFreeBudget(t *testing.T) {
+	m := meter(t, t.TempDir(), Budgets{PerSession: 0.5})
+	stale := Record{Time: time.Now().Add(-holdTTL - time.Minute), Session: "s", Kind: KindHold, Hold: "dead", Cost: 0.4}
+	if err := m.ledger.Append(stale); err != nil {
+		t.Fatal(err)
+	}
+	if err := call(m, llm{}); err != nil {
+		t.Errorf("call = %v, want the hold of a process that died ignored", err)
+	}
+}
+
+func TestRecordsLeaveOutHolds(t *testing.T) {
+	m := meter(t, t.TempDir(), Budgets{PerSession: 10})
+	call(m, llm{})
+	call(m, llm{err: errors.New("status 500")})
+
+	records, err := m.ledger.Records(Month(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Kind != "" || records[0].Hold == "" {
+		t.Errorf("records = %+v, want the one call, settling its hold", records)
+	}
+}
+
+func TestForgetResetsSpend(t *testing.T) {
+	m := meter(t, t.TempDir(), Budgets{PerSession: 0.35})
+	if err := call(m, llm{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.ledger.Forget("", map[string]bool{"s": true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := call(m, llm{}); err != nil {
+		t.Errorf("call = %v, want an erased session's spend forgotten", err)
+	}
+}
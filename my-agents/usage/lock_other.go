@@ -0,0 +1,12 @@
+//go:build !unix
+
+package usage
+
+import "os"
+
+// lockFile leaves the ledger to the process's own lock on platforms
+// without flock: replicas sharing a ledger directory there may each spend
+// what is left of a budget.
+func lockFile(f *os.File) error { return nil }
+
+func unlockFile(f *os.File) error { return nil }
@@ -0,0 +1,18 @@
+//go:build unix
+
+package usage
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockFile blocks until f is locked exclusively, against other processes
+// too.
+func lockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_EX)
+}
+
+func unlockFile(f *os.File) error {
+	return syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+}
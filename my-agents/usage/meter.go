@@ -0,0 +1,180 @@
+package usage
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+
+	"my-agents/flags"
+	"my-agents/history"
+	"my-agents/tenant"
+)
+
+// WorkflowKey is the event metadata key naming the workflow a run belongs
+// to. It defaults to the run's entry route.
+const WorkflowKey = "workflow"
+
+// attribution is who the event being handled is billed to.
+type attribution struct {
+	runID, session, tenant, user, workflow string
+}
+
+// Meter records every LLM call made by agents into a ledger, and keeps
+// totals per run and per agent in memory.
+type Meter struct {
+	ledger    *Ledger
+	prices    map[string]Price
+	budgets   Budgets
+	providers func(agent string) string // agent → provider name
+
+	mu      sync.Mutex
+	current attribution
+	tracker tracker
+}
+
+// NewMeter creates a meter. providers maps an agent to the provider name
+// its calls are priced by.
+func NewMeter(ledger *Ledger, cfg Config, providers func(agent string) string) *Meter {
+	return &Meter{ledger: ledger, prices: cfg.Prices, budgets: cfg.Budgets, providers: providers}
+}
+
+// Register tags entry events with their workflow and tracks whose event
+// the runner is handling; the default runner handles one at a time. With
+// budgets, each agent's state carries what is left of them in its
+// metadata for the callbacks after this one.
+func (m *Meter) Register(runner core.Runner) error {
+	err := runner.RegisterCallback(core.HookBeforeEventHandling, "usage", func(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+		event := args.Event
+		if event == nil {
+			return args.State, nil
+		}
+		workflow, _ := event.GetMetadataValue(WorkflowKey)
+		if workflow == "" {
+			workflow, _ = event.GetMetadataValue(core.RouteMetadataKey)
+			event.SetMetadata(WorkflowKey, workflow)
+		}
+		who := attributionOf(event)
+		m.mu.Lock()
+		m.current = who
+		m.mu.Unlock()
+		return args.State, nil
+	})
+	if err != nil {
+		return err
+	}
+	if m.budgets == (Budgets{}) {
+		return nil
+	}
+	return runner.RegisterCallback(core.HookBeforeAgentRun, "usage-budget", func(ctx context.Context, args core.CallbackArgs) (core.State, error) {
+		if args.Event != nil && args.State != nil {
+			m.stampRemaining(args.State, attributionOf(args.Event))
+		}
+		return args.State, nil
+	})
+}
+
+func attributionOf(event core.Event) attribution {
+	runID, _ := event.GetMetadataValue(history.RunIDKey)
+	session, _ := event.GetMetadataValue(core.SessionIDKey)
+	user, _ := event.GetMetadataValue(flags.UserKey)
+	workflow, _ := event.GetMetadataValue(WorkflowKey)
+	return attribution{runID: runID, session: session, tenant: tenant.FromEvent(event), user: user, workflow: workflow}
+}
+
+// Middleware meters each agent's provider, failing calls that don't fit in
+// what is left of a budget of the current event. It has the shape of di.LLMMiddleware.
+func (m *Meter) Middleware() func(agent string, llm core.ModelProvider) core.ModelProvider {
+	return func(agent string, llm core.ModelProvider) core.ModelProvider {
+		return &metered{ModelProvider: llm, m: m, agent: agent}
+	}
+}
+
+// record appends agent's call to the ledger, settling its hold.
+func (m *Meter) record(agent string, prompt core.Prompt, resp core.Response, hold string) {
+	m.mu.Lock()
+	who := m.current
+	m.mu.Unlock()
+
+	rec := Record{
+		Time:             time.Now(),
+		RunID:            who.runID,
+		Session:          who.session,
+		Tenant:           who.tenant,
+		User:             who.user,
+		Workflow:         who.workflow,
+		Agent:            agent,
+		Provider:         m.providers(agent),
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		Hold:             hold,
+	}
+	if rec.Tokens() == 0 {
+		// About four characters per token
+		rec.PromptTokens = (len(prompt.System) + len(prompt.User) + 3) / 4
+		rec.CompletionTokens = (len(resp.Content) + 3) / 4
+		rec.Estimated = true
+	}
+	rec.Cost = m.prices[rec.Provider].Cost(rec.PromptTokens, rec.CompletionTokens)
+	if err := m.ledger.Append(rec); err != nil {
+		core.Logger().Error().Str("agent", agent).Err(err).Msg("Failed to record usage")
+	}
+	m.mu.Lock()
+	m.tracker.track(rec)
+	m.mu.Unlock()
+}
+
+// who returns whose event the runner is handling.
+func (m *Meter) who() attribution {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.current
+}
+
+type metered struct {
+	core.ModelProvider
+	m     *Meter
+	agent string
+}
+
+// Unwrap returns the provider wrapped, for the LLM cache to see through.
+func (p *metered) Unwrap() core.ModelProvider { return p.ModelProvider }
+
+func (p *metered) Call(ctx context.Context, prompt core.Prompt) (core.Response, error) {
+	hold, err := p.m.reserve(p.agent, prompt)
+	if err != nil {
+		return core.Response{}, err
+	}
+	resp, err := p.ModelProvider.Call(ctx, prompt)
+	if err != nil {
+		p.m.release(hold)
+		return resp, err
+	}
+	p.m.record(p.agent, prompt, resp, hold)
+	return resp, nil
+}
+
+func (p *metered) Stream(ctx context.Context, prompt core.Prompt) (<-chan core.Token, error) {
+	hold, err := p.m.reserve(p.agent, prompt)
+	if err != nil {
+		return nil, err
+	}
+	in, err := p.ModelProvider.Stream(ctx, prompt)
+	if err != nil {
+		p.m.release(hold)
+		return nil, err
+	}
+	out := make(chan core.Token)
+	go func() {
+		defer close(out)
+		var b strings.Builder
+		for tok := range in {
+			b.WriteString(tok.Content)
+			out <- tok
+		}
+		p.m.record(p.agent, prompt, core.Response{Content: b.String()}, hold)
+	}()
+	return out, nil
+}
@@ -0,0 +1,203 @@
+package usage
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dimensions a report can group by.
+const (
+	ByTenant   = "tenant"
+	ByUser     = "user"
+	ByWorkflow = "workflow"
+	ByAgent    = "agent"
+	ByProvider = "provider"
+)
+
+// Row is the aggregated usage of one group in a month. Fields of
+// dimensions not grouped by are empty.
+type Row struct {
+	Month            string  `json:"month"`
+	Tenant           string  `json:"tenant,omitempty"`
+	User             string  `json:"user,omitempty"`
+	Workflow         string  `json:"workflow,omitempty"`
+	Agent            string  `json:"agent,omitempty"`
+	Provider         string  `json:"provider,omitempty"`
+	Runs             int     `json:"runs"`
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Tokens           int     `json:"tokens"`
+	Cost             float64 `json:"cost"`
+}
+
+// ParseDimensions splits a comma-separated dimension list, rejecting
+// unknown names.
+func ParseDimensions(s string) ([]string, error) {
+	var dims []string
+	for _, d := range strings.Split(s, ",") {
+		switch d = strings.TrimSpace(d); d {
+		case "":
+		case ByTenant, ByUser, ByWorkflow, ByAgent, ByProvider:
+			dims = append(dims, d)
+		default:
+			return nil, fmt.Errorf("unknown usage dimension %q", d)
+		}
+	}
+	return dims, nil
+}
+
+// Aggregate groups records by the given dimensions, sorted by cost.
+func Aggregate(month string, records []Record, by []string) []Row {
+	rows := make(map[Row]*Row)
+	runs := make(map[Row]map[string]bool)
+	for _, rec := range records {
+		key := Row{Month: month}
+		for _, d := range by {
+			switch d {
+			case ByTenant:
+				key.Tenant = rec.Tenant
+			case ByUser:
+				key.User = rec.User
+			case ByWorkflow:
+				key.Workflow = rec.Workflow
+			case ByAgent:
+				key.Agent = rec.Agent
+			case ByProvider:
+				key.Provider = rec.Provider
+			}
+		}
+		row, ok := rows[key]
+		if !ok {
+			copied := key
+			row = &copied
+			rows[key] = row
+			runs[key] = make(map[string]bool)
+		}
+		row.Calls++
+		row.PromptTokens += rec.PromptTokens
+		row.CompletionTokens += rec.CompletionTokens
+		row.Tokens += rec.Tokens()
+		row.Cost += rec.Cost
+		if rec.RunID != "" && !runs[key][rec.RunID] {
+			runs[key][rec.RunID] = true
+			row.Runs++
+		}
+	}
+
+	out := make([]Row, 0, len(rows))
+	for _, row := range rows {
+		out = append(out, *row)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Cost != out[j].Cost {
+			return out[i].Cost > out[j].Cost
+		}
+		return out[i].Tokens > out[j].Tokens
+	})
+	return out
+}
+
+// Report aggregates a month of the ledger.
+func (l *Ledger) Report(month string, by []string) ([]Row, error) {
+	records, err := l.Records(month)
+	if err != nil {
+		return nil, err
+	}
+	return Aggregate(month, records, by), nil
+}
+
+// WriteCSV writes rows with a header of the grouped dimensions and totals.
+func WriteCSV(w io.Writer, rows []Row, by []string) error {
+	cw := csv.NewWriter(w)
+	header := append([]string{"month"}, by...)
+	header = append(header, "runs", "calls", "prompt_tokens", "completion_tokens", "tokens", "cost")
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := []string{row.Month}
+		for _, d := range by {
+			record = append(record, row.dimension(d))
+		}
+		record = append(record,
+			strconv.Itoa(row.Runs), strconv.Itoa(row.Calls), strconv.Itoa(row.PromptTokens),
+			strconv.Itoa(row.CompletionTokens), strconv.Itoa(row.Tokens), strconv.FormatFloat(row.Cost, 'f', 6, 64))
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes rows as an indented JSON array.
+func WriteJSON(w io.Writer, rows []Row) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rows)
+}
+
+func (r Row) dimension(d string) string {
+	switch d {
+	case ByTenant:
+		return r.Tenant
+	case ByUser:
+		return r.User
+	case ByWorkflow:
+		return r.Workflow
+	case ByAgent:
+		return r.Agent
+	case ByProvider:
+		return r.Provider
+	}
+	return ""
+}
+
+// Handler serves reports: GET ?month=YYYY-MM&by=tenant,user&format=json|csv.
+// The month defaults to the current one and the grouping to tenant.
+func (l *Ledger) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q := r.URL.Query()
+		month := q.Get("month")
+		if month == "" {
+			month = Month(time.Now())
+		} else if _, err := time.Parse("2006-01", month); err != nil {
+			http.Error(w, "month must be YYYY-MM", http.StatusBadRequest)
+			return
+		}
+		by, err := ParseDimensions(q.Get("by"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(by) == 0 {
+			by = []string{ByTenant}
+		}
+		rows, err := l.Report(month, by)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if q.Get("format") == "csv" {
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=usage-%s.csv", month))
+			WriteCSV(w, rows, by)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		WriteJSON(w, rows)
+	})
+}
@@ -0,0 +1,205 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/kunalkushwaha/agenticgokit/core"
+)
+
+// State metadata keys an agent's result carries its run's usage so far
+// under, so the terminal agent's result holds the run's totals.
+const (
+	CallsKey            = "usage_calls"
+	PromptTokensKey     = "usage_prompt_tokens"
+	CompletionTokensKey = "usage_completion_tokens"
+	CostKey             = "usage_cost"
+)
+
+// trackedRuns is how many recent runs the meter keeps totals of in memory;
+// the ledger keeps them all.
+const trackedRuns = 1000
+
+// Totals is the usage of a set of LLM calls.
+type Totals struct {
+	Calls            int     `json:"calls"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Tokens           int     `json:"tokens"`
+	Cost             float64 `json:"cost"`
+	Estimated        bool    `json:"estimated,omitempty"` // some token counts were estimated
+}
+
+func (t *Totals) add(rec Record) {
+	t.Calls++
+	t.PromptTokens += rec.PromptTokens
+	t.CompletionTokens += rec.CompletionTokens
+	t.Tokens += rec.Tokens()
+	t.Cost += rec.Cost
+	t.Estimated = t.Estimated || rec.Estimated
+}
+
+// RunUsage is the usage of one run — the event that started it and the
+// events its agents routed on — in all and per agent.
+type RunUsage struct {
+	RunID string `json:"run_id"`
+	Totals
+	Agents map[string]Totals `json:"agents"`
+}
+
+// Report is the usage metered since the process started: in all, per
+// agent, and per recent run, most recent last.
+type Report struct {
+	Totals
+	Agents map[string]Totals `json:"agents"`
+	Runs   []RunUsage        `json:"runs"`
+}
+
+// tracker aggregates records per run and per agent in memory.
+type tracker struct {
+	total  Totals
+	agents map[string]Totals
+	runs   map[string]*RunUsage
+	order  []string // run IDs, oldest first
+}
+
+// track adds rec to the totals. The meter's lock must be held.
+func (t *tracker) track(rec Record) {
+	if t.agents == nil {
+		t.agents = make(map[string]Totals)
+		t.runs = make(map[string]*RunUsage)
+	}
+	t.total.add(rec)
+	agent := t.agents[rec.Agent]
+	agent.add(rec)
+	t.agents[rec.Agent] = agent
+	if rec.RunID == "" {
+		return
+	}
+	run, ok := t.runs[rec.RunID]
+	if !ok {
+		if len(t.order) == trackedRuns {
+			delete(t.runs, t.order[0])
+			t.order = t.order[1:]
+		}
+		run = &RunUsage{RunID: rec.RunID, Agents: make(map[string]Totals)}
+		t.runs[rec.RunID] = run
+		t.order = append(t.order, rec.RunID)
+	}
+	run.add(rec)
+	agent = run.Agents[rec.Agent]
+	agent.add(rec)
+	run.Agents[rec.Agent] = agent
+}
+
+// RunUsage returns the usage of the run runID, if it is among the recent
+// runs the meter tracks.
+func (m *Meter) RunUsage(runID string) (RunUsage, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	run, ok := m.tracker.runs[runID]
+	if !ok {
+		return RunUsage{}, false
+	}
+	return copyRun(run), true
+}
+
+// UsageReport returns the usage metered since the process started.
+func (m *Meter) UsageReport() Report {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	rep := Report{Totals: m.tracker.total, Agents: make(map[string]Totals, len(m.tracker.agents))}
+	for agent, t := range m.tracker.agents {
+		rep.Agents[agent] = t
+	}
+	rep.Runs = make([]RunUsage, len(m.tracker.order))
+	for i, id := range m.tracker.order {
+		rep.Runs[i] = copyRun(m.tracker.runs[id])
+	}
+	return rep
+}
+
+func copyRun(run *RunUsage) RunUsage {
+	out := *run
+	out.Agents = make(map[string]Totals, len(run.Agents))
+	for agent, t := range run.Agents {
+		out.Agents[agent] = t
+	}
+	return out
+}
+
+// Wrap wraps an agent so it fails with a *BudgetExceededError instead of
+// running once a budget of its event is spent, and its result carries its
+// run's usage so far in the state metadata, under CallsKey,
+// PromptTokensKey, CompletionTokensKey and CostKey, with what is left of
+// the budgets.
+func (m *Meter) Wrap(h core.AgentHandler) core.AgentHandler {
+	return core.AgentHandlerFunc(func(ctx context.Context, event core.Event, state core.State) (core.AgentResult, error) {
+		who := attributionOf(event)
+		if err := m.check(who); err != nil {
+			return core.AgentResult{}, err
+		}
+		result, err := h.Run(ctx, event, state)
+		if err != nil || result.OutputState == nil {
+			return result, err
+		}
+		m.stampRemaining(result.OutputState, who)
+		if run, ok := m.RunUsage(who.runID); ok {
+			result.OutputState.SetMeta(CallsKey, strconv.Itoa(run.Calls))
+			result.OutputState.SetMeta(PromptTokensKey, strconv.Itoa(run.PromptTokens))
+			result.OutputState.SetMeta(CompletionTokensKey, strconv.Itoa(run.CompletionTokens))
+			result.OutputState.SetMeta(CostKey, strconv.FormatFloat(run.Cost, 'f', 6, 64))
+		}
+		return result, err
+	})
+}
+
+// FromResult reads the run's usage an annotated agent's result carries.
+func FromResult(result core.AgentResult) (Totals, bool) {
+	if result.OutputState == nil {
+		return Totals{}, false
+	}
+	calls, ok := result.OutputState.GetMeta(CallsKey)
+	if !ok {
+		return Totals{}, false
+	}
+	var t Totals
+	t.Calls, _ = strconv.Atoi(calls)
+	prompt, _ := result.OutputState.GetMeta(PromptTokensKey)
+	t.PromptTokens, _ = strconv.Atoi(prompt)
+	completion, _ := result.OutputState.GetMeta(CompletionTokensKey)
+	t.CompletionTokens, _ = strconv.Atoi(completion)
+	cost, _ := result.OutputState.GetMeta(CostKey)
+	t.Cost, _ = strconv.ParseFloat(cost, 64)
+	t.Tokens = t.PromptTokens + t.CompletionTokens
+	return t, true
+}
+
+// RunsHandler serves the in-memory usage: GET /admin/usage/runs reports it
+// all, GET /admin/usage/runs/{run} one run's.
+func (m *Meter) RunsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var v any
+		if id := r.PathValue("run"); id != "" {
+			run, ok := m.RunUsage(id)
+			if !ok {
+				http.Error(w, "no usage tracked for run "+id, http.StatusNotFound)
+				return
+			}
+			v = run
+		} else {
+			v = m.UsageReport()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		enc.Encode(v)
+	})
+}
@@ -0,0 +1,260 @@
+// Package usage meters LLM calls into a monthly ledger attributed to tenant,
+// user and workflow, and aggregates it into usage reports for charge-back
+// and invoicing. Totals per run and per agent are kept in memory too, and
+// carried on the agents' results.
+package usage
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config is the [usage] section of agentflow.toml:
+//
+//	[usage]
+//	enabled = true
+//	[usage.prices.default]
+//	prompt_per_1k = 0.0005
+//	completion_per_1k = 0.0015
+//	[usage.budgets]
+//	per_event = 0.25
+//	per_day = 50.0
+type Config struct {
+	Enabled bool             `toml:"enabled"`
+	Path    string           `toml:"path"`   // ledger directory (default .agentflow/usage)
+	Prices  map[string]Price `toml:"prices"` // by provider name
+	Budgets Budgets          `toml:"budgets"`
+}
+
+// Price is what a provider charges per thousand tokens.
+type Price struct {
+	PromptPer1K     float64 `toml:"prompt_per_1k"`
+	CompletionPer1K float64 `toml:"completion_per_1k"`
+}
+
+// Cost prices a call.
+func (p Price) Cost(promptTokens, completionTokens int) float64 {
+	return (p.PromptPer1K*float64(promptTokens) + p.CompletionPer1K*float64(completionTokens)) / 1000
+}
+
+// Record is one metered LLM call.
+type Record struct {
+	Time             time.Time `json:"time"`
+	RunID            string    `json:"run_id,omitempty"`
+	Session          string    `json:"session,omitempty"`
+	Tenant           string    `json:"tenant,omitempty"`
+	User             string    `json:"user,omitempty"`
+	Workflow         string    `json:"workflow,omitempty"`
+	Agent            string    `json:"agent"`
+	Provider         string    `json:"provider"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	Estimated        bool      `json:"estimated,omitempty"` // token counts estimated from text length
+	Cost             float64   `json:"cost"`
+	// Kind is "" for a call. KindHold and KindRelease records reserve
+	// budget for a call and give it back when the call fails; Records
+	// leaves them out.
+	Kind string `json:"kind,omitempty"`
+	// Hold is the reservation's ID, on the hold and on the call or
+	// release that settles it.
+	Hold string `json:"hold,omitempty"`
+}
+
+// Record kinds other than calls.
+const (
+	KindHold    = "hold"
+	KindRelease = "release"
+)
+
+// Tokens is the call's total token count.
+func (r Record) Tokens() int { return r.PromptTokens + r.CompletionTokens }
+
+// Month is the YYYY-MM a record is billed in.
+func Month(t time.Time) string { return t.UTC().Format("2006-01") }
+
+// Ledger appends records to one JSON-lines file per month. Processes
+// sharing its directory take turns writing, so the budgets of each see
+// what the others spent and hold.
+type Ledger struct {
+	dir  string
+	lock *os.File // locked while writing, against other processes
+
+	mu    sync.Mutex
+	spend map[string]*monthSpend // by month, as far as read
+}
+
+// OpenLedger opens the ledger in dir (default .agentflow/usage).
+func OpenLedger(dir string) (*Ledger, error) {
+	if dir == "" {
+		dir = filepath.Join(".agentflow", "usage")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create usage directory %s: %w", dir, err)
+	}
+	lock, err := os.OpenFile(filepath.Join(dir, ".lock"), os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open usage lock: %w", err)
+	}
+	return &Ledger{dir: dir, lock: lock, spend: make(map[string]*monthSpend)}, nil
+}
+
+// write takes the ledger for writing, here and in the other processes
+// sharing its directory, returning the function that gives it back.
+func (l *Ledger) write() (func(), error) {
+	l.mu.Lock()
+	if err := lockFile(l.lock); err != nil {
+		l.mu.Unlock()
+		return nil, fmt.Errorf("failed to lock usage ledger: %w", err)
+	}
+	return func() {
+		unlockFile(l.lock)
+		l.mu.Unlock()
+	}, nil
+}
+
+func (l *Ledger) path(month string) string {
+	return filepath.Join(l.dir, month+".jsonl")
+}
+
+// Append adds rec to its month's file.
+func (l *Ledger) Append(rec Record) error {
+	unlock, err := l.write()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	return l.append(rec)
+}
+
+// append adds rec to its month's file. The ledger must be taken for
+// writing.
+func (l *Ledger) append(rec Record) error {
+	line, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(l.path(Month(rec.Time)), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Records returns every call billed in month.
+func (l *Ledger) Records(month string) ([]Record, error) {
+	l.mu.Lock()
+	records, err := l.read(month)
+	l.mu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	calls := records[:0]
+	for _, rec := range records {
+		if rec.Kind == "" {
+			calls = append(calls, rec)
+		}
+	}
+	return calls, nil
+}
+
+// read returns month's records, holds and releases included. l.mu must be
+// held.
+func (l *Ledger) read(month string) ([]Record, error) {
+	f, err := os.Open(l.path(month))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []Record
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", l.path(month), n, err)
+		}
+		records = append(records, rec)
+	}
+	return records, scanner.Err()
+}
+
+// Forget strips the user, and the runs and sessions, from the records of
+// user or of any of sessions, returning how many it changed. The records
+// stay, attributed to their tenant and workflow, so totals already billed
+// still add up.
+func (l *Ledger) Forget(user string, sessions map[string]bool) (int, error) {
+	unlock, err := l.write()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+	files, err := filepath.Glob(filepath.Join(l.dir, "*.jsonl"))
+	if err != nil {
+		return 0, err
+	}
+	forgotten := 0
+	for _, file := range files {
+		month := strings.TrimSuffix(filepath.Base(file), ".jsonl")
+		records, err := l.read(month)
+		if err != nil {
+			return forgotten, err
+		}
+		changed := 0
+		for i, rec := range records {
+			if (user == "" || rec.User != user) && !sessions[rec.Session] {
+				continue
+			}
+			records[i].User, records[i].Session, records[i].RunID = "", "", ""
+			changed++
+		}
+		if changed == 0 {
+			continue
+		}
+		if err := l.rewrite(month, records); err != nil {
+			return forgotten, err
+		}
+		forgotten += changed
+	}
+	return forgotten, nil
+}
+
+// rewrite replaces month's file with records. The ledger must be taken for
+// writing.
+func (l *Ledger) rewrite(month string, records []Record) error {
+	tmp, err := os.CreateTemp(l.dir, month+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	w := bufio.NewWriter(tmp)
+	enc := json.NewEncoder(w)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), l.path(month))
+}
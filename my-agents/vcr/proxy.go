@@ -0,0 +1,20 @@
+package vcr
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+)
+
+// Proxy serves upstream through t: a provider configured with the proxy's
+// address as its base URL or endpoint has its traffic recorded or replayed.
+func Proxy(t *Transport, upstream *url.URL) http.Handler {
+	t.Hosts = append(t.Hosts, upstream.Host)
+	return &httputil.ReverseProxy{
+		Rewrite: func(r *httputil.ProxyRequest) {
+			r.SetURL(upstream)
+			r.Out.Host = upstream.Host
+		},
+		Transport: t,
+	}
+}
@@ -0,0 +1,29 @@
+package vcr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestProxy(t *testing.T) {
+	srv, n := upstream(t)
+	u, _ := url.Parse(srv.URL)
+	tr := transport(t, ModeAuto, filepath.Join(t.TempDir(), "dev.json"))
+	proxy := httptest.NewServer(Proxy(tr, u))
+	defer proxy.Close()
+
+	for range 2 {
+		if got, err := post(t, http.DefaultClient, proxy.URL+"/api/generate", "hi"); err != nil || got != "hi #1" {
+			t.Errorf("proxied %q, %v", got, err)
+		}
+	}
+	if n.Load() != 1 || tr.Cassette.Len() != 1 {
+		t.Errorf("upstream saw %d requests, cassette holds %d", n.Load(), tr.Cassette.Len())
+	}
+	if len(tr.Hosts) != 1 || tr.Hosts[0] != u.Host {
+		t.Errorf("hosts = %v", tr.Hosts)
+	}
+}
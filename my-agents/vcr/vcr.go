@@ -0,0 +1,319 @@
+// Package vcr records provider HTTP traffic to cassette files and serves it
+// back, so the pipeline can be developed offline against realistic
+// responses. Credentials are stripped before anything is written. Traffic is
+// captured in-process by replacing the default HTTP transport, or through a
+// local reverse proxy for clients configured with a base URL.
+package vcr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Modes.
+const (
+	// ModeRecord sends every request upstream and records it.
+	ModeRecord = "record"
+	// ModeReplay serves requests from the cassette and fails those it
+	// doesn't hold, without touching the network.
+	ModeReplay = "replay"
+	// ModeAuto replays what the cassette holds and records the rest.
+	ModeAuto = "auto"
+)
+
+// DefaultDir is where cassettes are kept unless configured.
+const DefaultDir = ".agentflow/cassettes"
+
+// ModeEnv overrides the configured mode, e.g. AGENTFLOW_VCR=replay to work
+// offline for a session.
+const ModeEnv = "AGENTFLOW_VCR"
+
+// Redacted replaces credentials in recorded requests.
+const Redacted = "REDACTED"
+
+// ErrNotRecorded is returned in replay mode for requests the cassette has
+// no response for.
+var ErrNotRecorded = errors.New("request not recorded in cassette")
+
+// Config is the [vcr] section of agentflow.toml. Hosts defaults to the
+// hosts of the configured providers.
+//
+//	[vcr]
+//	mode = "auto"
+//	cassette = "dev"
+//	hosts = ["api.openai.com", "localhost:11434"]
+type Config struct {
+	Mode     string   `toml:"mode"`     // ModeRecord, ModeReplay or ModeAuto; empty disables recording
+	Dir      string   `toml:"dir"`      // default DefaultDir
+	Cassette string   `toml:"cassette"` // file name without extension (default "default")
+	Hosts    []string `toml:"hosts"`    // host[:port] of provider traffic
+}
+
+// Request is a recorded request with its credentials removed.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Response is a recorded response.
+type Response struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// Interaction is one request and the response it got.
+type Interaction struct {
+	Request    Request       `json:"request"`
+	Response   Response      `json:"response"`
+	RecordedAt time.Time     `json:"recorded_at"`
+	Duration   time.Duration `json:"duration_ns"`
+}
+
+// Cassette is a file of recorded interactions.
+type Cassette struct {
+	path string
+
+	mu           sync.Mutex
+	interactions []Interaction
+	played       map[string]int // match key → times replayed
+}
+
+// Load opens the cassette at path; a missing file is an empty cassette.
+func Load(path string) (*Cassette, error) {
+	c := &Cassette{path: path, played: make(map[string]int)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+	var file struct {
+		Interactions []Interaction `json:"interactions"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode cassette %s: %w", path, err)
+	}
+	c.interactions = file.Interactions
+	return c, nil
+}
+
+// Len returns the number of recorded interactions.
+func (c *Cassette) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.interactions)
+}
+
+// find returns the recorded response to req. Identical requests are
+// answered with their recordings in order, the last one repeating.
+func (c *Cassette) find(req Request) (Interaction, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := matchKey(req)
+	var matches []int
+	for i, in := range c.interactions {
+		if matchKey(in.Request) == key {
+			matches = append(matches, i)
+		}
+	}
+	if len(matches) == 0 {
+		return Interaction{}, false
+	}
+	n := c.played[key]
+	c.played[key]++
+	return c.interactions[matches[min(n, len(matches)-1)]], true
+}
+
+// add records an interaction and rewrites the cassette file.
+func (c *Cassette) add(in Interaction) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.interactions = append(c.interactions, in)
+	// A request recorded now has been played once
+	c.played[matchKey(in.Request)]++
+	data, err := json.MarshalIndent(struct {
+		Interactions []Interaction `json:"interactions"`
+	}{c.interactions}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+func matchKey(req Request) string {
+	return req.Method + " " + req.URL + "\n" + req.Body
+}
+
+// Transport is an http.RoundTripper that records and replays requests to
+// the configured hosts and passes everything else to Next.
+type Transport struct {
+	Mode     string
+	Cassette *Cassette
+	Hosts    []string // empty matches every host
+	Next     http.RoundTripper
+}
+
+// New creates a transport from cfg, opening its cassette. hosts is used
+// when cfg names none.
+func New(cfg Config, hosts []string) (*Transport, error) {
+	switch cfg.Mode {
+	case ModeRecord, ModeReplay, ModeAuto:
+	default:
+		return nil, fmt.Errorf("unknown vcr mode %q", cfg.Mode)
+	}
+	dir, name := cfg.Dir, cfg.Cassette
+	if dir == "" {
+		dir = DefaultDir
+	}
+	if name == "" {
+		name = "default"
+	}
+	cas, err := Load(filepath.Join(dir, filepath.Base(name)+".json"))
+	if err != nil {
+		return nil, err
+	}
+	if len(cfg.Hosts) > 0 {
+		hosts = cfg.Hosts
+	}
+	return &Transport{Mode: cfg.Mode, Cassette: cas, Hosts: hosts, Next: http.DefaultTransport}, nil
+}
+
+// WithEnv returns cfg with its mode overridden by ModeEnv when set.
+func (cfg Config) WithEnv() Config {
+	if env := os.Getenv(ModeEnv); env != "" {
+		cfg.Mode = env
+	}
+	return cfg
+}
+
+// Install makes t the default HTTP transport, so every client without its
+// own transport goes through it, and returns a function restoring the
+// previous one.
+func Install(t *Transport) (restore func()) {
+	prev := http.DefaultTransport
+	if t.Next == nil || t.Next == t {
+		t.Next = prev
+	}
+	http.DefaultTransport = t
+	return func() { http.DefaultTransport = prev }
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if len(t.Hosts) > 0 && !slices.Contains(t.Hosts, r.URL.Host) && !slices.Contains(t.Hosts, r.URL.Hostname()) {
+		return t.Next.RoundTrip(r)
+	}
+	var body []byte
+	if r.Body != nil {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+		r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	req := sanitize(r, body)
+
+	if t.Mode != ModeRecord {
+		if in, ok := t.Cassette.find(req); ok {
+			return in.Response.http(r), nil
+		}
+		if t.Mode == ModeReplay {
+			return nil, fmt.Errorf("%s %s: %w", r.Method, req.URL, ErrNotRecorded)
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.Next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	in := Interaction{
+		Request:    req,
+		Response:   Response{Status: resp.StatusCode, Header: redactHeader(resp.Header), Body: string(data)},
+		RecordedAt: start,
+		Duration:   time.Since(start),
+	}
+	if err := t.Cassette.add(in); err != nil {
+		return nil, fmt.Errorf("failed to record %s %s: %w", r.Method, req.URL, err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	return resp, nil
+}
+
+// http rebuilds a recorded response for r.
+func (resp Response) http(r *http.Request) *http.Response {
+	header := resp.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", resp.Status, http.StatusText(resp.Status)),
+		StatusCode:    resp.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(resp.Body)),
+		ContentLength: int64(len(resp.Body)),
+		Request:       r,
+	}
+}
+
+// Credentials never written to a cassette.
+var (
+	secretHeaders = []string{"Authorization", "Proxy-Authorization", "Api-Key", "X-Api-Key", "X-Goog-Api-Key", "X-Amz-Security-Token", "Cookie", "Set-Cookie", "Openai-Organization"}
+	secretParams  = []string{"key", "api_key", "apikey", "access_token", "token", "X-Amz-Security-Token"}
+)
+
+// sanitize records r without its credentials.
+func sanitize(r *http.Request, body []byte) Request {
+	u := *r.URL
+	q := u.Query()
+	for _, p := range secretParams {
+		if q.Has(p) {
+			q.Set(p, Redacted)
+		}
+	}
+	u.RawQuery = q.Encode()
+	if u.User != nil {
+		u.User = url.User(Redacted)
+	}
+	return Request{Method: r.Method, URL: u.String(), Header: redactHeader(r.Header), Body: string(body)}
+}
+
+func redactHeader(h http.Header) http.Header {
+	out := h.Clone()
+	for _, name := range secretHeaders {
+		if out.Get(name) != "" {
+			out.Set(name, Redacted)
+		}
+	}
+	return out
+}